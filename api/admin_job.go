@@ -0,0 +1,266 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/uber/aresdb/utils"
+)
+
+// AdminJobState represents the state of an admin job.
+type AdminJobState string
+
+const (
+	// AdminJobPending means the job is waiting to be executed.
+	AdminJobPending AdminJobState = "pending"
+	// AdminJobRunning means the job is being executed.
+	AdminJobRunning AdminJobState = "running"
+	// AdminJobSucceeded means the job finished without error.
+	AdminJobSucceeded AdminJobState = "succeeded"
+	// AdminJobFailed means the job finished with error.
+	AdminJobFailed AdminJobState = "failed"
+	// AdminJobCancelled means the job was cancelled before finishing.
+	AdminJobCancelled AdminJobState = "cancelled"
+)
+
+const (
+	// maxAdminJobLogs is the max number of log lines kept for each job.
+	maxAdminJobLogs = 100
+	// maxFinishedAdminJobs is the max number of finished jobs kept for inspection.
+	maxFinishedAdminJobs = 100
+)
+
+// errAdminJobCancelled is returned by job steps when the job has been cancelled.
+var errAdminJobCancelled = fmt.Errorf("job cancelled")
+
+// AdminJobRunner runs the actual work of an admin job. It should report progress
+// via job.SetProgress and check job.Cancelled() between steps.
+type AdminJobRunner func(job *AdminJob) error
+
+// AdminJobStatus is the externally visible status of an admin job.
+type AdminJobStatus struct {
+	ID         string        `json:"id"`
+	Type       string        `json:"type"`
+	Table      string        `json:"table"`
+	Shard      int           `json:"shard"`
+	State      AdminJobState `json:"state"`
+	Progress   float64       `json:"progress"`
+	Error      string        `json:"error,omitempty"`
+	Logs       []string      `json:"logs"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	StartedAt  *time.Time    `json:"startedAt,omitempty"`
+	FinishedAt *time.Time    `json:"finishedAt,omitempty"`
+}
+
+// AdminJob is a long running admin operation (purge, archiving, preload etc)
+// submitted via the jobs api.
+type AdminJob struct {
+	sync.RWMutex
+	status AdminJobStatus
+	// seq is the submission order of the job.
+	seq        int64
+	cancelChan chan struct{}
+	cancelOnce sync.Once
+}
+
+// Logf appends a log line to the job and writes it to the server log.
+func (job *AdminJob) Logf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	utils.GetLogger().With("job", job.status.ID, "type", job.status.Type, "table", job.status.Table, "shard", job.status.Shard).Info(msg)
+	job.Lock()
+	defer job.Unlock()
+	job.status.Logs = append(job.status.Logs, fmt.Sprintf("%s %s", utils.Now().UTC().Format(time.RFC3339), msg))
+	if len(job.status.Logs) > maxAdminJobLogs {
+		job.status.Logs = job.status.Logs[len(job.status.Logs)-maxAdminJobLogs:]
+	}
+}
+
+// SetProgress sets the progress of the job, which is between 0 and 1.
+func (job *AdminJob) SetProgress(progress float64) {
+	job.Lock()
+	job.status.Progress = progress
+	job.Unlock()
+}
+
+// Cancelled returns whether cancellation of the job has been requested.
+func (job *AdminJob) Cancelled() bool {
+	select {
+	case <-job.cancelChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// isFinished returns whether the job has reached a terminal state. Caller needs to hold the lock.
+func (job *AdminJob) isFinished() bool {
+	return job.status.State == AdminJobSucceeded || job.status.State == AdminJobFailed || job.status.State == AdminJobCancelled
+}
+
+// snapshot returns a copy of the job status for serialization.
+func (job *AdminJob) snapshot() AdminJobStatus {
+	job.RLock()
+	defer job.RUnlock()
+	status := job.status
+	status.Logs = append([]string{}, job.status.Logs...)
+	return status
+}
+
+// AdminJobManager keeps track of admin jobs and runs them asynchronously.
+// Jobs are executed one at a time in submission order.
+type AdminJobManager struct {
+	sync.RWMutex
+	nextID   int64
+	jobs     map[string]*AdminJob
+	finished []string
+	// makes sure only one job is running at a time.
+	executorLock sync.Mutex
+}
+
+// NewAdminJobManager returns a new AdminJobManager.
+func NewAdminJobManager() *AdminJobManager {
+	return &AdminJobManager{
+		jobs: make(map[string]*AdminJob),
+	}
+}
+
+// Submit creates a new job and runs it asynchronously. It returns immediately with the pending job.
+func (m *AdminJobManager) Submit(jobType, table string, shard int, runner AdminJobRunner) AdminJobStatus {
+	m.Lock()
+	m.nextID++
+	job := &AdminJob{
+		status: AdminJobStatus{
+			ID:        fmt.Sprintf("%s_%d_%d", jobType, utils.Now().Unix(), m.nextID),
+			Type:      jobType,
+			Table:     table,
+			Shard:     shard,
+			State:     AdminJobPending,
+			Logs:      []string{},
+			CreatedAt: utils.Now().UTC(),
+		},
+		seq:        m.nextID,
+		cancelChan: make(chan struct{}),
+	}
+	m.jobs[job.status.ID] = job
+	m.Unlock()
+
+	job.Logf("Submitted job")
+	go m.run(job, runner)
+	return job.snapshot()
+}
+
+func (m *AdminJobManager) run(job *AdminJob, runner AdminJobRunner) {
+	m.executorLock.Lock()
+	defer m.executorLock.Unlock()
+
+	var err error
+	if job.Cancelled() {
+		err = errAdminJobCancelled
+	} else {
+		now := utils.Now().UTC()
+		job.Lock()
+		job.status.State = AdminJobRunning
+		job.status.StartedAt = &now
+		job.Unlock()
+		job.Logf("Started job")
+		err = runner(job)
+	}
+
+	now := utils.Now().UTC()
+	job.Lock()
+	job.status.FinishedAt = &now
+	if err == nil {
+		job.status.State = AdminJobSucceeded
+		job.status.Progress = 1
+	} else if err == errAdminJobCancelled {
+		job.status.State = AdminJobCancelled
+	} else {
+		job.status.State = AdminJobFailed
+		job.status.Error = err.Error()
+	}
+	state := job.status.State
+	job.Unlock()
+	if err != nil && err != errAdminJobCancelled {
+		job.Logf("Job failed: %s", err)
+	} else {
+		job.Logf("Job %s", state)
+	}
+
+	m.Lock()
+	m.finished = append(m.finished, job.status.ID)
+	if len(m.finished) > maxFinishedAdminJobs {
+		delete(m.jobs, m.finished[0])
+		m.finished = m.finished[1:]
+	}
+	m.Unlock()
+}
+
+// Get returns the job with given id.
+func (m *AdminJobManager) Get(jobID string) (AdminJobStatus, bool) {
+	m.RLock()
+	job, ok := m.jobs[jobID]
+	m.RUnlock()
+	if !ok {
+		return AdminJobStatus{}, false
+	}
+	return job.snapshot(), true
+}
+
+// List returns all known jobs with most recently submitted ones first.
+func (m *AdminJobManager) List() []AdminJobStatus {
+	m.RLock()
+	jobs := make([]*AdminJob, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, job)
+	}
+	m.RUnlock()
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].seq > jobs[j].seq
+	})
+	statuses := make([]AdminJobStatus, len(jobs))
+	for i, job := range jobs {
+		statuses[i] = job.snapshot()
+	}
+	return statuses
+}
+
+// Cancel requests cancellation of the job. Pending jobs will not be started, running
+// jobs stop at the next cancellation point. It returns false if the job does not exist
+// or has already finished.
+func (m *AdminJobManager) Cancel(jobID string) bool {
+	m.RLock()
+	job, ok := m.jobs[jobID]
+	m.RUnlock()
+	if !ok {
+		return false
+	}
+
+	job.RLock()
+	finished := job.isFinished()
+	job.RUnlock()
+	if finished {
+		return false
+	}
+	job.cancelOnce.Do(func() {
+		close(job.cancelChan)
+	})
+	job.Logf("Cancellation requested")
+	return true
+}
@@ -0,0 +1,220 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uber/aresdb/api/common"
+	memCom "github.com/uber/aresdb/memstore/common"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	// start of the time range counted for fact tables if not specified.
+	defaultColumnValuesFrom = "-1d"
+)
+
+// ListColumnValues swagger:route GET /query/{table}/columns/{column}/values listColumnValues
+// list distinct values of a column with number of rows over a time range, so that dashboards
+// can populate filter dropdowns without running group by queries. Counts are served from value
+// counts kept with archive batches and a scan of live batches. Enum columns return the whole
+// dictionary including values not seen in the time range, numeric columns return the most
+// frequent values. Time range only applies to fact tables and is extended to whole days for
+// archived data. Counts are of shards owned by the datanode, use the broker endpoint for
+// counts of the whole table.
+//
+// Produces:
+//    - application/json
+//
+// Responses:
+//    default: errorResponse
+//        200: columnValuesResponse
+func (handler *QueryHandler) ListColumnValues(w http.ResponseWriter, r *http.Request) {
+	var request common.ColumnValuesRequest
+	if err := common.ReadRequest(r, &request); err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	schema, err := handler.memStore.GetSchema(request.TableName)
+	if err != nil {
+		common.RespondWithError(w, ErrTableDoesNotExist)
+		return
+	}
+
+	principal := utils.PrincipalFromContext(r.Context())
+	schema.RLock()
+	// checked before reading enum dictionaries, which are returned regardless of the counts.
+	readable := principal == nil || (schema.Schema.ACL.CanRead(principal.Identities()) &&
+		schema.Schema.ACL.CanReadColumn(request.ColumnName, principal.Identities()))
+	isFactTable := schema.Schema.IsFactTable
+	columnID, found := schema.ColumnIDs[request.ColumnName]
+	var deleted, isEnum bool
+	var dataType memCom.DataType
+	var decimalScale int
+	var enumCases []string
+	if found {
+		column := schema.Schema.Columns[columnID]
+		deleted, isEnum = column.Deleted, column.IsEnumColumn()
+		dataType = schema.ValueTypeByColumn[columnID]
+		decimalScale = column.DecimalScale
+		if isEnum {
+			enumCases = append(enumCases, schema.EnumDicts[request.ColumnName].ReverseDict...)
+		}
+	}
+	schema.RUnlock()
+
+	if !readable {
+		common.RespondWithError(w, utils.APIError{
+			Code:    http.StatusForbidden,
+			Message: fmt.Sprintf("principal %s is not allowed to read column %s of table %s", principal.Name, request.ColumnName, request.TableName),
+		})
+		return
+	}
+	if !found {
+		common.RespondWithError(w, ErrColumnDoesNotExist)
+		return
+	}
+	if deleted {
+		common.RespondWithError(w, ErrColumnDeleted)
+		return
+	}
+	if !isEnum && !memCom.IsNumeric(dataType) && dataType != memCom.Bool {
+		common.RespondWithError(w, ErrColumnValuesNotSupported)
+		return
+	}
+
+	var fromDay, toDay int32
+	if isFactTable {
+		fromDay, toDay, err = getColumnValuesDays(request)
+		if err != nil {
+			common.RespondWithBadRequest(w, err)
+			return
+		}
+	}
+
+	shardIDs := handler.shardOwner.GetOwnedShards()
+	if request.Shards != "" {
+		if shardIDs, err = parseShardIDs(request.Shards); err != nil {
+			common.RespondWithBadRequest(w, err)
+			return
+		}
+	}
+
+	counts := make(map[string]int64, len(enumCases))
+	for _, enumCase := range enumCases {
+		counts[enumCase] = 0
+	}
+	for _, shardID := range shardIDs {
+		shard, err := handler.memStore.GetTableShard(request.TableName, shardID)
+		if err != nil {
+			// shards requested by broker must be counted.
+			if request.Shards == "" {
+				continue
+			}
+			common.RespondWithError(w, utils.APIError{
+				Code:    http.StatusInternalServerError,
+				Message: fmt.Sprintf("shard %d of table %s is not found", shardID, request.TableName),
+				Cause:   err,
+			})
+			return
+		}
+		shardCounts, err := shard.CountColumnValues(columnID, fromDay, toDay)
+		shard.Users.Done()
+		if err != nil {
+			common.RespondWithError(w, utils.APIError{
+				Code:    http.StatusInternalServerError,
+				Message: "Failed to count column values",
+				Cause:   err,
+			})
+			return
+		}
+		for value, count := range shardCounts {
+			counts[formatColumnValue(value, dataType, decimalScale, enumCases)] += count
+		}
+	}
+
+	var response common.ColumnValuesResponse
+	response.Body.Values, response.Body.Complete = common.SortColumnValues(counts, request.Limit)
+	common.RespondWithJSONObject(w, response.Body)
+}
+
+// getColumnValuesDays returns the range of days covering the time range of the request.
+func getColumnValuesDays(request common.ColumnValuesRequest) (fromDay, toDay int32, err error) {
+	filter := queryCom.TimeFilter{From: request.From, To: request.To}
+	if filter.From == "" {
+		filter.From = defaultColumnValuesFrom
+	}
+	var loc *time.Location
+	if request.Timezone != "" {
+		if loc, err = queryCom.ParseTimezone(request.Timezone); err != nil {
+			return
+		}
+	}
+	from, to, err := queryCom.ParseTimeFilter(filter, loc, utils.Now())
+	if err != nil {
+		return
+	}
+	fromDay = int32(from.Time.Unix() / queryCom.SecondsPerDay)
+	toDay = int32((to.Time.Unix() + queryCom.SecondsPerDay - 1) / queryCom.SecondsPerDay)
+	return
+}
+
+// parseShardIDs parses comma separated shard ids.
+func parseShardIDs(str string) ([]int, error) {
+	var shardIDs []int
+	for _, segment := range strings.Split(str, ",") {
+		shardID, err := strconv.Atoi(segment)
+		if err != nil {
+			return nil, utils.StackError(err, "invalid shard id %s", segment)
+		}
+		shardIDs = append(shardIDs, shardID)
+	}
+	return shardIDs, nil
+}
+
+// formatColumnValue formats a value counted by TableShard.CountColumnValues the same
+// way as dimension values in query results.
+func formatColumnValue(value interface{}, dataType memCom.DataType, decimalScale int, enumCases []string) string {
+	switch v := value.(type) {
+	case nil:
+		return queryCom.NULLString
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case uint8:
+		if memCom.IsEnumType(dataType) && int(v) < len(enumCases) {
+			return enumCases[v]
+		}
+	case uint16:
+		if memCom.IsEnumType(dataType) && int(v) < len(enumCases) {
+			return enumCases[v]
+		}
+	case int64:
+		if dataType == memCom.Decimal {
+			return memCom.FormatDecimal(v, decimalScale)
+		}
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	}
+	return fmt.Sprint(value)
+}
@@ -35,6 +35,8 @@ var (
 	ErrMsgDeletedColumn = "Bad request: column is already deleted"
 	// ErrMsgNotImplemented represents error message for method not implemented.
 	ErrMsgNotImplemented = "Not implemented"
+	// ErrMsgQueryBlocked represents error message for queries blocked by admin.
+	ErrMsgQueryBlocked = "Query blocked by admin"
 	// ErrMsgFailedToJSONMarshalResponseBody respresents error message for failure to marshal
 	// response body into json.
 	ErrMsgFailedToJSONMarshalResponseBody = "Failed to marshal the response body into json"
@@ -68,6 +70,16 @@ var (
 		Code:    http.StatusBadRequest,
 		Message: "Bad request: batch does not exist",
 	}
+	// ErrQueryBlockDoesNotExist represents api error for query block does not exist or already expired.
+	ErrQueryBlockDoesNotExist = utils.APIError{
+		Code:    http.StatusNotFound,
+		Message: "Query block does not exist or already expired",
+	}
+	// ErrQueryTraceDoesNotExist represents api error for query trace does not exist or already evicted.
+	ErrQueryTraceDoesNotExist = utils.APIError{
+		Code:    http.StatusNotFound,
+		Message: "Query trace does not exist or already evicted",
+	}
 	// ErrFailedToJSONMarshalResponseBody represents the api error for failure to marshal
 	// response body into json.
 	ErrFailedToJSONMarshalResponseBody = utils.APIError{
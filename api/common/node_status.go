@@ -0,0 +1,49 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	queryCom "github.com/uber/aresdb/query/common"
+)
+
+// NodeStatus is the status of a datanode reported via the status endpoint and
+// aggregated by broker for the cluster status dashboard.
+type NodeStatus struct {
+	Healthy bool   `json:"healthy"`
+	Ready   bool   `json:"ready"`
+	Version string `json:"version"`
+	// shards owned by the datanode
+	Shards []int `json:"shards"`
+	// ingestion progress of each table shard
+	Ingestion  []queryCom.ShardFreshness `json:"ingestion"`
+	HostMemory NodeMemoryStatus          `json:"hostMemory"`
+	Devices    []NodeDeviceStatus        `json:"devices"`
+}
+
+// NodeMemoryStatus is the host memory usage of a datanode.
+type NodeMemoryStatus struct {
+	// configured memory limit
+	TotalBytes int64 `json:"totalBytes"`
+	// memory used by primary keys and vector parties of all table shards
+	UsedBytes int64 `json:"usedBytes"`
+}
+
+// NodeDeviceStatus is the status of a gpu device of a datanode.
+type NodeDeviceStatus struct {
+	DeviceID    int `json:"deviceID"`
+	QueryCount  int `json:"queryCount"`
+	TotalMemory int `json:"totalMemory"`
+	FreeMemory  int `json:"freeMemory"`
+}
@@ -31,7 +31,9 @@ type RequestContext struct {
 
 // AQLRequest represents AQL query request. Debug mode will
 // run **each batch** in synchronized mode and report time
-// for each step.
+// for each step. Trace mode records all stages of the query
+// in chrome tracing format, downloadable from /query/traces/{id}
+// with the id in X-Ares-Query-Trace-ID response header.
 // swagger:parameters queryAQL
 type AQLRequest struct {
 	// in: query
@@ -41,24 +43,38 @@ type AQLRequest struct {
 	// in: query
 	Debug int `query:"debug,optional" json:"debug"`
 	// in: query
+	Strict int `query:"strict,optional" json:"strict"`
+	// in: query
+	TriValuedLogic int `query:"triValuedLogic,optional" json:"triValuedLogic"`
+	// in: query
 	Profiling string `query:"profiling,optional" json:"profiling"`
 	// in: query
+	Trace int `query:"trace,optional" json:"trace"`
+	// in: query
 	Query string `query:"q,optional" json:"q"`
 	// in: query
 	DataOnly int `query:"dataonly,optional" json:"dataonly"`
 	// in: query
 	DeviceChoosingTimeout int `query:"timeout,optional" json:"timeout"`
+	// in: query
+	DataScope string `query:"dataScope,optional" json:"dataScope"`
+	// in: query
+	UnknownEnumValue string `query:"unknownEnumValue,optional" json:"unknownEnumValue"`
 	// in: header
 	Accept string `header:"Accept,optional" json:"accept"`
 	// in: header
 	Origin string `header:"Rpc-Caller,optional" json:"origin"`
+	// in: header
+	RequestID string `header:"RequestID,optional" json:"requestID"`
 	// in: body
 	Body queryCom.AQLRequest `body:""`
 }
 
 // SQLRequest represents SQL query request. Debug mode will
 // run **each batch** in synchronized mode and report time
-// for each step.
+// for each step. Trace mode records all stages of the query
+// in chrome tracing format, downloadable from /query/traces/{id}
+// with the id in X-Ares-Query-Trace-ID response header.
 // swagger:parameters querySQL
 type SQLRequest struct {
 	// in: query
@@ -68,15 +84,117 @@ type SQLRequest struct {
 	// in: query
 	Debug int `query:"debug,optional" json:"debug"`
 	// in: query
+	Strict int `query:"strict,optional" json:"strict"`
+	// in: query
+	TriValuedLogic int `query:"triValuedLogic,optional" json:"triValuedLogic"`
+	// in: query
 	Profiling string `query:"profiling,optional" json:"profiling"`
 	// in: query
+	Trace int `query:"trace,optional" json:"trace"`
+	// in: query
 	DeviceChoosingTimeout int `query:"timeout,optional" json:"timeout"`
+	// in: query
+	DataScope string `query:"dataScope,optional" json:"dataScope"`
+	// in: query
+	UnknownEnumValue string `query:"unknownEnumValue,optional" json:"unknownEnumValue"`
 	// in: header
 	Accept string `header:"Accept,optional" json:"accept"`
 	// in: header
 	Origin string `header:"Rpc-Caller,optional" json:"origin"`
+	// in: header
+	RequestID string `header:"RequestID,optional" json:"requestID"`
 	// in: body
 	Body struct {
 		Queries []string `json:"queries"`
 	} `body:""`
 }
+
+// ColumnValuesRequest represents the request to list distinct values of a column with
+// their counts, from and to are in the format of aql time filter. Negative limit returns
+// all values. Shards are comma separated ids of shards to count, all shards owned by the
+// datanode if not specified.
+// swagger:parameters listColumnValues
+type ColumnValuesRequest struct {
+	// in: path
+	TableName string `path:"table" json:"table"`
+	// in: path
+	ColumnName string `path:"column" json:"column"`
+	// in: query
+	From string `query:"from,optional" json:"from"`
+	// in: query
+	To string `query:"to,optional" json:"to"`
+	// in: query
+	Timezone string `query:"timezone,optional" json:"timezone"`
+	// in: query
+	Limit int `query:"limit,optional" json:"limit"`
+	// in: query
+	Shards string `query:"shards,optional" json:"shards"`
+	// in: header
+	Origin string `header:"Rpc-Caller,optional" json:"origin"`
+	// in: header
+	Caller string `header:"X-Caller,optional" json:"caller"`
+}
+
+// QueryBlockRequest represents the request to block queries on a table matching the fingerprint
+// for a period. The fingerprint is computed from the sample query if not specified, all queries
+// on the table are blocked if neither is specified.
+// swagger:parameters blockQueries
+type QueryBlockRequest struct {
+	// in: body
+	Body struct {
+		Table           string             `json:"table"`
+		Fingerprint     string             `json:"fingerprint,omitempty"`
+		Query           *queryCom.AQLQuery `json:"query,omitempty"`
+		Reason          string             `json:"reason,omitempty"`
+		DurationSeconds int64              `json:"durationSeconds"`
+	} `body:""`
+}
+
+// QueryBlock validates the request and returns the query block expiring after the duration.
+func (r QueryBlockRequest) QueryBlock(now int64) (block queryCom.QueryBlock, err error) {
+	block = queryCom.QueryBlock{
+		Table:       r.Body.Table,
+		Fingerprint: r.Body.Fingerprint,
+		Reason:      r.Body.Reason,
+		ExpiresAt:   now + r.Body.DurationSeconds,
+	}
+	if r.Body.Query != nil {
+		if block.Table == "" {
+			block.Table = r.Body.Query.Table
+		}
+		if block.Fingerprint == "" {
+			block.Fingerprint = queryCom.QueryFingerprint(r.Body.Query)
+		}
+	}
+	if block.Table == "" || r.Body.DurationSeconds <= 0 {
+		err = ErrMissingParameter
+	}
+	return
+}
+
+// QueryUnblockRequest represents the request to remove a query block.
+// swagger:parameters unblockQueries
+type QueryUnblockRequest struct {
+	// in: path
+	TableName string `path:"table" json:"table"`
+	// in: query
+	Fingerprint string `query:"fingerprint,optional" json:"fingerprint"`
+}
+
+// QueryTraceRequest represents the request to get the trace of a query executed in trace mode.
+// swagger:parameters getQueryTrace
+type QueryTraceRequest struct {
+	// in: path
+	ID string `path:"id" json:"id"`
+}
+
+// SQLCompletionRequest represents the request to complete a partial sql statement at the cursor.
+// swagger:parameters completeSQL
+type SQLCompletionRequest struct {
+	// in: body
+	Body struct {
+		SQL string `json:"sql"`
+		// byte offset of the cursor in sql.
+		Cursor int `json:"cursor"`
+	} `body:""`
+}
@@ -15,7 +15,11 @@
 package common
 
 import (
+	"sort"
+
 	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/query/sql"
+	"github.com/uber/aresdb/utils"
 )
 
 // AQLResponse represents queryAQL response.
@@ -24,3 +28,76 @@ type AQLResponse struct {
 	//in: body
 	Body queryCom.AQLResponse
 }
+
+// ColumnValueCount is a distinct value of a column and the number of rows having it.
+type ColumnValueCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// ColumnValuesResponse represents listColumnValues response.
+// swagger:response columnValuesResponse
+type ColumnValuesResponse struct {
+	//in: body
+	Body struct {
+		// values ordered by count descendingly
+		Values []ColumnValueCount `json:"values"`
+		// false if values are truncated by limit
+		Complete bool `json:"complete"`
+	}
+}
+
+// DefaultColumnValuesLimit is the max number of values returned by listColumnValues if not
+// specified.
+const DefaultColumnValuesLimit = 1000
+
+// SortColumnValues orders values by count descendingly then by value, and truncates them to
+// the limit of the request. Returns false if values are truncated.
+func SortColumnValues(counts map[string]int64, limit int) ([]ColumnValueCount, bool) {
+	values := make([]ColumnValueCount, 0, len(counts))
+	for value, count := range counts {
+		values = append(values, ColumnValueCount{Value: value, Count: count})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+
+	if limit == 0 {
+		limit = DefaultColumnValuesLimit
+	}
+	if limit < 0 || len(values) <= limit {
+		return values, true
+	}
+	return values[:limit], false
+}
+
+// ListQueryBlocksResponse represents ListQueryBlocks response.
+// swagger:response listQueryBlocksResponse
+type ListQueryBlocksResponse struct {
+	//in: body
+	Body []queryCom.QueryBlock
+}
+
+// QueryBlockResponse represents BlockQueries response.
+// swagger:response queryBlockResponse
+type QueryBlockResponse struct {
+	//in: body
+	Body queryCom.QueryBlock
+}
+
+// QueryTraceResponse represents GetQueryTrace response.
+// swagger:response queryTraceResponse
+type QueryTraceResponse struct {
+	//in: body
+	Body utils.ChromeTrace
+}
+
+// SQLCompletionResponse represents completeSQL response.
+// swagger:response sqlCompletionResponse
+type SQLCompletionResponse struct {
+	//in: body
+	Body sql.Completion
+}
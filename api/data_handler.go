@@ -16,6 +16,7 @@ package api
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/uber/aresdb/api/common"
 	"github.com/uber/aresdb/memstore"
@@ -50,6 +51,7 @@ func (handler *DataHandler) Register(router *mux.Router, wrappers ...utils.HTTPH
 // Responses:
 //    default: errorResponse
 //        200: noContentResponse
+//        429: errorResponse
 func (handler *DataHandler) PostData(w http.ResponseWriter, r *http.Request) {
 	var postDataRequest PostDataRequest
 	err := common.ReadRequest(r, &postDataRequest)
@@ -65,10 +67,29 @@ func (handler *DataHandler) PostData(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = handler.memStore.HandleIngestion(postDataRequest.TableName, postDataRequest.Shard, upsertBatch)
+	utils.AuditRequest(r, utils.AuditOpIngestBatch, postDataRequest.TableName, map[string]interface{}{
+		"shard": postDataRequest.Shard,
+		"rows":  upsertBatch.NumRows,
+		"bytes": len(postDataRequest.Body),
+	}, err)
+	if throttled, ok := err.(*memstore.IngestionThrottledError); ok {
+		// ask the ingestion client to back off instead of piling up data in memory.
+		w.Header().Set("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())))
+		common.RespondWithError(w, utils.APIError{
+			Code:    http.StatusTooManyRequests,
+			Message: throttled.Error(),
+		})
+		return
+	}
 	if err != nil {
 		common.RespondWithError(w, err)
 		return
 	}
 
+	// clients pass the write position to queries to read their writes.
+	if shard, err := handler.memStore.GetTableShard(postDataRequest.TableName, postDataRequest.Shard); err == nil {
+		w.Header().Set(memCom.WritePositionHeaderKey, shard.GetWritePosition().String())
+		shard.Users.Done()
+	}
 	common.RespondWithJSONObject(w, nil)
 }
@@ -20,7 +20,9 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"time"
 
+	"github.com/uber/aresdb/memstore"
 	memCom "github.com/uber/aresdb/memstore/common"
 	memMocks "github.com/uber/aresdb/memstore/mocks"
 	metaCom "github.com/uber/aresdb/metastore/common"
@@ -45,6 +47,12 @@ var _ = ginkgo.Describe("DataHandler", func() {
 	ginkgo.BeforeEach(func() {
 		memStore = CreateMemStore(testSchema, 0, nil, CreateMockDiskStore())
 		memStore.On("HandleIngestion", "abc", 0, mock.Anything).Return(nil)
+		memStore.On("HandleIngestion", "abc", 1, mock.Anything).Return(&memstore.IngestionThrottledError{
+			Table:      "abc",
+			Shard:      1,
+			Reason:     "memory usage ratio 0.95 over 0.90",
+			RetryAfter: 5 * time.Second,
+		})
 		dataHandler := NewDataHandler(memStore)
 		testRouter := mux.NewRouter()
 		dataHandler.Register(testRouter.PathPrefix("/data").Subrouter())
@@ -74,5 +82,17 @@ var _ = ginkgo.Describe("DataHandler", func() {
 		_, err = ioutil.ReadAll(resp.Body)
 		Ω(err).Should(BeNil())
 		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		Ω(resp.Header.Get(memCom.WritePositionHeaderKey)).Should(Equal("0:0"))
+	})
+
+	ginkgo.It("PostData should respond 429 with Retry-After when ingestion is throttled", func() {
+		buffer, _ := memCom.NewUpsertBatchBuilder().ToByteArray()
+		hostPort := testServer.Listener.Addr().String()
+		resp, err := http.Post(fmt.Sprintf("http://%s/data/abc/1", hostPort), "application/upsert-data", bytes.NewBuffer(buffer))
+		Ω(err).Should(BeNil())
+		_, err = ioutil.ReadAll(resp.Body)
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusTooManyRequests))
+		Ω(resp.Header.Get("Retry-After")).Should(Equal("5"))
 	})
 })
@@ -23,6 +23,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/uber/aresdb/api/common"
@@ -69,22 +70,39 @@ func NewDebugHandler(
 	}
 }
 
-// Register registers http handlers.
-func (handler *DebugHandler) Register(router *mux.Router) {
+// Register registers http handlers. Admin handlers and handlers dumping data are wrapped with
+// wrappers, e.g. authentication, which run before the admin handlers are audited so that the
+// audit log records the authenticated principal.
+func (handler *DebugHandler) Register(router *mux.Router, wrappers ...utils.HTTPHandlerWrapper) {
+	auditAdmin := utils.WithAdminAuditFunc(func(r *http.Request) string {
+		return mux.Vars(r)["table"]
+	})
+	audited := func(h http.HandlerFunc) http.HandlerFunc {
+		return utils.ApplyHTTPWrappers(auditAdmin(h), wrappers)
+	}
 	router.HandleFunc("/health", handler.Health).Methods(http.MethodGet)
-	router.HandleFunc("/health/{onOrOff}", handler.HealthSwitch).Methods(http.MethodPost)
+	router.HandleFunc("/health/{onOrOff}", audited(handler.HealthSwitch)).Methods(http.MethodPost)
 	router.HandleFunc("/jobs/{jobType}", handler.ShowJobStatus).Methods(http.MethodGet)
 	router.HandleFunc("/devices", handler.ShowDeviceStatus).Methods(http.MethodGet)
 	router.HandleFunc("/host-memory", handler.ShowHostMemory).Methods(http.MethodGet)
 	router.HandleFunc("/shards", handler.ShowShardSet).Methods(http.MethodGet)
+	router.HandleFunc("/queries", handler.ShowRunningQueries).Methods(http.MethodGet)
+	router.HandleFunc("/queries/{requestID}", audited(handler.KillQuery)).Methods(http.MethodDelete)
+	router.HandleFunc("/audit-log", handler.ShowAuditLog).Methods(http.MethodGet)
+	router.HandleFunc("/{table}/enum-dicts/compaction", audited(handler.CompactEnumDicts)).Methods(http.MethodPost)
 	router.HandleFunc("/{table}/{shard}", handler.ShowShardMeta).Methods(http.MethodGet)
-	router.HandleFunc("/{table}/{shard}/archive", handler.Archive).Methods(http.MethodPost)
-	router.HandleFunc("/{table}/{shard}/backfill", handler.Backfill).Methods(http.MethodPost)
-	router.HandleFunc("/{table}/{shard}/snapshot", handler.Snapshot).Methods(http.MethodPost)
-	router.HandleFunc("/{table}/{shard}/purge", handler.Purge).Methods(http.MethodPost)
+	router.HandleFunc("/{table}/{shard}/recovery", handler.ShowRecoveryProgress).Methods(http.MethodGet)
+	router.HandleFunc("/{table}/{shard}/archive", audited(handler.Archive)).Methods(http.MethodPost)
+	router.HandleFunc("/{table}/{shard}/backfill", audited(handler.Backfill)).Methods(http.MethodPost)
+	router.HandleFunc("/{table}/{shard}/snapshot", audited(handler.Snapshot)).Methods(http.MethodPost)
+	router.HandleFunc("/{table}/{shard}/purge", audited(handler.Purge)).Methods(http.MethodPost)
+	router.HandleFunc("/{table}/{shard}/compaction", audited(handler.Compact)).Methods(http.MethodPost)
+	router.HandleFunc("/{table}/{shard}/compaction/{pauseOrResume}", audited(handler.CompactionSwitch)).Methods(http.MethodPost)
 	router.HandleFunc("/{table}/{shard}/batches/{batch}", handler.ShowBatch).Methods(http.MethodGet)
+	router.HandleFunc("/{table}/{shard}/batches/{batch}/dump", utils.ApplyHTTPWrappers(handler.DownloadArchiveBatch, wrappers)).Methods(http.MethodGet)
+	router.HandleFunc("/{table}/{shard}/batches/{batch}/dump", audited(handler.UploadArchiveBatch)).Methods(http.MethodPut)
 	router.HandleFunc("/{table}/{shard}/batches/{batch}/vector-parties/{column}", handler.LoadVectorParty).Methods(http.MethodGet)
-	router.HandleFunc("/{table}/{shard}/batches/{batch}/vector-parties/{column}", handler.EvictVectorParty).Methods(http.MethodDelete)
+	router.HandleFunc("/{table}/{shard}/batches/{batch}/vector-parties/{column}", audited(handler.EvictVectorParty)).Methods(http.MethodDelete)
 	router.HandleFunc("/{table}/{shard}/primary-keys", handler.LookupPrimaryKey).Methods(http.MethodGet)
 	router.HandleFunc("/{table}/{shard}/redologs", handler.ListRedoLogs).
 		Methods(http.MethodGet)
@@ -93,7 +111,7 @@ func (handler *DebugHandler) Register(router *mux.Router) {
 	router.HandleFunc("/{table}/{shard}/redologs/{creationTime}/upsertbatches/{offset}", handler.ReadUpsertBatch).
 		Methods(http.MethodGet)
 	router.HandleFunc("/{table}/{shard}/backfill-manager/upsertbatches/{offset}", handler.ReadBackfillQueueUpsertBatch).Methods(http.MethodGet)
-	router.HandleFunc("/bootstrap/retry", handler.BootstrapRetry).Methods(http.MethodPost)
+	router.HandleFunc("/bootstrap/retry", audited(handler.BootstrapRetry)).Methods(http.MethodPost)
 }
 
 // ShowShardSet shows the shard set owned by the server
@@ -453,38 +471,119 @@ func (handler *DebugHandler) Snapshot(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Purge starts an purge process on demand.
-func (handler *DebugHandler) Purge(w http.ResponseWriter, r *http.Request) {
-	var request PurgeRequest
+// Compact starts a compaction process of a dimension table shard on demand.
+func (handler *DebugHandler) Compact(w http.ResponseWriter, r *http.Request) {
+	var request CompactionRequest
 	err := common.ReadRequest(r, &request)
 	if err != nil {
 		common.RespondWithBadRequest(w, err)
 		return
 	}
 
-	if !request.Body.SafePurge && (request.Body.BatchIDStart < 0 || request.Body.BatchIDEnd < 0 || request.Body.BatchIDStart > request.Body.BatchIDEnd) {
-		common.RespondWithBadRequest(w, fmt.Errorf("invalid batch range, expects both to be > 0, got [%d, %d)",
-			request.Body.BatchIDStart, request.Body.BatchIDEnd))
+	shard, err := handler.memStore.GetTableShard(request.TableName, request.ShardID)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+	defer shard.Users.Done()
+
+	if shard.LiveStore.CompactionManager == nil {
+		common.RespondWithBadRequest(w, errors.New("compaction is only supported for dimension tables"))
 		return
 	}
 
-	shard, err := handler.memStore.GetTableShard(request.TableName, request.ShardID)
+	scheduler := handler.memStore.GetScheduler()
+	err, errChan := scheduler.SubmitJob(
+		scheduler.NewCompactionJob(request.TableName, request.ShardID))
+	if err != nil {
+		common.RespondWithError(w, err)
+		return
+	}
+	go func() {
+		<-errChan
+	}()
+	common.RespondJSONObjectWithCode(w, http.StatusOK, "Compaction job submitted")
+}
+
+// CompactEnumDicts drops enum cases no longer referenced by any row of the table and renumbers
+// the remaining cases. Clients caching enum ids of the table need to refresh them afterwards.
+func (handler *DebugHandler) CompactEnumDicts(w http.ResponseWriter, r *http.Request) {
+	var request CompactEnumDictsRequest
+	err := common.ReadRequest(r, &request)
 	if err != nil {
 		common.RespondWithBadRequest(w, err)
 		return
 	}
-	defer shard.Users.Done()
 
-	if request.Body.SafePurge {
-		retentionDays := shard.Schema.Schema.Config.RecordRetentionInDays
-		if retentionDays > 0 {
-			nowInDay := int(utils.Now().Unix() / 86400)
-			request.Body.BatchIDStart = 0
-			request.Body.BatchIDEnd = nowInDay - retentionDays
-		} else {
-			common.RespondWithBadRequest(w, utils.APIError{Message: "safe purge attempted on table with infinite retention"})
+	if handler.enumReader != nil {
+		common.RespondWithBadRequest(w, errors.New("enum dicts are managed by controller and cannot be compacted by datanode"))
+		return
+	}
+
+	dropped, err := handler.memStore.CompactEnumDicts(request.TableName)
+	if err != nil {
+		if err == metaCom.ErrTableDoesNotExist {
+			common.RespondWithBadRequest(w, err)
 			return
 		}
+		common.RespondWithError(w, err)
+		return
+	}
+	common.RespondJSONObjectWithCode(w, http.StatusOK, dropped)
+}
+
+// CompactionSwitch pauses or resumes scheduled compaction of a dimension table shard, eg. during
+// busy periods. Compaction submitted on demand still runs while paused.
+func (handler *DebugHandler) CompactionSwitch(w http.ResponseWriter, r *http.Request) {
+	var request CompactionSwitchRequest
+	err := common.ReadRequest(r, &request)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	if request.PauseOrResume != "pause" && request.PauseOrResume != "resume" {
+		common.RespondWithBadRequest(w, errors.New("must specify pause or resume in the url"))
+		return
+	}
+
+	shard, err := handler.memStore.GetTableShard(request.TableName, request.ShardID)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+	defer shard.Users.Done()
+
+	if shard.LiveStore.CompactionManager == nil {
+		common.RespondWithBadRequest(w, errors.New("compaction is only supported for dimension tables"))
+		return
+	}
+
+	shard.LiveStore.CompactionManager.SetPaused(request.PauseOrResume == "pause")
+	io.WriteString(w, "OK")
+}
+
+// Purge starts an purge process on demand.
+func (handler *DebugHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	var request PurgeRequest
+	err := common.ReadRequest(r, &request)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	shard, err := handler.memStore.GetTableShard(request.TableName, request.ShardID)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+	defer shard.Users.Done()
+
+	request.Body.BatchIDStart, request.Body.BatchIDEnd, err = getPurgeBatchRange(shard,
+		request.Body.BatchIDStart, request.Body.BatchIDEnd, request.Body.SafePurge)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
 	}
 
 	scheduler := handler.memStore.GetScheduler()
@@ -499,6 +598,25 @@ func (handler *DebugHandler) Purge(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// getPurgeBatchRange validates the batch range to purge. For safe purge, the range is derived
+// from the retention of the table instead.
+func getPurgeBatchRange(shard *memstore.TableShard, batchIDStart, batchIDEnd int, safePurge bool) (int, int, error) {
+	if safePurge {
+		retentionDays := shard.Schema.Schema.Config.RecordRetentionInDays
+		if retentionDays <= 0 {
+			return 0, 0, utils.APIError{Message: "safe purge attempted on table with infinite retention"}
+		}
+		nowInDay := int(utils.Now().Unix() / 86400)
+		return 0, nowInDay - retentionDays, nil
+	}
+
+	if batchIDStart < 0 || batchIDEnd < 0 || batchIDStart > batchIDEnd {
+		return 0, 0, fmt.Errorf("invalid batch range, expects both to be > 0, got [%d, %d)",
+			batchIDStart, batchIDEnd)
+	}
+	return batchIDStart, batchIDEnd, nil
+}
+
 // ShowShardMeta shows the metadata for a table shard. It won't show the underlying data.
 func (handler *DebugHandler) ShowShardMeta(w http.ResponseWriter, r *http.Request) {
 	var request ShowShardMetaRequest
@@ -517,6 +635,23 @@ func (handler *DebugHandler) ShowShardMeta(w http.ResponseWriter, r *http.Reques
 	return
 }
 
+// ShowRecoveryProgress shows bytes of redologs replayed vs total of a shard.
+func (handler *DebugHandler) ShowRecoveryProgress(w http.ResponseWriter, r *http.Request) {
+	var request ShowRecoveryProgressRequest
+	err := common.ReadRequest(r, &request)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+	shard, err := handler.memStore.GetTableShard(request.TableName, request.ShardID)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+	defer shard.Users.Done()
+	common.RespondWithJSONObject(w, shard.GetRecoveryProgress())
+}
+
 // ListRedoLogs lists all the redo log files for a given shard.
 func (handler *DebugHandler) ListRedoLogs(w http.ResponseWriter, r *http.Request) {
 	var request ListRedoLogsRequest
@@ -656,6 +791,90 @@ func (handler *DebugHandler) LoadVectorParty(w http.ResponseWriter, r *http.Requ
 	common.RespondWithJSONObject(w, nil)
 }
 
+// DownloadArchiveBatch dumps vector party files of the current version of an archive batch with
+// their checksums, for repairing the batch on another replica.
+func (handler *DebugHandler) DownloadArchiveBatch(w http.ResponseWriter, r *http.Request) {
+	if utils.PrincipalFromContext(r.Context()) == nil {
+		common.RespondWithError(w, ErrArchiveBatchRepairUnauthenticated)
+		return
+	}
+
+	var request DownloadArchiveBatchRequest
+	if err := common.ReadRequest(r, &request); err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	shard, err := handler.memStore.GetTableShard(request.TableName, request.ShardID)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+	defer shard.Users.Done()
+
+	if !shard.Schema.Schema.IsFactTable {
+		common.RespondWithBadRequest(w, errors.New("only fact tables have archive batches"))
+		return
+	}
+
+	dump, err := shard.ExportArchiveBatch(request.BatchID)
+	if err != nil {
+		common.RespondWithError(w, err)
+		return
+	}
+	common.RespondWithJSONObject(w, dump)
+}
+
+// UploadArchiveBatch replaces the current version of an archive batch with a dump downloaded by
+// DownloadArchiveBatch, e.g. from a healthy replica. The batch is replaced atomically by the
+// scheduler and the request blocks until it's done.
+func (handler *DebugHandler) UploadArchiveBatch(w http.ResponseWriter, r *http.Request) {
+	if utils.PrincipalFromContext(r.Context()) == nil {
+		common.RespondWithError(w, ErrArchiveBatchRepairUnauthenticated)
+		return
+	}
+
+	var request UploadArchiveBatchRequest
+	if err := common.ReadRequest(r, &request); err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	if request.Body.BatchID != request.BatchID {
+		common.RespondWithBadRequest(w, errors.New("archive batch dump does not belong to the batch"))
+		return
+	}
+
+	shard, err := handler.memStore.GetTableShard(request.TableName, request.ShardID)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+	defer shard.Users.Done()
+
+	if !shard.Schema.Schema.IsFactTable {
+		common.RespondWithBadRequest(w, errors.New("only fact tables have archive batches"))
+		return
+	}
+
+	if err = shard.ValidateArchiveBatchDump(&request.Body); err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	scheduler := handler.memStore.GetScheduler()
+	err, errChan := scheduler.SubmitJob(
+		scheduler.NewArchiveBatchRepairJob(request.TableName, request.ShardID, &request.Body))
+	if err == nil {
+		err = <-errChan
+	}
+	if err != nil {
+		common.RespondWithError(w, err)
+		return
+	}
+	common.RespondWithJSONObject(w, nil)
+}
+
 // EvictVectorParty evict a vector party from memory.
 func (handler *DebugHandler) EvictVectorParty(w http.ResponseWriter, r *http.Request) {
 	var request EvictVectorPartyRequest
@@ -721,6 +940,55 @@ func (handler *DebugHandler) ShowDeviceStatus(w http.ResponseWriter, r *http.Req
 	return
 }
 
+// ShowRunningQueries shows the queries being executed.
+func (handler *DebugHandler) ShowRunningQueries(w http.ResponseWriter, r *http.Request) {
+	common.RespondWithJSONObject(w, handler.queryHandler.GetQueryRegistry().List())
+}
+
+// KillQuery kills queries of a request being executed.
+func (handler *DebugHandler) KillQuery(w http.ResponseWriter, r *http.Request) {
+	var request KillQueryRequest
+	if err := common.ReadRequest(r, &request); err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	if !handler.queryHandler.GetQueryRegistry().Kill(request.RequestID) {
+		common.RespondWithError(w, ErrQueryDoesNotExist)
+		return
+	}
+	utils.GetLogger().With("requestID", request.RequestID).Info("Killed query")
+	common.RespondWithJSONObject(w, nil)
+}
+
+// ShowAuditLog shows the most recent audit log entries matching the request, newest first.
+func (handler *DebugHandler) ShowAuditLog(w http.ResponseWriter, r *http.Request) {
+	var request ShowAuditLogRequest
+	if err := common.ReadRequest(r, &request); err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	filter := utils.AuditLogFilter{
+		Actor:     request.Actor,
+		Operation: request.Operation,
+		Table:     request.Table,
+		Limit:     request.Limit,
+	}
+	if request.From > 0 {
+		filter.From = time.Unix(request.From, 0)
+	}
+	if request.To > 0 {
+		filter.To = time.Unix(request.To, 0)
+	}
+	entries, err := utils.GetAuditLog().Query(filter)
+	if err != nil {
+		common.RespondWithError(w, err)
+		return
+	}
+	common.RespondWithJSONObject(w, entries)
+}
+
 // ShowHostMemory shows the current host memory usage
 func (handler *DebugHandler) ShowHostMemory(w http.ResponseWriter, r *http.Request) {
 	memoryUsageByTableShard, err := handler.memStore.GetMemoryUsageDetails()
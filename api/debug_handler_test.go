@@ -33,14 +33,17 @@ import (
 	"github.com/uber/aresdb/metastore"
 	metaCom "github.com/uber/aresdb/metastore/common"
 	"github.com/uber/aresdb/query"
+	queryCom "github.com/uber/aresdb/query/common"
 	"github.com/uber/aresdb/redolog"
 	"github.com/uber/aresdb/utils"
 	utilsMocks "github.com/uber/aresdb/utils/mocks"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 	"unsafe"
@@ -57,6 +60,17 @@ func convertToAPIError(err error) error {
 	}
 }
 
+// testAuthenticator authenticates requests with bearer token as the principal named by the token.
+type testAuthenticator struct{}
+
+func (testAuthenticator) Authenticate(r *http.Request) (*utils.Principal, error) {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return nil, errors.New("missing bearer token")
+	}
+	return &utils.Principal{Name: strings.TrimPrefix(authorization, "Bearer ")}, nil
+}
+
 var _ = ginkgo.Describe("DebugHandler", func() {
 
 	testFactory := memstore.GetFactory()
@@ -203,6 +217,7 @@ var _ = ginkgo.Describe("DebugHandler", func() {
 		mockMetaStore.On(
 			"UpdateArchivingCutoff", mock.Anything, mock.Anything,
 			mock.Anything).Return(nil)
+		mockMetaStore.On("GetDailyRowCounts", mock.Anything, mock.Anything).Return(metaCom.DailyRowCounts{}, nil)
 		mockDiskStore.On(
 			"DeleteBatchVersions", mock.Anything, mock.Anything,
 			mock.Anything, mock.Anything, mock.Anything).Return(nil)
@@ -216,9 +231,16 @@ var _ = ginkgo.Describe("DebugHandler", func() {
 		mockDiskStore.On(
 			"OpenVectorPartyFileForWrite", mock.Anything,
 			mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(writer, nil)
+		mockDiskStore.On("ListArchiveBatchVectorPartyFiles", testTableName, testTableShardID, int(batchID),
+			uint32(0), uint32(0)).Return([]int{0}, nil)
+		mockDiskStore.On("OpenVectorPartyFileForRead", testTableName, 0, testTableShardID, int(batchID),
+			uint32(0), uint32(0)).Return(func(string, int, int, int, uint32, uint32) io.ReadCloser {
+			return ioutil.NopCloser(bytes.NewReader([]byte("vector party")))
+		}, nil)
 
 		queryHandler := NewQueryHandler(
 			memStore,
+			nil,
 			topology.NewStaticShardOwner([]int{0}),
 			common.QueryConfig{
 				DeviceMemoryUtilization: 0.9,
@@ -609,6 +631,7 @@ var _ = ginkgo.Describe("DebugHandler", func() {
       "numUpsertBatches": 0
     },
     "batchSize": 10,
+    "minBatchSize": 0,
     "batches": {
       "-2147483648": {
         "capacity": 10,
@@ -666,6 +689,17 @@ var _ = ginkgo.Describe("DebugHandler", func() {
 		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
 	})
 
+	ginkgo.It("ShowRecoveryProgress request should work", func() {
+		hostPort := testServer.Listener.Addr().String()
+		resp, err := http.Get(
+			fmt.Sprintf("http://%s/debug/%s/%d/recovery", hostPort, testTableName, testTableShardID))
+		Ω(err).Should(BeNil())
+		bs, err := ioutil.ReadAll(resp.Body)
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		Ω(string(bs)).Should(MatchJSON(`{"totalBytes": 0, "replayedBytes": 0, "done": false}`))
+	})
+
 	ginkgo.It("LoadVectorParty should work", func() {
 		hostPort := testServer.Listener.Addr().String()
 		columnName := "c0"
@@ -783,6 +817,7 @@ var _ = ginkgo.Describe("DebugHandler", func() {
 				  "lastStartTime": "1970-01-01T00:01:40Z",
 				  "numMutations": 0,
 				  "numBatches": 0,
+				  "numMergedBatches": 0,
 				  "redologFile": 0,
 				  "batchOffset": 0,
 				  "stage": ""
@@ -919,6 +954,43 @@ var _ = ginkgo.Describe("DebugHandler", func() {
 		Ω(bs).Should(MatchJSON(expectedStatus))
 	})
 
+	ginkgo.It("ShowRunningQueries and KillQuery should work", func() {
+		hostPort := testServer.Listener.Addr().String()
+		qc := &query.AQLQueryContext{
+			Query: &queryCom.AQLQuery{
+				Table:    testTableName,
+				Measures: []queryCom.Measure{{Expr: "count()"}},
+			},
+			TableScanners: []*query.TableScanner{{Shards: []int{0, 1}}},
+			RequestID:     "broker_1",
+		}
+		registry := debugHandler.queryHandler.GetQueryRegistry()
+		registry.Register(qc)
+		defer registry.Deregister(qc)
+
+		resp, err := http.Get(fmt.Sprintf("http://%s/debug/queries", hostPort))
+		Ω(err).Should(BeNil())
+		bs, err := ioutil.ReadAll(resp.Body)
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		var runningQueries []query.RunningQuery
+		Ω(json.Unmarshal(bs, &runningQueries)).Should(BeNil())
+		Ω(runningQueries).Should(HaveLen(1))
+		Ω(runningQueries[0].RequestID).Should(Equal("broker_1"))
+		Ω(runningQueries[0].Query.Table).Should(Equal(testTableName))
+		Ω(runningQueries[0].ShardsPending).Should(Equal(2))
+
+		req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("http://%s/debug/queries/broker_1", hostPort), nil)
+		resp, err = http.DefaultClient.Do(req)
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+
+		req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("http://%s/debug/queries/broker_2", hostPort), nil)
+		resp, err = http.DefaultClient.Do(req)
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusNotFound))
+	})
+
 	ginkgo.It("Backfill request should work", func() {
 		hostPort := testServer.Listener.Addr().String()
 		request := &BackfillRequest{}
@@ -974,6 +1046,46 @@ var _ = ginkgo.Describe("DebugHandler", func() {
 		Ω(string(bs)).Should(ContainSubstring("Failed to get shard"))
 	})
 
+	ginkgo.It("Compaction requests should only be accepted for dimension tables", func() {
+		hostPort := testServer.Listener.Addr().String()
+		contentType := "application/json"
+		for _, url := range []string{
+			fmt.Sprintf("http://%s/debug/%s/%d/compaction", hostPort, testTableName, testTableShardID),
+			fmt.Sprintf("http://%s/debug/%s/%d/compaction/pause", hostPort, testTableName, testTableShardID),
+		} {
+			resp, err := http.Post(url, contentType, nil)
+			Ω(err).Should(BeNil())
+			bs, err := ioutil.ReadAll(resp.Body)
+			Ω(err).Should(BeNil())
+			Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+			Ω(string(bs)).Should(ContainSubstring("compaction is only supported for dimension tables"))
+		}
+
+		resp, err := http.Post(
+			fmt.Sprintf("http://%s/debug/%s/%d/compaction/stop", hostPort, testTableName, testTableShardID), contentType, nil)
+		Ω(err).Should(BeNil())
+		bs, err := ioutil.ReadAll(resp.Body)
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+		Ω(string(bs)).Should(ContainSubstring("must specify pause or resume in the url"))
+	})
+
+	ginkgo.It("CompactEnumDicts request should work", func() {
+		hostPort := testServer.Listener.Addr().String()
+		memStore.On("CompactEnumDicts", testTableName).Return(map[string]int{"c6": 2}, nil).Once()
+		resp, err := http.Post(fmt.Sprintf("http://%s/debug/%s/enum-dicts/compaction", hostPort, testTableName), "application/json", nil)
+		Ω(err).Should(BeNil())
+		bs, err := ioutil.ReadAll(resp.Body)
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		Ω(string(bs)).Should(MatchJSON(`{"c6": 2}`))
+
+		memStore.On("CompactEnumDicts", "unknown").Return(nil, metaCom.ErrTableDoesNotExist).Once()
+		resp, err = http.Post(fmt.Sprintf("http://%s/debug/unknown/enum-dicts/compaction", hostPort), "application/json", nil)
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+	})
+
 	ginkgo.It("Purge request should work", func() {
 		hostPort := testServer.Listener.Addr().String()
 		request := &PurgeRequest{}
@@ -1020,6 +1132,82 @@ var _ = ginkgo.Describe("DebugHandler", func() {
 		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
 	})
 
+	ginkgo.It("DownloadArchiveBatch and UploadArchiveBatch should work", func() {
+		dumpURL := fmt.Sprintf("/debug/%s/%d/batches/%d/dump", testTableName, testTableShardID, batchID)
+
+		// requests without authenticated principal are rejected.
+		resp, err := http.Get(fmt.Sprintf("http://%s%s", testServer.Listener.Addr().String(), dumpURL))
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusUnauthorized))
+
+		router := mux.NewRouter()
+		debugHandler.Register(router.PathPrefix("/debug").Subrouter(), utils.WithAuthentication(testAuthenticator{}))
+		authServer := httptest.NewServer(router)
+		defer authServer.Close()
+		url := fmt.Sprintf("http://%s%s", authServer.Listener.Addr().String(), dumpURL)
+
+		// requests failing authentication are rejected by the authentication wrapper.
+		resp, err = http.Get(url)
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusUnauthorized))
+
+		get := func(url string) *http.Response {
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			Ω(err).Should(BeNil())
+			request.Header.Set("Authorization", "Bearer admin")
+			resp, err := http.DefaultClient.Do(request)
+			Ω(err).Should(BeNil())
+			return resp
+		}
+		resp = get(url)
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		var dump memstore.ArchiveBatchDump
+		Ω(json.NewDecoder(resp.Body).Decode(&dump)).Should(BeNil())
+		Ω(dump).Should(Equal(memstore.ArchiveBatchDump{
+			Table:   testTableName,
+			Shard:   testTableShardID,
+			BatchID: int(batchID),
+			Size:    5,
+			VectorParties: []memstore.VectorPartyDump{
+				{
+					ColumnID:   0,
+					ColumnName: "c0",
+					Checksum:   "845941f62d9afc42721b21d48b6837289833170fabd27bb50c767167220c89cf",
+					Data:       []byte("vector party"),
+				},
+			},
+		}))
+
+		job := new(memMocks.Job)
+		errChan := make(chan error, 1)
+		errChan <- nil
+		scheduler.On("NewArchiveBatchRepairJob", testTableName, testTableShardID, &dump).Return(job)
+		scheduler.On("SubmitJob", job).Return(nil, errChan)
+		upload := func(dump memstore.ArchiveBatchDump) *http.Response {
+			bs, err := json.Marshal(dump)
+			Ω(err).Should(BeNil())
+			request, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(bs))
+			Ω(err).Should(BeNil())
+			request.Header.Set("Authorization", "Bearer admin")
+			resp, err := http.DefaultClient.Do(request)
+			Ω(err).Should(BeNil())
+			return resp
+		}
+		Ω(upload(dump).StatusCode).Should(Equal(http.StatusOK))
+
+		corrupted := dump
+		corrupted.VectorParties = []memstore.VectorPartyDump{dump.VectorParties[0]}
+		corrupted.VectorParties[0].Data = []byte("corrupted")
+		resp = upload(corrupted)
+		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+		bs, _ := ioutil.ReadAll(resp.Body)
+		Ω(string(bs)).Should(ContainSubstring("checksum mismatch of column c0"))
+
+		otherBatch := dump
+		otherBatch.BatchID++
+		Ω(upload(otherBatch).StatusCode).Should(Equal(http.StatusBadRequest))
+	})
+
 	ginkgo.It("translateEnums should work", func() {
 		vector := memCom.SlicedVector {
 			Values: []interface{} {
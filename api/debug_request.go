@@ -14,6 +14,8 @@
 
 package api
 
+import "github.com/uber/aresdb/memstore"
+
 // ShardRequest is the common request struct for all shard related operations.
 type ShardRequest struct {
 	TableName string `path:"table" json:"table"`
@@ -28,6 +30,19 @@ type ShowBatchRequest struct {
 	NumRows  int `query:"numRows,optional" json:"numRows"`
 }
 
+// DownloadArchiveBatchRequest represents request to download an archive batch for manual repair.
+type DownloadArchiveBatchRequest struct {
+	ShardRequest
+	BatchID int `path:"batch" json:"batch"`
+}
+
+// UploadArchiveBatchRequest represents request to replace an archive batch with a dump.
+type UploadArchiveBatchRequest struct {
+	ShardRequest
+	BatchID int                       `path:"batch" json:"batch"`
+	Body    memstore.ArchiveBatchDump `body:""`
+}
+
 // LookupPrimaryKeyRequest represents primary key lookup request
 type LookupPrimaryKeyRequest struct {
 	ShardRequest
@@ -40,6 +55,11 @@ type ShowShardMetaRequest struct {
 	ShardRequest
 }
 
+// ShowRecoveryProgressRequest represents request to show redolog replay progress of a shard.
+type ShowRecoveryProgressRequest struct {
+	ShardRequest
+}
+
 // ArchiveRequest represents request to start an on demand archiving.
 type ArchiveRequest struct {
 	ShardRequest
@@ -58,6 +78,17 @@ type SnapshotRequest struct {
 	ShardRequest
 }
 
+// CompactionRequest represents request to start an on demand compaction.
+type CompactionRequest struct {
+	ShardRequest
+}
+
+// CompactionSwitchRequest represents request to pause or resume scheduled compaction.
+type CompactionSwitchRequest struct {
+	ShardRequest
+	PauseOrResume string `path:"pauseOrResume" json:"pauseOrResume"`
+}
+
 // PurgeRequest represents request to purge a batch.
 type PurgeRequest struct {
 	ShardRequest
@@ -125,7 +156,27 @@ type ShowJobStatusRequest struct {
 	JobType string `path:"jobType" json:"jobType"`
 }
 
+// KillQueryRequest represents the request to kill queries of a request.
+type KillQueryRequest struct {
+	RequestID string `path:"requestID" json:"requestID"`
+}
+
 // HealthSwitchRequest represents the request to  turn on/off the health check.
 type HealthSwitchRequest struct {
 	OnOrOff string `path:"onOrOff" json:"onOrOff"`
 }
+
+// ShowAuditLogRequest represents the request to query audit log, from and to are unix seconds.
+type ShowAuditLogRequest struct {
+	Actor     string `query:"actor,optional" json:"actor"`
+	Operation string `query:"operation,optional" json:"operation"`
+	Table     string `query:"table,optional" json:"table"`
+	From      int64  `query:"from,optional" json:"from"`
+	To        int64  `query:"to,optional" json:"to"`
+	Limit     int    `query:"limit,optional" json:"limit"`
+}
+
+// CompactEnumDictsRequest represents the request to drop enum cases no longer referenced by the table.
+type CompactEnumDictsRequest struct {
+	TableName string `path:"table" json:"table"`
+}
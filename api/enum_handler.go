@@ -98,6 +98,10 @@ func (handler *EnumHandler) AddEnumCase(w http.ResponseWriter, r *http.Request)
 	}
 
 	addEnumCaseResponse.Body, err = handler.metastore.ExtendEnumDict(addEnumCaseRequest.TableName, addEnumCaseRequest.ColumnName, addEnumCaseRequest.Body.EnumCases)
+	utils.AuditRequest(r, utils.AuditOpAddEnumCases, addEnumCaseRequest.TableName, map[string]interface{}{
+		"column":    addEnumCaseRequest.ColumnName,
+		"enumCases": addEnumCaseRequest.Body.EnumCases,
+	}, err)
 	if err != nil {
 		// TODO: need mapping from metaStore error to api error
 		// for metaStore error might also be user error
@@ -35,6 +35,9 @@ var (
 	ErrMsgDeletedColumn = "Bad request: column is already deleted"
 	// ErrMsgNotImplemented represents error message for method not implemented.
 	ErrMsgNotImplemented = "Not implemented"
+	// ErrMsgWritesNotApplied represents error message for writes that queries ask to read
+	// not applied in time.
+	ErrMsgWritesNotApplied = "Writes to read after are not applied in time"
 	// ErrMsgFailedToJSONMarshalResponseBody respresents error message for failure to marshal
 	// response body into json.
 	ErrMsgFailedToJSONMarshalResponseBody = "Failed to marshal the response body into json"
@@ -68,6 +71,38 @@ var (
 		Code:    http.StatusBadRequest,
 		Message: "Bad request: batch does not exist",
 	}
+	// ErrArchiveBatchRepairUnauthenticated represents api error for archive batch repair requests
+	// without authenticated principal.
+	ErrArchiveBatchRepairUnauthenticated = utils.APIError{
+		Code:    http.StatusUnauthorized,
+		Message: "Archive batch repair requires authentication",
+	}
+	// ErrQueryDoesNotExist represents api error for query does not exist or already finished.
+	ErrQueryDoesNotExist = utils.APIError{
+		Code:    http.StatusNotFound,
+		Message: "Query does not exist or already finished",
+	}
+	// ErrJobDoesNotExist represents api error for admin job does not exist or already expired.
+	ErrJobDoesNotExist = utils.APIError{
+		Code:    http.StatusNotFound,
+		Message: "Job does not exist or already expired",
+	}
+	// ErrJobAlreadyFinished represents api error for cancelling an admin job already finished.
+	ErrJobAlreadyFinished = utils.APIError{
+		Code:    http.StatusBadRequest,
+		Message: "Bad request: job already finished",
+	}
+	// ErrBackupNotEnabled represents api error for backup and restore when backup is not configured.
+	ErrBackupNotEnabled = utils.APIError{
+		Code:    http.StatusBadRequest,
+		Message: "Bad request: backup is not enabled",
+	}
+	// ErrColumnValuesNotSupported represents api error for listing values of a column
+	// which is not enum, numeric or bool.
+	ErrColumnValuesNotSupported = utils.APIError{
+		Code:    http.StatusBadRequest,
+		Message: "Bad request: only values of enum, numeric and bool columns can be listed",
+	}
 	// ErrFailedToJSONMarshalResponseBody represents the api error for failure to marshal
 	// response body into json.
 	ErrFailedToJSONMarshalResponseBody = utils.APIError{
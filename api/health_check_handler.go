@@ -29,6 +29,9 @@ type HealthCheckHandler struct {
 	// Useful when server is lagging behind too much so developper manually call an API in debug handler
 	// to disable the health check.
 	disable bool
+	// This flag controls whether returns 200 or 503 in readiness check handler, server is
+	// not ready until bootstrap finishes or after it starts shutting down.
+	notReady bool
 }
 
 // NewHealthCheckHandler return a new http handler for health check.
@@ -48,6 +51,41 @@ func (handler *HealthCheckHandler) HealthCheck(w http.ResponseWriter, r *http.Re
 	}
 }
 
+// SetReady sets whether the server is ready to serve queries.
+func (handler *HealthCheckHandler) SetReady(ready bool) {
+	handler.Lock()
+	handler.notReady = !ready
+	handler.Unlock()
+}
+
+// IsHealthy returns whether health check is enabled.
+func (handler *HealthCheckHandler) IsHealthy() bool {
+	handler.RLock()
+	defer handler.RUnlock()
+	return !handler.disable
+}
+
+// IsReady returns whether the server is healthy and ready to serve queries.
+func (handler *HealthCheckHandler) IsReady() bool {
+	handler.RLock()
+	defer handler.RUnlock()
+	return !handler.disable && !handler.notReady
+}
+
+// ReadinessCheck is the readiness check endpoint.
+func (handler *HealthCheckHandler) ReadinessCheck(w http.ResponseWriter, r *http.Request) {
+	handler.RLock()
+	disabled, notReady := handler.disable, handler.notReady
+	handler.RUnlock()
+	if disabled {
+		common.RespondBytesWithCode(w, http.StatusServiceUnavailable, []byte("Health check disabled"))
+	} else if notReady {
+		common.RespondBytesWithCode(w, http.StatusServiceUnavailable, []byte("Not ready"))
+	} else {
+		io.WriteString(w, "OK")
+	}
+}
+
 // Version is the Version check endpoint.
 func (handler *HealthCheckHandler) Version(w http.ResponseWriter, r *http.Request) {
 	io.WriteString(w, utils.GetConfig().Version)
@@ -31,6 +31,7 @@ var _ = ginkgo.Describe("HealthCheck", func() {
 	ginkgo.BeforeEach(func() {
 		testRouter := mux.NewRouter()
 		testRouter.HandleFunc("/health", healthCheckHandler.HealthCheck)
+		testRouter.HandleFunc("/ready", healthCheckHandler.ReadinessCheck)
 		testServer = httptest.NewUnstartedServer(WithPanicHandling(testRouter))
 		testServer.Start()
 	})
@@ -56,5 +57,30 @@ var _ = ginkgo.Describe("HealthCheck", func() {
 		Ω(string(b)).Should(Equal("Health check disabled"))
 		Ω(err).Should(BeNil())
 		Ω(resp.StatusCode).Should(Equal(http.StatusServiceUnavailable))
+		healthCheckHandler.disable = false
+	})
+
+	ginkgo.It("ReadinessCheck should work", func() {
+		hostPort := testServer.Listener.Addr().String()
+		resp, err := http.Get(fmt.Sprintf("http://%s/ready", hostPort))
+		Ω(err).Should(BeNil())
+		b, err := ioutil.ReadAll(resp.Body)
+		Ω(string(b)).Should(Equal("OK"))
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+
+		healthCheckHandler.SetReady(false)
+		resp, err = http.Get(fmt.Sprintf("http://%s/ready", hostPort))
+		Ω(err).Should(BeNil())
+		b, err = ioutil.ReadAll(resp.Body)
+		Ω(string(b)).Should(Equal("Not ready"))
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusServiceUnavailable))
+
+		// health check is not affected by readiness
+		resp, err = http.Get(fmt.Sprintf("http://%s/health", hostPort))
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		healthCheckHandler.SetReady(true)
 	})
 })
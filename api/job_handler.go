@@ -0,0 +1,364 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/backup"
+	"github.com/uber/aresdb/memstore"
+	memCom "github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	// PreloadJobType is the admin job type for preloading archive columns into host memory.
+	PreloadJobType = "preload"
+	// BackupJobType is the admin job type for backing up archive batches to object storage.
+	BackupJobType = "backup"
+	// RestoreJobType is the admin job type for restoring archive batches from object storage.
+	RestoreJobType = "restore"
+)
+
+// JobHandler handles long running admin jobs asynchronously. Each submitted job
+// gets a job id which can be used to check its state, progress and logs or to cancel it.
+type JobHandler struct {
+	memStore   memstore.MemStore
+	jobManager *AdminJobManager
+	// nil if backup is not enabled.
+	backupManager *backup.Manager
+}
+
+// NewJobHandler returns a new JobHandler.
+func NewJobHandler(memStore memstore.MemStore, backupManager *backup.Manager) *JobHandler {
+	return &JobHandler{
+		memStore:      memStore,
+		jobManager:    NewAdminJobManager(),
+		backupManager: backupManager,
+	}
+}
+
+// Register registers http handlers.
+func (handler *JobHandler) Register(router *mux.Router, wrappers ...utils.HTTPHandlerWrapper) {
+	router.HandleFunc("/jobs", utils.ApplyHTTPWrappers(handler.ListJobs, wrappers)).Methods(http.MethodGet)
+	router.HandleFunc("/jobs", utils.ApplyHTTPWrappers(handler.SubmitJob, wrappers)).Methods(http.MethodPost)
+	router.HandleFunc("/jobs/{jobID}", utils.ApplyHTTPWrappers(handler.GetJob, wrappers)).Methods(http.MethodGet)
+	router.HandleFunc("/jobs/{jobID}", utils.ApplyHTTPWrappers(handler.CancelJob, wrappers)).Methods(http.MethodDelete)
+	router.HandleFunc("/backups/{table}", utils.ApplyHTTPWrappers(handler.ListBackups, wrappers)).Methods(http.MethodGet)
+}
+
+// SubmitJob swagger:route POST /jobs submitJob
+// submits an admin job (archiving, backfill, snapshot, purge, compaction, hllRecompute, preload, backup
+// or restore) for a table shard and returns immediately with the job id. Compaction jobs are only
+// supported for dimension tables and hllRecompute jobs for fact tables.
+//
+// Responses:
+//    default: errorResponse
+//        202: AdminJobStatus
+func (handler *JobHandler) SubmitJob(w http.ResponseWriter, r *http.Request) {
+	var request SubmitJobRequest
+	if err := common.ReadRequest(r, &request); err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	// The table to restore into may not exist yet.
+	if request.Body.Type == BackupJobType || request.Body.Type == RestoreJobType {
+		handler.submitBackupJob(w, request)
+		return
+	}
+
+	shard, err := handler.memStore.GetTableShard(request.Body.Table, request.Body.Shard)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+	defer shard.Users.Done()
+
+	var runner AdminJobRunner
+	switch request.Body.Type {
+	case string(memCom.ArchivingJobType):
+		runner = handler.runSchedulerJob(func(scheduler memstore.Scheduler) memstore.Job {
+			return scheduler.NewArchivingJob(request.Body.Table, request.Body.Shard, request.Body.Cutoff)
+		})
+	case string(memCom.BackfillJobType):
+		runner = handler.runSchedulerJob(func(scheduler memstore.Scheduler) memstore.Job {
+			return scheduler.NewBackfillJob(request.Body.Table, request.Body.Shard)
+		})
+	case string(memCom.SnapshotJobType):
+		runner = handler.runSchedulerJob(func(scheduler memstore.Scheduler) memstore.Job {
+			return scheduler.NewSnapshotJob(request.Body.Table, request.Body.Shard)
+		})
+	case string(memCom.CompactionJobType):
+		if shard.LiveStore.CompactionManager == nil {
+			common.RespondWithBadRequest(w, fmt.Errorf("compaction is only supported for dimension tables"))
+			return
+		}
+		runner = handler.runSchedulerJob(func(scheduler memstore.Scheduler) memstore.Job {
+			return scheduler.NewCompactionJob(request.Body.Table, request.Body.Shard)
+		})
+	case string(memCom.HLLRecomputeJobType):
+		if !shard.Schema.Schema.IsFactTable {
+			common.RespondWithBadRequest(w, fmt.Errorf("hll recomputation is only supported for fact tables"))
+			return
+		}
+		runner = handler.runSchedulerJob(func(scheduler memstore.Scheduler) memstore.Job {
+			return scheduler.NewHLLRecomputeJob(request.Body.Table, request.Body.Shard)
+		})
+	case string(memCom.PurgeJobType):
+		batchIDStart, batchIDEnd, err := getPurgeBatchRange(shard,
+			request.Body.BatchIDStart, request.Body.BatchIDEnd, request.Body.SafePurge)
+		if err != nil {
+			common.RespondWithBadRequest(w, err)
+			return
+		}
+		runner = handler.runSchedulerJob(func(scheduler memstore.Scheduler) memstore.Job {
+			return scheduler.NewPurgeJob(request.Body.Table, request.Body.Shard, batchIDStart, batchIDEnd)
+		})
+	case PreloadJobType:
+		columnIDs, err := getPreloadColumnIDs(shard, request.Body.Columns)
+		if err != nil {
+			common.RespondWithBadRequest(w, err)
+			return
+		}
+		runner = handler.runPreloadJob(request.Body.Table, request.Body.Shard, columnIDs,
+			request.Body.StartDay, request.Body.EndDay)
+	default:
+		common.RespondWithBadRequest(w, fmt.Errorf("unsupported job type %s", request.Body.Type))
+		return
+	}
+
+	job := handler.jobManager.Submit(request.Body.Type, request.Body.Table, request.Body.Shard, runner)
+	common.RespondJSONObjectWithCode(w, http.StatusAccepted, job)
+}
+
+// submitBackupJob submits a backup or restore job of a table shard.
+func (handler *JobHandler) submitBackupJob(w http.ResponseWriter, request SubmitJobRequest) {
+	if handler.backupManager == nil {
+		common.RespondWithError(w, ErrBackupNotEnabled)
+		return
+	}
+	if err := backup.ValidateBackupID(request.Body.BackupID); err != nil {
+		common.RespondWithError(w, err)
+		return
+	}
+
+	table, shardID := request.Body.Table, request.Body.Shard
+	var run func(job *AdminJob) error
+	if request.Body.Type == BackupJobType {
+		if request.Body.BaseBackupID != "" {
+			if err := backup.ValidateBackupID(request.Body.BaseBackupID); err != nil {
+				common.RespondWithError(w, err)
+				return
+			}
+		}
+		shard, err := handler.memStore.GetTableShard(table, shardID)
+		if err != nil {
+			common.RespondWithBadRequest(w, err)
+			return
+		}
+		shard.Users.Done()
+
+		run = func(job *AdminJob) error {
+			return handler.backupManager.Backup(table, shardID, request.Body.BackupID, request.Body.BaseBackupID, job)
+		}
+	} else {
+		sourceTable := request.Body.SourceTable
+		if sourceTable == "" {
+			sourceTable = table
+		}
+		run = func(job *AdminJob) error {
+			return handler.backupManager.Restore(table, shardID, sourceTable, request.Body.BackupID, job)
+		}
+	}
+
+	job := handler.jobManager.Submit(request.Body.Type, table, shardID, func(job *AdminJob) error {
+		if err := run(job); err != backup.ErrCancelled {
+			return err
+		}
+		return errAdminJobCancelled
+	})
+	common.RespondJSONObjectWithCode(w, http.StatusAccepted, job)
+}
+
+// ListBackups swagger:route GET /backups/{table} listBackups
+// lists backups of a table in object storage.
+//
+// Responses:
+//    default: errorResponse
+//        200: []BackupInfo
+func (handler *JobHandler) ListBackups(w http.ResponseWriter, r *http.Request) {
+	var request ListBackupsRequest
+	if err := common.ReadRequest(r, &request); err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	if handler.backupManager == nil {
+		common.RespondWithError(w, ErrBackupNotEnabled)
+		return
+	}
+
+	backups, err := handler.backupManager.ListBackups(request.Table)
+	if err != nil {
+		common.RespondWithError(w, err)
+		return
+	}
+	common.RespondWithJSONObject(w, backups)
+}
+
+// ListJobs swagger:route GET /jobs listJobs
+// lists recent admin jobs with most recently submitted ones first.
+//
+// Responses:
+//    default: errorResponse
+//        200: []AdminJobStatus
+func (handler *JobHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	common.RespondWithJSONObject(w, handler.jobManager.List())
+}
+
+// GetJob swagger:route GET /jobs/{jobID} getJob
+// returns the state, progress and logs of an admin job.
+//
+// Responses:
+//    default: errorResponse
+//        200: AdminJobStatus
+func (handler *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	var request GetJobRequest
+	if err := common.ReadRequest(r, &request); err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	job, ok := handler.jobManager.Get(request.JobID)
+	if !ok {
+		common.RespondWithError(w, ErrJobDoesNotExist)
+		return
+	}
+	common.RespondWithJSONObject(w, job)
+}
+
+// CancelJob swagger:route DELETE /jobs/{jobID} cancelJob
+// cancels an admin job. Pending jobs will not be started and running jobs will stop
+// at the next cancellation point.
+//
+// Responses:
+//    default: errorResponse
+//        200: AdminJobStatus
+func (handler *JobHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	var request CancelJobRequest
+	if err := common.ReadRequest(r, &request); err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	if _, ok := handler.jobManager.Get(request.JobID); !ok {
+		common.RespondWithError(w, ErrJobDoesNotExist)
+		return
+	}
+
+	if !handler.jobManager.Cancel(request.JobID) {
+		common.RespondWithError(w, ErrJobAlreadyFinished)
+		return
+	}
+	job, _ := handler.jobManager.Get(request.JobID)
+	common.RespondWithJSONObject(w, job)
+}
+
+// runSchedulerJob returns a runner which submits the job to memstore scheduler and waits for it
+// to finish. The job can only be cancelled before it's handed over to the scheduler.
+func (handler *JobHandler) runSchedulerJob(newJob func(scheduler memstore.Scheduler) memstore.Job) AdminJobRunner {
+	return func(job *AdminJob) error {
+		scheduler := handler.memStore.GetScheduler()
+		schedulerJob := newJob(scheduler)
+		if job.Cancelled() {
+			return errAdminJobCancelled
+		}
+
+		job.Logf("Submitting %s to scheduler", schedulerJob)
+		err, errChan := scheduler.SubmitJob(schedulerJob)
+		if err != nil {
+			return err
+		}
+		job.Logf("Scheduler started running %s", schedulerJob)
+		return <-errChan
+	}
+}
+
+// runPreloadJob returns a runner which preloads given columns of archive batches within
+// (startDay, endDay] into host memory. If both days are zero, preloading days of each column
+// will be used. Cancellation is checked between columns.
+func (handler *JobHandler) runPreloadJob(table string, shardID int, columnIDs []int, startDay, endDay int) AdminJobRunner {
+	return func(job *AdminJob) error {
+		shard, err := handler.memStore.GetTableShard(table, shardID)
+		if err != nil {
+			return err
+		}
+		defer shard.Users.Done()
+
+		currentDay := int(utils.Now().Unix() / 86400)
+		for i, columnID := range columnIDs {
+			if job.Cancelled() {
+				return errAdminJobCancelled
+			}
+
+			columnStartDay, columnEndDay := startDay, endDay
+			if columnStartDay == 0 && columnEndDay == 0 {
+				shard.Schema.RLock()
+				preloadingDays := shard.Schema.Schema.Columns[columnID].Config.PreloadingDays
+				shard.Schema.RUnlock()
+				columnStartDay, columnEndDay = currentDay-preloadingDays, currentDay
+			}
+
+			job.Logf("Preloading column %d within (%d, %d]", columnID, columnStartDay, columnEndDay)
+			shard.PreloadColumn(columnID, columnStartDay, columnEndDay)
+			job.SetProgress(float64(i+1) / float64(len(columnIDs)))
+		}
+		return nil
+	}
+}
+
+// getPreloadColumnIDs resolves the column ids to preload. All non deleted columns will be returned
+// if no column is specified.
+func getPreloadColumnIDs(shard *memstore.TableShard, columns []string) ([]int, error) {
+	shard.Schema.RLock()
+	defer shard.Schema.RUnlock()
+
+	if !shard.Schema.Schema.IsFactTable {
+		return nil, utils.APIError{Message: "preload is only supported for fact tables"}
+	}
+
+	var columnIDs []int
+	if len(columns) == 0 {
+		for columnID, column := range shard.Schema.Schema.Columns {
+			if !column.Deleted {
+				columnIDs = append(columnIDs, columnID)
+			}
+		}
+		return columnIDs, nil
+	}
+
+	for _, column := range columns {
+		columnID, ok := shard.Schema.ColumnIDs[column]
+		if !ok {
+			return nil, ErrColumnDoesNotExist
+		}
+		columnIDs = append(columnIDs, columnID)
+	}
+	return columnIDs, nil
+}
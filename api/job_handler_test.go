@@ -0,0 +1,239 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	memCom "github.com/uber/aresdb/memstore/common"
+	memMocks "github.com/uber/aresdb/memstore/mocks"
+	metaCom "github.com/uber/aresdb/metastore/common"
+)
+
+var _ = ginkgo.Describe("JobHandler", func() {
+	testTable := metaCom.Table{
+		Name:        "test",
+		IsFactTable: true,
+		Columns: []metaCom.Column{
+			{Name: "c0", Type: metaCom.Uint32},
+			{Name: "c1", Type: metaCom.Bool, Deleted: true},
+		},
+		Config: metaCom.TableConfig{
+			BatchSize: 10,
+		},
+	}
+
+	var testServer *httptest.Server
+	var scheduler *memMocks.Scheduler
+	var hostPort string
+
+	getJob := func(jobID string) AdminJobStatus {
+		resp, err := http.Get(fmt.Sprintf("http://%s/jobs/%s", hostPort, jobID))
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		var job AdminJobStatus
+		Ω(json.NewDecoder(resp.Body).Decode(&job)).Should(BeNil())
+		return job
+	}
+
+	ginkgo.BeforeEach(func() {
+		schema := memCom.NewTableSchema(&testTable)
+		memStore := CreateMemStore(schema, 0, CreateMockMetaStore(), CreateMockDiskStore())
+		memStore.On("GetTableShard", "test", 1).Return(nil, fmt.Errorf("Failed to get shard"))
+		scheduler = new(memMocks.Scheduler)
+		memStore.On("GetScheduler").Return(scheduler)
+
+		testRouter := mux.NewRouter()
+		NewJobHandler(memStore, nil).Register(testRouter)
+		testServer = httptest.NewUnstartedServer(testRouter)
+		testServer.Start()
+		hostPort = testServer.Listener.Addr().String()
+	})
+
+	ginkgo.AfterEach(func() {
+		testServer.Close()
+	})
+
+	ginkgo.It("SubmitJob should work", func() {
+		job := new(memMocks.Job)
+		job.On("String").Return("purge job")
+		errChan := make(chan error, 1)
+		errChan <- nil
+		scheduler.On("NewPurgeJob", "test", 0, 1, 5).Return(job)
+		scheduler.On("SubmitJob", job).Return(nil, errChan)
+
+		var request SubmitJobRequest
+		request.Body.Type = "purge"
+		request.Body.Table = "test"
+		request.Body.BatchIDStart = 1
+		request.Body.BatchIDEnd = 5
+		resp, err := http.Post(fmt.Sprintf("http://%s/jobs", hostPort), "application/json", RequestToBody(&request.Body))
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusAccepted))
+		var submitted AdminJobStatus
+		Ω(json.NewDecoder(resp.Body).Decode(&submitted)).Should(BeNil())
+		Ω(submitted.ID).ShouldNot(BeEmpty())
+		Ω(submitted.Type).Should(Equal("purge"))
+		Ω(submitted.Table).Should(Equal("test"))
+
+		Eventually(func() AdminJobState {
+			return getJob(submitted.ID).State
+		}).Should(Equal(AdminJobSucceeded))
+		finished := getJob(submitted.ID)
+		Ω(finished.Progress).Should(Equal(1.0))
+		Ω(finished.Logs).ShouldNot(BeEmpty())
+
+		resp, err = http.Get(fmt.Sprintf("http://%s/jobs", hostPort))
+		Ω(err).Should(BeNil())
+		var jobs []AdminJobStatus
+		Ω(json.NewDecoder(resp.Body).Decode(&jobs)).Should(BeNil())
+		Ω(jobs).Should(HaveLen(1))
+
+		// cancel finished job.
+		req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("http://%s/jobs/%s", hostPort, submitted.ID), nil)
+		resp, err = http.DefaultClient.Do(req)
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+
+		// cancel non existing job.
+		req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("http://%s/jobs/%s", hostPort, "unknown"), nil)
+		resp, err = http.DefaultClient.Do(req)
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusNotFound))
+	})
+
+	ginkgo.It("SubmitJob should fail for invalid requests", func() {
+		var request SubmitJobRequest
+		request.Body.Type = "reshard"
+		request.Body.Table = "test"
+		resp, err := http.Post(fmt.Sprintf("http://%s/jobs", hostPort), "application/json", RequestToBody(&request.Body))
+		Ω(err).Should(BeNil())
+		bs, _ := ioutil.ReadAll(resp.Body)
+		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+		Ω(string(bs)).Should(ContainSubstring("unsupported job type reshard"))
+
+		request.Body.Type = "purge"
+		request.Body.BatchIDStart = 5
+		request.Body.BatchIDEnd = 1
+		resp, err = http.Post(fmt.Sprintf("http://%s/jobs", hostPort), "application/json", RequestToBody(&request.Body))
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+
+		request.Body.Type = PreloadJobType
+		request.Body.Columns = []string{"unknown"}
+		resp, err = http.Post(fmt.Sprintf("http://%s/jobs", hostPort), "application/json", RequestToBody(&request.Body))
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+
+		request.Body.Shard = 1
+		resp, err = http.Post(fmt.Sprintf("http://%s/jobs", hostPort), "application/json", RequestToBody(&request.Body))
+		Ω(err).Should(BeNil())
+		bs, _ = ioutil.ReadAll(resp.Body)
+		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+		Ω(string(bs)).Should(ContainSubstring("Failed to get shard"))
+	})
+
+	ginkgo.It("backup and restore should fail if backup is not enabled", func() {
+		var request SubmitJobRequest
+		request.Body.Type = BackupJobType
+		request.Body.Table = "test"
+		request.Body.BackupID = "b1"
+		resp, err := http.Post(fmt.Sprintf("http://%s/jobs", hostPort), "application/json", RequestToBody(&request.Body))
+		Ω(err).Should(BeNil())
+		bs, _ := ioutil.ReadAll(resp.Body)
+		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+		Ω(string(bs)).Should(ContainSubstring("backup is not enabled"))
+
+		request.Body.Type = RestoreJobType
+		resp, err = http.Post(fmt.Sprintf("http://%s/jobs", hostPort), "application/json", RequestToBody(&request.Body))
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+
+		resp, err = http.Get(fmt.Sprintf("http://%s/backups/test", hostPort))
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+	})
+})
+
+var _ = ginkgo.Describe("AdminJobManager", func() {
+	ginkgo.It("Cancel should work", func() {
+		manager := NewAdminJobManager()
+
+		started := make(chan struct{})
+		running := manager.Submit("test", "t", 0, func(job *AdminJob) error {
+			close(started)
+			for {
+				if job.Cancelled() {
+					return errAdminJobCancelled
+				}
+				job.SetProgress(0.5)
+				time.Sleep(time.Millisecond)
+			}
+		})
+		<-started
+
+		// second job is pending since only one job runs at a time.
+		pending := manager.Submit("test", "t", 0, func(job *AdminJob) error {
+			return fmt.Errorf("should not run")
+		})
+		status, ok := manager.Get(pending.ID)
+		Ω(ok).Should(BeTrue())
+		Ω(status.State).Should(Equal(AdminJobPending))
+
+		jobs := manager.List()
+		Ω(jobs).Should(HaveLen(2))
+		Ω(jobs[0].ID).Should(Equal(pending.ID))
+		Ω(jobs[1].ID).Should(Equal(running.ID))
+
+		Ω(manager.Cancel(pending.ID)).Should(BeTrue())
+		Ω(manager.Cancel(running.ID)).Should(BeTrue())
+		Ω(manager.Cancel("unknown")).Should(BeFalse())
+
+		Eventually(func() AdminJobState {
+			status, _ := manager.Get(pending.ID)
+			return status.State
+		}).Should(Equal(AdminJobCancelled))
+		status, _ = manager.Get(running.ID)
+		Ω(status.State).Should(Equal(AdminJobCancelled))
+		Ω(status.Progress).Should(Equal(0.5))
+		Ω(status.StartedAt).ShouldNot(BeNil())
+		Ω(status.FinishedAt).ShouldNot(BeNil())
+
+		Ω(manager.Cancel(running.ID)).Should(BeFalse())
+	})
+
+	ginkgo.It("Submit should record failures", func() {
+		manager := NewAdminJobManager()
+		job := manager.Submit("test", "t", 0, func(job *AdminJob) error {
+			job.Logf("doing %s", "something")
+			return fmt.Errorf("some error")
+		})
+		Eventually(func() AdminJobState {
+			status, _ := manager.Get(job.ID)
+			return status.State
+		}).Should(Equal(AdminJobFailed))
+		status, _ := manager.Get(job.ID)
+		Ω(status.Error).Should(Equal("some error"))
+		Ω(status.Logs).Should(ContainElement(ContainSubstring("doing something")))
+	})
+})
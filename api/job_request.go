@@ -0,0 +1,57 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+// SubmitJobRequest represents request to submit an admin job.
+type SubmitJobRequest struct {
+	Body struct {
+		// Type is one of archiving, backfill, snapshot, purge, compaction, hllRecompute, preload, backup and restore.
+		Type  string `json:"type"`
+		Table string `json:"table"`
+		Shard int    `json:"shard"`
+		// For archiving job.
+		Cutoff uint32 `json:"cutoff,omitempty"`
+		// For purge job.
+		BatchIDStart int  `json:"batchIDStart,omitempty"`
+		BatchIDEnd   int  `json:"batchIDEnd,omitempty"`
+		SafePurge    bool `json:"safePurge,omitempty"`
+		// For preload job, preloads columns within (startDay, endDay]. All columns
+		// will be preloaded if not specified.
+		Columns  []string `json:"columns,omitempty"`
+		StartDay int      `json:"startDay,omitempty"`
+		EndDay   int      `json:"endDay,omitempty"`
+		// For backup and restore job. Backup of all shards of a table should share the same id.
+		BackupID string `json:"backupID,omitempty"`
+		// For incremental backup, batch versions already in the base backup are not uploaded again.
+		BaseBackupID string `json:"baseBackupID,omitempty"`
+		// For restore job, the table the backup was taken from, defaults to the table to restore.
+		SourceTable string `json:"sourceTable,omitempty"`
+	} `body:""`
+}
+
+// ListBackupsRequest represents request to list backups of a table.
+type ListBackupsRequest struct {
+	Table string `path:"table" json:"table"`
+}
+
+// GetJobRequest represents request to get status of an admin job.
+type GetJobRequest struct {
+	JobID string `path:"jobID" json:"jobID"`
+}
+
+// CancelJobRequest represents request to cancel an admin job.
+type CancelJobRequest struct {
+	JobID string `path:"jobID" json:"jobID"`
+}
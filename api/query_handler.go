@@ -15,47 +15,189 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/uber/aresdb/cluster/topology"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/uber/aresdb/memstore"
+	memCom "github.com/uber/aresdb/memstore/common"
+	metaCom "github.com/uber/aresdb/metastore/common"
 	"github.com/uber/aresdb/query"
 	queryCom "github.com/uber/aresdb/query/common"
 	"github.com/uber/aresdb/utils"
 
 	"time"
 
+	"github.com/gofrs/uuid"
 	"github.com/gorilla/mux"
 	apiCom "github.com/uber/aresdb/api/common"
 	"github.com/uber/aresdb/common"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // QueryHandler handles query execution.
 type QueryHandler struct {
 	shardOwner    topology.ShardOwner
 	memStore      memstore.MemStore
+	metaStore     metaCom.MetaStore
 	deviceManager *query.DeviceManager
+	queryRegistry *query.QueryRegistry
+	queryBlocks   *queryCom.QueryBlocklist
+	queryTraces   *utils.QueryTraceStore
+	// fetches schemas without waiting for next periodical fetch, nil if schemas are not
+	// fetched from controller
+	schemaRefresher func()
 }
 
-// NewQueryHandler creates a new QueryHandler.
-func NewQueryHandler(memStore memstore.MemStore, shardOwner topology.ShardOwner, cfg common.QueryConfig) *QueryHandler {
+// NewQueryHandler creates a new QueryHandler, DDL statements in SQL are applied to metaStore.
+func NewQueryHandler(memStore memstore.MemStore, metaStore metaCom.MetaStore, shardOwner topology.ShardOwner, cfg common.QueryConfig) *QueryHandler {
 	return &QueryHandler{
 		memStore:      memStore,
+		metaStore:     metaStore,
 		shardOwner:    shardOwner,
 		deviceManager: query.NewDeviceManager(cfg),
+		queryRegistry: query.NewQueryRegistry(),
+		queryBlocks:   queryCom.NewQueryBlocklist(),
+		queryTraces:   utils.NewQueryTraceStore(),
 	}
 }
 
+// SetSchemaRefresher sets the function to fetch schemas from controller immediately, it is called
+// when queries from broker expect newer schemas than local ones.
+func (handler *QueryHandler) SetSchemaRefresher(schemaRefresher func()) {
+	handler.schemaRefresher = schemaRefresher
+}
+
 // GetDeviceManager returns the device manager of query handler.
 func (handler *QueryHandler) GetDeviceManager() *query.DeviceManager {
 	return handler.deviceManager
 }
 
+// GetQueryRegistry returns the registry of queries being executed.
+func (handler *QueryHandler) GetQueryRegistry() *query.QueryRegistry {
+	return handler.queryRegistry
+}
+
+// GetQueryBlocklist returns the queries blocked by admin.
+func (handler *QueryHandler) GetQueryBlocklist() *queryCom.QueryBlocklist {
+	return handler.queryBlocks
+}
+
 // Register registers http handlers.
 func (handler *QueryHandler) Register(router *mux.Router, wrappers ...utils.HTTPHandlerWrapper) {
 	router.HandleFunc("/aql", utils.ApplyHTTPWrappers(handler.HandleAQL, wrappers)).Methods(http.MethodGet, http.MethodPost)
 	router.HandleFunc("/sql", utils.ApplyHTTPWrappers(handler.HandleSQL, wrappers)).Methods(http.MethodGet, http.MethodPost)
+	router.HandleFunc("/sql/complete", utils.ApplyHTTPWrappers(handler.CompleteSQL, wrappers)).Methods(http.MethodPost)
+	router.HandleFunc("/{table}/columns/{column}/values", utils.ApplyHTTPWrappers(handler.ListColumnValues, wrappers)).Methods(http.MethodGet)
+	router.HandleFunc("/blocks", utils.ApplyHTTPWrappers(handler.ListQueryBlocks, wrappers)).Methods(http.MethodGet)
+	router.HandleFunc("/blocks", utils.ApplyHTTPWrappers(handler.BlockQueries, wrappers)).Methods(http.MethodPost)
+	router.HandleFunc("/blocks/{table}", utils.ApplyHTTPWrappers(handler.UnblockQueries, wrappers)).Methods(http.MethodDelete)
+	router.HandleFunc("/traces/{id}", utils.ApplyHTTPWrappers(handler.GetQueryTrace, wrappers)).Methods(http.MethodGet)
+}
+
+// GetQueryTrace swagger:route GET /query/traces/{id} getQueryTrace
+// get the trace of a recent query executed in trace mode in chrome tracing format
+//
+// Produces:
+//    - application/json
+//
+// Responses:
+//    default: errorResponse
+//        200: queryTraceResponse
+func (handler *QueryHandler) GetQueryTrace(w http.ResponseWriter, r *http.Request) {
+	var request apiCom.QueryTraceRequest
+	err := apiCom.ReadRequest(r, &request)
+	if err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	queryTrace := handler.queryTraces.Get(request.ID)
+	if queryTrace == nil {
+		apiCom.RespondWithError(w, apiCom.ErrQueryTraceDoesNotExist)
+		return
+	}
+	apiCom.RespondWithJSONObject(w, queryTrace.ChromeTrace())
+}
+
+// ListQueryBlocks swagger:route GET /query/blocks listQueryBlocks
+// list queries blocked by admin which have not expired
+//
+// Produces:
+//    - application/json
+//
+// Responses:
+//    default: errorResponse
+//        200: listQueryBlocksResponse
+func (handler *QueryHandler) ListQueryBlocks(w http.ResponseWriter, r *http.Request) {
+	apiCom.RespondWithJSONObject(w, handler.queryBlocks.List())
+}
+
+// BlockQueries swagger:route POST /query/blocks blockQueries
+// block queries on a table matching the fingerprint for a period, so that a bad query can be
+// stopped without redeploying its clients
+//
+// Consumes:
+//    - application/json
+//
+// Produces:
+//    - application/json
+//
+// Responses:
+//    default: errorResponse
+//        200: queryBlockResponse
+func (handler *QueryHandler) BlockQueries(w http.ResponseWriter, r *http.Request) {
+	var request apiCom.QueryBlockRequest
+	err := apiCom.ReadRequest(r, &request)
+	if err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	var block queryCom.QueryBlock
+	block, err = request.QueryBlock(utils.Now().Unix())
+	utils.AuditRequest(r, utils.AuditOpBlockQueries, block.Table, map[string]interface{}{
+		"fingerprint": block.Fingerprint,
+		"reason":      block.Reason,
+		"expiresAt":   block.ExpiresAt,
+	}, err)
+	if err != nil {
+		apiCom.RespondWithError(w, err)
+		return
+	}
+	handler.queryBlocks.Block(block)
+	utils.GetLogger().With("block", block).Info("Blocked queries")
+	apiCom.RespondWithJSONObject(w, block)
+}
+
+// UnblockQueries swagger:route DELETE /query/blocks/{table} unblockQueries
+// remove the block on queries of the table with the fingerprint
+//
+// Responses:
+//    default: errorResponse
+//        200: noContentResponse
+func (handler *QueryHandler) UnblockQueries(w http.ResponseWriter, r *http.Request) {
+	var request apiCom.QueryUnblockRequest
+	err := apiCom.ReadRequest(r, &request)
+	if err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	if !handler.queryBlocks.Unblock(request.TableName, request.Fingerprint) {
+		err = apiCom.ErrQueryBlockDoesNotExist
+	}
+	utils.AuditRequest(r, utils.AuditOpUnblockQueries, request.TableName, map[string]interface{}{
+		"fingerprint": request.Fingerprint,
+	}, err)
+	if err != nil {
+		apiCom.RespondWithError(w, err)
+		return
+	}
+	apiCom.RespondWithJSONObject(w, nil)
 }
 
 // HandleAQL swagger:route POST /query/aql queryAQL
@@ -94,6 +236,27 @@ func (handler *QueryHandler) handleAQLInternal(aqlRequest apiCom.AQLRequest, w h
 	var qcs []*query.AQLQueryContext
 	var statusCode int
 
+	// continue the trace from broker if any
+	traceCtx := utils.ExtractTraceContext(utils.WithAuthenticationFrom(context.Background(), r.Context()), r.Header)
+	var queryTrace *utils.QueryTrace
+	if aqlRequest.Trace != 0 {
+		queryTrace = utils.NewQueryTrace("datanode")
+		traceCtx = utils.WithQueryTrace(traceCtx, queryTrace)
+		// spans are sent back to broker in response header for data only requests, otherwise the
+		// trace is stored for download after the request span ends.
+		if aqlRequest.DataOnly == 0 {
+			traceID := newQueryTraceID()
+			w.Header().Set(utils.QueryTraceIDHeaderKey, traceID)
+			defer handler.queryTraces.Put(traceID, queryTrace)
+		}
+	}
+	ctx, span := utils.StartSpan(traceCtx, "datanode.HandleAQL",
+		attribute.String("requestID", aqlRequest.RequestID))
+	defer func() {
+		span.SetAttributes(attribute.Int("statusCode", statusCode))
+		utils.EndSpan(span, err)
+	}()
+
 	defer func() {
 		var errStr string
 		if err != nil {
@@ -140,6 +303,49 @@ func (handler *QueryHandler) handleAQLInternal(aqlRequest apiCom.AQLRequest, w h
 		return
 	}
 
+	if readAfter := r.Header.Get(memCom.ReadAfterHeaderKey); readAfter != "" {
+		if err = waitForWritePositions(ctx, handler.memStore, readAfter, r.Header.Get(memCom.ReadAfterTimeoutHeaderKey)); err != nil {
+			statusCode = http.StatusServiceUnavailable
+			apiCom.RespondWithError(w, utils.APIError{
+				Code:    statusCode,
+				Message: ErrMsgWritesNotApplied,
+				Cause:   err,
+			})
+			return
+		}
+	}
+
+	// queries still expecting other schema versions after waiting are rejected by compilation.
+	handler.waitForSchemaVersions(ctx, aqlRequest.Body.Queries)
+
+	if aqlRequest.Strict != 0 {
+		for i := range aqlRequest.Body.Queries {
+			aqlRequest.Body.Queries[i].Strict = true
+		}
+	}
+
+	if aqlRequest.TriValuedLogic != 0 {
+		for i := range aqlRequest.Body.Queries {
+			aqlRequest.Body.Queries[i].TriValuedLogic = true
+		}
+	}
+
+	if aqlRequest.DataScope != "" {
+		for i := range aqlRequest.Body.Queries {
+			if aqlRequest.Body.Queries[i].DataScope == "" {
+				aqlRequest.Body.Queries[i].DataScope = aqlRequest.DataScope
+			}
+		}
+	}
+
+	if aqlRequest.UnknownEnumValue != "" {
+		for i := range aqlRequest.Body.Queries {
+			if aqlRequest.Body.Queries[i].UnknownEnumValue == "" {
+				aqlRequest.Body.Queries[i].UnknownEnumValue = aqlRequest.UnknownEnumValue
+			}
+		}
+	}
+
 	returnHLL := aqlRequest.Accept == utils.HTTPContentTypeHyperLogLog
 	if aqlRequest.DeviceChoosingTimeout <= 0 {
 		aqlRequest.DeviceChoosingTimeout = -1
@@ -152,21 +358,40 @@ func (handler *QueryHandler) handleAQLInternal(aqlRequest apiCom.AQLRequest, w h
 	if !returnHLL && canEagerFlush(aqlRequest.Body.Queries) {
 		statusCode = http.StatusOK
 		aqlQuery := aqlRequest.Body.Queries[0]
+		if err = checkQueryBlocks(handler.queryBlocks, &aqlQuery); err != nil {
+			statusCode = http.StatusForbidden
+			apiCom.RespondWithError(w, utils.APIError{
+				Code:    statusCode,
+				Message: apiCom.ErrMsgQueryBlocked,
+				Cause:   err,
+			})
+			return
+		}
 		qc := &query.AQLQueryContext{
 			Query:         &aqlQuery,
 			ReturnHLLData: false,
 			DataOnly:      aqlRequest.DataOnly != 0,
+			Origin:        aqlRequest.Origin,
+			RequestID:     aqlRequest.RequestID,
+			Principal:     utils.PrincipalFromContext(ctx),
 		}
-		qc.Compile(handler.memStore, handler.shardOwner)
+		compileQuery(ctx, qc, handler.memStore, handler.shardOwner)
 		qc.ResponseWriter = w
 		if qc.Error != nil {
 			err = qc.Error
-			statusCode = http.StatusBadRequest
+			statusCode = compileErrorStatusCode(qc.Error)
 			w.WriteHeader(statusCode)
 			return
 		}
 		// for logging purpose only
 		qcs = append(qcs, qc)
+		setDataFreshnessHeader(w, handler.memStore, qcs)
+		queryCom.SetQueryWarningsHeader(w, qc.Warnings)
+		// scan stats are only known after the result is streamed.
+		w.Header().Set("Trailer", queryCom.ScanStatsHeaderKey)
+		if queryTrace != nil && qc.DataOnly {
+			w.Header().Add("Trailer", utils.QueryTraceHeaderKey)
+		}
 
 		qc.FindDeviceForQuery(handler.memStore, aqlRequest.Device, handler.deviceManager, aqlRequest.DeviceChoosingTimeout)
 		if qc.Error != nil {
@@ -177,7 +402,12 @@ func (handler *QueryHandler) handleAQLInternal(aqlRequest apiCom.AQLRequest, w h
 		}
 		defer handler.deviceManager.ReleaseReservedMemory(qc.Device, qc.Query)
 
-		qc.ProcessQuery(handler.memStore)
+		handler.queryRegistry.Register(qc)
+		// broker cancels the request once the limit is satisfied by other datanodes.
+		stopWatching := killOnCancel(r.Context(), qc)
+		processQuery(ctx, qc, handler.memStore)
+		stopWatching()
+		handler.queryRegistry.Deregister(qc)
 		if qc.Error != nil {
 			err = qc.Error
 			utils.GetQueryLogger().With(
@@ -189,6 +419,11 @@ func (handler *QueryHandler) handleAQLInternal(aqlRequest apiCom.AQLRequest, w h
 			return
 		}
 
+		setScanStatsHeader(w, qcs)
+		if qc.DataOnly {
+			setQueryTraceHeader(w, queryTrace)
+		}
+
 		if !qc.DataOnly {
 			w.Write([]byte(`]}]`))
 
@@ -210,7 +445,7 @@ func (handler *QueryHandler) handleAQLInternal(aqlRequest apiCom.AQLRequest, w h
 
 		var qc *query.AQLQueryContext
 		for i, aqlQuery := range aqlRequest.Body.Queries {
-			qc, statusCode = handleQuery(handler.memStore, handler.shardOwner, handler.deviceManager, aqlRequest, aqlQuery)
+			qc, statusCode = handleQuery(ctx, handler.memStore, handler.shardOwner, handler.deviceManager, handler.queryRegistry, handler.queryBlocks, aqlRequest, aqlQuery)
 			if aqlRequest.Verbose > 0 {
 				requestResponseWriter.ReportQueryContext(qc)
 			}
@@ -230,19 +465,36 @@ func (handler *QueryHandler) handleAQLInternal(aqlRequest apiCom.AQLRequest, w h
 	duration = utils.Now().Sub(start)
 	queryTimer.Record(duration)
 	if requestResponseWriter != nil {
+		setDataFreshnessHeader(w, handler.memStore, qcs)
+		setScanStatsHeader(w, qcs)
+		setShardCoverageHeader(w, qcs)
+		if aqlRequest.DataOnly != 0 {
+			setQueryTraceHeader(w, queryTrace)
+		}
+		for _, qc := range qcs {
+			queryCom.SetQueryWarningsHeader(w, qc.Warnings)
+		}
 		requestResponseWriter.Respond(w)
 		statusCode = requestResponseWriter.GetStatusCode()
 	}
 	return
 }
 
-func handleQuery(memStore memstore.MemStore, shardOwner topology.ShardOwner, deviceManager *query.DeviceManager, aqlRequest apiCom.AQLRequest, aqlQuery queryCom.AQLQuery) (qc *query.AQLQueryContext, statusCode int) {
+func handleQuery(ctx context.Context, memStore memstore.MemStore, shardOwner topology.ShardOwner, deviceManager *query.DeviceManager, queryRegistry *query.QueryRegistry,
+	queryBlocks *queryCom.QueryBlocklist, aqlRequest apiCom.AQLRequest, aqlQuery queryCom.AQLQuery) (qc *query.AQLQueryContext, statusCode int) {
 	qc = &query.AQLQueryContext{
 		Query:         &aqlQuery,
 		ReturnHLLData: aqlRequest.Accept == utils.HTTPContentTypeHyperLogLog,
 		DataOnly:      aqlRequest.DataOnly != 0,
+		Origin:        aqlRequest.Origin,
+		RequestID:     aqlRequest.RequestID,
+		Principal:     utils.PrincipalFromContext(ctx),
 	}
-	qc.Compile(memStore, shardOwner)
+	if qc.Error = checkQueryBlocks(queryBlocks, &aqlQuery); qc.Error != nil {
+		statusCode = http.StatusForbidden
+		return
+	}
+	compileQuery(ctx, qc, memStore, shardOwner)
 
 	for tableName := range qc.TableSchemaByName {
 		utils.GetRootReporter().GetChildCounter(map[string]string{
@@ -257,9 +509,14 @@ func handleQuery(memStore memstore.MemStore, shardOwner topology.ShardOwner, dev
 	}
 	qc.Profiling = aqlRequest.Profiling
 
-	// Compilation error, should be bad request
+	// Compilation error, should be bad request unless access is denied
 	if qc.Error != nil {
-		statusCode = http.StatusBadRequest
+		statusCode = compileErrorStatusCode(qc.Error)
+		return
+	}
+
+	// count(*) by day on archived data is answered from metadata without scanning.
+	if qc.AnswerFromDailyRowCounts(memStore) {
 		return
 	}
 
@@ -273,8 +530,13 @@ func handleQuery(memStore memstore.MemStore, shardOwner topology.ShardOwner, dev
 		return
 	}
 	defer deviceManager.ReleaseReservedMemory(qc.Device, qc.Query)
+	// Split large queries across idle devices.
+	qc.SplitAcrossDevices(memStore, shardOwner, deviceManager)
+	defer qc.ReleasePartitions(deviceManager)
 	// Execute.
-	qc.ProcessQuery(memStore)
+	queryRegistry.Register(qc)
+	processQuery(ctx, qc, memStore)
+	queryRegistry.Deregister(qc)
 	if qc.Error != nil {
 		utils.GetQueryLogger().With(
 			"error", qc.Error,
@@ -291,6 +553,264 @@ func handleQuery(memStore memstore.MemStore, shardOwner topology.ShardOwner, dev
 	return
 }
 
+// compileErrorStatusCode returns the status code of compilation errors, errors carrying a
+// status code (e.g. denied access to tables) keep theirs.
+func compileErrorStatusCode(err error) int {
+	if apiErr, ok := err.(utils.APIError); ok && apiErr.Code != 0 {
+		return apiErr.Code
+	}
+	return http.StatusBadRequest
+}
+
+// compileQuery compiles the query within a tracing span.
+func compileQuery(ctx context.Context, qc *query.AQLQueryContext, memStore memstore.MemStore, shardOwner topology.ShardOwner) {
+	_, span := utils.StartSpan(ctx, "datanode.Compile", attribute.String("table", qc.Query.Table))
+	qc.Compile(memStore, shardOwner)
+	utils.EndSpan(span, qc.Error)
+}
+
+// processQuery executes the query within a tracing span, per shard scans are traced as its children.
+// Bytes transferred to devices and device time of the query are reported to broker in scan stats
+// for quota accounting.
+func processQuery(ctx context.Context, qc *query.AQLQueryContext, memStore memstore.MemStore) {
+	traceCtx, span := utils.StartSpan(ctx, "datanode.ProcessQuery",
+		attribute.String("table", qc.Query.Table), attribute.Int("device", qc.Device))
+	qc.TraceContext = traceCtx
+	qc.ProcessQuery(memStore)
+	qc.ScanStats.BytesScanned = qc.BytesScanned()
+	qc.ScanStats.DeviceSeconds = qc.DeviceSeconds()
+	span.SetAttributes(attribute.Int("rowsFlushed", qc.ResultsRowsFlushed()))
+	utils.EndSpan(span, qc.Error)
+}
+
+// killOnCancel kills the query once the request context is done, the returned function
+// stops watching the context.
+func killOnCancel(ctx context.Context, qc *query.AQLQueryContext) (stopWatching func()) {
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			qc.Kill()
+		case <-finished:
+		}
+	}()
+	return func() { close(finished) }
+}
+
+// checkQueryBlocks returns error if the query is blocked by admin.
+const (
+	// defaultReadAfterTimeout is the time to wait for writes to read after if the client
+	// does not specify it.
+	defaultReadAfterTimeout = 5 * time.Second
+	// maxReadAfterTimeout caps the time queries hold the request waiting for writes.
+	maxReadAfterTimeout = time.Minute
+	// schemaVersionWaitTimeout is the time to wait for local schemas to catch up with the schema
+	// versions broker compiled the queries against.
+	schemaVersionWaitTimeout = 5 * time.Second
+	// schemaVersionPollInterval is the interval to check local schema versions while waiting.
+	schemaVersionPollInterval = 100 * time.Millisecond
+)
+
+// waitForWritePositions blocks until write positions of table shards the client asks to read
+// after are applied, for at most the timeout in milliseconds the client asks for.
+func waitForWritePositions(ctx context.Context, memStore memstore.MemStore, readAfter, timeoutMillis string) error {
+	positions, err := memCom.ParseWritePositions(readAfter)
+	if err != nil {
+		return err
+	}
+	timeout := defaultReadAfterTimeout
+	if timeoutMillis != "" {
+		millis, err := strconv.Atoi(timeoutMillis)
+		if err != nil || millis < 0 {
+			return utils.StackError(err, "invalid read after timeout %s", timeoutMillis)
+		}
+		timeout = time.Duration(millis) * time.Millisecond
+	}
+	if timeout > maxReadAfterTimeout {
+		timeout = maxReadAfterTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for id, position := range positions {
+		shard, err := memStore.GetTableShard(id.Table, id.Shard)
+		if err != nil {
+			return err
+		}
+		err = shard.WaitForWritePosition(ctx, position)
+		shard.Users.Done()
+		if err != nil {
+			return utils.StackError(err, "failed to read after writes to table %s shard %d", id.Table, id.Shard)
+		}
+	}
+	return nil
+}
+
+// waitForSchemaVersions blocks until local schemas are no older than the schema versions of the
+// queries, e.g. when broker picks up a committed schema rollout earlier than this datanode.
+func (handler *QueryHandler) waitForSchemaVersions(ctx context.Context, queries []queryCom.AQLQuery) {
+	if !schemasBehind(handler.memStore, queries) {
+		return
+	}
+	if handler.schemaRefresher != nil {
+		handler.schemaRefresher()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, schemaVersionWaitTimeout)
+	defer cancel()
+	ticker := time.NewTicker(schemaVersionPollInterval)
+	defer ticker.Stop()
+	for schemasBehind(handler.memStore, queries) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// schemasBehind tells whether any table of the queries is missing or older than the schema
+// version the query expects.
+func schemasBehind(memStore memstore.MemStore, queries []queryCom.AQLQuery) bool {
+	memStore.RLock()
+	defer memStore.RUnlock()
+	schemas := memStore.GetSchemas()
+	for _, aqlQuery := range queries {
+		for tableName, version := range aqlQuery.SchemaVersions {
+			schema := schemas[tableName]
+			if schema == nil || schema.Snapshot().Schema.Version < version {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func checkQueryBlocks(queryBlocks *queryCom.QueryBlocklist, aqlQuery *queryCom.AQLQuery) error {
+	block := queryBlocks.Check(aqlQuery)
+	if block == nil {
+		return nil
+	}
+	utils.GetRootReporter().GetChildCounter(map[string]string{
+		"table": aqlQuery.Table,
+	}, utils.QueryBlocked).Inc(1)
+	return utils.StackError(nil, "queries on table %s with fingerprint %s are blocked until %s: %s",
+		block.Table, block.Fingerprint, time.Unix(block.ExpiresAt, 0).UTC().Format(time.RFC3339), block.Reason)
+}
+
+// setDataFreshnessHeader sets the freshness of all table shards scanned by the queries and the
+// time range scanned into response header, so that broker can report them in query response
+// metadata.
+func setDataFreshnessHeader(w http.ResponseWriter, memStore memstore.MemStore, qcs []*query.AQLQueryContext) {
+	var shards []queryCom.ShardFreshness
+	var timeRange *queryCom.QueryTimeRange
+	for _, qc := range qcs {
+		if qcTimeRange := qc.TimeRange(); qcTimeRange != nil {
+			if timeRange != nil {
+				*qcTimeRange = timeRange.Union(*qcTimeRange)
+			}
+			timeRange = qcTimeRange
+		}
+		for _, scanner := range qc.TableScanners {
+			if scanner == nil || scanner.Schema == nil {
+				continue
+			}
+			tableName := scanner.Schema.Schema.Name
+			for _, shardID := range scanner.Shards {
+				shard, err := memStore.GetTableShard(tableName, shardID)
+				if err != nil {
+					continue
+				}
+				highWatermark, redoLogLag := shard.LiveStore.GetDataFreshness()
+				var archivingCutoff uint32
+				if shard.Schema.Schema.IsFactTable {
+					archiveStore := shard.ArchiveStore.GetCurrentVersion()
+					archivingCutoff = archiveStore.ArchivingCutoff
+					archiveStore.Users.Done()
+				}
+				shard.Users.Done()
+				shards = append(shards, queryCom.ShardFreshness{
+					Table:           tableName,
+					Shard:           shardID,
+					HighWatermark:   highWatermark,
+					RedoLogLag:      redoLogLag,
+					ArchivingCutoff: archivingCutoff,
+				})
+			}
+		}
+	}
+
+	if timeRange != nil {
+		if timeRangeBytes, err := json.Marshal(timeRange); err == nil {
+			w.Header().Set(queryCom.QueryTimeRangeHeaderKey, string(timeRangeBytes))
+		}
+	}
+	if len(shards) == 0 {
+		return
+	}
+	freshnessBytes, err := json.Marshal(shards)
+	if err != nil {
+		return
+	}
+	w.Header().Set(queryCom.DataFreshnessHeaderKey, string(freshnessBytes))
+}
+
+// setShardCoverageHeader sets coverage tokens of table shards scanned by the queries into
+// response header, so that broker can deduplicate partial results covering the same shards.
+func setShardCoverageHeader(w http.ResponseWriter, qcs []*query.AQLQueryContext) {
+	var tokens []string
+	for _, qc := range qcs {
+		if qc == nil || qc.Error != nil {
+			continue
+		}
+		for _, shardID := range qc.ShardsCovered {
+			tokens = append(tokens, queryCom.CoverageToken(qc.Query.Table, shardID))
+		}
+	}
+	if len(tokens) == 0 {
+		return
+	}
+	w.Header().Set(queryCom.ShardCoverageHeaderKey, strings.Join(tokens, ","))
+}
+
+// setScanStatsHeader sets the scan stats of all queries into response header, so that broker
+// can monitor the effectiveness of pruning.
+func setScanStatsHeader(w http.ResponseWriter, qcs []*query.AQLQueryContext) {
+	var stats queryCom.ScanStats
+	for _, qc := range qcs {
+		if qc != nil {
+			stats.Add(qc.ScanStats)
+		}
+	}
+	statsBytes, err := json.Marshal(stats)
+	if err != nil {
+		return
+	}
+	w.Header().Set(queryCom.ScanStatsHeaderKey, string(statsBytes))
+}
+
+// setQueryTraceHeader sets the spans recorded in the query trace into response header, so that
+// broker can merge them into the trace of the query.
+func setQueryTraceHeader(w http.ResponseWriter, queryTrace *utils.QueryTrace) {
+	if queryTrace == nil {
+		return
+	}
+	spansBytes, err := json.Marshal(queryTrace.Spans())
+	if err != nil {
+		return
+	}
+	w.Header().Set(utils.QueryTraceHeaderKey, string(spansBytes))
+}
+
+// newQueryTraceID returns a random ID to download the trace of a query.
+func newQueryTraceID() string {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return strconv.FormatInt(utils.Now().UnixNano(), 10)
+	}
+	return id.String()
+}
+
 func getReponseWriter(returnHLL bool, nQueries int) QueryResponseWriter {
 	if returnHLL {
 		return NewHLLQueryResponseWriter()
@@ -34,6 +34,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/uber/aresdb/common"
 	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
 )
 
 var _ = ginkgo.Describe("QueryHandler", func() {
@@ -69,12 +70,16 @@ var _ = ginkgo.Describe("QueryHandler", func() {
 		memStore = CreateMemStore(testSchema, 0, nil, CreateMockDiskStore())
 		queryHandler := NewQueryHandler(
 			memStore,
+			nil,
 			topology.NewStaticShardOwner([]int{0}),
 			common.QueryConfig{
 				DeviceMemoryUtilization: 1.0,
 			})
 		testRouter := mux.NewRouter()
 		testRouter.HandleFunc("/aql", queryHandler.HandleAQL).Methods(http.MethodGet, http.MethodPost)
+		testRouter.HandleFunc("/{table}/columns/{column}/values", queryHandler.ListColumnValues).Methods(http.MethodGet)
+		testRouter.HandleFunc("/traces/{id}", queryHandler.GetQueryTrace).Methods(http.MethodGet)
+		testRouter.HandleFunc("/sql/complete", queryHandler.CompleteSQL).Methods(http.MethodPost)
 		testServer = httptest.NewUnstartedServer(WithPanicHandling(testRouter))
 		testServer.Start()
 	})
@@ -168,6 +173,53 @@ var _ = ginkgo.Describe("QueryHandler", func() {
 		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
 	})
 
+	ginkgo.It("HandleAQL should record query trace in trace mode", func() {
+		hostPort := testServer.Listener.Addr().String()
+		query := `
+			{
+			  "queries": [
+				{
+				  "measures": [
+					{
+					  "sqlExpression": "count(*)"
+					}
+				  ],
+				  "table": "trips",
+				  "timeFilter": {
+					"column": "trips.request_at",
+					"from": "-6d"
+				  }
+				}
+			  ]
+			}
+		`
+		resp, err := http.Post(fmt.Sprintf("http://%s/aql?trace=1", hostPort), "application/json", bytes.NewBuffer([]byte(query)))
+		Ω(err).Should(BeNil())
+		_, err = ioutil.ReadAll(resp.Body)
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		traceID := resp.Header.Get(utils.QueryTraceIDHeaderKey)
+		Ω(traceID).ShouldNot(BeEmpty())
+
+		resp, err = http.Get(fmt.Sprintf("http://%s/traces/%s", hostPort, traceID))
+		Ω(err).Should(BeNil())
+		bs, err := ioutil.ReadAll(resp.Body)
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		var chromeTrace utils.ChromeTrace
+		Ω(json.Unmarshal(bs, &chromeTrace)).Should(BeNil())
+		var names []string
+		for _, event := range chromeTrace.TraceEvents {
+			names = append(names, event.Name)
+		}
+		Ω(names).Should(ContainElement("datanode.HandleAQL"))
+		Ω(names).Should(ContainElement("datanode.Compile"))
+
+		resp, err = http.Get(fmt.Sprintf("http://%s/traces/unknown", hostPort))
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusNotFound))
+	})
+
 	ginkgo.It("HandleAQL should fail on request that cannot be unmarshaled", func() {
 		hostPort := testServer.Listener.Addr().String()
 		resp, err := http.Post(fmt.Sprintf("http://%s/aql", hostPort), "application/json", bytes.NewBuffer([]byte{}))
@@ -223,6 +275,48 @@ var _ = ginkgo.Describe("QueryHandler", func() {
 		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
 	})
 
+	ginkgo.It("HandleAQL should reject queries blocked by admin", func() {
+		queryHandler := NewQueryHandler(
+			memStore,
+			nil,
+			topology.NewStaticShardOwner([]int{0}),
+			common.QueryConfig{
+				DeviceMemoryUtilization: 1.0,
+			})
+		testRouter := mux.NewRouter()
+		queryHandler.Register(testRouter)
+		blockTestServer := httptest.NewServer(testRouter)
+		defer blockTestServer.Close()
+
+		block := `{"query": {"measures": [{"sqlExpression": "count(*)"}], "table": "trips", "rowFilters": ["status = 'completed'"]}, "reason": "bad dashboard", "durationSeconds": 3600}`
+		resp, err := http.Post(fmt.Sprintf("%s/blocks", blockTestServer.URL), "application/json", bytes.NewBuffer([]byte(block)))
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		var placed queryCom.QueryBlock
+		Ω(json.NewDecoder(resp.Body).Decode(&placed)).Should(BeNil())
+		Ω(placed.Table).Should(Equal("trips"))
+		Ω(placed.Fingerprint).ShouldNot(BeEmpty())
+		Ω(queryHandler.GetQueryBlocklist().List()).Should(Equal([]queryCom.QueryBlock{placed}))
+
+		// same query with different filter values is blocked.
+		aql := `{"queries": [{"measures": [{"sqlExpression": "count(*)"}], "table": "trips", "rowFilters": ["status = 'canceled'"]}]}`
+		resp, err = http.Post(fmt.Sprintf("%s/aql", blockTestServer.URL), "application/json", bytes.NewBuffer([]byte(aql)))
+		Ω(err).Should(BeNil())
+		bs, err := ioutil.ReadAll(resp.Body)
+		Ω(err).Should(BeNil())
+		Ω(string(bs)).Should(ContainSubstring("bad dashboard"))
+
+		req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/blocks/trips?fingerprint=%s", blockTestServer.URL, placed.Fingerprint), nil)
+		resp, err = http.DefaultClient.Do(req)
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		Ω(queryHandler.GetQueryBlocklist().List()).Should(BeEmpty())
+
+		resp, err = http.DefaultClient.Do(req)
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusNotFound))
+	})
+
 	ginkgo.It("ReportError should work", func() {
 		rw := NewHLLQueryResponseWriter()
 		Ω(rw.GetStatusCode()).Should(Equal(http.StatusOK))
@@ -372,4 +466,115 @@ var _ = ginkgo.Describe("QueryHandler", func() {
 		Ω(string(bs)).Should(ContainSubstring("mainTableCommonFilters"))
 		Ω(string(bs)).Should(ContainSubstring("allBatches"))
 	})
+
+	ginkgo.It("ListColumnValues should work", func() {
+		hostPort := testServer.Listener.Addr().String()
+		testSchema.Lock()
+		testSchema.EnumDicts["status"] = memCom.EnumDict{
+			Capacity:    0x100,
+			Dict:        map[string]int{"ACTIVE": 0, "COMPLETED": 1},
+			ReverseDict: []string{"ACTIVE", "COMPLETED"},
+		}
+		testSchema.Unlock()
+		defer func() {
+			testSchema.Lock()
+			delete(testSchema.EnumDicts, "status")
+			testSchema.Unlock()
+		}()
+
+		get := func(path string) (int, string) {
+			resp, err := http.Get(fmt.Sprintf("http://%s/%s", hostPort, path))
+			Ω(err).Should(BeNil())
+			bs, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			Ω(err).Should(BeNil())
+			return resp.StatusCode, string(bs)
+		}
+
+		// enum columns return the whole dictionary.
+		statusCode, body := get("trips/columns/status/values")
+		Ω(statusCode).Should(Equal(http.StatusOK))
+		Ω(body).Should(MatchJSON(`{
+			"values": [{"value": "ACTIVE", "count": 0}, {"value": "COMPLETED", "count": 0}],
+			"complete": true
+		}`))
+
+		statusCode, body = get("trips/columns/status/values?limit=1")
+		Ω(statusCode).Should(Equal(http.StatusOK))
+		Ω(body).Should(MatchJSON(`{"values": [{"value": "ACTIVE", "count": 0}], "complete": false}`))
+
+		statusCode, body = get("trips/columns/status/values?limit=-1&shards=0")
+		Ω(statusCode).Should(Equal(http.StatusOK))
+		Ω(body).Should(MatchJSON(`{
+			"values": [{"value": "ACTIVE", "count": 0}, {"value": "COMPLETED", "count": 0}],
+			"complete": true
+		}`))
+
+		// shards requested by broker must exist.
+		statusCode, _ = get("trips/columns/status/values?shards=0,1")
+		Ω(statusCode).Should(Equal(http.StatusInternalServerError))
+		statusCode, _ = get("trips/columns/status/values?shards=a")
+		Ω(statusCode).Should(Equal(http.StatusBadRequest))
+
+		statusCode, body = get("trips/columns/city_id/values")
+		Ω(statusCode).Should(Equal(http.StatusOK))
+		Ω(body).Should(MatchJSON(`{"values": [], "complete": true}`))
+
+		statusCode, _ = get("trips/columns/fare_total/values")
+		Ω(statusCode).Should(Equal(http.StatusBadRequest))
+
+		statusCode, _ = get("trips/columns/unknown/values")
+		Ω(statusCode).Should(Equal(http.StatusBadRequest))
+
+		statusCode, _ = get("unknown/columns/status/values")
+		Ω(statusCode).Should(Equal(http.StatusBadRequest))
+	})
+
+	ginkgo.It("formatColumnValue should format values as query results", func() {
+		Ω(formatColumnValue(nil, memCom.Uint16, 0, nil)).Should(Equal("NULL"))
+		Ω(formatColumnValue(true, memCom.Bool, 0, nil)).Should(Equal("1"))
+		Ω(formatColumnValue(uint8(1), memCom.SmallEnum, 0, []string{"a", "b"})).Should(Equal("b"))
+		Ω(formatColumnValue(uint16(2), memCom.BigEnum, 0, []string{"a", "b"})).Should(Equal("2"))
+		Ω(formatColumnValue(uint16(2), memCom.Uint16, 0, []string{"a", "b"})).Should(Equal("2"))
+		Ω(formatColumnValue(int64(-1234), memCom.Decimal, 2, nil)).Should(Equal("-12.34"))
+		Ω(formatColumnValue(int64(-1234), memCom.Int64, 0, nil)).Should(Equal("-1234"))
+		Ω(formatColumnValue(float32(1.5), memCom.Float32, 0, nil)).Should(Equal("1.5"))
+	})
+
+	ginkgo.It("CompleteSQL should work", func() {
+		hostPort := testServer.Listener.Addr().String()
+		testSchema.Lock()
+		testSchema.EnumDicts["status"] = memCom.EnumDict{
+			Capacity:    0x100,
+			Dict:        map[string]int{"ACTIVE": 0, "COMPLETED": 1},
+			ReverseDict: []string{"ACTIVE", "COMPLETED"},
+		}
+		testSchema.Unlock()
+		defer func() {
+			testSchema.Lock()
+			delete(testSchema.EnumDicts, "status")
+			testSchema.Unlock()
+		}()
+
+		complete := func(sql string, cursor int) (int, string) {
+			body, _ := json.Marshal(map[string]interface{}{"sql": sql, "cursor": cursor})
+			resp, err := http.Post(fmt.Sprintf("http://%s/sql/complete", hostPort), "application/json", bytes.NewBuffer(body))
+			Ω(err).Should(BeNil())
+			bs, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			Ω(err).Should(BeNil())
+			return resp.StatusCode, string(bs)
+		}
+
+		statusCode, body := complete("SELECT count(*) FROM tri", 24)
+		Ω(statusCode).Should(Equal(http.StatusOK))
+		Ω(body).Should(ContainSubstring(`{"text":"trips","kind":"table"}`))
+
+		statusCode, body = complete("SELECT count(*) FROM trips WHERE status = 'AC", 45)
+		Ω(statusCode).Should(Equal(http.StatusOK))
+		Ω(body).Should(MatchJSON(`{"prefix": "AC", "candidates": [{"text": "ACTIVE", "kind": "value", "table": "trips"}]}`))
+
+		statusCode, _ = complete("SELECT", 10)
+		Ω(statusCode).Should(Equal(http.StatusBadRequest))
+	})
 })
@@ -49,12 +49,19 @@ func (handler *SchemaHandler) Register(router *mux.Router, wrappers ...utils.HTT
 	router.HandleFunc("/tables/{table}/columns", utils.ApplyHTTPWrappers(handler.AddColumn, wrappers)).Methods(http.MethodPost)
 	router.HandleFunc("/tables/{table}/columns/{column}", utils.ApplyHTTPWrappers(handler.UpdateColumn, wrappers)).Methods(http.MethodPut)
 	router.HandleFunc("/tables/{table}/columns/{column}", utils.ApplyHTTPWrappers(handler.DeleteColumn, wrappers)).Methods(http.MethodDelete)
+	router.HandleFunc("/tables/{table}/columns/{column}/promote", utils.ApplyHTTPWrappers(handler.PromoteEnumColumn, wrappers)).Methods(http.MethodPost)
+	router.HandleFunc("/tables/{table}/enum-usage", utils.ApplyHTTPWrappers(handler.ListEnumUsage, wrappers)).Methods(http.MethodGet)
+	router.HandleFunc("/tables/{table}/legal-holds", utils.ApplyHTTPWrappers(handler.ListLegalHolds, wrappers)).Methods(http.MethodGet)
+	router.HandleFunc("/tables/{table}/legal-holds", utils.ApplyHTTPWrappers(handler.PlaceLegalHold, wrappers)).Methods(http.MethodPost)
+	router.HandleFunc("/tables/{table}/legal-holds/{batchIDStart}/{batchIDEnd}", utils.ApplyHTTPWrappers(handler.RemoveLegalHold, wrappers)).Methods(http.MethodDelete)
 }
 
 // RegisterForDebug register handlers for debug port
 func (handler *SchemaHandler) RegisterForDebug(router *mux.Router, wrappers ...utils.HTTPHandlerWrapper) {
 	router.HandleFunc("/tables", utils.ApplyHTTPWrappers(handler.ListTables, wrappers)).Methods(http.MethodGet)
 	router.HandleFunc("/tables/{table}", utils.ApplyHTTPWrappers(handler.GetTable, wrappers)).Methods(http.MethodGet)
+	router.HandleFunc("/tables/{table}/enum-usage", utils.ApplyHTTPWrappers(handler.ListEnumUsage, wrappers)).Methods(http.MethodGet)
+	router.HandleFunc("/tables/{table}/legal-holds", utils.ApplyHTTPWrappers(handler.ListLegalHolds, wrappers)).Methods(http.MethodGet)
 }
 
 // ListTables swagger:route GET /schema/tables listTables
@@ -140,6 +147,7 @@ func (handler *SchemaHandler) AddTable(w http.ResponseWriter, r *http.Request) {
 
 	newTable := addTableRequest.Body
 	err = handler.metaStore.CreateTable(&newTable)
+	utils.AuditRequest(r, utils.AuditOpCreateTable, newTable.Name, map[string]interface{}{"table": newTable}, err)
 	if err != nil {
 		common.RespondWithError(w, err)
 		return
@@ -165,6 +173,7 @@ func (handler *SchemaHandler) UpdateTableConfig(w http.ResponseWriter, r *http.R
 	}
 
 	err = handler.metaStore.UpdateTableConfig(request.TableName, request.Body)
+	utils.AuditRequest(r, utils.AuditOpUpdateTableConfig, request.TableName, map[string]interface{}{"config": request.Body}, err)
 	if err != nil {
 		common.RespondWithError(w, err)
 		return
@@ -188,6 +197,7 @@ func (handler *SchemaHandler) DeleteTable(w http.ResponseWriter, r *http.Request
 	}
 
 	err = handler.metaStore.DeleteTable(deleteTableRequest.TableName)
+	utils.AuditRequest(r, utils.AuditOpDeleteTable, deleteTableRequest.TableName, nil, err)
 	if err != nil {
 		// TODO: need mapping from metaStore error to api error
 		/// for metaStore error might also be user error
@@ -216,6 +226,10 @@ func (handler *SchemaHandler) AddColumn(w http.ResponseWriter, r *http.Request)
 	}
 
 	err = handler.metaStore.AddColumn(addColumnRequest.TableName, addColumnRequest.Body.Column, addColumnRequest.Body.AddToArchivingSortOrder)
+	utils.AuditRequest(r, utils.AuditOpAddColumn, addColumnRequest.TableName, map[string]interface{}{
+		"column":                  addColumnRequest.Body.Column,
+		"addToArchivingSortOrder": addColumnRequest.Body.AddToArchivingSortOrder,
+	}, err)
 	// TODO: validate column
 	// might better do in metaStore and here needs to return either user error or server error
 	if err != nil {
@@ -246,8 +260,13 @@ func (handler *SchemaHandler) UpdateColumn(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if err = handler.metaStore.UpdateColumn(updateColumnRequest.TableName,
-		updateColumnRequest.ColumnName, updateColumnRequest.Body); err != nil {
+	err = handler.metaStore.UpdateColumn(updateColumnRequest.TableName,
+		updateColumnRequest.ColumnName, updateColumnRequest.Body)
+	utils.AuditRequest(r, utils.AuditOpUpdateColumn, updateColumnRequest.TableName, map[string]interface{}{
+		"column": updateColumnRequest.ColumnName,
+		"config": updateColumnRequest.Body,
+	}, err)
+	if err != nil {
 		// TODO: need mapping from metaStore error to api error
 		// for metaStore error might also be user error
 		common.RespondWithError(w, err)
@@ -273,6 +292,7 @@ func (handler *SchemaHandler) DeleteColumn(w http.ResponseWriter, r *http.Reques
 	}
 
 	err = handler.metaStore.DeleteColumn(deleteColumnRequest.TableName, deleteColumnRequest.ColumnName)
+	utils.AuditRequest(r, utils.AuditOpDeleteColumn, deleteColumnRequest.TableName, map[string]interface{}{"column": deleteColumnRequest.ColumnName}, err)
 	// TODO: validate whether table exists and specified columns does not belong to primary key or time column
 	// might be better for metaStore to do this and return specified error type
 	if err != nil {
@@ -284,3 +304,214 @@ func (handler *SchemaHandler) DeleteColumn(w http.ResponseWriter, r *http.Reques
 
 	common.RespondWithJSONObject(w, nil)
 }
+
+// PromoteEnumColumn swagger:route POST /schema/tables/{table}/columns/{column}/promote promoteEnumColumn
+// promote a small enum column running out of capacity to big enum,
+// data already ingested is converted online
+//
+// Responses:
+//    default: errorResponse
+//        200: noContentResponse
+func (handler *SchemaHandler) PromoteEnumColumn(w http.ResponseWriter, r *http.Request) {
+	var promoteEnumColumnRequest PromoteEnumColumnRequest
+	err := common.ReadRequest(r, &promoteEnumColumnRequest)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	table, err := handler.metaStore.GetTable(promoteEnumColumnRequest.TableName)
+	if err != nil {
+		if err == metaCom.ErrTableDoesNotExist {
+			common.RespondWithBadRequest(w, err)
+			return
+		}
+		common.RespondWithError(w, err)
+		return
+	}
+
+	newTable := *table
+	newTable.Columns = make([]metaCom.Column, len(table.Columns))
+	copy(newTable.Columns, table.Columns)
+	newTable.Version++
+
+	err = metaCom.ErrColumnDoesNotExist
+	for columnID, column := range newTable.Columns {
+		if column.Name == promoteEnumColumnRequest.ColumnName && !column.Deleted {
+			err = nil
+			if column.Type != metaCom.SmallEnum {
+				err = metaCom.ErrNotSmallEnumColumn
+			}
+			newTable.Columns[columnID].Type = metaCom.BigEnum
+			break
+		}
+	}
+	if err == nil {
+		validator := metastore.NewTableSchameValidator()
+		validator.SetOldTable(*table)
+		validator.SetNewTable(newTable)
+		err = validator.Validate()
+	}
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	err = handler.metaStore.UpdateTable(newTable)
+	utils.AuditRequest(r, utils.AuditOpUpdateColumn, promoteEnumColumnRequest.TableName, map[string]interface{}{
+		"column": promoteEnumColumnRequest.ColumnName,
+		"type":   metaCom.BigEnum,
+	}, err)
+	if err != nil {
+		common.RespondWithError(w, err)
+		return
+	}
+
+	common.RespondWithJSONObject(w, nil)
+}
+
+// ListEnumUsage swagger:route GET /schema/tables/{table}/enum-usage listEnumUsage
+// list number of enum cases against the capacity of each enum column of the table
+//
+// Produces:
+//    - application/json
+//
+// Responses:
+//    default: errorResponse
+//        200: listEnumUsageResponse
+func (handler *SchemaHandler) ListEnumUsage(w http.ResponseWriter, r *http.Request) {
+	var listEnumUsageRequest ListEnumUsageRequest
+	err := common.ReadRequest(r, &listEnumUsageRequest)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	table, err := handler.metaStore.GetTable(listEnumUsageRequest.TableName)
+	if err != nil {
+		if err == metaCom.ErrTableDoesNotExist {
+			common.RespondWithBadRequest(w, err)
+			return
+		}
+		common.RespondWithError(w, err)
+		return
+	}
+
+	usages := []EnumColumnUsage{}
+	for _, column := range table.Columns {
+		capacity := metaCom.EnumCardinality(column.Type)
+		if column.Deleted || capacity == 0 {
+			continue
+		}
+		enumCases, err := handler.metaStore.GetEnumDict(table.Name, column.Name)
+		if err != nil {
+			common.RespondWithError(w, err)
+			return
+		}
+		usage := float64(len(enumCases)) / float64(capacity)
+		usages = append(usages, EnumColumnUsage{
+			Column:   column.Name,
+			Type:     column.Type,
+			Cases:    len(enumCases),
+			Capacity: capacity,
+			Usage:    usage,
+			Alert:    usage >= metaCom.EnumCapacityAlertRatio,
+		})
+	}
+	common.RespondWithJSONObject(w, usages)
+}
+
+// ListLegalHolds swagger:route GET /schema/tables/{table}/legal-holds listLegalHolds
+// list batch ranges of the table under legal hold, which are exempted from retention purge
+//
+// Produces:
+//    - application/json
+//
+// Responses:
+//    default: errorResponse
+//        200: listLegalHoldsResponse
+func (handler *SchemaHandler) ListLegalHolds(w http.ResponseWriter, r *http.Request) {
+	var listLegalHoldsRequest ListLegalHoldsRequest
+	err := common.ReadRequest(r, &listLegalHoldsRequest)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	holds, err := handler.metaStore.GetLegalHolds(listLegalHoldsRequest.TableName)
+	if err != nil {
+		common.RespondWithError(w, err)
+		return
+	}
+	if holds == nil {
+		holds = []metaCom.LegalHold{}
+	}
+	common.RespondWithJSONObject(w, holds)
+}
+
+// PlaceLegalHold swagger:route POST /schema/tables/{table}/legal-holds placeLegalHold
+// place a legal hold on a batch range of the table so that retention purge skips it
+//
+// Consumes:
+//    - application/json
+//
+// Responses:
+//    default: errorResponse
+//        200: noContentResponse
+func (handler *SchemaHandler) PlaceLegalHold(w http.ResponseWriter, r *http.Request) {
+	var placeLegalHoldRequest PlaceLegalHoldRequest
+	err := common.ReadRequest(r, &placeLegalHoldRequest)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	hold := placeLegalHoldRequest.Body
+	hold.PlacedAt = utils.Now().Unix()
+	err = handler.metaStore.PlaceLegalHold(placeLegalHoldRequest.TableName, hold)
+	utils.AuditRequest(r, utils.AuditOpPlaceLegalHold, placeLegalHoldRequest.TableName, map[string]interface{}{
+		"hold": hold,
+	}, err)
+	if err != nil {
+		if err == metaCom.ErrInvalidLegalHold || err == metaCom.ErrNotFactTable {
+			common.RespondWithBadRequest(w, err)
+			return
+		}
+		common.RespondWithError(w, err)
+		return
+	}
+
+	common.RespondWithJSONObject(w, nil)
+}
+
+// RemoveLegalHold swagger:route DELETE /schema/tables/{table}/legal-holds/{batchIDStart}/{batchIDEnd} removeLegalHold
+// remove the legal hold of a batch range from the table
+//
+// Responses:
+//    default: errorResponse
+//        200: noContentResponse
+func (handler *SchemaHandler) RemoveLegalHold(w http.ResponseWriter, r *http.Request) {
+	var removeLegalHoldRequest RemoveLegalHoldRequest
+	err := common.ReadRequest(r, &removeLegalHoldRequest)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	err = handler.metaStore.RemoveLegalHold(removeLegalHoldRequest.TableName,
+		removeLegalHoldRequest.BatchIDStart, removeLegalHoldRequest.BatchIDEnd)
+	utils.AuditRequest(r, utils.AuditOpRemoveLegalHold, removeLegalHoldRequest.TableName, map[string]interface{}{
+		"batchIDStart": removeLegalHoldRequest.BatchIDStart,
+		"batchIDEnd":   removeLegalHoldRequest.BatchIDEnd,
+	}, err)
+	if err != nil {
+		if err == metaCom.ErrLegalHoldDoesNotExist {
+			common.RespondBytesWithCode(w, http.StatusNotFound, []byte(err.Error()))
+			return
+		}
+		common.RespondWithError(w, err)
+		return
+	}
+
+	common.RespondWithJSONObject(w, nil)
+}
@@ -238,4 +238,97 @@ var _ = ginkgo.Describe("SchemaHandler", func() {
 		resp, _ = http.DefaultClient.Do(req)
 		Ω(resp.StatusCode).Should(Equal(http.StatusInternalServerError))
 	})
+
+	ginkgo.It("PromoteEnumColumn should work", func() {
+		enumTable := metaCom.Table{
+			Name: "enumTable",
+			Columns: []metaCom.Column{
+				{Name: "col1", Type: metaCom.Int32},
+				{Name: "col2", Type: metaCom.SmallEnum},
+				{Name: "col3", Type: metaCom.BigEnum},
+			},
+			PrimaryKeyColumns: []int{0},
+			Config:            metastore.DefaultTableConfig,
+		}
+
+		testMetaStore.On("GetTable", "enumTable").Return(&enumTable, nil).Times(3)
+		testMetaStore.On("UpdateTable", mock.MatchedBy(func(table metaCom.Table) bool {
+			return table.Columns[1].Type == metaCom.BigEnum && table.Version == enumTable.Version+1
+		})).Return(nil).Once()
+		resp, _ := http.Post(fmt.Sprintf("http://%s/schema/tables/%s/columns/%s/promote", hostPort, "enumTable", "col2"), "application/json", &bytes.Buffer{})
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		// the table returned by metaStore is not modified.
+		Ω(enumTable.Columns[1].Type).Should(Equal(metaCom.SmallEnum))
+
+		// column already big enum.
+		resp, _ = http.Post(fmt.Sprintf("http://%s/schema/tables/%s/columns/%s/promote", hostPort, "enumTable", "col3"), "application/json", &bytes.Buffer{})
+		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+
+		resp, _ = http.Post(fmt.Sprintf("http://%s/schema/tables/%s/columns/%s/promote", hostPort, "enumTable", "unknown"), "application/json", &bytes.Buffer{})
+		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+	})
+
+	ginkgo.It("ListEnumUsage should work", func() {
+		enumTable := metaCom.Table{
+			Name: "enumTable",
+			Columns: []metaCom.Column{
+				{Name: "col1", Type: metaCom.Int32},
+				{Name: "col2", Type: metaCom.SmallEnum},
+				{Name: "col3", Type: metaCom.BigEnum},
+				{Name: "col4", Type: metaCom.SmallEnum, Deleted: true},
+			},
+			PrimaryKeyColumns: []int{0},
+		}
+		enumCases := make([]string, 240)
+		for i := range enumCases {
+			enumCases[i] = fmt.Sprintf("%d", i)
+		}
+
+		testMetaStore.On("GetTable", "enumTable").Return(&enumTable, nil).Once()
+		testMetaStore.On("GetEnumDict", "enumTable", "col2").Return(enumCases, nil).Once()
+		testMetaStore.On("GetEnumDict", "enumTable", "col3").Return(enumCases[:1], nil).Once()
+		resp, err := http.Get(fmt.Sprintf("http://%s/schema/tables/%s/enum-usage", hostPort, "enumTable"))
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		var usages []EnumColumnUsage
+		Ω(json.NewDecoder(resp.Body).Decode(&usages)).Should(BeNil())
+		Ω(usages).Should(Equal([]EnumColumnUsage{
+			{Column: "col2", Type: metaCom.SmallEnum, Cases: 240, Capacity: 256, Usage: 240.0 / 256, Alert: true},
+			{Column: "col3", Type: metaCom.BigEnum, Cases: 1, Capacity: 65536, Usage: 1.0 / 65536},
+		}))
+
+		testMetaStore.On("GetTable", "unknown").Return(nil, metaCom.ErrTableDoesNotExist).Once()
+		resp, _ = http.Get(fmt.Sprintf("http://%s/schema/tables/%s/enum-usage", hostPort, "unknown"))
+		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+	})
+
+	ginkgo.It("LegalHolds should work", func() {
+		hold := metaCom.LegalHold{BatchIDStart: 1, BatchIDEnd: 3, Reason: "litigation", PlacedAt: 100}
+		testMetaStore.On("GetLegalHolds", "testTable").Return([]metaCom.LegalHold{hold}, nil).Once()
+		resp, err := http.Get(fmt.Sprintf("http://%s/schema/tables/%s/legal-holds", hostPort, "testTable"))
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		var holds []metaCom.LegalHold
+		Ω(json.NewDecoder(resp.Body).Decode(&holds)).Should(BeNil())
+		Ω(holds).Should(Equal([]metaCom.LegalHold{hold}))
+
+		b, _ := json.Marshal(metaCom.LegalHold{BatchIDStart: 1, BatchIDEnd: 3, Reason: "litigation"})
+		testMetaStore.On("PlaceLegalHold", "testTable", mock.Anything).Return(nil).Once()
+		resp, _ = http.Post(fmt.Sprintf("http://%s/schema/tables/%s/legal-holds", hostPort, "testTable"), "application/json", bytes.NewReader(b))
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+
+		testMetaStore.On("PlaceLegalHold", "testTable", mock.Anything).Return(metaCom.ErrInvalidLegalHold).Once()
+		resp, _ = http.Post(fmt.Sprintf("http://%s/schema/tables/%s/legal-holds", hostPort, "testTable"), "application/json", bytes.NewReader(b))
+		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+
+		testMetaStore.On("RemoveLegalHold", "testTable", 1, 3).Return(nil).Once()
+		req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("http://%s/schema/tables/%s/legal-holds/1/3", hostPort, "testTable"), &bytes.Buffer{})
+		resp, _ = http.DefaultClient.Do(req)
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+
+		testMetaStore.On("RemoveLegalHold", "testTable", 1, 3).Return(metaCom.ErrLegalHoldDoesNotExist).Once()
+		req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("http://%s/schema/tables/%s/legal-holds/1/3", hostPort, "testTable"), &bytes.Buffer{})
+		resp, _ = http.DefaultClient.Do(req)
+		Ω(resp.StatusCode).Should(Equal(http.StatusNotFound))
+	})
 })
@@ -105,3 +105,46 @@ type AddEnumCaseRequest struct {
 		EnumCases []string `json:"enumCases"`
 	} `body:""`
 }
+
+// PromoteEnumColumnRequest represents PromoteEnumColumn request.
+// swagger:parameters promoteEnumColumn
+type PromoteEnumColumnRequest struct {
+	// in: path
+	TableName string `path:"table" json:"table"`
+	// in: path
+	ColumnName string `path:"column" json:"column"`
+}
+
+// ListEnumUsageRequest represents ListEnumUsage request.
+// swagger:parameters listEnumUsage
+type ListEnumUsageRequest struct {
+	// in: path
+	TableName string `path:"table" json:"table"`
+}
+
+// ListLegalHoldsRequest represents ListLegalHolds request.
+// swagger:parameters listLegalHolds
+type ListLegalHoldsRequest struct {
+	// in: path
+	TableName string `path:"table" json:"table"`
+}
+
+// PlaceLegalHoldRequest represents PlaceLegalHold request.
+// swagger:parameters placeLegalHold
+type PlaceLegalHoldRequest struct {
+	// in: path
+	TableName string `path:"table" json:"table"`
+	// in: body
+	Body metaCom.LegalHold `body:""`
+}
+
+// RemoveLegalHoldRequest represents RemoveLegalHold request.
+// swagger:parameters removeLegalHold
+type RemoveLegalHoldRequest struct {
+	// in: path
+	TableName string `path:"table" json:"table"`
+	// in: path
+	BatchIDStart int `path:"batchIDStart" json:"batchIDStart"`
+	// in: path
+	BatchIDEnd int `path:"batchIDEnd" json:"batchIDEnd"`
+}
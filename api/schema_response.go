@@ -40,3 +40,29 @@ type ListEnumCasesResponse struct {
 	EnumCases  []string
 	JSONBuffer []byte `json:"-"`
 }
+
+// ListLegalHoldsResponse represents ListLegalHolds response.
+// swagger:response listLegalHoldsResponse
+type ListLegalHoldsResponse struct {
+	//in: body
+	Body []metaCom.LegalHold
+}
+
+// EnumColumnUsage represents the usage of the enum dictionary of an enum column.
+type EnumColumnUsage struct {
+	Column   string `json:"column"`
+	Type     string `json:"type"`
+	Cases    int    `json:"cases"`
+	Capacity int    `json:"capacity"`
+	// Ratio of cases to capacity.
+	Usage float64 `json:"usage"`
+	// Whether the usage reaches the alert threshold and the column should be promoted.
+	Alert bool `json:"alert"`
+}
+
+// ListEnumUsageResponse represents ListEnumUsage response.
+// swagger:response listEnumUsageResponse
+type ListEnumUsageResponse struct {
+	//in: body
+	Body []EnumColumnUsage
+}
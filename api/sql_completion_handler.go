@@ -0,0 +1,133 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package api
+
+import (
+	"net/http"
+
+	"github.com/uber/aresdb/api/common"
+	memCom "github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/query/sql"
+	"github.com/uber/aresdb/utils"
+)
+
+// memStoreCompletionSchema provides tables and columns in memstore readable by the principal
+// for sql completion.
+type memStoreCompletionSchema struct {
+	schemaReader memCom.TableSchemaReader
+	// nil means table ACLs are not enforced.
+	principal *utils.Principal
+}
+
+// readableSchema returns the schema of the table if the principal can read it.
+func (s memStoreCompletionSchema) readableSchema(table string) *memCom.TableSchema {
+	schema, err := s.schemaReader.GetSchema(table)
+	if err != nil {
+		return nil
+	}
+	if s.principal != nil && !schema.Schema.ACL.CanRead(s.principal.Identities()) {
+		return nil
+	}
+	return schema
+}
+
+// canReadColumn tells whether the principal can read the column, caller should hold the
+// schema reader lock.
+func (s memStoreCompletionSchema) canReadColumn(schema *memCom.TableSchema, column string) bool {
+	return s.principal == nil || schema.Schema.ACL.CanReadColumn(column, s.principal.Identities())
+}
+
+// TableNames implements sql.CompletionSchema.
+func (s memStoreCompletionSchema) TableNames() []string {
+	s.schemaReader.RLock()
+	schemas := s.schemaReader.GetSchemas()
+	tables := make([]string, 0, len(schemas))
+	for table, schema := range schemas {
+		schema.RLock()
+		readable := s.principal == nil || schema.Schema.ACL.CanRead(s.principal.Identities())
+		schema.RUnlock()
+		if readable {
+			tables = append(tables, table)
+		}
+	}
+	s.schemaReader.RUnlock()
+	return tables
+}
+
+// Columns implements sql.CompletionSchema.
+func (s memStoreCompletionSchema) Columns(table string) []string {
+	schema := s.readableSchema(table)
+	if schema == nil {
+		return nil
+	}
+	schema.RLock()
+	defer schema.RUnlock()
+	var columns []string
+	for _, column := range schema.Schema.Columns {
+		if !column.Deleted && s.canReadColumn(schema, column.Name) {
+			columns = append(columns, column.Name)
+		}
+	}
+	return columns
+}
+
+// EnumCases implements sql.CompletionSchema.
+func (s memStoreCompletionSchema) EnumCases(table, column string) []string {
+	schema := s.readableSchema(table)
+	if schema == nil {
+		return nil
+	}
+	schema.RLock()
+	defer schema.RUnlock()
+	if !s.canReadColumn(schema, column) {
+		return nil
+	}
+	return append([]string(nil), schema.EnumDicts[column].ReverseDict...)
+}
+
+// CompleteSQL swagger:route POST /query/sql/complete completeSQL
+// return completion candidates of tables, columns, functions, keywords and enum values
+// at the cursor of a partial sql statement, computed from table schemas and tokens
+// expected by the sql parser at the cursor. Tables and columns the caller cannot read
+// are not returned.
+//
+// Consumes:
+//    - application/json
+//
+// Produces:
+//    - application/json
+//
+// Responses:
+//    default: errorResponse
+//        200: sqlCompletionResponse
+func (handler *QueryHandler) CompleteSQL(w http.ResponseWriter, r *http.Request) {
+	var request common.SQLCompletionRequest
+	if err := common.ReadRequest(r, &request); err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	schema := memStoreCompletionSchema{
+		schemaReader: handler.memStore,
+		principal:    utils.PrincipalFromContext(r.Context()),
+	}
+	completion, err := sql.Complete(request.Body.SQL, request.Body.Cursor, schema)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+	var response common.SQLCompletionResponse
+	response.Body = completion
+	common.RespondWithJSONObject(w, response.Body)
+}
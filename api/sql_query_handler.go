@@ -16,6 +16,7 @@ package api
 
 import (
 	"github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/metastore"
 	queryCom "github.com/uber/aresdb/query/common"
 	"github.com/uber/aresdb/query/sql"
 	"github.com/uber/aresdb/utils"
@@ -23,7 +24,8 @@ import (
 )
 
 // HandleSQL swagger:route POST /query/sql querySQL
-// query in SQL
+// query in SQL, mutate schema with a single CREATE, ALTER or DROP statement, or introspect
+// schema with a single SHOW TABLES or DESCRIBE statement
 //
 // Consumes:
 //    - application/json
@@ -48,6 +50,25 @@ func (handler *QueryHandler) HandleSQL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	for _, sqlQuery := range sqlRequest.Body.Queries {
+		if sql.IsDDL(sqlQuery) {
+			if len(sqlRequest.Body.Queries) > 1 {
+				common.RespondWithBadRequest(w, utils.StackError(nil, "DDL statement must be the only statement of the request"))
+				return
+			}
+			handler.handleDDL(w, r, sqlQuery)
+			return
+		}
+		if sql.IsMetadataStatement(sqlQuery) {
+			if len(sqlRequest.Body.Queries) > 1 {
+				common.RespondWithBadRequest(w, utils.StackError(nil, "SHOW or DESCRIBE statement must be the only statement of the request"))
+				return
+			}
+			handler.handleMetadataStatement(w, sqlQuery)
+			return
+		}
+	}
+
 	var aqlQueries []queryCom.AQLQuery
 	if sqlRequest.Body.Queries != nil {
 		aqlQueries = make([]queryCom.AQLQuery, len(sqlRequest.Body.Queries))
@@ -70,13 +91,71 @@ func (handler *QueryHandler) HandleSQL(w http.ResponseWriter, r *http.Request) {
 		Device:                sqlRequest.Device,
 		Verbose:               sqlRequest.Verbose + sqlRequest.Debug,
 		Debug:                 sqlRequest.Debug,
+		Strict:                sqlRequest.Strict,
+		TriValuedLogic:        sqlRequest.TriValuedLogic,
 		Profiling:             sqlRequest.Profiling,
+		Trace:                 sqlRequest.Trace,
 		DeviceChoosingTimeout: sqlRequest.DeviceChoosingTimeout,
+		DataScope:             sqlRequest.DataScope,
+		UnknownEnumValue:      sqlRequest.UnknownEnumValue,
 		Accept:                sqlRequest.Accept,
 		Origin:                sqlRequest.Origin,
+		RequestID:             sqlRequest.RequestID,
 		Body: queryCom.AQLRequest{
 			Queries: aqlQueries,
 		},
 	}
 	handler.handleAQLInternal(aqlRequest, w, r)
 }
+
+// handleDDL applies the DDL statement to metaStore the same way as the schema API does.
+func (handler *QueryHandler) handleDDL(w http.ResponseWriter, r *http.Request, statement string) {
+	ddl, err := sql.ParseDDL(statement)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	table := ddl.Table
+	switch ddl.Type {
+	case sql.CreateTable:
+		table.Config = metastore.DefaultTableConfig
+		err = handler.metaStore.CreateTable(&table)
+		utils.AuditRequest(r, utils.AuditOpCreateTable, table.Name, map[string]interface{}{"table": table}, err)
+	case sql.DropTable:
+		err = handler.metaStore.DeleteTable(table.Name)
+		utils.AuditRequest(r, utils.AuditOpDeleteTable, table.Name, nil, err)
+	case sql.AddColumn:
+		err = handler.metaStore.AddColumn(table.Name, ddl.Column, false)
+		utils.AuditRequest(r, utils.AuditOpAddColumn, table.Name, map[string]interface{}{
+			"column":                  ddl.Column,
+			"addToArchivingSortOrder": false,
+		}, err)
+	case sql.DropColumn:
+		err = handler.metaStore.DeleteColumn(table.Name, ddl.Column.Name)
+		utils.AuditRequest(r, utils.AuditOpDeleteColumn, table.Name, map[string]interface{}{"column": ddl.Column.Name}, err)
+	}
+
+	if err != nil {
+		common.RespondWithError(w, err)
+		return
+	}
+	common.RespondWithJSONObject(w, nil)
+}
+
+// handleMetadataStatement answers the SHOW or DESCRIBE statement from metaStore in the same response
+// format as queries.
+func (handler *QueryHandler) handleMetadataStatement(w http.ResponseWriter, statement string) {
+	metadataStatement, err := sql.ParseMetadataStatement(statement)
+	if err != nil {
+		common.RespondWithBadRequest(w, err)
+		return
+	}
+
+	result, err := metadataStatement.Execute(handler.metaStore)
+	if err != nil {
+		common.RespondWithError(w, err)
+		return
+	}
+	common.RespondWithJSONObject(w, queryCom.AQLResponse{Results: []queryCom.AQLQueryResult{result}})
+}
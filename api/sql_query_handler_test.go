@@ -16,6 +16,7 @@ package api
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/uber/aresdb/cluster/topology"
 	"io/ioutil"
@@ -24,11 +25,14 @@ import (
 
 	memCom "github.com/uber/aresdb/memstore/common"
 	memMocks "github.com/uber/aresdb/memstore/mocks"
+	"github.com/uber/aresdb/metastore"
 	metaCom "github.com/uber/aresdb/metastore/common"
+	metaMocks "github.com/uber/aresdb/metastore/mocks"
 
 	"github.com/gorilla/mux"
 	"github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
 	"github.com/uber/aresdb/common"
 )
 
@@ -61,10 +65,13 @@ var _ = ginkgo.Describe("QueryHandler SQL", func() {
 	})
 
 	var memStore *memMocks.MemStore
+	var metaStore *metaMocks.MetaStore
 	ginkgo.BeforeEach(func() {
 		memStore = CreateMemStore(testSchema, 0, nil, CreateMockDiskStore())
+		metaStore = &metaMocks.MetaStore{}
 		queryHandler := NewQueryHandler(
 			memStore,
+			metaStore,
 			topology.NewStaticShardOwner([]int{0}),
 			common.QueryConfig{
 				DeviceMemoryUtilization: 1.0,
@@ -145,4 +152,87 @@ var _ = ginkgo.Describe("QueryHandler SQL", func() {
 		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
 		Ω(string(bs)).Should(ContainSubstring("Bad request: missing/invalid parameter"))
 	})
+
+	ginkgo.It("HandleSQL should apply DDL statements to metaStore", func() {
+		hostPort := testServer.Listener.Addr().String()
+		postSQL := func(statements ...string) (int, string) {
+			body, _ := json.Marshal(map[string][]string{"queries": statements})
+			resp, err := http.Post(fmt.Sprintf("http://%s/sql", hostPort), "application/json", bytes.NewBuffer(body))
+			Ω(err).Should(BeNil())
+			bs, err := ioutil.ReadAll(resp.Body)
+			Ω(err).Should(BeNil())
+			return resp.StatusCode, string(bs)
+		}
+
+		metaStore.On("CreateTable", mock.MatchedBy(func(table *metaCom.Table) bool {
+			return table.Name == "cities" && !table.IsFactTable && len(table.Columns) == 2 &&
+				table.Config == metastore.DefaultTableConfig
+		})).Return(nil).Once()
+		statusCode, _ := postSQL("CREATE TABLE cities (id Uint16, name BigEnum, PRIMARY KEY (id))")
+		Ω(statusCode).Should(Equal(http.StatusOK))
+
+		metaStore.On("AddColumn", "cities", metaCom.Column{Name: "population", Type: "Uint32"}, false).Return(nil).Once()
+		statusCode, _ = postSQL("ALTER TABLE cities ADD COLUMN population Uint32")
+		Ω(statusCode).Should(Equal(http.StatusOK))
+
+		metaStore.On("DeleteColumn", "cities", "name").Return(metaCom.ErrColumnDoesNotExist).Once()
+		statusCode, _ = postSQL("ALTER TABLE cities DROP COLUMN name")
+		Ω(statusCode).Should(Equal(http.StatusInternalServerError))
+
+		metaStore.On("DeleteTable", "cities").Return(nil).Once()
+		statusCode, _ = postSQL("DROP TABLE cities")
+		Ω(statusCode).Should(Equal(http.StatusOK))
+
+		statusCode, bs := postSQL("DROP TABLE cities", "SELECT count(*) FROM trips")
+		Ω(statusCode).Should(Equal(http.StatusBadRequest))
+		Ω(bs).Should(ContainSubstring("DDL statement must be the only statement"))
+		statusCode, _ = postSQL("CREATE INDEX idx ON cities (id)")
+		Ω(statusCode).Should(Equal(http.StatusBadRequest))
+		metaStore.AssertExpectations(ginkgo.GinkgoT())
+	})
+
+	ginkgo.It("HandleSQL should answer SHOW TABLES and DESCRIBE statements from metaStore", func() {
+		hostPort := testServer.Listener.Addr().String()
+		postSQL := func(statements ...string) (int, string) {
+			body, _ := json.Marshal(map[string][]string{"queries": statements})
+			resp, err := http.Post(fmt.Sprintf("http://%s/sql", hostPort), "application/json", bytes.NewBuffer(body))
+			Ω(err).Should(BeNil())
+			bs, err := ioutil.ReadAll(resp.Body)
+			Ω(err).Should(BeNil())
+			return resp.StatusCode, string(bs)
+		}
+
+		cities := &metaCom.Table{
+			Name: "cities",
+			Columns: []metaCom.Column{
+				{Name: "id", Type: metaCom.Uint16},
+				{Name: "name", Type: metaCom.BigEnum},
+			},
+			PrimaryKeyColumns: []int{0},
+		}
+		metaStore.On("ListTables").Return([]string{"cities"}, nil).Once()
+		metaStore.On("GetTable", "cities").Return(cities, nil).Twice()
+		statusCode, bs := postSQL("SHOW TABLES")
+		Ω(statusCode).Should(Equal(http.StatusOK))
+		Ω(bs).Should(MatchJSON(`{"results": [{
+			"headers": ["table", "type", "primaryKey", "recordRetentionInDays"],
+			"matrixData": [["cities", "dimension", "id", "0"]]
+		}]}`))
+
+		statusCode, bs = postSQL("DESCRIBE cities")
+		Ω(statusCode).Should(Equal(http.StatusOK))
+		Ω(bs).Should(MatchJSON(`{"results": [{
+			"headers": ["column", "type", "primaryKey", "hll", "defaultValue"],
+			"matrixData": [["id", "Uint16", "1", "false", "NULL"], ["name", "BigEnum", "NULL", "false", "NULL"]]
+		}]}`))
+
+		metaStore.On("GetTable", "towns").Return(nil, metaCom.ErrTableDoesNotExist).Once()
+		statusCode, _ = postSQL("DESC towns")
+		Ω(statusCode).Should(Equal(http.StatusInternalServerError))
+
+		statusCode, bs = postSQL("SHOW TABLES", "SELECT count(*) FROM trips")
+		Ω(statusCode).Should(Equal(http.StatusBadRequest))
+		Ω(bs).Should(ContainSubstring("SHOW or DESCRIBE statement must be the only statement"))
+		metaStore.AssertExpectations(ginkgo.GinkgoT())
+	})
 })
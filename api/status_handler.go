@@ -0,0 +1,142 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+	"github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/cluster/topology"
+	"github.com/uber/aresdb/memstore"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// StatusHandler reports the status of current datanode in a single document,
+// which is aggregated by broker into the cluster status.
+type StatusHandler struct {
+	memStore           memstore.MemStore
+	shardOwner         topology.ShardOwner
+	queryHandler       *QueryHandler
+	healthCheckHandler *HealthCheckHandler
+}
+
+// NewStatusHandler returns a new StatusHandler.
+func NewStatusHandler(memStore memstore.MemStore, shardOwner topology.ShardOwner,
+	queryHandler *QueryHandler, healthCheckHandler *HealthCheckHandler) *StatusHandler {
+	return &StatusHandler{
+		memStore:           memStore,
+		shardOwner:         shardOwner,
+		queryHandler:       queryHandler,
+		healthCheckHandler: healthCheckHandler,
+	}
+}
+
+// Register registers http handlers.
+func (handler *StatusHandler) Register(router *mux.Router, wrappers ...utils.HTTPHandlerWrapper) {
+	router.HandleFunc("/status", utils.ApplyHTTPWrappers(handler.Status, wrappers)).Methods(http.MethodGet)
+}
+
+// Status swagger:route GET /status status
+// returns health, shard ownership, ingestion lag, host memory and gpu status of current datanode.
+//
+// Responses:
+//    default: errorResponse
+//        200: NodeStatus
+func (handler *StatusHandler) Status(w http.ResponseWriter, r *http.Request) {
+	common.RespondWithJSONObject(w, handler.getStatus())
+}
+
+func (handler *StatusHandler) getStatus() common.NodeStatus {
+	ownedShards := handler.shardOwner.GetOwnedShards()
+	sort.Ints(ownedShards)
+	status := common.NodeStatus{
+		Healthy: handler.healthCheckHandler.IsHealthy(),
+		Ready:   handler.healthCheckHandler.IsReady(),
+		Version: utils.GetConfig().Version,
+		Shards:  ownedShards,
+		HostMemory: common.NodeMemoryStatus{
+			TotalBytes: utils.GetConfig().TotalMemorySize,
+		},
+		Ingestion: []queryCom.ShardFreshness{},
+		Devices:   []common.NodeDeviceStatus{},
+	}
+
+	// fact tables are sharded while dimension tables only have shard 0.
+	isFactTableByName := map[string]bool{}
+	handler.memStore.RLock()
+	for tableName, schema := range handler.memStore.GetSchemas() {
+		schema.RLock()
+		isFactTableByName[tableName] = schema.Schema.IsFactTable
+		schema.RUnlock()
+	}
+	handler.memStore.RUnlock()
+
+	tableNames := make([]string, 0, len(isFactTableByName))
+	for tableName := range isFactTableByName {
+		tableNames = append(tableNames, tableName)
+	}
+	sort.Strings(tableNames)
+
+	for _, tableName := range tableNames {
+		shardIDs := []int{0}
+		if isFactTableByName[tableName] {
+			shardIDs = ownedShards
+		}
+		for _, shardID := range shardIDs {
+			shard, err := handler.memStore.GetTableShard(tableName, shardID)
+			if err != nil {
+				continue
+			}
+			highWatermark, redoLogLag := shard.LiveStore.GetDataFreshness()
+			shard.Users.Done()
+			status.Ingestion = append(status.Ingestion, queryCom.ShardFreshness{
+				Table:         tableName,
+				Shard:         shardID,
+				HighWatermark: highWatermark,
+				RedoLogLag:    redoLogLag,
+			})
+		}
+	}
+
+	memoryUsageByTableShard, err := handler.memStore.GetMemoryUsageDetails()
+	if err != nil {
+		utils.GetLogger().With("error", err).Warn("Failed to get host memory usage for status")
+	}
+	for _, tableShardMemoryUsage := range memoryUsageByTableShard {
+		status.HostMemory.UsedBytes += int64(tableShardMemoryUsage.PrimaryKeyMemory)
+		for _, columnMemoryUsage := range tableShardMemoryUsage.ColumnMemory {
+			status.HostMemory.UsedBytes += int64(columnMemoryUsage.Preloaded +
+				columnMemoryUsage.NonPreloaded + columnMemoryUsage.Live)
+		}
+	}
+
+	if handler.queryHandler != nil {
+		deviceManager := handler.queryHandler.GetDeviceManager()
+		deviceManager.RLock()
+		for _, deviceInfo := range deviceManager.DeviceInfos {
+			status.Devices = append(status.Devices, common.NodeDeviceStatus{
+				DeviceID:    deviceInfo.DeviceID,
+				QueryCount:  deviceInfo.QueryCount,
+				TotalMemory: deviceInfo.TotalMemory,
+				FreeMemory:  deviceInfo.FreeMemory,
+			})
+		}
+		deviceManager.RUnlock()
+	}
+	return status
+}
@@ -0,0 +1,71 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gorilla/mux"
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/cluster/topology"
+	"github.com/uber/aresdb/memstore"
+	memCom "github.com/uber/aresdb/memstore/common"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	queryCom "github.com/uber/aresdb/query/common"
+)
+
+var _ = ginkgo.Describe("StatusHandler", func() {
+	ginkgo.It("Status should work", func() {
+		schema := memCom.NewTableSchema(&metaCom.Table{
+			Name:        "test",
+			IsFactTable: true,
+			Columns: []metaCom.Column{
+				{Name: "c0", Type: metaCom.Uint32},
+			},
+		})
+		memStore := CreateMemStore(schema, 0, CreateMockMetaStore(), CreateMockDiskStore())
+		memStore.On("GetMemoryUsageDetails").Return(map[string]memstore.TableShardMemoryUsage{
+			"test_0": {
+				PrimaryKeyMemory: 10,
+				ColumnMemory: map[string]*memCom.ColumnMemoryUsage{
+					"c0": {Preloaded: 1, NonPreloaded: 2, Live: 3},
+				},
+			},
+		}, nil)
+
+		healthCheckHandler := NewHealthCheckHandler()
+		healthCheckHandler.SetReady(false)
+		router := mux.NewRouter()
+		NewStatusHandler(memStore, topology.NewStaticShardOwner([]int{0}), nil, healthCheckHandler).Register(router)
+		testServer := httptest.NewServer(router)
+		defer testServer.Close()
+
+		resp, err := http.Get(testServer.URL + "/status")
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		var status common.NodeStatus
+		Ω(json.NewDecoder(resp.Body).Decode(&status)).Should(BeNil())
+		Ω(status.Healthy).Should(BeTrue())
+		Ω(status.Ready).Should(BeFalse())
+		Ω(status.Shards).Should(Equal([]int{0}))
+		Ω(status.Ingestion).Should(Equal([]queryCom.ShardFreshness{{Table: "test", Shard: 0}}))
+		Ω(status.HostMemory.UsedBytes).Should(Equal(int64(16)))
+		Ω(status.Devices).Should(BeEmpty())
+	})
+})
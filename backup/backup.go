@@ -0,0 +1,504 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uber/aresdb/common"
+	"github.com/uber/aresdb/diskstore"
+	"github.com/uber/aresdb/memstore"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	// how long restore waits for the shard of a newly created table to be loaded by memstore.
+	shardLoadTimeout      = time.Minute
+	shardLoadPollInterval = time.Second
+)
+
+var (
+	// ErrCancelled is returned when the backup or restore job is cancelled.
+	ErrCancelled = errors.New("backup job cancelled")
+	// ErrNotFactTable is returned when backing up a dimension table.
+	ErrNotFactTable = errors.New("backup is only supported for fact tables")
+	// ErrShardNotEmpty is returned when restoring into a shard that already has archived data.
+	ErrShardNotEmpty = errors.New("can only restore into shards without archived data")
+)
+
+// JobContext receives logs and progress of a backup or restore and tells whether it should stop.
+type JobContext interface {
+	Logf(format string, args ...interface{})
+	SetProgress(progress float64)
+	Cancelled() bool
+}
+
+// ShardBackup is the manifest of a table shard in a backup. It's written after all batch
+// objects are uploaded so a backup of a shard is visible only when it's complete.
+type ShardBackup struct {
+	BackupID     string `json:"backupID"`
+	BaseBackupID string `json:"baseBackupID,omitempty"`
+	Shard        int    `json:"shard"`
+	CreatedAt    int64  `json:"createdAt"`
+	// Schema of the table at the time of backup.
+	Schema metaCom.Table `json:"schema"`
+	// Enum dictionaries of enum columns taken after archive batches are uploaded, so they
+	// cover all enum cases referenced by the batches.
+	EnumDicts       map[string][]string `json:"enumDicts"`
+	ArchivingCutoff uint32              `json:"archivingCutoff"`
+	Batches         []BatchBackup       `json:"batches"`
+}
+
+// BatchBackup is an archive batch version in a backup.
+type BatchBackup struct {
+	BatchID int    `json:"batchID"`
+	Version uint32 `json:"version"`
+	SeqNum  uint32 `json:"seqNum"`
+	Size    int    `json:"size"`
+	Columns []int  `json:"columns"`
+}
+
+// batchKey identifies an immutable archive batch version.
+type batchKey struct {
+	batchID int
+	version uint32
+	seqNum  uint32
+}
+
+func (b BatchBackup) key() batchKey {
+	return batchKey{batchID: b.BatchID, version: b.Version, seqNum: b.SeqNum}
+}
+
+// BackupInfo describes a backup of a table.
+type BackupInfo struct {
+	ID     string `json:"id"`
+	Shards []int  `json:"shards"`
+}
+
+// Manager backs up archived data of table shards to object storage and restores them.
+//
+// Objects are laid out as:
+//
+//	{prefix}/{table}/batches/{shard}/{batchID}/{version}-{seqNum}/{columnID}
+//	{prefix}/{table}/backups/{backupID}/{shard}.json
+//
+// Batch objects are immutable since a (batchID, version, seqNum) is never rewritten, which
+// allows incremental backups to reference batch objects uploaded by previous backups.
+//
+// Vector party files are backed up as stored on disk, so files of encrypted columns stay
+// encrypted in object storage and restoring them requires the kms of the backed up cluster.
+type Manager struct {
+	store     ObjectStore
+	prefix    string
+	metaStore metaCom.MetaStore
+	diskStore diskstore.DiskStore
+	memStore  memstore.MemStore
+}
+
+// NewManager creates a backup manager with the object store configured.
+func NewManager(cfg common.BackupConfig, metaStore metaCom.MetaStore, diskStore diskstore.DiskStore,
+	memStore memstore.MemStore) (*Manager, error) {
+	store, err := NewObjectStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewManagerWithObjectStore(store, cfg.Prefix, metaStore, diskStore, memStore), nil
+}
+
+// NewManagerWithObjectStore creates a backup manager with given object store.
+func NewManagerWithObjectStore(store ObjectStore, prefix string, metaStore metaCom.MetaStore,
+	diskStore diskstore.DiskStore, memStore memstore.MemStore) *Manager {
+	return &Manager{
+		store:     store,
+		prefix:    strings.Trim(prefix, "/"),
+		metaStore: metaStore,
+		diskStore: diskstore.Raw(diskStore),
+		memStore:  memStore,
+	}
+}
+
+func (m *Manager) tablePrefix(table string) string {
+	return path.Join(m.prefix, table)
+}
+
+func (m *Manager) batchObjectKey(table string, shard int, batch BatchBackup, columnID int) string {
+	return path.Join(m.tablePrefix(table), "batches", strconv.Itoa(shard), strconv.Itoa(batch.BatchID),
+		fmt.Sprintf("%d-%d", batch.Version, batch.SeqNum), strconv.Itoa(columnID))
+}
+
+func (m *Manager) backupsPrefix(table string) string {
+	return path.Join(m.tablePrefix(table), "backups") + "/"
+}
+
+func (m *Manager) manifestKey(table, backupID string, shard int) string {
+	return path.Join(m.tablePrefix(table), "backups", backupID, fmt.Sprintf("%d.json", shard))
+}
+
+// ValidateBackupID checks that backup id can be used as part of object keys.
+func ValidateBackupID(backupID string) error {
+	if backupID == "" || strings.ContainsAny(backupID, "/\\") || backupID == "." || backupID == ".." {
+		return utils.APIError{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid backup id %q", backupID)}
+	}
+	return nil
+}
+
+// ListBackups returns backups of the table sorted by backup id.
+func (m *Manager) ListBackups(table string) ([]BackupInfo, error) {
+	prefix := m.backupsPrefix(table)
+	keys, err := m.store.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	shardsByID := make(map[string][]int)
+	for _, key := range keys {
+		parts := strings.Split(strings.TrimPrefix(key, prefix), "/")
+		if len(parts) != 2 || !strings.HasSuffix(parts[1], ".json") {
+			continue
+		}
+		shard, err := strconv.Atoi(strings.TrimSuffix(parts[1], ".json"))
+		if err != nil {
+			continue
+		}
+		shardsByID[parts[0]] = append(shardsByID[parts[0]], shard)
+	}
+
+	backups := make([]BackupInfo, 0, len(shardsByID))
+	for id, shards := range shardsByID {
+		sort.Ints(shards)
+		backups = append(backups, BackupInfo{ID: id, Shards: shards})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ID < backups[j].ID
+	})
+	return backups, nil
+}
+
+// GetShardBackup reads the manifest of a table shard in the backup.
+func (m *Manager) GetShardBackup(table, backupID string, shard int) (*ShardBackup, error) {
+	reader, err := m.store.Get(m.manifestKey(table, backupID, shard))
+	if err != nil {
+		if err == ErrObjectNotFound {
+			return nil, utils.APIError{Code: http.StatusNotFound,
+				Message: fmt.Sprintf("backup %s of table %s shard %d does not exist", backupID, table, shard)}
+		}
+		return nil, err
+	}
+	defer reader.Close()
+
+	var manifest ShardBackup
+	if err := json.NewDecoder(reader).Decode(&manifest); err != nil {
+		return nil, utils.StackError(err, "Failed to decode manifest of backup %s of table %s shard %d",
+			backupID, table, shard)
+	}
+	return &manifest, nil
+}
+
+// Backup uploads archive batches of the table shard at its current archiving cutoff. If base
+// backup id is specified, batch versions already in the base backup will not be uploaded again.
+func (m *Manager) Backup(table string, shardID int, backupID, baseBackupID string, job JobContext) error {
+	schema, err := m.metaStore.GetTable(table)
+	if err != nil {
+		return err
+	}
+	if !schema.IsFactTable {
+		return ErrNotFactTable
+	}
+
+	// columns of batch versions in base backup.
+	baseBatches := make(map[batchKey][]int)
+	if baseBackupID != "" {
+		base, err := m.GetShardBackup(table, baseBackupID, shardID)
+		if err != nil {
+			return err
+		}
+		for _, batch := range base.Batches {
+			baseBatches[batch.key()] = batch.Columns
+		}
+		job.Logf("Incremental backup based on %s with %d batches", baseBackupID, len(base.Batches))
+	}
+
+	shard, err := m.memStore.GetTableShard(table, shardID)
+	if err != nil {
+		return err
+	}
+	defer shard.Users.Done()
+
+	// Holding the archive store version prevents archiving and backfill from deleting the
+	// batch versions being uploaded.
+	version := shard.ArchiveStore.GetCurrentVersion()
+	defer version.Users.Done()
+
+	batchIDs, err := m.metaStore.GetArchiveBatches(table, shardID, 0, math.MaxInt32)
+	if err != nil {
+		return err
+	}
+	sort.Ints(batchIDs)
+	job.Logf("Backing up %d batches at archiving cutoff %d", len(batchIDs), version.ArchivingCutoff)
+
+	manifest := ShardBackup{
+		BackupID:        backupID,
+		BaseBackupID:    baseBackupID,
+		Shard:           shardID,
+		CreatedAt:       utils.Now().Unix(),
+		Schema:          *schema,
+		ArchivingCutoff: version.ArchivingCutoff,
+	}
+
+	var uploadedBatches, uploadedBytes int
+	for i, batchID := range batchIDs {
+		if job.Cancelled() {
+			return ErrCancelled
+		}
+
+		archiveBatch := version.RequestBatch(int32(batchID))
+		if archiveBatch.Version == 0 {
+			// batch created after the archiving cutoff of the version.
+			continue
+		}
+		batch := BatchBackup{
+			BatchID: batchID,
+			Version: archiveBatch.Version,
+			SeqNum:  archiveBatch.SeqNum,
+			Size:    archiveBatch.Size,
+		}
+
+		if columnIDs, ok := baseBatches[batch.key()]; ok {
+			batch.Columns = columnIDs
+		} else {
+			columnIDs, err := m.diskStore.ListArchiveBatchVectorPartyFiles(table, shardID, batchID, batch.Version, batch.SeqNum)
+			if err != nil {
+				return err
+			}
+			sort.Ints(columnIDs)
+			batch.Columns = columnIDs
+			for _, columnID := range columnIDs {
+				size, err := m.uploadVectorParty(table, shardID, batch, columnID)
+				if err != nil {
+					return err
+				}
+				uploadedBytes += size
+			}
+			uploadedBatches++
+		}
+		manifest.Batches = append(manifest.Batches, batch)
+		job.SetProgress(float64(i+1) / float64(len(batchIDs)))
+	}
+
+	manifest.EnumDicts = make(map[string][]string)
+	for _, column := range schema.Columns {
+		if column.Deleted || !column.IsEnumColumn() {
+			continue
+		}
+		enumCases, err := m.metaStore.GetEnumDict(table, column.Name)
+		if err != nil {
+			return err
+		}
+		manifest.EnumDicts[column.Name] = enumCases
+	}
+
+	if err := m.putJSON(m.manifestKey(table, backupID, shardID), manifest); err != nil {
+		return err
+	}
+	job.Logf("Backup %s finished, uploaded %d of %d batches, %d bytes",
+		backupID, uploadedBatches, len(manifest.Batches), uploadedBytes)
+	return nil
+}
+
+// Restore restores the table shard from the backup of the source table shard. The table will be
+// created with the backed up schema if it does not exist. The shard must not have any archived
+// data, and it's recommended to restore before ingestion starts since live records older than
+// the restored archiving cutoff will not be archived.
+func (m *Manager) Restore(table string, shardID int, sourceTable, backupID string, job JobContext) error {
+	manifest, err := m.GetShardBackup(sourceTable, backupID, shardID)
+	if err != nil {
+		return err
+	}
+
+	if err := m.restoreSchema(table, manifest, job); err != nil {
+		return err
+	}
+
+	shard, err := m.waitForShard(table, shardID, job)
+	if err != nil {
+		return err
+	}
+	defer shard.Users.Done()
+
+	cutoff, err := m.metaStore.GetArchivingCutoff(table, shardID)
+	if err != nil {
+		return err
+	}
+	existingBatches, err := m.metaStore.GetArchiveBatches(table, shardID, 0, math.MaxInt32)
+	if err != nil {
+		return err
+	}
+	if cutoff != 0 || len(existingBatches) != 0 {
+		return ErrShardNotEmpty
+	}
+
+	job.Logf("Restoring %d batches at archiving cutoff %d", len(manifest.Batches), manifest.ArchivingCutoff)
+	var restoredBytes int
+	for i, batch := range manifest.Batches {
+		if job.Cancelled() {
+			return ErrCancelled
+		}
+		for _, columnID := range batch.Columns {
+			size, err := m.downloadVectorParty(sourceTable, table, shardID, batch, columnID)
+			if err != nil {
+				return err
+			}
+			restoredBytes += size
+		}
+		if err := m.metaStore.AddArchiveBatchVersion(table, shardID, batch.BatchID, batch.Version, batch.SeqNum, batch.Size); err != nil {
+			return err
+		}
+		job.SetProgress(float64(i+1) / float64(len(manifest.Batches)))
+	}
+
+	if err := m.metaStore.UpdateArchivingCutoff(table, shardID, manifest.ArchivingCutoff); err != nil {
+		return err
+	}
+	if err := shard.ReloadArchiveStore(); err != nil {
+		return err
+	}
+	job.Logf("Restore of backup %s finished, %d bytes restored", backupID, restoredBytes)
+	return nil
+}
+
+// restoreSchema creates the table with the backed up schema if it does not exist, or checks that
+// columns of existing table match the backup otherwise. Backed up enum cases are then appended to
+// enum dictionaries which must keep their enum ids.
+func (m *Manager) restoreSchema(table string, manifest *ShardBackup, job JobContext) error {
+	schema := manifest.Schema
+	schema.Name = table
+	err := m.metaStore.CreateTable(&schema)
+	if err == nil {
+		job.Logf("Created table %s", table)
+	} else if err != metaCom.ErrTableAlreadyExist {
+		return err
+	}
+
+	existing, err := m.metaStore.GetTable(table)
+	if err != nil {
+		return err
+	}
+	if !existing.IsFactTable || len(existing.Columns) < len(schema.Columns) {
+		return utils.APIError{Code: http.StatusBadRequest, Message: fmt.Sprintf("schema of table %s does not match the backup", table)}
+	}
+	for columnID, column := range schema.Columns {
+		existingColumn := existing.Columns[columnID]
+		if existingColumn.Name != column.Name || existingColumn.Type != column.Type {
+			return utils.APIError{Code: http.StatusBadRequest,
+				Message: fmt.Sprintf("column %d of table %s does not match the backup", columnID, table)}
+		}
+	}
+
+	for column, enumCases := range manifest.EnumDicts {
+		if len(enumCases) == 0 {
+			continue
+		}
+		enumIDs, err := m.metaStore.ExtendEnumDict(table, column, enumCases)
+		if err != nil {
+			return err
+		}
+		for i, enumID := range enumIDs {
+			if enumID != i {
+				return utils.APIError{Code: http.StatusBadRequest,
+					Message: fmt.Sprintf("enum dict of column %s of table %s conflicts with the backup", column, table)}
+			}
+		}
+	}
+	return nil
+}
+
+// waitForShard waits for the shard to be loaded by memstore, which happens asynchronously after
+// table creation. Caller needs to call shard.Users.Done() after use.
+func (m *Manager) waitForShard(table string, shardID int, job JobContext) (*memstore.TableShard, error) {
+	deadline := utils.Now().Add(shardLoadTimeout)
+	for {
+		shard, err := m.memStore.GetTableShard(table, shardID)
+		if err == nil {
+			return shard, nil
+		}
+		if job.Cancelled() {
+			return nil, ErrCancelled
+		}
+		if utils.Now().After(deadline) {
+			return nil, err
+		}
+		time.Sleep(shardLoadPollInterval)
+	}
+}
+
+// uploadVectorParty uploads the archive vector party file as stored on disk and returns its size.
+func (m *Manager) uploadVectorParty(table string, shard int, batch BatchBackup, columnID int) (int, error) {
+	reader, err := m.diskStore.OpenVectorPartyFileForRead(table, columnID, shard, batch.BatchID, batch.Version, batch.SeqNum)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return 0, utils.StackError(err, "Failed to read vector party file of table %s shard %d batch %d column %d",
+			table, shard, batch.BatchID, columnID)
+	}
+	return len(data), m.store.Put(m.batchObjectKey(table, shard, batch, columnID), data)
+}
+
+// downloadVectorParty writes the backed up vector party of source table into the table as is and
+// returns its size, encrypted and compressed files are decoded when loaded.
+func (m *Manager) downloadVectorParty(sourceTable, table string, shard int, batch BatchBackup, columnID int) (int, error) {
+	reader, err := m.store.Get(m.batchObjectKey(sourceTable, shard, batch, columnID))
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	writer, err := m.diskStore.OpenVectorPartyFileForWrite(table, columnID, shard, batch.BatchID, batch.Version, batch.SeqNum)
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(writer, reader)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, utils.StackError(err, "Failed to write vector party file of table %s shard %d batch %d column %d",
+			table, shard, batch.BatchID, columnID)
+	}
+	return int(size), nil
+}
+
+func (m *Manager) putJSON(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return utils.StackError(err, "Failed to marshal object %s", key)
+	}
+	return m.store.Put(key, data)
+}
@@ -0,0 +1,241 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	"github.com/uber/aresdb/common"
+	"github.com/uber/aresdb/diskstore"
+	"github.com/uber/aresdb/memstore"
+	memCom "github.com/uber/aresdb/memstore/common"
+	memComMocks "github.com/uber/aresdb/memstore/common/mocks"
+	memMocks "github.com/uber/aresdb/memstore/mocks"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	metaMocks "github.com/uber/aresdb/metastore/mocks"
+	"github.com/uber/aresdb/redolog"
+)
+
+// testJob records logs of backup and restore.
+type testJob struct {
+	logs      []string
+	progress  float64
+	cancelled bool
+}
+
+func (j *testJob) Logf(format string, args ...interface{}) {
+	j.logs = append(j.logs, format)
+}
+
+func (j *testJob) SetProgress(progress float64) {
+	j.progress = progress
+}
+
+func (j *testJob) Cancelled() bool {
+	return j.cancelled
+}
+
+var _ = Describe("backup manager", func() {
+	var rootPath string
+	var diskStore diskstore.DiskStore
+	var metaStore *metaMocks.MetaStore
+	var memStore *memMocks.MemStore
+	var manager *Manager
+
+	newTable := func(name string) metaCom.Table {
+		return metaCom.Table{
+			Name:        name,
+			IsFactTable: true,
+			Columns: []metaCom.Column{
+				{Name: "c0", Type: metaCom.Uint32},
+				{Name: "c1", Type: metaCom.SmallEnum},
+			},
+			Config: metaCom.TableConfig{BatchSize: 10},
+		}
+	}
+
+	newShard := func(table metaCom.Table) *memstore.TableShard {
+		redoLogManagerMaster, _ := redolog.NewRedoLogManagerMaster("", &common.RedoLogConfig{}, diskStore, metaStore)
+		hostMemoryManager := &memComMocks.HostMemoryManager{}
+		hostMemoryManager.On("ReportUnmanagedSpaceUsageChange", mock.Anything).Return()
+		hostMemoryManager.On("ReportManagedObject", mock.Anything, mock.Anything,
+			mock.Anything, mock.Anything, mock.Anything).Return()
+		shard := memstore.NewTableShard(memCom.NewTableSchema(&table), metaStore, diskStore, hostMemoryManager, 0,
+			memstore.NewOptions(new(memComMocks.BootStrapToken), redoLogManagerMaster))
+		memStore.On("GetTableShard", table.Name, 0).Return(shard, nil).Run(func(arguments mock.Arguments) {
+			shard.Users.Add(1)
+		})
+		return shard
+	}
+
+	writeColumn := func(table string, batchID int, version, seqNum uint32, columnID int, data string) {
+		writer, err := diskStore.OpenVectorPartyFileForWrite(table, columnID, 0, batchID, version, seqNum)
+		Ω(err).Should(BeNil())
+		writer.Write([]byte(data))
+		Ω(writer.Close()).Should(BeNil())
+	}
+
+	readColumn := func(table string, batchID int, version, seqNum uint32, columnID int) string {
+		reader, err := diskStore.OpenVectorPartyFileForRead(table, columnID, 0, batchID, version, seqNum)
+		Ω(err).Should(BeNil())
+		defer reader.Close()
+		data, _ := ioutil.ReadAll(reader)
+		return string(data)
+	}
+
+	BeforeEach(func() {
+		var err error
+		rootPath, err = ioutil.TempDir("", "backup_test")
+		Ω(err).Should(BeNil())
+		diskStore = diskstore.NewLocalDiskStore(rootPath + "/data")
+		metaStore = &metaMocks.MetaStore{}
+		memStore = &memMocks.MemStore{}
+		manager = NewManagerWithObjectStore(NewLocalObjectStore(rootPath+"/backup"), "ares",
+			metaStore, diskStore, memStore)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(rootPath)
+	})
+
+	It("incremental backup and restore should work", func() {
+		table := newTable("test")
+		shard := newShard(table)
+		metaStore.On("GetTable", "test").Return(&table, nil)
+		metaStore.On("GetEnumDict", "test", "c1").Return([]string{"a", "b"}, nil)
+		metaStore.On("GetArchiveBatches", "test", 0, int32(0), int32(math.MaxInt32)).Return([]int{1, 2}, nil).Once()
+
+		// full backup.
+		writeColumn("test", 1, 100, 0, 0, "b1c0")
+		writeColumn("test", 1, 100, 0, 1, "b1c1")
+		writeColumn("test", 2, 100, 0, 0, "b2c0")
+		shard.ArchiveStore.CurrentVersion = memstore.NewArchiveStoreVersion(100, shard)
+		metaStore.On("GetArchiveBatchVersion", "test", 0, 1, uint32(100)).Return(uint32(100), uint32(0), 5, nil)
+		metaStore.On("GetArchiveBatchVersion", "test", 0, 2, uint32(100)).Return(uint32(100), uint32(0), 6, nil)
+
+		job := &testJob{}
+		Ω(manager.Backup("test", 0, "b1", "", job)).Should(BeNil())
+		Ω(job.progress).Should(Equal(1.0))
+
+		// batch 2 gets backfilled and batch 3 gets archived.
+		writeColumn("test", 2, 100, 1, 0, "b2c0'")
+		writeColumn("test", 3, 200, 0, 0, "b3c0")
+		shard.ArchiveStore.CurrentVersion = memstore.NewArchiveStoreVersion(200, shard)
+		metaStore.On("GetArchiveBatches", "test", 0, int32(0), int32(math.MaxInt32)).Return([]int{1, 2, 3}, nil)
+		metaStore.On("GetArchiveBatchVersion", "test", 0, 1, uint32(200)).Return(uint32(100), uint32(0), 5, nil)
+		metaStore.On("GetArchiveBatchVersion", "test", 0, 2, uint32(200)).Return(uint32(100), uint32(1), 7, nil)
+		metaStore.On("GetArchiveBatchVersion", "test", 0, 3, uint32(200)).Return(uint32(200), uint32(0), 8, nil)
+
+		// delete the file of batch 1 to make sure it's not uploaded again.
+		Ω(diskStore.DeleteBatchVersions("test", 0, 1, 100, 0)).Should(BeNil())
+		Ω(manager.Backup("test", 0, "b2", "b1", job)).Should(BeNil())
+
+		backups, err := manager.ListBackups("test")
+		Ω(err).Should(BeNil())
+		Ω(backups).Should(Equal([]BackupInfo{{ID: "b1", Shards: []int{0}}, {ID: "b2", Shards: []int{0}}}))
+
+		manifest, err := manager.GetShardBackup("test", "b2", 0)
+		Ω(err).Should(BeNil())
+		Ω(manifest.BaseBackupID).Should(Equal("b1"))
+		Ω(manifest.ArchivingCutoff).Should(Equal(uint32(200)))
+		Ω(manifest.EnumDicts).Should(Equal(map[string][]string{"c1": {"a", "b"}}))
+		Ω(manifest.Batches).Should(Equal([]BatchBackup{
+			{BatchID: 1, Version: 100, SeqNum: 0, Size: 5, Columns: []int{0, 1}},
+			{BatchID: 2, Version: 100, SeqNum: 1, Size: 7, Columns: []int{0}},
+			{BatchID: 3, Version: 200, SeqNum: 0, Size: 8, Columns: []int{0}},
+		}))
+
+		// restore into a new table.
+		restoredTable := newTable("restored")
+		metaStore.On("CreateTable", mock.Anything).Return(nil)
+		metaStore.On("GetTable", "restored").Return(&restoredTable, nil)
+		metaStore.On("ExtendEnumDict", "restored", "c1", []string{"a", "b"}).Return([]int{0, 1}, nil)
+		metaStore.On("GetArchivingCutoff", "restored", 0).Return(uint32(0), nil).Once()
+		metaStore.On("GetArchivingCutoff", "restored", 0).Return(uint32(200), nil)
+		metaStore.On("GetArchiveBatches", "restored", 0, int32(0), int32(math.MaxInt32)).Return([]int{}, nil)
+		metaStore.On("AddArchiveBatchVersion", "restored", 0, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		metaStore.On("UpdateArchivingCutoff", "restored", 0, uint32(200)).Return(nil)
+		restoredShard := newShard(restoredTable)
+
+		Ω(manager.Restore("restored", 0, "test", "b2", job)).Should(BeNil())
+		Ω(readColumn("restored", 1, 100, 0, 1)).Should(Equal("b1c1"))
+		Ω(readColumn("restored", 2, 100, 1, 0)).Should(Equal("b2c0'"))
+		Ω(readColumn("restored", 3, 200, 0, 0)).Should(Equal("b3c0"))
+		metaStore.AssertCalled(GinkgoT(), "AddArchiveBatchVersion", "restored", 0, 2, uint32(100), uint32(1), 7)
+		metaStore.AssertCalled(GinkgoT(), "UpdateArchivingCutoff", "restored", 0, uint32(200))
+		Ω(restoredShard.ArchiveStore.CurrentVersion.ArchivingCutoff).Should(Equal(uint32(200)))
+		Ω(restoredShard.LiveStore.ArchivingCutoffHighWatermark).Should(Equal(uint32(200)))
+
+		// restoring again should fail since the shard already has archived data.
+		Ω(manager.Restore("restored", 0, "test", "b2", job)).Should(Equal(ErrShardNotEmpty))
+	})
+
+	It("backup should fail for dimension tables and missing base backups", func() {
+		table := newTable("test")
+		table.IsFactTable = false
+		metaStore.On("GetTable", "test").Return(&table, nil)
+		Ω(manager.Backup("test", 0, "b1", "", &testJob{})).Should(Equal(ErrNotFactTable))
+
+		table.IsFactTable = true
+		Ω(manager.Backup("test", 0, "b2", "b1", &testJob{})).ShouldNot(BeNil())
+		_, err := manager.GetShardBackup("test", "b1", 0)
+		Ω(err).ShouldNot(BeNil())
+	})
+
+	It("encrypted vector parties should stay encrypted in backups", func() {
+		kms, err := diskstore.NewLocalKMS(bytes.Repeat([]byte{7}, 32))
+		Ω(err).Should(BeNil())
+		encryptedDiskStore := diskstore.NewCompressedDiskStore(
+			diskstore.NewEncryptedDiskStore(diskStore, kms, func(table string, columnID int) bool { return true }),
+			-1, func(table string, columnID int) int { return 0 })
+		manager = NewManagerWithObjectStore(NewLocalObjectStore(rootPath+"/backup"), "ares",
+			metaStore, encryptedDiskStore, memStore)
+		writer, err := encryptedDiskStore.OpenVectorPartyFileForWrite("test", 0, 0, 1, 100, 0)
+		Ω(err).Should(BeNil())
+		writer.Write([]byte("plaintext"))
+		Ω(writer.Close()).Should(BeNil())
+
+		batch := BatchBackup{BatchID: 1, Version: 100}
+		size, err := manager.uploadVectorParty("test", 0, batch, 0)
+		Ω(err).Should(BeNil())
+		reader, err := manager.store.Get(manager.batchObjectKey("test", 0, batch, 0))
+		Ω(err).Should(BeNil())
+		data, _ := ioutil.ReadAll(reader)
+		reader.Close()
+		Ω(data).Should(HaveLen(size))
+		Ω(string(data)).ShouldNot(ContainSubstring("plaintext"))
+
+		_, err = manager.downloadVectorParty("test", "restored", 0, batch, 0)
+		Ω(err).Should(BeNil())
+		reader, err = encryptedDiskStore.OpenVectorPartyFileForRead("restored", 0, 0, 1, 100, 0)
+		Ω(err).Should(BeNil())
+		data, _ = ioutil.ReadAll(reader)
+		reader.Close()
+		Ω(string(data)).Should(Equal("plaintext"))
+	})
+
+	It("ValidateBackupID should work", func() {
+		Ω(ValidateBackupID("20190101")).Should(BeNil())
+		Ω(ValidateBackupID("")).ShouldNot(BeNil())
+		Ω(ValidateBackupID("..")).ShouldNot(BeNil())
+		Ω(ValidateBackupID("a/b")).ShouldNot(BeNil())
+	})
+})
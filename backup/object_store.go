@@ -0,0 +1,131 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/uber/aresdb/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// ErrObjectNotFound is returned when the requested object does not exist in object store.
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectStore stores immutable backup objects by slash separated keys.
+type ObjectStore interface {
+	// Put writes the object of the key, overwriting any existing object.
+	Put(key string, data []byte) error
+	// Get opens the object of the key for read. ErrObjectNotFound will be returned if
+	// the object does not exist.
+	Get(key string) (io.ReadCloser, error)
+	// List returns keys of all objects with the prefix in lexical order.
+	List(prefix string) ([]string, error)
+	// Delete deletes the object of the key, deleting a missing object is not an error.
+	Delete(key string) error
+}
+
+// NewObjectStore creates the object store according to backup config.
+func NewObjectStore(cfg common.BackupConfig) (ObjectStore, error) {
+	switch cfg.Storage {
+	case "local":
+		return NewLocalObjectStore(cfg.Bucket), nil
+	case "s3", "gcs":
+		return NewS3ObjectStore(cfg)
+	}
+	return nil, utils.StackError(nil, "Unsupported backup storage %s", cfg.Storage)
+}
+
+// localObjectStore stores objects as files under a root directory, mainly for
+// backing up to mounted network file systems and for testing.
+type localObjectStore struct {
+	rootPath string
+}
+
+// NewLocalObjectStore creates an object store backed by local directory.
+func NewLocalObjectStore(rootPath string) ObjectStore {
+	return &localObjectStore{rootPath: rootPath}
+}
+
+func (s *localObjectStore) path(key string) string {
+	return filepath.Join(s.rootPath, filepath.FromSlash(key))
+}
+
+// Put writes to a temp file and renames it so that readers never see partial objects.
+func (s *localObjectStore) Put(key string, data []byte) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return utils.StackError(err, "Failed to make dirs for object %s", key)
+	}
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return utils.StackError(err, "Failed to write object %s", key)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return utils.StackError(err, "Failed to rename object %s", key)
+	}
+	return nil
+}
+
+func (s *localObjectStore) Get(key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrObjectNotFound
+	} else if err != nil {
+		return nil, utils.StackError(err, "Failed to open object %s", key)
+	}
+	return f, nil
+}
+
+func (s *localObjectStore) Delete(key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return utils.StackError(err, "Failed to delete object %s", key)
+	}
+	return nil
+}
+
+func (s *localObjectStore) List(prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.Walk(s.rootPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		relPath, err := filepath.Rel(s.rootPath, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(relPath); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, utils.StackError(err, "Failed to list objects with prefix %s", prefix)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,296 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/uber/aresdb/common"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	s3RequestTimeout = 10 * time.Minute
+	// maximum size of error response body to include in errors.
+	s3MaxErrorBodySize = 4096
+)
+
+// s3ObjectStore talks to object storages compatible with the s3 rest api, including aws s3,
+// google cloud storage (xml api with hmac keys) and minio. Requests are path style and
+// signed with aws signature version 4.
+type s3ObjectStore struct {
+	client          *http.Client
+	endpoint        *url.URL
+	bucket          string
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+}
+
+// NewS3ObjectStore creates an object store for s3 or gcs buckets.
+func NewS3ObjectStore(cfg common.BackupConfig) (ObjectStore, error) {
+	region, endpoint := cfg.Region, cfg.Endpoint
+	if cfg.Storage == "gcs" {
+		if region == "" {
+			region = "auto"
+		}
+		if endpoint == "" {
+			endpoint = "https://storage.googleapis.com"
+		}
+	} else {
+		if region == "" {
+			region = "us-east-1"
+		}
+		if endpoint == "" {
+			endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+		}
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, utils.StackError(err, "Invalid backup storage endpoint %s", endpoint)
+	}
+
+	accessKeyID, secretAccessKey := cfg.AccessKeyID, cfg.SecretAccessKey
+	if accessKeyID == "" {
+		accessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if secretAccessKey == "" {
+		secretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+
+	return &s3ObjectStore{
+		client:          &http.Client{Timeout: s3RequestTimeout},
+		endpoint:        endpointURL,
+		bucket:          cfg.Bucket,
+		region:          region,
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+	}, nil
+}
+
+func (s *s3ObjectStore) Put(key string, data []byte) error {
+	resp, err := s.do(http.MethodPut, key, nil, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *s3ObjectStore) Get(key string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *s3ObjectStore) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, nil)
+	if err == ErrObjectNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listBucketResult is the response of ListObjects (v1) which is supported by all s3 compatible storages.
+type listBucketResult struct {
+	IsTruncated bool   `xml:"IsTruncated"`
+	NextMarker  string `xml:"NextMarker"`
+	Contents    []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+}
+
+func (s *s3ObjectStore) List(prefix string) ([]string, error) {
+	var keys []string
+	marker := ""
+	for {
+		query := url.Values{"prefix": {prefix}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		resp, err := s.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, utils.StackError(err, "Failed to decode object list of bucket %s", s.bucket)
+		}
+
+		for _, content := range result.Contents {
+			keys = append(keys, content.Key)
+		}
+		if !result.IsTruncated || len(result.Contents) == 0 {
+			break
+		}
+		// NextMarker is only returned when delimiter is specified.
+		marker = result.NextMarker
+		if marker == "" {
+			marker = result.Contents[len(result.Contents)-1].Key
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// do sends a signed request for the object key (or the bucket if key is empty) and returns the
+// response if it succeeds. Caller needs to close the response body.
+func (s *s3ObjectStore) do(method, key string, query url.Values, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = "/" + s.bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQueryString(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, utils.StackError(err, "Failed to create %s request for object %s", method, key)
+	}
+	payloadHash := sha256.Sum256(body)
+	signV4(req, s.accessKeyID, s.secretAccessKey, s.region, hex.EncodeToString(payloadHash[:]), utils.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, utils.StackError(err, "Failed to %s object %s in bucket %s", method, key, s.bucket)
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && key != "" {
+		return nil, ErrObjectNotFound
+	}
+	errBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, s3MaxErrorBodySize))
+	return nil, utils.StackError(nil, "Failed to %s object %s in bucket %s, status %d: %s",
+		method, key, s.bucket, resp.StatusCode, errBody)
+}
+
+// signV4 signs the request with aws signature version 4 for s3 service. All headers already
+// set on the request and host are signed.
+func signV4(req *http.Request, accessKeyID, secretAccessKey, region, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	headerNames := make([]string, 0, len(headers))
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQueryString(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalRequestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQueryString returns the query string sorted by keys with values uri encoded.
+func canonicalQueryString(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var params []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			params = append(params, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// uriEncode encodes every byte except unreserved characters as required by signature v4.
+// Slashes are kept as is unless encodeSlash is true.
+func uriEncode(s string, encodeSlash bool) string {
+	var builder strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			builder.WriteByte(c)
+		} else {
+			fmt.Fprintf(&builder, "%%%02X", c)
+		}
+	}
+	return builder.String()
+}
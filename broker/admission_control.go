@@ -0,0 +1,205 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uber/aresdb/broker/config"
+	"github.com/uber/aresdb/utils"
+)
+
+const defaultAdmissionMetricsIntervalSeconds = 10
+
+var (
+	// ErrQueryQueueFull is returned when too many queries are waiting for execution.
+	ErrQueryQueueFull = utils.APIError{
+		Code:    http.StatusServiceUnavailable,
+		Message: "Too many queries waiting for execution in broker, retry later",
+	}
+	// ErrQueryQueueTimeout is returned when the query waited for execution longer than the queue timeout.
+	ErrQueryQueueTimeout = utils.APIError{
+		Code:    http.StatusServiceUnavailable,
+		Message: "Timed out waiting for execution in broker, retry later",
+	}
+)
+
+// number of requests sent to datanodes and not responded yet, across all queries.
+var activeScatterRPCs int64
+
+// trackScatterRPC counts a request sent to a datanode as active until the returned function is called.
+func trackScatterRPC() func() {
+	atomic.AddInt64(&activeScatterRPCs, 1)
+	return func() {
+		atomic.AddInt64(&activeScatterRPCs, -1)
+	}
+}
+
+// AdmissionController bounds the number of queries executed concurrently by the broker. Queries
+// beyond the limit wait until running queries finish, so that a burst of queries queues up in the
+// broker instead of overloading datanodes. Queue depth, wait time, active scatter rpcs and the age
+// of the oldest in-flight query are reported so that saturation is visible before clients time out.
+type AdmissionController struct {
+	sync.Mutex
+
+	// nil if the number of concurrent queries is not limited.
+	slots        chan struct{}
+	maxQueued    int
+	queueTimeout time.Duration
+
+	queued int
+	// start time of admitted queries by admission id.
+	inFlight map[int64]time.Time
+	nextID   int64
+
+	metricsInterval time.Duration
+	stopChan        chan struct{}
+}
+
+// NewAdmissionController creates a new AdmissionController.
+func NewAdmissionController(cfg config.AdmissionControlConfig) *AdmissionController {
+	ac := &AdmissionController{
+		maxQueued:       cfg.MaxQueuedQueries,
+		queueTimeout:    time.Duration(cfg.QueueTimeoutMillis) * time.Millisecond,
+		inFlight:        make(map[int64]time.Time),
+		metricsInterval: time.Duration(cfg.MetricsIntervalSeconds) * time.Second,
+		stopChan:        make(chan struct{}),
+	}
+	if cfg.MaxConcurrentQueries > 0 {
+		ac.slots = make(chan struct{}, cfg.MaxConcurrentQueries)
+	}
+	if ac.metricsInterval <= 0 {
+		ac.metricsInterval = defaultAdmissionMetricsIntervalSeconds * time.Second
+	}
+	return ac
+}
+
+// Admit waits until the query can be executed and returns the function to call once the query
+// finishes. Returns error if the queue is full, the queue timeout expires or ctx is done first.
+func (ac *AdmissionController) Admit(ctx context.Context) (release func(), err error) {
+	if ac.slots != nil {
+		if err = ac.wait(ctx); err != nil {
+			utils.GetRootReporter().GetCounter(utils.QueryRejectedBroker).Inc(1)
+			return nil, err
+		}
+	}
+
+	ac.Lock()
+	ac.nextID++
+	id := ac.nextID
+	ac.inFlight[id] = utils.Now()
+	utils.GetRootReporter().GetGauge(utils.InFlightQueriesBroker).Update(float64(len(ac.inFlight)))
+	ac.Unlock()
+
+	return func() {
+		ac.Lock()
+		delete(ac.inFlight, id)
+		utils.GetRootReporter().GetGauge(utils.InFlightQueriesBroker).Update(float64(len(ac.inFlight)))
+		ac.Unlock()
+		if ac.slots != nil {
+			<-ac.slots
+		}
+	}, nil
+}
+
+// wait takes a slot for the query, queueing the query if all slots are taken.
+func (ac *AdmissionController) wait(ctx context.Context) error {
+	select {
+	case ac.slots <- struct{}{}:
+		utils.GetRootReporter().GetTimer(utils.QueryQueueWaitTimeBroker).Record(0)
+		return nil
+	default:
+	}
+
+	ac.Lock()
+	if ac.maxQueued > 0 && ac.queued >= ac.maxQueued {
+		ac.Unlock()
+		return ErrQueryQueueFull
+	}
+	ac.queued++
+	utils.GetRootReporter().GetGauge(utils.QueryQueueDepthBroker).Update(float64(ac.queued))
+	ac.Unlock()
+
+	start := utils.Now()
+	defer func() {
+		utils.GetRootReporter().GetTimer(utils.QueryQueueWaitTimeBroker).Record(utils.Now().Sub(start))
+		ac.Lock()
+		ac.queued--
+		utils.GetRootReporter().GetGauge(utils.QueryQueueDepthBroker).Update(float64(ac.queued))
+		ac.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if ac.queueTimeout > 0 {
+		timer := time.NewTimer(ac.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case ac.slots <- struct{}{}:
+		return nil
+	case <-timeout:
+		return ErrQueryQueueTimeout
+	case <-ctx.Done():
+		return utils.StackError(ctx.Err(), "request canceled while waiting for execution in broker")
+	}
+}
+
+// Start reports saturation gauges periodically until Stop is called. Queue depth and in-flight
+// queries are also reported on change, the periodical report keeps gauges fresh when idle and
+// tracks the age of the oldest in-flight query.
+func (ac *AdmissionController) Start() {
+	ticker := time.NewTicker(ac.metricsInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ac.reportMetrics()
+			case <-ac.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops reporting saturation gauges.
+func (ac *AdmissionController) Stop() {
+	close(ac.stopChan)
+}
+
+// reportMetrics reports the current saturation gauges.
+func (ac *AdmissionController) reportMetrics() {
+	now := utils.Now()
+	ac.Lock()
+	queued, inFlight := ac.queued, len(ac.inFlight)
+	var oldestAge time.Duration
+	for _, start := range ac.inFlight {
+		if age := now.Sub(start); age > oldestAge {
+			oldestAge = age
+		}
+	}
+	ac.Unlock()
+
+	reporter := utils.GetRootReporter()
+	reporter.GetGauge(utils.QueryQueueDepthBroker).Update(float64(queued))
+	reporter.GetGauge(utils.InFlightQueriesBroker).Update(float64(inFlight))
+	reporter.GetGauge(utils.ActiveScatterRPCsBroker).Update(float64(atomic.LoadInt64(&activeScatterRPCs)))
+	reporter.GetGauge(utils.OldestInFlightQueryAgeBroker).Update(oldestAge.Seconds())
+}
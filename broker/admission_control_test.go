@@ -0,0 +1,104 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber-go/tally"
+	"github.com/uber/aresdb/broker/config"
+	"github.com/uber/aresdb/common"
+	"github.com/uber/aresdb/utils"
+)
+
+var _ = ginkgo.Describe("admission control", func() {
+	var testScope tally.TestScope
+
+	ginkgo.BeforeEach(func() {
+		testScope = tally.NewTestScope("test", nil)
+		utils.Init(common.AresServerConfig{}, common.NewLoggerFactory().GetDefaultLogger(), common.NewLoggerFactory().GetDefaultLogger(), testScope)
+	})
+
+	gaugeValue := func(name string) float64 {
+		for _, gauge := range testScope.Snapshot().Gauges() {
+			if gauge.Name() == "test."+name {
+				return gauge.Value()
+			}
+		}
+		return -1
+	}
+
+	ginkgo.It("should queue queries beyond max concurrent queries", func() {
+		ac := NewAdmissionController(config.AdmissionControlConfig{MaxConcurrentQueries: 1, MaxQueuedQueries: 1})
+		release, err := ac.Admit(context.Background())
+		Ω(err).Should(BeNil())
+
+		admitted := make(chan struct{})
+		go func() {
+			defer ginkgo.GinkgoRecover()
+			release, err := ac.Admit(context.Background())
+			Ω(err).Should(BeNil())
+			close(admitted)
+			release()
+		}()
+		Eventually(func() float64 { return gaugeValue("query_queue_depth_broker") }).Should(Equal(1.0))
+
+		// queue is full.
+		_, err = ac.Admit(context.Background())
+		Ω(err).Should(Equal(ErrQueryQueueFull))
+
+		Consistently(admitted, 10*time.Millisecond).ShouldNot(BeClosed())
+		release()
+		Eventually(admitted).Should(BeClosed())
+		Eventually(func() float64 { return gaugeValue("in_flight_queries_broker") }).Should(Equal(0.0))
+		Ω(gaugeValue("query_queue_depth_broker")).Should(Equal(0.0))
+	})
+
+	ginkgo.It("should reject queries waiting longer than queue timeout or canceled", func() {
+		ac := NewAdmissionController(config.AdmissionControlConfig{MaxConcurrentQueries: 1, QueueTimeoutMillis: 10})
+		release, err := ac.Admit(context.Background())
+		Ω(err).Should(BeNil())
+		defer release()
+
+		_, err = ac.Admit(context.Background())
+		Ω(err).Should(Equal(ErrQueryQueueTimeout))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = ac.Admit(ctx)
+		Ω(err).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("should report active scatter rpcs and oldest in-flight query age", func() {
+		utils.SetClockImplementation(func() time.Time { return time.Unix(100, 0) })
+		defer utils.ResetClockImplementation()
+
+		ac := NewAdmissionController(config.AdmissionControlConfig{})
+		release, err := ac.Admit(context.Background())
+		Ω(err).Should(BeNil())
+		defer release()
+		rpcDone := trackScatterRPC()
+		defer rpcDone()
+
+		utils.SetClockImplementation(func() time.Time { return time.Unix(130, 0) })
+		ac.reportMetrics()
+		Ω(gaugeValue("active_scatter_rpcs_broker")).Should(Equal(1.0))
+		Ω(gaugeValue("oldest_in_flight_query_age_broker")).Should(Equal(30.0))
+		Ω(gaugeValue("in_flight_queries_broker")).Should(Equal(1.0))
+	})
+})
@@ -0,0 +1,202 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/query/expr"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	defaultZScoreThreshold = 3.0
+	maxZScoreWindow        = 10000
+
+	// keys of the measure value of each time bucket marked by zscore_outliers.
+	outlierValueKey  = "value"
+	outlierZScoreKey = "zscore"
+	outlierKey       = "outlier"
+)
+
+// zScoreOutliers marks time buckets whose measure deviates from the mean of the trailing window
+// of the same series by more than threshold standard deviations. A series is the buckets of the
+// same values of all dimensions other than the time dimension.
+type zScoreOutliers struct {
+	// number of preceding buckets in the series the z-score of a bucket is computed from.
+	window    int
+	threshold float64
+	// index of the time bucketized dimension, resolved after dimensions are processed.
+	timeDimIndex int
+}
+
+// extractOutlierDetection strips the zscore_outliers call of the measure so that datanodes only
+// compute the aggregate, the detection is applied to the merged results by broker.
+func (qc *QueryContext) extractOutlierDetection(measure *common.Measure) {
+	call, ok := measure.ExprParsed.(*expr.Call)
+	if !ok || strings.ToLower(call.Name) != expr.ZScoreOutliersCallName {
+		return
+	}
+	if len(call.Args) != 2 && len(call.Args) != 3 {
+		qc.Error = utils.StackError(nil, "expect 2 or 3 arguments for %s, but got %s", call.Name, call.String())
+		return
+	}
+	if qc.ReturnHLLBinary {
+		qc.Error = utils.StackError(nil, "%s is not supported in hll binary results", call.Name)
+		return
+	}
+
+	window, ok := call.Args[1].(*expr.NumberLiteral)
+	if !ok || window.ExprType != expr.Unsigned || window.Int < 2 || window.Int > maxZScoreWindow {
+		qc.Error = utils.StackError(nil, "expect window of %s to be an integer between 2 and %d, but got %s",
+			call.Name, maxZScoreWindow, call.Args[1].String())
+		return
+	}
+	threshold := defaultZScoreThreshold
+	if len(call.Args) == 3 {
+		literal, ok := call.Args[2].(*expr.NumberLiteral)
+		if !ok || literal.Val <= 0 {
+			qc.Error = utils.StackError(nil, "expect threshold of %s to be a positive number, but got %s",
+				call.Name, call.Args[2].String())
+			return
+		}
+		threshold = literal.Val
+	}
+
+	qc.OutlierDetection = &zScoreOutliers{
+		window:    window.Int,
+		threshold: threshold,
+	}
+	measure.ExprParsed = call.Args[0]
+	measure.Expr = call.Args[0].String()
+}
+
+// processOutlierDetection finds the time dimension the series of outlier detection are ordered by.
+func (qc *QueryContext) processOutlierDetection() {
+	if qc.OutlierDetection == nil {
+		return
+	}
+	if qc.IsNonAggregationQuery {
+		qc.Error = utils.StackError(nil, "%s is only supported in aggregation queries", expr.ZScoreOutliersCallName)
+		return
+	}
+	for i, dim := range qc.AQLQuery.Dimensions {
+		if dim.TimeBucketizer != "" {
+			qc.OutlierDetection.timeDimIndex = i
+			return
+		}
+	}
+	qc.Error = utils.StackError(nil, "%s requires a time bucketized dimension", expr.ZScoreOutliersCallName)
+}
+
+// outlierBucket is a time bucket of a series in the results.
+type outlierBucket struct {
+	time   string
+	value  float64
+	parent map[string]interface{}
+	key    string
+}
+
+// mark replaces the measure value of each time bucket in the results with the value, its
+// z-score and whether it is an outlier. Z-score is null for buckets without enough preceding
+// buckets or with a constant window, in which case any deviation is an outlier.
+func (o *zScoreOutliers) mark(results map[string]interface{}, numDims int) {
+	series := make(map[string][]outlierBucket)
+	dimValues := make([]string, numDims)
+	var traverse func(depth int, curr map[string]interface{})
+	traverse = func(depth int, curr map[string]interface{}) {
+		for key, child := range curr {
+			dimValues[depth] = key
+			if depth < numDims-1 {
+				if children, ok := child.(map[string]interface{}); ok {
+					traverse(depth+1, children)
+				}
+				continue
+			}
+			value, ok := child.(float64)
+			if !ok {
+				continue
+			}
+			var seriesKey []string
+			for i, dimValue := range dimValues {
+				if i != o.timeDimIndex {
+					seriesKey = append(seriesKey, dimValue)
+				}
+			}
+			id := strings.Join(seriesKey, "\x00")
+			series[id] = append(series[id], outlierBucket{
+				time:   dimValues[o.timeDimIndex],
+				value:  value,
+				parent: curr,
+				key:    key,
+			})
+		}
+	}
+	if numDims > 0 {
+		traverse(0, results)
+	}
+
+	for _, buckets := range series {
+		sort.Slice(buckets, func(i, j int) bool { return lessTimeBucket(buckets[i].time, buckets[j].time) })
+		for i, bucket := range buckets {
+			marked := map[string]interface{}{
+				outlierValueKey:  bucket.value,
+				outlierZScoreKey: nil,
+				outlierKey:       false,
+			}
+			if i >= 2 {
+				start := i - o.window
+				if start < 0 {
+					start = 0
+				}
+				mean, stddev := meanAndStddev(buckets[start:i])
+				if stddev > 0 {
+					zscore := (bucket.value - mean) / stddev
+					marked[outlierZScoreKey] = zscore
+					marked[outlierKey] = math.Abs(zscore) > o.threshold
+				} else {
+					marked[outlierKey] = bucket.value != mean
+				}
+			}
+			bucket.parent[bucket.key] = marked
+		}
+	}
+}
+
+// lessTimeBucket orders time buckets numerically if both are numbers, e.g. seconds since epoch,
+// otherwise as strings, e.g. formatted dates.
+func lessTimeBucket(a, b string) bool {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return fa < fb
+	}
+	return a < b
+}
+
+func meanAndStddev(buckets []outlierBucket) (mean, stddev float64) {
+	for _, bucket := range buckets {
+		mean += bucket.value
+	}
+	mean /= float64(len(buckets))
+	for _, bucket := range buckets {
+		stddev += (bucket.value - mean) * (bucket.value - mean)
+	}
+	return mean, math.Sqrt(stddev / float64(len(buckets)))
+}
@@ -0,0 +1,142 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/broker/common"
+	memCom "github.com/uber/aresdb/memstore/common"
+	memComMocks "github.com/uber/aresdb/memstore/common/mocks"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	queryCom "github.com/uber/aresdb/query/common"
+)
+
+var _ = ginkgo.Describe("anomaly detection", func() {
+	table := &metaCom.Table{
+		Name: "trips",
+		Columns: []metaCom.Column{
+			{Name: "request_at", Type: "Uint32"},
+			{Name: "city", Type: "SmallEnum"},
+			{Name: "fare", Type: "Float32"},
+		},
+	}
+	tableSchema := memCom.NewTableSchema(table)
+	tableSchema.CreateEnumDict("city", []string{"sf", "nyc"})
+
+	compile := func(measure string, dimensions ...queryCom.Dimension) *QueryContext {
+		mockTableSchemaReader := memComMocks.TableSchemaReader{}
+		mockTableSchemaReader.On("RLock").Return(nil)
+		mockTableSchemaReader.On("RUnlock").Return(nil)
+		mockTableSchemaReader.On("GetSchema", "trips").Return(tableSchema, nil)
+
+		qc := NewQueryContext(&queryCom.AQLQuery{
+			Table:      "trips",
+			Measures:   []queryCom.Measure{{Expr: measure}},
+			Dimensions: dimensions,
+		}, false, httptest.NewRecorder())
+		qc.Compile(&mockTableSchemaReader)
+		return qc
+	}
+
+	ginkgo.It("should compile zscore_outliers measures", func() {
+		qc := compile("zscore_outliers(sum(fare), 24)",
+			queryCom.Dimension{Expr: "city"}, queryCom.Dimension{Expr: "request_at", TimeBucketizer: "hour"})
+		Ω(qc.Error).Should(BeNil())
+		Ω(*qc.OutlierDetection).Should(Equal(zScoreOutliers{window: 24, threshold: 3, timeDimIndex: 1}))
+		Ω(qc.GetRewrittenQuery().Measures[0].Expr).Should(Equal("sum(fare)"))
+
+		qc = compile("zscore_outliers(avg(fare), 24, 2.5)", queryCom.Dimension{Expr: "request_at", TimeBucketizer: "day"})
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.OutlierDetection.threshold).Should(Equal(2.5))
+		// avg queries are split into sum and count queries by measure expression.
+		sumqc, _ := splitAvgQuery(*qc)
+		Ω(sumqc.AQLQuery.Measures[0].Expr).Should(Equal("sum(fare)"))
+
+		qc = compile("sum(fare)", queryCom.Dimension{Expr: "request_at", TimeBucketizer: "day"})
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.OutlierDetection).Should(BeNil())
+
+		qc = compile("zscore_outliers(sum(fare), 24)", queryCom.Dimension{Expr: "city"})
+		Ω(qc.Error.Error()).Should(ContainSubstring("requires a time bucketized dimension"))
+		qc = compile("zscore_outliers(sum(fare), 1)", queryCom.Dimension{Expr: "request_at", TimeBucketizer: "day"})
+		Ω(qc.Error.Error()).Should(ContainSubstring("expect window of zscore_outliers"))
+		qc = compile("zscore_outliers(sum(fare), 24, 0)", queryCom.Dimension{Expr: "request_at", TimeBucketizer: "day"})
+		Ω(qc.Error.Error()).Should(ContainSubstring("expect threshold of zscore_outliers"))
+		qc = compile("zscore_outliers(sum(fare))", queryCom.Dimension{Expr: "request_at", TimeBucketizer: "day"})
+		Ω(qc.Error).ShouldNot(BeNil())
+		qc = compile("zscore_outliers(1, 24)", queryCom.Dimension{Expr: "request_at", TimeBucketizer: "day"})
+		Ω(qc.Error.Error()).Should(ContainSubstring("only supported in aggregation queries"))
+	})
+
+	ginkgo.It("should mark outliers of each series", func() {
+		detection := &zScoreOutliers{window: 3, threshold: 2, timeDimIndex: 1}
+		results := map[string]interface{}{
+			"sf": map[string]interface{}{
+				"1000": 10.0,
+				"2000": 12.0,
+				"3000": 10.0,
+				"4000": 12.0,
+				"5000": 30.0,
+			},
+			"nyc": map[string]interface{}{
+				"200":  5.0,
+				"300":  5.0,
+				"1000": 5.0,
+				"2000": 6.0,
+			},
+		}
+		detection.mark(results, 2)
+
+		sf := results["sf"].(map[string]interface{})
+		Ω(sf["1000"]).Should(Equal(map[string]interface{}{"value": 10.0, "zscore": nil, "outlier": false}))
+		Ω(sf["2000"]).Should(Equal(map[string]interface{}{"value": 12.0, "zscore": nil, "outlier": false}))
+		// mean 10.67 and stddev 0.94 of 10, 12, 10.
+		Ω(sf["4000"].(map[string]interface{})["zscore"]).Should(BeNumerically("~", 1.414, 0.001))
+		Ω(sf["4000"].(map[string]interface{})["outlier"]).Should(BeFalse())
+		Ω(sf["5000"].(map[string]interface{})["zscore"]).Should(BeNumerically("~", 19.799, 0.001))
+		Ω(sf["5000"].(map[string]interface{})["outlier"]).Should(BeTrue())
+
+		// buckets are ordered numerically and constant windows have no z-score.
+		nyc := results["nyc"].(map[string]interface{})
+		Ω(nyc["1000"]).Should(Equal(map[string]interface{}{"value": 5.0, "zscore": nil, "outlier": false}))
+		Ω(nyc["2000"]).Should(Equal(map[string]interface{}{"value": 6.0, "zscore": nil, "outlier": true}))
+	})
+
+	ginkgo.It("should mark outliers after translating enums", func() {
+		qc := compile("zscore_outliers(count(*), 2, 1)",
+			queryCom.Dimension{Expr: "request_at", TimeBucketizer: "day"}, queryCom.Dimension{Expr: "city"})
+		Ω(qc.Error).Should(BeNil())
+		plan := AggQueryPlan{aggType: common.Count, qc: qc}
+		results := queryCom.AQLQueryResult{
+			"1": map[string]interface{}{"0": 1.0},
+			"2": map[string]interface{}{"0": 2.0},
+			"3": map[string]interface{}{"0": 10.0},
+		}
+		w := httptest.NewRecorder()
+		Ω(plan.postProcess(context.TODO(), results, nil, w)).Should(BeNil())
+
+		var marked map[string]map[string]map[string]interface{}
+		Ω(json.Unmarshal(w.Body.Bytes(), &marked)).Should(BeNil())
+		Ω(marked["1"]["sf"]["outlier"]).Should(BeFalse())
+		Ω(marked["3"]["sf"]["value"]).Should(Equal(10.0))
+		Ω(marked["3"]["sf"]["zscore"]).Should(Equal(17.0))
+		Ω(marked["3"]["sf"]["outlier"]).Should(BeTrue())
+	})
+})
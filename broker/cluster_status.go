@@ -0,0 +1,211 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	apiCom "github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/cluster/topology"
+	dataCli "github.com/uber/aresdb/datanode/client"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	clusterStatusTimeoutSeconds = 5
+)
+
+// HostStatus is the status of a datanode in cluster status.
+type HostStatus struct {
+	ID      string `json:"id"`
+	Address string `json:"address"`
+	// shards assigned to the host in topology
+	AssignedShards []int `json:"assignedShards"`
+	Reachable      bool  `json:"reachable"`
+	// error fetching status from the host
+	Error  string             `json:"error,omitempty"`
+	Status *apiCom.NodeStatus `json:"status,omitempty"`
+}
+
+// ClusterStatus aggregates status of all datanodes in the cluster.
+type ClusterStatus struct {
+	Time                time.Time `json:"time"`
+	NumHosts            int       `json:"numHosts"`
+	NumReadyHosts       int       `json:"numReadyHosts"`
+	NumUnreachableHosts int       `json:"numUnreachableHosts"`
+	NumShards           int       `json:"numShards"`
+	// shards in topology not assigned to any host in current view
+	UnassignedShards []int `json:"unassignedShards"`
+	// max redolog lag in seconds among all table shards
+	MaxRedoLogLag uint32       `json:"maxRedoLogLag"`
+	Hosts         []HostStatus `json:"hosts"`
+}
+
+// ClusterStatusHandler serves the status of all datanodes in the cluster, so that
+// operators do not need to check each datanode individually.
+type ClusterStatusHandler struct {
+	topo   topology.Topology
+	client dataCli.DataNodeStatusClient
+}
+
+// NewClusterStatusHandler creates a new ClusterStatusHandler.
+func NewClusterStatusHandler(topo topology.Topology, client dataCli.DataNodeStatusClient) *ClusterStatusHandler {
+	return &ClusterStatusHandler{
+		topo:   topo,
+		client: client,
+	}
+}
+
+// Register registers http handlers.
+func (handler *ClusterStatusHandler) Register(router *mux.Router, wrappers ...utils.HTTPHandlerWrapper) {
+	router.HandleFunc("/status", utils.ApplyHTTPWrappers(handler.HandleStatus, wrappers)).Methods(http.MethodGet)
+	router.HandleFunc("/status/html", utils.ApplyHTTPWrappers(handler.HandleStatusHTML, wrappers)).Methods(http.MethodGet)
+}
+
+// HandleStatus returns cluster status as json.
+func (handler *ClusterStatusHandler) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	apiCom.RespondWithJSONObject(w, handler.getClusterStatus(r.Context()))
+}
+
+// HandleStatusHTML renders cluster status as a html page.
+func (handler *ClusterStatusHandler) HandleStatusHTML(w http.ResponseWriter, r *http.Request) {
+	status := handler.getClusterStatus(r.Context())
+	w.Header().Set(utils.HTTPContentTypeHeaderKey, "text/html; charset=utf-8")
+	if err := clusterStatusTemplate.Execute(w, status); err != nil {
+		utils.GetLogger().With("error", err).Error("failed to render cluster status")
+	}
+}
+
+func (handler *ClusterStatusHandler) getClusterStatus(ctx context.Context) ClusterStatus {
+	ctx, cancelFn := context.WithTimeout(ctx, clusterStatusTimeoutSeconds*time.Second)
+	defer cancelFn()
+
+	m := handler.topo.Get()
+	hostShardSets := m.HostShardSets()
+	status := ClusterStatus{
+		Time:             utils.Now().UTC(),
+		NumHosts:         len(hostShardSets),
+		UnassignedShards: []int{},
+		Hosts:            make([]HostStatus, len(hostShardSets)),
+	}
+
+	assignedShards := map[uint32]bool{}
+	wg := &sync.WaitGroup{}
+	for i, hostShardSet := range hostShardSets {
+		host := hostShardSet.Host()
+		hostStatus := HostStatus{
+			ID:             host.ID(),
+			Address:        host.Address(),
+			AssignedShards: []int{},
+		}
+		for _, shardID := range hostShardSet.ShardSet().AllIDs() {
+			hostStatus.AssignedShards = append(hostStatus.AssignedShards, int(shardID))
+			assignedShards[shardID] = true
+		}
+		sort.Ints(hostStatus.AssignedShards)
+		status.Hosts[i] = hostStatus
+
+		wg.Add(1)
+		go func(i int, host topology.Host) {
+			defer wg.Done()
+			nodeStatus, err := handler.client.Status(ctx, host)
+			if err != nil {
+				status.Hosts[i].Error = err.Error()
+				return
+			}
+			status.Hosts[i].Reachable = true
+			status.Hosts[i].Status = &nodeStatus
+		}(i, host)
+	}
+	wg.Wait()
+
+	for _, shardID := range m.ShardSet().AllIDs() {
+		status.NumShards++
+		if !assignedShards[shardID] {
+			status.UnassignedShards = append(status.UnassignedShards, int(shardID))
+		}
+	}
+	sort.Ints(status.UnassignedShards)
+
+	for _, hostStatus := range status.Hosts {
+		if !hostStatus.Reachable {
+			status.NumUnreachableHosts++
+			continue
+		}
+		if hostStatus.Status.Ready {
+			status.NumReadyHosts++
+		}
+		for _, shard := range hostStatus.Status.Ingestion {
+			if shard.RedoLogLag > status.MaxRedoLogLag {
+				status.MaxRedoLogLag = shard.RedoLogLag
+			}
+		}
+	}
+
+	sort.Slice(status.Hosts, func(i, j int) bool {
+		return status.Hosts[i].ID < status.Hosts[j].ID
+	})
+	return status
+}
+
+var clusterStatusTemplate = template.Must(template.New("clusterStatus").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>AresDB Cluster Status</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+.bad { color: #c00; }
+</style>
+</head>
+<body>
+<h2>Cluster Status</h2>
+<p>Generated at {{.Time}}</p>
+<p>
+Hosts: {{.NumHosts}}, ready: {{.NumReadyHosts}},
+<span {{if .NumUnreachableHosts}}class="bad"{{end}}>unreachable: {{.NumUnreachableHosts}}</span><br>
+Shards: {{.NumShards}}, <span {{if .UnassignedShards}}class="bad"{{end}}>unassigned: {{.UnassignedShards}}</span><br>
+Max redolog lag: {{.MaxRedoLogLag}}s
+</p>
+<table>
+<tr><th>Host</th><th>Address</th><th>Assigned Shards</th><th>Healthy</th><th>Ready</th><th>Version</th><th>Host Memory (used/total bytes)</th><th>GPUs (queries, free/total bytes)</th><th>Ingestion (table/shard: high watermark, redolog lag)</th></tr>
+{{range .Hosts}}
+<tr>
+<td>{{.ID}}</td>
+<td>{{.Address}}</td>
+<td>{{.AssignedShards}}</td>
+{{if .Reachable}}
+<td {{if not .Status.Healthy}}class="bad"{{end}}>{{.Status.Healthy}}</td>
+<td {{if not .Status.Ready}}class="bad"{{end}}>{{.Status.Ready}}</td>
+<td>{{.Status.Version}}</td>
+<td>{{.Status.HostMemory.UsedBytes}} / {{.Status.HostMemory.TotalBytes}}</td>
+<td>{{range .Status.Devices}}#{{.DeviceID}}: {{.QueryCount}}, {{.FreeMemory}} / {{.TotalMemory}}<br>{{end}}</td>
+<td>{{range .Status.Ingestion}}{{.Table}}/{{.Shard}}: {{.HighWatermark}}, {{.RedoLogLag}}s<br>{{end}}</td>
+{{else}}
+<td colspan="6" class="bad">unreachable: {{.Error}}</td>
+{{end}}
+</tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,113 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gorilla/mux"
+	m3Shard "github.com/m3db/m3/src/cluster/shard"
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	apiCom "github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/cluster/shard"
+	"github.com/uber/aresdb/cluster/topology"
+	topoMock "github.com/uber/aresdb/cluster/topology/mocks"
+	dataCliMocks "github.com/uber/aresdb/datanode/client/mocks"
+	queryCom "github.com/uber/aresdb/query/common"
+)
+
+var _ = ginkgo.Describe("cluster status", func() {
+	var testServer *httptest.Server
+
+	ginkgo.BeforeEach(func() {
+		host1 := topology.NewHost("h1", "h1:9374")
+		host2 := topology.NewHost("h2", "h2:9374")
+		topoMap := topology.NewStaticMap(topology.NewStaticOptions().
+			SetShardSet(shard.NewShardSet(shard.NewShards([]uint32{0, 1, 2}, m3Shard.Available))).
+			SetReplicas(1).
+			SetHostShardSets([]topology.HostShardSet{
+				topology.NewHostShardSet(host2, shard.NewShardSet(shard.NewShards([]uint32{1}, m3Shard.Available))),
+				topology.NewHostShardSet(host1, shard.NewShardSet(shard.NewShards([]uint32{0}, m3Shard.Available))),
+			}))
+		mockTopo := &topoMock.HealthTrackingDynamicTopoloy{}
+		mockTopo.On("Get").Return(topoMap)
+
+		mockClient := &dataCliMocks.DataNodeStatusClient{}
+		mockClient.On("Status", mock.Anything, host1).Return(apiCom.NodeStatus{
+			Healthy: true,
+			Ready:   true,
+			Shards:  []int{0},
+			Ingestion: []queryCom.ShardFreshness{
+				{Table: "t1", Shard: 0, HighWatermark: 100, RedoLogLag: 3},
+				{Table: "t2", Shard: 0, HighWatermark: 120, RedoLogLag: 5},
+			},
+			HostMemory: apiCom.NodeMemoryStatus{TotalBytes: 100, UsedBytes: 10},
+			Devices:    []apiCom.NodeDeviceStatus{{DeviceID: 0, QueryCount: 1, TotalMemory: 100, FreeMemory: 50}},
+		}, nil)
+		mockClient.On("Status", mock.Anything, host2).Return(apiCom.NodeStatus{}, errors.New("failed to connect"))
+
+		router := mux.NewRouter()
+		NewClusterStatusHandler(mockTopo, mockClient).Register(router.PathPrefix("/cluster").Subrouter())
+		testServer = httptest.NewServer(router)
+	})
+
+	ginkgo.AfterEach(func() {
+		testServer.Close()
+	})
+
+	ginkgo.It("should aggregate datanode status", func() {
+		resp, err := http.Get(testServer.URL + "/cluster/status")
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		var status ClusterStatus
+		Ω(json.NewDecoder(resp.Body).Decode(&status)).Should(BeNil())
+
+		Ω(status.NumHosts).Should(Equal(2))
+		Ω(status.NumReadyHosts).Should(Equal(1))
+		Ω(status.NumUnreachableHosts).Should(Equal(1))
+		Ω(status.NumShards).Should(Equal(3))
+		Ω(status.UnassignedShards).Should(Equal([]int{2}))
+		Ω(status.MaxRedoLogLag).Should(Equal(uint32(5)))
+
+		Ω(status.Hosts).Should(HaveLen(2))
+		Ω(status.Hosts[0].ID).Should(Equal("h1"))
+		Ω(status.Hosts[0].Reachable).Should(BeTrue())
+		Ω(status.Hosts[0].AssignedShards).Should(Equal([]int{0}))
+		Ω(status.Hosts[0].Status.HostMemory.UsedBytes).Should(Equal(int64(10)))
+		Ω(status.Hosts[0].Status.Devices).Should(HaveLen(1))
+		Ω(status.Hosts[1].ID).Should(Equal("h2"))
+		Ω(status.Hosts[1].Reachable).Should(BeFalse())
+		Ω(status.Hosts[1].Error).Should(Equal("failed to connect"))
+		Ω(status.Hosts[1].Status).Should(BeNil())
+	})
+
+	ginkgo.It("should render html", func() {
+		resp, err := http.Get(testServer.URL + "/cluster/status/html")
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		Ω(resp.Header.Get("Content-Type")).Should(ContainSubstring("text/html"))
+		bs, err := ioutil.ReadAll(resp.Body)
+		Ω(err).Should(BeNil())
+		Ω(string(bs)).Should(ContainSubstring("<td>h1</td>"))
+		Ω(string(bs)).Should(ContainSubstring("t2/0: 120, 5s"))
+		Ω(string(bs)).Should(ContainSubstring("unreachable: failed to connect"))
+	})
+})
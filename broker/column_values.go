@@ -0,0 +1,186 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	apiCom "github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/broker/util"
+	"github.com/uber/aresdb/cluster/topology"
+	dataCli "github.com/uber/aresdb/datanode/client"
+	memCom "github.com/uber/aresdb/memstore/common"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	columnValuesTimeoutSeconds = 10
+	// start of the time range counted for fact tables if not specified.
+	defaultColumnValuesFrom = "-1d"
+)
+
+// ColumnValuesHandler lists distinct values of a column with their counts across all shards
+// of the table, by merging counts of the shards assigned to each datanode.
+type ColumnValuesHandler struct {
+	schemaReader memCom.TableSchemaReader
+	topo         topology.Topology
+	client       dataCli.DataNodeColumnValuesClient
+}
+
+// NewColumnValuesHandler creates a new ColumnValuesHandler.
+func NewColumnValuesHandler(schemaReader memCom.TableSchemaReader, topo topology.Topology, client dataCli.DataNodeColumnValuesClient) *ColumnValuesHandler {
+	return &ColumnValuesHandler{
+		schemaReader: schemaReader,
+		topo:         topo,
+		client:       client,
+	}
+}
+
+// Register registers http handlers.
+func (handler *ColumnValuesHandler) Register(router *mux.Router, wrappers ...utils.HTTPHandlerWrapper) {
+	router.HandleFunc("/{table}/columns/{column}/values", utils.ApplyHTTPWrappers(handler.ListColumnValues, wrappers)).Methods(http.MethodGet)
+}
+
+// ListColumnValues lists values of the column ordered by counts summed over all shards. The
+// request fails if counts of any shard are not available, since partial counts would be
+// misleading.
+func (handler *ColumnValuesHandler) ListColumnValues(w http.ResponseWriter, r *http.Request) {
+	var request apiCom.ColumnValuesRequest
+	if err := apiCom.ReadRequest(r, &request); err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	handler.schemaReader.RLock()
+	schema, err := handler.schemaReader.GetSchema(request.TableName)
+	handler.schemaReader.RUnlock()
+	if err != nil {
+		apiCom.RespondWithError(w, apiCom.ErrTableDoesNotExist)
+		return
+	}
+
+	principal := utils.PrincipalFromContext(r.Context())
+	schema.RLock()
+	readable := principal == nil || (schema.Schema.ACL.CanRead(principal.Identities()) &&
+		schema.Schema.ACL.CanReadColumn(request.ColumnName, principal.Identities()))
+	isFactTable := schema.Schema.IsFactTable
+	_, found := schema.ColumnIDs[request.ColumnName]
+	schema.RUnlock()
+
+	if !readable {
+		apiCom.RespondWithError(w, utils.APIError{
+			Code:    http.StatusForbidden,
+			Message: fmt.Sprintf("principal %s is not allowed to read column %s of table %s", principal.Name, request.ColumnName, request.TableName),
+		})
+		return
+	}
+	if !found {
+		apiCom.RespondWithError(w, apiCom.ErrColumnDoesNotExist)
+		return
+	}
+
+	if isFactTable {
+		// resolved so that all datanodes count the same time range.
+		if err = resolveColumnValuesTimeRange(&request); err != nil {
+			apiCom.RespondWithBadRequest(w, err)
+			return
+		}
+	}
+
+	assignment, err := util.CalculateShardAssignment(handler.topo)
+	if err != nil {
+		apiCom.RespondWithError(w, utils.APIError{
+			Code:    http.StatusServiceUnavailable,
+			Message: "Failed to assign shards to datanodes",
+			Cause:   err,
+		})
+		return
+	}
+
+	counts, err := handler.fetchCounts(r.Context(), request, assignment)
+	if err != nil {
+		apiCom.RespondWithError(w, utils.APIError{
+			Code:    http.StatusInternalServerError,
+			Message: "Failed to count column values",
+			Cause:   err,
+		})
+		return
+	}
+
+	var response apiCom.ColumnValuesResponse
+	response.Body.Values, response.Body.Complete = apiCom.SortColumnValues(counts, request.Limit)
+	apiCom.RespondWithJSONObject(w, response.Body)
+}
+
+// fetchCounts fetches counts of the shards assigned to each datanode in parallel and sums them.
+func (handler *ColumnValuesHandler) fetchCounts(ctx context.Context, request apiCom.ColumnValuesRequest,
+	assignment map[topology.Host][]uint32) (map[string]int64, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, columnValuesTimeoutSeconds*time.Second)
+	defer cancelFn()
+
+	counts := make(map[string]int64)
+	var firstErr error
+	var mutex sync.Mutex
+	wg := &sync.WaitGroup{}
+	for host, shards := range assignment {
+		wg.Add(1)
+		go func(host topology.Host, shards []uint32) {
+			defer wg.Done()
+			values, err := handler.client.ListColumnValues(ctx, host, request, shards)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				utils.GetLogger().With("host", host.ID(), "table", request.TableName,
+					"column", request.ColumnName, "error", err).Error("failed to list column values")
+				if firstErr == nil {
+					firstErr = utils.StackError(err, "failed to list column values on host %s", host.ID())
+				}
+				return
+			}
+			for _, value := range values {
+				counts[value.Value] += value.Count
+			}
+		}(host, shards)
+	}
+	wg.Wait()
+	return counts, firstErr
+}
+
+// resolveColumnValuesTimeRange replaces the time range of the request with seconds since epoch.
+func resolveColumnValuesTimeRange(request *apiCom.ColumnValuesRequest) error {
+	filter := queryCom.TimeFilter{From: request.From, To: request.To}
+	if filter.From == "" {
+		filter.From = defaultColumnValuesFrom
+	}
+	var loc *time.Location
+	if request.Timezone != "" {
+		var err error
+		if loc, err = queryCom.ParseTimezone(request.Timezone); err != nil {
+			return err
+		}
+	}
+	resolved, err := queryCom.ResolveTimeFilter(filter, loc, utils.Now())
+	if err != nil {
+		return err
+	}
+	request.From, request.To = resolved.From, resolved.To
+	return nil
+}
@@ -0,0 +1,155 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gorilla/mux"
+	m3Shard "github.com/m3db/m3/src/cluster/shard"
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	apiCom "github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/cluster/shard"
+	"github.com/uber/aresdb/cluster/topology"
+	topoMock "github.com/uber/aresdb/cluster/topology/mocks"
+	dataCliMocks "github.com/uber/aresdb/datanode/client/mocks"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+var _ = ginkgo.Describe("column values", func() {
+	var testServer *httptest.Server
+	var mockClient *dataCliMocks.DataNodeColumnValuesClient
+	var principal *utils.Principal
+	host1 := topology.NewHost("h1", "h1:9374")
+	host2 := topology.NewHost("h2", "h2:9374")
+
+	ginkgo.BeforeEach(func() {
+		topoMap := topology.NewStaticMap(topology.NewStaticOptions().
+			SetShardSet(shard.NewShardSet(shard.NewShards([]uint32{0, 1}, m3Shard.Available))).
+			SetReplicas(1).
+			SetHostShardSets([]topology.HostShardSet{
+				topology.NewHostShardSet(host1, shard.NewShardSet(shard.NewShards([]uint32{0}, m3Shard.Available))),
+				topology.NewHostShardSet(host2, shard.NewShardSet(shard.NewShards([]uint32{1}, m3Shard.Available))),
+			}))
+		mockTopo := &topoMock.HealthTrackingDynamicTopoloy{}
+		mockTopo.On("Get").Return(topoMap)
+
+		schemaMutator := NewBrokerSchemaMutator()
+		schemaMutator.CreateTable(&metaCom.Table{
+			Name: "trips",
+			Columns: []metaCom.Column{
+				{Name: "request_at", Type: "Uint32"},
+				{Name: "status", Type: "SmallEnum"},
+				{Name: "fare", Type: "Float32"},
+			},
+			IsFactTable: true,
+			ACL: &metaCom.TableACL{
+				Readers:           []string{"analysts"},
+				RestrictedColumns: map[string][]string{"fare": {"admins"}},
+			},
+		})
+
+		mockClient = &dataCliMocks.DataNodeColumnValuesClient{}
+		principal = nil
+		router := mux.NewRouter()
+		withPrincipal := func(handler http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				if principal != nil {
+					r = r.WithContext(utils.WithPrincipal(r.Context(), principal))
+				}
+				handler(w, r)
+			}
+		}
+		NewColumnValuesHandler(schemaMutator, mockTopo, mockClient).Register(router.PathPrefix("/query").Subrouter(), withPrincipal)
+		testServer = httptest.NewServer(router)
+		utils.SetClockImplementation(func() time.Time {
+			return time.Unix(1549123300, 0)
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		testServer.Close()
+		utils.ResetClockImplementation()
+	})
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(testServer.URL + path)
+		Ω(err).Should(BeNil())
+		bs, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		Ω(err).Should(BeNil())
+		return resp.StatusCode, string(bs)
+	}
+
+	ginkgo.It("should merge counts of all shards", func() {
+		mockClient.On("ListColumnValues", mock.Anything, host1, mock.Anything, []uint32{0}).Return([]apiCom.ColumnValueCount{
+			{Value: "ACTIVE", Count: 3}, {Value: "COMPLETED", Count: 1},
+		}, nil).Once()
+		mockClient.On("ListColumnValues", mock.Anything, host2, mock.Anything, []uint32{1}).Return([]apiCom.ColumnValueCount{
+			{Value: "COMPLETED", Count: 4}, {Value: "ACTIVE", Count: 2}, {Value: "NULL", Count: 1},
+		}, nil).Once()
+
+		statusCode, body := get("/query/trips/columns/status/values?limit=2&timezone=America/New_York")
+		Ω(statusCode).Should(Equal(http.StatusOK))
+		Ω(body).Should(MatchJSON(`{
+			"values": [{"value": "ACTIVE", "count": 5}, {"value": "COMPLETED", "count": 5}],
+			"complete": false
+		}`))
+
+		// time range is resolved by broker.
+		request := mockClient.Calls[0].Arguments.Get(2).(apiCom.ColumnValuesRequest)
+		Ω(request.TableName).Should(Equal("trips"))
+		Ω(request.ColumnName).Should(Equal("status"))
+		Ω(request.Limit).Should(Equal(2))
+		Ω(request.From).ShouldNot(Equal(""))
+		Ω(request.From).ShouldNot(Equal("-1d"))
+		Ω(request.To).Should(Equal("1549123300"))
+		mockClient.AssertExpectations(ginkgo.GinkgoT())
+	})
+
+	ginkgo.It("should fail if counts of any shard are not available", func() {
+		mockClient.On("ListColumnValues", mock.Anything, host1, mock.Anything, []uint32{0}).Return([]apiCom.ColumnValueCount{
+			{Value: "ACTIVE", Count: 3},
+		}, nil).Once()
+		mockClient.On("ListColumnValues", mock.Anything, host2, mock.Anything, []uint32{1}).Return(nil, errors.New("failed to connect")).Once()
+
+		statusCode, _ := get("/query/trips/columns/status/values")
+		Ω(statusCode).Should(Equal(http.StatusInternalServerError))
+	})
+
+	ginkgo.It("should check read permission of the principal", func() {
+		principal = &utils.Principal{Name: "bob"}
+		statusCode, body := get("/query/trips/columns/status/values")
+		Ω(statusCode).Should(Equal(http.StatusForbidden))
+		Ω(body).Should(ContainSubstring("principal bob is not allowed to read column status of table trips"))
+
+		principal = &utils.Principal{Name: "alice", Groups: []string{"analysts"}}
+		statusCode, _ = get("/query/trips/columns/fare/values")
+		Ω(statusCode).Should(Equal(http.StatusForbidden))
+
+		statusCode, _ = get("/query/trips/columns/unknown/values")
+		Ω(statusCode).Should(Equal(http.StatusBadRequest))
+		statusCode, _ = get("/query/unknown/columns/status/values")
+		Ω(statusCode).Should(Equal(http.StatusBadRequest))
+		mockClient.AssertNotCalled(ginkgo.GinkgoT(), "ListColumnValues", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+})
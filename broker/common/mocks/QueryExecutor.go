@@ -1,6 +1,7 @@
 // Code generated by mockery v1.0.0
 package mocks
 
+import common "github.com/uber/aresdb/query/common"
 import context "context"
 import http "net/http"
 import mock "github.com/stretchr/testify/mock"
@@ -10,13 +11,13 @@ type QueryExecutor struct {
 	mock.Mock
 }
 
-// Execute provides a mock function with given fields: ctx, namespace, sqlQuery, w
-func (_m *QueryExecutor) Execute(ctx context.Context, namespace string, sqlQuery string, w http.ResponseWriter) error {
-	ret := _m.Called(ctx, namespace, sqlQuery, w)
+// Execute provides a mock function with given fields: ctx, requestID, aql, returnHLLBinary, w
+func (_m *QueryExecutor) Execute(ctx context.Context, requestID string, aql *common.AQLQuery, returnHLLBinary bool, w http.ResponseWriter) error {
+	ret := _m.Called(ctx, requestID, aql, returnHLLBinary, w)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string, http.ResponseWriter) error); ok {
-		r0 = rf(ctx, namespace, sqlQuery, w)
+	if rf, ok := ret.Get(0).(func(context.Context, string, *common.AQLQuery, bool, http.ResponseWriter) error); ok {
+		r0 = rf(ctx, requestID, aql, returnHLLBinary, w)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -0,0 +1,25 @@
+// Code generated by mockery v1.0.0
+package mocks
+
+import context "context"
+import http "net/http"
+import mock "github.com/stretchr/testify/mock"
+
+// QueryPlan is an autogenerated mock type for the QueryPlan type
+type QueryPlan struct {
+	mock.Mock
+}
+
+// Execute provides a mock function with given fields: ctx, w
+func (_m *QueryPlan) Execute(ctx context.Context, w http.ResponseWriter) error {
+	ret := _m.Called(ctx, w)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, http.ResponseWriter) error); ok {
+		r0 = rf(ctx, w)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -24,4 +24,124 @@ type BrokerConfig struct {
 
 	HTTP    common.HTTPConfig    `yaml:"http"`
 	Cluster common.ClusterConfig `yaml:"cluster"`
+	Query   QueryConfig          `yaml:"query"`
+	CTAS    CTASConfig           `yaml:"ctas"`
+
+	TempTable TempTableConfig `yaml:"temp_table"`
+
+	SlowQueryLog SlowQueryLogConfig   `yaml:"slow_query_log"`
+	Tracing      common.TracingConfig `yaml:"tracing"`
+	Auth         common.AuthConfig    `yaml:"auth"`
+	TLS          common.TLSConfig     `yaml:"tls"`
+
+	ResultSigning common.ResultSigningConfig `yaml:"result_signing"`
+}
+
+// QueryConfig is the static configuration for broker query execution.
+type QueryConfig struct {
+	// max number of distinct values of any dimension of an aggregation query while merging
+	// datanode results before it is aborted, 0 means no limit
+	MaxDimensionCardinality int `yaml:"max_dimension_cardinality"`
+	// timeout in milliseconds of each request sent to a datanode, timed out requests are
+	// retried within the scatter timeout
+	ShardTimeoutMillis int `yaml:"shard_timeout_millis"`
+	// timeout in milliseconds of waiting for all datanodes to respond
+	ScatterTimeoutMillis int `yaml:"scatter_timeout_millis"`
+	// timeout in milliseconds of merging datanode results of aggregation queries
+	MergeTimeoutMillis int `yaml:"merge_timeout_millis"`
+	// replicas dedicated to heavy export queries
+	AnalyticsReplicas AnalyticsReplicaConfig `yaml:"analytics_replicas"`
+	// splitting of aggregation queries spanning long time ranges
+	TimeRangeSplit TimeRangeSplitConfig `yaml:"time_range_split"`
+	// bounding of queries executed concurrently
+	AdmissionControl AdmissionControlConfig `yaml:"admission_control"`
+	// timeout in milliseconds of query executions shared by requests with the same idempotency
+	// token, which are not cancelled by the requests, 300000 if not set
+	IdempotentQueryTimeoutMillis int `yaml:"idempotent_query_timeout_millis"`
+	// daily query quotas per caller and per table, enforced against usage summed over all datanodes
+	Quota common.QueryQuotaConfig `yaml:"quota"`
+}
+
+type AnalyticsReplicaConfig struct {
+	// ids of datanode hosts serving heavy export queries, other queries are routed to them
+	// only for shards without other routable replicas
+	Hosts []string `yaml:"hosts"`
+	// tables whose heavy export queries are routed to analytics replicas, empty means all tables
+	Tables []string `yaml:"tables"`
+	// non aggregation queries with limit no less than this are heavy export queries in addition
+	// to those without limit, 0 means only queries without limit
+	MinLimit int `yaml:"min_limit"`
+}
+
+// TimeRangeSplitConfig is the configuration for splitting aggregation queries spanning long
+// time ranges into sub-queries of consecutive time range chunks, which are executed separately
+// and merged so that datanodes process less data at a time.
+type TimeRangeSplitConfig struct {
+	Enable bool `yaml:"enable"`
+	// queries spanning more days than this are split, 31 if not set
+	MinDays int `yaml:"min_days"`
+	// days of each time range chunk, 7 if not set
+	ChunkDays int `yaml:"chunk_days"`
+	// max number of chunks executed concurrently, 4 if not set
+	MaxParallelism int `yaml:"max_parallelism"`
+}
+
+// AdmissionControlConfig is the configuration for bounding the number of queries executed
+// concurrently by the broker, queries beyond the limit wait for running queries to finish.
+type AdmissionControlConfig struct {
+	// max number of queries executed concurrently, 0 means no limit
+	MaxConcurrentQueries int `yaml:"max_concurrent_queries"`
+	// max number of queries waiting for execution, queries beyond are rejected, 0 means no limit
+	MaxQueuedQueries int `yaml:"max_queued_queries"`
+	// timeout in milliseconds of waiting for execution, 0 means waiting until the request is canceled
+	QueueTimeoutMillis int `yaml:"queue_timeout_millis"`
+	// interval in seconds of reporting queue depth, active scatter rpcs and oldest in-flight
+	// query age, 10 if not set
+	MetricsIntervalSeconds int `yaml:"metrics_interval_seconds"`
+}
+
+// CTASConfig is the static configuration for loading query results into new tables.
+// TempTableConfig is the config for session scoped temporary result tables.
+type TempTableConfig struct {
+	// ttl in seconds of temporary tables created without ttl
+	DefaultTTLSeconds int `yaml:"default_ttl_seconds"`
+	// max ttl in seconds allowed for temporary tables
+	MaxTTLSeconds int `yaml:"max_ttl_seconds"`
+	// max number of temporary tables alive in one session
+	MaxTablesPerSession int `yaml:"max_tables_per_session"`
+}
+
+type CTASConfig struct {
+	// max number of rows in one upsert batch sent to datanodes
+	BatchSize int `yaml:"batch_size"`
+	// max time in seconds loading rows into datanodes, including waiting for datanodes
+	// to pick up the new table
+	LoadTimeoutSeconds int `yaml:"load_timeout_seconds"`
+	// interval in seconds between retries of sending an upsert batch
+	RetryIntervalSeconds int `yaml:"retry_interval_seconds"`
+}
+
+// SlowQueryLog sink types.
+const (
+	SlowQueryLogSinkLogger = "logger"
+	SlowQueryLogSinkFile   = "file"
+	SlowQueryLogSinkKafka  = "kafka"
+)
+
+// SlowQueryLogConfig is the configuration for logging slow queries for offline analysis.
+type SlowQueryLogConfig struct {
+	Enable bool `yaml:"enable"`
+	// queries taking longer than threshold will be logged
+	ThresholdMillis int `yaml:"threshold_millis"`
+	// portion of slow queries to log, between 0 and 1, 0 means logging all
+	SampleRate float64 `yaml:"sample_rate"`
+	// one of logger (default), file and kafka
+	Sink string `yaml:"sink"`
+	// file path for file sink
+	FilePath string `yaml:"file_path"`
+	// kafka brokers and topic for kafka sink
+	KafkaBrokers []string `yaml:"kafka_brokers"`
+	KafkaTopic   string   `yaml:"kafka_topic"`
+	// max number of entries buffered before written to sink, entries will be dropped when buffer is full
+	BufferSize int `yaml:"buffer_size"`
 }
@@ -0,0 +1,453 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/gorilla/mux"
+	apiCom "github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/broker/common"
+	"github.com/uber/aresdb/broker/config"
+	"github.com/uber/aresdb/client"
+	"github.com/uber/aresdb/cluster/topology"
+	mutatorCom "github.com/uber/aresdb/controller/mutators/common"
+	dataCli "github.com/uber/aresdb/datanode/client"
+	memCom "github.com/uber/aresdb/memstore/common"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/query/sql"
+	"github.com/uber/aresdb/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultCTASBatchSize          = 10000
+	defaultCTASLoadTimeoutSeconds = 300
+)
+
+// CTASRequest represents create table as select request. The table is created with the
+// given schema and loaded with results of the query, which is either an aggregation or
+// a non aggregation query. Non aggregation queries are limited to 1000 rows by default,
+// set limit to -1 to load all rows.
+// swagger:parameters createTableAsSelect
+type CTASRequest struct {
+	// in: header
+	Origin string `header:"Rpc-Caller,optional" json:"origin"`
+	// in: body
+	Body struct {
+		Table metaCom.Table `json:"table"`
+		// either aql or sql should be provided
+		AQL *queryCom.AQLQuery `json:"aql,omitempty"`
+		SQL string             `json:"sql,omitempty"`
+		// columns of the table to load each result column into, defaults to result headers
+		Columns []string `json:"columns,omitempty"`
+	} `body:""`
+}
+
+// CTASResponse represents the result of create table as select.
+// swagger:response createTableAsSelectResponse
+type CTASResponse struct {
+	Table string `json:"table"`
+	// number of rows returned by the query
+	NumRows int `json:"numRows"`
+	// number of rows loaded into the table, rows with invalid values are ignored
+	NumRowsLoaded int `json:"numRowsLoaded"`
+}
+
+// ctasQueryResult holds both column major aggregation results and non aggregation results.
+type ctasQueryResult struct {
+	Headers    []string        `json:"headers"`
+	Columns    [][]interface{} `json:"columns"`
+	MatrixData [][]interface{} `json:"matrixData"`
+}
+
+// CTASHandler creates tables from query results, so that derived datasets can be built
+// without external ETL. Rows are sharded the same way as ares-subscriber and sent to all
+// datanodes owning the shard, and archived into archive batches by datanodes afterwards.
+// Rows of SQL INSERT statements into dimension tables are loaded the same way.
+type CTASHandler struct {
+	exec          common.QueryExecutor
+	namespace     string
+	schemaMutator mutatorCom.TableSchemaMutator
+	enumMutator   mutatorCom.EnumMutator
+	topo          topology.Topology
+	client        dataCli.DataNodeIngestionClient
+	cfg           config.CTASConfig
+	logger        *zap.SugaredLogger
+}
+
+// NewCTASHandler creates a new CTASHandler.
+func NewCTASHandler(exec common.QueryExecutor, namespace string, schemaMutator mutatorCom.TableSchemaMutator,
+	enumMutator mutatorCom.EnumMutator, topo topology.Topology, client dataCli.DataNodeIngestionClient,
+	cfg config.CTASConfig, logger *zap.SugaredLogger) *CTASHandler {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultCTASBatchSize
+	}
+	if cfg.LoadTimeoutSeconds <= 0 {
+		cfg.LoadTimeoutSeconds = defaultCTASLoadTimeoutSeconds
+	}
+	return &CTASHandler{
+		exec:          exec,
+		namespace:     namespace,
+		schemaMutator: schemaMutator,
+		enumMutator:   enumMutator,
+		topo:          topo,
+		client:        client,
+		cfg:           cfg,
+		logger:        logger,
+	}
+}
+
+// Register registers http handlers.
+func (handler *CTASHandler) Register(router *mux.Router, wrappers ...utils.HTTPHandlerWrapper) {
+	router.HandleFunc("/ctas", utils.ApplyHTTPWrappers(handler.HandleCTAS, wrappers)).Methods(http.MethodPost)
+	router.HandleFunc("/sql/insert", utils.ApplyHTTPWrappers(handler.HandleSQLInsert, wrappers)).Methods(http.MethodPost)
+}
+
+// HandleCTAS swagger:route POST /ctas createTableAsSelect
+// creates a table and loads results of the query into it
+//
+// Consumes:
+//    - application/json
+//
+// Produces:
+//    - application/json
+//
+// Responses:
+//    default: errorResponse
+//        200: createTableAsSelectResponse
+func (handler *CTASHandler) HandleCTAS(w http.ResponseWriter, r *http.Request) {
+	var ctasRequest CTASRequest
+	var err error
+	ctx, span := utils.StartSpan(utils.WithAuthenticationFrom(context.Background(), r.Context()), "broker.HandleCTAS")
+	defer func() {
+		utils.EndSpan(span, err)
+		if err != nil {
+			utils.GetRootReporter().GetCounter(utils.CTASFailedBroker).Inc(1)
+			utils.GetLogger().With(
+				"error", err,
+				"request", ctasRequest).Error("Error happened when processing create table as select request")
+		} else {
+			utils.GetRootReporter().GetCounter(utils.CTASSucceededBroker).Inc(1)
+		}
+	}()
+
+	err = apiCom.ReadRequest(r, &ctasRequest)
+	if err != nil {
+		apiCom.RespondWithError(w, err)
+		return
+	}
+
+	var aql *queryCom.AQLQuery
+	if aql, err = handler.getQuery(ctasRequest); err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	var headers []string
+	var rows []client.Row
+	if headers, rows, err = executeQueryForRows(queryCom.WithCaller(ctx, ctasRequest.Origin), handler.exec, "ctas", aql); err != nil {
+		apiCom.RespondWithError(w, err)
+		return
+	}
+
+	columnNames := ctasRequest.Body.Columns
+	if len(columnNames) == 0 {
+		columnNames = headers
+	}
+	if len(columnNames) != len(headers) {
+		err = utils.StackError(nil, "query returns %d columns but %d columns are specified", len(headers), len(columnNames))
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	// query is executed before creating the table, so that failed queries do not leave empty tables.
+	table := &ctasRequest.Body.Table
+	if err = handler.schemaMutator.CreateTable(handler.namespace, table, false); err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+	if table, err = handler.schemaMutator.GetTable(handler.namespace, table.Name); err != nil {
+		apiCom.RespondWithError(w, err)
+		return
+	}
+
+	response := CTASResponse{
+		Table:   table.Name,
+		NumRows: len(rows),
+	}
+	response.NumRowsLoaded, err = handler.load(ctx, table, columnNames, rows)
+	utils.GetRootReporter().GetCounter(utils.CTASRowsLoadedBroker).Inc(int64(response.NumRowsLoaded))
+	if err != nil {
+		err = utils.StackError(err, "table %s is created but only %d of %d rows are loaded", table.Name, response.NumRowsLoaded, response.NumRows)
+		apiCom.RespondWithError(w, err)
+		return
+	}
+	apiCom.RespondWithJSONObject(w, response)
+}
+
+// getQuery returns the aql query of the request, parsing sql if provided.
+func (handler *CTASHandler) getQuery(ctasRequest CTASRequest) (aql *queryCom.AQLQuery, err error) {
+	if ctasRequest.Body.SQL != "" {
+		return sql.Parse(ctasRequest.Body.SQL, utils.GetLogger())
+	}
+	if ctasRequest.Body.AQL == nil {
+		return nil, utils.StackError(nil, "either aql or sql is required")
+	}
+	return ctasRequest.Body.AQL, nil
+}
+
+// executeQueryForRows runs the query and returns the result as rows, null values are
+// returned as nil.
+func executeQueryForRows(ctx context.Context, exec common.QueryExecutor, requestID string, aql *queryCom.AQLQuery) (headers []string, rows []client.Row, err error) {
+	// aggregation results are returned as rows only in column major format.
+	aql.ResultFormat = queryCom.ResultFormatColumnMajor
+	response := newBufferedResponseWriter()
+	if err = exec.Execute(ctx, requestID, aql, false, response); err != nil {
+		return
+	}
+
+	var result ctasQueryResult
+	if err = json.Unmarshal(response.body.Bytes(), &result); err != nil {
+		err = utils.StackError(err, "failed to read query result")
+		return
+	}
+
+	headers = result.Headers
+	if result.Columns != nil {
+		numRows := 0
+		if len(result.Columns) > 0 {
+			numRows = len(result.Columns[0])
+		}
+		rows = make([]client.Row, numRows)
+		for i := range rows {
+			rows[i] = make(client.Row, len(result.Columns))
+			for j, column := range result.Columns {
+				rows[i][j] = column[i]
+			}
+		}
+	} else {
+		rows = make([]client.Row, len(result.MatrixData))
+		for i, row := range result.MatrixData {
+			rows[i] = row
+		}
+	}
+
+	for _, row := range rows {
+		if len(row) != len(headers) {
+			err = utils.StackError(nil, "query result row has %d columns but %d headers", len(row), len(headers))
+			return
+		}
+		for i, value := range row {
+			if value == queryCom.NULLString {
+				row[i] = nil
+			}
+		}
+	}
+	return
+}
+
+// load shards rows by primary key and sends them to all datanodes owning the shard.
+// Returns number of rows loaded.
+func (handler *CTASHandler) load(ctx context.Context, table *metaCom.Table, columnNames []string, rows []client.Row) (numRowsLoaded int, err error) {
+	ctx, cancelFn := context.WithTimeout(ctx, time.Duration(handler.cfg.LoadTimeoutSeconds)*time.Second)
+	defer cancelFn()
+
+	m := handler.topo.Get()
+	numShards := uint32(len(m.ShardSet().AllIDs()))
+	if numShards == 0 {
+		return 0, utils.StackError(nil, "no shards in topology")
+	}
+
+	// dimension tables are not sharded but replicated to all datanodes as shard 0.
+	shards := map[uint32][]client.Row{0: rows}
+	if table.IsFactTable {
+		if shards, err = shardCTASRows(table, columnNames, rows, numShards); err != nil {
+			return
+		}
+	}
+
+	// enum cases are extended through the schema mutators, a new schema handler is used
+	// for each request as table names can be reused after deletion.
+	schemaHandler := client.NewCachedSchemaHandler(handler.logger, utils.GetRootReporter().GetRootScope(),
+		&ctasSchemaFetcher{
+			namespace:     handler.namespace,
+			schemaMutator: handler.schemaMutator,
+			enumMutator:   handler.enumMutator,
+		})
+	upsertBatchBuilder := client.NewUpsertBatchBuilderImpl(handler.logger, utils.GetRootReporter().GetRootScope(), schemaHandler)
+	updateModes := make([]memCom.ColumnUpdateMode, len(columnNames))
+
+	for shardID, shardRows := range shards {
+		hosts := m.Hosts()
+		if table.IsFactTable {
+			if hosts, err = m.RouteShard(shardID); err != nil {
+				return
+			}
+		}
+		for start := 0; start < len(shardRows); start += handler.cfg.BatchSize {
+			end := start + handler.cfg.BatchSize
+			if end > len(shardRows) {
+				end = len(shardRows)
+			}
+			var upsertBatch []byte
+			var numRows int
+			upsertBatch, numRows, err = upsertBatchBuilder.PrepareUpsertBatch(table.Name, columnNames, updateModes, shardRows[start:end])
+			if err != nil {
+				return
+			}
+			for _, host := range hosts {
+				if err = handler.ingest(ctx, host, table.Name, shardID, upsertBatch); err != nil {
+					return
+				}
+			}
+			numRowsLoaded += numRows
+		}
+	}
+	return
+}
+
+// ingest sends the upsert batch to the host, retrying until ctx is done since datanodes
+// reject rows until they pick up the new table.
+func (handler *CTASHandler) ingest(ctx context.Context, host topology.Host, table string, shard uint32, upsertBatch []byte) error {
+	for {
+		err := handler.client.Ingest(ctx, host, table, shard, upsertBatch)
+		if err == nil {
+			return nil
+		}
+		handler.logger.With("host", host.ID(), "table", table, "shard", shard, "error", err).Warn("failed to load rows, will retry")
+		select {
+		case <-ctx.Done():
+			return utils.StackError(err, "failed to load shard %d of table %s into host %s", shard, table, host.ID())
+		case <-time.After(time.Duration(handler.cfg.RetryIntervalSeconds) * time.Second):
+		}
+	}
+}
+
+// shardCTASRows shards rows by primary key the same way as ares-subscriber, so that later
+// upserts to the table go to the same shard. Rows with null primary key are dropped.
+func shardCTASRows(table *metaCom.Table, columnNames []string, rows []client.Row, numShards uint32) (map[uint32][]client.Row, error) {
+	if numShards == 1 {
+		return map[uint32][]client.Row{0: rows}, nil
+	}
+
+	columnIndexes := make(map[string]int, len(columnNames))
+	for i, columnName := range columnNames {
+		columnIndexes[columnName] = i
+	}
+	primaryKeyIndexes := make([]int, len(table.PrimaryKeyColumns))
+	for i, columnID := range table.PrimaryKeyColumns {
+		columnName := table.Columns[columnID].Name
+		index, ok := columnIndexes[columnName]
+		if !ok {
+			return nil, utils.StackError(nil, "primary key column %s is not loaded", columnName)
+		}
+		primaryKeyIndexes[i] = index
+	}
+
+	shards := make(map[uint32][]client.Row)
+	for _, row := range rows {
+		key, err := getCTASPrimaryKeyBytes(table, primaryKeyIndexes, row)
+		if err != nil || len(key) == 0 {
+			continue
+		}
+		shardID := utils.Murmur3Sum32(unsafe.Pointer(&key[0]), len(key), 0) / (math.MaxUint32 / numShards)
+		shards[shardID] = append(shards[shardID], row)
+	}
+	return shards, nil
+}
+
+// getCTASPrimaryKeyBytes returns primary key bytes of the row, enum values are appended
+// as strings after other primary key values.
+func getCTASPrimaryKeyBytes(table *metaCom.Table, primaryKeyIndexes []int, row client.Row) ([]byte, error) {
+	primaryKeyValues := make([]memCom.DataValue, 0, len(primaryKeyIndexes))
+	var strBytes []byte
+	for i, index := range primaryKeyIndexes {
+		columnID := table.PrimaryKeyColumns[i]
+		column := table.Columns[columnID]
+		value := row[index]
+		if value == nil {
+			return nil, utils.StackError(nil, "primary key column %s is null", column.Name)
+		}
+		if column.IsEnumColumn() {
+			str, ok := value.(string)
+			if !ok {
+				return nil, utils.StackError(nil, "invalid enum value %v for column %s", value, column.Name)
+			}
+			str, _ = column.EnumNormalization.Normalize(str)
+			if !column.CaseInsensitive {
+				str = strings.ToLower(str)
+			}
+			strBytes = append(strBytes, []byte(str)...)
+			continue
+		}
+		dataValue, err := memCom.GetDataValue(value, columnID, column.Type)
+		if err != nil {
+			return nil, err
+		}
+		primaryKeyValues = append(primaryKeyValues, dataValue)
+	}
+
+	key, err := memCom.GetPrimaryKeyBytes(primaryKeyValues, 0)
+	if err != nil {
+		return nil, err
+	}
+	return append(key, strBytes...), nil
+}
+
+// ctasSchemaFetcher implements client.SchemaFetcher through schema mutators of the namespace.
+type ctasSchemaFetcher struct {
+	namespace     string
+	schemaMutator mutatorCom.TableSchemaMutator
+	enumMutator   mutatorCom.EnumMutator
+}
+
+func (f *ctasSchemaFetcher) FetchAllSchemas() ([]*metaCom.Table, error) {
+	tableNames, err := f.schemaMutator.ListTables(f.namespace)
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]*metaCom.Table, 0, len(tableNames))
+	for _, tableName := range tableNames {
+		table, err := f.schemaMutator.GetTable(f.namespace, tableName)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+func (f *ctasSchemaFetcher) FetchSchema(table string) (*metaCom.Table, error) {
+	return f.schemaMutator.GetTable(f.namespace, table)
+}
+
+func (f *ctasSchemaFetcher) FetchAllEnums(tableName string, columnName string) ([]string, error) {
+	return f.enumMutator.GetEnumCases(f.namespace, tableName, columnName)
+}
+
+func (f *ctasSchemaFetcher) ExtendEnumCases(tableName, columnName string, enumCases []string) ([]int, error) {
+	if len(enumCases) == 0 {
+		return nil, nil
+	}
+	return f.enumMutator.ExtendEnumCases(f.namespace, tableName, columnName, enumCases)
+}
@@ -0,0 +1,203 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gorilla/mux"
+	m3Shard "github.com/m3db/m3/src/cluster/shard"
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	"github.com/uber/aresdb/broker/common/mocks"
+	"github.com/uber/aresdb/broker/config"
+	"github.com/uber/aresdb/cluster/shard"
+	"github.com/uber/aresdb/cluster/topology"
+	topoMock "github.com/uber/aresdb/cluster/topology/mocks"
+	mutatorMocks "github.com/uber/aresdb/controller/mutators/mocks"
+	dataCliMocks "github.com/uber/aresdb/datanode/client/mocks"
+	memCom "github.com/uber/aresdb/memstore/common"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	queryCom "github.com/uber/aresdb/query/common"
+	"go.uber.org/zap"
+)
+
+var _ = ginkgo.Describe("create table as select", func() {
+	var (
+		testServer    *httptest.Server
+		mockExec      *mocks.QueryExecutor
+		schemaMutator *mutatorMocks.TableSchemaMutator
+		enumMutator   *mutatorMocks.EnumMutator
+		ingestClient  *dataCliMocks.DataNodeIngestionClient
+		host          topology.Host
+	)
+
+	table := metaCom.Table{
+		Name: "t2",
+		Columns: []metaCom.Column{
+			{Name: "time", Type: metaCom.Uint32},
+			{Name: "city", Type: metaCom.SmallEnum},
+			{Name: "count", Type: metaCom.Uint32},
+		},
+		PrimaryKeyColumns: []int{1},
+		IsFactTable:       true,
+	}
+
+	ginkgo.BeforeEach(func() {
+		host = topology.NewHost("h1", "h1:9374")
+		topoMap := topology.NewStaticMap(topology.NewStaticOptions().
+			SetShardSet(shard.NewShardSet(shard.NewShards([]uint32{0}, m3Shard.Available))).
+			SetReplicas(1).
+			SetHostShardSets([]topology.HostShardSet{
+				topology.NewHostShardSet(host, shard.NewShardSet(shard.NewShards([]uint32{0}, m3Shard.Available))),
+			}))
+		mockTopo := &topoMock.HealthTrackingDynamicTopoloy{}
+		mockTopo.On("Get").Return(topoMap)
+
+		mockExec = &mocks.QueryExecutor{}
+		schemaMutator = &mutatorMocks.TableSchemaMutator{}
+		enumMutator = &mutatorMocks.EnumMutator{}
+		ingestClient = &dataCliMocks.DataNodeIngestionClient{}
+
+		router := mux.NewRouter()
+		NewCTASHandler(mockExec, "ns1", schemaMutator, enumMutator, mockTopo, ingestClient, config.CTASConfig{}, zap.NewNop().Sugar()).Register(router)
+		testServer = httptest.NewServer(router)
+	})
+
+	ginkgo.AfterEach(func() {
+		testServer.Close()
+	})
+
+	postCTAS := func() *http.Response {
+		body, _ := json.Marshal(map[string]interface{}{
+			"table": table,
+			"aql": queryCom.AQLQuery{
+				Table:      "t1",
+				Dimensions: []queryCom.Dimension{{Expr: "request_at", TimeBucketizer: "day"}, {Expr: "city"}},
+				Measures:   []queryCom.Measure{{Expr: "count(*)"}},
+			},
+			"columns": []string{"time", "city", "count"},
+		})
+		resp, err := http.Post(testServer.URL+"/ctas", "application/json", bytes.NewReader(body))
+		Ω(err).Should(BeNil())
+		return resp
+	}
+
+	ginkgo.It("should create table and load query results", func() {
+		mockExec.On("Execute", mock.Anything, mock.Anything, mock.Anything, false, mock.Anything).
+			Run(func(args mock.Arguments) {
+				Ω(args.Get(2).(*queryCom.AQLQuery).ResultFormat).Should(Equal(queryCom.ResultFormatColumnMajor))
+				args.Get(4).(http.ResponseWriter).Write([]byte(`{"headers":["request_at","city","count"],"columns":[["1570000000","1570000000"],["sf","NULL"],[3,2]]}`))
+			}).Return(nil).Once()
+		schemaMutator.On("CreateTable", "ns1", mock.Anything, false).Return(nil).Once()
+		schemaMutator.On("GetTable", "ns1", "t2").Return(&table, nil)
+		enumMutator.On("GetEnumCases", "ns1", "t2", "city").Return([]string{}, nil).Once()
+		enumMutator.On("ExtendEnumCases", "ns1", "t2", "city", []string{"sf"}).Return([]int{0}, nil).Once()
+
+		var upsertBatch []byte
+		// datanode rejects rows before picking up the new table.
+		ingestClient.On("Ingest", mock.Anything, host, "t2", uint32(0), mock.Anything).
+			Return(errors.New("table not found")).Once()
+		ingestClient.On("Ingest", mock.Anything, host, "t2", uint32(0), mock.Anything).
+			Run(func(args mock.Arguments) {
+				upsertBatch = args.Get(4).([]byte)
+			}).Return(nil).Once()
+
+		resp := postCTAS()
+		bs, _ := ioutil.ReadAll(resp.Body)
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK), string(bs))
+		var response CTASResponse
+		Ω(json.Unmarshal(bs, &response)).Should(BeNil())
+		Ω(response).Should(Equal(CTASResponse{
+			Table:         "t2",
+			NumRows:       2,
+			NumRowsLoaded: 1,
+		}))
+
+		batch, err := memCom.NewUpsertBatch(upsertBatch)
+		Ω(err).Should(BeNil())
+		Ω(batch.NumRows).Should(Equal(1))
+		ingestClient.AssertExpectations(ginkgo.GinkgoT())
+		enumMutator.AssertExpectations(ginkgo.GinkgoT())
+	})
+
+	ginkgo.It("should not create table if query fails", func() {
+		mockExec.On("Execute", mock.Anything, mock.Anything, mock.Anything, false, mock.Anything).
+			Return(errors.New("query failed")).Once()
+
+		resp := postCTAS()
+		Ω(resp.StatusCode).Should(Equal(http.StatusInternalServerError))
+		schemaMutator.AssertNotCalled(ginkgo.GinkgoT(), "CreateTable", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	ginkgo.It("should upsert rows of sql insert into dimension tables", func() {
+		dimTable := metaCom.Table{
+			Name: "cities",
+			Columns: []metaCom.Column{
+				{Name: "id", Type: metaCom.Uint32},
+				{Name: "removed", Type: metaCom.Bool, Deleted: true},
+				{Name: "name", Type: metaCom.SmallEnum},
+			},
+			PrimaryKeyColumns: []int{0},
+		}
+		schemaMutator.On("GetTable", "ns1", "cities").Return(&dimTable, nil)
+		schemaMutator.On("GetTable", "ns1", "t2").Return(&table, nil)
+		enumMutator.On("GetEnumCases", "ns1", "cities", "name").Return([]string{}, nil).Once()
+		enumMutator.On("ExtendEnumCases", "ns1", "cities", "name", []string{"sf", "la"}).Return([]int{0, 1}, nil).Once()
+
+		var upsertBatch []byte
+		ingestClient.On("Ingest", mock.Anything, host, "cities", uint32(0), mock.Anything).
+			Run(func(args mock.Arguments) {
+				upsertBatch = args.Get(4).([]byte)
+			}).Return(nil).Once()
+
+		postInsert := func(sql string) (int, []byte) {
+			body, _ := json.Marshal(map[string]interface{}{"sql": sql})
+			resp, err := http.Post(testServer.URL+"/sql/insert", "application/json", bytes.NewReader(body))
+			Ω(err).Should(BeNil())
+			bs, _ := ioutil.ReadAll(resp.Body)
+			return resp.StatusCode, bs
+		}
+
+		code, bs := postInsert("INSERT INTO cities VALUES (1, 'sf'), (2, 'la')")
+		Ω(code).Should(Equal(http.StatusOK), string(bs))
+		var response SQLInsertResponse
+		Ω(json.Unmarshal(bs, &response)).Should(BeNil())
+		Ω(response).Should(Equal(SQLInsertResponse{
+			Table:         "cities",
+			NumRows:       2,
+			NumRowsLoaded: 2,
+		}))
+		batch, err := memCom.NewUpsertBatch(upsertBatch)
+		Ω(err).Should(BeNil())
+		Ω(batch.NumRows).Should(Equal(2))
+		Ω(batch.NumColumns).Should(Equal(2))
+
+		code, _ = postInsert("INSERT INTO t2 VALUES (1, 'sf', 1)")
+		Ω(code).Should(Equal(http.StatusBadRequest))
+		code, _ = postInsert("INSERT INTO cities (id, removed) VALUES (1, 1)")
+		Ω(code).Should(Equal(http.StatusBadRequest))
+		code, _ = postInsert("INSERT INTO cities VALUES (1, 'sf', 1)")
+		Ω(code).Should(Equal(http.StatusBadRequest))
+		ingestClient.AssertExpectations(ginkgo.GinkgoT())
+		enumMutator.AssertExpectations(ginkgo.GinkgoT())
+	})
+})
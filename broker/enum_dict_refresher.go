@@ -0,0 +1,114 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	mutatorCom "github.com/uber/aresdb/controller/mutators/common"
+	memCom "github.com/uber/aresdb/memstore/common"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// min interval between re-fetches of the enum dictionary of the same column, so that queries of
+// enum values that do not exist do not overload the enum store.
+const minEnumDictRefreshInterval = time.Second
+
+// enumColumn identifies an enum column of a table.
+type enumColumn struct {
+	table  string
+	column string
+}
+
+// EnumDictRefresher re-fetches enum dictionaries cached by broker when queries reference enum
+// values missing from them, so that enum values added since the last periodical enum fetch are
+// immediately queryable.
+type EnumDictRefresher struct {
+	sync.Mutex
+
+	namespace    string
+	enumReader   mutatorCom.EnumReader
+	enumUpdater  memCom.EnumUpdater
+	schemaReader memCom.TableSchemaReader
+
+	lastRefreshed map[enumColumn]time.Time
+}
+
+// NewEnumDictRefresher creates a new EnumDictRefresher.
+func NewEnumDictRefresher(namespace string, enumReader mutatorCom.EnumReader, enumUpdater memCom.EnumUpdater,
+	schemaReader memCom.TableSchemaReader) *EnumDictRefresher {
+	return &EnumDictRefresher{
+		namespace:     namespace,
+		enumReader:    enumReader,
+		enumUpdater:   enumUpdater,
+		schemaReader:  schemaReader,
+		lastRefreshed: make(map[enumColumn]time.Time),
+	}
+}
+
+// refresh re-fetches the enum dictionaries of the columns and returns the columns with new enum
+// cases found.
+func (r *EnumDictRefresher) refresh(columns []enumColumn) (refreshed []enumColumn) {
+	now := utils.Now()
+	for _, column := range columns {
+		r.Lock()
+		last, exists := r.lastRefreshed[column]
+		if exists && now.Sub(last) < minEnumDictRefreshInterval {
+			r.Unlock()
+			continue
+		}
+		r.lastRefreshed[column] = now
+		r.Unlock()
+
+		enumCases, err := r.enumReader.GetEnumCases(r.namespace, column.table, column.column)
+		if err != nil {
+			utils.GetLogger().With("table", column.table, "column", column.column, "error", err).
+				Warn("failed to re-fetch stale enum dict")
+			continue
+		}
+		if len(enumCases) <= r.numEnumCases(column) {
+			continue
+		}
+		if err = r.enumUpdater.UpdateEnum(column.table, column.column, enumCases); err != nil {
+			utils.GetLogger().With("table", column.table, "column", column.column, "error", err).
+				Warn("failed to update stale enum dict")
+			continue
+		}
+		refreshed = append(refreshed, column)
+	}
+	utils.GetRootReporter().GetCounter(utils.StaleEnumDictRefreshedBroker).Inc(int64(len(refreshed)))
+	return
+}
+
+// numEnumCases returns the number of enum cases of the cached dictionary of the column.
+func (r *EnumDictRefresher) numEnumCases(column enumColumn) int {
+	schema, err := r.schemaReader.GetSchema(column.table)
+	if err != nil {
+		return 0
+	}
+	schema.RLock()
+	defer schema.RUnlock()
+	return len(schema.EnumDicts[column.column].ReverseDict)
+}
+
+// setStaleEnumRetryHeader lists the refreshed enum columns in response header.
+func setStaleEnumRetryHeader(w http.ResponseWriter, columns []enumColumn) {
+	for _, column := range columns {
+		w.Header().Add(queryCom.StaleEnumRetryHeaderKey, column.table+"."+column.column)
+	}
+}
@@ -0,0 +1,108 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"errors"
+	"net/http/httptest"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	mutatorMocks "github.com/uber/aresdb/controller/mutators/mocks"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
+)
+
+var _ = ginkgo.Describe("enum dict refresher", func() {
+	var schemaMutator *BrokerSchemaMutator
+	var enumReader *mutatorMocks.EnumReader
+	var refresher *EnumDictRefresher
+	city := enumColumn{table: "trips", column: "city"}
+
+	ginkgo.BeforeEach(func() {
+		schemaMutator = NewBrokerSchemaMutator()
+		schemaMutator.CreateTable(&metaCom.Table{
+			Name: "trips",
+			Columns: []metaCom.Column{
+				{Name: "request_at", Type: "Uint32"},
+				{Name: "city", Type: "SmallEnum"},
+			},
+		})
+		schemaMutator.UpdateEnum("trips", "city", []string{"sf"})
+		enumReader = &mutatorMocks.EnumReader{}
+		refresher = NewEnumDictRefresher("ns", enumReader, schemaMutator, schemaMutator)
+		utils.SetClockImplementation(func() time.Time {
+			return time.Unix(100, 0)
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		utils.ResetClockImplementation()
+	})
+
+	compile := func(filter string) *QueryContext {
+		qc := NewQueryContext(&queryCom.AQLQuery{
+			Table:            "trips",
+			Measures:         []queryCom.Measure{{Expr: "count(*)"}},
+			Filters:          []string{filter},
+			UnknownEnumValue: queryCom.UnknownEnumValueFail,
+		}, false, httptest.NewRecorder())
+		qc.Compile(schemaMutator)
+		return qc
+	}
+
+	ginkgo.It("should record enum columns missing enum values", func() {
+		qc := compile("city = 'sf'")
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.UnknownEnumColumns).Should(BeEmpty())
+
+		qc = compile("city = 'nyc' or city = 'la'")
+		Ω(qc.Error).ShouldNot(BeNil())
+		Ω(qc.UnknownEnumColumns).Should(Equal([]enumColumn{city}))
+	})
+
+	ginkgo.It("should re-fetch stale enum dicts", func() {
+		enumReader.On("GetEnumCases", "ns", "trips", "city").Return([]string{"sf", "nyc"}, nil).Once()
+		Ω(refresher.refresh([]enumColumn{city})).Should(Equal([]enumColumn{city}))
+		qc := compile("city = 'nyc'")
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.UnknownEnumColumns).Should(BeEmpty())
+
+		// throttled.
+		Ω(refresher.refresh([]enumColumn{city})).Should(BeEmpty())
+
+		// no new enum cases.
+		utils.SetClockImplementation(func() time.Time {
+			return time.Unix(200, 0)
+		})
+		enumReader.On("GetEnumCases", "ns", "trips", "city").Return([]string{"sf", "nyc"}, nil).Once()
+		Ω(refresher.refresh([]enumColumn{city})).Should(BeEmpty())
+
+		utils.SetClockImplementation(func() time.Time {
+			return time.Unix(300, 0)
+		})
+		enumReader.On("GetEnumCases", "ns", "trips", "city").Return(nil, errors.New("etcd error")).Once()
+		Ω(refresher.refresh([]enumColumn{city})).Should(BeEmpty())
+		enumReader.AssertExpectations(ginkgo.GinkgoT())
+	})
+
+	ginkgo.It("should list refreshed columns in response header", func() {
+		w := httptest.NewRecorder()
+		setStaleEnumRetryHeader(w, []enumColumn{city})
+		Ω(w.Header()[queryCom.StaleEnumRetryHeaderKey]).Should(Equal([]string{"trips.city"}))
+	})
+})
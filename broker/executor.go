@@ -16,11 +16,14 @@ package broker
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/uber/aresdb/broker/common"
+	"github.com/uber/aresdb/broker/config"
 	"github.com/uber/aresdb/cluster/topology"
 	dataCli "github.com/uber/aresdb/datanode/client"
 	memCom "github.com/uber/aresdb/memstore/common"
 	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
 	"net/http"
 	"time"
 )
@@ -30,11 +33,20 @@ const (
 )
 
 // NewQueryExecutor creates a new QueryExecutor
-func NewQueryExecutor(tsr memCom.TableSchemaReader, topo topology.HealthTrackingDynamicTopoloy, client dataCli.DataNodeQueryClient) common.QueryExecutor {
+// enumRefresher re-fetches stale enum dicts of queries referencing missing enum values, nil to disable.
+// quotaManager accounts usage of queries and rejects queries exceeding daily quotas, nil to disable.
+func NewQueryExecutor(tsr memCom.TableSchemaReader, topo topology.HealthTrackingDynamicTopoloy, client dataCli.DataNodeQueryClient, cfg config.QueryConfig,
+	enumRefresher *EnumDictRefresher, quotaManager *QuotaManager) common.QueryExecutor {
 	return &queryExecutorImpl{
 		tableSchemaReader: tsr,
+		enumRefresher:     enumRefresher,
+		quotaManager:      quotaManager,
 		topo:              topo,
 		dataNodeClient:    client,
+		cfg:               cfg,
+		timeouts:          NewQueryTimeouts(cfg),
+		replicaRouter:     newReplicaRouter(cfg.AnalyticsReplicas),
+		timeRangeSplitter: newTimeRangeSplitter(cfg.TimeRangeSplit),
 	}
 }
 
@@ -43,6 +55,12 @@ type queryExecutorImpl struct {
 	tableSchemaReader memCom.TableSchemaReader
 	topo              topology.HealthTrackingDynamicTopoloy
 	dataNodeClient    dataCli.DataNodeQueryClient
+	cfg               config.QueryConfig
+	timeouts          QueryTimeouts
+	replicaRouter     replicaRouter
+	timeRangeSplitter timeRangeSplitter
+	enumRefresher     *EnumDictRefresher
+	quotaManager      *QuotaManager
 }
 
 func (qe *queryExecutorImpl) Execute(ctx context.Context, requestID string, aql *queryCom.AQLQuery, returnHLLBinary bool, w http.ResponseWriter) (err error) {
@@ -50,13 +68,42 @@ func (qe *queryExecutorImpl) Execute(ctx context.Context, requestID string, aql
 	ctx, cancelFn = context.WithTimeout(ctx, time.Duration(executorTimeoutSeconds)*time.Second)
 	defer cancelFn()
 
+	caller := quotaCaller(ctx)
+	if qe.quotaManager != nil {
+		if err = qe.quotaManager.Check(caller, aql.Table); err != nil {
+			return
+		}
+	}
+
 	// compile
-	qc := NewQueryContext(aql, returnHLLBinary, w)
-	qc.Compile(qe.tableSchemaReader)
+	compileStart := utils.Now()
+	var original []byte
+	if qe.enumRefresher != nil {
+		// compiling mutates the query, keep the original to compile again after enum refresh.
+		original, _ = json.Marshal(aql)
+	}
+	qc := qe.compile(ctx, aql, returnHLLBinary, w)
+	if len(qc.UnknownEnumColumns) > 0 && qe.enumRefresher != nil && original != nil {
+		if refreshed := qe.enumRefresher.refresh(qc.UnknownEnumColumns); len(refreshed) > 0 {
+			*aql = queryCom.AQLQuery{}
+			if err = json.Unmarshal(original, aql); err != nil {
+				return
+			}
+			qc = qe.compile(ctx, aql, returnHLLBinary, w)
+			setStaleEnumRetryHeader(w, refreshed)
+		}
+	}
 	if qc.Error != nil {
 		err = qc.Error
 		return
 	}
+	queryProfileFromContext(ctx).setCompile(utils.Now().Sub(compileStart), qc.GetRewrittenQuery())
+	queryCom.SetQueryWarningsHeader(w, qc.Warnings)
+	qc.PreferredHosts = qe.replicaRouter.preferredHosts(qc)
+	if qc.TimeRangeChunks = qe.timeRangeSplitter.split(qc, utils.Now()); qc.TimeRangeChunks != nil {
+		qc.TimeRangeParallelism = qe.timeRangeSplitter.maxParallelism
+		utils.GetRootReporter().GetCounter(utils.QuerySplitByTimeRangeBroker).Inc(1)
+	}
 
 	// execute
 	var queryPlan common.QueryPlan
@@ -69,5 +116,61 @@ func (qe *queryExecutorImpl) Execute(ctx context.Context, requestID string, aql
 		return
 	}
 
-	return queryPlan.Execute(ctx, w)
+	// collect data freshness from datanode responses for response metadata
+	ctx = queryCom.WithDataFreshnessCollector(ctx, queryCom.NewDataFreshnessCollector())
+	scanStatsCollector := &queryCom.ScanStatsCollector{}
+	ctx = queryCom.WithScanStatsCollector(ctx, scanStatsCollector)
+	err = queryPlan.Execute(ctx, w)
+	scanStats := scanStatsCollector.Result()
+	reportScanStats(aql.Table, scanStats)
+	if qe.quotaManager != nil {
+		qe.quotaManager.Record(caller, aql.Table, QueryUsage{
+			Queries:      1,
+			ScannedBytes: scanStats.BytesScanned,
+			GPUSeconds:   scanStats.DeviceSeconds,
+		})
+	}
+	return
+}
+
+func (qe *queryExecutorImpl) compile(ctx context.Context, aql *queryCom.AQLQuery, returnHLLBinary bool, w http.ResponseWriter) *QueryContext {
+	_, compileSpan := utils.StartSpan(ctx, "broker.Compile")
+	qc := NewQueryContext(aql, returnHLLBinary, w)
+	qc.MaxDimensionCardinality = qe.cfg.MaxDimensionCardinality
+	qc.Timeouts = qe.timeouts.Override(queryTimeoutOverridesFromContext(ctx))
+	qc.Principal = utils.PrincipalFromContext(ctx)
+	qc.Compile(qe.tableSchemaReader)
+	utils.EndSpan(compileSpan, qc.Error)
+	return qc
+}
+
+// reportScanStats reports the scan stats of a query collected from datanode responses, so that
+// the effectiveness of shard and batch pruning can be monitored.
+func reportScanStats(table string, stats queryCom.ScanStats) {
+	tags := map[string]string{
+		"table": table,
+	}
+	reporter := utils.GetRootReporter()
+	reporter.GetChildHistogram(tags, utils.ShardsRequestedBroker).RecordValue(float64(stats.ShardsRequested))
+	reporter.GetChildHistogram(tags, utils.ShardsPrunedBroker).RecordValue(float64(stats.ShardsPruned))
+	reporter.GetChildHistogram(tags, utils.BatchesScannedBroker).RecordValue(float64(stats.BatchesScanned))
+	reporter.GetChildHistogram(tags, utils.BatchesSkippedBroker).RecordValue(float64(stats.BatchesSkipped))
+	reporter.GetChildHistogram(tags, utils.BytesTransferredBroker).RecordValue(float64(stats.BytesTransferred))
+}
+
+// setDataFreshnessHeader sets the data freshness collected from datanode responses into response header.
+func setDataFreshnessHeader(ctx context.Context, w http.ResponseWriter) {
+	collector := queryCom.DataFreshnessCollectorFromContext(ctx)
+	if collector == nil {
+		return
+	}
+	freshness := collector.Result()
+	if len(freshness.Shards) == 0 {
+		return
+	}
+	freshnessBytes, err := json.Marshal(freshness)
+	if err != nil {
+		return
+	}
+	w.Header().Set(queryCom.DataFreshnessHeaderKey, string(freshnessBytes))
 }
@@ -0,0 +1,436 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	maxForecastBuckets = 1000
+	maxForecastPeriod  = 1000
+	// max number of most recent buckets of a series the forecast is computed from.
+	maxForecastHistory = 10000
+
+	defaultHoltWintersAlpha = 0.5
+	defaultHoltWintersBeta  = 0.1
+	defaultHoltWintersGamma = 0.1
+)
+
+// forecaster forecasts future buckets of each time series of aggregation results. A series is
+// the buckets of the same values of all dimensions other than the time dimension.
+type forecaster struct {
+	common.Forecast
+	// index of the time bucketized dimension.
+	timeDimIndex int
+	timeline     forecastTimeline
+}
+
+// forecastTimeline parses, advances and formats time buckets of the time dimension. Time
+// dimension values are already shifted to the query timezone by datanodes, so buckets are
+// advanced in UTC.
+type forecastTimeline struct {
+	// unit of numeric time dimension values, 0 if values are formatted with layout.
+	unit   time.Duration
+	layout string
+	// advance moves the start of a bucket n buckets forward or backward.
+	advance func(t time.Time, n int) time.Time
+}
+
+// processForecast validates the forecast options and finds the time dimension of the series.
+func (qc *QueryContext) processForecast() {
+	options := qc.AQLQuery.Forecast
+	if options == nil {
+		return
+	}
+	if qc.IsNonAggregationQuery {
+		qc.Error = utils.StackError(nil, "forecast is only supported in aggregation queries")
+		return
+	}
+	if qc.ReturnHLLBinary {
+		qc.Error = utils.StackError(nil, "forecast is not supported in hll binary results")
+		return
+	}
+	if qc.OutlierDetection != nil {
+		qc.Error = utils.StackError(nil, "forecast is not supported together with zscore_outliers")
+		return
+	}
+	if options.Buckets < 1 || options.Buckets > maxForecastBuckets {
+		qc.Error = utils.StackError(nil, "expect forecast buckets between 1 and %d, but got %d",
+			maxForecastBuckets, options.Buckets)
+		return
+	}
+
+	f := &forecaster{Forecast: *options}
+	switch options.Method {
+	case common.ForecastMethodLinear:
+	case common.ForecastMethodHoltWinters:
+		if options.Period == 1 || options.Period < 0 || options.Period > maxForecastPeriod {
+			qc.Error = utils.StackError(nil, "expect forecast period to be 0 or between 2 and %d, but got %d",
+				maxForecastPeriod, options.Period)
+			return
+		}
+		for _, factor := range []*float64{&f.Alpha, &f.Beta, &f.Gamma} {
+			if *factor < 0 || *factor > 1 {
+				qc.Error = utils.StackError(nil, "expect forecast smoothing factors between 0 and 1, but got %v", *factor)
+				return
+			}
+		}
+		if f.Alpha == 0 {
+			f.Alpha = defaultHoltWintersAlpha
+		}
+		if f.Beta == 0 {
+			f.Beta = defaultHoltWintersBeta
+		}
+		if f.Gamma == 0 {
+			f.Gamma = defaultHoltWintersGamma
+		}
+	default:
+		qc.Error = utils.StackError(nil, "unknown forecast method %s", options.Method)
+		return
+	}
+
+	for i, dim := range qc.AQLQuery.Dimensions {
+		if dim.TimeBucketizer != "" {
+			timeline, err := newForecastTimeline(dim)
+			if err != nil {
+				qc.Error = err
+				return
+			}
+			f.timeDimIndex = i
+			f.timeline = timeline
+			qc.TimeSeriesForecast = f
+			return
+		}
+	}
+	qc.Error = utils.StackError(nil, "forecast requires a time bucketized dimension")
+}
+
+func newForecastTimeline(dim common.Dimension) (timeline forecastTimeline, err error) {
+	bucketizer := strings.ToLower(strings.TrimSpace(dim.TimeBucketizer))
+	switch bucketizer {
+	case "week":
+		timeline.advance = func(t time.Time, n int) time.Time { return t.AddDate(0, 0, 7*n) }
+	case "month":
+		timeline.advance = func(t time.Time, n int) time.Time { return t.AddDate(0, n, 0) }
+	case "quarter":
+		timeline.advance = func(t time.Time, n int) time.Time { return t.AddDate(0, 3*n, 0) }
+	case "year":
+		timeline.advance = func(t time.Time, n int) time.Time { return t.AddDate(n, 0, 0) }
+	default:
+		bucket, parseErr := common.ParseRegularTimeBucketizer(bucketizer)
+		if parseErr != nil {
+			err = utils.StackError(nil, "time bucketizer %s is not supported by forecast", dim.TimeBucketizer)
+			return
+		}
+		size := time.Duration(bucket.Size*common.BucketSizeToseconds[bucket.Unit]) * time.Second
+		timeline.advance = func(t time.Time, n int) time.Time { return t.Add(time.Duration(n) * size) }
+		// same layouts datanodes format regular time buckets with.
+		switch bucket.Unit {
+		case "m":
+			timeline.layout = "2006-01-02 15:04"
+		case "h":
+			timeline.layout = "2006-01-02 15:00"
+		case "d":
+			timeline.layout = "2006-01-02"
+		}
+	}
+
+	if dim.TimeUnit != "" || timeline.layout == "" {
+		timeline.layout = ""
+		switch dim.TimeUnit {
+		case "day":
+			timeline.unit = 24 * time.Hour
+		case "hour":
+			timeline.unit = time.Hour
+		case "minute":
+			timeline.unit = time.Minute
+		case "millisecond":
+			timeline.unit = time.Millisecond
+		default:
+			timeline.unit = time.Second
+		}
+	}
+	return
+}
+
+func (t forecastTimeline) parse(value string) (time.Time, bool) {
+	if t.unit == 0 {
+		parsed, err := time.Parse(t.layout, value)
+		return parsed, err == nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, n*int64(t.unit)).UTC(), true
+}
+
+func (t forecastTimeline) format(bucket time.Time) string {
+	if t.unit == 0 {
+		return bucket.Format(t.layout)
+	}
+	return strconv.FormatInt(bucket.UnixNano()/int64(t.unit), 10)
+}
+
+// forecastRow is a row of results with values of all dimensions and the measure value.
+type forecastRow struct {
+	dimValues []string
+	value     float64
+}
+
+// forecastSeries is a time series of the results.
+type forecastSeries struct {
+	dimValues []string
+	buckets   map[int64]float64
+	first     time.Time
+	last      time.Time
+}
+
+// run returns the rows of forecasted buckets of each series, ordered by series and time. Missing
+// buckets within a series are treated as 0 since aggregation results omit empty buckets.
+func (f *forecaster) run(rows []forecastRow) []forecastRow {
+	series := make(map[string]*forecastSeries)
+	for _, row := range rows {
+		bucket, ok := f.timeline.parse(row.dimValues[f.timeDimIndex])
+		if !ok {
+			continue
+		}
+		var seriesKey []string
+		for i, dimValue := range row.dimValues {
+			if i != f.timeDimIndex {
+				seriesKey = append(seriesKey, dimValue)
+			}
+		}
+		id := strings.Join(seriesKey, "\x00")
+		s, exists := series[id]
+		if !exists {
+			s = &forecastSeries{
+				dimValues: row.dimValues,
+				buckets:   make(map[int64]float64),
+				first:     bucket,
+				last:      bucket,
+			}
+			series[id] = s
+		}
+		s.buckets[bucket.UnixNano()] += row.value
+		if bucket.Before(s.first) {
+			s.first = bucket
+		}
+		if bucket.After(s.last) {
+			s.last = bucket
+		}
+	}
+
+	ids := make([]string, 0, len(series))
+	for id := range series {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var forecasted []forecastRow
+	for _, id := range ids {
+		s := series[id]
+		values := make([]float64, 0)
+		for bucket := s.last; !bucket.Before(s.first) && len(values) < maxForecastHistory; bucket = f.timeline.advance(bucket, -1) {
+			values = append(values, s.buckets[bucket.UnixNano()])
+		}
+		for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+			values[i], values[j] = values[j], values[i]
+		}
+
+		for i, value := range f.predict(values) {
+			dimValues := make([]string, len(s.dimValues))
+			copy(dimValues, s.dimValues)
+			dimValues[f.timeDimIndex] = f.timeline.format(f.timeline.advance(s.last, i+1))
+			forecasted = append(forecasted, forecastRow{dimValues: dimValues, value: value})
+		}
+	}
+	return forecasted
+}
+
+// predict returns the forecasted values of the next buckets of the series.
+func (f *forecaster) predict(values []float64) []float64 {
+	if f.Method == common.ForecastMethodHoltWinters {
+		if f.Period > 0 && len(values) >= 2*f.Period {
+			return holtWinters(values, f.Buckets, f.Period, f.Alpha, f.Beta, f.Gamma)
+		}
+		// not enough seasons to initialize seasonal components.
+		return holt(values, f.Buckets, f.Alpha, f.Beta)
+	}
+	return linearTrend(values, f.Buckets)
+}
+
+// linearTrend extrapolates the least squares fit of the values against their bucket indexes.
+func linearTrend(values []float64, n int) []float64 {
+	var sumX, sumY, sumXY, sumXX float64
+	for i, v := range values {
+		x := float64(i)
+		sumX += x
+		sumY += v
+		sumXY += x * v
+		sumXX += x * x
+	}
+	count := float64(len(values))
+	var slope float64
+	if denominator := count*sumXX - sumX*sumX; denominator != 0 {
+		slope = (count*sumXY - sumX*sumY) / denominator
+	}
+	intercept := (sumY - slope*sumX) / count
+
+	predictions := make([]float64, n)
+	for i := range predictions {
+		predictions[i] = intercept + slope*float64(len(values)+i)
+	}
+	return predictions
+}
+
+// holt applies double exponential smoothing of level and trend.
+func holt(values []float64, n int, alpha, beta float64) []float64 {
+	level, trend := values[0], 0.0
+	if len(values) > 1 {
+		trend = values[1] - values[0]
+	}
+	for _, v := range values[1:] {
+		lastLevel := level
+		level = alpha*v + (1-alpha)*(level+trend)
+		trend = beta*(level-lastLevel) + (1-beta)*trend
+	}
+
+	predictions := make([]float64, n)
+	for i := range predictions {
+		predictions[i] = level + float64(i+1)*trend
+	}
+	return predictions
+}
+
+// holtWinters applies additive triple exponential smoothing of level, trend and season, the
+// values must cover at least 2 seasons.
+func holtWinters(values []float64, n, period int, alpha, beta, gamma float64) []float64 {
+	// initial trend is the average slope between the first 2 seasons.
+	var trend float64
+	for i := 0; i < period; i++ {
+		trend += (values[i+period] - values[i]) / float64(period)
+	}
+	trend /= float64(period)
+
+	// initial seasonal components are the average deviations from the mean of complete seasons.
+	numSeasons := len(values) / period
+	seasonals := make([]float64, period)
+	for j := 0; j < numSeasons; j++ {
+		var mean float64
+		for i := 0; i < period; i++ {
+			mean += values[j*period+i]
+		}
+		mean /= float64(period)
+		for i := 0; i < period; i++ {
+			seasonals[i] += (values[j*period+i] - mean) / float64(numSeasons)
+		}
+	}
+
+	// initial level is the mean of the first season.
+	var level float64
+	for i := 0; i < period; i++ {
+		level += values[i] / float64(period)
+	}
+	for i := 0; i < len(values); i++ {
+		lastLevel := level
+		level = alpha*(values[i]-seasonals[i%period]) + (1-alpha)*(level+trend)
+		trend = beta*(level-lastLevel) + (1-beta)*trend
+		seasonals[i%period] = gamma*(values[i]-level) + (1-gamma)*seasonals[i%period]
+	}
+
+	predictions := make([]float64, n)
+	for i := range predictions {
+		predictions[i] = level + float64(i+1)*trend + seasonals[(len(values)+i)%period]
+	}
+	return predictions
+}
+
+// forecastNested returns the forecast of row major results in the same nested format.
+func (f *forecaster) forecastNested(results map[string]interface{}, numDims int) map[string]interface{} {
+	var rows []forecastRow
+	dimValues := make([]string, numDims)
+	var traverse func(depth int, curr map[string]interface{})
+	traverse = func(depth int, curr map[string]interface{}) {
+		for key, child := range curr {
+			dimValues[depth] = key
+			if depth < numDims-1 {
+				if children, ok := child.(map[string]interface{}); ok {
+					traverse(depth+1, children)
+				}
+				continue
+			}
+			if value, ok := child.(float64); ok {
+				rows = append(rows, forecastRow{dimValues: append([]string(nil), dimValues...), value: value})
+			}
+		}
+	}
+	traverse(0, results)
+
+	forecast := make(map[string]interface{})
+	for _, row := range f.run(rows) {
+		curr := forecast
+		for _, dimValue := range row.dimValues[:numDims-1] {
+			child, exists := curr[dimValue]
+			if !exists {
+				child = make(map[string]interface{})
+				curr[dimValue] = child
+			}
+			curr = child.(map[string]interface{})
+		}
+		curr[row.dimValues[numDims-1]] = row.value
+	}
+	return forecast
+}
+
+// forecastColumnMajor returns the forecast of column major results with the same headers.
+func (f *forecaster) forecastColumnMajor(results common.ColumnMajorResult) *common.ColumnMajorResult {
+	numDims := len(results.Columns) - 1
+	var rows []forecastRow
+	for i, measure := range results.Columns[numDims] {
+		value, ok := measure.(float64)
+		if !ok {
+			continue
+		}
+		row := forecastRow{dimValues: make([]string, numDims), value: value}
+		for dimIndex := 0; dimIndex < numDims; dimIndex++ {
+			row.dimValues[dimIndex], _ = results.Columns[dimIndex][i].(string)
+		}
+		rows = append(rows, row)
+	}
+
+	forecasted := f.run(rows)
+	forecast := &common.ColumnMajorResult{
+		Headers: results.Headers,
+		Columns: make([][]interface{}, len(results.Columns)),
+	}
+	for i := range forecast.Columns {
+		forecast.Columns[i] = make([]interface{}, len(forecasted))
+	}
+	for i, row := range forecasted {
+		for dimIndex, dimValue := range row.dimValues {
+			forecast.Columns[dimIndex][i] = dimValue
+		}
+		forecast.Columns[numDims][i] = row.value
+	}
+	return forecast
+}
@@ -0,0 +1,147 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/broker/common"
+	memCom "github.com/uber/aresdb/memstore/common"
+	memComMocks "github.com/uber/aresdb/memstore/common/mocks"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	queryCom "github.com/uber/aresdb/query/common"
+)
+
+var _ = ginkgo.Describe("forecast", func() {
+	table := &metaCom.Table{
+		Name: "trips",
+		Columns: []metaCom.Column{
+			{Name: "request_at", Type: "Uint32"},
+			{Name: "city", Type: "SmallEnum"},
+			{Name: "fare", Type: "Float32"},
+		},
+	}
+	tableSchema := memCom.NewTableSchema(table)
+	tableSchema.CreateEnumDict("city", []string{"sf", "nyc"})
+
+	compile := func(forecast queryCom.Forecast, resultFormat string, dimensions ...queryCom.Dimension) *QueryContext {
+		mockTableSchemaReader := memComMocks.TableSchemaReader{}
+		mockTableSchemaReader.On("RLock").Return(nil)
+		mockTableSchemaReader.On("RUnlock").Return(nil)
+		mockTableSchemaReader.On("GetSchema", "trips").Return(tableSchema, nil)
+
+		qc := NewQueryContext(&queryCom.AQLQuery{
+			Table:        "trips",
+			Measures:     []queryCom.Measure{{Expr: "count(*)"}},
+			Dimensions:   dimensions,
+			ResultFormat: resultFormat,
+			Forecast:     &forecast,
+		}, false, httptest.NewRecorder())
+		qc.Compile(&mockTableSchemaReader)
+		return qc
+	}
+	day := queryCom.Dimension{Expr: "request_at", TimeBucketizer: "day"}
+
+	ginkgo.It("should validate forecast options", func() {
+		qc := compile(queryCom.Forecast{Method: "holt_winters", Buckets: 3, Period: 7}, "", day)
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.TimeSeriesForecast.Alpha).Should(Equal(defaultHoltWintersAlpha))
+		Ω(qc.GetRewrittenQuery().Forecast).Should(BeNil())
+
+		qc = compile(queryCom.Forecast{Method: "linear", Buckets: 3}, "", queryCom.Dimension{Expr: "city"}, day)
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.TimeSeriesForecast.timeDimIndex).Should(Equal(1))
+
+		qc = compile(queryCom.Forecast{Method: "arima", Buckets: 3}, "", day)
+		Ω(qc.Error.Error()).Should(ContainSubstring("unknown forecast method"))
+		qc = compile(queryCom.Forecast{Method: "linear"}, "", day)
+		Ω(qc.Error.Error()).Should(ContainSubstring("expect forecast buckets"))
+		qc = compile(queryCom.Forecast{Method: "holt_winters", Buckets: 3, Period: 1}, "", day)
+		Ω(qc.Error.Error()).Should(ContainSubstring("expect forecast period"))
+		qc = compile(queryCom.Forecast{Method: "holt_winters", Buckets: 3, Alpha: 2}, "", day)
+		Ω(qc.Error.Error()).Should(ContainSubstring("expect forecast smoothing factors"))
+		qc = compile(queryCom.Forecast{Method: "linear", Buckets: 3}, "", queryCom.Dimension{Expr: "city"})
+		Ω(qc.Error.Error()).Should(ContainSubstring("requires a time bucketized dimension"))
+		qc = compile(queryCom.Forecast{Method: "linear", Buckets: 3}, "",
+			queryCom.Dimension{Expr: "request_at", TimeBucketizer: "day of week"})
+		Ω(qc.Error.Error()).Should(ContainSubstring("is not supported by forecast"))
+	})
+
+	ginkgo.It("should forecast each series of row major results", func() {
+		qc := compile(queryCom.Forecast{Method: "linear", Buckets: 2}, "", queryCom.Dimension{Expr: "city"}, day)
+		Ω(qc.Error).Should(BeNil())
+		plan := AggQueryPlan{aggType: common.Count, qc: qc}
+		results := queryCom.AQLQueryResult{
+			"0": map[string]interface{}{
+				"2019-01-01": 1.0,
+				"2019-01-02": 3.0,
+				"2019-01-03": 5.0,
+			},
+			// missing buckets are 0.
+			"1": map[string]interface{}{
+				"2019-01-01": 6.0,
+				"2019-01-03": 6.0,
+			},
+		}
+		w := httptest.NewRecorder()
+		Ω(plan.postProcess(context.TODO(), results, nil, w)).Should(BeNil())
+		Ω(w.Body.String()).Should(MatchJSON(`{
+			"results": {
+				"sf": {"2019-01-01": 1, "2019-01-02": 3, "2019-01-03": 5},
+				"nyc": {"2019-01-01": 6, "2019-01-03": 6}
+			},
+			"forecast": {
+				"sf": {"2019-01-04": 7, "2019-01-05": 9},
+				"nyc": {"2019-01-04": 4, "2019-01-05": 4}
+			}
+		}`))
+	})
+
+	ginkgo.It("should forecast column major results of calendar buckets", func() {
+		qc := compile(queryCom.Forecast{Method: "holt_winters", Buckets: 2}, queryCom.ResultFormatColumnMajor,
+			queryCom.Dimension{Expr: "request_at", TimeBucketizer: "month"})
+		Ω(qc.Error).Should(BeNil())
+		plan := AggQueryPlan{aggType: common.Count, qc: qc}
+		// 2019-01-01, 2019-02-01 and 2019-03-01.
+		results := queryCom.AQLQueryResult{
+			"1546300800": 10.0,
+			"1548979200": 20.0,
+			"1551398400": 30.0,
+		}
+		w := httptest.NewRecorder()
+		Ω(plan.postProcess(context.TODO(), results, nil, w)).Should(BeNil())
+		var columnMajor queryCom.ColumnMajorResult
+		Ω(json.Unmarshal(w.Body.Bytes(), &columnMajor)).Should(BeNil())
+		Ω(columnMajor.Forecast.Headers).Should(Equal(columnMajor.Headers))
+		// 2019-04-01 and 2019-05-01.
+		Ω(columnMajor.Forecast.Columns[0]).Should(Equal([]interface{}{"1554076800", "1556668800"}))
+		Ω(columnMajor.Forecast.Columns[1][0]).Should(BeNumerically("~", 40, 0.001))
+		Ω(columnMajor.Forecast.Columns[1][1]).Should(BeNumerically("~", 50, 0.001))
+	})
+
+	ginkgo.It("should forecast seasonal series", func() {
+		var values []float64
+		for i := 0; i < 4; i++ {
+			values = append(values, 10, 20, 30, 20)
+		}
+		Ω(holtWinters(values, 5, 4, 0.5, 0.1, 0.1)).Should(Equal([]float64{10, 20, 30, 20, 10}))
+		Ω(linearTrend([]float64{1, 3, 5, 7}, 2)).Should(Equal([]float64{9, 11}))
+		Ω(holt([]float64{5}, 2, 0.5, 0.1)).Should(Equal([]float64{5, 5}))
+	})
+})
@@ -20,29 +20,65 @@ import (
 	"github.com/gorilla/mux"
 	apiCom "github.com/uber/aresdb/api/common"
 	"github.com/uber/aresdb/broker/common"
+	"github.com/uber/aresdb/client"
 	queryCom "github.com/uber/aresdb/query/common"
 	"github.com/uber/aresdb/query/sql"
 	"github.com/uber/aresdb/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"mime"
 	"net/http"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type QueryHandler struct {
-	exec          common.QueryExecutor
-	nextRequestID int64
-	instanceID    string
+	exec              common.QueryExecutor
+	nextRequestID     int64
+	instanceID        string
+	slowQueryLogger   *SlowQueryLogger
+	idempotentQueries *idempotentQueryRegistry
+	tempTables        *TempTableHandler
+	queryBlocks       *queryCom.QueryBlocklist
+	queryTraces       *utils.QueryTraceStore
+	admission         *AdmissionController
 }
 
-func NewQueryHandler(executor common.QueryExecutor, instanceID string) QueryHandler {
+func NewQueryHandler(executor common.QueryExecutor, instanceID string, slowQueryLogger *SlowQueryLogger, tempTables *TempTableHandler, queryBlocks *queryCom.QueryBlocklist, admission *AdmissionController, idempotentQueryTimeout time.Duration) QueryHandler {
 	return QueryHandler{
-		exec:       executor,
-		instanceID: instanceID,
+		exec:              executor,
+		instanceID:        instanceID,
+		slowQueryLogger:   slowQueryLogger,
+		idempotentQueries: newIdempotentQueryRegistry(idempotentQueryTimeout),
+		tempTables:        tempTables,
+		queryBlocks:       queryBlocks,
+		queryTraces:       utils.NewQueryTraceStore(),
+		admission:         admission,
 	}
 }
 
 func (handler *QueryHandler) Register(router *mux.Router, wrappers ...utils.HTTPHandlerWrapper) {
 	router.HandleFunc("/sql", utils.ApplyHTTPWrappers(handler.HandleSQL, wrappers)).Methods(http.MethodPost)
 	router.HandleFunc("/aql", utils.ApplyHTTPWrappers(handler.HandleAQL, wrappers)).Methods(http.MethodPost)
+	router.HandleFunc("/traces/{id}", utils.ApplyHTTPWrappers(handler.GetQueryTrace, wrappers)).Methods(http.MethodGet)
+}
+
+// GetQueryTrace returns the trace of a recent query executed in trace mode in chrome tracing format,
+// with stages on broker and datanodes.
+func (handler *QueryHandler) GetQueryTrace(w http.ResponseWriter, r *http.Request) {
+	var request apiCom.QueryTraceRequest
+	err := apiCom.ReadRequest(r, &request)
+	if err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	queryTrace := handler.queryTraces.Get(request.ID)
+	if queryTrace == nil {
+		apiCom.RespondWithError(w, apiCom.ErrQueryTraceDoesNotExist)
+		return
+	}
+	apiCom.RespondWithJSONObject(w, queryTrace.ChromeTrace())
 }
 
 func (handler *QueryHandler) HandleSQL(w http.ResponseWriter, r *http.Request) {
@@ -51,7 +87,16 @@ func (handler *QueryHandler) HandleSQL(w http.ResponseWriter, r *http.Request) {
 
 	start := utils.Now()
 	var err error
+	var requestID string
+	var parseDuration time.Duration
+	profile := &queryProfile{}
+	ctx, span := utils.StartSpan(utils.ExtractTraceContext(utils.WithAuthenticationFrom(context.Background(), r.Context()), r.Header), "broker.HandleSQL")
+	var queryTrace *utils.QueryTrace
 	defer func() {
+		span.SetAttributes(attribute.String("requestID", requestID))
+		utils.EndSpan(span, err)
+		handler.finishQueryTrace(queryTrace, "broker.HandleSQL", requestID, start)
+
 		duration := utils.Now().Sub(start)
 		utils.GetRootReporter().GetTimer(utils.QueryLatencyBroker).Record(duration)
 		if err != nil {
@@ -63,6 +108,7 @@ func (handler *QueryHandler) HandleSQL(w http.ResponseWriter, r *http.Request) {
 			utils.GetRootReporter().GetCounter(utils.QuerySucceededBroker).Inc(1)
 			utils.GetLogger().With("request", queryReqeust).Info("Request succeeded")
 		}
+		handler.slowQueryLogger.Log(profile.toLogEntry(requestID, queryReqeust.Origin, queryReqeust.Body.Query, parseDuration, duration, err))
 	}()
 
 	err = apiCom.ReadRequest(r, &queryReqeust)
@@ -70,21 +116,59 @@ func (handler *QueryHandler) HandleSQL(w http.ResponseWriter, r *http.Request) {
 		apiCom.RespondWithError(w, err)
 		return
 	}
+	// fingerprint of the request as sent, before defaults are applied.
+	fingerprint := requestFingerprint(queryReqeust)
+	if queryReqeust.Trace != 0 {
+		queryTrace = utils.NewQueryTrace("broker")
+		ctx = utils.WithQueryTrace(ctx, queryTrace)
+	}
 
 	sqlParseStart := utils.Now()
-	var aql *queryCom.AQLQuery
-	aql, err = sql.Parse(queryReqeust.Body.Query, utils.GetLogger())
-	utils.GetRootReporter().GetTimer(utils.SQLParsingLatencyBroker).Record(utils.Now().Sub(sqlParseStart))
+	_, parseSpan := utils.StartSpan(ctx, "broker.ParseSQL")
+	var aqls []*queryCom.AQLQuery
+	aqls, err = sql.ParseUnionAll(queryReqeust.Body.Query, utils.GetLogger())
+	if err == nil && aqls == nil {
+		var aql *queryCom.AQLQuery
+		aql, err = sql.Parse(queryReqeust.Body.Query, utils.GetLogger())
+		aqls = []*queryCom.AQLQuery{aql}
+	}
+	utils.EndSpan(parseSpan, err)
+	parseDuration = utils.Now().Sub(sqlParseStart)
+	utils.GetRootReporter().GetTimer(utils.SQLParsingLatencyBroker).Record(parseDuration)
 	if err != nil {
 		apiCom.RespondWithError(w, err)
 		return
 	}
-
-	err = handler.exec.Execute(context.Background(), handler.getReqestID(), aql, queryReqeust.Accept == utils.HTTPContentTypeHyperLogLog, w)
-	if err != nil {
-		apiCom.RespondWithError(w, err)
+	for _, aql := range aqls {
+		aql.Strict = queryReqeust.Strict != 0
+		aql.TriValuedLogic = queryReqeust.TriValuedLogic != 0
+		aql.ResultFormat = getResultFormat(queryReqeust.Format, queryReqeust.Accept)
+		aql.DataScope = queryReqeust.DataScope
+		aql.UnknownEnumValue = queryReqeust.UnknownEnumValue
+		handler.tempTables.ResolveTempTables(ctx, queryReqeust.Session, aql)
+		if err = checkQueryBlocks(handler.queryBlocks, aql); err != nil {
+			apiCom.RespondWithError(w, err)
+			return
+		}
+	}
+	if len(aqls) > 1 && queryReqeust.Accept == utils.HTTPContentTypeHyperLogLog {
+		err = utils.StackError(nil, "UNION ALL does not support %s results", utils.HTTPContentTypeHyperLogLog)
+		apiCom.RespondWithBadRequest(w, err)
 		return
 	}
+
+	ctx = withQueryTimeoutOverrides(ctx, queryReqeust.ShardTimeoutMillis, queryReqeust.ScatterTimeoutMillis, queryReqeust.MergeTimeoutMillis)
+	requestID = handler.getReqestID()
+	if queryTrace != nil {
+		w.Header().Set(utils.QueryTraceIDHeaderKey, requestID)
+	}
+	ctx = withQueryProfile(queryCom.WithCaller(ctx, getCaller(queryReqeust.Caller, queryReqeust.Origin)), profile)
+	err = handler.execute(r.Context(), ctx, queryReqeust.IdempotencyToken, fingerprint, w, func(ctx context.Context, w http.ResponseWriter) error {
+		if len(aqls) > 1 {
+			return handler.executeUnionAll(ctx, requestID, aqls, w)
+		}
+		return handler.exec.Execute(ctx, requestID, aqls[0], queryReqeust.Accept == utils.HTTPContentTypeHyperLogLog, w)
+	})
 	return
 }
 
@@ -94,7 +178,15 @@ func (handler *QueryHandler) HandleAQL(w http.ResponseWriter, r *http.Request) {
 
 	start := utils.Now()
 	var err error
+	var requestID string
+	profile := &queryProfile{}
+	ctx, span := utils.StartSpan(utils.ExtractTraceContext(utils.WithAuthenticationFrom(context.Background(), r.Context()), r.Header), "broker.HandleAQL")
+	var queryTrace *utils.QueryTrace
 	defer func() {
+		span.SetAttributes(attribute.String("requestID", requestID))
+		utils.EndSpan(span, err)
+		handler.finishQueryTrace(queryTrace, "broker.HandleAQL", requestID, start)
+
 		duration := utils.Now().Sub(start)
 		utils.GetRootReporter().GetTimer(utils.QueryLatencyBroker).Record(duration)
 		if err != nil {
@@ -106,6 +198,7 @@ func (handler *QueryHandler) HandleAQL(w http.ResponseWriter, r *http.Request) {
 			utils.GetRootReporter().GetCounter(utils.QuerySucceededBroker).Inc(1)
 			utils.GetLogger().With("request", queryReqeust).Info("Request succeeded")
 		}
+		handler.slowQueryLogger.Log(profile.toLogEntry(requestID, queryReqeust.Origin, "", 0, duration, err))
 	}()
 
 	err = apiCom.ReadRequest(r, &queryReqeust)
@@ -113,33 +206,194 @@ func (handler *QueryHandler) HandleAQL(w http.ResponseWriter, r *http.Request) {
 		apiCom.RespondWithError(w, err)
 		return
 	}
+	// fingerprint of the request as sent, before defaults are applied.
+	fingerprint := requestFingerprint(queryReqeust)
+	if queryReqeust.Trace != 0 {
+		queryTrace = utils.NewQueryTrace("broker")
+		ctx = utils.WithQueryTrace(ctx, queryTrace)
+	}
 
-	err = handler.exec.Execute(context.TODO(), handler.getReqestID(), &queryReqeust.Body.Query, queryReqeust.Accept == utils.HTTPContentTypeHyperLogLog, w)
-	if err != nil {
+	queryReqeust.Body.Query.Strict = queryReqeust.Body.Query.Strict || queryReqeust.Strict != 0
+	queryReqeust.Body.Query.TriValuedLogic = queryReqeust.Body.Query.TriValuedLogic || queryReqeust.TriValuedLogic != 0
+	if queryReqeust.Body.Query.ResultFormat == "" {
+		queryReqeust.Body.Query.ResultFormat = getResultFormat(queryReqeust.Format, queryReqeust.Accept)
+	}
+	if queryReqeust.Body.Query.DataScope == "" {
+		queryReqeust.Body.Query.DataScope = queryReqeust.DataScope
+	}
+	if queryReqeust.Body.Query.UnknownEnumValue == "" {
+		queryReqeust.Body.Query.UnknownEnumValue = queryReqeust.UnknownEnumValue
+	}
+	handler.tempTables.ResolveTempTables(ctx, queryReqeust.Session, &queryReqeust.Body.Query)
+	if err = checkQueryBlocks(handler.queryBlocks, &queryReqeust.Body.Query); err != nil {
 		apiCom.RespondWithError(w, err)
 		return
 	}
+	ctx = withQueryTimeoutOverrides(ctx, queryReqeust.ShardTimeoutMillis, queryReqeust.ScatterTimeoutMillis, queryReqeust.MergeTimeoutMillis)
+	requestID = handler.getReqestID()
+	if queryTrace != nil {
+		w.Header().Set(utils.QueryTraceIDHeaderKey, requestID)
+	}
+	ctx = withQueryProfile(queryCom.WithCaller(ctx, getCaller(queryReqeust.Caller, queryReqeust.Origin)), profile)
+	err = handler.execute(r.Context(), ctx, queryReqeust.IdempotencyToken, fingerprint, w, func(ctx context.Context, w http.ResponseWriter) error {
+		return handler.exec.Execute(ctx, requestID, &queryReqeust.Body.Query, queryReqeust.Accept == utils.HTTPContentTypeHyperLogLog, w)
+	})
 	return
 }
 
+// execute runs the query once admitted by admission control and responds errors if any. Requests
+// with the same idempotency token are attached to the running execution if any instead of starting
+// a new one, which is detached from the requests so that it is not cancelled by any of them. Tokens
+// are scoped to the authenticated principal so that results are never shared across principals,
+// and requests reusing a running token with a different fingerprint are rejected.
+func (handler *QueryHandler) execute(requestCtx, ctx context.Context, token, fingerprint string, w http.ResponseWriter,
+	execute func(ctx context.Context, w http.ResponseWriter) error) error {
+	key := token
+	if principal := utils.PrincipalFromContext(ctx); principal != nil && token != "" {
+		key = principal.Name + "/" + token
+	}
+	attached, err := handler.idempotentQueries.execute(requestCtx, ctx, key, fingerprint, w, func(ctx context.Context, w http.ResponseWriter) error {
+		if handler.admission != nil {
+			release, err := handler.admission.Admit(ctx)
+			if err != nil {
+				apiCom.RespondWithError(w, err)
+				return err
+			}
+			defer release()
+		}
+		err := execute(ctx, w)
+		if err != nil {
+			apiCom.RespondWithError(w, err)
+		}
+		return err
+	})
+	if err == errIdempotencyTokenReused {
+		apiCom.RespondWithError(w, err)
+	}
+	if attached {
+		utils.GetLogger().With("token", key, "error", err).Info("Request attached to running query with same idempotency token")
+	}
+	return err
+}
+
+// executeUnionAll runs the queries of UNION ALL concurrently and responds their result rows
+// concatenated in order as a non aggregation query result.
+func (handler *QueryHandler) executeUnionAll(ctx context.Context, requestID string, aqls []*queryCom.AQLQuery, w http.ResponseWriter) error {
+	headers := make([][]string, len(aqls))
+	rows := make([][]client.Row, len(aqls))
+	errs := make([]error, len(aqls))
+	wg := &sync.WaitGroup{}
+	for i, aql := range aqls {
+		wg.Add(1)
+		go func(i int, aql *queryCom.AQLQuery) {
+			defer wg.Done()
+			headers[i], rows[i], errs[i] = executeQueryForRows(ctx, handler.exec, fmt.Sprintf("%s_%d", requestID, i), aql)
+		}(i, aql)
+	}
+	wg.Wait()
+
+	var matrixData [][]interface{}
+	for i := range aqls {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		if len(headers[i]) != len(headers[0]) {
+			return utils.StackError(nil, "queries of UNION ALL must return the same number of columns, but got %d and %d",
+				len(headers[0]), len(headers[i]))
+		}
+		for _, row := range rows[i] {
+			for j, value := range row {
+				if value == nil {
+					row[j] = queryCom.NULLString
+				}
+			}
+			matrixData = append(matrixData, row)
+		}
+	}
+	apiCom.RespondWithJSONObject(w, queryCom.AQLQueryResult{
+		queryCom.HeadersKey:    headers[0],
+		queryCom.MatrixDataKey: matrixData,
+	})
+	return nil
+}
+
+// finishQueryTrace records the request span in the trace of the query and stores the trace for
+// download with the request ID.
+func (handler *QueryHandler) finishQueryTrace(queryTrace *utils.QueryTrace, name, requestID string, start time.Time) {
+	if queryTrace == nil || requestID == "" {
+		return
+	}
+	queryTrace.Record(name, start, utils.Now().Sub(start), map[string]interface{}{"requestID": requestID})
+	handler.queryTraces.Put(requestID, queryTrace)
+}
+
 func (handler *QueryHandler) getReqestID() string {
 	newID := atomic.AddInt64(&handler.nextRequestID, 1)
 	return fmt.Sprintf("%s_%d", handler.instanceID, newID)
 }
 
+// getCaller returns the caller set by the client for quota accounting when authentication is not
+// enabled, falls back to origin.
+func getCaller(caller, origin string) string {
+	if caller != "" {
+		return caller
+	}
+	return origin
+}
+
+// getResultFormat returns result format from format query parameter, or format parameter
+// of json Accept header, eg. application/json; format=columnMajor.
+func getResultFormat(format, accept string) string {
+	if format != "" {
+		return format
+	}
+	mediaType, params, err := mime.ParseMediaType(accept)
+	if err != nil || mediaType != utils.HTTPContentTypeApplicationJson {
+		return ""
+	}
+	return params["format"]
+}
+
 // BrokerSQLRequest represents SQL query request. Debug mode will
 // run **each batch** in synchronized mode and report time
-// for each step.
+// for each step. Trace mode records all stages of the query
+// on broker and datanodes in chrome tracing format, downloadable
+// from /query/traces/{id} with the id in X-Ares-Query-Trace-ID
+// response header.
 // swagger:parameters querySQL
 type BrokerSQLRequest struct {
 	// in: query
 	Verbose int `query:"verbose,optional" json:"verbose"`
 	// in: query
 	Debug int `query:"debug,optional" json:"debug"`
+	// in: query
+	Strict int `query:"strict,optional" json:"strict"`
+	// in: query
+	TriValuedLogic int `query:"triValuedLogic,optional" json:"triValuedLogic"`
+	// in: query
+	Format string `query:"format,optional" json:"format"`
+	// in: query
+	DataScope string `query:"dataScope,optional" json:"dataScope"`
+	// in: query
+	UnknownEnumValue string `query:"unknownEnumValue,optional" json:"unknownEnumValue"`
+	// in: query
+	ShardTimeoutMillis int `query:"shardTimeoutMillis,optional" json:"shardTimeoutMillis,omitempty"`
+	// in: query
+	ScatterTimeoutMillis int `query:"scatterTimeoutMillis,optional" json:"scatterTimeoutMillis,omitempty"`
+	// in: query
+	MergeTimeoutMillis int `query:"mergeTimeoutMillis,optional" json:"mergeTimeoutMillis,omitempty"`
+	// in: query
+	Trace int `query:"trace,optional" json:"trace,omitempty"`
 	// in: header
 	Accept string `header:"Accept,optional" json:"accept"`
 	// in: header
 	Origin string `header:"Rpc-Caller,optional" json:"origin"`
+	// in: header
+	Caller string `header:"X-Caller,optional" json:"caller"`
+	// in: header
+	IdempotencyToken string `header:"Idempotency-Token,optional" json:"idempotencyToken,omitempty"`
+	// in: header
+	Session string `header:"X-Ares-Session,optional" json:"session,omitempty"`
 	// in: body
 	Body struct {
 		Query string `json:"query"`
@@ -148,17 +402,44 @@ type BrokerSQLRequest struct {
 
 // BrokerAQLRequest represents AQL query request. Debug mode will
 // run **each batch** in synchronized mode and report time
-// for each step.
+// for each step. Trace mode records all stages of the query
+// on broker and datanodes in chrome tracing format, downloadable
+// from /query/traces/{id} with the id in X-Ares-Query-Trace-ID
+// response header.
 // swagger:parameters querySQL
 type BrokerAQLRequest struct {
 	// in: query
 	Verbose int `query:"verbose,optional" json:"verbose"`
 	// in: query
 	Debug int `query:"debug,optional" json:"debug"`
+	// in: query
+	Strict int `query:"strict,optional" json:"strict"`
+	// in: query
+	TriValuedLogic int `query:"triValuedLogic,optional" json:"triValuedLogic"`
+	// in: query
+	Format string `query:"format,optional" json:"format"`
+	// in: query
+	DataScope string `query:"dataScope,optional" json:"dataScope"`
+	// in: query
+	UnknownEnumValue string `query:"unknownEnumValue,optional" json:"unknownEnumValue"`
+	// in: query
+	ShardTimeoutMillis int `query:"shardTimeoutMillis,optional" json:"shardTimeoutMillis,omitempty"`
+	// in: query
+	ScatterTimeoutMillis int `query:"scatterTimeoutMillis,optional" json:"scatterTimeoutMillis,omitempty"`
+	// in: query
+	MergeTimeoutMillis int `query:"mergeTimeoutMillis,optional" json:"mergeTimeoutMillis,omitempty"`
+	// in: query
+	Trace int `query:"trace,optional" json:"trace,omitempty"`
 	// in: header
 	Accept string `header:"Accept,optional" json:"accept"`
 	// in: header
 	Origin string `header:"Rpc-Caller,optional" json:"origin"`
+	// in: header
+	Caller string `header:"X-Caller,optional" json:"caller"`
+	// in: header
+	IdempotencyToken string `header:"Idempotency-Token,optional" json:"idempotencyToken,omitempty"`
+	// in: header
+	Session string `header:"X-Ares-Session,optional" json:"session,omitempty"`
 	// in: body
 	Body struct {
 		Query queryCom.AQLQuery `json:"query"`
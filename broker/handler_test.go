@@ -15,16 +15,64 @@
 package broker
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+
 	"github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	"github.com/uber/aresdb/broker/common/mocks"
+	queryCom "github.com/uber/aresdb/query/common"
 )
 
 var _ = ginkgo.Describe("broker handler", func() {
 	ginkgo.It("getRequestID should work", func() {
-		h := NewQueryHandler(nil, "inst1")
+		h := NewQueryHandler(nil, "inst1", nil, nil, nil, nil, 0)
 		for i := 0; i < 10; i++ {
 			Ω(h.getReqestID()).Should(Equal(fmt.Sprintf("inst1_%d", i+1)))
 		}
 	})
+
+	ginkgo.It("getResultFormat should work", func() {
+		Ω(getResultFormat("", "")).Should(Equal(""))
+		Ω(getResultFormat("", "application/hll")).Should(Equal(""))
+		Ω(getResultFormat("", "application/json")).Should(Equal(""))
+		Ω(getResultFormat("", "application/json; format=columnMajor")).Should(Equal(queryCom.ResultFormatColumnMajor))
+		Ω(getResultFormat(queryCom.ResultFormatColumnMajor, "application/json")).Should(Equal(queryCom.ResultFormatColumnMajor))
+	})
+
+	ginkgo.It("executeUnionAll should concatenate results of queries", func() {
+		mockExec := &mocks.QueryExecutor{}
+		h := NewQueryHandler(mockExec, "inst1", nil, nil, nil, nil, 0)
+		aqls := []*queryCom.AQLQuery{{Table: "trips"}, {Table: "trips"}}
+		mockExec.On("Execute", mock.Anything, "inst1_1_0", aqls[0], false, mock.Anything).
+			Run(func(args mock.Arguments) {
+				args.Get(4).(http.ResponseWriter).Write([]byte(`{"headers":["status","trips"],"columns":[["completed","NULL"],[3,1]]}`))
+			}).Return(nil).Once()
+		mockExec.On("Execute", mock.Anything, "inst1_1_1", aqls[1], false, mock.Anything).
+			Run(func(args mock.Arguments) {
+				args.Get(4).(http.ResponseWriter).Write([]byte(`{"headers":["status","trips"],"columns":[["completed"],[2]]}`))
+			}).Return(nil).Once()
+
+		w := httptest.NewRecorder()
+		Ω(h.executeUnionAll(context.Background(), "inst1_1", aqls, w)).Should(BeNil())
+		Ω(w.Body.String()).Should(MatchJSON(`{
+			"headers": ["status", "trips"],
+			"matrixData": [["completed", 3], ["NULL", 1], ["completed", 2]]
+		}`))
+
+		mockExec.On("Execute", mock.Anything, "inst1_2_0", aqls[0], false, mock.Anything).
+			Run(func(args mock.Arguments) {
+				args.Get(4).(http.ResponseWriter).Write([]byte(`{"headers":["status"],"matrixData":[["completed"]]}`))
+			}).Return(nil).Once()
+		mockExec.On("Execute", mock.Anything, "inst1_2_1", aqls[1], false, mock.Anything).
+			Run(func(args mock.Arguments) {
+				args.Get(4).(http.ResponseWriter).Write([]byte(`{"headers":["status","trips"],"matrixData":[["completed",2]]}`))
+			}).Return(nil).Once()
+		err := h.executeUnionAll(context.Background(), "inst1_2", aqls, httptest.NewRecorder())
+		Ω(err.Error()).Should(ContainSubstring("must return the same number of columns"))
+		mockExec.AssertExpectations(ginkgo.GinkgoT())
+	})
 })
@@ -0,0 +1,202 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	apiCom "github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/utils"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultIdempotentQueryTimeout is the timeout of shared executions if not configured.
+const defaultIdempotentQueryTimeout = 5 * time.Minute
+
+// errIdempotencyTokenReused is returned to requests reusing the idempotency token of a running
+// query with a different request.
+var errIdempotencyTokenReused = utils.APIError{
+	Code:    http.StatusUnprocessableEntity,
+	Message: "idempotency token is reused by a different request",
+}
+
+// idempotentQuery is a query execution shared by requests with the same idempotency token.
+type idempotentQuery struct {
+	// fingerprint of the request starting the execution.
+	fingerprint string
+	// closed when execution finishes, fields below are only valid after that.
+	done     chan struct{}
+	response *bufferedResponseWriter
+	err      error
+}
+
+// idempotentQueryRegistry tracks running queries by idempotency token, so that a client
+// retrying a query is attached to the running execution instead of starting a duplicate scan.
+type idempotentQueryRegistry struct {
+	sync.Mutex
+	queries map[string]*idempotentQuery
+	// timeout of shared executions, which are detached from the requests waiting for them.
+	timeout time.Duration
+}
+
+func newIdempotentQueryRegistry(timeout time.Duration) *idempotentQueryRegistry {
+	if timeout <= 0 {
+		timeout = defaultIdempotentQueryTimeout
+	}
+	return &idempotentQueryRegistry{
+		queries: make(map[string]*idempotentQuery),
+		timeout: timeout,
+	}
+}
+
+// requestFingerprint returns the sha256 of the json encoded request, so that requests sharing
+// an idempotency token can be checked to be the same.
+func requestFingerprint(request interface{}) string {
+	bs, _ := json.Marshal(request)
+	sum := sha256.Sum256(bs)
+	return hex.EncodeToString(sum[:])
+}
+
+// execute runs fn with execCtx cancelled with ctx if key is empty. Otherwise fn is started in background with
+// execCtx and the registry timeout if no query with the key is running, and the request waits
+// for the running query until ctx is done, so that cancelling any of the requests does not
+// affect the others. Key should scope the idempotency token to the principal, and requests with
+// a running key but a different fingerprint are rejected with errIdempotencyTokenReused.
+//
+// fn should write the response including errors to the response writer passed in. The response
+// of the execution is buffered and written to w of all requests sharing the key, so results are
+// not streamed for queries with token. Returns whether the request was attached to a running
+// query and the error of the execution.
+func (r *idempotentQueryRegistry) execute(ctx, execCtx context.Context, key, fingerprint string, w http.ResponseWriter,
+	fn func(ctx context.Context, w http.ResponseWriter) error) (attached bool, err error) {
+	if key == "" {
+		// not shared, so cancelled with the request.
+		var cancel context.CancelFunc
+		execCtx, cancel = context.WithCancel(execCtx)
+		defer cancel()
+		go func() {
+			select {
+			case <-ctx.Done():
+				cancel()
+			case <-execCtx.Done():
+			}
+		}()
+		err = fn(execCtx, w)
+		return
+	}
+
+	r.Lock()
+	query, attached := r.queries[key]
+	if attached && query.fingerprint != fingerprint {
+		r.Unlock()
+		return false, errIdempotencyTokenReused
+	}
+	if !attached {
+		query = &idempotentQuery{
+			fingerprint: fingerprint,
+			done:        make(chan struct{}),
+			response:    newBufferedResponseWriter(),
+		}
+		r.queries[key] = query
+		go r.run(execCtx, key, query, fn)
+	}
+	r.Unlock()
+
+	if attached {
+		utils.GetRootReporter().GetCounter(utils.QueryAttachedBroker).Inc(1)
+	}
+	select {
+	case <-query.done:
+	case <-ctx.Done():
+		err = utils.StackError(ctx.Err(), "request cancelled while waiting for query with token %s", key)
+		return
+	}
+
+	if _, writeErr := query.response.writeTo(w); writeErr != nil {
+		utils.GetLogger().With("error", writeErr, "token", key).Warn("Failed to write query response")
+	}
+	err = query.err
+	return
+}
+
+// run executes the shared query and removes it from the registry once finished, so that later
+// retries with the same key start a new execution.
+func (r *idempotentQueryRegistry) run(ctx context.Context, key string, query *idempotentQuery,
+	fn func(ctx context.Context, w http.ResponseWriter) error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	query.err = utils.RecoverWrap(func() error {
+		return fn(ctx, query.response)
+	})
+	if query.err != nil && query.response.statusCode == 0 {
+		// fn panicked before responding.
+		apiCom.RespondWithError(query.response, query.err)
+	}
+
+	r.Lock()
+	delete(r.queries, key)
+	r.Unlock()
+	close(query.done)
+}
+
+// bufferedResponseWriter is a http.ResponseWriter buffering the response in memory.
+type bufferedResponseWriter struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{
+		header: http.Header{},
+	}
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) Write(data []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	return w.body.Write(data)
+}
+
+func (w *bufferedResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+// writeTo writes the buffered response to rw.
+func (w *bufferedResponseWriter) writeTo(rw http.ResponseWriter) (int, error) {
+	for key, values := range w.header {
+		// trailers are already set when the body is buffered, send them as headers.
+		if key == "Trailer" {
+			continue
+		}
+		rw.Header()[key] = append([]string(nil), values...)
+	}
+	if w.statusCode != 0 {
+		rw.WriteHeader(w.statusCode)
+	}
+	return rw.Write(w.body.Bytes())
+}
@@ -0,0 +1,177 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"errors"
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber-go/tally"
+	"github.com/uber/aresdb/common"
+	"github.com/uber/aresdb/utils"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+)
+
+var _ = ginkgo.Describe("idempotent query", func() {
+	utils.Init(common.AresServerConfig{}, common.NewLoggerFactory().GetDefaultLogger(), common.NewLoggerFactory().GetDefaultLogger(), tally.NewTestScope("test", nil))
+
+	ginkgo.It("should execute every request without token", func() {
+		registry := newIdempotentQueryRegistry(0)
+		var numExecutions int32
+		for i := 0; i < 2; i++ {
+			w := httptest.NewRecorder()
+			attached, err := registry.execute(context.TODO(), context.TODO(), "", "", w, func(ctx context.Context, w http.ResponseWriter) error {
+				atomic.AddInt32(&numExecutions, 1)
+				w.Write([]byte("result"))
+				return nil
+			})
+			Ω(attached).Should(BeFalse())
+			Ω(err).Should(BeNil())
+			Ω(w.Body.String()).Should(Equal("result"))
+		}
+		Ω(numExecutions).Should(Equal(int32(2)))
+
+		// executions without token are cancelled with the request.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := registry.execute(ctx, context.TODO(), "", "", httptest.NewRecorder(), func(ctx context.Context, w http.ResponseWriter) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		Ω(err).Should(Equal(context.Canceled))
+	})
+
+	ginkgo.It("should attach requests with same token to running query", func() {
+		registry := newIdempotentQueryRegistry(0)
+		var numExecutions int32
+		started := make(chan struct{})
+		finish := make(chan struct{})
+		fn := func(ctx context.Context, w http.ResponseWriter) error {
+			atomic.AddInt32(&numExecutions, 1)
+			close(started)
+			<-finish
+			w.Header().Set("Trailer", "Data-Freshness")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("bad query"))
+			w.Header().Set("Data-Freshness", "[]")
+			return errors.New("bad query")
+		}
+
+		w1 := httptest.NewRecorder()
+		done := make(chan struct{})
+		var attached1 bool
+		var err1 error
+		go func() {
+			attached1, err1 = registry.execute(context.TODO(), context.TODO(), "token1", "fingerprint", w1, fn)
+			close(done)
+		}()
+		<-started
+
+		// reusing the token with a different request is rejected.
+		attached, err := registry.execute(context.TODO(), context.TODO(), "token1", "other", httptest.NewRecorder(), fn)
+		Ω(attached).Should(BeFalse())
+		Ω(err).Should(Equal(errIdempotencyTokenReused))
+
+		w2 := httptest.NewRecorder()
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			close(finish)
+		}()
+		attached, err = registry.execute(context.TODO(), context.TODO(), "token1", "fingerprint", w2, fn)
+		Ω(attached).Should(BeTrue())
+		Ω(err).ShouldNot(BeNil())
+		<-done
+		Ω(attached1).Should(BeFalse())
+		Ω(err1).ShouldNot(BeNil())
+
+		Ω(numExecutions).Should(Equal(int32(1)))
+		for _, w := range []*httptest.ResponseRecorder{w1, w2} {
+			Ω(w.Code).Should(Equal(http.StatusBadRequest))
+			Ω(w.Body.String()).Should(Equal("bad query"))
+			Ω(w.Header().Get("Data-Freshness")).Should(Equal("[]"))
+			Ω(w.Header().Get("Trailer")).Should(BeEmpty())
+		}
+		registry.Lock()
+		Ω(registry.queries).Should(BeEmpty())
+		registry.Unlock()
+	})
+
+	ginkgo.It("should not cancel shared execution when requests are cancelled", func() {
+		registry := newIdempotentQueryRegistry(0)
+		started := make(chan struct{})
+		finish := make(chan struct{})
+		finished := make(chan error)
+		fn := func(ctx context.Context, w http.ResponseWriter) error {
+			close(started)
+			select {
+			case <-finish:
+			case <-ctx.Done():
+			}
+			finished <- ctx.Err()
+			return nil
+		}
+
+		ctx1, cancel1 := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		var err1 error
+		go func() {
+			_, err1 = registry.execute(ctx1, context.TODO(), "token1", "", httptest.NewRecorder(), fn)
+			close(done)
+		}()
+		<-started
+		cancel1()
+		<-done
+		Ω(err1).ShouldNot(BeNil())
+
+		ctx2, cancel2 := context.WithCancel(context.Background())
+		cancel2()
+		attached, err := registry.execute(ctx2, context.TODO(), "token1", "", httptest.NewRecorder(), fn)
+		Ω(attached).Should(BeTrue())
+		Ω(err).ShouldNot(BeNil())
+
+		close(finish)
+		Ω(<-finished).Should(BeNil())
+	})
+
+	ginkgo.It("should time out shared execution and recover panics", func() {
+		registry := newIdempotentQueryRegistry(time.Millisecond)
+		w := httptest.NewRecorder()
+		_, err := registry.execute(context.TODO(), context.TODO(), "token1", "", w, func(ctx context.Context, w http.ResponseWriter) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		Ω(err).Should(Equal(context.DeadlineExceeded))
+
+		w = httptest.NewRecorder()
+		_, err = registry.execute(context.TODO(), context.TODO(), "token2", "", w, func(ctx context.Context, w http.ResponseWriter) error {
+			panic("query panicked")
+		})
+		Ω(err).Should(MatchError("query panicked"))
+		Ω(w.Code).Should(Equal(http.StatusInternalServerError))
+	})
+
+	ginkgo.It("requestFingerprint should work", func() {
+		request := BrokerSQLRequest{}
+		request.Body.Query = "SELECT 1"
+		fingerprint := requestFingerprint(request)
+		Ω(requestFingerprint(request)).Should(Equal(fingerprint))
+		request.Body.Query = "SELECT 2"
+		Ω(requestFingerprint(request)).ShouldNot(Equal(fingerprint))
+	})
+})
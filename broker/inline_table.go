@@ -0,0 +1,199 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package broker
+
+import (
+	"fmt"
+	"github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/query/expr"
+	"github.com/uber/aresdb/utils"
+	"strconv"
+	"strings"
+)
+
+const (
+	// max number of rows allowed for an inline table
+	maxInlineTableRows = 1000
+)
+
+// inlineTable is an inline table materialized by broker. It is joined by an equi join
+// condition between one of its columns and an expression of joined tables, dimensions
+// referencing its columns are rewritten to the key expression and translated back after
+// datanode results are merged.
+type inlineTable struct {
+	join common.Join
+	// main table expression of the join condition
+	keyExpr string
+	// mapping from key expression values to column values, by column index
+	valueMaps []map[string]string
+}
+
+// extractInlineTables moves inline tables out of joins sent to datanodes.
+func (qc *QueryContext) extractInlineTables() {
+	qc.InlineTables = make(map[string]*inlineTable)
+	var joins []common.Join
+	for _, join := range qc.AQLQuery.Joins {
+		if join.InlineTable == nil {
+			joins = append(joins, join)
+			continue
+		}
+		if join.Alias == "" {
+			qc.Error = utils.StackError(nil, "alias is required for inline table")
+			return
+		}
+		if _, exists := qc.InlineTables[join.Alias]; exists {
+			qc.Error = utils.StackError(nil, "table alias %s is redefined", join.Alias)
+			return
+		}
+		if len(join.InlineTable.Rows) > maxInlineTableRows {
+			qc.Error = utils.StackError(nil, "inline table %s has %d rows, exceeding limit %d",
+				join.Alias, len(join.InlineTable.Rows), maxInlineTableRows)
+			return
+		}
+		qc.InlineTables[join.Alias] = &inlineTable{join: join}
+	}
+	qc.AQLQuery.Joins = joins
+}
+
+// processInlineTables resolves the join key of inline tables and builds value mappings
+// for translating join key values to inline table column values.
+func (qc *QueryContext) processInlineTables() {
+	for alias, table := range qc.InlineTables {
+		if _, exists := qc.TableIDByAlias[alias]; exists {
+			qc.Error = utils.StackError(nil, "table alias %s is redefined", alias)
+			return
+		}
+		if len(table.join.Conditions) != 1 {
+			qc.Error = utils.StackError(nil, "expect one equi join condition for inline table %s, but got %d",
+				alias, len(table.join.Conditions))
+			return
+		}
+		cond, err := expr.ParseExpr(table.join.Conditions[0])
+		if err != nil {
+			qc.Error = utils.StackError(err, "Failed to parse join condition: %s", table.join.Conditions[0])
+			return
+		}
+
+		keyColumn := -1
+		var keyExpr expr.Expr
+		if binary, ok := cond.(*expr.BinaryExpr); ok && binary.Op == expr.EQ {
+			for _, operands := range [][2]expr.Expr{{binary.LHS, binary.RHS}, {binary.RHS, binary.LHS}} {
+				if vr, ok := operands[0].(*expr.VarRef); ok {
+					if t, column, _ := qc.resolveInlineColumn(vr.Val); t == table && column >= 0 {
+						keyColumn, keyExpr = column, operands[1]
+						break
+					}
+				}
+			}
+		}
+		if keyColumn < 0 {
+			qc.Error = utils.StackError(nil, "expect equi join condition on inline table %s column, but got %s",
+				alias, table.join.Conditions[0])
+			return
+		}
+
+		// validate key expression against joined tables.
+		table.keyExpr = keyExpr.String()
+		expr.Rewrite(qc, keyExpr)
+		if qc.Error != nil {
+			return
+		}
+
+		table.valueMaps = make([]map[string]string, len(table.join.InlineTable.Columns))
+		for i := range table.valueMaps {
+			table.valueMaps[i] = make(map[string]string)
+		}
+		for _, row := range table.join.InlineTable.Rows {
+			if len(row) != len(table.valueMaps) {
+				qc.Error = utils.StackError(nil, "expect %d values per row of inline table %s, but got %d",
+					len(table.valueMaps), alias, len(row))
+				return
+			}
+			// null never equals to any key.
+			if row[keyColumn] == nil {
+				continue
+			}
+			key := inlineTableValueString(row[keyColumn])
+			if _, exists := table.valueMaps[0][key]; exists {
+				qc.Error = utils.StackError(nil, "duplicated join key %s in inline table %s", key, alias)
+				return
+			}
+			for i, value := range row {
+				table.valueMaps[i][key] = inlineTableValueString(value)
+			}
+		}
+	}
+}
+
+// resolveInlineColumn returns the inline table and column index of the identifier, table is
+// nil if the identifier does not reference inline tables.
+func (qc *QueryContext) resolveInlineColumn(identifier string) (table *inlineTable, column int, err error) {
+	segments := strings.SplitN(identifier, ".", 2)
+	if len(segments) != 2 {
+		return
+	}
+	if table = qc.InlineTables[segments[0]]; table == nil {
+		return
+	}
+	for i, name := range table.join.InlineTable.Columns {
+		if name == segments[1] {
+			return table, i, nil
+		}
+	}
+	return table, -1, utils.StackError(nil, "unknown column %s for inline table %s", segments[1], segments[0])
+}
+
+// rewriteInlineDimension rewrites dimension referencing inline table column to the join key
+// expression and records the value mapping for translating results.
+func (qc *QueryContext) rewriteInlineDimension(idx int, dim common.Dimension) expr.Expr {
+	vr, ok := dim.ExprParsed.(*expr.VarRef)
+	if !ok {
+		return dim.ExprParsed
+	}
+	table, column, err := qc.resolveInlineColumn(vr.Val)
+	if err != nil {
+		qc.Error = err
+		return dim.ExprParsed
+	}
+	if table == nil {
+		return dim.ExprParsed
+	}
+
+	if qc.IsNonAggregationQuery || qc.ReturnHLLBinary {
+		qc.Error = utils.StackError(nil, "inline table column %s is only supported in aggregation queries with json results", vr.Val)
+		return dim.ExprParsed
+	}
+	bucketizer := dim.NumericBucketizer
+	if dim.TimeBucketizer != "" || bucketizer.BucketWidth != 0 || bucketizer.LogBase != 0 || len(bucketizer.ManualPartitions) > 0 {
+		qc.Error = utils.StackError(nil, "bucketizer is not supported on inline table column %s", vr.Val)
+		return dim.ExprParsed
+	}
+
+	keyExpr, _ := expr.ParseExpr(table.keyExpr)
+	qc.DimensionValueMaps[idx] = table.valueMaps[column]
+	return keyExpr
+}
+
+// inlineTableValueString formats inline table value as dimension values in query results.
+func inlineTableValueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return common.NULLString
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	}
+	return fmt.Sprint(value)
+}
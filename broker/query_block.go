@@ -0,0 +1,184 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	apiCom "github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/cluster/topology"
+	dataCli "github.com/uber/aresdb/datanode/client"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	queryBlockTimeoutSeconds = 5
+)
+
+// QueryBlockResult is the result of placing or removing a query block cluster wide.
+type QueryBlockResult struct {
+	Block *queryCom.QueryBlock `json:"block,omitempty"`
+	// errors propagating the change to datanodes keyed by host id, the block is still
+	// enforced by broker for hosts failed.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// QueryBlockHandler blocks queries matching a table and fingerprint cluster wide, so that
+// a bad query overloading datanodes can be stopped without redeploying its clients. Blocks
+// are enforced by the broker and propagated to all datanodes in the topology to cover other
+// brokers and direct datanode queries. Blocks are kept in memory and expire on their own.
+type QueryBlockHandler struct {
+	blocklist *queryCom.QueryBlocklist
+	topo      topology.Topology
+	client    dataCli.DataNodeQueryBlockClient
+}
+
+// NewQueryBlockHandler creates a new QueryBlockHandler.
+func NewQueryBlockHandler(topo topology.Topology, client dataCli.DataNodeQueryBlockClient) *QueryBlockHandler {
+	return &QueryBlockHandler{
+		blocklist: queryCom.NewQueryBlocklist(),
+		topo:      topo,
+		client:    client,
+	}
+}
+
+// GetQueryBlocklist returns the query blocks enforced by the broker.
+func (handler *QueryBlockHandler) GetQueryBlocklist() *queryCom.QueryBlocklist {
+	return handler.blocklist
+}
+
+// Register registers http handlers.
+func (handler *QueryBlockHandler) Register(router *mux.Router, wrappers ...utils.HTTPHandlerWrapper) {
+	router.HandleFunc("/blocks", utils.ApplyHTTPWrappers(handler.ListQueryBlocks, wrappers)).Methods(http.MethodGet)
+	router.HandleFunc("/blocks", utils.ApplyHTTPWrappers(handler.BlockQueries, wrappers)).Methods(http.MethodPost)
+	router.HandleFunc("/blocks/{table}", utils.ApplyHTTPWrappers(handler.UnblockQueries, wrappers)).Methods(http.MethodDelete)
+}
+
+// ListQueryBlocks returns query blocks not expired yet.
+func (handler *QueryBlockHandler) ListQueryBlocks(w http.ResponseWriter, r *http.Request) {
+	apiCom.RespondWithJSONObject(w, handler.blocklist.List())
+}
+
+// BlockQueries blocks queries matching the request on the broker and all datanodes.
+func (handler *QueryBlockHandler) BlockQueries(w http.ResponseWriter, r *http.Request) {
+	var request apiCom.QueryBlockRequest
+	err := apiCom.ReadRequest(r, &request)
+	if err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	var block queryCom.QueryBlock
+	block, err = request.QueryBlock(utils.Now().Unix())
+	utils.AuditRequest(r, utils.AuditOpBlockQueries, block.Table, map[string]interface{}{
+		"fingerprint": block.Fingerprint,
+		"reason":      block.Reason,
+		"expiresAt":   block.ExpiresAt,
+	}, err)
+	if err != nil {
+		apiCom.RespondWithError(w, err)
+		return
+	}
+
+	handler.blocklist.Block(block)
+	utils.GetLogger().With("block", block).Info("Blocked queries")
+	result := QueryBlockResult{
+		Block: &block,
+		Errors: handler.propagate(r.Context(), func(ctx context.Context, host topology.Host) error {
+			return handler.client.Block(ctx, host, block)
+		}),
+	}
+	apiCom.RespondWithJSONObject(w, result)
+}
+
+// UnblockQueries removes the block on queries of the table with the fingerprint from the
+// broker and all datanodes.
+func (handler *QueryBlockHandler) UnblockQueries(w http.ResponseWriter, r *http.Request) {
+	var request apiCom.QueryUnblockRequest
+	err := apiCom.ReadRequest(r, &request)
+	if err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	if !handler.blocklist.Unblock(request.TableName, request.Fingerprint) {
+		err = apiCom.ErrQueryBlockDoesNotExist
+	}
+	utils.AuditRequest(r, utils.AuditOpUnblockQueries, request.TableName, map[string]interface{}{
+		"fingerprint": request.Fingerprint,
+	}, err)
+	if err != nil {
+		apiCom.RespondWithError(w, err)
+		return
+	}
+
+	result := QueryBlockResult{
+		Errors: handler.propagate(r.Context(), func(ctx context.Context, host topology.Host) error {
+			return handler.client.Unblock(ctx, host, request.TableName, request.Fingerprint)
+		}),
+	}
+	apiCom.RespondWithJSONObject(w, result)
+}
+
+// propagate calls fn on all datanodes in parallel and returns errors keyed by host id.
+func (handler *QueryBlockHandler) propagate(ctx context.Context, fn func(ctx context.Context, host topology.Host) error) map[string]string {
+	ctx, cancelFn := context.WithTimeout(ctx, queryBlockTimeoutSeconds*time.Second)
+	defer cancelFn()
+
+	var hostErrors map[string]string
+	var mutex sync.Mutex
+	wg := &sync.WaitGroup{}
+	for _, host := range handler.topo.Get().Hosts() {
+		wg.Add(1)
+		go func(host topology.Host) {
+			defer wg.Done()
+			if err := fn(ctx, host); err != nil {
+				utils.GetLogger().With("host", host.ID(), "error", err).Error("failed to propagate query block")
+				mutex.Lock()
+				if hostErrors == nil {
+					hostErrors = make(map[string]string)
+				}
+				hostErrors[host.ID()] = err.Error()
+				mutex.Unlock()
+			}
+		}(host)
+	}
+	wg.Wait()
+	return hostErrors
+}
+
+// checkQueryBlocks stamps the fingerprint of the query forwarded to datanodes and returns error
+// if the query is blocked.
+func checkQueryBlocks(blocklist *queryCom.QueryBlocklist, aql *queryCom.AQLQuery) error {
+	aql.Fingerprint = queryCom.QueryFingerprint(aql)
+	block := blocklist.Check(aql)
+	if block == nil {
+		return nil
+	}
+	utils.GetRootReporter().GetChildCounter(map[string]string{
+		"table": aql.Table,
+	}, utils.QueryBlockedBroker).Inc(1)
+	return utils.APIError{
+		Code:    http.StatusForbidden,
+		Message: apiCom.ErrMsgQueryBlocked,
+		Cause: utils.StackError(nil, "queries on table %s with fingerprint %s are blocked until %s: %s",
+			block.Table, block.Fingerprint, time.Unix(block.ExpiresAt, 0).UTC().Format(time.RFC3339), block.Reason),
+	}
+}
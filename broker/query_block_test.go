@@ -0,0 +1,117 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gorilla/mux"
+	m3Shard "github.com/m3db/m3/src/cluster/shard"
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	"github.com/uber/aresdb/cluster/shard"
+	"github.com/uber/aresdb/cluster/topology"
+	topoMock "github.com/uber/aresdb/cluster/topology/mocks"
+	dataCliMocks "github.com/uber/aresdb/datanode/client/mocks"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
+)
+
+var _ = ginkgo.Describe("query block", func() {
+	var testServer *httptest.Server
+	var handler *QueryBlockHandler
+	var mockClient *dataCliMocks.DataNodeQueryBlockClient
+	host1 := topology.NewHost("h1", "h1:9374")
+	host2 := topology.NewHost("h2", "h2:9374")
+
+	ginkgo.BeforeEach(func() {
+		topoMap := topology.NewStaticMap(topology.NewStaticOptions().
+			SetShardSet(shard.NewShardSet(shard.NewShards([]uint32{0, 1}, m3Shard.Available))).
+			SetReplicas(1).
+			SetHostShardSets([]topology.HostShardSet{
+				topology.NewHostShardSet(host1, shard.NewShardSet(shard.NewShards([]uint32{0}, m3Shard.Available))),
+				topology.NewHostShardSet(host2, shard.NewShardSet(shard.NewShards([]uint32{1}, m3Shard.Available))),
+			}))
+		mockTopo := &topoMock.HealthTrackingDynamicTopoloy{}
+		mockTopo.On("Get").Return(topoMap)
+		mockClient = &dataCliMocks.DataNodeQueryBlockClient{}
+
+		handler = NewQueryBlockHandler(mockTopo, mockClient)
+		router := mux.NewRouter()
+		handler.Register(router.PathPrefix("/query").Subrouter())
+		testServer = httptest.NewServer(router)
+	})
+
+	ginkgo.AfterEach(func() {
+		testServer.Close()
+	})
+
+	ginkgo.It("should block and unblock queries on all datanodes", func() {
+		mockClient.On("Block", mock.Anything, host1, mock.Anything).Return(nil).Once()
+		mockClient.On("Block", mock.Anything, host2, mock.Anything).Return(errors.New("failed to connect")).Once()
+		resp, err := http.Post(testServer.URL+"/query/blocks", "application/json",
+			bytes.NewBufferString(`{"table": "trips", "fingerprint": "abc", "reason": "bad dashboard", "durationSeconds": 600}`))
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		var result QueryBlockResult
+		Ω(json.NewDecoder(resp.Body).Decode(&result)).Should(BeNil())
+		Ω(result.Block.Table).Should(Equal("trips"))
+		Ω(result.Block.Fingerprint).Should(Equal("abc"))
+		Ω(result.Errors).Should(Equal(map[string]string{"h2": "failed to connect"}))
+		Ω(handler.GetQueryBlocklist().List()).Should(Equal([]queryCom.QueryBlock{*result.Block}))
+		mockClient.AssertExpectations(utils.TestingT)
+
+		mockClient.On("Unblock", mock.Anything, mock.Anything, "trips", "abc").Return(nil).Twice()
+		req, _ := http.NewRequest(http.MethodDelete, testServer.URL+"/query/blocks/trips?fingerprint=abc", nil)
+		resp, err = http.DefaultClient.Do(req)
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+		Ω(handler.GetQueryBlocklist().List()).Should(BeEmpty())
+		mockClient.AssertExpectations(utils.TestingT)
+
+		resp, err = http.DefaultClient.Do(req)
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusNotFound))
+	})
+
+	ginkgo.It("should reject invalid blocks", func() {
+		resp, err := http.Post(testServer.URL+"/query/blocks", "application/json",
+			bytes.NewBufferString(`{"table": "trips"}`))
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusBadRequest))
+		Ω(handler.GetQueryBlocklist().List()).Should(BeEmpty())
+	})
+
+	ginkgo.It("checkQueryBlocks should stamp fingerprint and reject blocked queries", func() {
+		aql := &queryCom.AQLQuery{
+			Table:    "trips",
+			Measures: []queryCom.Measure{{Expr: "count(*)"}},
+		}
+		blocklist := queryCom.NewQueryBlocklist()
+		Ω(checkQueryBlocks(blocklist, aql)).Should(BeNil())
+		Ω(aql.Fingerprint).Should(Equal(queryCom.QueryFingerprint(aql)))
+
+		blocklist.Block(queryCom.QueryBlock{Table: "trips", Fingerprint: aql.Fingerprint, Reason: "bad dashboard", ExpiresAt: utils.Now().Unix() + 60})
+		err := checkQueryBlocks(blocklist, aql)
+		Ω(err).ShouldNot(BeNil())
+		Ω(err.(utils.APIError).Code).Should(Equal(http.StatusForbidden))
+		Ω(err.Error()).Should(ContainSubstring("bad dashboard"))
+	})
+})
@@ -15,6 +15,8 @@
 package broker
 
 import (
+	"fmt"
+	"github.com/uber/aresdb/cluster/topology"
 	memCom "github.com/uber/aresdb/memstore/common"
 	metaCom "github.com/uber/aresdb/metastore/common"
 	"github.com/uber/aresdb/query/common"
@@ -23,6 +25,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -48,6 +51,38 @@ type QueryContext struct {
 	DimensionVectorIndex []int
 	DimRowBytes          int
 	RequestID            string
+	// max number of distinct values of each dimension allowed when merging datanode results,
+	// 0 means no limit
+	MaxDimensionCardinality int
+	// timeouts of scattering the query to datanodes and merging results
+	Timeouts QueryTimeouts
+	// datanode hosts preferred for routing the query, nil means all hosts are preferred
+	PreferredHosts func(topology.Host) bool
+	// authenticated principal of the query, nil means table ACLs are not enforced
+	Principal *utils.Principal
+	// inline tables joined by broker by alias
+	InlineTables map[string]*inlineTable
+	// lookup table from dimension index to value mapping of inline table column, used for
+	// postprocessing after enum translation
+	DimensionValueMaps map[int]map[string]string
+	// warnings found when compiling the query, e.g. unknown enum values in filters
+	Warnings []string
+	// enum columns whose cached dictionary misses enum values referenced by the query
+	UnknownEnumColumns []enumColumn
+	// consecutive time ranges the aggregation query is split into and executed separately,
+	// nil means the query is not split
+	TimeRangeChunks []common.TimeFilter
+	// max number of time range chunks executed concurrently, 0 means no limit
+	TimeRangeParallelism int
+	// z-score outlier detection applied to time series of the merged results, nil means no detection
+	OutlierDetection *zScoreOutliers
+	// forecast of future buckets of time series appended to the merged results, nil means no forecast
+	TimeSeriesForecast *forecaster
+	// top groups of aggregation queries with limit kept in the merged results, nil means all groups
+	TopN *topN
+
+	// resolved time range of the time filter, nil if not specified
+	fromTime, toTime *common.AlignedTime
 }
 
 // NewQueryContext creates new query context
@@ -57,6 +92,7 @@ func NewQueryContext(aql *common.AQLQuery, returnHLLBinary bool, w http.Response
 		ReturnHLLBinary:           returnHLLBinary,
 		Writer:                    w,
 		DimensionEnumReverseDicts: make(map[int][]string),
+		DimensionValueMaps:        make(map[int]map[string]string),
 	}
 	return &ctx
 }
@@ -64,6 +100,15 @@ func NewQueryContext(aql *common.AQLQuery, returnHLLBinary bool, w http.Response
 // GetRewrittenQuery get the rewritten query after query parsing
 func (qc *QueryContext) GetRewrittenQuery() common.AQLQuery {
 	newQuery := *qc.AQLQuery
+	// forecast is computed by broker only.
+	newQuery.Forecast = nil
+	// datanodes only serve the query with the same schemas as the query is compiled against.
+	if len(qc.TableSchemaByName) > 0 {
+		newQuery.SchemaVersions = make(map[string]int, len(qc.TableSchemaByName))
+		for tableName, schema := range qc.TableSchemaByName {
+			newQuery.SchemaVersions[tableName] = schema.Schema.Version
+		}
+	}
 	for i, measure := range newQuery.Measures {
 		if measure.ExprParsed != nil {
 			measure.Expr = measure.ExprParsed.String()
@@ -112,8 +157,30 @@ func (qc *QueryContext) GetRewrittenQuery() common.AQLQuery {
 // Compile parses expressions into ast, load schema from schema reader, resolve types,
 // and collects meta data needed by post processing
 func (qc *QueryContext) Compile(tableSchemaReader memCom.TableSchemaReader) {
+	if qc.AQLQuery.ResultFormat != "" && qc.AQLQuery.ResultFormat != common.ResultFormatColumnMajor {
+		qc.Error = utils.StackError(nil, "unknown result format %s", qc.AQLQuery.ResultFormat)
+		return
+	}
+	if !common.IsValidDataScope(qc.AQLQuery.DataScope) {
+		qc.Error = utils.StackError(nil, "unknown data scope %s", qc.AQLQuery.DataScope)
+		return
+	}
+	if !common.IsValidUnknownEnumValuePolicy(qc.AQLQuery.UnknownEnumValue) {
+		qc.Error = utils.StackError(nil, "unknown enum value policy %s", qc.AQLQuery.UnknownEnumValue)
+		return
+	}
+
+	qc.resolveTimeFilter()
+	if qc.Error != nil {
+		return
+	}
+
+	qc.extractInlineTables()
+	if qc.Error != nil {
+		return
+	}
+
 	qc.readSchema(tableSchemaReader)
-	defer qc.releaseSchema()
 	if qc.Error != nil {
 		return
 	}
@@ -123,6 +190,11 @@ func (qc *QueryContext) Compile(tableSchemaReader memCom.TableSchemaReader) {
 		return
 	}
 
+	qc.processInlineTables()
+	if qc.Error != nil {
+		return
+	}
+
 	qc.processMeasures()
 	if qc.Error != nil {
 		return
@@ -132,6 +204,21 @@ func (qc *QueryContext) Compile(tableSchemaReader memCom.TableSchemaReader) {
 		return
 	}
 
+	qc.processOutlierDetection()
+	if qc.Error != nil {
+		return
+	}
+
+	qc.processForecast()
+	if qc.Error != nil {
+		return
+	}
+
+	qc.processTopN()
+	if qc.Error != nil {
+		return
+	}
+
 	qc.processFilters()
 	if qc.Error != nil {
 		return
@@ -141,6 +228,31 @@ func (qc *QueryContext) Compile(tableSchemaReader memCom.TableSchemaReader) {
 	return
 }
 
+// resolveTimeFilter resolves relative expressions in the time filter (e.g. now(), -7d, this
+// quarter) into timestamps in the query timezone, so that all datanodes and time range chunks
+// see the same time range. Queries with timezones from a column are resolved in UTC, same as
+// datanodes parse their time filters.
+func (qc *QueryContext) resolveTimeFilter() {
+	loc := time.UTC
+	if qc.AQLQuery.Timezone != "" {
+		if fixed, err := common.ParseTimezone(qc.AQLQuery.Timezone); err == nil {
+			loc = fixed
+		}
+	}
+	now := utils.Now()
+	if qc.AQLQuery.Now > 0 {
+		now = time.Unix(qc.AQLQuery.Now, 0)
+	}
+
+	resolved, err := common.ResolveTimeFilter(qc.AQLQuery.TimeFilter, loc, now)
+	if err != nil {
+		qc.Error = utils.StackError(err, "invalid time filter")
+		return
+	}
+	qc.AQLQuery.TimeFilter = resolved
+	qc.fromTime, qc.toTime, _ = common.ParseTimeFilter(resolved, loc, now)
+}
+
 func (qc *QueryContext) readSchema(tableSchemaReader memCom.TableSchemaReader) {
 	qc.Tables = make([]*memCom.TableSchema, 1+len(qc.AQLQuery.Joins))
 	qc.TableIDByAlias = make(map[string]int)
@@ -159,9 +271,14 @@ func (qc *QueryContext) readSchema(tableSchemaReader memCom.TableSchemaReader) {
 		qc.Error = utils.StackError(err, "unknown main table %s", qc.AQLQuery.Table)
 		return
 	}
+	// compile against immutable snapshots so that schema writers are never blocked.
+	schema = schema.Snapshot()
 	qc.TableSchemaByName[qc.AQLQuery.Table] = schema
-	schema.RLock()
 	qc.Tables[0] = schema
+	if !qc.canReadTable(schema) {
+		qc.Error = qc.accessDenied("table %s", qc.AQLQuery.Table)
+		return
+	}
 
 	qc.TableIDByAlias[qc.AQLQuery.Table] = 0
 
@@ -173,10 +290,11 @@ func (qc *QueryContext) readSchema(tableSchemaReader memCom.TableSchemaReader) {
 			return
 		}
 
-		if qc.TableSchemaByName[join.Table] == nil {
-			qc.TableSchemaByName[join.Table] = schema
-			// Prevent double locking.
-			schema.RLock()
+		schema = schema.Snapshot()
+		qc.TableSchemaByName[join.Table] = schema
+		if !qc.canReadTable(schema) {
+			qc.Error = qc.accessDenied("table %s", join.Table)
+			return
 		}
 
 		qc.Tables[1+i] = schema
@@ -194,9 +312,20 @@ func (qc *QueryContext) readSchema(tableSchemaReader memCom.TableSchemaReader) {
 	}
 }
 
-func (qc *QueryContext) releaseSchema() {
-	for _, schema := range qc.TableSchemaByName {
-		schema.RUnlock()
+// canReadTable checks the read permission of the principal on the table.
+func (qc *QueryContext) canReadTable(schema *memCom.TableSchema) bool {
+	return qc.Principal == nil || schema.Schema.ACL.CanRead(qc.Principal.Identities())
+}
+
+// canReadColumn checks whether the column is restricted for the principal.
+func (qc *QueryContext) canReadColumn(schema *memCom.TableSchema, column string) bool {
+	return qc.Principal == nil || schema.Schema.ACL.CanReadColumn(column, qc.Principal.Identities())
+}
+
+func (qc *QueryContext) accessDenied(format string, args ...interface{}) error {
+	return utils.APIError{
+		Code:    http.StatusForbidden,
+		Message: fmt.Sprintf("principal %s is not allowed to read %s", qc.Principal.Name, fmt.Sprintf(format, args...)),
 	}
 }
 
@@ -216,6 +345,9 @@ func (qc *QueryContext) resolveColumn(identifier string) (int, int, error) {
 
 	columnID, exists := qc.Tables[tableID].ColumnIDs[column]
 	if !exists {
+		if systemColumn, isSystemColumn := memCom.GetSystemColumn(column); isSystemColumn && tableID == 0 {
+			return tableID, systemColumn.ID, nil
+		}
 		return 0, 0, utils.StackError(nil, "unknown column %s for table alias %s",
 			column, tableAlias)
 	}
@@ -259,6 +391,11 @@ func (qc *QueryContext) processFilters() {
 	}
 
 	qc.AQLQuery.FiltersParsed = normalizeAndFilters(qc.AQLQuery.FiltersParsed)
+	if qc.AQLQuery.TriValuedLogic {
+		for i, filter := range qc.AQLQuery.FiltersParsed {
+			qc.AQLQuery.FiltersParsed[i] = common.RewriteKleeneAnds(filter)
+		}
+	}
 }
 
 func (qc *QueryContext) processMeasures() {
@@ -270,10 +407,18 @@ func (qc *QueryContext) processMeasures() {
 			qc.Error = utils.StackError(err, "Failed to parse measure: %s", measure.Expr)
 			return
 		}
+		qc.extractOutlierDetection(&measure)
+		if qc.Error != nil {
+			return
+		}
 		measure.ExprParsed = expr.Rewrite(qc, measure.ExprParsed)
 		if qc.Error != nil {
 			return
 		}
+		qc.checkNoSystemColumn(measure.ExprParsed)
+		if qc.Error != nil {
+			return
+		}
 
 		measure.FiltersParsed = make([]expr.Expr, len(measure.Filters))
 		for j, filter := range measure.Filters {
@@ -288,6 +433,12 @@ func (qc *QueryContext) processMeasures() {
 			}
 		}
 		measure.FiltersParsed = normalizeAndFilters(measure.FiltersParsed)
+		if qc.AQLQuery.TriValuedLogic {
+			measure.ExprParsed = common.RewriteKleeneAnds(measure.ExprParsed)
+			for j, filter := range measure.FiltersParsed {
+				measure.FiltersParsed[j] = common.RewriteKleeneAnds(filter)
+			}
+		}
 		qc.AQLQuery.Measures[i] = measure
 	}
 
@@ -348,7 +499,25 @@ func (qc *QueryContext) processDimensions() {
 	}
 
 	for idx, dim := range qc.AQLQuery.Dimensions {
-		dim.ExprParsed = expr.Rewrite(qc, dim.ExprParsed)
+		dim.ExprParsed = qc.rewriteInlineDimension(idx, dim)
+		if qc.Error != nil {
+			return
+		}
+		if call, ok := dim.ExprParsed.(*expr.Call); ok && strings.ToLower(call.Name) == expr.MapValuesCallName {
+			dim.ExprParsed = qc.rewriteMapValuesDimension(idx, call)
+		} else {
+			dim.ExprParsed = expr.Rewrite(qc, dim.ExprParsed)
+		}
+		if qc.Error != nil {
+			return
+		}
+		qc.checkNoSystemColumn(dim.ExprParsed)
+		if qc.Error != nil {
+			return
+		}
+		if qc.AQLQuery.TriValuedLogic {
+			dim.ExprParsed = common.RewriteKleeneAnds(dim.ExprParsed)
+		}
 		if vr, ok := dim.ExprParsed.(*expr.VarRef); ok {
 			if len(vr.EnumReverseDict) > 0 {
 				qc.DimensionEnumReverseDicts[idx] = vr.EnumReverseDict
@@ -358,6 +527,40 @@ func (qc *QueryContext) processDimensions() {
 	}
 }
 
+// rewriteMapValuesDimension rewrites map_values dimension to its enum column sent to datanodes and
+// records the groups of enum values for translating results.
+func (qc *QueryContext) rewriteMapValuesDimension(idx int, call *expr.Call) expr.Expr {
+	if qc.ReturnHLLBinary {
+		qc.Error = utils.StackError(nil, "map_values is not supported in hll binary results")
+		return call
+	}
+	for i, arg := range call.Args {
+		call.Args[i] = expr.Rewrite(qc, arg)
+		if qc.Error != nil {
+			return call
+		}
+	}
+	column, groups, err := common.RewriteMapValues(call)
+	if err != nil {
+		qc.Error = err
+		return call
+	}
+
+	// rows of non aggregation queries are translated one by one.
+	if qc.IsNonAggregationQuery {
+		grouped := *column
+		grouped.EnumReverseDict = groups
+		return &grouped
+	}
+	// results of enum values in the same group are merged after translation.
+	valueMap := make(map[string]string, len(groups))
+	for rank, value := range column.EnumReverseDict {
+		valueMap[value] = groups[rank]
+	}
+	qc.DimensionValueMaps[idx] = valueMap
+	return column
+}
+
 func (qc *QueryContext) sortDimensionColumns() {
 	orderedIndex := 0
 	numDimensions := len(qc.AQLQuery.Dimensions)
@@ -382,9 +585,11 @@ func (qc *QueryContext) sortDimensionColumns() {
 }
 
 func (qc *QueryContext) getAllColumnsDimension() (columns []common.Dimension) {
-	// only main table columns wildcard match supported
+	// only main table columns wildcard match supported, restricted columns and the hidden ingestion source
+	// column are skipped
 	for _, column := range qc.Tables[0].Schema.Columns {
-		if !column.Deleted && column.Type != metaCom.GeoShape {
+		if !column.Deleted && column.Type != metaCom.GeoShape && column.Name != metaCom.IngestionSourceColumnName &&
+			qc.canReadColumn(qc.Tables[0], column.Name) {
 			columns = append(columns, common.Dimension{
 				Expr:       column.Name,
 				ExprParsed: &expr.VarRef{Val: column.Name},
@@ -403,17 +608,32 @@ func (qc *QueryContext) Rewrite(expression expr.Expr) expr.Expr {
 		// Strip parenthesis from the input
 		return e.Expr
 	case *expr.VarRef:
+		if table, _, _ := qc.resolveInlineColumn(e.Val); table != nil {
+			qc.Error = utils.StackError(nil, "inline table column %s can only be used as dimension", e.Val)
+			return expression
+		}
 		tableID, columnID, err := qc.resolveColumn(e.Val)
 		if err != nil {
 			qc.Error = err
 			return expression
 		}
+		if memCom.IsSystemColumn(columnID) {
+			e.DataType = memCom.GetSystemColumnType(columnID)
+			e.ExprType = common.DataTypeToExprType[e.DataType]
+			e.TableID = tableID
+			e.ColumnID = columnID
+			return expression
+		}
 		column := qc.Tables[tableID].Schema.Columns[columnID]
 		if column.Deleted {
 			qc.Error = utils.StackError(nil, "column %s of table %s has been deleted",
 				column.Name, qc.Tables[tableID].Schema.Name)
 			return expression
 		}
+		if !qc.canReadColumn(qc.Tables[tableID], column.Name) {
+			qc.Error = qc.accessDenied("restricted column %s of table %s", column.Name, qc.Tables[tableID].Schema.Name)
+			return expression
+		}
 		dataType := qc.Tables[tableID].ValueTypeByColumn[columnID]
 		e.ExprType = common.DataTypeToExprType[dataType]
 		e.TableID = tableID
@@ -429,11 +649,25 @@ func (qc *QueryContext) Rewrite(expression expr.Expr) expr.Expr {
 			return expression
 		}
 
-		if err := blockNumericOpsForColumnOverFourBytes(e.Op, e.Expr); err != nil {
+		if err := common.BlockNumericOpsForColumnOverFourBytes(e.Op, e.Expr); err != nil {
+			qc.Error = err
+			return expression
+		}
+
+		if err := common.BlockOpsForStringColumn(e.Op, e.Expr); err != nil {
 			qc.Error = err
 			return expression
 		}
 
+		if qc.AQLQuery.TriValuedLogic {
+			if e.Op == expr.IS_TRUE || e.Op == expr.IS_FALSE {
+				return common.RewriteTriValuedIs(e)
+			}
+			if rewritten, ok := common.RewriteTriValuedNull(e); ok {
+				return rewritten
+			}
+		}
+
 		e.ExprType = e.Expr.Type()
 		switch e.Op {
 		case expr.EXCLAMATION, expr.NOT, expr.IS_FALSE:
@@ -484,7 +718,18 @@ func (qc *QueryContext) Rewrite(expression expr.Expr) expr.Expr {
 				e.String())
 		}
 	case *expr.BinaryExpr:
-		if err := blockNumericOpsForColumnOverFourBytes(e.Op, e.LHS, e.RHS); err != nil {
+		if qc.AQLQuery.TriValuedLogic {
+			if rewritten, ok := common.RewriteTriValuedNull(e); ok {
+				return rewritten
+			}
+		}
+
+		if err := common.BlockNumericOpsForColumnOverFourBytes(e.Op, e.LHS, e.RHS); err != nil {
+			qc.Error = err
+			return expression
+		}
+
+		if err := common.BlockOpsForStringColumn(e.Op, e.LHS, e.RHS); err != nil {
 			qc.Error = err
 			return expression
 		}
@@ -569,6 +814,8 @@ func (qc *QueryContext) Rewrite(expression expr.Expr) expr.Expr {
 					// short circuiting hard.
 					// To play it safe we match against an invalid value.
 					value = -1
+					qc.addUnknownEnumColumn(lhs)
+					qc.checkUnknownEnumValue(lhs.Val, rhs.Val)
 				}
 				e.RHS = &expr.NumberLiteral{Int: value, ExprType: expr.Unsigned}
 				break
@@ -602,45 +849,12 @@ func (qc *QueryContext) Rewrite(expression expr.Expr) expr.Expr {
 		e.Name = strings.ToLower(e.Name)
 		switch e.Name {
 		case expr.ConvertTzCallName:
-			if len(e.Args) != 3 {
-				qc.Error = utils.StackError(
-					nil, "convert_tz must have 3 arguments",
-				)
-				break
-			}
-			fromTzStringExpr, isStrLiteral := e.Args[1].(*expr.StringLiteral)
-			if !isStrLiteral {
-				qc.Error = utils.StackError(nil, "2nd argument of convert_tz must be a string")
-				break
-			}
-			toTzStringExpr, isStrLiteral := e.Args[2].(*expr.StringLiteral)
-			if !isStrLiteral {
-				qc.Error = utils.StackError(nil, "3rd argument of convert_tz must be a string")
-				break
-			}
-			fromTz, err := common.ParseTimezone(fromTzStringExpr.Val)
+			rewritten, err := common.RewriteConvertTz(e, qc.AQLQuery.Strict, qc.fromTime, qc.toTime)
 			if err != nil {
-				qc.Error = utils.StackError(err, "failed to rewrite convert_tz")
+				qc.Error = err
 				break
 			}
-			toTz, err := common.ParseTimezone(toTzStringExpr.Val)
-			if err != nil {
-				qc.Error = utils.StackError(err, "failed to rewrite convert_tz")
-				break
-			}
-			_, fromOffsetInSeconds := utils.Now().In(fromTz).Zone()
-			_, toOffsetInSeconds := utils.Now().In(toTz).Zone()
-			offsetInSeconds := toOffsetInSeconds - fromOffsetInSeconds
-			return &expr.BinaryExpr{
-				Op:  expr.ADD,
-				LHS: e.Args[0],
-				RHS: &expr.NumberLiteral{
-					Int:      offsetInSeconds,
-					Expr:     strconv.Itoa(offsetInSeconds),
-					ExprType: expr.Unsigned,
-				},
-				ExprType: expr.Unsigned,
-			}
+			return rewritten
 		case expr.CountCallName:
 			e.ExprType = expr.Unsigned
 		case expr.DayOfWeekCallName:
@@ -691,28 +905,12 @@ func (qc *QueryContext) Rewrite(expression expr.Expr) expr.Expr {
 			}
 			// no-op, this will be over written
 		case expr.FromUnixTimeCallName:
-			// for now, only the following format is allowed for backward compatibility
-			// from_unixtime(time_col / 1000)
-			timeColumnDivideErrMsg := "from_unixtime must be time column / 1000"
-			timeColDivide, isBinary := e.Args[0].(*expr.BinaryExpr)
-			if !isBinary || timeColDivide.Op != expr.DIV {
-				qc.Error = utils.StackError(nil, timeColumnDivideErrMsg)
-				break
-			}
-			divisor, isLiteral := timeColDivide.RHS.(*expr.NumberLiteral)
-			if !isLiteral || divisor.Int != 1000 {
-				qc.Error = utils.StackError(nil, timeColumnDivideErrMsg)
-				break
-			}
-			if par, isParen := timeColDivide.LHS.(*expr.ParenExpr); isParen {
-				timeColDivide.LHS = par.Expr
-			}
-			timeColExpr, isVarRef := timeColDivide.LHS.(*expr.VarRef)
-			if !isVarRef {
-				qc.Error = utils.StackError(nil, timeColumnDivideErrMsg)
+			rewritten, err := common.RewriteFromUnixTime(e, qc.AQLQuery.Strict)
+			if err != nil {
+				qc.Error = err
 				break
 			}
-			return timeColExpr
+			return rewritten
 		case expr.HourCallName:
 			if len(e.Args) != 1 {
 				qc.Error = utils.StackError(nil, "hour takes exactly 1 argument")
@@ -805,6 +1003,12 @@ func (qc *QueryContext) Rewrite(expression expr.Expr) expr.Expr {
 					nil, "expect 1 argument to be a column for %s", e.Name)
 				break
 			}
+			// ids of String values are not consistent across data nodes.
+			if colRef.DataType == memCom.String {
+				qc.Error = utils.StackError(
+					nil, "String column %s is not supported by %s", colRef.Val, e.Name)
+				break
+			}
 
 			e.Name = expr.HllCallName
 			// 1. noop when column itself is hll column
@@ -837,6 +1041,11 @@ func (qc *QueryContext) Rewrite(expression expr.Expr) expr.Expr {
 					nil, "expect 1 argument for %s, but got %s", e.Name, e.String())
 				break
 			}
+			if common.IsStringColumn(e.Args[0]) {
+				qc.Error = utils.StackError(
+					nil, "String column %s is not supported by %s", e.Args[0].String(), e.Name)
+				break
+			}
 			// For avg, the expression type should always be float.
 			if e.Name == expr.AvgCallName {
 				e.Args[0] = expr.Cast(e.Args[0], expr.Float)
@@ -884,6 +1093,8 @@ func (qc *QueryContext) Rewrite(expression expr.Expr) expr.Expr {
 				e.ExprType = vr.ExprType
 			}
 
+		case expr.MapValuesCallName:
+			qc.Error = utils.StackError(nil, "map_values is only supported as a dimension")
 		default:
 			qc.Error = utils.StackError(nil, "unknown function %s", e.Name)
 		}
@@ -907,16 +1118,49 @@ func (qc *QueryContext) Rewrite(expression expr.Expr) expr.Expr {
 }
 
 // TODO: remove dup in aql_compiler.go
-func blockNumericOpsForColumnOverFourBytes(token expr.Token, expressions ...expr.Expr) error {
-	if token == expr.UNARY_MINUS || token == expr.BITWISE_NOT ||
-		(token >= expr.ADD && token <= expr.BITWISE_LEFT_SHIFT) {
-		for _, expression := range expressions {
-			if varRef, isVarRef := expression.(*expr.VarRef); isVarRef && memCom.DataTypeBytes(varRef.DataType) > 4 {
-				return utils.StackError(nil, "numeric operations not supported for column over 4 bytes length, got %s", expression.String())
-			}
+// systemColumnDetector detects system columns involved in AST.
+type systemColumnDetector struct {
+	systemColumn *expr.VarRef
+}
+
+func (c *systemColumnDetector) Visit(expression expr.Expr) expr.Visitor {
+	if e, ok := expression.(*expr.VarRef); ok && c.systemColumn == nil && memCom.IsSystemColumn(e.ColumnID) {
+		c.systemColumn = e
+	}
+	return c
+}
+
+// checkNoSystemColumn reports error if system columns are used outside of filters.
+func (qc *QueryContext) checkNoSystemColumn(expression expr.Expr) {
+	detector := systemColumnDetector{}
+	expr.Walk(&detector, expression)
+	if detector.systemColumn != nil {
+		qc.Error = utils.StackError(nil, "system column %s can only be used in filters", detector.systemColumn.Val)
+	}
+}
+
+// checkUnknownEnumValue applies the unknown enum value policy of the query to a value missing
+// from the enum dictionary of the column.
+func (qc *QueryContext) checkUnknownEnumValue(column, value string) {
+	warning, err := qc.AQLQuery.CheckUnknownEnumValue(column, value)
+	if err != nil {
+		qc.Error = err
+	} else if warning != "" && utils.IndexOfStr(qc.Warnings, warning) < 0 {
+		qc.Warnings = append(qc.Warnings, warning)
+	}
+}
+
+// addUnknownEnumColumn records the enum column missing an enum value referenced by the query, so
+// that its dictionary can be re-fetched in case it is stale.
+func (qc *QueryContext) addUnknownEnumColumn(varRef *expr.VarRef) {
+	table := qc.Tables[varRef.TableID].Schema
+	column := enumColumn{table: table.Name, column: table.Columns[varRef.ColumnID].Name}
+	for _, existing := range qc.UnknownEnumColumns {
+		if existing == column {
+			return
 		}
 	}
-	return nil
+	qc.UnknownEnumColumns = append(qc.UnknownEnumColumns, column)
 }
 
 func (qc *QueryContext) expandINop(e *expr.BinaryExpr) (expandedExpr expr.Expr) {
@@ -935,7 +1179,7 @@ func (qc *QueryContext) expandINop(e *expr.BinaryExpr) (expandedExpr expr.Expr)
 					Op:  expr.EQ,
 					LHS: lhs,
 					RHS: value,
-				}).(*expr.BinaryExpr)
+				})
 			default:
 				lastExpr := expandedExpr
 				expandedExpr = &expr.BinaryExpr{
@@ -945,7 +1189,7 @@ func (qc *QueryContext) expandINop(e *expr.BinaryExpr) (expandedExpr expr.Expr)
 						Op:  expr.EQ,
 						LHS: lhs,
 						RHS: value,
-					}).(*expr.BinaryExpr),
+					}),
 				}
 			}
 		}
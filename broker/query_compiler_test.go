@@ -22,10 +22,14 @@ import (
 	"github.com/stretchr/testify/mock"
 	memCom "github.com/uber/aresdb/memstore/common"
 	memComMocks "github.com/uber/aresdb/memstore/common/mocks"
+	memComTestUtil "github.com/uber/aresdb/memstore/common/testutil"
 	metaCom "github.com/uber/aresdb/metastore/common"
 	"github.com/uber/aresdb/query/common"
 	"github.com/uber/aresdb/query/expr"
+	"github.com/uber/aresdb/utils"
+	"net/http"
 	"net/http/httptest"
+	"time"
 )
 
 var _ = ginkgo.Describe("query compiler", func() {
@@ -35,6 +39,7 @@ var _ = ginkgo.Describe("query compiler", func() {
 			{Name: "field1", Type: "Uint32"},
 			{Name: "field2", Type: "Uint16"},
 		},
+		Version: 3,
 	}
 	tableSchema1 := memCom.NewTableSchema(table1)
 
@@ -120,8 +125,9 @@ var _ = ginkgo.Describe("query compiler", func() {
 					FiltersParsed: []expr.Expr{},
 				},
 			},
-			FiltersParsed: []expr.Expr{},
-			SQLQuery:      "SELECT count(*) FROM table1 JOIN table2 ON table1.field2 = table2.field2 GROUP BY field1",
+			FiltersParsed:  []expr.Expr{},
+			SQLQuery:       "SELECT count(*) FROM table1 JOIN table2 ON table1.field2 = table2.field2 GROUP BY field1",
+			SchemaVersions: map[string]int{"table1": 3, "table2": 0},
 		}))
 
 		Ω(qc.NumDimsPerDimWidth).Should(Equal(common.DimCountsPerDimWidth{0, 0, 1, 0, 0}))
@@ -161,9 +167,10 @@ var _ = ginkgo.Describe("query compiler", func() {
 					FiltersParsed: []expr.Expr{},
 				},
 			},
-			FiltersParsed: []expr.Expr{},
-			Limit:         nonAggregationQueryLimit,
-			SQLQuery:      "SELECT * FROM table1",
+			FiltersParsed:  []expr.Expr{},
+			Limit:          nonAggregationQueryLimit,
+			SQLQuery:       "SELECT * FROM table1",
+			SchemaVersions: map[string]int{"table1": 3},
 		}))
 	})
 
@@ -354,10 +361,22 @@ var _ = ginkgo.Describe("query compiler", func() {
 		qc.processFilters()
 		Ω(qc.Error).Should(BeNil())
 		Ω(qc.AQLQuery.FiltersParsed[0].String()).Should(Equal("NOT(id = 1 OR id = 2 OR id = 3)"))
+
+		// comparisons with NULL are null in tri-valued logic mode.
+		qc.AQLQuery.TriValuedLogic = true
+		qc.AQLQuery.Filters[0] = "id not in (1, NULL)"
+		qc.processFilters()
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.AQLQuery.FiltersParsed[0].String()).Should(Equal("NOT(id = 1 OR NULL)"))
+
+		qc.AQLQuery.Filters[0] = "id in (NULL, 2)"
+		qc.processFilters()
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.AQLQuery.FiltersParsed[0].String()).Should(Equal("NULL OR id = 2"))
 	})
 
 	ginkgo.It("rewrite should work", func() {
-		qc := QueryContext{}
+		qc := QueryContext{AQLQuery: &common.AQLQuery{}}
 
 		// paren
 		Ω(qc.Rewrite(&expr.ParenExpr{Expr: &expr.StringLiteral{Val: "foo"}})).Should(Equal(&expr.StringLiteral{Val: "foo"}))
@@ -621,6 +640,41 @@ var _ = ginkgo.Describe("query compiler", func() {
 		}))
 	})
 
+	ginkgo.It("rewrite should apply unknown enum value policy", func() {
+		unknownEnum := func() *expr.BinaryExpr {
+			return &expr.BinaryExpr{
+				Op:  expr.EQ,
+				LHS: &expr.VarRef{Val: "f", ExprType: expr.Unsigned, EnumDict: map[string]int{"foo": 1}},
+				RHS: &expr.StringLiteral{Val: "bar"},
+			}
+		}
+
+		tables := []*memCom.TableSchema{
+			{Schema: metaCom.Table{Name: "t", Columns: []metaCom.Column{{Name: "f"}}}},
+		}
+
+		qc := QueryContext{AQLQuery: &common.AQLQuery{}, Tables: tables}
+		Ω(qc.Rewrite(unknownEnum()).(*expr.BinaryExpr).RHS).Should(Equal(&expr.NumberLiteral{Int: -1, ExprType: expr.Unsigned}))
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.Warnings).Should(BeEmpty())
+		Ω(qc.UnknownEnumColumns).Should(Equal([]enumColumn{{table: "t", column: "f"}}))
+
+		qc = QueryContext{AQLQuery: &common.AQLQuery{UnknownEnumValue: common.UnknownEnumValueWarn}, Tables: tables}
+		qc.Rewrite(unknownEnum())
+		qc.Rewrite(unknownEnum())
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.Warnings).Should(Equal([]string{`unknown enum value "bar" of column f matches nothing`}))
+
+		qc = QueryContext{AQLQuery: &common.AQLQuery{UnknownEnumValue: common.UnknownEnumValueFail}, Tables: tables}
+		qc.Rewrite(unknownEnum())
+		Ω(qc.Error).ShouldNot(BeNil())
+		Ω(qc.Warnings).Should(BeEmpty())
+
+		qc = QueryContext{AQLQuery: &common.AQLQuery{Table: "table1", UnknownEnumValue: "drop"}}
+		qc.Compile(nil)
+		Ω(qc.Error.Error()).Should(ContainSubstring("unknown enum value policy drop"))
+	})
+
 	ginkgo.It("rewrite should fail", func() {
 		qc := QueryContext{
 			TableIDByAlias: map[string]int{"t": 0},
@@ -671,6 +725,21 @@ var _ = ginkgo.Describe("query compiler", func() {
 		})
 		Ω(qc.Error.Error()).Should(ContainSubstring("string type only support EQ and NEQ operators"))
 
+		// String column
+		qc.Error = nil
+		qc.Rewrite(&expr.BinaryExpr{
+			Op:  expr.LT,
+			LHS: &expr.VarRef{Val: "s", DataType: memCom.String},
+			RHS: &expr.StringLiteral{Val: "foo"},
+		})
+		Ω(qc.Error.Error()).Should(ContainSubstring("String column s only supports EQ, NEQ, IN and null checks"))
+		qc.Error = nil
+		qc.Rewrite(&expr.Call{
+			Name: expr.SumCallName,
+			Args: []expr.Expr{&expr.VarRef{Val: "s", DataType: memCom.String}},
+		})
+		Ω(qc.Error.Error()).Should(ContainSubstring("String column s is not supported by sum"))
+
 		// call
 		qc.Error = nil
 		qc.Rewrite(&expr.Call{
@@ -730,6 +799,40 @@ var _ = ginkgo.Describe("query compiler", func() {
 		Ω(qc.Error.Error()).Should(ContainSubstring("from_unixtime must be time column / 1000"))
 	})
 
+	ginkgo.It("resolveTimeFilter should resolve relative time expressions", func() {
+		utils.SetClockImplementation(func() time.Time {
+			// 2019-03-01 12:30:00 UTC.
+			return time.Unix(1551443400, 0)
+		})
+		defer utils.ResetClockImplementation()
+
+		qc := QueryContext{AQLQuery: &common.AQLQuery{
+			Table:      "table1",
+			TimeFilter: common.TimeFilter{Column: "field1", From: "-7d", To: "now()"},
+		}}
+		qc.resolveTimeFilter()
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.AQLQuery.TimeFilter).Should(Equal(common.TimeFilter{Column: "field1", From: "1550793600", To: "1551443400"}))
+
+		// calendar units are aligned in the query timezone.
+		qc.AQLQuery.TimeFilter = common.TimeFilter{Column: "field1", From: "this quarter"}
+		qc.AQLQuery.Timezone = "America/Los_Angeles"
+		qc.resolveTimeFilter()
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.AQLQuery.TimeFilter).Should(Equal(common.TimeFilter{Column: "field1", From: "1546329600", To: "1551443400"}))
+
+		// now of the query overrides the clock.
+		qc.AQLQuery.TimeFilter = common.TimeFilter{Column: "field1", From: "-1d"}
+		qc.AQLQuery.Timezone = ""
+		qc.AQLQuery.Now = 1551398400
+		qc.resolveTimeFilter()
+		Ω(qc.AQLQuery.TimeFilter).Should(Equal(common.TimeFilter{Column: "field1", From: "1551312000", To: "1551398400"}))
+
+		qc.AQLQuery.TimeFilter = common.TimeFilter{Column: "field1", From: "next week"}
+		qc.resolveTimeFilter()
+		Ω(qc.Error).ShouldNot(BeNil())
+	})
+
 	ginkgo.It("GetRewrittenQuery should work", func() {
 		mockTableSchemaReader := memComMocks.TableSchemaReader{}
 		mockTableSchemaReader.On("RLock").Return(nil)
@@ -797,7 +900,195 @@ var _ = ginkgo.Describe("query compiler", func() {
 				"column": "",
 				"from": "", 
 				"to": ""
+			},
+			"schemaVersions": {
+				"table2": 0,
+				"table3": 0
 			}
 		}`))
 	})
+
+	ginkgo.It("should enforce table ACLs", func() {
+		table4 := &metaCom.Table{
+			Name: "table4",
+			Columns: []metaCom.Column{
+				{Name: "field1", Type: "Uint32"},
+				{Name: "field2", Type: "Uint16"},
+			},
+			ACL: &metaCom.TableACL{
+				Readers:           []string{"analysts"},
+				RestrictedColumns: map[string][]string{"field2": {"admins"}},
+			},
+		}
+		mockTableSchemaReader := memComMocks.TableSchemaReader{}
+		mockTableSchemaReader.On("RLock").Return(nil)
+		mockTableSchemaReader.On("RUnlock").Return(nil)
+		mockTableSchemaReader.On("GetSchema", "table4").Return(memCom.NewTableSchema(table4), nil)
+
+		compile := func(principal *utils.Principal, measure, dimension string) *QueryContext {
+			qc := NewQueryContext(&common.AQLQuery{
+				Table:      "table4",
+				Measures:   []common.Measure{{Expr: measure}},
+				Dimensions: []common.Dimension{{Expr: dimension}},
+				Limit:      nonAggregationQueryLimit,
+			}, false, httptest.NewRecorder())
+			qc.Principal = principal
+			qc.Compile(&mockTableSchemaReader)
+			return qc
+		}
+
+		// not authenticated.
+		qc := compile(nil, "sum(field2)", "field1")
+		Ω(qc.Error).Should(BeNil())
+
+		qc = compile(&utils.Principal{Name: "bob"}, "sum(field1)", "field1")
+		Ω(qc.Error).ShouldNot(BeNil())
+		Ω(qc.Error.(utils.APIError).Code).Should(Equal(http.StatusForbidden))
+		Ω(qc.Error.Error()).Should(ContainSubstring("principal bob is not allowed to read table table4"))
+
+		analyst := &utils.Principal{Name: "alice", Groups: []string{"analysts"}}
+		qc = compile(analyst, "sum(field2)", "field1")
+		Ω(qc.Error).ShouldNot(BeNil())
+		Ω(qc.Error.Error()).Should(ContainSubstring("not allowed to read restricted column field2 of table table4"))
+
+		qc = compile(analyst, "1", "*")
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.AQLQuery.Dimensions).Should(HaveLen(1))
+		Ω(qc.AQLQuery.Dimensions[0].Expr).Should(Equal("field1"))
+
+		qc = compile(&utils.Principal{Name: "carol", Groups: []string{"analysts", "admins"}}, "1", "*")
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.AQLQuery.Dimensions).Should(HaveLen(2))
+	})
+
+	ginkgo.It("should compile inline table joins", func() {
+		mockTableSchemaReader := memComMocks.TableSchemaReader{}
+		mockTableSchemaReader.On("RLock").Return(nil)
+		mockTableSchemaReader.On("RUnlock").Return(nil)
+		mockTableSchemaReader.On("GetSchema", "table1").Return(tableSchema1, nil)
+
+		compile := func(condition, measure, dimension string, rows ...[]interface{}) *QueryContext {
+			qc := NewQueryContext(&common.AQLQuery{
+				Table: "table1",
+				Joins: []common.Join{
+					{
+						Alias:      "t",
+						Conditions: []string{condition},
+						InlineTable: &common.InlineTable{
+							Columns: []string{"id", "label"},
+							Rows:    rows,
+						},
+					},
+				},
+				Measures:   []common.Measure{{Expr: measure}},
+				Dimensions: []common.Dimension{{Expr: dimension}},
+			}, false, httptest.NewRecorder())
+			qc.Compile(&mockTableSchemaReader)
+			return qc
+		}
+
+		qc := compile("t.id = field1", "count(*)", "t.label", []interface{}{1.0, "a"}, []interface{}{2.5, nil}, []interface{}{nil, "c"})
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.AQLQuery.Joins).Should(BeEmpty())
+		Ω(qc.AQLQuery.Dimensions[0].Expr).Should(Equal("t.label"))
+		Ω(qc.AQLQuery.Dimensions[0].ExprParsed.String()).Should(Equal("field1"))
+		Ω(qc.DimensionValueMaps).Should(Equal(map[int]map[string]string{
+			0: {"1": "a", "2.5": "NULL"},
+		}))
+
+		qc = compile("field1 = t.id", "count(*)", "field2")
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.DimensionValueMaps).Should(BeEmpty())
+
+		// inline table columns used outside dimensions.
+		qc = compile("t.id = field1", "sum(t.id)", "field2")
+		Ω(qc.Error.Error()).Should(ContainSubstring("inline table column t.id can only be used as dimension"))
+
+		// non aggregation query.
+		qc = compile("t.id = field1", "1", "t.label")
+		Ω(qc.Error).ShouldNot(BeNil())
+
+		// unknown column.
+		qc = compile("t.id = field1", "count(*)", "t.name")
+		Ω(qc.Error.Error()).Should(ContainSubstring("unknown column name for inline table t"))
+
+		// not equi join on inline table column.
+		qc = compile("t.id > field1", "count(*)", "t.label")
+		Ω(qc.Error).ShouldNot(BeNil())
+
+		// duplicated join keys.
+		qc = compile("t.id = field1", "count(*)", "t.label", []interface{}{1.0, "a"}, []interface{}{1.0, "b"})
+		Ω(qc.Error.Error()).Should(ContainSubstring("duplicated join key 1 in inline table t"))
+	})
+
+	ginkgo.It("should compile map_values dimensions", func() {
+		mockTableSchemaReader := memComMocks.TableSchemaReader{}
+		mockTableSchemaReader.On("RLock").Return(nil)
+		mockTableSchemaReader.On("RUnlock").Return(nil)
+		mockTableSchemaReader.On("GetSchema", "table3").Return(tableSchema3, nil)
+
+		compile := func(measure, dimension string) *QueryContext {
+			qc := NewQueryContext(&common.AQLQuery{
+				Table:      "table3",
+				Measures:   []common.Measure{{Expr: measure}},
+				Dimensions: []common.Dimension{{Expr: dimension}},
+			}, false, httptest.NewRecorder())
+			qc.Compile(&mockTableSchemaReader)
+			return qc
+		}
+
+		qc := compile("count(*)", "map_values(field1, {'a': 'x', 'b': 'x'}, 'y')")
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.GetRewrittenQuery().Dimensions[0].Expr).Should(Equal("field1"))
+		Ω(qc.DimensionEnumReverseDicts).Should(Equal(map[int][]string{0: {"a", "b", "c"}}))
+		Ω(qc.DimensionValueMaps).Should(Equal(map[int]map[string]string{
+			0: {"a": "x", "b": "x", "c": "y"},
+		}))
+
+		// non aggregation query translates rows directly.
+		qc = compile("1", "map_values(field1, {'a': 'x', 'b': 'x'})")
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.DimensionEnumReverseDicts).Should(Equal(map[int][]string{0: {"x", "x", "c"}}))
+		Ω(qc.DimensionValueMaps).Should(BeEmpty())
+
+		qc = compile("count(*)", "map_values(field1, 'a')")
+		Ω(qc.Error.Error()).Should(ContainSubstring("2nd argument of map_values must be a map"))
+
+		qc = compile("count(*)", "length(map_values(field1, {'a': 'x'}))")
+		Ω(qc.Error).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("should pick up schema changes between compiles", func() {
+		table1V2 := *table1
+		table1V2.Columns = []metaCom.Column{
+			{Name: "field1", Type: "Uint32", Deleted: true},
+			{Name: "field2", Type: "Uint16"},
+		}
+		schemaReader := memComTestUtil.NewTableSchemaReader()
+		schemaReader.Script("table1", tableSchema1, memCom.NewTableSchema(&table1V2), nil)
+
+		newQuery := func() *common.AQLQuery {
+			return &common.AQLQuery{
+				Table: "table1",
+				Measures: []common.Measure{
+					{Expr: "sum(field1)"},
+				},
+			}
+		}
+
+		qc := NewQueryContext(newQuery(), false, httptest.NewRecorder())
+		qc.Compile(schemaReader)
+		Ω(qc.Error).Should(BeNil())
+
+		// field1 got deleted.
+		qc = NewQueryContext(newQuery(), false, httptest.NewRecorder())
+		qc.Compile(schemaReader)
+		Ω(qc.Error).ShouldNot(BeNil())
+
+		// table got deleted.
+		qc = NewQueryContext(newQuery(), false, httptest.NewRecorder())
+		qc.Compile(schemaReader)
+		Ω(qc.Error.Error()).Should(ContainSubstring("unknown main table table1"))
+		Ω(schemaReader.GetSchemaCalls("table1")).Should(Equal(3))
+	})
 })
@@ -26,6 +26,7 @@ import (
 	queryCom "github.com/uber/aresdb/query/common"
 	"github.com/uber/aresdb/query/expr"
 	"github.com/uber/aresdb/utils"
+	"go.opentelemetry.io/otel/attribute"
 	"net/http"
 	"strconv"
 	"strings"
@@ -58,6 +59,16 @@ type mergeNodeImpl struct {
 	blockingPlanNodeImpl
 	// MeasureType decides merge behaviour
 	aggType common.AggType
+	// max number of distinct values of each dimension allowed in merged result, 0 means no limit
+	maxDimensionCardinality int
+	// dimensions of the query
+	dimensions []queryCom.Dimension
+	// timeouts of waiting for children and merging their results, zero means no timeout
+	timeouts QueryTimeouts
+	// max number of children executed concurrently, 0 means no limit
+	maxParallelism int
+	// called with the index of each child finished successfully, used for reporting progress
+	onChildFinished func(child int)
 }
 
 func (mn *mergeNodeImpl) AggType() common.AggType {
@@ -93,14 +104,29 @@ func (mn *mergeNodeImpl) Execute(ctx context.Context) (result queryCom.AQLQueryR
 	}
 
 	childrenResult := make([]queryCom.AQLQueryResult, nChildren)
+	// coverage tokens of shards scanned for each child, reported by datanodes. Nested merge
+	// nodes carry their own collectors so collectors here stay empty for them.
+	childrenCoverage := make([]*queryCom.ShardCoverageCollector, nChildren)
 	nerrs := 0
 	wg := &sync.WaitGroup{}
+	fanOutCtx, fanOutSpan := utils.StartSpan(ctx, "broker.FanOut", attribute.Int("children", nChildren))
+	fanOutCtx, cancelFanOut := withTimeout(fanOutCtx, mn.timeouts.Scatter)
+	defer cancelFanOut()
+	var semaphore chan struct{}
+	if mn.maxParallelism > 0 {
+		semaphore = make(chan struct{}, mn.maxParallelism)
+	}
 	for i, c := range mn.children {
 		wg.Add(1)
 		go func(i int, n common.BlockingPlanNode) {
 			defer wg.Done()
+			if semaphore != nil {
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+			}
 			var res queryCom.AQLQueryResult
-			res, err = n.Execute(ctx)
+			childrenCoverage[i] = &queryCom.ShardCoverageCollector{}
+			res, err = n.Execute(queryCom.WithShardCoverageCollector(fanOutCtx, childrenCoverage[i]))
 			if err != nil {
 				// err means downstream retry failed
 				utils.GetLogger().With(
@@ -110,31 +136,158 @@ func (mn *mergeNodeImpl) Execute(ctx context.Context) (result queryCom.AQLQueryR
 				return
 			}
 			childrenResult[i] = res
+			if mn.onChildFinished != nil {
+				mn.onChildFinished(i)
+			}
 		}(i, c)
 	}
 
 	dataNodeWaitStart := utils.Now()
 	// TODO early merge before all results come back
 	wg.Wait()
-	utils.GetRootReporter().GetTimer(utils.TimeWaitedForDataNode).Record(utils.Now().Sub(dataNodeWaitStart))
+	dataNodeWaitDuration := utils.Now().Sub(dataNodeWaitStart)
+	utils.GetRootReporter().GetTimer(utils.TimeWaitedForDataNode).Record(dataNodeWaitDuration)
+	profile := queryProfileFromContext(ctx)
+	profile.addFanOut(dataNodeWaitDuration)
+	fanOutSpan.SetAttributes(attribute.Int("errors", nerrs))
+	fanOutSpan.End()
 
 	if nerrs > 0 {
 		err = utils.StackError(nil, fmt.Sprintf("%d errors happened executing merge node", nerrs))
 		return
 	}
 
+	mergeStart := utils.Now()
+	_, mergeSpan := utils.StartSpan(ctx, "broker.Merge")
+	defer func() {
+		profile.addMerge(utils.Now().Sub(mergeStart))
+		utils.EndSpan(mergeSpan, err)
+	}()
+
 	result = childrenResult[0]
+	if err = mn.checkDimensionCardinality(result, 1, nChildren); err != nil {
+		return
+	}
+	covered := make(map[string]struct{})
+	addCoverage(covered, childrenCoverage[0].Result())
 	for i := 1; i < nChildren; i++ {
+		if mn.timeouts.Merge > 0 && utils.Now().Sub(mergeStart) > mn.timeouts.Merge {
+			err = utils.StackError(nil,
+				"merge timeout %v exceeded, aborted after merging results from %d of %d datanodes",
+				mn.timeouts.Merge, i, nChildren)
+			return
+		}
+		var duplicated bool
+		if duplicated, err = checkCoverageOverlap(covered, childrenCoverage[i].Result()); err != nil {
+			return
+		} else if duplicated {
+			utils.GetLogger().With("child", i, "shards", childrenCoverage[i].Result()).
+				Info("skipped result covering shards already merged")
+			utils.GetRootReporter().GetCounter(utils.QueryShardCoverageDeduplicatedBroker).Inc(1)
+			continue
+		}
+		addCoverage(covered, childrenCoverage[i].Result())
 		mergeCtx := newResultMergeContext(mn.aggType)
 		result = mergeCtx.run(result, childrenResult[i])
 		if mergeCtx.err != nil {
 			err = mergeCtx.err
 			return
 		}
+		if err = mn.checkDimensionCardinality(result, i+1, nChildren); err != nil {
+			return
+		}
 	}
 	return
 }
 
+// addCoverage adds coverage tokens to the covered set.
+func addCoverage(covered map[string]struct{}, tokens []string) {
+	for _, token := range tokens {
+		covered[token] = struct{}{}
+	}
+}
+
+// checkCoverageOverlap tells whether the shards covered by tokens have all been merged already,
+// in which case the result is a duplicate, eg. served by both the old and new owner of shards
+// during resharding. Partial overlap can't be deduplicated and returns error. Results without
+// coverage tokens are never treated as duplicates.
+func checkCoverageOverlap(covered map[string]struct{}, tokens []string) (duplicated bool, err error) {
+	if len(tokens) == 0 {
+		return false, nil
+	}
+	var overlapped []string
+	for _, token := range tokens {
+		if _, ok := covered[token]; ok {
+			overlapped = append(overlapped, token)
+		}
+	}
+	if len(overlapped) == 0 {
+		return false, nil
+	}
+	if len(overlapped) == len(tokens) {
+		return true, nil
+	}
+	return false, utils.StackError(nil,
+		"partial results overlap on shards %s only partially, cannot be deduplicated", strings.Join(overlapped, ","))
+}
+
+// checkDimensionCardinality returns error if any dimension has more distinct values in the
+// merged result than maxDimensionCardinality.
+func (mn *mergeNodeImpl) checkDimensionCardinality(result queryCom.AQLQueryResult, numMerged, numChildren int) error {
+	if mn.maxDimensionCardinality <= 0 || len(mn.dimensions) == 0 {
+		return nil
+	}
+	// no dimension can exceed the limit if the number of groups does not.
+	if getResultSizeRecursive(result) <= mn.maxDimensionCardinality {
+		return nil
+	}
+	for dimIndex, cardinality := range getDimensionCardinalities(result, len(mn.dimensions)) {
+		if cardinality <= mn.maxDimensionCardinality {
+			continue
+		}
+		utils.GetRootReporter().GetCounter(utils.QueryDimCardinalityExceededBroker).Inc(1)
+		return utils.StackError(nil,
+			"dimension %s has %d distinct values exceeding max dimension cardinality %d, aborted after merging results from %d of %d datanodes",
+			mn.dimensions[dimIndex].Expr, cardinality, mn.maxDimensionCardinality, numMerged, numChildren)
+	}
+	return nil
+}
+
+// getDimensionCardinalities returns the number of distinct values of each of the first numDims
+// dimensions of the nested result.
+func getDimensionCardinalities(result queryCom.AQLQueryResult, numDims int) []int {
+	values := make([]map[string]struct{}, numDims)
+	for i := range values {
+		values[i] = make(map[string]struct{})
+	}
+	var collect func(res interface{}, depth int)
+	collect = func(res interface{}, depth int) {
+		if depth >= numDims {
+			return
+		}
+		var children map[string]interface{}
+		switch val := res.(type) {
+		case map[string]interface{}:
+			children = val
+		case queryCom.AQLQueryResult:
+			children = val
+		default:
+			return
+		}
+		for key, child := range children {
+			values[depth][key] = struct{}{}
+			collect(child, depth+1)
+		}
+	}
+	collect(result, 0)
+
+	cardinalities := make([]int, numDims)
+	for i := range values {
+		cardinalities[i] = len(values[i])
+	}
+	return cardinalities
+}
+
 // BlockingScanNode is a BlockingPlanNode that handles rpc calls to fetch data from datanode
 type BlockingScanNode struct {
 	blockingPlanNodeImpl
@@ -146,6 +299,10 @@ type BlockingScanNode struct {
 }
 
 func (sn *BlockingScanNode) Execute(ctx context.Context) (result queryCom.AQLQueryResult, err error) {
+	ctx, span := utils.StartSpan(ctx, "broker.ScanDataNode")
+	defer func() {
+		utils.EndSpan(span, err)
+	}()
 	done := ctx.Done()
 
 	isHll := common.CallNameToAggType[sn.qc.AQLQuery.Measures[0].ExprParsed.(*expr.Call).Name] == common.Hll
@@ -175,7 +332,16 @@ func (sn *BlockingScanNode) Execute(ctx context.Context) (result queryCom.AQLQue
 				// context cancelled or expired, cancel node execution
 				return nil, nil
 			}
-			result, fetchErr = sn.dataNodeClient.Query(ctx, sn.qc.RequestID, sn.host, *sn.qc.AQLQuery, isHll)
+			// each request has its own timeout so that slow requests can be retried before
+			// the scatter timeout
+			shardCtx, cancelShard := withTimeout(ctx, sn.qc.Timeouts.Shard)
+			rpcDone := trackScatterRPC()
+			result, fetchErr = sn.dataNodeClient.Query(shardCtx, sn.qc.RequestID, sn.host, *sn.qc.AQLQuery, isHll)
+			if fetchErr != nil && shardCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+				utils.GetRootReporter().GetCounter(utils.DataNodeQueryTimeouts).Inc(1)
+			}
+			rpcDone()
+			cancelShard()
 		}
 
 		if fetchErr != nil {
@@ -218,7 +384,7 @@ func NewAggQueryPlan(qc *QueryContext, topo topology.HealthTrackingDynamicTopolo
 	var root common.MergeNode
 
 	var assignments map[topology.Host][]uint32
-	assignments, err = util.CalculateShardAssignment(topo)
+	assignments, err = util.CalculateShardAssignmentWithPreference(topo, qc.PreferredHosts)
 	if err != nil {
 		return
 	}
@@ -232,10 +398,10 @@ func NewAggQueryPlan(qc *QueryContext, topo topology.HealthTrackingDynamicTopolo
 		root = NewMergeNode(common.Avg)
 		sumQuery, countQuery := splitAvgQuery(*qc)
 		root.Add(
-			buildSubPlan(common.Sum, sumQuery, assignments, topo, client),
-			buildSubPlan(common.Count, countQuery, assignments, topo, client))
+			buildTimeRangeSplitPlan(common.Sum, sumQuery, assignments, topo, client),
+			buildTimeRangeSplitPlan(common.Count, countQuery, assignments, topo, client))
 	default:
-		root = buildSubPlan(agg, *qc, assignments, topo, client)
+		root = buildTimeRangeSplitPlan(agg, *qc, assignments, topo, client)
 	}
 
 	plan = &AggQueryPlan{
@@ -246,7 +412,7 @@ func NewAggQueryPlan(qc *QueryContext, topo topology.HealthTrackingDynamicTopolo
 	return
 }
 
-func (ap *AggQueryPlan) postProcess(results queryCom.AQLQueryResult, execErr error, w http.ResponseWriter) (err error) {
+func (ap *AggQueryPlan) postProcess(ctx context.Context, results queryCom.AQLQueryResult, execErr error, w http.ResponseWriter) (err error) {
 	var data []byte
 	if ap.qc.ReturnHLLBinary {
 		w.Header().Set(utils.HTTPContentTypeHeaderKey, utils.HTTPContentTypeHyperLogLog)
@@ -256,8 +422,46 @@ func (ap *AggQueryPlan) postProcess(results queryCom.AQLQueryResult, execErr err
 			err = execErr
 			return
 		}
+		results = queryCom.ComputeSketchResult(results)
+		numDims := len(ap.qc.AQLQuery.Dimensions)
+		// top groups are selected before translation so that only the selected groups are
+		// translated, except for row major results of inline table values which are merged
+		// after translation.
+		limitBeforeTranslation := ap.qc.TopN != nil &&
+			(ap.qc.AQLQuery.ResultFormat == queryCom.ResultFormatColumnMajor || len(ap.qc.DimensionValueMaps) == 0)
+		if limitBeforeTranslation {
+			if results, err = ap.qc.TopN.limitNested(results, numDims, ap.translateDimValue); err != nil {
+				return
+			}
+		}
+
 		var rewritten interface{}
-		rewritten, err = ap.translateEnum(results)
+		if ap.qc.AQLQuery.ResultFormat == queryCom.ResultFormatColumnMajor {
+			ap.markOutliers(results)
+			var columnMajor queryCom.ColumnMajorResult
+			columnMajor, err = ap.toColumnMajor(results)
+			if err == nil && ap.qc.TopN != nil {
+				err = ap.qc.TopN.orderColumnMajor(columnMajor)
+			}
+			if err == nil && ap.qc.TimeSeriesForecast != nil {
+				columnMajor.Forecast = ap.qc.TimeSeriesForecast.forecastColumnMajor(columnMajor)
+			}
+			rewritten = columnMajor
+		} else {
+			rewritten, err = ap.translateEnum(results)
+			if err == nil {
+				rewritten, err = ap.translateInlineValues(0, rewritten)
+			}
+			if err == nil && ap.qc.TopN != nil && !limitBeforeTranslation {
+				if translated, ok := rewritten.(map[string]interface{}); ok {
+					rewritten, err = ap.qc.TopN.limitNested(translated, numDims, identityTranslation)
+				}
+			}
+			if err == nil {
+				ap.markOutliers(rewritten)
+				rewritten = ap.appendForecast(rewritten)
+			}
+		}
 		if err != nil {
 			return
 		}
@@ -267,7 +471,61 @@ func (ap *AggQueryPlan) postProcess(results queryCom.AQLQueryResult, execErr err
 	if err != nil {
 		return
 	}
-	_, err = w.Write([]byte(data))
+	var numBytes int
+	numBytes, err = w.Write([]byte(data))
+	queryProfileFromContext(ctx).addResultBytes(numBytes)
+	return
+}
+
+// markOutliers marks outlier time buckets of the results if the measure is zscore_outliers. Row
+// major results are marked after translation since results of inline values translated to the
+// same value are merged.
+func (ap *AggQueryPlan) markOutliers(results interface{}) {
+	if ap.qc.OutlierDetection == nil {
+		return
+	}
+	switch v := results.(type) {
+	case queryCom.AQLQueryResult:
+		ap.qc.OutlierDetection.mark(v, len(ap.qc.AQLQuery.Dimensions))
+	case map[string]interface{}:
+		ap.qc.OutlierDetection.mark(v, len(ap.qc.AQLQuery.Dimensions))
+	}
+}
+
+// appendForecast wraps row major results with the forecast of their time series if requested.
+func (ap *AggQueryPlan) appendForecast(results interface{}) interface{} {
+	if ap.qc.TimeSeriesForecast == nil {
+		return results
+	}
+	var nested map[string]interface{}
+	switch v := results.(type) {
+	case queryCom.AQLQueryResult:
+		nested = v
+	case map[string]interface{}:
+		nested = v
+	default:
+		return results
+	}
+	return queryCom.ForecastedResult{
+		Results:  results,
+		Forecast: ap.qc.TimeSeriesForecast.forecastNested(nested, len(ap.qc.AQLQuery.Dimensions)),
+	}
+}
+
+// translateDimValue translates the enum rank or join key of the dimension value.
+func (ap *AggQueryPlan) translateDimValue(dimIndex int, value string) (translated string, err error) {
+	translated = value
+	if reverseDict, exists := ap.qc.DimensionEnumReverseDicts[dimIndex]; exists {
+		if translated, err = translateEnumRank(value, reverseDict); err != nil {
+			return
+		}
+	}
+	if valueMap, exists := ap.qc.DimensionValueMaps[dimIndex]; exists {
+		var found bool
+		if translated, found = valueMap[translated]; !found {
+			translated = queryCom.NULLString
+		}
+	}
 	return
 }
 
@@ -300,18 +558,10 @@ func traverseRecursive(dimIndex int, curr interface{}, dimReverseDict map[int][]
 
 		newRes := make(map[string]interface{})
 		for k, val := range v {
-			if queryCom.NULLString == k {
-				newRes[k] = val
-				continue
-			}
-			enumRank, err := strconv.Atoi(k)
+			enumVal, err := translateEnumRank(k, reverseDict)
 			if err != nil {
 				return nil, err
 			}
-			if enumRank >= len(reverseDict) || enumRank < 0 {
-				return nil, utils.StackError(nil, "invalid enum rank %d, enums were %s", enumRank, reverseDict)
-			}
-			enumVal := reverseDict[enumRank]
 			newRes[enumVal] = val
 		}
 		return newRes, nil
@@ -320,6 +570,93 @@ func traverseRecursive(dimIndex int, curr interface{}, dimReverseDict map[int][]
 	return curr, nil
 }
 
+// translateEnumRank translates enum rank to enum value, NULL is kept as is.
+func translateEnumRank(rank string, reverseDict []string) (string, error) {
+	if queryCom.NULLString == rank {
+		return rank, nil
+	}
+	enumRank, err := strconv.Atoi(rank)
+	if err != nil {
+		return "", err
+	}
+	if enumRank >= len(reverseDict) || enumRank < 0 {
+		return "", utils.StackError(nil, "invalid enum rank %d, enums were %s", enumRank, reverseDict)
+	}
+	return reverseDict[enumRank], nil
+}
+
+// toColumnMajor converts results to column major format and translates enum ranks to enum values
+// and join keys to inline table values.
+func (ap *AggQueryPlan) toColumnMajor(results queryCom.AQLQueryResult) (columnMajor queryCom.ColumnMajorResult, err error) {
+	numDims := len(ap.qc.AQLQuery.Dimensions)
+	headers := make([]string, numDims+1)
+	for i, dim := range ap.qc.AQLQuery.Dimensions {
+		headers[i] = dim.Expr
+	}
+	headers[numDims] = ap.qc.AQLQuery.Measures[0].Expr
+
+	columnMajor = results.ToColumnMajor(headers, numDims)
+	for dimIndex, reverseDict := range ap.qc.DimensionEnumReverseDicts {
+		column := columnMajor.Columns[dimIndex]
+		for i, value := range column {
+			if column[i], err = translateEnumRank(value.(string), reverseDict); err != nil {
+				return
+			}
+		}
+	}
+	for dimIndex, valueMap := range ap.qc.DimensionValueMaps {
+		column := columnMajor.Columns[dimIndex]
+		for i, value := range column {
+			translated, found := valueMap[value.(string)]
+			if !found {
+				translated = queryCom.NULLString
+			}
+			column[i] = translated
+		}
+	}
+	return
+}
+
+// translateInlineValues translates join key values to inline table column values. Results of
+// join keys translated to the same value are merged.
+func (ap *AggQueryPlan) translateInlineValues(dimIndex int, curr interface{}) (rewritten interface{}, err error) {
+	v, ok := curr.(map[string]interface{})
+	if len(ap.qc.DimensionValueMaps) == 0 || !ok {
+		return curr, nil
+	}
+
+	for ck, child := range v {
+		if v[ck], err = ap.translateInlineValues(dimIndex+1, child); err != nil {
+			return
+		}
+	}
+
+	valueMap, exists := ap.qc.DimensionValueMaps[dimIndex]
+	if !exists {
+		return v, nil
+	}
+	newRes := make(map[string]interface{})
+	for k, child := range v {
+		value, found := valueMap[k]
+		if !found {
+			value = queryCom.NULLString
+		}
+		if existing, duplicated := newRes[value]; duplicated {
+			if ap.aggType == common.Avg {
+				return nil, utils.StackError(nil, "failed to merge avg results of inline table value %s at %dth dimension", value, dimIndex)
+			}
+			mergeCtx := newResultMergeContext(ap.aggType)
+			merged := mergeCtx.run(queryCom.AQLQueryResult{value: existing}, queryCom.AQLQueryResult{value: child})
+			if mergeCtx.err != nil {
+				return nil, mergeCtx.err
+			}
+			child = merged[value]
+		}
+		newRes[value] = child
+	}
+	return newRes, nil
+}
+
 func getResultSizeRecursive(res interface{}) int {
 	total := 0
 	switch val := res.(type) {
@@ -424,7 +761,8 @@ func (ap *AggQueryPlan) postProcessHLLBinary(res queryCom.AQLQueryResult, execEr
 func (ap *AggQueryPlan) Execute(ctx context.Context, w http.ResponseWriter) (err error) {
 	var results queryCom.AQLQueryResult
 	results, err = ap.root.Execute(ctx)
-	return ap.postProcess(results, err, w)
+	setDataFreshnessHeader(ctx, w)
+	return ap.postProcess(ctx, results, err, w)
 }
 
 // splitAvgQuery to sum and count queries
@@ -462,7 +800,12 @@ func splitAvgQuery(qc QueryContext) (sumqc QueryContext, countqc QueryContext) {
 }
 
 func buildSubPlan(agg common.AggType, qc QueryContext, assignments map[topology.Host][]uint32, topo topology.HealthTrackingDynamicTopoloy, client dataCli.DataNodeQueryClient) common.MergeNode {
-	root := NewMergeNode(agg)
+	root := &mergeNodeImpl{
+		aggType:                 agg,
+		maxDimensionCardinality: qc.MaxDimensionCardinality,
+		dimensions:              qc.AQLQuery.Dimensions,
+		timeouts:                qc.Timeouts,
+	}
 	query := qc.GetRewrittenQuery()
 	for host, shardIDs := range assignments {
 		// make deep copy
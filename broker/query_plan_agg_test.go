@@ -122,6 +122,81 @@ var _ = ginkgo.Describe("agg query plan", func() {
 		Ω(err.Error()).Should(ContainSubstring("errors happened executing merge node"))
 	})
 
+	ginkgo.It("MergeNode Execute should abort when exceeding max dimension cardinality", func() {
+		mockNode1 := mocks.BlockingPlanNode{}
+		mockNode2 := mocks.BlockingPlanNode{}
+		// results are merged in place, so return a new result on every call.
+		mockNode1.On("Execute", mock.Anything).Return(func(context.Context) queryCom.AQLQueryResult {
+			return queryCom.AQLQueryResult{
+				"sf": map[string]interface{}{"1": float64(1), "2": float64(1)},
+				"la": map[string]interface{}{"1": float64(1)},
+			}
+		}, nil)
+		mockNode2.On("Execute", mock.Anything).Return(func(context.Context) queryCom.AQLQueryResult {
+			return queryCom.AQLQueryResult{
+				"sf": map[string]interface{}{"2": float64(1), "3": float64(1)},
+				"la": map[string]interface{}{"3": float64(1)},
+			}
+		}, nil)
+		dimensions := []queryCom.Dimension{{Expr: "city"}, {Expr: "status"}}
+
+		// 5 groups, with 2 cities and 3 statuses.
+		node := &mergeNodeImpl{aggType: brokerCom.Count, maxDimensionCardinality: 3, dimensions: dimensions}
+		node.Add(&mockNode1, &mockNode2)
+		res, err := node.Execute(context.TODO())
+		Ω(err).Should(BeNil())
+		Ω(getResultSizeRecursive(res)).Should(Equal(5))
+
+		node = &mergeNodeImpl{aggType: brokerCom.Count, maxDimensionCardinality: 2, dimensions: dimensions}
+		node.Add(&mockNode1, &mockNode2)
+		_, err = node.Execute(context.TODO())
+		Ω(err).ShouldNot(BeNil())
+		Ω(err.Error()).Should(ContainSubstring("dimension status has 3 distinct values exceeding max dimension cardinality 2, aborted after merging results from 2 of 2 datanodes"))
+	})
+
+	ginkgo.It("getDimensionCardinalities should work", func() {
+		result := queryCom.AQLQueryResult{
+			"sf": map[string]interface{}{"1": float64(1), "2": float64(1)},
+			"la": map[string]interface{}{"2": float64(1), "NULL": float64(1)},
+		}
+		Ω(getDimensionCardinalities(result, 2)).Should(Equal([]int{2, 3}))
+		Ω(getDimensionCardinalities(result, 1)).Should(Equal([]int{2}))
+		Ω(getDimensionCardinalities(queryCom.AQLQueryResult{}, 2)).Should(Equal([]int{0, 0}))
+	})
+
+	ginkgo.It("MergeNode Execute should deduplicate results with the same shard coverage", func() {
+		newScanNode := func(tokens ...string) *mocks.BlockingPlanNode {
+			node := &mocks.BlockingPlanNode{}
+			node.On("Execute", mock.Anything).Return(func(ctx context.Context) queryCom.AQLQueryResult {
+				queryCom.ShardCoverageCollectorFromContext(ctx).Add(tokens...)
+				return queryCom.AQLQueryResult{
+					"1": float64(1),
+				}
+			}, nil)
+			return node
+		}
+
+		// old and new owner of shard 1 both respond during resharding.
+		node := &mergeNodeImpl{aggType: brokerCom.Count}
+		node.Add(newScanNode("t/0", "t/1"), newScanNode("t/1"), newScanNode("t/2"))
+		res, err := node.Execute(context.TODO())
+		Ω(err).Should(BeNil())
+		Ω(res).Should(Equal(queryCom.AQLQueryResult{"1": float64(2)}))
+
+		// results without coverage are always merged.
+		node = &mergeNodeImpl{aggType: brokerCom.Count}
+		node.Add(newScanNode(), newScanNode())
+		res, err = node.Execute(context.TODO())
+		Ω(err).Should(BeNil())
+		Ω(res).Should(Equal(queryCom.AQLQueryResult{"1": float64(2)}))
+
+		node = &mergeNodeImpl{aggType: brokerCom.Count}
+		node.Add(newScanNode("t/0", "t/1"), newScanNode("t/1", "t/2"))
+		_, err = node.Execute(context.TODO())
+		Ω(err).ShouldNot(BeNil())
+		Ω(err.Error()).Should(ContainSubstring("overlap on shards t/1 only partially"))
+	})
+
 	ginkgo.It("NewAggQueryPlan should work", func() {
 		q := queryCom.AQLQuery{
 			Table: "table1",
@@ -541,6 +616,81 @@ var _ = ginkgo.Describe("agg query plan", func() {
 		}))
 	})
 
+	ginkgo.It("post process column major should work", func() {
+		plan := AggQueryPlan{
+			qc: &QueryContext{
+				AQLQuery: &queryCom.AQLQuery{
+					Dimensions:   []queryCom.Dimension{{Expr: "status"}, {Expr: "city_id"}},
+					Measures:     []queryCom.Measure{{Expr: "count(*)"}},
+					ResultFormat: queryCom.ResultFormatColumnMajor,
+				},
+				DimensionEnumReverseDicts: map[int][]string{
+					0: {"completed", "canceled"},
+				},
+			},
+		}
+
+		result := queryCom.AQLQueryResult{
+			"1": map[string]interface{}{
+				"2": 3.0,
+			},
+			"0": map[string]interface{}{
+				"1":    1.0,
+				"NULL": 2.0,
+			},
+		}
+		w := httptest.NewRecorder()
+		err := plan.postProcess(context.TODO(), result, nil, w)
+		Ω(err).Should(BeNil())
+		Ω(w.Body.String()).Should(Equal(`{"headers":["status","city_id","count(*)"],"columns":[["completed","completed","canceled"],["1","NULL","2"],[1,2,3]]}`))
+
+		result = queryCom.AQLQueryResult{"5": map[string]interface{}{"1": 1.0}}
+		w = httptest.NewRecorder()
+		err = plan.postProcess(context.TODO(), result, nil, w)
+		Ω(err).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("translate inline table values should work", func() {
+		plan := AggQueryPlan{
+			aggType: brokerCom.Count,
+			qc: &QueryContext{
+				AQLQuery: &queryCom.AQLQuery{
+					Dimensions: []queryCom.Dimension{{Expr: "t.label"}, {Expr: "status"}},
+					Measures:   []queryCom.Measure{{Expr: "count(*)"}},
+				},
+				DimensionValueMaps: map[int]map[string]string{
+					0: {"1": "sf", "2": "ny", "3": "sf"},
+				},
+			},
+		}
+
+		newResult := func() queryCom.AQLQueryResult {
+			return queryCom.AQLQueryResult{
+				"1": map[string]interface{}{"a": 1.0},
+				"2": map[string]interface{}{"a": 5.0},
+				"3": map[string]interface{}{"a": 2.0, "b": 1.0},
+				"4": map[string]interface{}{"b": 1.0},
+			}
+		}
+		res, err := plan.translateInlineValues(0, map[string]interface{}(newResult()))
+		Ω(err).Should(BeNil())
+		Ω(res).Should(Equal(map[string]interface{}{
+			"sf":   map[string]interface{}{"a": 3.0, "b": 1.0},
+			"ny":   map[string]interface{}{"a": 5.0},
+			"NULL": map[string]interface{}{"b": 1.0},
+		}))
+
+		plan.qc.AQLQuery.ResultFormat = queryCom.ResultFormatColumnMajor
+		w := httptest.NewRecorder()
+		err = plan.postProcess(context.TODO(), newResult(), nil, w)
+		Ω(err).Should(BeNil())
+		Ω(w.Body.String()).Should(Equal(`{"headers":["t.label","status","count(*)"],"columns":[["sf","ny","sf","sf","NULL"],["a","a","a","b","b"],[1,5,2,1,1]]}`))
+
+		plan.aggType = brokerCom.Avg
+		_, err = plan.translateInlineValues(0, map[string]interface{}(newResult()))
+		Ω(err).ShouldNot(BeNil())
+	})
+
 	ginkgo.It("cancel query on context cancel", func() {
 		ctx, cf := context.WithCancel(context.Background())
 		cf()
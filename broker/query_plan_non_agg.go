@@ -22,6 +22,7 @@ import (
 	dataCli "github.com/uber/aresdb/datanode/client"
 	"github.com/uber/aresdb/query/common"
 	"github.com/uber/aresdb/utils"
+	"go.opentelemetry.io/otel/attribute"
 	"net/http"
 	"strconv"
 )
@@ -35,6 +36,11 @@ type StreamingScanNode struct {
 }
 
 func (ssn *StreamingScanNode) Execute(ctx context.Context) (bs []byte, err error) {
+	ctx, span := utils.StartSpan(ctx, "broker.ScanDataNode")
+	defer func() {
+		span.SetAttributes(attribute.Int("bytes", len(bs)))
+		utils.EndSpan(span, err)
+	}()
 	done := ctx.Done()
 
 	hostHealthy := true
@@ -64,7 +70,20 @@ func (ssn *StreamingScanNode) Execute(ctx context.Context) (bs []byte, err error
 				// context cancelled or expired, no need to query
 				return nil, nil
 			}
-			bs, fetchErr = ssn.dataNodeClient.QueryRaw(ctx, ssn.qc.RequestID, ssn.host, *ssn.qc.AQLQuery)
+			// each request has its own timeout so that slow requests can be retried before
+			// the scatter timeout
+			shardCtx, cancelShard := withTimeout(ctx, ssn.qc.Timeouts.Shard)
+			rpcDone := trackScatterRPC()
+			bs, fetchErr = ssn.dataNodeClient.QueryRaw(shardCtx, ssn.qc.RequestID, ssn.host, *ssn.qc.AQLQuery)
+			if fetchErr != nil && shardCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+				utils.GetRootReporter().GetCounter(utils.DataNodeQueryTimeouts).Inc(1)
+			}
+			rpcDone()
+			cancelShard()
+		}
+		if fetchErr != nil && ctx.Err() == context.Canceled {
+			// canceled by the plan as the limit is already satisfied by other datanodes.
+			return nil, nil
 		}
 		if fetchErr != nil {
 			utils.GetRootReporter().GetCounter(utils.DataNodeQueryFailures).Inc(1)
@@ -108,7 +127,7 @@ func NewNonAggQueryPlan(qc *QueryContext, topo topology.HealthTrackingDynamicTop
 	}
 
 	var assignment map[topology.Host][]uint32
-	assignment, err = util.CalculateShardAssignment(topo)
+	assignment, err = util.CalculateShardAssignmentWithPreference(topo, qc.PreferredHosts)
 	if err != nil {
 		return
 	}
@@ -154,6 +173,9 @@ type NonAggQueryPlan struct {
 }
 
 func (nqp *NonAggQueryPlan) Execute(ctx context.Context, w http.ResponseWriter) (err error) {
+	// results are streamed before all datanodes respond, so data freshness is sent as trailer
+	w.Header().Set("Trailer", common.DataFreshnessHeaderKey)
+
 	var headersBytes []byte
 	headersBytes, err = json.Marshal(nqp.headers)
 	if err != nil {
@@ -172,24 +194,37 @@ func (nqp *NonAggQueryPlan) Execute(ctx context.Context, w http.ResponseWriter)
 		return
 	}
 
+	// results are merged while waiting for other datanodes, so fan out span also includes merge time
+	fanOutCtx, fanOutSpan := utils.StartSpan(ctx, "broker.FanOut", attribute.Int("children", len(nqp.nodes)))
+	defer func() {
+		utils.EndSpan(fanOutSpan, err)
+	}()
+	// requests to remaining datanodes are canceled once the limit is satisfied or the scatter
+	// timeout is exceeded.
+	fanOutCtx, cancelFanOut := withTimeout(fanOutCtx, nqp.qc.Timeouts.Scatter)
+	defer cancelFanOut()
 	for _, node := range nqp.nodes {
 		go func(n *StreamingScanNode) {
-			var bs []byte
-			bs, err = n.Execute(ctx)
-			utils.GetLogger().With("dataSize", len(bs), "error", err).Debug("sending result to result channel")
+			bs, nodeErr := n.Execute(fanOutCtx)
+			utils.GetLogger().With("dataSize", len(bs), "error", nodeErr).Debug("sending result to result channel")
 			select {
 			case <-nqp.doneChan:
 				utils.GetLogger().Debug("cancel pushing to result channel")
 				return
 			case nqp.resultChan <- streamingScanNoderesult{
 				data: bs,
-				err:  err,
+				err:  nodeErr,
 			}:
 			}
 		}(node)
 	}
 
 	dataNodeWaitStart := utils.Now()
+	profile := queryProfileFromContext(ctx)
+	defer func() {
+		// results are merged while waiting for other datanodes, so fan out also includes merge time
+		profile.addFanOut(utils.Now().Sub(dataNodeWaitStart))
+	}()
 
 	// the first result
 	processedFirtBatch := false
@@ -197,6 +232,8 @@ func (nqp *NonAggQueryPlan) Execute(ctx context.Context, w http.ResponseWriter)
 		if nqp.getRowsWanted() == 0 {
 			utils.GetLogger().Debug("got enough rows, exiting")
 			close(nqp.doneChan)
+			cancelFanOut()
+			utils.GetRootReporter().GetCounter(utils.NonAggQueryShortCircuited).Inc(1)
 			break
 		}
 		res := <-nqp.resultChan
@@ -223,6 +260,7 @@ func (nqp *NonAggQueryPlan) Execute(ctx context.Context, w http.ResponseWriter)
 				w.Write([]byte(`,`))
 			}
 			w.Write(res.data)
+			profile.addResultBytes(len(res.data))
 		} else {
 			// we have to deserialize
 			if len(res.data) == 0 {
@@ -241,8 +279,19 @@ func (nqp *NonAggQueryPlan) Execute(ctx context.Context, w http.ResponseWriter)
 				return
 			}
 
-			// translate enum
-			for _, row := range resultData {
+			var dataToFlush [][]interface{}
+			if nqp.qc.AQLQuery.Limit < 0 {
+				dataToFlush = resultData
+			} else {
+				rowsToFlush := nqp.getRowsWanted()
+				if rowsToFlush > len(resultData) {
+					rowsToFlush = len(resultData)
+				}
+				dataToFlush = resultData[:rowsToFlush]
+			}
+
+			// translate enum of rows to flush only
+			for _, row := range dataToFlush {
 				for i, col := range row {
 					if enumReverseDict, exists := nqp.qc.DimensionEnumReverseDicts[i]; exists {
 						if s, ok := col.(string); ok {
@@ -266,16 +315,6 @@ func (nqp *NonAggQueryPlan) Execute(ctx context.Context, w http.ResponseWriter)
 				}
 			}
 
-			var dataToFlush [][]interface{}
-			if nqp.qc.AQLQuery.Limit < 0 {
-				dataToFlush = resultData
-			} else {
-				rowsToFlush := nqp.getRowsWanted()
-				if rowsToFlush > len(resultData) {
-					rowsToFlush = len(resultData)
-				}
-				dataToFlush = resultData[:rowsToFlush]
-			}
 			var bs []byte
 			bs, err = json.Marshal(dataToFlush)
 			if err != nil {
@@ -286,14 +325,18 @@ func (nqp *NonAggQueryPlan) Execute(ctx context.Context, w http.ResponseWriter)
 			}
 			// strip brackets
 			w.Write(bs[1 : len(bs)-1])
+			profile.addResultBytes(len(bs) - 2)
 			nqp.flushed += len(dataToFlush)
 			utils.GetLogger().With("nrows", len(dataToFlush)).Debug("flushed rows")
-			utils.GetRootReporter().GetTimer(utils.TimeSerDeDataNodeResponse).Record(utils.Now().Sub(serDeStart))
+			serDeDuration := utils.Now().Sub(serDeStart)
+			utils.GetRootReporter().GetTimer(utils.TimeSerDeDataNodeResponse).Record(serDeDuration)
+			profile.addMerge(serDeDuration)
 		}
 		processedFirtBatch = true
 	}
 
 	_, err = w.Write([]byte(`]}`))
+	setDataFreshnessHeader(ctx, w)
 	return
 }
 
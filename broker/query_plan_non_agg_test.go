@@ -142,6 +142,31 @@ var _ = ginkgo.Describe("non agg query plan", func() {
 		Ω(w.Body.String()).Should(Equal(`{"headers":["field1","field2"],"matrixData":[["foo","1"],["NULL","2"],["foo","1"]]}`))
 	})
 
+	ginkgo.It("should not retry datanode requests canceled after limit is satisfied", func() {
+		q := queryCom.AQLQuery{
+			Table: "table1",
+			Limit: 1,
+		}
+		mockTopo := topoMock.HealthTrackingDynamicTopoloy{}
+		mockDatanodeCli := dataCliMock.DataNodeQueryClient{}
+		ctx, cancel := context.WithCancel(context.Background())
+		mockDatanodeCli.On("QueryRaw", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { cancel() }).Return(nil, context.Canceled).Once()
+		mockTopo.On("MarkHostHealthy", mock.Anything).Return(nil).Once()
+
+		node := &StreamingScanNode{
+			qc:             QueryContext{AQLQuery: &q},
+			host:           &topoMock.Host{},
+			dataNodeClient: &mockDatanodeCli,
+			topo:           &mockTopo,
+		}
+		bs, err := node.Execute(ctx)
+		Ω(err).Should(BeNil())
+		Ω(bs).Should(BeNil())
+		mockDatanodeCli.AssertNumberOfCalls(utils.TestingT, "QueryRaw", 1)
+		mockTopo.AssertNotCalled(utils.TestingT, "MarkHostUnhealthy", mock.Anything)
+	})
+
 	ginkgo.It("should mark host unhealthy on connection error", func() {
 		q := queryCom.AQLQuery{
 			Table: "table1",
@@ -195,6 +220,8 @@ var _ = ginkgo.Describe("non agg query plan", func() {
 
 		mockDatanodeCli.On("QueryRaw", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, client.ErrFailedToConnect).Times(len(mockHosts) * 2)
 		mockTopo.On("MarkHostUnhealthy", mock.Anything).Return(nil).Times(len(mockHosts))
+		// requests to remaining datanodes are canceled once the first one fails.
+		mockTopo.On("MarkHostHealthy", mock.Anything).Return(nil).Maybe()
 
 		err = plan.Execute(context.TODO(), w)
 		Ω(err.Error()).Should(ContainSubstring("Datanode query client failed to connect"))
@@ -0,0 +1,95 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"github.com/uber/aresdb/broker/config"
+	"time"
+)
+
+const (
+	defaultShardTimeoutMillis   = 10000
+	defaultScatterTimeoutMillis = 25000
+	defaultMergeTimeoutMillis   = 5000
+)
+
+// QueryTimeouts are the timeouts of different stages of a broker query, all bounded by the
+// executor timeout. The shard timeout is shorter than the scatter timeout so that a slow
+// datanode request is retried before the scatter deadline instead of consuming all of it.
+type QueryTimeouts struct {
+	// timeout of each request sent to a datanode
+	Shard time.Duration
+	// timeout of waiting for all datanodes to respond
+	Scatter time.Duration
+	// timeout of merging datanode results of aggregation queries
+	Merge time.Duration
+}
+
+// NewQueryTimeouts creates QueryTimeouts from config, defaults are used for unset timeouts.
+func NewQueryTimeouts(cfg config.QueryConfig) QueryTimeouts {
+	return QueryTimeouts{
+		Shard:   millisOrDefault(cfg.ShardTimeoutMillis, defaultShardTimeoutMillis),
+		Scatter: millisOrDefault(cfg.ScatterTimeoutMillis, defaultScatterTimeoutMillis),
+		Merge:   millisOrDefault(cfg.MergeTimeoutMillis, defaultMergeTimeoutMillis),
+	}
+}
+
+// Override returns timeouts with positive timeouts in overrides taking precedence.
+func (t QueryTimeouts) Override(overrides QueryTimeouts) QueryTimeouts {
+	if overrides.Shard > 0 {
+		t.Shard = overrides.Shard
+	}
+	if overrides.Scatter > 0 {
+		t.Scatter = overrides.Scatter
+	}
+	if overrides.Merge > 0 {
+		t.Merge = overrides.Merge
+	}
+	return t
+}
+
+func millisOrDefault(millis, defaultMillis int) time.Duration {
+	if millis <= 0 {
+		millis = defaultMillis
+	}
+	return time.Duration(millis) * time.Millisecond
+}
+
+type queryTimeoutsContextKey struct{}
+
+// withQueryTimeoutOverrides returns a context carrying timeouts overridden by the request.
+func withQueryTimeoutOverrides(ctx context.Context, shardMillis, scatterMillis, mergeMillis int) context.Context {
+	return context.WithValue(ctx, queryTimeoutsContextKey{}, QueryTimeouts{
+		Shard:   time.Duration(shardMillis) * time.Millisecond,
+		Scatter: time.Duration(scatterMillis) * time.Millisecond,
+		Merge:   time.Duration(mergeMillis) * time.Millisecond,
+	})
+}
+
+// queryTimeoutOverridesFromContext returns timeouts overridden by the request, zero timeouts
+// are not overridden.
+func queryTimeoutOverridesFromContext(ctx context.Context) QueryTimeouts {
+	overrides, _ := ctx.Value(queryTimeoutsContextKey{}).(QueryTimeouts)
+	return overrides
+}
+
+// withTimeout returns a context with the timeout, no timeout is applied if timeout is not positive.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
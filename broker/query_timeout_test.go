@@ -0,0 +1,58 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/broker/config"
+)
+
+var _ = ginkgo.Describe("query timeouts", func() {
+	ginkgo.It("uses defaults for unset timeouts", func() {
+		timeouts := NewQueryTimeouts(config.QueryConfig{ShardTimeoutMillis: 2000})
+		Ω(timeouts).Should(Equal(QueryTimeouts{
+			Shard:   2 * time.Second,
+			Scatter: defaultScatterTimeoutMillis * time.Millisecond,
+			Merge:   defaultMergeTimeoutMillis * time.Millisecond,
+		}))
+	})
+
+	ginkgo.It("overrides timeouts set by request", func() {
+		timeouts := NewQueryTimeouts(config.QueryConfig{})
+		ctx := withQueryTimeoutOverrides(context.Background(), 0, 3000, 0)
+		Ω(timeouts.Override(queryTimeoutOverridesFromContext(ctx))).Should(Equal(QueryTimeouts{
+			Shard:   defaultShardTimeoutMillis * time.Millisecond,
+			Scatter: 3 * time.Second,
+			Merge:   defaultMergeTimeoutMillis * time.Millisecond,
+		}))
+		Ω(timeouts.Override(queryTimeoutOverridesFromContext(context.Background()))).Should(Equal(timeouts))
+	})
+
+	ginkgo.It("withTimeout only applies positive timeouts", func() {
+		ctx, cancel := withTimeout(context.Background(), 0)
+		defer cancel()
+		_, hasDeadline := ctx.Deadline()
+		Ω(hasDeadline).Should(BeFalse())
+
+		ctx, cancel = withTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		<-ctx.Done()
+		Ω(ctx.Err()).Should(Equal(context.DeadlineExceeded))
+	})
+})
@@ -0,0 +1,207 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	apiCom "github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/common"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	// UnknownCaller is the caller of queries without authenticated principal or caller header.
+	UnknownCaller = "unknown"
+	// ErrMsgQuotaExceeded represents error message for caller or table exceeding daily query quota.
+	ErrMsgQuotaExceeded = "Daily query quota exceeded"
+)
+
+// QueryUsage is the accounted usage of queries, summed over all datanodes processing them.
+// GPUSeconds is the time kernels of the queries run on devices.
+type QueryUsage struct {
+	Queries      int64   `json:"queries"`
+	ScannedBytes int64   `json:"scannedBytes"`
+	GPUSeconds   float64 `json:"gpuSeconds"`
+}
+
+func (u *QueryUsage) add(usage QueryUsage) {
+	u.Queries += usage.Queries
+	u.ScannedBytes += usage.ScannedBytes
+	u.GPUSeconds += usage.GPUSeconds
+}
+
+// exceeds returns the name of the first limit exceeded by the usage, empty if none.
+func (u QueryUsage) exceeds(quota common.QueryQuota) string {
+	if quota.Queries > 0 && u.Queries >= quota.Queries {
+		return "queries"
+	}
+	if quota.ScannedBytes > 0 && u.ScannedBytes >= quota.ScannedBytes {
+		return "scanned bytes"
+	}
+	if quota.GPUSeconds > 0 && u.GPUSeconds >= quota.GPUSeconds {
+		return "gpu seconds"
+	}
+	return ""
+}
+
+// QuotaUsageReport is the usage of the current day per caller and per table.
+type QuotaUsageReport struct {
+	// start of the day in UTC
+	Day     time.Time             `json:"day"`
+	Callers map[string]QueryUsage `json:"callers"`
+	Tables  map[string]QueryUsage `json:"tables"`
+}
+
+// QuotaManager accounts query usage per caller and per table, and enforces daily quotas.
+// Usage is reset at the start of each day in UTC. Quotas are enforced by broker against usage
+// reported by all datanodes in query responses, so that they are not multiplied by the number
+// of datanodes.
+type QuotaManager struct {
+	sync.Mutex
+	cfg     common.QueryQuotaConfig
+	day     time.Time
+	callers map[string]*QueryUsage
+	tables  map[string]*QueryUsage
+}
+
+// NewQuotaManager creates a new QuotaManager.
+func NewQuotaManager(cfg common.QueryQuotaConfig) *QuotaManager {
+	return &QuotaManager{
+		cfg:     cfg,
+		day:     startOfDay(utils.Now()),
+		callers: make(map[string]*QueryUsage),
+		tables:  make(map[string]*QueryUsage),
+	}
+}
+
+// Register registers http handlers.
+func (m *QuotaManager) Register(router *mux.Router, wrappers ...utils.HTTPHandlerWrapper) {
+	router.HandleFunc("/quota", utils.ApplyHTTPWrappers(m.ShowUsage, wrappers)).Methods(http.MethodGet)
+}
+
+// ShowUsage shows query usage of the current day per caller and per table for chargeback.
+func (m *QuotaManager) ShowUsage(w http.ResponseWriter, r *http.Request) {
+	apiCom.RespondWithJSONObject(w, m.GetUsage())
+}
+
+// Check returns error if the caller or the table has used up its daily quota.
+func (m *QuotaManager) Check(caller, table string) error {
+	if !m.cfg.Enable {
+		return nil
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	m.resetIfNewDay()
+
+	quota, ok := m.cfg.Callers[caller]
+	if !ok {
+		quota = m.cfg.DefaultCaller
+	}
+	if usage, ok := m.callers[caller]; ok {
+		if limit := usage.exceeds(quota); limit != "" {
+			return quotaExceededError("caller %s exceeded daily quota of %s", caller, limit)
+		}
+	}
+
+	quota, ok = m.cfg.Tables[table]
+	if !ok {
+		quota = m.cfg.DefaultTable
+	}
+	if usage, ok := m.tables[table]; ok {
+		if limit := usage.exceeds(quota); limit != "" {
+			return quotaExceededError("table %s exceeded daily quota of %s", table, limit)
+		}
+	}
+	return nil
+}
+
+// Record accounts the usage of a query to the caller and the table.
+func (m *QuotaManager) Record(caller, table string, usage QueryUsage) {
+	m.Lock()
+	defer m.Unlock()
+	m.resetIfNewDay()
+
+	if _, ok := m.callers[caller]; !ok {
+		m.callers[caller] = &QueryUsage{}
+	}
+	m.callers[caller].add(usage)
+
+	if _, ok := m.tables[table]; !ok {
+		m.tables[table] = &QueryUsage{}
+	}
+	m.tables[table].add(usage)
+}
+
+// GetUsage returns the usage of the current day.
+func (m *QuotaManager) GetUsage() QuotaUsageReport {
+	m.Lock()
+	defer m.Unlock()
+	m.resetIfNewDay()
+
+	report := QuotaUsageReport{
+		Day:     m.day,
+		Callers: make(map[string]QueryUsage, len(m.callers)),
+		Tables:  make(map[string]QueryUsage, len(m.tables)),
+	}
+	for caller, usage := range m.callers {
+		report.Callers[caller] = *usage
+	}
+	for table, usage := range m.tables {
+		report.Tables[table] = *usage
+	}
+	return report
+}
+
+// resetIfNewDay resets usage at the start of a new day, caller should hold the lock.
+func (m *QuotaManager) resetIfNewDay() {
+	day := startOfDay(utils.Now())
+	if day.Equal(m.day) {
+		return
+	}
+	m.day = day
+	m.callers = make(map[string]*QueryUsage)
+	m.tables = make(map[string]*QueryUsage)
+}
+
+// quotaCaller returns the caller queries are accounted to, which is the authenticated principal
+// if any. The caller set by clients is only trusted when authentication is not enabled.
+func quotaCaller(ctx context.Context) string {
+	if principal := utils.PrincipalFromContext(ctx); principal != nil {
+		return principal.Name
+	}
+	if caller := queryCom.CallerFromContext(ctx); caller != "" {
+		return caller
+	}
+	return UnknownCaller
+}
+
+func quotaExceededError(format string, args ...interface{}) error {
+	return utils.APIError{
+		Code:    http.StatusTooManyRequests,
+		Message: ErrMsgQuotaExceeded,
+		Cause:   utils.StackError(nil, format, args...),
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
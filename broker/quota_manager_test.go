@@ -0,0 +1,121 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/common"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
+)
+
+var _ = ginkgo.Describe("quota manager", func() {
+	var now time.Time
+
+	ginkgo.BeforeEach(func() {
+		now = time.Unix(86400+3600, 0)
+		utils.SetClockImplementation(func() time.Time {
+			return now
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		utils.ResetClockImplementation()
+	})
+
+	ginkgo.It("should account usage and enforce quotas", func() {
+		manager := NewQuotaManager(common.QueryQuotaConfig{
+			Enable: true,
+			DefaultCaller: common.QueryQuota{
+				Queries: 2,
+			},
+			Callers: map[string]common.QueryQuota{
+				"dashboard": {ScannedBytes: 100},
+			},
+			Tables: map[string]common.QueryQuota{
+				"trips": {GPUSeconds: 1.5},
+			},
+		})
+
+		Ω(manager.Check("svc", "trips")).Should(BeNil())
+		manager.Record("svc", "trips", QueryUsage{Queries: 1, ScannedBytes: 10, GPUSeconds: 1})
+		Ω(manager.Check("svc", "orders")).Should(BeNil())
+		manager.Record("svc", "orders", QueryUsage{Queries: 1, ScannedBytes: 20, GPUSeconds: 1})
+		// default caller quota of 2 queries is used up.
+		Ω(manager.Check("svc", "orders")).ShouldNot(BeNil())
+
+		Ω(manager.Check("dashboard", "trips")).Should(BeNil())
+		manager.Record("dashboard", "trips", QueryUsage{Queries: 1, ScannedBytes: 100, GPUSeconds: 0.5})
+		Ω(manager.Check("dashboard", "orders")).ShouldNot(BeNil())
+		// table quota of 1.5 gpu seconds is used up.
+		Ω(manager.Check("other", "trips")).ShouldNot(BeNil())
+		Ω(manager.Check("other", "orders")).Should(BeNil())
+
+		Ω(manager.GetUsage()).Should(Equal(QuotaUsageReport{
+			Day: time.Unix(86400, 0).UTC(),
+			Callers: map[string]QueryUsage{
+				"svc":       {Queries: 2, ScannedBytes: 30, GPUSeconds: 2},
+				"dashboard": {Queries: 1, ScannedBytes: 100, GPUSeconds: 0.5},
+			},
+			Tables: map[string]QueryUsage{
+				"trips":  {Queries: 2, ScannedBytes: 110, GPUSeconds: 1.5},
+				"orders": {Queries: 1, ScannedBytes: 20, GPUSeconds: 1},
+			},
+		}))
+
+		// usage is reset the next day.
+		now = now.Add(24 * time.Hour)
+		Ω(manager.Check("svc", "trips")).Should(BeNil())
+		Ω(manager.GetUsage().Callers).Should(BeEmpty())
+	})
+
+	ginkgo.It("should reject queries exceeding quota with too many requests", func() {
+		manager := NewQuotaManager(common.QueryQuotaConfig{
+			Enable:        true,
+			DefaultCaller: common.QueryQuota{Queries: 1},
+		})
+		manager.Record("svc", "trips", QueryUsage{Queries: 1})
+		err := manager.Check("svc", "trips")
+		Ω(err).Should(BeAssignableToTypeOf(utils.APIError{}))
+		Ω(err.(utils.APIError).Code).Should(Equal(http.StatusTooManyRequests))
+		Ω(err.Error()).Should(ContainSubstring("caller svc exceeded daily quota of queries"))
+	})
+
+	ginkgo.It("should account queries to the authenticated principal", func() {
+		ctx := context.Background()
+		Ω(quotaCaller(ctx)).Should(Equal(UnknownCaller))
+		ctx = queryCom.WithCaller(ctx, "svc")
+		Ω(quotaCaller(ctx)).Should(Equal("svc"))
+		// caller set by clients is ignored once authenticated.
+		ctx = utils.WithPrincipal(ctx, &utils.Principal{Name: "alice"})
+		Ω(quotaCaller(ctx)).Should(Equal("alice"))
+	})
+
+	ginkgo.It("should not enforce quotas when disabled", func() {
+		manager := NewQuotaManager(common.QueryQuotaConfig{
+			DefaultCaller: common.QueryQuota{
+				Queries: 1,
+			},
+		})
+		manager.Record("svc", "trips", QueryUsage{Queries: 1})
+		Ω(manager.Check("svc", "trips")).Should(BeNil())
+		Ω(manager.GetUsage().Callers["svc"].Queries).Should(Equal(int64(1)))
+	})
+})
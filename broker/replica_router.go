@@ -0,0 +1,72 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"github.com/uber/aresdb/broker/config"
+	"github.com/uber/aresdb/cluster/topology"
+)
+
+// replicaRouter separates datanode replicas serving heavy export queries from replicas serving
+// other queries, so that dashboards keep consistent latency while large exports are running.
+type replicaRouter struct {
+	analyticsHosts map[string]bool
+	// tables routing heavy export queries to analytics replicas, nil means all tables
+	tables   map[string]bool
+	minLimit int
+}
+
+// newReplicaRouter creates replicaRouter from config.
+func newReplicaRouter(cfg config.AnalyticsReplicaConfig) replicaRouter {
+	router := replicaRouter{
+		analyticsHosts: make(map[string]bool),
+		minLimit:       cfg.MinLimit,
+	}
+	for _, host := range cfg.Hosts {
+		router.analyticsHosts[host] = true
+	}
+	if len(cfg.Tables) > 0 {
+		router.tables = make(map[string]bool)
+		for _, table := range cfg.Tables {
+			router.tables[table] = true
+		}
+	}
+	return router
+}
+
+// isHeavyExport returns whether the query is a heavy export query routed to analytics replicas.
+func (r replicaRouter) isHeavyExport(qc *QueryContext) bool {
+	if !qc.IsNonAggregationQuery {
+		return false
+	}
+	if r.tables != nil && !r.tables[qc.AQLQuery.Table] {
+		return false
+	}
+	limit := qc.AQLQuery.Limit
+	return limit < 0 || (r.minLimit > 0 && limit >= r.minLimit)
+}
+
+// preferredHosts returns the filter of hosts preferred for routing the query: analytics replicas
+// for heavy export queries and other replicas for the rest. Nil is returned when there are no
+// analytics replicas.
+func (r replicaRouter) preferredHosts(qc *QueryContext) func(topology.Host) bool {
+	if len(r.analyticsHosts) == 0 {
+		return nil
+	}
+	heavyExport := r.isHeavyExport(qc)
+	return func(host topology.Host) bool {
+		return r.analyticsHosts[host.ID()] == heavyExport
+	}
+}
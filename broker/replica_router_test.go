@@ -0,0 +1,69 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/broker/config"
+	"github.com/uber/aresdb/cluster/topology"
+	queryCom "github.com/uber/aresdb/query/common"
+)
+
+var _ = ginkgo.Describe("replica router", func() {
+	analytics := topology.NewHost("analytics", "analytics:9374")
+	serving := topology.NewHost("serving", "serving:9374")
+
+	queryContext := func(table string, nonAgg bool, limit int) *QueryContext {
+		return &QueryContext{
+			AQLQuery:              &queryCom.AQLQuery{Table: table, Limit: limit},
+			IsNonAggregationQuery: nonAgg,
+		}
+	}
+
+	ginkgo.It("does not prefer any host without analytics replicas", func() {
+		router := newReplicaRouter(config.AnalyticsReplicaConfig{})
+		Ω(router.preferredHosts(queryContext("trips", true, -1))).Should(BeNil())
+	})
+
+	ginkgo.It("routes heavy export queries to analytics replicas", func() {
+		router := newReplicaRouter(config.AnalyticsReplicaConfig{
+			Hosts:    []string{"analytics"},
+			Tables:   []string{"trips"},
+			MinLimit: 10000,
+		})
+
+		for _, testCase := range []struct {
+			qc          *QueryContext
+			heavyExport bool
+		}{
+			{queryContext("trips", true, -1), true},
+			{queryContext("trips", true, 10000), true},
+			{queryContext("trips", true, 1000), false},
+			{queryContext("trips", false, -1), false},
+			{queryContext("users", true, -1), false},
+		} {
+			Ω(router.isHeavyExport(testCase.qc)).Should(Equal(testCase.heavyExport))
+			preferred := router.preferredHosts(testCase.qc)
+			Ω(preferred(analytics)).Should(Equal(testCase.heavyExport))
+			Ω(preferred(serving)).Should(Equal(!testCase.heavyExport))
+		}
+
+		// only queries without limit are heavy without min limit.
+		router = newReplicaRouter(config.AnalyticsReplicaConfig{Hosts: []string{"analytics"}})
+		Ω(router.isHeavyExport(queryContext("users", true, 100000))).Should(BeFalse())
+		Ω(router.isHeavyExport(queryContext("users", true, -1))).Should(BeTrue())
+	})
+})
@@ -92,13 +92,17 @@ func (c *resultMergeContext) mergeResultsRecursive(lhs, rhs interface{}) {
 			l = l / r
 		}
 		c.parent[c.path[len(c.path)-1]] = l
-	case queryCom.HLL:
-		r := rhs.(queryCom.HLL)
-		if c.agg != common.Hll {
+	case queryCom.Sketch:
+		// sketches of any registered type are merged by the sketch itself.
+		if l.SketchType() == queryCom.SketchTypeHLL && c.agg != common.Hll {
 			c.err = utils.StackError(nil, fmt.Sprintf("error merging: HLL value found for non Hll aggregation: %d", c.agg))
 		}
-		l.Merge(r)
-		c.parent[c.path[len(c.path)-1]] = l
+		merged, err := l.MergeSketch(rhs.(queryCom.Sketch))
+		if err != nil {
+			c.err = utils.StackError(err, "error merging sketches")
+			return
+		}
+		c.parent[c.path[len(c.path)-1]] = merged
 	case map[string]interface{}:
 		r := rhs.(map[string]interface{})
 		for k, lv := range l {
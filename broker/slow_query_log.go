@@ -0,0 +1,319 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/uber/aresdb/broker/config"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
+)
+
+const defaultSlowQueryLogBufferSize = 1000
+
+// SlowQueryTimings is the timing breakdown of a query in milliseconds.
+type SlowQueryTimings struct {
+	Parse   float64 `json:"parse"`
+	Compile float64 `json:"compile"`
+	// time waited for datanodes to respond
+	FanOut float64 `json:"fanOut"`
+	// time spent merging datanode results
+	Merge float64 `json:"merge"`
+	Total float64 `json:"total"`
+}
+
+// SlowQueryLogEntry is a record in slow query log.
+type SlowQueryLogEntry struct {
+	RequestID string    `json:"requestID"`
+	Origin    string    `json:"origin,omitempty"`
+	Time      time.Time `json:"time"`
+	SQL       string    `json:"sql,omitempty"`
+	// rewritten aql after compilation, nil if query failed to compile
+	AQL     *queryCom.AQLQuery `json:"aql,omitempty"`
+	Timings SlowQueryTimings   `json:"timings"`
+	// number of bytes of query result
+	ResultBytes int    `json:"resultBytes"`
+	Error       string `json:"error,omitempty"`
+}
+
+// SlowQuerySink is where slow query log entries are written to.
+type SlowQuerySink interface {
+	Write(entry SlowQueryLogEntry) error
+	Close() error
+}
+
+// SlowQueryLogger logs queries slower than threshold to sink asynchronously.
+// A nil SlowQueryLogger logs nothing.
+type SlowQueryLogger struct {
+	threshold  time.Duration
+	sampleRate float64
+	sink       SlowQuerySink
+	entries    chan SlowQueryLogEntry
+	done       chan struct{}
+}
+
+// NewSlowQueryLogger creates a SlowQueryLogger with the sink specified in config.
+// It returns nil if slow query log is not enabled.
+func NewSlowQueryLogger(cfg config.SlowQueryLogConfig) (*SlowQueryLogger, error) {
+	if !cfg.Enable {
+		return nil, nil
+	}
+
+	var sink SlowQuerySink
+	var err error
+	switch cfg.Sink {
+	case config.SlowQueryLogSinkLogger, "":
+		sink = loggerSlowQuerySink{}
+	case config.SlowQueryLogSinkFile:
+		sink, err = newFileSlowQuerySink(cfg.FilePath)
+	case config.SlowQueryLogSinkKafka:
+		sink, err = newKafkaSlowQuerySink(cfg.KafkaBrokers, cfg.KafkaTopic)
+	default:
+		err = utils.StackError(nil, "unknown slow query log sink %s", cfg.Sink)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewSlowQueryLoggerWithSink(cfg, sink), nil
+}
+
+// NewSlowQueryLoggerWithSink creates a SlowQueryLogger writing to given sink.
+func NewSlowQueryLoggerWithSink(cfg config.SlowQueryLogConfig, sink SlowQuerySink) *SlowQueryLogger {
+	bufferSize := cfg.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultSlowQueryLogBufferSize
+	}
+	l := &SlowQueryLogger{
+		threshold:  time.Duration(cfg.ThresholdMillis) * time.Millisecond,
+		sampleRate: cfg.SampleRate,
+		sink:       sink,
+		entries:    make(chan SlowQueryLogEntry, bufferSize),
+		done:       make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// Log logs the query if it's slower than threshold and sampled. It never blocks
+// and drops the entry if the buffer is full.
+func (l *SlowQueryLogger) Log(entry SlowQueryLogEntry) {
+	if l == nil || entry.Timings.Total < float64(l.threshold)/float64(time.Millisecond) {
+		return
+	}
+	if l.sampleRate > 0 && l.sampleRate < 1 && rand.Float64() >= l.sampleRate {
+		return
+	}
+
+	select {
+	case l.entries <- entry:
+	default:
+		utils.GetRootReporter().GetCounter(utils.SlowQueryLogDroppedBroker).Inc(1)
+	}
+}
+
+// Close stops the logger after writing buffered entries and closes the sink.
+func (l *SlowQueryLogger) Close() error {
+	if l == nil {
+		return nil
+	}
+	close(l.entries)
+	<-l.done
+	return l.sink.Close()
+}
+
+func (l *SlowQueryLogger) run() {
+	defer close(l.done)
+	for entry := range l.entries {
+		if err := l.sink.Write(entry); err != nil {
+			utils.GetLogger().With("error", err, "requestID", entry.RequestID).Error("failed to write slow query log")
+			continue
+		}
+		utils.GetRootReporter().GetCounter(utils.SlowQueryLoggedBroker).Inc(1)
+	}
+}
+
+// loggerSlowQuerySink writes slow queries to query logger.
+type loggerSlowQuerySink struct{}
+
+func (loggerSlowQuerySink) Write(entry SlowQueryLogEntry) error {
+	utils.GetQueryLogger().With("slowQuery", entry).Info("slow query")
+	return nil
+}
+
+func (loggerSlowQuerySink) Close() error {
+	return nil
+}
+
+// fileSlowQuerySink appends slow queries to a file, one json object per line.
+type fileSlowQuerySink struct {
+	file *os.File
+}
+
+func newFileSlowQuerySink(path string) (*fileSlowQuerySink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, utils.StackError(err, "failed to open slow query log file %s", path)
+	}
+	return &fileSlowQuerySink{file: file}, nil
+}
+
+func (s *fileSlowQuerySink) Write(entry SlowQueryLogEntry) error {
+	bs, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(append(bs, '\n'))
+	return err
+}
+
+func (s *fileSlowQuerySink) Close() error {
+	return s.file.Close()
+}
+
+// kafkaSlowQuerySink publishes slow queries to a kafka topic keyed by request id.
+type kafkaSlowQuerySink struct {
+	producer sarama.SyncProducer
+	topic    string
+}
+
+func newKafkaSlowQuerySink(brokers []string, topic string) (*kafkaSlowQuerySink, error) {
+	if len(brokers) == 0 || topic == "" {
+		return nil, utils.StackError(nil, "kafka brokers and topic are required for slow query log kafka sink")
+	}
+	cfg := sarama.NewConfig()
+	cfg.Producer.Return.Successes = true
+	producer, err := sarama.NewSyncProducer(brokers, cfg)
+	if err != nil {
+		return nil, utils.StackError(err, "failed to create kafka producer for slow query log")
+	}
+	return &kafkaSlowQuerySink{producer: producer, topic: topic}, nil
+}
+
+func (s *kafkaSlowQuerySink) Write(entry SlowQueryLogEntry) error {
+	bs, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, _, err = s.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: s.topic,
+		Key:   sarama.StringEncoder(entry.RequestID),
+		Value: sarama.ByteEncoder(bs),
+	})
+	return err
+}
+
+func (s *kafkaSlowQuerySink) Close() error {
+	return s.producer.Close()
+}
+
+type queryProfileContextKey struct{}
+
+// queryProfile collects compile, fan out and merge timings and result size of
+// a query during execution for slow query log.
+type queryProfile struct {
+	sync.Mutex
+	compile        time.Duration
+	fanOut         time.Duration
+	merge          time.Duration
+	resultBytes    int
+	rewrittenQuery *queryCom.AQLQuery
+}
+
+func withQueryProfile(ctx context.Context, profile *queryProfile) context.Context {
+	return context.WithValue(ctx, queryProfileContextKey{}, profile)
+}
+
+// queryProfileFromContext returns the queryProfile in context, nil if not found.
+func queryProfileFromContext(ctx context.Context) *queryProfile {
+	profile, _ := ctx.Value(queryProfileContextKey{}).(*queryProfile)
+	return profile
+}
+
+func (p *queryProfile) setCompile(duration time.Duration, rewrittenQuery queryCom.AQLQuery) {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	p.compile = duration
+	p.rewrittenQuery = &rewrittenQuery
+	p.Unlock()
+}
+
+// addFanOut records time waited for datanodes. Merge nodes of avg queries wait
+// concurrently so only the longest wait is kept.
+func (p *queryProfile) addFanOut(duration time.Duration) {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	if duration > p.fanOut {
+		p.fanOut = duration
+	}
+	p.Unlock()
+}
+
+func (p *queryProfile) addMerge(duration time.Duration) {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	p.merge += duration
+	p.Unlock()
+}
+
+func (p *queryProfile) addResultBytes(numBytes int) {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	p.resultBytes += numBytes
+	p.Unlock()
+}
+
+// toLogEntry converts the profile into a slow query log entry.
+func (p *queryProfile) toLogEntry(requestID, origin, sql string, parse, total time.Duration, err error) SlowQueryLogEntry {
+	p.Lock()
+	defer p.Unlock()
+	entry := SlowQueryLogEntry{
+		RequestID:   requestID,
+		Origin:      origin,
+		Time:        utils.Now().UTC(),
+		SQL:         sql,
+		AQL:         p.rewrittenQuery,
+		ResultBytes: p.resultBytes,
+		Timings: SlowQueryTimings{
+			Parse:   durationInMillis(parse),
+			Compile: durationInMillis(p.compile),
+			FanOut:  durationInMillis(p.fanOut),
+			Merge:   durationInMillis(p.merge),
+			Total:   durationInMillis(total),
+		},
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+func durationInMillis(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
@@ -0,0 +1,163 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/broker/config"
+	queryCom "github.com/uber/aresdb/query/common"
+)
+
+type memSlowQuerySink struct {
+	sync.Mutex
+	entries []SlowQueryLogEntry
+	closed  bool
+}
+
+func (s *memSlowQuerySink) Write(entry SlowQueryLogEntry) error {
+	s.Lock()
+	defer s.Unlock()
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *memSlowQuerySink) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	s.closed = true
+	return nil
+}
+
+var _ = ginkgo.Describe("slow query log", func() {
+	ginkgo.It("NewSlowQueryLogger should work", func() {
+		l, err := NewSlowQueryLogger(config.SlowQueryLogConfig{})
+		Ω(err).Should(BeNil())
+		Ω(l).Should(BeNil())
+		// nil logger should be no-op.
+		l.Log(SlowQueryLogEntry{Timings: SlowQueryTimings{Total: 1e6}})
+		Ω(l.Close()).Should(BeNil())
+
+		_, err = NewSlowQueryLogger(config.SlowQueryLogConfig{Enable: true, Sink: "unknown"})
+		Ω(err).ShouldNot(BeNil())
+
+		_, err = NewSlowQueryLogger(config.SlowQueryLogConfig{Enable: true, Sink: config.SlowQueryLogSinkKafka})
+		Ω(err).ShouldNot(BeNil())
+
+		l, err = NewSlowQueryLogger(config.SlowQueryLogConfig{Enable: true})
+		Ω(err).Should(BeNil())
+		Ω(l.sink).Should(Equal(loggerSlowQuerySink{}))
+		Ω(l.Close()).Should(BeNil())
+	})
+
+	ginkgo.It("Log should only log queries slower than threshold", func() {
+		sink := &memSlowQuerySink{}
+		l := NewSlowQueryLoggerWithSink(config.SlowQueryLogConfig{ThresholdMillis: 100}, sink)
+		l.Log(SlowQueryLogEntry{RequestID: "fast", Timings: SlowQueryTimings{Total: 99}})
+		l.Log(SlowQueryLogEntry{RequestID: "slow", Timings: SlowQueryTimings{Total: 100}})
+		Ω(l.Close()).Should(BeNil())
+		Ω(sink.closed).Should(BeTrue())
+		Ω(sink.entries).Should(HaveLen(1))
+		Ω(sink.entries[0].RequestID).Should(Equal("slow"))
+	})
+
+	ginkgo.It("Log should sample slow queries", func() {
+		sink := &memSlowQuerySink{}
+		l := NewSlowQueryLoggerWithSink(config.SlowQueryLogConfig{SampleRate: 0.5, BufferSize: 1000}, sink)
+		for i := 0; i < 1000; i++ {
+			l.Log(SlowQueryLogEntry{})
+		}
+		Ω(l.Close()).Should(BeNil())
+		Ω(len(sink.entries)).Should(BeNumerically(">", 0))
+		Ω(len(sink.entries)).Should(BeNumerically("<", 1000))
+	})
+
+	ginkgo.It("file sink should work", func() {
+		dir, err := ioutil.TempDir("", "slow_query_log")
+		Ω(err).Should(BeNil())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "slow_query.log")
+
+		l, err := NewSlowQueryLogger(config.SlowQueryLogConfig{
+			Enable:   true,
+			Sink:     config.SlowQueryLogSinkFile,
+			FilePath: path,
+		})
+		Ω(err).Should(BeNil())
+		l.Log(SlowQueryLogEntry{RequestID: "1", SQL: "select count(*) from t"})
+		l.Log(SlowQueryLogEntry{RequestID: "2", AQL: &queryCom.AQLQuery{Table: "t"}})
+		Ω(l.Close()).Should(BeNil())
+
+		file, err := os.Open(path)
+		Ω(err).Should(BeNil())
+		defer file.Close()
+		var entries []SlowQueryLogEntry
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var entry SlowQueryLogEntry
+			Ω(json.Unmarshal(scanner.Bytes(), &entry)).Should(BeNil())
+			entries = append(entries, entry)
+		}
+		Ω(entries).Should(HaveLen(2))
+		Ω(entries[0].SQL).Should(Equal("select count(*) from t"))
+		Ω(entries[1].AQL.Table).Should(Equal("t"))
+	})
+
+	ginkgo.It("queryProfile should work", func() {
+		var nilProfile *queryProfile
+		nilProfile.addFanOut(time.Second)
+		nilProfile.addMerge(time.Second)
+		nilProfile.addResultBytes(1)
+		nilProfile.setCompile(time.Second, queryCom.AQLQuery{})
+
+		profile := &queryProfile{}
+		ctx := withQueryProfile(context.Background(), profile)
+		Ω(queryProfileFromContext(ctx)).Should(Equal(profile))
+		Ω(queryProfileFromContext(context.Background())).Should(BeNil())
+
+		profile.setCompile(2*time.Millisecond, queryCom.AQLQuery{Table: "t"})
+		profile.addFanOut(10 * time.Millisecond)
+		profile.addFanOut(5 * time.Millisecond)
+		profile.addMerge(time.Millisecond)
+		profile.addMerge(time.Millisecond)
+		profile.addResultBytes(10)
+		profile.addResultBytes(20)
+
+		entry := profile.toLogEntry("req1", "test", "select 1", time.Millisecond, 20*time.Millisecond, errors.New("some error"))
+		Ω(entry.RequestID).Should(Equal("req1"))
+		Ω(entry.Origin).Should(Equal("test"))
+		Ω(entry.SQL).Should(Equal("select 1"))
+		Ω(entry.AQL.Table).Should(Equal("t"))
+		Ω(entry.Error).Should(Equal("some error"))
+		Ω(entry.ResultBytes).Should(Equal(30))
+		Ω(entry.Timings).Should(Equal(SlowQueryTimings{
+			Parse:   1,
+			Compile: 2,
+			FanOut:  10,
+			Merge:   2,
+			Total:   20,
+		}))
+	})
+})
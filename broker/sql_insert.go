@@ -0,0 +1,152 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"net/http"
+
+	apiCom "github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/client"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	"github.com/uber/aresdb/query/sql"
+	"github.com/uber/aresdb/utils"
+)
+
+// SQLInsertRequest represents a SQL INSERT INTO dim_table [(column, ...)] VALUES (...), ...
+// request, so that small lookup tables can be maintained without crafting upsert batches.
+// swagger:parameters sqlInsert
+type SQLInsertRequest struct {
+	// in: header
+	Origin string `header:"Rpc-Caller,optional" json:"origin"`
+	// in: body
+	Body struct {
+		SQL string `json:"sql"`
+	} `body:""`
+}
+
+// SQLInsertResponse represents the result of SQL INSERT.
+// swagger:response sqlInsertResponse
+type SQLInsertResponse struct {
+	Table string `json:"table"`
+	// number of rows of the statement
+	NumRows int `json:"numRows"`
+	// number of rows upserted into the table, rows with invalid values are ignored
+	NumRowsLoaded int `json:"numRowsLoaded"`
+}
+
+// HandleSQLInsert swagger:route POST /sql/insert sqlInsert
+// upserts rows of a SQL INSERT statement into a dimension table
+//
+// Consumes:
+//    - application/json
+//
+// Produces:
+//    - application/json
+//
+// Responses:
+//    default: errorResponse
+//        200: sqlInsertResponse
+func (handler *CTASHandler) HandleSQLInsert(w http.ResponseWriter, r *http.Request) {
+	var insertRequest SQLInsertRequest
+	var err error
+	ctx, span := utils.StartSpan(utils.WithAuthenticationFrom(context.Background(), r.Context()), "broker.HandleSQLInsert")
+	defer func() {
+		utils.EndSpan(span, err)
+		if err != nil {
+			utils.GetRootReporter().GetCounter(utils.SQLInsertFailedBroker).Inc(1)
+			utils.GetLogger().With(
+				"error", err,
+				"request", insertRequest).Error("Error happened when processing sql insert request")
+		} else {
+			utils.GetRootReporter().GetCounter(utils.SQLInsertSucceededBroker).Inc(1)
+		}
+	}()
+
+	err = apiCom.ReadRequest(r, &insertRequest)
+	if err != nil {
+		apiCom.RespondWithError(w, err)
+		return
+	}
+
+	var insert *sql.Insert
+	if insert, err = sql.ParseInsert(insertRequest.Body.SQL, utils.GetLogger()); err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	var table *metaCom.Table
+	if table, err = handler.schemaMutator.GetTable(handler.namespace, insert.Table); err != nil {
+		apiCom.RespondWithError(w, err)
+		return
+	}
+	var columnNames []string
+	if columnNames, err = getInsertColumns(table, insert); err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	rows := make([]client.Row, len(insert.Rows))
+	for i, row := range insert.Rows {
+		rows[i] = row
+	}
+	response := SQLInsertResponse{
+		Table:   table.Name,
+		NumRows: len(rows),
+	}
+	response.NumRowsLoaded, err = handler.load(ctx, table, columnNames, rows)
+	if err != nil {
+		err = utils.StackError(err, "only %d of %d rows are upserted into table %s", response.NumRowsLoaded, response.NumRows, table.Name)
+		apiCom.RespondWithError(w, err)
+		return
+	}
+	apiCom.RespondWithJSONObject(w, response)
+}
+
+// getInsertColumns validates the insert statement against the table and returns columns of the
+// values, which default to all columns of the table in schema order.
+func getInsertColumns(table *metaCom.Table, insert *sql.Insert) ([]string, error) {
+	if table.IsFactTable {
+		return nil, utils.StackError(nil, "INSERT is only supported for dimension tables but %s is a fact table", table.Name)
+	}
+
+	if len(insert.Columns) > 0 {
+		for _, columnName := range insert.Columns {
+			found := false
+			for _, column := range table.Columns {
+				if !column.Deleted && column.Name == columnName {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, utils.StackError(nil, "column %s does not exist in table %s", columnName, table.Name)
+			}
+		}
+		return insert.Columns, nil
+	}
+
+	var columnNames []string
+	for _, column := range table.Columns {
+		if !column.Deleted {
+			columnNames = append(columnNames, column.Name)
+		}
+	}
+	if len(insert.Rows) > 0 && len(insert.Rows[0]) != len(columnNames) {
+		return nil, utils.StackError(nil, "table %s has %d columns but %d values are provided per row",
+			table.Name, len(columnNames), len(insert.Rows[0]))
+	}
+	return columnNames, nil
+}
@@ -0,0 +1,316 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	apiCom "github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/broker/common"
+	"github.com/uber/aresdb/broker/config"
+	"github.com/uber/aresdb/client"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/query/sql"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	defaultTempTableTTLSeconds          = 600
+	defaultTempTableMaxTTLSeconds       = 3600
+	defaultTempTableMaxTablesPerSession = 16
+
+	// TempTableSessionHeaderKey is the header of the session that temporary tables belong to.
+	TempTableSessionHeaderKey = "X-Ares-Session"
+)
+
+// TempTableRequest represents request to materialize query results into a temporary table
+// of the session. The table can be joined by name in later queries of the same session
+// until it expires.
+// swagger:parameters createTempTable
+type TempTableRequest struct {
+	// in: header
+	Origin string `header:"Rpc-Caller,optional" json:"origin"`
+	// in: header
+	Session string `header:"X-Ares-Session" json:"session"`
+	// in: body
+	Body struct {
+		Name string `json:"name"`
+		// either aql or sql should be provided
+		AQL *queryCom.AQLQuery `json:"aql,omitempty"`
+		SQL string             `json:"sql,omitempty"`
+		// defaults to default_ttl_seconds of the temp table config
+		TTLSeconds int `json:"ttlSeconds,omitempty"`
+	} `body:""`
+}
+
+// TempTableResponse represents the result of creating a temporary table.
+// swagger:response createTempTableResponse
+type TempTableResponse struct {
+	Table   string `json:"table"`
+	NumRows int    `json:"numRows"`
+	// unix seconds when the table expires
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// DropTempTableRequest represents request to drop a temporary table of the session.
+// swagger:parameters dropTempTable
+type DropTempTableRequest struct {
+	// in: path
+	Name string `path:"name" json:"name"`
+	// in: header
+	Session string `header:"X-Ares-Session" json:"session"`
+}
+
+// tempTable is a materialized query result kept in broker memory.
+type tempTable struct {
+	headers   []string
+	rows      [][]interface{}
+	expiresAt time.Time
+}
+
+// TempTableHandler manages session scoped temporary tables, so that results of expensive
+// queries can be reused by follow up queries during iterative analysis without scanning
+// datanodes again. Temporary tables are joined as inline tables by the broker, thus they
+// are subject to the same row limit and only supported in joins of aggregation queries.
+type TempTableHandler struct {
+	sync.RWMutex
+
+	exec common.QueryExecutor
+	cfg  config.TempTableConfig
+	// temporary tables by session key and table name
+	sessions map[string]map[string]*tempTable
+}
+
+// NewTempTableHandler creates a new TempTableHandler.
+func NewTempTableHandler(exec common.QueryExecutor, cfg config.TempTableConfig) *TempTableHandler {
+	if cfg.DefaultTTLSeconds <= 0 {
+		cfg.DefaultTTLSeconds = defaultTempTableTTLSeconds
+	}
+	if cfg.MaxTTLSeconds <= 0 {
+		cfg.MaxTTLSeconds = defaultTempTableMaxTTLSeconds
+	}
+	if cfg.MaxTablesPerSession <= 0 {
+		cfg.MaxTablesPerSession = defaultTempTableMaxTablesPerSession
+	}
+	return &TempTableHandler{
+		exec:     exec,
+		cfg:      cfg,
+		sessions: make(map[string]map[string]*tempTable),
+	}
+}
+
+// Register registers http handlers.
+func (handler *TempTableHandler) Register(router *mux.Router, wrappers ...utils.HTTPHandlerWrapper) {
+	router.HandleFunc("/temp_tables", utils.ApplyHTTPWrappers(handler.HandleCreateTempTable, wrappers)).Methods(http.MethodPost)
+	router.HandleFunc("/temp_tables/{name}", utils.ApplyHTTPWrappers(handler.HandleDropTempTable, wrappers)).Methods(http.MethodDelete)
+}
+
+// HandleCreateTempTable swagger:route POST /temp_tables createTempTable
+// materializes results of the query into a temporary table of the session
+//
+// Consumes:
+//    - application/json
+//
+// Produces:
+//    - application/json
+//
+// Responses:
+//    default: errorResponse
+//        200: createTempTableResponse
+func (handler *TempTableHandler) HandleCreateTempTable(w http.ResponseWriter, r *http.Request) {
+	var tempTableRequest TempTableRequest
+	var err error
+	ctx, span := utils.StartSpan(utils.WithAuthenticationFrom(context.Background(), r.Context()), "broker.HandleCreateTempTable")
+	defer func() {
+		utils.EndSpan(span, err)
+		if err != nil {
+			utils.GetRootReporter().GetCounter(utils.TempTableCreateFailedBroker).Inc(1)
+			utils.GetLogger().With(
+				"error", err,
+				"request", tempTableRequest).Error("Error happened when creating temporary table")
+		} else {
+			utils.GetRootReporter().GetCounter(utils.TempTableCreatedBroker).Inc(1)
+		}
+	}()
+
+	err = apiCom.ReadRequest(r, &tempTableRequest)
+	if err != nil {
+		apiCom.RespondWithError(w, err)
+		return
+	}
+
+	if tempTableRequest.Body.Name == "" {
+		err = utils.StackError(nil, "name of temporary table is required")
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+	ttlSeconds := tempTableRequest.Body.TTLSeconds
+	if ttlSeconds <= 0 {
+		ttlSeconds = handler.cfg.DefaultTTLSeconds
+	}
+	if ttlSeconds > handler.cfg.MaxTTLSeconds {
+		err = utils.StackError(nil, "ttl %d seconds exceeds limit %d seconds", ttlSeconds, handler.cfg.MaxTTLSeconds)
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	var aql *queryCom.AQLQuery
+	if tempTableRequest.Body.SQL != "" {
+		aql, err = sql.Parse(tempTableRequest.Body.SQL, utils.GetLogger())
+	} else if aql = tempTableRequest.Body.AQL; aql == nil {
+		err = utils.StackError(nil, "either aql or sql is required")
+	}
+	if err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	sessionKey := getTempTableSessionKey(ctx, tempTableRequest.Session)
+	// temporary tables materialized earlier can be joined when building new ones.
+	handler.resolve(sessionKey, aql)
+
+	table := &tempTable{}
+	var rows []client.Row
+	if table.headers, rows, err = executeQueryForRows(queryCom.WithCaller(ctx, tempTableRequest.Origin), handler.exec, "temp_table", aql); err != nil {
+		apiCom.RespondWithError(w, err)
+		return
+	}
+	if len(rows) > maxInlineTableRows {
+		err = utils.StackError(nil, "query returns %d rows, exceeding temporary table limit %d", len(rows), maxInlineTableRows)
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+	table.rows = make([][]interface{}, len(rows))
+	for i, row := range rows {
+		table.rows[i] = row
+	}
+	table.expiresAt = utils.Now().Add(time.Duration(ttlSeconds) * time.Second)
+
+	if err = handler.put(sessionKey, tempTableRequest.Body.Name, table); err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+	apiCom.RespondWithJSONObject(w, TempTableResponse{
+		Table:     tempTableRequest.Body.Name,
+		NumRows:   len(table.rows),
+		ExpiresAt: table.expiresAt.Unix(),
+	})
+}
+
+// HandleDropTempTable swagger:route DELETE /temp_tables/{name} dropTempTable
+// drops the temporary table of the session
+//
+// Responses:
+//    default: errorResponse
+//        200: noContentResponse
+func (handler *TempTableHandler) HandleDropTempTable(w http.ResponseWriter, r *http.Request) {
+	var dropRequest DropTempTableRequest
+	if err := apiCom.ReadRequest(r, &dropRequest); err != nil {
+		apiCom.RespondWithError(w, err)
+		return
+	}
+
+	sessionKey := getTempTableSessionKey(r.Context(), dropRequest.Session)
+	handler.Lock()
+	delete(handler.sessions[sessionKey], dropRequest.Name)
+	if len(handler.sessions[sessionKey]) == 0 {
+		delete(handler.sessions, sessionKey)
+	}
+	handler.Unlock()
+	apiCom.RespondWithJSONObject(w, nil)
+}
+
+// ResolveTempTables replaces joins of temporary tables of the session with inline tables
+// holding their rows. Temporary tables shadow tables stored in datanodes with the same name.
+// It's a no-op when session is empty.
+func (handler *TempTableHandler) ResolveTempTables(ctx context.Context, session string, aql *queryCom.AQLQuery) {
+	if handler == nil || session == "" {
+		return
+	}
+	handler.resolve(getTempTableSessionKey(ctx, session), aql)
+}
+
+func (handler *TempTableHandler) resolve(sessionKey string, aql *queryCom.AQLQuery) {
+	now := utils.Now()
+	handler.RLock()
+	defer handler.RUnlock()
+	tables := handler.sessions[sessionKey]
+	if len(tables) == 0 {
+		return
+	}
+
+	joins := make([]queryCom.Join, len(aql.Joins))
+	for i, join := range aql.Joins {
+		joins[i] = join
+		if join.InlineTable != nil {
+			continue
+		}
+		table := tables[join.Table]
+		if table == nil || !now.Before(table.expiresAt) {
+			continue
+		}
+		if joins[i].Alias == "" {
+			joins[i].Alias = join.Table
+		}
+		joins[i].InlineTable = &queryCom.InlineTable{
+			Columns: table.headers,
+			Rows:    table.rows,
+		}
+		utils.GetRootReporter().GetCounter(utils.TempTableJoinedBroker).Inc(1)
+	}
+	aql.Joins = joins
+}
+
+// put stores the table into the session, replacing existing table with the same name.
+// Expired tables of all sessions are purged along the way.
+func (handler *TempTableHandler) put(sessionKey, name string, table *tempTable) error {
+	now := utils.Now()
+	handler.Lock()
+	defer handler.Unlock()
+	for key, tables := range handler.sessions {
+		for tableName, t := range tables {
+			if !now.Before(t.expiresAt) {
+				delete(tables, tableName)
+			}
+		}
+		if len(tables) == 0 {
+			delete(handler.sessions, key)
+		}
+	}
+
+	tables := handler.sessions[sessionKey]
+	if tables == nil {
+		tables = make(map[string]*tempTable)
+		handler.sessions[sessionKey] = tables
+	}
+	if _, exists := tables[name]; !exists && len(tables) >= handler.cfg.MaxTablesPerSession {
+		return utils.StackError(nil, "session already has %d temporary tables", len(tables))
+	}
+	tables[name] = table
+	return nil
+}
+
+// getTempTableSessionKey scopes the session to the authenticated principal so that
+// temporary tables are never shared across principals.
+func getTempTableSessionKey(ctx context.Context, session string) string {
+	if principal := utils.PrincipalFromContext(ctx); principal != nil {
+		return principal.Name + "/" + session
+	}
+	return session
+}
@@ -0,0 +1,140 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	"github.com/uber/aresdb/broker/common/mocks"
+	"github.com/uber/aresdb/broker/config"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
+)
+
+var _ = ginkgo.Describe("temporary table", func() {
+	var (
+		testServer *httptest.Server
+		mockExec   *mocks.QueryExecutor
+		handler    *TempTableHandler
+	)
+
+	ginkgo.BeforeEach(func() {
+		utils.SetCurrentTime(time.Unix(1570000000, 0))
+		mockExec = &mocks.QueryExecutor{}
+		handler = NewTempTableHandler(mockExec, config.TempTableConfig{MaxTablesPerSession: 1})
+		router := mux.NewRouter()
+		handler.Register(router)
+		testServer = httptest.NewServer(router)
+	})
+
+	ginkgo.AfterEach(func() {
+		testServer.Close()
+		utils.ResetClockImplementation()
+	})
+
+	createTempTable := func(name string, ttlSeconds int) *http.Response {
+		body, _ := json.Marshal(map[string]interface{}{
+			"name":       name,
+			"sql":        "SELECT city_id, count(*) AS trips FROM trips GROUP BY city_id",
+			"ttlSeconds": ttlSeconds,
+		})
+		req, _ := http.NewRequest(http.MethodPost, testServer.URL+"/temp_tables", bytes.NewReader(body))
+		req.Header.Set(TempTableSessionHeaderKey, "s1")
+		resp, err := http.DefaultClient.Do(req)
+		Ω(err).Should(BeNil())
+		return resp
+	}
+
+	joinQuery := func() *queryCom.AQLQuery {
+		return &queryCom.AQLQuery{
+			Table:      "cities",
+			Joins:      []queryCom.Join{{Table: "t1", Conditions: []string{"t1.city_id = cities.id"}}},
+			Dimensions: []queryCom.Dimension{{Expr: "t1.trips"}},
+			Measures:   []queryCom.Measure{{Expr: "count(*)"}},
+		}
+	}
+
+	ginkgo.It("should materialize query results and resolve joins within the session", func() {
+		mockExec.On("Execute", mock.Anything, "temp_table", mock.Anything, false, mock.Anything).
+			Run(func(args mock.Arguments) {
+				args.Get(4).(http.ResponseWriter).Write([]byte(`{"headers":["city_id","trips"],"columns":[["1","2"],[3,"NULL"]]}`))
+			}).Return(nil).Once()
+
+		resp := createTempTable("t1", 0)
+		bs, _ := ioutil.ReadAll(resp.Body)
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK), string(bs))
+		var response TempTableResponse
+		Ω(json.Unmarshal(bs, &response)).Should(BeNil())
+		Ω(response).Should(Equal(TempTableResponse{
+			Table:     "t1",
+			NumRows:   2,
+			ExpiresAt: 1570000000 + defaultTempTableTTLSeconds,
+		}))
+
+		aql := joinQuery()
+		handler.ResolveTempTables(context.Background(), "s1", aql)
+		Ω(aql.Joins[0].Alias).Should(Equal("t1"))
+		Ω(*aql.Joins[0].InlineTable).Should(Equal(queryCom.InlineTable{
+			Columns: []string{"city_id", "trips"},
+			Rows:    [][]interface{}{{"1", 3.0}, {"2", nil}},
+		}))
+
+		// other sessions and principals do not see the table.
+		aql = joinQuery()
+		handler.ResolveTempTables(context.Background(), "s2", aql)
+		Ω(aql.Joins[0].InlineTable).Should(BeNil())
+		handler.ResolveTempTables(utils.WithPrincipal(context.Background(), &utils.Principal{Name: "p1"}), "s1", aql)
+		Ω(aql.Joins[0].InlineTable).Should(BeNil())
+
+		// expired table is not resolved.
+		utils.SetCurrentTime(time.Unix(1570000000+defaultTempTableTTLSeconds, 0))
+		handler.ResolveTempTables(context.Background(), "s1", aql)
+		Ω(aql.Joins[0].InlineTable).Should(BeNil())
+	})
+
+	ginkgo.It("should limit tables per session and drop tables", func() {
+		mockExec.On("Execute", mock.Anything, "temp_table", mock.Anything, false, mock.Anything).
+			Run(func(args mock.Arguments) {
+				args.Get(4).(http.ResponseWriter).Write([]byte(`{"headers":["city_id","trips"],"columns":[["1"],[3]]}`))
+			}).Return(nil)
+
+		Ω(createTempTable("t1", 60).StatusCode).Should(Equal(http.StatusOK))
+		// replacing the table with the same name is allowed.
+		Ω(createTempTable("t1", 60).StatusCode).Should(Equal(http.StatusOK))
+		Ω(createTempTable("t2", 60).StatusCode).Should(Equal(http.StatusBadRequest))
+		Ω(createTempTable("t2", defaultTempTableMaxTTLSeconds+1).StatusCode).Should(Equal(http.StatusBadRequest))
+
+		req, _ := http.NewRequest(http.MethodDelete, testServer.URL+"/temp_tables/t1", nil)
+		req.Header.Set(TempTableSessionHeaderKey, "s1")
+		resp, err := http.DefaultClient.Do(req)
+		Ω(err).Should(BeNil())
+		Ω(resp.StatusCode).Should(Equal(http.StatusOK))
+
+		aql := joinQuery()
+		handler.ResolveTempTables(context.Background(), "s1", aql)
+		Ω(aql.Joins[0].InlineTable).Should(BeNil())
+		Ω(createTempTable("t2", 60).StatusCode).Should(Equal(http.StatusOK))
+	})
+})
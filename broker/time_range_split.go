@@ -0,0 +1,145 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/uber/aresdb/broker/common"
+	"github.com/uber/aresdb/broker/config"
+	"github.com/uber/aresdb/cluster/topology"
+	dataCli "github.com/uber/aresdb/datanode/client"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	defaultTimeRangeSplitMinDays        = 31
+	defaultTimeRangeSplitChunkDays      = 7
+	defaultTimeRangeSplitMaxParallelism = 4
+	// numbers no larger than this are not treated as timestamps in time filters.
+	maxNonTimestampTimeFilterNumber = 9999999
+)
+
+// timeRangeSplitter splits aggregation queries spanning long time ranges into sub-queries of
+// consecutive time range chunks. Chunks are executed with bounded parallelism and their results
+// are merged like results of different datanodes, so that datanodes process less data at a
+// time and progress can be reported as chunks finish.
+type timeRangeSplitter struct {
+	enabled        bool
+	minRange       time.Duration
+	chunk          time.Duration
+	maxParallelism int
+}
+
+// newTimeRangeSplitter creates timeRangeSplitter from config, defaults are used for unset values.
+func newTimeRangeSplitter(cfg config.TimeRangeSplitConfig) timeRangeSplitter {
+	days := func(days, defaultDays int) time.Duration {
+		if days <= 0 {
+			days = defaultDays
+		}
+		return time.Duration(days) * 24 * time.Hour
+	}
+	maxParallelism := cfg.MaxParallelism
+	if maxParallelism <= 0 {
+		maxParallelism = defaultTimeRangeSplitMaxParallelism
+	}
+	return timeRangeSplitter{
+		enabled:        cfg.Enable,
+		minRange:       days(cfg.MinDays, defaultTimeRangeSplitMinDays),
+		chunk:          days(cfg.ChunkDays, defaultTimeRangeSplitChunkDays),
+		maxParallelism: maxParallelism,
+	}
+}
+
+// split returns time filters of the chunks the query should be split into, nil is returned if
+// the query should not be split. Chunks are half open ranges of absolute timestamps so that all
+// datanodes see the same time range no matter when they receive the query.
+func (s timeRangeSplitter) split(qc *QueryContext, now time.Time) []queryCom.TimeFilter {
+	if !s.enabled || qc.IsNonAggregationQuery || qc.AQLQuery.TimeFilter.From == "" {
+		return nil
+	}
+
+	// queries with timezones from a column are not split since their time filters are
+	// parsed by datanodes in UTC.
+	loc := time.UTC
+	if qc.AQLQuery.Timezone != "" {
+		var err error
+		if loc, err = queryCom.ParseTimezone(qc.AQLQuery.Timezone); err != nil {
+			return nil
+		}
+	}
+	from, to, err := queryCom.ParseTimeFilter(qc.AQLQuery.TimeFilter, loc, now)
+	if err != nil || from == nil || to == nil ||
+		from.Time.Unix() <= maxNonTimestampTimeFilterNumber || to.Time.Sub(from.Time) <= s.minRange {
+		return nil
+	}
+
+	var chunks []queryCom.TimeFilter
+	for start := from.Time; start.Before(to.Time); start = start.Add(s.chunk) {
+		end := start.Add(s.chunk)
+		if end.After(to.Time) {
+			end = to.Time
+		}
+		chunks = append(chunks, queryCom.TimeFilter{
+			Column: qc.AQLQuery.TimeFilter.Column,
+			From:   strconv.FormatInt(start.Unix(), 10),
+			To:     strconv.FormatInt(end.Unix(), 10),
+		})
+	}
+	return chunks
+}
+
+// buildTimeRangeSplitPlan builds the sub plan of the query for each time range chunk, chunks
+// are merged by a merge node executing at most TimeRangeParallelism chunks concurrently. The
+// sub plan is built directly if the query is not split.
+func buildTimeRangeSplitPlan(agg common.AggType, qc QueryContext, assignments map[topology.Host][]uint32, topo topology.HealthTrackingDynamicTopoloy, client dataCli.DataNodeQueryClient) common.MergeNode {
+	chunks := qc.TimeRangeChunks
+	if len(chunks) == 0 {
+		return buildSubPlan(agg, qc, assignments, topo, client)
+	}
+
+	var numFinished int32
+	root := &mergeNodeImpl{
+		aggType:                 agg,
+		maxDimensionCardinality: qc.MaxDimensionCardinality,
+		dimensions:              qc.AQLQuery.Dimensions,
+		// each chunk waits for datanodes within its own scatter timeout.
+		timeouts:       QueryTimeouts{Merge: qc.Timeouts.Merge},
+		maxParallelism: qc.TimeRangeParallelism,
+		onChildFinished: func(i int) {
+			utils.GetRootReporter().GetCounter(utils.TimeRangeChunkFinishedBroker).Inc(1)
+			utils.GetLogger().With(
+				"requestID", qc.RequestID,
+				"table", qc.AQLQuery.Table,
+				"from", chunks[i].From,
+				"to", chunks[i].To,
+				"finished", atomic.AddInt32(&numFinished, 1),
+				"chunks", len(chunks),
+			).Info("time range chunk finished")
+		},
+	}
+	for _, chunk := range chunks {
+		chunkQuery := *qc.AQLQuery
+		chunkQuery.TimeFilter = chunk
+		chunkQc := qc
+		chunkQc.AQLQuery = &chunkQuery
+		chunkQc.TimeRangeChunks = nil
+		root.Add(buildSubPlan(agg, chunkQc, assignments, topo, client))
+	}
+	return root
+}
@@ -0,0 +1,164 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/broker/common"
+	"github.com/uber/aresdb/broker/config"
+	"github.com/uber/aresdb/cluster/topology"
+	topoMock "github.com/uber/aresdb/cluster/topology/mocks"
+	queryCom "github.com/uber/aresdb/query/common"
+)
+
+// concurrencyTrackingNode is a plan node recording the max number of nodes executing concurrently.
+type concurrencyTrackingNode struct {
+	sync.Mutex
+	running, maxRunning int
+}
+
+func (n *concurrencyTrackingNode) Execute(ctx context.Context) (queryCom.AQLQueryResult, error) {
+	n.Lock()
+	n.running++
+	if n.running > n.maxRunning {
+		n.maxRunning = n.running
+	}
+	n.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	n.Lock()
+	n.running--
+	n.Unlock()
+	return queryCom.AQLQueryResult{"1": map[string]interface{}{"dim1": float64(1)}}, nil
+}
+
+func (n *concurrencyTrackingNode) Children() []common.BlockingPlanNode { return nil }
+
+func (n *concurrencyTrackingNode) Add(...common.BlockingPlanNode) {}
+
+var _ = ginkgo.Describe("time range split", func() {
+	now := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	splitter := newTimeRangeSplitter(config.TimeRangeSplitConfig{Enable: true})
+
+	queryContext := func(from, to, timezone string) *QueryContext {
+		return &QueryContext{AQLQuery: &queryCom.AQLQuery{
+			Table:      "trips",
+			TimeFilter: queryCom.TimeFilter{Column: "request_at", From: from, To: to},
+			Timezone:   timezone,
+		}}
+	}
+
+	ginkgo.It("newTimeRangeSplitter should use defaults", func() {
+		Ω(splitter).Should(Equal(timeRangeSplitter{
+			enabled:        true,
+			minRange:       31 * 24 * time.Hour,
+			chunk:          7 * 24 * time.Hour,
+			maxParallelism: 4,
+		}))
+	})
+
+	ginkgo.It("should split long time ranges into chunks", func() {
+		chunks := splitter.split(queryContext("-60d", "", ""), now)
+		Ω(chunks).Should(HaveLen(9))
+		// 2018-12-31 00:00:00 UTC.
+		Ω(chunks[0]).Should(Equal(queryCom.TimeFilter{Column: "request_at", From: "1546214400", To: "1546819200"}))
+		Ω(chunks[1].From).Should(Equal(chunks[0].To))
+		// last chunk is shorter and ends now.
+		Ω(chunks[8]).Should(Equal(queryCom.TimeFilter{Column: "request_at", From: "1551052800", To: "1551398400"}))
+
+		// chunks are aligned in the query timezone, date in `to` is inclusive.
+		chunks = splitter.split(queryContext("2019-01-01", "2019-02-01", "America/Los_Angeles"), now)
+		Ω(chunks).Should(HaveLen(5))
+		// 2019-01-01 00:00:00 PST.
+		Ω(chunks[0].From).Should(Equal("1546329600"))
+		// 2019-02-02 00:00:00 PST.
+		Ω(chunks[4].To).Should(Equal("1549094400"))
+	})
+
+	ginkgo.It("should not split other queries", func() {
+		Ω(newTimeRangeSplitter(config.TimeRangeSplitConfig{}).split(queryContext("-60d", "", ""), now)).Should(BeNil())
+		Ω(splitter.split(queryContext("-30d", "", ""), now)).Should(BeNil())
+		Ω(splitter.split(queryContext("", "", ""), now)).Should(BeNil())
+		Ω(splitter.split(queryContext("-60d", "", "timezone(city_id)"), now)).Should(BeNil())
+		Ω(splitter.split(queryContext("bad", "", ""), now)).Should(BeNil())
+
+		qc := queryContext("-60d", "", "")
+		qc.IsNonAggregationQuery = true
+		Ω(splitter.split(qc, now)).Should(BeNil())
+	})
+
+	ginkgo.It("buildTimeRangeSplitPlan should build sub plans per chunk", func() {
+		qc := *queryContext("-60d", "", "")
+		qc.TimeRangeChunks = splitter.split(&qc, now)
+		qc.TimeRangeParallelism = 2
+		qc.Timeouts = QueryTimeouts{Scatter: time.Second, Merge: time.Minute}
+		assignments := map[topology.Host][]uint32{
+			&topoMock.Host{}: {0, 1},
+			&topoMock.Host{}: {2, 3},
+		}
+
+		root, ok := buildTimeRangeSplitPlan(common.Count, qc, assignments, nil, nil).(*mergeNodeImpl)
+		Ω(ok).Should(BeTrue())
+		Ω(root.aggType).Should(Equal(common.Count))
+		Ω(root.maxParallelism).Should(Equal(2))
+		Ω(root.timeouts).Should(Equal(QueryTimeouts{Merge: time.Minute}))
+		Ω(root.children).Should(HaveLen(len(qc.TimeRangeChunks)))
+		for i, child := range root.children {
+			chunkNode := child.(*mergeNodeImpl)
+			Ω(chunkNode.children).Should(HaveLen(2))
+			for _, scan := range chunkNode.children {
+				scanQc := scan.(*BlockingScanNode).qc
+				Ω(scanQc.AQLQuery.TimeFilter).Should(Equal(qc.TimeRangeChunks[i]))
+				Ω(scanQc.TimeRangeChunks).Should(BeNil())
+			}
+		}
+		// original query is not modified.
+		Ω(qc.AQLQuery.TimeFilter.From).Should(Equal("-60d"))
+
+		qc.TimeRangeChunks = nil
+		root = buildTimeRangeSplitPlan(common.Count, qc, assignments, nil, nil).(*mergeNodeImpl)
+		Ω(root.children).Should(HaveLen(2))
+		_, ok = root.children[0].(*BlockingScanNode)
+		Ω(ok).Should(BeTrue())
+	})
+
+	ginkgo.It("merge node should bound parallelism and report finished children", func() {
+		tracker := &concurrencyTrackingNode{}
+		var lock sync.Mutex
+		var finished []int
+		node := &mergeNodeImpl{
+			aggType:        common.Count,
+			maxParallelism: 2,
+			onChildFinished: func(i int) {
+				lock.Lock()
+				finished = append(finished, i)
+				lock.Unlock()
+			},
+		}
+		for i := 0; i < 5; i++ {
+			node.Add(tracker)
+		}
+
+		res, err := node.Execute(context.TODO())
+		Ω(err).Should(BeNil())
+		Ω(res).Should(Equal(queryCom.AQLQueryResult{"1": map[string]interface{}{"dim1": float64(5)}}))
+		Ω(tracker.maxRunning).Should(BeNumerically("<=", 2))
+		Ω(finished).Should(ConsistOf(0, 1, 2, 3, 4))
+	})
+})
@@ -0,0 +1,245 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/query/expr"
+	"github.com/uber/aresdb/utils"
+)
+
+// topN keeps the first limit groups of aggregation results ordered by the sort fields. Groups
+// are selected from the merged results keyed by enum ids, so only dimension values compared
+// while sorting and those of the selected groups are translated.
+type topN struct {
+	limit int
+	sorts []topNSort
+}
+
+type topNSort struct {
+	// index of the dimension to sort by, -1 for the measure.
+	dimIndex int
+	desc     bool
+}
+
+// topNRow is a group of aggregation results.
+type topNRow struct {
+	dimValues []string
+	measure   interface{}
+}
+
+// processTopN resolves the sort fields of aggregation queries with limit. The measure in
+// descending order is used if no sort field is specified.
+func (qc *QueryContext) processTopN() {
+	if qc.IsNonAggregationQuery || qc.ReturnHLLBinary || qc.AQLQuery.Limit <= 0 {
+		return
+	}
+	if qc.OutlierDetection != nil || qc.TimeSeriesForecast != nil {
+		qc.Error = utils.StackError(nil, "limit is not supported together with zscore_outliers or forecast")
+		return
+	}
+
+	t := &topN{limit: qc.AQLQuery.Limit}
+	for _, field := range qc.AQLQuery.Sorts {
+		var desc bool
+		switch strings.ToLower(field.Order) {
+		case "", "asc":
+		case "desc":
+			desc = true
+		default:
+			qc.Error = utils.StackError(nil, "unknown sort order %s of %s", field.Order, field.Name)
+			return
+		}
+		dimIndex, found := qc.resolveSortField(field.Name)
+		if !found {
+			qc.Error = utils.StackError(nil, "sort field %s is neither the measure nor a dimension", field.Name)
+			return
+		}
+		t.sorts = append(t.sorts, topNSort{dimIndex: dimIndex, desc: desc})
+	}
+	if len(t.sorts) == 0 {
+		t.sorts = []topNSort{{dimIndex: -1, desc: true}}
+	}
+	qc.TopN = t
+}
+
+// resolveSortField returns the index of the dimension matching the sort field name by alias or
+// expression, or -1 for the measure.
+func (qc *QueryContext) resolveSortField(name string) (int, bool) {
+	matches := func(alias, expression string, parsed expr.Expr) bool {
+		return (alias != "" && alias == name) || strings.EqualFold(expression, name) ||
+			(parsed != nil && strings.EqualFold(parsed.String(), name))
+	}
+	measure := qc.AQLQuery.Measures[0]
+	if matches(measure.Alias, measure.Expr, measure.ExprParsed) {
+		return -1, true
+	}
+	for i, dim := range qc.AQLQuery.Dimensions {
+		if matches(dim.Alias, dim.Expr, dim.ExprParsed) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// flatten returns the groups of nested results.
+func (t *topN) flatten(results map[string]interface{}, numDims int) (rows []topNRow) {
+	dimValues := make([]string, numDims)
+	var traverse func(depth int, curr map[string]interface{})
+	traverse = func(depth int, curr map[string]interface{}) {
+		for key, child := range curr {
+			dimValues[depth] = key
+			if depth < numDims-1 {
+				switch children := child.(type) {
+				case map[string]interface{}:
+					traverse(depth+1, children)
+				case common.AQLQueryResult:
+					traverse(depth+1, children)
+				}
+				continue
+			}
+			rows = append(rows, topNRow{dimValues: append([]string(nil), dimValues...), measure: child})
+		}
+	}
+	if numDims > 0 {
+		traverse(0, results)
+	}
+	return
+}
+
+// sortRows orders the groups by the sort fields, dimension values are compared after translation.
+// Remaining ties are broken by dimension values so that the results are deterministic.
+func (t *topN) sortRows(rows []topNRow, translate func(dimIndex int, value string) (string, error)) (err error) {
+	value := func(row topNRow, dimIndex int) string {
+		translated, translateErr := translate(dimIndex, row.dimValues[dimIndex])
+		if translateErr != nil && err == nil {
+			err = translateErr
+		}
+		return translated
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, s := range t.sorts {
+			var c int
+			if s.dimIndex < 0 {
+				c = compareMeasures(rows[i].measure, rows[j].measure)
+			} else {
+				c = compareDimValues(value(rows[i], s.dimIndex), value(rows[j], s.dimIndex))
+			}
+			if c != 0 {
+				return (c < 0) != s.desc
+			}
+		}
+		for dimIndex := range rows[i].dimValues {
+			if c := compareDimValues(rows[i].dimValues[dimIndex], rows[j].dimValues[dimIndex]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return
+}
+
+// limitNested keeps the top groups of nested results.
+func (t *topN) limitNested(results map[string]interface{}, numDims int,
+	translate func(dimIndex int, value string) (string, error)) (common.AQLQueryResult, error) {
+	rows := t.flatten(results, numDims)
+	if len(rows) <= t.limit {
+		return results, nil
+	}
+	if err := t.sortRows(rows, translate); err != nil {
+		return nil, err
+	}
+
+	limited := common.AQLQueryResult{}
+	for _, row := range rows[:t.limit] {
+		curr := map[string]interface{}(limited)
+		for _, dimValue := range row.dimValues[:numDims-1] {
+			child, exists := curr[dimValue]
+			if !exists {
+				child = make(map[string]interface{})
+				curr[dimValue] = child
+			}
+			curr = child.(map[string]interface{})
+		}
+		curr[row.dimValues[numDims-1]] = row.measure
+	}
+	return limited, nil
+}
+
+// orderColumnMajor orders the rows of translated column major results by the sort fields.
+func (t *topN) orderColumnMajor(results common.ColumnMajorResult) error {
+	numDims := len(results.Columns) - 1
+	rows := make([]topNRow, len(results.Columns[numDims]))
+	for i := range rows {
+		rows[i].dimValues = make([]string, numDims)
+		for dimIndex := 0; dimIndex < numDims; dimIndex++ {
+			rows[i].dimValues[dimIndex], _ = results.Columns[dimIndex][i].(string)
+		}
+		rows[i].measure = results.Columns[numDims][i]
+	}
+	if err := t.sortRows(rows, identityTranslation); err != nil {
+		return err
+	}
+	for i, row := range rows {
+		for dimIndex, dimValue := range row.dimValues {
+			results.Columns[dimIndex][i] = dimValue
+		}
+		results.Columns[numDims][i] = row.measure
+	}
+	return nil
+}
+
+func identityTranslation(dimIndex int, value string) (string, error) {
+	return value, nil
+}
+
+// compareMeasures compares numeric measures, nulls are the smallest.
+func compareMeasures(a, b interface{}) int {
+	fa, okA := a.(float64)
+	fb, okB := b.(float64)
+	switch {
+	case !okA && !okB:
+		return 0
+	case !okA:
+		return -1
+	case !okB:
+		return 1
+	case fa < fb:
+		return -1
+	case fa > fb:
+		return 1
+	}
+	return 0
+}
+
+// compareDimValues compares dimension values numerically if both are numbers, otherwise as strings.
+func compareDimValues(a, b string) int {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,108 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"net/http/httptest"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/broker/common"
+	memCom "github.com/uber/aresdb/memstore/common"
+	memComMocks "github.com/uber/aresdb/memstore/common/mocks"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	queryCom "github.com/uber/aresdb/query/common"
+)
+
+var _ = ginkgo.Describe("top n", func() {
+	table := &metaCom.Table{
+		Name: "trips",
+		Columns: []metaCom.Column{
+			{Name: "request_at", Type: "Uint32"},
+			{Name: "city", Type: "SmallEnum"},
+			{Name: "fare", Type: "Float32"},
+		},
+	}
+	tableSchema := memCom.NewTableSchema(table)
+	tableSchema.CreateEnumDict("city", []string{"sf", "nyc", "la", "boston"})
+
+	compile := func(limit int, sorts []queryCom.SortField, resultFormat string) *QueryContext {
+		mockTableSchemaReader := memComMocks.TableSchemaReader{}
+		mockTableSchemaReader.On("RLock").Return(nil)
+		mockTableSchemaReader.On("RUnlock").Return(nil)
+		mockTableSchemaReader.On("GetSchema", "trips").Return(tableSchema, nil)
+
+		qc := NewQueryContext(&queryCom.AQLQuery{
+			Table:        "trips",
+			Measures:     []queryCom.Measure{{Expr: "count(*)", Alias: "trips"}},
+			Dimensions:   []queryCom.Dimension{{Expr: "city"}},
+			Limit:        limit,
+			Sorts:        sorts,
+			ResultFormat: resultFormat,
+		}, false, httptest.NewRecorder())
+		qc.Compile(&mockTableSchemaReader)
+		return qc
+	}
+	// keyed by enum ids of sf, nyc, la and boston.
+	results := func() queryCom.AQLQueryResult {
+		return queryCom.AQLQueryResult{"0": 10.0, "1": 30.0, "2": 20.0, "3": 5.0}
+	}
+
+	ginkgo.It("should resolve sort fields", func() {
+		qc := compile(2, nil, "")
+		Ω(qc.Error).Should(BeNil())
+		Ω(*qc.TopN).Should(Equal(topN{limit: 2, sorts: []topNSort{{dimIndex: -1, desc: true}}}))
+
+		qc = compile(2, []queryCom.SortField{{Name: "city", Order: "ASC"}, {Name: "trips", Order: "DESC"}}, "")
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.TopN.sorts).Should(Equal([]topNSort{{dimIndex: 0}, {dimIndex: -1, desc: true}}))
+
+		qc = compile(0, nil, "")
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.TopN).Should(BeNil())
+
+		qc = compile(2, []queryCom.SortField{{Name: "fare"}}, "")
+		Ω(qc.Error.Error()).Should(ContainSubstring("sort field fare is neither the measure nor a dimension"))
+		qc = compile(2, []queryCom.SortField{{Name: "city", Order: "up"}}, "")
+		Ω(qc.Error.Error()).Should(ContainSubstring("unknown sort order"))
+	})
+
+	ginkgo.It("should keep top groups of row major results", func() {
+		qc := compile(2, nil, "")
+		plan := AggQueryPlan{aggType: common.Count, qc: qc}
+		w := httptest.NewRecorder()
+		Ω(plan.postProcess(context.TODO(), results(), nil, w)).Should(BeNil())
+		Ω(w.Body.String()).Should(MatchJSON(`{"nyc": 30, "la": 20}`))
+
+		// sorted by translated enum values.
+		qc = compile(2, []queryCom.SortField{{Name: "city"}}, "")
+		plan = AggQueryPlan{aggType: common.Count, qc: qc}
+		w = httptest.NewRecorder()
+		Ω(plan.postProcess(context.TODO(), results(), nil, w)).Should(BeNil())
+		Ω(w.Body.String()).Should(MatchJSON(`{"boston": 5, "la": 20}`))
+	})
+
+	ginkgo.It("should order top groups of column major results", func() {
+		qc := compile(3, []queryCom.SortField{{Name: "count(*)", Order: "asc"}}, queryCom.ResultFormatColumnMajor)
+		plan := AggQueryPlan{aggType: common.Count, qc: qc}
+		w := httptest.NewRecorder()
+		Ω(plan.postProcess(context.TODO(), results(), nil, w)).Should(BeNil())
+		Ω(w.Body.String()).Should(MatchJSON(`{
+			"headers": ["city", "count(*)"],
+			"columns": [["boston", "sf", "la"], [5, 10, 20]]
+		}`))
+	})
+})
@@ -22,16 +22,17 @@ import (
 
 // CalculateShardAssignment maps shards to hosts
 func CalculateShardAssignment(topo topology.Topology) (as map[topology.Host][]uint32, err error) {
+	return CalculateShardAssignmentWithPreference(topo, nil)
+}
+
+// CalculateShardAssignmentWithPreference maps shards to hosts, where each shard is routed to
+// preferred hosts only unless none of its routable hosts is preferred. Nil preferred means all
+// hosts are preferred. Hosts without shards assigned are not included.
+func CalculateShardAssignmentWithPreference(topo topology.Topology, preferred func(topology.Host) bool) (as map[topology.Host][]uint32, err error) {
 	m := topo.Get()
-	hosts := m.Hosts()
 	shardIDs := m.ShardSet().AllIDs()
 
-	// initialize host map
 	as = make(map[topology.Host][]uint32)
-	for _, host := range hosts {
-		as[host] = []uint32{}
-	}
-
 	for _, shardID := range shardIDs {
 		var shardHosts []topology.Host
 		// get routable hosts for current shard
@@ -40,6 +41,17 @@ func CalculateShardAssignment(topo topology.Topology) (as map[topology.Host][]ui
 			err = utils.StackError(err, fmt.Sprintf("failed to route shard %d", shardID))
 			return
 		}
+		if preferred != nil {
+			var preferredHosts []topology.Host
+			for _, shardHost := range shardHosts {
+				if preferred(shardHost) {
+					preferredHosts = append(preferredHosts, shardHost)
+				}
+			}
+			if len(preferredHosts) > 0 {
+				shardHosts = preferredHosts
+			}
+		}
 		// pick host with lowest load to route current shard
 		var pick topology.Host
 		minLoad := len(shardIDs) + 1
@@ -60,6 +60,43 @@ var _ = ginkgo.Describe("broker util", func() {
 		Ω(res[mockHost3]).Should(HaveLen(2))
 	})
 
+	ginkgo.It("should prefer hosts", func() {
+		mockTopo := topoMock.Topology{}
+		mockMap := topoMock.Map{}
+		mockShardSet := shardMock.ShardSet{}
+		mockTopo.On("Get").Return(&mockMap)
+		mockMap.On("ShardSet").Return(&mockShardSet)
+		mockShardSet.On("AllIDs").Return([]uint32{0, 1, 2, 3})
+		host1 := topology.NewHost("host1", "host1:9374")
+		host2 := topology.NewHost("host2", "host2:9374")
+		host3 := topology.NewHost("host3", "host3:9374")
+		mockMap.On("RouteShard", uint32(0)).Return([]topology.Host{host1, host2}, nil)
+		mockMap.On("RouteShard", uint32(1)).Return([]topology.Host{host1, host3}, nil)
+		mockMap.On("RouteShard", uint32(2)).Return([]topology.Host{host2, host3}, nil)
+		// shard 3 has no preferred hosts routable, shard 2 goes to the first of equally loaded hosts.
+		mockMap.On("RouteShard", uint32(3)).Return([]topology.Host{host1}, nil)
+
+		res, err := CalculateShardAssignmentWithPreference(&mockTopo, func(host topology.Host) bool {
+			return host.ID() != "host1"
+		})
+		Ω(err).Should(BeNil())
+		Ω(res).Should(Equal(map[topology.Host][]uint32{
+			host1: {3},
+			host2: {0, 2},
+			host3: {1},
+		}))
+
+		// hosts without shards are not included.
+		res, err = CalculateShardAssignmentWithPreference(&mockTopo, func(host topology.Host) bool {
+			return host.ID() == "host1"
+		})
+		Ω(err).Should(BeNil())
+		Ω(res).Should(Equal(map[topology.Host][]uint32{
+			host1: {0, 1, 3},
+			host2: {2},
+		}))
+	})
+
 	ginkgo.It("should work no available host", func() {
 		mockTopo := topoMock.Topology{}
 		mockMap := topoMock.Map{}
@@ -0,0 +1,372 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	apiCom "github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/broker/common"
+	"github.com/uber/aresdb/broker/config"
+	"github.com/uber/aresdb/broker/util"
+	"github.com/uber/aresdb/cluster/topology"
+	memCom "github.com/uber/aresdb/memstore/common"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/query/expr"
+	"github.com/uber/aresdb/query/sql"
+	"github.com/uber/aresdb/utils"
+)
+
+// defaultSimulationScanDays is the number of days scanned by simulated queries without time
+// filter on tables without record retention.
+const defaultSimulationScanDays = 365
+
+// SimulationRequest replays a recorded workload against the current schemas and query config
+// (baseline) and against a candidate with modified schemas or query config.
+// swagger:parameters simulateWorkload
+type SimulationRequest struct {
+	// in: body
+	Body struct {
+		// slow query log entries of the workload, recorded with threshold 0 to capture all queries.
+		Workload []SlowQueryLogEntry `json:"workload"`
+		// tables replacing the current schemas of tables with the same names in the candidate.
+		Tables []metaCom.Table `json:"tables,omitempty"`
+		// query config of the candidate, current query config if not set.
+		Query *config.QueryConfig `json:"query,omitempty"`
+	} `body:""`
+}
+
+// SimulatedPlan is the plan predicted for a query without executing it.
+type SimulatedPlan struct {
+	// compile or planning error, the query would fail.
+	Error          string `json:"error,omitempty"`
+	NonAggregation bool   `json:"nonAggregation"`
+	// number of shards and datanode hosts the query fans out to.
+	Shards int `json:"shards"`
+	Hosts  int `json:"hosts"`
+	// number of time range chunks, 1 if not split.
+	TimeRangeChunks int `json:"timeRangeChunks"`
+	// number of requests sent to datanodes.
+	DatanodeRequests int `json:"datanodeRequests"`
+	// days of data scanned on each shard.
+	ScanDays float64 `json:"scanDays"`
+	// relative cost in shard days scanned, counting each joined table and sub query.
+	Cost float64 `json:"cost"`
+}
+
+// SimulatedQuery compares the baseline and candidate plans of a recorded query.
+type SimulatedQuery struct {
+	RequestID string        `json:"requestID"`
+	SQL       string        `json:"sql,omitempty"`
+	Baseline  SimulatedPlan `json:"baseline"`
+	Candidate SimulatedPlan `json:"candidate"`
+	// candidate minus baseline.
+	DatanodeRequestsDelta int     `json:"datanodeRequestsDelta"`
+	CostDelta             float64 `json:"costDelta"`
+}
+
+// SimulationTotals sums up plans of the workload.
+type SimulationTotals struct {
+	// number of queries failing to compile or plan.
+	Failed           int     `json:"failed"`
+	DatanodeRequests int     `json:"datanodeRequests"`
+	Cost             float64 `json:"cost"`
+}
+
+// SimulationReport is the result of replaying a workload.
+type SimulationReport struct {
+	NumQueries int              `json:"numQueries"`
+	Baseline   SimulationTotals `json:"baseline"`
+	Candidate  SimulationTotals `json:"candidate"`
+	// candidate minus baseline.
+	DatanodeRequestsDelta int     `json:"datanodeRequestsDelta"`
+	CostDelta             float64 `json:"costDelta"`
+	// queries whose plans differ between baseline and candidate.
+	Changed []SimulatedQuery `json:"changed"`
+}
+
+// SimulationHandler replays recorded workloads through the compiler and planner only, so that
+// schema and pruning config changes can be evaluated before they are applied. Queries are never
+// sent to datanodes.
+type SimulationHandler struct {
+	tableSchemaReader memCom.TableSchemaReader
+	topo              topology.Topology
+	cfg               config.QueryConfig
+}
+
+// NewSimulationHandler creates a new SimulationHandler.
+func NewSimulationHandler(tsr memCom.TableSchemaReader, topo topology.Topology, cfg config.QueryConfig) *SimulationHandler {
+	return &SimulationHandler{
+		tableSchemaReader: tsr,
+		topo:              topo,
+		cfg:               cfg,
+	}
+}
+
+// Register registers http handlers.
+func (handler *SimulationHandler) Register(router *mux.Router, wrappers ...utils.HTTPHandlerWrapper) {
+	router.HandleFunc("/simulate", utils.ApplyHTTPWrappers(handler.HandleSimulate, wrappers)).Methods(http.MethodPost)
+}
+
+// HandleSimulate swagger:route POST /query/simulate simulateWorkload
+// replays a recorded workload against a candidate schema or query config
+// and reports predicted shard fan-out and cost deltas.
+func (handler *SimulationHandler) HandleSimulate(w http.ResponseWriter, r *http.Request) {
+	var request SimulationRequest
+	if err := apiCom.ReadRequest(r, &request); err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	candidateCfg := handler.cfg
+	if request.Body.Query != nil {
+		candidateCfg = *request.Body.Query
+	}
+	report := handler.Simulate(r.Context(), request.Body.Workload,
+		newSimulationSchemaReader(handler.tableSchemaReader, request.Body.Tables), candidateCfg)
+	apiCom.RespondWithJSONObject(w, report)
+}
+
+// Simulate replays the workload against the current schemas and query config and against the
+// candidate ones.
+func (handler *SimulationHandler) Simulate(ctx context.Context, workload []SlowQueryLogEntry,
+	candidateSchemas memCom.TableSchemaReader, candidateCfg config.QueryConfig) SimulationReport {
+	principal := utils.PrincipalFromContext(ctx)
+	report := SimulationReport{Changed: []SimulatedQuery{}}
+	for _, entry := range workload {
+		query := SimulatedQuery{
+			RequestID: entry.RequestID,
+			SQL:       entry.SQL,
+			Baseline:  handler.simulateEntry(entry, principal, handler.tableSchemaReader, handler.cfg),
+			Candidate: handler.simulateEntry(entry, principal, candidateSchemas, candidateCfg),
+		}
+		query.DatanodeRequestsDelta = query.Candidate.DatanodeRequests - query.Baseline.DatanodeRequests
+		query.CostDelta = query.Candidate.Cost - query.Baseline.Cost
+
+		report.NumQueries++
+		report.Baseline.add(query.Baseline)
+		report.Candidate.add(query.Candidate)
+		if query.Baseline != query.Candidate {
+			report.Changed = append(report.Changed, query)
+		}
+	}
+	report.DatanodeRequestsDelta = report.Candidate.DatanodeRequests - report.Baseline.DatanodeRequests
+	report.CostDelta = report.Candidate.Cost - report.Baseline.Cost
+	return report
+}
+
+func (t *SimulationTotals) add(plan SimulatedPlan) {
+	if plan.Error != "" {
+		t.Failed++
+		return
+	}
+	t.DatanodeRequests += plan.DatanodeRequests
+	t.Cost += plan.Cost
+}
+
+// simulateEntry plans the recorded query, sql is parsed again if recorded, otherwise the
+// rewritten aql is compiled again. Relative time filters are resolved as of the time the query
+// was recorded.
+func (handler *SimulationHandler) simulateEntry(entry SlowQueryLogEntry, principal *utils.Principal,
+	tsr memCom.TableSchemaReader, cfg config.QueryConfig) (plan SimulatedPlan) {
+	var aqls []*queryCom.AQLQuery
+	var err error
+	switch {
+	case entry.SQL != "":
+		aqls, err = sql.ParseUnionAll(entry.SQL, utils.GetLogger())
+		if err == nil && aqls == nil {
+			var aql *queryCom.AQLQuery
+			aql, err = sql.Parse(entry.SQL, utils.GetLogger())
+			aqls = []*queryCom.AQLQuery{aql}
+		}
+	case entry.AQL != nil:
+		// compiling mutates the query, compile a copy.
+		var aql queryCom.AQLQuery
+		var data []byte
+		if data, err = json.Marshal(entry.AQL); err == nil {
+			err = json.Unmarshal(data, &aql)
+		}
+		aqls = []*queryCom.AQLQuery{&aql}
+	default:
+		err = utils.StackError(nil, "no query recorded")
+	}
+	if err != nil {
+		plan.Error = err.Error()
+		return
+	}
+
+	for _, aql := range aqls {
+		if aql.Now == 0 && !entry.Time.IsZero() {
+			aql.Now = entry.Time.Unix()
+		}
+		now := utils.Now()
+		if aql.Now > 0 {
+			now = time.Unix(aql.Now, 0)
+		}
+		subPlan, err := simulatePlan(aql, principal, tsr, handler.topo, cfg, now)
+		if err != nil {
+			return SimulatedPlan{Error: err.Error()}
+		}
+		plan.NonAggregation = plan.NonAggregation || subPlan.NonAggregation
+		if subPlan.Shards > plan.Shards {
+			plan.Shards = subPlan.Shards
+		}
+		if subPlan.Hosts > plan.Hosts {
+			plan.Hosts = subPlan.Hosts
+		}
+		plan.TimeRangeChunks += subPlan.TimeRangeChunks
+		plan.DatanodeRequests += subPlan.DatanodeRequests
+		plan.ScanDays += subPlan.ScanDays
+		plan.Cost += subPlan.Cost
+	}
+	return
+}
+
+// simulatePlan compiles the query and predicts its plan the same way the query executor does.
+func simulatePlan(aql *queryCom.AQLQuery, principal *utils.Principal, tsr memCom.TableSchemaReader,
+	topo topology.Topology, cfg config.QueryConfig, now time.Time) (plan SimulatedPlan, err error) {
+	qc := NewQueryContext(aql, false, nil)
+	qc.Principal = principal
+	qc.Compile(tsr)
+	if qc.Error != nil {
+		return plan, qc.Error
+	}
+
+	qc.PreferredHosts = newReplicaRouter(cfg.AnalyticsReplicas).preferredHosts(qc)
+	assignments, err := util.CalculateShardAssignmentWithPreference(topo, qc.PreferredHosts)
+	if err != nil {
+		return plan, err
+	}
+	plan.NonAggregation = qc.IsNonAggregationQuery
+	plan.Hosts = len(assignments)
+	for _, shards := range assignments {
+		plan.Shards += len(shards)
+	}
+
+	plan.TimeRangeChunks = 1
+	if chunks := newTimeRangeSplitter(cfg.TimeRangeSplit).split(qc, now); chunks != nil {
+		plan.TimeRangeChunks = len(chunks)
+	}
+	// avg is executed as sum and count sub queries.
+	subQueries := 1
+	if !qc.IsNonAggregationQuery {
+		if measure, ok := qc.AQLQuery.Measures[0].ExprParsed.(*expr.Call); ok && common.CallNameToAggType[measure.Name] == common.Avg {
+			subQueries = 2
+		}
+	}
+	plan.DatanodeRequests = plan.Hosts * plan.TimeRangeChunks * subQueries
+
+	plan.ScanDays = simulatedScanDays(qc, now)
+	plan.Cost = float64(plan.Shards*subQueries*len(qc.Tables)) * plan.ScanDays
+	return
+}
+
+// simulatedScanDays returns the days of data the query scans on each shard. Dimension tables
+// count as one day, queries without time filter scan all records retained.
+func simulatedScanDays(qc *QueryContext, now time.Time) float64 {
+	table := qc.Tables[0].Schema
+	if !table.IsFactTable {
+		return 1
+	}
+
+	retentionDays := float64(table.Config.RecordRetentionInDays)
+	if retentionDays <= 0 {
+		retentionDays = defaultSimulationScanDays
+	}
+	if qc.AQLQuery.TimeFilter.From == "" {
+		return retentionDays
+	}
+
+	loc := time.UTC
+	if qc.AQLQuery.Timezone != "" {
+		if parsed, err := queryCom.ParseTimezone(qc.AQLQuery.Timezone); err == nil {
+			loc = parsed
+		}
+	}
+	from, to, err := queryCom.ParseTimeFilter(qc.AQLQuery.TimeFilter, loc, now)
+	if err != nil || from == nil || from.Time.Unix() <= maxNonTimestampTimeFilterNumber {
+		return retentionDays
+	}
+	end := now
+	if to != nil {
+		end = to.Time
+	}
+	days := end.Sub(from.Time).Hours() / 24
+	if days > retentionDays {
+		days = retentionDays
+	}
+	if days < 0 {
+		days = 0
+	}
+	return days
+}
+
+// simulationSchemaReader reads schemas of the candidate, which are the given tables or the
+// current schemas for other tables. Enum dicts of replaced tables are kept for columns with the
+// same names.
+type simulationSchemaReader struct {
+	memCom.TableSchemaReader
+	tables map[string]*metaCom.Table
+}
+
+func newSimulationSchemaReader(tsr memCom.TableSchemaReader, tables []metaCom.Table) memCom.TableSchemaReader {
+	if len(tables) == 0 {
+		return tsr
+	}
+	reader := &simulationSchemaReader{
+		TableSchemaReader: tsr,
+		tables:            make(map[string]*metaCom.Table, len(tables)),
+	}
+	for i := range tables {
+		reader.tables[tables[i].Name] = &tables[i]
+	}
+	return reader
+}
+
+// GetSchema returns the candidate schema of the table, caller must hold the read lock.
+func (r *simulationSchemaReader) GetSchema(table string) (*memCom.TableSchema, error) {
+	candidate, ok := r.tables[table]
+	if !ok {
+		return r.TableSchemaReader.GetSchema(table)
+	}
+
+	schema := memCom.NewTableSchema(candidate)
+	if current, err := r.TableSchemaReader.GetSchema(table); err == nil {
+		current = current.Snapshot()
+		for column, enumDict := range current.EnumDicts {
+			if _, ok := schema.ColumnIDs[column]; ok {
+				schema.EnumDicts[column] = enumDict
+			}
+		}
+	}
+	return schema, nil
+}
+
+// GetSchemas returns candidate schemas of all tables, caller must hold the read lock.
+func (r *simulationSchemaReader) GetSchemas() map[string]*memCom.TableSchema {
+	schemas := make(map[string]*memCom.TableSchema)
+	for table := range r.TableSchemaReader.GetSchemas() {
+		schemas[table], _ = r.GetSchema(table)
+	}
+	for table := range r.tables {
+		schemas[table], _ = r.GetSchema(table)
+	}
+	return schemas
+}
@@ -0,0 +1,122 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/broker/config"
+	shardMock "github.com/uber/aresdb/cluster/shard/mocks"
+	"github.com/uber/aresdb/cluster/topology"
+	topoMock "github.com/uber/aresdb/cluster/topology/mocks"
+	"github.com/uber/aresdb/memstore/common/testutil"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	queryCom "github.com/uber/aresdb/query/common"
+)
+
+var _ = ginkgo.Describe("workload simulation", func() {
+	table := metaCom.Table{
+		Name:        "trips",
+		IsFactTable: true,
+		Columns: []metaCom.Column{
+			{Name: "request_at", Type: metaCom.Uint32},
+			{Name: "fare", Type: metaCom.Float32},
+		},
+		PrimaryKeyColumns: []int{0},
+	}
+
+	var handler *SimulationHandler
+	ginkgo.BeforeEach(func() {
+		mockTopo := &topoMock.Topology{}
+		mockMap := &topoMock.Map{}
+		mockShardSet := &shardMock.ShardSet{}
+		mockTopo.On("Get").Return(mockMap)
+		mockMap.On("ShardSet").Return(mockShardSet)
+		mockShardSet.On("AllIDs").Return([]uint32{0, 1, 2, 3})
+		host1 := topology.NewHost("host1", "host1:9374")
+		host2 := topology.NewHost("host2", "host2:9374")
+		mockMap.On("RouteShard", uint32(0)).Return([]topology.Host{host1}, nil)
+		mockMap.On("RouteShard", uint32(1)).Return([]topology.Host{host1}, nil)
+		mockMap.On("RouteShard", uint32(2)).Return([]topology.Host{host2}, nil)
+		mockMap.On("RouteShard", uint32(3)).Return([]topology.Host{host2}, nil)
+
+		tsr := testutil.NewTableSchemaReader(testutil.MustNewTableSchema(&table, nil))
+		handler = NewSimulationHandler(tsr, mockTopo, config.QueryConfig{})
+	})
+
+	ginkgo.It("reports fan-out and cost deltas of a candidate", func() {
+		recorded := time.Unix(1551484800, 0)
+		workload := []SlowQueryLogEntry{
+			{
+				RequestID: "1",
+				Time:      recorded,
+				AQL: &queryCom.AQLQuery{
+					Table:    "trips",
+					Measures: []queryCom.Measure{{Expr: "avg(fare)"}},
+					// 60 days.
+					TimeFilter: queryCom.TimeFilter{Column: "request_at", From: "1546300800", To: "1551484800"},
+				},
+			},
+			{
+				RequestID: "2",
+				Time:      recorded,
+				SQL:       "SELECT fare, count(*) FROM trips GROUP BY fare",
+			},
+			{
+				RequestID: "3",
+				Time:      recorded,
+				SQL:       "SELECT count(*) FROM unknown_table",
+			},
+		}
+
+		candidateTable := table
+		candidateTable.Config.RecordRetentionInDays = 30
+		candidateCfg := config.QueryConfig{
+			TimeRangeSplit: config.TimeRangeSplitConfig{Enable: true, MinDays: 31, ChunkDays: 7},
+		}
+		report := handler.Simulate(context.Background(), workload,
+			newSimulationSchemaReader(handler.tableSchemaReader, []metaCom.Table{candidateTable}), candidateCfg)
+
+		Ω(report.NumQueries).Should(Equal(3))
+		Ω(report.Baseline.Failed).Should(Equal(1))
+		Ω(report.Candidate.Failed).Should(Equal(1))
+		Ω(report.Changed).Should(HaveLen(2))
+
+		// avg is split into sum and count, which are split into 9 weekly chunks in candidate.
+		avg := report.Changed[0]
+		Ω(avg.Baseline.Shards).Should(Equal(4))
+		Ω(avg.Baseline.Hosts).Should(Equal(2))
+		Ω(avg.Baseline.TimeRangeChunks).Should(Equal(1))
+		Ω(avg.Baseline.DatanodeRequests).Should(Equal(4))
+		Ω(avg.Candidate.TimeRangeChunks).Should(Equal(9))
+		Ω(avg.Candidate.DatanodeRequests).Should(Equal(36))
+		Ω(avg.DatanodeRequestsDelta).Should(Equal(32))
+		// scanned days are bounded by the retention of the candidate.
+		Ω(avg.Baseline.ScanDays).Should(BeNumerically("==", 60))
+		Ω(avg.Candidate.ScanDays).Should(BeNumerically("==", 30))
+		Ω(avg.Baseline.Cost).Should(BeNumerically("==", 4*2*60))
+
+		count := report.Changed[1]
+		Ω(count.Baseline.ScanDays).Should(BeNumerically("==", defaultSimulationScanDays))
+		Ω(count.Candidate.ScanDays).Should(BeNumerically("==", 30))
+		Ω(count.CostDelta).Should(BeNumerically("==", 4*(30-defaultSimulationScanDays)))
+
+		Ω(report.DatanodeRequestsDelta).Should(Equal(32))
+		Ω(report.CostDelta).Should(BeNumerically("==", avg.CostDelta+count.CostDelta))
+	})
+})
@@ -0,0 +1,55 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"github.com/Shopify/sarama"
+	"github.com/uber/aresdb/utils"
+)
+
+// kafkaSink publishes change events to kafka topics.
+type kafkaSink struct {
+	producer sarama.SyncProducer
+}
+
+func newKafkaSink(brokers []string) (*kafkaSink, error) {
+	if len(brokers) == 0 {
+		return nil, utils.StackError(nil, "kafka brokers are required for change data capture")
+	}
+	cfg := sarama.NewConfig()
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	producer, err := sarama.NewSyncProducer(brokers, cfg)
+	if err != nil {
+		return nil, utils.StackError(err, "failed to create kafka producer for change data capture")
+	}
+	return &kafkaSink{producer: producer}, nil
+}
+
+func (s *kafkaSink) Write(topic string, messages []Message) error {
+	producerMessages := make([]*sarama.ProducerMessage, len(messages))
+	for i, message := range messages {
+		producerMessages[i] = &sarama.ProducerMessage{
+			Topic: topic,
+			Key:   sarama.ByteEncoder(message.Key),
+			Value: sarama.ByteEncoder(message.Value),
+		}
+	}
+	return s.producer.SendMessages(producerMessages)
+}
+
+func (s *kafkaSink) Close() error {
+	return s.producer.Close()
+}
@@ -0,0 +1,323 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber/aresdb/common"
+	memCom "github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	// FormatJSON is the json envelope format of change events.
+	FormatJSON = "json"
+	// EnvelopeVersion is the version of the change event envelope, bumped on incompatible changes.
+	EnvelopeVersion = 1
+	// OpUpsert is the operation of change events for upserted rows.
+	OpUpsert = "upsert"
+
+	tablePlaceholder      = "{table}"
+	defaultBufferSize     = 1000
+	maxPublishRetries     = 3
+	publishRetryBackoff   = time.Second
+	defaultUpdateModeName = "overwrite_not_null"
+)
+
+var updateModeNames = map[memCom.ColumnUpdateMode]string{
+	memCom.UpdateOverwriteNotNull: defaultUpdateModeName,
+	memCom.UpdateForceOverwrite:   "force_overwrite",
+	memCom.UpdateWithAddition:     "add",
+	memCom.UpdateWithMin:          "min",
+	memCom.UpdateWithMax:          "max",
+}
+
+// Envelope is the stable format of a change event, one event is published per upserted row.
+type Envelope struct {
+	Version int    `json:"version"`
+	Table   string `json:"table"`
+	Shard   int    `json:"shard"`
+	Op      string `json:"op"`
+	// redo log file and offset of the upsert batch, together with row they identify the event.
+	RedoLogFile int64  `json:"redoLogFile"`
+	Offset      uint32 `json:"offset"`
+	Row         int    `json:"row"`
+	// commit time in milliseconds since epoch.
+	CommitTime int64 `json:"commitTime"`
+	// values of primary key columns.
+	Key map[string]interface{} `json:"key"`
+	// values of upserted columns, enum values are translated to enum cases. A null value of a
+	// column with default update mode means the column is unchanged.
+	Columns map[string]interface{} `json:"columns"`
+	// update modes of columns not using the default overwrite_not_null mode.
+	UpdateModes map[string]string `json:"updateModes,omitempty"`
+}
+
+// Message is a change event to be written to sink.
+type Message struct {
+	Key   []byte
+	Value []byte
+}
+
+// Sink is where change events are written to.
+type Sink interface {
+	Write(topic string, messages []Message) error
+	Close() error
+}
+
+type change struct {
+	schema      *memCom.TableSchema
+	shardID     int
+	upsertBatch *memCom.UpsertBatch
+	redoLogFile int64
+	offset      uint32
+	commitTime  time.Time
+}
+
+// Publisher publishes upserts committed to table shards as change events asynchronously.
+// Upsert batches are buffered and published in commit order, ingestion blocks when the buffer
+// is full. Events failed to publish after retries are dropped and reported.
+type Publisher struct {
+	// protects closed from Publish calls racing with Close.
+	sync.RWMutex
+	closed bool
+
+	topic   string
+	tables  map[string]bool
+	sink    Sink
+	changes chan change
+	done    chan struct{}
+}
+
+// NewPublisher creates a Publisher writing to the kafka sink specified in config. It returns nil
+// if change data capture is not enabled.
+func NewPublisher(cfg common.CDCConfig) (*Publisher, error) {
+	if !cfg.Enable {
+		return nil, nil
+	}
+	sink, err := newKafkaSink(cfg.KafkaBrokers)
+	if err != nil {
+		return nil, err
+	}
+	publisher, err := NewPublisherWithSink(cfg, sink)
+	if err != nil {
+		sink.Close()
+		return nil, err
+	}
+	return publisher, nil
+}
+
+// NewPublisherWithSink creates a Publisher writing to given sink.
+func NewPublisherWithSink(cfg common.CDCConfig, sink Sink) (*Publisher, error) {
+	if cfg.Format != "" && cfg.Format != FormatJSON {
+		return nil, utils.StackError(nil, "unsupported change event format %s", cfg.Format)
+	}
+	if cfg.Topic == "" {
+		return nil, utils.StackError(nil, "change event topic is required")
+	}
+	bufferSize := cfg.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+	var tables map[string]bool
+	if len(cfg.Tables) > 0 {
+		tables = make(map[string]bool)
+		for _, table := range cfg.Tables {
+			tables[table] = true
+		}
+	}
+	p := &Publisher{
+		topic:   cfg.Topic,
+		tables:  tables,
+		sink:    sink,
+		changes: make(chan change, bufferSize),
+		done:    make(chan struct{}),
+	}
+	go p.run()
+	return p, nil
+}
+
+// Publish queues the upsert batch to be published as change events.
+func (p *Publisher) Publish(schema *memCom.TableSchema, shardID int, upsertBatch *memCom.UpsertBatch, redoLogFile int64, offset uint32) {
+	if p.tables != nil && !p.tables[schema.Schema.Name] {
+		return
+	}
+	p.RLock()
+	defer p.RUnlock()
+	if p.closed {
+		return
+	}
+	p.changes <- change{
+		schema:      schema,
+		shardID:     shardID,
+		upsertBatch: upsertBatch,
+		redoLogFile: redoLogFile,
+		offset:      offset,
+		commitTime:  utils.Now(),
+	}
+}
+
+// Close stops the publisher after publishing buffered changes and closes the sink, changes
+// published after Close are ignored.
+func (p *Publisher) Close() error {
+	p.Lock()
+	p.closed = true
+	close(p.changes)
+	p.Unlock()
+	<-p.done
+	return p.sink.Close()
+}
+
+func (p *Publisher) run() {
+	defer close(p.done)
+	for c := range p.changes {
+		table := c.schema.Schema.Name
+		messages, err := NewMessages(c.schema, c.shardID, c.upsertBatch, c.redoLogFile, c.offset, c.commitTime)
+		if err == nil {
+			topic := strings.Replace(p.topic, tablePlaceholder, table, -1)
+			for i := 0; ; i++ {
+				if err = p.sink.Write(topic, messages); err == nil || i >= maxPublishRetries {
+					break
+				}
+				time.Sleep(publishRetryBackoff)
+			}
+		}
+		if err != nil {
+			utils.GetReporter(table, c.shardID).GetCounter(utils.CDCPublishFailure).Inc(1)
+			utils.GetLogger().With("table", table, "shard", c.shardID, "redoLogFile", c.redoLogFile,
+				"offset", c.offset, "error", err.Error()).Error("Failed to publish change events")
+			continue
+		}
+		utils.GetReporter(table, c.shardID).GetCounter(utils.CDCEventsPublished).Inc(int64(len(messages)))
+	}
+}
+
+// NewMessages converts rows of the upsert batch to change event messages keyed by table and
+// primary key values.
+func NewMessages(schema *memCom.TableSchema, shardID int, upsertBatch *memCom.UpsertBatch,
+	redoLogFile int64, offset uint32, commitTime time.Time) ([]Message, error) {
+	if upsertBatch.NumRows == 0 {
+		return nil, nil
+	}
+	rows, err := upsertBatch.ReadData(0, upsertBatch.NumRows)
+	if err != nil {
+		return nil, err
+	}
+
+	// names of upserted columns, empty for deleted columns.
+	columnNames := make([]string, upsertBatch.NumColumns)
+	enumCases := make([][]string, upsertBatch.NumColumns)
+	isPrimaryKey := make([]bool, upsertBatch.NumColumns)
+	schema.RLock()
+	table := schema.Schema.Name
+	for col := range columnNames {
+		columnID, err := upsertBatch.GetColumnID(col)
+		if err != nil {
+			schema.RUnlock()
+			return nil, err
+		}
+		if columnID >= len(schema.Schema.Columns) {
+			schema.RUnlock()
+			return nil, utils.StackError(nil, "Column id %d out of range %d", columnID, len(schema.Schema.Columns))
+		}
+		column := &schema.Schema.Columns[columnID]
+		if column.Deleted {
+			continue
+		}
+		columnNames[col] = column.Name
+		if column.IsEnumColumn() {
+			enumCases[col] = schema.EnumDicts[column.Name].ReverseDict
+		}
+		isPrimaryKey[col] = utils.IndexOfInt(schema.Schema.PrimaryKeyColumns, columnID) >= 0
+	}
+	schema.RUnlock()
+
+	var updateModes map[string]string
+	for col, name := range columnNames {
+		if name == "" {
+			continue
+		}
+		if mode := upsertBatch.GetColumnUpdateMode(col); mode != memCom.UpdateOverwriteNotNull {
+			if updateModes == nil {
+				updateModes = make(map[string]string)
+			}
+			updateModes[name] = updateModeNames[mode]
+		}
+	}
+
+	messages := make([]Message, len(rows))
+	for row, values := range rows {
+		envelope := Envelope{
+			Version:     EnvelopeVersion,
+			Table:       table,
+			Shard:       shardID,
+			Op:          OpUpsert,
+			RedoLogFile: redoLogFile,
+			Offset:      offset,
+			Row:         row,
+			CommitTime:  commitTime.UnixNano() / int64(time.Millisecond),
+			Key:         make(map[string]interface{}),
+			Columns:     make(map[string]interface{}, len(columnNames)),
+			UpdateModes: updateModes,
+		}
+		for col, name := range columnNames {
+			if name == "" {
+				continue
+			}
+			value := values[col]
+			if enumCases[col] != nil && value != nil {
+				value = translateEnum(value, enumCases[col])
+			}
+			envelope.Columns[name] = value
+			if isPrimaryKey[col] {
+				envelope.Key[name] = value
+			}
+		}
+
+		var key, value []byte
+		if key, err = json.Marshal(struct {
+			Table string                 `json:"table"`
+			Key   map[string]interface{} `json:"key"`
+		}{table, envelope.Key}); err != nil {
+			return nil, utils.StackError(err, "failed to marshal change event key")
+		}
+		if value, err = json.Marshal(envelope); err != nil {
+			return nil, utils.StackError(err, "failed to marshal change event")
+		}
+		messages[row] = Message{Key: key, Value: value}
+	}
+	return messages, nil
+}
+
+// translateEnum translates enum id to enum case, ids without known cases are kept as is.
+func translateEnum(value interface{}, enumCases []string) interface{} {
+	var id int
+	switch v := value.(type) {
+	case uint8:
+		id = int(v)
+	case uint16:
+		id = int(v)
+	default:
+		return value
+	}
+	if id < len(enumCases) {
+		return enumCases[id]
+	}
+	return value
+}
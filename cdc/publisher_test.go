@@ -0,0 +1,152 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/common"
+	memCom "github.com/uber/aresdb/memstore/common"
+	metaCom "github.com/uber/aresdb/metastore/common"
+)
+
+type topicMessages struct {
+	topic    string
+	messages []Message
+}
+
+// memSink records written messages, and fails writes while failures is positive.
+type memSink struct {
+	sync.Mutex
+	writes   []topicMessages
+	failures int
+	closed   bool
+}
+
+func (s *memSink) Write(topic string, messages []Message) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("kafka unavailable")
+	}
+	s.writes = append(s.writes, topicMessages{topic, messages})
+	return nil
+}
+
+func (s *memSink) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	s.closed = true
+	return nil
+}
+
+var _ = Describe("change data capture", func() {
+	schema := memCom.NewTableSchema(&metaCom.Table{
+		Name:              "trips",
+		PrimaryKeyColumns: []int{0},
+		Columns: []metaCom.Column{
+			{Name: "id", Type: metaCom.Uint32},
+			{Name: "city", Type: metaCom.SmallEnum},
+			{Name: "fare", Type: metaCom.Float32},
+			{Name: "old", Type: metaCom.Int32, Deleted: true},
+		},
+	})
+	schema.CreateEnumDict("city", []string{"sf", "nyc"})
+
+	newUpsertBatch := func() *memCom.UpsertBatch {
+		builder := memCom.NewUpsertBatchBuilder()
+		Ω(builder.AddColumn(0, memCom.Uint32)).Should(BeNil())
+		Ω(builder.AddColumnWithUpdateMode(1, memCom.SmallEnum, memCom.UpdateForceOverwrite)).Should(BeNil())
+		Ω(builder.AddColumnWithUpdateMode(2, memCom.Float32, memCom.UpdateWithAddition)).Should(BeNil())
+		Ω(builder.AddColumn(3, memCom.Int32)).Should(BeNil())
+		builder.AddRow()
+		Ω(builder.SetValue(0, 0, 1)).Should(BeNil())
+		Ω(builder.SetValue(0, 1, 1)).Should(BeNil())
+		Ω(builder.SetValue(0, 2, 1.5)).Should(BeNil())
+		Ω(builder.SetValue(0, 3, 7)).Should(BeNil())
+		builder.AddRow()
+		Ω(builder.SetValue(1, 0, 2)).Should(BeNil())
+		buffer, err := builder.ToByteArray()
+		Ω(err).Should(BeNil())
+		upsertBatch, err := memCom.NewUpsertBatch(buffer)
+		Ω(err).Should(BeNil())
+		return upsertBatch
+	}
+
+	It("converts upsert batches to change events", func() {
+		messages, err := NewMessages(schema, 1, newUpsertBatch(), 100, 2, time.Unix(10, 0))
+		Ω(err).Should(BeNil())
+		Ω(messages).Should(HaveLen(2))
+
+		Ω(string(messages[0].Key)).Should(Equal(`{"table":"trips","key":{"id":1}}`))
+		var envelope map[string]interface{}
+		Ω(json.Unmarshal(messages[0].Value, &envelope)).Should(BeNil())
+		Ω(envelope).Should(Equal(map[string]interface{}{
+			"version":     1.0,
+			"table":       "trips",
+			"shard":       1.0,
+			"op":          "upsert",
+			"redoLogFile": 100.0,
+			"offset":      2.0,
+			"row":         0.0,
+			"commitTime":  10000.0,
+			"key":         map[string]interface{}{"id": 1.0},
+			"columns":     map[string]interface{}{"id": 1.0, "city": "nyc", "fare": 1.5},
+			"updateModes": map[string]interface{}{"city": "force_overwrite", "fare": "add"},
+		}))
+
+		Ω(string(messages[1].Key)).Should(Equal(`{"table":"trips","key":{"id":2}}`))
+		Ω(json.Unmarshal(messages[1].Value, &envelope)).Should(BeNil())
+		Ω(envelope["row"]).Should(Equal(1.0))
+		Ω(envelope["columns"]).Should(Equal(map[string]interface{}{"id": 2.0, "city": nil, "fare": nil}))
+	})
+
+	It("validates config", func() {
+		_, err := NewPublisherWithSink(common.CDCConfig{Topic: "cdc", Format: "avro"}, &memSink{})
+		Ω(err).ShouldNot(BeNil())
+		_, err = NewPublisherWithSink(common.CDCConfig{}, &memSink{})
+		Ω(err).ShouldNot(BeNil())
+		publisher, err := NewPublisher(common.CDCConfig{})
+		Ω(err).Should(BeNil())
+		Ω(publisher).Should(BeNil())
+	})
+
+	It("publishes change events of configured tables to table topics", func() {
+		sink := &memSink{failures: 1}
+		publisher, err := NewPublisherWithSink(common.CDCConfig{
+			Topic:  "cdc-{table}",
+			Tables: []string{"trips"},
+		}, sink)
+		Ω(err).Should(BeNil())
+
+		other := memCom.NewTableSchema(&metaCom.Table{Name: "other"})
+		publisher.Publish(other, 0, newUpsertBatch(), 1, 0)
+		publisher.Publish(schema, 0, newUpsertBatch(), 1, 1)
+		Ω(publisher.Close()).Should(BeNil())
+		// ignored after close.
+		publisher.Publish(schema, 0, newUpsertBatch(), 1, 2)
+
+		Ω(sink.closed).Should(BeTrue())
+		Ω(sink.writes).Should(HaveLen(1))
+		Ω(sink.writes[0].topic).Should(Equal("cdc-trips"))
+		Ω(sink.writes[0].messages).Should(HaveLen(2))
+	})
+})
@@ -0,0 +1,127 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include "../../query/time_series_aggregate.h"
+#include "dispatch.h"
+
+DISPATCH("algorithm", InitIndexVector,
+         (uint32_t *indexVector, uint32_t start, int indexVectorLength,
+             void *cudaStream, int device),
+         (indexVector, start, indexVectorLength, cudaStream, device))
+
+DISPATCH("algorithm", HashLookup,
+         (InputVector input, RecordID *output, uint32_t *indexVector,
+             int indexVectorLength, uint32_t *baseCounts, uint32_t startCount,
+             CuckooHashIndex hashIndex, void *cudaStream, int device),
+         (input, output, indexVector, indexVectorLength, baseCounts,
+             startCount, hashIndex, cudaStream, device))
+
+DISPATCH("algorithm", AsOfLookup,
+         (InputVector eventTimes, RecordID *recordIDs, uint32_t *indexVector,
+             int indexVectorLength, uint32_t *baseCounts, uint32_t startCount,
+             VersionIndex versionIndex, void *cudaStream, int device),
+         (eventTimes, recordIDs, indexVector, indexVectorLength, baseCounts,
+             startCount, versionIndex, cudaStream, device))
+
+DISPATCH("algorithm", UnaryTransform,
+         (InputVector input, OutputVector output, uint32_t *indexVector,
+             int indexVectorLength, uint32_t *baseCounts, uint32_t startCount,
+             enum UnaryFunctorType functorType, void *cudaStream, int device),
+         (input, output, indexVector, indexVectorLength, baseCounts,
+             startCount, functorType, cudaStream, device))
+
+DISPATCH("algorithm", UnaryFilter,
+         (InputVector input, uint32_t *indexVector, uint8_t *predicateVector,
+             int indexVectorLength, RecordID **recordIDVectors,
+             int numForeignTables, uint32_t *baseCounts, uint32_t startCount,
+             enum UnaryFunctorType functorType, void *cudaStream, int device),
+         (input, indexVector, predicateVector, indexVectorLength,
+             recordIDVectors, numForeignTables, baseCounts, startCount,
+             functorType, cudaStream, device))
+
+DISPATCH("algorithm", BinaryTransform,
+         (InputVector lhs, InputVector rhs, OutputVector output,
+             uint32_t *indexVector, int indexVectorLength,
+             uint32_t *baseCounts, uint32_t startCount,
+             enum BinaryFunctorType functorType, void *cudaStream,
+             int device),
+         (lhs, rhs, output, indexVector, indexVectorLength, baseCounts,
+             startCount, functorType, cudaStream, device))
+
+DISPATCH("algorithm", BinaryFilter,
+         (InputVector lhs, InputVector rhs, uint32_t *indexVector,
+             uint8_t *predicateVector, int indexVectorLength,
+             RecordID **recordIDVectors, int numForeignTables,
+             uint32_t *baseCounts, uint32_t startCount,
+             enum BinaryFunctorType functorType, void *cudaStream,
+             int device),
+         (lhs, rhs, indexVector, predicateVector, indexVectorLength,
+             recordIDVectors, numForeignTables, baseCounts, startCount,
+             functorType, cudaStream, device))
+
+DISPATCH("algorithm", Sort,
+         (DimensionVector keys, int length, void *cudaStream, int device),
+         (keys, length, cudaStream, device))
+
+DISPATCH("algorithm", Reduce,
+         (DimensionVector inputKeys, uint8_t *inputValues,
+             DimensionVector outputKeys, uint8_t *outputValues,
+             int valueBytes, int length, enum AggregateFunction aggFunc,
+             void *cudaStream, int device),
+         (inputKeys, inputValues, outputKeys, outputValues, valueBytes,
+             length, aggFunc, cudaStream, device))
+
+DISPATCH("algorithm", HashReduce,
+         (DimensionVector inputKeys, uint8_t *inputValues,
+             DimensionVector outputKeys, uint8_t *outputValues,
+             int valueBytes, int length, enum AggregateFunction aggFunc,
+             void *cudaStream, int device),
+         (inputKeys, inputValues, outputKeys, outputValues, valueBytes,
+             length, aggFunc, cudaStream, device))
+
+DISPATCH("algorithm", Expand,
+         (DimensionVector inputKeys, DimensionVector outputKeys,
+             uint32_t *baseCounts, uint32_t *indexVector, int indexVectorLen,
+             int outputOccupiedLen, void *cudaStream, int device),
+         (inputKeys, outputKeys, baseCounts, indexVector, indexVectorLen,
+             outputOccupiedLen, cudaStream, device))
+
+DISPATCH("algorithm", HyperLogLog,
+         (DimensionVector prevDimOut, DimensionVector curDimOut,
+             uint32_t *prevValuesOut, uint32_t *curValuesOut,
+             int prevResultSize, int curBatchSize, bool isLastBatch,
+             uint8_t **hllVectorPtr, size_t *hllVectorSizePtr,
+             uint16_t **hllDimRegIDCountPtr, void *cudaStream, int device),
+         (prevDimOut, curDimOut, prevValuesOut, curValuesOut, prevResultSize,
+             curBatchSize, isLastBatch, hllVectorPtr, hllVectorSizePtr,
+             hllDimRegIDCountPtr, cudaStream, device))
+
+DISPATCH("algorithm", GeoBatchIntersects,
+         (GeoShapeBatch geoShapeBatch, InputVector points,
+             uint32_t *indexVector, int indexVectorLength,
+             uint32_t startCount, RecordID **recordIDVectors,
+             int numForeignTables, uint32_t *outputPredicate, bool inOrOut,
+             void *cudaStream, int device),
+         (geoShapeBatch, points, indexVector, indexVectorLength, startCount,
+             recordIDVectors, numForeignTables, outputPredicate, inOrOut,
+             cudaStream, device))
+
+DISPATCH("algorithm", WriteGeoShapeDim,
+         (int shapeTotalWords, DimensionOutputVector dimOut,
+             int indexVectorLengthBeforeGeo, uint32_t *outputPredicate,
+             void *cudaStream, int device),
+         (shapeTotalWords, dimOut, indexVectorLengthBeforeGeo,
+             outputPredicate, cudaStream, device))
+
+DISPATCH("algorithm", BootstrapDevice, (), ())
@@ -0,0 +1,60 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#ifndef CGOUTILS_DISPATCH_DISPATCH_H_
+#define CGOUTILS_DISPATCH_DISPATCH_H_
+
+#include "../utils.h"
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+// In DEVICE mode builds, libmem.so and libalgorithm.so linked by golang only
+// forward calls to the implementations loaded with dlopen from the same
+// directory: lib<name>_device.so if the cuda runtime can be loaded and there
+// is at least one cuda device, lib<name>_host.so otherwise. Setting
+// ARES_QUERY_MODE to host or device skips the detection, no fallback happens
+// when device is required.
+#define QUERY_MODE_ENV "ARES_QUERY_MODE"
+
+// DispatchLookup returns the address of symbol in the implementation of
+// library name (mem or algorithm) of the selected query mode. It returns NULL
+// and sets error, which should be freed by caller, if the symbol cannot be
+// resolved.
+void *DispatchLookup(const char *name, const char *symbol, const char **error);
+
+// DISPATCH defines function fn forwarding the call to its implementation in
+// library name. Failures to resolve the implementation are returned as errors
+// of the call.
+#define DISPATCH(name, fn, params, args)                                     \
+  CGoCallResHandle fn params {                                               \
+    /* racing threads resolve and store the same address. */                \
+    static CGoCallResHandle (*impl) params = NULL;                           \
+    if (impl == NULL) {                                                      \
+      const char *error = NULL;                                              \
+      impl = (CGoCallResHandle (*) params) DispatchLookup(name, #fn, &error); \
+      if (impl == NULL) {                                                    \
+        CGoCallResHandle resHandle = {NULL, error};                          \
+        return resHandle;                                                    \
+      }                                                                      \
+    }                                                                        \
+    return impl args;                                                        \
+  }
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif  // CGOUTILS_DISPATCH_DISPATCH_H_
@@ -0,0 +1,190 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#ifndef _GNU_SOURCE
+#define _GNU_SOURCE
+#endif
+#include <dlfcn.h>
+#include <limits.h>
+#include <pthread.h>
+#include <stdint.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#include "../memory.h"
+#include "dispatch.h"
+
+static pthread_once_t queryModeOnce = PTHREAD_ONCE_INIT;
+static const char *queryMode = "host";
+// Set if device mode is required but not available.
+static char *queryModeError = NULL;
+// Directory of this library with trailing slash, implementations are
+// loaded from there.
+static char libraryDir[PATH_MAX] = "";
+
+static void *openImplementation(const char *name, const char *mode,
+                                char **error) {
+  char path[PATH_MAX + 32];
+  snprintf(path, sizeof(path), "%slib%s_%s.so", libraryDir, name, mode);
+  void *handle = dlopen(path, RTLD_NOW | RTLD_LOCAL);
+  if (handle == NULL && error != NULL) {
+    const char *message = dlerror();
+    *error = strdup(message != NULL ? message : path);
+  }
+  return handle;
+}
+
+// deviceAvailable loads the device mode libmem, which fails if the cuda
+// runtime is not installed, and checks whether there is any cuda device. The
+// library is never closed as the cuda runtime does not support unloading.
+static int deviceAvailable(char **error) {
+  void *handle = openImplementation("mem", "device", error);
+  if (handle == NULL) {
+    return 0;
+  }
+  CGoCallResHandle (*getDeviceCount)() =
+      (CGoCallResHandle (*)()) dlsym(handle, "GetDeviceCount");
+  if (getDeviceCount == NULL) {
+    *error = strdup("GetDeviceCount not found in device mode libmem");
+    return 0;
+  }
+  CGoCallResHandle resHandle = getDeviceCount();
+  if (resHandle.pStrErr != NULL) {
+    *error = (char *) resHandle.pStrErr;
+    return 0;
+  }
+  if ((intptr_t) resHandle.res <= 0) {
+    *error = strdup("no cuda device found");
+    return 0;
+  }
+  return 1;
+}
+
+static void selectQueryMode() {
+  Dl_info info;
+  if (dladdr((void *) &selectQueryMode, &info) != 0 &&
+      info.dli_fname != NULL) {
+    const char *slash = strrchr(info.dli_fname, '/');
+    size_t length = slash != NULL ? slash - info.dli_fname + 1 : 0;
+    if (length < sizeof(libraryDir)) {
+      memcpy(libraryDir, info.dli_fname, length);
+      libraryDir[length] = '\0';
+    }
+  }
+
+  const char *mode = getenv(QUERY_MODE_ENV);
+  if (mode != NULL && strcmp(mode, "host") == 0) {
+    return;
+  }
+
+  char *error = NULL;
+  if (deviceAvailable(&error)) {
+    queryMode = "device";
+    return;
+  }
+
+  if (mode != NULL && strcmp(mode, "device") == 0) {
+    queryMode = "device";
+    queryModeError = error;
+    return;
+  }
+  fprintf(stderr, "Falling back to host mode query execution: %s\n", error);
+  free(error);
+}
+
+void *DispatchLookup(const char *name, const char *symbol,
+                     const char **error) {
+  pthread_once(&queryModeOnce, selectQueryMode);
+  if (queryModeError != NULL) {
+    *error = fmtError(symbol, queryModeError);
+    return NULL;
+  }
+
+  char *openError = NULL;
+  // dlopen returns the same handle for libraries already loaded.
+  void *handle = openImplementation(name, queryMode, &openError);
+  if (handle == NULL) {
+    *error = fmtError(symbol, openError);
+    free(openError);
+    return NULL;
+  }
+  void *address = dlsym(handle, symbol);
+  if (address == NULL) {
+    *error = fmtError(symbol, "symbol not found in implementation");
+  }
+  return address;
+}
+
+DeviceMemoryFlags GetFlags() {
+  const char *error = NULL;
+  DeviceMemoryFlags (*getFlags)() =
+      (DeviceMemoryFlags (*)()) DispatchLookup("mem", "GetFlags", &error);
+  if (getFlags == NULL) {
+    free((void *) error);
+    return 0;
+  }
+  return getFlags();
+}
+
+DISPATCH("mem", HostAlloc, (size_t bytes), (bytes))
+
+DISPATCH("mem", HostFree, (void *p), (p))
+
+DISPATCH("mem", HostMemCpy, (void *dst, const void *src, size_t bytes),
+         (dst, src, bytes))
+
+DISPATCH("mem", CreateCudaStream, (int device), (device))
+
+DISPATCH("mem", WaitForCudaStream, (void *s, int device), (s, device))
+
+DISPATCH("mem", DestroyCudaStream, (void *s, int device), (s, device))
+
+DISPATCH("mem", CreateCudaEvent, (int device), (device))
+
+DISPATCH("mem", RecordCudaEvent, (void *e, void *s, int device), (e, s, device))
+
+DISPATCH("mem", GetCudaEventElapsedTime,
+         (float *ms, void *start, void *end, int device),
+         (ms, start, end, device))
+
+DISPATCH("mem", DestroyCudaEvent, (void *e, int device), (e, device))
+
+DISPATCH("mem", DeviceAllocate, (size_t bytes, int device), (bytes, device))
+
+DISPATCH("mem", DeviceFree, (void *p, int device), (p, device))
+
+DISPATCH("mem", AsyncCopyHostToDevice,
+         (void *dst, void *src, size_t bytes, void *stream, int device),
+         (dst, src, bytes, stream, device))
+
+DISPATCH("mem", AsyncCopyDeviceToDevice,
+         (void *dst, void *src, size_t bytes, void *stream, int device),
+         (dst, src, bytes, stream, device))
+
+DISPATCH("mem", AsyncCopyDeviceToHost,
+         (void *dst, void *src, size_t bytes, void *stream, int device),
+         (dst, src, bytes, stream, device))
+
+DISPATCH("mem", GetDeviceCount, (), ())
+
+DISPATCH("mem", GetDeviceGlobalMemoryInMB, (int device), (device))
+
+DISPATCH("mem", CudaProfilerStart, (), ())
+
+DISPATCH("mem", CudaProfilerStop, (), ())
+
+DISPATCH("mem", GetDeviceMemoryInfo,
+         (size_t *freeSize, size_t *totalSize, int device),
+         (freeSize, totalSize, device))
@@ -99,6 +99,40 @@ func DestroyCudaStream(stream unsafe.Pointer, device int) {
 	}
 }
 
+// CreateCudaEvent creates a Cuda event for timing operations on streams.
+func CreateCudaEvent(device int) unsafe.Pointer {
+	return unsafe.Pointer(doCGoCall(func() C.CGoCallResHandle {
+		return C.CreateCudaEvent(C.int(device))
+	}))
+}
+
+// RecordCudaEvent records the event on the specified Cuda stream once all
+// operations already enqueued on the stream are finished.
+func RecordCudaEvent(event, stream unsafe.Pointer, device int) {
+	doCGoCall(func() C.CGoCallResHandle {
+		return C.RecordCudaEvent(event, stream, C.int(device))
+	})
+}
+
+// GetCudaEventElapsedTime block waits until the end event is recorded and
+// returns the device time elapsed between the two events in milliseconds.
+func GetCudaEventElapsedTime(start, end unsafe.Pointer, device int) float64 {
+	var ms C.float
+	doCGoCall(func() C.CGoCallResHandle {
+		return C.GetCudaEventElapsedTime(&ms, start, end, C.int(device))
+	})
+	return float64(ms)
+}
+
+// DestroyCudaEvent destroys the specified Cuda event.
+func DestroyCudaEvent(event unsafe.Pointer, device int) {
+	if event != nil {
+		doCGoCall(func() C.CGoCallResHandle {
+			return C.DestroyCudaEvent(event, C.int(device))
+		})
+	}
+}
+
 // DeviceAllocate allocates the specified amount of memory on the device.
 func DeviceAllocate(bytes, device int) unsafe.Pointer {
 	return unsafe.Pointer(doCGoCall(func() C.CGoCallResHandle {
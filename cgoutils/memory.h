@@ -62,6 +62,15 @@ CGoCallResHandle WaitForCudaStream(void *s, int device);
 
 CGoCallResHandle DestroyCudaStream(void *s, int device);
 
+CGoCallResHandle CreateCudaEvent(int device);
+
+CGoCallResHandle RecordCudaEvent(void *e, void *s, int device);
+
+CGoCallResHandle GetCudaEventElapsedTime(float *ms, void *start, void *end,
+    int device);
+
+CGoCallResHandle DestroyCudaEvent(void *e, int device);
+
 CGoCallResHandle DeviceAllocate(size_t bytes, int device);
 
 CGoCallResHandle DeviceFree(void *p, int device);
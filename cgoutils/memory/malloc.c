@@ -15,6 +15,7 @@
 #include <stdio.h>
 #include <stdlib.h>
 #include <string.h>
+#include <time.h>
 
 #include "../memory.h"
 
@@ -61,6 +62,30 @@ CGoCallResHandle DestroyCudaStream(void *s, int device) {
   return resHandle;
 }
 
+// Events record host time as kernels run synchronously on host.
+CGoCallResHandle CreateCudaEvent(int device) {
+  return HostAlloc(sizeof(struct timespec));
+}
+
+CGoCallResHandle RecordCudaEvent(void *e, void *s, int device) {
+  CGoCallResHandle resHandle = {NULL, NULL};
+  clock_gettime(CLOCK_MONOTONIC, (struct timespec *) e);
+  return resHandle;
+}
+
+CGoCallResHandle GetCudaEventElapsedTime(float *ms, void *start, void *end,
+    int device) {
+  CGoCallResHandle resHandle = {NULL, NULL};
+  struct timespec *s = (struct timespec *) start;
+  struct timespec *e = (struct timespec *) end;
+  *ms = (e->tv_sec - s->tv_sec) * 1e3f + (e->tv_nsec - s->tv_nsec) / 1e6f;
+  return resHandle;
+}
+
+CGoCallResHandle DestroyCudaEvent(void *e, int device) {
+  return HostFree(e);
+}
+
 // Simulate on host side.
 CGoCallResHandle DeviceAllocate(size_t bytes, int device) {
   return HostAlloc(bytes);
@@ -175,6 +175,42 @@ CGoCallResHandle DestroyCudaStream(void *s, int device) {
   return resHandle;
 }
 
+CGoCallResHandle CreateCudaEvent(int device) {
+  CGoCallResHandle resHandle = {NULL, NULL};
+  cudaSetDevice(device);
+  cudaEvent_t e = NULL;
+  cudaEventCreate(&e);
+  resHandle.res = reinterpret_cast<void *>(e);
+  resHandle.pStrErr = checkCUDAError("CreateCudaEvent");
+  return resHandle;
+}
+
+CGoCallResHandle RecordCudaEvent(void *e, void *s, int device) {
+  CGoCallResHandle resHandle = {NULL, NULL};
+  cudaSetDevice(device);
+  cudaEventRecord((cudaEvent_t) e, (cudaStream_t) s);
+  resHandle.pStrErr = checkCUDAError("RecordCudaEvent");
+  return resHandle;
+}
+
+CGoCallResHandle GetCudaEventElapsedTime(float *ms, void *start, void *end,
+                                         int device) {
+  CGoCallResHandle resHandle = {NULL, NULL};
+  cudaSetDevice(device);
+  cudaEventSynchronize((cudaEvent_t) end);
+  cudaEventElapsedTime(ms, (cudaEvent_t) start, (cudaEvent_t) end);
+  resHandle.pStrErr = checkCUDAError("GetCudaEventElapsedTime");
+  return resHandle;
+}
+
+CGoCallResHandle DestroyCudaEvent(void *e, int device) {
+  CGoCallResHandle resHandle = {NULL, NULL};
+  cudaSetDevice(device);
+  cudaEventDestroy((cudaEvent_t) e);
+  resHandle.pStrErr = checkCUDAError("DestroyCudaEvent");
+  return resHandle;
+}
+
 CGoCallResHandle AsyncCopyHostToDevice(
     void *dst, void *src, size_t bytes, void *stream, int device) {
   CGoCallResHandle resHandle = {NULL, NULL};
@@ -205,9 +241,18 @@ CGoCallResHandle AsyncCopyDeviceToHost(
   return resHandle;
 }
 
+// GetDeviceCount returns 0 instead of an error when there is no cuda capable
+// device or driver so that callers can fall back to host mode.
 CGoCallResHandle GetDeviceCount() {
   CGoCallResHandle resHandle = {NULL, NULL};
-  cudaGetDeviceCount(reinterpret_cast<int *>(&resHandle.res));
+  int deviceCount = 0;
+  cudaError_t error = cudaGetDeviceCount(&deviceCount);
+  if (error == cudaErrorNoDevice || error == cudaErrorInsufficientDriver) {
+    // Reset the sticky last error.
+    cudaGetLastError();
+    return resHandle;
+  }
+  resHandle.res = reinterpret_cast<void *>(deviceCount);
   resHandle.pStrErr = checkCUDAError("GetDeviceCount");
   return resHandle;
 }
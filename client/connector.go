@@ -17,6 +17,9 @@ package client
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
 	"net/http"
 	"sync"
 	"time"
@@ -29,6 +32,7 @@ import (
 	"github.com/uber-go/tally"
 	memCom "github.com/uber/aresdb/memstore/common"
 	metaCom "github.com/uber/aresdb/metastore/common"
+	queryCom "github.com/uber/aresdb/query/common"
 	"github.com/uber/aresdb/utils"
 	"go.uber.org/zap"
 )
@@ -41,6 +45,10 @@ const (
 	dataIngestionHeader          = "application/upsert-data"
 	applicationJSONHeader        = "application/json"
 	defaultStringEnumLength      = 1024
+	// default wait before retrying throttled ingestion if ares does not specify it
+	defaultThrottleRetryAfter = 5 * time.Second
+	// max bytes of error responses to include in errors
+	maxErrorResponseBytes = 1024
 )
 
 // Row represents a row of insert data.
@@ -53,10 +61,24 @@ type Connector interface {
 	// updateModes are optional, if ignored for all columns, no need to set
 	// if set, then all columns needs to be set
 	Insert(tableName string, columnNames []string, rows []Row, updateModes ...memCom.ColumnUpdateMode) (int, error)
+	// Query runs sql queries and returns their results.
+	// If ReadYourWrites is enabled, queries only run after rows inserted by the connector are applied.
+	Query(sqls ...string) ([]queryCom.AQLQueryResult, error)
 	// Close the connection
 	Close()
 }
 
+// ThrottledError is returned by Insert when ares rejects the rows because it cannot keep up
+// with ingestion. Callers should back off and retry the rows after RetryAfter.
+type ThrottledError struct {
+	Table      string
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("ingestion into table %s is throttled, retry after %v", e.Table, e.RetryAfter)
+}
+
 // UpsertBatchBuilder is an interface of upsertBatch on client side
 type UpsertBatchBuilder interface {
 	PrepareUpsertBatch(tableName string, columnNames []string, updateModes []memCom.ColumnUpdateMode, rows []Row) ([]byte, int, error)
@@ -83,6 +105,10 @@ type UpsertBatchBuilderImpl struct {
 	logger        *zap.SugaredLogger
 	metricScope   tally.Scope
 	schemaHandler *CachedSchemaHandler
+	// max number of vertices of geo shapes, 0 means no simplification
+	geoShapeMaxVertices int
+	// whether rows with geo shapes failing validation are dropped
+	validateGeoShapes bool
 }
 
 // connector is the ares connector implementation
@@ -91,6 +117,10 @@ type connector struct {
 	httpClient         http.Client
 	upsertBatchBuilder UpsertBatchBuilder
 	schemaHandler      *CachedSchemaHandler
+
+	// write positions of rows inserted by the connector, for queries to read them.
+	writePositionsLock sync.Mutex
+	writePositions     memCom.WritePositions
 }
 
 // ConnectorConfig holds the configurations for ares Connector.
@@ -104,6 +134,20 @@ type ConnectorConfig struct {
 	// fetch and refresh schema from ares
 	// if <= 0, will use default
 	SchemaRefreshInterval int `yaml:"schemaRefreshInterval" json:"schemaRefreshInterval"`
+	// GeoShapeMaxVertices is the max number of vertices of geo shapes to ingest,
+	// shapes with more vertices will be simplified.
+	// if <= 0, shapes will not be simplified
+	GeoShapeMaxVertices int `yaml:"geoShapeMaxVertices" json:"geoShapeMaxVertices"`
+	// ValidateGeoShapes drops rows with geo shapes having unclosed or degenerated rings,
+	// which produce wrong results for geography_intersects.
+	ValidateGeoShapes bool `yaml:"validateGeoShapes" json:"validateGeoShapes"`
+	// ReadYourWrites makes the connector record the write positions of its inserts and
+	// queries of the connector wait for those positions to be applied before running.
+	ReadYourWrites bool `yaml:"readYourWrites" json:"readYourWrites"`
+	// ReadYourWritesTimeout is the max milliseconds queries wait for inserts to be applied,
+	// queries fail if inserts are still not applied after that.
+	// if <= 0, will use server default
+	ReadYourWritesTimeout int `yaml:"readYourWritesTimeout" json:"readYourWritesTimeout"`
 }
 
 func NewUpsertBatchBuilderImpl(logger *zap.SugaredLogger, scope tally.Scope, schemaHandler *CachedSchemaHandler) UpsertBatchBuilder {
@@ -136,11 +180,14 @@ func (cfg ConnectorConfig) NewConnector(logger *zap.SugaredLogger, metricScope t
 		cfg:        cfg,
 		httpClient: httpClient,
 		upsertBatchBuilder: &UpsertBatchBuilderImpl{
-			logger:        logger,
-			metricScope:   metricScope,
-			schemaHandler: cachedSchemaHandler,
+			logger:              logger,
+			metricScope:         metricScope,
+			schemaHandler:       cachedSchemaHandler,
+			geoShapeMaxVertices: cfg.GeoShapeMaxVertices,
+			validateGeoShapes:   cfg.ValidateGeoShapes,
 		},
-		schemaHandler: cachedSchemaHandler,
+		schemaHandler:  cachedSchemaHandler,
+		writePositions: memCom.WritePositions{},
 	}
 	return connector
 }
@@ -182,22 +229,88 @@ func (c *connector) Insert(tableName string, columnNames []string, rows []Row, u
 
 	//TODO: currently always use shard zero for single instance version
 	resp, err := c.httpClient.Post(c.dataPath(tableName, 0), dataIngestionHeader, bytes.NewReader(upsertBatchBytes))
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		resp.Body.Close()
+		retryAfter := defaultThrottleRetryAfter
+		if seconds, parseErr := strconv.Atoi(resp.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return 0, &ThrottledError{Table: tableName, RetryAfter: retryAfter}
+	}
 	if err != nil || resp.StatusCode != http.StatusOK {
 		//TODO: break status code check and error check into two parts for more specific handling like retrying on 5xx
 		return 0, utils.StackError(err, "Failed to post upsert batch, table: %s, shard: %d", tableName, 0)
 	}
+	defer resp.Body.Close()
 
+	if c.cfg.ReadYourWrites {
+		position, err := memCom.ParseWritePosition(resp.Header.Get(memCom.WritePositionHeaderKey))
+		if err != nil {
+			return numRows, utils.StackError(err, "Missing write position of upsert batch, table: %s, shard: %d", tableName, 0)
+		}
+		c.writePositionsLock.Lock()
+		c.writePositions.Advance(memCom.TableShardID{Table: tableName, Shard: 0}, position)
+		c.writePositionsLock.Unlock()
+	}
 	return numRows, nil
 }
 
+// Query runs sql queries and returns their results.
+func (c *connector) Query(sqls ...string) ([]queryCom.AQLQueryResult, error) {
+	requestBytes, err := json.Marshal(map[string][]string{"queries": sqls})
+	if err != nil {
+		return nil, utils.StackError(err, "Failed to marshal queries")
+	}
+	req, err := http.NewRequest(http.MethodPost, c.queryPath(), bytes.NewReader(requestBytes))
+	if err != nil {
+		return nil, utils.StackError(err, "Failed to create query request")
+	}
+	req.Header.Set("Content-Type", applicationJSONHeader)
+	if c.cfg.ReadYourWrites {
+		c.writePositionsLock.Lock()
+		readAfter := c.writePositions.String()
+		c.writePositionsLock.Unlock()
+		if readAfter != "" {
+			req.Header.Set(memCom.ReadAfterHeaderKey, readAfter)
+			if c.cfg.ReadYourWritesTimeout > 0 {
+				req.Header.Set(memCom.ReadAfterTimeoutHeaderKey, strconv.Itoa(c.cfg.ReadYourWritesTimeout))
+			}
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, utils.StackError(err, "Failed to post queries")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorResponseBytes))
+		return nil, utils.StackError(nil, "Failed to run queries, status: %d, response: %s", resp.StatusCode, body)
+	}
+
+	var response struct {
+		Results []queryCom.AQLQueryResult `json:"results"`
+		Errors  []interface{}             `json:"errors"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, utils.StackError(err, "Failed to decode query response")
+	}
+	for i, queryErr := range response.Errors {
+		if queryErr != nil {
+			return response.Results, utils.StackError(nil, "Query %d failed: %v", i, queryErr)
+		}
+	}
+	return response.Results, nil
+}
+
 // Close the connection
 func (c *connector) Close() {
 	c.schemaHandler = nil
 	c.upsertBatchBuilder = nil
 }
 
-// computeHLLValue populate hyperloglog value
-func computeHLLValue(dataType memCom.DataType, value interface{}) (uint32, error) {
+// computeHLLValue populate hyperloglog value of the precision
+func computeHLLValue(dataType memCom.DataType, value interface{}, precision int) (uint32, error) {
 	var ok bool
 	var hashed uint64
 	switch dataType {
@@ -223,7 +336,7 @@ func computeHLLValue(dataType memCom.DataType, value interface{}) (uint32, error
 	if !ok {
 		return 0, utils.StackError(nil, "invalid data value %v for data type %s", value, memCom.DataTypeName[dataType])
 	}
-	return utils.ComputeHLLValue(hashed), nil
+	return utils.ComputeHLLValueWithPrecision(hashed, precision), nil
 }
 
 // prepareUpsertBatch prepares the upsert batch for upsert,
@@ -282,8 +395,36 @@ func (c *connector) dataPath(tableName string, shard int) string {
 	return fmt.Sprintf("http://%s/data/%s/%d", c.cfg.Address, tableName, shard)
 }
 
-func (u *UpsertBatchBuilderImpl) prepareEnumCases(isEnumArrayCol bool, tableName, columnName string, colIndex, columnID int, rows []Row, abandonRows map[int]struct{}, caseInsensitive bool, disableAutoExpand bool) error {
+func (c *connector) queryPath() string {
+	return fmt.Sprintf("http://%s/query/sql", c.cfg.Address)
+}
+
+// normalizeEnumCase applies the enum normalization rules of the column to enum string values,
+// remapped values are counted.
+func (u *UpsertBatchBuilderImpl) normalizeEnumCase(tableName string, columnID int, normalization *metaCom.EnumNormalization, value interface{}) interface{} {
+	enumCase, ok := value.(string)
+	if !ok || normalization == nil {
+		return value
+	}
+	normalized, remapped := normalization.Normalize(enumCase)
+	if remapped {
+		u.metricScope.Tagged(map[string]string{"table": tableName, "columnID": strconv.Itoa(columnID)}).
+			Counter("enum_values_remapped").Inc(1)
+	}
+	return normalized
+}
+
+func (u *UpsertBatchBuilderImpl) prepareEnumCases(isEnumArrayCol bool, tableName, columnName string, colIndex, columnID int, rows []Row, abandonRows map[int]struct{}, caseInsensitive bool, disableAutoExpand bool, normalization *metaCom.EnumNormalization) error {
+	// enum cases are collected in the order they appear in rows so that new enum cases
+	// are assigned enum ids deterministically.
 	enumCaseSet := make(map[string]struct{})
+	var enumCases []string
+	addEnumCase := func(enumCase string) {
+		if _, exist := enumCaseSet[enumCase]; !exist {
+			enumCaseSet[enumCase] = struct{}{}
+			enumCases = append(enumCases, enumCase)
+		}
+	}
 	for rowIndex, row := range rows {
 		if _, exist := abandonRows[rowIndex]; exist {
 			continue
@@ -333,18 +474,20 @@ func (u *UpsertBatchBuilderImpl) prepareEnumCases(isEnumArrayCol bool, tableName
 										"value", value).Debug("Enum array value is not string")
 									break
 								}
+								item, _ = normalization.Normalize(item)
 								if caseInsensitive {
 									item = strings.ToLower(item)
 								}
-								enumCaseSet[item] = struct{}{}
+								addEnumCase(item)
 							}
 						}
 					}
 				} else {
+					enumCase, _ = normalization.Normalize(enumCase)
 					if caseInsensitive {
 						enumCase = strings.ToLower(enumCase)
 					}
-					enumCaseSet[enumCase] = struct{}{}
+					addEnumCase(enumCase)
 				}
 			}
 		} else {
@@ -364,11 +507,7 @@ func (u *UpsertBatchBuilderImpl) prepareEnumCases(isEnumArrayCol bool, tableName
 		}
 	}
 
-	if len(enumCaseSet) > 0 {
-		enumCases := make([]string, 0, len(enumCaseSet))
-		for enumCase := range enumCaseSet {
-			enumCases = append(enumCases, enumCase)
-		}
+	if len(enumCases) > 0 {
 		err := u.schemaHandler.PrepareEnumCases(tableName, columnName, enumCases)
 		if err != nil {
 			return err
@@ -377,6 +516,124 @@ func (u *UpsertBatchBuilderImpl) prepareEnumCases(isEnumArrayCol bool, tableName
 	return nil
 }
 
+// parseMapValue parses value of map column from either a map or a json object string.
+func parseMapValue(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case string:
+		mapVal := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(v), &mapVal); err != nil {
+			return nil, false
+		}
+		return mapVal, true
+	}
+	return nil, false
+}
+
+// prepareMapKeys collects keys of map column values as enum cases of the column, rows with
+// invalid map values or values not representable by the value type of the map are abandoned.
+func (u *UpsertBatchBuilderImpl) prepareMapKeys(tableName, columnName string, colIndex, columnID int, valueType memCom.DataType, rows []Row, abandonRows map[int]struct{}, caseInsensitive bool) error {
+	keySet := make(map[string]struct{})
+	for rowIndex, row := range rows {
+		if _, exist := abandonRows[rowIndex]; exist {
+			continue
+		}
+		value := row[colIndex]
+		if value == nil {
+			continue
+		}
+
+		mapVal, ok := parseMapValue(value)
+		if ok {
+			for key, val := range mapVal {
+				if len(key) > defaultStringEnumLength || (val != nil && !isMapValueOfType(val, valueType)) {
+					ok = false
+					break
+				}
+			}
+		}
+		if !ok {
+			u.logger.With(
+				"name", "prepareMapKeys",
+				"error", fmt.Sprintf("Map value should be json object of %s numbers", memCom.DataTypeName[valueType]),
+				"table", tableName,
+				"columnID", columnID,
+				"value", value).Debug("Invalid map value")
+			u.metricScope.Tagged(map[string]string{"table": tableName, "columnID": strconv.Itoa(columnID)}).
+				Counter("abandoned_rows").Inc(1)
+			abandonRows[rowIndex] = struct{}{}
+			continue
+		}
+
+		for key := range mapVal {
+			if caseInsensitive {
+				key = strings.ToLower(key)
+			}
+			keySet[key] = struct{}{}
+		}
+	}
+
+	if len(keySet) > 0 {
+		keys := make([]string, 0, len(keySet))
+		for key := range keySet {
+			keys = append(keys, key)
+		}
+		return u.schemaHandler.PrepareEnumCases(tableName, columnName, keys)
+	}
+	return nil
+}
+
+// isMapValueOfType checks whether the value of a map entry is a json number representable
+// by the value type of the map.
+func isMapValueOfType(value interface{}, valueType memCom.DataType) bool {
+	number, isNumber := value.(float64)
+	if !isNumber || (valueType != memCom.Float32 && number != math.Trunc(number)) {
+		return false
+	}
+	_, err := memCom.ConvertValueForType(valueType, value, 0)
+	return err == nil
+}
+
+// translateMapKeys converts map value into array of values indexed by key ids.
+func (u *UpsertBatchBuilderImpl) translateMapKeys(tableName string, columnID int, mapVal map[string]interface{}, caseInsensitive bool) ([]interface{}, error) {
+	arrVal := make([]interface{}, 0, len(mapVal))
+	for key, val := range mapVal {
+		keyID, err := u.schemaHandler.TranslateEnum(tableName, columnID, key, caseInsensitive)
+		if err != nil {
+			return nil, err
+		}
+		if keyID < 0 {
+			continue
+		}
+		for len(arrVal) <= keyID {
+			arrVal = append(arrVal, nil)
+		}
+		arrVal[keyID] = val
+	}
+	return arrVal, nil
+}
+
+// prepareGeoShape converts the value into geo shape, validates it if required and
+// simplifies it if it has more vertices than allowed
+func (u *UpsertBatchBuilderImpl) prepareGeoShape(value interface{}) (interface{}, error) {
+	shape, ok := memCom.ConvertToGeoShape(value)
+	if !ok {
+		return nil, utils.StackError(nil, "invalid geo shape %v", value)
+	}
+	if u.validateGeoShapes {
+		if err := shape.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if numVertices := shape.NumPoints(); u.geoShapeMaxVertices > 0 && numVertices > u.geoShapeMaxVertices {
+		shape.Simplify(u.geoShapeMaxVertices)
+		u.metricScope.Counter("geo_shapes_simplified").Inc(1)
+		u.logger.With("name", "PrepareUpsertBatch", "from", numVertices, "to", shape.NumPoints()).Debug("geo shape simplified")
+	}
+	return shape, nil
+}
+
 // PrepareUpsertBatch prepares the upsert batch for upsert,
 // returns upsertBatch byte array, number of rows in upsert batch and error.
 func (u *UpsertBatchBuilderImpl) PrepareUpsertBatch(tableName string, columnNames []string,
@@ -413,12 +670,21 @@ func (u *UpsertBatchBuilderImpl) PrepareUpsertBatch(tableName string, columnName
 		}
 
 		dataType := memCom.DataTypeForColumn(column)
-		if err = upsertBatchBuilder.AddColumnWithUpdateMode(columnID, dataType, updateModes[colIndex]); err != nil {
+		if dataType == memCom.Decimal {
+			err = upsertBatchBuilder.AddDecimalColumn(columnID, column.DecimalScale, updateModes[colIndex])
+		} else {
+			err = upsertBatchBuilder.AddColumnWithUpdateMode(columnID, dataType, updateModes[colIndex])
+		}
+		if err != nil {
 			return nil, 0, err
 		}
 
-		if column.IsEnumBasedColumn() {
-			if err = u.prepareEnumCases(column.IsEnumArrayColumn(), tableName, columnName, colIndex, columnID, rows, abandonRows, column.CaseInsensitive, column.DisableAutoExpand); err != nil {
+		if column.IsMapColumn() {
+			if err = u.prepareMapKeys(tableName, columnName, colIndex, columnID, memCom.GetElementDataType(dataType), rows, abandonRows, column.CaseInsensitive); err != nil {
+				return nil, 0, err
+			}
+		} else if column.IsEnumBasedColumn() {
+			if err = u.prepareEnumCases(column.IsEnumArrayColumn(), tableName, columnName, colIndex, columnID, rows, abandonRows, column.CaseInsensitive, column.DisableAutoExpand, column.EnumNormalization); err != nil {
 				return nil, 0, err
 			}
 		}
@@ -462,7 +728,22 @@ func (u *UpsertBatchBuilderImpl) PrepareUpsertBatch(tableName string, columnName
 				break
 			}
 
-			if column.IsEnumBasedColumn() {
+			if column.IsMapColumn() {
+				if value != nil {
+					// no error handling of parsing here as it should already be covered in prepareMapKeys
+					mapVal, _ := parseMapValue(value)
+					if value, err = u.translateMapKeys(tableName, columnID, mapVal, column.CaseInsensitive); err != nil {
+						upsertBatchBuilder.RemoveRow()
+						u.logger.With(
+							"name", "prepareUpsertBatch",
+							"error", err.Error(),
+							"table", tableName,
+							"columnID", columnID,
+							"value", mapVal).Error("Failed to translate map keys")
+						break
+					}
+				}
+			} else if column.IsEnumBasedColumn() {
 				if column.IsEnumArrayColumn() {
 					if value != nil {
 						arrVal := make([]interface{}, 0)
@@ -470,6 +751,7 @@ func (u *UpsertBatchBuilderImpl) PrepareUpsertBatch(tableName string, columnName
 						json.Unmarshal([]byte(value.(string)), &arrVal)
 						hasError := false
 						for i := 0; i < len(arrVal); i++ {
+							arrVal[i] = u.normalizeEnumCase(tableName, columnID, column.EnumNormalization, arrVal[i])
 							arrVal[i], err = u.schemaHandler.TranslateEnum(tableName, columnID, arrVal[i], column.CaseInsensitive)
 							if err != nil {
 								hasError = true
@@ -492,6 +774,7 @@ func (u *UpsertBatchBuilderImpl) PrepareUpsertBatch(tableName string, columnName
 						value = arrVal
 					}
 				} else {
+					value = u.normalizeEnumCase(tableName, columnID, column.EnumNormalization, value)
 					value, err = u.schemaHandler.TranslateEnum(tableName, columnID, value, column.CaseInsensitive)
 					if err != nil {
 						upsertBatchBuilder.RemoveRow()
@@ -511,11 +794,43 @@ func (u *UpsertBatchBuilderImpl) PrepareUpsertBatch(tableName string, columnName
 				}
 			}
 
+			// validate geo shapes and simplify those exceeding vertex budget
+			if value != nil && (u.geoShapeMaxVertices > 0 || u.validateGeoShapes) && memCom.DataTypeForColumn(column) == memCom.GeoShape {
+				var shape interface{}
+				if shape, err = u.prepareGeoShape(value); err == nil {
+					value = shape
+				} else {
+					upsertBatchBuilder.RemoveRow()
+					u.logger.With("name", "PrepareUpsertBatch", "error", err.Error(), "table", tableName, "columnID", columnID, "value", value).Error("Failed to prepare geo shape")
+					break
+				}
+			}
+
+			// vector values must have exactly the dimension of the column
+			if value != nil && column.IsVectorColumn() {
+				if _, ok := memCom.ConvertToVector(value, column.VectorDimension); !ok {
+					upsertBatchBuilder.RemoveRow()
+					u.logger.With("name", "PrepareUpsertBatch", "table", tableName, "columnID", columnID, "value", value).Error("Invalid vector value")
+					break
+				}
+			}
+
+			// convert Time64 values to milliseconds since epoch
+			if value != nil && column.IsTime64Column() {
+				time64, ok := memCom.ConvertToTime64(value)
+				if !ok {
+					upsertBatchBuilder.RemoveRow()
+					u.logger.With("name", "PrepareUpsertBatch", "table", tableName, "columnID", columnID, "value", value).Error("Invalid time64 value")
+					break
+				}
+				value = time64
+			}
+
 			// Set value to the last row.
 			// compute hll value to insert
 			if column.HLLConfig.IsHLLColumn {
 				// here use original column data type to compute hll value
-				value, err = computeHLLValue(memCom.DataTypeFromString(column.Type), value)
+				value, err = computeHLLValue(memCom.DataTypeFromString(column.Type), value, column.HLLConfig.GetPrecision())
 				if err != nil {
 					upsertBatchBuilder.RemoveRow()
 					u.logger.With("name", "PrepareUpsertBatch", "error", err.Error(), "table", tableName, "columnID", columnID, "value", value).Error("Failed to set value")
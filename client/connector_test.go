@@ -16,6 +16,8 @@ package client
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
@@ -26,6 +28,7 @@ import (
 
 	"github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/uber-go/tally"
 	"github.com/uber/aresdb/common"
 	memCom "github.com/uber/aresdb/memstore/common"
 	metaCom "github.com/uber/aresdb/metastore/common"
@@ -89,6 +92,24 @@ var _ = ginkgo.Describe("AresDB connector", func() {
 					DisableAutoExpand: false,
 					CaseInsensitive:   true,
 				},
+				{
+					Name: "col8",
+					Type: metaCom.GeoShape,
+				},
+				{
+					Name:              "col9",
+					Type:              metaCom.BigEnum,
+					DisableAutoExpand: true,
+					EnumNormalization: &metaCom.EnumNormalization{
+						Trim:      true,
+						Lowercase: true,
+						Mappings:  map[string]string{"sf": "san francisco"},
+					},
+				},
+				{
+					Name: "col10",
+					Type: metaCom.MapInt16,
+				},
 			},
 			PrimaryKeyColumns: []int{1},
 			IsFactTable:       true,
@@ -97,18 +118,24 @@ var _ = ginkgo.Describe("AresDB connector", func() {
 
 	// this is the enum cases at first
 	initialColumn2EnumCases := map[string][]string{
-		"col2": {"1"},
-		"col4": {"a"},
-		"col5": {"A"},
-		"col6": {"E"},
-		"col7": {"F"},
+		"col2":  {"1"},
+		"col4":  {"a"},
+		"col5":  {"A"},
+		"col6":  {"E"},
+		"col7":  {"F"},
+		"col9":  {"san francisco", "nyc"},
+		"col10": {"surge", "tip"},
 	}
 
 	// extendedEnumIDs
 	column2extendedEnumIDs := []int{2}
 
 	var insertBytes []byte
+	var throttled bool
+	var readAfter, readAfterTimeout string
 	ginkgo.BeforeEach(func() {
+		throttled = false
+		readAfter, readAfterTimeout = "", ""
 		testServer = httptest.NewUnstartedServer(
 			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				if strings.HasSuffix(r.URL.Path, "tables") && r.Method == http.MethodGet {
@@ -144,13 +171,30 @@ var _ = ginkgo.Describe("AresDB connector", func() {
 						w.Write(enumIDBytes)
 					}
 				} else if strings.Contains(r.URL.Path, "data") && r.Method == http.MethodPost {
+					if throttled {
+						w.Header().Set("Retry-After", "3")
+						w.WriteHeader(http.StatusTooManyRequests)
+						return
+					}
 					var err error
 					insertBytes, err = ioutil.ReadAll(r.Body)
 					if err != nil {
 						w.WriteHeader(http.StatusInternalServerError)
 					} else {
+						w.Header().Set(memCom.WritePositionHeaderKey, "1570000000:3")
+						w.WriteHeader(http.StatusOK)
+					}
+				} else if strings.HasSuffix(r.URL.Path, "query/sql") && r.Method == http.MethodPost {
+					readAfter = r.Header.Get(memCom.ReadAfterHeaderKey)
+					readAfterTimeout = r.Header.Get(memCom.ReadAfterTimeoutHeaderKey)
+					body, _ := ioutil.ReadAll(r.Body)
+					if strings.Contains(string(body), "bad") {
 						w.WriteHeader(http.StatusOK)
+						w.Write([]byte(`{"results":[null],"errors":["unknown column bad"]}`))
+						return
 					}
+					w.WriteHeader(http.StatusOK)
+					w.Write([]byte(`{"results":[{"1":2}]}`))
 				}
 			}))
 		testServer.Start()
@@ -161,6 +205,48 @@ var _ = ginkgo.Describe("AresDB connector", func() {
 		testServer.Close()
 	})
 
+	ginkgo.It("Insert should return ThrottledError when ares throttles ingestion", func() {
+		config := ConnectorConfig{
+			Address: hostPort,
+		}
+		logger := zap.NewExample().Sugar()
+		rootScope, _, _ := common.NewNoopMetrics().NewRootScope()
+		connector := config.NewConnector(logger, rootScope)
+
+		throttled = true
+		n, err := connector.Insert("a", []string{"col0", "col1"}, []Row{{100, 1}})
+		Ω(n).Should(Equal(0))
+		Ω(err).Should(Equal(&ThrottledError{Table: "a", RetryAfter: 3 * time.Second}))
+	})
+
+	ginkgo.It("Query should read writes of the connector if ReadYourWrites is enabled", func() {
+		logger := zap.NewExample().Sugar()
+		rootScope, _, _ := common.NewNoopMetrics().NewRootScope()
+
+		connector := ConnectorConfig{Address: hostPort}.NewConnector(logger, rootScope)
+		_, err := connector.Insert("a", []string{"col0", "col1"}, []Row{{100, 1}})
+		Ω(err).Should(BeNil())
+		results, err := connector.Query("SELECT count(*) FROM a")
+		Ω(err).Should(BeNil())
+		Ω(results).Should(HaveLen(1))
+		Ω(readAfter).Should(BeEmpty())
+
+		connector = ConnectorConfig{Address: hostPort, ReadYourWrites: true, ReadYourWritesTimeout: 100}.NewConnector(logger, rootScope)
+		_, err = connector.Query("SELECT count(*) FROM a")
+		Ω(err).Should(BeNil())
+		Ω(readAfter).Should(BeEmpty())
+
+		_, err = connector.Insert("a", []string{"col0", "col1"}, []Row{{100, 1}})
+		Ω(err).Should(BeNil())
+		_, err = connector.Query("SELECT count(*) FROM a")
+		Ω(err).Should(BeNil())
+		Ω(readAfter).Should(Equal("a/0@1570000000:3"))
+		Ω(readAfterTimeout).Should(Equal("100"))
+
+		_, err = connector.Query("SELECT bad FROM a")
+		Ω(err.Error()).Should(ContainSubstring("unknown column bad"))
+	})
+
 	ginkgo.It("Insert", func() {
 		config := ConnectorConfig{
 			Address: hostPort,
@@ -309,6 +395,134 @@ var _ = ginkgo.Describe("AresDB connector", func() {
 		Ω(n).Should(Equal(4))
 	})
 
+	ginkgo.It("Insert geo shape should work", func() {
+		config := ConnectorConfig{
+			Address:             hostPort,
+			GeoShapeMaxVertices: 10,
+			ValidateGeoShapes:   true,
+		}
+		logger := zap.NewExample().Sugar()
+		rootScope, _, _ := common.NewNoopMetrics().NewRootScope()
+
+		c := config.NewConnector(logger, rootScope)
+
+		coordinates := make([]string, 0, 101)
+		for i := 0; i < 100; i++ {
+			angle := 2 * math.Pi * float64(i) / 100
+			coordinates = append(coordinates, fmt.Sprintf("[%f,%f]", math.Cos(angle), math.Sin(angle)))
+		}
+		coordinates = append(coordinates, coordinates[0])
+		geoJSON := fmt.Sprintf(`{"type":"Polygon","coordinates":[[%s]]}`, strings.Join(coordinates, ","))
+
+		n, err := c.Insert("a", []string{"col0", "col1", "col8"}, []Row{
+			{100, int32(1), geoJSON},
+			{200, int32(2), "POLYGON((0 0, 1 0, 1 1, 0 0))"},
+			{300, int32(3), "POLYGON((0 0, 1 0, 1 1))"}, // ring not closed
+			{400, int32(4), nil},
+		})
+		Ω(err).Should(BeNil())
+		Ω(n).Should(Equal(3))
+
+		upsertBatch, err := memCom.NewUpsertBatch(insertBytes)
+		Ω(err).Should(BeNil())
+		value, err := upsertBatch.GetDataValue(0, 2)
+		Ω(err).Should(BeNil())
+		Ω(value.Valid).Should(BeTrue())
+		shape := value.GoVal.(*memCom.GeoShapeGo)
+		Ω(shape.NumPoints() <= 10).Should(BeTrue())
+		Ω(shape.Validate()).Should(BeNil())
+
+		value, err = upsertBatch.GetDataValue(1, 2)
+		Ω(err).Should(BeNil())
+		Ω(value.GoVal.(*memCom.GeoShapeGo).NumPoints()).Should(Equal(4))
+
+		// shapes are not validated unless required.
+		config.ValidateGeoShapes = false
+		c = config.NewConnector(logger, rootScope)
+		n, err = c.Insert("a", []string{"col0", "col1", "col8"}, []Row{
+			{300, int32(3), "POLYGON((0 0, 1 0, 1 1))"},
+		})
+		Ω(err).Should(BeNil())
+		Ω(n).Should(Equal(1))
+	})
+
+	ginkgo.It("Insert should normalize enum values", func() {
+		config := ConnectorConfig{
+			Address: hostPort,
+		}
+		logger := zap.NewExample().Sugar()
+		scope := tally.NewTestScope("test", nil)
+
+		c := config.NewConnector(logger, scope)
+
+		n, err := c.Insert("a", []string{"col0", "col1", "col9"}, []Row{
+			{100, int32(1), " SF "},
+			{200, int32(2), "NYC"},
+			{300, int32(3), "la"},
+		})
+		Ω(err).Should(BeNil())
+		Ω(n).Should(Equal(3))
+
+		upsertBatch, err := memCom.NewUpsertBatch(insertBytes)
+		Ω(err).Should(BeNil())
+		for row, expected := range []uint16{0, 1} {
+			value, err := upsertBatch.GetDataValue(row, 2)
+			Ω(err).Should(BeNil())
+			Ω(value.ConvertToHumanReadable(memCom.BigEnum)).Should(Equal(expected))
+		}
+		value, err := upsertBatch.GetDataValue(2, 2)
+		Ω(err).Should(BeNil())
+		Ω(value.Valid).Should(BeFalse())
+
+		var remapped int64
+		for _, counter := range scope.Snapshot().Counters() {
+			if counter.Name() == "test.enum_values_remapped" {
+				remapped += counter.Value()
+			}
+		}
+		Ω(remapped).Should(Equal(int64(1)))
+	})
+
+	ginkgo.It("Insert map should work", func() {
+		config := ConnectorConfig{
+			Address: hostPort,
+		}
+		logger := zap.NewExample().Sugar()
+		rootScope, _, _ := common.NewNoopMetrics().NewRootScope()
+
+		c := config.NewConnector(logger, rootScope)
+
+		n, err := c.Insert("a", []string{"col0", "col1", "col10"}, []Row{
+			{100, int32(1), `{"surge": 2, "tip": -3}`},
+			{200, int32(2), map[string]interface{}{"tip": float64(1)}},
+			{300, int32(3), `{"surge": 1.5}`},   // invalid value - not integer
+			{400, int32(4), `{"surge": 40000}`}, // invalid value - overflows int16
+			{500, int32(5), nil},
+		})
+		Ω(err).Should(BeNil())
+		Ω(n).Should(Equal(3))
+
+		upsertBatch, err := memCom.NewUpsertBatch(insertBytes)
+		Ω(err).Should(BeNil())
+		value, err := upsertBatch.GetDataValue(0, 2)
+		Ω(err).Should(BeNil())
+		reader := memCom.NewArrayValueReader(memCom.ArrayInt16, value.OtherVal)
+		Ω(reader.GetLength()).Should(Equal(2))
+		Ω(*(*int16)(reader.Get(0))).Should(BeEquivalentTo(2))
+		Ω(*(*int16)(reader.Get(1))).Should(BeEquivalentTo(-3))
+
+		value, err = upsertBatch.GetDataValue(1, 2)
+		Ω(err).Should(BeNil())
+		reader = memCom.NewArrayValueReader(memCom.ArrayInt16, value.OtherVal)
+		Ω(reader.GetLength()).Should(Equal(2))
+		Ω(reader.IsItemValid(0)).Should(BeFalse())
+		Ω(*(*int16)(reader.Get(1))).Should(BeEquivalentTo(1))
+
+		value, err = upsertBatch.GetDataValue(2, 2)
+		Ω(err).Should(BeNil())
+		Ω(value.Valid).Should(BeFalse())
+	})
+
 	ginkgo.It("computeHLLValue should work", func() {
 		tests := [][]interface{}{
 			{memCom.UUID, []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}, uint32(329736)},
@@ -319,9 +533,13 @@ var _ = ginkgo.Describe("AresDB connector", func() {
 			dataType := test[0].(memCom.DataType)
 			input := test[1]
 			expected := test[2].(uint32)
-			out, err := computeHLLValue(dataType, input)
+			out, err := computeHLLValue(dataType, input, 14)
 			Ω(err).Should(BeNil())
 			Ω(out).Should(Equal(expected))
+
+			out, err = computeHLLValue(dataType, input, 8)
+			Ω(err).Should(BeNil())
+			Ω(out).Should(Equal(utils.ReduceHLLValuePrecision(expected, 8)))
 		}
 	})
 })
@@ -19,6 +19,7 @@ import (
 	mock "github.com/stretchr/testify/mock"
 	"github.com/uber/aresdb/client"
 	"github.com/uber/aresdb/memstore/common"
+	queryCom "github.com/uber/aresdb/query/common"
 )
 
 // Connector is an autogenerated mock type for the Connector type
@@ -47,5 +48,28 @@ func (_m *Connector) Insert(tableName string, columnNames []string, rows []clien
 	return r0, r1
 }
 
+// Query provides a mock function with given fields: sqls
+func (_m *Connector) Query(sqls ...string) ([]queryCom.AQLQueryResult, error) {
+	ret := _m.Called(sqls)
+
+	var r0 []queryCom.AQLQueryResult
+	if rf, ok := ret.Get(0).(func(...string) []queryCom.AQLQueryResult); ok {
+		r0 = rf(sqls...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]queryCom.AQLQueryResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(...string) error); ok {
+		r1 = rf(sqls...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Close the connection
 func (_m *Connector) Close() {}
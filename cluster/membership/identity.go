@@ -0,0 +1,81 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package membership
+
+import (
+	"os"
+
+	"github.com/uber/aresdb/common"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	defaultPodNameEnv      = "POD_NAME"
+	defaultPodNamespaceEnv = "POD_NAMESPACE"
+	defaultPodIPEnv        = "POD_IP"
+	defaultNodeNameEnv     = "NODE_NAME"
+)
+
+// PodIdentity is the identity of the pod current instance is running in,
+// read from env vars populated by kubernetes downward API.
+type PodIdentity struct {
+	Name      string
+	Namespace string
+	IP        string
+	NodeName  string
+}
+
+// NewPodIdentity reads pod identity from env vars, pod name is required
+// as it's used as the instance id.
+func NewPodIdentity(cfg common.MembershipConfig) (PodIdentity, error) {
+	identity := PodIdentity{
+		Name:      os.Getenv(envOrDefault(cfg.PodNameEnv, defaultPodNameEnv)),
+		Namespace: os.Getenv(envOrDefault(cfg.PodNamespaceEnv, defaultPodNamespaceEnv)),
+		IP:        os.Getenv(envOrDefault(cfg.PodIPEnv, defaultPodIPEnv)),
+		NodeName:  os.Getenv(envOrDefault(cfg.NodeNameEnv, defaultNodeNameEnv)),
+	}
+	if identity.Name == "" {
+		return identity, utils.StackError(nil, "pod name is not set in env %s",
+			envOrDefault(cfg.PodNameEnv, defaultPodNameEnv))
+	}
+	return identity, nil
+}
+
+// ResolveIdentity fills instance id and locality of cluster config from pod identity
+// when pod identity is enabled, otherwise the cluster config is returned as is.
+func ResolveIdentity(cfg common.ClusterConfig) (common.ClusterConfig, error) {
+	if !cfg.Membership.UsePodIdentity {
+		return cfg, nil
+	}
+
+	identity, err := NewPodIdentity(cfg.Membership)
+	if err != nil {
+		return cfg, err
+	}
+
+	// pod name is stable across restarts for statefulset pods
+	cfg.InstanceID = identity.Name
+	// pods on the same kubernetes node share the same failure domain
+	if cfg.Locality.Rack == "" {
+		cfg.Locality.Rack = identity.NodeName
+	}
+	return cfg, nil
+}
+
+func envOrDefault(env, defaultEnv string) string {
+	if env == "" {
+		return defaultEnv
+	}
+	return env
+}
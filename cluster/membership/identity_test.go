@@ -0,0 +1,62 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package membership
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber/aresdb/common"
+)
+
+func TestResolveIdentity(t *testing.T) {
+	cfg := common.ClusterConfig{
+		InstanceID: "static",
+	}
+
+	t.Run("pod identity disabled should keep config", func(t *testing.T) {
+		res, err := ResolveIdentity(cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, cfg, res)
+	})
+
+	t.Run("missing pod name should fail", func(t *testing.T) {
+		cfg.Membership.UsePodIdentity = true
+		cfg.Membership.PodNameEnv = "ARES_TEST_MISSING_POD_NAME"
+		_, err := ResolveIdentity(cfg)
+		assert.Error(t, err)
+	})
+
+	t.Run("pod identity should be used", func(t *testing.T) {
+		os.Setenv("ARES_TEST_POD_NAME", "ares-datanode-0")
+		os.Setenv("ARES_TEST_NODE_NAME", "node-1")
+		defer os.Unsetenv("ARES_TEST_POD_NAME")
+		defer os.Unsetenv("ARES_TEST_NODE_NAME")
+
+		cfg.Membership.UsePodIdentity = true
+		cfg.Membership.PodNameEnv = "ARES_TEST_POD_NAME"
+		cfg.Membership.NodeNameEnv = "ARES_TEST_NODE_NAME"
+		res, err := ResolveIdentity(cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, "ares-datanode-0", res.InstanceID)
+		assert.Equal(t, "node-1", res.Locality.Rack)
+
+		// configured rack should not be overwritten
+		cfg.Locality.Rack = "rack1"
+		res, err = ResolveIdentity(cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, "rack1", res.Locality.Rack)
+	})
+}
@@ -0,0 +1,99 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package membership
+
+import (
+	"sync"
+
+	"github.com/m3db/m3/src/cluster/placement"
+	"github.com/m3db/m3/src/cluster/services"
+	"github.com/uber/aresdb/utils"
+)
+
+// Membership manages whether current instance is registered in the cluster.
+type Membership interface {
+	// Register advertises current instance to the cluster.
+	Register() error
+	// Deregister stops advertising current instance, so that it will be
+	// considered unhealthy and no longer receive queries.
+	Deregister() error
+	// Registered tells whether current instance is registered.
+	Registered() bool
+}
+
+// serviceMembership registers instance through heartbeats of cluster services.
+type serviceMembership struct {
+	sync.Mutex
+
+	services   services.Services
+	serviceID  services.ServiceID
+	metadata   services.Metadata
+	instance   placement.Instance
+	registered bool
+}
+
+// NewServiceMembership creates a membership registering the placement instance
+// under the service.
+func NewServiceMembership(svcs services.Services, serviceID services.ServiceID,
+	metadata services.Metadata, instance placement.Instance) Membership {
+	return &serviceMembership{
+		services:  svcs,
+		serviceID: serviceID,
+		metadata:  metadata,
+		instance:  instance,
+	}
+}
+
+func (m *serviceMembership) Register() error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.registered {
+		return nil
+	}
+
+	if err := m.services.SetMetadata(m.serviceID, m.metadata); err != nil {
+		return utils.StackError(err, "failed to set heart beat metadata")
+	}
+
+	ad := services.NewAdvertisement().
+		SetServiceID(m.serviceID).
+		SetPlacementInstance(m.instance)
+	if err := m.services.Advertise(ad); err != nil {
+		return utils.StackError(err, "failed to advertise instance %s", m.instance.ID())
+	}
+	m.registered = true
+	return nil
+}
+
+func (m *serviceMembership) Deregister() error {
+	m.Lock()
+	defer m.Unlock()
+
+	if !m.registered {
+		return nil
+	}
+
+	if err := m.services.Unadvertise(m.serviceID, m.instance.ID()); err != nil {
+		return utils.StackError(err, "failed to unadvertise instance %s", m.instance.ID())
+	}
+	m.registered = false
+	return nil
+}
+
+func (m *serviceMembership) Registered() bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.registered
+}
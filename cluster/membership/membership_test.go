@@ -0,0 +1,53 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package membership
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/m3db/m3/src/cluster/placement"
+	"github.com/m3db/m3/src/cluster/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceMembership(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clusterServices := services.NewMockServices(ctrl)
+	serviceID := services.NewServiceID().SetName("ares-datanode-ns1").SetZone("local").SetEnvironment("test")
+	instance := placement.NewInstance().SetID("ares-datanode-0")
+	m := NewServiceMembership(clusterServices, serviceID, services.NewMetadata(), instance)
+
+	// deregister before register is noop
+	assert.NoError(t, m.Deregister())
+
+	clusterServices.EXPECT().SetMetadata(serviceID, gomock.Any()).Return(nil).Times(2)
+	clusterServices.EXPECT().Advertise(gomock.Any()).Return(errors.New("advertise failed")).Times(1)
+	assert.Error(t, m.Register())
+	assert.False(t, m.Registered())
+
+	clusterServices.EXPECT().Advertise(gomock.Any()).Return(nil).Times(1)
+	assert.NoError(t, m.Register())
+	assert.True(t, m.Registered())
+	// register twice is noop
+	assert.NoError(t, m.Register())
+
+	clusterServices.EXPECT().Unadvertise(serviceID, "ares-datanode-0").Return(nil).Times(1)
+	assert.NoError(t, m.Deregister())
+	assert.False(t, m.Registered())
+	assert.NoError(t, m.Deregister())
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package testutil
+
+import (
+	"sync"
+
+	"github.com/m3db/m3/src/cluster/shard"
+	xwatch "github.com/m3db/m3/src/x/watch"
+	"github.com/uber/aresdb/cluster/topology"
+)
+
+// FakeTopology is a topology.HealthTrackingDynamicTopoloy whose placement can be
+// changed while a test is running. Every change is published to watchers, and
+// hosts marked unhealthy are filtered out of the map returned by Get.
+type FakeTopology struct {
+	sync.RWMutex
+
+	watchable xwatch.Watchable
+	current   topology.Map
+	unhealthy map[string]struct{}
+	closed    bool
+}
+
+// NewFakeTopology creates a FakeTopology with given initial map.
+func NewFakeTopology(m topology.Map) *FakeTopology {
+	t := &FakeTopology{
+		watchable: xwatch.NewWatchable(),
+		current:   m,
+		unhealthy: make(map[string]struct{}),
+	}
+	t.watchable.Update(m)
+	return t
+}
+
+// NewFakeTopologyFromAssignment creates a FakeTopology from a shard assignment.
+// It's a utility method to make tests easier to write.
+func NewFakeTopologyFromAssignment(replicas int, assignment map[string][]shard.Shard) *FakeTopology {
+	return NewFakeTopology(MustNewTopologyMap(replicas, assignment))
+}
+
+// SetMap replaces the placement map and notifies watchers.
+func (t *FakeTopology) SetMap(m topology.Map) {
+	t.Lock()
+	defer t.Unlock()
+	t.current = m
+	t.watchable.Update(m)
+}
+
+// SetAssignment replaces the placement map with one built from the shard assignment.
+func (t *FakeTopology) SetAssignment(replicas int, assignment map[string][]shard.Shard) {
+	t.SetMap(MustNewTopologyMap(replicas, assignment))
+}
+
+// Get returns the current map with unhealthy hosts filtered out.
+func (t *FakeTopology) Get() topology.Map {
+	t.RLock()
+	defer t.RUnlock()
+	if len(t.unhealthy) == 0 {
+		return t.current
+	}
+
+	var hostShardSets []topology.HostShardSet
+	for _, hss := range t.current.HostShardSets() {
+		if _, ok := t.unhealthy[hss.Host().ID()]; !ok {
+			hostShardSets = append(hostShardSets, hss)
+		}
+	}
+	opts := topology.NewStaticOptions().
+		SetReplicas(t.current.Replicas()).
+		SetShardSet(t.current.ShardSet()).
+		SetHostShardSets(hostShardSets)
+	return topology.NewStaticMap(opts)
+}
+
+// Watch returns a watch which is notified on every SetMap call.
+func (t *FakeTopology) Watch() (topology.MapWatch, error) {
+	_, w, err := t.watchable.Watch()
+	if err != nil {
+		return nil, err
+	}
+	return topology.NewMapWatch(w), nil
+}
+
+// Close closes all watches.
+func (t *FakeTopology) Close() {
+	t.Lock()
+	defer t.Unlock()
+	if t.closed {
+		return
+	}
+	t.closed = true
+	t.watchable.Close()
+}
+
+// MarkHostHealthy adds the host back to the view returned by Get.
+func (t *FakeTopology) MarkHostHealthy(host topology.Host) error {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.unhealthy, host.ID())
+	return nil
+}
+
+// MarkHostUnhealthy removes the host from the view returned by Get.
+func (t *FakeTopology) MarkHostUnhealthy(host topology.Host) error {
+	t.Lock()
+	defer t.Unlock()
+	t.unhealthy[host.ID()] = struct{}{}
+	return nil
+}
+
+// MarkShardsAvailable is a no-op so that FakeTopology can also stand in for a
+// topology.DynamicTopology.
+func (t *FakeTopology) MarkShardsAvailable(instanceID string, shardIDs ...uint32) error {
+	return nil
+}
@@ -15,7 +15,6 @@
 package cmd
 
 import (
-	"fmt"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/m3db/m3/src/cluster/services"
@@ -23,10 +22,15 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/uber-go/tally"
 	"github.com/uber/aresdb/api"
+	"github.com/uber/aresdb/backup"
+	"github.com/uber/aresdb/cdc"
 	"github.com/uber/aresdb/cgoutils"
+	"github.com/uber/aresdb/cluster/kvstore"
+	"github.com/uber/aresdb/cluster/membership"
 	"github.com/uber/aresdb/cluster/topology"
 	"github.com/uber/aresdb/common"
 	controllerCli "github.com/uber/aresdb/controller/client"
+	etcdCli "github.com/uber/aresdb/controller/client/etcd"
 	"github.com/uber/aresdb/controller/mutators/etcd"
 	"github.com/uber/aresdb/datanode"
 	"github.com/uber/aresdb/datanode/bootstrap"
@@ -35,11 +39,15 @@ import (
 	memCom "github.com/uber/aresdb/memstore/common"
 	"github.com/uber/aresdb/metastore"
 	"github.com/uber/aresdb/redolog"
+	"github.com/uber/aresdb/replication"
 	"github.com/uber/aresdb/utils"
 	"go.uber.org/zap"
 	"net/http"
 	"net/http/pprof"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 	"unsafe"
 )
@@ -114,6 +122,32 @@ func start(cfg common.AresServerConfig, logger common.Logger, queryLogger common
 	// Init common components.
 	utils.Init(cfg, logger, queryLogger, scope)
 
+	shutdownTracing, err := utils.InitTracing(utils.AresDataNode, cfg.Tracing)
+	if err != nil {
+		logger.Fatal("Failed to init tracing", err)
+	}
+	defer shutdownTracing()
+
+	shutdownTLS, err := utils.InitTLS(cfg.TLS)
+	if err != nil {
+		logger.Fatal("Failed to init tls", err)
+	}
+	defer shutdownTLS()
+
+	shutdownAuditLog, err := utils.InitAuditLog(cfg.AuditLog)
+	if err != nil {
+		logger.Fatal("Failed to init audit log", err)
+	}
+	defer shutdownAuditLog()
+
+	authenticator, err := utils.NewAuthenticator(cfg.Auth)
+	if err != nil {
+		logger.Fatal("Failed to init authentication", err)
+	}
+	if authenticator != nil {
+		httpWrappers = append(httpWrappers, utils.WithAuthentication(authenticator))
+	}
+
 	scope.Counter("restart").Inc(1)
 
 	if cfg.Cluster.Distributed {
@@ -133,23 +167,45 @@ func start(cfg common.AresServerConfig, logger common.Logger, queryLogger common
 	}
 
 	// Create DiskStore.
-	diskStore := diskstore.NewLocalDiskStore(cfg.RootPath)
+	diskStore, err := diskstore.WithEncryption(diskstore.NewLocalDiskStore(cfg.RootPath),
+		cfg.DiskStore.Encryption, metastore.ColumnEncryptionChecker(metaStore))
+	if err != nil {
+		logger.Fatal("Failed to init disk store encryption", err)
+	}
+	diskStore = diskstore.WithCompression(diskStore, cfg.DiskStore.Compression,
+		metastore.ColumnCompressionLevel(metaStore, cfg.DiskStore.Compression.Enable))
 
-	// fetch schema from controller and start periodical job
+	// fetch schema from controller or etcd and start periodical job
 	if cfg.Cluster.Enable {
 		if cfg.Cluster.Namespace == "" {
 			logger.Fatal("Missing namespace")
 		}
-		controllerClientCfg := cfg.Cluster.Controller
-		if controllerClientCfg == nil {
-			logger.Fatal("Missing controller client config", err)
-		}
-		if cfg.Cluster.InstanceID != "" {
-			controllerClientCfg.Headers.Add(controllerCli.InstanceNameHeaderKey, cfg.Cluster.InstanceID)
+
+		var (
+			controllerClient controllerCli.ControllerClient
+			schemaWatch      <-chan struct{}
+		)
+		if cfg.Cluster.UseEtcdBackend() {
+			etcdClient := kvstore.NewEtcdClient(zap.NewExample().Sugar(), kvstore.EtcdConfig{Configuration: cfg.Cluster.Etcd, Enabled: true})
+			etcdControllerClient := etcdCli.NewControllerClient(etcdClient, cfg.Cluster.InstanceID, zap.NewExample().Sugar())
+			schemaWatch, err = etcdControllerClient.WatchSchema(cfg.Cluster.Namespace, nil)
+			if err != nil {
+				logger.With("error", err.Error()).Error("failed to watch schema, only fetch schema periodically")
+			}
+			controllerClient = etcdControllerClient
+		} else {
+			controllerClientCfg := cfg.Cluster.Controller
+			if controllerClientCfg == nil {
+				logger.Fatal("Missing controller client config", err)
+			}
+			if cfg.Cluster.InstanceID != "" {
+				controllerClientCfg.Headers.Add(controllerCli.InstanceNameHeaderKey, cfg.Cluster.InstanceID)
+			}
+			controllerClient = controllerCli.NewControllerHTTPClient(controllerClientCfg.Address, time.Duration(controllerClientCfg.TimeoutSec)*time.Second, controllerClientCfg.Headers)
 		}
 
-		controllerClient := controllerCli.NewControllerHTTPClient(controllerClientCfg.Address, time.Duration(controllerClientCfg.TimeoutSec)*time.Second, controllerClientCfg.Headers)
 		schemaFetchJob := metastore.NewSchemaFetchJob(30, metaStore, nil, metastore.NewTableSchameValidator(), controllerClient, nil, cfg.Cluster.Namespace, "")
+		schemaFetchJob.SetSchemaWatch(schemaWatch)
 		// immediate initial fetch
 		schemaFetchJob.FetchSchema()
 		go schemaFetchJob.Run()
@@ -162,9 +218,29 @@ func start(cfg common.AresServerConfig, logger common.Logger, queryLogger common
 	if err != nil {
 		utils.GetLogger().Fatal(err)
 	}
+	if cfg.RedoLogConfig.ObjectStore.Enable {
+		redoLogObjectStore, err := backup.NewObjectStore(cfg.RedoLogConfig.ObjectStore)
+		if err != nil {
+			logger.Fatal("Failed to init redolog object store", err)
+		}
+		redoLogManagerMaster.SetLogStore(redolog.NewObjectLogStore(redoLogObjectStore, cfg.RedoLogConfig.ObjectStore.Prefix))
+	}
+
+	memStoreOptions := []memstore.Option{memstore.WithReplayConcurrency(cfg.RedoLogConfig.ReplayConcurrency)}
+	cdcPublisher, err := cdc.NewPublisher(cfg.CDC)
+	if err != nil {
+		logger.Fatal("Failed to init change data capture", err)
+	}
+	if cdcPublisher != nil {
+		memStoreOptions = append(memStoreOptions, memstore.WithChangePublisher(cdcPublisher))
+	}
+	if throttleCfg := cfg.IngestionThrottle; throttleCfg.Enable {
+		memStoreOptions = append(memStoreOptions, memstore.WithIngestionThrottle(throttleCfg.MemoryUsageRatio,
+			throttleCfg.MaxRedoLogSizeMB*1024*1024, time.Duration(throttleCfg.RetryAfterSeconds)*time.Second))
+	}
 
 	// Create MemStore.
-	memStore := memstore.NewMemStore(metaStore, diskStore, memstore.NewOptions(bootstrapToken, redoLogManagerMaster))
+	memStore := memstore.NewMemStore(metaStore, diskStore, memstore.NewOptions(bootstrapToken, redoLogManagerMaster, memStoreOptions...))
 
 	// Read schema.
 	utils.GetLogger().Infof("Reading schema from local MetaStore %s", metaStorePath)
@@ -182,21 +258,23 @@ func start(cfg common.AresServerConfig, logger common.Logger, queryLogger common
 	// create query hanlder.
 	// static shard owner with non distributed version
 	staticShardOwner := topology.NewStaticShardOwner([]int{0})
-	queryHandler := api.NewQueryHandler(memStore, staticShardOwner, cfg.Query)
+	queryHandler := api.NewQueryHandler(memStore, metaStore, staticShardOwner, cfg.Query)
 
 	// create health check handler.
 	healthCheckHandler := api.NewHealthCheckHandler()
 
 	nodeModulesHandler := http.StripPrefix("/node_modules/", http.FileServer(http.Dir("./api/ui/node_modules/")))
 
-	// Start HTTP server for debugging.
+	// Start HTTP server for debugging, admin debug handlers are authenticated with the same
+	// wrappers as the service port.
+	debugWrappers := httpWrappers
 	go func() {
 		debugHandler := api.NewDebugHandler(cfg.Cluster.Namespace, memStore, metaStore, queryHandler, healthCheckHandler, staticShardOwner, nil)
 
 		debugStaticHandler := http.StripPrefix("/static/", utils.NoCache(
 			http.FileServer(http.Dir("./api/ui/debug/"))))
 		debugRouter := mux.NewRouter()
-		debugHandler.Register(debugRouter.PathPrefix("/dbg").Subrouter())
+		debugHandler.Register(debugRouter.PathPrefix("/dbg").Subrouter(), debugWrappers...)
 		schemaHandler.RegisterForDebug(debugRouter.PathPrefix("/schema").Subrouter())
 
 		debugRouter.PathPrefix("/node_modules/").Handler(nodeModulesHandler)
@@ -208,7 +286,7 @@ func start(cfg common.AresServerConfig, logger common.Logger, queryLogger common
 		debugRouter.PathPrefix("/debug/pprof/").Handler(http.HandlerFunc(pprof.Index))
 
 		utils.GetLogger().Infof("Starting HTTP server on dbg-port %d", cfg.DebugPort)
-		utils.GetLogger().Fatal(http.ListenAndServe(fmt.Sprintf(":%d", cfg.DebugPort), debugRouter))
+		utils.GetLogger().Fatal(utils.ListenAndServe(cfg.DebugPort, debugRouter))
 	}()
 
 	// Init shards.
@@ -217,6 +295,15 @@ func start(cfg common.AresServerConfig, logger common.Logger, queryLogger common
 
 	// Start serving.
 	dataHandler := api.NewDataHandler(memStore)
+	var backupManager *backup.Manager
+	if cfg.Backup.Enable {
+		backupManager, err = backup.NewManager(cfg.Backup, metaStore, diskStore, memStore)
+		if err != nil {
+			logger.Fatal("Failed to init backup manager", err)
+		}
+	}
+	jobHandler := api.NewJobHandler(memStore, backupManager)
+	statusHandler := api.NewStatusHandler(memStore, staticShardOwner, queryHandler, healthCheckHandler)
 	router := mux.NewRouter()
 
 	httpWrappers = append([]utils.HTTPHandlerWrapper{utils.WithMetricsFunc}, httpWrappers...)
@@ -229,6 +316,8 @@ func start(cfg common.AresServerConfig, logger common.Logger, queryLogger common
 	enumHandler.Register(router.PathPrefix("/schema").Subrouter(), httpWrappers...)
 	dataHandler.Register(router.PathPrefix("/data").Subrouter(), httpWrappers...)
 	queryHandler.Register(router.PathPrefix("/query").Subrouter(), httpWrappers...)
+	jobHandler.Register(router, httpWrappers...)
+	statusHandler.Register(router, httpWrappers...)
 
 	swaggerHandler := http.StripPrefix("/swagger/", http.FileServer(http.Dir("./api/ui/swagger/")))
 	router.PathPrefix("/swagger/").Handler(swaggerHandler)
@@ -238,7 +327,7 @@ func start(cfg common.AresServerConfig, logger common.Logger, queryLogger common
 
 	// Support CORS calls.
 	allowOrigins := handlers.AllowedOrigins([]string{"*"})
-	allowHeaders := handlers.AllowedHeaders([]string{"Accept", "Accept-Language", "Content-Language", "Origin", "Content-Type"})
+	allowHeaders := handlers.AllowedHeaders([]string{"Accept", "Accept-Language", "Content-Language", "Origin", "Content-Type", "Authorization"})
 	allowMethods := handlers.AllowedMethods([]string{"GET", "PUT", "POST", "DELETE", "OPTIONS"})
 
 	serverRestartTimer.Stop()
@@ -246,15 +335,36 @@ func start(cfg common.AresServerConfig, logger common.Logger, queryLogger common
 	batchStatsReporter := memstore.NewBatchStatsReporter(5*60, memStore, topology.NewStaticShardOwner([]int{0}))
 	go batchStatsReporter.Run()
 
+	var replicationAgent *replication.Agent
+	if cfg.Replication.Enable {
+		replicationAgent, err = replication.NewAgent(cfg.Replication, cfg.RootPath, diskStore, memStore, staticShardOwner)
+		if err != nil {
+			logger.Fatal("Failed to init replication agent", err)
+		}
+		go replicationAgent.Run()
+	}
+
 	utils.GetLogger().Infof("Starting HTTP server on port %d with max connection %d", cfg.Port, cfg.HTTP.MaxConnections)
 	utils.LimitServe(cfg.Port, handlers.CORS(allowOrigins, allowHeaders, allowMethods)(router), cfg.HTTP)
 	batchStatsReporter.Stop()
+	if replicationAgent != nil {
+		replicationAgent.Stop()
+	}
 	redoLogManagerMaster.Stop()
+	if cdcPublisher != nil {
+		cdcPublisher.Close()
+	}
 }
 
 // start datanode in distributed mode
 func startDataNode(cfg common.AresServerConfig, logger common.Logger, scope tally.Scope, httpWrappers ...utils.HTTPHandlerWrapper) {
-	opts := datanode.NewOptions().SetServerConfig(cfg).SetInstrumentOptions(utils.NewOptions()).SetBootstrapOptions(bootstrap.NewOptions()).SetHTTPWrappers(httpWrappers)
+	var err error
+	cfg.Cluster, err = membership.ResolveIdentity(cfg.Cluster)
+	if err != nil {
+		logger.With("error", err.Error()).Fatal("failed to resolve pod identity")
+	}
+
+	opts := datanode.NewOptions().SetServerConfig(cfg).SetInstrumentOptions(utils.NewOptions()).SetBootstrapOptions(bootstrap.NewOptions().SetTransferCompressionLevel(cfg.DiskStore.Compression.TransferLevel)).SetHTTPWrappers(httpWrappers)
 
 	var topo topology.Topology
 	etcdCfg := cfg.Cluster.Etcd
@@ -294,6 +404,16 @@ func startDataNode(cfg common.AresServerConfig, logger common.Logger, scope tall
 	if err != nil {
 		logger.Fatal("Failed to open datanode,", err)
 	}
+
+	// deregister from cluster before exiting on termination
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		sig := <-signals
+		logger.With("signal", sig.String()).Info("shutting down datanode")
+		dataNode.Close()
+		os.Exit(0)
+	}()
 	// start serving traffic
 	dataNode.Serve()
 }
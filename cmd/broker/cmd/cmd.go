@@ -27,10 +27,12 @@ import (
 	"github.com/spf13/viper"
 	"github.com/uber/aresdb/broker"
 	"github.com/uber/aresdb/broker/config"
+	"github.com/uber/aresdb/cluster/kvstore"
 	"github.com/uber/aresdb/cluster/topology"
 	"github.com/uber/aresdb/cmd/aresd/cmd"
 	"github.com/uber/aresdb/common"
 	"github.com/uber/aresdb/controller/client"
+	etcdCli "github.com/uber/aresdb/controller/client/etcd"
 	controllerEtcd "github.com/uber/aresdb/controller/mutators/etcd"
 	dataNodeCli "github.com/uber/aresdb/datanode/client"
 	"github.com/uber/aresdb/metastore"
@@ -93,11 +95,6 @@ func start(cfg config.BrokerConfig, logger common.Logger, queryLogger common.Log
 	defer serverRestartTimer.Stop()
 
 	// fetch and keep syncing schema
-	controllerClientCfg := cfg.Cluster.Controller
-	if controllerClientCfg == nil {
-		logger.Fatal("Missing controller client config", err)
-	}
-
 	var (
 		topo        topology.HealthTrackingDynamicTopoloy
 		clusterName = cfg.Cluster.Namespace
@@ -105,6 +102,26 @@ func start(cfg config.BrokerConfig, logger common.Logger, queryLogger common.Log
 		store       kv.TxnStore
 	)
 
+	shutdownTracing, err := utils.InitTracing(utils.AresBroker, cfg.Tracing)
+	if err != nil {
+		logger.Fatal("Failed to init tracing", err)
+	}
+	defer shutdownTracing()
+
+	shutdownTLS, err := utils.InitTLS(cfg.TLS)
+	if err != nil {
+		logger.Fatal("Failed to init tls", err)
+	}
+	defer shutdownTLS()
+
+	authenticator, err := utils.NewAuthenticator(cfg.Auth)
+	if err != nil {
+		logger.Fatal("Failed to init authentication", err)
+	}
+	if authenticator != nil {
+		httpWrappers = append(httpWrappers, utils.WithAuthentication(authenticator))
+	}
+
 	cfg.Cluster.Etcd.Service = serviceName
 	configServiceCli, err := cfg.Cluster.Etcd.NewClient(
 		instrument.NewOptions().SetLogger(zap.NewExample()))
@@ -112,33 +129,59 @@ func start(cfg config.BrokerConfig, logger common.Logger, queryLogger common.Log
 		logger.Fatal("Failed to create config service client,", err)
 	}
 
-	controllerClient := client.NewControllerHTTPClient(
-		controllerClientCfg.Address,
-		time.Duration(controllerClientCfg.TimeoutSec)*time.Second,
-		controllerClientCfg.Headers,
-	)
-	brokerSchemaMutator := broker.NewBrokerSchemaMutator()
-
 	store, err = configServiceCli.Txn()
 	if err != nil {
 		logger.Fatal("Failed to get kv store")
 	}
 
+	var (
+		controllerClient client.ControllerClient
+		schemaWatch      <-chan struct{}
+	)
+	if cfg.Cluster.UseEtcdBackend() {
+		clusterServices, err := configServiceCli.Services(nil)
+		if err != nil {
+			logger.Fatal("Failed to create cluster services client,", err)
+		}
+		etcdControllerClient := etcdCli.NewControllerClient(&kvstore.EtcdClient{
+			Zone:          cfg.Cluster.Etcd.Zone,
+			Environment:   cfg.Cluster.Etcd.Env,
+			ServiceName:   serviceName,
+			ClusterClient: configServiceCli,
+			TxnStore:      store,
+			Services:      clusterServices,
+		}, cfg.Cluster.InstanceID, zap.NewExample().Sugar())
+		schemaWatch, err = etcdControllerClient.WatchSchema(clusterName, nil)
+		if err != nil {
+			logger.With("error", err.Error()).Error("failed to watch schema, only fetch schema periodically")
+		}
+		controllerClient = etcdControllerClient
+	} else {
+		controllerClientCfg := cfg.Cluster.Controller
+		if controllerClientCfg == nil {
+			logger.Fatal("Missing controller client config", err)
+		}
+		controllerClient = client.NewControllerHTTPClient(
+			controllerClientCfg.Address,
+			time.Duration(controllerClientCfg.TimeoutSec)*time.Second,
+			controllerClientCfg.Headers,
+		)
+	}
+	brokerSchemaMutator := broker.NewBrokerSchemaMutator()
+	tableSchemaMutator := controllerEtcd.NewTableSchemaMutator(store, zap.NewExample().Sugar())
+	enumMutator := controllerEtcd.NewEnumMutator(store, tableSchemaMutator)
+
 	schemaFetchJob := metastore.NewSchemaFetchJob(
 		10,
 		brokerSchemaMutator,
 		brokerSchemaMutator,
 		metastore.NewTableSchameValidator(),
 		controllerClient,
-		controllerEtcd.NewEnumMutator(
-			store, controllerEtcd.NewTableSchemaMutator(
-				store,
-				zap.NewExample().Sugar(),
-			),
-		),
+		enumMutator,
 		clusterName,
 		"",
 	)
+	schemaFetchJob.SetSchemaWatch(schemaWatch)
 	schemaFetchJob.FetchSchema()
 	schemaFetchJob.FetchEnum()
 	go schemaFetchJob.Run()
@@ -150,19 +193,56 @@ func start(cfg config.BrokerConfig, logger common.Logger, queryLogger common.Log
 	}
 
 	// executor
-	exec := broker.NewQueryExecutor(brokerSchemaMutator, topo, dataNodeCli.NewDataNodeQueryClient())
+	enumDictRefresher := broker.NewEnumDictRefresher(clusterName, enumMutator, brokerSchemaMutator, brokerSchemaMutator)
+	quotaManager := broker.NewQuotaManager(cfg.Query.Quota)
+	exec := broker.NewQueryExecutor(brokerSchemaMutator, topo, dataNodeCli.NewDataNodeQueryClient(), cfg.Query, enumDictRefresher, quotaManager)
+
+	// slow query log
+	slowQueryLogger, err := broker.NewSlowQueryLogger(cfg.SlowQueryLog)
+	if err != nil {
+		logger.Fatal("Failed to create slow query logger,", err)
+	}
+	defer slowQueryLogger.Close()
 
 	// init handlers
-	queryHandler := broker.NewQueryHandler(exec, cfg.Cluster.InstanceID)
+	tempTableHandler := broker.NewTempTableHandler(exec, cfg.TempTable)
+	queryBlockHandler := broker.NewQueryBlockHandler(topo, dataNodeCli.NewDataNodeQueryBlockClient())
+	admissionController := broker.NewAdmissionController(cfg.Query.AdmissionControl)
+	admissionController.Start()
+	defer admissionController.Stop()
+	queryHandler := broker.NewQueryHandler(exec, cfg.Cluster.InstanceID, slowQueryLogger, tempTableHandler, queryBlockHandler.GetQueryBlocklist(), admissionController,
+		time.Duration(cfg.Query.IdempotentQueryTimeoutMillis)*time.Millisecond)
+	simulationHandler := broker.NewSimulationHandler(brokerSchemaMutator, topo, cfg.Query)
+	clusterStatusHandler := broker.NewClusterStatusHandler(topo, dataNodeCli.NewDataNodeStatusClient())
+	columnValuesHandler := broker.NewColumnValuesHandler(brokerSchemaMutator, topo, dataNodeCli.NewDataNodeColumnValuesClient())
+	ctasHandler := broker.NewCTASHandler(exec, clusterName, tableSchemaMutator, enumMutator, topo,
+		dataNodeCli.NewDataNodeIngestionClient(), cfg.CTAS, zap.NewExample().Sugar())
+
+	resultSigner, err := utils.NewResultSigner(cfg.ResultSigning)
+	if err != nil {
+		logger.Fatal("Failed to init result signing", err)
+	}
 
 	// start HTTP server
 	router := mux.NewRouter()
 	httpWrappers = append([]utils.HTTPHandlerWrapper{utils.WithMetricsFunc}, httpWrappers...)
-	queryHandler.Register(router.PathPrefix("/query").Subrouter(), httpWrappers...)
+	queryWrappers := httpWrappers
+	if resultSigner != nil {
+		// innermost so that the body written by query handlers is signed as is
+		queryWrappers = append([]utils.HTTPHandlerWrapper{utils.WithResultSigning(resultSigner)}, httpWrappers...)
+	}
+	queryHandler.Register(router.PathPrefix("/query").Subrouter(), queryWrappers...)
+	queryBlockHandler.Register(router.PathPrefix("/query").Subrouter(), httpWrappers...)
+	simulationHandler.Register(router.PathPrefix("/query").Subrouter(), httpWrappers...)
+	columnValuesHandler.Register(router.PathPrefix("/query").Subrouter(), httpWrappers...)
+	quotaManager.Register(router.PathPrefix("/query").Subrouter(), httpWrappers...)
+	clusterStatusHandler.Register(router.PathPrefix("/cluster").Subrouter(), httpWrappers...)
+	ctasHandler.Register(router, httpWrappers...)
+	tempTableHandler.Register(router, httpWrappers...)
 
 	// Support CORS calls.
 	allowOrigins := handlers.AllowedOrigins([]string{"*"})
-	allowHeaders := handlers.AllowedHeaders([]string{"Accept", "Accept-Language", "Content-Language", "Origin", "Content-Type"})
+	allowHeaders := handlers.AllowedHeaders([]string{"Accept", "Accept-Language", "Content-Language", "Origin", "Content-Type", "Authorization", broker.TempTableSessionHeaderKey})
 	allowMethods := handlers.AllowedMethods([]string{"GET", "PUT", "POST", "DELETE", "OPTIONS"})
 
 	utils.GetLogger().Infof("Starting HTTP server on port %d with max connection %d", cfg.Port, cfg.HTTP.MaxConnections)
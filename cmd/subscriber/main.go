@@ -10,6 +10,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/uber-go/tally"
+	"github.com/uber/aresdb/common"
 	"github.com/uber/aresdb/subscriber/common/job"
 	"github.com/uber/aresdb/subscriber/common/message"
 	"github.com/uber/aresdb/subscriber/common/rules"
@@ -119,6 +120,41 @@ func newDefaultLogger(params Params, env utils.EnvironmentContext) *zap.Logger {
 	return logger
 }
 
+func initTracing(params Params, provider cfgfx.Provider, logger *zap.Logger) {
+	var tracingCfg common.TracingConfig
+	if err := provider.Get("tracing").Populate(&tracingCfg); err != nil {
+		panic(utils.StackError(err, "Failed to load tracing config"))
+	}
+	shutdownTracing, err := utils.InitTracing(utils.AresSubscriber, tracingCfg)
+	if err != nil {
+		logger.Error("Failed to init tracing", zap.Error(err))
+		return
+	}
+	params.Lifecycle.Append(fx.Hook{
+		OnStop: func(context.Context) error {
+			shutdownTracing()
+			return nil
+		},
+	})
+}
+
+func initTLS(params Params, provider cfgfx.Provider, logger *zap.Logger) {
+	var tlsCfg common.TLSConfig
+	if err := provider.Get("tls").Populate(&tlsCfg); err != nil {
+		panic(utils.StackError(err, "Failed to load tls config"))
+	}
+	shutdownTLS, err := utils.InitTLS(tlsCfg)
+	if err != nil {
+		panic(utils.StackError(err, "Failed to init tls"))
+	}
+	params.Lifecycle.Append(fx.Hook{
+		OnStop: func(context.Context) error {
+			shutdownTLS()
+			return nil
+		},
+	})
+}
+
 func newDefaultScope() tally.Scope {
 	return tally.NewTestScope("test", nil)
 }
@@ -128,6 +164,8 @@ func Init(params Params) Result {
 	provider := newDefaultConfig()
 	logger := newDefaultLogger(params, env)
 	scope := newDefaultScope()
+	initTracing(params, provider, logger)
+	initTLS(params, provider, logger)
 
 	return Result{
 		Environment: env,
@@ -33,11 +33,90 @@ type QueryConfig struct {
 	DeviceChoosingTimeout int            `yaml:"device_choosing_timeout"`
 	TimezoneTable         TimezoneConfig `yaml:"timezone_table"`
 	EnableHashReduction   bool           `yaml:"enable_hash_reduction"`
+	// max number of distinct values of any dimension of an aggregation query before it
+	// is aborted, 0 means no limit
+	MaxDimensionCardinality int `yaml:"max_dimension_cardinality"`
+	// max number of devices a single aggregation query can be split across by archive
+	// batch range, 0 or 1 means queries are processed on one device
+	MaxDevicesPerQuery int `yaml:"max_devices_per_query"`
+	// keep archive batch columns transferred by queries in device memory
+	DeviceColumnCache DeviceColumnCacheConfig `yaml:"device_column_cache"`
+	// load archive batches adjacent to recently queried time ranges into host memory
+	ArchivePrefetch ArchivePrefetchConfig `yaml:"archive_prefetch"`
+	// weights of queries by origin when queries are queued waiting for device, multiplied by
+	// the query weight of the main table, origins not listed get weight 1
+	OriginQueryWeights map[string]int `yaml:"origin_query_weights"`
+}
+
+// ArchivePrefetchConfig is the configuration for prefetching archive batches next to the
+// time range queried recently on each fact table shard, so that queries scrolling back (or
+// forward) in time find the batches already loaded.
+type ArchivePrefetchConfig struct {
+	Enable bool `yaml:"enable"`
+	// number of adjacent archive batches (days) to prefetch, default to 1
+	NumBatches int `yaml:"num_batches"`
+}
+
+// DeviceColumnCacheConfig is the configuration for caching archive batch columns in device
+// memory. Cached columns share device memory with query reservations and are evicted whenever
+// queries need the memory.
+type DeviceColumnCacheConfig struct {
+	Enable bool `yaml:"enable"`
+	// max portion of usable device memory cached columns can take
+	MemoryUtilization float32 `yaml:"memory_utilization"`
+}
+
+// QueryQuota is the daily limit of query usage, 0 means no limit.
+type QueryQuota struct {
+	Queries      int64   `yaml:"queries"`
+	ScannedBytes int64   `yaml:"scanned_bytes"`
+	GPUSeconds   float64 `yaml:"gpu_seconds"`
+}
+
+// QueryQuotaConfig is the configuration for query quota enforcement by broker. Usage is always
+// accounted, quotas are only enforced when enabled.
+type QueryQuotaConfig struct {
+	Enable bool `yaml:"enable"`
+	// quota for callers not configured in Callers
+	DefaultCaller QueryQuota            `yaml:"default_caller"`
+	Callers       map[string]QueryQuota `yaml:"callers"`
+	// quota for tables not configured in Tables
+	DefaultTable QueryQuota            `yaml:"default_table"`
+	Tables       map[string]QueryQuota `yaml:"tables"`
 }
 
 // DiskStoreConfig is the static configuration for disk store.
 type DiskStoreConfig struct {
-	WriteSync bool `yaml:"write_sync"`
+	WriteSync   bool              `yaml:"write_sync"`
+	Encryption  EncryptionConfig  `yaml:"encryption"`
+	Compression CompressionConfig `yaml:"compression"`
+	// directory of files backing archive batch columns spilled for exceeding the table memory
+	// budget, defaults to spill under root_path.
+	SpillPath string `yaml:"spill_path"`
+}
+
+// KMS types supported for column encryption.
+const (
+	KMSLocal = "local"
+)
+
+// EncryptionConfig is the configuration for encrypting vector party files of encrypted columns.
+type EncryptionConfig struct {
+	Enable bool `yaml:"enable"`
+	// kms wrapping data keys of encrypted files, only local is supported now
+	KMS string `yaml:"kms"`
+	// file of the hex encoded 256 bit master key for local kms
+	MasterKeyFile string `yaml:"master_key_file"`
+}
+
+// CompressionConfig is the configuration for compressing vector party files with zstd.
+type CompressionConfig struct {
+	Enable bool `yaml:"enable"`
+	// zstd level for columns without compression level configured, 3 if not set
+	Level int `yaml:"level"`
+	// zstd level requested to compress vector party data copied from peers during bootstrap,
+	// 0 to copy uncompressed
+	TransferLevel int `yaml:"transfer_level"`
 }
 
 // HTTPConfig is the static configuration for main http server (query and schema).
@@ -62,6 +141,46 @@ type HeartbeatConfig struct {
 	Interval int `yaml:"interval"`
 }
 
+const (
+	// CoordinationBackendController fetches schema through ares-controller
+	CoordinationBackendController = "controller"
+	// CoordinationBackendEtcd reads schema from etcd directly without ares-controller
+	CoordinationBackendEtcd = "etcd"
+)
+
+// LocalityConfig is the config for locality labels of current instance,
+// used by controller to place replicas of the same shard into different zones/racks
+type LocalityConfig struct {
+	Zone string `yaml:"zone"`
+	Rack string `yaml:"rack"`
+}
+
+// StandbyConfig is the config for running a datanode as warm standby of another datanode. The
+// standby copies data of the shards owned by the primary from peers without ingesting, and takes
+// over the shards of the primary in the placement once the primary stops heartbeating.
+type StandbyConfig struct {
+	Enable bool `yaml:"enable"`
+	// Primary is the instance id of the datanode to take over.
+	Primary string `yaml:"primary"`
+	// FailoverSec is how long the primary is not heartbeating before being taken over, default 10.
+	FailoverSec int `yaml:"failover_sec"`
+}
+
+// MembershipConfig is the config for running datanodes under kubernetes, where the
+// identity of current instance is exposed through downward API env vars.
+type MembershipConfig struct {
+	// UsePodIdentity controls whether to use the pod name as instance id
+	// and the node name as rack if not configured.
+	UsePodIdentity bool `yaml:"use_pod_identity"`
+	// env vars set by downward API, default to POD_NAME, POD_NAMESPACE, POD_IP and NODE_NAME
+	PodNameEnv      string `yaml:"pod_name_env"`
+	PodNamespaceEnv string `yaml:"pod_namespace_env"`
+	PodIPEnv        string `yaml:"pod_ip_env"`
+	NodeNameEnv     string `yaml:"node_name_env"`
+	// DrainSec is how long to keep serving in flight queries after deregistered on SIGTERM
+	DrainSec int `yaml:"drain_sec"`
+}
+
 // ClusterConfig is the config for starting current instance with cluster mode
 type ClusterConfig struct {
 	// Enable controls whether to start in cluster mode
@@ -77,7 +196,12 @@ type ClusterConfig struct {
 	// it can be static configured in yaml, or dynamically set on start up
 	InstanceID string `yaml:"instance_id"`
 
-	// controller config
+	// CoordinationBackend selects how schema is coordinated across the cluster,
+	// either controller (default) or etcd. Membership and shard assignment are
+	// always stored in etcd.
+	CoordinationBackend string `yaml:"coordination_backend"`
+
+	// controller config, not required when using etcd coordination backend
 	Controller *ControllerConfig `yaml:"controller,omitempty"`
 
 	// etcd client required config
@@ -85,12 +209,33 @@ type ClusterConfig struct {
 
 	// heartbeat config
 	HeartbeatConfig HeartbeatConfig `yaml:"heartbeat"`
+
+	// locality labels of current instance
+	Locality LocalityConfig `yaml:"locality"`
+
+	// kubernetes membership config
+	Membership MembershipConfig `yaml:"membership"`
+
+	// warm standby config
+	Standby StandbyConfig `yaml:"standby"`
+}
+
+// UseEtcdBackend tells whether schema is coordinated through etcd directly
+func (c ClusterConfig) UseEtcdBackend() bool {
+	return c.CoordinationBackend == CoordinationBackendEtcd
 }
 
 // local redolog config
 type DiskRedoLogConfig struct {
 	// disable local disk redolog, default will be enabled
 	Disabled bool `yaml:"disabled"`
+	// rewrite redolog files at checkpoint to drop upsert batches already covered by archived
+	// batches, the batch offsets of the remaining upsert batches are kept while their byte
+	// offsets in the file change
+	Compaction bool `yaml:"compaction"`
+	// truncate a redolog file at the first upsert batch failing checksum verification during
+	// recovery instead of failing the recovery
+	Salvage bool `yaml:"salvage"`
 }
 
 // Kafka source config
@@ -111,6 +256,141 @@ type RedoLogConfig struct {
 	KafkaConfig KafkaRedoLogConfig `yaml:"kafka"`
 	// Disk only redolog for unsharded tables
 	DiskOnlyForUnsharded bool `yaml:"diskOnlyForUnsharded"`
+	// Object storage to store redolog files in instead of local disk when enabled, configured
+	// the same way as backup storage. Each upsert batch is written as an object before being
+	// acknowledged, trading ingestion latency for durability. Redolog browsing, replication
+	// and peer bootstrap still read local redolog files only.
+	ObjectStore BackupConfig `yaml:"object_store"`
+	// max number of goroutines applying a replayed upsert batch to live batches of a shard in
+	// parallel during recovery, 0 or 1 means upsert batches are applied by a single goroutine
+	ReplayConcurrency int `yaml:"replay_concurrency"`
+}
+
+// TracingConfig is the configuration for exporting OpenTelemetry traces.
+type TracingConfig struct {
+	Enable bool `yaml:"enable"`
+	// jaeger collector endpoint spans are exported to, eg http://localhost:14268/api/traces
+	CollectorEndpoint string `yaml:"collector_endpoint"`
+	// portion of root spans to sample, between 0 and 1, 0 means sampling all
+	SampleRate float64 `yaml:"sample_rate"`
+}
+
+// Authentication methods.
+const (
+	AuthMethodJWT  = "jwt"
+	AuthMethodMTLS = "mtls"
+)
+
+// AuthConfig is the configuration for authenticating http requests.
+type AuthConfig struct {
+	Enable bool `yaml:"enable"`
+	// one of jwt and mtls
+	Method string    `yaml:"method"`
+	JWT    JWTConfig `yaml:"jwt"`
+	// principals (e.g. brokers) allowed to make requests on behalf of other principals
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// JWTConfig is the configuration for verifying HS256 signed json web tokens.
+type JWTConfig struct {
+	// shared secret tokens are signed with
+	Secret string `yaml:"secret"`
+	// expected issuer of tokens, empty means any issuer
+	Issuer string `yaml:"issuer"`
+}
+
+// ResultSigningConfig is the configuration for signing query responses so that downstream
+// services can verify results were not tampered with in transit.
+type ResultSigningConfig struct {
+	Enable bool `yaml:"enable"`
+	// id of the signing key sent along with signatures, used by verifiers to pick the public key
+	KeyID string `yaml:"key_id"`
+	// PEM encoded PKCS #8 ed25519 private key, verifiers only hold the public key
+	PrivateKeyFile string `yaml:"private_key_file"`
+	// max size of response bodies buffered in memory for signing, larger responses fail,
+	// 64MB if not set
+	MaxBodyBytes int `yaml:"max_body_bytes"`
+}
+
+// TLSConfig is the configuration for serving and connecting to other nodes over TLS.
+// Certificate files are watched and reloaded without restart.
+type TLSConfig struct {
+	Enable   bool   `yaml:"enable"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// CA bundle to verify peers, system roots are used to verify servers if not set.
+	CAFile string `yaml:"ca_file"`
+	// MutualAuth requires servers to verify client certificates against CAFile.
+	MutualAuth bool `yaml:"mutual_auth"`
+	// interval in seconds to check certificate files for changes, default to 60
+	ReloadIntervalSeconds int `yaml:"reload_interval_seconds"`
+}
+
+// AuditLogConfig is the configuration for recording schema and data administration operations.
+type AuditLogConfig struct {
+	Enable bool `yaml:"enable"`
+	// path of the append only audit log file, one json object per line
+	FilePath string `yaml:"file_path"`
+}
+
+// BackupConfig is the configuration for backing up archived table data to object storage.
+type BackupConfig struct {
+	Enable bool `yaml:"enable"`
+	// one of local, s3 and gcs. gcs is accessed via its s3 compatible xml api with hmac keys.
+	Storage string `yaml:"storage"`
+	// bucket name for s3 and gcs, root directory for local storage.
+	Bucket string `yaml:"bucket"`
+	// prefix of all backup object keys.
+	Prefix string `yaml:"prefix"`
+	// endpoint of the object storage, defaults to aws s3 of the region or google cloud storage.
+	Endpoint string `yaml:"endpoint"`
+	Region   string `yaml:"region"`
+	// credentials fall back to AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables.
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+}
+
+// ReplicationConfig is the configuration for asynchronously replicating ingested upsert batches
+// from local redo log files to a standby cluster.
+type ReplicationConfig struct {
+	Enable bool `yaml:"enable"`
+	// host:port of the standby ingestion endpoint, batches are posted to /data/{table}/{shard} on it.
+	TargetAddress string `yaml:"target_address"`
+	// bearer token sent to the standby if it has authentication enabled.
+	AuthToken string `yaml:"auth_token"`
+	// tables to replicate, empty means all tables.
+	Tables []string `yaml:"tables"`
+	// interval in milliseconds to poll redo log files for new upsert batches, default to 1000.
+	PollIntervalMilliseconds int `yaml:"poll_interval_milliseconds"`
+}
+
+// CDCConfig is the configuration for publishing committed upserts as change events to kafka.
+type CDCConfig struct {
+	Enable       bool     `yaml:"enable"`
+	KafkaBrokers []string `yaml:"kafka_brokers"`
+	// topic change events are published to, {table} is replaced by the table name.
+	Topic string `yaml:"topic"`
+	// tables to publish change events for, empty means all tables.
+	Tables []string `yaml:"tables"`
+	// envelope format of change events, only json is supported now.
+	Format string `yaml:"format"`
+	// number of upsert batches buffered for publishing, ingestion blocks when the buffer is full.
+	BufferSize int `yaml:"buffer_size"`
+}
+
+// IngestionThrottleConfig is the configuration for rejecting upsert batches posted to the data
+// API with 429 when a shard cannot keep up, so that ingestion clients back off instead of the
+// node running out of memory.
+type IngestionThrottleConfig struct {
+	Enable bool `yaml:"enable"`
+	// ratio of unmanaged memory usage (live batches, primary keys etc.) to the total memory size
+	// above which ingestion is throttled, 0 means not checked.
+	MemoryUsageRatio float64 `yaml:"memory_usage_ratio"`
+	// max size in MB of redolog files of a shard not purged by archiving and backfill yet
+	// above which ingestion into the shard is throttled, 0 means not checked.
+	MaxRedoLogSizeMB int64 `yaml:"max_redolog_size_mb"`
+	// seconds ingestion clients are asked to wait before retrying via the Retry-After header.
+	RetryAfterSeconds int `yaml:"retry_after_seconds"`
 }
 
 // AresServerConfig is config specific for ares server.
@@ -143,4 +423,16 @@ type AresServerConfig struct {
 
 	// Cluster determines the cluster mode configuration of aresdb
 	Cluster ClusterConfig `yaml:"cluster"`
+
+	Tracing TracingConfig `yaml:"tracing"`
+	Auth    AuthConfig    `yaml:"auth"`
+	TLS     TLSConfig     `yaml:"tls"`
+
+	AuditLog AuditLogConfig `yaml:"audit_log"`
+	Backup   BackupConfig   `yaml:"backup"`
+
+	Replication ReplicationConfig `yaml:"replication"`
+	CDC         CDCConfig         `yaml:"cdc"`
+
+	IngestionThrottle IngestionThrottleConfig `yaml:"ingestion_throttle"`
 }
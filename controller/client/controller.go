@@ -42,6 +42,8 @@ type ControllerClient interface {
 
 	GetSchemaHash(namespace string) (string, error)
 	GetAllSchema(namespace string) ([]metaCom.Table, error)
+	GetSchemaRollouts(namespace string) ([]models.SchemaRollout, error)
+	AckSchemaRollout(namespace, tableName string, version int) error
 	GetNamespaces() ([]string, error)
 	GetAssignmentHash(jobNamespace, instance string) (string, error)
 	GetAssignment(jobNamespace, instance string) (*models.IngestionAssignment, error)
@@ -59,7 +61,8 @@ type ControllerHTTPClient struct {
 func NewControllerHTTPClient(address string, timeoutSec time.Duration, headers http.Header) *ControllerHTTPClient {
 	return &ControllerHTTPClient{
 		c: &http.Client{
-			Timeout: timeoutSec,
+			Timeout:   timeoutSec,
+			Transport: utils.HTTPClientTransport(),
 		},
 		address: address,
 		headers: headers,
@@ -69,7 +72,7 @@ func NewControllerHTTPClient(address string, timeoutSec time.Duration, headers h
 // buildRequest builds an http.Request with headers.
 func (c *ControllerHTTPClient) buildRequest(method, path string, body io.Reader) (req *http.Request, err error) {
 	path = strings.TrimPrefix(path, "/")
-	url := fmt.Sprintf("http://%s/%s", c.address, path)
+	url := fmt.Sprintf("%s://%s/%s", utils.HTTPScheme(), c.address, path)
 	req, err = http.NewRequest(method, url, body)
 	if err != nil {
 		req = nil
@@ -149,6 +152,35 @@ func (c *ControllerHTTPClient) GetAllSchema(namespace string) (tables []metaCom.
 	return
 }
 
+// GetSchemaRollouts gets schema rollouts in prepare phase
+func (c *ControllerHTTPClient) GetSchemaRollouts(namespace string) (rollouts []models.SchemaRollout, err error) {
+	request, err := c.buildRequest(http.MethodGet, fmt.Sprintf("/schema/%s/rollouts", namespace), nil)
+	if err != nil {
+		return
+	}
+	err = c.getJSONResponse(request, &rollouts)
+	if err != nil {
+		err = utils.StackError(err, "controller client error fetching schema rollouts")
+		return
+	}
+
+	return
+}
+
+// AckSchemaRollout acknowledges the pending schema of given version has been prepared,
+// the instance is identified by instance name header
+func (c *ControllerHTTPClient) AckSchemaRollout(namespace, tableName string, version int) (err error) {
+	request, err := c.buildRequest(http.MethodPost, fmt.Sprintf("/schema/%s/rollouts/%s/versions/%d/ack", namespace, tableName, version), nil)
+	if err != nil {
+		return
+	}
+	_, err = c.getResponse(request)
+	if err != nil {
+		err = utils.StackError(err, "controller client error acknowledging schema rollout for table: %s", tableName)
+	}
+	return
+}
+
 func (c *ControllerHTTPClient) GetNamespaces() (namespaces []string, err error) {
 	request, err := c.buildRequest(http.MethodGet, "/namespaces", nil)
 	if err != nil {
@@ -22,6 +22,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/controller/models"
 	"github.com/uber/aresdb/metastore/common"
 )
 
@@ -65,6 +66,14 @@ var _ = ginkgo.Describe("Controller", func() {
 	enumCasesBytes, _ := json.Marshal(column2EnumCases)
 	column2extendedEnumIDs := []int{2}
 	enumIDBytes, _ := json.Marshal(column2extendedEnumIDs)
+	rollouts := []models.SchemaRollout{
+		{
+			Table:   "test1",
+			Version: 1,
+			Phase:   models.SchemaRolloutPrepare,
+			Schema:  table,
+		},
+	}
 
 	ginkgo.BeforeEach(func() {
 		testRouter := mux.NewRouter()
@@ -80,6 +89,15 @@ var _ = ginkgo.Describe("Controller", func() {
 		testRouter.HandleFunc("/schema/ns_baddata/tables", func(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte(`"bad data`))
 		})
+		testRouter.HandleFunc("/schema/ns1/rollouts", func(w http.ResponseWriter, r *http.Request) {
+			b, _ := json.Marshal(rollouts)
+			w.Write(b)
+		})
+		testRouter.HandleFunc("/schema/ns1/rollouts/test1/versions/1/ack", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		})
 		testRouter.HandleFunc("/schema/ns1/hash", func(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte("123"))
 		})
@@ -180,6 +198,11 @@ var _ = ginkgo.Describe("Controller", func() {
 		Ω(err).Should(BeNil())
 		Ω(tablesGot).Should(Equal(tables))
 
+		rolloutsGot, err := c.GetSchemaRollouts("ns1")
+		Ω(err).Should(BeNil())
+		Ω(rolloutsGot).Should(Equal(rollouts))
+		Ω(c.AckSchemaRollout("ns1", "test1", 1)).Should(BeNil())
+
 		namespacesGot, err := c.GetNamespaces()
 		Ω(err).Should(BeNil())
 		Ω(namespacesGot).Should(Equal(namespaces))
@@ -217,6 +240,9 @@ var _ = ginkgo.Describe("Controller", func() {
 		tablesGot, err := c.GetAllSchema("bad_ns")
 		Ω(err).ShouldNot(BeNil())
 		Ω(tablesGot).Should(BeNil())
+		_, err = c.GetSchemaRollouts("bad_ns")
+		Ω(err).ShouldNot(BeNil())
+		Ω(c.AckSchemaRollout("ns1", "test1", 2)).ShouldNot(BeNil())
 		c.SetNamespace("bad_ns")
 		_, err = c.FetchAllSchemas()
 		Ω(err).ShouldNot(BeNil())
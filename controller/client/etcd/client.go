@@ -0,0 +1,180 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcd
+
+import (
+	"errors"
+
+	"github.com/uber/aresdb/cluster/kvstore"
+	controllerCli "github.com/uber/aresdb/controller/client"
+	"github.com/uber/aresdb/controller/models"
+	"github.com/uber/aresdb/controller/mutators/common"
+	mutators "github.com/uber/aresdb/controller/mutators/etcd"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	"github.com/uber/aresdb/utils"
+	"go.uber.org/zap"
+)
+
+var _ controllerCli.ControllerClient = &ControllerClient{}
+
+var errMissingInstanceName = errors.New("instance name is required to acknowledge schema rollouts")
+
+// ControllerClient implements ControllerClient by reading and writing etcd directly
+// through controller mutators, for deployments not running ares-controller
+type ControllerClient struct {
+	etcdClient   *kvstore.EtcdClient
+	namespace    string
+	instanceName string
+
+	schemaMutator     common.TableSchemaMutator
+	rolloutMutator    common.SchemaRolloutMutator
+	enumMutator       common.EnumMutator
+	namespaceMutator  common.NamespaceMutator
+	assignmentMutator common.IngestionAssignmentMutator
+}
+
+// NewControllerClient returns new etcd backed ControllerClient, instanceName identifies
+// current instance when acknowledging schema rollouts
+func NewControllerClient(etcdClient *kvstore.EtcdClient, instanceName string, logger *zap.SugaredLogger) *ControllerClient {
+	schemaMutator := mutators.NewTableSchemaMutator(etcdClient.TxnStore, logger)
+	return &ControllerClient{
+		etcdClient:        etcdClient,
+		instanceName:      instanceName,
+		schemaMutator:     schemaMutator,
+		rolloutMutator:    mutators.NewSchemaRolloutMutator(etcdClient.TxnStore, mutators.NewMembershipMutator(etcdClient), logger),
+		enumMutator:       mutators.NewEnumMutator(etcdClient.TxnStore, schemaMutator),
+		namespaceMutator:  mutators.NewNamespaceMutator(etcdClient.TxnStore),
+		assignmentMutator: mutators.NewIngestionAssignmentMutator(etcdClient.TxnStore),
+	}
+}
+
+// GetSchemaHash returns hash that will be different if any table changed
+func (c *ControllerClient) GetSchemaHash(namespace string) (string, error) {
+	return c.schemaMutator.GetHash(namespace)
+}
+
+// GetAllSchema returns schemas of all tables in the namespace
+func (c *ControllerClient) GetAllSchema(namespace string) (tables []metaCom.Table, err error) {
+	tableNames, err := c.schemaMutator.ListTables(namespace)
+	if err != nil {
+		return nil, utils.StackError(err, "etcd client error listing tables")
+	}
+
+	tables = make([]metaCom.Table, 0, len(tableNames))
+	for _, tableName := range tableNames {
+		table, err := c.schemaMutator.GetTable(namespace, tableName)
+		if err != nil {
+			return nil, utils.StackError(err, "etcd client error fetching schema for table: %s", tableName)
+		}
+		tables = append(tables, *table)
+	}
+	return tables, nil
+}
+
+// GetSchemaRollouts gets schema rollouts in prepare phase
+func (c *ControllerClient) GetSchemaRollouts(namespace string) ([]models.SchemaRollout, error) {
+	return c.rolloutMutator.GetRollouts(namespace)
+}
+
+// AckSchemaRollout acknowledges the pending schema of given version has been prepared by current instance
+func (c *ControllerClient) AckSchemaRollout(namespace, tableName string, version int) error {
+	if c.instanceName == "" {
+		return errMissingInstanceName
+	}
+	return c.rolloutMutator.AckRollout(namespace, tableName, c.instanceName, version)
+}
+
+// GetNamespaces returns all namespaces
+func (c *ControllerClient) GetNamespaces() ([]string, error) {
+	return c.namespaceMutator.ListNamespaces()
+}
+
+// GetAssignmentHash get hash code of assignment
+func (c *ControllerClient) GetAssignmentHash(jobNamespace, instance string) (string, error) {
+	return c.assignmentMutator.GetHash(jobNamespace, instance)
+}
+
+// GetAssignment gets the job assignment of the ares-subscriber
+func (c *ControllerClient) GetAssignment(jobNamespace, instance string) (*models.IngestionAssignment, error) {
+	assignment, err := c.assignmentMutator.GetIngestionAssignment(jobNamespace, instance)
+	if err != nil {
+		return nil, err
+	}
+	return &assignment, nil
+}
+
+// SetNamespace sets the namespace which the ControllerClient reads from
+func (c *ControllerClient) SetNamespace(namespace string) {
+	c.namespace = namespace
+}
+
+// FetchAllSchemas fetches all schemas
+func (c *ControllerClient) FetchAllSchemas() ([]*metaCom.Table, error) {
+	schemas, err := c.GetAllSchema(c.namespace)
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]*metaCom.Table, 0, len(schemas))
+	for i := range schemas {
+		tables = append(tables, &schemas[i])
+	}
+	return tables, nil
+}
+
+// FetchSchema fetch one schema for given table
+func (c *ControllerClient) FetchSchema(tableName string) (*metaCom.Table, error) {
+	return c.schemaMutator.GetTable(c.namespace, tableName)
+}
+
+// FetchAllEnums fetches all enums for given table and column
+func (c *ControllerClient) FetchAllEnums(tableName string, columnName string) ([]string, error) {
+	return c.enumMutator.GetEnumCases(c.namespace, tableName, columnName)
+}
+
+// ExtendEnumCases extends enum cases to given table column
+func (c *ControllerClient) ExtendEnumCases(tableName, columnName string, enumCases []string) ([]int, error) {
+	if len(enumCases) == 0 {
+		return nil, nil
+	}
+	return c.enumMutator.ExtendEnumCases(c.namespace, tableName, columnName, enumCases)
+}
+
+// WatchSchema returns a channel notified whenever table list of the namespace changes,
+// which happens on every table creation, update and deletion. Pending schema rollouts
+// are stored per table and are not notified. The watch stops once done is closed.
+func (c *ControllerClient) WatchSchema(namespace string, done <-chan struct{}) (<-chan struct{}, error) {
+	watch, err := c.etcdClient.TxnStore.Watch(utils.SchemaListKey(namespace))
+	if err != nil {
+		return nil, utils.StackError(err, "failed to watch schema, namespace: %s", namespace)
+	}
+
+	notifications := make(chan struct{}, 1)
+	go func() {
+		defer watch.Close()
+		for {
+			select {
+			case <-done:
+				return
+			case <-watch.C():
+				// coalesce notifications not consumed yet
+				select {
+				case notifications <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return notifications, nil
+}
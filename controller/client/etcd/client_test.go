@@ -0,0 +1,179 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package etcd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m3db/m3/src/cluster/kv/mem"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber/aresdb/cluster/kvstore"
+	pb "github.com/uber/aresdb/controller/generated/proto"
+	"github.com/uber/aresdb/controller/models"
+	mutators "github.com/uber/aresdb/controller/mutators/etcd"
+	"github.com/uber/aresdb/controller/mutators/mocks"
+	"github.com/uber/aresdb/metastore"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	"github.com/uber/aresdb/utils"
+	"go.uber.org/zap"
+)
+
+func TestControllerClient(t *testing.T) {
+	testTable := metaCom.Table{
+		Name: "test1",
+		Columns: []metaCom.Column{
+			{
+				Name: "col1",
+				Type: "Int32",
+			},
+			{
+				Name: "col2",
+				Type: metaCom.SmallEnum,
+			},
+		},
+		PrimaryKeyColumns: []int{0},
+		Config:            metastore.DefaultTableConfig,
+	}
+
+	testTableWithNewColumn := testTable
+	testTableWithNewColumn.Columns = append([]metaCom.Column{}, testTable.Columns...)
+	testTableWithNewColumn.Columns = append(testTableWithNewColumn.Columns, metaCom.Column{
+		Name: "col3",
+		Type: "Int32",
+	})
+
+	setup := func(t *testing.T, instanceName string) *ControllerClient {
+		store := mem.NewStore()
+		_, err := store.Set(utils.SchemaListKey("ns1"), &pb.EntityList{})
+		assert.NoError(t, err)
+
+		c := NewControllerClient(&kvstore.EtcdClient{TxnStore: store}, instanceName, zap.NewExample().Sugar())
+		c.SetNamespace("ns1")
+		table := testTable
+		assert.NoError(t, c.schemaMutator.CreateTable("ns1", &table, false))
+		return c
+	}
+
+	t.Run("should read schemas from etcd", func(t *testing.T) {
+		c := setup(t, "inst1")
+
+		hash, err := c.GetSchemaHash("ns1")
+		assert.NoError(t, err)
+		assert.NotEmpty(t, hash)
+
+		tables, err := c.GetAllSchema("ns1")
+		assert.NoError(t, err)
+		assert.Len(t, tables, 1)
+		assert.Equal(t, "test1", tables[0].Name)
+
+		fetchedTables, err := c.FetchAllSchemas()
+		assert.NoError(t, err)
+		assert.Len(t, fetchedTables, 1)
+
+		table, err := c.FetchSchema("test1")
+		assert.NoError(t, err)
+		assert.Len(t, table.Columns, 2)
+
+		_, err = c.FetchSchema("test2")
+		assert.Equal(t, metaCom.ErrTableDoesNotExist, err)
+
+		// hash changes after table update
+		assert.NoError(t, c.schemaMutator.UpdateTable("ns1", testTableWithNewColumn, false))
+		newHash, err := c.GetSchemaHash("ns1")
+		assert.NoError(t, err)
+		assert.NotEqual(t, hash, newHash)
+	})
+
+	t.Run("should extend and fetch enums from etcd", func(t *testing.T) {
+		c := setup(t, "inst1")
+
+		enumIDs, err := c.ExtendEnumCases("test1", "col2", []string{"a", "b"})
+		assert.NoError(t, err)
+		assert.Equal(t, []int{0, 1}, enumIDs)
+
+		enumIDs, err = c.ExtendEnumCases("test1", "col2", nil)
+		assert.NoError(t, err)
+		assert.Empty(t, enumIDs)
+
+		enumCases, err := c.FetchAllEnums("test1", "col2")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, enumCases)
+	})
+
+	t.Run("should acknowledge schema rollouts as current instance", func(t *testing.T) {
+		c := setup(t, "inst1")
+		membershipMutator := &mocks.MembershipMutator{}
+		membershipMutator.On("GetInstances", "ns1").Return([]models.Instance{{Name: "inst1"}, {Name: "inst2"}}, nil)
+		c.rolloutMutator = mutators.NewSchemaRolloutMutator(c.etcdClient.TxnStore, membershipMutator, zap.NewExample().Sugar())
+
+		_, err := c.rolloutMutator.StartRollout("ns1", testTableWithNewColumn, false)
+		assert.NoError(t, err)
+
+		rollouts, err := c.GetSchemaRollouts("ns1")
+		assert.NoError(t, err)
+		assert.Len(t, rollouts, 1)
+		assert.Equal(t, models.SchemaRolloutPrepare, rollouts[0].Phase)
+
+		assert.NoError(t, c.AckSchemaRollout("ns1", "test1", rollouts[0].Version))
+		rollout, err := c.rolloutMutator.GetRollout("ns1", "test1")
+		assert.NoError(t, err)
+		assert.True(t, rollout.IsPreparedBy("inst1"))
+		// not committed until prepared by all instances
+		assert.Equal(t, models.SchemaRolloutPrepare, rollout.Phase)
+
+		c.instanceName = ""
+		assert.Equal(t, errMissingInstanceName, c.AckSchemaRollout("ns1", "test1", rollouts[0].Version))
+	})
+
+	t.Run("should read namespaces and assignments from etcd", func(t *testing.T) {
+		c := setup(t, "inst1")
+		assert.NoError(t, mutators.NewNamespaceMutator(c.etcdClient.TxnStore).CreateNamespace("ns2"))
+
+		namespaces, err := c.GetNamespaces()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"ns2"}, namespaces)
+
+		assert.NoError(t, c.assignmentMutator.AddIngestionAssignment("ns2", models.IngestionAssignment{Subscriber: "sub1"}))
+		assignment, err := c.GetAssignment("ns2", "sub1")
+		assert.NoError(t, err)
+		assert.Equal(t, "sub1", assignment.Subscriber)
+
+		hash, err := c.GetAssignmentHash("ns2", "sub1")
+		assert.NoError(t, err)
+		assert.NotEmpty(t, hash)
+	})
+
+	t.Run("should notify schema changes", func(t *testing.T) {
+		c := setup(t, "inst1")
+		done := make(chan struct{})
+		defer close(done)
+
+		schemaWatch, err := c.WatchSchema("ns1", done)
+		assert.NoError(t, err)
+		// initial value
+		waitForNotification(t, schemaWatch)
+
+		assert.NoError(t, c.schemaMutator.UpdateTable("ns1", testTableWithNewColumn, false))
+		waitForNotification(t, schemaWatch)
+	})
+}
+
+func waitForNotification(t *testing.T, ch <-chan struct{}) {
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "timed out waiting for notification")
+	}
+}
@@ -10,6 +10,20 @@ type ControllerClient struct {
 	mock.Mock
 }
 
+// AckSchemaRollout provides a mock function with given fields: namespace, tableName, version
+func (_m *ControllerClient) AckSchemaRollout(namespace string, tableName string, version int) error {
+	ret := _m.Called(namespace, tableName, version)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, int) error); ok {
+		r0 = rf(namespace, tableName, version)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // ExtendEnumCases provides a mock function with given fields: tableName, columnName, enumCases
 func (_m *ControllerClient) ExtendEnumCases(tableName string, columnName string, enumCases []string) ([]int, error) {
 	ret := _m.Called(tableName, columnName, enumCases)
@@ -212,3 +226,26 @@ func (_m *ControllerClient) GetSchemaHash(namespace string) (string, error) {
 
 	return r0, r1
 }
+
+// GetSchemaRollouts provides a mock function with given fields: namespace
+func (_m *ControllerClient) GetSchemaRollouts(namespace string) ([]models.SchemaRollout, error) {
+	ret := _m.Called(namespace)
+
+	var r0 []models.SchemaRollout
+	if rf, ok := ret.Get(0).(func(string) []models.SchemaRollout); ok {
+		r0 = rf(namespace)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.SchemaRollout)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(namespace)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,112 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	apiCom "github.com/uber/aresdb/api/common"
+	mutators "github.com/uber/aresdb/controller/mutators/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// defaultMaxZoneMoves is the number of shard migrations allowed in flight when rebalancing zones
+const defaultMaxZoneMoves = 1
+
+// PlacementHandler serves the admin endpoints of datanode shard placement
+type PlacementHandler struct {
+	placementMutator mutators.PlacementMutator
+}
+
+// NewPlacementHandler creates a new PlacementHandler
+func NewPlacementHandler(placementMutator mutators.PlacementMutator) PlacementHandler {
+	return PlacementHandler{
+		placementMutator: placementMutator,
+	}
+}
+
+// GetZoneViolationsRequest is the request to get replicas violating zone isolation
+type GetZoneViolationsRequest struct {
+	Namespace string `path:"namespace"`
+}
+
+// RebalanceZonesRequest is the request to move replicas violating zone isolation
+type RebalanceZonesRequest struct {
+	Namespace string `path:"namespace"`
+	// max number of shard migrations in flight, including ones started by previous rebalances
+	MaxMoves int `query:"maxMoves,optional"`
+}
+
+// Register registers the placement endpoints
+func (h PlacementHandler) Register(router *mux.Router, wrappers ...utils.HTTPHandlerWrapper) {
+	router.HandleFunc("/{namespace}/zone-violations", utils.ApplyHTTPWrappers(h.GetZoneViolations, wrappers)).Methods(http.MethodGet)
+	router.HandleFunc("/{namespace}/zone-rebalance", utils.ApplyHTTPWrappers(h.RebalanceZones, wrappers)).Methods(http.MethodPost)
+}
+
+// GetZoneViolations responds with replicas placed in the same isolation group with another replica of the shard
+func (h PlacementHandler) GetZoneViolations(w http.ResponseWriter, r *http.Request) {
+	var request GetZoneViolationsRequest
+	if err := apiCom.ReadRequest(r, &request); err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	violations, err := h.placementMutator.GetZoneViolations(request.Namespace)
+	if err != nil {
+		respondWithPlacementError(w, err)
+		return
+	}
+	apiCom.RespondWithJSONObject(w, violations)
+}
+
+// RebalanceZones starts moving replicas violating zone isolation and responds with violations left,
+// operators are expected to call it repeatedly until no violation is left
+func (h PlacementHandler) RebalanceZones(w http.ResponseWriter, r *http.Request) {
+	var request RebalanceZonesRequest
+	if err := apiCom.ReadRequest(r, &request); err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+	if request.MaxMoves == 0 {
+		request.MaxMoves = defaultMaxZoneMoves
+	} else if request.MaxMoves < 0 {
+		apiCom.RespondWithBadRequest(w, utils.APIError{Message: "maxMoves should be positive"})
+		return
+	}
+
+	if _, err := h.placementMutator.RebalanceZones(request.Namespace, request.MaxMoves); err != nil {
+		respondWithPlacementError(w, err)
+		return
+	}
+
+	violations, err := h.placementMutator.GetZoneViolations(request.Namespace)
+	if err != nil {
+		respondWithPlacementError(w, err)
+		return
+	}
+	apiCom.RespondWithJSONObject(w, violations)
+}
+
+func respondWithPlacementError(w http.ResponseWriter, err error) {
+	if mutators.IsNonExist(err) {
+		apiCom.RespondWithError(w, utils.APIError{
+			Code:    http.StatusNotFound,
+			Message: err.Error(),
+		})
+		return
+	}
+	apiCom.RespondWithError(w, err)
+}
@@ -0,0 +1,80 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/m3db/m3/src/cluster/placement"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber/aresdb/controller/models"
+	mutators "github.com/uber/aresdb/controller/mutators/common"
+	"github.com/uber/aresdb/controller/mutators/mocks"
+)
+
+func TestPlacementHandler(t *testing.T) {
+	setup := func() (*mocks.PlacementMutator, *mux.Router) {
+		placementMutator := &mocks.PlacementMutator{}
+		router := mux.NewRouter()
+		NewPlacementHandler(placementMutator).Register(router.PathPrefix("/placement").Subrouter())
+		return placementMutator, router
+	}
+
+	serve := func(router *mux.Router, method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(method, path, nil)
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	violations := []models.ZoneViolation{{Shard: 0, Instance: "inst2", IsolationGroup: "zone1/rack1"}}
+
+	t.Run("should get zone violations", func(t *testing.T) {
+		placementMutator, router := setup()
+		placementMutator.On("GetZoneViolations", "ns1").Return(violations, nil).Once()
+		placementMutator.On("GetZoneViolations", "ns2").Return(nil, mutators.ErrPlacementDoesNotExist).Once()
+
+		w := serve(router, http.MethodGet, "/placement/ns1/zone-violations")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `[{"shard":0,"instance":"inst2","isolationGroup":"zone1/rack1"}]`, w.Body.String())
+
+		w = serve(router, http.MethodGet, "/placement/ns2/zone-violations")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("should rebalance zones", func(t *testing.T) {
+		placementMutator, router := setup()
+		placementMutator.On("RebalanceZones", "ns1", defaultMaxZoneMoves).Return(placement.NewPlacement(), nil).Once()
+		placementMutator.On("RebalanceZones", "ns1", 3).Return(placement.NewPlacement(), nil).Once()
+		placementMutator.On("GetZoneViolations", "ns1").Return(violations, nil).Twice()
+
+		w := serve(router, http.MethodPost, "/placement/ns1/zone-rebalance")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `[{"shard":0,"instance":"inst2","isolationGroup":"zone1/rack1"}]`, w.Body.String())
+
+		w = serve(router, http.MethodPost, "/placement/ns1/zone-rebalance?maxMoves=3")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = serve(router, http.MethodPost, "/placement/ns1/zone-rebalance?maxMoves=-1")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = serve(router, http.MethodGet, "/placement/ns1/zone-rebalance")
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+		placementMutator.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,198 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	apiCom "github.com/uber/aresdb/api/common"
+	mutators "github.com/uber/aresdb/controller/mutators/common"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// SchemaRolloutHandler serves column changes of tables, which are rolled out to all datanodes
+// of the namespace before being committed, and the endpoints datanodes use to prepare them
+type SchemaRolloutHandler struct {
+	rolloutMutator mutators.SchemaRolloutMutator
+}
+
+// NewSchemaRolloutHandler creates a new SchemaRolloutHandler
+func NewSchemaRolloutHandler(rolloutMutator mutators.SchemaRolloutMutator) SchemaRolloutHandler {
+	return SchemaRolloutHandler{
+		rolloutMutator: rolloutMutator,
+	}
+}
+
+// GetRolloutsRequest is the request to get rollouts in prepare phase
+type GetRolloutsRequest struct {
+	Namespace string `path:"namespace"`
+}
+
+// RolloutRequest is the request to get or abort the rollout of a table
+type RolloutRequest struct {
+	Namespace string `path:"namespace"`
+	TableName string `path:"table"`
+}
+
+// AckRolloutRequest is the request of datanodes acknowledging the pending schema
+type AckRolloutRequest struct {
+	Namespace string `path:"namespace"`
+	TableName string `path:"table"`
+	Version   int    `path:"version"`
+	// sent by controller client in InstanceNameHeaderKey
+	Instance string `header:"AresDB-InstanceName"`
+}
+
+// AddColumnRequest is the request to add a column to a table
+type AddColumnRequest struct {
+	Namespace string `path:"namespace"`
+	TableName string `path:"table"`
+	Body      struct {
+		metaCom.Column
+		AddToArchivingSortOrder bool `json:"addToArchivingSortOrder,omitempty"`
+	} `body:""`
+}
+
+// DeleteColumnRequest is the request to delete a column from a table
+type DeleteColumnRequest struct {
+	Namespace  string `path:"namespace"`
+	TableName  string `path:"table"`
+	ColumnName string `path:"column"`
+}
+
+// Register registers the schema rollout endpoints
+func (h SchemaRolloutHandler) Register(router *mux.Router, wrappers ...utils.HTTPHandlerWrapper) {
+	router.HandleFunc("/{namespace}/tables/{table}/columns", utils.ApplyHTTPWrappers(h.AddColumn, wrappers)).Methods(http.MethodPost)
+	router.HandleFunc("/{namespace}/tables/{table}/columns/{column}", utils.ApplyHTTPWrappers(h.DeleteColumn, wrappers)).Methods(http.MethodDelete)
+	router.HandleFunc("/{namespace}/rollouts", utils.ApplyHTTPWrappers(h.GetRollouts, wrappers)).Methods(http.MethodGet)
+	router.HandleFunc("/{namespace}/rollouts/{table}", utils.ApplyHTTPWrappers(h.GetRollout, wrappers)).Methods(http.MethodGet)
+	router.HandleFunc("/{namespace}/rollouts/{table}", utils.ApplyHTTPWrappers(h.AbortRollout, wrappers)).Methods(http.MethodDelete)
+	router.HandleFunc("/{namespace}/rollouts/{table}/versions/{version}/ack", utils.ApplyHTTPWrappers(h.AckRollout, wrappers)).Methods(http.MethodPost)
+}
+
+// AddColumn starts the rollout of the column addition and responds with the rollout,
+// the column is added once all datanodes prepared the pending schema
+func (h SchemaRolloutHandler) AddColumn(w http.ResponseWriter, r *http.Request) {
+	var request AddColumnRequest
+	if err := apiCom.ReadRequest(r, &request); err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	rollout, err := h.rolloutMutator.AddColumn(request.Namespace, request.TableName, request.Body.Column, request.Body.AddToArchivingSortOrder)
+	if err != nil {
+		respondWithRolloutError(w, err)
+		return
+	}
+	apiCom.RespondWithJSONObject(w, rollout)
+}
+
+// DeleteColumn starts the rollout of the column deletion and responds with the rollout,
+// the column is deleted once all datanodes prepared the pending schema
+func (h SchemaRolloutHandler) DeleteColumn(w http.ResponseWriter, r *http.Request) {
+	var request DeleteColumnRequest
+	if err := apiCom.ReadRequest(r, &request); err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	rollout, err := h.rolloutMutator.DeleteColumn(request.Namespace, request.TableName, request.ColumnName)
+	if err != nil {
+		respondWithRolloutError(w, err)
+		return
+	}
+	apiCom.RespondWithJSONObject(w, rollout)
+}
+
+// GetRollouts responds with rollouts in prepare phase of the namespace
+func (h SchemaRolloutHandler) GetRollouts(w http.ResponseWriter, r *http.Request) {
+	var request GetRolloutsRequest
+	if err := apiCom.ReadRequest(r, &request); err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	rollouts, err := h.rolloutMutator.GetRollouts(request.Namespace)
+	if err != nil {
+		respondWithRolloutError(w, err)
+		return
+	}
+	apiCom.RespondWithJSONObject(w, rollouts)
+}
+
+// GetRollout responds with the latest rollout of the table
+func (h SchemaRolloutHandler) GetRollout(w http.ResponseWriter, r *http.Request) {
+	var request RolloutRequest
+	if err := apiCom.ReadRequest(r, &request); err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	rollout, err := h.rolloutMutator.GetRollout(request.Namespace, request.TableName)
+	if err != nil {
+		respondWithRolloutError(w, err)
+		return
+	}
+	apiCom.RespondWithJSONObject(w, rollout)
+}
+
+// AbortRollout discards the pending schema of the table
+func (h SchemaRolloutHandler) AbortRollout(w http.ResponseWriter, r *http.Request) {
+	var request RolloutRequest
+	if err := apiCom.ReadRequest(r, &request); err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	if err := h.rolloutMutator.AbortRollout(request.Namespace, request.TableName); err != nil {
+		respondWithRolloutError(w, err)
+		return
+	}
+	apiCom.RespondWithJSONObject(w, nil)
+}
+
+// AckRollout records the datanode identified by instance name header has prepared the pending schema,
+// the rollout is committed once all datanodes prepared it
+func (h SchemaRolloutHandler) AckRollout(w http.ResponseWriter, r *http.Request) {
+	var request AckRolloutRequest
+	if err := apiCom.ReadRequest(r, &request); err != nil {
+		apiCom.RespondWithBadRequest(w, err)
+		return
+	}
+
+	if err := h.rolloutMutator.AckRollout(request.Namespace, request.TableName, request.Instance, request.Version); err != nil {
+		respondWithRolloutError(w, err)
+		return
+	}
+	apiCom.RespondWithJSONObject(w, nil)
+}
+
+func respondWithRolloutError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case mutators.IsNonExist(err), err == metaCom.ErrTableDoesNotExist, err == metaCom.ErrColumnDoesNotExist:
+		code = http.StatusNotFound
+	case err == mutators.ErrSchemaRolloutInProgress, err == mutators.ErrSchemaVersionMismatch:
+		code = http.StatusConflict
+	case err == metaCom.ErrDeleteTimeColumn, err == metaCom.ErrDeletePrimaryKeyColumn:
+		code = http.StatusBadRequest
+	}
+	apiCom.RespondWithError(w, utils.APIError{
+		Code:    code,
+		Message: err.Error(),
+	})
+}
@@ -0,0 +1,109 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	controllerCli "github.com/uber/aresdb/controller/client"
+	"github.com/uber/aresdb/controller/models"
+	mutators "github.com/uber/aresdb/controller/mutators/common"
+	"github.com/uber/aresdb/controller/mutators/mocks"
+	metaCom "github.com/uber/aresdb/metastore/common"
+)
+
+func TestSchemaRolloutHandler(t *testing.T) {
+	setup := func() (*mocks.SchemaRolloutMutator, *mux.Router) {
+		rolloutMutator := &mocks.SchemaRolloutMutator{}
+		router := mux.NewRouter()
+		NewSchemaRolloutHandler(rolloutMutator).Register(router.PathPrefix("/schema").Subrouter())
+		return rolloutMutator, router
+	}
+
+	serve := func(router *mux.Router, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		for key := range header {
+			r.Header.Set(key, header.Get(key))
+		}
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	rollout := models.SchemaRollout{
+		Table:   "table1",
+		Version: 2,
+		Phase:   models.SchemaRolloutPrepare,
+	}
+
+	t.Run("should start rollouts of column changes", func(t *testing.T) {
+		rolloutMutator, router := setup()
+		rolloutMutator.On("AddColumn", "ns1", "table1", metaCom.Column{Name: "col2", Type: metaCom.Int32}, true).Return(rollout, nil).Once()
+		rolloutMutator.On("DeleteColumn", "ns1", "table1", "col2").Return(rollout, nil).Once()
+		rolloutMutator.On("DeleteColumn", "ns1", "table1", "col1").Return(models.SchemaRollout{}, metaCom.ErrDeletePrimaryKeyColumn).Once()
+		rolloutMutator.On("DeleteColumn", "ns1", "table1", "col3").Return(models.SchemaRollout{}, mutators.ErrSchemaRolloutInProgress).Once()
+
+		w := serve(router, http.MethodPost, "/schema/ns1/tables/table1/columns",
+			`{"name": "col2", "type": "Int32", "addToArchivingSortOrder": true}`, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"phase":"prepare"`)
+
+		w = serve(router, http.MethodPost, "/schema/ns1/tables/table1/columns", `{`, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = serve(router, http.MethodDelete, "/schema/ns1/tables/table1/columns/col2", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = serve(router, http.MethodDelete, "/schema/ns1/tables/table1/columns/col1", "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = serve(router, http.MethodDelete, "/schema/ns1/tables/table1/columns/col3", "", nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		rolloutMutator.AssertExpectations(t)
+	})
+
+	t.Run("should serve rollouts to datanodes", func(t *testing.T) {
+		rolloutMutator, router := setup()
+		rolloutMutator.On("GetRollouts", "ns1").Return([]models.SchemaRollout{rollout}, nil).Once()
+		rolloutMutator.On("GetRollout", "ns1", "table2").Return(models.SchemaRollout{}, mutators.ErrSchemaRolloutDoesNotExist).Once()
+		rolloutMutator.On("AckRollout", "ns1", "table1", "inst1", 2).Return(nil).Once()
+		rolloutMutator.On("AckRollout", "ns1", "table1", "inst1", 1).Return(mutators.ErrSchemaVersionMismatch).Once()
+		rolloutMutator.On("AbortRollout", "ns1", "table1").Return(nil).Once()
+
+		w := serve(router, http.MethodGet, "/schema/ns1/rollouts", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"table":"table1"`)
+
+		w = serve(router, http.MethodGet, "/schema/ns1/rollouts/table2", "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		instanceHeader := http.Header{}
+		instanceHeader.Set(controllerCli.InstanceNameHeaderKey, "inst1")
+		w = serve(router, http.MethodPost, "/schema/ns1/rollouts/table1/versions/2/ack", "", instanceHeader)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = serve(router, http.MethodPost, "/schema/ns1/rollouts/table1/versions/1/ack", "", instanceHeader)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		// instance name is required
+		w = serve(router, http.MethodPost, "/schema/ns1/rollouts/table1/versions/2/ack", "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = serve(router, http.MethodDelete, "/schema/ns1/rollouts/table1", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		rolloutMutator.AssertExpectations(t)
+	})
+}
@@ -13,10 +13,49 @@
 // limitations under the License.
 package models
 
+import "strings"
+
+// localitySeparator separates zone and rack labels encoded in placement isolation group
+const localitySeparator = "/"
+
 // Instance is the external view of instances
 type Instance struct {
 	Address string `json:"address"`
 	Host    string `json:"host"`
 	Port    uint32 `json:"port"`
 	Name    string `json:"name"`
+	// Zone and Rack are optional locality labels of the host
+	Zone string `json:"zone,omitempty"`
+	Rack string `json:"rack,omitempty"`
+}
+
+// IsolationGroup returns the failure domain of the instance for shard placement,
+// replicas of the same shard will not be placed into the same isolation group.
+// zone and rack are both encoded so that replicas are spread across zones first and then
+// across racks within a zone, instance without locality labels is its own isolation group
+func (i Instance) IsolationGroup() string {
+	if i.Zone != "" {
+		return EncodeLocality(i.Zone, i.Rack)
+	}
+	if i.Rack != "" {
+		return i.Rack
+	}
+	return i.Name
+}
+
+// EncodeLocality encodes zone and rack labels into isolation group advertised through heartbeat
+func EncodeLocality(zone, rack string) string {
+	if rack == "" {
+		return zone
+	}
+	return zone + localitySeparator + rack
+}
+
+// DecodeLocality decodes zone and rack labels from advertised isolation group
+func DecodeLocality(isolationGroup string) (zone, rack string) {
+	parts := strings.SplitN(isolationGroup, localitySeparator, 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
 }
@@ -0,0 +1,55 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package models
+
+import (
+	metaCom "github.com/uber/aresdb/metastore/common"
+)
+
+// SchemaRolloutPhase is the phase of a two-phase schema rollout
+type SchemaRolloutPhase string
+
+const (
+	// SchemaRolloutPrepare means datanodes are validating and acknowledging the pending schema,
+	// the committed schema is still the one served to queries
+	SchemaRolloutPrepare SchemaRolloutPhase = "prepare"
+	// SchemaRolloutCommitted means the pending schema has been committed as the table schema
+	SchemaRolloutCommitted SchemaRolloutPhase = "committed"
+	// SchemaRolloutAborted means the pending schema has been discarded
+	SchemaRolloutAborted SchemaRolloutPhase = "aborted"
+)
+
+// SchemaRollout tracks a schema change being rolled out to all datanodes of a namespace
+type SchemaRollout struct {
+	// Table is the name of the table being changed
+	Table string `json:"table"`
+	// Version is the schema version the table will have once committed
+	Version int `json:"version"`
+	// Phase is the current phase of the rollout
+	Phase SchemaRolloutPhase `json:"phase"`
+	// Schema is the pending table schema
+	Schema metaCom.Table `json:"schema"`
+	// PreparedInstances are datanodes which have acknowledged the pending schema
+	PreparedInstances []string `json:"preparedInstances"`
+}
+
+// IsPreparedBy tells whether the instance has acknowledged the pending schema
+func (r SchemaRollout) IsPreparedBy(instance string) bool {
+	for _, prepared := range r.PreparedInstances {
+		if prepared == instance {
+			return true
+		}
+	}
+	return false
+}
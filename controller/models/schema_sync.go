@@ -0,0 +1,68 @@
+//	Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package models
+
+import (
+	metaCom "github.com/uber/aresdb/metastore/common"
+)
+
+// SchemaSyncAction is the change needed to make an AresDB table consistent with the external
+// metastore
+type SchemaSyncAction string
+
+const (
+	// SchemaSyncNone means the table is already consistent
+	SchemaSyncNone SchemaSyncAction = "none"
+	// SchemaSyncCreate means the table does not exist in AresDB yet
+	SchemaSyncCreate SchemaSyncAction = "create"
+	// SchemaSyncUpdate means columns need to be added or deleted
+	SchemaSyncUpdate SchemaSyncAction = "update"
+	// SchemaSyncConflict means the table cannot be synced automatically, see Conflicts
+	SchemaSyncConflict SchemaSyncAction = "conflict"
+)
+
+// ExternalTable is a table definition imported from an external metastore with column types
+// converted to AresDB data types
+type ExternalTable struct {
+	Name    string           `json:"name"`
+	Columns []metaCom.Column `json:"columns"`
+	// columns of types not supported by AresDB, mapped to their external types
+	UnsupportedColumns map[string]string `json:"unsupportedColumns,omitempty"`
+}
+
+// SchemaSyncDiff is the difference between a table in the external metastore and AresDB
+type SchemaSyncDiff struct {
+	Namespace      string           `json:"namespace"`
+	Database       string           `json:"database"`
+	Table          string           `json:"table"`
+	Action         SchemaSyncAction `json:"action"`
+	AddedColumns   []string         `json:"addedColumns,omitempty"`
+	DeletedColumns []string         `json:"deletedColumns,omitempty"`
+	Conflicts      []string         `json:"conflicts,omitempty"`
+	// columns of types not supported by AresDB in the form of name:type
+	SkippedColumns []string `json:"skippedColumns,omitempty"`
+	// whether the change has been applied to AresDB, changes of existing tables are applied by
+	// starting schema rollouts and take effect once all datanodes prepared them
+	Applied bool   `json:"applied"`
+	Error   string `json:"error,omitempty"`
+}
+
+// SchemaSyncReport is the result of a schema sync run
+type SchemaSyncReport struct {
+	Source string `json:"source"`
+	DryRun bool   `json:"dryRun"`
+	// unix seconds when the sync finished
+	SyncedAt int64            `json:"syncedAt"`
+	Diffs    []SchemaSyncDiff `json:"diffs"`
+}
@@ -39,6 +39,16 @@ var (
 	ErrIngestionAssignmentAlreadyExist = errors.New("Ingestion assignment already exists")
 	// ErrInstanceAlreadyExist indicates an instance already exists
 	ErrInstanceAlreadyExist = errors.New("Instance already exists")
+	// ErrNotEnoughIsolationGroups indicates there are fewer isolation groups than replicas
+	ErrNotEnoughIsolationGroups = errors.New("Not enough isolation groups for replicas")
+	// ErrInvalidNumShards indicates number of shards or replicas is invalid
+	ErrInvalidNumShards = errors.New("Invalid number of shards or replicas")
+	// ErrSchemaRolloutInProgress indicates another schema rollout of the table is in progress
+	ErrSchemaRolloutInProgress = errors.New("Schema rollout already in progress")
+	// ErrSchemaRolloutNotReady indicates not all instances have prepared the pending schema
+	ErrSchemaRolloutNotReady = errors.New("Schema rollout not prepared by all instances")
+	// ErrSchemaVersionMismatch indicates schema version does not match the expected one
+	ErrSchemaVersionMismatch = errors.New("Schema version mismatch")
 
 	// ErrJobConfigDoesNotExist indicates job config does not exist
 	ErrJobConfigDoesNotExist = NotExist("Job config does not exist")
@@ -50,6 +60,10 @@ var (
 	ErrInstanceDoesNotExist = NotExist("Instance does not exist")
 	// ErrSubscriberDoesNotExist indicates an subscriber does not exist
 	ErrSubscriberDoesNotExist = NotExist("Subscriber does not exist")
+	// ErrPlacementDoesNotExist indicates placement does not exist
+	ErrPlacementDoesNotExist = NotExist("Placement does not exist")
+	// ErrSchemaRolloutDoesNotExist indicates schema rollout does not exist
+	ErrSchemaRolloutDoesNotExist = NotExist("Schema rollout does not exist")
 )
 
 // IsNonExist check whether error is non exist error
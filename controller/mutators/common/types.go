@@ -14,6 +14,7 @@
 package common
 
 import (
+	"github.com/m3db/m3/src/cluster/placement"
 	"github.com/uber/aresdb/controller/models"
 	"github.com/uber/aresdb/metastore/common"
 )
@@ -28,6 +29,29 @@ type TableSchemaMutator interface {
 	GetHash(namespace string) (string, error)
 }
 
+// SchemaRolloutMutator orchestrates table schema changes across all datanodes of a namespace
+// with a prepare/commit protocol: the pending schema is only committed after every datanode
+// has acknowledged it, and every step is fenced by the schema version
+type SchemaRolloutMutator interface {
+	// StartRollout validates the table against the committed schema and starts the prepare phase
+	StartRollout(namespace string, table common.Table, force bool) (models.SchemaRollout, error)
+	// AddColumn starts the rollout of the table with the column appended
+	AddColumn(namespace, name string, column common.Column, appendToArchivingSortOrder bool) (models.SchemaRollout, error)
+	// DeleteColumn starts the rollout of the table with the column deleted
+	DeleteColumn(namespace, name, column string) (models.SchemaRollout, error)
+	// GetRollout returns the latest rollout of a table
+	GetRollout(namespace, name string) (models.SchemaRollout, error)
+	// GetRollouts returns rollouts in prepare phase of a namespace
+	GetRollouts(namespace string) ([]models.SchemaRollout, error)
+	// AckRollout records the instance has prepared the pending schema of given version,
+	// and commits the rollout once all instances prepared it
+	AckRollout(namespace, name, instance string, version int) error
+	// CommitRollout commits the pending schema of given version if all instances prepared it
+	CommitRollout(namespace, name string, version int) error
+	// AbortRollout discards the pending schema
+	AbortRollout(namespace, name string) error
+}
+
 // SubscriberMutator defines rw operations
 // only read ops needed for now, creating and removing subscriber should be done by each subscriber process
 type SubscriberMutator interface {
@@ -91,3 +115,20 @@ type MembershipMutator interface {
 	// GetHash returns hash of all instances
 	GetHash(namespace string) (string, error)
 }
+
+// PlacementMutator defines rw operations on datanode shard placement
+type PlacementMutator interface {
+	// BuildInitialPlacement builds initial placement for a namespace,
+	// replicas of the same shard are placed into distinct isolation groups, spread across zones first and then racks
+	BuildInitialPlacement(namespace string, numShards int, numReplica int, instances []models.Instance) (placement.Placement, error)
+	// GetCurrentPlacement returns the current placement of a namespace
+	GetCurrentPlacement(namespace string) (placement.Placement, error)
+	// GetZoneViolations returns shard replicas placed in the same isolation group with another replica of the shard
+	GetZoneViolations(namespace string) ([]models.ZoneViolation, error)
+	// RebalanceZones moves replicas violating zone isolation to other instances gradually,
+	// at most maxMoves shard migrations will be in flight at the same time
+	RebalanceZones(namespace string, maxMoves int) (placement.Placement, error)
+	// PromoteStandby replaces the primary instance with the warm standby instance in the placement,
+	// shards of the primary are initializing on the standby until it finishes redolog replay
+	PromoteStandby(namespace string, primary string, standby models.Instance) (placement.Placement, error)
+}
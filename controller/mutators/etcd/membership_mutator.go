@@ -19,6 +19,7 @@ import (
 	"strconv"
 	"unsafe"
 
+	"github.com/m3db/m3/src/cluster/placement"
 	"github.com/m3db/m3/src/cluster/services"
 	"github.com/uber/aresdb/cluster/kvstore"
 	"github.com/uber/aresdb/controller/models"
@@ -63,11 +64,7 @@ func (mm membershipMutatorImpl) GetInstance(namespace, instanceName string) (ins
 
 	for _, instance := range instances {
 		if instance.ID() == instanceName {
-			return models.Instance{
-				Name: instance.ID(),
-				Host: instance.Hostname(),
-				Port: instance.Port(),
-			}, nil
+			return newInstance(instance), nil
 		}
 	}
 
@@ -92,11 +89,7 @@ func (mm membershipMutatorImpl) GetInstances(namespace string) ([]models.Instanc
 
 	result := make([]models.Instance, 0, len(instances))
 	for _, instance := range instances {
-		result = append(result, models.Instance{
-			Name: instance.ID(),
-			Host: instance.Hostname(),
-			Port: instance.Port(),
-		})
+		result = append(result, newInstance(instance))
 	}
 	return result, nil
 }
@@ -125,3 +118,15 @@ func (mm membershipMutatorImpl) GetHash(namespace string) (string, error) {
 	}
 	return strconv.Itoa(int(hash)), nil
 }
+
+// newInstance converts advertised placement instance to external view of instance
+func newInstance(instance placement.Instance) models.Instance {
+	zone, rack := models.DecodeLocality(instance.IsolationGroup())
+	return models.Instance{
+		Name: instance.ID(),
+		Host: instance.Hostname(),
+		Port: instance.Port(),
+		Zone: zone,
+		Rack: rack,
+	}
+}
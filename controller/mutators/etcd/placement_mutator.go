@@ -0,0 +1,368 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package etcd
+
+import (
+	"sort"
+
+	"github.com/m3db/m3/src/cluster/kv"
+	"github.com/m3db/m3/src/cluster/placement"
+	"github.com/m3db/m3/src/cluster/services"
+	"github.com/m3db/m3/src/cluster/shard"
+	"github.com/uber/aresdb/cluster/kvstore"
+	"github.com/uber/aresdb/controller/models"
+	"github.com/uber/aresdb/controller/mutators/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// NewPlacementMutator creates new PlacementMutator
+func NewPlacementMutator(etcdClient *kvstore.EtcdClient) common.PlacementMutator {
+	return placementMutatorImpl{
+		etcdClient: etcdClient,
+	}
+}
+
+type placementMutatorImpl struct {
+	etcdClient *kvstore.EtcdClient
+}
+
+func (pm placementMutatorImpl) placementService(namespace string) (placement.Service, error) {
+	serviceID := services.NewServiceID().
+		SetName(utils.DataNodeServiceName(namespace)).
+		SetEnvironment(pm.etcdClient.Environment).
+		SetZone(pm.etcdClient.Zone)
+
+	placementService, err := pm.etcdClient.Services.PlacementService(serviceID, nil)
+	if err != nil {
+		return nil, utils.StackError(err, "failed to get placement service, namespace: %s", namespace)
+	}
+	return placementService, nil
+}
+
+func (pm placementMutatorImpl) BuildInitialPlacement(namespace string, numShards int, numReplica int, instances []models.Instance) (placement.Placement, error) {
+	placementInstances := make([]placement.Instance, 0, len(instances))
+	for _, instance := range instances {
+		placementInstances = append(placementInstances, pm.newPlacementInstance(instance))
+	}
+
+	p, err := buildZoneAwarePlacement(placementInstances, numShards, numReplica)
+	if err != nil {
+		return nil, err
+	}
+
+	placementService, err := pm.placementService(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return placementService.SetIfNotExist(p)
+}
+
+func (pm placementMutatorImpl) GetCurrentPlacement(namespace string) (placement.Placement, error) {
+	placementService, err := pm.placementService(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := placementService.Placement()
+	if err == kv.ErrNotFound {
+		return nil, common.ErrPlacementDoesNotExist
+	}
+	return p, err
+}
+
+func (pm placementMutatorImpl) GetZoneViolations(namespace string) ([]models.ZoneViolation, error) {
+	p, err := pm.GetCurrentPlacement(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return findZoneViolations(p), nil
+}
+
+func (pm placementMutatorImpl) RebalanceZones(namespace string, maxMoves int) (placement.Placement, error) {
+	placementService, err := pm.placementService(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := placementService.Placement()
+	if err == kv.ErrNotFound {
+		return nil, common.ErrPlacementDoesNotExist
+	} else if err != nil {
+		return nil, err
+	}
+
+	newPlacement, numMoves := moveZoneViolations(p, maxMoves)
+	if numMoves == 0 {
+		return p, nil
+	}
+
+	utils.GetLogger().With("namespace", namespace, "moves", numMoves).Info("rebalancing shards violating zone isolation")
+	return placementService.CheckAndSet(newPlacement, p.Version())
+}
+
+func (pm placementMutatorImpl) PromoteStandby(namespace string, primary string, standby models.Instance) (placement.Placement, error) {
+	placementService, err := pm.placementService(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := placementService.Placement()
+	if err == kv.ErrNotFound {
+		return nil, common.ErrPlacementDoesNotExist
+	} else if err != nil {
+		return nil, err
+	}
+
+	newPlacement, err := replaceInstance(p, primary, pm.newPlacementInstance(standby))
+	if err != nil {
+		return nil, err
+	}
+
+	utils.GetLogger().With("namespace", namespace, "primary", primary, "standby", standby.Name).Info("promoting standby instance")
+	return placementService.CheckAndSet(newPlacement, p.Version())
+}
+
+func (pm placementMutatorImpl) newPlacementInstance(instance models.Instance) placement.Instance {
+	return placement.NewInstance().
+		SetID(instance.Name).
+		SetHostname(instance.Host).
+		SetPort(instance.Port).
+		SetEndpoint(instance.Address).
+		SetIsolationGroup(instance.IsolationGroup()).
+		SetZone(pm.etcdClient.Zone).
+		SetWeight(1).
+		SetShards(shard.NewShards(nil))
+}
+
+// replaceInstance returns a cloned placement with the primary instance replaced by the standby,
+// shards of the primary are initializing on the standby from the primary, except for shards
+// already leaving the primary which are taken over by their new owners
+func replaceInstance(p placement.Placement, primary string, standby placement.Instance) (placement.Placement, error) {
+	newPlacement := p.Clone()
+	primaryInstance, ok := newPlacement.Instance(primary)
+	if !ok {
+		return nil, common.ErrInstanceDoesNotExist
+	}
+	if _, ok := newPlacement.Instance(standby.ID()); ok {
+		return nil, common.ErrInstanceAlreadyExist
+	}
+
+	for _, s := range primaryInstance.Shards().All() {
+		if s.State() == shard.Leaving {
+			continue
+		}
+		standby.Shards().Add(shard.NewShard(s.ID()).
+			SetState(shard.Initializing).
+			SetSourceID(primary))
+	}
+
+	instances := make([]placement.Instance, 0, newPlacement.NumInstances())
+	for _, instance := range newPlacement.Instances() {
+		if instance.ID() != primary {
+			instances = append(instances, instance)
+		}
+	}
+	return newPlacement.SetInstances(append(instances, standby)), nil
+}
+
+// buildZoneAwarePlacement assigns each shard to numReplica instances in distinct isolation groups,
+// preferring instances in distinct zones and then the instances with fewest shards
+func buildZoneAwarePlacement(instances []placement.Instance, numShards int, numReplica int) (placement.Placement, error) {
+	if numShards <= 0 || numReplica <= 0 {
+		return nil, common.ErrInvalidNumShards
+	}
+
+	isolationGroups := make(map[string]struct{})
+	for _, instance := range instances {
+		isolationGroups[instance.IsolationGroup()] = struct{}{}
+	}
+	if len(isolationGroups) < numReplica {
+		return nil, common.ErrNotEnoughIsolationGroups
+	}
+
+	shardIDs := make([]uint32, numShards)
+	for i := range shardIDs {
+		shardIDs[i] = uint32(i)
+		usedGroups := make(map[string]struct{})
+		usedZones := make(map[string]struct{})
+		for r := 0; r < numReplica; r++ {
+			target := pickLeastLoadedInstance(instances, usedGroups, usedZones)
+			target.Shards().Add(shard.NewShard(shardIDs[i]).SetState(shard.Available))
+			usedGroups[target.IsolationGroup()] = struct{}{}
+			usedZones[zoneOf(target)] = struct{}{}
+		}
+	}
+
+	return placement.NewPlacement().
+		SetInstances(instances).
+		SetShards(shardIDs).
+		SetReplicaFactor(numReplica).
+		SetIsSharded(true), nil
+}
+
+// findZoneViolations returns replicas sharing isolation group with another replica of the same shard,
+// or sharing zone with another replica while some zone holds no replica of the shard. For each shard
+// the replicas are kept in order of instance id and the others are reported, at most as many zone
+// sharing replicas as free zones are reported since the rest can only be spread across racks.
+// replicas already leaving are not counted as they are being moved away
+func findZoneViolations(p placement.Placement) []models.ZoneViolation {
+	zones := make(map[string]struct{})
+	for _, instance := range p.Instances() {
+		zones[zoneOf(instance)] = struct{}{}
+	}
+
+	var violations []models.ZoneViolation
+	for _, shardID := range p.Shards() {
+		owners := activeShardOwners(p, shardID)
+		usedZones := make(map[string]struct{})
+		for _, instance := range owners {
+			usedZones[zoneOf(instance)] = struct{}{}
+		}
+		freeZones := len(zones) - len(usedZones)
+
+		seenGroups := make(map[string]struct{})
+		seenZones := make(map[string]struct{})
+		for _, instance := range owners {
+			group, zone := instance.IsolationGroup(), zoneOf(instance)
+			_, groupSeen := seenGroups[group]
+			_, zoneSeen := seenZones[zone]
+			if !groupSeen && (!zoneSeen || freeZones <= 0) {
+				seenGroups[group] = struct{}{}
+				seenZones[zone] = struct{}{}
+				continue
+			}
+			if !groupSeen {
+				freeZones--
+			}
+			violations = append(violations, models.ZoneViolation{
+				Shard:          shardID,
+				Instance:       instance.ID(),
+				IsolationGroup: group,
+			})
+		}
+	}
+	return violations
+}
+
+// moveZoneViolations returns a cloned placement with replicas violating zone isolation marked as leaving,
+// and new replicas initializing from them on instances in unused isolation groups, preferring unused zones.
+// Only up to maxMoves shard migrations (including ones started by previous rebalances) will be in flight.
+func moveZoneViolations(p placement.Placement, maxMoves int) (placement.Placement, int) {
+	newPlacement := p.Clone()
+	budget := maxMoves - numShardsInitializing(newPlacement)
+
+	numMoves := 0
+	for _, violation := range findZoneViolations(newPlacement) {
+		if budget <= 0 {
+			break
+		}
+
+		usedGroups := make(map[string]struct{})
+		usedZones := make(map[string]struct{})
+		groupShared := false
+		for _, instance := range shardOwners(newPlacement, violation.Shard) {
+			usedGroups[instance.IsolationGroup()] = struct{}{}
+			s, _ := instance.Shards().Shard(violation.Shard)
+			if s.State() == shard.Leaving || instance.ID() == violation.Instance {
+				continue
+			}
+			usedZones[zoneOf(instance)] = struct{}{}
+			groupShared = groupShared || instance.IsolationGroup() == violation.IsolationGroup
+		}
+		target := pickLeastLoadedInstance(newPlacement.Instances(), usedGroups, usedZones)
+		if target != nil && !groupShared {
+			// replicas only sharing zone have to be moved to a free zone
+			if _, ok := usedZones[zoneOf(target)]; ok {
+				target = nil
+			}
+		}
+		if target == nil {
+			utils.GetLogger().With("shard", violation.Shard, "instance", violation.Instance).
+				Warn("no instance available in other isolation groups")
+			continue
+		}
+
+		source, _ := newPlacement.Instance(violation.Instance)
+		s, _ := source.Shards().Shard(violation.Shard)
+		s.SetState(shard.Leaving)
+		target.Shards().Add(shard.NewShard(violation.Shard).
+			SetState(shard.Initializing).
+			SetSourceID(violation.Instance))
+
+		budget--
+		numMoves++
+	}
+	return newPlacement, numMoves
+}
+
+// pickLeastLoadedInstance returns the instance with fewest non leaving shards among instances
+// not in excluded isolation groups. Instances outside used zones are preferred so replicas
+// are spread across zones first and then across racks within a zone, ties are broken by instance id
+func pickLeastLoadedInstance(instances []placement.Instance, excludedGroups, usedZones map[string]struct{}) placement.Instance {
+	var target placement.Instance
+	targetLoad, targetZoneUsed := 0, false
+	for _, instance := range instances {
+		if _, ok := excludedGroups[instance.IsolationGroup()]; ok {
+			continue
+		}
+		_, zoneUsed := usedZones[zoneOf(instance)]
+		load := loadOf(instance)
+		if target == nil || (targetZoneUsed && !zoneUsed) ||
+			(targetZoneUsed == zoneUsed && (load < targetLoad || (load == targetLoad && instance.ID() < target.ID()))) {
+			target = instance
+			targetLoad = load
+			targetZoneUsed = zoneUsed
+		}
+	}
+	return target
+}
+
+// shardOwners returns instances owning the shard in any state sorted by instance id
+func shardOwners(p placement.Placement, shardID uint32) []placement.Instance {
+	owners := p.InstancesForShard(shardID)
+	sort.Slice(owners, func(i, j int) bool {
+		return owners[i].ID() < owners[j].ID()
+	})
+	return owners
+}
+
+// activeShardOwners returns instances owning the shard and not leaving it sorted by instance id
+func activeShardOwners(p placement.Placement, shardID uint32) []placement.Instance {
+	var owners []placement.Instance
+	for _, instance := range shardOwners(p, shardID) {
+		if s, _ := instance.Shards().Shard(shardID); s.State() != shard.Leaving {
+			owners = append(owners, instance)
+		}
+	}
+	return owners
+}
+
+// zoneOf returns the zone of the instance decoded from its isolation group, instances without
+// zone label are treated as their own zones
+func zoneOf(instance placement.Instance) string {
+	zone, _ := models.DecodeLocality(instance.IsolationGroup())
+	return zone
+}
+
+func numShardsInitializing(p placement.Placement) int {
+	count := 0
+	for _, instance := range p.Instances() {
+		count += instance.Shards().NumShardsForState(shard.Initializing)
+	}
+	return count
+}
+
+func loadOf(instance placement.Instance) int {
+	return instance.Shards().NumShards() - instance.Shards().NumShardsForState(shard.Leaving)
+}
@@ -0,0 +1,314 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package etcd
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/m3db/m3/src/cluster/kv/mem"
+	"github.com/m3db/m3/src/cluster/placement"
+	"github.com/m3db/m3/src/cluster/services"
+	"github.com/m3db/m3/src/cluster/shard"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber/aresdb/cluster/kvstore"
+	"github.com/uber/aresdb/controller/models"
+	"github.com/uber/aresdb/controller/mutators/common"
+)
+
+func TestPlacementMutator(t *testing.T) {
+	newInstance := func(id, group string, shards ...shard.Shard) placement.Instance {
+		return placement.NewInstance().
+			SetID(id).
+			SetIsolationGroup(group).
+			SetWeight(1).
+			SetShards(shard.NewShards(shards))
+	}
+
+	assertZoneIsolated := func(t *testing.T, p placement.Placement) {
+		for _, shardID := range p.Shards() {
+			groups := make(map[string]struct{})
+			for _, instance := range p.InstancesForShard(shardID) {
+				s, _ := instance.Shards().Shard(shardID)
+				if s.State() == shard.Leaving {
+					continue
+				}
+				_, exist := groups[instance.IsolationGroup()]
+				assert.False(t, exist, "shard %d has multiple replicas in %s", shardID, instance.IsolationGroup())
+				groups[instance.IsolationGroup()] = struct{}{}
+			}
+		}
+	}
+
+	t.Run("locality labels should be encoded into isolation group", func(t *testing.T) {
+		assert.Equal(t, "zone1/rack1", models.EncodeLocality("zone1", "rack1"))
+		assert.Equal(t, "zone1", models.EncodeLocality("zone1", ""))
+		zone, rack := models.DecodeLocality("zone1/rack1")
+		assert.Equal(t, "zone1", zone)
+		assert.Equal(t, "rack1", rack)
+		zone, rack = models.DecodeLocality("")
+		assert.Equal(t, "", zone)
+		assert.Equal(t, "", rack)
+
+		assert.Equal(t, "zone1/rack1", models.Instance{Name: "inst1", Zone: "zone1", Rack: "rack1"}.IsolationGroup())
+		assert.Equal(t, "zone1", models.Instance{Name: "inst1", Zone: "zone1"}.IsolationGroup())
+		assert.Equal(t, "rack1", models.Instance{Name: "inst1", Rack: "rack1"}.IsolationGroup())
+		assert.Equal(t, "inst1", models.Instance{Name: "inst1"}.IsolationGroup())
+	})
+
+	t.Run("initial placement should place replicas into distinct zones", func(t *testing.T) {
+		instances := []placement.Instance{
+			newInstance("inst1", "zone1"),
+			newInstance("inst2", "zone1"),
+			newInstance("inst3", "zone2"),
+			newInstance("inst4", "zone2"),
+			newInstance("inst5", "zone3"),
+		}
+		p, err := buildZoneAwarePlacement(instances, 8, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, 8, p.NumShards())
+		assert.Equal(t, 3, p.ReplicaFactor())
+		for _, shardID := range p.Shards() {
+			assert.Len(t, p.InstancesForShard(shardID), 3)
+		}
+		assertZoneIsolated(t, p)
+		assert.Empty(t, findZoneViolations(p))
+
+		_, err = buildZoneAwarePlacement([]placement.Instance{
+			newInstance("inst1", "zone1"),
+			newInstance("inst2", "zone1"),
+			newInstance("inst3", "zone2"),
+		}, 8, 3)
+		assert.Equal(t, common.ErrNotEnoughIsolationGroups, err)
+
+		_, err = buildZoneAwarePlacement(instances, 0, 3)
+		assert.Equal(t, common.ErrInvalidNumShards, err)
+	})
+
+	t.Run("initial placement should spread replicas across racks within a zone", func(t *testing.T) {
+		instances := []placement.Instance{
+			newInstance("inst1", "zone1/rack1"),
+			newInstance("inst2", "zone1/rack1"),
+			newInstance("inst3", "zone1/rack2"),
+			newInstance("inst4", "zone2/rack1"),
+		}
+		p, err := buildZoneAwarePlacement(instances, 4, 3)
+		assert.NoError(t, err)
+		assertZoneIsolated(t, p)
+		for _, shardID := range p.Shards() {
+			zones := make(map[string]int)
+			for _, instance := range p.InstancesForShard(shardID) {
+				zones[zoneOf(instance)]++
+			}
+			// both zones are used, the third replica goes to the other rack of zone1
+			assert.Equal(t, map[string]int{"zone1": 2, "zone2": 1}, zones)
+		}
+		assert.Empty(t, findZoneViolations(p))
+	})
+
+	t.Run("rebalance should move replicas sharing zone to free zones", func(t *testing.T) {
+		// shard 0 has both replicas in zone1 on different racks while zone2 is free
+		p := placement.NewPlacement().
+			SetInstances([]placement.Instance{
+				newInstance("inst1", "zone1/rack1", shard.NewShard(0).SetState(shard.Available)),
+				newInstance("inst2", "zone1/rack2", shard.NewShard(0).SetState(shard.Available)),
+				newInstance("inst3", "zone1/rack3"),
+				newInstance("inst4", "zone2/rack1"),
+			}).
+			SetShards([]uint32{0}).
+			SetReplicaFactor(2).
+			SetIsSharded(true)
+
+		assert.Equal(t, []models.ZoneViolation{
+			{Shard: 0, Instance: "inst2", IsolationGroup: "zone1/rack2"},
+		}, findZoneViolations(p))
+
+		// the replica is moved to the free zone instead of the less loaded rack of the same zone
+		p1, numMoves := moveZoneViolations(p, 1)
+		assert.Equal(t, 1, numMoves)
+		inst4, _ := p1.Instance("inst4")
+		s, _ := inst4.Shards().Shard(0)
+		assert.Equal(t, shard.Initializing, s.State())
+		assert.Equal(t, "inst2", s.SourceID())
+		assert.Empty(t, findZoneViolations(p1))
+
+		// replicas on different racks of the same zone are fine when no zone is free
+		p = placement.NewPlacement().
+			SetInstances([]placement.Instance{
+				newInstance("inst1", "zone1/rack1", shard.NewShard(0).SetState(shard.Available)),
+				newInstance("inst2", "zone1/rack2", shard.NewShard(0).SetState(shard.Available)),
+			}).
+			SetShards([]uint32{0}).
+			SetReplicaFactor(2).
+			SetIsSharded(true)
+		assert.Empty(t, findZoneViolations(p))
+	})
+
+	t.Run("rebalance should move violating replicas gradually", func(t *testing.T) {
+		// shard 0 and 1 both have two replicas in zone1
+		p := placement.NewPlacement().
+			SetInstances([]placement.Instance{
+				newInstance("inst1", "zone1",
+					shard.NewShard(0).SetState(shard.Available),
+					shard.NewShard(1).SetState(shard.Available)),
+				newInstance("inst2", "zone1",
+					shard.NewShard(0).SetState(shard.Available),
+					shard.NewShard(1).SetState(shard.Available)),
+				newInstance("inst3", "zone2"),
+				newInstance("inst4", "zone3"),
+			}).
+			SetShards([]uint32{0, 1}).
+			SetReplicaFactor(2).
+			SetIsSharded(true)
+
+		assert.Equal(t, []models.ZoneViolation{
+			{Shard: 0, Instance: "inst2", IsolationGroup: "zone1"},
+			{Shard: 1, Instance: "inst2", IsolationGroup: "zone1"},
+		}, findZoneViolations(p))
+
+		// only one move allowed in flight
+		p1, numMoves := moveZoneViolations(p, 1)
+		assert.Equal(t, 1, numMoves)
+		inst2, _ := p1.Instance("inst2")
+		s, _ := inst2.Shards().Shard(0)
+		assert.Equal(t, shard.Leaving, s.State())
+		inst3, _ := p1.Instance("inst3")
+		s, _ = inst3.Shards().Shard(0)
+		assert.Equal(t, shard.Initializing, s.State())
+		assert.Equal(t, "inst2", s.SourceID())
+		assert.Equal(t, []models.ZoneViolation{
+			{Shard: 1, Instance: "inst2", IsolationGroup: "zone1"},
+		}, findZoneViolations(p1))
+
+		// original placement is not changed
+		assert.Len(t, findZoneViolations(p), 2)
+
+		// no more moves until the in flight one finishes
+		_, numMoves = moveZoneViolations(p1, 1)
+		assert.Equal(t, 0, numMoves)
+
+		// move the rest with larger budget, the least loaded instance is picked
+		p2, numMoves := moveZoneViolations(p1, 2)
+		assert.Equal(t, 1, numMoves)
+		inst4, _ := p2.Instance("inst4")
+		s, _ = inst4.Shards().Shard(1)
+		assert.Equal(t, shard.Initializing, s.State())
+		assert.Equal(t, "inst2", s.SourceID())
+		assert.Empty(t, findZoneViolations(p2))
+		assertZoneIsolated(t, p2)
+	})
+
+	t.Run("rebalance should update placement through placement service", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		clusterService := services.NewMockServices(ctrl)
+		placementService := placement.NewMockService(ctrl)
+		clusterService.EXPECT().PlacementService(gomock.Any(), gomock.Any()).Return(placementService, nil).AnyTimes()
+
+		etcdClient := &kvstore.EtcdClient{
+			ServiceName: "ares-controller",
+			Environment: "test",
+			Zone:        "local",
+			Services:    clusterService,
+			TxnStore:    mem.NewStore(),
+		}
+
+		p := placement.NewPlacement().
+			SetInstances([]placement.Instance{
+				newInstance("inst1", "zone1", shard.NewShard(0).SetState(shard.Available)),
+				newInstance("inst2", "zone1", shard.NewShard(0).SetState(shard.Available)),
+				newInstance("inst3", "zone2"),
+			}).
+			SetShards([]uint32{0}).
+			SetReplicaFactor(2).
+			SetIsSharded(true).
+			SetVersion(3)
+
+		placementService.EXPECT().Placement().Return(p, nil).Times(2)
+		placementService.EXPECT().CheckAndSet(gomock.Any(), 3).DoAndReturn(func(newPlacement placement.Placement, version int) (placement.Placement, error) {
+			return newPlacement, nil
+		}).Times(1)
+
+		placementMutator := NewPlacementMutator(etcdClient)
+		violations, err := placementMutator.GetZoneViolations("ns1")
+		assert.NoError(t, err)
+		assert.Len(t, violations, 1)
+
+		newPlacement, err := placementMutator.RebalanceZones("ns1", 1)
+		assert.NoError(t, err)
+		assert.Empty(t, findZoneViolations(newPlacement))
+		assertZoneIsolated(t, newPlacement)
+	})
+
+	t.Run("promote standby should replace primary instance", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		clusterService := services.NewMockServices(ctrl)
+		placementService := placement.NewMockService(ctrl)
+		clusterService.EXPECT().PlacementService(gomock.Any(), gomock.Any()).Return(placementService, nil).AnyTimes()
+
+		etcdClient := &kvstore.EtcdClient{
+			ServiceName: "ares-controller",
+			Environment: "test",
+			Zone:        "local",
+			Services:    clusterService,
+			TxnStore:    mem.NewStore(),
+		}
+
+		p := placement.NewPlacement().
+			SetInstances([]placement.Instance{
+				newInstance("inst1", "zone1",
+					shard.NewShard(0).SetState(shard.Available),
+					shard.NewShard(1).SetState(shard.Leaving)),
+				newInstance("inst2", "zone2",
+					shard.NewShard(0).SetState(shard.Available),
+					shard.NewShard(1).SetState(shard.Initializing).SetSourceID("inst1")),
+			}).
+			SetShards([]uint32{0, 1}).
+			SetReplicaFactor(2).
+			SetIsSharded(true).
+			SetVersion(5)
+
+		placementService.EXPECT().Placement().Return(p, nil).Times(3)
+		placementService.EXPECT().CheckAndSet(gomock.Any(), 5).DoAndReturn(func(newPlacement placement.Placement, version int) (placement.Placement, error) {
+			return newPlacement, nil
+		}).Times(1)
+
+		placementMutator := NewPlacementMutator(etcdClient)
+		newPlacement, err := placementMutator.PromoteStandby("ns1", "inst1", models.Instance{
+			Name:    "standby1",
+			Host:    "host3",
+			Port:    9374,
+			Address: "host3:9374",
+			Zone:    "zone1",
+		})
+		assert.NoError(t, err)
+		_, exist := newPlacement.Instance("inst1")
+		assert.False(t, exist)
+		standby, exist := newPlacement.Instance("standby1")
+		assert.True(t, exist)
+		assert.Equal(t, "host3:9374", standby.Endpoint())
+		assert.Equal(t, []uint32{0}, standby.Shards().AllIDs())
+		s, _ := standby.Shards().Shard(0)
+		assert.Equal(t, shard.Initializing, s.State())
+		assert.Equal(t, "inst1", s.SourceID())
+
+		_, err = placementMutator.PromoteStandby("ns1", "inst3", models.Instance{Name: "standby1"})
+		assert.Equal(t, common.ErrInstanceDoesNotExist, err)
+		_, err = placementMutator.PromoteStandby("ns1", "inst1", models.Instance{Name: "inst2"})
+		assert.Equal(t, common.ErrInstanceAlreadyExist, err)
+	})
+}
@@ -208,25 +208,41 @@ func (m *tableSchemaMutator) deleteEnum(namespace string, table *metaCom.Table)
 }
 
 func (m *tableSchemaMutator) UpdateTable(namespace string, table metaCom.Table, force bool) (err error) {
-	tableListProto, tableListVersion, err := readEntityList(m.txnStore, utils.SchemaListKey(namespace))
+	txn, found, err := m.buildUpdateTableTxn(namespace, &table, force)
 	if err != nil {
 		return err
 	}
 
-	tableListProto, found := updateEntity(tableListProto, table.Name)
 	if !found {
 		m.logger.With(
 			"table", table,
 		).Info("table not found for update, creating new table")
 		return m.CreateTable(namespace, &table, force)
 	}
+	return txn.WriteTo(m.txnStore)
+}
+
+// buildUpdateTableTxn validates the table update and builds the transaction to write it,
+// table will be merged with existing configs and its version will be bumped.
+// found will be false if table does not exist in table list
+func (m *tableSchemaMutator) buildUpdateTableTxn(namespace string, table *metaCom.Table, force bool) (txn *kvstore.Transaction, found bool, err error) {
+	tableListProto, tableListVersion, err := readEntityList(m.txnStore, utils.SchemaListKey(namespace))
+	if err != nil {
+		return
+	}
+
+	tableListProto, found = updateEntity(tableListProto, table.Name)
+	if !found {
+		return
+	}
 
 	schemaProto, schemaVersion, err := m.readSchema(namespace, table.Name)
 	if err != nil {
-		return err
+		return
 	}
 	if schemaProto.Tomstoned {
-		return metaCom.ErrTableDoesNotExist
+		err = metaCom.ErrTableDoesNotExist
+		return
 	}
 
 	var oldTable metaCom.Table
@@ -237,6 +253,7 @@ func (m *tableSchemaMutator) UpdateTable(namespace string, table metaCom.Table,
 
 	// always use old table's incarnation for update table operation will not modify incarnation
 	table.Incarnation = oldTable.Incarnation
+	table.Version = oldTable.Version + 1
 
 	// merge existing table and column level configs if not specified in the input
 	if (metaCom.TableConfig{}) == table.Config {
@@ -255,7 +272,7 @@ func (m *tableSchemaMutator) UpdateTable(namespace string, table metaCom.Table,
 
 	if !force {
 		validator := metastore.NewTableSchameValidator()
-		validator.SetNewTable(table)
+		validator.SetNewTable(*table)
 		validator.SetOldTable(oldTable)
 		err = validator.Validate()
 		if err != nil {
@@ -269,13 +286,13 @@ func (m *tableSchemaMutator) UpdateTable(namespace string, table metaCom.Table,
 		return
 	}
 
-	txn := kvstore.NewTransaction().
+	txn = kvstore.NewTransaction().
 		AddKeyValue(utils.SchemaListKey(namespace), tableListVersion, &tableListProto).
 		AddKeyValue(utils.SchemaKey(namespace, table.Name), schemaVersion, &schemaProto)
 
 	// for new columns, pre-create enum nodes
-	preCreateEnumNodes(txn, namespace, &table, len(oldTable.Columns), len(table.Columns))
-	return txn.WriteTo(m.txnStore)
+	preCreateEnumNodes(txn, namespace, table, len(oldTable.Columns), len(table.Columns))
+	return
 }
 
 func preCreateEnumNodes(txn *kvstore.Transaction, namespace string, table *metaCom.Table, startColumnID int, endColumnID int) {
@@ -142,6 +142,8 @@ func TestSchemaMutator(t *testing.T) {
 		expectedTable2.Config = defaultConfig
 		// default should not overwrite explicit config
 		expectedTable2.Config.BatchSize = 100
+		// version should be bumped on update
+		expectedTable2.Version = 1
 		assert.Equal(t, expectedTable2, *table2)
 
 		err = schemaMutator.DeleteTable("ns1", "test1")
@@ -0,0 +1,271 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package etcd
+
+import (
+	"encoding/json"
+
+	"github.com/m3db/m3/src/cluster/kv"
+	pb "github.com/uber/aresdb/controller/generated/proto"
+	"github.com/uber/aresdb/controller/models"
+	"github.com/uber/aresdb/controller/mutators/common"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	"github.com/uber/aresdb/utils"
+	"go.uber.org/zap"
+)
+
+// NewSchemaRolloutMutator returns a new SchemaRolloutMutator
+func NewSchemaRolloutMutator(store kv.TxnStore, membershipMutator common.MembershipMutator, logger *zap.SugaredLogger) common.SchemaRolloutMutator {
+	return &schemaRolloutMutator{
+		txnStore: store,
+		schemaMutator: &tableSchemaMutator{
+			txnStore: store,
+			logger:   logger,
+		},
+		membershipMutator: membershipMutator,
+		logger:            logger,
+	}
+}
+
+type schemaRolloutMutator struct {
+	txnStore          kv.TxnStore
+	schemaMutator     *tableSchemaMutator
+	membershipMutator common.MembershipMutator
+	logger            *zap.SugaredLogger
+}
+
+func (m *schemaRolloutMutator) StartRollout(namespace string, table metaCom.Table, force bool) (rollout models.SchemaRollout, err error) {
+	rollout, rolloutVersion, err := m.readRollout(namespace, table.Name)
+	if err == nil && rollout.Phase == models.SchemaRolloutPrepare {
+		return rollout, common.ErrSchemaRolloutInProgress
+	} else if err != nil && !common.IsNonExist(err) {
+		return rollout, err
+	}
+
+	// validate and merge table with committed schema without writing it,
+	// the version of pending schema will be bumped
+	_, found, err := m.schemaMutator.buildUpdateTableTxn(namespace, &table, force)
+	if err != nil {
+		return
+	}
+	if !found {
+		err = metaCom.ErrTableDoesNotExist
+		return
+	}
+
+	rollout = models.SchemaRollout{
+		Table:   table.Name,
+		Version: table.Version,
+		Phase:   models.SchemaRolloutPrepare,
+		Schema:  table,
+	}
+	err = m.writeRollout(namespace, rollout, rolloutVersion)
+	if err == nil {
+		m.logger.With("namespace", namespace, "table", table.Name, "version", table.Version).Info("started schema rollout")
+	}
+	return
+}
+
+func (m *schemaRolloutMutator) AddColumn(namespace, name string, column metaCom.Column, appendToArchivingSortOrder bool) (rollout models.SchemaRollout, err error) {
+	table, err := m.schemaMutator.GetTable(namespace, name)
+	if err != nil {
+		return
+	}
+
+	newColumnID := len(table.Columns)
+	table.Columns = append(table.Columns, column)
+	if appendToArchivingSortOrder {
+		table.ArchivingSortColumns = append(table.ArchivingSortColumns, newColumnID)
+	}
+	return m.StartRollout(namespace, *table, false)
+}
+
+func (m *schemaRolloutMutator) DeleteColumn(namespace, name, columnName string) (rollout models.SchemaRollout, err error) {
+	table, err := m.schemaMutator.GetTable(namespace, name)
+	if err != nil {
+		return
+	}
+
+	for id, column := range table.Columns {
+		// deleted columns may share the name with the live one
+		if column.Name != columnName || column.Deleted {
+			continue
+		}
+		if table.IsFactTable && id == 0 {
+			err = metaCom.ErrDeleteTimeColumn
+			return
+		}
+		if utils.IndexOfInt(table.PrimaryKeyColumns, id) >= 0 {
+			err = metaCom.ErrDeletePrimaryKeyColumn
+			return
+		}
+		table.Columns[id].Deleted = true
+		return m.StartRollout(namespace, *table, false)
+	}
+	err = metaCom.ErrColumnDoesNotExist
+	return
+}
+
+func (m *schemaRolloutMutator) GetRollout(namespace, name string) (rollout models.SchemaRollout, err error) {
+	rollout, _, err = m.readRollout(namespace, name)
+	return
+}
+
+func (m *schemaRolloutMutator) GetRollouts(namespace string) (rollouts []models.SchemaRollout, err error) {
+	tableNames, err := m.schemaMutator.ListTables(namespace)
+	if err != nil {
+		return
+	}
+
+	rollouts = make([]models.SchemaRollout, 0)
+	for _, tableName := range tableNames {
+		rollout, _, err := m.readRollout(namespace, tableName)
+		if common.IsNonExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if rollout.Phase == models.SchemaRolloutPrepare {
+			rollouts = append(rollouts, rollout)
+		}
+	}
+	return rollouts, nil
+}
+
+func (m *schemaRolloutMutator) AckRollout(namespace, name, instance string, version int) error {
+	rollout, rolloutVersion, err := m.readRollout(namespace, name)
+	if err != nil {
+		return err
+	}
+
+	if rollout.Phase != models.SchemaRolloutPrepare || rollout.Version != version {
+		return common.ErrSchemaVersionMismatch
+	}
+
+	if !rollout.IsPreparedBy(instance) {
+		rollout.PreparedInstances = append(rollout.PreparedInstances, instance)
+		if err = m.writeRollout(namespace, rollout, rolloutVersion); err != nil {
+			return err
+		}
+	}
+
+	// the last instance to prepare commits the rollout, repeated acks retry failed commits
+	err = m.CommitRollout(namespace, name, version)
+	if err == common.ErrSchemaRolloutNotReady {
+		return nil
+	}
+	return err
+}
+
+func (m *schemaRolloutMutator) CommitRollout(namespace, name string, version int) error {
+	rollout, rolloutVersion, err := m.readRollout(namespace, name)
+	if err != nil {
+		return err
+	}
+
+	if rollout.Phase != models.SchemaRolloutPrepare || rollout.Version != version {
+		return common.ErrSchemaVersionMismatch
+	}
+
+	instances, err := m.membershipMutator.GetInstances(namespace)
+	if err != nil {
+		return err
+	}
+	for _, instance := range instances {
+		if !rollout.IsPreparedBy(instance.Name) {
+			m.logger.With("namespace", namespace, "table", name, "version", version, "instance", instance.Name).
+				Info("schema rollout not prepared by instance")
+			return common.ErrSchemaRolloutNotReady
+		}
+	}
+
+	// pending schema has been validated when rollout started, as long as the committed
+	// schema is not changed since then (fenced by version), it's safe to skip validation
+	table := rollout.Schema
+	txn, found, err := m.schemaMutator.buildUpdateTableTxn(namespace, &table, true)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return metaCom.ErrTableDoesNotExist
+	}
+	if table.Version != rollout.Version {
+		return common.ErrSchemaVersionMismatch
+	}
+
+	rollout.Phase = models.SchemaRolloutCommitted
+	rolloutProto, err := rolloutToProto(rollout)
+	if err != nil {
+		return err
+	}
+
+	// commit schema and rollout phase atomically
+	err = txn.AddKeyValue(utils.SchemaRolloutKey(namespace, name), rolloutVersion, &rolloutProto).
+		WriteTo(m.txnStore)
+	if err == nil {
+		m.logger.With("namespace", namespace, "table", name, "version", version).Info("committed schema rollout")
+	}
+	return err
+}
+
+func (m *schemaRolloutMutator) AbortRollout(namespace, name string) error {
+	rollout, rolloutVersion, err := m.readRollout(namespace, name)
+	if err != nil {
+		return err
+	}
+
+	if rollout.Phase != models.SchemaRolloutPrepare {
+		return common.ErrSchemaRolloutDoesNotExist
+	}
+
+	rollout.Phase = models.SchemaRolloutAborted
+	err = m.writeRollout(namespace, rollout, rolloutVersion)
+	if err == nil {
+		m.logger.With("namespace", namespace, "table", name, "version", rollout.Version).Info("aborted schema rollout")
+	}
+	return err
+}
+
+func (m *schemaRolloutMutator) readRollout(namespace, name string) (rollout models.SchemaRollout, version int, err error) {
+	var rolloutProto pb.EntityConfig
+	version, err = readValue(m.txnStore, utils.SchemaRolloutKey(namespace, name), &rolloutProto)
+	if common.IsNonExist(err) {
+		return rollout, kv.UninitializedVersion, common.ErrSchemaRolloutDoesNotExist
+	} else if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(rolloutProto.Config, &rollout)
+	return
+}
+
+func (m *schemaRolloutMutator) writeRollout(namespace string, rollout models.SchemaRollout, version int) error {
+	rolloutProto, err := rolloutToProto(rollout)
+	if err != nil {
+		return err
+	}
+
+	if version == kv.UninitializedVersion {
+		_, err = m.txnStore.SetIfNotExists(utils.SchemaRolloutKey(namespace, rollout.Table), &rolloutProto)
+	} else {
+		_, err = m.txnStore.CheckAndSet(utils.SchemaRolloutKey(namespace, rollout.Table), version, &rolloutProto)
+	}
+	return err
+}
+
+func rolloutToProto(rollout models.SchemaRollout) (rolloutProto pb.EntityConfig, err error) {
+	rolloutProto.Name = rollout.Table
+	rolloutProto.Config, err = json.Marshal(rollout)
+	return
+}
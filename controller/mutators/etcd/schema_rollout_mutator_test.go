@@ -0,0 +1,215 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package etcd
+
+import (
+	"testing"
+
+	"github.com/m3db/m3/src/cluster/kv/mem"
+	"github.com/stretchr/testify/assert"
+	pb "github.com/uber/aresdb/controller/generated/proto"
+	"github.com/uber/aresdb/controller/models"
+	"github.com/uber/aresdb/controller/mutators/common"
+	"github.com/uber/aresdb/controller/mutators/mocks"
+	"github.com/uber/aresdb/metastore"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	"github.com/uber/aresdb/utils"
+	"go.uber.org/zap"
+)
+
+func TestSchemaRolloutMutator(t *testing.T) {
+	testTable := metaCom.Table{
+		Name: "test1",
+		Columns: []metaCom.Column{
+			{
+				Name: "col1",
+				Type: "Int32",
+			},
+		},
+		PrimaryKeyColumns: []int{0},
+		Config:            metastore.DefaultTableConfig,
+	}
+
+	testTableWithNewColumn := metaCom.Table{
+		Name: "test1",
+		Columns: []metaCom.Column{
+			{
+				Name: "col1",
+				Type: "Int32",
+			},
+			{
+				Name: "col2",
+				Type: "Int32",
+			},
+		},
+		PrimaryKeyColumns: []int{0},
+		Config:            metastore.DefaultTableConfig,
+	}
+
+	instances := []models.Instance{
+		{Name: "inst1"},
+		{Name: "inst2"},
+	}
+
+	setup := func(t *testing.T) (*schemaRolloutMutator, *mocks.MembershipMutator) {
+		store := mem.NewStore()
+		_, err := store.Set(utils.SchemaListKey("ns1"), &pb.EntityList{})
+		assert.NoError(t, err)
+
+		membershipMutator := &mocks.MembershipMutator{}
+		rolloutMutator := NewSchemaRolloutMutator(store, membershipMutator, zap.NewExample().Sugar()).(*schemaRolloutMutator)
+		table := testTable
+		assert.NoError(t, rolloutMutator.schemaMutator.CreateTable("ns1", &table, false))
+		return rolloutMutator, membershipMutator
+	}
+
+	t.Run("rollout should commit after all instances prepared", func(t *testing.T) {
+		rolloutMutator, membershipMutator := setup(t)
+		membershipMutator.On("GetInstances", "ns1").Return(instances, nil)
+
+		rollout, err := rolloutMutator.StartRollout("ns1", testTableWithNewColumn, false)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, rollout.Version)
+		assert.Equal(t, models.SchemaRolloutPrepare, rollout.Phase)
+
+		// committed schema is not changed during prepare phase
+		table, err := rolloutMutator.schemaMutator.GetTable("ns1", "test1")
+		assert.NoError(t, err)
+		assert.Len(t, table.Columns, 1)
+		assert.Equal(t, 0, table.Version)
+
+		// another rollout of the same table is rejected
+		_, err = rolloutMutator.StartRollout("ns1", testTableWithNewColumn, false)
+		assert.Equal(t, common.ErrSchemaRolloutInProgress, err)
+
+		rollouts, err := rolloutMutator.GetRollouts("ns1")
+		assert.NoError(t, err)
+		assert.Len(t, rollouts, 1)
+
+		assert.NoError(t, rolloutMutator.AckRollout("ns1", "test1", "inst1", 1))
+		// ack is idempotent
+		assert.NoError(t, rolloutMutator.AckRollout("ns1", "test1", "inst1", 1))
+		// ack with stale version is fenced
+		assert.Equal(t, common.ErrSchemaVersionMismatch, rolloutMutator.AckRollout("ns1", "test1", "inst2", 0))
+
+		assert.Equal(t, common.ErrSchemaRolloutNotReady, rolloutMutator.CommitRollout("ns1", "test1", 1))
+
+		// the last ack commits the rollout
+		assert.NoError(t, rolloutMutator.AckRollout("ns1", "test1", "inst2", 1))
+		assert.Equal(t, common.ErrSchemaVersionMismatch, rolloutMutator.CommitRollout("ns1", "test1", 1))
+
+		table, err = rolloutMutator.schemaMutator.GetTable("ns1", "test1")
+		assert.NoError(t, err)
+		assert.Len(t, table.Columns, 2)
+		assert.Equal(t, 1, table.Version)
+
+		rollout, err = rolloutMutator.GetRollout("ns1", "test1")
+		assert.NoError(t, err)
+		assert.Equal(t, models.SchemaRolloutCommitted, rollout.Phase)
+		assert.Equal(t, []string{"inst1", "inst2"}, rollout.PreparedInstances)
+
+		rollouts, err = rolloutMutator.GetRollouts("ns1")
+		assert.NoError(t, err)
+		assert.Empty(t, rollouts)
+	})
+
+	t.Run("commit should be fenced by schema version", func(t *testing.T) {
+		rolloutMutator, membershipMutator := setup(t)
+		membershipMutator.On("GetInstances", "ns1").Return(instances, nil)
+
+		_, err := rolloutMutator.StartRollout("ns1", testTableWithNewColumn, false)
+		assert.NoError(t, err)
+		assert.NoError(t, rolloutMutator.AckRollout("ns1", "test1", "inst1", 1))
+
+		// schema updated directly during rollout
+		assert.NoError(t, rolloutMutator.schemaMutator.UpdateTable("ns1", testTable, true))
+		assert.Equal(t, common.ErrSchemaVersionMismatch, rolloutMutator.AckRollout("ns1", "test1", "inst2", 1))
+		assert.Equal(t, common.ErrSchemaVersionMismatch, rolloutMutator.CommitRollout("ns1", "test1", 1))
+
+		table, err := rolloutMutator.schemaMutator.GetTable("ns1", "test1")
+		assert.NoError(t, err)
+		assert.Len(t, table.Columns, 1)
+	})
+
+	t.Run("abort should discard pending schema", func(t *testing.T) {
+		rolloutMutator, _ := setup(t)
+
+		_, err := rolloutMutator.GetRollout("ns1", "test1")
+		assert.Equal(t, common.ErrSchemaRolloutDoesNotExist, err)
+		assert.Equal(t, common.ErrSchemaRolloutDoesNotExist, rolloutMutator.AbortRollout("ns1", "test1"))
+
+		_, err = rolloutMutator.StartRollout("ns1", testTableWithNewColumn, false)
+		assert.NoError(t, err)
+		assert.NoError(t, rolloutMutator.AbortRollout("ns1", "test1"))
+
+		rollout, err := rolloutMutator.GetRollout("ns1", "test1")
+		assert.NoError(t, err)
+		assert.Equal(t, models.SchemaRolloutAborted, rollout.Phase)
+		assert.Equal(t, common.ErrSchemaVersionMismatch, rolloutMutator.AckRollout("ns1", "test1", "inst1", 1))
+		assert.Equal(t, common.ErrSchemaVersionMismatch, rolloutMutator.CommitRollout("ns1", "test1", 1))
+
+		// new rollout can be started after abort
+		_, err = rolloutMutator.StartRollout("ns1", testTableWithNewColumn, false)
+		assert.NoError(t, err)
+	})
+
+	t.Run("column changes should be rolled out", func(t *testing.T) {
+		rolloutMutator, membershipMutator := setup(t)
+		membershipMutator.On("GetInstances", "ns1").Return(instances[:1], nil)
+
+		rollout, err := rolloutMutator.AddColumn("ns1", "test1", metaCom.Column{Name: "col2", Type: "Int32"}, false)
+		assert.NoError(t, err)
+		assert.Equal(t, testTableWithNewColumn.Columns, rollout.Schema.Columns)
+
+		// column is only added once the rollout is committed
+		table, err := rolloutMutator.schemaMutator.GetTable("ns1", "test1")
+		assert.NoError(t, err)
+		assert.Len(t, table.Columns, 1)
+		assert.NoError(t, rolloutMutator.AckRollout("ns1", "test1", "inst1", 1))
+		table, err = rolloutMutator.schemaMutator.GetTable("ns1", "test1")
+		assert.NoError(t, err)
+		assert.Len(t, table.Columns, 2)
+
+		_, err = rolloutMutator.DeleteColumn("ns1", "test1", "col1")
+		assert.Equal(t, metaCom.ErrDeletePrimaryKeyColumn, err)
+		_, err = rolloutMutator.DeleteColumn("ns1", "test1", "col3")
+		assert.Equal(t, metaCom.ErrColumnDoesNotExist, err)
+
+		rollout, err = rolloutMutator.DeleteColumn("ns1", "test1", "col2")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, rollout.Version)
+		assert.True(t, rollout.Schema.Columns[1].Deleted)
+		assert.NoError(t, rolloutMutator.AckRollout("ns1", "test1", "inst1", 2))
+		table, err = rolloutMutator.schemaMutator.GetTable("ns1", "test1")
+		assert.NoError(t, err)
+		assert.True(t, table.Columns[1].Deleted)
+	})
+
+	t.Run("start should fail for invalid schema change", func(t *testing.T) {
+		rolloutMutator, _ := setup(t)
+
+		invalidTable := testTable
+		invalidTable.PrimaryKeyColumns = nil
+		_, err := rolloutMutator.StartRollout("ns1", invalidTable, false)
+		assert.Error(t, err)
+
+		nonExistTable := testTable
+		nonExistTable.Name = "test2"
+		_, err = rolloutMutator.StartRollout("ns1", nonExistTable, false)
+		assert.Equal(t, metaCom.ErrTableDoesNotExist, err)
+
+		_, err = rolloutMutator.GetRollout("ns1", "test1")
+		assert.Equal(t, common.ErrSchemaRolloutDoesNotExist, err)
+	})
+}
@@ -0,0 +1,139 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Code generated by mockery v1.0.0
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+import models "github.com/uber/aresdb/controller/models"
+import placement "github.com/m3db/m3/src/cluster/placement"
+
+// PlacementMutator is an autogenerated mock type for the PlacementMutator type
+type PlacementMutator struct {
+	mock.Mock
+}
+
+// BuildInitialPlacement provides a mock function with given fields: namespace, numShards, numReplica, instances
+func (_m *PlacementMutator) BuildInitialPlacement(namespace string, numShards int, numReplica int, instances []models.Instance) (placement.Placement, error) {
+	ret := _m.Called(namespace, numShards, numReplica, instances)
+
+	var r0 placement.Placement
+	if rf, ok := ret.Get(0).(func(string, int, int, []models.Instance) placement.Placement); ok {
+		r0 = rf(namespace, numShards, numReplica, instances)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(placement.Placement)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, int, int, []models.Instance) error); ok {
+		r1 = rf(namespace, numShards, numReplica, instances)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCurrentPlacement provides a mock function with given fields: namespace
+func (_m *PlacementMutator) GetCurrentPlacement(namespace string) (placement.Placement, error) {
+	ret := _m.Called(namespace)
+
+	var r0 placement.Placement
+	if rf, ok := ret.Get(0).(func(string) placement.Placement); ok {
+		r0 = rf(namespace)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(placement.Placement)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(namespace)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetZoneViolations provides a mock function with given fields: namespace
+func (_m *PlacementMutator) GetZoneViolations(namespace string) ([]models.ZoneViolation, error) {
+	ret := _m.Called(namespace)
+
+	var r0 []models.ZoneViolation
+	if rf, ok := ret.Get(0).(func(string) []models.ZoneViolation); ok {
+		r0 = rf(namespace)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.ZoneViolation)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(namespace)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PromoteStandby provides a mock function with given fields: namespace, primary, standby
+func (_m *PlacementMutator) PromoteStandby(namespace string, primary string, standby models.Instance) (placement.Placement, error) {
+	ret := _m.Called(namespace, primary, standby)
+
+	var r0 placement.Placement
+	if rf, ok := ret.Get(0).(func(string, string, models.Instance) placement.Placement); ok {
+		r0 = rf(namespace, primary, standby)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(placement.Placement)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, models.Instance) error); ok {
+		r1 = rf(namespace, primary, standby)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RebalanceZones provides a mock function with given fields: namespace, maxMoves
+func (_m *PlacementMutator) RebalanceZones(namespace string, maxMoves int) (placement.Placement, error) {
+	ret := _m.Called(namespace, maxMoves)
+
+	var r0 placement.Placement
+	if rf, ok := ret.Get(0).(func(string, int) placement.Placement); ok {
+		r0 = rf(namespace, maxMoves)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(placement.Placement)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, int) error); ok {
+		r1 = rf(namespace, maxMoves)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,173 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Code generated by mockery v1.0.0
+package mocks
+
+import common "github.com/uber/aresdb/metastore/common"
+import mock "github.com/stretchr/testify/mock"
+import models "github.com/uber/aresdb/controller/models"
+
+// SchemaRolloutMutator is an autogenerated mock type for the SchemaRolloutMutator type
+type SchemaRolloutMutator struct {
+	mock.Mock
+}
+
+// AbortRollout provides a mock function with given fields: namespace, name
+func (_m *SchemaRolloutMutator) AbortRollout(namespace string, name string) error {
+	ret := _m.Called(namespace, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(namespace, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AckRollout provides a mock function with given fields: namespace, name, instance, version
+func (_m *SchemaRolloutMutator) AckRollout(namespace string, name string, instance string, version int) error {
+	ret := _m.Called(namespace, name, instance, version)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string, int) error); ok {
+		r0 = rf(namespace, name, instance, version)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AddColumn provides a mock function with given fields: namespace, name, column, appendToArchivingSortOrder
+func (_m *SchemaRolloutMutator) AddColumn(namespace string, name string, column common.Column, appendToArchivingSortOrder bool) (models.SchemaRollout, error) {
+	ret := _m.Called(namespace, name, column, appendToArchivingSortOrder)
+
+	var r0 models.SchemaRollout
+	if rf, ok := ret.Get(0).(func(string, string, common.Column, bool) models.SchemaRollout); ok {
+		r0 = rf(namespace, name, column, appendToArchivingSortOrder)
+	} else {
+		r0 = ret.Get(0).(models.SchemaRollout)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, common.Column, bool) error); ok {
+		r1 = rf(namespace, name, column, appendToArchivingSortOrder)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CommitRollout provides a mock function with given fields: namespace, name, version
+func (_m *SchemaRolloutMutator) CommitRollout(namespace string, name string, version int) error {
+	ret := _m.Called(namespace, name, version)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, int) error); ok {
+		r0 = rf(namespace, name, version)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteColumn provides a mock function with given fields: namespace, name, column
+func (_m *SchemaRolloutMutator) DeleteColumn(namespace string, name string, column string) (models.SchemaRollout, error) {
+	ret := _m.Called(namespace, name, column)
+
+	var r0 models.SchemaRollout
+	if rf, ok := ret.Get(0).(func(string, string, string) models.SchemaRollout); ok {
+		r0 = rf(namespace, name, column)
+	} else {
+		r0 = ret.Get(0).(models.SchemaRollout)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, string) error); ok {
+		r1 = rf(namespace, name, column)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRollout provides a mock function with given fields: namespace, name
+func (_m *SchemaRolloutMutator) GetRollout(namespace string, name string) (models.SchemaRollout, error) {
+	ret := _m.Called(namespace, name)
+
+	var r0 models.SchemaRollout
+	if rf, ok := ret.Get(0).(func(string, string) models.SchemaRollout); ok {
+		r0 = rf(namespace, name)
+	} else {
+		r0 = ret.Get(0).(models.SchemaRollout)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(namespace, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRollouts provides a mock function with given fields: namespace
+func (_m *SchemaRolloutMutator) GetRollouts(namespace string) ([]models.SchemaRollout, error) {
+	ret := _m.Called(namespace)
+
+	var r0 []models.SchemaRollout
+	if rf, ok := ret.Get(0).(func(string) []models.SchemaRollout); ok {
+		r0 = rf(namespace)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.SchemaRollout)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(namespace)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StartRollout provides a mock function with given fields: namespace, table, force
+func (_m *SchemaRolloutMutator) StartRollout(namespace string, table common.Table, force bool) (models.SchemaRollout, error) {
+	ret := _m.Called(namespace, table, force)
+
+	var r0 models.SchemaRollout
+	if rf, ok := ret.Get(0).(func(string, common.Table, bool) models.SchemaRollout); ok {
+		r0 = rf(namespace, table, force)
+	} else {
+		r0 = ret.Get(0).(models.SchemaRollout)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, common.Table, bool) error); ok {
+		r1 = rf(namespace, table, force)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -16,6 +16,7 @@ package common
 import (
 	"github.com/uber-go/tally"
 	"github.com/uber/aresdb/cluster/kvstore"
+	"github.com/uber/aresdb/controller/models"
 	"github.com/uber/aresdb/controller/mutators/common"
 	"go.uber.org/config"
 	"go.uber.org/zap"
@@ -35,6 +36,25 @@ type IngestionAssignmentTaskParams struct {
 	SubscriberMutator  common.SubscriberMutator
 }
 
+// SchemaSyncTaskParams defines all parameters needed to create a SchemaSyncTask
+type SchemaSyncTaskParams struct {
+	ConfigProvider config.Provider
+	Logger         *zap.SugaredLogger
+	Scope          tally.Scope
+
+	EtcdClient           *kvstore.EtcdClient
+	SchemaMutator        common.TableSchemaMutator
+	SchemaRolloutMutator common.SchemaRolloutMutator
+}
+
+// ExternalMetastore reads table definitions from an external metastore like Hive or Schemaless
+type ExternalMetastore interface {
+	// Name returns the name of the metastore type
+	Name() string
+	// GetTable returns the definition of the table in the database
+	GetTable(database, table string) (models.ExternalTable, error)
+}
+
 // Task is the interface for a long running task
 type Task interface {
 	Run()
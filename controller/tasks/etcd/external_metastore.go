@@ -0,0 +1,193 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package etcd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uber/aresdb/controller/models"
+	"github.com/uber/aresdb/controller/tasks/common"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	hiveMetastoreType       = "hive"
+	schemalessMetastoreType = "schemaless"
+
+	externalMetastoreTimeout = 30 * time.Second
+)
+
+var hiveDecimalType = regexp.MustCompile(`^decimal\((\d+),\s*(\d+)\)$`)
+
+// externalColumn is a column definition returned by external metastores
+type externalColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// NewExternalMetastore creates the external metastore client of the type
+func NewExternalMetastore(metastoreType, address, user string) (common.ExternalMetastore, error) {
+	client := &http.Client{Timeout: externalMetastoreTimeout}
+	switch metastoreType {
+	case hiveMetastoreType:
+		return &hiveMetastore{address: strings.TrimSuffix(address, "/"), user: user, client: client}, nil
+	case schemalessMetastoreType:
+		return &schemalessMetastore{address: strings.TrimSuffix(address, "/"), client: client}, nil
+	}
+	return nil, fmt.Errorf("unknown external metastore type %s", metastoreType)
+}
+
+// hiveMetastore reads table definitions from Hive through the WebHCat rest api
+type hiveMetastore struct {
+	address string
+	user    string
+	client  *http.Client
+}
+
+// Name returns the name of the metastore type
+func (h *hiveMetastore) Name() string {
+	return hiveMetastoreType
+}
+
+// GetTable returns the definition of the table in the database
+func (h *hiveMetastore) GetTable(database, table string) (models.ExternalTable, error) {
+	path := fmt.Sprintf("%s/templeton/v1/ddl/database/%s/table/%s?user.name=%s", h.address,
+		url.PathEscape(database), url.PathEscape(table), url.QueryEscape(h.user))
+	var response struct {
+		Columns []externalColumn `json:"columns"`
+	}
+	if err := getExternalSchema(h.client, path, &response); err != nil {
+		return models.ExternalTable{}, err
+	}
+	return convertExternalTable(table, response.Columns, hiveColumnType), nil
+}
+
+// hiveColumnType converts hive column types to AresDB data types, returns false if the type
+// is not supported
+func hiveColumnType(externalType string) (metaCom.Column, bool) {
+	externalType = strings.ToLower(strings.TrimSpace(externalType))
+	switch {
+	case externalType == "boolean":
+		return metaCom.Column{Type: metaCom.Bool}, true
+	case externalType == "tinyint":
+		return metaCom.Column{Type: metaCom.Int8}, true
+	case externalType == "smallint":
+		return metaCom.Column{Type: metaCom.Int16}, true
+	case externalType == "int" || externalType == "integer":
+		return metaCom.Column{Type: metaCom.Int32}, true
+	case externalType == "bigint":
+		return metaCom.Column{Type: metaCom.Int64}, true
+	case externalType == "float" || externalType == "double":
+		return metaCom.Column{Type: metaCom.Float32}, true
+	case externalType == "string" || strings.HasPrefix(externalType, "varchar") || strings.HasPrefix(externalType, "char"):
+		return metaCom.Column{Type: metaCom.BigEnum}, true
+	case externalType == "timestamp" || externalType == "date":
+		return metaCom.Column{Type: metaCom.Uint32}, true
+	}
+	if matches := hiveDecimalType.FindStringSubmatch(externalType); matches != nil {
+		precision, _ := strconv.Atoi(matches[1])
+		scale, _ := strconv.Atoi(matches[2])
+		// decimals are stored as int64.
+		if precision <= 18 {
+			return metaCom.Column{Type: metaCom.Decimal, DecimalScale: scale}, true
+		}
+	}
+	return metaCom.Column{}, false
+}
+
+// schemalessMetastore reads table definitions from the schema service of Schemaless
+type schemalessMetastore struct {
+	address string
+	client  *http.Client
+}
+
+// Name returns the name of the metastore type
+func (s *schemalessMetastore) Name() string {
+	return schemalessMetastoreType
+}
+
+// GetTable returns the definition of the table in the database
+func (s *schemalessMetastore) GetTable(database, table string) (models.ExternalTable, error) {
+	path := fmt.Sprintf("%s/schemas/%s/tables/%s", s.address, url.PathEscape(database), url.PathEscape(table))
+	var response struct {
+		Columns []externalColumn `json:"columns"`
+	}
+	if err := getExternalSchema(s.client, path, &response); err != nil {
+		return models.ExternalTable{}, err
+	}
+	return convertExternalTable(table, response.Columns, schemalessColumnType), nil
+}
+
+// schemalessColumnType converts schemaless column types to AresDB data types, returns false if
+// the type is not supported
+func schemalessColumnType(externalType string) (metaCom.Column, bool) {
+	switch strings.ToLower(strings.TrimSpace(externalType)) {
+	case "boolean", "bool":
+		return metaCom.Column{Type: metaCom.Bool}, true
+	case "int", "integer":
+		return metaCom.Column{Type: metaCom.Int32}, true
+	case "long":
+		return metaCom.Column{Type: metaCom.Int64}, true
+	case "float", "double":
+		return metaCom.Column{Type: metaCom.Float32}, true
+	case "string":
+		return metaCom.Column{Type: metaCom.BigEnum}, true
+	case "uuid":
+		return metaCom.Column{Type: metaCom.UUID}, true
+	case "timestamp":
+		return metaCom.Column{Type: metaCom.Uint32}, true
+	}
+	return metaCom.Column{}, false
+}
+
+func getExternalSchema(client *http.Client, path string, response interface{}) error {
+	resp, err := client.Get(path)
+	if err != nil {
+		return utils.StackError(err, "failed to fetch schema from %s", path)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return utils.StackError(nil, "failed to fetch schema from %s, status code %d", path, resp.StatusCode)
+	}
+	if err = json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return utils.StackError(err, "failed to decode schema from %s", path)
+	}
+	return nil
+}
+
+func convertExternalTable(name string, columns []externalColumn,
+	columnType func(externalType string) (metaCom.Column, bool)) models.ExternalTable {
+	table := models.ExternalTable{Name: name}
+	for _, externalColumn := range columns {
+		column, ok := columnType(externalColumn.Type)
+		if !ok {
+			if table.UnsupportedColumns == nil {
+				table.UnsupportedColumns = make(map[string]string)
+			}
+			table.UnsupportedColumns[externalColumn.Name] = externalColumn.Type
+			continue
+		}
+		column.Name = externalColumn.Name
+		table.Columns = append(table.Columns, column)
+	}
+	return table
+}
@@ -0,0 +1,395 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package etcd
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/m3db/m3/src/cluster/services"
+	"github.com/uber-go/tally"
+	apiCom "github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/controller/models"
+	mutators "github.com/uber/aresdb/controller/mutators/common"
+	"github.com/uber/aresdb/controller/tasks/common"
+	"github.com/uber/aresdb/metastore"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	"github.com/uber/aresdb/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	schemaSyncConfigKey       = "schemaSyncTask"
+	schemaSyncTaskTagValue    = "schemaSyncTask"
+	schemaSyncAppliedMetric   = "schema_sync_applied"
+	schemaSyncConflictMetric  = "schema_sync_conflict"
+	schemaSyncErrorMetricName = "schema_sync_error"
+)
+
+type schemaSyncTaskConfig struct {
+	IntervalInSeconds int `yaml:"intervalInSeconds"`
+	// dry run only reports the diffs without applying them to AresDB
+	DryRun bool `yaml:"dryRun"`
+	// whether to delete AresDB columns dropped from the external metastore
+	DeleteColumns bool `yaml:"deleteColumns"`
+	Source        struct {
+		// hive or schemaless
+		Type    string `yaml:"type"`
+		Address string `yaml:"address"`
+		User    string `yaml:"user"`
+	} `yaml:"source"`
+	Tables []schemaSyncTableConfig `yaml:"tables"`
+}
+
+// schemaSyncTableConfig maps a table of the external metastore to an AresDB namespace
+type schemaSyncTableConfig struct {
+	Namespace string `yaml:"namespace"`
+	Database  string `yaml:"database"`
+	Name      string `yaml:"name"`
+	// names of primary key columns, required to create the table
+	PrimaryKey []string `yaml:"primaryKey"`
+	// time column of fact tables, empty for dimension tables
+	TimeColumn string `yaml:"timeColumn"`
+}
+
+// SchemaSyncTask imports table definitions from an external metastore and keeps AresDB
+// schemas consistent with them. Only the leader controller applies the changes, diffs of the
+// last run and dry-run diffs are reported via admin endpoints.
+type SchemaSyncTask struct {
+	sync.RWMutex
+
+	config    schemaSyncTaskConfig
+	logger    *zap.SugaredLogger
+	scope     tally.Scope
+	stopChan  chan struct{}
+	metastore common.ExternalMetastore
+
+	schemaMutator  mutators.TableSchemaMutator
+	rolloutMutator mutators.SchemaRolloutMutator
+	leaderElection LeaderElector
+
+	lastReport *models.SchemaSyncReport
+}
+
+// NewSchemaSyncTask creates a new SchemaSyncTask
+func NewSchemaSyncTask(p common.SchemaSyncTaskParams) *SchemaSyncTask {
+	var cfg schemaSyncTaskConfig
+	logger := p.Logger.With("task", schemaSyncTaskTagValue)
+	scope := p.Scope.Tagged(map[string]string{"task": schemaSyncTaskTagValue})
+
+	if err := p.ConfigProvider.Get(schemaSyncConfigKey).Populate(&cfg); err != nil {
+		logger.Fatal("failed to load config")
+	}
+	externalMetastore, err := NewExternalMetastore(cfg.Source.Type, cfg.Source.Address, cfg.Source.User)
+	if err != nil {
+		logger.With("error", err.Error()).Fatal("failed to create external metastore")
+	}
+
+	serviceID := services.NewServiceID().
+		SetEnvironment(p.EtcdClient.Environment).
+		SetZone(p.EtcdClient.Zone).
+		SetName(p.EtcdClient.ServiceName)
+	leaderService, err := p.EtcdClient.Services.LeaderService(serviceID, nil)
+	if err != nil {
+		logger.Fatal("failed to create leader service")
+	}
+
+	return &SchemaSyncTask{
+		config:         cfg,
+		logger:         logger,
+		scope:          scope,
+		stopChan:       make(chan struct{}, 1),
+		metastore:      externalMetastore,
+		schemaMutator:  p.SchemaMutator,
+		rolloutMutator: p.SchemaRolloutMutator,
+		leaderElection: NewLeaderElector(leaderService),
+	}
+}
+
+// Run starts the SchemaSyncTask
+func (s *SchemaSyncTask) Run() {
+	hostName, _ := os.Hostname()
+
+	// wait random interval to avoid herd effect electing for leader on cluster reboot
+	time.Sleep(time.Duration(rand.Intn(5)) * time.Second)
+
+	if err := s.leaderElection.Start(); err != nil {
+		s.logger.With("host", hostName, "error", err.Error()).Error("failed to start leader election")
+		s.scope.Counter("task_failed").Inc(1)
+		return
+	}
+	defer func() {
+		if err := s.leaderElection.Close(); err != nil {
+			s.logger.Error(err)
+		}
+	}()
+
+	var ticker *time.Ticker
+	var tickerChan <-chan time.Time
+	stopTicker := func() {
+		if ticker != nil {
+			ticker.Stop()
+			ticker, tickerChan = nil, nil
+		}
+	}
+	defer stopTicker()
+
+	for {
+		select {
+		case <-s.leaderElection.C():
+			if s.leaderElection.Status() != Leader {
+				stopTicker()
+				continue
+			}
+			if ticker == nil {
+				s.logger.With("host", hostName).Infof("elected as leader")
+				ticker = time.NewTicker(time.Duration(s.config.IntervalInSeconds) * time.Second)
+				tickerChan = ticker.C
+			}
+		case <-tickerChan:
+			if s.leaderElection.Status() != Leader {
+				stopTicker()
+				continue
+			}
+			report := s.Sync(s.config.DryRun)
+			s.Lock()
+			s.lastReport = &report
+			s.Unlock()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// Done stops the task
+func (s *SchemaSyncTask) Done() {
+	s.logger.Info("killing schema sync task")
+	close(s.stopChan)
+}
+
+// Sync computes the diffs of all configured tables, and applies them unless dryRun is set.
+func (s *SchemaSyncTask) Sync(dryRun bool) models.SchemaSyncReport {
+	report := models.SchemaSyncReport{
+		Source: s.metastore.Name(),
+		DryRun: dryRun,
+	}
+
+	for _, tableConfig := range s.config.Tables {
+		diff := models.SchemaSyncDiff{
+			Namespace: tableConfig.Namespace,
+			Database:  tableConfig.Database,
+			Table:     tableConfig.Name,
+		}
+		newTable, err := s.diffTable(tableConfig, &diff)
+		if err == nil && !dryRun && newTable != nil {
+			if diff.Action == models.SchemaSyncCreate {
+				err = s.schemaMutator.CreateTable(tableConfig.Namespace, newTable, false)
+			} else {
+				// column changes are rolled out to all datanodes before being committed
+				_, err = s.rolloutMutator.StartRollout(tableConfig.Namespace, *newTable, false)
+			}
+			diff.Applied = err == nil
+		}
+
+		if err == mutators.ErrSchemaRolloutInProgress {
+			// the diff will be applied by the next sync after the pending rollout finishes
+			s.logger.With("namespace", tableConfig.Namespace, "table", tableConfig.Name).
+				Info("schema rollout in progress, skipped syncing table schema")
+		} else if err != nil {
+			diff.Error = err.Error()
+			s.scope.Counter(schemaSyncErrorMetricName).Inc(1)
+			s.logger.With("namespace", tableConfig.Namespace, "table", tableConfig.Name,
+				"error", err.Error()).Error("failed to sync table schema")
+		} else if diff.Applied {
+			s.scope.Counter(schemaSyncAppliedMetric).Inc(1)
+			s.logger.With("namespace", tableConfig.Namespace, "table", tableConfig.Name,
+				"diff", diff).Info("synced table schema")
+		} else if diff.Action == models.SchemaSyncConflict {
+			s.scope.Counter(schemaSyncConflictMetric).Inc(1)
+		}
+		report.Diffs = append(report.Diffs, diff)
+	}
+	report.SyncedAt = utils.Now().Unix()
+	return report
+}
+
+// diffTable fills the diff between the external and the AresDB table, and returns the AresDB
+// table to create or update if there is any change that can be applied.
+func (s *SchemaSyncTask) diffTable(tableConfig schemaSyncTableConfig, diff *models.SchemaSyncDiff) (*metaCom.Table, error) {
+	external, err := s.metastore.GetTable(tableConfig.Database, tableConfig.Name)
+	if err != nil {
+		return nil, err
+	}
+	// columns of unsupported types are skipped instead of blocking the sync of other columns.
+	for column, externalType := range external.UnsupportedColumns {
+		diff.SkippedColumns = append(diff.SkippedColumns, fmt.Sprintf("%s:%s", column, externalType))
+	}
+	sort.Strings(diff.SkippedColumns)
+
+	existing, err := s.schemaMutator.GetTable(tableConfig.Namespace, tableConfig.Name)
+	if err == metaCom.ErrTableDoesNotExist {
+		return newSyncedTable(tableConfig, external, diff), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return updateSyncedTable(*existing, external, s.config.DeleteColumns, diff), nil
+}
+
+// newSyncedTable creates the AresDB table for the external table, the time column of fact
+// tables is placed as the first column.
+func newSyncedTable(tableConfig schemaSyncTableConfig, external models.ExternalTable, diff *models.SchemaSyncDiff) *metaCom.Table {
+	table := &metaCom.Table{
+		Name:        tableConfig.Name,
+		IsFactTable: tableConfig.TimeColumn != "",
+		Config:      metastore.DefaultTableConfig,
+	}
+
+	columnIDs := make(map[string]int)
+	if table.IsFactTable {
+		for _, column := range external.Columns {
+			if column.Name == tableConfig.TimeColumn {
+				if column.Type != metaCom.Uint32 {
+					diff.Conflicts = append(diff.Conflicts, fmt.Sprintf("time column %s is of type %s", column.Name, column.Type))
+				}
+				columnIDs[column.Name] = 0
+				table.Columns = append(table.Columns, column)
+			}
+		}
+		if len(table.Columns) == 0 {
+			diff.Conflicts = append(diff.Conflicts, fmt.Sprintf("time column %s does not exist", tableConfig.TimeColumn))
+		}
+	}
+	for _, column := range external.Columns {
+		if _, exist := columnIDs[column.Name]; !exist {
+			columnIDs[column.Name] = len(table.Columns)
+			table.Columns = append(table.Columns, column)
+		}
+		diff.AddedColumns = append(diff.AddedColumns, column.Name)
+	}
+
+	if len(tableConfig.PrimaryKey) == 0 {
+		diff.Conflicts = append(diff.Conflicts, "primary key is not configured")
+	}
+	for _, name := range tableConfig.PrimaryKey {
+		columnID, exist := columnIDs[name]
+		if !exist {
+			diff.Conflicts = append(diff.Conflicts, fmt.Sprintf("primary key column %s does not exist", name))
+			continue
+		}
+		table.PrimaryKeyColumns = append(table.PrimaryKeyColumns, columnID)
+	}
+
+	if len(diff.Conflicts) > 0 {
+		diff.Action = models.SchemaSyncConflict
+		return nil
+	}
+	diff.Action = models.SchemaSyncCreate
+	return table
+}
+
+// updateSyncedTable adds columns of the external table missing in the AresDB table and deletes
+// columns dropped from the external table if deleteColumns is set. Column types cannot be
+// changed and are reported as conflicts.
+func updateSyncedTable(table metaCom.Table, external models.ExternalTable, deleteColumns bool, diff *models.SchemaSyncDiff) *metaCom.Table {
+	// columns are shared with the table returned by the schema mutator.
+	table.Columns = append([]metaCom.Column(nil), table.Columns...)
+	existingColumns := make(map[string]int)
+	for id, column := range table.Columns {
+		if !column.Deleted {
+			existingColumns[column.Name] = id
+		}
+	}
+
+	externalColumns := make(map[string]struct{})
+	for _, column := range external.Columns {
+		externalColumns[column.Name] = struct{}{}
+		id, exist := existingColumns[column.Name]
+		if !exist {
+			table.Columns = append(table.Columns, column)
+			diff.AddedColumns = append(diff.AddedColumns, column.Name)
+			continue
+		}
+		if existing := table.Columns[id]; existing.Type != column.Type {
+			diff.Conflicts = append(diff.Conflicts, fmt.Sprintf("column %s is of type %s in AresDB but %s externally",
+				column.Name, existing.Type, column.Type))
+		}
+	}
+
+	if deleteColumns {
+		primaryKeyColumns := make(map[int]struct{})
+		for _, id := range table.PrimaryKeyColumns {
+			primaryKeyColumns[id] = struct{}{}
+		}
+		for id, column := range table.Columns {
+			if _, exist := externalColumns[column.Name]; exist || column.Deleted {
+				continue
+			}
+			// primary key and time columns cannot be deleted.
+			if _, isPrimaryKey := primaryKeyColumns[id]; isPrimaryKey || (table.IsFactTable && id == 0) {
+				diff.Conflicts = append(diff.Conflicts, fmt.Sprintf("column %s cannot be deleted", column.Name))
+				continue
+			}
+			table.Columns[id].Deleted = true
+			diff.DeletedColumns = append(diff.DeletedColumns, column.Name)
+		}
+	}
+
+	switch {
+	case len(diff.Conflicts) > 0:
+		diff.Action = models.SchemaSyncConflict
+		return nil
+	case len(diff.AddedColumns) == 0 && len(diff.DeletedColumns) == 0:
+		diff.Action = models.SchemaSyncNone
+		return nil
+	}
+	diff.Action = models.SchemaSyncUpdate
+	return &table
+}
+
+// LastReport returns the report of the last sync run by this controller, nil if not synced yet
+func (s *SchemaSyncTask) LastReport() *models.SchemaSyncReport {
+	s.RLock()
+	defer s.RUnlock()
+	return s.lastReport
+}
+
+// Register registers the admin endpoints of schema sync
+func (s *SchemaSyncTask) Register(router *mux.Router, wrappers ...utils.HTTPHandlerWrapper) {
+	router.HandleFunc("/report", utils.ApplyHTTPWrappers(s.GetReport, wrappers)).Methods(http.MethodGet)
+	router.HandleFunc("/dry-run", utils.ApplyHTTPWrappers(s.DryRun, wrappers)).Methods(http.MethodPost)
+}
+
+// GetReport responds with the report of the last sync
+func (s *SchemaSyncTask) GetReport(w http.ResponseWriter, r *http.Request) {
+	report := s.LastReport()
+	if report == nil {
+		apiCom.RespondWithError(w, utils.APIError{
+			Code:    http.StatusNotFound,
+			Message: "schema has not been synced by this controller",
+		})
+		return
+	}
+	apiCom.RespondWithJSONObject(w, report)
+}
+
+// DryRun responds with the diffs between the external metastore and AresDB without applying them
+func (s *SchemaSyncTask) DryRun(w http.ResponseWriter, r *http.Request) {
+	apiCom.RespondWithJSONObject(w, s.Sync(true))
+}
@@ -0,0 +1,179 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package etcd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/uber-go/tally"
+	"github.com/uber/aresdb/controller/models"
+	mutatorCom "github.com/uber/aresdb/controller/mutators/common"
+	"github.com/uber/aresdb/controller/mutators/mocks"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	"go.uber.org/zap"
+)
+
+func TestSchemaSyncTask(t *testing.T) {
+	hiveServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "ares", r.URL.Query().Get("user.name"))
+		switch r.URL.Path {
+		case "/templeton/v1/ddl/database/db1/table/trips":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"columns": []externalColumn{
+					{Name: "request_at", Type: "timestamp"},
+					{Name: "uuid", Type: "string"},
+					{Name: "fare", Type: "decimal(10,2)"},
+					{Name: "tags", Type: "array<string>"},
+				},
+			})
+		case "/templeton/v1/ddl/database/db1/table/cities":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"columns": []externalColumn{
+					{Name: "id", Type: "int"},
+					{Name: "name", Type: "varchar(64)"},
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer hiveServer.Close()
+
+	hive, err := NewExternalMetastore("hive", hiveServer.URL, "ares")
+	assert.NoError(t, err)
+	_, err = NewExternalMetastore("unknown", hiveServer.URL, "ares")
+	assert.Error(t, err)
+
+	newTaskWithRollout := func(schemaMutator *mocks.TableSchemaMutator, rolloutMutator *mocks.SchemaRolloutMutator, tables ...schemaSyncTableConfig) *SchemaSyncTask {
+		task := &SchemaSyncTask{
+			logger:         zap.NewNop().Sugar(),
+			scope:          tally.NoopScope,
+			metastore:      hive,
+			schemaMutator:  schemaMutator,
+			rolloutMutator: rolloutMutator,
+		}
+		task.config.DeleteColumns = true
+		task.config.Tables = tables
+		return task
+	}
+
+	newTask := func(schemaMutator *mocks.TableSchemaMutator, tables ...schemaSyncTableConfig) *SchemaSyncTask {
+		return newTaskWithRollout(schemaMutator, &mocks.SchemaRolloutMutator{}, tables...)
+	}
+
+	t.Run("creates missing tables", func(t *testing.T) {
+		schemaMutator := &mocks.TableSchemaMutator{}
+		schemaMutator.On("GetTable", "ns1", "trips").Return(nil, metaCom.ErrTableDoesNotExist)
+		var created *metaCom.Table
+		schemaMutator.On("CreateTable", "ns1", mock.Anything, false).Run(func(args mock.Arguments) {
+			created = args.Get(1).(*metaCom.Table)
+		}).Return(nil)
+
+		task := newTask(schemaMutator, schemaSyncTableConfig{
+			Namespace: "ns1", Database: "db1", Name: "trips", PrimaryKey: []string{"uuid", "request_at"}, TimeColumn: "request_at",
+		})
+		report := task.Sync(false)
+		assert.Equal(t, "hive", report.Source)
+		assert.Equal(t, []models.SchemaSyncDiff{{
+			Namespace:      "ns1",
+			Database:       "db1",
+			Table:          "trips",
+			Action:         models.SchemaSyncCreate,
+			AddedColumns:   []string{"request_at", "uuid", "fare"},
+			SkippedColumns: []string{"tags:array<string>"},
+			Applied:        true,
+		}}, report.Diffs)
+		assert.True(t, created.IsFactTable)
+		assert.Equal(t, []metaCom.Column{
+			{Name: "request_at", Type: metaCom.Uint32},
+			{Name: "uuid", Type: metaCom.BigEnum},
+			{Name: "fare", Type: metaCom.Decimal, DecimalScale: 2},
+		}, created.Columns)
+		assert.Equal(t, []int{1, 0}, created.PrimaryKeyColumns)
+	})
+
+	t.Run("reports conflicts without applying", func(t *testing.T) {
+		schemaMutator := &mocks.TableSchemaMutator{}
+		schemaMutator.On("GetTable", "ns1", "trips").Return(nil, metaCom.ErrTableDoesNotExist)
+		schemaMutator.On("GetTable", "ns1", "cities").Return(&metaCom.Table{
+			Name:              "cities",
+			Columns:           []metaCom.Column{{Name: "id", Type: metaCom.Uint32}},
+			PrimaryKeyColumns: []int{0},
+		}, nil)
+
+		task := newTask(schemaMutator,
+			schemaSyncTableConfig{Namespace: "ns1", Database: "db1", Name: "trips"},
+			schemaSyncTableConfig{Namespace: "ns1", Database: "db1", Name: "cities", PrimaryKey: []string{"id"}},
+		)
+		report := task.Sync(false)
+		assert.Len(t, report.Diffs, 2)
+		assert.Equal(t, models.SchemaSyncConflict, report.Diffs[0].Action)
+		assert.Equal(t, []string{"primary key is not configured"}, report.Diffs[0].Conflicts)
+		assert.Equal(t, models.SchemaSyncConflict, report.Diffs[1].Action)
+		assert.Equal(t, []string{"column id is of type Uint32 in AresDB but Int32 externally"}, report.Diffs[1].Conflicts)
+		schemaMutator.AssertNotCalled(t, "CreateTable", mock.Anything, mock.Anything, mock.Anything)
+		schemaMutator.AssertNotCalled(t, "UpdateTable", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("adds and deletes columns of existing tables", func(t *testing.T) {
+		schemaMutator := &mocks.TableSchemaMutator{}
+		schemaMutator.On("GetTable", "ns1", "cities").Return(&metaCom.Table{
+			Name: "cities",
+			Columns: []metaCom.Column{
+				{Name: "id", Type: metaCom.Int32},
+				{Name: "population", Type: metaCom.Int64},
+			},
+			PrimaryKeyColumns: []int{0},
+		}, nil)
+		rolloutMutator := &mocks.SchemaRolloutMutator{}
+		var updated metaCom.Table
+		rolloutMutator.On("StartRollout", "ns1", mock.Anything, false).Run(func(args mock.Arguments) {
+			updated = args.Get(1).(metaCom.Table)
+		}).Return(models.SchemaRollout{}, nil).Once()
+
+		task := newTaskWithRollout(schemaMutator, rolloutMutator, schemaSyncTableConfig{Namespace: "ns1", Database: "db1", Name: "cities", PrimaryKey: []string{"id"}})
+		report := task.Sync(true)
+		assert.Equal(t, models.SchemaSyncUpdate, report.Diffs[0].Action)
+		assert.Equal(t, []string{"name"}, report.Diffs[0].AddedColumns)
+		assert.Equal(t, []string{"population"}, report.Diffs[0].DeletedColumns)
+		assert.False(t, report.Diffs[0].Applied)
+		rolloutMutator.AssertNotCalled(t, "StartRollout", mock.Anything, mock.Anything, mock.Anything)
+
+		report = task.Sync(false)
+		assert.True(t, report.Diffs[0].Applied)
+		assert.Equal(t, []metaCom.Column{
+			{Name: "id", Type: metaCom.Int32},
+			{Name: "population", Type: metaCom.Int64, Deleted: true},
+			{Name: "name", Type: metaCom.BigEnum},
+		}, updated.Columns)
+		schemaMutator.AssertNotCalled(t, "UpdateTable", mock.Anything, mock.Anything, mock.Anything)
+
+		// changes are not synced again until the pending rollout finishes
+		rolloutMutator.On("StartRollout", "ns1", mock.Anything, false).Return(models.SchemaRollout{}, mutatorCom.ErrSchemaRolloutInProgress)
+		report = task.Sync(false)
+		assert.False(t, report.Diffs[0].Applied)
+		assert.Empty(t, report.Diffs[0].Error)
+	})
+
+	t.Run("reports external metastore errors", func(t *testing.T) {
+		task := newTask(&mocks.TableSchemaMutator{}, schemaSyncTableConfig{Namespace: "ns1", Database: "db1", Name: "unknown"})
+		report := task.Sync(false)
+		assert.NotEmpty(t, report.Diffs[0].Error)
+	})
+}
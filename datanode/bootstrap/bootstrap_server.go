@@ -18,9 +18,11 @@ import (
 	"bufio"
 	"context"
 	"errors"
+	"github.com/DataDog/zstd"
 	pb "github.com/uber/aresdb/datanode/generated/proto/rpc"
 	"github.com/uber/aresdb/diskstore"
 	"github.com/uber/aresdb/metastore/common"
+	"github.com/uber/aresdb/redolog"
 	"github.com/uber/aresdb/utils"
 	"io"
 	"os"
@@ -41,6 +43,7 @@ var (
 	errInvalidSessionID = errors.New("invalid session id")
 	errInvalidRequset   = errors.New("invalid request, table/shard not match")
 	errSessionExisting  = errors.New("The request table/shard already have session running from the same node")
+	errInvalidRedoLog   = errors.New("invalid redolog file header")
 )
 
 type PeerDataNodeServerImpl struct {
@@ -431,8 +434,14 @@ func (p *PeerDataNodeServerImpl) FetchVectorPartyRawData(req *pb.VectorPartyRawD
 
 	defer reader.Close()
 
+	var writer io.Writer = &rawDataStreamWriter{stream: stream}
+	var compressor *zstd.Writer
+	if req.CompressionLevel > 0 {
+		compressor = zstd.NewWriterLevel(&rawDataStreamWriter{stream: stream, compressed: true}, int(req.CompressionLevel))
+		writer = compressor
+	}
+
 	bufferedReader := bufio.NewReaderSize(reader, bufferSize)
-	vp := &pb.VectorPartyRawData{}
 	buf := make([]byte, chunkSize)
 	for {
 		n, err := bufferedReader.Read(buf)
@@ -440,8 +449,7 @@ func (p *PeerDataNodeServerImpl) FetchVectorPartyRawData(req *pb.VectorPartyRawD
 			return err
 		}
 		if n > 0 {
-			vp.Chunk = buf[:n]
-			if err = stream.Send(vp); err != nil {
+			if _, err = writer.Write(buf[:n]); err != nil {
 				return err
 			}
 		}
@@ -451,12 +459,127 @@ func (p *PeerDataNodeServerImpl) FetchVectorPartyRawData(req *pb.VectorPartyRawD
 			break
 		}
 	}
+	if compressor != nil {
+		if err = compressor.Close(); err != nil {
+			return err
+		}
+	}
 	// in macro second
 	timeElapsed = utils.Now().Sub(timeStart).Nanoseconds() / 1000
 
 	return nil
 }
 
+// rawDataStreamWriter sends data written to it as vector party raw data chunks.
+type rawDataStreamWriter struct {
+	stream     pb.PeerDataNode_FetchVectorPartyRawDataServer
+	compressed bool
+}
+
+func (w *rawDataStreamWriter) Write(p []byte) (int, error) {
+	vp := &pb.VectorPartyRawData{Compressed: w.compressed}
+	for written := 0; written < len(p); written += len(vp.Chunk) {
+		size := len(p) - written
+		if size > chunkSize {
+			size = chunkSize
+		}
+		vp.Chunk = p[written : written+size]
+		if err := w.stream.Send(vp); err != nil {
+			return written, err
+		}
+	}
+	return len(p), nil
+}
+
+// FetchRedoLog streams upsert batches in local redolog files of the table/shard, starting from the requested
+// redolog file id and upsert batch offset. Only complete upsert batches are sent, so a batch still being appended
+// to the latest redolog file will be picked up by the next call
+func (p *PeerDataNodeServerImpl) FetchRedoLog(req *pb.RedoLogRequest, stream pb.PeerDataNode_FetchRedoLogServer) error {
+	sessionInfo := &sessionInfo{
+		table:   req.Table,
+		shardID: req.Shard,
+		nodeID:  req.NodeID,
+	}
+	var err error
+	var numBatches int
+
+	logInfoMsg(sessionInfo, "FetchRedoLog called", "redoFileID", req.RedoFileID, "redoFileOffset", req.RedoFileOffset)
+	defer func() {
+		if err == nil {
+			logInfoMsg(sessionInfo, "FetchRedoLog succeed", "redoFileID", req.RedoFileID, "redoFileOffset", req.RedoFileOffset, "batches", numBatches)
+		} else {
+			logErrorMsg(sessionInfo, err, "FetchRedoLog failed", "redoFileID", req.RedoFileID, "redoFileOffset", req.RedoFileOffset)
+		}
+	}()
+
+	if err = p.validateRequest(req.SessionID, req.NodeID, req.Table, req.Shard); err != nil {
+		return err
+	}
+
+	redoFileIDs, err := p.diskStore.ListLogFiles(req.Table, int(req.Shard))
+	if err != nil {
+		return err
+	}
+
+	for _, redoFileID := range redoFileIDs {
+		if redoFileID < req.RedoFileID {
+			continue
+		}
+		var startOffset uint32
+		if redoFileID == req.RedoFileID {
+			startOffset = req.RedoFileOffset
+		}
+		var n int
+		if n, err = p.streamRedoLogFile(req.Table, int(req.Shard), redoFileID, startOffset, stream); err != nil {
+			return err
+		}
+		numBatches += n
+	}
+	return nil
+}
+
+// streamRedoLogFile sends upsert batches in one redolog file starting from startOffset, returns number of batches sent
+func (p *PeerDataNodeServerImpl) streamRedoLogFile(table string, shard int, redoFileID int64, startOffset uint32, stream pb.PeerDataNode_FetchRedoLogServer) (int, error) {
+	reader, err := p.diskStore.OpenLogFileForReplay(table, shard, redoFileID)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	recordReader, err := redolog.NewRecordReader(bufio.NewReaderSize(reader, bufferSize))
+	if err == io.EOF {
+		// file just created and header not flushed yet
+		return 0, nil
+	} else if err != nil {
+		return 0, errInvalidRedoLog
+	}
+
+	var numBatches int
+	for offset := uint32(0); ; offset++ {
+		if offset < startOffset {
+			if err = recordReader.Skip(); err != nil {
+				break
+			}
+			continue
+		}
+		// upsert batches compacted away are sent as empty ones to keep the offsets.
+		buffer, err := recordReader.Next()
+		if err != nil {
+			// reached the end of file or a partially written or corrupted upsert batch
+			break
+		}
+		if err = stream.Send(&pb.RedoLogUpsertBatch{
+			RedoFileID:     redoFileID,
+			RedoFileOffset: offset,
+			UpsertBatch:    buffer,
+		}); err != nil {
+			return numBatches, err
+		}
+		numBatches++
+	}
+	return numBatches, nil
+}
+
 // BenchmarkFileTransfer is used to benchmark testing, we can remove later TODO
 func (p *PeerDataNodeServerImpl) BenchmarkFileTransfer(req *pb.BenchmarkRequest, stream pb.PeerDataNode_BenchmarkFileTransferServer) error {
 	var err error
@@ -15,19 +15,36 @@
 package bootstrap
 
 import (
+	"bytes"
 	"context"
+	"github.com/DataDog/zstd"
 	"github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	pb "github.com/uber/aresdb/datanode/generated/proto/rpc"
 	"github.com/uber/aresdb/diskstore"
 	"github.com/uber/aresdb/metastore"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	metaMocks "github.com/uber/aresdb/metastore/mocks"
+	"github.com/uber/aresdb/redolog"
 	"github.com/uber/aresdb/utils"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/test/bufconn"
+	"io/ioutil"
 	"net"
+	"os"
 	"time"
 )
 
+type testRedoLogServerStream struct {
+	grpc.ServerStream
+	batches []*pb.RedoLogUpsertBatch
+}
+
+func (s *testRedoLogServerStream) Send(batch *pb.RedoLogUpsertBatch) error {
+	s.batches = append(s.batches, batch)
+	return nil
+}
+
 const bufSize = 1024 * 1024
 
 var _ = ginkgo.Describe("bootstrap server", func() {
@@ -265,6 +282,26 @@ var _ = ginkgo.Describe("bootstrap server", func() {
 		Ω(l).Should(Equal(163840))
 		Ω(err.Error()).Should(ContainSubstring("EOF")) // weird it not return io.EOF while msg is EOF
 
+		// compressed transfer
+		dataRequest.CompressionLevel = 3
+		fetchClient, err = client.FetchVectorPartyRawData(context.Background(), dataRequest)
+		Ω(err).Should(BeNil())
+		var compressed []byte
+		for {
+			rawData, err = fetchClient.Recv()
+			if err != nil {
+				break
+			}
+			Ω(rawData.Compressed).Should(BeTrue())
+			compressed = append(compressed, rawData.Chunk...)
+		}
+		Ω(err.Error()).Should(ContainSubstring("EOF"))
+		decompressor := zstd.NewReader(bytes.NewReader(compressed))
+		decompressed, err := ioutil.ReadAll(decompressor)
+		Ω(err).Should(BeNil())
+		Ω(decompressor.Close()).Should(BeNil())
+		Ω(decompressed).Should(HaveLen(163840))
+
 		// dim table
 		req.Table = dimTable
 		session, err = client.StartSession(context.Background(), &req)
@@ -300,6 +337,84 @@ var _ = ginkgo.Describe("bootstrap server", func() {
 		Ω(err.Error()).Should(ContainSubstring("EOF"))
 	})
 
+	ginkgo.It("FetchRedoLog test", func() {
+		rootPath, err := ioutil.TempDir("", "bootstrap_redolog")
+		Ω(err).Should(BeNil())
+		defer os.RemoveAll(rootPath)
+
+		redoLogDiskStore := diskstore.NewLocalDiskStore(rootPath)
+		writeRedoLog := func(redoFileID int64, batches ...[]byte) {
+			file, err := redoLogDiskStore.OpenLogFileForAppend(factTable, shardID, redoFileID)
+			Ω(err).Should(BeNil())
+			defer file.Close()
+			writer := utils.NewStreamDataWriter(file)
+			Ω(writer.WriteUint32(redolog.UpsertHeader)).Should(BeNil())
+			for _, batch := range batches {
+				Ω(writer.WriteUint32(uint32(len(batch)))).Should(BeNil())
+				Ω(writer.Write(batch)).Should(BeNil())
+			}
+			// partially written upsert batch should not be sent
+			Ω(writer.WriteUint32(10)).Should(BeNil())
+		}
+		writeRedoLog(1, []byte{1}, []byte{2, 2})
+		writeRedoLog(2, []byte{3, 3, 3})
+
+		mockMetaStore := &metaMocks.MetaStore{}
+		mockMetaStore.On("GetTable", factTable).Return(&metaCom.Table{Name: factTable, IsFactTable: true}, nil)
+		s := NewPeerDataNodeServer(mockMetaStore, redoLogDiskStore).(*PeerDataNodeServerImpl)
+
+		session, err := s.StartSession(context.Background(), &pb.StartSessionRequest{
+			Table:  factTable,
+			Shard:  uint32(shardID),
+			NodeID: nodeID,
+			Ttl:    int64(5 * time.Minute),
+		})
+		Ω(err).Should(BeNil())
+
+		// no session will fail
+		stream := &testRedoLogServerStream{}
+		err = s.FetchRedoLog(&pb.RedoLogRequest{Table: factTable, Shard: uint32(shardID), NodeID: nodeID}, stream)
+		Ω(err).ShouldNot(BeNil())
+
+		// fetch all redologs
+		redoLogRequest := &pb.RedoLogRequest{
+			Table:     factTable,
+			Shard:     uint32(shardID),
+			SessionID: session.ID,
+			NodeID:    nodeID,
+		}
+		err = s.FetchRedoLog(redoLogRequest, stream)
+		Ω(err).Should(BeNil())
+		Ω(stream.batches).Should(HaveLen(3))
+		Ω(stream.batches[0].RedoFileID).Should(Equal(int64(1)))
+		Ω(stream.batches[0].RedoFileOffset).Should(Equal(uint32(0)))
+		Ω(stream.batches[0].UpsertBatch).Should(Equal([]byte{1}))
+		Ω(stream.batches[1].RedoFileID).Should(Equal(int64(1)))
+		Ω(stream.batches[1].RedoFileOffset).Should(Equal(uint32(1)))
+		Ω(stream.batches[1].UpsertBatch).Should(Equal([]byte{2, 2}))
+		Ω(stream.batches[2].RedoFileID).Should(Equal(int64(2)))
+		Ω(stream.batches[2].RedoFileOffset).Should(Equal(uint32(0)))
+		Ω(stream.batches[2].UpsertBatch).Should(Equal([]byte{3, 3, 3}))
+
+		// fetch from the middle of the first redolog file
+		stream = &testRedoLogServerStream{}
+		redoLogRequest.RedoFileID = 1
+		redoLogRequest.RedoFileOffset = 1
+		err = s.FetchRedoLog(redoLogRequest, stream)
+		Ω(err).Should(BeNil())
+		Ω(stream.batches).Should(HaveLen(2))
+		Ω(stream.batches[0].UpsertBatch).Should(Equal([]byte{2, 2}))
+		Ω(stream.batches[1].UpsertBatch).Should(Equal([]byte{3, 3, 3}))
+
+		// nothing new after the last upsert batch
+		stream = &testRedoLogServerStream{}
+		redoLogRequest.RedoFileID = 2
+		redoLogRequest.RedoFileOffset = 1
+		err = s.FetchRedoLog(redoLogRequest, stream)
+		Ω(err).Should(BeNil())
+		Ω(stream.batches).Should(BeEmpty())
+	})
+
 	ginkgo.It("AcquireToken/ReleaseToken test", func() {
 		s := peerServer.(*PeerDataNodeServerImpl)
 		ok := s.AcquireToken(factTable, 0)
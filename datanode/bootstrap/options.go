@@ -8,6 +8,8 @@ const (
 	defaultBootstrapSessionTTL              = int64(5 * time.Minute)
 	defaultMaxConcurrentTableShards         = 8
 	defaultMaxCocurrentSessionPerTableShard = 2
+	defaultMaxRedoLogCatchUpRounds          = 10
+	defaultRedoLogCatchUpThreshold          = 100
 )
 
 // options implements bootstrap Options
@@ -15,6 +17,9 @@ type options struct {
 	maxConcurrentTableShards          int
 	maxConcurrentStreamsPerTableShard int
 	bootstrapSessionTTL               int64
+	maxRedoLogCatchUpRounds           int
+	redoLogCatchUpThreshold           int
+	transferCompressionLevel          int
 }
 
 func (o *options) MaxConcurrentTableShards() int {
@@ -46,11 +51,46 @@ func (o *options) SetBootstrapSessionTTL(ttl int64) Options {
 	return o
 }
 
+// MaxRedoLogCatchUpRounds returns the max number of rounds to fetch redolog from peer
+func (o *options) MaxRedoLogCatchUpRounds() int {
+	return o.maxRedoLogCatchUpRounds
+}
+
+// SetMaxRedoLogCatchUpRounds sets the max number of rounds to fetch redolog from peer
+func (o *options) SetMaxRedoLogCatchUpRounds(rounds int) Options {
+	o.maxRedoLogCatchUpRounds = rounds
+	return o
+}
+
+// RedoLogCatchUpThreshold returns the number of upsert batches under which the table shard is considered caught up
+func (o *options) RedoLogCatchUpThreshold() int {
+	return o.redoLogCatchUpThreshold
+}
+
+// SetRedoLogCatchUpThreshold sets the number of upsert batches under which the table shard is considered caught up
+func (o *options) SetRedoLogCatchUpThreshold(numBatches int) Options {
+	o.redoLogCatchUpThreshold = numBatches
+	return o
+}
+
+// TransferCompressionLevel returns the zstd level requested to compress vector party raw data copied from peer
+func (o *options) TransferCompressionLevel() int {
+	return o.transferCompressionLevel
+}
+
+// SetTransferCompressionLevel sets the zstd level requested to compress vector party raw data copied from peer
+func (o *options) SetTransferCompressionLevel(level int) Options {
+	o.transferCompressionLevel = level
+	return o
+}
+
 // NewOptions returns bootstrap default options
 func NewOptions() Options {
 	return &options{
 		bootstrapSessionTTL:               defaultBootstrapSessionTTL,
 		maxConcurrentTableShards:          defaultMaxConcurrentTableShards,
 		maxConcurrentStreamsPerTableShard: defaultMaxCocurrentSessionPerTableShard,
+		maxRedoLogCatchUpRounds:           defaultMaxRedoLogCatchUpRounds,
+		redoLogCatchUpThreshold:           defaultRedoLogCatchUpThreshold,
 	}
 }
@@ -48,11 +48,12 @@ var (
 type BootstrapStage string
 
 const (
-	Waiting  BootstrapStage = "waiting"
-	PeerCopy BootstrapStage = "peercopy"
-	Preload  BootstrapStage = "preload"
-	Recovery BootstrapStage = "recovery"
-	Finished BootstrapStage = "finished"
+	Waiting        BootstrapStage = "waiting"
+	PeerCopy       BootstrapStage = "peercopy"
+	RedoLogCatchUp BootstrapStage = "redologcatchup"
+	Preload        BootstrapStage = "preload"
+	Recovery       BootstrapStage = "recovery"
+	Finished       BootstrapStage = "finished"
 )
 
 // BootstrapDetail describes details for bootstrap
@@ -98,4 +99,19 @@ type Options interface {
 	BootstrapSessionTTL() int64
 	// SetBootstrapSessionTTL sets the session ttl for bootstrap session
 	SetBootstrapSessionTTL(ttl int64) Options
+	// MaxRedoLogCatchUpRounds returns the max number of rounds to fetch redolog from peer after raw data is copied,
+	// bootstrap fails if the table shard is not caught up within these rounds, 0 means redolog shipping is disabled
+	MaxRedoLogCatchUpRounds() int
+	// SetMaxRedoLogCatchUpRounds sets the max number of rounds to fetch redolog from peer
+	SetMaxRedoLogCatchUpRounds(rounds int) Options
+	// RedoLogCatchUpThreshold returns the number of upsert batches fetched in one round under which
+	// the table shard is considered caught up with peer
+	RedoLogCatchUpThreshold() int
+	// SetRedoLogCatchUpThreshold sets the number of upsert batches under which the table shard is considered caught up
+	SetRedoLogCatchUpThreshold(numBatches int) Options
+	// TransferCompressionLevel returns the zstd level requested to compress vector party raw data copied from peer,
+	// 0 means raw data is copied uncompressed
+	TransferCompressionLevel() int
+	// SetTransferCompressionLevel sets the zstd level requested to compress vector party raw data copied from peer
+	SetTransferCompressionLevel(level int) Options
 }
@@ -0,0 +1,110 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	apiCom "github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/cluster/topology"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// NewDataNodeColumnValuesClient creates a new DataNodeColumnValuesClient.
+func NewDataNodeColumnValuesClient() DataNodeColumnValuesClient {
+	return &dataNodeColumnValuesClientImpl{
+		client: http.Client{
+			Transport: utils.HTTPClientTransport(),
+		},
+	}
+}
+
+type dataNodeColumnValuesClientImpl struct {
+	client http.Client
+}
+
+func (dc *dataNodeColumnValuesClientImpl) ListColumnValues(ctx context.Context, host topology.Host,
+	request apiCom.ColumnValuesRequest, shards []uint32) (values []apiCom.ColumnValueCount, err error) {
+	if host == nil {
+		err = utils.StackError(nil, "host is nil")
+		return
+	}
+	var u *url.URL
+	u, err = url.Parse(host.Address())
+	if err != nil {
+		return
+	}
+	u.Scheme = utils.HTTPScheme()
+	u.Path = fmt.Sprintf("/query/%s/columns/%s/values", request.TableName, request.ColumnName)
+	shardIDs := make([]string, len(shards))
+	for i, shard := range shards {
+		shardIDs[i] = strconv.Itoa(int(shard))
+	}
+	query := url.Values{}
+	query.Set("shards", strings.Join(shardIDs, ","))
+	// counts of all values are needed to merge them.
+	query.Set("limit", "-1")
+	if request.From != "" {
+		query.Set("from", request.From)
+	}
+	if request.To != "" {
+		query.Set("to", request.To)
+	}
+	if request.Timezone != "" {
+		query.Set("timezone", request.Timezone)
+	}
+	u.RawQuery = query.Encode()
+
+	var req *http.Request
+	req, err = http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return
+	}
+	if request.Caller != "" {
+		req.Header.Add(queryCom.CallerHeaderKey, request.Caller)
+	}
+	utils.InjectCredentials(ctx, req.Header)
+	req = req.WithContext(ctx)
+
+	var res *http.Response
+	res, err = dc.client.Do(req)
+	if res != nil {
+		defer res.Body.Close()
+	}
+	if err != nil {
+		utils.GetLogger().With("host", host, "err", err).Error("error connecting to datanode")
+		err = ErrFailedToConnect
+		return
+	}
+	if res.StatusCode != http.StatusOK {
+		resBody, _ := ioutil.ReadAll(res.Body)
+		err = fmt.Errorf("got status code %d from datanode: %s", res.StatusCode, resBody)
+		return
+	}
+	var response apiCom.ColumnValuesResponse
+	if err = json.NewDecoder(res.Body).Decode(&response.Body); err != nil {
+		return
+	}
+	values = response.Body.Values
+	return
+}
@@ -0,0 +1,76 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/uber/aresdb/cluster/topology"
+	"github.com/uber/aresdb/utils"
+)
+
+// NewDataNodeIngestionClient creates a new DataNodeIngestionClient.
+func NewDataNodeIngestionClient() DataNodeIngestionClient {
+	return &dataNodeIngestionClientImpl{
+		client: http.Client{
+			Transport: utils.HTTPClientTransport(),
+		},
+	}
+}
+
+type dataNodeIngestionClientImpl struct {
+	client http.Client
+}
+
+func (dc *dataNodeIngestionClientImpl) Ingest(ctx context.Context, host topology.Host, table string, shard uint32, upsertBatch []byte) (err error) {
+	if host == nil {
+		return utils.StackError(nil, "host is nil")
+	}
+	var u *url.URL
+	u, err = url.Parse(host.Address())
+	if err != nil {
+		return
+	}
+	u.Scheme = utils.HTTPScheme()
+	u.Path = fmt.Sprintf("/data/%s/%d", table, shard)
+
+	var req *http.Request
+	req, err = http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(upsertBatch))
+	if err != nil {
+		return
+	}
+	req.Header.Set(utils.HTTPContentTypeHeaderKey, utils.HTTPContentTypeUpsertBatch)
+	req = req.WithContext(ctx)
+
+	var res *http.Response
+	res, err = dc.client.Do(req)
+	if res != nil {
+		defer res.Body.Close()
+	}
+	if err != nil {
+		utils.GetLogger().With("host", host, "err", err).Error("error connecting to datanode")
+		return ErrFailedToConnect
+	}
+	if res.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("got status code %d from datanode: %s", res.StatusCode, body)
+	}
+	return
+}
@@ -0,0 +1,35 @@
+// Code generated by mockery v1.0.0
+package mocks
+
+import common "github.com/uber/aresdb/api/common"
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+import topology "github.com/uber/aresdb/cluster/topology"
+
+// DataNodeColumnValuesClient is an autogenerated mock type for the DataNodeColumnValuesClient type
+type DataNodeColumnValuesClient struct {
+	mock.Mock
+}
+
+// ListColumnValues provides a mock function with given fields: ctx, host, request, shards
+func (_m *DataNodeColumnValuesClient) ListColumnValues(ctx context.Context, host topology.Host, request common.ColumnValuesRequest, shards []uint32) ([]common.ColumnValueCount, error) {
+	ret := _m.Called(ctx, host, request, shards)
+
+	var r0 []common.ColumnValueCount
+	if rf, ok := ret.Get(0).(func(context.Context, topology.Host, common.ColumnValuesRequest, []uint32) []common.ColumnValueCount); ok {
+		r0 = rf(ctx, host, request, shards)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]common.ColumnValueCount)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, topology.Host, common.ColumnValuesRequest, []uint32) error); ok {
+		r1 = rf(ctx, host, request, shards)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
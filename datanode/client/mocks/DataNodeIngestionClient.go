@@ -0,0 +1,25 @@
+// Code generated by mockery v1.0.0
+package mocks
+
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+import topology "github.com/uber/aresdb/cluster/topology"
+
+// DataNodeIngestionClient is an autogenerated mock type for the DataNodeIngestionClient type
+type DataNodeIngestionClient struct {
+	mock.Mock
+}
+
+// Ingest provides a mock function with given fields: ctx, host, table, shard, upsertBatch
+func (_m *DataNodeIngestionClient) Ingest(ctx context.Context, host topology.Host, table string, shard uint32, upsertBatch []byte) error {
+	ret := _m.Called(ctx, host, table, shard, upsertBatch)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, topology.Host, string, uint32, []byte) error); ok {
+		r0 = rf(ctx, host, table, shard, upsertBatch)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
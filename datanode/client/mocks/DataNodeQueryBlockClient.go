@@ -0,0 +1,40 @@
+// Code generated by mockery v1.0.0
+package mocks
+
+import common "github.com/uber/aresdb/query/common"
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+import topology "github.com/uber/aresdb/cluster/topology"
+
+// DataNodeQueryBlockClient is an autogenerated mock type for the DataNodeQueryBlockClient type
+type DataNodeQueryBlockClient struct {
+	mock.Mock
+}
+
+// Block provides a mock function with given fields: ctx, host, block
+func (_m *DataNodeQueryBlockClient) Block(ctx context.Context, host topology.Host, block common.QueryBlock) error {
+	ret := _m.Called(ctx, host, block)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, topology.Host, common.QueryBlock) error); ok {
+		r0 = rf(ctx, host, block)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Unblock provides a mock function with given fields: ctx, host, table, fingerprint
+func (_m *DataNodeQueryBlockClient) Unblock(ctx context.Context, host topology.Host, table string, fingerprint string) error {
+	ret := _m.Called(ctx, host, table, fingerprint)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, topology.Host, string, string) error); ok {
+		r0 = rf(ctx, host, table, fingerprint)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
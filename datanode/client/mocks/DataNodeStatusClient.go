@@ -0,0 +1,33 @@
+// Code generated by mockery v1.0.0
+package mocks
+
+import common "github.com/uber/aresdb/api/common"
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+import topology "github.com/uber/aresdb/cluster/topology"
+
+// DataNodeStatusClient is an autogenerated mock type for the DataNodeStatusClient type
+type DataNodeStatusClient struct {
+	mock.Mock
+}
+
+// Status provides a mock function with given fields: ctx, host
+func (_m *DataNodeStatusClient) Status(ctx context.Context, host topology.Host) (common.NodeStatus, error) {
+	ret := _m.Called(ctx, host)
+
+	var r0 common.NodeStatus
+	if rf, ok := ret.Get(0).(func(context.Context, topology.Host) common.NodeStatus); ok {
+		r0 = rf(ctx, host)
+	} else {
+		r0 = ret.Get(0).(common.NodeStatus)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, topology.Host) error); ok {
+		r1 = rf(ctx, host)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
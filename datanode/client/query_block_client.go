@@ -0,0 +1,106 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	apiCom "github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/cluster/topology"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// NewDataNodeQueryBlockClient creates a new DataNodeQueryBlockClient.
+func NewDataNodeQueryBlockClient() DataNodeQueryBlockClient {
+	return &dataNodeQueryBlockClientImpl{
+		client: http.Client{
+			Transport: utils.HTTPClientTransport(),
+		},
+	}
+}
+
+type dataNodeQueryBlockClientImpl struct {
+	client http.Client
+}
+
+func (dc *dataNodeQueryBlockClientImpl) Block(ctx context.Context, host topology.Host, block queryCom.QueryBlock) error {
+	var request apiCom.QueryBlockRequest
+	request.Body.Table = block.Table
+	request.Body.Fingerprint = block.Fingerprint
+	request.Body.Reason = block.Reason
+	request.Body.DurationSeconds = block.ExpiresAt - utils.Now().Unix()
+	if request.Body.DurationSeconds <= 0 {
+		return utils.StackError(nil, "query block already expired")
+	}
+	body, err := json.Marshal(request.Body)
+	if err != nil {
+		return err
+	}
+	return dc.do(ctx, host, http.MethodPost, "/query/blocks", nil, bytes.NewReader(body))
+}
+
+func (dc *dataNodeQueryBlockClientImpl) Unblock(ctx context.Context, host topology.Host, table, fingerprint string) error {
+	query := url.Values{}
+	if fingerprint != "" {
+		query.Set("fingerprint", fingerprint)
+	}
+	return dc.do(ctx, host, http.MethodDelete, fmt.Sprintf("/query/blocks/%s", table), query, nil)
+}
+
+func (dc *dataNodeQueryBlockClientImpl) do(ctx context.Context, host topology.Host, method, path string, query url.Values, body io.Reader) (err error) {
+	if host == nil {
+		return utils.StackError(nil, "host is nil")
+	}
+	var u *url.URL
+	u, err = url.Parse(host.Address())
+	if err != nil {
+		return
+	}
+	u.Scheme = utils.HTTPScheme()
+	u.Path = path
+	u.RawQuery = query.Encode()
+
+	var req *http.Request
+	req, err = http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return
+	}
+	req.Header.Set(utils.HTTPContentTypeHeaderKey, utils.HTTPContentTypeApplicationJson)
+	utils.InjectCredentials(ctx, req.Header)
+	req = req.WithContext(ctx)
+
+	var res *http.Response
+	res, err = dc.client.Do(req)
+	if res != nil {
+		defer res.Body.Close()
+	}
+	if err != nil {
+		utils.GetLogger().With("host", host, "err", err).Error("error connecting to datanode")
+		return ErrFailedToConnect
+	}
+	if res.StatusCode != http.StatusOK {
+		resBody, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("got status code %d from datanode: %s", res.StatusCode, resBody)
+	}
+	return
+}
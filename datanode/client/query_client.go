@@ -23,6 +23,7 @@ import (
 	"github.com/uber/aresdb/cluster/topology"
 	queryCom "github.com/uber/aresdb/query/common"
 	"github.com/uber/aresdb/utils"
+	"go.opentelemetry.io/otel/attribute"
 	. "io/ioutil"
 	"net/http"
 	"net/url"
@@ -36,7 +37,9 @@ var ErrFailedToConnect = errors.New("Datanode query client failed to connect")
 
 func NewDataNodeQueryClient() DataNodeQueryClient {
 	return &dataNodeQueryClientImpl{
-		client: http.Client{},
+		client: http.Client{
+			Transport: utils.HTTPClientTransport(),
+		},
 	}
 }
 
@@ -80,6 +83,10 @@ func (dc *dataNodeQueryClientImpl) Query(ctx context.Context, requestID string,
 			return
 		}
 		result = respBody.Results[0]
+		// sketches are serialized with type tags so that broker can merge sketches of any type.
+		if err = queryCom.DecodeSketchResult(result); err != nil {
+			return
+		}
 	}
 
 	utils.GetLogger().With("host", host, "query", query, "result", result, "hll", hll).Debug("datanode query client Query succeeded")
@@ -103,14 +110,24 @@ func (dc *dataNodeQueryClientImpl) queryRaw(ctx context.Context, requestID strin
 		err = utils.StackError(nil, "host is nil")
 		return
 	}
+	// one span per attempt so that retries are visible in the trace
+	ctx, span := utils.StartSpan(ctx, "broker.QueryDataNode", attribute.String("host", host.Address()))
+	defer func() {
+		utils.EndSpan(span, err)
+	}()
+
 	u, err = url.Parse(host.Address())
 	if err != nil {
 		return
 	}
-	u.Scheme = "http"
+	u.Scheme = utils.HTTPScheme()
 	u.Path = "/query/aql"
 	q := u.Query()
 	q.Set("dataonly", "1")
+	queryTrace := utils.QueryTraceFromContext(ctx)
+	if queryTrace != nil {
+		q.Set("trace", "1")
+	}
 	u.RawQuery = q.Encode()
 
 	aqlRequestBody := aqlRequestBody{
@@ -128,6 +145,9 @@ func (dc *dataNodeQueryClientImpl) queryRaw(ctx context.Context, requestID strin
 	}
 
 	req.Header.Add(requestIDHeaderKey, requestID)
+	// forward credentials so that datanodes enforce table acls of the principal
+	utils.InjectCredentials(ctx, req.Header)
+	utils.InjectTraceContext(ctx, req.Header)
 	if hll {
 		req.Header.Add(utils.HTTPAcceptTypeHeaderKey, utils.HTTPContentTypeHyperLogLog)
 	}
@@ -150,7 +170,65 @@ func (dc *dataNodeQueryClientImpl) queryRaw(ctx context.Context, requestID strin
 	bs, err = ReadAll(res.Body)
 	if err != nil {
 		bs = nil
+		return
+	}
+
+	if collector := queryCom.DataFreshnessCollectorFromContext(ctx); collector != nil {
+		if freshnessHeader := res.Header.Get(queryCom.DataFreshnessHeaderKey); freshnessHeader != "" {
+			var shards []queryCom.ShardFreshness
+			if jsonErr := json.Unmarshal([]byte(freshnessHeader), &shards); jsonErr != nil {
+				utils.GetLogger().With("host", host, "header", freshnessHeader, "error", jsonErr).Warn("invalid data freshness header from datanode")
+			} else {
+				collector.Add(shards...)
+			}
+		}
+		if timeRangeHeader := res.Header.Get(queryCom.QueryTimeRangeHeaderKey); timeRangeHeader != "" {
+			var timeRange queryCom.QueryTimeRange
+			if jsonErr := json.Unmarshal([]byte(timeRangeHeader), &timeRange); jsonErr != nil {
+				utils.GetLogger().With("host", host, "header", timeRangeHeader, "error", jsonErr).Warn("invalid query time range header from datanode")
+			} else {
+				collector.AddTimeRange(timeRange)
+			}
+		}
 	}
 
+	if queryTrace != nil {
+		// streamed responses carry trace spans in trailer.
+		traceHeader := res.Header.Get(utils.QueryTraceHeaderKey)
+		if traceHeader == "" {
+			traceHeader = res.Trailer.Get(utils.QueryTraceHeaderKey)
+		}
+		if traceHeader != "" {
+			var spans []utils.TraceSpan
+			if jsonErr := json.Unmarshal([]byte(traceHeader), &spans); jsonErr != nil {
+				utils.GetLogger().With("host", host, "error", jsonErr).Warn("invalid query trace header from datanode")
+			} else {
+				for i := range spans {
+					spans[i].Process = "datanode " + host.Address()
+				}
+				queryTrace.Add(spans...)
+			}
+		}
+	}
+
+	if collector := queryCom.ShardCoverageCollectorFromContext(ctx); collector != nil {
+		collector.Add(queryCom.ParseCoverageTokens(res.Header.Get(queryCom.ShardCoverageHeaderKey))...)
+	}
+
+	if collector := queryCom.ScanStatsCollectorFromContext(ctx); collector != nil {
+		stats := queryCom.ScanStats{BytesTransferred: len(bs)}
+		// streamed responses carry scan stats in trailer.
+		statsHeader := res.Header.Get(queryCom.ScanStatsHeaderKey)
+		if statsHeader == "" {
+			statsHeader = res.Trailer.Get(queryCom.ScanStatsHeaderKey)
+		}
+		if statsHeader != "" {
+			if jsonErr := json.Unmarshal([]byte(statsHeader), &stats); jsonErr != nil {
+				utils.GetLogger().With("host", host, "header", statsHeader, "error", jsonErr).Warn("invalid scan stats header from datanode")
+			}
+			stats.BytesTransferred = len(bs)
+		}
+		collector.Add(stats)
+	}
 	return
 }
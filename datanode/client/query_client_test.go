@@ -20,6 +20,7 @@ import (
 	. "github.com/onsi/gomega"
 	topoMocks "github.com/uber/aresdb/cluster/topology/mocks"
 	"github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
 	"net/http"
 	"net/http/httptest"
 )
@@ -56,6 +57,96 @@ var _ = ginkgo.Describe("datanode query client", func() {
 		Ω(res).Should(Equal(aqlResult))
 	})
 
+	ginkgo.It("should collect data freshness from response header", func() {
+		server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set(common.DataFreshnessHeaderKey, `[{"table":"trips","shard":0,"highWatermark":100,"redoLogLag":2,"archivingCutoff":50}]`)
+			rw.Header().Set(common.QueryTimeRangeHeaderKey, `{"from":60,"to":120}`)
+			bs, _ := json.Marshal(aqlRespBody{
+				Results: []common.AQLQueryResult{
+					aqlResult,
+				},
+			})
+			rw.Write(bs)
+		}))
+		add := "http://" + server.Listener.Addr().String()
+		mockHost := topoMocks.Host{}
+		mockHost.On("Address").Return(add)
+
+		collector := common.NewDataFreshnessCollector()
+		ctx := common.WithDataFreshnessCollector(context.TODO(), collector)
+		client := NewDataNodeQueryClient()
+		_, err := client.Query(ctx, "", &mockHost, common.AQLQuery{}, false)
+		Ω(err).Should(BeNil())
+		Ω(collector.Result()).Should(Equal(common.DataFreshness{
+			HighWatermark: 100,
+			RedoLogLag:    2,
+			Shards: []common.ShardFreshness{
+				{Table: "trips", Shard: 0, HighWatermark: 100, RedoLogLag: 2, ArchivingCutoff: 50},
+			},
+			TimeRange: &common.QueryTimeRange{From: 60, To: 120},
+		}))
+	})
+
+	ginkgo.It("should collect scan stats from response header or trailer", func() {
+		var body []byte
+		server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("Trailer", common.ScanStatsHeaderKey)
+			body, _ = json.Marshal(aqlRespBody{
+				Results: []common.AQLQueryResult{
+					aqlResult,
+				},
+			})
+			rw.Write(body)
+			rw.Header().Set(common.ScanStatsHeaderKey, `{"shardsRequested":2,"shardsPruned":1,"batchesScanned":3,"batchesSkipped":4}`)
+		}))
+		add := "http://" + server.Listener.Addr().String()
+		mockHost := topoMocks.Host{}
+		mockHost.On("Address").Return(add)
+
+		collector := &common.ScanStatsCollector{}
+		ctx := common.WithScanStatsCollector(context.TODO(), collector)
+		client := NewDataNodeQueryClient()
+		_, err := client.Query(ctx, "", &mockHost, common.AQLQuery{}, false)
+		Ω(err).Should(BeNil())
+		_, err = client.QueryRaw(ctx, "", &mockHost, common.AQLQuery{})
+		Ω(err).Should(BeNil())
+		Ω(collector.Result()).Should(Equal(common.ScanStats{
+			ShardsRequested:  4,
+			ShardsPruned:     2,
+			BatchesScanned:   6,
+			BatchesSkipped:   8,
+			BytesTransferred: 2 * len(body),
+		}))
+	})
+
+	ginkgo.It("should request and collect query trace of datanode", func() {
+		var traceParam string
+		server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			traceParam = req.URL.Query().Get("trace")
+			rw.Header().Set(utils.QueryTraceHeaderKey, `[{"name":"datanode.ScanShard","process":"datanode","start":100,"duration":10}]`)
+			bs, _ := json.Marshal(aqlRespBody{
+				Results: []common.AQLQueryResult{
+					aqlResult,
+				},
+			})
+			rw.Write(bs)
+		}))
+		add := "http://" + server.Listener.Addr().String()
+		mockHost := topoMocks.Host{}
+		mockHost.On("Address").Return(add)
+
+		queryTrace := utils.NewQueryTrace("broker")
+		client := NewDataNodeQueryClient()
+		_, err := client.Query(utils.WithQueryTrace(context.TODO(), queryTrace), "", &mockHost, common.AQLQuery{}, false)
+		Ω(err).Should(BeNil())
+		Ω(traceParam).Should(Equal("1"))
+		var names []string
+		for _, span := range queryTrace.Spans() {
+			names = append(names, span.Process+":"+span.Name)
+		}
+		Ω(names).Should(ConsistOf("datanode "+add+":datanode.ScanShard", "broker:broker.QueryDataNode"))
+	})
+
 	ginkgo.It("should fail status code not ok", func() {
 		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 			rw.WriteHeader(500)
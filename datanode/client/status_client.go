@@ -0,0 +1,78 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	apiCom "github.com/uber/aresdb/api/common"
+	"github.com/uber/aresdb/cluster/topology"
+	"github.com/uber/aresdb/utils"
+)
+
+// NewDataNodeStatusClient creates a new DataNodeStatusClient.
+func NewDataNodeStatusClient() DataNodeStatusClient {
+	return &dataNodeStatusClientImpl{
+		client: http.Client{
+			Transport: utils.HTTPClientTransport(),
+		},
+	}
+}
+
+type dataNodeStatusClientImpl struct {
+	client http.Client
+}
+
+func (dc *dataNodeStatusClientImpl) Status(ctx context.Context, host topology.Host) (status apiCom.NodeStatus, err error) {
+	if host == nil {
+		err = utils.StackError(nil, "host is nil")
+		return
+	}
+	var u *url.URL
+	u, err = url.Parse(host.Address())
+	if err != nil {
+		return
+	}
+	u.Scheme = utils.HTTPScheme()
+	u.Path = "/status"
+
+	var req *http.Request
+	req, err = http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return
+	}
+	req = req.WithContext(ctx)
+
+	var res *http.Response
+	res, err = dc.client.Do(req)
+	if res != nil {
+		defer res.Body.Close()
+	}
+	if err != nil {
+		utils.GetLogger().With("host", host, "err", err).Error("error connecting to datanode")
+		err = ErrFailedToConnect
+		return
+	}
+	if res.StatusCode != http.StatusOK {
+		err = fmt.Errorf("got status code %d from datanode", res.StatusCode)
+		return
+	}
+	err = json.NewDecoder(res.Body).Decode(&status)
+	return
+}
@@ -15,6 +15,7 @@ package client
 
 import (
 	"context"
+	apiCom "github.com/uber/aresdb/api/common"
 	"github.com/uber/aresdb/cluster/topology"
 	"github.com/uber/aresdb/datanode/generated/proto/rpc"
 	queryCom "github.com/uber/aresdb/query/common"
@@ -46,3 +47,29 @@ type DataNodeQueryClient interface {
 	// used for non agg query, header is left out, only matrixData returned as raw bytes
 	QueryRaw(ctx context.Context, requestID string, host topology.Host, query queryCom.AQLQuery) ([]byte, error)
 }
+
+// DataNodeStatusClient fetches status of datanodes
+type DataNodeStatusClient interface {
+	// Status returns health, shard ownership, ingestion, memory and gpu status of the datanode
+	Status(ctx context.Context, host topology.Host) (apiCom.NodeStatus, error)
+}
+
+// DataNodeQueryBlockClient places and removes query blocks on datanodes
+type DataNodeQueryBlockClient interface {
+	// Block blocks queries matching the block on the datanode until the block expires
+	Block(ctx context.Context, host topology.Host, block queryCom.QueryBlock) error
+	// Unblock removes the block on queries of the table with the fingerprint from the datanode
+	Unblock(ctx context.Context, host topology.Host, table, fingerprint string) error
+}
+
+// DataNodeColumnValuesClient counts values of columns on datanodes
+type DataNodeColumnValuesClient interface {
+	// ListColumnValues returns counts of all values of the column in the shards on the datanode
+	ListColumnValues(ctx context.Context, host topology.Host, request apiCom.ColumnValuesRequest, shards []uint32) ([]apiCom.ColumnValueCount, error)
+}
+
+// DataNodeIngestionClient sends upsert batches to datanodes
+type DataNodeIngestionClient interface {
+	// Ingest posts the upsert batch to the table shard on the datanode
+	Ingest(ctx context.Context, host topology.Host, table string, shard uint32, upsertBatch []byte) error
+}
@@ -15,7 +15,6 @@
 package datanode
 
 import (
-	"fmt"
 	"github.com/uber/aresdb/common"
 	"net/http"
 	"net/http/pprof"
@@ -25,6 +24,7 @@ import (
 
 	"github.com/m3db/m3/src/x/instrument"
 	controllerCli "github.com/uber/aresdb/controller/client"
+	etcdCli "github.com/uber/aresdb/controller/client/etcd"
 
 	"strings"
 
@@ -35,9 +35,15 @@ import (
 	m3Shard "github.com/m3db/m3/src/cluster/shard"
 	"github.com/uber-go/tally"
 	"github.com/uber/aresdb/api"
+	"github.com/uber/aresdb/backup"
+	"github.com/uber/aresdb/cdc"
+	"github.com/uber/aresdb/cluster/kvstore"
+	"github.com/uber/aresdb/cluster/membership"
 	"github.com/uber/aresdb/cluster/shard"
 	"github.com/uber/aresdb/cluster/topology"
+	"github.com/uber/aresdb/controller/models"
 	mutatorsCom "github.com/uber/aresdb/controller/mutators/common"
+	mutatorsEtcd "github.com/uber/aresdb/controller/mutators/etcd"
 	"github.com/uber/aresdb/datanode/bootstrap"
 	"github.com/uber/aresdb/datanode/generated/proto/rpc"
 	"github.com/uber/aresdb/diskstore"
@@ -46,6 +52,7 @@ import (
 	"github.com/uber/aresdb/metastore"
 	metaCom "github.com/uber/aresdb/metastore/common"
 	"github.com/uber/aresdb/redolog"
+	"github.com/uber/aresdb/replication"
 	"github.com/uber/aresdb/utils"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
@@ -60,12 +67,22 @@ type dataNode struct {
 	startedAt       time.Time
 	shardSet        shard.ShardSet
 	clusterServices services.Services
+	membership      membership.Membership
+
+	// only set when using etcd coordination backend
+	etcdClient *kvstore.EtcdClient
 
 	topo       topology.Topology
 	enumReader mutatorsCom.EnumReader
 	metaStore  metaCom.MetaStore
 	memStore   memstore.MemStore
 	diskStore  diskstore.DiskStore
+	// nil if backup is not enabled.
+	backupManager *backup.Manager
+	// nil if replication is not enabled.
+	replicationAgent *replication.Agent
+	// nil if change data capture is not enabled.
+	cdcPublisher *cdc.Publisher
 
 	opts     Options
 	logger   common.Logger
@@ -76,8 +93,14 @@ type dataNode struct {
 	redoLogManagerMaster *redolog.RedoLogManagerMaster
 	grpcServer           *grpc.Server
 
-	mapWatch topology.MapWatch
-	close    chan struct{}
+	// 1 while running as warm standby not yet promoted, updated atomically.
+	standby int32
+	// only set when running as warm standby.
+	placementMutator mutatorsCom.PlacementMutator
+
+	mapWatch  topology.MapWatch
+	close     chan struct{}
+	closeOnce sync.Once
 
 	readyCh chan struct{}
 }
@@ -87,6 +110,8 @@ type datanodeHandlers struct {
 	enumHandler        *api.EnumHandler
 	queryHandler       *api.QueryHandler
 	dataHandler        *api.DataHandler
+	jobHandler         *api.JobHandler
+	statusHandler      *api.StatusHandler
 	nodeModuleHandler  http.Handler
 	debugStaticHandler http.Handler
 	debugHandler       *api.DebugHandler
@@ -117,7 +142,13 @@ func NewDataNode(
 	if err != nil {
 		return nil, utils.StackError(err, "failed to initialize local metastore")
 	}
-	diskStore := diskstore.NewLocalDiskStore(opts.ServerConfig().RootPath)
+	diskStore, err := diskstore.WithEncryption(diskstore.NewLocalDiskStore(opts.ServerConfig().RootPath),
+		opts.ServerConfig().DiskStore.Encryption, metastore.ColumnEncryptionChecker(metaStore))
+	if err != nil {
+		return nil, utils.StackError(err, "failed to initialize disk store encryption")
+	}
+	diskStore = diskstore.WithCompression(diskStore, opts.ServerConfig().DiskStore.Compression,
+		metastore.ColumnCompressionLevel(metaStore, opts.ServerConfig().DiskStore.Compression.Enable))
 
 	bootstrapServer := bootstrap.NewPeerDataNodeServer(metaStore, diskStore)
 	bootstrapToken := bootstrapServer.(memCom.BootStrapToken)
@@ -127,10 +158,39 @@ func NewDataNode(
 	if err != nil {
 		return nil, utils.StackError(err, "failed to initialize redolog manager master")
 	}
+	if redologCfg.ObjectStore.Enable {
+		redoLogObjectStore, err := backup.NewObjectStore(redologCfg.ObjectStore)
+		if err != nil {
+			return nil, utils.StackError(err, "failed to initialize redolog object store")
+		}
+		redoLogManagerMaster.SetLogStore(redolog.NewObjectLogStore(redoLogObjectStore, redologCfg.ObjectStore.Prefix))
+	}
+
+	cdcPublisher, err := cdc.NewPublisher(opts.ServerConfig().CDC)
+	if err != nil {
+		return nil, utils.StackError(err, "failed to initialize change data capture")
+	}
 
 	numShards := len(topo.Get().ShardSet().AllIDs())
+	memStoreOptions := []memstore.Option{memstore.WithNumShards(numShards),
+		memstore.WithReplayConcurrency(opts.ServerConfig().RedoLogConfig.ReplayConcurrency)}
+	if cdcPublisher != nil {
+		memStoreOptions = append(memStoreOptions, memstore.WithChangePublisher(cdcPublisher))
+	}
+	if throttleCfg := opts.ServerConfig().IngestionThrottle; throttleCfg.Enable {
+		memStoreOptions = append(memStoreOptions, memstore.WithIngestionThrottle(throttleCfg.MemoryUsageRatio,
+			throttleCfg.MaxRedoLogSizeMB*1024*1024, time.Duration(throttleCfg.RetryAfterSeconds)*time.Second))
+	}
 	memStore := memstore.NewMemStore(metaStore, diskStore,
-		memstore.NewOptions(bootstrapToken, redoLogManagerMaster, memstore.WithNumShards(numShards)))
+		memstore.NewOptions(bootstrapToken, redoLogManagerMaster, memStoreOptions...))
+
+	var backupManager *backup.Manager
+	if opts.ServerConfig().Backup.Enable {
+		backupManager, err = backup.NewManager(opts.ServerConfig().Backup, metaStore, diskStore, memStore)
+		if err != nil {
+			return nil, utils.StackError(err, "failed to initialize backup manager")
+		}
+	}
 
 	grpcServer := grpc.NewServer()
 	rpc.RegisterPeerDataNodeServer(grpcServer, bootstrapServer)
@@ -143,6 +203,8 @@ func NewDataNode(
 		metaStore:            metaStore,
 		memStore:             memStore,
 		diskStore:            diskStore,
+		backupManager:        backupManager,
+		cdcPublisher:         cdcPublisher,
 		opts:                 opts,
 		logger:               logger,
 		metrics:              newDatanodeMetrics(scope),
@@ -153,6 +215,8 @@ func NewDataNode(
 		readyCh:              make(chan struct{}),
 	}
 	d.handlers = d.newHandlers()
+	// not ready until all owned shards are bootstrapped
+	d.handlers.healthCheckHandler.SetReady(false)
 	d.bootstrapManager = NewBootstrapManager(d.hostID, memStore, opts.BootstrapOptions(), topo)
 	clusterClient, err := d.opts.ServerConfig().Cluster.Etcd.NewClient(instrument.NewOptions())
 	if err != nil {
@@ -162,6 +226,33 @@ func NewDataNode(
 	if err != nil {
 		return nil, utils.StackError(err, "failed to create cluster services client")
 	}
+	d.membership = d.newMembership()
+
+	clusterCfg := d.opts.ServerConfig().Cluster
+	if clusterCfg.UseEtcdBackend() || clusterCfg.Standby.Enable {
+		txnStore, err := clusterClient.Txn()
+		if err != nil {
+			return nil, utils.StackError(err, "failed to create etcd txn store")
+		}
+		etcdClient := &kvstore.EtcdClient{
+			Zone:          clusterCfg.Etcd.Zone,
+			Environment:   clusterCfg.Etcd.Env,
+			ServiceName:   clusterCfg.Etcd.Service,
+			ClusterClient: clusterClient,
+			TxnStore:      txnStore,
+			Services:      d.clusterServices,
+		}
+		if clusterCfg.UseEtcdBackend() {
+			d.etcdClient = etcdClient
+		}
+		if clusterCfg.Standby.Enable {
+			if clusterCfg.Standby.Primary == "" {
+				return nil, utils.StackError(nil, "missing primary of standby datanode")
+			}
+			d.standby = 1
+			d.placementMutator = mutatorsEtcd.NewPlacementMutator(etcdClient)
+		}
+	}
 	return d, nil
 }
 
@@ -189,9 +280,8 @@ func (d *dataNode) Open() error {
 
 	select {
 	case <-d.mapWatch.C():
-		hostShardSet, ok := d.mapWatch.Get().LookupHostShardSet(d.hostID)
-		if ok {
-			d.assignShardSet(hostShardSet.ShardSet())
+		if shardSet, ok := d.lookupShardSet(d.mapWatch.Get()); ok {
+			d.assignShardSet(shardSet)
 		}
 	default:
 	}
@@ -219,26 +309,47 @@ func (d *dataNode) Open() error {
 	go d.startAnalyzingServerReadiness()
 	// 10. start bootstrap retry watch
 	go d.startBootstrapRetryWatch()
+	// 11. start taking over primary on failure if running as warm standby
+	if d.isStandby() {
+		go d.startStandbyFailoverWatch()
+	}
 
 	return nil
 }
 
 func (d *dataNode) startSchemaWatch() {
 	if d.opts.ServerConfig().Cluster.Enable {
-		// TODO better to reuse the code directly in controller to talk to etcd
 		if d.opts.ServerConfig().Cluster.Namespace == "" {
 			d.logger.Fatal("Missing cluster name")
 		}
-		controllerClientCfg := d.opts.ServerConfig().Cluster.Controller
-		if controllerClientCfg == nil {
-			d.logger.Fatal("Missing controller client config")
-		}
-		if d.opts.ServerConfig().Cluster.InstanceID != "" {
-			controllerClientCfg.Headers.Add(controllerCli.InstanceNameHeaderKey, d.opts.ServerConfig().Cluster.InstanceID)
+
+		var (
+			controllerClient controllerCli.ControllerClient
+			schemaWatch      <-chan struct{}
+		)
+		if d.etcdClient != nil {
+			etcdControllerClient := etcdCli.NewControllerClient(d.etcdClient, d.hostID, zap.NewExample().Sugar())
+			var err error
+			schemaWatch, err = etcdControllerClient.WatchSchema(d.opts.ServerConfig().Cluster.Namespace, d.close)
+			if err != nil {
+				d.logger.With("error", err.Error()).Error("failed to watch schema, only fetch schema periodically")
+			}
+			controllerClient = etcdControllerClient
+		} else {
+			controllerClientCfg := d.opts.ServerConfig().Cluster.Controller
+			if controllerClientCfg == nil {
+				d.logger.Fatal("Missing controller client config")
+			}
+			if d.opts.ServerConfig().Cluster.InstanceID != "" {
+				controllerClientCfg.Headers.Add(controllerCli.InstanceNameHeaderKey, d.opts.ServerConfig().Cluster.InstanceID)
+			}
+			controllerClient = controllerCli.NewControllerHTTPClient(controllerClientCfg.Address, time.Duration(controllerClientCfg.TimeoutSec)*time.Second, controllerClientCfg.Headers)
 		}
 
-		controllerClient := controllerCli.NewControllerHTTPClient(controllerClientCfg.Address, time.Duration(controllerClientCfg.TimeoutSec)*time.Second, controllerClientCfg.Headers)
 		schemaFetchJob := metastore.NewSchemaFetchJob(30, d.metaStore, nil, metastore.NewTableSchameValidator(), controllerClient, nil, d.opts.ServerConfig().Cluster.Namespace, "")
+		schemaFetchJob.EnableSchemaRollout()
+		schemaFetchJob.SetSchemaWatch(schemaWatch)
+		d.handlers.queryHandler.SetSchemaRefresher(schemaFetchJob.Refresh)
 		// immediate initial fetch
 		schemaFetchJob.FetchSchema()
 		go schemaFetchJob.Run()
@@ -246,16 +357,47 @@ func (d *dataNode) startSchemaWatch() {
 }
 
 func (d *dataNode) Close() {
+	d.closeOnce.Do(d.shutdown)
+}
+
+// shutdown stops routing queries to this datanode before stopping it, so that
+// rolling restarts will not fail in flight queries.
+func (d *dataNode) shutdown() {
+	d.handlers.healthCheckHandler.SetReady(false)
+	if d.membership != nil && d.membership.Registered() {
+		if err := d.membership.Deregister(); err != nil {
+			d.logger.With("error", err.Error()).Error("failed to deregister datanode")
+		} else {
+			d.logger.Info("deregistered datanode from cluster")
+		}
+		// wait for brokers to pick up the membership change
+		time.Sleep(time.Duration(d.opts.ServerConfig().Cluster.Membership.DrainSec) * time.Second)
+	}
+
 	close(d.close)
 	if d.mapWatch != nil {
 		d.mapWatch.Close()
 		d.mapWatch = nil
 	}
 	d.grpcServer.Stop()
+	if d.replicationAgent != nil {
+		d.replicationAgent.Stop()
+	}
 	d.redoLogManagerMaster.Stop()
+	if d.cdcPublisher != nil {
+		d.cdcPublisher.Close()
+	}
 }
 
 func (d *dataNode) startDebugServer() {
+	debugRouter := d.newDebugRouter()
+	d.opts.InstrumentOptions().Logger().Infof("Starting HTTP server on dbg-port %d", d.opts.ServerConfig().DebugPort)
+	d.opts.InstrumentOptions().Logger().Fatal(utils.ListenAndServe(d.opts.ServerConfig().DebugPort, debugRouter))
+}
+
+// newDebugRouter creates the router of debug port, admin debug handlers are authenticated
+// by the configured http wrappers.
+func (d *dataNode) newDebugRouter() *mux.Router {
 	debugRouter := mux.NewRouter()
 	debugRouter.PathPrefix("/node_modules/").Handler(d.handlers.nodeModuleHandler)
 	debugRouter.PathPrefix("/static/").Handler(d.handlers.debugStaticHandler)
@@ -265,11 +407,12 @@ func (d *dataNode) startDebugServer() {
 	debugRouter.HandleFunc("/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
 	debugRouter.PathPrefix("/debug/pprof/").Handler(http.HandlerFunc(pprof.Index))
 
-	d.handlers.debugHandler.Register(debugRouter.PathPrefix("/dbg").Subrouter())
+	// health and readiness are served on debug port during bootstrap
+	debugRouter.HandleFunc("/health", d.handlers.healthCheckHandler.HealthCheck)
+	debugRouter.HandleFunc("/ready", d.handlers.healthCheckHandler.ReadinessCheck)
+	d.handlers.debugHandler.Register(debugRouter.PathPrefix("/dbg").Subrouter(), d.opts.HTTPWrappers()...)
 	d.handlers.schemaHandler.RegisterForDebug(debugRouter.PathPrefix("/schema").Subrouter())
-
-	d.opts.InstrumentOptions().Logger().Infof("Starting HTTP server on dbg-port %d", d.opts.ServerConfig().DebugPort)
-	d.opts.InstrumentOptions().Logger().Fatal(http.ListenAndServe(fmt.Sprintf(":%d", d.opts.ServerConfig().DebugPort), debugRouter))
+	return debugRouter
 }
 
 func (d *dataNode) startTableAdditionWatch() {
@@ -302,10 +445,8 @@ func (d *dataNode) startActiveTopologyWatch() {
 			if !ok {
 				return
 			}
-			topoMap := d.mapWatch.Get()
-			hostShardSet, ok := topoMap.LookupHostShardSet(d.hostID)
-			if ok {
-				d.assignShardSet(hostShardSet.ShardSet())
+			if shardSet, ok := d.lookupShardSet(d.mapWatch.Get()); ok {
+				d.assignShardSet(shardSet)
 			} else {
 				// assign empty shard set when host does not appear in placement
 				d.assignShardSet(shard.NewShardSet(nil))
@@ -358,6 +499,11 @@ func (d *dataNode) startAnalyzingServerReadiness() {
 			return
 		}
 
+		// warm standby does not serve until promoted
+		if d.isStandby() {
+			continue
+		}
+
 		nonInitializing := make([]uint32, 0)
 
 		// condition for serving readiness
@@ -476,6 +622,7 @@ func (d *dataNode) Serve() {
 
 	// start advertising to the cluster
 	d.advertise()
+	d.handlers.healthCheckHandler.SetReady(true)
 	// enable archiving jobs
 	if !d.opts.ServerConfig().SchedulerOff {
 		d.memStore.GetScheduler().EnableJobType(memCom.ArchivingJobType, true)
@@ -494,15 +641,18 @@ func (d *dataNode) Serve() {
 	d.handlers.enumHandler.Register(router.PathPrefix("/schema").Subrouter(), httpWrappers...)
 	d.handlers.dataHandler.Register(router.PathPrefix("/data").Subrouter(), httpWrappers...)
 	d.handlers.queryHandler.Register(router.PathPrefix("/query").Subrouter(), httpWrappers...)
+	d.handlers.jobHandler.Register(router, httpWrappers...)
+	d.handlers.statusHandler.Register(router, httpWrappers...)
 
 	router.PathPrefix("/swagger/").Handler(d.handlers.swaggerHandler)
 	router.PathPrefix("/node_modules/").Handler(d.handlers.nodeModuleHandler)
 	router.HandleFunc("/health", utils.WithMetricsFunc(d.handlers.healthCheckHandler.HealthCheck))
+	router.HandleFunc("/ready", d.handlers.healthCheckHandler.ReadinessCheck)
 	router.HandleFunc("/version", d.handlers.healthCheckHandler.Version)
 
 	// Support CORS calls.
 	allowOrigins := handlers.AllowedOrigins([]string{"*"})
-	allowHeaders := handlers.AllowedHeaders([]string{"Accept", "Accept-Language", "Content-Language", "Origin", "Content-Type"})
+	allowHeaders := handlers.AllowedHeaders([]string{"Accept", "Accept-Language", "Content-Language", "Origin", "Content-Type", "Authorization"})
 	allowMethods := handlers.AllowedMethods([]string{"GET", "PUT", "POST", "DELETE", "OPTIONS"})
 
 	// record time from data node started to actually serving
@@ -512,30 +662,40 @@ func (d *dataNode) Serve() {
 	batchStatsReporter := memstore.NewBatchStatsReporter(5*60, d.memStore, d)
 	go batchStatsReporter.Run()
 
+	// start replication agent
+	if d.opts.ServerConfig().Replication.Enable {
+		agent, err := replication.NewAgent(d.opts.ServerConfig().Replication, d.opts.ServerConfig().RootPath,
+			d.diskStore, d.memStore, d)
+		if err != nil {
+			d.logger.With("error", err.Error()).Fatal("failed to initialize replication agent")
+		}
+		d.replicationAgent = agent
+		go agent.Run()
+	}
+
 	d.opts.InstrumentOptions().Logger().Infof("Starting HTTP server on port %d with max connection %d", d.opts.ServerConfig().Port, d.opts.ServerConfig().HTTP.MaxConnections)
 	utils.LimitServe(d.opts.ServerConfig().Port, handlers.CORS(allowOrigins, allowHeaders, allowMethods)(mixedHandler(d.grpcServer, router)), d.opts.ServerConfig().HTTP)
 }
 
-func (d *dataNode) advertise() {
+func (d *dataNode) newMembership() membership.Membership {
+	clusterCfg := d.opts.ServerConfig().Cluster
 	serviceID := services.NewServiceID().
-		SetEnvironment(d.opts.ServerConfig().Cluster.Etcd.Env).
-		SetZone(d.opts.ServerConfig().Cluster.Etcd.Zone).
-		SetName(utils.DataNodeServiceName(d.opts.ServerConfig().Cluster.Namespace))
-
-	err := d.clusterServices.SetMetadata(serviceID, services.NewMetadata().
-		SetHeartbeatInterval(time.Duration(d.opts.ServerConfig().Cluster.HeartbeatConfig.Interval)*time.Second).
-		SetLivenessInterval(time.Duration(d.opts.ServerConfig().Cluster.HeartbeatConfig.Timeout)*time.Second))
-	if err != nil {
-		d.logger.With("error", err.Error()).Fatalf("failed to set heart beat metadata")
-	}
-
-	placementInstance := placement.NewInstance().SetID(d.hostID)
-	ad := services.NewAdvertisement().
-		SetServiceID(serviceID).
-		SetPlacementInstance(placementInstance)
+		SetEnvironment(clusterCfg.Etcd.Env).
+		SetZone(clusterCfg.Etcd.Zone).
+		SetName(utils.DataNodeServiceName(clusterCfg.Namespace))
+
+	metadata := services.NewMetadata().
+		SetHeartbeatInterval(time.Duration(clusterCfg.HeartbeatConfig.Interval) * time.Second).
+		SetLivenessInterval(time.Duration(clusterCfg.HeartbeatConfig.Timeout) * time.Second)
+
+	placementInstance := placement.NewInstance().
+		SetID(d.hostID).
+		SetIsolationGroup(models.EncodeLocality(clusterCfg.Locality.Zone, clusterCfg.Locality.Rack))
+	return membership.NewServiceMembership(d.clusterServices, serviceID, metadata, placementInstance)
+}
 
-	err = d.clusterServices.Advertise(ad)
-	if err != nil {
+func (d *dataNode) advertise() {
+	if err := d.membership.Register(); err != nil {
 		d.logger.With("error", err.Error()).Fatalf("failed to advertise data node")
 	} else {
 		d.logger.Info("start advertising datanode to cluster")
@@ -555,15 +715,17 @@ func (d *dataNode) addTable(table string) {
 	}
 	isFactTable := schema.Schema.IsFactTable
 
-	if !isFactTable {
-		d.logger.With("table", table, "shard", 0).Info("adding table shard on schema addition")
-		// dimension table defaults shard to zero
-		// new table does not need to copy data from peer
-		d.memStore.AddTableShard(table, 0, false)
-	} else {
-		for _, shardID := range d.shardSet.AllIDs() {
-			d.logger.With("table", table, "shard", shardID).Info("adding table shard on schema addition")
+	shardIDs := []uint32{0}
+	if isFactTable {
+		shardIDs = d.shardSet.AllIDs()
+	}
+	for _, shardID := range shardIDs {
+		d.logger.With("table", table, "shard", shardID).Info("adding table shard on schema addition")
+		if d.isStandby() {
+			d.memStore.AddStandbyTableShard(table, int(shardID))
+		} else {
 			// new table does not need to copy data from peer
+			// dimension table defaults shard to zero
 			d.memStore.AddTableShard(table, int(shardID), false)
 		}
 	}
@@ -636,11 +798,17 @@ func (d *dataNode) assignShardSet(shardSet shard.ShardSet) {
 		}
 	}
 
+	// warm standby copies data of shards owned by its primary from peers
+	standby := d.isStandby()
 	for _, shard := range adding {
 		needPeerCopy := shard.State() == m3Shard.Initializing
 		for _, table := range factTables {
-			d.logger.With("table", table, "shard", shard.ID(), "state", shard.State()).Info("adding fact table shard on placement change")
-			d.memStore.AddTableShard(table, int(shard.ID()), needPeerCopy)
+			d.logger.With("table", table, "shard", shard.ID(), "state", shard.State(), "standby", standby).Info("adding fact table shard on placement change")
+			if standby {
+				d.memStore.AddStandbyTableShard(table, int(shard.ID()))
+			} else {
+				d.memStore.AddTableShard(table, int(shard.ID()), needPeerCopy)
+			}
 		}
 	}
 
@@ -656,7 +824,11 @@ func (d *dataNode) assignShardSet(shardSet shard.ShardSet) {
 			d.logger.With("table", table, "shard", 0).Info("adding dimension table shard on placement change")
 			// only copy data from peer for dimension table
 			// when from zero shards to all initialing shards
-			d.memStore.AddTableShard(table, 0, needPeerCopy)
+			if standby {
+				d.memStore.AddStandbyTableShard(table, 0)
+			} else {
+				d.memStore.AddTableShard(table, 0, needPeerCopy)
+			}
 		}
 	}
 
@@ -696,11 +868,14 @@ func newDatanodeMetrics(scope tally.Scope) datanodeMetrics {
 
 func (d *dataNode) newHandlers() datanodeHandlers {
 	healthCheckHandler := api.NewHealthCheckHandler()
+	queryHandler := api.NewQueryHandler(d.memStore, d.metaStore, d, d.opts.ServerConfig().Query)
 	return datanodeHandlers{
 		schemaHandler:      api.NewSchemaHandler(d.metaStore),
 		enumHandler:        api.NewEnumHandler(d.memStore, d.metaStore),
-		queryHandler:       api.NewQueryHandler(d.memStore, d, d.opts.ServerConfig().Query),
+		queryHandler:       queryHandler,
+		statusHandler:      api.NewStatusHandler(d.memStore, d, queryHandler, healthCheckHandler),
 		dataHandler:        api.NewDataHandler(d.memStore),
+		jobHandler:         api.NewJobHandler(d.memStore, d.backupManager),
 		nodeModuleHandler:  http.StripPrefix("/node_modules/", http.FileServer(http.Dir("./api/ui/node_modules/"))),
 		debugStaticHandler: http.StripPrefix("/static/", utils.NoCache(http.FileServer(http.Dir("./api/ui/debug/")))),
 		swaggerHandler:     http.StripPrefix("/swagger/", http.FileServer(http.Dir("./api/ui/swagger/"))),
@@ -732,4 +907,4 @@ func (d *dataNode) startBootstrapRetryWatch() {
 			}()
 		}
 	}
-}
\ No newline at end of file
+}
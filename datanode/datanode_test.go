@@ -1,9 +1,14 @@
 package datanode
 
 import (
+	"errors"
 	"github.com/uber/aresdb/api"
 	"github.com/uber/aresdb/controller/mutators/mocks"
+	"github.com/uber/aresdb/utils"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 
 	"github.com/m3db/m3/src/cluster/client/etcd"
 	"github.com/onsi/ginkgo"
@@ -19,6 +24,17 @@ import (
 	memStoreMocks "github.com/uber/aresdb/memstore/mocks"
 )
 
+// testAuthenticator authenticates requests with bearer token as the principal named by the token.
+type testAuthenticator struct{}
+
+func (testAuthenticator) Authenticate(r *http.Request) (*utils.Principal, error) {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return nil, errors.New("missing bearer token")
+	}
+	return &utils.Principal{Name: strings.TrimPrefix(authorization, "Bearer ")}, nil
+}
+
 var _ = ginkgo.Describe("datanode", func() {
 
 	ginkgo.It("NewDataNode", func() {
@@ -148,6 +164,41 @@ var _ = ginkgo.Describe("datanode", func() {
 		Ω(shards[0]).Should(Equal(uint32(1)))
 	})
 
+	ginkgo.It("newDebugRouter should authenticate admin debug handlers", func() {
+		memStore := new(memStoreMocks.MemStore)
+		memStore.On("GetTableShard", "t1", 0).Return(nil, errors.New("table not found"))
+		dataNode := dataNode{
+			opts: NewOptions().SetHTTPWrappers([]utils.HTTPHandlerWrapper{
+				utils.WithAuthentication(testAuthenticator{}),
+			}),
+		}
+		dataNode.handlers = datanodeHandlers{
+			schemaHandler:      api.NewSchemaHandler(nil),
+			healthCheckHandler: api.NewHealthCheckHandler(),
+		}
+		dataNode.handlers.debugHandler = api.NewDebugHandler("test", memStore, nil, nil, dataNode.handlers.healthCheckHandler, nil, nil)
+		testServer := httptest.NewServer(dataNode.newDebugRouter())
+		defer testServer.Close()
+
+		request := func(method, path, token string) int {
+			r, err := http.NewRequest(method, testServer.URL+path, nil)
+			Ω(err).Should(BeNil())
+			if token != "" {
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+			resp, err := http.DefaultClient.Do(r)
+			Ω(err).Should(BeNil())
+			return resp.StatusCode
+		}
+
+		Ω(request(http.MethodGet, "/dbg/health", "")).Should(Equal(http.StatusOK))
+		Ω(request(http.MethodGet, "/dbg/t1/0/batches/1/dump", "")).Should(Equal(http.StatusUnauthorized))
+		Ω(request(http.MethodPut, "/dbg/t1/0/batches/1/dump", "")).Should(Equal(http.StatusUnauthorized))
+		Ω(request(http.MethodPost, "/dbg/t1/0/archive", "")).Should(Equal(http.StatusUnauthorized))
+		// authenticated requests reach the handlers.
+		Ω(request(http.MethodGet, "/dbg/t1/0/batches/1/dump", "admin")).Should(Equal(http.StatusBadRequest))
+	})
+
 	ginkgo.It("startBootstrapRetryWatch", func() {
 		dataNode := dataNode{}
 		dataNode.handlers = datanodeHandlers{}
@@ -42,6 +42,36 @@ func (_m *PeerDataNodeClient) BenchmarkFileTransfer(ctx context.Context, in *rpc
 	return r0, r1
 }
 
+// FetchRedoLog provides a mock function with given fields: ctx, in, opts
+func (_m *PeerDataNodeClient) FetchRedoLog(ctx context.Context, in *rpc.RedoLogRequest, opts ...grpc.CallOption) (rpc.PeerDataNode_FetchRedoLogClient, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 rpc.PeerDataNode_FetchRedoLogClient
+	if rf, ok := ret.Get(0).(func(context.Context, *rpc.RedoLogRequest, ...grpc.CallOption) rpc.PeerDataNode_FetchRedoLogClient); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(rpc.PeerDataNode_FetchRedoLogClient)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *rpc.RedoLogRequest, ...grpc.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // FetchTableShardMetaData provides a mock function with given fields: ctx, in, opts
 func (_m *PeerDataNodeClient) FetchTableShardMetaData(ctx context.Context, in *rpc.TableShardMetaDataRequest, opts ...grpc.CallOption) (*rpc.TableShardMetaData, error) {
 	_va := make([]interface{}, len(opts))
@@ -661,6 +661,7 @@ type VectorPartyRawDataRequest struct {
 	ColumnID             uint32                              `protobuf:"varint,7,opt,name=columnID,proto3" json:"columnID,omitempty"`
 	SessionID            int64                               `protobuf:"varint,8,opt,name=sessionID,proto3" json:"sessionID,omitempty"`
 	NodeID               string                              `protobuf:"bytes,9,opt,name=nodeID,proto3" json:"nodeID,omitempty"`
+	CompressionLevel     int32                               `protobuf:"varint,10,opt,name=compressionLevel,proto3" json:"compressionLevel,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}                            `json:"-"`
 	XXX_unrecognized     []byte                              `json:"-"`
 	XXX_sizecache        int32                               `json:"-"`
@@ -777,6 +778,13 @@ func (m *VectorPartyRawDataRequest) GetNodeID() string {
 	return ""
 }
 
+func (m *VectorPartyRawDataRequest) GetCompressionLevel() int32 {
+	if m != nil {
+		return m.CompressionLevel
+	}
+	return 0
+}
+
 // XXX_OneofWrappers is for the internal use of the proto package.
 func (*VectorPartyRawDataRequest) XXX_OneofWrappers() []interface{} {
 	return []interface{}{
@@ -787,6 +795,7 @@ func (*VectorPartyRawDataRequest) XXX_OneofWrappers() []interface{} {
 
 type VectorPartyRawData struct {
 	Chunk                []byte   `protobuf:"bytes,1,opt,name=chunk,proto3" json:"chunk,omitempty"`
+	Compressed           bool     `protobuf:"varint,2,opt,name=compressed,proto3" json:"compressed,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -824,6 +833,13 @@ func (m *VectorPartyRawData) GetChunk() []byte {
 	return nil
 }
 
+func (m *VectorPartyRawData) GetCompressed() bool {
+	if m != nil {
+		return m.Compressed
+	}
+	return false
+}
+
 type StartSessionRequest struct {
 	Table                string   `protobuf:"bytes,1,opt,name=table,proto3" json:"table,omitempty"`
 	Shard                uint32   `protobuf:"varint,2,opt,name=shard,proto3" json:"shard,omitempty"`
@@ -1114,6 +1130,140 @@ func (m *HealthCheckResponse) GetStatus() HealthCheckResponse_ServingStatus {
 	return HealthCheckResponse_UNKNOWN
 }
 
+type RedoLogRequest struct {
+	Table                string   `protobuf:"bytes,1,opt,name=table,proto3" json:"table,omitempty"`
+	Shard                uint32   `protobuf:"varint,2,opt,name=shard,proto3" json:"shard,omitempty"`
+	RedoFileID           int64    `protobuf:"varint,3,opt,name=redoFileID,proto3" json:"redoFileID,omitempty"`
+	RedoFileOffset       uint32   `protobuf:"varint,4,opt,name=redoFileOffset,proto3" json:"redoFileOffset,omitempty"`
+	SessionID            int64    `protobuf:"varint,5,opt,name=sessionID,proto3" json:"sessionID,omitempty"`
+	NodeID               string   `protobuf:"bytes,6,opt,name=nodeID,proto3" json:"nodeID,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RedoLogRequest) Reset()         { *m = RedoLogRequest{} }
+func (m *RedoLogRequest) String() string { return proto.CompactTextString(m) }
+func (*RedoLogRequest) ProtoMessage()    {}
+func (*RedoLogRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7b771d46e8b2ce71, []int{18}
+}
+
+func (m *RedoLogRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RedoLogRequest.Unmarshal(m, b)
+}
+func (m *RedoLogRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RedoLogRequest.Marshal(b, m, deterministic)
+}
+func (m *RedoLogRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RedoLogRequest.Merge(m, src)
+}
+func (m *RedoLogRequest) XXX_Size() int {
+	return xxx_messageInfo_RedoLogRequest.Size(m)
+}
+func (m *RedoLogRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RedoLogRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RedoLogRequest proto.InternalMessageInfo
+
+func (m *RedoLogRequest) GetTable() string {
+	if m != nil {
+		return m.Table
+	}
+	return ""
+}
+
+func (m *RedoLogRequest) GetShard() uint32 {
+	if m != nil {
+		return m.Shard
+	}
+	return 0
+}
+
+func (m *RedoLogRequest) GetRedoFileID() int64 {
+	if m != nil {
+		return m.RedoFileID
+	}
+	return 0
+}
+
+func (m *RedoLogRequest) GetRedoFileOffset() uint32 {
+	if m != nil {
+		return m.RedoFileOffset
+	}
+	return 0
+}
+
+func (m *RedoLogRequest) GetSessionID() int64 {
+	if m != nil {
+		return m.SessionID
+	}
+	return 0
+}
+
+func (m *RedoLogRequest) GetNodeID() string {
+	if m != nil {
+		return m.NodeID
+	}
+	return ""
+}
+
+type RedoLogUpsertBatch struct {
+	RedoFileID           int64    `protobuf:"varint,1,opt,name=redoFileID,proto3" json:"redoFileID,omitempty"`
+	RedoFileOffset       uint32   `protobuf:"varint,2,opt,name=redoFileOffset,proto3" json:"redoFileOffset,omitempty"`
+	UpsertBatch          []byte   `protobuf:"bytes,3,opt,name=upsertBatch,proto3" json:"upsertBatch,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RedoLogUpsertBatch) Reset()         { *m = RedoLogUpsertBatch{} }
+func (m *RedoLogUpsertBatch) String() string { return proto.CompactTextString(m) }
+func (*RedoLogUpsertBatch) ProtoMessage()    {}
+func (*RedoLogUpsertBatch) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7b771d46e8b2ce71, []int{19}
+}
+
+func (m *RedoLogUpsertBatch) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RedoLogUpsertBatch.Unmarshal(m, b)
+}
+func (m *RedoLogUpsertBatch) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RedoLogUpsertBatch.Marshal(b, m, deterministic)
+}
+func (m *RedoLogUpsertBatch) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RedoLogUpsertBatch.Merge(m, src)
+}
+func (m *RedoLogUpsertBatch) XXX_Size() int {
+	return xxx_messageInfo_RedoLogUpsertBatch.Size(m)
+}
+func (m *RedoLogUpsertBatch) XXX_DiscardUnknown() {
+	xxx_messageInfo_RedoLogUpsertBatch.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RedoLogUpsertBatch proto.InternalMessageInfo
+
+func (m *RedoLogUpsertBatch) GetRedoFileID() int64 {
+	if m != nil {
+		return m.RedoFileID
+	}
+	return 0
+}
+
+func (m *RedoLogUpsertBatch) GetRedoFileOffset() uint32 {
+	if m != nil {
+		return m.RedoFileOffset
+	}
+	return 0
+}
+
+func (m *RedoLogUpsertBatch) GetUpsertBatch() []byte {
+	if m != nil {
+		return m.UpsertBatch
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("rpc.HealthCheckResponse_ServingStatus", HealthCheckResponse_ServingStatus_name, HealthCheckResponse_ServingStatus_value)
 	proto.RegisterType((*KafkaOffset)(nil), "rpc.KafkaOffset")
@@ -1134,78 +1284,86 @@ func init() {
 	proto.RegisterType((*BenchmarkRequest)(nil), "rpc.BenchmarkRequest")
 	proto.RegisterType((*HealthCheckRequest)(nil), "rpc.HealthCheckRequest")
 	proto.RegisterType((*HealthCheckResponse)(nil), "rpc.HealthCheckResponse")
+	proto.RegisterType((*RedoLogRequest)(nil), "rpc.RedoLogRequest")
+	proto.RegisterType((*RedoLogUpsertBatch)(nil), "rpc.RedoLogUpsertBatch")
 }
 
 func init() { proto.RegisterFile("peer_streaming.proto", fileDescriptor_7b771d46e8b2ce71) }
 
 var fileDescriptor_7b771d46e8b2ce71 = []byte{
-	// 1042 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x56, 0xeb, 0x6e, 0xe3, 0xc4,
-	0x17, 0xf7, 0x25, 0x97, 0xf6, 0x24, 0x69, 0xf3, 0x9f, 0x76, 0xdb, 0x6c, 0xf7, 0xaf, 0x2a, 0x1a,
-	0xa1, 0x55, 0x55, 0xa1, 0x68, 0x37, 0x08, 0x71, 0xd3, 0xae, 0xd8, 0x50, 0xba, 0xa9, 0x2a, 0xd2,
-	0x6a, 0x5c, 0xba, 0x02, 0x81, 0x56, 0x13, 0x67, 0x52, 0x5b, 0x49, 0xec, 0xec, 0xcc, 0x24, 0x08,
-	0x5e, 0x80, 0xcf, 0x3c, 0x04, 0xbc, 0x04, 0x4f, 0xc1, 0x23, 0x20, 0x5e, 0x04, 0xcd, 0xf8, 0x52,
-	0x3b, 0x4e, 0xfa, 0x69, 0xf9, 0xe6, 0xf3, 0xf3, 0xf9, 0x9d, 0xfb, 0x39, 0x36, 0xec, 0xcf, 0x19,
-	0xe3, 0x6f, 0x85, 0xe4, 0x8c, 0xce, 0xfc, 0xe0, 0xae, 0x33, 0xe7, 0xa1, 0x0c, 0x91, 0xcd, 0xe7,
-	0x2e, 0xfe, 0x11, 0x6a, 0x97, 0x74, 0x3c, 0xa1, 0x57, 0xe3, 0xb1, 0x60, 0x12, 0x9d, 0x42, 0xd3,
-	0xf5, 0x98, 0x3b, 0xb9, 0x0e, 0xfd, 0x40, 0x46, 0x58, 0xcb, 0x6c, 0x9b, 0x27, 0x36, 0x29, 0xe0,
-	0x08, 0x43, 0xdd, 0x0d, 0x67, 0x33, 0x3f, 0xd1, 0xb3, 0xb4, 0x5e, 0x0e, 0xc3, 0x3f, 0x00, 0xea,
-	0x51, 0x77, 0x32, 0xf6, 0xa7, 0xd3, 0xaf, 0x14, 0x7f, 0xae, 0xf8, 0xe8, 0x18, 0x80, 0xb3, 0x51,
-	0x78, 0xee, 0x4f, 0xd9, 0xc5, 0x59, 0x6c, 0x3f, 0x83, 0xa0, 0xa7, 0xb0, 0x93, 0x48, 0x19, 0xdb,
-	0x0d, 0xb2, 0x82, 0xe2, 0xef, 0x61, 0xe7, 0x15, 0x77, 0x3d, 0x7f, 0xc9, 0x6e, 0x19, 0x17, 0x7e,
-	0x18, 0x28, 0x26, 0xcd, 0x21, 0xda, 0x7a, 0x83, 0xac, 0xa0, 0xa8, 0x0d, 0xb5, 0x61, 0x1c, 0x97,
-	0xc3, 0xde, 0xc5, 0xe6, 0xb3, 0x10, 0xfe, 0x0e, 0x76, 0x9d, 0x80, 0xce, 0x85, 0x17, 0xca, 0x84,
-	0xf4, 0xbe, 0xc2, 0x7e, 0x0e, 0x7b, 0xb7, 0xcc, 0x95, 0x21, 0xbf, 0xa6, 0x5c, 0xfe, 0xfc, 0x0d,
-	0x93, 0xf4, 0x8c, 0x4a, 0x8a, 0x8e, 0x60, 0xcb, 0x0d, 0xa7, 0x8b, 0x59, 0x10, 0x1b, 0x6f, 0x90,
-	0x54, 0xc6, 0x7f, 0x98, 0xd0, 0xe8, 0x51, 0xe9, 0x7a, 0xa9, 0x76, 0x0b, 0xaa, 0x43, 0x05, 0xc4,
-	0xca, 0x65, 0x92, 0x88, 0x08, 0x41, 0x49, 0xf8, 0xbf, 0xb0, 0xd8, 0xb9, 0x7e, 0x46, 0x5f, 0x14,
-	0xea, 0x62, 0xb7, 0xcd, 0x93, 0x5a, 0x77, 0xaf, 0xc3, 0xe7, 0x6e, 0x27, 0x5f, 0xc4, 0x42, 0xb1,
-	0x4e, 0xc1, 0x5e, 0xce, 0x45, 0xab, 0xd4, 0xb6, 0x4f, 0x6a, 0xdd, 0x96, 0x66, 0xac, 0x89, 0x9f,
-	0x28, 0x25, 0xfc, 0xab, 0x09, 0x07, 0xe7, 0xd4, 0x95, 0x37, 0x74, 0x38, 0x65, 0x8e, 0x47, 0xf9,
-	0x28, 0x8d, 0xf8, 0x03, 0x68, 0x78, 0xfe, 0x9d, 0xf7, 0x86, 0x4a, 0xc6, 0x67, 0x94, 0x4f, 0xe2,
-	0x24, 0xf3, 0x20, 0x7a, 0x0d, 0x68, 0x58, 0x98, 0x18, 0x9d, 0x4b, 0xad, 0x7b, 0xa8, 0x7d, 0x17,
-	0x07, 0x8a, 0xac, 0xa1, 0xe0, 0xdf, 0x4d, 0x78, 0x72, 0xe6, 0xcf, 0x58, 0xa0, 0x72, 0x58, 0x13,
-	0xce, 0x4b, 0xd8, 0x15, 0xf9, 0x06, 0xeb, 0x80, 0x6a, 0xdd, 0x7d, 0xed, 0x65, 0xa5, 0xf9, 0x64,
-	0x55, 0x59, 0x8d, 0xd0, 0x94, 0x0a, 0xd9, 0x8b, 0x9b, 0x60, 0xe9, 0x26, 0x64, 0x21, 0x95, 0x70,
-	0x2a, 0x3a, 0xaa, 0x23, 0xb6, 0xd6, 0xc9, 0x83, 0xf8, 0x2f, 0x0b, 0xd0, 0x9a, 0xf0, 0xf6, 0xa1,
-	0x2c, 0x15, 0xaa, 0x83, 0xda, 0x26, 0x91, 0xa0, 0x9c, 0xfa, 0x81, 0x4b, 0x79, 0x40, 0xa5, 0x0a,
-	0x38, 0x76, 0x9a, 0x81, 0x14, 0x4f, 0x28, 0x43, 0xda, 0x59, 0x83, 0x44, 0x02, 0xea, 0x42, 0x6d,
-	0x72, 0xbf, 0xe6, 0xad, 0x92, 0x4e, 0xb4, 0xa9, 0x13, 0xcd, 0xac, 0x3f, 0xc9, 0x2a, 0xa1, 0xcf,
-	0x60, 0x6b, 0x4c, 0x5d, 0xa9, 0x22, 0x6a, 0x95, 0x35, 0xe1, 0x89, 0x26, 0xac, 0x6f, 0x6f, 0xdf,
-	0x20, 0xa9, 0x3a, 0xea, 0x43, 0x63, 0x94, 0x94, 0x5e, 0xf3, 0x2b, 0x9a, 0xdf, 0xd6, 0xfc, 0x07,
-	0x9a, 0xd2, 0x37, 0x48, 0x9e, 0x88, 0x3e, 0x8c, 0xc7, 0x9c, 0x89, 0x56, 0x55, 0xcf, 0x1f, 0x8a,
-	0x67, 0x20, 0xb3, 0x0b, 0x24, 0x51, 0xe9, 0x55, 0xa0, 0x34, 0x63, 0x92, 0xe2, 0x7f, 0x4c, 0x78,
-	0x5c, 0xb4, 0x4e, 0xd8, 0xbb, 0x05, 0x13, 0xf2, 0x3d, 0x97, 0x16, 0x43, 0x5d, 0x48, 0xca, 0xd3,
-	0x41, 0x28, 0x69, 0x62, 0x0e, 0x53, 0x97, 0x83, 0x05, 0xa3, 0x44, 0xa3, 0xac, 0x35, 0x32, 0x08,
-	0xfa, 0x3f, 0x6c, 0x0b, 0x26, 0x54, 0xd2, 0x17, 0x67, 0xba, 0x56, 0x36, 0xb9, 0x07, 0xd0, 0x01,
-	0x54, 0x82, 0x70, 0xa4, 0x6e, 0x4e, 0x55, 0x07, 0x1c, 0x4b, 0xf8, 0x6f, 0x0b, 0x1e, 0x67, 0x16,
-	0x91, 0xd0, 0x9f, 0xfe, 0xbb, 0x2c, 0x33, 0xe7, 0xa6, 0x94, 0x3f, 0x37, 0x2f, 0x0a, 0xa7, 0xa5,
-	0xbc, 0xf1, 0xb4, 0xf4, 0x8d, 0xc2, 0x71, 0xf9, 0xb2, 0xb8, 0x86, 0x95, 0xcd, 0x6b, 0xd8, 0x37,
-	0x8a, 0x8b, 0x98, 0xbd, 0x9b, 0xd5, 0xfc, 0xdd, 0xcc, 0x17, 0x76, 0x6b, 0x73, 0x61, 0xb7, 0xb3,
-	0x85, 0xed, 0x6d, 0x43, 0x75, 0x19, 0x19, 0xc7, 0xa7, 0x80, 0x8a, 0x25, 0x56, 0x35, 0x72, 0xbd,
-	0x45, 0x10, 0x9d, 0xb0, 0x3a, 0x89, 0x04, 0x3c, 0x81, 0x3d, 0x47, 0x75, 0xdd, 0x89, 0x1c, 0x3c,
-	0xdc, 0x88, 0xb4, 0xcc, 0x56, 0xb6, 0xcc, 0x4d, 0xb0, 0xa5, 0x9c, 0xea, 0xd2, 0xdb, 0x44, 0x3d,
-	0x66, 0x62, 0x2c, 0xe5, 0x9a, 0xff, 0x1c, 0xaa, 0xb1, 0x1f, 0xb4, 0x03, 0x56, 0xfa, 0x3d, 0xb2,
-	0x72, 0x69, 0x59, 0x39, 0xca, 0xc7, 0xf0, 0xbf, 0x4b, 0xc6, 0xe6, 0xaf, 0xa6, 0xfe, 0x92, 0x11,
-	0x26, 0xe6, 0x61, 0x20, 0x58, 0x81, 0x1c, 0x47, 0x60, 0xa5, 0x11, 0xe0, 0x11, 0x34, 0x7b, 0x2c,
-	0x70, 0x3d, 0x75, 0x9e, 0x93, 0x9c, 0x10, 0x94, 0xc6, 0x7e, 0x9a, 0x92, 0x7e, 0x56, 0xb5, 0xd6,
-	0x75, 0x70, 0x92, 0x8f, 0x4f, 0x99, 0xdc, 0x03, 0x6a, 0x05, 0x86, 0x8b, 0xf1, 0x98, 0xf1, 0xcc,
-	0x25, 0xcc, 0x20, 0xb8, 0x03, 0xa8, 0xcf, 0xe8, 0x54, 0x7a, 0xfa, 0x84, 0x27, 0x7e, 0x5a, 0x50,
-	0x15, 0x8c, 0x2f, 0x7d, 0x37, 0x71, 0x95, 0x88, 0xf8, 0x37, 0x13, 0xf6, 0x72, 0x84, 0x38, 0x9f,
-	0x97, 0x50, 0x11, 0x92, 0xca, 0x85, 0xd0, 0x84, 0x9d, 0xee, 0x53, 0x3d, 0x46, 0x6b, 0x34, 0x3b,
-	0x8e, 0xb2, 0x14, 0xdc, 0x39, 0x5a, 0x9b, 0xc4, 0x2c, 0xfc, 0x39, 0x34, 0x72, 0x2f, 0x50, 0x0d,
-	0xaa, 0xdf, 0x0e, 0x2e, 0x07, 0x57, 0x6f, 0x06, 0x4d, 0x43, 0x09, 0xce, 0xd7, 0xe4, 0xf6, 0x62,
-	0xf0, 0xba, 0x69, 0xa2, 0x5d, 0xa8, 0x0d, 0xae, 0x6e, 0xde, 0x26, 0x80, 0xd5, 0xfd, 0xd3, 0x86,
-	0xfa, 0x35, 0x63, 0x5c, 0xcd, 0xc8, 0x20, 0x1c, 0x31, 0xf4, 0x02, 0x2a, 0x91, 0x67, 0x74, 0x58,
-	0x0c, 0x43, 0x67, 0x78, 0xd4, 0xda, 0x14, 0x1f, 0x36, 0xd0, 0xa7, 0x50, 0xcf, 0x0e, 0x14, 0x8a,
-	0x74, 0xd7, 0xcc, 0xd8, 0x51, 0x3d, 0x7a, 0x13, 0x81, 0xd8, 0x40, 0x9f, 0xc0, 0x76, 0xda, 0x6a,
-	0x94, 0x7b, 0x79, 0x74, 0x10, 0x5d, 0xfd, 0xd5, 0x41, 0xc0, 0xc6, 0x89, 0xf9, 0xcc, 0x44, 0x37,
-	0x70, 0x78, 0xce, 0xa4, 0xeb, 0xad, 0xf9, 0x22, 0x1d, 0x6b, 0xe2, 0xc6, 0xb3, 0x7a, 0x74, 0xb8,
-	0xe1, 0x3d, 0x36, 0xd0, 0x6d, 0x6c, 0x75, 0xcd, 0x2a, 0x1d, 0xaf, 0xfe, 0x4f, 0xe4, 0xcf, 0x58,
-	0x6c, 0xb5, 0xf8, 0x1e, 0x1b, 0xcf, 0x4c, 0x74, 0x09, 0x8f, 0xd2, 0xd1, 0x54, 0x3f, 0x58, 0x37,
-	0x9c, 0x06, 0x62, 0xcc, 0x38, 0x7a, 0x14, 0x7d, 0x25, 0x56, 0xc6, 0xf6, 0x41, 0x63, 0xc3, 0x8a,
-	0xfe, 0x2d, 0xfe, 0xe8, 0xdf, 0x00, 0x00, 0x00, 0xff, 0xff, 0x9b, 0xfe, 0x9f, 0xb3, 0x2e, 0x0b,
-	0x00, 0x00,
+	// 1147 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x57, 0xdd, 0x6e, 0x1b, 0x45,
+	0x14, 0xf6, 0x7a, 0xfd, 0x93, 0x1c, 0xdb, 0xa9, 0x99, 0xa4, 0xb1, 0xeb, 0xa2, 0x28, 0x1a, 0xa1,
+	0x2a, 0xaa, 0x50, 0xd4, 0x1a, 0x21, 0xfe, 0xd4, 0xaa, 0x35, 0x21, 0x75, 0x48, 0x71, 0xa2, 0x71,
+	0x9a, 0x0a, 0x04, 0xaa, 0xc6, 0xeb, 0x71, 0xbc, 0xb2, 0xbd, 0xbb, 0xdd, 0x19, 0x1b, 0xc1, 0x05,
+	0xb7, 0x5c, 0x71, 0xc1, 0x43, 0xc0, 0x43, 0xf0, 0x16, 0x3c, 0x03, 0xcf, 0x81, 0x84, 0x66, 0xf6,
+	0x27, 0x33, 0x5e, 0x3b, 0x42, 0x28, 0xdc, 0x79, 0xbe, 0x3d, 0xdf, 0xf9, 0x3f, 0x67, 0xc6, 0xb0,
+	0x13, 0x30, 0x16, 0xbe, 0xe1, 0x22, 0x64, 0x74, 0xe6, 0x7a, 0x57, 0x87, 0x41, 0xe8, 0x0b, 0x1f,
+	0xd9, 0x61, 0xe0, 0xe0, 0xef, 0xa0, 0x72, 0x4a, 0x47, 0x13, 0x7a, 0x36, 0x1a, 0x71, 0x26, 0xd0,
+	0x43, 0xa8, 0x3b, 0x63, 0xe6, 0x4c, 0xce, 0x7d, 0xd7, 0x13, 0x11, 0xd6, 0xb4, 0xf6, 0xad, 0x03,
+	0x9b, 0x64, 0x70, 0x84, 0xa1, 0xea, 0xf8, 0xb3, 0x99, 0x9b, 0xc8, 0xe5, 0x95, 0x9c, 0x81, 0xe1,
+	0x6f, 0x01, 0x75, 0xa8, 0x33, 0x19, 0xb9, 0xd3, 0xe9, 0xe7, 0x92, 0x1f, 0x48, 0x3e, 0xda, 0x03,
+	0x08, 0xd9, 0xd0, 0x3f, 0x76, 0xa7, 0xec, 0xe4, 0x28, 0xd6, 0xaf, 0x21, 0xe8, 0x01, 0x6c, 0x25,
+	0x27, 0x4d, 0x77, 0x8d, 0x2c, 0xa1, 0xf8, 0x1b, 0xd8, 0x7a, 0x1e, 0x3a, 0x63, 0x77, 0xc1, 0x2e,
+	0x59, 0xc8, 0x5d, 0xdf, 0x93, 0x4c, 0x6a, 0x20, 0x4a, 0x7b, 0x8d, 0x2c, 0xa1, 0x68, 0x1f, 0x2a,
+	0x83, 0xd8, 0xaf, 0x3e, 0x7b, 0x1b, 0xab, 0xd7, 0x21, 0xfc, 0x35, 0xdc, 0xe9, 0x7b, 0x34, 0xe0,
+	0x63, 0x5f, 0x24, 0xa4, 0xdb, 0x72, 0xfb, 0x31, 0x6c, 0x5f, 0x32, 0x47, 0xf8, 0xe1, 0x39, 0x0d,
+	0xc5, 0x0f, 0x5f, 0x31, 0x41, 0x8f, 0xa8, 0xa0, 0xa8, 0x05, 0x1b, 0x8e, 0x3f, 0x9d, 0xcf, 0xbc,
+	0x58, 0x79, 0x8d, 0xa4, 0x67, 0xfc, 0xbb, 0x05, 0xb5, 0x0e, 0x15, 0xce, 0x38, 0x95, 0x6e, 0x42,
+	0x79, 0x20, 0x81, 0x58, 0xb8, 0x48, 0x92, 0x23, 0x42, 0x50, 0xe0, 0xee, 0x8f, 0x2c, 0x36, 0xae,
+	0x7e, 0xa3, 0xcf, 0x32, 0x79, 0xb1, 0xf7, 0xad, 0x83, 0x4a, 0x7b, 0xfb, 0x30, 0x0c, 0x9c, 0x43,
+	0x33, 0x89, 0x99, 0x64, 0x3d, 0x04, 0x7b, 0x11, 0xf0, 0x66, 0x61, 0xdf, 0x3e, 0xa8, 0xb4, 0x9b,
+	0x8a, 0xb1, 0xc2, 0x7f, 0x22, 0x85, 0xf0, 0xcf, 0x16, 0xec, 0x1e, 0x53, 0x47, 0x5c, 0xd0, 0xc1,
+	0x94, 0xf5, 0xc7, 0x34, 0x1c, 0xa6, 0x1e, 0xbf, 0x07, 0xb5, 0xb1, 0x7b, 0x35, 0x7e, 0x4d, 0x05,
+	0x0b, 0x67, 0x34, 0x9c, 0xc4, 0x41, 0x9a, 0x20, 0x7a, 0x01, 0x68, 0x90, 0xe9, 0x18, 0x15, 0x4b,
+	0xa5, 0xdd, 0x50, 0xb6, 0xb3, 0x0d, 0x45, 0x56, 0x50, 0xf0, 0x6f, 0x16, 0xdc, 0x3f, 0x72, 0x67,
+	0xcc, 0x93, 0x31, 0xac, 0x70, 0xe7, 0x29, 0xdc, 0xe1, 0x66, 0x81, 0x95, 0x43, 0x95, 0xf6, 0x8e,
+	0xb2, 0xb2, 0x54, 0x7c, 0xb2, 0x2c, 0x2c, 0x5b, 0x68, 0x4a, 0xb9, 0xe8, 0xc4, 0x45, 0xc8, 0xab,
+	0x22, 0xe8, 0x90, 0x0c, 0x38, 0x3d, 0xf6, 0x65, 0x45, 0x6c, 0x25, 0x63, 0x82, 0xf8, 0xcf, 0x3c,
+	0xa0, 0x15, 0xee, 0xed, 0x40, 0x51, 0x48, 0x54, 0x39, 0xb5, 0x49, 0xa2, 0x83, 0x34, 0xea, 0x7a,
+	0x0e, 0x0d, 0x3d, 0x2a, 0xa4, 0xc3, 0xb1, 0x51, 0x0d, 0x92, 0x3c, 0x2e, 0x15, 0x29, 0x63, 0x35,
+	0x12, 0x1d, 0x50, 0x1b, 0x2a, 0x93, 0xeb, 0x31, 0x6f, 0x16, 0x54, 0xa0, 0x75, 0x15, 0xa8, 0x36,
+	0xfe, 0x44, 0x17, 0x42, 0x9f, 0xc0, 0xc6, 0x88, 0x3a, 0x42, 0x7a, 0xd4, 0x2c, 0x2a, 0xc2, 0x7d,
+	0x45, 0x58, 0x5d, 0xde, 0x6e, 0x8e, 0xa4, 0xe2, 0xa8, 0x0b, 0xb5, 0x61, 0x92, 0x7a, 0xc5, 0x2f,
+	0x29, 0xfe, 0xbe, 0xe2, 0xdf, 0x50, 0x94, 0x6e, 0x8e, 0x98, 0x44, 0xf4, 0x7e, 0xdc, 0xe6, 0x8c,
+	0x37, 0xcb, 0xaa, 0xff, 0x50, 0xdc, 0x03, 0xda, 0x2c, 0x90, 0x44, 0xa4, 0x53, 0x82, 0xc2, 0x8c,
+	0x09, 0x8a, 0xff, 0xb2, 0xe0, 0x5e, 0x56, 0x3b, 0x61, 0x6f, 0xe7, 0x8c, 0x8b, 0x5b, 0x4e, 0x2d,
+	0x86, 0x2a, 0x17, 0x34, 0x4c, 0x1b, 0xa1, 0xa0, 0x88, 0x06, 0x26, 0x37, 0x07, 0xf3, 0x86, 0x89,
+	0x44, 0x51, 0x49, 0x68, 0x08, 0x7a, 0x17, 0x36, 0x39, 0xe3, 0x32, 0xe8, 0x93, 0x23, 0x95, 0x2b,
+	0x9b, 0x5c, 0x03, 0x68, 0x17, 0x4a, 0x9e, 0x3f, 0x94, 0x3b, 0xa7, 0xac, 0x1c, 0x8e, 0x4f, 0xf8,
+	0x17, 0x1b, 0xee, 0x69, 0x83, 0x48, 0xe8, 0xf7, 0xff, 0x5f, 0x94, 0xda, 0xba, 0x29, 0x98, 0xeb,
+	0xe6, 0x49, 0x66, 0xb5, 0x14, 0xd7, 0xae, 0x96, 0x6e, 0x2e, 0xb3, 0x5c, 0x9e, 0x65, 0xc7, 0xb0,
+	0xb4, 0x7e, 0x0c, 0xbb, 0xb9, 0xec, 0x20, 0xea, 0x7b, 0xb3, 0x6c, 0xee, 0x4d, 0x33, 0xb1, 0x1b,
+	0xeb, 0x13, 0xbb, 0xa9, 0x27, 0x56, 0xdd, 0x82, 0xfe, 0x2c, 0x08, 0x23, 0xc1, 0x97, 0x6c, 0xc1,
+	0xa6, 0x4d, 0x50, 0x51, 0x67, 0xf0, 0xce, 0x26, 0x94, 0x17, 0x91, 0x23, 0xf8, 0x4b, 0x40, 0xd9,
+	0x72, 0xc8, 0x7c, 0x3a, 0xe3, 0xb9, 0x17, 0xad, 0xbb, 0x2a, 0x89, 0x0e, 0xb2, 0x23, 0x12, 0x55,
+	0x6c, 0xa8, 0xca, 0xb0, 0x41, 0x34, 0x04, 0x4f, 0x60, 0xbb, 0x2f, 0x3b, 0xa8, 0x1f, 0xd9, 0xba,
+	0xb9, 0xa8, 0x69, 0xc9, 0xf2, 0x7a, 0xc9, 0xea, 0x60, 0x0b, 0x31, 0x55, 0x65, 0xb4, 0x89, 0xfc,
+	0xa9, 0xc5, 0x5b, 0x30, 0x1a, 0xe9, 0x31, 0x94, 0x63, 0x3b, 0x68, 0x0b, 0xf2, 0xe9, 0xdd, 0x96,
+	0x37, 0x52, 0x94, 0x37, 0x28, 0x1f, 0xc2, 0x3b, 0xa7, 0x8c, 0x05, 0xcf, 0xa7, 0xee, 0x82, 0x11,
+	0xc6, 0x03, 0xdf, 0xe3, 0x2c, 0x43, 0x8e, 0x3d, 0xc8, 0xa7, 0x1e, 0xe0, 0x21, 0xd4, 0x3b, 0xcc,
+	0x73, 0xc6, 0x72, 0xd5, 0x27, 0x31, 0x21, 0x28, 0x8c, 0xdc, 0x34, 0x24, 0xf5, 0x5b, 0xd6, 0x4d,
+	0xe5, 0xa9, 0x9f, 0x5c, 0x64, 0x45, 0x72, 0x0d, 0xc8, 0xe4, 0x0d, 0xe6, 0xa3, 0x11, 0x0b, 0xb5,
+	0xad, 0xaa, 0x21, 0xf8, 0x10, 0x50, 0x97, 0xd1, 0xa9, 0x18, 0xab, 0xeb, 0x20, 0xb1, 0xd3, 0x84,
+	0x32, 0x67, 0xe1, 0xc2, 0x75, 0x12, 0x53, 0xc9, 0x11, 0xff, 0x6a, 0xc1, 0xb6, 0x41, 0x88, 0xe3,
+	0x79, 0x0a, 0x25, 0x2e, 0xa8, 0x98, 0x73, 0x45, 0xd8, 0x6a, 0x3f, 0x50, 0x2d, 0xb9, 0x42, 0xf2,
+	0xb0, 0x2f, 0x35, 0x79, 0x57, 0x7d, 0x25, 0x4d, 0x62, 0x16, 0xfe, 0x14, 0x6a, 0xc6, 0x07, 0x54,
+	0x81, 0xf2, 0xab, 0xde, 0x69, 0xef, 0xec, 0x75, 0xaf, 0x9e, 0x93, 0x87, 0xfe, 0x17, 0xe4, 0xf2,
+	0xa4, 0xf7, 0xa2, 0x6e, 0xa1, 0x3b, 0x50, 0xe9, 0x9d, 0x5d, 0xbc, 0x49, 0x80, 0x3c, 0xfe, 0xc3,
+	0x82, 0x2d, 0xc2, 0x86, 0xfe, 0x4b, 0xff, 0xea, 0xbf, 0x14, 0xdf, 0x7c, 0xab, 0xd8, 0xff, 0xe2,
+	0xad, 0x52, 0x58, 0xf5, 0x56, 0x31, 0x07, 0xa8, 0xb8, 0x7e, 0x80, 0x4a, 0x46, 0x77, 0xfc, 0x04,
+	0x28, 0xf6, 0xfd, 0x55, 0xc0, 0x59, 0xbc, 0x07, 0x6f, 0xeb, 0xfd, 0x24, 0x77, 0xd8, 0xfc, 0x5a,
+	0xad, 0x0a, 0xae, 0x4a, 0x74, 0xa8, 0xfd, 0xb7, 0x0d, 0xd5, 0x73, 0xc6, 0x42, 0x39, 0x80, 0x3d,
+	0x7f, 0xc8, 0xd0, 0x13, 0x28, 0x45, 0x65, 0x43, 0x8d, 0x6c, 0x0d, 0x55, 0x76, 0x5b, 0xcd, 0x75,
+	0xc5, 0xc5, 0x39, 0xf4, 0x31, 0x54, 0xf5, 0x69, 0x44, 0x91, 0xec, 0x8a, 0x01, 0x6d, 0x55, 0xa3,
+	0x2f, 0x11, 0x88, 0x73, 0xe8, 0x23, 0xd8, 0x4c, 0xe7, 0x04, 0x19, 0x1f, 0x5b, 0xbb, 0xd1, 0xf5,
+	0xbb, 0x3c, 0x45, 0x38, 0x77, 0x60, 0x3d, 0xb2, 0xd0, 0x05, 0x34, 0x8e, 0x99, 0x70, 0xc6, 0x2b,
+	0x9e, 0x06, 0x7b, 0x8a, 0xb8, 0xf6, 0x7e, 0x6b, 0x35, 0xd6, 0x7c, 0xc7, 0x39, 0x74, 0x19, 0x6b,
+	0x5d, 0xb1, 0xa7, 0xf6, 0x96, 0x1f, 0x76, 0xe6, 0x7d, 0xd2, 0x6a, 0xac, 0xf9, 0x8e, 0x73, 0x8f,
+	0x2c, 0x74, 0x0a, 0x77, 0xd3, 0xb9, 0x96, 0x95, 0xba, 0x08, 0xa9, 0xc7, 0x47, 0x2c, 0x44, 0x77,
+	0xa3, 0xeb, 0x7a, 0x69, 0xe6, 0x6f, 0x56, 0xf6, 0x0c, 0xaa, 0xca, 0xc9, 0xb8, 0x85, 0x50, 0x74,
+	0x93, 0x98, 0xc3, 0xd0, 0x6a, 0xe8, 0xa0, 0xd6, 0x65, 0x52, 0xc3, 0xa0, 0xa4, 0xfe, 0xe1, 0x7c,
+	0xf0, 0xcf, 0x00, 0x71, 0x04, 0xec, 0x1a, 0xf9, 0x0c, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -1232,6 +1390,8 @@ type PeerDataNodeClient interface {
 	FetchVectorPartyRawData(ctx context.Context, in *VectorPartyRawDataRequest, opts ...grpc.CallOption) (PeerDataNode_FetchVectorPartyRawDataClient, error)
 	// benchmark function to test performance using different config for file transfer
 	BenchmarkFileTransfer(ctx context.Context, in *BenchmarkRequest, opts ...grpc.CallOption) (PeerDataNode_BenchmarkFileTransferClient, error)
+	// FetchRedoLog fetches upsert batches from redolog files starting from given redolog file id and offset
+	FetchRedoLog(ctx context.Context, in *RedoLogRequest, opts ...grpc.CallOption) (PeerDataNode_FetchRedoLogClient, error)
 }
 
 type peerDataNodeClient struct {
@@ -1364,6 +1524,38 @@ func (x *peerDataNodeBenchmarkFileTransferClient) Recv() (*VectorPartyRawData, e
 	return m, nil
 }
 
+func (c *peerDataNodeClient) FetchRedoLog(ctx context.Context, in *RedoLogRequest, opts ...grpc.CallOption) (PeerDataNode_FetchRedoLogClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_PeerDataNode_serviceDesc.Streams[3], "/rpc.PeerDataNode/FetchRedoLog", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &peerDataNodeFetchRedoLogClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type PeerDataNode_FetchRedoLogClient interface {
+	Recv() (*RedoLogUpsertBatch, error)
+	grpc.ClientStream
+}
+
+type peerDataNodeFetchRedoLogClient struct {
+	grpc.ClientStream
+}
+
+func (x *peerDataNodeFetchRedoLogClient) Recv() (*RedoLogUpsertBatch, error) {
+	m := new(RedoLogUpsertBatch)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // PeerDataNodeServer is the server API for PeerDataNode service.
 type PeerDataNodeServer interface {
 	Health(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error)
@@ -1378,6 +1570,8 @@ type PeerDataNodeServer interface {
 	FetchVectorPartyRawData(*VectorPartyRawDataRequest, PeerDataNode_FetchVectorPartyRawDataServer) error
 	// benchmark function to test performance using different config for file transfer
 	BenchmarkFileTransfer(*BenchmarkRequest, PeerDataNode_BenchmarkFileTransferServer) error
+	// FetchRedoLog fetches upsert batches from redolog files starting from given redolog file id and offset
+	FetchRedoLog(*RedoLogRequest, PeerDataNode_FetchRedoLogServer) error
 }
 
 func RegisterPeerDataNodeServer(s *grpc.Server, srv PeerDataNodeServer) {
@@ -1506,6 +1700,27 @@ func (x *peerDataNodeBenchmarkFileTransferServer) Send(m *VectorPartyRawData) er
 	return x.ServerStream.SendMsg(m)
 }
 
+func _PeerDataNode_FetchRedoLog_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RedoLogRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PeerDataNodeServer).FetchRedoLog(m, &peerDataNodeFetchRedoLogServer{stream})
+}
+
+type PeerDataNode_FetchRedoLogServer interface {
+	Send(*RedoLogUpsertBatch) error
+	grpc.ServerStream
+}
+
+type peerDataNodeFetchRedoLogServer struct {
+	grpc.ServerStream
+}
+
+func (x *peerDataNodeFetchRedoLogServer) Send(m *RedoLogUpsertBatch) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _PeerDataNode_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "rpc.PeerDataNode",
 	HandlerType: (*PeerDataNodeServer)(nil),
@@ -1540,6 +1755,11 @@ var _PeerDataNode_serviceDesc = grpc.ServiceDesc{
 			Handler:       _PeerDataNode_BenchmarkFileTransfer_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "FetchRedoLog",
+			Handler:       _PeerDataNode_FetchRedoLog_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "peer_streaming.proto",
 }
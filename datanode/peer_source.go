@@ -10,6 +10,7 @@ import (
 	"github.com/uber/aresdb/datanode/generated/proto/rpc"
 	"github.com/uber/aresdb/utils"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"net/url"
 	"sync"
 )
@@ -27,6 +28,15 @@ var grpcDialer = func(target string, opts ...grpc.DialOption) (client rpc.PeerDa
 	return rpc.NewPeerDataNodeClient(conn), func() error { return conn.Close() }, nil
 }
 
+// peerTransportOption returns the transport credentials to dial peers with,
+// tls config is created per dial so that reloaded certificates are used.
+func peerTransportOption() grpc.DialOption {
+	if tlsConfig := utils.ClientTLSConfig(); tlsConfig != nil {
+		return grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	}
+	return grpc.WithInsecure()
+}
+
 type peer struct {
 	sync.RWMutex
 	sync.WaitGroup
@@ -59,7 +69,7 @@ func (p *peer) BorrowConnection(fn client.WithConnectionFn) (err error) {
 			return err
 		}
 
-		p.conn, p.closeFn, err = p.dialer(fmt.Sprintf("%s:%s", parsedURL.Hostname(), parsedURL.Port()), grpc.WithInsecure())
+		p.conn, p.closeFn, err = p.dialer(fmt.Sprintf("%s:%s", parsedURL.Hostname(), parsedURL.Port()), peerTransportOption())
 		if err != nil {
 			p.Unlock()
 			return err
@@ -0,0 +1,149 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datanode
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/m3db/m3/src/cluster/services"
+	"github.com/uber/aresdb/cluster/shard"
+	"github.com/uber/aresdb/cluster/topology"
+	"github.com/uber/aresdb/controller/models"
+	"github.com/uber/aresdb/utils"
+)
+
+// default time the primary is not heartbeating before being taken over by the standby.
+const defaultStandbyFailoverTimeout = 10 * time.Second
+
+// isStandby tells whether the datanode is running as warm standby and not yet promoted.
+func (d *dataNode) isStandby() bool {
+	return atomic.LoadInt32(&d.standby) == 1
+}
+
+// lookupShardSet returns the shard set to load from the topology map. Warm standby loads the
+// shards owned by its primary until the standby itself appears in the placement, which promotes it.
+func (d *dataNode) lookupShardSet(topoMap topology.Map) (shard.ShardSet, bool) {
+	hostShardSet, ok := topoMap.LookupHostShardSet(d.hostID)
+	if ok {
+		if d.isStandby() {
+			go d.promote()
+		}
+		return hostShardSet.ShardSet(), true
+	}
+	if d.isStandby() {
+		hostShardSet, ok = topoMap.LookupHostShardSet(d.opts.ServerConfig().Cluster.Standby.Primary)
+		if ok {
+			return hostShardSet.ShardSet(), true
+		}
+	}
+	return nil, false
+}
+
+// promote starts ingestion of table shards pre-loaded by the standby.
+func (d *dataNode) promote() {
+	if !atomic.CompareAndSwapInt32(&d.standby, 1, 0) {
+		return
+	}
+	d.logger.With("primary", d.opts.ServerConfig().Cluster.Standby.Primary).Info("promoting standby datanode")
+	d.memStore.PromoteStandbyTableShards()
+	d.logger.Info("standby datanode promoted")
+}
+
+// startStandbyFailoverWatch replaces the primary with the standby in the placement once the
+// primary has not been heartbeating for the failover timeout.
+func (d *dataNode) startStandbyFailoverWatch() {
+	clusterCfg := d.opts.ServerConfig().Cluster
+	failoverTimeout := time.Duration(clusterCfg.Standby.FailoverSec) * time.Second
+	if failoverTimeout <= 0 {
+		failoverTimeout = defaultStandbyFailoverTimeout
+	}
+
+	lastHeartbeat := utils.Now()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for d.isStandby() {
+		select {
+		case <-ticker.C:
+		case <-d.close:
+			return
+		}
+
+		alive, err := d.isPrimaryAlive()
+		if err != nil {
+			d.logger.With("error", err.Error()).Warn("failed to check heartbeat of primary")
+			continue
+		}
+		if alive {
+			lastHeartbeat = utils.Now()
+			continue
+		}
+		if utils.Now().Sub(lastHeartbeat) < failoverTimeout || !d.isStandby() {
+			continue
+		}
+
+		if !d.bootstrapManager.IsBootstrapped() {
+			d.logger.Warn("taking over primary before standby finishes loading data")
+		}
+		if _, err = d.placementMutator.PromoteStandby(clusterCfg.Namespace, clusterCfg.Standby.Primary,
+			d.standbyInstance()); err != nil {
+			d.logger.With("error", err.Error(), "primary", clusterCfg.Standby.Primary).Error("failed to take over primary")
+			continue
+		}
+		d.promote()
+	}
+}
+
+// isPrimaryAlive tells whether the primary is heartbeating.
+func (d *dataNode) isPrimaryAlive() (bool, error) {
+	clusterCfg := d.opts.ServerConfig().Cluster
+	serviceID := services.NewServiceID().
+		SetEnvironment(clusterCfg.Etcd.Env).
+		SetZone(clusterCfg.Etcd.Zone).
+		SetName(utils.DataNodeServiceName(clusterCfg.Namespace))
+	heartbeatService, err := d.clusterServices.HeartbeatService(serviceID)
+	if err != nil {
+		return false, err
+	}
+	ids, err := heartbeatService.Get()
+	if err != nil {
+		return false, err
+	}
+	for _, id := range ids {
+		if id == clusterCfg.Standby.Primary {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// standbyInstance returns the placement instance of the standby.
+func (d *dataNode) standbyInstance() models.Instance {
+	cfg := d.opts.ServerConfig()
+	hostname, err := os.Hostname()
+	if err != nil {
+		d.logger.With("error", err.Error()).Error("failed to get host name")
+	}
+	return models.Instance{
+		Name:    d.hostID,
+		Host:    hostname,
+		Port:    uint32(cfg.Port),
+		Address: fmt.Sprintf("%s:%d", hostname, cfg.Port),
+		Zone:    cfg.Cluster.Locality.Zone,
+		Rack:    cfg.Cluster.Locality.Rack,
+	}
+}
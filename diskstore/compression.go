@@ -0,0 +1,267 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/DataDog/zstd"
+	"github.com/uber/aresdb/common"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	// DefaultCompressionLevel is the zstd level used when no level is configured.
+	DefaultCompressionLevel = 3
+	// MaxCompressionLevel is the highest zstd compression level.
+	MaxCompressionLevel = 22
+	// size of uncompressed data of each block in compressed files.
+	compressionBlockSize = 256 * 1024
+	// block header is a flag byte followed by the uint32 size of the compressed block.
+	compressionBlockHeaderSize = 5
+	compressionFinalBlockFlag  = 1
+)
+
+// maxCompressedBlockSize is the max size of a compressed block, which is the worst case size of
+// compressing a full block.
+var maxCompressedBlockSize = zstd.CompressBound(compressionBlockSize)
+
+// compressionMagic is the header of compressed vector party files, it's followed by
+// blocks compressed independently with zstd.
+var compressionMagic = []byte("ARESZST1")
+
+// ColumnCompressionLevel returns the zstd level to compress vector party files of a column
+// with, 0 for the default level of the disk store and negative for uncompressed.
+type ColumnCompressionLevel func(table string, columnID int) int
+
+// WithCompression wraps the disk store to compress vector party files with zstd. If
+// compression is disabled, only files of columns with a positive level are compressed.
+func WithCompression(diskStore DiskStore, cfg common.CompressionConfig, columnLevel ColumnCompressionLevel) DiskStore {
+	if !cfg.Enable {
+		return NewCompressedDiskStore(diskStore, -1, columnLevel)
+	}
+	level := cfg.Level
+	if level <= 0 {
+		level = DefaultCompressionLevel
+	}
+	return NewCompressedDiskStore(diskStore, level, columnLevel)
+}
+
+// compressedDiskStore compresses archived and snapshot vector party files block by block
+// and decompresses them transparently on read, so that vector parties are loaded into
+// memstore uncompressed.
+type compressedDiskStore struct {
+	DiskStore
+	defaultLevel int
+	columnLevel  ColumnCompressionLevel
+}
+
+// NewCompressedDiskStore creates a DiskStore compressing vector party files of columns at
+// the level returned by columnLevel, or defaultLevel for columns without a level.
+func NewCompressedDiskStore(diskStore DiskStore, defaultLevel int, columnLevel ColumnCompressionLevel) DiskStore {
+	return &compressedDiskStore{
+		DiskStore:    diskStore,
+		defaultLevel: defaultLevel,
+		columnLevel:  columnLevel,
+	}
+}
+
+func (s *compressedDiskStore) unwrap() DiskStore {
+	return s.DiskStore
+}
+
+func (s *compressedDiskStore) OpenSnapshotVectorPartyFileForRead(table string, shard int,
+	redoLogFile int64, offset uint32, batchID int, columnID int) (io.ReadCloser, error) {
+	reader, err := s.DiskStore.OpenSnapshotVectorPartyFileForRead(table, shard, redoLogFile, offset, batchID, columnID)
+	if err != nil {
+		return nil, err
+	}
+	return newDecompressingReader(reader)
+}
+
+func (s *compressedDiskStore) OpenSnapshotVectorPartyFileForWrite(table string, shard int,
+	redoLogFile int64, offset uint32, batchID int, columnID int) (io.WriteCloser, error) {
+	writer, err := s.DiskStore.OpenSnapshotVectorPartyFileForWrite(table, shard, redoLogFile, offset, batchID, columnID)
+	if err != nil {
+		return writer, err
+	}
+	return s.newWriter(writer, table, columnID)
+}
+
+func (s *compressedDiskStore) OpenVectorPartyFileForRead(table string, columnID, shard, batchID int, batchVersion uint32,
+	seqNum uint32) (io.ReadCloser, error) {
+	reader, err := s.DiskStore.OpenVectorPartyFileForRead(table, columnID, shard, batchID, batchVersion, seqNum)
+	if err != nil || reader == nil {
+		return reader, err
+	}
+	return newDecompressingReader(reader)
+}
+
+func (s *compressedDiskStore) OpenVectorPartyFileForWrite(table string, columnID, shard, batchID int, batchVersion uint32,
+	seqNum uint32) (io.WriteCloser, error) {
+	writer, err := s.DiskStore.OpenVectorPartyFileForWrite(table, columnID, shard, batchID, batchVersion, seqNum)
+	if err != nil {
+		return writer, err
+	}
+	return s.newWriter(writer, table, columnID)
+}
+
+// newWriter writes the file header if the column should be compressed.
+func (s *compressedDiskStore) newWriter(writer io.WriteCloser, table string, columnID int) (io.WriteCloser, error) {
+	level := s.columnLevel(table, columnID)
+	if level == 0 {
+		level = s.defaultLevel
+	}
+	if level <= 0 {
+		return writer, nil
+	}
+	if _, err := writer.Write(compressionMagic); err != nil {
+		writer.Close()
+		return nil, utils.StackError(err, "failed to create compressed vector party file")
+	}
+	return &compressingWriter{
+		writer: writer,
+		level:  level,
+		buffer: make([]byte, 0, compressionBlockSize),
+	}, nil
+}
+
+// newDecompressingReader decompresses the file if it's compressed, other files are read as is.
+func newDecompressingReader(reader io.ReadCloser) (io.ReadCloser, error) {
+	bufferedReader := bufio.NewReader(reader)
+	magic, err := bufferedReader.Peek(len(compressionMagic))
+	if err != nil || !bytes.Equal(magic, compressionMagic) {
+		return &bufferedReadCloser{Reader: bufferedReader, closer: reader}, nil
+	}
+	if _, err = bufferedReader.Discard(len(compressionMagic)); err != nil {
+		reader.Close()
+		return nil, utils.StackError(err, "failed to open compressed vector party file")
+	}
+	return &decompressingReader{reader: bufferedReader, closer: reader}, nil
+}
+
+// compressingWriter compresses data in blocks, the last block is flagged so that
+// truncated files are detected on read.
+type compressingWriter struct {
+	writer io.WriteCloser
+	level  int
+	buffer []byte
+	// compressed block of last flush, reused across blocks
+	block []byte
+}
+
+func (w *compressingWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		size := compressionBlockSize - len(w.buffer)
+		if size > len(p) {
+			size = len(p)
+		}
+		w.buffer = append(w.buffer, p[:size]...)
+		p = p[size:]
+		n += size
+		if len(w.buffer) == compressionBlockSize {
+			if err = w.flush(0); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (w *compressingWriter) flush(flag byte) error {
+	bound := compressionBlockHeaderSize + zstd.CompressBound(len(w.buffer))
+	if cap(w.block) < bound {
+		w.block = make([]byte, bound)
+	}
+	compressed, err := zstd.CompressLevel(w.block[compressionBlockHeaderSize:bound], w.buffer, w.level)
+	if err != nil {
+		return utils.StackError(err, "failed to compress vector party file")
+	}
+	block := w.block[:compressionBlockHeaderSize+len(compressed)]
+	block[0] = flag
+	binary.LittleEndian.PutUint32(block[1:], uint32(len(compressed)))
+	if _, err = w.writer.Write(block); err != nil {
+		return err
+	}
+	w.buffer = w.buffer[:0]
+	return nil
+}
+
+// Close writes the final block and closes the file.
+func (w *compressingWriter) Close() error {
+	err := w.flush(compressionFinalBlockFlag)
+	if closeErr := w.writer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// decompressingReader decompresses blocks one by one.
+type decompressingReader struct {
+	reader io.Reader
+	closer io.Closer
+	// decompressed data of current block not read yet
+	buffer []byte
+	final  bool
+}
+
+func (r *decompressingReader) Read(p []byte) (int, error) {
+	for len(r.buffer) == 0 {
+		if r.final {
+			return 0, io.EOF
+		}
+		if err := r.readBlock(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.buffer)
+	r.buffer = r.buffer[n:]
+	return n, nil
+}
+
+func (r *decompressingReader) readBlock() error {
+	header := make([]byte, compressionBlockHeaderSize)
+	if _, err := io.ReadFull(r.reader, header); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return utils.StackError(err, "compressed vector party file is truncated")
+	}
+	// the size is read from the file, check it before allocating so that corrupted files
+	// can not make us allocate up to 4GB.
+	size := binary.LittleEndian.Uint32(header[1:])
+	if size > uint32(maxCompressedBlockSize) {
+		return utils.StackError(nil, "compressed block size %d exceeds max %d, vector party file is corrupted",
+			size, maxCompressedBlockSize)
+	}
+	compressed := make([]byte, size)
+	if _, err := io.ReadFull(r.reader, compressed); err != nil {
+		return utils.StackError(err, "compressed vector party file is truncated")
+	}
+	decompressed, err := zstd.Decompress(nil, compressed)
+	if err != nil || len(decompressed) > compressionBlockSize {
+		return utils.StackError(err, "failed to decompress vector party file")
+	}
+	r.buffer = decompressed
+	r.final = header[0] == compressionFinalBlockFlag
+	return nil
+}
+
+func (r *decompressingReader) Close() error {
+	return r.closer.Close()
+}
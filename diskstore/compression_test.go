@@ -0,0 +1,173 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/uber/aresdb/common"
+)
+
+var _ = ginkgo.Describe("compressed disk store", func() {
+	prefix := "/tmp/testCompressedDiskStore"
+	table := "myTable"
+	// larger than a block to test multiple blocks.
+	data := make([]byte, compressionBlockSize*2+100)
+	for i := range data {
+		data[i] = byte(i / 1000)
+	}
+
+	var diskStore DiskStore
+	// column 0 uses default level, column 1 its own level and column 2 is uncompressed.
+	columnLevel := func(table string, columnID int) int {
+		return []int{0, 19, -1}[columnID]
+	}
+
+	writeFile := func(store DiskStore, columnID int, content []byte) {
+		writer, err := store.OpenVectorPartyFileForWrite(table, columnID, 0, 100, 1, 0)
+		Ω(err).Should(BeNil())
+		_, err = writer.Write(content)
+		Ω(err).Should(BeNil())
+		Ω(writer.Close()).Should(BeNil())
+	}
+
+	readFile := func(store DiskStore, columnID int) ([]byte, error) {
+		reader, err := store.OpenVectorPartyFileForRead(table, columnID, 0, 100, 1, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	}
+
+	filePath := func(columnID int) string {
+		return GetPathForTableArchiveBatchColumnFile(prefix, table, 0, daysSinceEpochToTimeStr(100), 1, 0, columnID)
+	}
+
+	ginkgo.BeforeEach(func() {
+		os.RemoveAll(prefix)
+		os.MkdirAll(prefix, 0755)
+		diskStore = NewCompressedDiskStore(NewLocalDiskStore(prefix), DefaultCompressionLevel, columnLevel)
+	})
+
+	ginkgo.AfterEach(func() {
+		os.RemoveAll(prefix)
+	})
+
+	ginkgo.It("compresses and decompresses columns", func() {
+		for _, columnID := range []int{0, 1} {
+			writeFile(diskStore, columnID, data)
+			raw, err := ioutil.ReadFile(filePath(columnID))
+			Ω(err).Should(BeNil())
+			Ω(bytes.HasPrefix(raw, compressionMagic)).Should(BeTrue())
+			Ω(len(raw)).Should(BeNumerically("<", len(data)/10))
+
+			content, err := readFile(diskStore, columnID)
+			Ω(err).Should(BeNil())
+			Ω(content).Should(Equal(data))
+		}
+
+		writeFile(diskStore, 1, []byte{})
+		content, err := readFile(diskStore, 1)
+		Ω(err).Should(BeNil())
+		Ω(content).Should(BeEmpty())
+	})
+
+	ginkgo.It("keeps uncompressed columns and existing files as is", func() {
+		writeFile(diskStore, 2, data)
+		raw, err := ioutil.ReadFile(filePath(2))
+		Ω(err).Should(BeNil())
+		Ω(raw).Should(Equal(data))
+
+		writeFile(NewLocalDiskStore(prefix), 1, data[:10])
+		content, err := readFile(diskStore, 1)
+		Ω(err).Should(BeNil())
+		Ω(content).Should(Equal(data[:10]))
+
+		_, err = readFile(diskStore, 0)
+		Ω(os.IsNotExist(err)).Should(BeTrue())
+	})
+
+	ginkgo.It("compresses snapshot files", func() {
+		writer, err := diskStore.OpenSnapshotVectorPartyFileForWrite(table, 0, 1, 0, -1, 1)
+		Ω(err).Should(BeNil())
+		_, err = writer.Write(data)
+		Ω(err).Should(BeNil())
+		Ω(writer.Close()).Should(BeNil())
+
+		reader, err := diskStore.OpenSnapshotVectorPartyFileForRead(table, 0, 1, 0, -1, 1)
+		Ω(err).Should(BeNil())
+		content, err := ioutil.ReadAll(reader)
+		Ω(err).Should(BeNil())
+		Ω(reader.Close()).Should(BeNil())
+		Ω(content).Should(Equal(data))
+	})
+
+	ginkgo.It("detects corrupted and truncated files", func() {
+		writeFile(diskStore, 1, data)
+		raw, err := ioutil.ReadFile(filePath(1))
+		Ω(err).Should(BeNil())
+
+		corrupted := append([]byte{}, raw...)
+		corrupted[len(compressionMagic)+compressionBlockHeaderSize] ^= 0xff
+		Ω(ioutil.WriteFile(filePath(1), corrupted, 0644)).Should(BeNil())
+		_, err = readFile(diskStore, 1)
+		Ω(err).ShouldNot(BeNil())
+
+		oversized := append([]byte{}, raw...)
+		binary.LittleEndian.PutUint32(oversized[len(compressionMagic)+1:], 0xffffffff)
+		Ω(ioutil.WriteFile(filePath(1), oversized, 0644)).Should(BeNil())
+		_, err = readFile(diskStore, 1)
+		Ω(err).ShouldNot(BeNil())
+		Ω(err.Error()).Should(ContainSubstring("exceeds max"))
+
+		truncated := raw[:len(raw)-1]
+		Ω(ioutil.WriteFile(filePath(1), truncated, 0644)).Should(BeNil())
+		_, err = readFile(diskStore, 1)
+		Ω(err).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("composes with encryption", func() {
+		kms, err := NewLocalKMS(bytes.Repeat([]byte{7}, encryptionKeySize))
+		Ω(err).Should(BeNil())
+		store := NewCompressedDiskStore(NewEncryptedDiskStore(NewLocalDiskStore(prefix), kms,
+			func(table string, columnID int) bool { return true }), DefaultCompressionLevel, columnLevel)
+		writeFile(store, 0, data)
+		raw, err := ioutil.ReadFile(filePath(0))
+		Ω(err).Should(BeNil())
+		Ω(bytes.HasPrefix(raw, encryptionMagic)).Should(BeTrue())
+		Ω(len(raw)).Should(BeNumerically("<", len(data)/10))
+
+		content, err := readFile(store, 0)
+		Ω(err).Should(BeNil())
+		Ω(content).Should(Equal(data))
+	})
+
+	ginkgo.It("creates compressed disk store from config", func() {
+		localDiskStore := NewLocalDiskStore(prefix)
+		store := WithCompression(localDiskStore, common.CompressionConfig{}, columnLevel)
+		Ω(store.(*compressedDiskStore).defaultLevel).Should(Equal(-1))
+		store = WithCompression(localDiskStore, common.CompressionConfig{Enable: true}, columnLevel)
+		Ω(store.(*compressedDiskStore).defaultLevel).Should(Equal(DefaultCompressionLevel))
+		store = WithCompression(localDiskStore, common.CompressionConfig{Enable: true, Level: 9}, columnLevel)
+		Ω(store.(*compressedDiskStore).defaultLevel).Should(Equal(9))
+	})
+})
@@ -39,6 +39,9 @@ type DiskStore interface {
 	DeleteLogFile(table string, shard int, creationTime int64) error
 	// Truncate Redolog to drop the last incomplete/corrupted upsert batch.
 	TruncateLogFile(table string, shard int, creationTime int64, offset int64) error
+	// Replaces the specified log file with the content written by rewrite, the log file is
+	// left unchanged if rewrite fails.
+	RewriteLogFile(table string, shard int, creationTime int64, rewrite func(io.Writer) error) error
 
 	// Snapshot files.
 	// Snapshots are stored in following format:
@@ -89,11 +92,47 @@ type DiskStore interface {
 	// Creates/truncates the vector party file at the specified batchVersion for write.
 	OpenVectorPartyFileForWrite(table string, column, shard, batchID int, batchVersion uint32,
 		seqNum uint32) (io.WriteCloser, error)
+	// Opens the index file of the column at the specified batchVersion for read, os.ErrNotExist is returned
+	// if the batch version has no index of the column. Enum columns have bitmap indexes and vector columns
+	// have IVF indexes.
+	OpenBatchIndexFileForRead(table string, column, shard, batchID int, batchVersion uint32,
+		seqNum uint32) (io.ReadCloser, error)
+	// Creates/truncates the index file of the column at the specified batchVersion for write.
+	OpenBatchIndexFileForWrite(table string, column, shard, batchID int, batchVersion uint32,
+		seqNum uint32) (io.WriteCloser, error)
+	// Opens the zone map file of the specified batchVersion for read, os.ErrNotExist is returned if the batch
+	// version has no zone maps.
+	OpenBatchZoneMapFileForRead(table string, shard, batchID int, batchVersion uint32,
+		seqNum uint32) (io.ReadCloser, error)
+	// Creates/truncates the zone map file of the specified batchVersion for write.
+	OpenBatchZoneMapFileForWrite(table string, shard, batchID int, batchVersion uint32,
+		seqNum uint32) (io.WriteCloser, error)
 	// Deletes all old batches with the specified batchID that have version lower than or equal to the specified batch
 	// version. All columns of those batches will be deleted.
 	DeleteBatchVersions(table string, shard, batchID int, batchVersion uint32, seqNum uint32) error
+	// Deletes the batch with the specified batchID at exactly the specified batch version, e.g. a batch
+	// version that failed to be committed to metastore.
+	DeleteBatchVersion(table string, shard, batchID int, batchVersion uint32, seqNum uint32) error
 	// Deletes all batches within range [batchIDStart, batchIDEnd)
 	DeleteBatches(table string, shard, batchIDStart, batchIDEnd int) (int, error)
 	// Deletes all batches of the specified column.
 	DeleteColumn(table string, column, shard int) error
 }
+
+// wrappingDiskStore is implemented by disk stores transforming files of the disk store they
+// wrap, e.g. encryption and compression.
+type wrappingDiskStore interface {
+	unwrap() DiskStore
+}
+
+// Raw returns the innermost disk store under encryption and compression wrappers, so that files
+// are read and written as stored on disk, e.g. encrypted files of backups stay encrypted.
+func Raw(diskStore DiskStore) DiskStore {
+	for {
+		wrapping, ok := diskStore.(wrappingDiskStore)
+		if !ok {
+			return diskStore
+		}
+		diskStore = wrapping.unwrap()
+	}
+}
@@ -27,6 +27,8 @@ const data string = "data"
 const redologs string = "redologs"
 const snapshots string = "snapshots"
 const archiveBatches string = "archiving_batches"
+const batchIndexFileSuffix string = ".idx"
+const batchZoneMapFile string = "zonemaps"
 
 // Utils for data hierarchy layout.
 // Following this wiki:
@@ -103,6 +105,7 @@ func GetPathForTableSnapshotColumnFilePath(prefix, table string, shardID int, re
 // Path on disk:
 //   {root_path}/data/{table_name}_{shard_id}/archiving_batches/{batch_id}_{batch_version}
 //   {root_path}/data/{table_name}_{shard_id}/archiving_batches/{batch_id}_{batch_version}/{columnID}.data
+//   {root_path}/data/{table_name}_{shard_id}/archiving_batches/{batch_id}_{batch_version}/{columnID}.idx
 // Note:
 //   batch_id is UTC date
 // 	 batch_version is the cutoff seconds in unix time.
@@ -137,6 +140,18 @@ func GetPathForTableArchiveBatchColumnFile(prefix, table string, shardID int, ba
 	return filepath.Join(tableArchiveBatchDir, columnFileName)
 }
 
+// GetPathForTableArchiveBatchIndexFile is used to get the file path of the index of a column inside an archive batch version.
+func GetPathForTableArchiveBatchIndexFile(prefix, table string, shardID int, batchID string, batchVersion uint32, seqNum uint32, columnID int) string {
+	tableArchiveBatchDir := GetPathForTableArchiveBatchDir(prefix, table, shardID, batchID, batchVersion, seqNum)
+	return filepath.Join(tableArchiveBatchDir, fmt.Sprintf("%d%s", columnID, batchIndexFileSuffix))
+}
+
+// GetPathForTableArchiveBatchZoneMapFile is used to get the file path of zone maps of columns inside an archive batch version.
+func GetPathForTableArchiveBatchZoneMapFile(prefix, table string, shardID int, batchID string, batchVersion uint32, seqNum uint32) string {
+	tableArchiveBatchDir := GetPathForTableArchiveBatchDir(prefix, table, shardID, batchID, batchVersion, seqNum)
+	return filepath.Join(tableArchiveBatchDir, batchZoneMapFile)
+}
+
 // ParseBatchIDAndVersionName will parse a batchIDAndVersion into batchID and batchVersion+seqNum.
 func ParseBatchIDAndVersionName(batchIDAndVersion string) (string, uint32, uint32, error) {
 	var batchID string
@@ -0,0 +1,379 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package diskstore
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/uber/aresdb/common"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	// size of data keys and local master keys in bytes.
+	encryptionKeySize = 32
+	// size of plaintext of each sealed chunk in encrypted files.
+	encryptionChunkSize = 64 * 1024
+	// chunk header is a flag byte followed by the uint32 size of the sealed chunk.
+	encryptionChunkHeaderSize = 5
+	encryptionFinalChunkFlag  = 1
+)
+
+// encryptionMagic is the header of encrypted vector party files, it's followed by
+// the uint16 size of the wrapped data key, the wrapped data key and sealed chunks.
+var encryptionMagic = []byte("ARESENC1")
+
+// KMS wraps data keys of encrypted files with master keys it manages.
+type KMS interface {
+	// GenerateDataKey returns a new data key in plaintext and wrapped by the master key.
+	GenerateDataKey() (plaintext, wrapped []byte, err error)
+	// DecryptDataKey unwraps a data key returned by GenerateDataKey.
+	DecryptDataKey(wrapped []byte) (plaintext []byte, err error)
+}
+
+// ColumnEncryptionChecker tells whether vector party files of a column should be encrypted.
+type ColumnEncryptionChecker func(table string, columnID int) bool
+
+// NewKMS creates the KMS specified in config.
+func NewKMS(cfg common.EncryptionConfig) (KMS, error) {
+	switch cfg.KMS {
+	case common.KMSLocal, "":
+		bs, err := ioutil.ReadFile(cfg.MasterKeyFile)
+		if err != nil {
+			return nil, utils.StackError(err, "failed to read master key file %s", cfg.MasterKeyFile)
+		}
+		masterKey, err := hex.DecodeString(strings.TrimSpace(string(bs)))
+		if err != nil {
+			return nil, utils.StackError(err, "master key in %s is not hex encoded", cfg.MasterKeyFile)
+		}
+		return NewLocalKMS(masterKey)
+	default:
+		return nil, utils.StackError(nil, "unknown kms %s", cfg.KMS)
+	}
+}
+
+// localKMS wraps data keys with a master key held in memory.
+type localKMS struct {
+	aead cipher.AEAD
+}
+
+// NewLocalKMS creates a KMS wrapping data keys with the 256 bit master key.
+func NewLocalKMS(masterKey []byte) (KMS, error) {
+	if len(masterKey) != encryptionKeySize {
+		return nil, utils.StackError(nil, "master key should be %d bytes, got %d", encryptionKeySize, len(masterKey))
+	}
+	aead, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return &localKMS{aead: aead}, nil
+}
+
+func (k *localKMS) GenerateDataKey() (plaintext, wrapped []byte, err error) {
+	plaintext = make([]byte, encryptionKeySize)
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err = rand.Read(plaintext); err != nil {
+		return
+	}
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+	wrapped = k.aead.Seal(nonce, nonce, plaintext, nil)
+	return
+}
+
+func (k *localKMS) DecryptDataKey(wrapped []byte) ([]byte, error) {
+	nonceSize := k.aead.NonceSize()
+	if len(wrapped) < nonceSize {
+		return nil, utils.StackError(nil, "invalid wrapped data key")
+	}
+	plaintext, err := k.aead.Open(nil, wrapped[:nonceSize], wrapped[nonceSize:], nil)
+	if err != nil {
+		return nil, utils.StackError(err, "failed to unwrap data key")
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce derives the nonce of a chunk from its index, nonces never repeat as
+// every file has its own data key.
+func chunkNonce(aead cipher.AEAD, index uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], index)
+	return nonce
+}
+
+// WithEncryption wraps the disk store to encrypt vector party files of columns marked as
+// encrypted if encryption is enabled.
+func WithEncryption(diskStore DiskStore, cfg common.EncryptionConfig, encrypted ColumnEncryptionChecker) (DiskStore, error) {
+	if !cfg.Enable {
+		return diskStore, nil
+	}
+	kms, err := NewKMS(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewEncryptedDiskStore(diskStore, kms, encrypted), nil
+}
+
+// encryptedDiskStore encrypts archived and snapshot vector party files of encrypted columns
+// with envelope keys and decrypts them transparently on read.
+type encryptedDiskStore struct {
+	DiskStore
+	kms       KMS
+	encrypted ColumnEncryptionChecker
+}
+
+// NewEncryptedDiskStore creates a DiskStore encrypting vector party files of columns
+// checked by encrypted using data keys generated by kms.
+func NewEncryptedDiskStore(diskStore DiskStore, kms KMS, encrypted ColumnEncryptionChecker) DiskStore {
+	return &encryptedDiskStore{
+		DiskStore: diskStore,
+		kms:       kms,
+		encrypted: encrypted,
+	}
+}
+
+func (s *encryptedDiskStore) unwrap() DiskStore {
+	return s.DiskStore
+}
+
+func (s *encryptedDiskStore) OpenSnapshotVectorPartyFileForRead(table string, shard int,
+	redoLogFile int64, offset uint32, batchID int, columnID int) (io.ReadCloser, error) {
+	reader, err := s.DiskStore.OpenSnapshotVectorPartyFileForRead(table, shard, redoLogFile, offset, batchID, columnID)
+	if err != nil {
+		return nil, err
+	}
+	return s.newReader(reader)
+}
+
+func (s *encryptedDiskStore) OpenSnapshotVectorPartyFileForWrite(table string, shard int,
+	redoLogFile int64, offset uint32, batchID int, columnID int) (io.WriteCloser, error) {
+	writer, err := s.DiskStore.OpenSnapshotVectorPartyFileForWrite(table, shard, redoLogFile, offset, batchID, columnID)
+	if err != nil || !s.encrypted(table, columnID) {
+		return writer, err
+	}
+	return s.newWriter(writer)
+}
+
+func (s *encryptedDiskStore) OpenVectorPartyFileForRead(table string, columnID, shard, batchID int, batchVersion uint32,
+	seqNum uint32) (io.ReadCloser, error) {
+	reader, err := s.DiskStore.OpenVectorPartyFileForRead(table, columnID, shard, batchID, batchVersion, seqNum)
+	if err != nil || reader == nil {
+		return reader, err
+	}
+	return s.newReader(reader)
+}
+
+func (s *encryptedDiskStore) OpenVectorPartyFileForWrite(table string, columnID, shard, batchID int, batchVersion uint32,
+	seqNum uint32) (io.WriteCloser, error) {
+	writer, err := s.DiskStore.OpenVectorPartyFileForWrite(table, columnID, shard, batchID, batchVersion, seqNum)
+	if err != nil || !s.encrypted(table, columnID) {
+		return writer, err
+	}
+	return s.newWriter(writer)
+}
+
+func (s *encryptedDiskStore) OpenBatchIndexFileForRead(table string, columnID, shard, batchID int, batchVersion uint32,
+	seqNum uint32) (io.ReadCloser, error) {
+	reader, err := s.DiskStore.OpenBatchIndexFileForRead(table, columnID, shard, batchID, batchVersion, seqNum)
+	if err != nil || reader == nil {
+		return reader, err
+	}
+	return s.newReader(reader)
+}
+
+// OpenBatchIndexFileForWrite encrypts index files of encrypted columns as they reveal the
+// distribution of column values.
+func (s *encryptedDiskStore) OpenBatchIndexFileForWrite(table string, columnID, shard, batchID int, batchVersion uint32,
+	seqNum uint32) (io.WriteCloser, error) {
+	writer, err := s.DiskStore.OpenBatchIndexFileForWrite(table, columnID, shard, batchID, batchVersion, seqNum)
+	if err != nil || !s.encrypted(table, columnID) {
+		return writer, err
+	}
+	return s.newWriter(writer)
+}
+
+// newWriter writes the file header with a new wrapped data key.
+func (s *encryptedDiskStore) newWriter(writer io.WriteCloser) (io.WriteCloser, error) {
+	dataKey, wrappedKey, err := s.kms.GenerateDataKey()
+	if err == nil {
+		var aead cipher.AEAD
+		if aead, err = newAEAD(dataKey); err == nil {
+			header := make([]byte, len(encryptionMagic)+2, len(encryptionMagic)+2+len(wrappedKey))
+			copy(header, encryptionMagic)
+			binary.LittleEndian.PutUint16(header[len(encryptionMagic):], uint16(len(wrappedKey)))
+			if _, err = writer.Write(append(header, wrappedKey...)); err == nil {
+				return &encryptingWriter{
+					writer: writer,
+					aead:   aead,
+					buffer: make([]byte, 0, encryptionChunkSize),
+				}, nil
+			}
+		}
+	}
+	writer.Close()
+	return nil, utils.StackError(err, "failed to create encrypted vector party file")
+}
+
+// newReader decrypts the file if it's encrypted, other files are read as is.
+func (s *encryptedDiskStore) newReader(reader io.ReadCloser) (io.ReadCloser, error) {
+	bufferedReader := bufio.NewReader(reader)
+	magic, err := bufferedReader.Peek(len(encryptionMagic))
+	if err != nil || !bytes.Equal(magic, encryptionMagic) {
+		return &bufferedReadCloser{Reader: bufferedReader, closer: reader}, nil
+	}
+
+	header := make([]byte, len(encryptionMagic)+2)
+	if _, err = io.ReadFull(bufferedReader, header); err == nil {
+		wrappedKey := make([]byte, binary.LittleEndian.Uint16(header[len(encryptionMagic):]))
+		if _, err = io.ReadFull(bufferedReader, wrappedKey); err == nil {
+			var dataKey []byte
+			if dataKey, err = s.kms.DecryptDataKey(wrappedKey); err == nil {
+				var aead cipher.AEAD
+				if aead, err = newAEAD(dataKey); err == nil {
+					return &decryptingReader{reader: bufferedReader, closer: reader, aead: aead}, nil
+				}
+			}
+		}
+	}
+	reader.Close()
+	return nil, utils.StackError(err, "failed to open encrypted vector party file")
+}
+
+// bufferedReadCloser reads from the buffered reader and closes the underlying file.
+type bufferedReadCloser struct {
+	*bufio.Reader
+	closer io.Closer
+}
+
+func (r *bufferedReadCloser) Close() error {
+	return r.closer.Close()
+}
+
+// encryptingWriter seals plaintext in chunks, the last chunk is flagged so that
+// truncated files are detected on read.
+type encryptingWriter struct {
+	writer io.WriteCloser
+	aead   cipher.AEAD
+	buffer []byte
+	index  uint64
+}
+
+func (w *encryptingWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		size := encryptionChunkSize - len(w.buffer)
+		if size > len(p) {
+			size = len(p)
+		}
+		w.buffer = append(w.buffer, p[:size]...)
+		p = p[size:]
+		n += size
+		if len(w.buffer) == encryptionChunkSize {
+			if err = w.flush(0); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (w *encryptingWriter) flush(flag byte) error {
+	header := make([]byte, encryptionChunkHeaderSize, encryptionChunkHeaderSize+len(w.buffer)+w.aead.Overhead())
+	header[0] = flag
+	binary.LittleEndian.PutUint32(header[1:], uint32(len(w.buffer)+w.aead.Overhead()))
+	chunk := w.aead.Seal(header, chunkNonce(w.aead, w.index), w.buffer, header[:1])
+	if _, err := w.writer.Write(chunk); err != nil {
+		return err
+	}
+	w.index++
+	w.buffer = w.buffer[:0]
+	return nil
+}
+
+// Close writes the final chunk and closes the file.
+func (w *encryptingWriter) Close() error {
+	err := w.flush(encryptionFinalChunkFlag)
+	if closeErr := w.writer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// decryptingReader opens sealed chunks one by one.
+type decryptingReader struct {
+	reader io.Reader
+	closer io.Closer
+	aead   cipher.AEAD
+	// plaintext of current chunk not read yet
+	buffer []byte
+	index  uint64
+	final  bool
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	for len(r.buffer) == 0 {
+		if r.final {
+			return 0, io.EOF
+		}
+		if err := r.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.buffer)
+	r.buffer = r.buffer[n:]
+	return n, nil
+}
+
+func (r *decryptingReader) readChunk() error {
+	header := make([]byte, encryptionChunkHeaderSize)
+	if _, err := io.ReadFull(r.reader, header); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return utils.StackError(err, "encrypted vector party file is truncated")
+	}
+	sealed := make([]byte, binary.LittleEndian.Uint32(header[1:]))
+	if _, err := io.ReadFull(r.reader, sealed); err != nil {
+		return utils.StackError(err, "encrypted vector party file is truncated")
+	}
+	plaintext, err := r.aead.Open(sealed[:0], chunkNonce(r.aead, r.index), sealed, header[:1])
+	if err != nil {
+		return utils.StackError(err, "failed to decrypt vector party file")
+	}
+	r.buffer = plaintext
+	r.index++
+	r.final = header[0] == encryptionFinalChunkFlag
+	return nil
+}
+
+func (r *decryptingReader) Close() error {
+	return r.closer.Close()
+}
@@ -0,0 +1,197 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package diskstore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/uber/aresdb/common"
+)
+
+var _ = ginkgo.Describe("encrypted disk store", func() {
+	prefix := "/tmp/testEncryptedDiskStore"
+	table := "myTable"
+	masterKey := bytes.Repeat([]byte{7}, encryptionKeySize)
+	// larger than a chunk to test multiple chunks.
+	data := make([]byte, encryptionChunkSize*2+100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	var kms KMS
+	var diskStore DiskStore
+	encrypted := func(table string, columnID int) bool {
+		return columnID == 1
+	}
+
+	writeFile := func(store DiskStore, columnID int, content []byte) {
+		writer, err := store.OpenVectorPartyFileForWrite(table, columnID, 0, 100, 1, 0)
+		Ω(err).Should(BeNil())
+		_, err = writer.Write(content)
+		Ω(err).Should(BeNil())
+		Ω(writer.Close()).Should(BeNil())
+	}
+
+	readFile := func(store DiskStore, columnID int) ([]byte, error) {
+		reader, err := store.OpenVectorPartyFileForRead(table, columnID, 0, 100, 1, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	}
+
+	filePath := func(columnID int) string {
+		return GetPathForTableArchiveBatchColumnFile(prefix, table, 0, daysSinceEpochToTimeStr(100), 1, 0, columnID)
+	}
+
+	ginkgo.BeforeEach(func() {
+		os.RemoveAll(prefix)
+		os.MkdirAll(prefix, 0755)
+		var err error
+		kms, err = NewLocalKMS(masterKey)
+		Ω(err).Should(BeNil())
+		diskStore = NewEncryptedDiskStore(NewLocalDiskStore(prefix), kms, encrypted)
+	})
+
+	ginkgo.AfterEach(func() {
+		os.RemoveAll(prefix)
+	})
+
+	ginkgo.It("encrypts and decrypts encrypted columns", func() {
+		writeFile(diskStore, 1, data)
+		raw, err := ioutil.ReadFile(filePath(1))
+		Ω(err).Should(BeNil())
+		Ω(bytes.HasPrefix(raw, encryptionMagic)).Should(BeTrue())
+		Ω(bytes.Contains(raw, data[:1024])).Should(BeFalse())
+
+		content, err := readFile(diskStore, 1)
+		Ω(err).Should(BeNil())
+		Ω(content).Should(Equal(data))
+
+		writeFile(diskStore, 1, []byte{})
+		content, err = readFile(diskStore, 1)
+		Ω(err).Should(BeNil())
+		Ω(content).Should(BeEmpty())
+	})
+
+	ginkgo.It("keeps other columns and existing files in plaintext", func() {
+		writeFile(diskStore, 0, data)
+		raw, err := ioutil.ReadFile(filePath(0))
+		Ω(err).Should(BeNil())
+		Ω(raw).Should(Equal(data))
+
+		writeFile(NewLocalDiskStore(prefix), 1, data[:10])
+		content, err := readFile(diskStore, 1)
+		Ω(err).Should(BeNil())
+		Ω(content).Should(Equal(data[:10]))
+
+		_, err = readFile(diskStore, 2)
+		Ω(os.IsNotExist(err)).Should(BeTrue())
+	})
+
+	ginkgo.It("reads and writes files as stored on disk through the raw disk store", func() {
+		compressed := NewCompressedDiskStore(diskStore, DefaultCompressionLevel, func(table string, columnID int) int { return 0 })
+		rawStore := Raw(compressed)
+		Ω(rawStore).Should(BeAssignableToTypeOf(NewLocalDiskStore(prefix)))
+
+		writeFile(compressed, 1, data)
+		stored, err := ioutil.ReadFile(filePath(1))
+		Ω(err).Should(BeNil())
+		content, err := readFile(rawStore, 1)
+		Ω(err).Should(BeNil())
+		Ω(content).Should(Equal(stored))
+
+		// copying the raw file keeps it decodable.
+		writeFile(rawStore, 2, content)
+		content, err = readFile(compressed, 2)
+		Ω(err).Should(BeNil())
+		Ω(content).Should(Equal(data))
+	})
+
+	ginkgo.It("encrypts snapshot files", func() {
+		writer, err := diskStore.OpenSnapshotVectorPartyFileForWrite(table, 0, 1, 0, -1, 1)
+		Ω(err).Should(BeNil())
+		_, err = writer.Write(data)
+		Ω(err).Should(BeNil())
+		Ω(writer.Close()).Should(BeNil())
+
+		reader, err := diskStore.OpenSnapshotVectorPartyFileForRead(table, 0, 1, 0, -1, 1)
+		Ω(err).Should(BeNil())
+		content, err := ioutil.ReadAll(reader)
+		Ω(err).Should(BeNil())
+		Ω(reader.Close()).Should(BeNil())
+		Ω(content).Should(Equal(data))
+	})
+
+	ginkgo.It("detects tampered and truncated files", func() {
+		writeFile(diskStore, 1, data)
+		raw, err := ioutil.ReadFile(filePath(1))
+		Ω(err).Should(BeNil())
+
+		tampered := append([]byte{}, raw...)
+		tampered[len(tampered)-1] ^= 1
+		Ω(ioutil.WriteFile(filePath(1), tampered, 0644)).Should(BeNil())
+		_, err = readFile(diskStore, 1)
+		Ω(err).ShouldNot(BeNil())
+
+		// drop the final chunk.
+		truncated := raw[:len(raw)-(100+encryptionChunkHeaderSize+16)]
+		Ω(ioutil.WriteFile(filePath(1), truncated, 0644)).Should(BeNil())
+		_, err = readFile(diskStore, 1)
+		Ω(err).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("fails to read with a different master key", func() {
+		writeFile(diskStore, 1, data)
+		otherKMS, err := NewLocalKMS(bytes.Repeat([]byte{8}, encryptionKeySize))
+		Ω(err).Should(BeNil())
+		_, err = readFile(NewEncryptedDiskStore(NewLocalDiskStore(prefix), otherKMS, encrypted), 1)
+		Ω(err).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("creates kms from config", func() {
+		_, err := NewLocalKMS([]byte{1})
+		Ω(err).ShouldNot(BeNil())
+
+		keyFile := filepath.Join(prefix, "master.key")
+		Ω(ioutil.WriteFile(keyFile, []byte(hex.EncodeToString(masterKey)+"\n"), 0600)).Should(BeNil())
+		store, err := WithEncryption(NewLocalDiskStore(prefix), common.EncryptionConfig{
+			Enable:        true,
+			KMS:           common.KMSLocal,
+			MasterKeyFile: keyFile,
+		}, encrypted)
+		Ω(err).Should(BeNil())
+		writeFile(store, 1, data)
+		content, err := readFile(diskStore, 1)
+		Ω(err).Should(BeNil())
+		Ω(content).Should(Equal(data))
+
+		store, err = WithEncryption(diskStore, common.EncryptionConfig{}, encrypted)
+		Ω(err).Should(BeNil())
+		Ω(store).Should(Equal(diskStore))
+
+		_, err = WithEncryption(diskStore, common.EncryptionConfig{Enable: true, KMS: "unknown"}, encrypted)
+		Ω(err).ShouldNot(BeNil())
+		_, err = WithEncryption(diskStore, common.EncryptionConfig{Enable: true, MasterKeyFile: filepath.Join(prefix, "missing")}, encrypted)
+		Ω(err).ShouldNot(BeNil())
+	})
+})
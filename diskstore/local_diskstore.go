@@ -15,6 +15,8 @@
 package diskstore
 
 import (
+	"bufio"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
@@ -132,6 +134,37 @@ func (l LocalDiskStore) TruncateLogFile(table string, shard int, creationTime in
 	return err
 }
 
+// RewriteLogFile writes the new content of the redolog into a temporary file and renames it
+// over the redolog once rewrite succeeds.
+func (l LocalDiskStore) RewriteLogFile(table string, shard int, creationTime int64, rewrite func(io.Writer) error) error {
+	redologFilePath := GetPathForRedologFile(l.rootPath, table, shard, creationTime)
+	tmpFilePath := filepath.Join(GetPathForTableRedologs(l.rootPath, table, shard), fmt.Sprintf("%d.compacting", creationTime))
+	f, err := os.OpenFile(tmpFilePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return utils.StackError(err, "Failed to open temporary file for redolog file: %s", redologFilePath)
+	}
+
+	writer := bufio.NewWriter(f)
+	if err = rewrite(writer); err == nil {
+		if err = writer.Flush(); err == nil {
+			err = f.Sync()
+		}
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFilePath)
+		return utils.StackError(err, "Failed to rewrite redolog file: %s", redologFilePath)
+	}
+
+	if err = os.Rename(tmpFilePath, redologFilePath); err != nil {
+		os.Remove(tmpFilePath)
+		return utils.StackError(err, "Failed to replace redolog file: %s", redologFilePath)
+	}
+	return nil
+}
+
 // Snapshot files.
 
 // ListSnapshotBatches : Returns the batch directories at the specified version.
@@ -184,6 +217,10 @@ func (l LocalDiskStore) readVectoryPartyFiles(dir string) (columnIDs []int, err
 	}
 
 	for _, f := range vpFiles {
+		// bitmap index and zone map files live along with vector party files of archive batches.
+		if strings.HasSuffix(f.Name(), batchIndexFileSuffix) || f.Name() == batchZoneMapFile {
+			continue
+		}
 		matchedVectorPartyFilePattern, _ := regexp.MatchString("([0-9]+).data", f.Name())
 		if matchedVectorPartyFilePattern {
 			var columnID int64
@@ -336,6 +373,80 @@ func (l LocalDiskStore) OpenVectorPartyFileForWrite(table string, columnID int,
 	return f, nil
 }
 
+// OpenBatchIndexFileForRead : Opens the index file of the column at the specified batchVersion for read.
+func (l LocalDiskStore) OpenBatchIndexFileForRead(table string, columnID int, shard, batchID int, batchVersion uint32,
+	seqNum uint32) (io.ReadCloser, error) {
+	batchIDTimeStr := daysSinceEpochToTimeStr(batchID)
+	indexFilePath := GetPathForTableArchiveBatchIndexFile(l.rootPath, table, shard, batchIDTimeStr, batchVersion,
+		seqNum, columnID)
+	f, err := os.OpenFile(indexFilePath, os.O_RDONLY, 0644)
+	if os.IsNotExist(err) {
+		return nil, os.ErrNotExist
+	} else if err != nil {
+		return nil, utils.StackError(err, "Failed to open batch index file: %s for read", indexFilePath)
+	}
+	return f, nil
+}
+
+// OpenBatchIndexFileForWrite : Creates/truncates the index file of the column at the specified batchVersion for write.
+func (l LocalDiskStore) OpenBatchIndexFileForWrite(table string, columnID int, shard, batchID int, batchVersion uint32,
+	seqNum uint32) (io.WriteCloser, error) {
+	batchIDTimeStr := daysSinceEpochToTimeStr(batchID)
+	batchDir := GetPathForTableArchiveBatchDir(l.rootPath, table, shard, batchIDTimeStr, batchVersion, seqNum)
+	if err := os.MkdirAll(batchDir, 0755); err != nil {
+		return nil, utils.StackError(err, "Failed to make dirs for path: %s", batchDir)
+	}
+	indexFilePath := GetPathForTableArchiveBatchIndexFile(l.rootPath, table, shard, batchIDTimeStr, batchVersion,
+		seqNum, columnID)
+
+	mode := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if l.diskStoreConfig.WriteSync {
+		mode |= os.O_SYNC
+	}
+
+	f, err := os.OpenFile(indexFilePath, mode, 0644)
+	if err != nil {
+		return nil, utils.StackError(err, "Failed to open batch index file: %s for write", indexFilePath)
+	}
+	return f, nil
+}
+
+// OpenBatchZoneMapFileForRead : Opens the zone map file at the specified batchVersion for read.
+func (l LocalDiskStore) OpenBatchZoneMapFileForRead(table string, shard, batchID int, batchVersion uint32,
+	seqNum uint32) (io.ReadCloser, error) {
+	batchIDTimeStr := daysSinceEpochToTimeStr(batchID)
+	zoneMapFilePath := GetPathForTableArchiveBatchZoneMapFile(l.rootPath, table, shard, batchIDTimeStr, batchVersion, seqNum)
+	f, err := os.OpenFile(zoneMapFilePath, os.O_RDONLY, 0644)
+	if os.IsNotExist(err) {
+		return nil, os.ErrNotExist
+	} else if err != nil {
+		return nil, utils.StackError(err, "Failed to open batch zone map file: %s for read", zoneMapFilePath)
+	}
+	return f, nil
+}
+
+// OpenBatchZoneMapFileForWrite : Creates/truncates the zone map file at the specified batchVersion for write.
+func (l LocalDiskStore) OpenBatchZoneMapFileForWrite(table string, shard, batchID int, batchVersion uint32,
+	seqNum uint32) (io.WriteCloser, error) {
+	batchIDTimeStr := daysSinceEpochToTimeStr(batchID)
+	batchDir := GetPathForTableArchiveBatchDir(l.rootPath, table, shard, batchIDTimeStr, batchVersion, seqNum)
+	if err := os.MkdirAll(batchDir, 0755); err != nil {
+		return nil, utils.StackError(err, "Failed to make dirs for path: %s", batchDir)
+	}
+	zoneMapFilePath := GetPathForTableArchiveBatchZoneMapFile(l.rootPath, table, shard, batchIDTimeStr, batchVersion, seqNum)
+
+	mode := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if l.diskStoreConfig.WriteSync {
+		mode |= os.O_SYNC
+	}
+
+	f, err := os.OpenFile(zoneMapFilePath, mode, 0644)
+	if err != nil {
+		return nil, utils.StackError(err, "Failed to open batch zone map file: %s for write", zoneMapFilePath)
+	}
+	return f, nil
+}
+
 // DeleteBatchVersions deletes all old batches with the specified batchID that have version lower than or equal to
 // the specified batch  version. All columns of those batches will be deleted.
 func (l LocalDiskStore) DeleteBatchVersions(table string, shard, batchID int, batchVersion uint32, seqNum uint32) error {
@@ -355,6 +466,16 @@ func (l LocalDiskStore) DeleteBatchVersions(table string, shard, batchID int, ba
 	return nil
 }
 
+// DeleteBatchVersion deletes the batch with the specified batchID at exactly the specified batch version.
+func (l LocalDiskStore) DeleteBatchVersion(table string, shard, batchID int, batchVersion uint32, seqNum uint32) error {
+	batchIDTimeStr := daysSinceEpochToTimeStr(batchID)
+	batchDir := GetPathForTableArchiveBatchDir(l.rootPath, table, shard, batchIDTimeStr, batchVersion, seqNum)
+	if err := os.RemoveAll(batchDir); err != nil {
+		return utils.StackError(err, "Failed to delete batch directory: %s", batchDir)
+	}
+	return nil
+}
+
 // DeleteBatches : Deletes all batches within [batchIDStart, batchIDEnd)
 func (l LocalDiskStore) DeleteBatches(table string, shard, batchIDStart, batchIDEnd int) (int, error) {
 	batchIDStartTime := daysSinceEpochToTime(batchIDStart)
@@ -416,6 +537,14 @@ func (l LocalDiskStore) DeleteColumn(table string, columnID int, shard int) erro
 					).Warn("Failed to delete a vector party file")
 					continue
 				}
+				indexFilePath := GetPathForTableArchiveBatchIndexFile(l.rootPath, table, shard, batchID,
+					batchVersion, seqNum, columnID)
+				if err = os.Remove(indexFilePath); err != nil && !os.IsNotExist(err) {
+					utils.GetLogger().With(
+						"indexFilePath", indexFilePath,
+						"err", err,
+					).Warn("Failed to delete a batch index file")
+				}
 			}
 		}
 	}
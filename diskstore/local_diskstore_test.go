@@ -15,6 +15,7 @@
 package diskstore
 
 import (
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"os"
@@ -193,6 +194,34 @@ var _ = ginkgo.Describe("DiskStore", func() {
 		}
 	})
 
+	ginkgo.It("RewriteLogFile should replace redolog file only if rewrite succeeds", func() {
+		redologDirPath := GetPathForTableRedologs(prefix, table, shard)
+		os.MkdirAll(redologDirPath, os.ModeDir|os.ModePerm)
+		filePath := GetPathForRedologFile(prefix, table, shard, 1)
+		ioutil.WriteFile(filePath, []byte("old"), os.ModePerm)
+		l := NewLocalDiskStore(prefix)
+
+		err := l.RewriteLogFile(table, shard, 1, func(w io.Writer) error {
+			w.Write([]byte("partial"))
+			return fmt.Errorf("rewrite failed")
+		})
+		Ω(err).ShouldNot(BeNil())
+		content, _ := ioutil.ReadFile(filePath)
+		Ω(string(content)).Should(Equal("old"))
+
+		err = l.RewriteLogFile(table, shard, 1, func(w io.Writer) error {
+			_, err := w.Write([]byte("new"))
+			return err
+		})
+		Ω(err).Should(BeNil())
+		content, _ = ioutil.ReadFile(filePath)
+		Ω(string(content)).Should(Equal("new"))
+
+		// no temporary file left.
+		files, _ := ioutil.ReadDir(redologDirPath)
+		Ω(files).Should(HaveLen(1))
+	})
+
 	ginkgo.It("works with non-existing redolog file directory", func() {
 		l := NewLocalDiskStore(prefix)
 		files, err := l.ListLogFiles(table, shard)
@@ -523,6 +552,28 @@ var _ = ginkgo.Describe("DiskStore", func() {
 		Ω(len(dirs)).Should(Equal(0))
 	})
 
+	ginkgo.It("Test DeleteBatchVersion for LocalDiskstore", func() {
+		l := NewLocalDiskStore(prefix)
+		batchIDSinceEpoch := 6742
+		for seqNum := uint32(0); seqNum < 3; seqNum++ {
+			writeCloser, err := l.OpenVectorPartyFileForWrite(table, 1, shard, batchIDSinceEpoch, 10, seqNum)
+			Ω(err).Should(BeNil())
+			Ω(writeCloser.Close()).Should(BeNil())
+		}
+
+		Ω(l.DeleteBatchVersion(table, shard, batchIDSinceEpoch, 10, 1)).Should(BeNil())
+		columnIDs, err := l.ListArchiveBatchVectorPartyFiles(table, shard, batchIDSinceEpoch, 10, 0)
+		Ω(err).Should(BeNil())
+		Ω(columnIDs).Should(Equal([]int{1}))
+		columnIDs, err = l.ListArchiveBatchVectorPartyFiles(table, shard, batchIDSinceEpoch, 10, 2)
+		Ω(err).Should(BeNil())
+		Ω(columnIDs).Should(Equal([]int{1}))
+		_, err = l.OpenVectorPartyFileForRead(table, 1, shard, batchIDSinceEpoch, 10, 1)
+		Ω(err).Should(Equal(os.ErrNotExist))
+		// deleting a missing batch version is no-op.
+		Ω(l.DeleteBatchVersion(table, shard, batchIDSinceEpoch, 10, 1)).Should(BeNil())
+	})
+
 	ginkgo.It("Test DeleteBatches with batchIDCutoff for LocalDiskstore", func() {
 		l := NewLocalDiskStore(prefix)
 		// Setup directory
@@ -662,6 +713,70 @@ var _ = ginkgo.Describe("DiskStore", func() {
 		Ω(err).Should(BeNil())
 		Ω(columns).Should(BeEmpty())
 	})
+
+	ginkgo.It("Test Read/Write/Delete batch index files for LocalDiskstore", func() {
+		batchID := "1988-06-17"
+		batchIDSinceEpoch := 6742
+		batchVersion := uint32(123)
+		l := NewLocalDiskStore(prefix)
+
+		_, err := l.OpenBatchIndexFileForRead(table, 1, shard, batchIDSinceEpoch, batchVersion, 0)
+		Ω(err).Should(Equal(os.ErrNotExist))
+
+		writer, err := l.OpenBatchIndexFileForWrite(table, 1, shard, batchIDSinceEpoch, batchVersion, 0)
+		Ω(err).Should(BeNil())
+		_, err = writer.Write([]byte{1, 2, 3})
+		Ω(err).Should(BeNil())
+		Ω(writer.Close()).Should(BeNil())
+		vpFilePath := GetPathForTableArchiveBatchColumnFile(prefix, table, shard, batchID, batchVersion, 0, 1)
+		Ω(ioutil.WriteFile(vpFilePath, []byte{}, os.ModePerm)).Should(BeNil())
+
+		reader, err := l.OpenBatchIndexFileForRead(table, 1, shard, batchIDSinceEpoch, batchVersion, 0)
+		Ω(err).Should(BeNil())
+		bytes, err := ioutil.ReadAll(reader)
+		Ω(err).Should(BeNil())
+		Ω(bytes).Should(Equal([]byte{1, 2, 3}))
+		reader.Close()
+
+		// index files are not listed as vector party files.
+		columns, err := l.ListArchiveBatchVectorPartyFiles(table, shard, batchIDSinceEpoch, batchVersion, 0)
+		Ω(err).Should(BeNil())
+		Ω(columns).Should(Equal([]int{1}))
+
+		Ω(l.DeleteColumn(table, 1, shard)).Should(BeNil())
+		_, err = l.OpenBatchIndexFileForRead(table, 1, shard, batchIDSinceEpoch, batchVersion, 0)
+		Ω(err).Should(Equal(os.ErrNotExist))
+	})
+
+	ginkgo.It("Test Read/Write batch zone map files for LocalDiskstore", func() {
+		batchID := "1988-06-17"
+		batchIDSinceEpoch := 6742
+		batchVersion := uint32(124)
+		l := NewLocalDiskStore(prefix)
+
+		_, err := l.OpenBatchZoneMapFileForRead(table, shard, batchIDSinceEpoch, batchVersion, 0)
+		Ω(err).Should(Equal(os.ErrNotExist))
+
+		writer, err := l.OpenBatchZoneMapFileForWrite(table, shard, batchIDSinceEpoch, batchVersion, 0)
+		Ω(err).Should(BeNil())
+		_, err = writer.Write([]byte{1, 2, 3})
+		Ω(err).Should(BeNil())
+		Ω(writer.Close()).Should(BeNil())
+		vpFilePath := GetPathForTableArchiveBatchColumnFile(prefix, table, shard, batchID, batchVersion, 0, 1)
+		Ω(ioutil.WriteFile(vpFilePath, []byte{}, os.ModePerm)).Should(BeNil())
+
+		reader, err := l.OpenBatchZoneMapFileForRead(table, shard, batchIDSinceEpoch, batchVersion, 0)
+		Ω(err).Should(BeNil())
+		bytes, err := ioutil.ReadAll(reader)
+		Ω(err).Should(BeNil())
+		Ω(bytes).Should(Equal([]byte{1, 2, 3}))
+		reader.Close()
+
+		// zone map files are not listed as vector party files.
+		columns, err := l.ListArchiveBatchVectorPartyFiles(table, shard, batchIDSinceEpoch, batchVersion, 0)
+		Ω(err).Should(BeNil())
+		Ω(columns).Should(Equal([]int{1}))
+	})
 })
 
 func getPathForRedologFile(prefix, table string, shardID int, filename string) string {
@@ -25,6 +25,20 @@ func (_m *DiskStore) DeleteBatchVersions(table string, shard int, batchID int, b
 	return r0
 }
 
+// DeleteBatchVersion provides a mock function with given fields: table, shard, batchID, batchVersion, seqNum
+func (_m *DiskStore) DeleteBatchVersion(table string, shard int, batchID int, batchVersion uint32, seqNum uint32) error {
+	ret := _m.Called(table, shard, batchID, batchVersion, seqNum)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int, int, uint32, uint32) error); ok {
+		r0 = rf(table, shard, batchID, batchVersion, seqNum)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteBatches provides a mock function with given fields: table, shard, batchIDStart, batchIDEnd
 func (_m *DiskStore) DeleteBatches(table string, shard int, batchIDStart int, batchIDEnd int) (int, error) {
 	ret := _m.Called(table, shard, batchIDStart, batchIDEnd)
@@ -194,6 +208,98 @@ func (_m *DiskStore) ListSnapshotVectorPartyFiles(table string, shard int, redoL
 	return r0, r1
 }
 
+// OpenBatchIndexFileForRead provides a mock function with given fields: table, column, shard, batchID, batchVersion, seqNum
+func (_m *DiskStore) OpenBatchIndexFileForRead(table string, column int, shard int, batchID int, batchVersion uint32, seqNum uint32) (io.ReadCloser, error) {
+	ret := _m.Called(table, column, shard, batchID, batchVersion, seqNum)
+
+	var r0 io.ReadCloser
+	if rf, ok := ret.Get(0).(func(string, int, int, int, uint32, uint32) io.ReadCloser); ok {
+		r0 = rf(table, column, shard, batchID, batchVersion, seqNum)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.ReadCloser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, int, int, int, uint32, uint32) error); ok {
+		r1 = rf(table, column, shard, batchID, batchVersion, seqNum)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// OpenBatchIndexFileForWrite provides a mock function with given fields: table, column, shard, batchID, batchVersion, seqNum
+func (_m *DiskStore) OpenBatchIndexFileForWrite(table string, column int, shard int, batchID int, batchVersion uint32, seqNum uint32) (io.WriteCloser, error) {
+	ret := _m.Called(table, column, shard, batchID, batchVersion, seqNum)
+
+	var r0 io.WriteCloser
+	if rf, ok := ret.Get(0).(func(string, int, int, int, uint32, uint32) io.WriteCloser); ok {
+		r0 = rf(table, column, shard, batchID, batchVersion, seqNum)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.WriteCloser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, int, int, int, uint32, uint32) error); ok {
+		r1 = rf(table, column, shard, batchID, batchVersion, seqNum)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// OpenBatchZoneMapFileForRead provides a mock function with given fields: table, shard, batchID, batchVersion, seqNum
+func (_m *DiskStore) OpenBatchZoneMapFileForRead(table string, shard int, batchID int, batchVersion uint32, seqNum uint32) (io.ReadCloser, error) {
+	ret := _m.Called(table, shard, batchID, batchVersion, seqNum)
+
+	var r0 io.ReadCloser
+	if rf, ok := ret.Get(0).(func(string, int, int, uint32, uint32) io.ReadCloser); ok {
+		r0 = rf(table, shard, batchID, batchVersion, seqNum)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.ReadCloser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, int, int, uint32, uint32) error); ok {
+		r1 = rf(table, shard, batchID, batchVersion, seqNum)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// OpenBatchZoneMapFileForWrite provides a mock function with given fields: table, shard, batchID, batchVersion, seqNum
+func (_m *DiskStore) OpenBatchZoneMapFileForWrite(table string, shard int, batchID int, batchVersion uint32, seqNum uint32) (io.WriteCloser, error) {
+	ret := _m.Called(table, shard, batchID, batchVersion, seqNum)
+
+	var r0 io.WriteCloser
+	if rf, ok := ret.Get(0).(func(string, int, int, uint32, uint32) io.WriteCloser); ok {
+		r0 = rf(table, shard, batchID, batchVersion, seqNum)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.WriteCloser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, int, int, uint32, uint32) error); ok {
+		r1 = rf(table, shard, batchID, batchVersion, seqNum)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // OpenLogFileForAppend provides a mock function with given fields: table, shard, creationTime
 func (_m *DiskStore) OpenLogFileForAppend(table string, shard int, creationTime int64) (io.WriteCloser, error) {
 	ret := _m.Called(table, shard, creationTime)
@@ -332,6 +438,20 @@ func (_m *DiskStore) OpenVectorPartyFileForWrite(table string, column int, shard
 	return r0, r1
 }
 
+// RewriteLogFile provides a mock function with given fields: table, shard, creationTime, rewrite
+func (_m *DiskStore) RewriteLogFile(table string, shard int, creationTime int64, rewrite func(io.Writer) error) error {
+	ret := _m.Called(table, shard, creationTime, rewrite)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int, int64, func(io.Writer) error) error); ok {
+		r0 = rf(table, shard, creationTime, rewrite)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // TruncateLogFile provides a mock function with given fields: table, shard, creationTime, offset
 func (_m *DiskStore) TruncateLogFile(table string, shard int, creationTime int64, offset int64) error {
 	ret := _m.Called(table, shard, creationTime, offset)
@@ -3,6 +3,7 @@ module github.com/uber/aresdb
 go 1.12
 
 require (
+	github.com/DataDog/zstd v1.3.6-0.20190409195224-796139022798
 	github.com/MichaelTJones/pcg v0.0.0-20180122055547-df440c6ed7ed // indirect
 	github.com/Shopify/sarama v1.22.1
 	github.com/abiosoft/ishell v2.0.0+incompatible
@@ -19,6 +20,7 @@ require (
 	github.com/fortytw2/leaktest v1.3.0 // indirect
 	github.com/getlantern/deepcopy v0.0.0-20160317154340-7f45deb8130a
 	github.com/gofrs/uuid v3.2.0+incompatible
+	github.com/golang/geo v0.0.0-20210211234256-740aa86cb551
 	github.com/golang/mock v1.3.1
 	github.com/golang/protobuf v1.3.1
 	github.com/gorilla/handlers v1.4.0
@@ -39,8 +41,12 @@ require (
 	github.com/spf13/cobra v0.0.5
 	github.com/spf13/pflag v1.0.3
 	github.com/spf13/viper v1.4.0
-	github.com/stretchr/testify v1.3.0
+	github.com/stretchr/testify v1.7.0
 	github.com/uber-go/tally v3.3.11+incompatible
+	go.opentelemetry.io/otel v1.0.0
+	go.opentelemetry.io/otel/exporters/jaeger v1.0.0
+	go.opentelemetry.io/otel/sdk v1.0.0
+	go.opentelemetry.io/otel/trace v1.0.0
 	go.uber.org/config v1.3.1
 	go.uber.org/dig v1.7.0 // indirect
 	go.uber.org/fx v1.9.0
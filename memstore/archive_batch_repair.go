@@ -0,0 +1,260 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// ArchiveBatchDump is an archive batch version exported for manual repair. Vector party files
+// are kept in their on disk format so that a dump of a healthy replica can replace a corrupted
+// batch of another replica as is.
+type ArchiveBatchDump struct {
+	Table   string `json:"table"`
+	Shard   int    `json:"shard"`
+	BatchID int    `json:"batchID"`
+	Version uint32 `json:"version"`
+	SeqNum  uint32 `json:"seqNum"`
+	// Number of rows of the batch.
+	Size          int               `json:"size"`
+	VectorParties []VectorPartyDump `json:"vectorParties"`
+}
+
+// VectorPartyDump is the vector party file of a column in an archive batch dump.
+type VectorPartyDump struct {
+	ColumnID   int    `json:"columnID"`
+	ColumnName string `json:"columnName"`
+	// Hex encoded sha256 of data.
+	Checksum string `json:"checksum"`
+	Data     []byte `json:"data"`
+}
+
+// vectorPartyChecksum returns the checksum of vector party file data in dumps.
+func vectorPartyChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ExportArchiveBatch dumps vector party files of the current version of the archive batch.
+// Columns without vector party file in the batch version (e.g. added after the batch was
+// archived) are not included.
+func (shard *TableShard) ExportArchiveBatch(batchID int) (*ArchiveBatchDump, error) {
+	// holding the archive store version prevents its batch versions from being purged from disk.
+	version := shard.ArchiveStore.GetCurrentVersion()
+	defer version.Users.Done()
+
+	batch := version.RequestBatch(int32(batchID))
+	if batch.Size == 0 {
+		return nil, utils.APIError{Code: http.StatusNotFound, Message: "archive batch not found"}
+	}
+
+	table := shard.Schema.Schema.Name
+	columnIDs, err := shard.diskStore.ListArchiveBatchVectorPartyFiles(table, shard.ShardID, batchID,
+		batch.Version, batch.SeqNum)
+	if err != nil {
+		return nil, err
+	}
+	sort.Ints(columnIDs)
+
+	shard.Schema.RLock()
+	columns := shard.Schema.Schema.Columns
+	shard.Schema.RUnlock()
+
+	dump := &ArchiveBatchDump{
+		Table:   table,
+		Shard:   shard.ShardID,
+		BatchID: batchID,
+		Version: batch.Version,
+		SeqNum:  batch.SeqNum,
+		Size:    batch.Size,
+	}
+	for _, columnID := range columnIDs {
+		if columnID >= len(columns) || columns[columnID].Deleted {
+			continue
+		}
+		data, err := shard.readArchiveVectorPartyFile(batchID, batch.Version, batch.SeqNum, columnID)
+		if err != nil {
+			return nil, err
+		}
+		dump.VectorParties = append(dump.VectorParties, VectorPartyDump{
+			ColumnID:   columnID,
+			ColumnName: columns[columnID].Name,
+			Checksum:   vectorPartyChecksum(data),
+			Data:       data,
+		})
+	}
+	return dump, nil
+}
+
+func (shard *TableShard) readArchiveVectorPartyFile(batchID int, version, seqNum uint32, columnID int) ([]byte, error) {
+	reader, err := shard.diskStore.OpenVectorPartyFileForRead(shard.Schema.Schema.Name, columnID, shard.ShardID,
+		batchID, version, seqNum)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, utils.StackError(err, "Failed to read vector party file of table %s shard %d batch %d column %d",
+			shard.Schema.Schema.Name, shard.ShardID, batchID, columnID)
+	}
+	return data, nil
+}
+
+// ValidateArchiveBatchDump checks that the dump belongs to the shard, its checksums match and
+// its columns exist in the schema.
+func (shard *TableShard) ValidateArchiveBatchDump(dump *ArchiveBatchDump) error {
+	if dump.Table != shard.Schema.Schema.Name || dump.Shard != shard.ShardID {
+		return utils.APIError{Code: http.StatusBadRequest, Message: "archive batch dump does not belong to the table shard"}
+	}
+	if dump.Size <= 0 {
+		return utils.APIError{Code: http.StatusBadRequest, Message: "archive batch dump has no rows"}
+	}
+
+	shard.Schema.RLock()
+	defer shard.Schema.RUnlock()
+	columnIDs := make(map[int]bool, len(dump.VectorParties))
+	for _, vp := range dump.VectorParties {
+		if vp.ColumnID < 0 || vp.ColumnID >= len(shard.Schema.Schema.Columns) {
+			return utils.APIError{Code: http.StatusBadRequest, Message: "unknown column in archive batch dump"}
+		}
+		column := shard.Schema.Schema.Columns[vp.ColumnID]
+		if column.Deleted || column.Name != vp.ColumnName {
+			return utils.APIError{Code: http.StatusBadRequest,
+				Message: "column " + vp.ColumnName + " of archive batch dump does not match the schema"}
+		}
+		if columnIDs[vp.ColumnID] {
+			return utils.APIError{Code: http.StatusBadRequest, Message: "duplicate column " + vp.ColumnName + " in archive batch dump"}
+		}
+		columnIDs[vp.ColumnID] = true
+		if vectorPartyChecksum(vp.Data) != vp.Checksum {
+			return utils.APIError{Code: http.StatusBadRequest, Message: "checksum mismatch of column " + vp.ColumnName}
+		}
+	}
+	return nil
+}
+
+// ImportArchiveBatch replaces the current version of the archive batch with the dump. Vector
+// party files are written as a new sequence of the batch version and only become visible once
+// all of them are written and the sequence is committed to metastore, so a failed import leaves
+// the batch untouched. The replaced batch version is purged afterwards. Must be run by the
+// scheduler to serialize with archiving, backfill and purge of the shard.
+func (shard *TableShard) ImportArchiveBatch(dump *ArchiveBatchDump) (err error) {
+	if err = shard.ValidateArchiveBatchDump(dump); err != nil {
+		return
+	}
+
+	table := shard.Schema.Schema.Name
+	oldVersion := shard.ArchiveStore.CurrentVersion
+	oldBatch := oldVersion.RequestBatch(int32(dump.BatchID))
+
+	batchVersion, seqNum := oldBatch.Version, oldBatch.SeqNum+1
+	if oldBatch.Size == 0 {
+		// restoring a missing batch as of current archiving cutoff.
+		if oldVersion.ArchivingCutoff == 0 {
+			return utils.APIError{Code: http.StatusBadRequest, Message: "table shard has not been archived yet"}
+		}
+		batchVersion, seqNum = oldVersion.ArchivingCutoff, 0
+	}
+
+	committed := false
+	defer func() {
+		if err != nil && !committed {
+			if deleteErr := shard.diskStore.DeleteBatchVersion(table, shard.ShardID, dump.BatchID,
+				batchVersion, seqNum); deleteErr != nil {
+				utils.GetLogger().With("table", table, "shard", shard.ShardID, "batchID", dump.BatchID,
+					"error", deleteErr.Error()).Error("Failed to delete uncommitted archive batch version")
+			}
+		}
+	}()
+
+	for _, vp := range dump.VectorParties {
+		if err = shard.writeArchiveVectorPartyFile(dump.BatchID, batchVersion, seqNum, vp); err != nil {
+			return
+		}
+	}
+
+	// commits the new batch version.
+	if err = shard.metaStore.AddArchiveBatchVersion(table, shard.ShardID, dump.BatchID, batchVersion, seqNum,
+		dump.Size); err != nil {
+		return
+	}
+	committed = true
+
+	newVersion := NewArchiveStoreVersion(oldVersion.ArchivingCutoff, shard)
+	oldVersion.RLock()
+	for batchID, batch := range oldVersion.Batches {
+		newVersion.Batches[batchID] = batch
+	}
+	oldVersion.RUnlock()
+	newVersion.Batches[int32(dump.BatchID)] = &ArchiveBatch{
+		Version: batchVersion,
+		SeqNum:  seqNum,
+		Size:    dump.Size,
+		BatchID: int32(dump.BatchID),
+		Shard:   shard,
+		Batch:   common.Batch{RWMutex: &sync.RWMutex{}},
+	}
+
+	shard.ArchiveStore.Lock()
+	shard.ArchiveStore.CurrentVersion = newVersion
+	shard.ArchiveStore.Unlock()
+
+	// Purge the replaced batch once all queries on the old version finish.
+	oldVersion.Users.Wait()
+	if oldBatch.Size != 0 && shard.options.bootstrapToken.AcquireToken(table, uint32(shard.ShardID)) {
+		deleteErr := shard.diskStore.DeleteBatchVersions(table, shard.ShardID, dump.BatchID,
+			oldBatch.Version, oldBatch.SeqNum)
+		shard.options.bootstrapToken.ReleaseToken(table, uint32(shard.ShardID))
+		if deleteErr != nil {
+			// the batch is already replaced.
+			utils.GetLogger().With("table", table, "shard", shard.ShardID, "batchID", dump.BatchID,
+				"error", deleteErr.Error()).Error("Failed to purge replaced archive batch version")
+		}
+	}
+	for columnID, vp := range oldBatch.Columns {
+		if vp != nil {
+			vp.SafeDestruct()
+			shard.HostMemoryManager.ReportManagedObject(table, shard.ShardID, dump.BatchID, columnID, 0)
+		}
+	}
+	return shard.updateDailyRowCounts(newVersion.ArchivingCutoff, map[int32]int{int32(dump.BatchID): dump.Size})
+}
+
+func (shard *TableShard) writeArchiveVectorPartyFile(batchID int, version, seqNum uint32, vp VectorPartyDump) error {
+	writer, err := shard.diskStore.OpenVectorPartyFileForWrite(shard.Schema.Schema.Name, vp.ColumnID, shard.ShardID,
+		batchID, version, seqNum)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(vp.Data)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return utils.StackError(err, "Failed to write vector party file of table %s shard %d batch %d column %d",
+			shard.Schema.Schema.Name, shard.ShardID, batchID, vp.ColumnID)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/diskstore"
+	"github.com/uber/aresdb/memstore/common"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	metaMocks "github.com/uber/aresdb/metastore/mocks"
+)
+
+var _ = ginkgo.Describe("archive batch repair", func() {
+	var rootPath string
+	var metaStore *metaMocks.MetaStore
+	var diskStore diskstore.DiskStore
+	var shard *TableShard
+
+	writeFile := func(columnID int, seqNum uint32, data string) {
+		writer, err := diskStore.OpenVectorPartyFileForWrite("abc", columnID, 0, 1, 100, seqNum)
+		Ω(err).Should(BeNil())
+		_, err = writer.Write([]byte(data))
+		Ω(err).Should(BeNil())
+		Ω(writer.Close()).Should(BeNil())
+	}
+
+	readFile := func(columnID int, seqNum uint32) (string, error) {
+		reader, err := diskStore.OpenVectorPartyFileForRead("abc", columnID, 0, 1, 100, seqNum)
+		if err != nil {
+			return "", err
+		}
+		defer reader.Close()
+		data, err := ioutil.ReadAll(reader)
+		return string(data), err
+	}
+
+	ginkgo.BeforeEach(func() {
+		var err error
+		rootPath, err = ioutil.TempDir("", "archive_batch_repair")
+		Ω(err).Should(BeNil())
+		metaStore = &metaMocks.MetaStore{}
+		diskStore = diskstore.NewLocalDiskStore(rootPath)
+		memStore := createMemStore("abc", 0, []common.DataType{common.Uint32, common.Uint8}, []int{0}, 10, true, false, metaStore, diskStore)
+		shard, _ = memStore.GetTableShard("abc", 0)
+		shard.Users.Done()
+		shard.ArchiveStore.CurrentVersion = NewArchiveStoreVersion(100, shard)
+
+		metaStore.On("GetArchiveBatchVersion", "abc", 0, 1, uint32(100)).Return(uint32(100), uint32(0), 3, nil)
+		metaStore.On("GetDailyRowCounts", "abc", 0).Return(metaCom.DailyRowCounts{}, nil)
+		writeFile(0, 0, "column0")
+		writeFile(1, 0, "column1")
+	})
+
+	ginkgo.AfterEach(func() {
+		os.RemoveAll(rootPath)
+	})
+
+	ginkgo.It("ExportArchiveBatch should dump vector party files with checksums", func() {
+		dump, err := shard.ExportArchiveBatch(1)
+		Ω(err).Should(BeNil())
+		Ω(dump.Table).Should(Equal("abc"))
+		Ω(dump.BatchID).Should(Equal(1))
+		Ω(dump.Version).Should(Equal(uint32(100)))
+		Ω(dump.Size).Should(Equal(3))
+		Ω(dump.VectorParties).Should(HaveLen(2))
+		Ω(string(dump.VectorParties[1].Data)).Should(Equal("column1"))
+		Ω(dump.VectorParties[1].Checksum).Should(Equal(vectorPartyChecksum([]byte("column1"))))
+		Ω(shard.ValidateArchiveBatchDump(dump)).Should(BeNil())
+
+		dump.VectorParties[1].Data = []byte("corrupted")
+		Ω(shard.ValidateArchiveBatchDump(dump)).ShouldNot(BeNil())
+
+		metaStore.On("GetArchiveBatchVersion", "abc", 0, 2, uint32(100)).Return(uint32(0), uint32(0), 0, nil)
+		_, err = shard.ExportArchiveBatch(2)
+		Ω(err).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("ImportArchiveBatch should replace the batch atomically", func() {
+		dump, err := shard.ExportArchiveBatch(1)
+		Ω(err).Should(BeNil())
+		dump.VectorParties[1].Data = []byte("repaired")
+		dump.VectorParties[1].Checksum = vectorPartyChecksum(dump.VectorParties[1].Data)
+
+		metaStore.On("AddArchiveBatchVersion", "abc", 0, 1, uint32(100), uint32(1), 3).Return(nil).Once()
+		Ω(shard.ImportArchiveBatch(dump)).Should(BeNil())
+
+		batch := shard.ArchiveStore.CurrentVersion.Batches[1]
+		Ω(batch.Version).Should(Equal(uint32(100)))
+		Ω(batch.SeqNum).Should(Equal(uint32(1)))
+		Ω(batch.Size).Should(Equal(3))
+		Ω(readFile(1, 1)).Should(Equal("repaired"))
+		_, err = readFile(1, 0)
+		Ω(err).Should(Equal(os.ErrNotExist))
+
+		// failing to commit leaves the batch untouched.
+		metaStore.On("AddArchiveBatchVersion", "abc", 0, 1, uint32(100), uint32(2), 3).
+			Return(errors.New("failed to write metastore")).Once()
+		Ω(shard.ImportArchiveBatch(dump)).ShouldNot(BeNil())
+		Ω(shard.ArchiveStore.CurrentVersion.Batches[1]).Should(BeIdenticalTo(batch))
+		_, err = readFile(1, 2)
+		Ω(err).Should(Equal(os.ErrNotExist))
+		Ω(readFile(1, 1)).Should(Equal("repaired"))
+	})
+})
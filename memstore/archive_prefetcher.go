@@ -0,0 +1,117 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"sync"
+
+	"github.com/uber/aresdb/utils"
+)
+
+// ArchivePrefetcher tracks the archive batch range queried recently on a fact table shard and
+// loads the batches adjacent to it into host memory in the background, so that follow up
+// queries scrolling back or forward in time do not wait for disk loads.
+type ArchivePrefetcher struct {
+	sync.Mutex
+	shard *TableShard
+	// archive batch range [start, end) of the last query, empty before the first query.
+	start, end int32
+	// at most one prefetch runs at a time for each shard.
+	running bool
+}
+
+// NewArchivePrefetcher creates a new ArchivePrefetcher for the table shard.
+func NewArchivePrefetcher(shard *TableShard) *ArchivePrefetcher {
+	return &ArchivePrefetcher{
+		shard: shard,
+	}
+}
+
+// nextBatches records the queried archive batch range [start, end) and returns the adjacent
+// batches to prefetch, nearest first. Batches after the range are returned when the range moves
+// forward in time, otherwise batches before the range are returned as users usually scroll back
+// in time. Caller needs to hold the lock.
+func (p *ArchivePrefetcher) nextBatches(start, end int32, numBatches int) []int32 {
+	forward := p.start < p.end && start >= p.start && end > p.end
+	p.start, p.end = start, end
+
+	batchIDs := make([]int32, 0, numBatches)
+	for i := int32(0); i < int32(numBatches); i++ {
+		if forward {
+			batchIDs = append(batchIDs, end+i)
+		} else if start-1-i >= 0 {
+			batchIDs = append(batchIDs, start-1-i)
+		}
+	}
+	return batchIDs
+}
+
+// Prefetch records the archive batch range [start, end) queried on the shard and asynchronously
+// loads the specified columns of numBatches adjacent batches into host memory. No prefetch is
+// started if the previous one of the shard is still running. Caller needs to hold the shard
+// (shard.Users) during the call.
+func (p *ArchivePrefetcher) Prefetch(start, end int32, columnIDs []int, numBatches int) {
+	if numBatches <= 0 || start >= end || len(columnIDs) == 0 {
+		return
+	}
+
+	p.Lock()
+	batchIDs := p.nextBatches(start, end, numBatches)
+	if p.running || len(batchIDs) == 0 {
+		p.Unlock()
+		return
+	}
+	p.running = true
+	p.Unlock()
+
+	// prevent the shard from being destructed while prefetching.
+	p.shard.Users.Add(1)
+	go p.load(batchIDs, columnIDs)
+}
+
+// load loads the columns of the batches from disk and releases them afterwards, leaving the
+// vector parties in host memory subject to eviction by the host memory manager.
+func (p *ArchivePrefetcher) load(batchIDs []int32, columnIDs []int) {
+	defer func() {
+		p.Lock()
+		p.running = false
+		p.Unlock()
+		p.shard.Users.Done()
+	}()
+
+	version := p.shard.ArchiveStore.GetCurrentVersion()
+	defer version.Users.Done()
+
+	// batches after the archiving cutoff have no archived records.
+	lastBatchID := int32(version.ArchivingCutoff / 86400)
+	numPrefetched := 0
+	for _, batchID := range batchIDs {
+		if batchID > lastBatchID {
+			continue
+		}
+		batch := version.RequestBatch(batchID)
+		if batch.Size == 0 {
+			continue
+		}
+		for _, columnID := range columnIDs {
+			vp := batch.RequestVectorParty(columnID)
+			vp.WaitForDiskLoad()
+			vp.Release()
+		}
+		numPrefetched++
+	}
+	utils.GetReporter(p.shard.Schema.Schema.Name, p.shard.ShardID).
+		GetCounter(utils.ArchiveBatchesPrefetched).Inc(int64(numPrefetched))
+}
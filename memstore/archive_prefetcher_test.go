@@ -0,0 +1,74 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"sync"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	memCom "github.com/uber/aresdb/memstore/common"
+	metaCom "github.com/uber/aresdb/metastore/common"
+)
+
+var _ = ginkgo.Describe("archive prefetcher", func() {
+	ginkgo.It("nextBatches should follow the direction of queries", func() {
+		prefetcher := NewArchivePrefetcher(nil)
+		// first query prefetches earlier batches.
+		Ω(prefetcher.nextBatches(10, 12, 2)).Should(Equal([]int32{9, 8}))
+		// scrolling back in time.
+		Ω(prefetcher.nextBatches(8, 10, 2)).Should(Equal([]int32{7, 6}))
+		// scrolling forward in time.
+		Ω(prefetcher.nextBatches(9, 11, 2)).Should(Equal([]int32{11, 12}))
+		// widening the range on both ends.
+		Ω(prefetcher.nextBatches(1, 13, 3)).Should(Equal([]int32{0}))
+	})
+
+	ginkgo.It("Prefetch should load adjacent batches", func() {
+		shard := &TableShard{
+			Schema: &memCom.TableSchema{
+				Schema: metaCom.Table{
+					Name: "table1",
+				},
+			},
+		}
+		shard.ArchivePrefetcher = NewArchivePrefetcher(shard)
+
+		version := NewArchiveStoreVersion(3*86400, shard)
+		shard.ArchiveStore = &ArchiveStore{CurrentVersion: version}
+
+		batch := &ArchiveBatch{
+			Batch:   memCom.Batch{RWMutex: &sync.RWMutex{}},
+			BatchID: 1,
+			Size:    10,
+			Shard:   shard,
+		}
+		vp := newArchiveVectorParty(batch.Size, memCom.Bool, memCom.NullDataValue, batch.RWMutex)
+		batch.Columns = []memCom.VectorParty{vp}
+		version.Batches[0] = &ArchiveBatch{Batch: memCom.Batch{RWMutex: &sync.RWMutex{}}, Shard: shard}
+		version.Batches[1] = batch
+
+		// prefetching is skipped while another prefetch is running.
+		shard.ArchivePrefetcher.running = true
+		shard.ArchivePrefetcher.Prefetch(2, 3, []int{0}, 2)
+		shard.Users.Wait()
+
+		shard.ArchivePrefetcher.running = false
+		shard.ArchivePrefetcher.Prefetch(2, 3, []int{0}, 2)
+		shard.Users.Wait()
+		Ω(shard.ArchivePrefetcher.running).Should(BeFalse())
+		Ω(vp.Pins).Should(Equal(0))
+	})
+})
@@ -16,6 +16,7 @@ package memstore
 
 import (
 	"encoding/json"
+	"fmt"
 	"sync"
 
 	"strconv"
@@ -42,6 +43,23 @@ type ArchiveBatch struct {
 	// For convenience.
 	BatchID int32
 	Shard   *TableShard
+
+	// Bitmap indexes of indexed enum columns, nil values for columns without index in this
+	// batch version. Protected by the batch lock.
+	enumIndexes map[int]*common.EnumBitmapIndex
+
+	// Zone maps of numeric columns loaded from disk on first request, nil if the batch version
+	// has no zone maps. Protected by the batch lock.
+	zoneMaps       map[int]*common.ZoneMap
+	zoneMapsLoaded bool
+
+	// IVF indexes of indexed vector columns, nil values for columns without index in this
+	// batch version. Protected by the batch lock.
+	vectorIndexes map[int]*common.IVFIndex
+
+	// Counts of values of columns listed by column values requests, computed on first request.
+	// Protected by the batch lock.
+	valueCounts map[int]ColumnValueCounts
 }
 
 // ArchiveStoreVersion stores a version of archive batches of columnar data.
@@ -151,17 +169,31 @@ func (v *ArchiveStoreVersion) RequestBatch(batchID int32) *ArchiveBatch {
 	return batch
 }
 
-// WriteToDisk writes each column of a batch to disk. It happens on archiving
-// stage for merged archive batch so there is no need to lock it.
+// WriteToDisk writes each column of a batch to disk encoded as the codec of the column.
+// It happens on archiving stage for merged archive batch so there is no need to lock it.
 func (b *ArchiveBatch) WriteToDisk() error {
+	b.Shard.Schema.RLock()
+	columns := b.Shard.Schema.Schema.Columns
+	b.Shard.Schema.RUnlock()
+
 	for columnID, column := range b.Columns {
 		serializer := common.NewVectorPartyArchiveSerializer(
 			b.Shard.HostMemoryManager, b.Shard.diskStore, b.Shard.Schema.Schema.Name, b.Shard.ShardID, columnID, int(b.BatchID), b.Version, b.SeqNum)
+		// honor the codec configured for the column.
+		if vp, ok := column.(*archiveVectorParty); ok && columnID < len(columns) {
+			column = codecArchiveVectorParty{vp, columns[columnID].Config.Codec}
+		}
 		if err := serializer.WriteVectorParty(column); err != nil {
 			return err
 		}
 	}
-	return nil
+	if err := b.writeEnumIndexes(); err != nil {
+		return err
+	}
+	if err := b.writeVectorIndexes(); err != nil {
+		return err
+	}
+	return b.writeZoneMaps()
 }
 
 // GetCurrentVersion returns current SortedVectorStoreVersion and does proper locking. It'v used by
@@ -174,6 +206,39 @@ func (s *ArchiveStore) GetCurrentVersion() *ArchiveStoreVersion {
 	return version
 }
 
+// ReloadArchiveStore reloads the archiving cutoff from metastore and switches to a new empty
+// archive store version, so that archive batches written to disk and metastore outside of
+// archiving (e.g. restored from a backup) become visible to queries.
+func (shard *TableShard) ReloadArchiveStore() error {
+	cutoff, err := shard.metaStore.GetArchivingCutoff(shard.Schema.Schema.Name, shard.ShardID)
+	if err != nil {
+		return err
+	}
+
+	shard.LiveStore.WriterLock.Lock()
+	shard.LiveStore.ArchivingCutoffHighWatermark = cutoff
+	shard.LiveStore.PrimaryKey.UpdateEventTimeCutoff(cutoff)
+	shard.LiveStore.WriterLock.Unlock()
+
+	shard.ArchiveStore.Lock()
+	oldVersion := shard.ArchiveStore.CurrentVersion
+	shard.ArchiveStore.CurrentVersion = NewArchiveStoreVersion(cutoff, shard)
+	shard.ArchiveStore.Unlock()
+
+	// Release vector parties loaded by the old version once all queries on it finish.
+	oldVersion.Users.Wait()
+	for _, batch := range oldVersion.Batches {
+		for columnID, vp := range batch.Columns {
+			if vp != nil {
+				vp.SafeDestruct()
+				shard.HostMemoryManager.ReportManagedObject(shard.Schema.Schema.Name, shard.ShardID,
+					int(batch.BatchID), columnID, 0)
+			}
+		}
+	}
+	return nil
+}
+
 // MarshalJSON marshals a ArchiveStore into json.
 func (s *ArchiveStore) MarshalJSON() ([]byte, error) {
 	currentVersion := s.GetCurrentVersion()
@@ -223,7 +288,10 @@ func (b *ArchiveBatch) RequestVectorParty(columnID int) common.ArchiveVectorPart
 	if common.IsArrayType(dataType) {
 		vp = list.NewArchiveVectorParty(b.Size, dataType, 0, b.RWMutex)
 	} else {
-		vp = newArchiveVectorParty(b.Size, dataType, *defaultValue, b.RWMutex)
+		archiveVP := newArchiveVectorParty(b.Size, dataType, *defaultValue, b.RWMutex)
+		archiveVP.enumCompactions = b.getEnumCompactions(columnID)
+		archiveVP.hllPrecision = b.Shard.Schema.GetReducedHLLPrecision(columnID)
+		vp = archiveVP
 	}
 	b.Columns[columnID] = vp
 
@@ -249,6 +317,37 @@ func (b *ArchiveBatch) BlockingDelete(columnID int) common.ArchiveVectorParty {
 	return b.evict(columnID, true)
 }
 
+// RewriteVectorParty loads the specified column and writes it back to disk with the current data
+// type and codec of the column, so that columns archived before their data type got promoted
+// no longer need to be converted on every load. The column is evicted again afterwards if it was
+// not loaded before.
+func (b *ArchiveBatch) RewriteVectorParty(columnID int) error {
+	b.RLock()
+	loaded := columnID < len(b.Columns) && b.Columns[columnID] != nil
+	b.RUnlock()
+
+	vp := b.RequestVectorParty(columnID)
+	vp.WaitForDiskLoad()
+
+	b.Shard.Schema.RLock()
+	codec := b.Shard.Schema.Schema.Columns[columnID].Config.Codec
+	b.Shard.Schema.RUnlock()
+
+	var column common.VectorParty = vp
+	if archiveVP, ok := vp.(*archiveVectorParty); ok {
+		column = codecArchiveVectorParty{archiveVP, codec}
+	}
+	serializer := common.NewVectorPartyArchiveSerializer(
+		b.Shard.HostMemoryManager, b.Shard.diskStore, b.Shard.Schema.Schema.Name, b.Shard.ShardID, columnID, int(b.BatchID), b.Version, b.SeqNum)
+	err := serializer.WriteVectorParty(column)
+	vp.Release()
+
+	if !loaded {
+		b.TryEvict(columnID)
+	}
+	return err
+}
+
 // evict attempts to evict and destruct the specified column from the archive
 // batch. It will block if the blocking is set to true, other wise it will fail
 // fast.
@@ -277,6 +376,38 @@ func (b *ArchiveBatch) evict(columnID int, blocking bool) common.ArchiveVectorPa
 	return vp
 }
 
+// TrySpill attempts to spill the specified column of the archive batch to a file under dir
+// mapped back into memory, after which the column no longer counts towards managed memory.
+// It fails fast if the column is currently in use. Returns whether the column got spilled.
+func (b *ArchiveBatch) TrySpill(columnID int, dir string) (bool, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	if columnID >= len(b.Columns) || b.Columns[columnID] == nil {
+		return false, nil
+	}
+
+	// list vector parties are not spilled.
+	vp, ok := b.Columns[columnID].(*archiveVectorParty)
+	if !ok || vp.IsSpilled() {
+		return false, nil
+	}
+
+	if !vp.WaitForUsers(false) {
+		return false, nil
+	}
+	// users pinning the vector party wait for disk load before release, so this returns immediately.
+	vp.WaitForDiskLoad()
+
+	prefix := fmt.Sprintf("%s_%d_%d_%d_%d_", b.Shard.Schema.Schema.Name, b.Shard.ShardID, b.BatchID, b.Version, columnID)
+	if err := vp.spill(dir, prefix); err != nil {
+		return false, err
+	}
+	b.Shard.HostMemoryManager.ReportManagedObject(
+		b.Shard.Schema.Schema.Name, b.Shard.ShardID, int(b.BatchID), columnID, 0)
+	return true, nil
+}
+
 // GetBatchForRead returns a archiveBatch for read,
 // reader needs to unlock after use
 func (v *ArchiveStoreVersion) GetBatchForRead(batchID int) *ArchiveBatch {
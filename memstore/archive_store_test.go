@@ -24,7 +24,10 @@ import (
 	memCom "github.com/uber/aresdb/memstore/common"
 	metaCom "github.com/uber/aresdb/metastore/common"
 	utilsMocks "github.com/uber/aresdb/utils/mocks"
+	"io/ioutil"
+	"os"
 	"sync"
+	"unsafe"
 )
 
 var _ = ginkgo.Describe("archive store", func() {
@@ -118,6 +121,67 @@ var _ = ginkgo.Describe("archive store", func() {
 		vp.Release()
 	})
 
+	ginkgo.It("TrySpill should work", func() {
+		spillDir, err := ioutil.TempDir("", "spill")
+		Ω(err).Should(BeNil())
+		defer os.RemoveAll(spillDir)
+
+		archiveBatch := &ArchiveBatch{
+			Batch: memCom.Batch{
+				RWMutex: &sync.RWMutex{},
+			},
+			Size:    10,
+			Version: cutoff,
+			BatchID: int32(batchID),
+			Shard: &TableShard{
+				ShardID:           shardID,
+				HostMemoryManager: hostMemoryManager,
+				Schema: &memCom.TableSchema{
+					Schema: metaCom.Table{
+						Name: table,
+					},
+				},
+			},
+		}
+
+		vp := newArchiveVectorParty(archiveBatch.Size, memCom.Uint32, memCom.NullDataValue, archiveBatch.RWMutex)
+		vp.Allocate(false)
+		for i := 0; i < archiveBatch.Size; i++ {
+			value := uint32(i)
+			vp.SetDataValue(i, memCom.DataValue{Valid: i%2 == 0, OtherVal: unsafe.Pointer(&value)}, memCom.IgnoreCount)
+		}
+		archiveBatch.Columns = []memCom.VectorParty{nil, vp}
+
+		// nothing to spill.
+		Ω(archiveBatch.TrySpill(0, spillDir)).Should(BeFalse())
+
+		// column in use.
+		vp.Pins = 1
+		Ω(archiveBatch.TrySpill(1, spillDir)).Should(BeFalse())
+		Ω(vp.IsSpilled()).Should(BeFalse())
+		vp.Pins = 0
+
+		Ω(archiveBatch.TrySpill(1, spillDir)).Should(BeTrue())
+		Ω(vp.IsSpilled()).Should(BeTrue())
+		for i := 0; i < archiveBatch.Size; i++ {
+			value := vp.GetDataValue(i)
+			Ω(value.Valid).Should(Equal(i%2 == 0))
+			if value.Valid {
+				Ω(*(*uint32)(value.OtherVal)).Should(Equal(uint32(i)))
+			}
+		}
+		// spill file is unlinked once mapped.
+		files, err := ioutil.ReadDir(spillDir)
+		Ω(err).Should(BeNil())
+		Ω(files).Should(BeEmpty())
+
+		// already spilled.
+		Ω(archiveBatch.TrySpill(1, spillDir)).Should(BeFalse())
+
+		vp.SafeDestruct()
+		Ω(vp.IsSpilled()).Should(BeFalse())
+	})
+
 	ginkgo.It("Clone ArchiveBatch should work", func() {
 		batch := &ArchiveBatch{
 			Version: 1,
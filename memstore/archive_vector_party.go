@@ -15,10 +15,17 @@
 package memstore
 
 import (
+	"github.com/uber/aresdb/cgoutils"
 	"github.com/uber/aresdb/diskstore"
 	"github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/memstore/vectors"
+	metaCom "github.com/uber/aresdb/metastore/common"
 	"github.com/uber/aresdb/utils"
+	"io"
+	"io/ioutil"
+	"os"
 	"sync"
+	"syscall"
 	"unsafe"
 )
 
@@ -26,6 +33,95 @@ import (
 type archiveVectorParty struct {
 	cVectorParty
 	common.Pinnable
+
+	// file mapping backing the vectors once spilled, nil if vectors are in memory.
+	mapping []byte
+	// compactions of the enum dictionary to remap enum ids of the batch version on load.
+	enumCompactions []metaCom.EnumDictCompaction
+	// precision to convert hll values of higher precisions to on load, 0 for no conversion.
+	hllPrecision int
+}
+
+// SafeDestruct destructs all vectors of this vector party and unmaps the spilled file if any.
+func (vp *archiveVectorParty) SafeDestruct() {
+	if vp != nil {
+		vp.cVectorParty.SafeDestruct()
+		if vp.mapping != nil {
+			if err := syscall.Munmap(vp.mapping); err != nil {
+				utils.GetLogger().With("error", err).Error("failed to unmap spilled vector party")
+			}
+			vp.mapping = nil
+		}
+	}
+}
+
+// IsSpilled tells whether the vectors are spilled to disk.
+func (vp *archiveVectorParty) IsSpilled() bool {
+	return vp.mapping != nil
+}
+
+// spill moves the vectors into a file under dir and maps the file back in place of the vectors,
+// so that pages are read from disk on access and can be reclaimed by OS under memory pressure.
+// The file is unlinked once mapped so nothing is left on disk after the vector party is destructed
+// or the process exits. Caller should hold the batch lock and make sure there are no users.
+func (vp *archiveVectorParty) spill(dir, prefix string) error {
+	if vp.mapping != nil {
+		return nil
+	}
+
+	vs := []*vectors.Vector{vp.values, vp.nulls, vp.counts}
+	// vectors are placed at page boundaries in the file.
+	pageSize := os.Getpagesize()
+	offsets := make([]int, len(vs))
+	size := 0
+	for i, v := range vs {
+		if v != nil {
+			offsets[i] = size
+			size += (v.Bytes + pageSize - 1) / pageSize * pageSize
+		}
+	}
+	// nothing to spill for all values default vector party.
+	if size == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return utils.StackError(err, "Failed to create spill dir %s", dir)
+	}
+	file, err := ioutil.TempFile(dir, prefix)
+	if err != nil {
+		return utils.StackError(err, "Failed to create spill file under %s", dir)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if err = file.Truncate(int64(size)); err != nil {
+		return utils.StackError(err, "Failed to allocate spill file %s", file.Name())
+	}
+	for i, v := range vs {
+		if v == nil {
+			continue
+		}
+		if _, err = file.WriteAt(cgoutils.MakeSliceFromCPtr(uintptr(v.Buffer()), v.Bytes), int64(offsets[i])); err != nil {
+			return utils.StackError(err, "Failed to write spill file %s", file.Name())
+		}
+	}
+
+	mapping, err := syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return utils.StackError(err, "Failed to map spill file %s", file.Name())
+	}
+
+	for i, v := range vs {
+		if v == nil {
+			continue
+		}
+		vs[i] = vectors.NewMappedVector(v.DataType, v.Size, unsafe.Pointer(&mapping[offsets[i]]))
+		v.SafeDestruct()
+	}
+	vp.values, vp.nulls, vp.counts = vs[0], vs[1], vs[2]
+	vp.mapping = mapping
+	return nil
 }
 
 // Prune judges column mode first and sets the mode to vector party.
@@ -86,16 +182,48 @@ func (vp *archiveVectorParty) CopyOnWrite(batchSize int) common.ArchiveVectorPar
 	return newVP
 }
 
+// Write writes the archive vector party to underlying writer with vectors encoded by the encoding
+// taking the least bytes on disk.
+func (vp *archiveVectorParty) Write(writer io.Writer) error {
+	return vp.write(writer, vp.chooseEncoding())
+}
+
+// codecArchiveVectorParty writes the archive vector party encoded as the codec configured for
+// the column.
+type codecArchiveVectorParty struct {
+	*archiveVectorParty
+	codec string
+}
+
+// Write writes the archive vector party to underlying writer with vectors encoded by the codec.
+func (vp codecArchiveVectorParty) Write(writer io.Writer) error {
+	return vp.write(writer, vp.encodingForCodec(vp.codec))
+}
+
 // LoadFromDisk load archive vector party from disk
 // caller should lock archive batch before using
 func (vp *archiveVectorParty) LoadFromDisk(hostMemManager common.HostMemoryManager, diskStore diskstore.DiskStore, table string, shardID int, columnID, batchID int, batchVersion uint32, seqNum uint32) {
 	vp.Loader.Add(1)
 	go func() {
 		serializer := common.NewVectorPartyArchiveSerializer(hostMemManager, diskStore, table, shardID, columnID, batchID, batchVersion, seqNum)
+		dataType := vp.dataType
 		err := serializer.ReadVectorParty(vp)
 		if err != nil {
 			utils.GetLogger().Panic(err)
 		}
+		// batch archived before the column was promoted to big enum and not rewritten yet.
+		if dataType == common.BigEnum && vp.dataType == common.SmallEnum {
+			vp.promoteEnum(vp.defaultValue)
+			serializer.ReportVectorPartyMemoryUsage(vp.GetBytes())
+		}
+		// batch archived before the enum dictionary got compacted.
+		if len(vp.enumCompactions) > 0 {
+			vp.remapEnum(vp.enumCompactions, vp.defaultValue)
+		}
+		// batch archived before the hll precision of the column got reduced and not recomputed yet.
+		if vp.hllPrecision > 0 {
+			vp.reduceHLLPrecision(vp.hllPrecision)
+		}
 		vp.Loader.Done()
 	}()
 }
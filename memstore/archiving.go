@@ -451,6 +451,15 @@ func (shard *TableShard) createNewArchiveStoreVersion(cutoff uint32, reporter Ar
 	}
 	oldVersion.RUnlock()
 
+	// row counts are updated before the cutoff so that counts of days before the cutoff are complete.
+	batchSizes := make(map[int32]int, len(patchByDay))
+	for day := range patchByDay {
+		batchSizes[day] = newVersion.Batches[day].Size
+	}
+	if err = shard.updateDailyRowCounts(cutoff, batchSizes); err != nil {
+		return
+	}
+
 	if err = shard.metaStore.UpdateArchivingCutoff(
 		shard.Schema.Schema.Name, shard.ShardID, cutoff); err != nil {
 		return
@@ -235,6 +235,8 @@ var _ = ginkgo.Describe("archiving", func() {
 
 		(m.diskStore).(*diskMocks.DiskStore).On(
 			"OpenVectorPartyFileForWrite", table, mock.Anything, shardID, day, mock.Anything, mock.Anything).Return(writer, nil)
+		(m.diskStore).(*diskMocks.DiskStore).On(
+			"OpenBatchZoneMapFileForWrite", table, shardID, day, mock.Anything, mock.Anything).Return(writer, nil)
 
 		redologManager := tableShard.LiveStore.RedoLogManager.(*redolog.FileRedoLogManager)
 		redologManager.CurrentFileCreationTime = 2
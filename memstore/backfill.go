@@ -188,6 +188,10 @@ func (shard *TableShard) createNewArchiveStoreVersionForBackfill(
 				newVersion.Batches[day].SeqNum, newVersion.Batches[day].Size); err != nil {
 				return
 			}
+			if err = shard.updateDailyRowCounts(oldVersion.ArchivingCutoff,
+				map[int32]int{day: newVersion.Batches[day].Size}); err != nil {
+				return
+			}
 		}
 
 		lockStart := utils.Now()
@@ -23,6 +23,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/DataDog/zstd"
 	m3Shard "github.com/m3db/m3/src/cluster/shard"
 	xerrors "github.com/m3db/m3/src/x/errors"
 	xretry "github.com/m3db/m3/src/x/retry"
@@ -31,6 +32,7 @@ import (
 	"github.com/uber/aresdb/datanode/bootstrap"
 	"github.com/uber/aresdb/datanode/client"
 	"github.com/uber/aresdb/datanode/generated/proto/rpc"
+	"github.com/uber/aresdb/redolog"
 	"github.com/uber/aresdb/utils"
 )
 
@@ -128,12 +130,13 @@ func (shard *TableShard) Bootstrap(
 	shard.BootstrapDetails.Clear()
 	shard.BootstrapDetails.SetNumColumns(numColumns)
 	success := false
+	recoveryDeferred := false
 	defer func() {
 		shard.bootstrapLock.Lock()
-		if success {
-			shard.BootstrapState = bootstrap.Bootstrapped
-		} else {
+		if !success {
 			shard.BootstrapState = bootstrap.BootstrapNotStarted
+		} else if !recoveryDeferred {
+			shard.BootstrapState = bootstrap.Bootstrapped
 		}
 		shard.bootstrapLock.Unlock()
 	}()
@@ -202,14 +205,57 @@ func (shard *TableShard) Bootstrap(
 		}
 	}
 
+	success = true
+	if recoveryDeferred = shard.deferRecovery(); recoveryDeferred {
+		shard.BootstrapDetails.SetBootstrapStage(bootstrap.Finished)
+		return nil
+	}
+
 	shard.BootstrapDetails.SetBootstrapStage(bootstrap.Recovery)
 	// start play redolog
 	shard.PlayRedoLog()
-	success = true
 	shard.BootstrapDetails.SetBootstrapStage(bootstrap.Finished)
 	return nil
 }
 
+// deferRecovery marks standby table shard as bootstrapped without replaying redolog, so that
+// ingestion is deferred until promoted.
+func (shard *TableShard) deferRecovery() bool {
+	shard.bootstrapLock.Lock()
+	defer shard.bootstrapLock.Unlock()
+	if !shard.standby {
+		return false
+	}
+	shard.BootstrapState = bootstrap.Bootstrapped
+	return true
+}
+
+// PromoteStandby starts ingestion of standby table shard by replaying its redolog. Standby table
+// shards still bootstrapping will replay redolog once their data is loaded.
+func (shard *TableShard) PromoteStandby() {
+	shard.bootstrapLock.Lock()
+	if !shard.standby {
+		shard.bootstrapLock.Unlock()
+		return
+	}
+	shard.standby = false
+	if shard.BootstrapState != bootstrap.Bootstrapped {
+		shard.bootstrapLock.Unlock()
+		return
+	}
+	// not ready for serving until redolog is replayed.
+	shard.BootstrapState = bootstrap.Bootstrapping
+	shard.bootstrapLock.Unlock()
+
+	shard.BootstrapDetails.SetBootstrapStage(bootstrap.Recovery)
+	shard.PlayRedoLog()
+	shard.BootstrapDetails.SetBootstrapStage(bootstrap.Finished)
+
+	shard.bootstrapLock.Lock()
+	shard.BootstrapState = bootstrap.Bootstrapped
+	shard.bootstrapLock.Unlock()
+}
+
 type vpRawDataRequest struct {
 	tableShardMeta *rpc.TableShardMetaData
 	batchMeta      *rpc.BatchMetaData
@@ -276,7 +322,7 @@ func (shard *TableShard) fetchDataFromPeer(
 				attempts := 0
 				err = retrier.Attempt(func() error {
 					attempts++
-					request, vpWriter, err := shard.createVectorPartyRawDataRequest(origin, sessionID, tableShardMeta, batchMeta, vpMeta)
+					request, vpWriter, err := shard.createVectorPartyRawDataRequest(origin, sessionID, tableShardMeta, batchMeta, vpMeta, options)
 					if err != nil {
 						utils.GetLogger().
 							With("peer", peerID, "table", shard.Schema.Schema.Name, "shard", shard.ShardID, "batch", batchMeta.GetBatchID(), "column", vpMeta.GetColumnID(), "request", request, "error", err.Error()).
@@ -337,9 +383,183 @@ func (shard *TableShard) fetchDataFromPeer(
 		return errors.FinalError()
 	}
 	utils.GetReporter(tableShardMeta.Table, int(tableShardMeta.Shard)).GetTimer(utils.TotalRawVPFetchTime).Record(utils.Now().Sub(fetchStart))
+
+	// 4. copy redologs not yet archived/snapshotted from peer until caught up
+	shard.BootstrapDetails.SetBootstrapStage(bootstrap.RedoLogCatchUp)
+	return shard.catchUpRedoLogFromPeer(peerID, origin, sessionID, client, tableShardMeta, options)
+}
+
+// catchUpRedoLogFromPeer copies redolog files from peer into local redolog files so that records not yet
+// archived (fact table) or snapshotted (dimension table) at peer will be recovered by PlayRedoLog.
+// Since peer keeps appending to its redologs while we are copying, we keep fetching upsert batches appended
+// after the last one we received, until a round returns no more than RedoLogCatchUpThreshold batches.
+// An error is returned if the table shard is still not caught up after MaxRedoLogCatchUpRounds, so that
+// it's not marked as bootstrapped with stale data and the bootstrap will be retried.
+func (shard *TableShard) catchUpRedoLogFromPeer(
+	peerID string,
+	origin string,
+	sessionID int64,
+	client rpc.PeerDataNodeClient,
+	tableShardMeta *rpc.TableShardMetaData,
+	options bootstrap.Options,
+) error {
+	if options.MaxRedoLogCatchUpRounds() <= 0 {
+		return nil
+	}
+
+	// purge stale redologs left by previous failed bootstrap attempts, the local table shard
+	// does not have valid data before peer copy finishes
+	redoFileIDs, err := shard.diskStore.ListLogFiles(shard.Schema.Schema.Name, shard.ShardID)
+	if err != nil {
+		return utils.StackError(err, "failed to list local redolog files")
+	}
+	for _, redoFileID := range redoFileIDs {
+		if err = shard.diskStore.DeleteLogFile(shard.Schema.Schema.Name, shard.ShardID, redoFileID); err != nil {
+			return utils.StackError(err, "failed to delete stale redolog file %d", redoFileID)
+		}
+	}
+
+	startFileID, startOffset := redoLogCatchUpStart(tableShardMeta)
+	writer := &redoLogWriter{shard: shard, startFileID: startFileID, startOffset: startOffset}
+	defer writer.close()
+
+	request := &rpc.RedoLogRequest{
+		Table:          shard.Schema.Schema.Name,
+		Shard:          uint32(shard.ShardID),
+		RedoFileID:     startFileID,
+		RedoFileOffset: startOffset,
+		SessionID:      sessionID,
+		NodeID:         origin,
+	}
+
+	for round := 0; round < options.MaxRedoLogCatchUpRounds(); round++ {
+		numBatches, err := shard.fetchRedoLogFromPeer(client, request, writer)
+		if err != nil {
+			return utils.StackError(err, "failed to fetch redolog from peer %s", peerID)
+		}
+		utils.GetLogger().
+			With("peer", peerID, "table", shard.Schema.Schema.Name, "shard", shard.ShardID, "round", round,
+				"redoFileID", writer.redoFileID, "redoFileOffset", writer.nextOffset).
+			Infof("fetched %d upsert batches from peer redolog", numBatches)
+		utils.GetReporter(shard.Schema.Schema.Name, shard.ShardID).GetCounter(utils.RedoLogBatchesFetched).Inc(int64(numBatches))
+
+		if numBatches <= options.RedoLogCatchUpThreshold() {
+			return nil
+		}
+		// continue from the upsert batch after the last one received
+		request.RedoFileID = writer.redoFileID
+		request.RedoFileOffset = writer.nextOffset
+	}
+	return utils.StackError(nil, "redolog not caught up with peer %s after %d rounds", peerID, options.MaxRedoLogCatchUpRounds())
+}
+
+// redoLogCatchUpStart returns the redolog file id and upsert batch offset to start fetching from peer.
+// Upsert batches up to the snapshot version of dimension tables are already in the snapshot copied.
+// Redologs of fact tables before the backfill checkpoint may still hold records after the archiving
+// cutoff, so they are fetched from the first redolog file kept by peer, which only keeps files not
+// fully covered by archiving and backfill and compacts covered upsert batches into empty ones.
+func redoLogCatchUpStart(tableShardMeta *rpc.TableShardMetaData) (int64, uint32) {
+	snapshotVersion := tableShardMeta.GetDimensionMeta().GetSnapshotVersion()
+	if snapshotVersion == nil || snapshotVersion.GetRedoFileID() == 0 {
+		return 0, 0
+	}
+	// snapshot version points to the last upsert batch in the snapshot
+	return snapshotVersion.GetRedoFileID(), snapshotVersion.GetRedoFileOffset() + 1
+}
+
+// fetchRedoLogFromPeer fetches upsert batches starting from the requested redolog file and offset
+// and writes them to local redolog files, returns number of upsert batches fetched
+func (shard *TableShard) fetchRedoLogFromPeer(
+	client rpc.PeerDataNodeClient,
+	request *rpc.RedoLogRequest,
+	writer *redoLogWriter,
+) (int, error) {
+	stream, err := client.FetchRedoLog(context.Background(), request)
+	if err != nil {
+		return 0, err
+	}
+
+	numBatches := 0
+	for {
+		batch, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return numBatches, err
+		}
+		if err = writer.write(batch); err != nil {
+			return numBatches, err
+		}
+		numBatches++
+	}
+	return numBatches, nil
+}
+
+// redoLogWriter writes upsert batches fetched from peer into local redolog files with the same
+// file id and upsert batch offset as the peer. Upsert batches before the start offset of the first
+// redolog file fetched are written as empty ones to keep the offsets.
+type redoLogWriter struct {
+	shard       *TableShard
+	file        io.WriteCloser
+	writer      *redolog.RecordWriter
+	redoFileID  int64
+	nextOffset  uint32
+	startFileID int64
+	startOffset uint32
+}
+
+func (w *redoLogWriter) write(batch *rpc.RedoLogUpsertBatch) error {
+	if w.file == nil || batch.RedoFileID != w.redoFileID {
+		if err := w.close(); err != nil {
+			return err
+		}
+		skipped := uint32(0)
+		if batch.RedoFileID == w.startFileID {
+			skipped = w.startOffset
+		}
+		if batch.RedoFileOffset != skipped {
+			return utils.StackError(nil, "redolog file %d does not start from upsert batch %d", batch.RedoFileID, skipped)
+		}
+
+		file, err := w.shard.diskStore.OpenLogFileForAppend(w.shard.Schema.Schema.Name, w.shard.ShardID, batch.RedoFileID)
+		if err != nil {
+			return err
+		}
+		w.file = file
+		w.redoFileID = batch.RedoFileID
+		w.nextOffset = 0
+		if w.writer, err = redolog.NewRecordWriter(file); err != nil {
+			return err
+		}
+		for ; w.nextOffset < skipped; w.nextOffset++ {
+			if err = w.writer.Write(nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	if batch.RedoFileOffset != w.nextOffset {
+		return utils.StackError(nil, "expect upsert batch offset %d in redolog file %d, got %d",
+			w.nextOffset, w.redoFileID, batch.RedoFileOffset)
+	}
+
+	if err := w.writer.Write(batch.UpsertBatch); err != nil {
+		return err
+	}
+	w.nextOffset++
 	return nil
 }
 
+func (w *redoLogWriter) close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
 func (shard *TableShard) fetchBatchMetaDataFromPeer(origin string, sessionID int64, client rpc.PeerDataNodeClient) (*rpc.TableShardMetaData, error) {
 	var (
 		endBatchID   int32 = math.MaxInt32
@@ -373,6 +593,7 @@ func (shard *TableShard) createVectorPartyRawDataRequest(
 	tableMeta *rpc.TableShardMetaData,
 	batchMeta *rpc.BatchMetaData,
 	vpMeta *rpc.VectorPartyMetaData,
+	options bootstrap.Options,
 ) (rawVPDataRequest *rpc.VectorPartyRawDataRequest, vpWriter io.WriteCloser, err error) {
 	if shard.Schema.Schema.IsFactTable {
 		// fact table archive vp writer
@@ -420,6 +641,7 @@ func (shard *TableShard) createVectorPartyRawDataRequest(
 			ColumnID: vpMeta.GetColumnID(),
 		}
 	}
+	rawVPDataRequest.CompressionLevel = int32(options.TransferCompressionLevel())
 	return
 }
 
@@ -433,22 +655,46 @@ func (shard *TableShard) fetchVectorPartyRawDataFromPeer(
 		return 0, err
 	}
 
-	totalBytes := 0
-	for {
-		data, err := stream.Recv()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return totalBytes, err
-		}
-		bytesWritten, err := vpWriter.Write(data.Chunk)
+	data, err := stream.Recv()
+	if err == io.EOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	streamReader := &rawDataStreamReader{stream: stream, chunk: data.Chunk, bytesReceived: len(data.Chunk)}
+	var reader io.Reader = streamReader
+	// peers not supporting compression send uncompressed data regardless of the requested level.
+	if data.Compressed {
+		decompressor := zstd.NewReader(streamReader)
+		defer decompressor.Close()
+		reader = decompressor
+	}
+	_, err = io.Copy(vpWriter, reader)
+	return streamReader.bytesReceived, err
+}
+
+// rawDataStreamReader reads vector party raw data chunks received from peer.
+type rawDataStreamReader struct {
+	stream rpc.PeerDataNode_FetchVectorPartyRawDataClient
+	// received data not read yet
+	chunk         []byte
+	bytesReceived int
+}
+
+func (r *rawDataStreamReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		data, err := r.stream.Recv()
 		if err != nil {
-			return totalBytes, err
+			return 0, err
 		}
-		totalBytes += bytesWritten
+		r.chunk = data.Chunk
+		r.bytesReceived += len(data.Chunk)
 	}
-	return totalBytes, nil
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
 }
 
 func (shard *TableShard) setBatchMetadata(tableShardMeta *rpc.TableShardMetaData, batchMeta *rpc.BatchMetaData) error {
@@ -18,6 +18,7 @@ import (
 	"io"
 	"time"
 
+	"github.com/DataDog/zstd"
 	"github.com/golang/mock/gomock"
 	m3Shard "github.com/m3db/m3/src/cluster/shard"
 	"github.com/onsi/ginkgo"
@@ -105,6 +106,10 @@ var _ = ginkgo.Describe("table shard bootstrap", func() {
 		mockKeepAliveStream.On("CloseSend", mock.Anything).Return(nil)
 		mockPeerDataNodeClient.On("KeepAlive", mock.Anything).Return(mockKeepAliveStream, nil)
 
+		mockFetchRedoLogStream := &rpcMocks.PeerDataNode_FetchRedoLogClient{}
+		mockFetchRedoLogStream.On("Recv").Return(nil, io.EOF)
+		mockPeerDataNodeClient.On("FetchRedoLog", mock.Anything, mock.Anything).Return(mockFetchRedoLogStream, nil)
+
 		ginkgo.It("bootstrap should work for fact table", func() {
 			table := "test_fact"
 			shardID := 0
@@ -134,7 +139,7 @@ var _ = ginkgo.Describe("table shard bootstrap", func() {
 			metaStore.On("GetArchiveBatchVersion", table, shardID, batchID, uint32(archivingCutoff)).Return(uint32(archivingCutoff), uint32(backfillSeq), batchSize, nil)
 			metaStore.On("UpdateBackfillProgress", table, shardID, int64(redoFileID), uint32(redoFileOffset)).Return(nil).Once()
 			metaStore.On("GetBackfillProgressInfo", table, shardID).Return(int64(redoFileID), uint32(redoFileOffset), nil).Once()
-			diskStore.On("ListLogFiles", table, shardID).Return([]int64{}, nil).Once()
+			diskStore.On("ListLogFiles", table, shardID).Return([]int64{}, nil).Twice()
 
 			tableSchema := memCom.NewTableSchema(
 				&metaCom.Table{
@@ -293,7 +298,7 @@ var _ = ginkgo.Describe("table shard bootstrap", func() {
 			metaStore.On("GetSnapshotProgress", table, shardID).Return(int64(redoFileID), uint32(redoFileOffset), int32(lastReadBatchID), uint32(lastBatchSize), nil).Once()
 			diskStore.On("ListSnapshotBatches", table, shardID, int64(redoFileID), uint32(redoFileOffset)).Return([]int{lastReadBatchID}, nil)
 			diskStore.On("ListSnapshotVectorPartyFiles", table, shardID, int64(redoFileID), uint32(redoFileOffset), lastReadBatchID).Return([]int{0, 1, 2}, nil).Once()
-			diskStore.On("ListLogFiles", table, shardID).Return([]int64{}, nil).Once()
+			diskStore.On("ListLogFiles", table, shardID).Return([]int64{}, nil).Twice()
 
 			column0MockBuffer := &testingUtils.TestReadWriteCloser{}
 			column1MockBuffer := &testingUtils.TestReadWriteCloser{}
@@ -417,4 +422,167 @@ var _ = ginkgo.Describe("table shard bootstrap", func() {
 			Ω(b2.Bytes()).Should(Equal(vp2BufferUnsorted.Bytes()))
 		})
 	})
+
+	ginkgo.It("standby table shard should defer redolog replay until promoted", func() {
+		shard := &TableShard{standby: true, BootstrapState: bootstrap.Bootstrapping}
+		Ω(shard.deferRecovery()).Should(BeTrue())
+		Ω(shard.IsBootstrapped()).Should(BeTrue())
+
+		// redolog is replayed by bootstrap if promoted while bootstrapping.
+		shard = &TableShard{standby: true, BootstrapState: bootstrap.Bootstrapping}
+		shard.PromoteStandby()
+		Ω(shard.standby).Should(BeFalse())
+		Ω(shard.deferRecovery()).Should(BeFalse())
+		Ω(shard.BootstrapState).Should(Equal(bootstrap.Bootstrapping))
+
+		// no-op for table shards not in standby.
+		shard = &TableShard{BootstrapState: bootstrap.Bootstrapped}
+		shard.PromoteStandby()
+		Ω(shard.IsBootstrapped()).Should(BeTrue())
+	})
+
+	ginkgo.It("catchUpRedoLogFromPeer should copy redolog from peer until caught up", func() {
+		table := "test_redolog_catch_up"
+		shardID := 0
+		redoLogDiskStore := &diskMocks.DiskStore{}
+		shard := &TableShard{
+			Schema:    memCom.NewTableSchema(&metaCom.Table{Name: table, IsFactTable: true}),
+			ShardID:   shardID,
+			diskStore: redoLogDiskStore,
+		}
+
+		file1 := &testingUtils.TestReadWriteCloser{}
+		file2 := &testingUtils.TestReadWriteCloser{}
+		redoLogDiskStore.On("ListLogFiles", table, shardID).Return([]int64{1}, nil).Once()
+		redoLogDiskStore.On("DeleteLogFile", table, shardID, int64(1)).Return(nil).Once()
+		redoLogDiskStore.On("OpenLogFileForAppend", table, shardID, int64(1)).Return(file1, nil).Once()
+		redoLogDiskStore.On("OpenLogFileForAppend", table, shardID, int64(2)).Return(file2, nil).Once()
+
+		// first round returns 2 batches, second round returns 1 batch which is under threshold
+		round1 := &rpcMocks.PeerDataNode_FetchRedoLogClient{}
+		round1.On("Recv").Return(&rpc.RedoLogUpsertBatch{RedoFileID: 1, RedoFileOffset: 0, UpsertBatch: []byte{1, 2}}, nil).Once()
+		round1.On("Recv").Return(&rpc.RedoLogUpsertBatch{RedoFileID: 1, RedoFileOffset: 1, UpsertBatch: []byte{3}}, nil).Once()
+		round1.On("Recv").Return(nil, io.EOF).Once()
+		round2 := &rpcMocks.PeerDataNode_FetchRedoLogClient{}
+		round2.On("Recv").Return(&rpc.RedoLogUpsertBatch{RedoFileID: 2, RedoFileOffset: 0, UpsertBatch: []byte{4}}, nil).Once()
+		round2.On("Recv").Return(nil, io.EOF).Once()
+
+		peerClient := &rpcMocks.PeerDataNodeClient{}
+		peerClient.On("FetchRedoLog", mock.Anything, mock.MatchedBy(func(req *rpc.RedoLogRequest) bool {
+			return req.RedoFileID == 0 && req.RedoFileOffset == 0
+		})).Return(round1, nil).Once()
+		peerClient.On("FetchRedoLog", mock.Anything, mock.MatchedBy(func(req *rpc.RedoLogRequest) bool {
+			return req.RedoFileID == 1 && req.RedoFileOffset == 2
+		})).Return(round2, nil).Once()
+
+		// redologs of fact tables are fetched from the first file kept by peer
+		tableShardMeta := &rpc.TableShardMetaData{
+			Meta: &rpc.TableShardMetaData_FactMeta{FactMeta: &rpc.FactTableShardMetaData{
+				BackfillCheckpoint: &rpc.BackfillCheckpoint{RedoFileID: 1, RedoFileOffset: 1},
+			}},
+		}
+		catchUpOptions := bootstrap.NewOptions().SetRedoLogCatchUpThreshold(1)
+		err := shard.catchUpRedoLogFromPeer("instance1", "instance0", 1, peerClient, tableShardMeta, catchUpOptions)
+		Ω(err).Should(BeNil())
+		peerClient.AssertExpectations(utils.TestingT)
+
+		// magic header, then size, crc32 checksum and upsert batch of each record.
+		Ω(file1.Bytes()).Should(Equal([]byte{
+			0xec, 0xfe, 0xda, 0xad,
+			2, 0, 0, 0, 0x92, 0x42, 0xcc, 0xb6, 1, 2,
+			1, 0, 0, 0, 0x37, 0xbe, 0x0b, 0x4b, 3,
+		}))
+		Ω(file2.Bytes()).Should(Equal([]byte{
+			0xec, 0xfe, 0xda, 0xad,
+			1, 0, 0, 0, 0x94, 0x2b, 0x6f, 0xd5, 4,
+		}))
+	})
+
+	ginkgo.It("catchUpRedoLogFromPeer should start from snapshot version and fail if not caught up", func() {
+		table := "test_redolog_catch_up_dimension"
+		shardID := 0
+		redoLogDiskStore := &diskMocks.DiskStore{}
+		shard := &TableShard{
+			Schema:    memCom.NewTableSchema(&metaCom.Table{Name: table}),
+			ShardID:   shardID,
+			diskStore: redoLogDiskStore,
+		}
+
+		file := &testingUtils.TestReadWriteCloser{}
+		redoLogDiskStore.On("ListLogFiles", table, shardID).Return([]int64{}, nil).Once()
+		redoLogDiskStore.On("OpenLogFileForAppend", table, shardID, int64(1)).Return(file, nil).Once()
+
+		// peer keeps appending more batches than the threshold
+		round1 := &rpcMocks.PeerDataNode_FetchRedoLogClient{}
+		round1.On("Recv").Return(&rpc.RedoLogUpsertBatch{RedoFileID: 1, RedoFileOffset: 2, UpsertBatch: []byte{1}}, nil).Once()
+		round1.On("Recv").Return(nil, io.EOF).Once()
+		round2 := &rpcMocks.PeerDataNode_FetchRedoLogClient{}
+		round2.On("Recv").Return(&rpc.RedoLogUpsertBatch{RedoFileID: 1, RedoFileOffset: 3, UpsertBatch: []byte{2}}, nil).Once()
+		round2.On("Recv").Return(nil, io.EOF).Once()
+
+		peerClient := &rpcMocks.PeerDataNodeClient{}
+		peerClient.On("FetchRedoLog", mock.Anything, mock.MatchedBy(func(req *rpc.RedoLogRequest) bool {
+			return req.RedoFileID == 1 && req.RedoFileOffset == 2
+		})).Return(round1, nil).Once()
+		peerClient.On("FetchRedoLog", mock.Anything, mock.MatchedBy(func(req *rpc.RedoLogRequest) bool {
+			return req.RedoFileID == 1 && req.RedoFileOffset == 3
+		})).Return(round2, nil).Once()
+
+		// upsert batches up to offset 1 of file 1 are in the snapshot
+		tableShardMeta := &rpc.TableShardMetaData{
+			Meta: &rpc.TableShardMetaData_DimensionMeta{DimensionMeta: &rpc.DimensionTableShardMetaData{
+				SnapshotVersion: &rpc.SnapshotVersion{RedoFileID: 1, RedoFileOffset: 1},
+			}},
+		}
+		catchUpOptions := bootstrap.NewOptions().SetRedoLogCatchUpThreshold(0).SetMaxRedoLogCatchUpRounds(2)
+		err := shard.catchUpRedoLogFromPeer("instance1", "instance0", 1, peerClient, tableShardMeta, catchUpOptions)
+		Ω(err).ShouldNot(BeNil())
+		peerClient.AssertExpectations(utils.TestingT)
+
+		// upsert batches in the snapshot are written as empty ones to keep the offsets.
+		Ω(file.Bytes()).Should(Equal([]byte{
+			0xec, 0xfe, 0xda, 0xad,
+			0, 0, 0, 0, 0, 0, 0, 0,
+			0, 0, 0, 0, 0, 0, 0, 0,
+			1, 0, 0, 0, 0x1b, 0xdf, 0x05, 0xa5, 1,
+			1, 0, 0, 0, 0xa1, 0x8e, 0x0c, 0x3c, 2,
+		}))
+	})
+
+	ginkgo.It("fetchVectorPartyRawDataFromPeer should decompress compressed raw data", func() {
+		data := bytes.Repeat([]byte{1, 2, 3, 4}, 10000)
+		compressed := &bytes.Buffer{}
+		compressor := zstd.NewWriterLevel(compressed, 3)
+		_, err := compressor.Write(data)
+		Ω(err).Should(BeNil())
+		Ω(compressor.Close()).Should(BeNil())
+		compressedBytes := compressed.Bytes()
+		half := len(compressedBytes) / 2
+
+		request := &rpc.VectorPartyRawDataRequest{CompressionLevel: 3}
+		mockStream := &rpcMocks.PeerDataNode_FetchVectorPartyRawDataClient{}
+		mockStream.On("Recv").Return(&rpc.VectorPartyRawData{Chunk: compressedBytes[:half], Compressed: true}, nil).Once()
+		mockStream.On("Recv").Return(&rpc.VectorPartyRawData{Chunk: compressedBytes[half:], Compressed: true}, nil).Once()
+		mockStream.On("Recv").Return(nil, io.EOF).Once()
+		mockClient := &rpcMocks.PeerDataNodeClient{}
+		mockClient.On("FetchVectorPartyRawData", mock.Anything, request).Return(mockStream, nil).Once()
+
+		vpWriter := &utils.ClosableBuffer{Buffer: &bytes.Buffer{}}
+		bytesFetched, err := (&TableShard{}).fetchVectorPartyRawDataFromPeer(mockClient, vpWriter, request)
+		Ω(err).Should(BeNil())
+		Ω(bytesFetched).Should(Equal(len(compressedBytes)))
+		Ω(vpWriter.Bytes()).Should(Equal(data))
+
+		// peers not supporting compression send uncompressed data.
+		mockStream = &rpcMocks.PeerDataNode_FetchVectorPartyRawDataClient{}
+		mockStream.On("Recv").Return(&rpc.VectorPartyRawData{Chunk: data}, nil).Once()
+		mockStream.On("Recv").Return(nil, io.EOF).Once()
+		mockClient.On("FetchVectorPartyRawData", mock.Anything, request).Return(mockStream, nil).Once()
+
+		vpWriter = &utils.ClosableBuffer{Buffer: &bytes.Buffer{}}
+		bytesFetched, err = (&TableShard{}).fetchVectorPartyRawDataFromPeer(mockClient, vpWriter, request)
+		Ω(err).Should(BeNil())
+		Ω(bytesFetched).Should(Equal(len(data)))
+		Ω(vpWriter.Bytes()).Should(Equal(data))
+	})
 })
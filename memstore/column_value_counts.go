@@ -0,0 +1,159 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	// max number of distinct values of a column counted in a shard, counting values of high
+	// cardinality columns would take too much memory and is not useful for listing values.
+	maxColumnValueCounts = 1 << 16
+)
+
+// ColumnValueCounts maps values of a column to the number of rows holding them. Values are of
+// the types returned by DataValue.ConvertToHumanReadable, i.e. enum ids for enum columns, and
+// nil for nulls.
+type ColumnValueCounts map[interface{}]int64
+
+// Merge adds counts of other into counts.
+func (counts ColumnValueCounts) Merge(other ColumnValueCounts) {
+	for value, count := range other {
+		counts[value] += count
+	}
+}
+
+// CountColumnValues counts rows of the shard by values of the column without running a query.
+// For fact tables only archive batches of days in [fromDay, toDay) and live rows with event
+// time in that range are counted, the range is ignored for dimension tables. Counts of each
+// archive batch version are computed once from its enum bitmap index, or by scanning the batch
+// if it has no index, and kept with the batch so following requests only scan live batches.
+func (shard *TableShard) CountColumnValues(columnID int, fromDay, toDay int32) (ColumnValueCounts, error) {
+	shard.Schema.RLock()
+	isFactTable := shard.Schema.Schema.IsFactTable
+	dataType := shard.Schema.ValueTypeByColumn[columnID]
+	defaultValue := *shard.Schema.DefaultValues[columnID]
+	shard.Schema.RUnlock()
+
+	counts := make(ColumnValueCounts)
+	var cutoff uint32
+	if isFactTable {
+		archiveStore := shard.ArchiveStore.GetCurrentVersion()
+		cutoff = archiveStore.ArchivingCutoff
+		var batches []*ArchiveBatch
+		archiveStore.RLock()
+		for batchID, batch := range archiveStore.Batches {
+			if batchID >= fromDay && batchID < toDay {
+				batches = append(batches, batch)
+			}
+		}
+		archiveStore.RUnlock()
+
+		for _, batch := range batches {
+			batchCounts, err := batch.requestValueCounts(columnID, dataType)
+			if err != nil {
+				archiveStore.Users.Done()
+				return nil, err
+			}
+			counts.Merge(batchCounts)
+		}
+		archiveStore.Users.Done()
+	}
+
+	// live rows before the cutoff are already counted in archive batches.
+	fromTime := int64(fromDay) * 86400
+	if int64(cutoff) > fromTime {
+		fromTime = int64(cutoff)
+	}
+	toTime := int64(toDay) * 86400
+	batchIDs, numRecordsInLastBatch := shard.LiveStore.GetBatchIDs()
+	for i, batchID := range batchIDs {
+		batch := shard.LiveStore.GetBatchForRead(batchID)
+		if batch == nil {
+			continue
+		}
+		size := batch.Capacity
+		if i == len(batchIDs)-1 {
+			size = numRecordsInLastBatch
+		}
+		for row := 0; row < size; row++ {
+			if isFactTable {
+				eventTime := batch.GetDataValue(row, 0)
+				if !eventTime.Valid || int64(*(*uint32)(eventTime.OtherVal)) < fromTime ||
+					int64(*(*uint32)(eventTime.OtherVal)) >= toTime {
+					continue
+				}
+			}
+			counts[batch.GetDataValueWithDefault(row, columnID, defaultValue).ConvertToHumanReadable(dataType)]++
+		}
+		batch.RUnlock()
+		if len(counts) > maxColumnValueCounts {
+			return nil, utils.StackError(nil, "column %d of table %s has more than %d distinct values",
+				columnID, shard.Schema.Schema.Name, maxColumnValueCounts)
+		}
+	}
+	return counts, nil
+}
+
+// requestValueCounts returns counts of values of the column in this batch version, computing
+// them on first request.
+func (b *ArchiveBatch) requestValueCounts(columnID int, dataType common.DataType) (ColumnValueCounts, error) {
+	b.RLock()
+	counts, ok := b.valueCounts[columnID]
+	b.RUnlock()
+	if ok {
+		return counts, nil
+	}
+
+	if index := b.RequestEnumIndex(columnID); index != nil {
+		counts = make(ColumnValueCounts)
+		var numNonNulls int64
+		for value, count := range index.Counts() {
+			if dataType == common.SmallEnum {
+				counts[uint8(value)] = int64(count)
+			} else {
+				counts[uint16(value)] = int64(count)
+			}
+			numNonNulls += int64(count)
+		}
+		if numNulls := int64(b.Size) - numNonNulls; numNulls > 0 {
+			counts[nil] = numNulls
+		}
+	} else {
+		vp := b.RequestVectorParty(columnID)
+		vp.WaitForDiskLoad()
+		counts = make(ColumnValueCounts)
+		b.ForEachValue(columnID, func(row int, value common.DataValue) {
+			if len(counts) <= maxColumnValueCounts {
+				counts[value.ConvertToHumanReadable(dataType)]++
+			}
+		})
+		vp.Release()
+		if len(counts) > maxColumnValueCounts {
+			return nil, utils.StackError(nil, "column %d of batch %d of table %s shard %d has more than %d distinct values",
+				columnID, b.BatchID, b.Shard.Schema.Schema.Name, b.Shard.ShardID, maxColumnValueCounts)
+		}
+	}
+
+	b.Lock()
+	if b.valueCounts == nil {
+		b.valueCounts = make(map[int]ColumnValueCounts)
+	}
+	b.valueCounts[columnID] = counts
+	b.Unlock()
+	return counts, nil
+}
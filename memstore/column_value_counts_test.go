@@ -0,0 +1,92 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"sync"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/memstore/tests"
+)
+
+var _ = ginkgo.Describe("column value counts", func() {
+	ingest := func(memStore *memStoreImpl, times []uint32, values []interface{}) {
+		builder := common.NewUpsertBatchBuilder()
+		builder.AddColumn(0, common.Uint32)
+		builder.AddColumn(1, common.Uint8)
+		for row, eventTime := range times {
+			builder.AddRow()
+			builder.SetValue(row, 0, eventTime)
+			builder.SetValue(row, 1, values[row])
+		}
+		buffer, _ := builder.ToByteArray()
+		upsertBatch, _ := common.NewUpsertBatch(buffer)
+		Ω(memStore.HandleIngestion("abc", 0, upsertBatch)).Should(BeNil())
+	}
+
+	ginkgo.It("should count values of archive batches in range and live rows after cutoff", func() {
+		memStore := createMemStore("abc", 0, []common.DataType{common.Uint32, common.Uint8}, []int{0}, 10, true, false, nil, CreateMockDiskStore())
+		shard, _ := memStore.GetTableShard("abc", 0)
+		shard.Users.Done()
+
+		ingest(memStore, []uint32{86400*2 + 1, 86400*3 + 1, 86400*3 + 2, 86400*3 + 3, 86400 * 5},
+			[]interface{}{uint8(1), uint8(1), uint8(3), nil, uint8(1)})
+		// live rows before the cutoff are already archived.
+		shard.ArchiveStore.CurrentVersion = NewArchiveStoreVersion(86400*3, shard)
+		for _, batchID := range []int32{1, 2} {
+			lock := &sync.RWMutex{}
+			vp, err := toVectorParty(&tests.RawVectorParty{
+				DataType: "Uint8", Length: 3,
+				Values: []string{"1", "2", "null"},
+			}, false)
+			Ω(err).Should(BeNil())
+			shard.ArchiveStore.CurrentVersion.Batches[batchID] = &ArchiveBatch{
+				Batch: common.Batch{
+					RWMutex: lock,
+					Columns: []common.VectorParty{nil, toArchiveVectorParty(vp, lock)},
+				},
+				Size:    3,
+				BatchID: batchID,
+				Shard:   shard,
+			}
+		}
+
+		counts, err := shard.CountColumnValues(1, 2, 5)
+		Ω(err).Should(BeNil())
+		Ω(counts).Should(Equal(ColumnValueCounts{uint8(1): 2, uint8(2): 1, uint8(3): 1, nil: 2}))
+		// counts of archive batches are kept.
+		Ω(shard.ArchiveStore.CurrentVersion.Batches[2].valueCounts).Should(Equal(map[int]ColumnValueCounts{
+			1: {uint8(1): 1, uint8(2): 1, nil: 1},
+		}))
+		Ω(shard.ArchiveStore.CurrentVersion.Batches[1].valueCounts).Should(BeNil())
+
+		counts, err = shard.CountColumnValues(1, 0, 6)
+		Ω(err).Should(BeNil())
+		Ω(counts).Should(Equal(ColumnValueCounts{uint8(1): 4, uint8(2): 2, uint8(3): 1, nil: 3}))
+	})
+
+	ginkgo.It("should count all rows of dimension tables", func() {
+		memStore := createMemStore("abc", 0, []common.DataType{common.Uint32, common.Uint8}, []int{0}, 10, false, false, nil, CreateMockDiskStore())
+		shard, _ := memStore.GetTableShard("abc", 0)
+		shard.Users.Done()
+		ingest(memStore, []uint32{1, 2, 86400 * 5}, []interface{}{uint8(1), uint8(1), uint8(2)})
+
+		counts, err := shard.CountColumnValues(1, 0, 1)
+		Ω(err).Should(BeNil())
+		Ω(counts).Should(Equal(ColumnValueCounts{uint8(1): 2, uint8(2): 1}))
+	})
+})
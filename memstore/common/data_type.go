@@ -25,6 +25,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 	"unsafe"
 
 	metaCom "github.com/uber/aresdb/metastore/common"
@@ -56,6 +57,10 @@ const (
 	GeoPoint  DataType = 0x000b0040
 	GeoShape  DataType = 0x000c0000
 	Int64     DataType = 0x000d0040
+	// String values are stored as uint32 ids of the dictionary of each batch.
+	String DataType = 0x000e0020
+	// Decimal values are stored as int64 scaled by 10^DecimalScale of the column.
+	Decimal DataType = 0x000f0040
 
 	// array types
 	ArrayBool      DataType = 0x01000001
@@ -90,6 +95,8 @@ var DataTypeName = map[DataType]string{
 	GeoPoint:  metaCom.GeoPoint,
 	GeoShape:  metaCom.GeoShape,
 	Int64:     metaCom.Int64,
+	String:    metaCom.String,
+	Decimal:   metaCom.Decimal,
 
 	// array types
 	ArrayBool:      metaCom.ArrayBool,
@@ -123,6 +130,8 @@ var StringToDataType = map[string]DataType{
 	metaCom.GeoPoint:  GeoPoint,
 	metaCom.GeoShape:  GeoShape,
 	metaCom.Int64:     Int64,
+	metaCom.String:    String,
+	metaCom.Decimal:   Decimal,
 
 	// array types
 	metaCom.ArrayBool:      ArrayBool,
@@ -138,6 +147,22 @@ var StringToDataType = map[string]DataType{
 	metaCom.ArrayUUID:      ArrayUUID,
 	metaCom.ArrayGeoPoint:  ArrayGeoPoint,
 	metaCom.ArrayInt64:     ArrayInt64,
+
+	// map types are stored as arrays of values indexed by key ids.
+	metaCom.MapInt8:    ArrayInt8,
+	metaCom.MapUint8:   ArrayUint8,
+	metaCom.MapInt16:   ArrayInt16,
+	metaCom.MapUint16:  ArrayUint16,
+	metaCom.MapInt32:   ArrayInt32,
+	metaCom.MapUint32:  ArrayUint32,
+	metaCom.MapFloat32: ArrayFloat32,
+	metaCom.MapInt64:   ArrayInt64,
+
+	// vector types are stored as arrays of fixed length.
+	metaCom.VectorFloat32: ArrayFloat32,
+
+	// Time64 values are stored as int64 milliseconds since epoch.
+	metaCom.Time64: Int64,
 }
 
 // NewDataType converts an uint32 value into a DataType. It returns error if the the data type is
@@ -159,6 +184,8 @@ func NewDataType(value uint32) (DataType, error) {
 	case UUID:
 	case GeoPoint:
 	case GeoShape:
+	case String:
+	case Decimal:
 	case ArrayBool:
 	case ArrayInt8:
 	case ArrayUint8:
@@ -180,7 +207,7 @@ func NewDataType(value uint32) (DataType, error) {
 
 // IsNumeric determines whether a data type is numeric
 func IsNumeric(dataType DataType) bool {
-	return (dataType >= Int8 && dataType <= Float32) || dataType == Int64
+	return (dataType >= Int8 && dataType <= Float32) || dataType == Int64 || dataType == Decimal
 }
 
 // IsArrayType determins where a data type is Array
@@ -220,8 +247,9 @@ func DataTypeBytes(dataType DataType) int {
 	return (DataTypeBits(dataType) + 7) / 8
 }
 
-// ConvertValueForType converts data value based on data type
-func ConvertValueForType(dataType DataType, value interface{}) (interface{}, error) {
+// ConvertValueForType converts data value based on data type, Decimal values are scaled by
+// 10^decimalScale, which is ignored for other data types.
+func ConvertValueForType(dataType DataType, value interface{}, decimalScale int) (interface{}, error) {
 	ok := false
 	var out interface{}
 	switch dataType {
@@ -245,6 +273,8 @@ func ConvertValueForType(dataType DataType, value interface{}) (interface{}, err
 		out, ok = ConvertToInt32(value)
 	case Int64:
 		out, ok = ConvertToInt64(value)
+	case Decimal:
+		out, ok = ConvertToDecimal(value, decimalScale)
 	case Float32:
 		out, ok = ConvertToFloat32(value)
 	case UUID:
@@ -253,6 +283,8 @@ func ConvertValueForType(dataType DataType, value interface{}) (interface{}, err
 		out, ok = ConvertToGeoPoint(value)
 	case GeoShape:
 		out, ok = ConvertToGeoShape(value)
+	case String:
+		out, ok = ConvertToString(value)
 	default:
 		if IsArrayType(dataType) {
 			return ConvertToArrayValue(dataType, value)
@@ -392,6 +424,17 @@ func ConvertToInt64(value interface{}) (int64, bool) {
 	return 0, false
 }
 
+// ConvertToTime64 converts input into milliseconds since epoch at best effort, numbers are
+// taken as milliseconds and strings are parsed either as milliseconds or as RFC3339 time.
+func ConvertToTime64(value interface{}) (int64, bool) {
+	if v, ok := value.(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t.UnixNano() / int64(time.Millisecond), true
+		}
+	}
+	return ConvertToInt64(value)
+}
+
 // ConvertToFloat64 convert input into float64 at best effort
 func ConvertToFloat64(value interface{}) (float64, bool) {
 	switch v := value.(type) {
@@ -511,29 +554,70 @@ func GeoShapeFromString(str string) (GeoShapeGo, error) {
 	return shape, nil
 }
 
-// ConvertToGeoShape converts the arbitrary value to GeoShapeGo
+// ConvertToGeoShape converts the arbitrary value to GeoShapeGo, shapes are
+// repaired or rejected later when written to the live store, see GeoShapeGo.Repair
 func ConvertToGeoShape(value interface{}) (*GeoShapeGo, bool) {
+	var shape GeoShapeGo
+	var err error
 	switch v := value.(type) {
 	case string:
-		shape, err := GeoShapeFromString(v)
+		if IsGeoJSON(v) {
+			shape, err = GeoShapeFromGeoJSON(v)
+		} else {
+			shape, err = GeoShapeFromString(v)
+		}
+	case map[string]interface{}:
+		// geojson object decoded from json input
+		var bs []byte
+		bs, err = json.Marshal(v)
 		if err == nil {
-			return &shape, true
+			shape, err = GeoShapeFromGeoJSON(string(bs))
 		}
 	case []byte:
-		shape := GeoShapeGo{}
 		dataReader := utils.NewStreamDataReader(bytes.NewReader(v))
-		err := shape.Read(&dataReader)
-		if err == nil {
-			return &shape, true
+		err = shape.Read(&dataReader)
+	case GeoShapeGo:
+		shape = v
+	case *GeoShapeGo:
+		if v == nil {
+			return nil, false
 		}
+		shape = *v
+	default:
+		return nil, false
+	}
+
+	if err != nil {
+		return nil, false
+	}
+	return &shape, true
+}
+
+// ConvertToString converts the arbitrary value to StringGo,
+// non string scalars are formatted with their default format
+func ConvertToString(value interface{}) (*StringGo, bool) {
+	var str StringGo
+	switch v := value.(type) {
+	case string:
+		str = StringGo(v)
+	case []byte:
+		str = StringGo(v)
+	case StringGo:
+		str = v
+	case *StringGo:
+		return v, v != nil
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		str = StringGo(fmt.Sprint(v))
+	default:
+		return nil, false
 	}
-	return nil, false
+	return &str, true
 }
 
-// IsGoType determines whether a data type is golang type
+// IsGoType determines whether a data type is golang type. String values are golang
+// values in upsert batches but are stored as dictionary ids in vector parties.
 func IsGoType(dataType DataType) bool {
-	// for now we only have GeoShape
-	return dataType == GeoShape
+	return dataType == GeoShape || dataType == String
 }
 
 // IsEnumType determines whether a data type is enum type
@@ -546,6 +630,8 @@ func GetGoDataValue(dataType DataType) GoDataValue {
 	switch dataType {
 	case GeoShape:
 		return &GeoShapeGo{}
+	case String:
+		return new(StringGo)
 	}
 	return nil
 }
@@ -572,7 +658,7 @@ func ArrayValueFromArray(value []interface{}, dataType DataType) (interface{}, e
 				arrValue.AddItem(nil)
 				continue
 			}
-			val, err := ConvertValueForType(dataType, item)
+			val, err := ConvertValueForType(dataType, item, 0)
 			if err != nil {
 				return nil, err
 			}
@@ -291,6 +291,23 @@ var _ = ginkgo.Describe("data_type", func() {
 		Ω(ok).Should(BeFalse())
 	})
 
+	ginkgo.It("ConvertToTime64", func() {
+		v, ok := ConvertToTime64("2019-01-02T03:04:05.678Z")
+		Ω(ok).Should(BeTrue())
+		Ω(v).Should(Equal(int64(1546398245678)))
+
+		v, ok = ConvertToTime64("1546398245678")
+		Ω(ok).Should(BeTrue())
+		Ω(v).Should(Equal(int64(1546398245678)))
+
+		v, ok = ConvertToTime64(int64(1546398245678))
+		Ω(ok).Should(BeTrue())
+		Ω(v).Should(Equal(int64(1546398245678)))
+
+		_, ok = ConvertToTime64("unknown")
+		Ω(ok).Should(BeFalse())
+	})
+
 	ginkgo.It("ConvertToInt32", func() {
 		v, ok := ConvertToInt32("1")
 		Ω(ok).Should(BeTrue())
@@ -480,6 +497,55 @@ var _ = ginkgo.Describe("data_type", func() {
 		Ω(shape).Should(Equal(expectedShape))
 	})
 
+	ginkgo.It("ConvertToGeoShape from geojson", func() {
+		// clockwise exterior ring will be rewound
+		geoJSON := `{"type":"Polygon","coordinates":[[[0,0],[0,1],[1,1],[1,0],[0,0]]]}`
+		expectedShape := &GeoShapeGo{
+			Polygons: [][]GeoPointGo{
+				{
+					{0, 0},
+					{0, 1},
+					{1, 1},
+					{1, 0},
+					{0, 0},
+				},
+			},
+		}
+		shape, ok := ConvertToGeoShape(geoJSON)
+		Ω(ok).Should(BeTrue())
+		Ω(shape).Should(Equal(expectedShape))
+
+		feature := map[string]interface{}{
+			"type": "Feature",
+			"geometry": map[string]interface{}{
+				"type": "MultiPolygon",
+				"coordinates": []interface{}{
+					[]interface{}{
+						[]interface{}{
+							[]interface{}{0, 0}, []interface{}{1, 0}, []interface{}{1, 1}, []interface{}{0, 1}, []interface{}{0, 0},
+						},
+					},
+					[]interface{}{
+						[]interface{}{
+							[]interface{}{2, 2, 100}, []interface{}{3, 2, 100}, []interface{}{3, 3, 100}, []interface{}{2, 2, 100},
+						},
+					},
+				},
+			},
+		}
+		shape, ok = ConvertToGeoShape(feature)
+		Ω(ok).Should(BeTrue())
+		Ω(shape.Polygons).Should(HaveLen(2))
+		Ω(shape.Polygons[1]).Should(Equal([]GeoPointGo{{2, 2}, {2, 3}, {3, 3}, {2, 2}}))
+
+		_, ok = ConvertToGeoShape(`{"type":"Point","coordinates":[0,0]}`)
+		Ω(ok).Should(BeFalse())
+		_, ok = ConvertToGeoShape(`{"type":"Polygon","coordinates":[[[0,0],[181,1],[1,1],[0,0]]]}`)
+		Ω(ok).Should(BeFalse())
+		_, ok = ConvertToGeoShape(`{"type":"Polygon","coordinates":[[[0,0],[0,1],[1,1],[1,0]]]}`)
+		Ω(ok).Should(BeFalse())
+	})
+
 	ginkgo.It("ConvertToArray", func() {
 		//int8
 		v, err := ConvertToArrayValue(ArrayInt8, "[\"11\",\"12\",\"13\"]")
@@ -175,7 +175,7 @@ func GetCompareFunc(dataType DataType) CompareFunc {
 		return CompareInt32
 	case Uint32:
 		return CompareUint32
-	case Int64:
+	case Int64, Decimal:
 		return CompareInt64
 	case Float32:
 		return CompareFloat32
@@ -217,8 +217,14 @@ type GeoPointGo [2]float32
 // GeoShapeGo represents GeoShape Golang Type
 type GeoShapeGo struct {
 	Polygons [][]GeoPointGo
+	// Covering stores ids of s2 cells covering the shape, computed by ComputeCovering when the
+	// shape is stored in memstore. It is not serialized.
+	Covering []uint64
 }
 
+// StringGo represents String Golang Type
+type StringGo string
+
 // Array value representation in Go for UpsertBatch
 type ArrayValue struct {
 	// item data type
@@ -267,7 +273,7 @@ func (v1 DataValue) ConvertToHumanReadable(dataType DataType) interface{} {
 		return *(*int32)(v1.OtherVal)
 	case Uint32:
 		return *(*uint32)(v1.OtherVal)
-	case Int64:
+	case Int64, Decimal:
 		return *(*int64)(v1.OtherVal)
 	case Float32:
 		return *(*float32)(v1.OtherVal)
@@ -301,6 +307,10 @@ func (v1 DataValue) ConvertToHumanReadable(dataType DataType) interface{} {
 			}
 			return fmt.Sprintf("Polygon(%s)", strings.Join(polygons, ","))
 		}
+	case String:
+		if str, ok := (v1.GoVal).(*StringGo); ok {
+			return string(*str)
+		}
 	default:
 		if IsArrayType(dataType) {
 			reader := NewArrayValueReader(dataType, v1.OtherVal)
@@ -423,7 +433,7 @@ func ValueFromString(str string, dataType DataType) (val DataValue, err error) {
 		val.Valid = true
 		val.OtherVal = unsafe.Pointer(&ui32)
 		return
-	case Int64:
+	case Int64, Decimal:
 		i, err = strconv.ParseInt(str, 10, 64)
 		if err != nil {
 			err = utils.StackError(err, "")
@@ -465,6 +475,11 @@ func ValueFromString(str string, dataType DataType) (val DataValue, err error) {
 		val.Valid = true
 		val.OtherVal = unsafe.Pointer(&point[0])
 		return
+	case String:
+		strVal := StringGo(str)
+		val.Valid = true
+		val.GoVal = &strVal
+		return
 	default:
 		if IsArrayType(dataType) {
 			var value interface{}
@@ -499,7 +514,7 @@ func (gs *GeoShapeGo) GetBytes() int {
 		numPoints := len(polygon)
 		numBytes += numPoints * int(SizeOfGeoPoint)
 	}
-	return numBytes
+	return numBytes + len(gs.Covering)*8
 }
 
 // GetSerBytes implements GoDataValue interface
@@ -575,6 +590,43 @@ func (gs *GeoShapeGo) Write(dataWriter *utils.StreamDataWriter) error {
 	return dataWriter.WritePadding(int(dataWriter.GetBytesWritten()), 4)
 }
 
+// GetBytes implements GoDataValue interface
+func (s *StringGo) GetBytes() int {
+	return len(*s)
+}
+
+// GetSerBytes implements GoDataValue interface
+func (s *StringGo) GetSerBytes() int {
+	// length (uint32) followed by the string bytes padded to 4 bytes
+	return 4 + utils.AlignOffset(len(*s), 4)
+}
+
+// Read implements Read interface for GoDataValue
+func (s *StringGo) Read(dataReader *utils.StreamDataReader) error {
+	length, err := dataReader.ReadUint32()
+	if err != nil {
+		return err
+	}
+	bs := make([]byte, length)
+	if err = dataReader.Read(bs); err != nil {
+		return err
+	}
+	*s = StringGo(bs)
+	return dataReader.ReadPadding(int(dataReader.GetBytesRead()), 4)
+}
+
+// Write implements Write interface for GoDataValue
+func (s *StringGo) Write(dataWriter *utils.StreamDataWriter) error {
+	err := dataWriter.WriteUint32(uint32(len(*s)))
+	if err != nil {
+		return err
+	}
+	if err = dataWriter.Write([]byte(*s)); err != nil {
+		return err
+	}
+	return dataWriter.WritePadding(int(dataWriter.GetBytesWritten()), 4)
+}
+
 // GetLength return item numbers for the array value
 func (av *ArrayValue) GetLength() int {
 	return len(av.Items)
@@ -661,7 +713,7 @@ func (av *ArrayValue) Write(writer *utils.BufferWriter) error {
 			} else {
 				err = writer.AppendFloat32(val.(float32))
 			}
-		case Int64:
+		case Int64, Decimal:
 			if val == nil {
 				err = writer.AppendInt64(0)
 			} else {
@@ -889,7 +941,7 @@ func GetDataValue(col interface{}, columnIDInSchema int, columnType string) (Dat
 		}
 		val.Valid = true
 		val.OtherVal = unsafe.Pointer(&ui32)
-	case Int64:
+	case Int64, Decimal:
 		var i64 int64
 		if i64, ok = col.(int64); !ok {
 			t := reflect.TypeOf(i64)
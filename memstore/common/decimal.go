@@ -0,0 +1,135 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"github.com/uber/aresdb/utils"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// MaxDecimalScale is the max number of fractional digits of a Decimal column,
+// scaled values of larger scales do not fit into int64.
+const MaxDecimalScale = 18
+
+// pow10 returns 10^scale as int64.
+func pow10(scale int) int64 {
+	factor := int64(1)
+	for i := 0; i < scale; i++ {
+		factor *= 10
+	}
+	return factor
+}
+
+// ParseDecimal parses a decimal string like "-12.34" into an int64 scaled by 10^scale.
+// Parsing is exact, it returns error if the string has more fractional digits than the
+// scale or the scaled value overflows int64.
+func ParseDecimal(str string, scale int) (int64, error) {
+	if scale < 0 || scale > MaxDecimalScale {
+		return 0, utils.StackError(nil, "Invalid decimal scale %d", scale)
+	}
+
+	str = strings.TrimSpace(str)
+	negative := strings.HasPrefix(str, "-")
+	digits := strings.TrimPrefix(str, "+")
+	if negative {
+		digits = str[1:]
+	}
+	intPart, fracPart := digits, ""
+	if dot := strings.IndexByte(digits, '.'); dot >= 0 {
+		intPart, fracPart = digits[:dot], digits[dot+1:]
+	}
+
+	if intPart == "" && fracPart == "" {
+		return 0, utils.StackError(nil, "Invalid decimal value %s", str)
+	}
+
+	if len(fracPart) > scale {
+		// trailing zeros do not lose precision.
+		if strings.TrimRight(fracPart[scale:], "0") != "" {
+			return 0, utils.StackError(nil, "Too many fractional digits in %s for decimal scale %d", str, scale)
+		}
+		fracPart = fracPart[:scale]
+	}
+	fracPart += strings.Repeat("0", scale-len(fracPart))
+	if intPart == "" {
+		intPart = "0"
+	}
+
+	value, err := strconv.ParseUint(intPart+fracPart, 10, 64)
+	if err != nil {
+		return 0, utils.StackError(err, "Invalid decimal value %s", str)
+	}
+	if negative {
+		if value > uint64(math.MaxInt64)+1 {
+			return 0, utils.StackError(nil, "Decimal value %s overflows for scale %d", str, scale)
+		}
+		return int64(-value), nil
+	}
+	if value > math.MaxInt64 {
+		return 0, utils.StackError(nil, "Decimal value %s overflows for scale %d", str, scale)
+	}
+	return int64(value), nil
+}
+
+// FormatDecimal formats an int64 scaled by 10^scale as a decimal string with exactly
+// scale fractional digits.
+func FormatDecimal(value int64, scale int) string {
+	if scale <= 0 {
+		return strconv.FormatInt(value, 10)
+	}
+
+	var sign string
+	abs := uint64(value)
+	if value < 0 {
+		sign = "-"
+		abs = uint64(-value)
+	}
+	digits := strconv.FormatUint(abs, 10)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+// ConvertToDecimal converts input into an int64 scaled by 10^scale at best effort.
+// Strings are parsed exactly, floats are rounded to the nearest scaled value.
+func ConvertToDecimal(value interface{}, scale int) (int64, bool) {
+	if scale < 0 || scale > MaxDecimalScale {
+		return 0, false
+	}
+
+	switch v := value.(type) {
+	case string:
+		num, err := ParseDecimal(v, scale)
+		return num, err == nil
+	case float32, float64:
+		num := reflect.ValueOf(value).Convert(reflect.TypeOf(float64(0))).Float() * math.Pow10(scale)
+		if math.IsNaN(num) || num >= math.MaxInt64 || num < math.MinInt64 {
+			return 0, false
+		}
+		return int64(math.Round(num)), true
+	case int, uint, int8, uint8, int16, uint16, int32, uint32, int64, uint64:
+		num, _ := ConvertToInt64(v)
+		factor := pow10(scale)
+		if num > math.MaxInt64/factor || num < math.MinInt64/factor {
+			return 0, false
+		}
+		return num * factor, true
+	}
+	return 0, false
+}
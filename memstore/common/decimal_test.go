@@ -0,0 +1,132 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"math"
+)
+
+var _ = ginkgo.Describe("decimal", func() {
+
+	ginkgo.It("parses decimal strings exactly", func() {
+		value, err := ParseDecimal("12.34", 2)
+		Ω(err).Should(BeNil())
+		Ω(value).Should(Equal(int64(1234)))
+
+		value, err = ParseDecimal("-0.5", 3)
+		Ω(err).Should(BeNil())
+		Ω(value).Should(Equal(int64(-500)))
+
+		value, err = ParseDecimal("+7", 2)
+		Ω(err).Should(BeNil())
+		Ω(value).Should(Equal(int64(700)))
+
+		value, err = ParseDecimal(".25", 2)
+		Ω(err).Should(BeNil())
+		Ω(value).Should(Equal(int64(25)))
+
+		value, err = ParseDecimal("1.2300", 2)
+		Ω(err).Should(BeNil())
+		Ω(value).Should(Equal(int64(123)))
+
+		value, err = ParseDecimal("-9223372036854775808", 0)
+		Ω(err).Should(BeNil())
+		Ω(value).Should(Equal(int64(math.MinInt64)))
+
+		_, err = ParseDecimal("1.234", 2)
+		Ω(err).ShouldNot(BeNil())
+
+		_, err = ParseDecimal("92233720368547758.08", 2)
+		Ω(err).ShouldNot(BeNil())
+
+		_, err = ParseDecimal("abc", 2)
+		Ω(err).ShouldNot(BeNil())
+
+		_, err = ParseDecimal("-", 2)
+		Ω(err).ShouldNot(BeNil())
+
+		_, err = ParseDecimal("1", 19)
+		Ω(err).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("formats scaled values", func() {
+		Ω(FormatDecimal(1234, 2)).Should(Equal("12.34"))
+		Ω(FormatDecimal(-5, 3)).Should(Equal("-0.005"))
+		Ω(FormatDecimal(0, 2)).Should(Equal("0.00"))
+		Ω(FormatDecimal(42, 0)).Should(Equal("42"))
+		Ω(FormatDecimal(math.MinInt64, 2)).Should(Equal("-92233720368547758.08"))
+	})
+
+	ginkgo.It("converts values to decimals", func() {
+		value, ok := ConvertToDecimal("1.5", 2)
+		Ω(ok).Should(BeTrue())
+		Ω(value).Should(Equal(int64(150)))
+
+		value, ok = ConvertToDecimal(0.125, 2)
+		Ω(ok).Should(BeTrue())
+		Ω(value).Should(Equal(int64(13)))
+
+		value, ok = ConvertToDecimal(float32(2.25), 1)
+		Ω(ok).Should(BeTrue())
+		Ω(value).Should(Equal(int64(23)))
+
+		value, ok = ConvertToDecimal(int32(-3), 4)
+		Ω(ok).Should(BeTrue())
+		Ω(value).Should(Equal(int64(-30000)))
+
+		_, ok = ConvertToDecimal(int64(math.MaxInt64), 1)
+		Ω(ok).Should(BeFalse())
+
+		_, ok = ConvertToDecimal(math.NaN(), 2)
+		Ω(ok).Should(BeFalse())
+
+		_, ok = ConvertToDecimal(true, 2)
+		Ω(ok).Should(BeFalse())
+	})
+
+	ginkgo.It("stores decimals as int64", func() {
+		Ω(DataTypeBytes(Decimal)).Should(Equal(8))
+		Ω(IsNumeric(Decimal)).Should(BeTrue())
+		Ω(DataTypeFromString("Decimal")).Should(Equal(Decimal))
+
+		value, err := ValueFromString("1234", Decimal)
+		Ω(err).Should(BeNil())
+		Ω(*(*int64)(value.OtherVal)).Should(Equal(int64(1234)))
+	})
+
+	ginkgo.It("scales decimal values on conversion", func() {
+		value, err := ConvertValueForType(Decimal, "12.34", 2)
+		Ω(err).Should(BeNil())
+		Ω(value).Should(Equal(int64(1234)))
+		value, err = ConvertValueForType(Decimal, 12, 2)
+		Ω(err).Should(BeNil())
+		Ω(value).Should(Equal(int64(1200)))
+		_, err = ConvertValueForType(Decimal, "1.234", 2)
+		Ω(err).ShouldNot(BeNil())
+		// scale is ignored by other data types.
+		value, err = ConvertValueForType(Int64, 12, 2)
+		Ω(err).Should(BeNil())
+		Ω(value).Should(Equal(int64(12)))
+
+		builder := NewUpsertBatchBuilder()
+		Ω(builder.AddDecimalColumn(1, MaxDecimalScale+1, UpdateOverwriteNotNull)).ShouldNot(BeNil())
+		Ω(builder.AddDecimalColumn(1, 2, UpdateOverwriteNotNull)).Should(BeNil())
+		builder.AddRow()
+		Ω(builder.SetValue(0, 0, 1.5)).Should(BeNil())
+		Ω(builder.columns[0].values[0]).Should(Equal(int64(150)))
+	})
+})
@@ -0,0 +1,111 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/binary"
+	"io"
+	"sort"
+
+	"github.com/uber/aresdb/utils"
+)
+
+// EnumBitmapIndex maps each enum value of a column in an archive batch to the bitmap of
+// rows holding the value, each bitmap takes one bit per row up to the last row holding the
+// value. Null rows are not indexed.
+type EnumBitmapIndex struct {
+	bitmaps map[uint32]*utils.Bitset
+}
+
+// NewEnumBitmapIndex creates an empty EnumBitmapIndex.
+func NewEnumBitmapIndex() *EnumBitmapIndex {
+	return &EnumBitmapIndex{
+		bitmaps: make(map[uint32]*utils.Bitset),
+	}
+}
+
+// Add adds the row to the bitmap of the enum value.
+func (idx *EnumBitmapIndex) Add(value, row uint32) {
+	bitmap, ok := idx.bitmaps[value]
+	if !ok {
+		bitmap = utils.NewBitset()
+		idx.bitmaps[value] = bitmap
+	}
+	bitmap.Add(row)
+}
+
+// Rows returns the union of rows holding any of the enum values.
+func (idx *EnumBitmapIndex) Rows(values ...uint32) *utils.Bitset {
+	rows := utils.NewBitset()
+	for _, value := range values {
+		if bitmap, ok := idx.bitmaps[value]; ok {
+			rows = rows.Or(bitmap)
+		}
+	}
+	return rows
+}
+
+// Counts returns the number of rows holding each enum value.
+func (idx *EnumBitmapIndex) Counts() map[uint32]int {
+	counts := make(map[uint32]int, len(idx.bitmaps))
+	for value, bitmap := range idx.bitmaps {
+		counts[value] = bitmap.Cardinality()
+	}
+	return counts
+}
+
+// Write serializes the index to the writer as the number of enum values followed by each
+// enum value and its bitmap in ascending order of enum values.
+func (idx *EnumBitmapIndex) Write(writer io.Writer) error {
+	values := make([]uint32, 0, len(idx.bitmaps))
+	for value := range idx.bitmaps {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	if err := binary.Write(writer, binary.LittleEndian, uint32(len(values))); err != nil {
+		return err
+	}
+	for _, value := range values {
+		if err := binary.Write(writer, binary.LittleEndian, value); err != nil {
+			return err
+		}
+		if err := idx.bitmaps[value].Write(writer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read deserializes the index written by Write from the reader.
+func (idx *EnumBitmapIndex) Read(reader io.Reader) error {
+	var numValues uint32
+	if err := binary.Read(reader, binary.LittleEndian, &numValues); err != nil {
+		return err
+	}
+	idx.bitmaps = make(map[uint32]*utils.Bitset, numValues)
+	for i := uint32(0); i < numValues; i++ {
+		var value uint32
+		if err := binary.Read(reader, binary.LittleEndian, &value); err != nil {
+			return err
+		}
+		bitmap := utils.NewBitset()
+		if err := bitmap.Read(reader); err != nil {
+			return err
+		}
+		idx.bitmaps[value] = bitmap
+	}
+	return nil
+}
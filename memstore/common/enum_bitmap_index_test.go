@@ -0,0 +1,43 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("enum bitmap index", func() {
+	ginkgo.It("should index rows by enum values", func() {
+		idx := NewEnumBitmapIndex()
+		for row, value := range []uint32{1, 2, 1, 3, 1} {
+			idx.Add(value, uint32(row))
+		}
+		Ω(idx.Rows(1).ToArray()).Should(Equal([]uint32{0, 2, 4}))
+		Ω(idx.Rows(2, 3).ToArray()).Should(Equal([]uint32{1, 3}))
+		Ω(idx.Rows(4).IsEmpty()).Should(BeTrue())
+		Ω(idx.Rows().IsEmpty()).Should(BeTrue())
+		Ω(idx.Counts()).Should(Equal(map[uint32]int{1: 3, 2: 1, 3: 1}))
+
+		buffer := &bytes.Buffer{}
+		Ω(idx.Write(buffer)).Should(BeNil())
+		read := NewEnumBitmapIndex()
+		Ω(read.Read(bytes.NewReader(buffer.Bytes()))).Should(BeNil())
+		Ω(read).Should(Equal(idx))
+		Ω(read.Read(bytes.NewReader(buffer.Bytes()[:10]))).ShouldNot(BeNil())
+	})
+})
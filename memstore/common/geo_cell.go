@@ -0,0 +1,105 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"math"
+
+	"github.com/golang/geo/r1"
+	"github.com/golang/geo/s1"
+	"github.com/golang/geo/s2"
+)
+
+const (
+	// geoLeafCellLevel is the level of s2 leaf cells.
+	geoLeafCellLevel = 30
+	// maxGeoCoveringCellsPerRing is the max number of s2 cells covering each ring of a shape.
+	maxGeoCoveringCellsPerRing = 8
+	// geoCoveringMargin is the margin in degrees added to the bounding box of each ring before
+	// covering it, so that points on the boundary are still covered after float32 rounding and
+	// differences between host and device trigonometric functions.
+	geoCoveringMargin = 1e-5
+)
+
+// GeoCellID returns the id of the s2 leaf cell containing the point.
+// Note: the computation must be kept in sync with get_s2_leaf_cell_id in query/utils.hpp.
+func GeoCellID(lat, lng float32) uint64 {
+	return uint64(s2.CellIDFromLatLng(s2.LatLngFromDegrees(float64(lat), float64(lng))))
+}
+
+// GeoCellRange returns the range [begin, end) of ids of leaf cells contained by the s2 cell.
+func GeoCellRange(cellID uint64) (begin, end uint64) {
+	id := s2.CellID(cellID)
+	return uint64(id.RangeMin()), uint64(id.RangeMax()) + 1
+}
+
+// ringBounds returns the bounding box of the ring, ok will be false if the ring does not have any point.
+func ringBounds(ring []GeoPointGo) (minPoint, maxPoint GeoPointGo, ok bool) {
+	for _, point := range ring {
+		if !ok {
+			minPoint, maxPoint, ok = point, point, true
+			continue
+		}
+		for i := range point {
+			if point[i] < minPoint[i] {
+				minPoint[i] = point[i]
+			}
+			if point[i] > maxPoint[i] {
+				maxPoint[i] = point[i]
+			}
+		}
+	}
+	return
+}
+
+// ComputeCovering computes the s2 cells covering the shape and stores their ids in Covering.
+// Each ring is covered by the cells covering its bounding box, so any point the point in shape
+// test in lat/lng space considers inside the shape is in one of the cells regardless of whether
+// rings are valid loops on the sphere. Covering is nil if the shape does not have any point.
+func (gs *GeoShapeGo) ComputeCovering() {
+	coverer := &s2.RegionCoverer{MaxLevel: geoLeafCellLevel, MaxCells: maxGeoCoveringCellsPerRing}
+	var covering s2.CellUnion
+	for _, ring := range gs.Polygons {
+		minPoint, maxPoint, ok := ringBounds(ring)
+		if !ok {
+			continue
+		}
+		rect := s2.Rect{
+			Lat: r1.Interval{
+				Lo: geoDegreesToRadians(math.Max(float64(minPoint[0])-geoCoveringMargin, -90)),
+				Hi: geoDegreesToRadians(math.Min(float64(maxPoint[0])+geoCoveringMargin, 90)),
+			},
+			Lng: s1.IntervalFromEndpoints(
+				geoDegreesToRadians(math.Max(float64(minPoint[1])-geoCoveringMargin, -180)),
+				geoDegreesToRadians(math.Min(float64(maxPoint[1])+geoCoveringMargin, 180)),
+			),
+		}
+		covering = append(covering, coverer.Covering(rect)...)
+	}
+
+	gs.Covering = nil
+	if len(covering) == 0 {
+		return
+	}
+	covering.Normalize()
+	gs.Covering = make([]uint64, len(covering))
+	for i, cellID := range covering {
+		gs.Covering[i] = uint64(cellID)
+	}
+}
+
+func geoDegreesToRadians(degrees float64) float64 {
+	return (s1.Angle(degrees) * s1.Degree).Radians()
+}
@@ -0,0 +1,95 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// geoCellCovered tells whether the leaf cell is in any of the cells.
+func geoCellCovered(cellIDs []uint64, leafCellID uint64) bool {
+	for _, cellID := range cellIDs {
+		begin, end := GeoCellRange(cellID)
+		if leafCellID >= begin && leafCellID < end {
+			return true
+		}
+	}
+	return false
+}
+
+var _ = ginkgo.Describe("geo_cell", func() {
+	ginkgo.It("GeoCellID should work", func() {
+		// leaf cells are odd, face is stored in the highest 3 bits.
+		Ω(GeoCellID(0, 0) & 1).Should(BeEquivalentTo(1))
+		Ω(GeoCellID(0, 0) >> 61).Should(BeEquivalentTo(0))
+		Ω(GeoCellID(0, 90) >> 61).Should(BeEquivalentTo(1))
+		Ω(GeoCellID(90, 0) >> 61).Should(BeEquivalentTo(2))
+		Ω(GeoCellID(0, 180) >> 61).Should(BeEquivalentTo(3))
+		Ω(GeoCellID(0, -90) >> 61).Should(BeEquivalentTo(4))
+		Ω(GeoCellID(-90, 0) >> 61).Should(BeEquivalentTo(5))
+		// the same values are checked by GeoCellTest.CheckS2LeafCellID in query/algorithm_unittest.cu.
+		Ω(GeoCellID(0, 0)).Should(BeEquivalentTo(uint64(0x1000000000000001)))
+		Ω(GeoCellID(37.775, -122.418)).Should(BeEquivalentTo(uint64(0x8085809c2c965037)))
+		Ω(GeoCellID(-33.8688, 151.2093)).Should(BeEquivalentTo(uint64(0x6b12ae3ff6290ac5)))
+	})
+
+	ginkgo.It("GeoCellRange should work", func() {
+		begin, end := GeoCellRange(GeoCellID(1, 1))
+		Ω(begin).Should(Equal(GeoCellID(1, 1)))
+		Ω(end).Should(Equal(GeoCellID(1, 1) + 1))
+
+		// face cell
+		begin, end = GeoCellRange(uint64(1) << 60)
+		Ω(begin).Should(BeEquivalentTo(1))
+		Ω(end).Should(BeEquivalentTo(uint64(1) << 61))
+	})
+
+	ginkgo.It("ComputeCovering should work", func() {
+		shape := &GeoShapeGo{}
+		shape.ComputeCovering()
+		Ω(shape.Covering).Should(BeNil())
+
+		shape = &GeoShapeGo{Polygons: [][]GeoPointGo{
+			{{37.7, -122.5}, {37.7, -122.4}, {37.8, -122.4}, {37.7, -122.5}},
+			{{-1, 179}, {1, 180}, {0, 179.5}, {-1, 179}},
+		}}
+		shape.ComputeCovering()
+		Ω(shape.Covering).ShouldNot(BeEmpty())
+		Ω(len(shape.Covering)).Should(BeNumerically("<=", 2*maxGeoCoveringCellsPerRing))
+		for i := 1; i < len(shape.Covering); i++ {
+			Ω(shape.Covering[i-1]).Should(BeNumerically("<", shape.Covering[i]))
+		}
+		Ω(shape.GetBytes()).Should(Equal(8*8 + 8*len(shape.Covering)))
+
+		// every point inside the bounding box of each ring is covered
+		for lat := float32(37.7); lat <= 37.8; lat += 0.005 {
+			for lng := float32(-122.5); lng <= -122.4; lng += 0.005 {
+				Ω(geoCellCovered(shape.Covering, GeoCellID(lat, lng))).Should(BeTrue())
+			}
+		}
+		for lat := float32(-1); lat <= 1; lat += 0.1 {
+			for lng := float32(179); lng <= 180; lng += 0.05 {
+				Ω(geoCellCovered(shape.Covering, GeoCellID(lat, lng))).Should(BeTrue())
+			}
+		}
+		Ω(geoCellCovered(shape.Covering, GeoCellID(37.7, -122.4))).Should(BeTrue())
+		Ω(geoCellCovered(shape.Covering, GeoCellID(1, -180))).Should(BeTrue())
+
+		// points far away are not covered
+		Ω(geoCellCovered(shape.Covering, GeoCellID(0, 0))).Should(BeFalse())
+		Ω(geoCellCovered(shape.Covering, GeoCellID(37.75, -121))).Should(BeFalse())
+	})
+})
@@ -0,0 +1,266 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	// a valid ring needs at least 3 distinct points plus the closing point
+	minPointsPerRing = 4
+	// initial tolerance in degrees used when simplifying shapes
+	initialSimplifyTolerance = 1e-7
+	// max number of times to double the tolerance when simplifying shapes
+	maxSimplifyIterations = 48
+)
+
+// geoJSONObject is the subset of GeoJSON object (RFC 7946) fields we need to parse geo shapes
+type geoJSONObject struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates,omitempty"`
+	Geometry    *geoJSONObject  `json:"geometry,omitempty"`
+}
+
+// IsGeoJSON tells whether the string looks like a GeoJSON object
+func IsGeoJSON(str string) bool {
+	return strings.HasPrefix(strings.TrimSpace(str), "{")
+}
+
+// GeoShapeFromGeoJSON converts GeoJSON string to geoshape
+// Supported types are Polygon, MultiPolygon and Feature with one of them as geometry.
+// Rings must be closed with at least 4 positions as required by RFC 7946, and are
+// rewound following the right hand rule: exterior rings counterclockwise and holes clockwise.
+func GeoShapeFromGeoJSON(str string) (GeoShapeGo, error) {
+	var obj geoJSONObject
+	if err := json.Unmarshal([]byte(str), &obj); err != nil {
+		return GeoShapeGo{}, utils.StackError(err, "invalid geojson %s", str)
+	}
+
+	if obj.Type == "Feature" {
+		if obj.Geometry == nil {
+			return GeoShapeGo{}, utils.StackError(nil, "geojson feature without geometry")
+		}
+		obj = *obj.Geometry
+	}
+
+	var polygons [][][][]float64
+	switch obj.Type {
+	case "Polygon":
+		var polygon [][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &polygon); err != nil {
+			return GeoShapeGo{}, utils.StackError(err, "invalid geojson polygon coordinates")
+		}
+		polygons = [][][][]float64{polygon}
+	case "MultiPolygon":
+		if err := json.Unmarshal(obj.Coordinates, &polygons); err != nil {
+			return GeoShapeGo{}, utils.StackError(err, "invalid geojson multipolygon coordinates")
+		}
+	default:
+		return GeoShapeGo{}, utils.StackError(nil, "unsupported geojson type %s", obj.Type)
+	}
+
+	shape := GeoShapeGo{}
+	for _, polygon := range polygons {
+		for ringIndex, coordinates := range polygon {
+			ring := make([]GeoPointGo, 0, len(coordinates))
+			for _, position := range coordinates {
+				// position may contain altitude as the third element, which is ignored
+				if len(position) < 2 {
+					return GeoShapeGo{}, utils.StackError(nil, "invalid geojson position %v", position)
+				}
+				lng, lat := position[0], position[1]
+				if lng < -180 || lng > 180 {
+					return GeoShapeGo{}, utils.StackError(nil, "invalid longitude, expect float number in [-180, 180], got %v", lng)
+				}
+				if lat < -90 || lat > 90 {
+					return GeoShapeGo{}, utils.StackError(nil, "invalid latitude, expect float number in [-90, 90], got %v", lat)
+				}
+				ring = append(ring, GeoPointGo{float32(lat), float32(lng)})
+			}
+			if len(ring) < minPointsPerRing || ring[0] != ring[len(ring)-1] {
+				return GeoShapeGo{}, utils.StackError(nil, "invalid geojson linear ring %v, expect closed ring with at least %d positions",
+					coordinates, minPointsPerRing)
+			}
+			// first ring is the exterior ring and the rest are holes
+			rewindRing(ring, ringIndex == 0)
+			shape.Polygons = append(shape.Polygons, ring)
+		}
+	}
+	return shape, nil
+}
+
+// Validate checks each ring of the shape is closed and not degenerated,
+// since bad shapes will produce wrong results for geography_intersects.
+// Clients can opt in with ConnectorConfig.ValidateGeoShapes to drop bad shapes before
+// ingestion, datanodes repair shapes on ingestion with Repair and reject unrepairable ones.
+func (gs *GeoShapeGo) Validate() error {
+	if len(gs.Polygons) == 0 {
+		return utils.StackError(nil, "geoshape should have at least one ring")
+	}
+
+	for i, ring := range gs.Polygons {
+		if len(ring) < minPointsPerRing {
+			return utils.StackError(nil, "ring %d should have at least %d points, got %d", i, minPointsPerRing, len(ring))
+		}
+		if ring[0] != ring[len(ring)-1] {
+			return utils.StackError(nil, "ring %d is not closed, first point %v, last point %v", i, ring[0], ring[len(ring)-1])
+		}
+		for _, point := range ring {
+			if point[0] < -90 || point[0] > 90 || point[1] < -180 || point[1] > 180 {
+				return utils.StackError(nil, "ring %d has invalid point %v", i, point)
+			}
+		}
+		if ringSignedArea(ring) == 0 {
+			return utils.StackError(nil, "ring %d has zero area", i)
+		}
+	}
+	return nil
+}
+
+// Repair closes unclosed rings and removes repeated consecutive points of the shape, then
+// validates it. Shapes still failing validation, e.g. with degenerated rings, can not be repaired.
+func (gs *GeoShapeGo) Repair() error {
+	for i, ring := range gs.Polygons {
+		repaired := make([]GeoPointGo, 0, len(ring)+1)
+		for _, point := range ring {
+			if len(repaired) == 0 || repaired[len(repaired)-1] != point {
+				repaired = append(repaired, point)
+			}
+		}
+		if len(repaired) > 0 && repaired[0] != repaired[len(repaired)-1] {
+			repaired = append(repaired, repaired[0])
+		}
+		gs.Polygons[i] = repaired
+	}
+	return gs.Validate()
+}
+
+// NumPoints returns the total number of points of all rings
+func (gs *GeoShapeGo) NumPoints() int {
+	numPoints := 0
+	for _, ring := range gs.Polygons {
+		numPoints += len(ring)
+	}
+	return numPoints
+}
+
+// Simplify reduces the number of points of the shape to be no more than maxPoints
+// using Douglas-Peucker algorithm with increasing tolerance. It's best effort as
+// rings will never be simplified to less than a triangle.
+func (gs *GeoShapeGo) Simplify(maxPoints int) {
+	if maxPoints <= 0 || gs.NumPoints() <= maxPoints {
+		return
+	}
+
+	original := gs.Polygons
+	simplified := make([][]GeoPointGo, len(original))
+	copy(simplified, original)
+	gs.Polygons = simplified
+
+	tolerance := initialSimplifyTolerance
+	for i := 0; i < maxSimplifyIterations && gs.NumPoints() > maxPoints; i++ {
+		for j, ring := range original {
+			newRing := simplifyRing(ring, tolerance)
+			// keep the previous result if the ring collapses
+			if len(newRing) >= minPointsPerRing {
+				simplified[j] = newRing
+			}
+		}
+		tolerance *= 2
+	}
+}
+
+// simplifyRing simplifies a closed ring with Douglas-Peucker algorithm,
+// the first and last point are always kept
+func simplifyRing(ring []GeoPointGo, tolerance float64) []GeoPointGo {
+	if len(ring) <= minPointsPerRing {
+		return ring
+	}
+
+	keep := make([]bool, len(ring))
+	keep[0], keep[len(ring)-1] = true, true
+	douglasPeucker(ring, 0, len(ring)-1, tolerance, keep)
+
+	newRing := make([]GeoPointGo, 0, len(ring))
+	for i, point := range ring {
+		if keep[i] {
+			newRing = append(newRing, point)
+		}
+	}
+	return newRing
+}
+
+func douglasPeucker(ring []GeoPointGo, start, end int, tolerance float64, keep []bool) {
+	if end-start < 2 {
+		return
+	}
+
+	maxDistance, index := -1.0, start
+	for i := start + 1; i < end; i++ {
+		distance := pointToSegmentDistance(ring[i], ring[start], ring[end])
+		if distance > maxDistance {
+			maxDistance, index = distance, i
+		}
+	}
+
+	if maxDistance > tolerance {
+		keep[index] = true
+		douglasPeucker(ring, start, index, tolerance, keep)
+		douglasPeucker(ring, index, end, tolerance, keep)
+	}
+}
+
+// pointToSegmentDistance computes the planar distance in degrees from point p to segment ab,
+// if a and b are the same point (eg. both ends of a closed ring), it's the distance between p and a
+func pointToSegmentDistance(p, a, b GeoPointGo) float64 {
+	px, py := float64(p[1]), float64(p[0])
+	ax, ay := float64(a[1]), float64(a[0])
+	bx, by := float64(b[1]), float64(b[0])
+
+	dx, dy := bx-ax, by-ay
+	if dx == 0 && dy == 0 {
+		return math.Hypot(px-ax, py-ay)
+	}
+
+	t := ((px-ax)*dx + (py-ay)*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(px-(ax+t*dx), py-(ay+t*dy))
+}
+
+// ringSignedArea computes twice the signed planar area of the ring using shoelace formula,
+// positive for counterclockwise rings and negative for clockwise rings
+func ringSignedArea(ring []GeoPointGo) float64 {
+	area := 0.0
+	for i := 0; i < len(ring)-1; i++ {
+		area += float64(ring[i][1])*float64(ring[i+1][0]) - float64(ring[i+1][1])*float64(ring[i][0])
+	}
+	return area
+}
+
+// rewindRing reverses the ring in place if needed, so that it's counterclockwise if ccw is true,
+// and clockwise otherwise
+func rewindRing(ring []GeoPointGo, ccw bool) {
+	area := ringSignedArea(ring)
+	if (ccw && area < 0) || (!ccw && area > 0) {
+		for i, j := 0, len(ring)-1; i < j; i, j = i+1, j-1 {
+			ring[i], ring[j] = ring[j], ring[i]
+		}
+	}
+}
@@ -0,0 +1,110 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"math"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("geo_shape", func() {
+	// circle approximated by numPoints points plus the closing point
+	circle := func(numPoints int) []GeoPointGo {
+		ring := make([]GeoPointGo, 0, numPoints+1)
+		for i := 0; i < numPoints; i++ {
+			angle := 2 * math.Pi * float64(i) / float64(numPoints)
+			ring = append(ring, GeoPointGo{float32(math.Sin(angle)), float32(math.Cos(angle))})
+		}
+		return append(ring, ring[0])
+	}
+
+	ginkgo.It("Validate should work", func() {
+		shape := GeoShapeGo{Polygons: [][]GeoPointGo{circle(8)}}
+		Ω(shape.Validate()).Should(BeNil())
+
+		Ω((&GeoShapeGo{}).Validate()).ShouldNot(BeNil())
+
+		// too few points
+		shape = GeoShapeGo{Polygons: [][]GeoPointGo{{{0, 0}, {1, 1}, {0, 0}}}}
+		Ω(shape.Validate()).ShouldNot(BeNil())
+
+		// not closed
+		shape = GeoShapeGo{Polygons: [][]GeoPointGo{{{0, 0}, {0, 1}, {1, 1}, {1, 0}}}}
+		Ω(shape.Validate()).ShouldNot(BeNil())
+
+		// collinear points
+		shape = GeoShapeGo{Polygons: [][]GeoPointGo{{{0, 0}, {1, 1}, {2, 2}, {0, 0}}}}
+		Ω(shape.Validate()).ShouldNot(BeNil())
+
+		// out of range
+		shape = GeoShapeGo{Polygons: [][]GeoPointGo{{{0, 0}, {0, 1}, {91, 1}, {0, 0}}}}
+		Ω(shape.Validate()).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("Repair should work", func() {
+		// unclosed ring with repeated points
+		shape := GeoShapeGo{Polygons: [][]GeoPointGo{{{0, 0}, {0, 1}, {0, 1}, {1, 1}, {1, 0}}}}
+		Ω(shape.Repair()).Should(BeNil())
+		Ω(shape.Polygons).Should(Equal([][]GeoPointGo{{{0, 0}, {0, 1}, {1, 1}, {1, 0}, {0, 0}}}))
+
+		// valid shapes are kept as is
+		shape = GeoShapeGo{Polygons: [][]GeoPointGo{circle(8)}}
+		Ω(shape.Repair()).Should(BeNil())
+		Ω(shape.Polygons).Should(Equal([][]GeoPointGo{circle(8)}))
+
+		// degenerated rings can not be repaired
+		shape = GeoShapeGo{Polygons: [][]GeoPointGo{{{0, 0}, {1, 1}, {1, 1}}}}
+		Ω(shape.Repair()).ShouldNot(BeNil())
+		shape = GeoShapeGo{Polygons: [][]GeoPointGo{{{0, 0}, {1, 1}, {2, 2}}}}
+		Ω(shape.Repair()).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("rewindRing should work", func() {
+		ring := []GeoPointGo{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}
+		Ω(ringSignedArea(ring) < 0).Should(BeTrue())
+		rewindRing(ring, true)
+		Ω(ringSignedArea(ring) > 0).Should(BeTrue())
+		Ω(ring).Should(Equal([]GeoPointGo{{0, 0}, {0, 1}, {1, 1}, {1, 0}, {0, 0}}))
+		rewindRing(ring, true)
+		Ω(ringSignedArea(ring) > 0).Should(BeTrue())
+		rewindRing(ring, false)
+		Ω(ringSignedArea(ring) < 0).Should(BeTrue())
+	})
+
+	ginkgo.It("Simplify should work", func() {
+		shape := GeoShapeGo{Polygons: [][]GeoPointGo{circle(1000), circle(100)}}
+		Ω(shape.NumPoints()).Should(Equal(1102))
+
+		// no budget or within budget
+		shape.Simplify(0)
+		Ω(shape.NumPoints()).Should(Equal(1102))
+		shape.Simplify(2000)
+		Ω(shape.NumPoints()).Should(Equal(1102))
+
+		shape.Simplify(100)
+		Ω(shape.NumPoints() <= 100).Should(BeTrue())
+		Ω(shape.Polygons).Should(HaveLen(2))
+		Ω(shape.Validate()).Should(BeNil())
+
+		// rings are never simplified to less than a triangle
+		shape.Simplify(1)
+		Ω(shape.Polygons).Should(HaveLen(2))
+		Ω(len(shape.Polygons[0]) >= minPointsPerRing).Should(BeTrue())
+		Ω(len(shape.Polygons[1]) >= minPointsPerRing).Should(BeTrue())
+		Ω(shape.Validate()).Should(BeNil())
+	})
+})
@@ -26,4 +26,12 @@ const (
 	SnapshotJobType JobType = "snapshot"
 	// PurgeJobType is the purge job type.
 	PurgeJobType JobType = "purge"
+	// CompactionJobType is the live store compaction job type.
+	CompactionJobType JobType = "compaction"
+	// HLLRecomputeJobType is the job type recomputing archived hll columns after their precision
+	// got reduced.
+	HLLRecomputeJobType JobType = "hllRecompute"
+	// ArchiveBatchRepairJobType is the job type replacing an archive batch with a dump uploaded for
+	// manual repair.
+	ArchiveBatchRepairJobType JobType = "archiveBatchRepair"
 )
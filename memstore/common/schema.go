@@ -17,6 +17,7 @@ package common
 import (
 	"encoding/json"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	metaCom "github.com/uber/aresdb/metastore/common"
@@ -33,6 +34,8 @@ type TableSchema struct {
 	ColumnIDs map[string]int `json:"columnIDs"`
 	// Maps from enum column names to their case dictionaries. Mutable.
 	EnumDicts map[string]EnumDict `json:"enumDicts"`
+	// Maps from String column names to their global dictionaries. Mutable.
+	StringDicts map[string]*GlobalStringDictionary `json:"-"`
 	// DataType for each column ordered by column ID. Mutable.
 	ValueTypeByColumn []DataType `json:"valueTypeByColumn"`
 	// Number of bytes in the primary key. Immutable.
@@ -41,6 +44,9 @@ type TableSchema struct {
 	PrimaryKeyColumnTypes []DataType `json:"primaryKeyColumnTypes"`
 	// Default values of each column. Mutable. Nil means default value is not set.
 	DefaultValues []*DataValue `json:"-"`
+
+	// Immutable copy of the schema returned by Snapshot, reset by every writer on Unlock.
+	snapshot unsafe.Pointer
 }
 
 // EnumDict contains mapping from and to enum strings to numbers.
@@ -49,6 +55,20 @@ type EnumDict struct {
 	Capacity    int            `json:"capacity"`
 	Dict        map[string]int `json:"dict"`
 	ReverseDict []string       `json:"reverseDict"`
+	// Compactions of the dictionary in the order applied, for remapping enum ids of data
+	// written before each compaction.
+	Compactions []metaCom.EnumDictCompaction `json:"-"`
+}
+
+// RemapEnumID maps the enum id of data written before the compactions to the current enum id.
+// Enum ids of dropped cases are mapped to 0 as they should not be referenced by any data.
+func RemapEnumID(compactions []metaCom.EnumDictCompaction, enumID int) int {
+	for _, compaction := range compactions {
+		if enumID = compaction.MapID(enumID); enumID == metaCom.DroppedEnumID {
+			return 0
+		}
+	}
+	return enumID
 }
 
 // NewTableSchema creates a new table schema object from metaStore table object,
@@ -68,6 +88,7 @@ func NewTableSchema(table *metaCom.Table) *TableSchema {
 			tableSchema.ColumnIDs[column.Name] = id
 		}
 		tableSchema.ValueTypeByColumn[id] = DataTypeForColumn(column)
+		tableSchema.createStringDict(column)
 	}
 
 	for i, columnID := range table.PrimaryKeyColumns {
@@ -92,6 +113,77 @@ func (t *TableSchema) MarshalJSON() ([]byte, error) {
 	return json.Marshal((*alias)(t))
 }
 
+// Unlock resets the snapshot of the schema before releasing the write lock, so that readers
+// taking snapshots afterwards see the changes of the writer.
+func (t *TableSchema) Unlock() {
+	atomic.StorePointer(&t.snapshot, nil)
+	t.RWMutex.Unlock()
+}
+
+// Snapshot returns an immutable copy of the schema. The copy is shared by readers until the next
+// schema change, so that readers like query compilation do not hold the read lock for long and
+// never block schema writers. Callers must not modify the returned schema, locking it is allowed
+// but not needed.
+func (t *TableSchema) Snapshot() *TableSchema {
+	if snapshot := atomic.LoadPointer(&t.snapshot); snapshot != nil {
+		return (*TableSchema)(snapshot)
+	}
+
+	// writers cannot reset the snapshot while the read lock is held.
+	t.RLock()
+	defer t.RUnlock()
+	if snapshot := atomic.LoadPointer(&t.snapshot); snapshot != nil {
+		return (*TableSchema)(snapshot)
+	}
+
+	snapshot := &TableSchema{
+		Schema:                t.Schema,
+		PrimaryKeyBytes:       t.PrimaryKeyBytes,
+		PrimaryKeyColumnTypes: t.PrimaryKeyColumnTypes,
+	}
+	if t.Schema.Columns != nil {
+		snapshot.Schema.Columns = append(make([]metaCom.Column, 0, len(t.Schema.Columns)), t.Schema.Columns...)
+	}
+	if t.ValueTypeByColumn != nil {
+		snapshot.ValueTypeByColumn = append(make([]DataType, 0, len(t.ValueTypeByColumn)), t.ValueTypeByColumn...)
+	}
+	if t.DefaultValues != nil {
+		snapshot.DefaultValues = append(make([]*DataValue, 0, len(t.DefaultValues)), t.DefaultValues...)
+	}
+	if t.ColumnIDs != nil {
+		snapshot.ColumnIDs = make(map[string]int, len(t.ColumnIDs))
+		for name, id := range t.ColumnIDs {
+			snapshot.ColumnIDs[name] = id
+		}
+	}
+	if t.EnumDicts != nil {
+		snapshot.EnumDicts = make(map[string]EnumDict, len(t.EnumDicts))
+		for name, enumDict := range t.EnumDicts {
+			dict := make(map[string]int, len(enumDict.Dict))
+			for enumCase, enumID := range enumDict.Dict {
+				dict[enumCase] = enumID
+			}
+			// enum cases are only appended to the reverse dict, so the prefix can be shared.
+			snapshot.EnumDicts[name] = EnumDict{
+				Capacity:    enumDict.Capacity,
+				Dict:        dict,
+				ReverseDict: enumDict.ReverseDict[:len(enumDict.ReverseDict):len(enumDict.ReverseDict)],
+				Compactions: enumDict.Compactions,
+			}
+		}
+	}
+	if t.StringDicts != nil {
+		snapshot.StringDicts = make(map[string]*GlobalStringDictionary, len(t.StringDicts))
+		for name, stringDict := range t.StringDicts {
+			snapshot.StringDicts[name] = stringDict
+		}
+	}
+	// snapshot of a snapshot is itself.
+	snapshot.snapshot = unsafe.Pointer(snapshot)
+	atomic.StorePointer(&t.snapshot, unsafe.Pointer(snapshot))
+	return snapshot
+}
+
 // SetTable sets a updated table and update TableSchema,
 // should acquire lock before calling.
 func (t *TableSchema) SetTable(table *metaCom.Table) {
@@ -105,11 +197,47 @@ func (t *TableSchema) SetTable(table *metaCom.Table) {
 
 		if id >= len(t.ValueTypeByColumn) {
 			t.ValueTypeByColumn = append(t.ValueTypeByColumn, DataTypeForColumn(column))
+		} else if dataType := DataTypeForColumn(column); dataType != t.ValueTypeByColumn[id] {
+			t.promoteEnumColumn(id, column.Name, dataType)
 		}
 
 		if id >= len(t.DefaultValues) {
 			t.DefaultValues = append(t.DefaultValues, nil)
 		}
+		t.createStringDict(column)
+	}
+}
+
+// createStringDict creates the global dictionary of a String column if it does not exist yet.
+func (t *TableSchema) createStringDict(column metaCom.Column) {
+	if column.Deleted || DataTypeForColumn(column) != String {
+		return
+	}
+	if t.StringDicts == nil {
+		t.StringDicts = make(map[string]*GlobalStringDictionary)
+	}
+	if _, ok := t.StringDicts[column.Name]; !ok {
+		t.StringDicts[column.Name] = NewGlobalStringDictionary()
+	}
+}
+
+// promoteEnumColumn updates the value type of a small enum column promoted to big enum and
+// resets its default value so that it will be parsed again by SetDefaultValue. Slices are
+// copied on write since readers may hold them after releasing the lock.
+func (t *TableSchema) promoteEnumColumn(columnID int, columnName string, dataType DataType) {
+	valueTypes := make([]DataType, len(t.ValueTypeByColumn))
+	copy(valueTypes, t.ValueTypeByColumn)
+	valueTypes[columnID] = dataType
+	t.ValueTypeByColumn = valueTypes
+
+	defaultValues := make([]*DataValue, len(t.DefaultValues))
+	copy(defaultValues, t.DefaultValues)
+	defaultValues[columnID] = nil
+	t.DefaultValues = defaultValues
+
+	if enumDict, ok := t.EnumDicts[columnName]; ok {
+		enumDict.Capacity = 1 << uint(DataTypeBits(dataType))
+		t.EnumDicts[columnName] = enumDict
 	}
 }
 
@@ -163,6 +291,17 @@ func (t *TableSchema) SetDefaultValue(columnID int) {
 			enumValUint16 := uint16(enumVal)
 			val.OtherVal = unsafe.Pointer(&enumValUint16)
 		}
+	} else if dataType == Decimal {
+		decimalVal, err := ParseDecimal(*defStrVal, column.DecimalScale)
+		if err != nil {
+			// Should not happen since the string value is already validated by schema handler.
+			utils.GetLogger().With(
+				"data_type", dataTypeName,
+				"default_value", *defStrVal,
+				"column", t.Schema.Columns[columnID].Name,
+			).Panic("Cannot parse default value")
+		}
+		val.OtherVal = unsafe.Pointer(&decimalVal)
 	} else {
 		dataValue, err := ValueFromString(*defStrVal, dataType)
 		if err != nil {
@@ -205,6 +344,68 @@ func (t *TableSchema) CreateEnumDict(columnName string, enumCases []string) {
 	}
 }
 
+// GetStringDict returns the global dictionary of the String column, nil if the column is not
+// a String column. Caller should hold the schema lock.
+func (t *TableSchema) GetStringDict(columnID int) *GlobalStringDictionary {
+	if columnID < 0 || columnID >= len(t.Schema.Columns) || t.ValueTypeByColumn[columnID] != String {
+		return nil
+	}
+	return t.StringDicts[t.Schema.Columns[columnID].Name]
+}
+
+// GetEnumDictCompactions returns compactions of the enum dict of the column in the order applied.
+// Caller should hold the schema lock.
+func (t *TableSchema) GetEnumDictCompactions(columnID int) []metaCom.EnumDictCompaction {
+	if columnID < 0 || columnID >= len(t.Schema.Columns) {
+		return nil
+	}
+	return t.EnumDicts[t.Schema.Columns[columnID].Name].Compactions
+}
+
+// CompactEnumDict drops enum cases of the column according to the compaction and records it.
+// The default value of the column is parsed again with the compacted dictionary. Caller should
+// hold the schema write lock.
+func (t *TableSchema) CompactEnumDict(columnID int, compaction metaCom.EnumDictCompaction) {
+	columnName := t.Schema.Columns[columnID].Name
+	enumDict, ok := t.EnumDicts[columnName]
+	if !ok {
+		return
+	}
+
+	reverseDict := make([]string, 0, len(enumDict.ReverseDict))
+	for enumID, enumCase := range enumDict.ReverseDict {
+		if compaction.MapID(enumID) != metaCom.DroppedEnumID {
+			reverseDict = append(reverseDict, enumCase)
+		}
+	}
+	dict := make(map[string]int, len(reverseDict))
+	for enumID, enumCase := range reverseDict {
+		dict[enumCase] = enumID
+	}
+	// slices are copied on write since readers may hold them after releasing the lock.
+	enumDict.Dict = dict
+	enumDict.ReverseDict = reverseDict
+	enumDict.Compactions = append(enumDict.Compactions[:len(enumDict.Compactions):len(enumDict.Compactions)], compaction)
+	t.EnumDicts[columnName] = enumDict
+
+	defaultValues := make([]*DataValue, len(t.DefaultValues))
+	copy(defaultValues, t.DefaultValues)
+	defaultValues[columnID] = nil
+	t.DefaultValues = defaultValues
+	t.SetDefaultValue(columnID)
+}
+
+// GetEnumDictGeneration returns the number of compactions of the enum dict of the column. Enum
+// ids of archived values change with the generation.
+func (t *TableSchema) GetEnumDictGeneration(columnID int) int {
+	t.RLock()
+	defer t.RUnlock()
+	if columnID >= len(t.Schema.Columns) {
+		return 0
+	}
+	return len(t.EnumDicts[t.Schema.Columns[columnID].Name].Compactions)
+}
+
 // GetValueTypeByColumn makes a copy of the ValueTypeByColumn so callers don't have to hold a read
 // lock to access it.
 func (t *TableSchema) GetValueTypeByColumn() []DataType {
@@ -231,6 +432,57 @@ func (t *TableSchema) GetColumnDeletions() []bool {
 	return deletedByColumn
 }
 
+// GetReducedHLLPrecisions returns for each column the precision hll values are reduced to, 0 for
+// columns keeping the default precision. Callers need to hold a read lock.
+func (t *TableSchema) GetReducedHLLPrecisions() []int {
+	precisions := make([]int, len(t.Schema.Columns))
+	for columnID, column := range t.Schema.Columns {
+		precisions[columnID] = reducedHLLPrecision(column)
+	}
+	return precisions
+}
+
+// GetReducedHLLPrecision returns the precision hll values of the column are reduced to, 0 if the
+// column keeps the default precision.
+func (t *TableSchema) GetReducedHLLPrecision(columnID int) int {
+	t.RLock()
+	defer t.RUnlock()
+	if columnID < 0 || columnID >= len(t.Schema.Columns) {
+		return 0
+	}
+	return reducedHLLPrecision(t.Schema.Columns[columnID])
+}
+
+func reducedHLLPrecision(column metaCom.Column) int {
+	if column.Deleted || !column.HLLConfig.IsHLLColumn || column.HLLConfig.GetPrecision() == metaCom.DefaultHLLPrecision {
+		return 0
+	}
+	return column.HLLConfig.GetPrecision()
+}
+
+// GetIndexedColumns returns IDs of enum columns with index hint. Callers need to hold a read lock.
+func (t *TableSchema) GetIndexedColumns() []int {
+	var indexedColumns []int
+	for columnID, column := range t.Schema.Columns {
+		if column.Config.Indexed && !column.Deleted && column.IsEnumColumn() {
+			indexedColumns = append(indexedColumns, columnID)
+		}
+	}
+	return indexedColumns
+}
+
+// GetVectorIndexedColumns returns IDs of vector columns with index hint. Callers need to hold a
+// read lock.
+func (t *TableSchema) GetVectorIndexedColumns() []int {
+	var indexedColumns []int
+	for columnID, column := range t.Schema.Columns {
+		if column.Config.Indexed && !column.Deleted && column.IsVectorColumn() {
+			indexedColumns = append(indexedColumns, columnID)
+		}
+	}
+	return indexedColumns
+}
+
 // GetColumnIfNonNilDefault returns a boolean slice that indicates whether a column has non nil default value. Callers
 // need to hold a read lock.
 func (t *TableSchema) GetColumnIfNonNilDefault() []bool {
@@ -0,0 +1,142 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metaCom "github.com/uber/aresdb/metastore/common"
+)
+
+var _ = ginkgo.Describe("table schema", func() {
+	ginkgo.It("Snapshot should be shared until the schema changes", func() {
+		schema := NewTableSchema(&metaCom.Table{
+			Name: "t1",
+			Columns: []metaCom.Column{
+				{Name: "c0", Type: metaCom.Uint32},
+				{Name: "c1", Type: metaCom.SmallEnum},
+			},
+			PrimaryKeyColumns: []int{0},
+		})
+		schema.EnumDicts["c1"] = EnumDict{
+			Capacity:    0x100,
+			Dict:        map[string]int{"a": 0},
+			ReverseDict: []string{"a"},
+		}
+
+		snapshot := schema.Snapshot()
+		Ω(snapshot).ShouldNot(BeIdenticalTo(schema))
+		Ω(schema.Snapshot()).Should(BeIdenticalTo(snapshot))
+		Ω(snapshot.Snapshot()).Should(BeIdenticalTo(snapshot))
+		Ω(snapshot.ColumnIDs).Should(Equal(map[string]int{"c0": 0, "c1": 1}))
+		Ω(snapshot.ValueTypeByColumn).Should(Equal([]DataType{Uint32, SmallEnum}))
+
+		schema.Lock()
+		enumDict := schema.EnumDicts["c1"]
+		enumDict.Dict["b"] = 1
+		enumDict.ReverseDict = append(enumDict.ReverseDict, "b")
+		schema.EnumDicts["c1"] = enumDict
+		schema.SetTable(&metaCom.Table{
+			Name: "t1",
+			Columns: []metaCom.Column{
+				{Name: "c0", Type: metaCom.Uint32},
+				{Name: "c1", Type: metaCom.SmallEnum},
+				{Name: "c2", Type: metaCom.Bool},
+			},
+			PrimaryKeyColumns: []int{0},
+		})
+		schema.Unlock()
+
+		// the old snapshot is not affected by the changes.
+		Ω(snapshot.EnumDicts["c1"].Dict).Should(Equal(map[string]int{"a": 0}))
+		Ω(snapshot.EnumDicts["c1"].ReverseDict).Should(Equal([]string{"a"}))
+		Ω(snapshot.Schema.Columns).Should(HaveLen(2))
+		Ω(snapshot.ColumnIDs).ShouldNot(HaveKey("c2"))
+
+		newSnapshot := schema.Snapshot()
+		Ω(newSnapshot).ShouldNot(BeIdenticalTo(snapshot))
+		Ω(newSnapshot.EnumDicts["c1"].ReverseDict).Should(Equal([]string{"a", "b"}))
+		Ω(newSnapshot.ColumnIDs).Should(HaveKeyWithValue("c2", 2))
+		Ω(newSnapshot.ValueTypeByColumn).Should(Equal([]DataType{Uint32, SmallEnum, Bool}))
+	})
+	ginkgo.It("should create global dictionaries for String columns", func() {
+		schema := NewTableSchema(&metaCom.Table{
+			Name: "t1",
+			Columns: []metaCom.Column{
+				{Name: "c0", Type: metaCom.Uint32},
+				{Name: "c1", Type: metaCom.String},
+			},
+			PrimaryKeyColumns: []int{0},
+		})
+		Ω(schema.GetStringDict(0)).Should(BeNil())
+		Ω(schema.GetStringDict(2)).Should(BeNil())
+		stringDict := schema.GetStringDict(1)
+		Ω(stringDict).ShouldNot(BeNil())
+
+		schema.Lock()
+		schema.SetTable(&metaCom.Table{
+			Name: "t1",
+			Columns: []metaCom.Column{
+				{Name: "c0", Type: metaCom.Uint32},
+				{Name: "c1", Type: metaCom.String},
+				{Name: "c2", Type: metaCom.String},
+			},
+			PrimaryKeyColumns: []int{0},
+		})
+		schema.Unlock()
+
+		// dictionaries of existing columns are kept.
+		Ω(schema.GetStringDict(1)).Should(BeIdenticalTo(stringDict))
+		Ω(schema.GetStringDict(2)).ShouldNot(BeNil())
+		Ω(schema.Snapshot().GetStringDict(1)).Should(BeIdenticalTo(stringDict))
+	})
+
+	ginkgo.It("SetTable should promote small enum column to big enum", func() {
+		defaultValue := "a"
+		schema := NewTableSchema(&metaCom.Table{
+			Name: "t1",
+			Columns: []metaCom.Column{
+				{Name: "c0", Type: metaCom.Uint32},
+				{Name: "c1", Type: metaCom.SmallEnum, DefaultValue: &defaultValue},
+			},
+			PrimaryKeyColumns: []int{0},
+		})
+		schema.CreateEnumDict("c1", []string{"a"})
+		schema.SetDefaultValue(0)
+		schema.SetDefaultValue(1)
+		valueTypes := schema.ValueTypeByColumn
+		Ω(schema.EnumDicts["c1"].Capacity).Should(Equal(0x100))
+		Ω(schema.DefaultValues[1].DataType).Should(Equal(SmallEnum))
+
+		schema.Lock()
+		schema.SetTable(&metaCom.Table{
+			Name: "t1",
+			Columns: []metaCom.Column{
+				{Name: "c0", Type: metaCom.Uint32},
+				{Name: "c1", Type: metaCom.BigEnum, DefaultValue: &defaultValue},
+			},
+			PrimaryKeyColumns: []int{0},
+		})
+		schema.SetDefaultValue(1)
+		schema.Unlock()
+
+		Ω(schema.ValueTypeByColumn).Should(Equal([]DataType{Uint32, BigEnum}))
+		// slices held by readers are not modified.
+		Ω(valueTypes).Should(Equal([]DataType{Uint32, SmallEnum}))
+		Ω(schema.EnumDicts["c1"].Capacity).Should(Equal(0x10000))
+		Ω(schema.DefaultValues[1].DataType).Should(Equal(BigEnum))
+		Ω(*(*uint16)(schema.DefaultValues[1].OtherVal)).Should(Equal(uint16(0)))
+	})
+})
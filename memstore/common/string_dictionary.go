@@ -0,0 +1,238 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"github.com/uber/aresdb/utils"
+	"sync"
+)
+
+// StringDictionary maps the distinct values of a String column within a single batch
+// to dense uint32 ids. Ids are assigned in insertion order and never reused.
+type StringDictionary struct {
+	sync.RWMutex
+	ids    map[string]uint32
+	values []string
+	bytes  int
+}
+
+// NewStringDictionary creates an empty StringDictionary.
+func NewStringDictionary() *StringDictionary {
+	return &StringDictionary{
+		ids: make(map[string]uint32),
+	}
+}
+
+// GetOrAdd returns the id of the value, adding it to the dictionary if absent.
+func (d *StringDictionary) GetOrAdd(value string) uint32 {
+	d.RLock()
+	id, ok := d.ids[value]
+	d.RUnlock()
+	if ok {
+		return id
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	if id, ok = d.ids[value]; ok {
+		return id
+	}
+	id = uint32(len(d.values))
+	d.ids[value] = id
+	d.values = append(d.values, value)
+	d.bytes += len(value)
+	return id
+}
+
+// Lookup returns the id of the value and whether it exists in the dictionary.
+func (d *StringDictionary) Lookup(value string) (uint32, bool) {
+	d.RLock()
+	defer d.RUnlock()
+	id, ok := d.ids[value]
+	return id, ok
+}
+
+// Get returns the value of the id and whether the id exists in the dictionary.
+func (d *StringDictionary) Get(id uint32) (string, bool) {
+	d.RLock()
+	defer d.RUnlock()
+	if int(id) >= len(d.values) {
+		return "", false
+	}
+	return d.values[id], true
+}
+
+// Len returns the number of distinct values in the dictionary.
+func (d *StringDictionary) Len() int {
+	d.RLock()
+	defer d.RUnlock()
+	return len(d.values)
+}
+
+// GetBytes returns the number of bytes of all values in the dictionary.
+func (d *StringDictionary) GetBytes() int {
+	d.RLock()
+	defer d.RUnlock()
+	return d.bytes
+}
+
+// valuesFrom returns the values with ids no less than start.
+func (d *StringDictionary) valuesFrom(start int) []string {
+	d.RLock()
+	defer d.RUnlock()
+	if start >= len(d.values) {
+		return nil
+	}
+	return d.values[start:len(d.values):len(d.values)]
+}
+
+// Write serializes the dictionary as the number of values followed by each value
+// in id order.
+func (d *StringDictionary) Write(dataWriter *utils.StreamDataWriter) error {
+	d.RLock()
+	defer d.RUnlock()
+	if err := dataWriter.WriteUint32(uint32(len(d.values))); err != nil {
+		return err
+	}
+	for _, value := range d.values {
+		str := StringGo(value)
+		if err := str.Write(dataWriter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read deserializes the dictionary written by Write.
+func (d *StringDictionary) Read(dataReader *utils.StreamDataReader) error {
+	numValues, err := dataReader.ReadUint32()
+	if err != nil {
+		return err
+	}
+	d.Lock()
+	defer d.Unlock()
+	d.ids = make(map[string]uint32, numValues)
+	d.values = make([]string, 0, numValues)
+	d.bytes = 0
+	for i := 0; i < int(numValues); i++ {
+		var str StringGo
+		if err = str.Read(dataReader); err != nil {
+			return err
+		}
+		d.ids[string(str)] = uint32(i)
+		d.values = append(d.values, string(str))
+		d.bytes += len(str)
+	}
+	return nil
+}
+
+// batchDictionaryMapping tracks how much of a batch dictionary has been merged into
+// the global dictionary and the global id of each merged local id.
+type batchDictionaryMapping struct {
+	globalIDs []uint32
+}
+
+// GlobalStringDictionary merges the batch dictionaries of a String column into a single
+// id space so that values can be compared across batches. Merging is lazy: registering
+// a batch dictionary is cheap and new batch values are only merged when global ids are
+// requested. Global ids are never reassigned so results of earlier lookups stay valid.
+type GlobalStringDictionary struct {
+	sync.RWMutex
+	ids     map[string]uint32
+	values  []string
+	batches map[*StringDictionary]*batchDictionaryMapping
+}
+
+// NewGlobalStringDictionary creates an empty GlobalStringDictionary.
+func NewGlobalStringDictionary() *GlobalStringDictionary {
+	return &GlobalStringDictionary{
+		ids:     make(map[string]uint32),
+		batches: make(map[*StringDictionary]*batchDictionaryMapping),
+	}
+}
+
+// Register adds the dictionary of a batch. Registering the same dictionary again keeps
+// the merge progress.
+func (g *GlobalStringDictionary) Register(dict *StringDictionary) {
+	g.Lock()
+	defer g.Unlock()
+	if _, ok := g.batches[dict]; !ok {
+		g.batches[dict] = &batchDictionaryMapping{}
+	}
+}
+
+// Unregister removes the dictionary of a batch. Values already merged stay in the global
+// dictionary so that global ids handed out remain stable.
+func (g *GlobalStringDictionary) Unregister(dict *StringDictionary) {
+	g.Lock()
+	defer g.Unlock()
+	delete(g.batches, dict)
+}
+
+// merge merges values added to the batch dictionary since the last merge, caller
+// needs to hold the write lock.
+func (g *GlobalStringDictionary) merge(dict *StringDictionary, mapping *batchDictionaryMapping) {
+	for _, value := range dict.valuesFrom(len(mapping.globalIDs)) {
+		id, ok := g.ids[value]
+		if !ok {
+			id = uint32(len(g.values))
+			g.ids[value] = id
+			g.values = append(g.values, value)
+		}
+		mapping.globalIDs = append(mapping.globalIDs, id)
+	}
+}
+
+// GlobalIDs returns the global id of each local id of the batch dictionary, merging
+// pending batch values first. Returns nil if the dictionary is not registered.
+func (g *GlobalStringDictionary) GlobalIDs(dict *StringDictionary) []uint32 {
+	g.Lock()
+	defer g.Unlock()
+	mapping, ok := g.batches[dict]
+	if !ok {
+		return nil
+	}
+	g.merge(dict, mapping)
+	return mapping.globalIDs[:len(mapping.globalIDs):len(mapping.globalIDs)]
+}
+
+// Lookup returns the global id of the value after merging all pending batch values.
+func (g *GlobalStringDictionary) Lookup(value string) (uint32, bool) {
+	g.Lock()
+	defer g.Unlock()
+	for dict, mapping := range g.batches {
+		g.merge(dict, mapping)
+	}
+	id, ok := g.ids[value]
+	return id, ok
+}
+
+// Get returns the value of the global id.
+func (g *GlobalStringDictionary) Get(id uint32) (string, bool) {
+	g.RLock()
+	defer g.RUnlock()
+	if int(id) >= len(g.values) {
+		return "", false
+	}
+	return g.values[id], true
+}
+
+// Values returns the values merged so far indexed by global id. Values are only appended
+// so the returned slice can be read without holding the lock.
+func (g *GlobalStringDictionary) Values() []string {
+	g.RLock()
+	defer g.RUnlock()
+	return g.values[:len(g.values):len(g.values)]
+}
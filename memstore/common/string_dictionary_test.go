@@ -0,0 +1,125 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/utils"
+)
+
+var _ = ginkgo.Describe("string_dictionary", func() {
+	ginkgo.It("should assign dense ids in insertion order", func() {
+		dict := NewStringDictionary()
+		Ω(dict.GetOrAdd("a")).Should(BeEquivalentTo(0))
+		Ω(dict.GetOrAdd("bb")).Should(BeEquivalentTo(1))
+		Ω(dict.GetOrAdd("a")).Should(BeEquivalentTo(0))
+		Ω(dict.Len()).Should(Equal(2))
+		Ω(dict.GetBytes()).Should(Equal(3))
+
+		id, ok := dict.Lookup("bb")
+		Ω(ok).Should(BeTrue())
+		Ω(id).Should(BeEquivalentTo(1))
+		_, ok = dict.Lookup("c")
+		Ω(ok).Should(BeFalse())
+
+		str, ok := dict.Get(1)
+		Ω(ok).Should(BeTrue())
+		Ω(str).Should(Equal("bb"))
+		_, ok = dict.Get(2)
+		Ω(ok).Should(BeFalse())
+	})
+
+	ginkgo.It("should serialize and deserialize", func() {
+		dict := NewStringDictionary()
+		dict.GetOrAdd("device-1")
+		dict.GetOrAdd("")
+		dict.GetOrAdd("https://example.com/a?b=c")
+
+		buffer := &bytes.Buffer{}
+		writer := utils.NewStreamDataWriter(buffer)
+		Ω(dict.Write(&writer)).Should(BeNil())
+
+		reader := utils.NewStreamDataReader(bytes.NewReader(buffer.Bytes()))
+		newDict := NewStringDictionary()
+		Ω(newDict.Read(&reader)).Should(BeNil())
+		Ω(newDict.Len()).Should(Equal(3))
+		Ω(newDict.GetBytes()).Should(Equal(dict.GetBytes()))
+		id, ok := newDict.Lookup("https://example.com/a?b=c")
+		Ω(ok).Should(BeTrue())
+		Ω(id).Should(BeEquivalentTo(2))
+	})
+
+	ginkgo.It("should merge batch dictionaries lazily", func() {
+		dict1 := NewStringDictionary()
+		dict1.GetOrAdd("a")
+		dict1.GetOrAdd("b")
+		dict2 := NewStringDictionary()
+		dict2.GetOrAdd("b")
+		dict2.GetOrAdd("c")
+
+		global := NewGlobalStringDictionary()
+		global.Register(dict1)
+		global.Register(dict2)
+		Ω(global.GlobalIDs(NewStringDictionary())).Should(BeNil())
+
+		ids1 := global.GlobalIDs(dict1)
+		Ω(ids1).Should(Equal([]uint32{0, 1}))
+		ids2 := global.GlobalIDs(dict2)
+		Ω(ids2).Should(Equal([]uint32{1, 2}))
+		Ω(global.Values()).Should(Equal([]string{"a", "b", "c"}))
+
+		// values added after the merge are picked up on next lookup
+		dict2.GetOrAdd("d")
+		id, ok := global.Lookup("d")
+		Ω(ok).Should(BeTrue())
+		Ω(id).Should(BeEquivalentTo(3))
+		str, ok := global.Get(3)
+		Ω(ok).Should(BeTrue())
+		Ω(str).Should(Equal("d"))
+
+		// registering the same dictionary keeps merge progress
+		global.Register(dict2)
+		Ω(global.GlobalIDs(dict2)).Should(Equal([]uint32{1, 2, 3}))
+
+		global.Unregister(dict1)
+		Ω(global.GlobalIDs(dict1)).Should(BeNil())
+		id, ok = global.Lookup("a")
+		Ω(ok).Should(BeTrue())
+		Ω(id).Should(BeEquivalentTo(0))
+	})
+
+	ginkgo.It("StringGo should serialize and deserialize", func() {
+		str := StringGo("hello")
+		Ω(str.GetSerBytes()).Should(Equal(12))
+		buffer := &bytes.Buffer{}
+		writer := utils.NewStreamDataWriter(buffer)
+		Ω(str.Write(&writer)).Should(BeNil())
+		Ω(buffer.Len()).Should(Equal(12))
+
+		var newStr StringGo
+		reader := utils.NewStreamDataReader(bytes.NewReader(buffer.Bytes()))
+		Ω(newStr.Read(&reader)).Should(BeNil())
+		Ω(newStr).Should(Equal(str))
+
+		converted, ok := ConvertToString(123)
+		Ω(ok).Should(BeTrue())
+		Ω(string(*converted)).Should(Equal("123"))
+		_, ok = ConvertToString([]int{1})
+		Ω(ok).Should(BeFalse())
+	})
+})
@@ -0,0 +1,93 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"unsafe"
+)
+
+// System columns are virtual columns of every table populated by live and archive stores
+// instead of ingestion. They are not part of table schemas and can be referenced in query
+// filters for debugging ingestion issues.
+const (
+	// IngestedAtColumnName is the system column of the time in seconds since epoch a row is
+	// ingested. Archiving does not keep ingestion time of rows, so rows of archive batches report
+	// the archiving cutoff of the batch version instead, which is no earlier than the ingestion
+	// time of any of them.
+	IngestedAtColumnName = "__ingested_at"
+	// BatchIDColumnName is the system column of the id of the batch storing a row.
+	BatchIDColumnName = "__batch_id"
+	// RowIndexColumnName is the system column of the position of a row in the batch storing it,
+	// its values are generated when batches are transferred to device for query.
+	RowIndexColumnName = "__row_index"
+)
+
+// IDs of system columns are negative so that they never collide with schema columns.
+const (
+	IngestedAtColumnID = -1 - iota
+	BatchIDColumnID
+	RowIndexColumnID
+)
+
+// SystemColumn defines a system column.
+type SystemColumn struct {
+	ID       int
+	Name     string
+	DataType DataType
+}
+
+// SystemColumns are all system columns.
+var SystemColumns = []SystemColumn{
+	{ID: IngestedAtColumnID, Name: IngestedAtColumnName, DataType: Uint32},
+	{ID: BatchIDColumnID, Name: BatchIDColumnName, DataType: Int32},
+	{ID: RowIndexColumnID, Name: RowIndexColumnName, DataType: Uint32},
+}
+
+// GetSystemColumn returns the system column by name.
+func GetSystemColumn(name string) (SystemColumn, bool) {
+	for _, column := range SystemColumns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return SystemColumn{}, false
+}
+
+// IsSystemColumn returns whether the column id refers to a system column.
+func IsSystemColumn(columnID int) bool {
+	return columnID < 0 && columnID >= RowIndexColumnID
+}
+
+// GetSystemColumnType returns the data type of the system column.
+func GetSystemColumnType(columnID int) DataType {
+	return SystemColumns[-1-columnID].DataType
+}
+
+// SystemColumnValue returns a valid data value of the system column shared by all rows of a
+// batch.
+func SystemColumnValue(columnID int, value interface{}) DataValue {
+	dataValue := DataValue{
+		DataType: GetSystemColumnType(columnID),
+		Valid:    true,
+	}
+	switch v := value.(type) {
+	case uint32:
+		dataValue.OtherVal = unsafe.Pointer(&v)
+	case int32:
+		dataValue.OtherVal = unsafe.Pointer(&v)
+	}
+	dataValue.CmpFunc = GetCompareFunc(dataValue.DataType)
+	return dataValue
+}
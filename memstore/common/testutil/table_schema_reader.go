@@ -0,0 +1,132 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"sync"
+
+	memCom "github.com/uber/aresdb/memstore/common"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// TableSchemaReader is a fake memCom.TableSchemaReader whose schemas can be
+// changed while a test is running. Besides setting and deleting schemas
+// directly, a test can script a sequence of schema versions for a table; each
+// GetSchema call on that table consumes the next version and the last version
+// sticks once the script is exhausted. This makes it easy to simulate schema
+// drift between two reads of the same table (eg. between compile and execute).
+type TableSchemaReader struct {
+	sync.RWMutex
+
+	// protects fields below, separate from the embedded lock which is
+	// exposed to the code under test.
+	mu       sync.Mutex
+	schemas  map[string]*memCom.TableSchema
+	scripts  map[string][]*memCom.TableSchema
+	getCalls map[string]int
+}
+
+// NewTableSchemaReader creates a fake TableSchemaReader with given initial schemas.
+func NewTableSchemaReader(schemas ...*memCom.TableSchema) *TableSchemaReader {
+	r := &TableSchemaReader{
+		schemas:  make(map[string]*memCom.TableSchema),
+		scripts:  make(map[string][]*memCom.TableSchema),
+		getCalls: make(map[string]int),
+	}
+	for _, schema := range schemas {
+		r.schemas[schema.Schema.Name] = schema
+	}
+	return r
+}
+
+// MustNewTableSchema creates a TableSchema from a metastore table and the given enum cases
+// per column. It's a utility method to make tests easier to write.
+func MustNewTableSchema(table *metaCom.Table, enumCases map[string][]string) *memCom.TableSchema {
+	schema := memCom.NewTableSchema(table)
+	for columnName, cases := range enumCases {
+		if _, ok := schema.ColumnIDs[columnName]; !ok {
+			panic(utils.StackError(nil, "unknown enum column %s in table %s", columnName, table.Name))
+		}
+		schema.CreateEnumDict(columnName, cases)
+	}
+	return schema
+}
+
+// SetSchema adds or replaces the schema for a table and drops any pending script for it.
+func (r *TableSchemaReader) SetSchema(schema *memCom.TableSchema) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schemas[schema.Schema.Name] = schema
+	delete(r.scripts, schema.Schema.Name)
+}
+
+// DeleteSchema removes the schema for a table and drops any pending script for it.
+func (r *TableSchemaReader) DeleteSchema(table string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.schemas, table)
+	delete(r.scripts, table)
+}
+
+// Script queues schema versions to be returned by successive GetSchema calls
+// for the table. A nil version makes the corresponding call return a table not
+// found error.
+func (r *TableSchemaReader) Script(table string, versions ...*memCom.TableSchema) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.scripts[table] = append(r.scripts[table], versions...)
+}
+
+// GetSchemaCalls returns how many times GetSchema was called for the table.
+func (r *TableSchemaReader) GetSchemaCalls(table string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.getCalls[table]
+}
+
+// GetSchema returns the current schema of the table, advancing its script if present.
+func (r *TableSchemaReader) GetSchema(table string) (*memCom.TableSchema, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.getCalls[table]++
+
+	if script := r.scripts[table]; len(script) > 0 {
+		next := script[0]
+		r.scripts[table] = script[1:]
+		if next == nil {
+			delete(r.schemas, table)
+		} else {
+			r.schemas[table] = next
+		}
+	}
+
+	schema, ok := r.schemas[table]
+	if !ok {
+		return nil, metaCom.ErrTableDoesNotExist
+	}
+	return schema, nil
+}
+
+// GetSchemas returns a snapshot of all current schemas. Scripts are not advanced.
+func (r *TableSchemaReader) GetSchemas() map[string]*memCom.TableSchema {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	schemas := make(map[string]*memCom.TableSchema, len(r.schemas))
+	for name, schema := range r.schemas {
+		schemas[name] = schema
+	}
+	return schemas
+}
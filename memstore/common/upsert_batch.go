@@ -16,6 +16,7 @@ package common
 
 import (
 	"bytes"
+	metaCom "github.com/uber/aresdb/metastore/common"
 	"github.com/uber/aresdb/utils"
 	"math"
 	"unsafe"
@@ -220,6 +221,79 @@ func (u *UpsertBatch) GetColumnType(col int) (DataType, error) {
 	return u.columns[col].dataType, nil
 }
 
+// PromoteEnumColumn widens the values of a small enum column to big enum, so that upsert
+// batches built before the column was promoted to big enum can still be applied.
+func (u *UpsertBatch) PromoteEnumColumn(col int) error {
+	if col >= len(u.columns) {
+		return utils.StackError(nil, "Column index %d out of range %d", col, len(u.columns))
+	}
+	column := u.columns[col]
+	if column.dataType != SmallEnum {
+		return utils.StackError(nil, "Column index %d is not small enum", col)
+	}
+
+	if column.columnMode != AllValuesDefault {
+		valueVector := make([]byte, utils.AlignOffset(u.NumRows*2, 8))
+		for row := 0; row < u.NumRows; row++ {
+			*(*uint16)(unsafe.Pointer(&valueVector[row*2])) = uint16(column.valueVector[row])
+		}
+		column.valueVector = valueVector
+	}
+	column.dataType = BigEnum
+	column.cmpFunc = GetCompareFunc(BigEnum)
+	return nil
+}
+
+// RemapEnumColumn maps the enum ids of an enum column to the ids after the compactions, so that
+// upsert batches built before the enum dictionary got compacted can still be applied.
+func (u *UpsertBatch) RemapEnumColumn(col int, compactions []metaCom.EnumDictCompaction) error {
+	if col >= len(u.columns) {
+		return utils.StackError(nil, "Column index %d out of range %d", col, len(u.columns))
+	}
+	column := u.columns[col]
+	if column.dataType != SmallEnum && column.dataType != BigEnum {
+		return utils.StackError(nil, "Column index %d is not enum", col)
+	}
+	if column.columnMode == AllValuesDefault || len(compactions) == 0 {
+		return nil
+	}
+
+	valueVector := make([]byte, len(column.valueVector))
+	for row := 0; row < u.NumRows; row++ {
+		if column.dataType == SmallEnum {
+			valueVector[row] = uint8(RemapEnumID(compactions, int(column.valueVector[row])))
+		} else {
+			enumID := RemapEnumID(compactions, int(*(*uint16)(unsafe.Pointer(&column.valueVector[row*2]))))
+			*(*uint16)(unsafe.Pointer(&valueVector[row*2])) = uint16(enumID)
+		}
+	}
+	column.valueVector = valueVector
+	return nil
+}
+
+// ReduceHLLColumnPrecision converts the values of an hll column to the precision, so that upsert
+// batches built before the hll precision of the column got reduced can still be applied.
+func (u *UpsertBatch) ReduceHLLColumnPrecision(col int, precision int) error {
+	if col >= len(u.columns) {
+		return utils.StackError(nil, "Column index %d out of range %d", col, len(u.columns))
+	}
+	column := u.columns[col]
+	if column.dataType != Uint32 {
+		return utils.StackError(nil, "Column index %d is not hll column", col)
+	}
+	if column.columnMode == AllValuesDefault {
+		return nil
+	}
+
+	valueVector := make([]byte, len(column.valueVector))
+	for row := 0; row < u.NumRows; row++ {
+		value := *(*uint32)(unsafe.Pointer(&column.valueVector[row*4]))
+		*(*uint32)(unsafe.Pointer(&valueVector[row*4])) = utils.ReduceHLLValuePrecision(value, precision)
+	}
+	column.valueVector = valueVector
+	return nil
+}
+
 // GetColumnIndex returns the local index of a column given a logical index id.
 func (u *UpsertBatch) GetColumnIndex(columnID int) (int, error) {
 	col, ok := u.columnsByID[columnID]
@@ -51,6 +51,7 @@ type columnBuilder struct {
 	values         []interface{}
 	numValidValues int
 	updateMode     ColumnUpdateMode
+	decimalScale   int
 }
 
 // SetValue write a value into the column at given row.
@@ -61,7 +62,7 @@ func (c *columnBuilder) SetValue(row int, value interface{}) error {
 		c.values[row] = nil
 	} else {
 		var err error
-		c.values[row], err = ConvertValueForType(c.dataType, value)
+		c.values[row], err = ConvertValueForType(c.dataType, value, c.decimalScale)
 		if err != nil {
 			return err
 		}
@@ -220,7 +221,7 @@ func (c *columnBuilder) AppendToBuffer(writer *utils.BufferWriter) error {
 				if err := valueWriter.AppendInt32(value.(int32)); err != nil {
 					return utils.StackError(err, "Failed to write int32 value at row %d", row)
 				}
-			case Int64:
+			case Int64, Decimal:
 				if err := valueWriter.AppendInt64(value.(int64)); err != nil {
 					return utils.StackError(err, "Failed to write int64 value at row %d", row)
 				}
@@ -256,12 +257,12 @@ func (c *columnBuilder) AppendToBuffer(writer *utils.BufferWriter) error {
 				if err != nil {
 					return utils.StackError(err, "Failed to write geopoint value at row %d", row)
 				}
-			case GeoShape:
+			case GeoShape, String:
 				goVal := value.(GoDataValue)
 				dataWriter := utils.NewStreamDataWriter(valueWriter)
 				err := goVal.Write(&dataWriter)
 				if err != nil {
-					return utils.StackError(err, "Failed to write geoshape value at row %d", row)
+					return utils.StackError(err, "Failed to write %s value at row %d", DataTypeName[c.dataType], row)
 				}
 				// advance current offset
 				currentValueOffset += uint32(goVal.GetSerBytes())
@@ -343,6 +344,19 @@ func (u *UpsertBatchBuilder) AddColumnWithUpdateMode(columnID int, dataType Data
 	return nil
 }
 
+// AddDecimalColumn add a new Decimal column to the builder with update mode info, values are scaled
+// by 10^decimalScale when set. Initially, new columns have all values set to null.
+func (u *UpsertBatchBuilder) AddDecimalColumn(columnID int, decimalScale int, updateMode ColumnUpdateMode) error {
+	if decimalScale < 0 || decimalScale > MaxDecimalScale {
+		return utils.StackError(nil, "Invalid decimal scale %d", decimalScale)
+	}
+	if err := u.AddColumnWithUpdateMode(columnID, Decimal, updateMode); err != nil {
+		return err
+	}
+	u.columns[len(u.columns)-1].decimalScale = decimalScale
+	return nil
+}
+
 // AddRow increases the number of rows in the batch by 1. A new row with all nil values is appended
 // to the row array.
 func (u *UpsertBatchBuilder) AddRow() {
@@ -462,7 +476,7 @@ func AdditionUpdate(oldValue, newValue unsafe.Pointer, dataType DataType) {
 		*(*int32)(oldValue) = *(*int32)(oldValue) + *(*int32)(newValue)
 	case Uint32:
 		*(*uint32)(oldValue) = *(*uint32)(oldValue) + *(*uint32)(newValue)
-	case Int64:
+	case Int64, Decimal:
 		*(*int64)(oldValue) = *(*int64)(oldValue) + *(*int64)(newValue)
 	case Float32:
 		*(*float32)(oldValue) = *(*float32)(oldValue) + *(*float32)(newValue)
@@ -485,7 +499,7 @@ func MinMaxUpdate(oldValue, newValue unsafe.Pointer, dataType DataType, cmpFunc
 			*(*int32)(oldValue) = *(*int32)(newValue)
 		case Uint32:
 			*(*uint32)(oldValue) = *(*uint32)(newValue)
-		case Int64:
+		case Int64, Decimal:
 			*(*int64)(oldValue) = *(*int64)(newValue)
 		case Float32:
 			*(*float32)(oldValue) = *(*float32)(newValue)
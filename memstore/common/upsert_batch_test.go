@@ -20,6 +20,7 @@ import (
 
 	"github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	metaCom "github.com/uber/aresdb/metastore/common"
 	"github.com/uber/aresdb/utils"
 	"time"
 	"unsafe"
@@ -498,6 +499,134 @@ var _ = ginkgo.Describe("upsert batch", func() {
 		Ω(dv.ConvertToHumanReadable(Bool)).Should(Equal(false))
 	})
 
+	ginkgo.It("PromoteEnumColumn should work", func() {
+		builder := NewUpsertBatchBuilder()
+		builder.AddColumn(0, Uint32)
+		builder.AddColumn(1, SmallEnum)
+		builder.AddRow()
+		builder.SetValue(0, 0, uint32(1))
+		builder.SetValue(0, 1, uint8(3))
+		builder.AddRow()
+		builder.SetValue(1, 0, uint32(2))
+		builder.SetValue(1, 1, nil)
+		builder.AddRow()
+		builder.SetValue(2, 0, uint32(3))
+		builder.SetValue(2, 1, uint8(255))
+
+		buffer, err := builder.ToByteArray()
+		Ω(err).Should(BeNil())
+		batch, err := NewUpsertBatch(buffer)
+		Ω(err).Should(BeNil())
+
+		Ω(batch.PromoteEnumColumn(0)).ShouldNot(BeNil())
+		Ω(batch.PromoteEnumColumn(2)).ShouldNot(BeNil())
+		Ω(batch.PromoteEnumColumn(1)).Should(BeNil())
+
+		columnType, err := batch.GetColumnType(1)
+		Ω(err).Should(BeNil())
+		Ω(columnType).Should(Equal(BigEnum))
+
+		value, valid, err := batch.GetValue(0, 1)
+		Ω(err).Should(BeNil())
+		Ω(valid).Should(BeTrue())
+		Ω(*(*uint16)(value)).Should(Equal(uint16(3)))
+		_, valid, err = batch.GetValue(1, 1)
+		Ω(err).Should(BeNil())
+		Ω(valid).Should(BeFalse())
+		value, valid, err = batch.GetValue(2, 1)
+		Ω(err).Should(BeNil())
+		Ω(valid).Should(BeTrue())
+		Ω(*(*uint16)(value)).Should(Equal(uint16(255)))
+
+		// other columns are not affected
+		value, valid, err = batch.GetValue(2, 0)
+		Ω(err).Should(BeNil())
+		Ω(valid).Should(BeTrue())
+		Ω(*(*uint32)(value)).Should(Equal(uint32(3)))
+
+		// already promoted
+		Ω(batch.PromoteEnumColumn(1)).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("ReduceHLLColumnPrecision should work", func() {
+		hllValue := utils.ComputeHLLValue(0x0f0f0f0f0f0f0f0f)
+		builder := NewUpsertBatchBuilder()
+		builder.AddColumn(0, Uint32)
+		builder.AddColumn(1, SmallEnum)
+		builder.AddRow()
+		builder.SetValue(0, 0, hllValue)
+		builder.SetValue(0, 1, uint8(3))
+		builder.AddRow()
+		builder.SetValue(1, 0, nil)
+		builder.SetValue(1, 1, uint8(1))
+
+		buffer, err := builder.ToByteArray()
+		Ω(err).Should(BeNil())
+		batch, err := NewUpsertBatch(buffer)
+		Ω(err).Should(BeNil())
+
+		Ω(batch.ReduceHLLColumnPrecision(1, 8)).ShouldNot(BeNil())
+		Ω(batch.ReduceHLLColumnPrecision(2, 8)).ShouldNot(BeNil())
+		Ω(batch.ReduceHLLColumnPrecision(0, 8)).Should(BeNil())
+
+		value, valid, err := batch.GetValue(0, 0)
+		Ω(err).Should(BeNil())
+		Ω(valid).Should(BeTrue())
+		Ω(*(*uint32)(value)).Should(Equal(utils.ComputeHLLValueWithPrecision(0x0f0f0f0f0f0f0f0f, 8)))
+		_, valid, err = batch.GetValue(1, 0)
+		Ω(err).Should(BeNil())
+		Ω(valid).Should(BeFalse())
+
+		// reducing again is a noop.
+		Ω(batch.ReduceHLLColumnPrecision(0, 8)).Should(BeNil())
+		value, _, _ = batch.GetValue(0, 0)
+		Ω(*(*uint32)(value)).Should(Equal(utils.ComputeHLLValueWithPrecision(0x0f0f0f0f0f0f0f0f, 8)))
+	})
+
+	ginkgo.It("RemapEnumColumn should work", func() {
+		builder := NewUpsertBatchBuilder()
+		builder.AddColumn(0, Uint32)
+		builder.AddColumn(1, SmallEnum)
+		builder.AddColumn(2, BigEnum)
+		for row, value := range []uint8{0, 2, 3} {
+			builder.AddRow()
+			builder.SetValue(row, 0, uint32(row))
+			builder.SetValue(row, 1, value)
+			builder.SetValue(row, 2, uint16(value))
+		}
+		builder.SetValue(1, 1, nil)
+
+		buffer, err := builder.ToByteArray()
+		Ω(err).Should(BeNil())
+		batch, err := NewUpsertBatch(buffer)
+		Ω(err).Should(BeNil())
+
+		// case 1 dropped by the first compaction and case 0 by the second.
+		compactions := []metaCom.EnumDictCompaction{
+			{Remap: []int{0, metaCom.DroppedEnumID, 1, 2}},
+			{Remap: []int{metaCom.DroppedEnumID, 0, 1, 2}},
+		}
+		Ω(batch.RemapEnumColumn(0, compactions)).ShouldNot(BeNil())
+		Ω(batch.RemapEnumColumn(3, compactions)).ShouldNot(BeNil())
+		Ω(batch.RemapEnumColumn(1, compactions)).Should(BeNil())
+		Ω(batch.RemapEnumColumn(2, compactions)).Should(BeNil())
+
+		for row, expected := range []int{0, 0, 1} {
+			value, valid, err := batch.GetValue(row, 1)
+			Ω(err).Should(BeNil())
+			Ω(valid).Should(Equal(row != 1))
+			if valid {
+				Ω(int(*(*uint8)(value))).Should(Equal(expected))
+			}
+			value, valid, err = batch.GetValue(row, 2)
+			Ω(err).Should(BeNil())
+			Ω(valid).Should(BeTrue())
+			Ω(int(*(*uint16)(value))).Should(Equal(expected))
+		}
+		// the raw buffer is not modified.
+		Ω(batch.GetBuffer()).Should(Equal(buffer))
+	})
+
 	ginkgo.It("Test ExtractBackfillBatch remove invalid columns", func() {
 		builder := NewUpsertBatchBuilder()
 		builder.AddColumn(0, Float32)
@@ -0,0 +1,278 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"sort"
+
+	metaCom "github.com/uber/aresdb/metastore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	// number of clusters when not configured is the square root of the number of vectors
+	// capped by this value to bound the time of building the index at archiving.
+	maxDefaultIVFLists = 256
+	// k-means is trained on at most this many vectors per cluster.
+	ivfTrainingSamplesPerList = 32
+	ivfTrainingIterations     = 10
+	// rows of a cluster are read in chunks so that memory allocated for a corrupted length is
+	// bounded by the data actually read.
+	ivfReadChunkSize = 1 << 16
+)
+
+// ConvertToVector converts input into a vector of the dimension at best effort, it returns
+// false if the input is not an array of exactly dimension numbers.
+func ConvertToVector(value interface{}, dimension int) ([]float32, bool) {
+	converted, err := ConvertToArrayValue(ArrayFloat32, value)
+	if err != nil {
+		return nil, false
+	}
+	array, ok := converted.(*ArrayValue)
+	if !ok || array.GetLength() != dimension {
+		return nil, false
+	}
+	vector := make([]float32, dimension)
+	for i, item := range array.Items {
+		f, ok := item.(float32)
+		if !ok {
+			return nil, false
+		}
+		vector[i] = f
+	}
+	return vector, true
+}
+
+// ReadVector reads the value of a vector column stored as Float32 array, it returns false if
+// the value is null, is not of the dimension or has null elements.
+func ReadVector(value DataValue, dimension int) ([]float32, bool) {
+	if !value.Valid || value.OtherVal == nil {
+		return nil, false
+	}
+	reader := NewArrayValueReader(ArrayFloat32, value.OtherVal)
+	if reader.GetLength() != dimension {
+		return nil, false
+	}
+	vector := make([]float32, dimension)
+	for i := range vector {
+		if !reader.IsItemValid(i) {
+			return nil, false
+		}
+		vector[i] = *(*float32)(reader.Get(i))
+	}
+	return vector, true
+}
+
+// SquaredL2Distance returns the squared euclidean distance between two vectors of the same
+// dimension.
+func SquaredL2Distance(a, b []float32) float32 {
+	var distance float32
+	for i := range a {
+		d := a[i] - b[i]
+		distance += d * d
+	}
+	return distance
+}
+
+// IVFIndex is an inverted file index of a vector column in an archive batch. Vectors are
+// clustered by k-means and each cluster keeps the rows of its vectors, so that nearest
+// neighbor search only compares the query vector with rows of the clusters closest to it.
+// Null rows and rows not of the dimension of the column are not indexed.
+type IVFIndex struct {
+	dimension int
+	centroids [][]float32
+	lists     [][]uint32
+}
+
+// NewIVFIndex creates an empty IVFIndex.
+func NewIVFIndex() *IVFIndex {
+	return &IVFIndex{}
+}
+
+// BuildIVFIndex builds the index of vectors of the rows with numLists clusters, 0 for the
+// square root of the number of vectors. K-means is trained on an evenly spaced sample of
+// the vectors, all vectors are then assigned to their nearest centroids.
+func BuildIVFIndex(dimension, numLists int, rows []uint32, vectors [][]float32) *IVFIndex {
+	index := &IVFIndex{dimension: dimension}
+	if len(vectors) == 0 {
+		return index
+	}
+	if numLists <= 0 {
+		numLists = int(math.Sqrt(float64(len(vectors))))
+		if numLists > maxDefaultIVFLists {
+			numLists = maxDefaultIVFLists
+		}
+	}
+	if numLists > len(vectors) {
+		numLists = len(vectors)
+	}
+
+	sample := vectors
+	if maxSamples := numLists * ivfTrainingSamplesPerList; len(vectors) > maxSamples {
+		sample = make([][]float32, maxSamples)
+		for i := range sample {
+			sample[i] = vectors[i*len(vectors)/maxSamples]
+		}
+	}
+
+	centroids := make([][]float32, numLists)
+	for i := range centroids {
+		centroids[i] = append([]float32(nil), sample[i*len(sample)/numLists]...)
+	}
+	sums := make([][]float64, numLists)
+	for i := range sums {
+		sums[i] = make([]float64, dimension)
+	}
+	counts := make([]int, numLists)
+	for iteration := 0; iteration < ivfTrainingIterations; iteration++ {
+		for i := range sums {
+			for d := range sums[i] {
+				sums[i][d] = 0
+			}
+			counts[i] = 0
+		}
+		for _, vector := range sample {
+			cluster := nearestCentroid(centroids, vector)
+			counts[cluster]++
+			for d, value := range vector {
+				sums[cluster][d] += float64(value)
+			}
+		}
+		// centroids of empty clusters are kept as is.
+		for i, count := range counts {
+			if count == 0 {
+				continue
+			}
+			for d := range centroids[i] {
+				centroids[i][d] = float32(sums[i][d] / float64(count))
+			}
+		}
+	}
+
+	index.centroids = centroids
+	index.lists = make([][]uint32, numLists)
+	for i, vector := range vectors {
+		cluster := nearestCentroid(centroids, vector)
+		index.lists[cluster] = append(index.lists[cluster], rows[i])
+	}
+	return index
+}
+
+func nearestCentroid(centroids [][]float32, vector []float32) int {
+	nearest, nearestDistance := 0, float32(math.MaxFloat32)
+	for i, centroid := range centroids {
+		if distance := SquaredL2Distance(centroid, vector); distance < nearestDistance {
+			nearest, nearestDistance = i, distance
+		}
+	}
+	return nearest
+}
+
+// Dimension returns the dimension of indexed vectors.
+func (idx *IVFIndex) Dimension() int {
+	return idx.dimension
+}
+
+// NumLists returns the number of clusters.
+func (idx *IVFIndex) NumLists() int {
+	return len(idx.centroids)
+}
+
+// Candidates returns rows of the probes clusters nearest to the query vector in ascending
+// order. Non positive probes probe 1/8 of the clusters.
+func (idx *IVFIndex) Candidates(query []float32, probes int) []uint32 {
+	if probes <= 0 {
+		probes = (len(idx.centroids) + 7) / 8
+	}
+	if probes > len(idx.centroids) {
+		probes = len(idx.centroids)
+	}
+	clusters := make([]int, len(idx.centroids))
+	distances := make([]float32, len(idx.centroids))
+	for i, centroid := range idx.centroids {
+		clusters[i] = i
+		distances[i] = SquaredL2Distance(centroid, query)
+	}
+	sort.Slice(clusters, func(i, j int) bool { return distances[clusters[i]] < distances[clusters[j]] })
+
+	var rows []uint32
+	for _, cluster := range clusters[:probes] {
+		rows = append(rows, idx.lists[cluster]...)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i] < rows[j] })
+	return rows
+}
+
+// Write serializes the index to the writer as the dimension and the number of clusters
+// followed by the centroid, the number of rows and the rows of each cluster.
+func (idx *IVFIndex) Write(writer io.Writer) error {
+	if err := binary.Write(writer, binary.LittleEndian, [2]uint32{uint32(idx.dimension), uint32(len(idx.centroids))}); err != nil {
+		return err
+	}
+	for i, centroid := range idx.centroids {
+		if err := binary.Write(writer, binary.LittleEndian, centroid); err != nil {
+			return err
+		}
+		if err := binary.Write(writer, binary.LittleEndian, uint32(len(idx.lists[i]))); err != nil {
+			return err
+		}
+		if err := binary.Write(writer, binary.LittleEndian, idx.lists[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read deserializes the index written by Write from the reader.
+func (idx *IVFIndex) Read(reader io.Reader) error {
+	var header [2]uint32
+	if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	dimension, numLists := int(header[0]), int(header[1])
+	if dimension > metaCom.MaxVectorDimension || numLists > metaCom.MaxVectorIndexLists {
+		return utils.StackError(nil, "invalid vector index of dimension %d with %d lists", dimension, numLists)
+	}
+
+	idx.dimension = dimension
+	idx.centroids = make([][]float32, numLists)
+	idx.lists = make([][]uint32, numLists)
+	for i := 0; i < numLists; i++ {
+		idx.centroids[i] = make([]float32, dimension)
+		if err := binary.Read(reader, binary.LittleEndian, idx.centroids[i]); err != nil {
+			return err
+		}
+		var length uint32
+		if err := binary.Read(reader, binary.LittleEndian, &length); err != nil {
+			return err
+		}
+		for remaining := int(length); remaining > 0; {
+			chunkSize := remaining
+			if chunkSize > ivfReadChunkSize {
+				chunkSize = ivfReadChunkSize
+			}
+			chunk := make([]uint32, chunkSize)
+			if err := binary.Read(reader, binary.LittleEndian, chunk); err != nil {
+				return err
+			}
+			idx.lists[i] = append(idx.lists[i], chunk...)
+			remaining -= len(chunk)
+		}
+	}
+	return nil
+}
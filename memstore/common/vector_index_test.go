@@ -0,0 +1,105 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unsafe"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/utils"
+)
+
+var _ = ginkgo.Describe("vector index", func() {
+	ginkgo.It("should convert and read vectors", func() {
+		vector, ok := ConvertToVector([]interface{}{1, 2.5, "-3"}, 3)
+		Ω(ok).Should(BeTrue())
+		Ω(vector).Should(Equal([]float32{1, 2.5, -3}))
+		vector, ok = ConvertToVector("[1,2.5,-3]", 3)
+		Ω(ok).Should(BeTrue())
+		Ω(vector).Should(Equal([]float32{1, 2.5, -3}))
+
+		_, ok = ConvertToVector([]interface{}{1, 2}, 3)
+		Ω(ok).Should(BeFalse())
+		_, ok = ConvertToVector([]interface{}{1, nil, 3}, 3)
+		Ω(ok).Should(BeFalse())
+		_, ok = ConvertToVector("abc", 3)
+		Ω(ok).Should(BeFalse())
+
+		array := NewArrayValue(Float32)
+		array.AddItem(float32(1))
+		array.AddItem(float32(-2))
+		buffer := make([]byte, array.GetSerBytes())
+		writer := utils.NewBufferWriter(buffer)
+		Ω(array.Write(&writer)).Should(BeNil())
+		value := DataValue{Valid: true, DataType: ArrayFloat32, OtherVal: unsafe.Pointer(&buffer[0])}
+		vector, ok = ReadVector(value, 2)
+		Ω(ok).Should(BeTrue())
+		Ω(vector).Should(Equal([]float32{1, -2}))
+		_, ok = ReadVector(value, 3)
+		Ω(ok).Should(BeFalse())
+		_, ok = ReadVector(NullDataValue, 2)
+		Ω(ok).Should(BeFalse())
+
+		Ω(SquaredL2Distance([]float32{1, 2}, []float32{4, 6})).Should(Equal(float32(25)))
+	})
+
+	ginkgo.It("should cluster vectors and return candidates of nearest clusters", func() {
+		var rows []uint32
+		var vectors [][]float32
+		for i := 0; i < 100; i++ {
+			// two well separated clusters around (0, 0) and (100, 100).
+			offset := float32(0)
+			if i%2 == 1 {
+				offset = 100
+			}
+			rows = append(rows, uint32(i))
+			vectors = append(vectors, []float32{offset + float32(i%5), offset - float32(i%3)})
+		}
+		idx := BuildIVFIndex(2, 2, rows, vectors)
+		Ω(idx.Dimension()).Should(Equal(2))
+		Ω(idx.NumLists()).Should(Equal(2))
+
+		candidates := idx.Candidates([]float32{99, 99}, 1)
+		Ω(candidates).Should(HaveLen(50))
+		for _, row := range candidates {
+			Ω(row % 2).Should(Equal(uint32(1)))
+		}
+		Ω(idx.Candidates([]float32{0, 0}, 2)).Should(Equal(rows))
+		// 1/8 of the clusters rounded up.
+		Ω(idx.Candidates([]float32{0, 0}, 0)).Should(HaveLen(50))
+
+		// number of clusters defaults to the square root of the number of vectors.
+		Ω(BuildIVFIndex(2, 0, rows, vectors).NumLists()).Should(Equal(10))
+		Ω(BuildIVFIndex(2, 0, nil, nil).NumLists()).Should(Equal(0))
+	})
+
+	ginkgo.It("should serialize and deserialize the index", func() {
+		idx := BuildIVFIndex(2, 2, []uint32{0, 1, 2, 3}, [][]float32{{0, 0}, {10, 10}, {0, 1}, {10, 11}})
+		buffer := &bytes.Buffer{}
+		Ω(idx.Write(buffer)).Should(BeNil())
+		read := NewIVFIndex()
+		Ω(read.Read(bytes.NewReader(buffer.Bytes()))).Should(BeNil())
+		Ω(read).Should(Equal(idx))
+		Ω(read.Read(bytes.NewReader(buffer.Bytes()[:10]))).ShouldNot(BeNil())
+
+		// corrupted header is rejected before allocating.
+		corrupted := &bytes.Buffer{}
+		binary.Write(corrupted, binary.LittleEndian, [2]uint32{1 << 30, 1 << 30})
+		Ω(read.Read(bytes.NewReader(corrupted.Bytes()))).ShouldNot(BeNil())
+	})
+})
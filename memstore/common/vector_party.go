@@ -40,6 +40,40 @@ const (
 	MaxColumnMode
 )
 
+// VectorPartyEncoding represents how vectors of an archive vector party are encoded on disk.
+// Encoded vectors are decoded into plain vectors when the vector party is loaded, run length
+// encoded vectors are kept as runs as well for query kernels to scan.
+type VectorPartyEncoding uint16
+
+const (
+	// PlainEncoding stores value and null vectors as they are in memory.
+	PlainEncoding VectorPartyEncoding = iota
+	// RunLengthEncoding stores the end row, validity and value of each run of consecutive rows
+	// with the same value and validity. It suits sorted and low cardinality columns.
+	RunLengthEncoding
+	// DeltaEncoding stores the first value and differences between consecutive values bit packed
+	// with the least bits required. It suits integer columns with low variance like time columns.
+	// Null vector is stored as is.
+	DeltaEncoding
+	// MaxVectorPartyEncoding represents the upper limit of vector party encodings
+	MaxVectorPartyEncoding
+)
+
+// IsRunLengthEncodable returns whether vectors of the data type can be run length encoded.
+func IsRunLengthEncodable(dataType DataType) bool {
+	switch dataType {
+	case Int8, Uint8, Int16, Uint16, Int32, Uint32,
+		Float32, SmallEnum, BigEnum, GeoPoint, Int64, Decimal:
+		return true
+	}
+	return false
+}
+
+// IsDeltaEncodable returns whether vectors of the data type can be delta encoded.
+func IsDeltaEncodable(dataType DataType) bool {
+	return IsRunLengthEncodable(dataType) && dataType != Float32 && dataType != GeoPoint
+}
+
 // HostVectorPartySlice stores pointers to data for a column in host memory.
 // And its start index and Bytes
 type HostVectorPartySlice struct {
@@ -0,0 +1,124 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	// WritePositionHeaderKey is the response header of ingestion requests carrying the write
+	// position of the table shard after the upsert batch is applied.
+	WritePositionHeaderKey = "X-Ares-Write-Position"
+	// ReadAfterHeaderKey is the request header of queries carrying the write positions of
+	// table shards that must be applied before the queries are executed.
+	ReadAfterHeaderKey = "X-Ares-Read-After"
+	// ReadAfterTimeoutHeaderKey is the request header of queries carrying the max milliseconds
+	// to wait for write positions in ReadAfterHeaderKey to be applied.
+	ReadAfterTimeoutHeaderKey = "X-Ares-Read-After-Timeout"
+)
+
+// WritePosition is the position of an upsert batch in the redo log (local redo log file or
+// kafka offset) of a table shard. Positions of a table shard increase in the order batches
+// are applied.
+type WritePosition struct {
+	RedoLogFile int64
+	Offset      uint32
+}
+
+// Before tells whether the position is before the other position.
+func (p WritePosition) Before(other WritePosition) bool {
+	return p.RedoLogFile < other.RedoLogFile ||
+		(p.RedoLogFile == other.RedoLogFile && p.Offset < other.Offset)
+}
+
+// String formats the position as <redoLogFile>:<offset>.
+func (p WritePosition) String() string {
+	return fmt.Sprintf("%d:%d", p.RedoLogFile, p.Offset)
+}
+
+// ParseWritePosition parses the position formatted by WritePosition.String.
+func ParseWritePosition(s string) (position WritePosition, err error) {
+	segments := strings.Split(s, ":")
+	if len(segments) != 2 {
+		return position, utils.StackError(nil, "invalid write position %s", s)
+	}
+	if position.RedoLogFile, err = strconv.ParseInt(segments[0], 10, 64); err != nil {
+		return position, utils.StackError(err, "invalid redo log file of write position %s", s)
+	}
+	offset, err := strconv.ParseUint(segments[1], 10, 32)
+	if err != nil {
+		return position, utils.StackError(err, "invalid offset of write position %s", s)
+	}
+	position.Offset = uint32(offset)
+	return position, nil
+}
+
+// TableShardID identifies a shard of a table.
+type TableShardID struct {
+	Table string
+	Shard int
+}
+
+// WritePositions maps table shards to write positions.
+type WritePositions map[TableShardID]WritePosition
+
+// Advance moves the position of the table shard forward to position.
+func (positions WritePositions) Advance(id TableShardID, position WritePosition) {
+	if current, ok := positions[id]; !ok || current.Before(position) {
+		positions[id] = position
+	}
+}
+
+// String formats the positions as comma separated <table>/<shard>@<position> sorted by table
+// shards, which is the value of ReadAfterHeaderKey.
+func (positions WritePositions) String() string {
+	entries := make([]string, 0, len(positions))
+	for id, position := range positions {
+		entries = append(entries, fmt.Sprintf("%s/%d@%s", id.Table, id.Shard, position))
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// ParseWritePositions parses the positions formatted by WritePositions.String.
+func ParseWritePositions(s string) (WritePositions, error) {
+	positions := WritePositions{}
+	if s == "" {
+		return positions, nil
+	}
+	for _, entry := range strings.Split(s, ",") {
+		atIndex := strings.LastIndex(entry, "@")
+		slashIndex := strings.LastIndex(entry, "/")
+		if atIndex < 0 || slashIndex < 0 || slashIndex > atIndex {
+			return nil, utils.StackError(nil, "invalid table shard write position %s", entry)
+		}
+		shard, err := strconv.Atoi(entry[slashIndex+1 : atIndex])
+		if err != nil {
+			return nil, utils.StackError(err, "invalid shard of table shard write position %s", entry)
+		}
+		position, err := ParseWritePosition(entry[atIndex+1:])
+		if err != nil {
+			return nil, err
+		}
+		positions.Advance(TableShardID{Table: strings.TrimSpace(entry[:slashIndex]), Shard: shard}, position)
+	}
+	return positions, nil
+}
@@ -0,0 +1,58 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("write position", func() {
+	ginkgo.It("WritePosition should be ordered and parsed", func() {
+		Ω(WritePosition{1, 5}.Before(WritePosition{2, 0})).Should(BeTrue())
+		Ω(WritePosition{2, 1}.Before(WritePosition{2, 5})).Should(BeTrue())
+		Ω(WritePosition{2, 5}.Before(WritePosition{2, 5})).Should(BeFalse())
+
+		position, err := ParseWritePosition(WritePosition{1570000000, 3}.String())
+		Ω(err).Should(BeNil())
+		Ω(position).Should(Equal(WritePosition{1570000000, 3}))
+
+		for _, invalid := range []string{"", "1", "a:1", "1:-1"} {
+			_, err = ParseWritePosition(invalid)
+			Ω(err).ShouldNot(BeNil())
+		}
+	})
+
+	ginkgo.It("WritePositions should advance and be parsed", func() {
+		positions := WritePositions{}
+		positions.Advance(TableShardID{"b", 0}, WritePosition{1, 2})
+		positions.Advance(TableShardID{"a", 1}, WritePosition{3, 4})
+		positions.Advance(TableShardID{"a", 1}, WritePosition{3, 1})
+		Ω(positions.String()).Should(Equal("a/1@3:4,b/0@1:2"))
+
+		parsed, err := ParseWritePositions(positions.String())
+		Ω(err).Should(BeNil())
+		Ω(parsed).Should(Equal(positions))
+
+		parsed, err = ParseWritePositions("")
+		Ω(err).Should(BeNil())
+		Ω(parsed).Should(BeEmpty())
+
+		for _, invalid := range []string{"a", "a/1", "a@1:2", "a/x@1:2", "a/1@1"} {
+			_, err = ParseWritePositions(invalid)
+			Ω(err).ShouldNot(BeNil())
+		}
+	})
+})
@@ -0,0 +1,157 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"sort"
+)
+
+// ZoneMap holds the min and max values of a numeric column in an archive batch, so that batches
+// that cannot match filters of the column are skipped without scanning. Values of float columns
+// are kept in MinFloat and MaxFloat and values of other columns in MinInt and MaxInt. Values of
+// Decimal columns are kept scaled by 10^DecimalScale and values of Time64 columns, which are
+// stored as Int64, in milliseconds since epoch.
+type ZoneMap struct {
+	// false if all values of the column are null in the batch.
+	HasValues bool
+	MinInt    int64
+	MaxInt    int64
+	MinFloat  float64
+	MaxFloat  float64
+}
+
+// IsZoneMapType returns whether zone maps are kept for columns of the data type. Time64 columns
+// are covered by Int64.
+func IsZoneMapType(dataType DataType) bool {
+	switch dataType {
+	case Int8, Uint8, Int16, Uint16, Int32, Uint32, Int64, Decimal, Float32:
+		return true
+	}
+	return false
+}
+
+// Update extends the range of the zone map with the value of the data type, null values
+// are ignored.
+func (z *ZoneMap) Update(value DataValue, dataType DataType) {
+	if !value.Valid {
+		return
+	}
+
+	if dataType == Float32 {
+		v := float64(*(*float32)(value.OtherVal))
+		if !z.HasValues || v < z.MinFloat {
+			z.MinFloat = v
+		}
+		if !z.HasValues || v > z.MaxFloat {
+			z.MaxFloat = v
+		}
+		z.HasValues = true
+		return
+	}
+
+	var v int64
+	switch dataType {
+	case Int8:
+		v = int64(*(*int8)(value.OtherVal))
+	case Uint8:
+		v = int64(*(*uint8)(value.OtherVal))
+	case Int16:
+		v = int64(*(*int16)(value.OtherVal))
+	case Uint16:
+		v = int64(*(*uint16)(value.OtherVal))
+	case Int32:
+		v = int64(*(*int32)(value.OtherVal))
+	case Uint32:
+		v = int64(*(*uint32)(value.OtherVal))
+	case Int64, Decimal:
+		v = *(*int64)(value.OtherVal)
+	default:
+		return
+	}
+	if !z.HasValues || v < z.MinInt {
+		z.MinInt = v
+	}
+	if !z.HasValues || v > z.MaxInt {
+		z.MaxInt = v
+	}
+	z.HasValues = true
+}
+
+// zoneMapRecord is the on disk layout of a zone map.
+type zoneMapRecord struct {
+	ColumnID  uint32
+	HasValues uint8
+	MinInt    int64
+	MaxInt    int64
+	MinFloat  uint64
+	MaxFloat  uint64
+}
+
+// WriteZoneMaps serializes zone maps of an archive batch by column id to the writer as the
+// number of columns followed by the zone map of each column in ascending order of column ids.
+func WriteZoneMaps(writer io.Writer, zoneMaps map[int]*ZoneMap) error {
+	columnIDs := make([]int, 0, len(zoneMaps))
+	for columnID := range zoneMaps {
+		columnIDs = append(columnIDs, columnID)
+	}
+	sort.Ints(columnIDs)
+
+	if err := binary.Write(writer, binary.LittleEndian, uint32(len(columnIDs))); err != nil {
+		return err
+	}
+	for _, columnID := range columnIDs {
+		zoneMap := zoneMaps[columnID]
+		record := zoneMapRecord{
+			ColumnID: uint32(columnID),
+			MinInt:   zoneMap.MinInt,
+			MaxInt:   zoneMap.MaxInt,
+			MinFloat: math.Float64bits(zoneMap.MinFloat),
+			MaxFloat: math.Float64bits(zoneMap.MaxFloat),
+		}
+		if zoneMap.HasValues {
+			record.HasValues = 1
+		}
+		if err := binary.Write(writer, binary.LittleEndian, &record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadZoneMaps deserializes zone maps written by WriteZoneMaps from the reader.
+func ReadZoneMaps(reader io.Reader) (map[int]*ZoneMap, error) {
+	var numColumns uint32
+	if err := binary.Read(reader, binary.LittleEndian, &numColumns); err != nil {
+		return nil, err
+	}
+	zoneMaps := make(map[int]*ZoneMap, numColumns)
+	for i := uint32(0); i < numColumns; i++ {
+		var record zoneMapRecord
+		if err := binary.Read(reader, binary.LittleEndian, &record); err != nil {
+			return nil, err
+		}
+		zoneMaps[int(record.ColumnID)] = &ZoneMap{
+			HasValues: record.HasValues != 0,
+			MinInt:    record.MinInt,
+			MaxInt:    record.MaxInt,
+			MinFloat:  math.Float64frombits(record.MinFloat),
+			MaxFloat:  math.Float64frombits(record.MaxFloat),
+		}
+	}
+	return zoneMaps, nil
+}
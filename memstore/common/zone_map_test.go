@@ -0,0 +1,79 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"unsafe"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metaCom "github.com/uber/aresdb/metastore/common"
+)
+
+var _ = ginkgo.Describe("zone map", func() {
+	int64Value := func(v int64) DataValue {
+		return DataValue{Valid: true, OtherVal: unsafe.Pointer(&v)}
+	}
+
+	ginkgo.It("IsZoneMapType should work", func() {
+		Ω(IsZoneMapType(Uint32)).Should(BeTrue())
+		Ω(IsZoneMapType(Int64)).Should(BeTrue())
+		Ω(IsZoneMapType(Float32)).Should(BeTrue())
+		Ω(IsZoneMapType(Decimal)).Should(BeTrue())
+		// Time64 columns are stored as Int64.
+		Ω(IsZoneMapType(DataTypeForColumn(metaCom.Column{Type: metaCom.Time64}))).Should(BeTrue())
+
+		Ω(IsZoneMapType(Bool)).Should(BeFalse())
+		Ω(IsZoneMapType(SmallEnum)).Should(BeFalse())
+		Ω(IsZoneMapType(UUID)).Should(BeFalse())
+		Ω(IsZoneMapType(GeoShape)).Should(BeFalse())
+	})
+
+	ginkgo.It("Update should keep scaled values of Decimal columns", func() {
+		zoneMap := &ZoneMap{}
+		zoneMap.Update(NullDataValue, Decimal)
+		Ω(zoneMap.HasValues).Should(BeFalse())
+
+		// 12.34, -0.5 and 7 with scale 2.
+		for _, v := range []int64{1234, -50, 700} {
+			zoneMap.Update(int64Value(v), Decimal)
+		}
+		Ω(*zoneMap).Should(Equal(ZoneMap{HasValues: true, MinInt: -50, MaxInt: 1234}))
+	})
+
+	ginkgo.It("Update should keep milliseconds of Time64 columns", func() {
+		dataType := DataTypeForColumn(metaCom.Column{Type: metaCom.Time64})
+		zoneMap := &ZoneMap{}
+		for _, v := range []int64{1549123200123, 1549094400000, 1549209599999} {
+			zoneMap.Update(int64Value(v), dataType)
+		}
+		Ω(*zoneMap).Should(Equal(ZoneMap{HasValues: true, MinInt: 1549094400000, MaxInt: 1549209599999}))
+	})
+
+	ginkgo.It("WriteZoneMaps and ReadZoneMaps should work", func() {
+		zoneMaps := map[int]*ZoneMap{
+			0: {HasValues: true, MinInt: 1549094400000, MaxInt: 1549209599999},
+			1: {HasValues: true, MinInt: -50, MaxInt: 1234},
+			2: {HasValues: true, MinFloat: -1.5, MaxFloat: 2.5},
+			3: {},
+		}
+		var buffer bytes.Buffer
+		Ω(WriteZoneMaps(&buffer, zoneMaps)).Should(BeNil())
+		read, err := ReadZoneMaps(&buffer)
+		Ω(err).Should(BeNil())
+		Ω(read).Should(Equal(zoneMaps))
+	})
+})
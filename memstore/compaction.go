@@ -0,0 +1,76 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	memCom "github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// Compact merges sparse live batches of a dimension table shard and reclaims the space of rows
+// no longer referenced by the primary key.
+func (m *memStoreImpl) Compact(table string, shardID int, reporter CompactionJobDetailReporter) error {
+	start := utils.Now()
+	jobKey := getIdentifier(table, shardID, memCom.CompactionJobType)
+	compactionTimer := utils.GetReporter(table, shardID).GetTimer(utils.CompactionTimingTotal)
+	defer func() {
+		duration := utils.Now().Sub(start)
+		compactionTimer.Record(duration)
+		reporter(jobKey, func(status *CompactionJobDetail) {
+			status.LastDuration = duration
+		})
+		utils.GetReporter(table, shardID).
+			GetCounter(utils.CompactionCount).Inc(1)
+	}()
+
+	shard, err := m.GetTableShard(table, shardID)
+	if err != nil {
+		utils.GetLogger().With("table", table, "shard", shardID, "error", err).Warn("Failed to find shard, is it deleted?")
+		return nil
+	}
+	defer shard.Users.Done()
+
+	if shard.LiveStore.CompactionManager == nil {
+		return utils.StackError(nil, "Compaction is only supported for dimension tables, got %s", table)
+	}
+
+	reporter(jobKey, func(status *CompactionJobDetail) {
+		status.Stage = CompactionCompact
+	})
+
+	numMergedBatches, numReclaimedRows, err := shard.compactLiveBatches(true)
+	if err != nil {
+		return err
+	}
+	shard.LiveStore.CompactionManager.Done()
+
+	utils.GetReporter(table, shardID).GetCounter(utils.CompactionMergedBatches).Inc(int64(numMergedBatches))
+	utils.GetReporter(table, shardID).GetCounter(utils.CompactionReclaimedRows).Inc(int64(numReclaimedRows))
+	utils.GetReporter(table, shardID).GetGauge(utils.CompactionLag).Update(0)
+
+	reporter(jobKey, func(status *CompactionJobDetail) {
+		status.NumMergedBatches = numMergedBatches
+		status.NumReclaimedRows = numReclaimedRows
+		status.Stage = CompactionComplete
+	})
+
+	utils.GetLogger().With(
+		"job", "compaction",
+		"table", table,
+		"shard", shardID,
+		"mergedBatches", numMergedBatches,
+		"reclaimedRows", numReclaimedRows).Info("Compaction done")
+	return nil
+}
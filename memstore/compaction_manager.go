@@ -0,0 +1,85 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"sync"
+	"time"
+
+	"github.com/uber/aresdb/utils"
+)
+
+// CompactionManager manages the compaction schedule of a dimension table shard.
+type CompactionManager struct {
+	sync.RWMutex `json:"-"`
+
+	// Last compaction time.
+	LastCompactionTime time.Time `json:"lastCompactionTime"`
+
+	// Specifies how often compaction runs, 0 if compaction is disabled.
+	CompactionInterval time.Duration `json:"compactionInterval"`
+
+	// Compaction is not scheduled while paused, eg. during busy periods.
+	Paused bool `json:"paused"`
+}
+
+// NewCompactionManager creates a new CompactionManager instance.
+func NewCompactionManager(shard *TableShard) *CompactionManager {
+	return &CompactionManager{
+		CompactionInterval: time.Duration(shard.Schema.Schema.Config.CompactionIntervalMinutes) * time.Minute,
+		LastCompactionTime: utils.Now(),
+	}
+}
+
+// QualifyForCompaction tells whether we can trigger a compaction job.
+func (c *CompactionManager) QualifyForCompaction() bool {
+	c.RLock()
+	defer c.RUnlock()
+	return !c.Paused && c.CompactionInterval > 0 && utils.Now().Sub(c.LastCompactionTime) >= c.CompactionInterval
+}
+
+// SetPaused pauses or resumes scheduling of compaction jobs.
+func (c *CompactionManager) SetPaused(paused bool) {
+	c.Lock()
+	defer c.Unlock()
+	c.Paused = paused
+}
+
+// IsPaused tells whether compaction is paused.
+func (c *CompactionManager) IsPaused() bool {
+	c.RLock()
+	defer c.RUnlock()
+	return c.Paused
+}
+
+// GetLag returns the time elapsed since compaction is due, 0 if it's not due yet or disabled.
+func (c *CompactionManager) GetLag() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+	if c.CompactionInterval <= 0 {
+		return 0
+	}
+	if lag := utils.Now().Sub(c.LastCompactionTime) - c.CompactionInterval; lag > 0 {
+		return lag
+	}
+	return 0
+}
+
+// Done records the completion of a compaction.
+func (c *CompactionManager) Done() {
+	c.Lock()
+	defer c.Unlock()
+	c.LastCompactionTime = utils.Now()
+}
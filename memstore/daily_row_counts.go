@@ -0,0 +1,79 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	metaCom "github.com/uber/aresdb/metastore/common"
+)
+
+// GetDailyRowCounts returns row counts of archived days of the shard maintained in metadata,
+// false if the table does not pre-aggregate daily row counts or they are not maintained yet.
+func (shard *TableShard) GetDailyRowCounts() (metaCom.DailyRowCounts, bool, error) {
+	shard.Schema.RLock()
+	enabled := shard.Schema.Schema.Config.PreAggregateDailyRowCounts
+	shard.Schema.RUnlock()
+	if !enabled {
+		return metaCom.DailyRowCounts{}, false, nil
+	}
+
+	counts, err := shard.metaStore.GetDailyRowCounts(shard.Schema.Schema.Name, shard.ShardID)
+	if err != nil || counts.Counts == nil {
+		return counts, false, err
+	}
+	return counts, true, nil
+}
+
+// updateDailyRowCounts records sizes of archive batches changed by archiving or backfill as of
+// the cutoff in metadata. Counts of all archived days are seeded from archive batch versions when
+// pre-aggregation of daily row counts is enabled, and removed once it is disabled.
+func (shard *TableShard) updateDailyRowCounts(cutoff uint32, batchSizes map[int32]int) error {
+	shard.Schema.RLock()
+	tableName := shard.Schema.Schema.Name
+	enabled := shard.Schema.Schema.Config.PreAggregateDailyRowCounts
+	shard.Schema.RUnlock()
+
+	counts, err := shard.metaStore.GetDailyRowCounts(tableName, shard.ShardID)
+	if err != nil {
+		return err
+	}
+
+	if !enabled {
+		if counts.Counts == nil {
+			return nil
+		}
+		return shard.metaStore.UpdateDailyRowCounts(tableName, shard.ShardID, metaCom.DailyRowCounts{})
+	}
+
+	if counts.Counts == nil {
+		counts.Counts = make(map[int32]int)
+		batchIDs, err := shard.metaStore.GetArchiveBatches(tableName, shard.ShardID, 0, 0)
+		if err != nil {
+			return err
+		}
+		for _, batchID := range batchIDs {
+			_, _, size, err := shard.metaStore.GetArchiveBatchVersion(tableName, shard.ShardID, batchID, cutoff)
+			if err != nil {
+				return err
+			}
+			counts.Counts[int32(batchID)] = size
+		}
+	}
+
+	for batchID, size := range batchSizes {
+		counts.Counts[batchID] = size
+	}
+	counts.ArchivingCutoff = cutoff
+	return shard.metaStore.UpdateDailyRowCounts(tableName, shard.ShardID, counts)
+}
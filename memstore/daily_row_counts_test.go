@@ -0,0 +1,70 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/memstore/common"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	metaMocks "github.com/uber/aresdb/metastore/mocks"
+)
+
+var _ = ginkgo.Describe("daily row counts", func() {
+	var metaStore *metaMocks.MetaStore
+	var shard *TableShard
+
+	ginkgo.BeforeEach(func() {
+		metaStore = &metaMocks.MetaStore{}
+		memStore := createMemStore("abc", 0, []common.DataType{common.Uint32, common.Uint8}, []int{0}, 10, true, false, metaStore, CreateMockDiskStore())
+		shard, _ = memStore.GetTableShard("abc", 0)
+		shard.Users.Done()
+	})
+
+	enable := func(enabled bool) {
+		shard.Schema.Lock()
+		shard.Schema.Schema.Config.PreAggregateDailyRowCounts = enabled
+		shard.Schema.Unlock()
+	}
+
+	ginkgo.It("should seed counts of archived days then update changed days", func() {
+		enable(true)
+		metaStore.On("GetDailyRowCounts", "abc", 0).Return(metaCom.DailyRowCounts{}, nil).Once()
+		metaStore.On("GetArchiveBatches", "abc", 0, int32(0), int32(0)).Return([]int{1, 2}, nil).Once()
+		metaStore.On("GetArchiveBatchVersion", "abc", 0, 1, uint32(86400*3)).Return(uint32(100), uint32(0), 10, nil).Once()
+		metaStore.On("GetArchiveBatchVersion", "abc", 0, 2, uint32(86400*3)).Return(uint32(86400*3), uint32(0), 5, nil).Once()
+		seeded := metaCom.DailyRowCounts{ArchivingCutoff: 86400 * 3, Counts: map[int32]int{1: 10, 2: 20}}
+		metaStore.On("UpdateDailyRowCounts", "abc", 0, seeded).Return(nil).Once()
+		Ω(shard.updateDailyRowCounts(86400*3, map[int32]int{2: 20})).Should(BeNil())
+
+		metaStore.On("GetDailyRowCounts", "abc", 0).Return(seeded, nil).Once()
+		counts, ok, err := shard.GetDailyRowCounts()
+		Ω(err).Should(BeNil())
+		Ω(ok).Should(BeTrue())
+		Ω(counts).Should(Equal(seeded))
+		metaStore.AssertExpectations(ginkgo.GinkgoT())
+	})
+
+	ginkgo.It("should remove counts once disabled", func() {
+		metaStore.On("GetDailyRowCounts", "abc", 0).Return(metaCom.DailyRowCounts{Counts: map[int32]int{1: 10}}, nil).Once()
+		metaStore.On("UpdateDailyRowCounts", "abc", 0, metaCom.DailyRowCounts{}).Return(nil).Once()
+		Ω(shard.updateDailyRowCounts(86400*3, map[int32]int{2: 20})).Should(BeNil())
+
+		_, ok, err := shard.GetDailyRowCounts()
+		Ω(err).Should(BeNil())
+		Ω(ok).Should(BeFalse())
+		metaStore.AssertExpectations(ginkgo.GinkgoT())
+	})
+})
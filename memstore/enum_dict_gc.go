@@ -0,0 +1,379 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"math"
+	"unsafe"
+
+	"github.com/uber/aresdb/memstore/common"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// CompactEnumDicts drops cases of enum dictionaries of the table no longer referenced by any
+// data, e.g. after the batches referencing them are purged out of retention. The remaining cases
+// are renumbered and live data is remapped in place, while data on disk written before the
+// compaction is remapped when loaded according to the compaction recorded in metastore.
+// Enum dictionaries of primary key columns are never compacted since primary keys are indexed
+// by enum ids.
+func (m *memStoreImpl) CompactEnumDicts(tableName string) (map[string]int, error) {
+	m.RLock()
+	tableSchema, ok := m.TableSchemas[tableName]
+	if !ok {
+		m.RUnlock()
+		return nil, metaCom.ErrTableDoesNotExist
+	}
+	var shards []*TableShard
+	for _, shard := range m.TableShards[tableName] {
+		shard.Users.Add(1)
+		shards = append(shards, shard)
+	}
+	m.RUnlock()
+	defer func() {
+		for _, shard := range shards {
+			shard.Users.Done()
+		}
+	}()
+
+	tableSchema.RLock()
+	var columnIDs []int
+	for columnID, column := range tableSchema.Schema.Columns {
+		if !column.Deleted && column.IsEnumColumn() &&
+			utils.IndexOfInt(tableSchema.Schema.PrimaryKeyColumns, columnID) < 0 {
+			columnIDs = append(columnIDs, columnID)
+		}
+	}
+	tableSchema.RUnlock()
+
+	// archived data does not change during compaction as archiving, backfill, snapshot and
+	// live batch merge are blocked.
+	for _, shard := range shards {
+		shard.columnDeletion.Lock()
+		defer shard.columnDeletion.Unlock()
+	}
+
+	droppedCases := make(map[string]int)
+	for _, columnID := range columnIDs {
+		columnName, numDropped, err := m.compactEnumDict(tableSchema, shards, columnID)
+		if err != nil {
+			return droppedCases, err
+		}
+		droppedCases[columnName] = numDropped
+		utils.GetLogger().With(
+			"table", tableName,
+			"column", columnName,
+			"droppedCases", numDropped).Info("Compacted enum dictionary")
+	}
+	return droppedCases, nil
+}
+
+// compactEnumDict compacts the enum dictionary of the column, caller should hold the column
+// deletion lock of all shards.
+func (m *memStoreImpl) compactEnumDict(tableSchema *common.TableSchema, shards []*TableShard, columnID int) (string, int, error) {
+	tableSchema.RLock()
+	tableName := tableSchema.Schema.Name
+	columnName := tableSchema.Schema.Columns[columnID].Name
+	dataType := tableSchema.ValueTypeByColumn[columnID]
+	defaultValue := tableSchema.DefaultValues[columnID]
+	tableSchema.RUnlock()
+
+	var used []bool
+	markUsed := func(value unsafe.Pointer) {
+		enumID := int(*(*uint8)(value))
+		if dataType == common.BigEnum {
+			enumID = int(*(*uint16)(value))
+		}
+		for enumID >= len(used) {
+			used = append(used, false)
+		}
+		used[enumID] = true
+	}
+	if defaultValue != nil && defaultValue.Valid {
+		markUsed(defaultValue.OtherVal)
+	}
+
+	compaction := metaCom.EnumDictCompaction{
+		CompactedAt: utils.Now().Unix(),
+	}
+	for _, shard := range shards {
+		if !tableSchema.Schema.IsFactTable {
+			continue
+		}
+		versions, err := shard.scanArchivedEnumColumn(columnID, markUsed)
+		if err != nil {
+			return columnName, 0, err
+		}
+		if compaction.ArchiveBatchVersions == nil {
+			compaction.ArchiveBatchVersions = make(map[int]map[int32]metaCom.ArchiveBatchVersion)
+		}
+		compaction.ArchiveBatchVersions[shard.ShardID] = versions
+	}
+
+	// block ingestion until live data is remapped along with the dictionary.
+	for _, shard := range shards {
+		shard.LiveStore.WriterLock.Lock()
+		defer shard.LiveStore.WriterLock.Unlock()
+		if backfillMgr := shard.LiveStore.BackfillManager; backfillMgr != nil {
+			backfillMgr.Lock()
+			defer backfillMgr.Unlock()
+			// upsert batches being backfilled are not visible here.
+			if backfillMgr.BackfillingBufferSize > 0 {
+				return columnName, 0, utils.StackError(nil,
+					"Backfill of table %s shard %d is in progress", tableName, shard.ShardID)
+			}
+		}
+	}
+
+	compaction.RedoLogPositions = make(map[int]metaCom.RedoLogPosition)
+	for _, shard := range shards {
+		shard.scanLiveEnumColumn(columnID, markUsed)
+		if tableSchema.Schema.IsFactTable {
+			backfillMgr := shard.LiveStore.BackfillManager
+			compaction.RedoLogPositions[shard.ShardID] = lastRedoLogPosition(
+				backfillMgr.CurrentRedoFile, backfillMgr.CurrentBatchOffset, backfillMgr.LastRedoFile, backfillMgr.LastBatchOffset)
+		} else {
+			snapshotMgr := shard.LiveStore.SnapshotManager
+			snapshotMgr.RLock()
+			compaction.RedoLogPositions[shard.ShardID] = lastRedoLogPosition(
+				snapshotMgr.CurrentRedoFile, snapshotMgr.CurrentBatchOffset, snapshotMgr.LastRedoFile, snapshotMgr.LastBatchOffset)
+			if compaction.SnapshotPositions == nil {
+				compaction.SnapshotPositions = make(map[int]metaCom.RedoLogPosition)
+			}
+			compaction.SnapshotPositions[shard.ShardID] = metaCom.RedoLogPosition{
+				RedoLogFile: snapshotMgr.LastRedoFile,
+				Offset:      snapshotMgr.LastBatchOffset,
+			}
+			snapshotMgr.RUnlock()
+		}
+	}
+
+	// the enum dict watcher is closed after pending cases are applied to the schema.
+	compaction, err := m.metaStore.CompactEnumDict(tableName, columnName, used, compaction)
+	if err != nil {
+		return columnName, 0, err
+	}
+
+	tableSchema.Lock()
+	tableSchema.CompactEnumDict(columnID, compaction)
+	newDefaultValue := *tableSchema.DefaultValues[columnID]
+	numCases := len(tableSchema.EnumDicts[columnName].ReverseDict)
+	compactions := []metaCom.EnumDictCompaction{compaction}
+	tableSchema.Unlock()
+
+	if err = m.watchEnumCases(tableName, columnName, numCases); err != nil {
+		return columnName, 0, err
+	}
+
+	for _, shard := range shards {
+		shard.remapLiveEnumColumn(columnID, compactions, newDefaultValue)
+		// archive batches loaded afterwards get remapped on load.
+		if tableSchema.Schema.IsFactTable {
+			shard.evictArchivedColumn(columnID)
+		}
+	}
+
+	numDropped := 0
+	for _, enumID := range compaction.Remap {
+		if enumID == metaCom.DroppedEnumID {
+			numDropped++
+		}
+	}
+	return columnName, numDropped, nil
+}
+
+// lastRedoLogPosition returns the later position of the last upsert batch queued and the last
+// upsert batch persisted.
+func lastRedoLogPosition(currentRedoFile int64, currentOffset uint32, lastRedoFile int64, lastOffset uint32) metaCom.RedoLogPosition {
+	current := metaCom.RedoLogPosition{RedoLogFile: currentRedoFile, Offset: currentOffset}
+	last := metaCom.RedoLogPosition{RedoLogFile: lastRedoFile, Offset: lastOffset}
+	if current.NotAfter(last) {
+		return last
+	}
+	return current
+}
+
+// scanArchivedEnumColumn calls markUsed with each valid value of the enum column in all archive
+// batches, and returns the versions of the batches scanned. Columns not loaded before are
+// evicted after scanning.
+func (shard *TableShard) scanArchivedEnumColumn(columnID int, markUsed func(value unsafe.Pointer)) (
+	map[int32]metaCom.ArchiveBatchVersion, error) {
+	batchIDs, err := shard.metaStore.GetArchiveBatches(shard.Schema.Schema.Name, shard.ShardID, 0, math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+
+	currentVersion := shard.ArchiveStore.GetCurrentVersion()
+	defer currentVersion.Users.Done()
+
+	versions := make(map[int32]metaCom.ArchiveBatchVersion)
+	for _, batchID := range batchIDs {
+		batch := currentVersion.RequestBatch(int32(batchID))
+		if batch.Size == 0 {
+			continue
+		}
+		versions[batch.BatchID] = metaCom.ArchiveBatchVersion{Version: batch.Version, SeqNum: batch.SeqNum}
+
+		batch.RLock()
+		loaded := columnID < len(batch.Columns) && batch.Columns[columnID] != nil
+		batch.RUnlock()
+
+		vp := batch.RequestVectorParty(columnID)
+		vp.WaitForDiskLoad()
+		batch.ForEachValue(columnID, func(row int, value common.DataValue) {
+			if value.Valid {
+				markUsed(value.OtherVal)
+			}
+		})
+		vp.Release()
+
+		if !loaded {
+			batch.TryEvict(columnID)
+		}
+	}
+	return versions, nil
+}
+
+// scanLiveEnumColumn calls markUsed with each valid value of the enum column in live batches
+// and upsert batches in the backfill queue. Caller should hold LiveStore.WriterLock and the lock
+// of backfill manager.
+func (shard *TableShard) scanLiveEnumColumn(columnID int, markUsed func(value unsafe.Pointer)) {
+	for _, batch := range shard.LiveStore.getAllBatches() {
+		batch.RLock()
+		if columnID < len(batch.Columns) && batch.Columns[columnID] != nil {
+			vp := batch.Columns[columnID]
+			for row := 0; row < vp.GetLength(); row++ {
+				if value := vp.GetDataValue(row); value.Valid {
+					markUsed(value.OtherVal)
+				}
+			}
+		}
+		batch.RUnlock()
+	}
+
+	if shard.LiveStore.BackfillManager == nil {
+		return
+	}
+	for _, upsertBatch := range shard.LiveStore.BackfillManager.UpsertBatches {
+		col, err := upsertBatch.GetColumnIndex(columnID)
+		if err != nil {
+			continue
+		}
+		for row := 0; row < upsertBatch.NumRows; row++ {
+			if value, valid, err := upsertBatch.GetValue(row, col); err == nil && valid {
+				markUsed(value)
+			}
+		}
+	}
+}
+
+// remapLiveEnumColumn maps enum ids of the column in live batches and upsert batches in the
+// backfill queue to ids after the compactions. Caller should hold LiveStore.WriterLock and the
+// lock of backfill manager.
+func (shard *TableShard) remapLiveEnumColumn(columnID int, compactions []metaCom.EnumDictCompaction, defaultValue common.DataValue) {
+	for _, batch := range shard.LiveStore.getAllBatches() {
+		batch.Lock()
+		if columnID < len(batch.Columns) {
+			if vp, ok := batch.Columns[columnID].(*cLiveVectorParty); ok {
+				vp.remapEnum(compactions, defaultValue)
+			}
+		}
+		batch.Unlock()
+	}
+
+	if shard.LiveStore.BackfillManager == nil {
+		return
+	}
+	for _, upsertBatch := range shard.LiveStore.BackfillManager.UpsertBatches {
+		if col, err := upsertBatch.GetColumnIndex(columnID); err == nil {
+			upsertBatch.RemapEnumColumn(col, compactions)
+		}
+	}
+}
+
+// evictArchivedColumn evicts the column from all loaded archive batches and drops their enum
+// indexes and value counts, so that the column is loaded and remapped again on next request.
+func (shard *TableShard) evictArchivedColumn(columnID int) {
+	currentVersion := shard.ArchiveStore.GetCurrentVersion()
+	defer currentVersion.Users.Done()
+
+	var batches []*ArchiveBatch
+	currentVersion.RLock()
+	for _, batch := range currentVersion.Batches {
+		batches = append(batches, batch)
+	}
+	currentVersion.RUnlock()
+
+	for _, batch := range batches {
+		batch.BlockingDelete(columnID)
+		batch.Lock()
+		delete(batch.enumIndexes, columnID)
+		delete(batch.valueCounts, columnID)
+		batch.Unlock()
+	}
+}
+
+// getEnumCompactions returns compactions of the enum dictionary of the column to remap enum ids
+// of this archive batch version loaded from disk.
+func (b *ArchiveBatch) getEnumCompactions(columnID int) (compactions []metaCom.EnumDictCompaction) {
+	b.Shard.Schema.RLock()
+	defer b.Shard.Schema.RUnlock()
+	for _, compaction := range b.Shard.Schema.GetEnumDictCompactions(columnID) {
+		if version, ok := compaction.ArchiveBatchVersions[b.Shard.ShardID][b.BatchID]; ok &&
+			version.Version == b.Version && version.SeqNum == b.SeqNum {
+			compactions = append(compactions, compaction)
+		}
+	}
+	return
+}
+
+// getSnapshotEnumCompactions returns compactions of the enum dictionary of the column to remap
+// enum ids of the snapshot taken at the redo log position.
+func (shard *TableShard) getSnapshotEnumCompactions(columnID int, redoLogFile int64, offset uint32) (
+	compactions []metaCom.EnumDictCompaction) {
+	position := metaCom.RedoLogPosition{RedoLogFile: redoLogFile, Offset: offset}
+	shard.Schema.RLock()
+	defer shard.Schema.RUnlock()
+	for _, compaction := range shard.Schema.GetEnumDictCompactions(columnID) {
+		if snapshotPosition, ok := compaction.SnapshotPositions[shard.ShardID]; ok && position.NotAfter(snapshotPosition) {
+			compactions = append(compactions, compaction)
+		}
+	}
+	return
+}
+
+// remapReplayedEnumColumns maps enum ids of the upsert batch replayed from the redo log position
+// if it was written before the enum dictionaries got compacted.
+func (shard *TableShard) remapReplayedEnumColumns(upsertBatch *common.UpsertBatch, redoLogFile int64, offset uint32) error {
+	position := metaCom.RedoLogPosition{RedoLogFile: redoLogFile, Offset: offset}
+	shard.Schema.RLock()
+	defer shard.Schema.RUnlock()
+	for col := 0; col < upsertBatch.NumColumns; col++ {
+		columnID, _ := upsertBatch.GetColumnID(col)
+		var compactions []metaCom.EnumDictCompaction
+		for _, compaction := range shard.Schema.GetEnumDictCompactions(columnID) {
+			if lastPosition, ok := compaction.RedoLogPositions[shard.ShardID]; ok && position.NotAfter(lastPosition) {
+				compactions = append(compactions, compaction)
+			}
+		}
+		if len(compactions) == 0 {
+			continue
+		}
+		if err := upsertBatch.RemapEnumColumn(col, compactions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	"github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// writeEnumIndexes builds bitmap indexes of indexed enum columns and writes them along with the
+// vector parties of the batch. Like WriteToDisk, it is only called for newly merged batches
+// so there is no need to lock it.
+func (b *ArchiveBatch) writeEnumIndexes() error {
+	b.Shard.Schema.RLock()
+	indexedColumns := b.Shard.Schema.GetIndexedColumns()
+	sortColumns := b.Shard.Schema.Schema.ArchivingSortColumns
+	dataTypes := b.Shard.Schema.ValueTypeByColumn
+	b.Shard.Schema.RUnlock()
+
+	for _, columnID := range indexedColumns {
+		if columnID >= len(b.Columns) || b.Columns[columnID] == nil {
+			continue
+		}
+		index := b.buildEnumIndex(columnID, dataTypes[columnID], utils.IndexOfInt(sortColumns, columnID) >= 0)
+		if err := b.writeIndex(columnID, index); err != nil {
+			return utils.StackError(err, "failed to write index of column %d for batch %d of table %s shard %d",
+				columnID, b.BatchID, b.Shard.Schema.Schema.Name, b.Shard.ShardID)
+		}
+		if b.enumIndexes == nil {
+			b.enumIndexes = make(map[int]*common.EnumBitmapIndex)
+		}
+		b.enumIndexes[columnID] = index
+	}
+	return nil
+}
+
+// buildEnumIndex builds the bitmap index of the enum column from its vector party.
+func (b *ArchiveBatch) buildEnumIndex(columnID int, dataType common.DataType, isSortColumn bool) *common.EnumBitmapIndex {
+	index := common.NewEnumBitmapIndex()
+	b.forEachValue(columnID, isSortColumn, func(row int, value common.DataValue) {
+		if !value.Valid {
+			return
+		}
+		if dataType == common.SmallEnum {
+			index.Add(uint32(*(*uint8)(value.OtherVal)), uint32(row))
+		} else {
+			index.Add(uint32(*(*uint16)(value.OtherVal)), uint32(row))
+		}
+	})
+	return index
+}
+
+// forEachValue calls fn with each row and its value of the column in this batch.
+func (b *ArchiveBatch) forEachValue(columnID int, isSortColumn bool, fn func(row int, value common.DataValue)) {
+	// values of sort columns are compressed with counts and need to be read by the iterator.
+	var iterator sortedColumnIterator
+	if isSortColumn {
+		iterator = newArchiveBatchColumnIterator(b, columnID, nil)
+		iterator.setEndPosition(uint32(b.Size))
+	}
+
+	for row := 0; row < b.Size; row++ {
+		var value common.DataValue
+		if iterator != nil {
+			if uint32(row) >= iterator.nextPosition() {
+				iterator.next()
+			}
+			value = iterator.value()
+		} else {
+			value = b.GetDataValue(row, columnID)
+		}
+		fn(row, value)
+	}
+}
+
+// batchIndex is the index of a column in an archive batch version stored in the index file of
+// the column.
+type batchIndex interface {
+	Write(writer io.Writer) error
+	Read(reader io.Reader) error
+}
+
+func (b *ArchiveBatch) writeIndex(columnID int, index batchIndex) error {
+	writer, err := b.Shard.diskStore.OpenBatchIndexFileForWrite(b.Shard.Schema.Schema.Name, columnID,
+		b.Shard.ShardID, int(b.BatchID), b.Version, b.SeqNum)
+	if err != nil {
+		return err
+	}
+	bufferedWriter := bufio.NewWriter(writer)
+	if err = index.Write(bufferedWriter); err == nil {
+		err = bufferedWriter.Flush()
+	}
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// RequestEnumIndex returns the bitmap index of the enum column in this batch version, loading it
+// from disk on first request. Nil is returned if the column is not indexed or the batch version
+// has no index of the column, e.g. it was archived before the column was indexed, fetched from
+// a peer or archived before the enum dictionary got compacted.
+func (b *ArchiveBatch) RequestEnumIndex(columnID int) *common.EnumBitmapIndex {
+	b.Shard.Schema.RLock()
+	indexed := utils.IndexOfInt(b.Shard.Schema.GetIndexedColumns(), columnID) >= 0
+	b.Shard.Schema.RUnlock()
+	// indexes of batches archived before the enum dictionary got compacted are keyed by old ids.
+	if !indexed || len(b.getEnumCompactions(columnID)) > 0 {
+		return nil
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	if index, ok := b.enumIndexes[columnID]; ok {
+		return index
+	}
+
+	index, err := b.readEnumIndex(columnID)
+	if err != nil {
+		// queries fall back to scanning the batch.
+		utils.GetLogger().With(
+			"table", b.Shard.Schema.Schema.Name,
+			"shard", b.Shard.ShardID,
+			"batchID", b.BatchID,
+			"column", columnID,
+			"error", err.Error()).Warn("failed to read batch index")
+	}
+	if b.enumIndexes == nil {
+		b.enumIndexes = make(map[int]*common.EnumBitmapIndex)
+	}
+	b.enumIndexes[columnID] = index
+	return index
+}
+
+func (b *ArchiveBatch) readEnumIndex(columnID int) (*common.EnumBitmapIndex, error) {
+	index := common.NewEnumBitmapIndex()
+	if found, err := b.readIndex(columnID, index); !found || err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// readIndex reads the index file of the column into index, it returns false if the batch
+// version has no index file of the column.
+func (b *ArchiveBatch) readIndex(columnID int, index batchIndex) (bool, error) {
+	reader, err := b.Shard.diskStore.OpenBatchIndexFileForRead(b.Shard.Schema.Schema.Name, columnID,
+		b.Shard.ShardID, int(b.BatchID), b.Version, b.SeqNum)
+	if err == os.ErrNotExist {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+	return true, index.Read(bufio.NewReader(reader))
+}
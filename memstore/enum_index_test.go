@@ -0,0 +1,129 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"os"
+	"sync"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	diskStoreMocks "github.com/uber/aresdb/diskstore/mocks"
+	memCom "github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/memstore/tests"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	testingUtils "github.com/uber/aresdb/testing"
+)
+
+var _ = ginkgo.Describe("enum index", func() {
+	table := "table1"
+	shardID := 0
+	batchID := 100
+	var cutoff uint32 = 200
+
+	newShard := func(ds *diskStoreMocks.DiskStore) *TableShard {
+		return &TableShard{
+			diskStore: ds,
+			ShardID:   shardID,
+			Schema: &memCom.TableSchema{
+				Schema: metaCom.Table{
+					Name: table,
+					Columns: []metaCom.Column{
+						{Name: "time", Type: metaCom.Uint32},
+						{Name: "status", Type: metaCom.SmallEnum, Config: metaCom.ColumnConfig{Indexed: true}},
+						{Name: "city", Type: metaCom.BigEnum, Config: metaCom.ColumnConfig{Indexed: true}},
+						{Name: "product", Type: metaCom.SmallEnum},
+					},
+					ArchivingSortColumns: []int{1},
+				},
+				ValueTypeByColumn: []memCom.DataType{memCom.Uint32, memCom.SmallEnum, memCom.BigEnum, memCom.SmallEnum},
+			},
+		}
+	}
+
+	newVectorParty := func(rvp tests.RawVectorParty, locker sync.Locker) memCom.VectorParty {
+		vp, err := toVectorParty(&rvp, false)
+		Ω(err).Should(BeNil())
+		return toArchiveVectorParty(vp, locker)
+	}
+
+	ginkgo.It("WriteToDisk should write indexes of indexed enum columns", func() {
+		ds := new(diskStoreMocks.DiskStore)
+		lock := &sync.RWMutex{}
+		batch := &ArchiveBatch{
+			Batch: memCom.Batch{
+				RWMutex: lock,
+				Columns: []memCom.VectorParty{
+					nil,
+					// sort column with counts: null, 1, 1, 2, 2.
+					newVectorParty(tests.RawVectorParty{
+						DataType: "SmallEnum", Length: 3, HasCounts: true,
+						Values: []string{"null,1", "1,3", "2,5"},
+					}, lock),
+					newVectorParty(tests.RawVectorParty{
+						DataType: "BigEnum", Length: 5,
+						Values: []string{"3", "null", "3", "400", "3"},
+					}, lock),
+					nil,
+				},
+			},
+			Size:    5,
+			Version: cutoff,
+			BatchID: int32(batchID),
+			Shard:   newShard(ds),
+		}
+
+		vpWriter := &testingUtils.TestReadWriteCloser{}
+		ds.On("OpenVectorPartyFileForWrite", table, mock.Anything, shardID, batchID, cutoff, uint32(0)).
+			Return(vpWriter, nil)
+		statusIndexFile := &testingUtils.TestReadWriteCloser{}
+		cityIndexFile := &testingUtils.TestReadWriteCloser{}
+		ds.On("OpenBatchIndexFileForWrite", table, 1, shardID, batchID, cutoff, uint32(0)).
+			Return(statusIndexFile, nil).Once()
+		ds.On("OpenBatchIndexFileForWrite", table, 2, shardID, batchID, cutoff, uint32(0)).
+			Return(cityIndexFile, nil).Once()
+		Ω(batch.WriteToDisk()).Should(BeNil())
+
+		statusIndex := batch.enumIndexes[1]
+		Ω(statusIndex.Rows(1).ToArray()).Should(Equal([]uint32{1, 2}))
+		Ω(statusIndex.Rows(2).ToArray()).Should(Equal([]uint32{3, 4}))
+		Ω(statusIndex.Rows(0).IsEmpty()).Should(BeTrue())
+		cityIndex := batch.enumIndexes[2]
+		Ω(cityIndex.Rows(3).ToArray()).Should(Equal([]uint32{0, 2, 4}))
+		Ω(cityIndex.Rows(400).ToArray()).Should(Equal([]uint32{3}))
+
+		// indexes are loaded from disk by other batch objects of the same version.
+		ds.On("OpenBatchIndexFileForRead", table, 2, shardID, batchID, cutoff, uint32(0)).
+			Return(cityIndexFile, nil).Once()
+		ds.On("OpenBatchIndexFileForRead", table, 1, shardID, batchID, cutoff, uint32(0)).
+			Return(nil, os.ErrNotExist).Once()
+		loaded := &ArchiveBatch{
+			Batch:   memCom.Batch{RWMutex: &sync.RWMutex{}},
+			Size:    5,
+			Version: cutoff,
+			BatchID: int32(batchID),
+			Shard:   batch.Shard,
+		}
+		Ω(loaded.RequestEnumIndex(2)).Should(Equal(cityIndex))
+		// cached after first request.
+		Ω(loaded.RequestEnumIndex(2)).Should(Equal(cityIndex))
+		Ω(loaded.RequestEnumIndex(1)).Should(BeNil())
+		Ω(loaded.RequestEnumIndex(1)).Should(BeNil())
+		// columns without index hint are not looked up.
+		Ω(loaded.RequestEnumIndex(3)).Should(BeNil())
+		ds.AssertExpectations(ginkgo.GinkgoT())
+	})
+})
@@ -0,0 +1,159 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"sort"
+	"sync/atomic"
+
+	memCom "github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// RecomputeHLL recomputes hll values of archived batches of a fact table shard for hll columns
+// whose precision got reduced, so that archived values no longer need to be converted on load.
+func (m *memStoreImpl) RecomputeHLL(table string, shardID int, reporter HLLRecomputeJobDetailReporter) error {
+	start := utils.Now()
+	jobKey := getIdentifier(table, shardID, memCom.HLLRecomputeJobType)
+	defer func() {
+		duration := utils.Now().Sub(start)
+		utils.GetReporter(table, shardID).GetTimer(utils.HLLRecomputeTimingTotal).Record(duration)
+		reporter(jobKey, func(status *HLLRecomputeJobDetail) {
+			status.LastDuration = duration
+		})
+	}()
+
+	shard, err := m.GetTableShard(table, shardID)
+	if err != nil {
+		utils.GetLogger().With("table", table, "shard", shardID, "error", err).Warn("Failed to find shard, is it deleted?")
+		return nil
+	}
+	defer shard.Users.Done()
+
+	if !shard.Schema.Schema.IsFactTable {
+		return utils.StackError(nil, "HLL recomputation is only supported for fact tables, got %s", table)
+	}
+
+	reporter(jobKey, func(status *HLLRecomputeJobDetail) {
+		status.Stage = HLLRecomputeRewrite
+		status.Current = 0
+		status.Total = 0
+	})
+
+	numBatches, err := shard.recomputeArchivedHLLColumns(func(current, total int) {
+		reporter(jobKey, func(status *HLLRecomputeJobDetail) {
+			status.Current = current
+			status.Total = total
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	utils.GetReporter(table, shardID).GetCounter(utils.HLLRecomputedBatches).Inc(int64(numBatches))
+	reporter(jobKey, func(status *HLLRecomputeJobDetail) {
+		status.NumRecomputedBatches = numBatches
+		status.Stage = HLLRecomputeComplete
+	})
+
+	utils.GetLogger().With(
+		"job", "hll_recompute",
+		"table", table,
+		"shard", shardID,
+		"batches", numBatches).Info("HLL recomputation done")
+	return nil
+}
+
+// markHLLRecomputePending schedules recomputation of archived hll columns of the shard.
+func (shard *TableShard) markHLLRecomputePending() {
+	atomic.StoreInt32(&shard.hllRecomputePending, 1)
+}
+
+// isHLLRecomputePending tells whether archived hll columns of the shard need to be recomputed.
+func (shard *TableShard) isHLLRecomputePending() bool {
+	return atomic.LoadInt32(&shard.hllRecomputePending) == 1
+}
+
+// recomputeArchivedHLLColumns rewrites hll columns with reduced precision in all archive batches
+// of the current archive store version one batch at a time, so that archiving and backfill are
+// not blocked for long. Progress is reported after each batch. Returns the number of batches
+// rewritten.
+func (shard *TableShard) recomputeArchivedHLLColumns(progress func(current, total int)) (int, error) {
+	// precision reductions during the run are picked up by the next run.
+	atomic.StoreInt32(&shard.hllRecomputePending, 0)
+
+	shard.Schema.RLock()
+	precisions := shard.Schema.GetReducedHLLPrecisions()
+	shard.Schema.RUnlock()
+
+	var columnIDs []int
+	for columnID, precision := range precisions {
+		if precision > 0 {
+			columnIDs = append(columnIDs, columnID)
+		}
+	}
+	if len(columnIDs) == 0 {
+		return 0, nil
+	}
+
+	currentVersion := shard.ArchiveStore.GetCurrentVersion()
+	currentVersion.RLock()
+	batchIDs := make([]int, 0, len(currentVersion.Batches))
+	for batchID := range currentVersion.Batches {
+		batchIDs = append(batchIDs, int(batchID))
+	}
+	currentVersion.RUnlock()
+	currentVersion.Users.Done()
+	sort.Ints(batchIDs)
+
+	numBatches := 0
+	for i, batchID := range batchIDs {
+		rewritten, err := shard.recomputeArchivedHLLBatch(int32(batchID), columnIDs)
+		if err != nil {
+			return numBatches, err
+		}
+		if rewritten {
+			numBatches++
+		}
+		progress(i+1, len(batchIDs))
+	}
+	return numBatches, nil
+}
+
+// recomputeArchivedHLLBatch rewrites the hll columns of the batch in the current archive store
+// version, the batch may have been merged into a new version or purged meanwhile.
+func (shard *TableShard) recomputeArchivedHLLBatch(batchID int32, columnIDs []int) (bool, error) {
+	shard.columnDeletion.Lock()
+	defer shard.columnDeletion.Unlock()
+
+	currentVersion := shard.ArchiveStore.GetCurrentVersion()
+	defer currentVersion.Users.Done()
+
+	currentVersion.RLock()
+	batch := currentVersion.Batches[batchID]
+	currentVersion.RUnlock()
+	if batch == nil {
+		return false, nil
+	}
+
+	for _, columnID := range columnIDs {
+		// columns loaded before the precision got reduced are evicted to be converted on load.
+		batch.BlockingDelete(columnID)
+		if err := batch.RewriteVectorParty(columnID); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -16,6 +16,7 @@ package memstore
 
 import (
 	"container/heap"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 
@@ -43,6 +44,10 @@ type hostMemoryManager struct {
 	// unManagedMemorySize and managedMemorySize reflect current memory usage status.
 	unManagedMemorySize int64
 	managedMemorySize   int64
+	// managed memory usage by table, protected by the lock.
+	managedMemorySizeByTable map[string]int64
+	// directory of files backing columns spilled for exceeding table memory budget.
+	spillPath string
 	// Init maps for table -> (columnID -> columnBatchInfos) mapping
 	batchInfosByColumn map[string]map[int]*columnBatchInfos
 	// channel to send preloadJob.
@@ -161,17 +166,23 @@ func (a *columnBatchInfos) GetArchiveMemoryUsageByShard(preloadDays int) map[int
 
 // NewHostMemoryManager is used to init a HostMemoryManager.
 func NewHostMemoryManager(memStore *memStoreImpl, totalMemorySize int64) common.HostMemoryManager {
+	spillPath := utils.GetConfig().DiskStore.SpillPath
+	if spillPath == "" {
+		spillPath = filepath.Join(utils.GetConfig().RootPath, "spill")
+	}
 	hostMemoryManager := &hostMemoryManager{
-		memStore:            memStore,
-		metaStore:           memStore.metaStore,
-		totalMemorySize:     totalMemorySize,
-		unManagedMemorySize: 0,
-		managedMemorySize:   0,
-		batchInfosByColumn:  make(map[string]map[int]*columnBatchInfos),
-		preloadJobChan:      make(chan preloadJob),
-		preloadStopChan:     make(chan struct{}),
-		evictionJobChan:     make(chan struct{}),
-		evictionStopChan:    make(chan struct{}),
+		memStore:                 memStore,
+		metaStore:                memStore.metaStore,
+		totalMemorySize:          totalMemorySize,
+		unManagedMemorySize:      0,
+		managedMemorySize:        0,
+		managedMemorySizeByTable: make(map[string]int64),
+		spillPath:                spillPath,
+		batchInfosByColumn:       make(map[string]map[int]*columnBatchInfos),
+		preloadJobChan:           make(chan preloadJob),
+		preloadStopChan:          make(chan struct{}),
+		evictionJobChan:          make(chan struct{}),
+		evictionStopChan:         make(chan struct{}),
 	}
 	utils.GetRootReporter().GetGauge(utils.TotalMemorySize).Update(float64(totalMemorySize))
 	return hostMemoryManager
@@ -267,6 +278,35 @@ func (h *hostMemoryManager) getManagedSpaceUsage() int64 {
 	return atomic.LoadInt64(&h.managedMemorySize)
 }
 
+func (h *hostMemoryManager) getTableManagedSpaceUsage(table string) int64 {
+	h.RLock()
+	defer h.RUnlock()
+	return h.managedMemorySizeByTable[table]
+}
+
+func (h *hostMemoryManager) addTableManagedSpaceUsage(table string, bytesChange int64) {
+	h.Lock()
+	defer h.Unlock()
+	h.managedMemorySizeByTable[table] += bytesChange
+	if h.managedMemorySizeByTable[table] <= 0 {
+		delete(h.managedMemorySizeByTable, table)
+	}
+}
+
+// underMemoryPressure tells whether the unmanaged space usage, which cannot be reclaimed by
+// eviction, is close to the memory limit.
+func (h *hostMemoryManager) underMemoryPressure() bool {
+	return float64(h.getUnmanagedSpaceUsage()) >= memoryPressureRatio*float64(h.totalMemorySize)
+}
+
+// unmanagedMemoryUsageRatio returns the ratio of the unmanaged space usage to the memory limit.
+func (h *hostMemoryManager) unmanagedMemoryUsageRatio() float64 {
+	if h.totalMemorySize <= 0 {
+		return 0
+	}
+	return float64(h.getUnmanagedSpaceUsage()) / float64(h.totalMemorySize)
+}
+
 // GetArchiveMemoryUsageByTableShard get the managed memory details by table shard and column
 func (h *hostMemoryManager) GetArchiveMemoryUsageByTableShard() (map[string]map[string]*common.ColumnMemoryUsage, error) {
 	h.RLock()
@@ -334,6 +374,7 @@ func (h *hostMemoryManager) addOrUpdateManagedObject(table string, shard, batchI
 
 	bytesChange := columnBatchInfos.SetManagedObject(shard, batchID, bytes)
 	atomic.AddInt64(&h.managedMemorySize, bytesChange)
+	h.addTableManagedSpaceUsage(table, bytesChange)
 	utils.GetLogger().Debugf("addOrUpdateManagedObject(%s,%d,%d,%d,%d), bytesChange = %d, "+
 		"managedMemorySize=%d\n ", table, shard, batchID, columnID, bytes, bytesChange, h.getManagedSpaceUsage())
 }
@@ -358,6 +399,7 @@ func (h *hostMemoryManager) deleteManagedObject(table string, shard, batchID, co
 	bytesChange := columnBatchInfos.DeleteManagedObject(shard, batchID)
 	utils.GetLogger().Debugf("Before deleteManagedObject managedMemorySize : %d, bytesChange : %d", h.getManagedSpaceUsage(), bytesChange)
 	atomic.AddInt64(&h.managedMemorySize, bytesChange)
+	h.addTableManagedSpaceUsage(table, bytesChange)
 	utils.GetLogger().Debugf("After deleteManagedObject managedMemorySize : %d", h.getManagedSpaceUsage())
 	h.Lock()
 	if columnBatchInfos.batchInfoByID.Size() == 0 {
@@ -404,6 +446,8 @@ func (h *hostMemoryManager) handleColumnPreloadingDaysChange(j preloadJob) {
 // decreases to a certain level. All failed eviction batches will be
 // reinserted.
 func (h *hostMemoryManager) tryEviction() {
+	h.trySpill()
+
 	// Check if eviction should be triggered
 	if (h.totalMemorySize - h.getManagedSpaceUsage() - h.getUnmanagedSpaceUsage()) < 0 {
 		utils.GetLogger().Debugf("UnmanagedMem: %d + ManagedMem: %d is larger than totalMem: %d! Eviction is triggered.",
@@ -460,6 +504,62 @@ func (h *hostMemoryManager) tryEviction() {
 	}
 }
 
+// getTableMemoryBudgets returns the memory budget in bytes of tables with managed memory usage
+// exceeding the budget.
+func (h *hostMemoryManager) getTableMemoryBudgets() map[string]int64 {
+	budgets := make(map[string]int64)
+	h.RLock()
+	defer h.RUnlock()
+	for table, usage := range h.managedMemorySizeByTable {
+		tableSchema, err := h.memStore.GetSchema(table)
+		if err != nil {
+			continue
+		}
+		tableSchema.RLock()
+		budget := tableSchema.Schema.Config.MemoryBudgetMB * (1 << 20)
+		tableSchema.RUnlock()
+		if budget > 0 && usage > budget {
+			budgets[table] = budget
+		}
+	}
+	return budgets
+}
+
+// trySpill spills columns of tables exceeding their memory budget to disk in the same priority
+// order as eviction until the usage decreases under the budget. Spilled columns are mapped back
+// into memory so queries still read them without loading from disk store, with pages reclaimable
+// by OS.
+func (h *hostMemoryManager) trySpill() {
+	for table, budget := range h.getTableMemoryBudgets() {
+		utils.GetLogger().Debugf("ManagedMem: %d of table %s is larger than budget: %d! Spilling is triggered.",
+			h.getTableManagedSpaceUsage(table), table, budget)
+		gpq := h.initialTablePriorityQueue(table)
+		for h.getTableManagedSpaceUsage(table) > budget && !gpq.isEmpty() {
+			globalPriorityItem := gpq.pop()
+			batchPriority := globalPriorityItem.priority
+			columnIt := globalPriorityItem.it
+
+			ok, err := h.memStore.TrySpillBatchColumn(table, batchPriority.shardID, int32(batchPriority.batchID), batchPriority.columnID, h.spillPath)
+			if err != nil {
+				utils.GetLogger().With(
+					"table", table,
+					"shard", batchPriority.shardID,
+					"batch", batchPriority.batchID,
+					"column", batchPriority.columnID,
+					"error", err,
+				).Error("Failed to spill column")
+			} else if ok {
+				utils.GetLogger().Debugf("Successfully spilled batch: table %s, shardID %d, batchID %d, columnID %d, size %d",
+					table, batchPriority.shardID, batchPriority.batchID, batchPriority.columnID, batchPriority.size)
+			}
+
+			if columnIt.Next() {
+				gpq.pushBatchIntoGlobalPriorityQueue(h, globalPriorityItem.value, batchPriority.columnID, columnIt)
+			}
+		}
+	}
+}
+
 // pushBatchIntoGlobalPriorityQueue will generate a globalPriority object then
 // push it into globalPriorityQueueWithLock.
 func (gpq *globalPriorityQueue) pushBatchIntoGlobalPriorityQueue(h *hostMemoryManager,
@@ -508,6 +608,21 @@ func (h *hostMemoryManager) initialGlobalPriorityQueue() *globalPriorityQueue {
 	return gpq
 }
 
+// initialTablePriorityQueue will initialize a globalPriorityQueue with one batch for each
+// column of the table from batchInfosByColumn.
+func (h *hostMemoryManager) initialTablePriorityQueue(table string) *globalPriorityQueue {
+	gpq := newGlobalPriorityQueue()
+	h.RLock()
+	for columnID, columnBatchInfos := range h.batchInfosByColumn[table] {
+		columnBatchIt := columnBatchInfos.batchInfoByID.Iterator()
+		if columnBatchIt.Next() {
+			gpq.pushBatchIntoGlobalPriorityQueue(h, columnBatchInfos, columnID, columnBatchIt)
+		}
+	}
+	h.RUnlock()
+	return gpq
+}
+
 // globalPriority is the holding struct for all the neccesarry fields to
 // compare batch priority in a global view.
 type globalPriority struct {
@@ -705,6 +705,62 @@ var _ = ginkgo.Describe("HostMemoryManager", func() {
 		logger.Infof("Test HostMemoryManager tryEviction Finished")
 	})
 
+	ginkgo.It("Test HostMemoryManager trySpill", func() {
+		testTableName := "myTable"
+		testTable := &metaCom.Table{
+			Name:        testTableName,
+			IsFactTable: true,
+			Columns: []metaCom.Column{
+				{
+					Name: "c0",
+					Config: metaCom.ColumnConfig{
+						Priority: 0,
+					},
+				},
+				{
+					Name: "c1",
+					Config: metaCom.ColumnConfig{
+						Priority: 10,
+					},
+				},
+			},
+			Config: metaCom.TableConfig{
+				BatchSize:      10,
+				MemoryBudgetMB: 1,
+			},
+		}
+		testSchema := memCom.NewTableSchema(testTable)
+		testMemStore.TableShards[testTableName] = map[int]*TableShard{
+			0: NewTableShard(testSchema, testMetaStore, testDiskStore, testHostMemoryManager, 0, options),
+		}
+		testMemStore.TableSchemas[testTableName] = testSchema
+		testShard := testMemStore.TableShards[testTableName][0]
+		testShard.ArchiveStore = &ArchiveStore{
+			CurrentVersion: &ArchiveStoreVersion{
+				Batches: map[int32]*ArchiveBatch{
+					15739: CreateTestArchiveBatch(testShard, 15739),
+				},
+				ArchivingCutoff: 100,
+			},
+		}
+
+		testHostMemoryManager.ReportManagedObject(testTableName, 0, 15739, 0, 1<<20)
+		testHostMemoryManager.ReportManagedObject(testTableName, 0, 15739, 1, 1<<20)
+		Ω(testHostMemoryManager.getTableManagedSpaceUsage(testTableName)).Should(Equal(int64(2 << 20)))
+
+		// column with lower priority should be spilled until the usage is within the budget.
+		testHostMemoryManager.trySpill()
+		Ω(testHostMemoryManager.getTableManagedSpaceUsage(testTableName)).Should(Equal(int64(1 << 20)))
+		Ω(testHostMemoryManager.managedObjectExists(testTableName, 0, 15739, 0)).Should(BeFalse())
+		Ω(testHostMemoryManager.managedObjectExists(testTableName, 0, 15739, 1)).Should(BeTrue())
+		// spilled column stays in the batch.
+		Ω(testShard.ArchiveStore.CurrentVersion.Batches[15739].Columns[0]).ShouldNot(BeNil())
+
+		// nothing to spill within the budget.
+		testHostMemoryManager.trySpill()
+		Ω(testHostMemoryManager.getTableManagedSpaceUsage(testTableName)).Should(Equal(int64(1 << 20)))
+	})
+
 	ginkgo.It("Test HostMemoryManager triggerEviction", func() {
 		logger.Infof("Test HostMemoryManager triggerEviction Started")
 		testTableName := "myTable"
@@ -15,10 +15,15 @@
 package memstore
 
 import (
+	xerrors "github.com/m3db/m3/src/x/errors"
+	xsync "github.com/m3db/m3/src/x/sync"
 	"github.com/uber/aresdb/memstore/common"
 	"github.com/uber/aresdb/utils"
 	"math"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"unsafe"
 )
 
 // HandleIngestion logs an upsert batch and applies it to the in-memory store.
@@ -34,6 +39,12 @@ func (m *memStoreImpl) HandleIngestion(table string, shardID int, upsertBatch *c
 		return utils.StackError(nil, "appending not enabled on redolog manager for table %s", table)
 	}
 
+	if m.options.ingestionThrottle != nil {
+		if err = m.options.ingestionThrottle.check(shard); err != nil {
+			return err
+		}
+	}
+
 	return shard.saveUpsertBatch(upsertBatch, 0, 0, false, false)
 }
 
@@ -51,6 +62,9 @@ func (shard *TableShard) saveUpsertBatch(upsertBatch *common.UpsertBatch, redoLo
 	} else {
 		utils.GetReporter(tableName, shardID).GetCounter(utils.IngestedUpsertBatches).Inc(1)
 		utils.GetReporter(tableName, shardID).GetGauge(utils.UpsertBatchSize).Update(float64(len(upsertBatch.GetBuffer())))
+		if nowInSeconds := uint32(utils.Now().Unix()); upsertBatch.ArrivalTime > 0 && nowInSeconds >= upsertBatch.ArrivalTime {
+			atomic.StoreUint32(&shard.LiveStore.redoLogLag, nowInSeconds-upsertBatch.ArrivalTime)
+		}
 		// for non-recovery and local file based redolog, need write the upsertbatch into redolog file
 		if shard.LiveStore.RedoLogManager.IsAppendEnabled() {
 			// change original file/offset to be local redolog file/offset
@@ -58,7 +72,23 @@ func (shard *TableShard) saveUpsertBatch(upsertBatch *common.UpsertBatch, redoLo
 		}
 	}
 
-	needToWaitForBackfillBuffer, err := shard.ApplyUpsertBatch(upsertBatch, redoLogFile, offset, skipBackFillRows)
+	concurrency := 1
+	if recovery {
+		concurrency = shard.options.replayConcurrency
+		// batches written to redo logs before the enum dictionary got compacted.
+		if err := shard.remapReplayedEnumColumns(upsertBatch, redoLogFile, offset); err != nil {
+			shard.LiveStore.WriterLock.Unlock()
+			return err
+		}
+	}
+	needToWaitForBackfillBuffer, err := shard.applyUpsertBatch(upsertBatch, redoLogFile, offset, skipBackFillRows, concurrency)
+	if err == nil {
+		shard.LiveStore.writePosition.advance(common.WritePosition{RedoLogFile: redoLogFile, Offset: offset})
+	}
+	// batches replayed in recovery were published before restart.
+	if err == nil && !recovery && shard.options.changePublisher != nil {
+		shard.options.changePublisher.Publish(shard.Schema, shardID, upsertBatch, redoLogFile, offset)
+	}
 	shard.LiveStore.WriterLock.Unlock()
 
 	// return immediately if it does not need to wait for backfill buffer availability
@@ -74,9 +104,17 @@ func (shard *TableShard) saveUpsertBatch(upsertBatch *common.UpsertBatch, redoLo
 // ApplyUpsertBatch applies the upsert batch to the memstore shard.
 // Returns true if caller needs to wait for availability of backfill buffer
 func (shard *TableShard) ApplyUpsertBatch(upsertBatch *common.UpsertBatch, redoLogFile int64, offset uint32, skipBackfillRows bool) (bool, error) {
+	return shard.applyUpsertBatch(upsertBatch, redoLogFile, offset, skipBackfillRows, 1)
+}
+
+// applyUpsertBatch applies the upsert batch to the memstore shard with records of different live
+// batches written by up to concurrency goroutines.
+func (shard *TableShard) applyUpsertBatch(upsertBatch *common.UpsertBatch, redoLogFile int64, offset uint32,
+	skipBackfillRows bool, concurrency int) (bool, error) {
 	shard.Schema.RLock()
 	valueTypeByColumn := shard.Schema.ValueTypeByColumn
 	columnDeletions := shard.Schema.GetColumnDeletions()
+	hllPrecisions := shard.Schema.GetReducedHLLPrecisions()
 	allowMissingEventTime := shard.Schema.Schema.Config.AllowMissingEventTime
 	shard.Schema.RUnlock()
 	primaryKeyColumns := shard.Schema.GetPrimaryKeyColumns()
@@ -95,12 +133,26 @@ func (shard *TableShard) ApplyUpsertBatch(upsertBatch *common.UpsertBatch, redoL
 		}
 
 		columnType, _ := upsertBatch.GetColumnType(i)
+		if valueTypeByColumn[columnID] == common.BigEnum && columnType == common.SmallEnum {
+			// upsert batch built before the column was promoted to big enum.
+			if err := upsertBatch.PromoteEnumColumn(i); err != nil {
+				return false, err
+			}
+			columnType = common.BigEnum
+		}
 		if valueTypeByColumn[columnID] != columnType {
 			return false, utils.StackError(
 				nil,
 				"Mismatched data type (upsert batch: %d, schema %d) for table %s shard %d column %d", columnType, valueTypeByColumn[columnID], shard.Schema.Schema.Name, shard.ShardID, columnID)
 		}
 
+		if columnID < len(hllPrecisions) && hllPrecisions[columnID] > 0 {
+			// upsert batch built before the hll precision of the column got reduced.
+			if err := upsertBatch.ReduceHLLColumnPrecision(i, hllPrecisions[columnID]); err != nil {
+				return false, err
+			}
+		}
+
 		if columnID == 0 && isFactTable {
 			eventTimeColumnIndex = i
 		}
@@ -120,16 +172,22 @@ func (shard *TableShard) ApplyUpsertBatch(upsertBatch *common.UpsertBatch, redoL
 		return false, err
 	}
 
-	// We write insert records first so records with the same primary key in a upsert batch
-	// will be updated in order.
-	for batchID, records := range insertRecords {
-		if err := shard.writeBatchRecords(columnDeletions, upsertBatch, batchID, records, false); err != nil {
+	if concurrency > 1 && len(insertRecords)+len(updateRecords) > 1 {
+		if err := shard.writeRecordsInParallel(columnDeletions, upsertBatch, insertRecords, updateRecords, concurrency); err != nil {
 			return false, err
 		}
-	}
-	for batchID, records := range updateRecords {
-		if err := shard.writeBatchRecords(columnDeletions, upsertBatch, batchID, records, true); err != nil {
-			return false, err
+	} else {
+		// We write insert records first so records with the same primary key in a upsert batch
+		// will be updated in order.
+		for batchID, records := range insertRecords {
+			if err := shard.writeBatchRecords(columnDeletions, upsertBatch, batchID, records, false); err != nil {
+				return false, err
+			}
+		}
+		for batchID, records := range updateRecords {
+			if err := shard.writeBatchRecords(columnDeletions, upsertBatch, batchID, records, true); err != nil {
+				return false, err
+			}
 		}
 	}
 
@@ -306,6 +364,10 @@ func (shard *TableShard) insertPrimaryKeys(primaryKeyColumns []int, eventTimeCol
 	}
 
 	var nowInSeconds = uint32(utils.Now().Unix())
+	if maxUpsertBatchEventTime > atomic.LoadUint32(&shard.LiveStore.highWatermarkEventTime) {
+		atomic.StoreUint32(&shard.LiveStore.highWatermarkEventTime, maxUpsertBatchEventTime)
+	}
+
 	// Update max event time for each column in this upsert batch.
 	if maxUpsertBatchEventTime > 0 {
 		for col := 0; col < upsertBatch.NumColumns; col++ {
@@ -357,6 +419,50 @@ func (shard *TableShard) insertPrimaryKeys(primaryKeyColumns []int, eventTimeCol
 }
 
 // Read rows from a batch group and write to memStore. Batch id = 0 is for records to be inserted.
+// writeRecordsInParallel writes records of each live batch in a separate goroutine, with up to
+// concurrency goroutines running at the same time. Rows of the same primary key always map to the
+// same record and hence the same batch, and insert records of a batch are written before its
+// update records, so rows of the same primary key are still applied in order.
+func (shard *TableShard) writeRecordsInParallel(columnDeletions []bool, upsertBatch *common.UpsertBatch,
+	insertRecords, updateRecords map[int32][]recordInfo, concurrency int) error {
+	batchIDs := make(map[int32]struct{}, len(insertRecords)+len(updateRecords))
+	for batchID := range insertRecords {
+		batchIDs[batchID] = struct{}{}
+	}
+	for batchID := range updateRecords {
+		batchIDs[batchID] = struct{}{}
+	}
+
+	workers := xsync.NewWorkerPool(concurrency)
+	workers.Init()
+	var (
+		multiErr = xerrors.NewMultiError()
+		mutex    sync.Mutex
+		wg       sync.WaitGroup
+	)
+	for batchID := range batchIDs {
+		batchID := batchID
+		wg.Add(1)
+		workers.Go(func() {
+			defer wg.Done()
+			var err error
+			if records, ok := insertRecords[batchID]; ok {
+				err = shard.writeBatchRecords(columnDeletions, upsertBatch, batchID, records, false)
+			}
+			if records, ok := updateRecords[batchID]; ok && err == nil {
+				err = shard.writeBatchRecords(columnDeletions, upsertBatch, batchID, records, true)
+			}
+			if err != nil {
+				mutex.Lock()
+				multiErr = multiErr.Add(err)
+				mutex.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	return multiErr.FinalError()
+}
+
 func (shard *TableShard) writeBatchRecords(columnDeletions []bool,
 	upsertBatch *common.UpsertBatch, batchID int32, records []recordInfo, forUpdate bool) error {
 	var batch *LiveBatch
@@ -374,6 +480,7 @@ func (shard *TableShard) writeBatchRecords(columnDeletions []bool,
 			}
 			batch.GetOrCreateVectorParty(columnID, true)
 		}
+		batch.getOrCreateIngestedAt()
 		batch.Unlock()
 
 		batch.RLock()
@@ -384,6 +491,16 @@ func (shard *TableShard) writeBatchRecords(columnDeletions []bool,
 		batch.MaxArrivalTime = upsertBatch.ArrivalTime
 	}
 
+	// Arrival time is kept in redologs so that recovered rows keep their ingestion time.
+	ingestedAt := upsertBatch.ArrivalTime
+	if ingestedAt == 0 {
+		ingestedAt = uint32(utils.Now().Unix())
+	}
+	ingestedAtVP := batch.getOrCreateIngestedAt()
+	for _, recordInfo := range records {
+		ingestedAtVP.SetValue(recordInfo.index, unsafe.Pointer(&ingestedAt), true)
+	}
+
 	// Instead of traversing row by row, we instead do column by column to avoid making checks on each row.
 	for col := 0; col < upsertBatch.NumColumns; col++ {
 		columnID, err := upsertBatch.GetColumnID(col)
@@ -448,6 +565,12 @@ func (shard *TableShard) writeBatchRecords(columnDeletions []bool,
 				vectorParty.SetBool(recordInfo.index, val, valid)
 			} else if common.IsGoType(dataType) {
 				val := upsertBatch.ReadGoValue(recordInfo.row, col)
+				if shape, ok := val.(*common.GeoShapeGo); ok && shape.Repair() != nil {
+					// unrepairable shapes produce wrong results for geo intersection and are
+					// rejected as null values.
+					val = nil
+					utils.GetReporter(shard.Schema.Schema.Name, shard.ShardID).GetCounter(utils.IngestRejectedGeoShapes).Inc(1)
+				}
 				valid := val != nil
 				if !valid && !forceWrite {
 					continue
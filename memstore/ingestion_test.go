@@ -42,6 +42,24 @@ var _ = ginkgo.Describe("ingestion", func() {
 		Ω(err).ShouldNot(BeNil())
 	})
 
+	ginkgo.It("throttles ingestion under memory pressure", func() {
+		memstore := createMemStore("abc", 0, []common.DataType{}, []int{}, 10, false, false, nil, CreateMockDiskStore())
+		memstore.options.ingestionThrottle = &ingestionThrottle{memoryUsageRatio: 0.5, retryAfter: 5 * time.Second}
+		shard, _ := memstore.GetTableShard("abc", 0)
+		shard.Users.Done()
+		buffer, _ := common.NewUpsertBatchBuilder().ToByteArray()
+		upsertBatch, _ := common.NewUpsertBatch(buffer)
+		Ω(memstore.HandleIngestion("abc", 0, upsertBatch)).Should(BeNil())
+
+		shard.HostMemoryManager.ReportUnmanagedSpaceUsageChange(3 << 30)
+		err := memstore.HandleIngestion("abc", 0, upsertBatch)
+		Ω(err).Should(BeAssignableToTypeOf(&IngestionThrottledError{}))
+		Ω(err.(*IngestionThrottledError).RetryAfter).Should(Equal(5 * time.Second))
+
+		shard.HostMemoryManager.ReportUnmanagedSpaceUsageChange(-(3 << 30))
+		Ω(memstore.HandleIngestion("abc", 0, upsertBatch)).Should(BeNil())
+	})
+
 	ginkgo.It("returns error for missing primary key", func() {
 		memstore := createMemStore("abc", 0, []common.DataType{common.Uint8}, []int{0}, 10, false, false, nil, CreateMockDiskStore())
 		buffer, _ := common.NewUpsertBatchBuilder().ToByteArray()
@@ -101,6 +119,65 @@ var _ = ginkgo.Describe("ingestion", func() {
 		Ω(shard.LiveStore.LastReadRecord.Index).Should(Equal(uint32(1)))
 	})
 
+	ginkgo.It("repairs or rejects bad geo shapes", func() {
+		memstore := createMemStore("abc", 0, []common.DataType{common.Uint8, common.GeoShape}, []int{0}, 10, false, false, nil, CreateMockDiskStore())
+		builder := common.NewUpsertBatchBuilder()
+		builder.AddColumn(0, common.Uint8)
+		builder.AddColumn(1, common.GeoShape)
+		builder.AddRow()
+		builder.SetValue(0, 0, uint8(1))
+		// unclosed ring
+		builder.SetValue(0, 1, &common.GeoShapeGo{Polygons: [][]common.GeoPointGo{{{0, 0}, {0, 1}, {1, 1}, {1, 0}}}})
+		builder.AddRow()
+		builder.SetValue(1, 0, uint8(2))
+		// degenerated ring
+		builder.SetValue(1, 1, &common.GeoShapeGo{Polygons: [][]common.GeoPointGo{{{0, 0}, {1, 1}, {2, 2}, {0, 0}}}})
+		buffer, _ := builder.ToByteArray()
+		upsertBatch, _ := common.NewUpsertBatch(buffer)
+		err := memstore.HandleIngestion("abc", 0, upsertBatch)
+		Ω(err).Should(BeNil())
+
+		shard, err := memstore.GetTableShard("abc", 0)
+		Ω(err).Should(BeNil())
+		batch := shard.LiveStore.GetBatchForRead(BaseBatchID)
+		defer batch.RUnlock()
+		shapes := batch.Columns[1]
+		value := shapes.GetDataValue(0)
+		Ω(value.Valid).Should(BeTrue())
+		Ω(value.GoVal.(*common.GeoShapeGo).Polygons).Should(Equal([][]common.GeoPointGo{{{0, 0}, {0, 1}, {1, 1}, {1, 0}, {0, 0}}}))
+		Ω(shapes.GetDataValue(1).Valid).Should(BeFalse())
+	})
+
+	ginkgo.It("records ingestion time of rows as system column", func() {
+		utils.SetClockImplementation(func() time.Time {
+			return time.Unix(1000, 0)
+		})
+		defer utils.ResetClockImplementation()
+
+		memstore := createMemStore("abc", 0, []common.DataType{common.Uint8}, []int{0}, 10, false, false, nil, CreateMockDiskStore())
+		builder := common.NewUpsertBatchBuilder()
+		builder.AddColumn(0, common.Uint8)
+		builder.AddRow()
+		builder.SetValue(0, 0, uint8(123))
+		buffer, _ := builder.ToByteArray()
+		upsertBatch, _ := common.NewUpsertBatch(buffer)
+		err := memstore.HandleIngestion("abc", 0, upsertBatch)
+		Ω(err).Should(BeNil())
+
+		shard, err := memstore.GetTableShard("abc", 0)
+		batch := shard.LiveStore.GetBatchForRead(BaseBatchID)
+		defer batch.RUnlock()
+		ingestedAt := batch.GetSystemVectorParty(common.IngestedAtColumnID)
+		Ω(ingestedAt).ShouldNot(BeNil())
+		value := ingestedAt.GetDataValue(0)
+		Ω(value.Valid).Should(BeTrue())
+		Ω(*(*uint32)(value.OtherVal)).Should(Equal(uint32(1000)))
+		Ω(batch.GetSystemVectorParty(common.BatchIDColumnID)).Should(BeNil())
+
+		batchID := batch.GetSystemColumnValue(BaseBatchID, common.BatchIDColumnID)
+		Ω(*(*int32)(batchID.OtherVal)).Should(Equal(BaseBatchID))
+	})
+
 	ginkgo.It("skip old records", func() {
 		memstore := createMemStore("abc", 0, []common.DataType{common.Uint8}, []int{0}, 10, true, false, nil, CreateMockDiskStore())
 		shard, err := memstore.GetTableShard("abc", 0)
@@ -286,6 +363,35 @@ var _ = ginkgo.Describe("ingestion", func() {
 		Ω(*(*uint8)(value)).Should(Equal(uint8(104)))
 	})
 
+	ginkgo.It("applies records of different batches in parallel", func() {
+		memstore := createMemStore("abc", 0, []common.DataType{common.Uint8, common.Uint8}, []int{0}, 2, false, false, nil, CreateMockDiskStore())
+		builder := common.NewUpsertBatchBuilder()
+		builder.AddColumn(0, common.Uint8)
+		builder.AddColumn(1, common.Uint8)
+		for i, key := range []uint8{100, 101, 102, 100, 103, 102} {
+			builder.AddRow()
+			builder.SetValue(i, 0, key)
+			builder.SetValue(i, 1, uint8(i))
+		}
+
+		buffer, _ := builder.ToByteArray()
+		upsertBatch, _ := common.NewUpsertBatch(buffer)
+		shard, err := memstore.GetTableShard("abc", 0)
+		Ω(err).Should(BeNil())
+		shard.LiveStore.WriterLock.Lock()
+		_, err = shard.applyUpsertBatch(upsertBatch, 0, 0, false, 4)
+		shard.LiveStore.WriterLock.Unlock()
+		Ω(err).Should(BeNil())
+		Ω(shard.LiveStore.LastReadRecord.BatchID).Should(Equal(BaseBatchID + 2))
+
+		// later rows of the same primary key are applied after earlier ones.
+		for key, expected := range map[uint8]uint8{100: 3, 101: 1, 102: 5, 103: 4} {
+			value, valid := ReadShardValue(shard, 1, []byte{key})
+			Ω(valid).Should(BeTrue())
+			Ω(*(*uint8)(value)).Should(Equal(expected))
+		}
+	})
+
 	ginkgo.It("batch grows correctly with batch size changes", func() {
 		// Make sure batch is going correctly.
 		memstore := createMemStore("abc", 0, []common.DataType{common.Uint8}, []int{0}, 1, false, false, nil, CreateMockDiskStore())
@@ -0,0 +1,72 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/uber/aresdb/utils"
+)
+
+// IngestionThrottledError is returned when an upsert batch is rejected because the shard
+// cannot keep up with ingestion. Clients should retry after RetryAfter.
+type IngestionThrottledError struct {
+	Table      string
+	Shard      int
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *IngestionThrottledError) Error() string {
+	return fmt.Sprintf("ingestion into table %s shard %d is throttled: %s, retry after %v",
+		e.Table, e.Shard, e.Reason, e.RetryAfter)
+}
+
+// ingestionThrottle holds the thresholds above which ingestion is throttled.
+type ingestionThrottle struct {
+	memoryUsageRatio float64
+	maxRedoLogSize   int64
+	retryAfter       time.Duration
+}
+
+// check returns IngestionThrottledError if the memory usage or the unpurged redolog size of
+// the shard is above the threshold.
+func (t *ingestionThrottle) check(shard *TableShard) error {
+	var reason string
+	if t.memoryUsageRatio > 0 {
+		if reporter, ok := shard.HostMemoryManager.(interface{ unmanagedMemoryUsageRatio() float64 }); ok {
+			if ratio := reporter.unmanagedMemoryUsageRatio(); ratio >= t.memoryUsageRatio {
+				reason = fmt.Sprintf("memory usage ratio %.2f over %.2f", ratio, t.memoryUsageRatio)
+			}
+		}
+	}
+	if reason == "" && t.maxRedoLogSize > 0 {
+		if size := int64(shard.LiveStore.RedoLogManager.GetTotalSize()); size >= t.maxRedoLogSize {
+			reason = fmt.Sprintf("unpurged redolog size %d over %d", size, t.maxRedoLogSize)
+		}
+	}
+	if reason == "" {
+		return nil
+	}
+
+	utils.GetReporter(shard.Schema.Schema.Name, shard.ShardID).GetCounter(utils.IngestionThrottled).Inc(1)
+	return &IngestionThrottledError{
+		Table:      shard.Schema.Schema.Name,
+		Shard:      shard.ShardID,
+		Reason:     reason,
+		RetryAfter: t.retryAfter,
+	}
+}
@@ -556,3 +556,276 @@ func (job *PurgeJob) String() string {
 func (job *PurgeJob) JobType() common.JobType {
 	return common.PurgeJobType
 }
+
+type compactionJobManager struct {
+	sync.RWMutex
+	// compaction job details for different tables, shard. Key is {tableName}|{shardID}|compaction,
+	jobDetails map[string]*CompactionJobDetail
+	memStore   *memStoreImpl
+	scheduler  *schedulerImpl
+}
+
+// newCompactionJobManager creates a new jobManager to manage compaction jobs.
+func newCompactionJobManager(scheduler *schedulerImpl) jobManager {
+	return &compactionJobManager{
+		jobDetails: make(map[string]*CompactionJobDetail),
+		memStore:   scheduler.memStore,
+		scheduler:  scheduler,
+	}
+}
+
+// generateJobs iterates each dimension table shard from memStore and prepare list of compaction
+// jobs to run. It also reports the compaction lag of each table shard.
+func (m *compactionJobManager) generateJobs() []Job {
+	m.memStore.RLock()
+	defer m.memStore.RUnlock()
+
+	var jobs []Job
+	for tableName, shardMap := range m.memStore.TableShards {
+		for shardID, tableShard := range shardMap {
+			compactionManager := tableShard.LiveStore.CompactionManager
+			if compactionManager == nil || !tableShard.IsDiskDataAvailable() {
+				continue
+			}
+
+			utils.GetReporter(tableName, shardID).GetGauge(utils.CompactionLag).
+				Update(compactionManager.GetLag().Seconds())
+
+			key := getIdentifier(tableName, shardID, common.CompactionJobType)
+			paused := compactionManager.IsPaused()
+			if compactionManager.QualifyForCompaction() {
+				jobs = append(jobs, m.scheduler.NewCompactionJob(tableName, shardID))
+				m.reportCompactionJobDetail(key, func(jobDetail *CompactionJobDetail) {
+					jobDetail.Status = JobReady
+					jobDetail.Paused = paused
+				})
+			} else {
+				m.reportCompactionJobDetail(key, func(jobDetail *CompactionJobDetail) {
+					// the job detail has just been initialized.
+					if jobDetail.LastRun.Unix() == 0 {
+						jobDetail.Status = JobWaiting
+					}
+					jobDetail.Paused = paused
+				})
+			}
+		}
+	}
+	return jobs
+}
+
+func (m *compactionJobManager) getJobDetails() interface{} {
+	m.RLock()
+	defer m.RUnlock()
+	return m.jobDetails
+}
+
+// caller needs to hold the write lock.
+func (m *compactionJobManager) getJobDetail(key string) *CompactionJobDetail {
+	jobDetail, found := m.jobDetails[key]
+	if !found {
+		jobDetail = &CompactionJobDetail{}
+		m.jobDetails[key] = jobDetail
+	}
+	return jobDetail
+}
+
+func (m *compactionJobManager) reportJobDetail(key string, jobMutator jobDetailMutator) {
+	m.Lock()
+	defer m.Unlock()
+	compactionJobDetail := m.getJobDetail(key)
+	jobDetail := &compactionJobDetail.JobDetail
+	jobMutator(jobDetail)
+}
+
+// deleteTable deletes metadata for the table in compactionJobManager.
+func (m *compactionJobManager) deleteTable(table string) {
+	m.Lock()
+	defer m.Unlock()
+	for key := range m.jobDetails {
+		if strings.HasPrefix(key, table) {
+			delete(m.jobDetails, key)
+		}
+	}
+}
+
+func (m *compactionJobManager) reportCompactionJobDetail(key string, jobMutator CompactionJobDetailMutator) {
+	m.Lock()
+	defer m.Unlock()
+	jobMutator(m.getJobDetail(key))
+}
+
+// CompactionJob defines the structure that a compaction job needs.
+type CompactionJob struct {
+	tableName string
+	shardID   int
+	memStore  MemStore
+	reporter  CompactionJobDetailReporter
+}
+
+// Run starts the compaction process and wait for it to finish.
+func (job *CompactionJob) Run() error {
+	return job.memStore.Compact(job.tableName, job.shardID, job.reporter)
+}
+
+// GetIdentifier returns a unique identifier of this job.
+func (job *CompactionJob) GetIdentifier() string {
+	return getIdentifier(job.tableName, job.shardID, common.CompactionJobType)
+}
+
+// String gives meaningful string representation for this job
+func (job *CompactionJob) String() string {
+	return fmt.Sprintf("CompactionJob<Table: %s, ShardID: %d>",
+		job.tableName, job.shardID)
+}
+
+// JobType return job type
+func (job *CompactionJob) JobType() common.JobType {
+	return common.CompactionJobType
+}
+
+type hllRecomputeJobManager struct {
+	sync.RWMutex
+	// hll recompute job details for different tables, shard. Key is {tableName}|{shardID}|hllRecompute,
+	jobDetails map[string]*HLLRecomputeJobDetail
+	memStore   *memStoreImpl
+	scheduler  *schedulerImpl
+}
+
+// newHLLRecomputeJobManager creates a new jobManager to manage hll recompute jobs.
+func newHLLRecomputeJobManager(scheduler *schedulerImpl) jobManager {
+	return &hllRecomputeJobManager{
+		jobDetails: make(map[string]*HLLRecomputeJobDetail),
+		memStore:   scheduler.memStore,
+		scheduler:  scheduler,
+	}
+}
+
+// generateJobs iterates each fact table shard from memStore and prepare list of hll recompute
+// jobs to run for shards whose hll column precision got reduced.
+func (m *hllRecomputeJobManager) generateJobs() []Job {
+	m.memStore.RLock()
+	defer m.memStore.RUnlock()
+
+	var jobs []Job
+	for tableName, shardMap := range m.memStore.TableShards {
+		for shardID, tableShard := range shardMap {
+			if !tableShard.Schema.Schema.IsFactTable || !tableShard.IsDiskDataAvailable() {
+				continue
+			}
+
+			key := getIdentifier(tableName, shardID, common.HLLRecomputeJobType)
+			if tableShard.isHLLRecomputePending() {
+				jobs = append(jobs, m.scheduler.NewHLLRecomputeJob(tableName, shardID))
+				m.reportHLLRecomputeJobDetail(key, func(jobDetail *HLLRecomputeJobDetail) {
+					jobDetail.Status = JobReady
+				})
+			}
+		}
+	}
+	return jobs
+}
+
+func (m *hllRecomputeJobManager) getJobDetails() interface{} {
+	m.RLock()
+	defer m.RUnlock()
+	return m.jobDetails
+}
+
+// caller needs to hold the write lock.
+func (m *hllRecomputeJobManager) getJobDetail(key string) *HLLRecomputeJobDetail {
+	jobDetail, found := m.jobDetails[key]
+	if !found {
+		jobDetail = &HLLRecomputeJobDetail{}
+		m.jobDetails[key] = jobDetail
+	}
+	return jobDetail
+}
+
+func (m *hllRecomputeJobManager) reportJobDetail(key string, jobMutator jobDetailMutator) {
+	m.Lock()
+	defer m.Unlock()
+	hllRecomputeJobDetail := m.getJobDetail(key)
+	jobDetail := &hllRecomputeJobDetail.JobDetail
+	jobMutator(jobDetail)
+}
+
+// deleteTable deletes metadata for the table in hllRecomputeJobManager.
+func (m *hllRecomputeJobManager) deleteTable(table string) {
+	m.Lock()
+	defer m.Unlock()
+	for key := range m.jobDetails {
+		if strings.HasPrefix(key, table) {
+			delete(m.jobDetails, key)
+		}
+	}
+}
+
+func (m *hllRecomputeJobManager) reportHLLRecomputeJobDetail(key string, jobMutator HLLRecomputeJobDetailMutator) {
+	m.Lock()
+	defer m.Unlock()
+	jobMutator(m.getJobDetail(key))
+}
+
+// HLLRecomputeJob defines the structure that a hll recompute job needs.
+type HLLRecomputeJob struct {
+	tableName string
+	shardID   int
+	memStore  MemStore
+	reporter  HLLRecomputeJobDetailReporter
+}
+
+// Run starts the hll recomputation and wait for it to finish.
+func (job *HLLRecomputeJob) Run() error {
+	return job.memStore.RecomputeHLL(job.tableName, job.shardID, job.reporter)
+}
+
+// GetIdentifier returns a unique identifier of this job.
+func (job *HLLRecomputeJob) GetIdentifier() string {
+	return getIdentifier(job.tableName, job.shardID, common.HLLRecomputeJobType)
+}
+
+// String gives meaningful string representation for this job
+func (job *HLLRecomputeJob) String() string {
+	return fmt.Sprintf("HLLRecomputeJob<Table: %s, ShardID: %d>",
+		job.tableName, job.shardID)
+}
+
+// JobType return job type
+func (job *HLLRecomputeJob) JobType() common.JobType {
+	return common.HLLRecomputeJobType
+}
+
+// ArchiveBatchRepairJob defines the structure that an archive batch repair job needs. It has no
+// job manager since it's only submitted manually.
+type ArchiveBatchRepairJob struct {
+	tableName string
+	shardID   int
+	dump      *ArchiveBatchDump
+	memStore  MemStore
+}
+
+// Run replaces the archive batch with the dump.
+func (job *ArchiveBatchRepairJob) Run() error {
+	shard, err := job.memStore.GetTableShard(job.tableName, job.shardID)
+	if err != nil {
+		return err
+	}
+	defer shard.Users.Done()
+	return shard.ImportArchiveBatch(job.dump)
+}
+
+// GetIdentifier returns a unique identifier of this job.
+func (job *ArchiveBatchRepairJob) GetIdentifier() string {
+	return getIdentifier(job.tableName, job.shardID, common.ArchiveBatchRepairJobType)
+}
+
+// String gives meaningful string representation for this job
+func (job *ArchiveBatchRepairJob) String() string {
+	return fmt.Sprintf("ArchiveBatchRepairJob<Table: %s, ShardID: %d, BatchID: %d>",
+		job.tableName, job.shardID, job.dump.BatchID)
+}
+
+// JobType return job type
+func (job *ArchiveBatchRepairJob) JobType() common.JobType {
+	return common.ArchiveBatchRepairJobType
+}
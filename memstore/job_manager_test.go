@@ -268,6 +268,7 @@ var _ = ginkgo.Describe("job manager", func() {
 			  "lastStartTime": "0001-01-01T00:00:00Z",
 			  "numMutations": 0,
 			  "numBatches": 0,
+			  "numMergedBatches": 0,
 			  "redologFile": 0,
 			  "batchOffset": 0,
 			  "stage": ""
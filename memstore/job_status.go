@@ -55,11 +55,30 @@ type SnapshotStage string
 
 // List of SnapshotStages
 const (
+	SnapshotMerge    SnapshotStage = "merge"
 	SnapshotSnapshot SnapshotStage = "snapshot"
 	SnapshotCleanup  SnapshotStage = "cleanup"
 	SnapshotComplete SnapshotStage = "complete"
 )
 
+// CompactionStage represents different stages of a running compaction job.
+type CompactionStage string
+
+// List of compaction stages
+const (
+	CompactionCompact  CompactionStage = "compact"
+	CompactionComplete CompactionStage = "complete"
+)
+
+// HLLRecomputeStage represents different stages of a running hll recompute job.
+type HLLRecomputeStage string
+
+// List of hll recompute stages
+const (
+	HLLRecomputeRewrite  HLLRecomputeStage = "rewrite"
+	HLLRecomputeComplete HLLRecomputeStage = "complete"
+)
+
 // PurgeStage represents different stages of a running purge job.
 type PurgeStage string
 
@@ -95,6 +114,18 @@ type SnapshotJobDetailReporter func(key string, mutator SnapshotJobDetailMutator
 // PurgeJobDetailReporter is the functor to apply mutator changes to corresponding JobDetail.
 type PurgeJobDetailReporter func(key string, mutator PurgeJobDetailMutator)
 
+// CompactionJobDetailMutator is the mutator functor to change CompactionJobDetail.
+type CompactionJobDetailMutator func(jobDetail *CompactionJobDetail)
+
+// CompactionJobDetailReporter is the functor to apply mutator changes to corresponding JobDetail.
+type CompactionJobDetailReporter func(key string, mutator CompactionJobDetailMutator)
+
+// HLLRecomputeJobDetailMutator is the mutator functor to change HLLRecomputeJobDetail.
+type HLLRecomputeJobDetailMutator func(jobDetail *HLLRecomputeJobDetail)
+
+// HLLRecomputeJobDetailReporter is the functor to apply mutator changes to corresponding JobDetail.
+type HLLRecomputeJobDetailReporter func(key string, mutator HLLRecomputeJobDetailMutator)
+
 // jobDetailMutator is the functor that change JobDetail.
 type jobDetailMutator func(jobDetail *JobDetail)
 
@@ -164,6 +195,8 @@ type SnapshotJobDetail struct {
 	NumMutations int `json:"numMutations"`
 	// Number of batches written in this snapshot.
 	NumBatches int `json:"numBatches"`
+	// Number of undersized live batches merged away before this snapshot.
+	NumMergedBatches int `json:"numMergedBatches"`
 	// Current redolog file that's being backfilled.
 	RedologFile int64 `json:"redologFile"`
 	// Batch offset within the RedologFile.
@@ -182,3 +215,26 @@ type PurgeJobDetail struct {
 	BatchIDStart int `json:"batchIDStart"`
 	BatchIDEnd   int `json:"batchIDEnd"`
 }
+
+// CompactionJobDetail represents compaction job status of a table shard.
+type CompactionJobDetail struct {
+	JobDetail
+	// Stage of the job is running.
+	Stage CompactionStage `json:"stage"`
+	// Whether compaction of the table shard is paused.
+	Paused bool `json:"paused"`
+	// Number of sparse live batches merged away.
+	NumMergedBatches int `json:"numMergedBatches"`
+	// Number of rows no longer referenced by the primary key reclaimed.
+	NumReclaimedRows int `json:"numReclaimedRows"`
+}
+
+// HLLRecomputeJobDetail represents hll recompute job status of a table shard. Total and Current
+// are the number of archive batches to rewrite and rewritten.
+type HLLRecomputeJobDetail struct {
+	JobDetail
+	// Stage of the job is running.
+	Stage HLLRecomputeStage `json:"stage"`
+	// Number of archive batches rewritten.
+	NumRecomputedBatches int `json:"numRecomputedBatches"`
+}
@@ -143,6 +143,7 @@ var _ = ginkgo.Describe("json marshaller", func() {
 		Ω(err).Should(BeNil())
 		Ω(jsonStr).Should(MatchJSON(`{
 			"batchSize": 10,
+			"minBatchSize": 0,
 			"batches": {
 			  "1": {
 				"capacity": 0,
@@ -277,6 +278,7 @@ var _ = ginkgo.Describe("json marshaller", func() {
         "schema": null,
         "liveStore": {
           "batchSize": 0,
+          "minBatchSize": 0,
           "batches": {
             "1": {
               "capacity": 0,
@@ -0,0 +1,303 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"sync"
+
+	"github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// planLiveBatchMerge groups consecutive batches by their capacities so that each group of
+// undersized batches fits into a single batch of batchSize. Returns indexes of batches in
+// each group.
+func planLiveBatchMerge(capacities []int, batchSize int) (groups [][]int) {
+	var groupSize int
+	for i, capacity := range capacities {
+		if len(groups) > 0 && groupSize+capacity <= batchSize {
+			groups[len(groups)-1] = append(groups[len(groups)-1], i)
+			groupSize += capacity
+			continue
+		}
+		groups = append(groups, []int{i})
+		groupSize = capacity
+	}
+	return
+}
+
+// mergeLiveBatches merges consecutive undersized live batches of an adaptively sized dimension
+// table into batches of up to BatchSize rows to reduce per batch overhead. Fact table live
+// batches are short lived as they are purged after archiving and are not merged. Returns the
+// number of batches merged away.
+func (shard *TableShard) mergeLiveBatches() (int, error) {
+	if !shard.LiveStore.isAdaptiveBatchSize() {
+		return 0, nil
+	}
+	numMerged, _, err := shard.compactLiveBatches(false)
+	if err == nil {
+		utils.GetReporter(shard.Schema.Schema.Name, shard.ShardID).GetCounter(utils.SnapshotMergedBatches).Inc(int64(numMerged))
+	}
+	return numMerged, err
+}
+
+// compactLiveBatches merges consecutive sparse live batches of a dimension table into batches of
+// up to BatchSize rows. If reclaim is true, rows no longer referenced by the primary key are
+// dropped so that their space is reclaimed. Batch ids of dimension tables need to stay
+// contiguous for foreign table joins, so batches following merged batches are renumbered and
+// the primary key is updated to the new record ids. Returns the number of batches merged away
+// and the number of rows reclaimed.
+func (shard *TableShard) compactLiveBatches(reclaim bool) (numMerged, numReclaimed int, err error) {
+	ls := shard.LiveStore
+	if shard.Schema.Schema.IsFactTable {
+		return 0, 0, nil
+	}
+
+	// Block column deletion.
+	shard.columnDeletion.Lock()
+	defer shard.columnDeletion.Unlock()
+	// Block ingestion.
+	ls.WriterLock.Lock()
+	defer ls.WriterLock.Unlock()
+
+	nextWriteRecord := ls.NextWriteRecord
+	ls.RLock()
+	batches := make([]*LiveBatch, 0, int(nextWriteRecord.BatchID-BaseBatchID))
+	for batchID := BaseBatchID; batchID < nextWriteRecord.BatchID; batchID++ {
+		batch := ls.Batches[batchID]
+		if batch == nil {
+			ls.RUnlock()
+			return 0, 0, utils.StackError(nil, "Missing live batch %d of dimension table %s",
+				batchID, shard.Schema.Schema.Name)
+		}
+		batches = append(batches, batch)
+	}
+	currentBatch := ls.Batches[nextWriteRecord.BatchID]
+	ls.RUnlock()
+
+	// Rows still referenced by the primary key are needed to plan the merge only if unreferenced
+	// rows are reclaimed.
+	var referenced []referencedRows
+	sizes := make([]int, len(batches))
+	var hasUnreferencedRows bool
+	for i, batch := range batches {
+		sizes[i] = batch.Capacity
+		if reclaim {
+			rows, err := shard.getReferencedRows(batch, BaseBatchID+int32(i), batch.Capacity)
+			if err != nil {
+				return 0, 0, err
+			}
+			referenced = append(referenced, rows)
+			sizes[i] = len(rows.rows)
+			hasUnreferencedRows = hasUnreferencedRows || sizes[i] < batch.Capacity
+		}
+	}
+
+	groups := planLiveBatchMerge(sizes, ls.BatchSize)
+	if len(groups) == len(batches) && !hasUnreferencedRows {
+		return 0, 0, nil
+	}
+
+	// Collect new record ids of moved records first so that the primary key is left untouched on
+	// errors.
+	var moves []recordMove
+	newBatches := make(map[int32]*LiveBatch, len(groups)+1)
+	var compactedBatches []*LiveBatch
+	for i, group := range groups {
+		newBatchID := BaseBatchID + int32(i)
+		sources := make([]*LiveBatch, len(group))
+		sourceRows := make([][]int, len(group))
+		var start int
+		for j, index := range group {
+			batch, oldBatchID := batches[index], BaseBatchID+int32(index)
+			sources[j] = batch
+			if reclaim {
+				moves = referenced[index].appendRecordMoves(moves, oldBatchID, newBatchID, start, true)
+				sourceRows[j] = referenced[index].rows
+			} else {
+				if oldBatchID != newBatchID || start != 0 {
+					rows, err := shard.getReferencedRows(batch, oldBatchID, batch.Capacity)
+					if err != nil {
+						return 0, 0, err
+					}
+					moves = rows.appendRecordMoves(moves, oldBatchID, newBatchID, start, false)
+				}
+				sourceRows[j] = allRows(batch.Capacity)
+			}
+			start += len(sourceRows[j])
+			numReclaimed += batch.Capacity - len(sourceRows[j])
+		}
+
+		if len(group) == 1 && len(sourceRows[0]) == sources[0].Capacity {
+			newBatches[newBatchID] = sources[0]
+			continue
+		}
+		newBatches[newBatchID] = ls.mergeBatches(sources, sourceRows)
+		compactedBatches = append(compactedBatches, sources...)
+	}
+	newCurrentBatchID := BaseBatchID + int32(len(groups))
+	if currentBatch != nil {
+		rows, err := shard.getReferencedRows(currentBatch, nextWriteRecord.BatchID, int(nextWriteRecord.Index))
+		if err != nil {
+			return 0, 0, err
+		}
+		moves = rows.appendRecordMoves(moves, nextWriteRecord.BatchID, newCurrentBatchID, 0, false)
+		newBatches[newCurrentBatchID] = currentBatch
+	}
+
+	for _, move := range moves {
+		ls.PrimaryKey.Update(move.key, move.recordID)
+	}
+
+	ls.Lock()
+	ls.Batches = newBatches
+	ls.LastReadRecord.BatchID -= nextWriteRecord.BatchID - newCurrentBatchID
+	ls.Unlock()
+	ls.NextWriteRecord.BatchID = newCurrentBatchID
+	ls.SnapshotManager.renumberCurrentRecord(nextWriteRecord.BatchID - newCurrentBatchID)
+
+	for _, batch := range compactedBatches {
+		ls.destructBatch(batch)
+	}
+
+	return len(batches) - len(groups), numReclaimed, nil
+}
+
+// allRows returns indexes of all rows of a batch.
+func allRows(numRows int) []int {
+	rows := make([]int, numRows)
+	for row := range rows {
+		rows[row] = row
+	}
+	return rows
+}
+
+// mergeBatches copies the rows of the sources into a new batch in order. Caller needs to hold
+// the writer lock.
+func (s *LiveStore) mergeBatches(sources []*LiveBatch, rows [][]int) *LiveBatch {
+	merged := &LiveBatch{
+		Batch: common.Batch{
+			RWMutex: &sync.RWMutex{},
+		},
+		liveStore: s,
+		createdAt: sources[0].createdAt,
+	}
+
+	var numColumns int
+	for i, source := range sources {
+		merged.Capacity += len(rows[i])
+		if len(source.Columns) > numColumns {
+			numColumns = len(source.Columns)
+		}
+		if source.MaxArrivalTime > merged.MaxArrivalTime {
+			merged.MaxArrivalTime = source.MaxArrivalTime
+		}
+	}
+	// batches need at least one row.
+	if merged.Capacity == 0 {
+		merged.Capacity = 1
+	}
+	merged.Columns = make([]common.VectorParty, numColumns)
+
+	for columnID := 0; columnID < numColumns; columnID++ {
+		var vp common.LiveVectorParty
+		var start int
+		for i, source := range sources {
+			if sourceVP := source.GetVectorParty(columnID); sourceVP != nil {
+				if vp == nil {
+					vp = merged.GetOrCreateVectorParty(columnID, true)
+				}
+				copyLiveVectorParty(sourceVP, vp, start, rows[i])
+			}
+			start += len(rows[i])
+		}
+	}
+
+	var start int
+	for i, source := range sources {
+		if source.ingestedAt != nil {
+			copyLiveVectorParty(source.ingestedAt, merged.getOrCreateIngestedAt(), start, rows[i])
+		}
+		start += len(rows[i])
+	}
+	return merged
+}
+
+// copyLiveVectorParty copies values of the rows of the source vector party to the destination
+// vector party starting at start.
+func copyLiveVectorParty(source common.VectorParty, dest common.LiveVectorParty, start int, rows []int) {
+	for i, row := range rows {
+		dest.SetDataValue(start+i, source.GetDataValue(row), common.IgnoreCount)
+	}
+}
+
+// recordMove is the new record id of a primary key.
+type recordMove struct {
+	key      []byte
+	recordID common.RecordID
+}
+
+// referencedRows are rows of a live batch still referenced by the primary key and their
+// primary keys.
+type referencedRows struct {
+	rows []int
+	keys [][]byte
+}
+
+// appendRecordMoves appends new record ids of the rows moved into the batch of newBatchID. If
+// packed is true, the rows are copied to consecutive rows starting at start, otherwise each row
+// is copied to start plus its index.
+func (r referencedRows) appendRecordMoves(moves []recordMove, oldBatchID, newBatchID int32, start int,
+	packed bool) []recordMove {
+	for i, row := range r.rows {
+		newIndex := start + row
+		if packed {
+			newIndex = start + i
+		}
+		if oldBatchID == newBatchID && newIndex == row {
+			continue
+		}
+		moves = append(moves, recordMove{
+			key:      r.keys[i],
+			recordID: common.RecordID{BatchID: newBatchID, Index: uint32(newIndex)},
+		})
+	}
+	return moves
+}
+
+// getReferencedRows returns the rows among the first numRows rows of the batch that are still
+// referenced by the primary key. Caller needs to hold the writer lock.
+func (shard *TableShard) getReferencedRows(batch *LiveBatch, batchID int32, numRows int) (referencedRows, error) {
+	primaryKey := shard.LiveStore.PrimaryKey
+	primaryKeyBytes := shard.Schema.PrimaryKeyBytes
+	primaryKeyColumns := shard.Schema.GetPrimaryKeyColumns()
+	primaryKeyValues := make([]common.DataValue, len(primaryKeyColumns))
+	var referenced referencedRows
+	for row := 0; row < numRows; row++ {
+		for i, columnID := range primaryKeyColumns {
+			primaryKeyValues[i] = batch.GetDataValue(row, columnID)
+		}
+		key, err := common.GetPrimaryKeyBytes(primaryKeyValues, primaryKeyBytes)
+		if err != nil {
+			return referenced, err
+		}
+		if recordID, found := primaryKey.Find(key); found &&
+			recordID.BatchID == batchID && recordID.Index == uint32(row) {
+			referenced.rows = append(referenced.rows, row)
+			referenced.keys = append(referenced.keys, key)
+		}
+	}
+	return referenced, nil
+}
@@ -0,0 +1,201 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"time"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	diskMocks "github.com/uber/aresdb/diskstore/mocks"
+	memCom "github.com/uber/aresdb/memstore/common"
+	metaMocks "github.com/uber/aresdb/metastore/mocks"
+	"github.com/uber/aresdb/testing"
+	"github.com/uber/aresdb/utils"
+)
+
+var _ = ginkgo.Describe("live batch merge and compaction", func() {
+	const tableName = "cities"
+
+	var memStore *memStoreImpl
+	var shard *TableShard
+	now := time.Unix(1000, 0)
+
+	// ingest inserts one row per upsert batch an hour apart so that batches stay at minimum size.
+	ingest := func(start, end int) {
+		for i := start; i < end; i++ {
+			utils.SetCurrentTime(now.Add(time.Duration(i) * time.Hour))
+			builder := memCom.NewUpsertBatchBuilder()
+			builder.AddColumn(0, memCom.Uint16)
+			builder.AddColumn(1, memCom.Uint32)
+			builder.AddRow()
+			builder.SetValue(0, 0, uint16(i))
+			builder.SetValue(0, 1, uint32(i*10))
+			buffer, _ := builder.ToByteArray()
+			upsertBatch, _ := memCom.NewUpsertBatch(buffer)
+			Ω(memStore.HandleIngestion(tableName, 0, upsertBatch)).Should(BeNil())
+		}
+	}
+
+	// checkRows checks that rows can be looked up by primary key.
+	checkRows := func(numRows int) {
+		for i := 0; i < numRows; i++ {
+			value, valid := ReadShardValue(shard, 1, []byte{byte(i), 0})
+			Ω(valid).Should(BeTrue())
+			Ω(*(*uint32)(value)).Should(Equal(uint32(i * 10)))
+		}
+	}
+
+	ginkgo.BeforeEach(func() {
+		diskStore := &diskMocks.DiskStore{}
+		diskStore.On("OpenLogFileForAppend", mock.Anything, mock.Anything, mock.Anything).Return(&testing.TestReadWriteCloser{}, nil)
+		memStore = createMemStore(tableName, 0, []memCom.DataType{memCom.Uint16, memCom.Uint32},
+			[]int{0}, 8, false, false, &metaMocks.MetaStore{}, diskStore)
+		shard, _ = memStore.GetTableShard(tableName, 0)
+		shard.LiveStore.MinBatchSize = 2
+	})
+
+	ginkgo.AfterEach(func() {
+		utils.ResetClockImplementation()
+	})
+
+	ginkgo.It("planLiveBatchMerge should work", func() {
+		Ω(planLiveBatchMerge(nil, 8)).Should(BeEmpty())
+		Ω(planLiveBatchMerge([]int{8, 8}, 8)).Should(Equal([][]int{{0}, {1}}))
+		Ω(planLiveBatchMerge([]int{2, 2, 2, 4, 8, 2, 2}, 8)).Should(Equal([][]int{{0, 1, 2}, {3}, {4}, {5, 6}}))
+		Ω(planLiveBatchMerge([]int{4, 4, 4}, 8)).Should(Equal([][]int{{0, 1}, {2}}))
+	})
+
+	ginkgo.It("merges undersized batches and renumbers following batches", func() {
+		ingest(0, 7)
+		ls := shard.LiveStore
+		Ω(ls.Batches).Should(HaveLen(4))
+		for _, batch := range ls.Batches {
+			Ω(batch.Capacity).Should(Equal(2))
+		}
+		Ω(ls.NextWriteRecord).Should(Equal(memCom.RecordID{BatchID: BaseBatchID + 3, Index: 1}))
+
+		numMerged, err := shard.mergeLiveBatches()
+		Ω(err).Should(BeNil())
+		Ω(numMerged).Should(Equal(2))
+		Ω(ls.Batches).Should(HaveLen(2))
+		Ω(ls.Batches[BaseBatchID].Capacity).Should(Equal(6))
+		Ω(ls.Batches[BaseBatchID+1].Capacity).Should(Equal(2))
+		Ω(ls.NextWriteRecord).Should(Equal(memCom.RecordID{BatchID: BaseBatchID + 1, Index: 1}))
+		Ω(ls.LastReadRecord).Should(Equal(ls.NextWriteRecord))
+		_, _, _, currentRecord := ls.SnapshotManager.StartSnapshot()
+		Ω(currentRecord).Should(Equal(ls.NextWriteRecord))
+
+		batchIDs, numRecordsInLastBatch := ls.GetBatchIDs()
+		Ω(batchIDs).Should(ConsistOf(BaseBatchID, BaseBatchID+1))
+		Ω(numRecordsInLastBatch).Should(Equal(1))
+		checkRows(7)
+		for i := 0; i < 7; i++ {
+			record, found := ls.PrimaryKey.Find([]byte{byte(i), 0})
+			Ω(found).Should(BeTrue())
+			Ω(record).Should(Equal(memCom.RecordID{BatchID: BaseBatchID + int32(i/6), Index: uint32(i % 6)}))
+		}
+
+		// nothing more to merge.
+		numMerged, err = shard.mergeLiveBatches()
+		Ω(err).Should(BeNil())
+		Ω(numMerged).Should(Equal(0))
+
+		// ingestion continues after the renumbered batch.
+		ingest(7, 10)
+		checkRows(10)
+		Ω(ls.Batches).Should(HaveLen(3))
+	})
+
+	ginkgo.It("compaction reclaims rows no longer referenced by the primary key", func() {
+		shard.LiveStore.MinBatchSize = 0
+		ingest(0, 20)
+		ls := shard.LiveStore
+		Ω(ls.Batches).Should(HaveLen(3))
+		Ω(ls.NextWriteRecord).Should(Equal(memCom.RecordID{BatchID: BaseBatchID + 2, Index: 4}))
+		for i := 1; i < 15; i++ {
+			if i != 7 && i != 8 {
+				ls.PrimaryKey.Delete([]byte{byte(i), 0})
+			}
+		}
+
+		var jobDetail CompactionJobDetail
+		Ω(memStore.Compact(tableName, 0, func(key string, mutator CompactionJobDetailMutator) {
+			Ω(key).Should(Equal("cities|0|compaction"))
+			mutator(&jobDetail)
+		})).Should(BeNil())
+		Ω(jobDetail.Stage).Should(Equal(CompactionComplete))
+		Ω(jobDetail.NumMergedBatches).Should(Equal(1))
+		Ω(jobDetail.NumReclaimedRows).Should(Equal(12))
+		Ω(ls.CompactionManager.LastCompactionTime).Should(Equal(utils.Now()))
+
+		Ω(ls.Batches).Should(HaveLen(2))
+		Ω(ls.Batches[BaseBatchID].Capacity).Should(Equal(4))
+		Ω(ls.NextWriteRecord).Should(Equal(memCom.RecordID{BatchID: BaseBatchID + 1, Index: 4}))
+		for i, key := range []int{0, 7, 8, 15, 16, 17, 18, 19} {
+			record, found := ls.PrimaryKey.Find([]byte{byte(key), 0})
+			Ω(found).Should(BeTrue())
+			Ω(record).Should(Equal(memCom.RecordID{BatchID: BaseBatchID + int32(i/4), Index: uint32(i % 4)}))
+			value, valid := ReadShardValue(shard, 1, []byte{byte(key), 0})
+			Ω(valid).Should(BeTrue())
+			Ω(*(*uint32)(value)).Should(Equal(uint32(key * 10)))
+		}
+
+		// nothing more to compact.
+		numMerged, numReclaimed, err := shard.compactLiveBatches(true)
+		Ω(err).Should(BeNil())
+		Ω(numMerged).Should(Equal(0))
+		Ω(numReclaimed).Should(Equal(0))
+	})
+
+	ginkgo.It("compaction manager schedules compaction by interval unless paused", func() {
+		utils.SetCurrentTime(now)
+		manager := &CompactionManager{LastCompactionTime: now}
+		utils.SetCurrentTime(now.Add(2 * time.Hour))
+		Ω(manager.QualifyForCompaction()).Should(BeFalse())
+		Ω(manager.GetLag()).Should(BeZero())
+
+		manager.CompactionInterval = time.Hour
+		Ω(manager.QualifyForCompaction()).Should(BeTrue())
+		Ω(manager.GetLag()).Should(Equal(time.Hour))
+
+		manager.SetPaused(true)
+		Ω(manager.IsPaused()).Should(BeTrue())
+		Ω(manager.QualifyForCompaction()).Should(BeFalse())
+		manager.SetPaused(false)
+		Ω(manager.QualifyForCompaction()).Should(BeTrue())
+
+		manager.Done()
+		Ω(manager.QualifyForCompaction()).Should(BeFalse())
+		Ω(manager.GetLag()).Should(BeZero())
+	})
+
+	ginkgo.It("does not merge fact tables or fixed size batches", func() {
+		ingest(0, 5)
+		shard.LiveStore.MinBatchSize = 0
+		numMerged, err := shard.mergeLiveBatches()
+		Ω(err).Should(BeNil())
+		Ω(numMerged).Should(Equal(0))
+		Ω(shard.LiveStore.Batches).Should(HaveLen(3))
+
+		shard.LiveStore.MinBatchSize = 2
+		shard.Schema.Schema.IsFactTable = true
+		numMerged, err = shard.mergeLiveBatches()
+		Ω(err).Should(BeNil())
+		Ω(numMerged).Should(Equal(0))
+		shard.Schema.Schema.IsFactTable = false
+	})
+})
@@ -19,6 +19,7 @@ import (
 	"github.com/uber/aresdb/memstore/vectors"
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/uber/aresdb/memstore/common"
@@ -29,6 +30,15 @@ import (
 // BaseBatchID is the starting id of all batches.
 const BaseBatchID = int32(math.MinInt32)
 
+const (
+	// targetBatchFillDuration is the time a new live batch is expected to be filled in at the
+	// current ingestion rate when live batches are adaptively sized.
+	targetBatchFillDuration = 10 * time.Minute
+	// memoryPressureRatio is the ratio of unmanaged memory usage to the memory limit above which
+	// new live batches are created with the minimum size.
+	memoryPressureRatio = 0.8
+)
+
 // LiveBatch represents a live batch.
 type LiveBatch struct {
 	// The common data structure holding column data.
@@ -43,6 +53,12 @@ type LiveBatch struct {
 
 	// maximum of arrival time
 	MaxArrivalTime uint32
+
+	// Ingestion time of each row for the __ingested_at system column, created on first write.
+	ingestedAt common.LiveVectorParty
+
+	// Creation time of the batch, used to measure the ingestion rate.
+	createdAt time.Time
 }
 
 // LiveStore stores live batches of columnar data.
@@ -54,9 +70,13 @@ type LiveStore struct {
 	// The batch id to batch map.
 	Batches map[int32]*LiveBatch
 
-	// Number of rows to create for new batches.
+	// Number of rows to create for new batches. When MinBatchSize is positive, it's the upper
+	// bound of the capacity of new batches.
 	BatchSize int
 
+	// Lower bound of the capacity of new batches, 0 disables adaptive sizing.
+	MinBatchSize int
+
 	// The upper bound of records (exclusive) that can be read by queries.
 	LastReadRecord common.RecordID
 
@@ -74,6 +94,9 @@ type LiveStore struct {
 	// Manage snapshot related stats.
 	SnapshotManager *SnapshotManager
 
+	// Manage compaction schedule of dimension tables.
+	CompactionManager *CompactionManager
+
 	// For convenience. Schema locks should be acquired after data locks.
 	tableSchema *common.TableSchema
 
@@ -92,6 +115,10 @@ type LiveStore struct {
 	// The position of the next record to be used for writing. Only used by the ingester.
 	NextWriteRecord common.RecordID
 
+	// Capacity of the next batch to create when live batches are adaptively sized, 0 for
+	// MinBatchSize.
+	nextBatchCapacity int
+
 	// For convenience.
 	HostMemoryManager common.HostMemoryManager `json:"-"`
 
@@ -99,6 +126,14 @@ type LiveStore struct {
 	// Protected by the writer lock of live store. If a column is never ingested, thhe last modified time will be zero.
 	// Metrics will be emitted after each ingestion request.
 	lastModifiedTimePerColumn []uint32
+
+	// Max event time ingested and seconds between arrival and ingestion of the last upsert batch.
+	// Written under the writer lock and accessed atomically so that queries can read them without it.
+	highWatermarkEventTime uint32
+	redoLogLag             uint32
+
+	// Position of the last upsert batch applied, for queries to read their writes.
+	writePosition writePositionTracker
 }
 
 // NewLiveStore creates a new live batch.
@@ -113,6 +148,7 @@ func NewLiveStore(batchSize int, shard *TableShard) *LiveStore {
 	}
 	ls := &LiveStore{
 		BatchSize:       batchSize,
+		MinBatchSize:    tableCfg.MinBatchSize,
 		Batches:         make(map[int32]*LiveBatch),
 		tableSchema:     schema,
 		LastReadRecord:  common.RecordID{BatchID: BaseBatchID, Index: 0},
@@ -136,6 +172,7 @@ func NewLiveStore(batchSize int, shard *TableShard) *LiveStore {
 			int64(ls.BackfillManager.MaxBufferSize * utils.GolangMemoryFootprintFactor))
 	} else {
 		ls.SnapshotManager = NewSnapshotManager(shard)
+		ls.CompactionManager = NewCompactionManager(shard)
 	}
 	return ls
 }
@@ -158,6 +195,18 @@ func (s *LiveStore) GetBatchIDs() (batchIDs []int32, numRecordsInLastBatch int)
 	return
 }
 
+// getAllBatches returns all live batches including batches allocated but not visible to
+// readers yet.
+func (s *LiveStore) getAllBatches() []*LiveBatch {
+	s.RLock()
+	defer s.RUnlock()
+	batches := make([]*LiveBatch, 0, len(s.Batches))
+	for _, batch := range s.Batches {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
 // GetBatchForRead returns and read locks the batch with its ID for reads. Caller must explicitly
 // RUnlock() the returned batch after all reads.
 func (s *LiveStore) GetBatchForRead(id int32) *LiveBatch {
@@ -207,6 +256,7 @@ func (s *LiveStore) AdvanceNextWriteRecord() common.RecordID {
 	}
 
 	if int(s.NextWriteRecord.Index)+1 == batch.Capacity {
+		s.nextBatchCapacity = s.adaptBatchCapacity(batch)
 		s.NextWriteRecord.BatchID++
 		s.NextWriteRecord.Index = 0
 	} else {
@@ -216,6 +266,12 @@ func (s *LiveStore) AdvanceNextWriteRecord() common.RecordID {
 	return s.NextWriteRecord
 }
 
+// GetDataFreshness returns the max event time ingested and the lag in seconds of
+// ingesting the last upsert batch from redolog.
+func (s *LiveStore) GetDataFreshness() (highWatermark, redoLogLag uint32) {
+	return atomic.LoadUint32(&s.highWatermarkEventTime), atomic.LoadUint32(&s.redoLogLag)
+}
+
 // AdvanceLastReadRecord advances the high watermark of the rows to the next write record.
 func (s *LiveStore) AdvanceLastReadRecord() {
 	s.Lock()
@@ -236,8 +292,9 @@ func (s *LiveStore) appendBatch(batchID int32) *LiveBatch {
 			RWMutex: &sync.RWMutex{},
 			Columns: make([]common.VectorParty, numColumns),
 		},
-		Capacity:  s.BatchSize,
+		Capacity:  s.getNewBatchCapacity(),
 		liveStore: s,
+		createdAt: utils.Now(),
 	}
 	s.Lock()
 	s.Batches[batchID] = batch
@@ -245,6 +302,48 @@ func (s *LiveStore) appendBatch(batchID int32) *LiveBatch {
 	return batch
 }
 
+// isAdaptiveBatchSize tells whether live batches are adaptively sized.
+func (s *LiveStore) isAdaptiveBatchSize() bool {
+	return s.MinBatchSize > 0 && s.MinBatchSize < s.BatchSize
+}
+
+// getNewBatchCapacity returns the capacity of the next batch to create.
+func (s *LiveStore) getNewBatchCapacity() int {
+	if !s.isAdaptiveBatchSize() {
+		return s.BatchSize
+	}
+	if s.nextBatchCapacity > 0 {
+		return s.nextBatchCapacity
+	}
+	return s.MinBatchSize
+}
+
+// adaptBatchCapacity returns the capacity of the batch following the filled batch so that it
+// will be filled in about targetBatchFillDuration at the ingestion rate observed when filling
+// the batch. Minimum capacity is used under memory pressure so that idle rows preallocated for
+// low traffic tables don't take up the memory.
+func (s *LiveStore) adaptBatchCapacity(filled *LiveBatch) int {
+	if !s.isAdaptiveBatchSize() {
+		return s.BatchSize
+	}
+	if reporter, ok := s.HostMemoryManager.(interface{ underMemoryPressure() bool }); ok && reporter.underMemoryPressure() {
+		return s.MinBatchSize
+	}
+
+	fillDuration := utils.Now().Sub(filled.createdAt)
+	if fillDuration <= 0 {
+		return s.BatchSize
+	}
+	capacity := float64(filled.Capacity) * float64(targetBatchFillDuration) / float64(fillDuration)
+	if capacity >= float64(s.BatchSize) {
+		return s.BatchSize
+	}
+	if capacity <= float64(s.MinBatchSize) {
+		return s.MinBatchSize
+	}
+	return int(capacity)
+}
+
 // PurgeBatch purges the specified batch.
 func (s *LiveStore) PurgeBatch(id int32) {
 	s.Lock()
@@ -254,18 +353,28 @@ func (s *LiveStore) PurgeBatch(id int32) {
 	s.Unlock()
 
 	if batch != nil {
-		// Wait for readers to finish.
-		batch.Lock()
-		batch.Unlock()
-		// SafeDestruct.
-		for _, vp := range batch.Columns {
-			if vp != nil {
-				bytes := -vp.GetBytes()
-				vp.SafeDestruct()
-				s.HostMemoryManager.ReportUnmanagedSpaceUsageChange(bytes)
-			}
+		s.destructBatch(batch)
+	}
+}
+
+// destructBatch waits for readers of a detached batch to finish and destructs its vectors.
+func (s *LiveStore) destructBatch(batch *LiveBatch) {
+	// Wait for readers to finish.
+	batch.Lock()
+	batch.Unlock()
+	// SafeDestruct.
+	for _, vp := range batch.Columns {
+		if vp != nil {
+			bytes := -vp.GetBytes()
+			vp.SafeDestruct()
+			s.HostMemoryManager.ReportUnmanagedSpaceUsageChange(bytes)
 		}
 	}
+	if batch.ingestedAt != nil {
+		bytes := -batch.ingestedAt.GetBytes()
+		batch.ingestedAt.SafeDestruct()
+		s.HostMemoryManager.ReportUnmanagedSpaceUsageChange(bytes)
+	}
 }
 
 // Destruct deletes all vectors allocated in C.
@@ -311,6 +420,7 @@ func (s *LiveStore) MarshalJSON() ([]byte, error) {
 	}
 	jsonMap["batches"] = json.RawMessage(batchMapJSON)
 	jsonMap["batchSize"] = s.BatchSize
+	jsonMap["minBatchSize"] = s.MinBatchSize
 	jsonMap["lastReadRecord"] = s.LastReadRecord
 	jsonMap["lastModifiedTimePerColumn"] = s.lastModifiedTimePerColumn
 
@@ -427,12 +537,14 @@ func (b *LiveBatch) GetOrCreateVectorParty(columnID int, locked bool) common.Liv
 		b.liveStore.tableSchema.RLock()
 		dataType := b.liveStore.tableSchema.ValueTypeByColumn[columnID]
 		defaultValue := *b.liveStore.tableSchema.DefaultValues[columnID]
+		stringDict := b.liveStore.tableSchema.GetStringDict(columnID)
 		b.liveStore.tableSchema.RUnlock()
 
 		bytes := vectors.CalculateVectorPartyBytes(dataType, b.Capacity, true, false)
 		b.liveStore.HostMemoryManager.ReportUnmanagedSpaceUsageChange(int64(bytes))
 		liveVP := NewLiveVectorParty(b.Capacity, dataType, defaultValue, b.liveStore.HostMemoryManager)
 		liveVP.Allocate(false)
+		registerStringDictionary(liveVP, stringDict)
 
 		if !locked {
 			b.Lock()
@@ -446,6 +558,18 @@ func (b *LiveBatch) GetOrCreateVectorParty(columnID int, locked bool) common.Liv
 	return b.Columns[columnID].(common.LiveVectorParty)
 }
 
+// getOrCreateIngestedAt returns the vector party of row ingestion times, caller needs to hold the
+// write lock of the batch.
+func (b *LiveBatch) getOrCreateIngestedAt() common.LiveVectorParty {
+	if b.ingestedAt == nil {
+		bytes := vectors.CalculateVectorPartyBytes(common.Uint32, b.Capacity, true, false)
+		b.liveStore.HostMemoryManager.ReportUnmanagedSpaceUsageChange(int64(bytes))
+		b.ingestedAt = NewLiveVectorParty(b.Capacity, common.Uint32, common.NullDataValue, b.liveStore.HostMemoryManager)
+		b.ingestedAt.Allocate(false)
+	}
+	return b.ingestedAt
+}
+
 // MarshalJSON marshals a LiveBatch into json.
 func (b *LiveBatch) MarshalJSON() ([]byte, error) {
 	b.RLock()
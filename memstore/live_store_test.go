@@ -15,9 +15,12 @@
 package memstore
 
 import (
+	"time"
+
 	"github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/utils"
 )
 
 var _ = ginkgo.Describe("live store", func() {
@@ -232,4 +235,59 @@ var _ = ginkgo.Describe("live store", func() {
 		liveBatch3.Unlock()
 		Ω(liveBatch3 == liveBatch1).Should(BeTrue())
 	})
+
+	ginkgo.It("adapts batch capacity to ingestion rate and memory pressure", func() {
+		shard := &TableShard{
+			Schema: &common.TableSchema{
+				ValueTypeByColumn: []common.DataType{common.Uint32},
+				DefaultValues:     []*common.DataValue{&common.NullDataValue},
+			},
+			diskStore:         mockDiskStore,
+			HostMemoryManager: hostMemoryManager,
+			options:           m.options,
+		}
+		m.options.redoLogMaster.Stop()
+		vs := NewLiveStore(16, shard)
+		vs.MinBatchSize = 4
+
+		now := time.Unix(1000, 0)
+		utils.SetCurrentTime(now)
+		defer utils.ResetClockImplementation()
+
+		// first batch starts with the minimum capacity.
+		record := vs.AdvanceNextWriteRecord()
+		batch := vs.GetBatchForRead(BaseBatchID)
+		batch.RUnlock()
+		Ω(batch.Capacity).Should(Equal(4))
+
+		// filled in a quarter of the target fill duration.
+		utils.SetCurrentTime(now.Add(targetBatchFillDuration / 4))
+		for record.BatchID == BaseBatchID {
+			record = vs.AdvanceNextWriteRecord()
+		}
+		Ω(vs.nextBatchCapacity).Should(Equal(16))
+		vs.AdvanceNextWriteRecord()
+		batch = vs.GetBatchForRead(BaseBatchID + 1)
+		batch.RUnlock()
+		Ω(batch.Capacity).Should(Equal(16))
+
+		// rate is measured on the filled batch.
+		batch.createdAt = now
+		Ω(vs.adaptBatchCapacity(batch)).Should(Equal(16))
+		utils.SetCurrentTime(now.Add(targetBatchFillDuration * 2))
+		Ω(vs.adaptBatchCapacity(batch)).Should(Equal(8))
+		utils.SetCurrentTime(now.Add(targetBatchFillDuration * 8))
+		Ω(vs.adaptBatchCapacity(batch)).Should(Equal(4))
+
+		// minimum capacity is used under memory pressure.
+		utils.SetCurrentTime(now.Add(targetBatchFillDuration / 4))
+		vs.HostMemoryManager = NewHostMemoryManager(m, 100)
+		vs.HostMemoryManager.ReportUnmanagedSpaceUsageChange(80)
+		Ω(vs.adaptBatchCapacity(batch)).Should(Equal(4))
+
+		// fixed batch size without minimum batch size.
+		vs.MinBatchSize = 0
+		Ω(vs.adaptBatchCapacity(batch)).Should(Equal(16))
+		Ω(vs.getNewBatchCapacity()).Should(Equal(16))
+	})
 })
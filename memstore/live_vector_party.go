@@ -120,6 +120,7 @@ func (vp *goLiveVectorParty) SetGoValue(offset int, val common.GoDataValue, vali
 		newBytes = 0
 		vp.values[offset] = nil
 	} else {
+		computeGeoCovering(val)
 		newBytes = val.GetBytes()
 		vp.values[offset] = val
 	}
@@ -129,6 +130,14 @@ func (vp *goLiveVectorParty) SetGoValue(offset int, val common.GoDataValue, vali
 	vp.totalBytes += bytesChange
 }
 
+// computeGeoCovering computes s2 cell coverings of geo shapes when they are stored, so that
+// geo intersection queries look up shapes by cells of points without covering shapes per query.
+func computeGeoCovering(val common.GoDataValue) {
+	if shape, ok := val.(*common.GeoShapeGo); ok {
+		shape.ComputeCovering()
+	}
+}
+
 // GetDataValue implements GetDataValue in VectorParty interface
 func (vp *goLiveVectorParty) GetDataValue(offset int) common.DataValue {
 	val := common.DataValue{
@@ -274,6 +283,7 @@ func (vp *goLiveVectorParty) Read(reader io.Reader, serializer common.VectorPart
 		if err != nil {
 			return err
 		}
+		computeGeoCovering(goValue)
 		vp.values[index] = goValue
 	}
 	return nil
@@ -335,6 +345,9 @@ func (vp *goLiveVectorParty) Dump(file *os.File) {
 
 // NewLiveVectorParty creates LiveVectorParty
 func NewLiveVectorParty(length int, dataType common.DataType, defaultValue common.DataValue, hostMemoryManager common.HostMemoryManager) common.LiveVectorParty {
+	if dataType == common.String {
+		return newStringLiveVectorParty(length, hostMemoryManager)
+	}
 	isGoType := common.IsGoType(dataType)
 	if isGoType {
 		return newGoLiveVetorParty(length, dataType, hostMemoryManager)
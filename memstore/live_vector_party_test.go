@@ -19,6 +19,7 @@ import (
 	"github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/utils"
 )
 
 var _ = ginkgo.Describe("live vector party", func() {
@@ -46,7 +47,8 @@ var _ = ginkgo.Describe("live vector party", func() {
 			},
 		}
 		vp1.SetGoValue(0, shape1, true)
-		Ω(vp1.GetBytes()).Should(Equal(int64(8)))
+		Ω(shape1.Covering).ShouldNot(BeEmpty())
+		Ω(vp1.GetBytes()).Should(Equal(int64(shape1.GetBytes())))
 		Ω(vp1.GetLength()).Should(Equal(10))
 		dv := vp1.GetDataValue(0)
 		Ω(dv.Valid).Should(BeTrue())
@@ -91,7 +93,7 @@ var _ = ginkgo.Describe("live vector party", func() {
 			GoVal: shape1,
 		}, common.IgnoreCount)
 
-		Ω(vp1.GetBytes()).Should(Equal(int64(8)))
+		Ω(vp1.GetBytes()).Should(Equal(int64(shape1.GetBytes())))
 		Ω(vp1.GetLength()).Should(Equal(10))
 		dv := vp1.GetDataValue(0)
 		Ω(dv.Valid).Should(BeTrue())
@@ -127,7 +129,7 @@ var _ = ginkgo.Describe("live vector party", func() {
 			GoVal: shape1,
 		}, common.IgnoreCount)
 
-		Ω(vp1.GetBytes()).Should(Equal(int64(8)))
+		Ω(vp1.GetBytes()).Should(Equal(int64(shape1.GetBytes())))
 		Ω(vp1.GetLength()).Should(Equal(10))
 
 		buffer := bytes.Buffer{}
@@ -139,7 +141,7 @@ var _ = ginkgo.Describe("live vector party", func() {
 		Ω(err).Should(BeNil())
 		Ω(common.VectorPartyEquals(vp1, vp2)).Should(BeTrue())
 
-		Ω(vp2.GetBytes()).Should(Equal(int64(8)))
+		Ω(vp2.GetBytes()).Should(Equal(int64(shape1.GetBytes())))
 		Ω(vp2.GetLength()).Should(Equal(10))
 	})
 
@@ -179,4 +181,62 @@ var _ = ginkgo.Describe("live vector party", func() {
 		vp1.Allocate(false)
 		Ω(vp1.Equals(vp2)).Should(Equal(false))
 	})
+
+	ginkgo.It("stringLiveVectorParty should encode values with the batch dictionary", func() {
+		vpSerializer := common.NewVectorPartyArchiveSerializer(hostMemoryManager, nil, "", 0, 0, 0, 0, 0)
+
+		vp1 := NewLiveVectorParty(4, common.String, common.NullDataValue, hostMemoryManager)
+		vp1.Allocate(false)
+		device1 := common.StringGo("device-1")
+		device2 := common.StringGo("device-2")
+		vp1.SetGoValue(0, &device1, true)
+		vp1.SetGoValue(1, &device2, true)
+		vp1.SetDataValue(2, common.DataValue{Valid: true, GoVal: &device1}, common.IgnoreCount)
+		vp1.SetGoValue(3, nil, false)
+
+		dict := vp1.(*stringLiveVectorParty).Dictionary()
+		Ω(dict.Len()).Should(Equal(2))
+		id, valid := vp1.GetValue(2)
+		Ω(valid).Should(BeTrue())
+		Ω(*(*uint32)(id)).Should(BeEquivalentTo(0))
+
+		Ω(vp1.Slice(0, 10)).Should(Equal(common.SlicedVector{
+			Values: []interface{}{"device-1", "device-2", "device-1", nil},
+			Counts: []int{1, 2, 3, 4},
+		}))
+
+		buffer := bytes.Buffer{}
+		Ω(vp1.Write(&buffer)).Should(BeNil())
+		vp2 := NewLiveVectorParty(4, common.String, common.NullDataValue, hostMemoryManager)
+		Ω(vp2.Read(&buffer, vpSerializer)).Should(BeNil())
+		Ω(common.VectorPartyEquals(vp1, vp2)).Should(BeTrue())
+		Ω(vp2.GetBytes()).Should(Equal(vp1.GetBytes()))
+	})
+
+	ginkgo.It("stringLiveVectorParty should translate values to global ids for transfer", func() {
+		globalDict := common.NewGlobalStringDictionary()
+		otherBatch := common.NewStringDictionary()
+		otherBatch.GetOrAdd("device-2")
+		globalDict.Register(otherBatch)
+		globalDict.Lookup("device-2")
+
+		vp := NewLiveVectorParty(3, common.String, common.NullDataValue, hostMemoryManager)
+		vp.Allocate(false)
+		registerStringDictionary(vp, globalDict)
+		device1 := common.StringGo("device-1")
+		device2 := common.StringGo("device-2")
+		vp.SetGoValue(0, &device1, true)
+		vp.SetGoValue(1, &device2, true)
+		vp.SetGoValue(2, nil, false)
+
+		slice := vp.(TransferableVectorParty).GetHostVectorPartySlice(0, 3)
+		Ω(slice.ValueType).Should(Equal(common.Uint32))
+		Ω(*(*uint32)(slice.Values)).Should(BeEquivalentTo(1))
+		Ω(*(*uint32)(utils.MemAccess(slice.Values, 4))).Should(BeEquivalentTo(0))
+
+		batchDict := vp.(*stringLiveVectorParty).Dictionary()
+		Ω(globalDict.GlobalIDs(batchDict)).Should(Equal([]uint32{1, 0}))
+		vp.SafeDestruct()
+		Ω(globalDict.GlobalIDs(batchDict)).Should(BeNil())
+	})
 })
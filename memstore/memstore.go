@@ -46,6 +46,11 @@ type MemStore interface {
 	GetHostMemoryManager() common.HostMemoryManager
 	// AddTableShard add a table shard to the memstore
 	AddTableShard(table string, shardID int, needPeerCopy bool)
+	// AddStandbyTableShard adds a table shard of a warm standby datanode to the memstore, its data
+	// is copied from peers during bootstrap while redolog replay is deferred until promoted.
+	AddStandbyTableShard(table string, shardID int)
+	// PromoteStandbyTableShards replays redologs of standby table shards to start ingestion.
+	PromoteStandbyTableShards()
 	// GetTableShard gets the data for a pinned table Shard. Caller needs to unpin after use.
 	GetTableShard(table string, shardID int) (*TableShard, error)
 	// RemoveTableShard removes table shard from memstore
@@ -70,6 +75,18 @@ type MemStore interface {
 
 	// Purge is the process to purge out of retention archive batches
 	Purge(table string, shardID, batchIDStart, batchIDEnd int, reporter PurgeJobDetailReporter) error
+
+	// Compact is the process to merge sparse live batches of dimension tables and reclaim space
+	// of rows no longer referenced by the primary key.
+	Compact(table string, shardID int, reporter CompactionJobDetailReporter) error
+
+	// RecomputeHLL is the process to rewrite hll columns of archive batches of fact tables after
+	// their precision got reduced.
+	RecomputeHLL(table string, shardID int, reporter HLLRecomputeJobDetailReporter) error
+
+	// CompactEnumDicts drops cases of enum dictionaries of the table no longer referenced by
+	// any data of the shards on this instance. Returns the number of dropped cases by column.
+	CompactEnumDicts(table string) (map[string]int, error)
 }
 
 // memStoreImpl implements the MemStore interface.
@@ -261,7 +278,41 @@ func (m *memStoreImpl) TryEvictBatchColumn(table string, shardID int, batchID in
 	return true, nil
 }
 
+// TrySpillBatchColumn tries to spill a column of a given table/Shard/batchID to a file under dir
+// mapped back into memory. Returns whether the column got spilled.
+func (m *memStoreImpl) TrySpillBatchColumn(table string, shardID int, batchID int32, columnID int, dir string) (bool, error) {
+	tableShard, err := m.GetTableShard(table, shardID)
+	if err != nil {
+		return false, utils.StackError(err, "Failed to spill batch %d from Shard %d for table %s", batchID, shardID, table)
+	}
+	defer tableShard.Users.Done()
+
+	currentVersion := tableShard.ArchiveStore.GetCurrentVersion()
+	defer currentVersion.Users.Done()
+
+	currentVersion.RLock()
+	archiveBatch, ok := currentVersion.Batches[batchID]
+	currentVersion.RUnlock()
+	if !ok {
+		return false, nil
+	}
+
+	spilled, err := archiveBatch.TrySpill(columnID, dir)
+	if spilled {
+		utils.GetReporter(table, shardID).GetCounter(utils.ArchiveColumnSpilled).Inc(1)
+	}
+	return spilled, err
+}
+
 func (m *memStoreImpl) AddTableShard(table string, shardID int, needPeerCopy bool) {
+	m.addTableShard(table, shardID, needPeerCopy, false)
+}
+
+func (m *memStoreImpl) AddStandbyTableShard(table string, shardID int) {
+	m.addTableShard(table, shardID, true, true)
+}
+
+func (m *memStoreImpl) addTableShard(table string, shardID int, needPeerCopy, standby bool) {
 	m.Lock()
 	defer m.Unlock()
 
@@ -281,12 +332,36 @@ func (m *memStoreImpl) AddTableShard(table string, shardID int, needPeerCopy boo
 		if needPeerCopy {
 			tableShard.needPeerCopy = 1
 		}
+		tableShard.standby = standby
 		shardMap[shardID] = tableShard
 		utils.AddTableShardReporter(table, shardID)
 	}
 	m.TableShards[table] = shardMap
 }
 
+func (m *memStoreImpl) PromoteStandbyTableShards() {
+	m.RLock()
+	var tableShards []*TableShard
+	for _, shardMap := range m.TableShards {
+		for _, shard := range shardMap {
+			shard.Users.Add(1)
+			tableShards = append(tableShards, shard)
+		}
+	}
+	m.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, shard := range tableShards {
+		wg.Add(1)
+		go func(shard *TableShard) {
+			defer wg.Done()
+			defer shard.Users.Done()
+			shard.PromoteStandby()
+		}(shard)
+	}
+	wg.Wait()
+}
+
 func (m *memStoreImpl) RemoveTableShard(table string, shardID int) {
 	var shard *TableShard
 	// Detach first.
@@ -19,6 +19,11 @@ func (_m *MemStore) AddTableShard(table string, shardID int, needPeerCopy bool)
 	_m.Called(table, shardID, needPeerCopy)
 }
 
+// AddStandbyTableShard provides a mock function with given fields: table, shardID
+func (_m *MemStore) AddStandbyTableShard(table string, shardID int) {
+	_m.Called(table, shardID)
+}
+
 // Archive provides a mock function with given fields: table, shardID, cutoff, reporter
 func (_m *MemStore) Archive(table string, shardID int, cutoff uint32, reporter memstore.ArchiveJobDetailReporter) error {
 	ret := _m.Called(table, shardID, cutoff, reporter)
@@ -47,6 +52,43 @@ func (_m *MemStore) Backfill(table string, shardID int, reporter memstore.Backfi
 	return r0
 }
 
+// Compact provides a mock function with given fields: table, shardID, reporter
+func (_m *MemStore) Compact(table string, shardID int, reporter memstore.CompactionJobDetailReporter) error {
+	ret := _m.Called(table, shardID, reporter)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int, memstore.CompactionJobDetailReporter) error); ok {
+		r0 = rf(table, shardID, reporter)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CompactEnumDicts provides a mock function with given fields: table
+func (_m *MemStore) CompactEnumDicts(table string) (map[string]int, error) {
+	ret := _m.Called(table)
+
+	var r0 map[string]int
+	if rf, ok := ret.Get(0).(func(string) map[string]int); ok {
+		r0 = rf(table)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]int)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(table)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Bootstrap provides a mock function with given fields: peerSource, origin, topo, topoState, options
 func (_m *MemStore) Bootstrap(peerSource client.PeerSource, origin string, topo topology.Topology, topoState *topology.StateSnapshot, options bootstrap.Options) error {
 	ret := _m.Called(peerSource, origin, topo, topoState, options)
@@ -216,6 +258,11 @@ func (_m *MemStore) Lock() {
 	_m.Called()
 }
 
+// PromoteStandbyTableShards provides a mock function with given fields:
+func (_m *MemStore) PromoteStandbyTableShards() {
+	_m.Called()
+}
+
 // Purge provides a mock function with given fields: table, shardID, batchIDStart, batchIDEnd, reporter
 func (_m *MemStore) Purge(table string, shardID int, batchIDStart int, batchIDEnd int, reporter memstore.PurgeJobDetailReporter) error {
 	ret := _m.Called(table, shardID, batchIDStart, batchIDEnd, reporter)
@@ -240,6 +287,20 @@ func (_m *MemStore) RUnlock() {
 	_m.Called()
 }
 
+// RecomputeHLL provides a mock function with given fields: table, shardID, reporter
+func (_m *MemStore) RecomputeHLL(table string, shardID int, reporter memstore.HLLRecomputeJobDetailReporter) error {
+	ret := _m.Called(table, shardID, reporter)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int, memstore.HLLRecomputeJobDetailReporter) error); ok {
+		r0 = rf(table, shardID, reporter)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // RemoveTableShard provides a mock function with given fields: table, shardID
 func (_m *MemStore) RemoveTableShard(table string, shardID int) {
 	_m.Called(table, shardID)
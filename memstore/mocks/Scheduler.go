@@ -68,6 +68,22 @@ func (_m *Scheduler) Lock() {
 	_m.Called()
 }
 
+// NewArchiveBatchRepairJob provides a mock function with given fields: tableName, shardID, dump
+func (_m *Scheduler) NewArchiveBatchRepairJob(tableName string, shardID int, dump *memstore.ArchiveBatchDump) memstore.Job {
+	ret := _m.Called(tableName, shardID, dump)
+
+	var r0 memstore.Job
+	if rf, ok := ret.Get(0).(func(string, int, *memstore.ArchiveBatchDump) memstore.Job); ok {
+		r0 = rf(tableName, shardID, dump)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(memstore.Job)
+		}
+	}
+
+	return r0
+}
+
 // NewArchivingJob provides a mock function with given fields: tableName, shardID, cutoff
 func (_m *Scheduler) NewArchivingJob(tableName string, shardID int, cutoff uint32) memstore.Job {
 	ret := _m.Called(tableName, shardID, cutoff)
@@ -100,6 +116,38 @@ func (_m *Scheduler) NewBackfillJob(tableName string, shardID int) memstore.Job
 	return r0
 }
 
+// NewCompactionJob provides a mock function with given fields: tableName, shardID
+func (_m *Scheduler) NewCompactionJob(tableName string, shardID int) memstore.Job {
+	ret := _m.Called(tableName, shardID)
+
+	var r0 memstore.Job
+	if rf, ok := ret.Get(0).(func(string, int) memstore.Job); ok {
+		r0 = rf(tableName, shardID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(memstore.Job)
+		}
+	}
+
+	return r0
+}
+
+// NewHLLRecomputeJob provides a mock function with given fields: tableName, shardID
+func (_m *Scheduler) NewHLLRecomputeJob(tableName string, shardID int) memstore.Job {
+	ret := _m.Called(tableName, shardID)
+
+	var r0 memstore.Job
+	if rf, ok := ret.Get(0).(func(string, int) memstore.Job); ok {
+		r0 = rf(tableName, shardID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(memstore.Job)
+		}
+	}
+
+	return r0
+}
+
 // NewPurgeJob provides a mock function with given fields: tableName, shardID, batchIDStart, batchIDEnd
 func (_m *Scheduler) NewPurgeJob(tableName string, shardID int, batchIDStart int, batchIDEnd int) memstore.Job {
 	ret := _m.Called(tableName, shardID, batchIDStart, batchIDEnd)
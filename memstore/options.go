@@ -15,17 +15,31 @@
 package memstore
 
 import (
+	"time"
+
 	"github.com/uber/aresdb/memstore/common"
 	"github.com/uber/aresdb/redolog"
 )
 
 type Option func(o *Options)
 
+// ChangePublisher publishes upsert batches applied to table shards to change data capture subscribers.
+// Publish is called while holding the shard writer lock so batches are published in redo log order.
+type ChangePublisher interface {
+	Publish(schema *common.TableSchema, shardID int, upsertBatch *common.UpsertBatch, redoLogFile int64, offset uint32)
+}
+
 // class to hold all necessary context objects used in memstore
 type Options struct {
 	numShards      int
 	bootstrapToken common.BootStrapToken
 	redoLogMaster  *redolog.RedoLogManagerMaster
+	// nil if change data capture is not enabled.
+	changePublisher ChangePublisher
+	// max number of goroutines applying an upsert batch during redolog replay.
+	replayConcurrency int
+	// nil if ingestion is never throttled.
+	ingestionThrottle *ingestionThrottle
 }
 
 // NewOptions create new options instance
@@ -47,3 +61,32 @@ func WithNumShards(numShards int) Option {
 		o.numShards = numShards
 	}
 }
+
+// WithChangePublisher set the publisher of applied upsert batches to memstore options
+func WithChangePublisher(changePublisher ChangePublisher) Option {
+	return func(o *Options) {
+		o.changePublisher = changePublisher
+	}
+}
+
+// WithReplayConcurrency set the max number of goroutines applying an upsert batch to live batches
+// of a shard during redolog replay to memstore options
+func WithReplayConcurrency(replayConcurrency int) Option {
+	return func(o *Options) {
+		o.replayConcurrency = replayConcurrency
+	}
+}
+
+// WithIngestionThrottle set the thresholds above which upsert batches are rejected with
+// IngestionThrottledError to memstore options. memoryUsageRatio is the ratio of unmanaged
+// memory usage to the memory limit, maxRedoLogSize is the max bytes of unpurged redolog files
+// of a shard, zero thresholds are not checked.
+func WithIngestionThrottle(memoryUsageRatio float64, maxRedoLogSize int64, retryAfter time.Duration) Option {
+	return func(o *Options) {
+		o.ingestionThrottle = &ingestionThrottle{
+			memoryUsageRatio: memoryUsageRatio,
+			maxRedoLogSize:   maxRedoLogSize,
+			retryAfter:       retryAfter,
+		}
+	}
+}
@@ -16,6 +16,7 @@ package memstore
 
 import (
 	memCom "github.com/uber/aresdb/memstore/common"
+	metaCom "github.com/uber/aresdb/metastore/common"
 	"github.com/uber/aresdb/utils"
 )
 
@@ -58,11 +59,18 @@ func (m *memStoreImpl) Purge(tableName string, shardID, batchIDStart, batchIDEnd
 		status.BatchIDEnd = batchIDEnd
 	})
 
+	// batches under legal hold are never purged.
+	legalHolds, err := shard.metaStore.GetLegalHolds(tableName)
+	if err != nil {
+		return err
+	}
+	batchRanges := unheldBatchRanges(batchIDStart, batchIDEnd, legalHolds)
+
 	// detach batch from memory
 	var batchesToPurge []*ArchiveBatch
 	currentVersion.Lock()
 	for batchID, batch := range currentVersion.Batches {
-		if batchID >= int32(batchIDStart) && batchID < int32(batchIDEnd) {
+		if batchID >= int32(batchIDStart) && batchID < int32(batchIDEnd) && !isHeld(int(batchID), legalHolds) {
 			delete(currentVersion.Batches, batchID)
 			if batch != nil {
 				batchesToPurge = append(batchesToPurge, batch)
@@ -72,9 +80,11 @@ func (m *memStoreImpl) Purge(tableName string, shardID, batchIDStart, batchIDEnd
 	currentVersion.Unlock()
 
 	// delete metadata of batches within range
-	err = shard.metaStore.PurgeArchiveBatches(tableName, shardID, batchIDStart, batchIDEnd)
-	if err != nil {
-		return err
+	for _, batchRange := range batchRanges {
+		err = shard.metaStore.PurgeArchiveBatches(tableName, shardID, batchRange[0], batchRange[1])
+		if err != nil {
+			return err
+		}
 	}
 
 	reporter(jobKey, func(status *PurgeJobDetail) {
@@ -82,9 +92,13 @@ func (m *memStoreImpl) Purge(tableName string, shardID, batchIDStart, batchIDEnd
 	})
 
 	// delete data file on disk of batches within range
-	numBatches, err := shard.diskStore.DeleteBatches(tableName, shardID, batchIDStart, batchIDEnd)
-	if err != nil {
-		return err
+	numBatches := 0
+	for _, batchRange := range batchRanges {
+		numDeleted, err := shard.diskStore.DeleteBatches(tableName, shardID, batchRange[0], batchRange[1])
+		if err != nil {
+			return err
+		}
+		numBatches += numDeleted
 	}
 	reporter(jobKey, func(status *PurgeJobDetail) {
 		status.NumBatches = numBatches
@@ -123,3 +137,37 @@ func (m *memStoreImpl) Purge(tableName string, shardID, batchIDStart, batchIDEnd
 
 	return nil
 }
+
+// isHeld tells whether the batch is under any of the legal holds.
+func isHeld(batchID int, legalHolds []metaCom.LegalHold) bool {
+	for _, hold := range legalHolds {
+		if hold.Holds(batchID) {
+			return true
+		}
+	}
+	return false
+}
+
+// unheldBatchRanges splits [batchIDStart, batchIDEnd) into ranges of batches not under any legal hold.
+func unheldBatchRanges(batchIDStart, batchIDEnd int, legalHolds []metaCom.LegalHold) [][2]int {
+	var ranges [][2]int
+	start := batchIDStart
+	for start < batchIDEnd {
+		// skip held batches.
+		for start < batchIDEnd && isHeld(start, legalHolds) {
+			start++
+		}
+		if start >= batchIDEnd {
+			break
+		}
+		end := batchIDEnd
+		for _, hold := range legalHolds {
+			if hold.BatchIDStart > start && hold.BatchIDStart < end {
+				end = hold.BatchIDStart
+			}
+		}
+		ranges = append(ranges, [2]int{start, end})
+		start = end
+	}
+	return ranges
+}
@@ -118,6 +118,7 @@ var _ = ginkgo.Describe("Purge", func() {
 		Ω(tableShard.ArchiveStore.CurrentVersion.Batches).Should(HaveKey(int32(1)))
 		Ω(tableShard.ArchiveStore.CurrentVersion.Batches).Should(HaveKey(int32(2)))
 
+		metaStore.On("GetLegalHolds", testTable).Return(nil, nil).Once()
 		diskStore.On("DeleteBatches", testTable, testShardID, 0, 2).
 			Return(1, nil).Once()
 		bootstrapToken.On("AcquireToken", mock.Anything, mock.Anything).Return(true).Once()
@@ -134,6 +135,46 @@ var _ = ginkgo.Describe("Purge", func() {
 		Ω(jobDetail.Stage).Should(BeEquivalentTo("complete"))
 	})
 
+	ginkgo.It("purge should skip batches under legal hold", func() {
+		jobDetail := &PurgeJobDetail{}
+
+		mockReporter := func(key string, mutator PurgeJobDetailMutator) {
+			mutator(jobDetail)
+		}
+
+		metaStore.On("GetLegalHolds", testTable).Return([]metaCom.LegalHold{
+			{BatchIDStart: 1, BatchIDEnd: 2, Reason: "litigation"},
+		}, nil).Once()
+		diskStore.On("DeleteBatches", testTable, testShardID, 0, 1).
+			Return(0, nil).Once()
+		diskStore.On("DeleteBatches", testTable, testShardID, 2, 3).
+			Return(1, nil).Once()
+		bootstrapToken.On("AcquireToken", mock.Anything, mock.Anything).Return(true).Once()
+		bootstrapToken.On("ReleaseToken", mock.Anything, mock.Anything).Return().Once()
+
+		err := memStore.Purge(testTable, testShardID, 0, 3, mockReporter)
+		Ω(err).Should(BeNil())
+		Ω(tableShard.ArchiveStore.CurrentVersion.Batches).Should(HaveKey(int32(1)))
+		Ω(tableShard.ArchiveStore.CurrentVersion.Batches).ShouldNot(HaveKey(int32(2)))
+		metaStore.AssertNumberOfCalls(utils.TestingT, "PurgeArchiveBatches", 2)
+		diskStore.AssertNumberOfCalls(utils.TestingT, "DeleteBatches", 2)
+
+		Ω(jobDetail.NumBatches).Should(Equal(1))
+		Ω(jobDetail.Stage).Should(BeEquivalentTo("complete"))
+	})
+
+	ginkgo.It("unheldBatchRanges should work", func() {
+		Ω(unheldBatchRanges(0, 10, nil)).Should(Equal([][2]int{{0, 10}}))
+		Ω(unheldBatchRanges(0, 10, []metaCom.LegalHold{
+			{BatchIDStart: 2, BatchIDEnd: 4},
+			{BatchIDStart: 3, BatchIDEnd: 5},
+			{BatchIDStart: 8, BatchIDEnd: 12},
+		})).Should(Equal([][2]int{{0, 2}, {5, 8}}))
+		Ω(unheldBatchRanges(0, 10, []metaCom.LegalHold{
+			{BatchIDStart: -1, BatchIDEnd: 11},
+		})).Should(BeNil())
+	})
+
 	ginkgo.It("purge should be blocked", func() {
 		jobDetail := &PurgeJobDetail{}
 
@@ -20,11 +20,32 @@ import (
 
 	"math"
 	"sort"
+	"sync/atomic"
 
 	memcom "github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/redolog"
 	"github.com/uber/aresdb/utils"
 )
 
+// RecoveryProgress shows the progress of redolog replay of a table shard.
+type RecoveryProgress struct {
+	// total bytes of redologs when the replay started.
+	TotalBytes int64 `json:"totalBytes"`
+	// bytes of upsert batches replayed so far.
+	ReplayedBytes int64 `json:"replayedBytes"`
+	// whether all redologs are replayed.
+	Done bool `json:"done"`
+}
+
+// GetRecoveryProgress returns the progress of redolog replay of the shard.
+func (shard *TableShard) GetRecoveryProgress() RecoveryProgress {
+	return RecoveryProgress{
+		TotalBytes:    atomic.LoadInt64(&shard.recoveryTotalBytes),
+		ReplayedBytes: atomic.LoadInt64(&shard.recoveryReplayedBytes),
+		Done:          atomic.LoadInt32(&shard.recoveryDone) == 1,
+	}
+}
+
 // PlayRedoLog loads data for the table Shard from disk store and recovers the Shard for serving.
 func (shard *TableShard) PlayRedoLog() {
 	timer := utils.GetReporter(shard.Schema.Schema.Name, shard.ShardID).GetTimer(utils.RecoveryLatency).Start()
@@ -44,6 +65,10 @@ func (shard *TableShard) PlayRedoLog() {
 	utils.GetLogger().With("table", shard.Schema.Schema.Name, "shard", shard.ShardID, "redoLogFile",
 		redoLogFilePersisted, "offset", offsetPersisted).Info("Checkpointed redolog file")
 
+	atomic.StoreInt32(&shard.recoveryDone, 0)
+	atomic.StoreInt64(&shard.recoveryReplayedBytes, 0)
+	atomic.StoreInt64(&shard.recoveryTotalBytes, int64(shard.LiveStore.RedoLogManager.GetTotalSize()))
+
 	go func() {
 		nextUpsertBatchFunc, err := shard.LiveStore.RedoLogManager.Iterator()
 		if err != nil {
@@ -57,6 +82,7 @@ func (shard *TableShard) PlayRedoLog() {
 			}
 			var skipBackfillRows bool
 			if batchInfo.Recovery {
+				atomic.AddInt64(&shard.recoveryReplayedBytes, int64(redolog.RecordSize(batchInfo.Batch.GetBuffer())))
 				// check if this batch has already been backfilled and persisted
 				skipBackfillRows = batchInfo.RedoLogFile < redoLogFilePersisted ||
 					(batchInfo.RedoLogFile == redoLogFilePersisted && batchInfo.BatchOffset <= offsetPersisted)
@@ -75,6 +101,7 @@ func (shard *TableShard) PlayRedoLog() {
 	}()
 
 	shard.LiveStore.RedoLogManager.WaitForRecoveryDone()
+	atomic.StoreInt32(&shard.recoveryDone, 1)
 
 	// report redolog size after replay
 	utils.GetReporter(shard.Schema.Schema.Name, shard.ShardID).GetGauge(utils.NumberOfRedologs).Update(float64(shard.LiveStore.RedoLogManager.GetNumFiles()))
@@ -389,6 +416,12 @@ func (shard *TableShard) loadTableShardSnapshot(
 	dataTypes := shard.Schema.ValueTypeByColumn
 	defaultValues := shard.Schema.DefaultValues
 	columns := shard.Schema.Schema.Columns
+	stringDicts := make(map[int]*memcom.GlobalStringDictionary)
+	for colID := range columns {
+		if stringDict := shard.Schema.GetStringDict(colID); stringDict != nil {
+			stringDicts[colID] = stringDict
+		}
+	}
 	shard.Schema.RUnlock()
 
 	var err error
@@ -422,6 +455,28 @@ func (shard *TableShard) loadTableShardSnapshot(
 			if err := serializer.ReadVectorParty(vp); err != nil {
 				return 0, err
 			}
+			// snapshot taken before the column was promoted to big enum.
+			if cvp, ok := vp.(*cLiveVectorParty); ok && dataTypes[colID] == memcom.BigEnum && cvp.dataType == memcom.SmallEnum {
+				bytes := cvp.GetBytes()
+				cvp.promoteEnum(*defaultValues[colID])
+				shard.HostMemoryManager.ReportUnmanagedSpaceUsageChange(cvp.GetBytes() - bytes)
+			}
+			// snapshot taken before the enum dictionary got compacted.
+			if cvp, ok := vp.(*cLiveVectorParty); ok {
+				if compactions := shard.getSnapshotEnumCompactions(colID, redoLogFile, offset); len(compactions) > 0 {
+					cvp.remapEnum(compactions, *defaultValues[colID])
+				}
+			}
+			// snapshot taken before the hll precision of the column got reduced.
+			if cvp, ok := vp.(*cLiveVectorParty); ok {
+				if precision := shard.Schema.GetReducedHLLPrecision(colID); precision > 0 {
+					cvp.reduceHLLPrecision(precision)
+				}
+			}
+			// batch dictionaries are registered once they are read from the snapshot.
+			registerStringDictionary(vp, stringDicts[colID])
+			// live batches can be adaptively sized, take the capacity from the snapshot.
+			batch.Capacity = vp.GetLength()
 		}
 		batch.Columns[colID] = vp
 	}
@@ -56,46 +56,20 @@ func (rb *redoLogBrowser) ListUpsertBatch(creationTime int64) ([]int64, error) {
 
 	defer f.Close()
 
-	streamReader := utils.NewStreamDataReader(f)
-
-	var size uint32
-	// Read magic header.
-	header, err := streamReader.ReadUint32()
+	reader, err := redolog.NewRecordReader(f)
 	if err != nil {
 		return nil, err
 	}
 
-	if header != redolog.UpsertHeader {
-		return nil, utils.StackError(nil,
-			"Invalid header %#x", header)
-	}
-
-	// Offset starts from magical header.
-	var currentOffset int64 = 4
-
 	var startOffsets []int64
-
-	for size, err = streamReader.ReadUint32(); err != io.EOF; size, err = streamReader.ReadUint32() {
-		if err != nil {
+	for {
+		currentOffset := reader.Offset
+		if err = reader.Skip(); err == io.EOF {
+			break
+		} else if err != nil {
 			return nil, err
 		}
-
-		var newOffset int64
-		if newOffset, err = f.Seek(int64(size), io.SeekCurrent); err != nil {
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		desiredOffset := currentOffset + 4 + int64(size)
-		if newOffset != desiredOffset {
-			return nil, utils.StackError(nil,
-				"Cannot seek to desired offset %d of redolog file ,current offset %d",
-				desiredOffset, newOffset)
-		}
-
 		startOffsets = append(startOffsets, currentOffset)
-		currentOffset = desiredOffset
 	}
 	return startOffsets, nil
 }
@@ -111,6 +85,12 @@ func (rb *redoLogBrowser) ReadData(creationTime int64, upsertBatchOffset int64,
 	}
 	defer f.Close()
 
+	// the magic header tells the format of records.
+	var reader *redolog.RecordReader
+	if reader, err = redolog.NewRecordReader(f); err != nil {
+		return
+	}
+
 	var actualOffset int64
 	if actualOffset, err = f.Seek(upsertBatchOffset, io.SeekStart); err != nil {
 		return
@@ -123,8 +103,13 @@ func (rb *redoLogBrowser) ReadData(creationTime int64, upsertBatchOffset int64,
 		return
 	}
 
+	var buffer []byte
+	if buffer, err = reader.Next(); err != nil {
+		return
+	}
+
 	var upsertBatch *common.UpsertBatch
-	if upsertBatch, err = rb.readUpsertBatch(f); err != nil {
+	if upsertBatch, err = common.NewUpsertBatch(buffer); err != nil {
 		return
 	}
 
@@ -141,21 +126,6 @@ func (rb *redoLogBrowser) ReadData(creationTime int64, upsertBatchOffset int64,
 	return
 }
 
-// readUpsertBatch reads an upsert batch from current offset of a stream.
-func (rb *redoLogBrowser) readUpsertBatch(f utils.ReaderSeekerCloser) (*common.UpsertBatch, error) {
-	streamReader := utils.NewStreamDataReader(f)
-	size, err := streamReader.ReadUint32()
-	if err != nil {
-		return nil, err
-	}
-	buffer := make([]byte, size)
-	if err = streamReader.Read(buffer); err != nil {
-		return nil, err
-	}
-
-	return common.NewUpsertBatch(buffer)
-}
-
 // NewRedoLogBrowser creates a RedoLogBrowser using field from Shard.
 func (shard *TableShard) NewRedoLogBrowser() RedoLogBrowser {
 	return &redoLogBrowser{
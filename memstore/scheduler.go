@@ -51,6 +51,9 @@ type Scheduler interface {
 	NewArchivingJob(tableName string, shardID int, cutoff uint32) Job
 	NewSnapshotJob(tableName string, shardID int) Job
 	NewPurgeJob(tableName string, shardID int, batchIDStart int, batchIDEnd int) Job
+	NewCompactionJob(tableName string, shardID int) Job
+	NewHLLRecomputeJob(tableName string, shardID int) Job
+	NewArchiveBatchRepairJob(tableName string, shardID int, dump *ArchiveBatchDump) Job
 	EnableJobType(jobType common.JobType, enable bool)
 	IsJobTypeEnabled(jobType common.JobType) bool
 	utils.RWLocker
@@ -70,6 +73,8 @@ func newScheduler(m *memStoreImpl) *schedulerImpl {
 	s.jobManagers[common.BackfillJobType] = newBackfillJobManager(s)
 	s.jobManagers[common.SnapshotJobType] = newSnapshotJobManager(s)
 	s.jobManagers[common.PurgeJobType] = newPurgeJobManager(s)
+	s.jobManagers[common.CompactionJobType] = newCompactionJobManager(s)
+	s.jobManagers[common.HLLRecomputeJobType] = newHLLRecomputeJobManager(s)
 	return s
 }
 
@@ -135,9 +140,11 @@ func (scheduler *schedulerImpl) DeleteTable(table string, isFactTable bool) {
 		scheduler.jobManagers[common.ArchivingJobType].deleteTable(table)
 		scheduler.jobManagers[common.BackfillJobType].deleteTable(table)
 		scheduler.jobManagers[common.PurgeJobType].deleteTable(table)
+		scheduler.jobManagers[common.HLLRecomputeJobType].deleteTable(table)
 		return
 	}
 	scheduler.jobManagers[common.SnapshotJobType].deleteTable(table)
+	scheduler.jobManagers[common.CompactionJobType].deleteTable(table)
 }
 
 // GetJobManager retrieve the JobManager according to job type
@@ -188,6 +195,36 @@ func (scheduler *schedulerImpl) NewPurgeJob(tableName string, shardID, batchIDSt
 	}
 }
 
+// NewCompactionJob creates a new CompactionJob.
+func (scheduler *schedulerImpl) NewCompactionJob(tableName string, shardID int) Job {
+	return &CompactionJob{
+		tableName: tableName,
+		shardID:   shardID,
+		memStore:  scheduler.memStore,
+		reporter:  scheduler.jobManagers[common.CompactionJobType].(*compactionJobManager).reportCompactionJobDetail,
+	}
+}
+
+// NewHLLRecomputeJob creates a new HLLRecomputeJob.
+func (scheduler *schedulerImpl) NewHLLRecomputeJob(tableName string, shardID int) Job {
+	return &HLLRecomputeJob{
+		tableName: tableName,
+		shardID:   shardID,
+		memStore:  scheduler.memStore,
+		reporter:  scheduler.jobManagers[common.HLLRecomputeJobType].(*hllRecomputeJobManager).reportHLLRecomputeJobDetail,
+	}
+}
+
+// NewArchiveBatchRepairJob creates a new ArchiveBatchRepairJob.
+func (scheduler *schedulerImpl) NewArchiveBatchRepairJob(tableName string, shardID int, dump *ArchiveBatchDump) Job {
+	return &ArchiveBatchRepairJob{
+		tableName: tableName,
+		shardID:   shardID,
+		dump:      dump,
+		memStore:  scheduler.memStore,
+	}
+}
+
 // Start starts the scheduler. It creates a new time.Timer every time to wait
 // at least schedulerInterval time instead of running at every tick so that we
 // will skip the tick if a single round takes more than one minute. This prevents
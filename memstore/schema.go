@@ -82,6 +82,17 @@ func (m *memStoreImpl) fetchTable(tableName string) error {
 						}
 					} else {
 						tableSchema.CreateEnumDict(column.Name, enumCases)
+						reportEnumCapacityUsage(tableName, column.Name, len(enumCases), tableSchema.EnumDicts[column.Name].Capacity)
+						if column.IsEnumColumn() {
+							// compactions are needed to remap enum ids of data written before them.
+							compactions, err := m.metaStore.GetEnumDictCompactions(tableName, column.Name)
+							if err != nil {
+								return utils.StackError(err, "Failed to fetch enum compactions for table: %s, column: %s", tableName, column.Name)
+							}
+							enumDict := tableSchema.EnumDicts[column.Name]
+							enumDict.Compactions = compactions
+							tableSchema.EnumDicts[column.Name] = enumDict
+						}
 					}
 				}
 			}
@@ -193,6 +204,25 @@ func (m *memStoreImpl) applyTableSchema(newTable *metaCom.Table) {
 
 	var columnsToDelete []int
 
+	tableSchema.RLock()
+	promotedColumns := promotedEnumColumns(tableSchema.Schema.Columns, newTable.Columns)
+	hllColumns := reducedHLLPrecisionColumns(tableSchema.Schema.Columns, newTable.Columns)
+	tableSchema.RUnlock()
+
+	var shardsToConvert []*TableShard
+	if len(promotedColumns) > 0 || len(hllColumns) > 0 {
+		m.RLock()
+		for _, shard := range m.TableShards[tableName] {
+			shard.Users.Add(1)
+			shardsToConvert = append(shardsToConvert, shard)
+		}
+		m.RUnlock()
+		// block ingestion until live batches are converted along with the schema.
+		for _, shard := range shardsToConvert {
+			shard.LiveStore.WriterLock.Lock()
+		}
+	}
+
 	tableSchema.Lock()
 	oldColumns := tableSchema.Schema.Columns
 	tableSchema.SetTable(newTable)
@@ -233,6 +263,41 @@ func (m *memStoreImpl) applyTableSchema(newTable *metaCom.Table) {
 	}
 	tableSchema.Unlock()
 
+	for _, shard := range shardsToConvert {
+		for _, columnID := range promotedColumns {
+			shard.promoteLiveEnumColumn(columnID)
+		}
+		for _, columnID := range hllColumns {
+			shard.reduceLiveHLLPrecision(columnID, newTable.Columns[columnID].HLLConfig.GetPrecision())
+		}
+		shard.LiveStore.WriterLock.Unlock()
+
+		if len(hllColumns) > 0 && newTable.IsFactTable {
+			// archived hll columns are converted on load until recomputed by the scheduled job.
+			shard.markHLLRecomputePending()
+		}
+
+		if len(promotedColumns) == 0 {
+			shard.Users.Done()
+			continue
+		}
+
+		// rewriting archive batches may take long, archived columns are converted on load meanwhile.
+		go func(shard *TableShard) {
+			defer shard.Users.Done()
+			for _, columnID := range promotedColumns {
+				if err := shard.PromoteArchivedEnumColumn(columnID); err != nil {
+					utils.GetLogger().With(
+						"error", err.Error(),
+						"table", tableName,
+						"shard", shard.ShardID,
+						"column", columnID).
+						Error("Failed to rewrite archived batches of promoted enum column")
+				}
+			}
+		}(shard)
+	}
+
 	for _, columnID := range columnsToDelete {
 		var shards []*TableShard
 		m.RLock()
@@ -250,6 +315,28 @@ func (m *memStoreImpl) applyTableSchema(newTable *metaCom.Table) {
 	}
 }
 
+// promotedEnumColumns returns ids of small enum columns promoted to big enum.
+func promotedEnumColumns(oldColumns, newColumns []metaCom.Column) (columnIDs []int) {
+	for columnID, column := range newColumns {
+		if columnID < len(oldColumns) && !column.Deleted &&
+			metaCom.IsEnumPromotion(oldColumns[columnID].Type, column.Type) {
+			columnIDs = append(columnIDs, columnID)
+		}
+	}
+	return
+}
+
+// reducedHLLPrecisionColumns returns ids of hll columns with reduced precision.
+func reducedHLLPrecisionColumns(oldColumns, newColumns []metaCom.Column) (columnIDs []int) {
+	for columnID, column := range newColumns {
+		if columnID < len(oldColumns) && !column.Deleted && column.HLLConfig.IsHLLColumn &&
+			column.HLLConfig.GetPrecision() < oldColumns[columnID].HLLConfig.GetPrecision() {
+			columnIDs = append(columnIDs, columnID)
+		}
+	}
+	return
+}
+
 // handleEnumDictChange handles enum dict change event from metaStore for specific table and column.
 func (m *memStoreImpl) handleEnumDictChange(tableName, columnName string, enumDictChangeEvents <-chan string, done chan<- struct{}) {
 	for newEnumCase := range enumDictChangeEvents {
@@ -278,4 +365,31 @@ func (m *memStoreImpl) applyEnumCase(tableName, columnName string, newEnumCase s
 	enumDict.ReverseDict = append(enumDict.ReverseDict, newEnumCase)
 	tableSchema.EnumDicts[columnName] = enumDict
 	tableSchema.Unlock()
+
+	reportEnumCapacityUsage(tableName, columnName, len(enumDict.ReverseDict), enumDict.Capacity)
+}
+
+// reportEnumCapacityUsage reports the ratio of used enum cases to the capacity of the column,
+// and warns when the enum dictionary is about to run out of capacity so that the column can be
+// promoted to big enum before new values get rejected.
+func reportEnumCapacityUsage(tableName, columnName string, numCases, capacity int) {
+	if capacity == 0 {
+		return
+	}
+	usage := float64(numCases) / float64(capacity)
+	utils.GetRootReporter().GetChildGauge(map[string]string{
+		"table":      tableName,
+		"columnName": columnName,
+	}, utils.EnumCapacityUsage).Update(usage)
+
+	// only warn once when crossing the threshold and once when full.
+	alertThreshold := int(float64(capacity) * metaCom.EnumCapacityAlertRatio)
+	if numCases == alertThreshold || numCases == capacity {
+		utils.GetLogger().With(
+			"table", tableName,
+			"column", columnName,
+			"cases", numCases,
+			"capacity", capacity).
+			Warn("Enum dictionary is running out of capacity")
+	}
 }
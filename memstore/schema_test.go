@@ -16,6 +16,7 @@ package memstore
 
 import (
 	"errors"
+	"unsafe"
 
 	"github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -25,6 +26,7 @@ import (
 	metaCom "github.com/uber/aresdb/metastore/common"
 	"github.com/uber/aresdb/metastore/mocks"
 	"github.com/uber/aresdb/redolog"
+	"github.com/uber/aresdb/utils"
 )
 
 var _ = ginkgo.Describe("memStoreImpl schema", func() {
@@ -261,7 +263,9 @@ var _ = ginkgo.Describe("memStoreImpl schema", func() {
 		mockMetastore.On("ListTables").Return([]string{"testTable"}, nil).Once()
 		mockMetastore.On("GetTable", testTable.Name).Return(&testTable, nil).Once()
 		mockMetastore.On("GetEnumDict", testTable.Name, testColumn2.Name).Return(testColumn2EnumCases, nil).Once()
+		mockMetastore.On("GetEnumDictCompactions", testTable.Name, testColumn2.Name).Return(nil, nil).Once()
 		mockMetastore.On("GetEnumDict", testTable.Name, testColumn3.Name).Return(testColumn3EnumCases, nil).Once()
+		mockMetastore.On("GetEnumDictCompactions", testTable.Name, testColumn3.Name).Return(nil, nil).Once()
 
 		mockMetastore.On("WatchTableListEvents").Return(recvTableListEvents, sendDoneChannel, nil).Once()
 		mockMetastore.On("WatchTableSchemaEvents").Return(recvTableSchemaEvents, sendDoneChannel, nil).Once()
@@ -349,6 +353,7 @@ var _ = ginkgo.Describe("memStoreImpl schema", func() {
 		mockMetastore.On("GetTable", testTable.Name).Return(&testTable, nil).Once()
 		mockMetastore.On("GetEnumDict", testTable.Name, testColumn2.Name).Return(nil, metaCom.ErrColumnDoesNotExist).Once()
 		mockMetastore.On("GetEnumDict", testTable.Name, testColumn3.Name).Return(testColumn3EnumCases, nil).Once()
+		mockMetastore.On("GetEnumDictCompactions", testTable.Name, testColumn3.Name).Return(nil, nil).Once()
 		doneChan = make(chan struct{})
 		mockMetastore.On("WatchTableListEvents").Return(recvTableListEvents, doneChan, nil).Once()
 		doneChan = make(chan struct{})
@@ -434,6 +439,84 @@ var _ = ginkgo.Describe("memStoreImpl schema", func() {
 		destroyTestMemstore(testMemstore)
 	})
 
+	ginkgo.It("applyTableSchema should promote small enum columns to big enum", func() {
+		testMemstore := getTestMemstore()
+		shard := testMemstore.TableShards[testTable.Name][0]
+
+		batch := shard.LiveStore.getOrCreateBatch(0)
+		vp := NewLiveVectorParty(batch.Capacity, memCom.SmallEnum, memCom.NullDataValue, nil)
+		vp.Allocate(false)
+		value := uint8(2)
+		vp.SetValue(0, unsafe.Pointer(&value), true)
+		batch.Columns[1] = vp
+		batch.Unlock()
+
+		testPromotedTable := testTable
+		testPromotedTable.Columns = []metaCom.Column{testColumn1, testColumn2, testColumn3}
+		testPromotedTable.Columns[1].Type = metaCom.BigEnum
+		testMemstore.applyTableSchema(&testPromotedTable)
+
+		tableSchema := testMemstore.TableSchemas[testTable.Name]
+		Ω(tableSchema.ValueTypeByColumn).Should(Equal([]memCom.DataType{memCom.Bool, memCom.BigEnum, memCom.BigEnum}))
+		Ω(tableSchema.EnumDicts[testColumn2.Name].Capacity).Should(Equal(0x10000))
+		Ω(tableSchema.EnumDicts[testColumn2.Name].ReverseDict).Should(Equal(testColumn2EnumCases))
+
+		batch = shard.LiveStore.GetBatchForRead(0)
+		Ω(batch.Columns[1].GetDataType()).Should(Equal(memCom.BigEnum))
+		promoted, valid := batch.Columns[1].(memCom.LiveVectorParty).GetValue(0)
+		Ω(valid).Should(BeTrue())
+		Ω(*(*uint16)(promoted)).Should(BeEquivalentTo(2))
+		_, valid = batch.Columns[1].(memCom.LiveVectorParty).GetValue(1)
+		Ω(valid).Should(BeFalse())
+		batch.RUnlock()
+
+		// ingestion is unblocked.
+		shard.LiveStore.WriterLock.Lock()
+		shard.LiveStore.WriterLock.Unlock()
+		destroyTestMemstore(testMemstore)
+	})
+
+	ginkgo.It("applyTableSchema should reduce precision of hll columns", func() {
+		testMemstore := getTestMemstore()
+		shard := testMemstore.TableShards[testTable.Name][0]
+
+		testHLLTable := testTable
+		testHLLTable.IsFactTable = true
+		testHLLTable.Columns = []metaCom.Column{testColumn1, testColumn2, testColumn3, {
+			Name:      "col4",
+			Type:      metaCom.UUID,
+			HLLConfig: metaCom.HLLConfig{IsHLLColumn: true},
+		}}
+		testMemstore.applyTableSchema(&testHLLTable)
+		Ω(shard.isHLLRecomputePending()).Should(BeFalse())
+
+		batch := shard.LiveStore.getOrCreateBatch(0)
+		vp := NewLiveVectorParty(batch.Capacity, memCom.Uint32, memCom.NullDataValue, nil)
+		vp.Allocate(false)
+		value := utils.ComputeHLLValue(0xf0f0f0f0f0f0f0f0)
+		vp.SetValue(0, unsafe.Pointer(&value), true)
+		batch.Columns = append(batch.Columns, vp)
+		batch.Unlock()
+
+		testReducedTable := testHLLTable
+		testReducedTable.Columns = append([]metaCom.Column{}, testHLLTable.Columns...)
+		testReducedTable.Columns[3].HLLConfig.Precision = 8
+		testMemstore.applyTableSchema(&testReducedTable)
+		Ω(testMemstore.TableSchemas[testTable.Name].GetReducedHLLPrecision(3)).Should(Equal(8))
+
+		batch = shard.LiveStore.GetBatchForRead(0)
+		reduced, valid := batch.Columns[3].(memCom.LiveVectorParty).GetValue(0)
+		Ω(valid).Should(BeTrue())
+		Ω(*(*uint32)(reduced)).Should(Equal(utils.ComputeHLLValueWithPrecision(0xf0f0f0f0f0f0f0f0, 8)))
+		batch.RUnlock()
+		Ω(shard.isHLLRecomputePending()).Should(BeTrue())
+
+		// ingestion is unblocked.
+		shard.LiveStore.WriterLock.Lock()
+		shard.LiveStore.WriterLock.Unlock()
+		destroyTestMemstore(testMemstore)
+	})
+
 	ginkgo.It("applyTableSchema should work with new table schema", func() {
 		testMemstore := getTestMemstore()
 
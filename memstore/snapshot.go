@@ -48,6 +48,20 @@ func (m *memStoreImpl) Snapshot(table string, shardID int, reporter SnapshotJobD
 		"job", "snapshot",
 		"table", table).Infof("Creating snapshot")
 
+	reporter(jobKey, func(status *SnapshotJobDetail) {
+		status.Stage = SnapshotMerge
+	})
+
+	// merge undersized live batches before the snapshot so that the snapshot has fewer batches.
+	numMergedBatches, err := shard.mergeLiveBatches()
+	if err != nil {
+		return err
+	}
+
+	reporter(jobKey, func(status *SnapshotJobDetail) {
+		status.NumMergedBatches = numMergedBatches
+	})
+
 	snapshotMgr := shard.LiveStore.SnapshotManager
 	// keep the current redofile and offset
 	redoFile, batchOffset, numMutations, lastReadRecord := snapshotMgr.StartSnapshot()
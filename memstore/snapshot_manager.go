@@ -92,6 +92,14 @@ func (s *SnapshotManager) ApplyUpsertBatch(redoFile int64, offset uint32, numMut
 	s.CurrentRecord = currentRecord
 }
 
+// renumberCurrentRecord moves CurrentRecord back by numBatches after live batches before it are
+// merged.
+func (s *SnapshotManager) renumberCurrentRecord(numBatches int32) {
+	s.Lock()
+	defer s.Unlock()
+	s.CurrentRecord.BatchID -= numBatches
+}
+
 // QualifyForSnapshot tells whether we can trigger a snapshot job.
 func (s *SnapshotManager) QualifyForSnapshot() bool {
 	s.RLock()
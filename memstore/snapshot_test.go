@@ -73,10 +73,8 @@ var _ = ginkgo.Describe("snapshot", func() {
 		rows               = 15
 	)
 
-	diskStore := &diskMocks.DiskStore{}
-	metaStore := &metaMocks.MetaStore{}
-
-	diskStore.On("OpenLogFileForAppend", mock.Anything, mock.Anything, mock.Anything).Return(&testing.TestReadWriteCloser{}, nil)
+	var diskStore *diskMocks.DiskStore
+	var metaStore *metaMocks.MetaStore
 
 	lastBatchID := int32(math.MinInt32 + 1)
 	lastIndex := uint32(5)
@@ -138,11 +136,52 @@ var _ = ginkgo.Describe("snapshot", func() {
 		Ω(err).Should(BeNil())
 	}
 
+	// recoverFromSnapshot loads the snapshot created by createSnapshot into a new live store.
+	recoverFromSnapshot := func(newBatchSize int) {
+		// using new instance to avoid data conflict
+		memStore = createMemStore(tableName, 0, []memCom.DataType{memCom.Uint16, memCom.SmallEnum, memCom.UUID, memCom.Uint32},
+			[]int{0}, newBatchSize, false, false, metaStore, diskStore)
+		shard, _ = memStore.GetTableShard(tableName, 0)
+
+		shard.LiveStore.SnapshotManager.ApplyUpsertBatch(0, 0, 0, memCom.RecordID{})
+		shard.LiveStore.SnapshotManager.SetLastSnapshotInfo(redoLogFile, offset, currentRecord)
+
+		batchIDs := []int{int(lastBatchID - 1), int(lastBatchID)}
+		colIDs := []int{0, 1, 2}
+		diskStore.On("ListSnapshotBatches", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(batchIDs, nil)
+		diskStore.On("ListSnapshotVectorPartyFiles", tableName, 0, redoLogFile, offset, mock.Anything).Return(colIDs, nil)
+
+		diskStore.On(
+			"OpenSnapshotVectorPartyFileForRead", tableName, 0, redoLogFile, offset, int(lastBatchID-1), 0).
+			Return(openSnapshotVectorPartyFileForRead(tableName, 0, redoLogFile, offset, int(lastBatchID-1), 0))
+		diskStore.On(
+			"OpenSnapshotVectorPartyFileForRead", tableName, 0, redoLogFile, offset, int(lastBatchID-1), 1).
+			Return(openSnapshotVectorPartyFileForRead(tableName, 0, redoLogFile, offset, int(lastBatchID-1), 1))
+		diskStore.On(
+			"OpenSnapshotVectorPartyFileForRead", tableName, 0, redoLogFile, offset, int(lastBatchID-1), 2).
+			Return(openSnapshotVectorPartyFileForRead(tableName, 0, redoLogFile, offset, int(lastBatchID-1), 2))
+		diskStore.On(
+			"OpenSnapshotVectorPartyFileForRead", tableName, 0, redoLogFile, offset, int(lastBatchID), 0).
+			Return(openSnapshotVectorPartyFileForRead(tableName, 0, redoLogFile, offset, int(lastBatchID), 0))
+		diskStore.On(
+			"OpenSnapshotVectorPartyFileForRead", tableName, 0, redoLogFile, offset, int(lastBatchID), 1).
+			Return(openSnapshotVectorPartyFileForRead(tableName, 0, redoLogFile, offset, int(lastBatchID), 1))
+		diskStore.On(
+			"OpenSnapshotVectorPartyFileForRead", tableName, 0, redoLogFile, offset, int(lastBatchID), 2).
+			Return(openSnapshotVectorPartyFileForRead(tableName, 0, redoLogFile, offset, int(lastBatchID), 2))
+
+		Ω(shard.LoadSnapshot()).Should(BeNil())
+	}
+
 	ginkgo.AfterEach(func() {
 		os.RemoveAll("/tmp/data")
 	})
 
 	ginkgo.BeforeEach(func() {
+		// snapshot files are opened when mocks are set up, so each test needs its own mocks.
+		diskStore = &diskMocks.DiskStore{}
+		metaStore = &metaMocks.MetaStore{}
+		diskStore.On("OpenLogFileForAppend", mock.Anything, mock.Anything, mock.Anything).Return(&testing.TestReadWriteCloser{}, nil)
 		memStore = createMemStore(tableName, 0, []memCom.DataType{memCom.Uint16, memCom.SmallEnum, memCom.UUID, memCom.Uint32},
 			[]int{0}, batchSize, false, false, metaStore, diskStore)
 		shard, _ = memStore.GetTableShard(tableName, 0)
@@ -194,40 +233,7 @@ var _ = ginkgo.Describe("snapshot", func() {
 		Ω(err).Should(BeNil())
 		Ω(fInfo.Size() > 0).Should(BeTrue())
 
-		// using new instance to avoid data conflict
-		memStore = createMemStore(tableName, 0, []memCom.DataType{memCom.Uint16, memCom.SmallEnum, memCom.UUID, memCom.Uint32},
-			[]int{0}, batchSize, false, false, metaStore, diskStore)
-		shard, _ = memStore.GetTableShard(tableName, 0)
-
-		shard.LiveStore.SnapshotManager.ApplyUpsertBatch(0, 0, 0, memCom.RecordID{})
-		shard.LiveStore.SnapshotManager.SetLastSnapshotInfo(redoLogFile, offset, currentRecord)
-
-		batchIDs := []int{int(lastBatchID - 1), int(lastBatchID)}
-		colIDs := []int{0, 1, 2}
-		diskStore.On("ListSnapshotBatches", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(batchIDs, nil)
-		diskStore.On("ListSnapshotVectorPartyFiles", tableName, 0, redoLogFile, offset, mock.Anything).Return(colIDs, nil)
-
-		diskStore.On(
-			"OpenSnapshotVectorPartyFileForRead", tableName, 0, redoLogFile, offset, int(lastBatchID-1), 0).
-			Return(openSnapshotVectorPartyFileForRead(tableName, 0, redoLogFile, offset, int(lastBatchID-1), 0))
-		diskStore.On(
-			"OpenSnapshotVectorPartyFileForRead", tableName, 0, redoLogFile, offset, int(lastBatchID-1), 1).
-			Return(openSnapshotVectorPartyFileForRead(tableName, 0, redoLogFile, offset, int(lastBatchID-1), 1))
-		diskStore.On(
-			"OpenSnapshotVectorPartyFileForRead", tableName, 0, redoLogFile, offset, int(lastBatchID-1), 2).
-			Return(openSnapshotVectorPartyFileForRead(tableName, 0, redoLogFile, offset, int(lastBatchID-1), 2))
-		diskStore.On(
-			"OpenSnapshotVectorPartyFileForRead", tableName, 0, redoLogFile, offset, int(lastBatchID), 0).
-			Return(openSnapshotVectorPartyFileForRead(tableName, 0, redoLogFile, offset, int(lastBatchID), 0))
-		diskStore.On(
-			"OpenSnapshotVectorPartyFileForRead", tableName, 0, redoLogFile, offset, int(lastBatchID), 1).
-			Return(openSnapshotVectorPartyFileForRead(tableName, 0, redoLogFile, offset, int(lastBatchID), 1))
-		diskStore.On(
-			"OpenSnapshotVectorPartyFileForRead", tableName, 0, redoLogFile, offset, int(lastBatchID), 2).
-			Return(openSnapshotVectorPartyFileForRead(tableName, 0, redoLogFile, offset, int(lastBatchID), 2))
-
-		err = shard.LoadSnapshot()
-		Ω(err).Should(BeNil())
+		recoverFromSnapshot(batchSize)
 		// check if the pointer advanced
 		Ω(shard.LiveStore.NextWriteRecord.BatchID).Should(Equal(lastBatchID))
 		Ω(shard.LiveStore.NextWriteRecord.Index).Should(Equal(lastIndex))
@@ -255,6 +261,31 @@ var _ = ginkgo.Describe("snapshot", func() {
 		}
 	})
 
+	ginkgo.It("recovery should keep capacities of batches in snapshot", func() {
+		Ω(ingestValues()).Should(BeNil())
+		createSnapshot()
+
+		// batches loaded from snapshot keep their sizes regardless of the batch size of the table.
+		recoverFromSnapshot(2 * batchSize)
+		Ω(shard.LiveStore.NextWriteRecord).Should(Equal(currentRecord))
+		for _, batchID := range []int32{lastBatchID - 1, lastBatchID} {
+			batch := shard.LiveStore.GetBatchForRead(batchID)
+			Ω(batch.Capacity).Should(Equal(batchSize))
+			batch.RUnlock()
+		}
+
+		primaryKeyValues := make([]memCom.DataValue, 1)
+		for row := 0; row < rows; row++ {
+			primaryKeyValues[0], _ = memCom.ValueFromString(fmt.Sprintf("%d", row+1), memCom.Uint16)
+			key, err := memCom.GetPrimaryKeyBytes(primaryKeyValues, shard.Schema.PrimaryKeyBytes)
+			Ω(err).Should(BeNil())
+			record, found := shard.LiveStore.PrimaryKey.Find(key)
+			Ω(found).Should(BeTrue())
+			Ω(record.BatchID).Should(Equal(lastBatchID - 1 + int32(row/batchSize)))
+			Ω(record.Index).Should(Equal(uint32(row % batchSize)))
+		}
+	})
+
 	ginkgo.It("dimension table snapshot on deleted table should not return error", func() {
 		snapshotJobM := &snapshotJobManager{
 			jobDetails: make(map[string]*SnapshotJobDetail),
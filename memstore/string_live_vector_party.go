@@ -0,0 +1,228 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"fmt"
+	"github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/utils"
+	"io"
+	"os"
+	"unsafe"
+)
+
+// stringLiveVectorParty is the implementation of LiveVectorParty for String columns.
+// Values are stored as uint32 ids in c allocated memory and resolved through the
+// dictionary of the batch, so high cardinality strings are not limited by enum cases.
+type stringLiveVectorParty struct {
+	*cLiveVectorParty
+
+	dictionary        *common.StringDictionary
+	hostMemoryManager common.HostMemoryManager
+	// global dictionary of the column the batch dictionary is registered with.
+	globalDictionary *common.GlobalStringDictionary
+}
+
+// newStringLiveVectorParty creates a LiveVectorParty for String columns.
+func newStringLiveVectorParty(length int, hostMemoryManager common.HostMemoryManager) *stringLiveVectorParty {
+	return &stringLiveVectorParty{
+		cLiveVectorParty:  newCLiveVectorParty(length, common.String, common.NullDataValue),
+		dictionary:        common.NewStringDictionary(),
+		hostMemoryManager: hostMemoryManager,
+	}
+}
+
+// Dictionary returns the dictionary of the vector party.
+func (vp *stringLiveVectorParty) Dictionary() *common.StringDictionary {
+	return vp.dictionary
+}
+
+// registerStringDictionary registers the batch dictionary of a String vector party with the
+// global dictionary of the column, vector parties of other types are ignored.
+func registerStringDictionary(vp common.LiveVectorParty, globalDictionary *common.GlobalStringDictionary) {
+	if stringVP, ok := vp.(*stringLiveVectorParty); ok && globalDictionary != nil {
+		stringVP.globalDictionary = globalDictionary
+		globalDictionary.Register(stringVP.dictionary)
+	}
+}
+
+// SetDataValue implements SetDataValue in VectorParty interface
+// liveVectorParty ignores countsUpdateMode or counts
+func (vp *stringLiveVectorParty) SetDataValue(offset int, value common.DataValue,
+	countsUpdateMode common.ValueCountsUpdateMode, counts ...uint32) {
+	vp.SetGoValue(offset, value.GoVal, value.Valid)
+}
+
+// SetGoValue implements SetGoValue in LiveVectorParty interface
+func (vp *stringLiveVectorParty) SetGoValue(offset int, val common.GoDataValue, valid bool) {
+	str, ok := val.(*common.StringGo)
+	if !valid || !ok || str == nil {
+		vp.cLiveVectorParty.SetValue(offset, nil, false)
+		return
+	}
+
+	bytesBefore := vp.dictionary.GetBytes()
+	id := vp.dictionary.GetOrAdd(string(*str))
+	if bytesChange := vp.dictionary.GetBytes() - bytesBefore; bytesChange > 0 {
+		vp.hostMemoryManager.ReportUnmanagedSpaceUsageChange(int64(bytesChange))
+	}
+	vp.cLiveVectorParty.SetValue(offset, unsafe.Pointer(&id), true)
+}
+
+// GetDataValue implements GetDataValue in VectorParty interface
+func (vp *stringLiveVectorParty) GetDataValue(offset int) common.DataValue {
+	val := common.DataValue{
+		Valid:    vp.GetValidity(offset),
+		DataType: common.String,
+	}
+	if !val.Valid {
+		return val
+	}
+
+	id := *(*uint32)(vp.values.GetValue(offset))
+	str, ok := vp.dictionary.Get(id)
+	if !ok {
+		val.Valid = false
+		return val
+	}
+	strVal := common.StringGo(str)
+	val.GoVal = &strVal
+	return val
+}
+
+// GetDataValueByRow implements GetDataValueByRow in VectorParty interface
+func (vp *stringLiveVectorParty) GetDataValueByRow(row int) common.DataValue {
+	return vp.GetDataValue(row)
+}
+
+// GetHostVectorPartySlice implements GetHostVectorPartySlice in TransferableVectorParty interface.
+// Ids of the batch dictionary are translated to uint32 ids of the global dictionary of the column
+// so that values can be compared and grouped across batches on gpu.
+func (vp *stringLiveVectorParty) GetHostVectorPartySlice(startIndex, length int) common.HostVectorPartySlice {
+	slice := vp.cLiveVectorParty.GetHostVectorPartySlice(startIndex, length)
+	slice.ValueType = common.Uint32
+	slice.DefaultValue = common.NullDataValue
+	if slice.Values == nil || vp.globalDictionary == nil {
+		return slice
+	}
+
+	globalIDs := vp.globalDictionary.GlobalIDs(vp.dictionary)
+	translated := make([]uint32, slice.ValueBytes/4)
+	for i := range translated {
+		// ids of null values are not in the dictionary and left as 0.
+		if id := *(*uint32)(utils.MemAccess(slice.Values, i*4)); int(id) < len(globalIDs) {
+			translated[i] = globalIDs[id]
+		}
+	}
+	if len(translated) > 0 {
+		slice.Values = unsafe.Pointer(&translated[0])
+	}
+	return slice
+}
+
+// GetMinMaxValue is **not supported** by stringLiveVectorParty
+func (vp *stringLiveVectorParty) GetMinMaxValue() (min uint32, max uint32) {
+	return 0, 0
+}
+
+// GetBytes implements GetBytes in VectorParty interface
+func (vp *stringLiveVectorParty) GetBytes() int64 {
+	return vp.cLiveVectorParty.GetBytes() + int64(vp.dictionary.GetBytes())
+}
+
+// Slice implements Slice in VectorParty interface
+func (vp *stringLiveVectorParty) Slice(startRow, numRows int) common.SlicedVector {
+	size := vp.length - startRow
+	if size < 0 {
+		size = 0
+	}
+	if size > numRows {
+		size = numRows
+	}
+
+	vector := common.SlicedVector{
+		Values: make([]interface{}, size),
+		Counts: make([]int, size),
+	}
+
+	for i := 0; i < size; i++ {
+		vector.Values[i] = vp.GetDataValue(startRow + i).ConvertToHumanReadable(common.String)
+		vector.Counts[i] = i + 1
+	}
+	return vector
+}
+
+// Write implements Write in VectorParty interface. The dictionary is written ahead
+// of the id vectors.
+func (vp *stringLiveVectorParty) Write(writer io.Writer) error {
+	dataWriter := utils.NewStreamDataWriter(writer)
+	if err := vp.dictionary.Write(&dataWriter); err != nil {
+		return err
+	}
+	return vp.cLiveVectorParty.Write(writer)
+}
+
+// Read implements Read in VectorParty interface
+func (vp *stringLiveVectorParty) Read(reader io.Reader, serializer common.VectorPartySerializer) error {
+	dataReader := utils.NewStreamDataReader(reader)
+	if err := vp.dictionary.Read(&dataReader); err != nil {
+		return err
+	}
+	serializer.ReportVectorPartyMemoryUsage(int64(vp.dictionary.GetBytes()))
+	return vp.cLiveVectorParty.Read(reader, serializer)
+}
+
+// SafeDestruct implements SafeDestruct in VectorParty interface
+func (vp *stringLiveVectorParty) SafeDestruct() {
+	vp.cLiveVectorParty.SafeDestruct()
+	if vp.globalDictionary != nil {
+		vp.globalDictionary.Unregister(vp.dictionary)
+		vp.globalDictionary = nil
+	}
+	vp.dictionary = common.NewStringDictionary()
+}
+
+// Equals implements Equals in VectorParty interface
+func (vp *stringLiveVectorParty) Equals(other common.VectorParty) bool {
+	if vp == nil || other == nil {
+		return vp == nil && other == nil
+	}
+
+	if other.GetDataType() != common.String || vp.GetLength() != other.GetLength() {
+		return false
+	}
+
+	for i := 0; i < vp.GetLength(); i++ {
+		if vp.GetDataValue(i).ConvertToHumanReadable(common.String) !=
+			other.GetDataValue(i).ConvertToHumanReadable(common.String) {
+			return false
+		}
+	}
+	return true
+}
+
+// Dump is for testing purpose
+func (vp *stringLiveVectorParty) Dump(file *os.File) {
+	fmt.Fprintf(file, "\nString LiveVectorParty, length: %d, dictionary size: %d, value: \n",
+		vp.GetLength(), vp.dictionary.Len())
+	for i := 0; i < vp.GetLength(); i++ {
+		val := vp.GetDataValue(i)
+		if val.Valid {
+			fmt.Fprintf(file, "\t%v\n", val.ConvertToHumanReadable(common.String))
+		} else {
+			fmt.Fprintln(file, "\tnil")
+		}
+	}
+}
@@ -0,0 +1,51 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"github.com/uber/aresdb/memstore/common"
+)
+
+// GetSystemVectorParty returns the vector party of the system column if rows of the live batch
+// have different values, otherwise nil.
+func (b *LiveBatch) GetSystemVectorParty(columnID int) common.VectorParty {
+	if columnID == common.IngestedAtColumnID && b.ingestedAt != nil {
+		return b.ingestedAt
+	}
+	return nil
+}
+
+// GetSystemColumnValue returns the value of the system column shared by all rows of the live
+// batch with the given id.
+func (b *LiveBatch) GetSystemColumnValue(batchID int32, columnID int) common.DataValue {
+	if columnID == common.BatchIDColumnID {
+		return common.SystemColumnValue(columnID, batchID)
+	}
+	// ingestion time of rows is only shared when the batch has no rows written yet.
+	return common.NullDataValue
+}
+
+// GetSystemColumnValue returns the value of the system column shared by all rows of the archive
+// batch. Archive batches do not keep ingestion time of rows, so the archiving cutoff of the batch
+// version is reported instead.
+func (b *ArchiveBatch) GetSystemColumnValue(columnID int) common.DataValue {
+	switch columnID {
+	case common.IngestedAtColumnID:
+		return common.SystemColumnValue(columnID, b.Version)
+	case common.BatchIDColumnID:
+		return common.SystemColumnValue(columnID, b.BatchID)
+	}
+	return common.NullDataValue
+}
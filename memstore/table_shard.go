@@ -44,6 +44,9 @@ type TableShard struct {
 	// Archive store.
 	ArchiveStore *ArchiveStore `json:"archiveStore"`
 
+	// Prefetches archive batches adjacent to recent queries, only for fact tables.
+	ArchivePrefetcher *ArchivePrefetcher `json:"-"`
+
 	// The special column deletion lock,
 	// see https://docs.google.com/spreadsheets/d/1QI3s1_4wgP3Cy-IGoKFCx9BcN23FzIfZGRSNC8I-1Sk/edit#gid=0
 	columnDeletion sync.Mutex
@@ -61,6 +64,18 @@ type TableShard struct {
 	// before own disk data is available for serve
 	// default to 0 (no need for peer copy)
 	needPeerCopy uint32
+	// standby marks the table shard of a warm standby datanode, whose redolog replay is deferred
+	// until promoted. Protected by bootstrapLock.
+	standby bool
+
+	// progress of redolog replay, updated atomically.
+	recoveryTotalBytes    int64
+	recoveryReplayedBytes int64
+	recoveryDone          int32
+
+	// whether archived hll columns need to be recomputed after their precision got reduced,
+	// updated atomically.
+	hllRecomputePending int32
 }
 
 // NewTableShard creates and initiates a table shard based on the schema.
@@ -79,6 +94,9 @@ func NewTableShard(schema *common.TableSchema, metaStore metaCom.MetaStore,
 	archiveStore := NewArchiveStore(tableShard)
 	tableShard.ArchiveStore = archiveStore
 	tableShard.LiveStore = NewLiveStore(schema.Schema.Config.BatchSize, tableShard)
+	if schema.Schema.IsFactTable {
+		tableShard.ArchivePrefetcher = NewArchivePrefetcher(tableShard)
+	}
 	return tableShard
 }
 
@@ -154,6 +172,85 @@ func (shard *TableShard) DeleteColumn(columnID int) error {
 	return nil
 }
 
+// promoteLiveEnumColumn widens the small enum column promoted to big enum in all live batches.
+// Caller should hold LiveStore.WriterLock and have the schema updated.
+func (shard *TableShard) promoteLiveEnumColumn(columnID int) {
+	shard.Schema.RLock()
+	defaultValue := *shard.Schema.DefaultValues[columnID]
+	shard.Schema.RUnlock()
+
+	// including batches allocated but not visible to readers yet.
+	shard.LiveStore.RLock()
+	batches := make([]*LiveBatch, 0, len(shard.LiveStore.Batches))
+	for _, batch := range shard.LiveStore.Batches {
+		batches = append(batches, batch)
+	}
+	shard.LiveStore.RUnlock()
+
+	for _, batch := range batches {
+		batch.Lock()
+		if columnID < len(batch.Columns) {
+			if vp, ok := batch.Columns[columnID].(*cLiveVectorParty); ok {
+				bytes := vp.GetBytes()
+				vp.promoteEnum(defaultValue)
+				shard.HostMemoryManager.ReportUnmanagedSpaceUsageChange(vp.GetBytes() - bytes)
+			}
+		}
+		batch.Unlock()
+	}
+}
+
+// reduceLiveHLLPrecision converts hll values of higher precisions of the column in all live batches
+// to the precision. Caller should hold the writer lock of the live store.
+func (shard *TableShard) reduceLiveHLLPrecision(columnID int, precision int) {
+	// including batches allocated but not visible to readers yet.
+	shard.LiveStore.RLock()
+	batches := make([]*LiveBatch, 0, len(shard.LiveStore.Batches))
+	for _, batch := range shard.LiveStore.Batches {
+		batches = append(batches, batch)
+	}
+	shard.LiveStore.RUnlock()
+
+	for _, batch := range batches {
+		batch.Lock()
+		if columnID < len(batch.Columns) {
+			if vp, ok := batch.Columns[columnID].(*cLiveVectorParty); ok {
+				vp.reduceHLLPrecision(precision)
+			}
+		}
+		batch.Unlock()
+	}
+}
+
+// PromoteArchivedEnumColumn rewrites the small enum column promoted to big enum in all archive
+// batches on disk. Archived columns are converted on load until they are rewritten.
+// May block for extended amount of time during archiving.
+func (shard *TableShard) PromoteArchivedEnumColumn(columnID int) error {
+	if !shard.Schema.Schema.IsFactTable {
+		return nil
+	}
+
+	shard.columnDeletion.Lock()
+	defer shard.columnDeletion.Unlock()
+
+	currentVersion := shard.ArchiveStore.GetCurrentVersion()
+	defer currentVersion.Users.Done()
+
+	var batches []*ArchiveBatch
+	currentVersion.RLock()
+	for _, batch := range currentVersion.Batches {
+		batches = append(batches, batch)
+	}
+	currentVersion.RUnlock()
+
+	for _, batch := range batches {
+		if err := batch.RewriteVectorParty(columnID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // PreloadColumn loads the column into memory and wait for completion of loading
 // within (startDay, endDay]. Note endDay is inclusive but startDay is exclusive.
 func (shard *TableShard) PreloadColumn(columnID int, startDay int, endDay int) {
@@ -25,6 +25,7 @@ import (
 	"github.com/uber/aresdb/memstore/list"
 	"github.com/uber/aresdb/memstore/tests"
 	"github.com/uber/aresdb/memstore/vectors"
+	metaCom "github.com/uber/aresdb/metastore/common"
 	metaMocks "github.com/uber/aresdb/metastore/mocks"
 	"github.com/uber/aresdb/redolog"
 	"github.com/uber/aresdb/utils"
@@ -56,6 +57,8 @@ type TestFactoryT struct {
 // NewMockMemStore returns a new memstore with mocked diskstore and metastore.
 func (t TestFactoryT) NewMockMemStore() *memStoreImpl {
 	metaStore := new(metaMocks.MetaStore)
+	// daily row counts are not maintained by default.
+	metaStore.On("GetDailyRowCounts", mock.Anything, mock.Anything).Return(metaCom.DailyRowCounts{}, nil)
 	diskStore := new(diskMocks.DiskStore)
 	redoLogManagerMaster, _ := redolog.NewRedoLogManagerMaster("", &common.RedoLogConfig{}, diskStore, metaStore)
 	bootstrapToken := new(memComMocks.BootStrapToken)
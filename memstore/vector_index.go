@@ -0,0 +1,106 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// writeVectorIndexes builds IVF indexes of indexed vector columns and writes them along with
+// the vector parties of the batch. Like WriteToDisk, it is only called for newly merged batches
+// so there is no need to lock it.
+func (b *ArchiveBatch) writeVectorIndexes() error {
+	b.Shard.Schema.RLock()
+	indexedColumns := b.Shard.Schema.GetVectorIndexedColumns()
+	columns := b.Shard.Schema.Schema.Columns
+	b.Shard.Schema.RUnlock()
+
+	for _, columnID := range indexedColumns {
+		if columnID >= len(b.Columns) || b.Columns[columnID] == nil {
+			continue
+		}
+		index := b.buildVectorIndex(columnID, columns[columnID].VectorDimension, columns[columnID].Config.VectorIndexLists)
+		if err := b.writeIndex(columnID, index); err != nil {
+			return utils.StackError(err, "failed to write vector index of column %d for batch %d of table %s shard %d",
+				columnID, b.BatchID, b.Shard.Schema.Schema.Name, b.Shard.ShardID)
+		}
+		if b.vectorIndexes == nil {
+			b.vectorIndexes = make(map[int]*common.IVFIndex)
+		}
+		b.vectorIndexes[columnID] = index
+	}
+	return nil
+}
+
+// buildVectorIndex builds the IVF index of the vector column from its vector party. Array
+// columns cannot be sort columns so values are read row by row.
+func (b *ArchiveBatch) buildVectorIndex(columnID, dimension, numLists int) *common.IVFIndex {
+	var rows []uint32
+	var vectors [][]float32
+	b.forEachValue(columnID, false, func(row int, value common.DataValue) {
+		if vector, ok := common.ReadVector(value, dimension); ok {
+			rows = append(rows, uint32(row))
+			vectors = append(vectors, vector)
+		}
+	})
+	return common.BuildIVFIndex(dimension, numLists, rows, vectors)
+}
+
+// RequestVectorIndex returns the IVF index of the vector column in this batch version, loading
+// it from disk on first request. Nil is returned if the column is not indexed or the batch
+// version has no index of the column, in which case all rows need to be compared.
+func (b *ArchiveBatch) RequestVectorIndex(columnID int) *common.IVFIndex {
+	b.Shard.Schema.RLock()
+	indexed := utils.IndexOfInt(b.Shard.Schema.GetVectorIndexedColumns(), columnID) >= 0
+	b.Shard.Schema.RUnlock()
+	if !indexed {
+		return nil
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	if index, ok := b.vectorIndexes[columnID]; ok {
+		return index
+	}
+
+	index := common.NewIVFIndex()
+	found, err := b.readIndex(columnID, index)
+	if err != nil {
+		utils.GetLogger().With(
+			"table", b.Shard.Schema.Schema.Name,
+			"shard", b.Shard.ShardID,
+			"batchID", b.BatchID,
+			"column", columnID,
+			"error", err.Error()).Warn("failed to read batch vector index")
+	}
+	if !found || err != nil {
+		index = nil
+	}
+	if b.vectorIndexes == nil {
+		b.vectorIndexes = make(map[int]*common.IVFIndex)
+	}
+	b.vectorIndexes[columnID] = index
+	return index
+}
+
+// ForEachValue calls fn with each row and its value of the column in this batch. Caller needs
+// to request the vector party of the column and wait for it to be loaded.
+func (b *ArchiveBatch) ForEachValue(columnID int, fn func(row int, value common.DataValue)) {
+	b.Shard.Schema.RLock()
+	isSortColumn := utils.IndexOfInt(b.Shard.Schema.Schema.ArchivingSortColumns, columnID) >= 0
+	b.Shard.Schema.RUnlock()
+	b.forEachValue(columnID, isSortColumn, fn)
+}
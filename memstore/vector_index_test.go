@@ -0,0 +1,110 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"sync"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	diskStoreMocks "github.com/uber/aresdb/diskstore/mocks"
+	memCom "github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/memstore/list"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	testingUtils "github.com/uber/aresdb/testing"
+)
+
+var _ = ginkgo.Describe("vector index", func() {
+	table := "table1"
+	shardID := 0
+	batchID := 100
+	var cutoff uint32 = 200
+
+	ginkgo.It("WriteToDisk should write indexes of indexed vector columns", func() {
+		ds := new(diskStoreMocks.DiskStore)
+		lock := &sync.RWMutex{}
+
+		values := []string{"[0,0]", "[100,100]", "[0,1]", "", "[100,101]"}
+		vp := list.NewArchiveVectorParty(len(values), memCom.ArrayFloat32,
+			int64(len(values)*memCom.CalculateListElementBytes(memCom.ArrayFloat32, 2)), lock)
+		vp.Allocate(false)
+		for row, str := range values {
+			value := memCom.NullDataValue
+			if str != "" {
+				var err error
+				value, err = memCom.ValueFromString(str, memCom.ArrayFloat32)
+				Ω(err).Should(BeNil())
+			}
+			vp.SetDataValue(row, value, memCom.IgnoreCount)
+		}
+
+		batch := &ArchiveBatch{
+			Batch: memCom.Batch{
+				RWMutex: lock,
+				Columns: []memCom.VectorParty{nil, vp, nil},
+			},
+			Size:    len(values),
+			Version: cutoff,
+			BatchID: int32(batchID),
+			Shard: &TableShard{
+				diskStore: ds,
+				ShardID:   shardID,
+				Schema: &memCom.TableSchema{
+					Schema: metaCom.Table{
+						Name: table,
+						Columns: []metaCom.Column{
+							{Name: "time", Type: metaCom.Uint32},
+							{Name: "embedding", Type: metaCom.VectorFloat32, VectorDimension: 2,
+								Config: metaCom.ColumnConfig{Indexed: true, VectorIndexLists: 2}},
+							{Name: "other", Type: metaCom.VectorFloat32, VectorDimension: 2},
+						},
+					},
+					ValueTypeByColumn: []memCom.DataType{memCom.Uint32, memCom.ArrayFloat32, memCom.ArrayFloat32},
+				},
+			},
+		}
+
+		vpWriter := &testingUtils.TestReadWriteCloser{}
+		ds.On("OpenVectorPartyFileForWrite", table, mock.Anything, shardID, batchID, cutoff, uint32(0)).
+			Return(vpWriter, nil)
+		indexFile := &testingUtils.TestReadWriteCloser{}
+		ds.On("OpenBatchIndexFileForWrite", table, 1, shardID, batchID, cutoff, uint32(0)).
+			Return(indexFile, nil).Once()
+		Ω(batch.WriteToDisk()).Should(BeNil())
+
+		index := batch.vectorIndexes[1]
+		Ω(index.NumLists()).Should(Equal(2))
+		Ω(index.Candidates([]float32{100, 100}, 1)).Should(Equal([]uint32{1, 4}))
+		Ω(index.Candidates([]float32{0, 0}, 1)).Should(Equal([]uint32{0, 2}))
+
+		// indexes are loaded from disk by other batch objects of the same version.
+		ds.On("OpenBatchIndexFileForRead", table, 1, shardID, batchID, cutoff, uint32(0)).
+			Return(indexFile, nil).Once()
+		loaded := &ArchiveBatch{
+			Batch:   memCom.Batch{RWMutex: &sync.RWMutex{}},
+			Size:    len(values),
+			Version: cutoff,
+			BatchID: int32(batchID),
+			Shard:   batch.Shard,
+		}
+		Ω(loaded.RequestVectorIndex(1)).Should(Equal(index))
+		// cached after first request.
+		Ω(loaded.RequestVectorIndex(1)).Should(Equal(index))
+		// columns without index hint are not looked up.
+		Ω(loaded.RequestVectorIndex(2)).Should(BeNil())
+		ds.AssertExpectations(ginkgo.GinkgoT())
+	})
+})
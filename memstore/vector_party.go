@@ -19,6 +19,7 @@ import (
 	"github.com/uber/aresdb/cgoutils"
 	"github.com/uber/aresdb/memstore/common"
 	"github.com/uber/aresdb/memstore/vectors"
+	metaCom "github.com/uber/aresdb/metastore/common"
 	"github.com/uber/aresdb/utils"
 	"io"
 	"os"
@@ -31,6 +32,14 @@ type TransferableVectorParty interface {
 	GetHostVectorPartySlice(startIndex, length int) common.HostVectorPartySlice
 }
 
+// RunsTransferableVectorParty is the interface for vector parties that can be transferred to gpu
+// as runs of rows.
+type RunsTransferableVectorParty interface {
+	// GetRunsHostVectorPartySlice returns the runs covering rows [startRow, endRow) as a mode 3
+	// slice, false if the vector party is not kept as runs.
+	GetRunsHostVectorPartySlice(startRow, endRow int) (common.HostVectorPartySlice, bool)
+}
+
 // baseVectorParty is the base vector party type
 type baseVectorParty struct {
 	// DataType of values. We need it since for mode 0 vector party, we cannot
@@ -63,6 +72,10 @@ type cVectorParty struct {
 	// be 0 and last value to be vp.Length. We can get a count of current value
 	// by Counts[i+1] - Counts[i] for Values[i]
 	counts *vectors.Vector
+
+	// Runs of the vectors as a mode 3 vector party if loaded from run length encoded vectors,
+	// read only and transferred to device in place of the vectors by queries.
+	runs *cVectorParty
 }
 
 // IsList tells whether it's a list vector party or not.
@@ -120,6 +133,75 @@ func (vp *cVectorParty) fillWithDefaultValue() {
 	}
 }
 
+// promoteEnum widens the values of a small enum vector party to big enum and sets the default
+// value of the promoted column. Caller should make sure there are no concurrent users of this
+// vector party.
+func (vp *cVectorParty) promoteEnum(defaultValue common.DataValue) {
+	if vp.dataType != common.SmallEnum {
+		return
+	}
+
+	if vp.values != nil {
+		values := vectors.NewVector(common.BigEnum, vp.values.Size)
+		for i := 0; i < vp.values.Size; i++ {
+			value := uint16(*(*uint8)(vp.values.GetValue(i)))
+			values.SetValue(i, unsafe.Pointer(&value))
+		}
+		vp.values.SafeDestruct()
+		vp.values = values
+	}
+	if vp.runs != nil {
+		vp.runs.promoteEnum(defaultValue)
+	}
+	vp.dataType = common.BigEnum
+	vp.defaultValue = defaultValue
+}
+
+// remapEnum maps the enum ids of an enum vector party written before the compactions of the
+// enum dictionary to the current enum ids and sets the default value of the column. Caller
+// should make sure there are no concurrent users of this vector party.
+func (vp *cVectorParty) remapEnum(compactions []metaCom.EnumDictCompaction, defaultValue common.DataValue) {
+	if vp.dataType != common.SmallEnum && vp.dataType != common.BigEnum {
+		return
+	}
+
+	if vp.values != nil {
+		for i := 0; i < vp.values.Size; i++ {
+			value := vp.values.GetValue(i)
+			if vp.dataType == common.SmallEnum {
+				*(*uint8)(value) = uint8(common.RemapEnumID(compactions, int(*(*uint8)(value))))
+			} else {
+				*(*uint16)(value) = uint16(common.RemapEnumID(compactions, int(*(*uint16)(value))))
+			}
+		}
+	}
+	if vp.runs != nil {
+		vp.runs.remapEnum(compactions, defaultValue)
+	}
+	vp.defaultValue = defaultValue
+}
+
+// reduceHLLPrecision converts the values of an hll column computed with higher precisions to the
+// precision. Caller should make sure there are no concurrent users of this vector party. Returns
+// whether any value is converted.
+func (vp *cVectorParty) reduceHLLPrecision(precision int) (reduced bool) {
+	if vp.dataType != common.Uint32 || vp.values == nil {
+		return false
+	}
+
+	for i := 0; i < vp.values.Size; i++ {
+		value := (*uint32)(vp.values.GetValue(i))
+		if newValue := utils.ReduceHLLValuePrecision(*value, precision); newValue != *value {
+			*value = newValue
+			reduced = true
+		}
+	}
+	if vp.runs != nil {
+		vp.runs.reduceHLLPrecision(precision)
+	}
+	return
+}
+
 // SafeDestruct destructs all vectors of this vector party. Corresponding pointer should be set
 // as nil after destruction.
 func (vp *cVectorParty) SafeDestruct() {
@@ -130,6 +212,8 @@ func (vp *cVectorParty) SafeDestruct() {
 		vp.nulls = nil
 		vp.counts.SafeDestruct()
 		vp.counts = nil
+		vp.runs.SafeDestruct()
+		vp.runs = nil
 	}
 }
 
@@ -147,6 +231,10 @@ func (vp *cVectorParty) GetBytes() int64 {
 	if vp.counts != nil {
 		bytes += int64(vp.counts.Bytes)
 	}
+
+	if vp.runs != nil {
+		bytes += vp.runs.GetBytes()
+	}
 	return bytes
 }
 
@@ -433,6 +521,11 @@ func (vp *cVectorParty) SliceIndex(lowerBoundRow, upperBoundRow int) (startIndex
 // Write writes a vector party to underlying writer. It first writes header and then writes vectors
 // based on vector party mode. **This vector party should be from archive batch and already pruned.**
 func (vp *cVectorParty) Write(writer io.Writer) error {
+	return vp.write(writer, common.PlainEncoding)
+}
+
+// write writes a vector party to underlying writer with vectors encoded by the encoding.
+func (vp *cVectorParty) write(writer io.Writer, encoding common.VectorPartyEncoding) error {
 	dataWriter := utils.NewStreamDataWriter(writer)
 	if err := dataWriter.WriteUint32(common.VectorPartyHeader); err != nil {
 		return err
@@ -455,8 +548,12 @@ func (vp *cVectorParty) Write(writer io.Writer) error {
 		return err
 	}
 
-	// Write 6 bytes padding.
-	if err := dataWriter.SkipBytes(6); err != nil {
+	if err := dataWriter.WriteUint16(uint16(encoding)); err != nil {
+		return err
+	}
+
+	// Write 4 bytes padding.
+	if err := dataWriter.SkipBytes(4); err != nil {
 		return err
 	}
 
@@ -466,6 +563,10 @@ func (vp *cVectorParty) Write(writer io.Writer) error {
 		return nil
 	}
 
+	if encoding != common.PlainEncoding {
+		return vp.writeEncoded(&dataWriter, encoding)
+	}
+
 	// Write value vector.
 	// Here we directly move data from c allocated memory into writer.
 	if err := dataWriter.Write(
@@ -547,8 +648,19 @@ func (vp *cVectorParty) Read(reader io.Reader, s common.VectorPartySerializer) e
 		return utils.StackError(nil, "Invalid mode %d", columnMode)
 	}
 
+	e, err := dataReader.ReadUint16()
+	if err != nil {
+		return err
+	}
+
+	encoding := common.VectorPartyEncoding(e)
+	if encoding >= common.MaxVectorPartyEncoding ||
+		(encoding != common.PlainEncoding && columnMode == common.HasCountVector) {
+		return utils.StackError(nil, "Invalid encoding %d for mode %d", encoding, columnMode)
+	}
+
 	// Read unused bytes
-	err = dataReader.SkipBytes(6)
+	err = dataReader.SkipBytes(4)
 	if err != nil {
 		return err
 	}
@@ -571,6 +683,17 @@ func (vp *cVectorParty) Read(reader io.Reader, s common.VectorPartySerializer) e
 		return nil
 	}
 
+	if encoding != common.PlainEncoding {
+		if err = vp.readEncoded(&dataReader, encoding); err != nil {
+			return err
+		}
+		if vp.runs != nil {
+			// runs are kept in addition to the decoded vectors.
+			s.ReportVectorPartyMemoryUsage(vp.GetBytes())
+		}
+		return nil
+	}
+
 	// Read value vector.
 	valueVector := vectors.NewVector(dataType, length)
 	// Here we directly read from reader into the c allocated bytes.
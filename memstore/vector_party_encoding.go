@@ -0,0 +1,493 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"unsafe"
+
+	"github.com/uber/aresdb/cgoutils"
+	"github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/memstore/vectors"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// Encoded vectors are written as a uint32 byte length followed by the encoded bytes.
+//
+// Run length encoding:
+//   uint32 number of runs
+//   uint32 exclusive end row of each run
+//   uint8 validity of each run, only for mode 2 vector party
+//   value of each run
+//
+// Delta encoding:
+//   uint64 value of the first row
+//   uint64 bit width of deltas
+//   zigzag encoded deltas between consecutive values packed into uint64 words
+//   followed by the null vector as is for mode 2 vector party
+//
+// Values of null rows are not stored and are decoded as the value of the previous row.
+//
+// Run length encoded vectors are also kept as runs in a mode 3 vector party when loaded, so
+// query kernels can scan runs with the count vector the same way as compressed sort columns
+// instead of transferring and scanning every row.
+
+// isSignedDataType returns whether values of the data type are sign extended when computing deltas.
+func isSignedDataType(dataType common.DataType) bool {
+	switch dataType {
+	case common.Int8, common.Int16, common.Int32, common.Int64, common.Decimal:
+		return true
+	}
+	return false
+}
+
+// getRawValue returns bits of the value at index, sign extended for signed data types.
+func getRawValue(v *vectors.Vector, index int, signed bool) uint64 {
+	ptr := v.GetValue(index)
+	switch common.DataTypeBits(v.DataType) {
+	case 8:
+		if signed {
+			return uint64(int64(*(*int8)(ptr)))
+		}
+		return uint64(*(*uint8)(ptr))
+	case 16:
+		if signed {
+			return uint64(int64(*(*int16)(ptr)))
+		}
+		return uint64(*(*uint16)(ptr))
+	case 32:
+		if signed {
+			return uint64(int64(*(*int32)(ptr)))
+		}
+		return uint64(*(*uint32)(ptr))
+	default:
+		return *(*uint64)(ptr)
+	}
+}
+
+// setRawValue sets the value at index to the lower bits of value.
+func setRawValue(v *vectors.Vector, index int, value uint64) {
+	v.SetValue(index, unsafe.Pointer(&value))
+}
+
+// putEncodedValue writes the lower valueBytes bytes of value to buf.
+func putEncodedValue(buf []byte, value uint64, valueBytes int) {
+	switch valueBytes {
+	case 1:
+		buf[0] = byte(value)
+	case 2:
+		binary.LittleEndian.PutUint16(buf, uint16(value))
+	case 4:
+		binary.LittleEndian.PutUint32(buf, uint32(value))
+	default:
+		binary.LittleEndian.PutUint64(buf, value)
+	}
+}
+
+// getEncodedValue reads a value of valueBytes bytes from buf.
+func getEncodedValue(buf []byte, valueBytes int) uint64 {
+	switch valueBytes {
+	case 1:
+		return uint64(buf[0])
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(buf))
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(buf))
+	default:
+		return binary.LittleEndian.Uint64(buf)
+	}
+}
+
+func zigzagEncode(delta uint64) uint64 {
+	signed := int64(delta)
+	return uint64((signed << 1) ^ (signed >> 63))
+}
+
+func zigzagDecode(encoded uint64) uint64 {
+	return (encoded >> 1) ^ -(encoded & 1)
+}
+
+// runLengthEncodedBytes returns the bytes taken on disk by a run length encoded vector party.
+func runLengthEncodedBytes(numRuns, valueBytes int, hasNulls bool) int {
+	bytes := 8 + numRuns*(4+valueBytes)
+	if hasNulls {
+		bytes += numRuns
+	}
+	return bytes
+}
+
+// deltaEncodedBytes returns the bytes taken on disk by a delta encoded vector party excluding
+// the null vector.
+func deltaEncodedBytes(length, bitWidth int) int {
+	return 20 + deltaEncodedWords(length, bitWidth)*8
+}
+
+func deltaEncodedWords(length, bitWidth int) int {
+	if length <= 1 {
+		return 0
+	}
+	return ((length-1)*bitWidth + 63) / 64
+}
+
+// forEachEncodedValue calls fn with the validity and value of each row, where values of null
+// rows are replaced by values of their previous rows.
+func (vp *cVectorParty) forEachEncodedValue(fn func(row int, valid bool, value uint64)) {
+	hasNulls := vp.columnMode == common.HasNullVector
+	signed := isSignedDataType(vp.dataType)
+	var value uint64
+	for row := 0; row < vp.length; row++ {
+		valid := !hasNulls || vp.nulls.GetBool(row)
+		if valid {
+			value = getRawValue(vp.values, row, signed)
+		}
+		fn(row, valid, value)
+	}
+}
+
+// chooseEncoding returns the encoding taking the least bytes on disk for the vector party.
+// Only uncompressed archive vector parties of fixed width data types are encoded.
+func (vp *cVectorParty) chooseEncoding() common.VectorPartyEncoding {
+	if (vp.columnMode != common.AllValuesPresent && vp.columnMode != common.HasNullVector) ||
+		vp.length == 0 || !common.IsRunLengthEncodable(vp.dataType) {
+		return common.PlainEncoding
+	}
+
+	hasNulls := vp.columnMode == common.HasNullVector
+	numRuns := 0
+	var maxDelta uint64
+	var prevValue uint64
+	var prevValid bool
+	vp.forEachEncodedValue(func(row int, valid bool, value uint64) {
+		if row == 0 || valid != prevValid || value != prevValue {
+			numRuns++
+		}
+		if row > 0 {
+			if delta := zigzagEncode(value - prevValue); delta > maxDelta {
+				maxDelta = delta
+			}
+		}
+		prevValue, prevValid = value, valid
+	})
+
+	nullBytes := 0
+	if hasNulls {
+		nullBytes = vp.nulls.Bytes
+	}
+
+	encoding, encodedBytes := common.PlainEncoding, vp.values.Bytes+nullBytes
+	if bytes := runLengthEncodedBytes(numRuns, common.DataTypeBytes(vp.dataType), hasNulls); bytes < encodedBytes {
+		encoding, encodedBytes = common.RunLengthEncoding, bytes
+	}
+	if common.IsDeltaEncodable(vp.dataType) {
+		if bytes := deltaEncodedBytes(vp.length, bits.Len64(maxDelta)) + nullBytes; bytes < encodedBytes {
+			encoding = common.DeltaEncoding
+		}
+	}
+	return encoding
+}
+
+// encodingForCodec returns the encoding to write the vector party with for the codec configured
+// for the column. Vectors are written plain if the forced encoding does not apply.
+func (vp *cVectorParty) encodingForCodec(codec string) common.VectorPartyEncoding {
+	if (vp.columnMode != common.AllValuesPresent && vp.columnMode != common.HasNullVector) || vp.length == 0 {
+		return common.PlainEncoding
+	}
+
+	switch codec {
+	case metaCom.ColumnCodecNone, metaCom.ColumnCodecZstd:
+		return common.PlainEncoding
+	case metaCom.ColumnCodecRLE:
+		if common.IsRunLengthEncodable(vp.dataType) {
+			return common.RunLengthEncoding
+		}
+		return common.PlainEncoding
+	case metaCom.ColumnCodecDelta:
+		if common.IsDeltaEncodable(vp.dataType) {
+			return common.DeltaEncoding
+		}
+		return common.PlainEncoding
+	}
+	return vp.chooseEncoding()
+}
+
+// encodeRunLength encodes values and validities of the vector party as runs.
+func (vp *cVectorParty) encodeRunLength() []byte {
+	hasNulls := vp.columnMode == common.HasNullVector
+	valueBytes := common.DataTypeBytes(vp.dataType)
+
+	var ends []uint32
+	var validities []bool
+	var values []uint64
+	vp.forEachEncodedValue(func(row int, valid bool, value uint64) {
+		last := len(ends) - 1
+		if last >= 0 && validities[last] == valid && values[last] == value {
+			ends[last] = uint32(row + 1)
+			return
+		}
+		ends = append(ends, uint32(row+1))
+		validities = append(validities, valid)
+		values = append(values, value)
+	})
+
+	numRuns := len(ends)
+	buf := make([]byte, runLengthEncodedBytes(numRuns, valueBytes, hasNulls)-4)
+	binary.LittleEndian.PutUint32(buf, uint32(numRuns))
+	offset := 4
+	for _, end := range ends {
+		binary.LittleEndian.PutUint32(buf[offset:], end)
+		offset += 4
+	}
+	if hasNulls {
+		for _, valid := range validities {
+			if valid {
+				buf[offset] = 1
+			}
+			offset++
+		}
+	}
+	for _, value := range values {
+		putEncodedValue(buf[offset:], value, valueBytes)
+		offset += valueBytes
+	}
+	return buf
+}
+
+// decodeRunLength decodes run length encoded bytes into the value vector and null vector if not nil.
+func decodeRunLength(data []byte, values, nulls *vectors.Vector, length int) error {
+	if len(data) < 4 {
+		return utils.StackError(nil, "Invalid run length encoded vector party of %d bytes", len(data))
+	}
+	valueBytes := common.DataTypeBytes(values.DataType)
+	numRuns := int(binary.LittleEndian.Uint32(data))
+	if len(data) != runLengthEncodedBytes(numRuns, valueBytes, nulls != nil)-4 {
+		return utils.StackError(nil, "Invalid run length encoded vector party of %d bytes with %d runs",
+			len(data), numRuns)
+	}
+
+	validityOffset := 4 + numRuns*4
+	valueOffset := validityOffset
+	if nulls != nil {
+		valueOffset += numRuns
+	}
+
+	row := 0
+	for run := 0; run < numRuns; run++ {
+		end := int(binary.LittleEndian.Uint32(data[4+run*4:]))
+		if end < row || end > length {
+			return utils.StackError(nil, "Invalid end row %d of run %d, length: %d", end, run, length)
+		}
+		value := getEncodedValue(data[valueOffset+run*valueBytes:], valueBytes)
+		valid := nulls == nil || data[validityOffset+run] != 0
+		for ; row < end; row++ {
+			setRawValue(values, row, value)
+			if nulls != nil {
+				nulls.SetBool(row, valid)
+			}
+		}
+	}
+
+	if row != length {
+		return utils.StackError(nil, "Run length encoded vector party has %d rows, expected %d", row, length)
+	}
+	return nil
+}
+
+// newRunLengthVectorParty creates a mode 3 vector party holding the runs of run length encoded
+// bytes already validated by decodeRunLength.
+func newRunLengthVectorParty(data []byte, dataType common.DataType, defaultValue common.DataValue, hasNulls bool) *cVectorParty {
+	valueBytes := common.DataTypeBytes(dataType)
+	numRuns := int(binary.LittleEndian.Uint32(data))
+	validityOffset := 4 + numRuns*4
+	valueOffset := validityOffset
+	if hasNulls {
+		valueOffset += numRuns
+	}
+
+	runs := &cVectorParty{
+		baseVectorParty: baseVectorParty{
+			length:       numRuns,
+			dataType:     dataType,
+			defaultValue: defaultValue,
+		},
+	}
+	runs.Allocate(true)
+	var count uint32
+	runs.counts.SetValue(0, unsafe.Pointer(&count))
+	for run := 0; run < numRuns; run++ {
+		count = binary.LittleEndian.Uint32(data[4+run*4:])
+		runs.counts.SetValue(run+1, unsafe.Pointer(&count))
+		runs.nulls.SetBool(run, !hasNulls || data[validityOffset+run] != 0)
+		setRawValue(runs.values, run, getEncodedValue(data[valueOffset+run*valueBytes:], valueBytes))
+	}
+	return runs
+}
+
+// GetRunsHostVectorPartySlice implements GetRunsHostVectorPartySlice in cVectorParty.
+func (vp *cVectorParty) GetRunsHostVectorPartySlice(startRow, endRow int) (common.HostVectorPartySlice, bool) {
+	if vp.runs == nil {
+		return common.HostVectorPartySlice{}, false
+	}
+	startIndex, endIndex := vp.runs.SliceIndex(startRow, endRow)
+	return vp.runs.GetHostVectorPartySlice(startIndex, endIndex-startIndex), true
+}
+
+// encodeDelta encodes values of the vector party as bit packed deltas.
+func (vp *cVectorParty) encodeDelta() []byte {
+	var first, prevValue, maxDelta uint64
+	deltas := make([]uint64, 0, vp.length)
+	vp.forEachEncodedValue(func(row int, valid bool, value uint64) {
+		if row == 0 {
+			first = value
+		} else {
+			delta := zigzagEncode(value - prevValue)
+			if delta > maxDelta {
+				maxDelta = delta
+			}
+			deltas = append(deltas, delta)
+		}
+		prevValue = value
+	})
+
+	bitWidth := bits.Len64(maxDelta)
+	words := make([]uint64, deltaEncodedWords(vp.length, bitWidth))
+	if bitWidth > 0 {
+		for i, delta := range deltas {
+			bitOffset := i * bitWidth
+			word, shift := bitOffset/64, uint(bitOffset%64)
+			words[word] |= delta << shift
+			if int(shift)+bitWidth > 64 {
+				words[word+1] |= delta >> (64 - shift)
+			}
+		}
+	}
+
+	buf := make([]byte, deltaEncodedBytes(vp.length, bitWidth)-4)
+	binary.LittleEndian.PutUint64(buf, first)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(bitWidth))
+	for i, word := range words {
+		binary.LittleEndian.PutUint64(buf[16+i*8:], word)
+	}
+	return buf
+}
+
+// decodeDelta decodes delta encoded bytes into the value vector.
+func decodeDelta(data []byte, values *vectors.Vector, length int) error {
+	if len(data) < 16 {
+		return utils.StackError(nil, "Invalid delta encoded vector party of %d bytes", len(data))
+	}
+	value := binary.LittleEndian.Uint64(data)
+	bitWidth := int(binary.LittleEndian.Uint64(data[8:]))
+	if bitWidth > 64 || len(data) != deltaEncodedBytes(length, bitWidth)-4 {
+		return utils.StackError(nil, "Invalid delta encoded vector party of %d bytes with bit width %d",
+			len(data), bitWidth)
+	}
+
+	if length == 0 {
+		return nil
+	}
+
+	mask := ^uint64(0)
+	if bitWidth < 64 {
+		mask = uint64(1)<<uint(bitWidth) - 1
+	}
+	words := data[16:]
+	setRawValue(values, 0, value)
+	for row := 1; row < length; row++ {
+		var delta uint64
+		if bitWidth > 0 {
+			bitOffset := (row - 1) * bitWidth
+			word, shift := bitOffset/64, uint(bitOffset%64)
+			delta = binary.LittleEndian.Uint64(words[word*8:]) >> shift
+			if int(shift)+bitWidth > 64 {
+				delta |= binary.LittleEndian.Uint64(words[(word+1)*8:]) << (64 - shift)
+			}
+			delta &= mask
+		}
+		value += zigzagDecode(delta)
+		setRawValue(values, row, value)
+	}
+	return nil
+}
+
+// writeEncoded writes vectors of the vector party with the encoding.
+func (vp *cVectorParty) writeEncoded(dataWriter *utils.StreamDataWriter, encoding common.VectorPartyEncoding) error {
+	var data []byte
+	if encoding == common.RunLengthEncoding {
+		data = vp.encodeRunLength()
+	} else {
+		data = vp.encodeDelta()
+	}
+
+	if err := dataWriter.WriteUint32(uint32(len(data))); err != nil {
+		return err
+	}
+
+	if err := dataWriter.Write(data); err != nil {
+		return err
+	}
+
+	if encoding == common.DeltaEncoding && vp.columnMode == common.HasNullVector {
+		return dataWriter.Write(
+			cgoutils.MakeSliceFromCPtr(uintptr(vp.nulls.Buffer()), vp.nulls.Bytes),
+		)
+	}
+	return nil
+}
+
+// readEncoded reads vectors of the vector party encoded with the encoding and decodes them
+// into plain vectors, keeping runs of run length encoded vectors as well.
+func (vp *cVectorParty) readEncoded(dataReader *utils.StreamDataReader, encoding common.VectorPartyEncoding) error {
+	size, err := dataReader.ReadUint32()
+	if err != nil {
+		return err
+	}
+
+	data := make([]byte, size)
+	if err = dataReader.Read(data); err != nil {
+		return err
+	}
+
+	valueVector := vectors.NewVector(vp.dataType, vp.length)
+	var nullVector *vectors.Vector
+	if vp.columnMode == common.HasNullVector {
+		nullVector = vectors.NewVector(common.Bool, vp.length)
+	}
+
+	if encoding == common.RunLengthEncoding {
+		err = decodeRunLength(data, valueVector, nullVector, vp.length)
+	} else {
+		err = decodeDelta(data, valueVector, vp.length)
+		if err == nil && nullVector != nil {
+			err = dataReader.Read(
+				cgoutils.MakeSliceFromCPtr(uintptr(nullVector.Buffer()), nullVector.Bytes),
+			)
+		}
+	}
+
+	if err != nil {
+		valueVector.SafeDestruct()
+		nullVector.SafeDestruct()
+		return err
+	}
+
+	vp.values = valueVector
+	vp.nulls = nullVector
+	if encoding == common.RunLengthEncoding {
+		vp.runs = newRunLengthVectorParty(data, vp.dataType, vp.defaultValue, nullVector != nil)
+	}
+	return nil
+}
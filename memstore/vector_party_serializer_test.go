@@ -26,7 +26,9 @@ import (
 	"fmt"
 	"github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/memstore/vectors"
 	"github.com/uber/aresdb/utils"
+	"sync"
 )
 
 var _ = ginkgo.Describe("vector party serializer", func() {
@@ -127,6 +129,132 @@ var _ = ginkgo.Describe("vector party serializer", func() {
 		Ω(mode3Int8.Equals(newVP)).Should(BeTrue())
 	})
 
+	ginkgo.It("encoded archive vector party should work", func() {
+		createVP := func(dataType common.DataType, length int, fn func(row int) (uint64, bool)) *archiveVectorParty {
+			vp := newArchiveVectorParty(length, dataType, common.NullDataValue, &sync.RWMutex{})
+			vp.Allocate(false)
+			for row := 0; row < length; row++ {
+				value, valid := fn(row)
+				setRawValue(vp.values, row, value)
+				vp.nulls.SetBool(row, valid)
+			}
+			vp.nonDefaultValueCount = length
+			vp.Prune()
+			return vp
+		}
+
+		diskStore.On("OpenVectorPartyFileForRead", table, columnID, shardID, batchID, batchVersion, seqNum).
+			Return(func(string, int, int, int, uint32, uint32) io.ReadCloser {
+				return &utils.ClosableReader{Reader: bytes.NewReader(buf.Bytes())}
+			}, nil)
+
+		for _, testCase := range []struct {
+			vp       *archiveVectorParty
+			encoding common.VectorPartyEncoding
+		}{
+			// sorted enum column with nulls.
+			{createVP(common.SmallEnum, 1000, func(row int) (uint64, bool) {
+				return uint64(row / 100), row%300 != 0
+			}), common.RunLengthEncoding},
+			// time column.
+			{createVP(common.Uint32, 1000, func(row int) (uint64, bool) {
+				return uint64(1500000000 + row*3 + row%2), true
+			}), common.DeltaEncoding},
+			// signed column with small variance and nulls.
+			{createVP(common.Int64, 1000, func(row int) (uint64, bool) {
+				return uint64(int64(row%7 - 3)), row%10 != 0
+			}), common.DeltaEncoding},
+			// random float column.
+			{createVP(common.Float32, 1000, func(row int) (uint64, bool) {
+				return uint64(row * 2654435761), true
+			}), common.PlainEncoding},
+		} {
+			vp := testCase.vp
+			Ω(vp.chooseEncoding()).Should(Equal(testCase.encoding))
+
+			buf.Reset()
+			Ω(serializer.WriteVectorParty(vp)).Should(BeNil())
+			if testCase.encoding != common.PlainEncoding {
+				Ω(buf.Len()).Should(BeNumerically("<", vp.values.Bytes))
+			}
+
+			newVP := &cVectorParty{}
+			Ω(serializer.ReadVectorParty(newVP)).Should(BeNil())
+			Ω(vp.Equals(newVP)).Should(BeTrue())
+			if testCase.encoding == common.RunLengthEncoding {
+				// rows [250, 450) are covered by runs of 2, null, 3 and 4.
+				runs, ok := newVP.GetRunsHostVectorPartySlice(250, 450)
+				Ω(ok).Should(BeTrue())
+				Ω(runs.Length).Should(Equal(4))
+				Ω(newVP.runs.GetMode()).Should(Equal(common.HasCountVector))
+				Ω(newVP.runs.GetDataValue(4).ConvertToHumanReadable(common.SmallEnum)).Should(BeNil())
+				Ω(newVP.runs.GetDataValue(5).ConvertToHumanReadable(common.SmallEnum)).Should(Equal(uint8(3)))
+				Ω(*(*int32)(newVP.runs.counts.GetValue(5))).Should(BeEquivalentTo(301))
+			} else {
+				_, ok := newVP.GetRunsHostVectorPartySlice(0, 1000)
+				Ω(ok).Should(BeFalse())
+			}
+			newVP.SafeDestruct()
+			vp.SafeDestruct()
+		}
+	})
+
+	ginkgo.It("archive vector party should be encoded as the column codec", func() {
+		vp := newArchiveVectorParty(1000, common.Uint32, common.NullDataValue, &sync.RWMutex{})
+		vp.Allocate(false)
+		for row := 0; row < 1000; row++ {
+			setRawValue(vp.values, row, uint64(1500000000+row*3))
+			vp.nulls.SetBool(row, true)
+		}
+		vp.nonDefaultValueCount = 1000
+		vp.Prune()
+		defer vp.SafeDestruct()
+
+		diskStore.On("OpenVectorPartyFileForRead", table, columnID, shardID, batchID, batchVersion, seqNum).
+			Return(func(string, int, int, int, uint32, uint32) io.ReadCloser {
+				return &utils.ClosableReader{Reader: bytes.NewReader(buf.Bytes())}
+			}, nil)
+
+		for codec, encoding := range map[string]common.VectorPartyEncoding{
+			"":                       common.DeltaEncoding,
+			metaCom.ColumnCodecNone:  common.PlainEncoding,
+			metaCom.ColumnCodecZstd:  common.PlainEncoding,
+			metaCom.ColumnCodecRLE:   common.RunLengthEncoding,
+			metaCom.ColumnCodecDelta: common.DeltaEncoding,
+		} {
+			Ω(vp.encodingForCodec(codec)).Should(Equal(encoding))
+
+			buf.Reset()
+			Ω(serializer.WriteVectorParty(codecArchiveVectorParty{vp, codec})).Should(BeNil())
+			newVP := &cVectorParty{}
+			Ω(serializer.ReadVectorParty(newVP)).Should(BeNil())
+			Ω(vp.Equals(newVP)).Should(BeTrue())
+			newVP.SafeDestruct()
+		}
+
+		// forced encodings not applicable to the data type fall back to plain.
+		floatVP := newArchiveVectorParty(10, common.Float32, common.NullDataValue, &sync.RWMutex{})
+		floatVP.Allocate(false)
+		floatVP.nulls.SetAllValid()
+		floatVP.nonDefaultValueCount = 10
+		floatVP.Prune()
+		defer floatVP.SafeDestruct()
+		Ω(floatVP.encodingForCodec(metaCom.ColumnCodecDelta)).Should(Equal(common.PlainEncoding))
+		Ω(floatVP.encodingForCodec(metaCom.ColumnCodecRLE)).Should(Equal(common.RunLengthEncoding))
+	})
+
+	ginkgo.It("decoding corrupted vector party should fail", func() {
+		values := vectors.NewVector(common.Uint16, 10)
+		defer values.SafeDestruct()
+		Ω(decodeRunLength([]byte{1}, values, nil, 10)).ShouldNot(BeNil())
+		// one run ending at row 5 of 10 rows.
+		Ω(decodeRunLength([]byte{1, 0, 0, 0, 5, 0, 0, 0, 1, 0}, values, nil, 10)).ShouldNot(BeNil())
+		Ω(decodeRunLength([]byte{1, 0, 0, 0, 10, 0, 0, 0, 1, 0}, values, nil, 10)).Should(BeNil())
+		Ω(*(*uint16)(values.GetValue(9))).Should(BeEquivalentTo(1))
+		Ω(decodeDelta(make([]byte, 8), values, 10)).ShouldNot(BeNil())
+		Ω(decodeDelta(make([]byte, 16), values, 10)).Should(BeNil())
+	})
+
 	ginkgo.AfterEach(func() {
 	})
 
@@ -21,6 +21,7 @@ import (
 	"github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/utils"
 	"sync"
 )
 
@@ -254,6 +255,69 @@ var _ = ginkgo.Describe("VectorParty", func() {
 
 	})
 
+	ginkgo.It("promoteEnum should work", func() {
+		var defVal uint16 = 1
+		defaultValue := common.DataValue{
+			OtherVal: unsafe.Pointer(&defVal),
+			Valid:    true,
+			DataType: common.BigEnum,
+			CmpFunc:  common.GetCompareFunc(common.BigEnum),
+		}
+
+		vp := cVectorParty{
+			values: vectors.NewVector(common.SmallEnum, 3),
+			nulls:  vectors.NewVector(common.Bool, 3),
+			baseVectorParty: baseVectorParty{
+				dataType: common.SmallEnum,
+				length:   3,
+			},
+			columnMode: common.HasNullVector,
+		}
+		for i, value := range []uint8{0, 2, 255} {
+			vp.values.SetValue(i, unsafe.Pointer(&value))
+			vp.nulls.SetBool(i, i != 1)
+		}
+
+		vp.promoteEnum(defaultValue)
+		Ω(vp.GetDataType()).Should(Equal(common.BigEnum))
+		Ω(vp.values.DataType).Should(Equal(common.BigEnum))
+		Ω(vp.defaultValue.OtherVal).Should(Equal(unsafe.Pointer(&defVal)))
+		Ω(*(*uint16)(vp.GetDataValueByRow(0).OtherVal)).Should(BeEquivalentTo(0))
+		Ω(vp.GetDataValueByRow(1).Valid).Should(BeFalse())
+		Ω(*(*uint16)(vp.GetDataValueByRow(2).OtherVal)).Should(BeEquivalentTo(255))
+
+		// no op for other data types.
+		vp.promoteEnum(common.NullDataValue)
+		Ω(vp.defaultValue.Valid).Should(BeTrue())
+		vp.SafeDestruct()
+	})
+
+	ginkgo.It("reduceHLLPrecision should work", func() {
+		vp := cVectorParty{
+			values: vectors.NewVector(common.Uint32, 2),
+			nulls:  vectors.NewVector(common.Bool, 2),
+			baseVectorParty: baseVectorParty{
+				dataType: common.Uint32,
+				length:   2,
+			},
+			columnMode: common.HasNullVector,
+		}
+		hashes := []uint64{0xf0f0f0f0f0f0f0f0, 8849112093580131862}
+		for i, hash := range hashes {
+			value := utils.ComputeHLLValue(hash)
+			vp.values.SetValue(i, unsafe.Pointer(&value))
+			vp.nulls.SetBool(i, true)
+		}
+
+		Ω(vp.reduceHLLPrecision(6)).Should(BeTrue())
+		for i, hash := range hashes {
+			Ω(*(*uint32)(vp.GetDataValueByRow(i).OtherVal)).Should(Equal(utils.ComputeHLLValueWithPrecision(hash, 6)))
+		}
+		// values are converted only once.
+		Ω(vp.reduceHLLPrecision(6)).Should(BeFalse())
+		vp.SafeDestruct()
+	})
+
 	ginkgo.It("Prune should work", func() {
 
 		var defVal uint32 = 100
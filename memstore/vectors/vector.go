@@ -45,6 +45,9 @@ type Vector struct {
 
 	// **All following fields only works for live batch's vectors.**
 
+	// Whether the buffer is mapped from a file by the owner of the vector instead of allocated in C.
+	mapped bool
+
 	// Min and Max values seen, only used for time columns of fact tables.
 	minValue uint32
 	maxValue uint32
@@ -71,6 +74,22 @@ func NewVector(dataType common.DataType, size int) *Vector {
 	}
 }
 
+// NewMappedVector creates a vector over the buffer mapped from a file, which should be at least
+// CalculateVectorBytes(dataType, size) bytes. The buffer is not freed by SafeDestruct and should be
+// unmapped by the owner after the vector is destructed.
+func NewMappedVector(dataType common.DataType, size int, buffer unsafe.Pointer) *Vector {
+	return &Vector{
+		DataType: dataType,
+		CmpFunc:  common.GetCompareFunc(dataType),
+		unitBits: common.DataTypeBits(dataType),
+		Size:     size,
+		Bytes:    CalculateVectorBytes(dataType, size),
+		buffer:   uintptr(buffer),
+		mapped:   true,
+		minValue: math.MaxUint32,
+	}
+}
+
 // CalculateVectorBytes calculates bytes the vector will occupy given data type and size without actual allocation.
 func CalculateVectorBytes(dataType common.DataType, size int) int {
 	unitBits := common.DataTypeBits(dataType)
@@ -112,9 +131,9 @@ func CalculateVectorPartyBytes(dataType common.DataType, size int, hasNulls bool
 	return bytes
 }
 
-// SafeDestruct destructs this vector's storage space managed in C.
+// SafeDestruct destructs this vector's storage space managed in C. Mapped buffers are left to their owners.
 func (v *Vector) SafeDestruct() {
-	if v != nil {
+	if v != nil && !v.mapped {
 		cgoutils.HostFree(unsafe.Pointer(v.buffer))
 	}
 }
@@ -0,0 +1,87 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"context"
+	"sync"
+
+	"github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// writePositionTracker tracks the position of the last upsert batch applied to a live store
+// and wakes up readers waiting for positions to be applied.
+type writePositionTracker struct {
+	sync.Mutex
+	applied common.WritePosition
+	// closed and replaced whenever applied position advances.
+	advanced chan struct{}
+}
+
+// advance moves the applied position forward to position.
+func (t *writePositionTracker) advance(position common.WritePosition) {
+	t.Lock()
+	defer t.Unlock()
+	if !t.applied.Before(position) {
+		return
+	}
+	t.applied = position
+	if t.advanced != nil {
+		close(t.advanced)
+		t.advanced = nil
+	}
+}
+
+// get returns the applied position.
+func (t *writePositionTracker) get() common.WritePosition {
+	t.Lock()
+	defer t.Unlock()
+	return t.applied
+}
+
+// wait blocks until the position is applied or ctx is done.
+func (t *writePositionTracker) wait(ctx context.Context, position common.WritePosition) error {
+	for {
+		t.Lock()
+		if !t.applied.Before(position) {
+			t.Unlock()
+			return nil
+		}
+		if t.advanced == nil {
+			t.advanced = make(chan struct{})
+		}
+		advanced := t.advanced
+		applied := t.applied
+		t.Unlock()
+
+		select {
+		case <-advanced:
+		case <-ctx.Done():
+			return utils.StackError(ctx.Err(), "write position %s not applied, applied %s", position, applied)
+		}
+	}
+}
+
+// GetWritePosition returns the position of the last upsert batch applied to the shard.
+func (shard *TableShard) GetWritePosition() common.WritePosition {
+	return shard.LiveStore.writePosition.get()
+}
+
+// WaitForWritePosition blocks until upsert batches up to the position are applied to the
+// shard so that queries can read them, or until ctx is done.
+func (shard *TableShard) WaitForWritePosition(ctx context.Context, position common.WritePosition) error {
+	return shard.LiveStore.writePosition.wait(ctx, position)
+}
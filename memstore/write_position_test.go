@@ -0,0 +1,55 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/memstore/common"
+)
+
+var _ = ginkgo.Describe("write position tracker", func() {
+	ginkgo.It("should wait until positions are applied", func() {
+		tracker := writePositionTracker{}
+		tracker.advance(common.WritePosition{RedoLogFile: 1, Offset: 2})
+		Ω(tracker.get()).Should(Equal(common.WritePosition{RedoLogFile: 1, Offset: 2}))
+		Ω(tracker.wait(context.Background(), common.WritePosition{RedoLogFile: 1, Offset: 1})).Should(BeNil())
+
+		done := make(chan error)
+		go func() {
+			done <- tracker.wait(context.Background(), common.WritePosition{RedoLogFile: 2, Offset: 0})
+		}()
+		tracker.advance(common.WritePosition{RedoLogFile: 1, Offset: 3})
+		Consistently(done, 10*time.Millisecond).ShouldNot(Receive())
+		tracker.advance(common.WritePosition{RedoLogFile: 2, Offset: 1})
+		Eventually(done).Should(Receive(BeNil()))
+
+		// positions never move backward.
+		tracker.advance(common.WritePosition{RedoLogFile: 1, Offset: 0})
+		Ω(tracker.get()).Should(Equal(common.WritePosition{RedoLogFile: 2, Offset: 1}))
+	})
+
+	ginkgo.It("should stop waiting when context is done", func() {
+		tracker := writePositionTracker{}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := tracker.wait(ctx, common.WritePosition{RedoLogFile: 1})
+		Ω(err).ShouldNot(BeNil())
+		Ω(err.Error()).Should(ContainSubstring("write position 1:0 not applied"))
+	})
+})
@@ -0,0 +1,108 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"bufio"
+	"os"
+
+	"github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// writeZoneMaps builds zone maps of numeric columns and writes them along with the vector parties
+// of the batch. Encrypted columns are skipped as zone map files are not encrypted. Like WriteToDisk,
+// it is only called for newly merged batches so there is no need to lock it.
+func (b *ArchiveBatch) writeZoneMaps() error {
+	b.Shard.Schema.RLock()
+	columns := b.Shard.Schema.Schema.Columns
+	sortColumns := b.Shard.Schema.Schema.ArchivingSortColumns
+	dataTypes := b.Shard.Schema.ValueTypeByColumn
+	b.Shard.Schema.RUnlock()
+
+	zoneMaps := make(map[int]*common.ZoneMap)
+	for columnID, dataType := range dataTypes {
+		if columnID >= len(b.Columns) || columnID >= len(columns) || b.Columns[columnID] == nil || !common.IsZoneMapType(dataType) ||
+			columns[columnID].Deleted || columns[columnID].Encrypted {
+			continue
+		}
+		zoneMap := &common.ZoneMap{}
+		b.forEachValue(columnID, utils.IndexOfInt(sortColumns, columnID) >= 0, func(row int, value common.DataValue) {
+			zoneMap.Update(value, dataType)
+		})
+		zoneMaps[columnID] = zoneMap
+	}
+	if len(zoneMaps) == 0 {
+		return nil
+	}
+
+	if err := b.writeZoneMapFile(zoneMaps); err != nil {
+		return utils.StackError(err, "failed to write zone maps for batch %d of table %s shard %d",
+			b.BatchID, b.Shard.Schema.Schema.Name, b.Shard.ShardID)
+	}
+	b.zoneMaps = zoneMaps
+	b.zoneMapsLoaded = true
+	return nil
+}
+
+func (b *ArchiveBatch) writeZoneMapFile(zoneMaps map[int]*common.ZoneMap) error {
+	writer, err := b.Shard.diskStore.OpenBatchZoneMapFileForWrite(b.Shard.Schema.Schema.Name,
+		b.Shard.ShardID, int(b.BatchID), b.Version, b.SeqNum)
+	if err != nil {
+		return err
+	}
+	bufferedWriter := bufio.NewWriter(writer)
+	if err = common.WriteZoneMaps(bufferedWriter, zoneMaps); err == nil {
+		err = bufferedWriter.Flush()
+	}
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// RequestZoneMap returns the zone map of the column in this batch version, loading zone maps of
+// all columns from disk on first request. Nil is returned if the batch version has no zone map
+// of the column, e.g. it was archived before zone maps were introduced or fetched from a peer.
+func (b *ArchiveBatch) RequestZoneMap(columnID int) *common.ZoneMap {
+	b.Lock()
+	defer b.Unlock()
+	if !b.zoneMapsLoaded {
+		zoneMaps, err := b.readZoneMaps()
+		if err != nil {
+			// queries fall back to scanning the batch.
+			utils.GetLogger().With(
+				"table", b.Shard.Schema.Schema.Name,
+				"shard", b.Shard.ShardID,
+				"batchID", b.BatchID,
+				"error", err.Error()).Warn("failed to read batch zone maps")
+		}
+		b.zoneMaps = zoneMaps
+		b.zoneMapsLoaded = true
+	}
+	return b.zoneMaps[columnID]
+}
+
+func (b *ArchiveBatch) readZoneMaps() (map[int]*common.ZoneMap, error) {
+	reader, err := b.Shard.diskStore.OpenBatchZoneMapFileForRead(b.Shard.Schema.Schema.Name,
+		b.Shard.ShardID, int(b.BatchID), b.Version, b.SeqNum)
+	if err == os.ErrNotExist {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return common.ReadZoneMaps(bufio.NewReader(reader))
+}
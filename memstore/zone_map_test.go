@@ -0,0 +1,145 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memstore
+
+import (
+	"os"
+	"sync"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	diskStoreMocks "github.com/uber/aresdb/diskstore/mocks"
+	memCom "github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/memstore/tests"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	testingUtils "github.com/uber/aresdb/testing"
+)
+
+var _ = ginkgo.Describe("zone map", func() {
+	table := "table1"
+	shardID := 0
+	batchID := 100
+	var cutoff uint32 = 200
+
+	newShard := func(ds *diskStoreMocks.DiskStore) *TableShard {
+		return &TableShard{
+			diskStore: ds,
+			ShardID:   shardID,
+			Schema: &memCom.TableSchema{
+				Schema: metaCom.Table{
+					Name: table,
+					Columns: []metaCom.Column{
+						{Name: "time", Type: metaCom.Uint32},
+						{Name: "fare", Type: metaCom.Float32},
+						{Name: "tip", Type: metaCom.Int16, Encrypted: true},
+						{Name: "status", Type: metaCom.SmallEnum},
+						{Name: "distance", Type: metaCom.Int32},
+					},
+					ArchivingSortColumns: []int{0},
+				},
+				ValueTypeByColumn: []memCom.DataType{memCom.Uint32, memCom.Float32, memCom.Int16, memCom.SmallEnum, memCom.Int32},
+			},
+		}
+	}
+
+	newVectorParty := func(rvp tests.RawVectorParty, locker sync.Locker) memCom.VectorParty {
+		vp, err := toVectorParty(&rvp, false)
+		Ω(err).Should(BeNil())
+		return toArchiveVectorParty(vp, locker)
+	}
+
+	ginkgo.It("WriteToDisk should write zone maps of numeric columns", func() {
+		ds := new(diskStoreMocks.DiskStore)
+		lock := &sync.RWMutex{}
+		batch := &ArchiveBatch{
+			Batch: memCom.Batch{
+				RWMutex: lock,
+				Columns: []memCom.VectorParty{
+					// sort column with counts: 100, 100, 150, 150, 150.
+					newVectorParty(tests.RawVectorParty{
+						DataType: "Uint32", Length: 2, HasCounts: true,
+						Values: []string{"100,2", "150,5"},
+					}, lock),
+					newVectorParty(tests.RawVectorParty{
+						DataType: "Float32", Length: 5,
+						Values: []string{"1.5", "null", "-2.5", "3", "0"},
+					}, lock),
+					newVectorParty(tests.RawVectorParty{
+						DataType: "Int16", Length: 5,
+						Values: []string{"1", "2", "3", "4", "5"},
+					}, lock),
+					newVectorParty(tests.RawVectorParty{
+						DataType: "SmallEnum", Length: 5,
+						Values: []string{"1", "2", "3", "4", "5"},
+					}, lock),
+					newVectorParty(tests.RawVectorParty{
+						DataType: "Int32", Length: 5,
+						Values: []string{"null", "null", "null", "null", "null"},
+					}, lock),
+				},
+			},
+			Size:    5,
+			Version: cutoff,
+			BatchID: int32(batchID),
+			Shard:   newShard(ds),
+		}
+
+		vpWriter := &testingUtils.TestReadWriteCloser{}
+		for columnID := range batch.Columns {
+			ds.On("OpenVectorPartyFileForWrite", table, columnID, shardID, batchID, cutoff, uint32(0)).
+				Return(vpWriter, nil)
+		}
+		zoneMapFile := &testingUtils.TestReadWriteCloser{}
+		ds.On("OpenBatchZoneMapFileForWrite", table, shardID, batchID, cutoff, uint32(0)).
+			Return(zoneMapFile, nil).Once()
+		Ω(batch.WriteToDisk()).Should(BeNil())
+
+		expected := map[int]*memCom.ZoneMap{
+			0: {HasValues: true, MinInt: 100, MaxInt: 150},
+			1: {HasValues: true, MinFloat: -2.5, MaxFloat: 3},
+			4: {},
+		}
+		Ω(batch.zoneMaps).Should(Equal(expected))
+
+		// zone maps are loaded from disk by other batch objects of the same version.
+		ds.On("OpenBatchZoneMapFileForRead", table, shardID, batchID, cutoff, uint32(0)).
+			Return(zoneMapFile, nil).Once()
+		loaded := &ArchiveBatch{
+			Batch:   memCom.Batch{RWMutex: &sync.RWMutex{}},
+			Size:    5,
+			Version: cutoff,
+			BatchID: int32(batchID),
+			Shard:   batch.Shard,
+		}
+		Ω(loaded.RequestZoneMap(0)).Should(Equal(expected[0]))
+		// loaded once for all columns.
+		Ω(loaded.RequestZoneMap(1)).Should(Equal(expected[1]))
+		Ω(loaded.RequestZoneMap(2)).Should(BeNil())
+		Ω(loaded.RequestZoneMap(3)).Should(BeNil())
+
+		// batches without zone maps are scanned.
+		ds.On("OpenBatchZoneMapFileForRead", table, shardID, batchID+1, cutoff, uint32(0)).
+			Return(nil, os.ErrNotExist).Once()
+		loaded = &ArchiveBatch{
+			Batch:   memCom.Batch{RWMutex: &sync.RWMutex{}},
+			Version: cutoff,
+			BatchID: int32(batchID + 1),
+			Shard:   batch.Shard,
+		}
+		Ω(loaded.RequestZoneMap(0)).Should(BeNil())
+		Ω(loaded.RequestZoneMap(1)).Should(BeNil())
+		ds.AssertExpectations(ginkgo.GinkgoT())
+	})
+})
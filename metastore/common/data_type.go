@@ -17,6 +17,9 @@ package common
 const (
 	// EnumDelimiter
 	EnumDelimiter = "\u0000\n"
+	// IngestionSourceColumnName is the name of the hidden enum column holding the ingestion source
+	// of each row for tables tracking ingestion source.
+	IngestionSourceColumnName = "__source"
 )
 
 // string representations of data types
@@ -35,6 +38,9 @@ const (
 	GeoPoint  = "GeoPoint"
 	GeoShape  = "GeoShape"
 	Int64     = "Int64"
+	String    = "String"
+	Decimal   = "Decimal"
+	Time64    = "Time64"
 
 	// array types
 	ArrayBool      = "Bool[]"
@@ -50,4 +56,17 @@ const (
 	ArrayUUID      = "UUID[]"
 	ArrayGeoPoint  = "GeoPoint[]"
 	ArrayInt64     = "Int64[]"
+
+	// map types, keys are strings in the enum dictionary of the column.
+	MapInt8    = "Map<String,Int8>"
+	MapUint8   = "Map<String,Uint8>"
+	MapInt16   = "Map<String,Int16>"
+	MapUint16  = "Map<String,Uint16>"
+	MapInt32   = "Map<String,Int32>"
+	MapUint32  = "Map<String,Uint32>"
+	MapFloat32 = "Map<String,Float32>"
+	MapInt64   = "Map<String,Int64>"
+
+	// vector types, fixed width arrays of embeddings for similarity search.
+	VectorFloat32 = "Vector<Float32>"
 )
@@ -31,6 +31,8 @@ var (
 	ErrColumnAlreadyDeleted = errors.New("Column already deleted")
 	// ErrNotEnumColumn indicates Column is not enum type
 	ErrNotEnumColumn = errors.New("Column is not enum type")
+	// ErrNotSmallEnumColumn indicates Column is not small enum type
+	ErrNotSmallEnumColumn = errors.New("Column is not small enum type")
 	// ErrEnumCardinalityOverflow indicates invalid enum extension over cardinality limit
 	ErrEnumCardinalityOverflow = errors.New("Enum column cardinality exceeds limit")
 	// ErrShardDoesNotExist indicates Shard does not exist
@@ -77,10 +79,29 @@ var (
 	// ErrTimeColumnDoesNotAllowHLLConfig indicates hll configured for time column
 	ErrTimeColumnDoesNotAllowHLLConfig   = errors.New("HLLConfig not allowed for time column")
 	ErrHLLColumnDoesNotAllowDefaultValue = errors.New("hll column does not allow default value")
+	ErrInvalidHLLPrecision               = errors.New("HLL precision is only allowed for hll columns and must be between 4 and 14")
+	ErrHLLPrecisionIncrease              = errors.New("HLL precision can only be reduced")
 	ErrInvalidTableBatchSize             = errors.New("Table batch size should be larger than zero")
 	ErrInvalidPrimaryKeyBucketSize       = errors.New("Table primary key bucket size should be larger than zero")
 	ErrInvalidPrimaryKeyDataType         = errors.New("Specified data type can not be used as primary key")
 	ErrInvalidSortColumnDataType         = errors.New("Specified data type can not be used as sorting column")
+	ErrInvalidValidityColumn             = errors.New("Validity columns must be uint32 columns of dimension table")
+	ErrValidFromColumnNotInPrimaryKey    = errors.New("Valid from column must be the last primary key column")
+	ErrInvalidEnumNormalization          = errors.New("Enum normalization is only allowed for enum columns")
+	ErrInvalidIngestionSourceColumn      = errors.New("Tables tracking ingestion source must have a __source enum column")
+	ErrInvalidStringColumn               = errors.New("String columns are only allowed in dimension tables")
+	ErrInvalidDecimalScale               = errors.New("Decimal scale is only allowed for Decimal columns and must be between 0 and 18")
+	ErrInvalidCompressionLevel           = errors.New("Compression level must be between -1 and 22")
+	ErrIndexOnNonEnumColumn              = errors.New("Index hint is only allowed for enum and vector columns")
+	ErrInvalidColumnCodec                = errors.New("Column codec is unknown or not supported by the data type")
+	ErrMapColumnDoesNotAllowDefaultValue = errors.New("map column does not allow default value")
+	ErrInvalidVectorDimension            = errors.New("Vector dimension is required for Vector columns only and must be between 1 and 4096")
+	ErrInvalidVectorIndexLists           = errors.New("Vector index lists are only allowed for Vector columns and must be between 0 and 4096")
+	ErrVectorColumnDoesNotAllowDefault   = errors.New("vector column does not allow default value")
+	// ErrInvalidLegalHold indicates the batch range of a legal hold is empty or negative
+	ErrInvalidLegalHold = errors.New("Legal hold must cover a non empty range of non negative batch ids")
+	// ErrLegalHoldDoesNotExist indicates no legal hold is placed on the batch range
+	ErrLegalHoldDoesNotExist = errors.New("Legal hold does not exist")
 	// ErrMaxEnumIDReached indicates a column has already reached its maximum enum id
 	// eg. SmallEnum: 255, BigEnum: 65535
 	ErrMaxEnumIDReached = errors.New("Maximum enum id reached")
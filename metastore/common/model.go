@@ -14,6 +14,8 @@
 
 package common
 
+import "strings"
+
 // ColumnConfig defines the schema of a column config that can be mutated by
 // UpdateColumn API call.
 // swagger:model columnConfig
@@ -33,8 +35,37 @@ type ColumnConfig struct {
 	//     High number implies high priority.
 	PreloadingDays int   `json:"preloadingDays,omitempty"`
 	Priority       int64 `json:"priority,omitempty"`
+	// CompressionLevel is the zstd level to compress vector party files of the
+	// column on disk when compression is enabled or the codec is zstd, 0 for the
+	// default level and -1 to keep files uncompressed. Files written before the
+	// change stay readable.
+	CompressionLevel int `json:"compressionLevel,omitempty"`
+	// Codec chooses how archive vector party files of the column are encoded:
+	// "none" for plain vectors, "rle" or "delta" to force run length or delta
+	// encoding, "zstd" for plain vectors compressed with zstd on disk even if
+	// compression is disabled for the disk store. Empty picks the encoding
+	// taking the least bytes for each batch.
+	Codec string `json:"codec,omitempty"`
+	// Indexed hints that the enum column is frequently filtered on. Archiving builds
+	// per batch bitmap indexes of the column so that queries can skip archive batches
+	// without matching rows before transferring them to the device.
+	// For vector columns, archiving builds per batch IVF indexes of the column so that
+	// nearest_neighbors only compares the query vector with rows of the closest clusters.
+	Indexed bool `json:"indexed,omitempty"`
+	// VectorIndexLists is the number of clusters of IVF indexes of the vector column,
+	// 0 for the square root of the batch size. Batches archived before the change keep
+	// their indexes.
+	VectorIndexLists int `json:"vectorIndexLists,omitempty"`
 }
 
+// Column codecs configurable in ColumnConfig.
+const (
+	ColumnCodecNone  = "none"
+	ColumnCodecRLE   = "rle"
+	ColumnCodecDelta = "delta"
+	ColumnCodecZstd  = "zstd"
+)
+
 // Column defines the schema of a column from MetaStore.
 // swagger:model column
 type Column struct {
@@ -57,18 +88,73 @@ type Column struct {
 	// Whether disable enum cases auto expansion.
 	DisableAutoExpand bool `json:"disableAutoExpand,omitempty"`
 
+	// Normalization applied to enum strings by ingestion client before dictionary lookup.
+	// Mutable, enum cases added before the change are not renormalized.
+	EnumNormalization *EnumNormalization `json:"enumNormalization,omitempty"`
+
 	// Mutable column configs.
 	Config ColumnConfig `json:"config,omitempty"`
 
 	// HLLEnabled determines whether a column is enabled for hll cardinality estimation
-	// HLLConfig is immutable
+	// HLLConfig is immutable except that the precision can be reduced
 	HLLConfig HLLConfig `json:"hllConfig,omitempty"`
+
+	// Whether to encrypt archived and snapshot vector party files of the column on disk.
+	// Files written before the change stay readable.
+	Encrypted bool `json:"encrypted,omitempty"`
+
+	// Number of fractional digits of Decimal columns, values are stored as int64 scaled
+	// by 10^DecimalScale. Immutable.
+	DecimalScale int `json:"decimalScale,omitempty"`
+
+	// Number of elements of each value of Vector columns. Immutable.
+	VectorDimension int `json:"vectorDimension,omitempty"`
+}
+
+// EnumNormalization defines the rules to normalize enum strings at ingestion.
+// swagger:model enumNormalization
+type EnumNormalization struct {
+	// Whether to trim leading and trailing white spaces.
+	Trim bool `json:"trim,omitempty"`
+	// Whether to convert to lower case.
+	Lowercase bool `json:"lowercase,omitempty"`
+	// Maps trimmed and case folded values to their canonical values, eg "sf" to "San Francisco".
+	Mappings map[string]string `json:"mappings,omitempty"`
+}
+
+// Normalize applies the normalization rules to the enum string, it also returns whether
+// the value is remapped by the mapping table.
+func (n *EnumNormalization) Normalize(value string) (normalized string, remapped bool) {
+	if n == nil {
+		return value, false
+	}
+	if n.Trim {
+		value = strings.TrimSpace(value)
+	}
+	if n.Lowercase {
+		value = strings.ToLower(value)
+	}
+	if mapped, ok := n.Mappings[value]; ok {
+		return mapped, true
+	}
+	return value, false
 }
 
 // HLLConfig defines hll configuration
 // swagger:model hllConfig
 type HLLConfig struct {
 	IsHLLColumn bool `json:"isHLLColumn,omitempty"`
+	// Number of bits of the hash used as register index, 0 means DefaultHLLPrecision. It can only
+	// be reduced as archived hll values are recomputed from values of higher precision.
+	Precision int `json:"precision,omitempty"`
+}
+
+// GetPrecision returns the number of bits of the hash used as register index.
+func (c HLLConfig) GetPrecision() int {
+	if c.Precision == 0 {
+		return DefaultHLLPrecision
+	}
+	return c.Precision
 }
 
 // TableConfig defines the table configurations that can be changed
@@ -83,6 +169,12 @@ type TableConfig struct {
 	// Size of each live batch, should be sufficiently large.
 	BatchSize int `json:"batchSize,omitempty" validate:"min=1"`
 
+	// Lower bound of the size of live batches. When set, live batches are sized between
+	// MinBatchSize and BatchSize based on the ingestion rate and host memory pressure, and
+	// undersized batches of dimension tables are merged by the snapshot job. 0 disables
+	// adaptive sizing.
+	MinBatchSize int `json:"minBatchSize,omitempty" validate:"min=0"`
+
 	// Specifies how often to create a new redo log file.
 	RedoLogRotationInterval int `json:"redoLogRotationInterval,omitempty" validate:"min=1"`
 
@@ -108,6 +200,11 @@ type TableConfig struct {
 	// Size of each live batch used by backfill job.
 	BackfillStoreBatchSize int `json:"backfillStoreBatchSize,omitempty" validate:"min=1"`
 
+	// Upper limit in MB of host memory held by archive batch columns of the table on each host,
+	// coldest columns beyond the limit are spilled to disk and paged back on access through mmap.
+	// 0 means unlimited.
+	MemoryBudgetMB int64 `json:"memoryBudgetMB,omitempty" validate:"min=0"`
+
 	// Records with timestamp older than now - RecordRetentionInDays will be skipped
 	// during ingestion and backfill. 0 means unlimited days.
 	RecordRetentionInDays int `json:"recordRetentionInDays,omitempty" validate:"min=0"`
@@ -120,7 +217,31 @@ type TableConfig struct {
 	// Specifies how often snapshot runs.
 	SnapshotIntervalMinutes int `json:"snapshotIntervalMinutes,omitempty" validate:"min=1"`
 
+	// Specifies how often compaction merging sparse live batches and reclaiming rows no longer
+	// referenced by the primary key runs, 0 to disable compaction.
+	CompactionIntervalMinutes int `json:"compactionIntervalMinutes,omitempty" validate:"min=0"`
+
+	// Names of the uint32 columns holding the validity range [validFrom, validTo) of each
+	// version for slowly changing dimension tables. Fact tables joining such table will join to the
+	// version valid at the event time of the fact row. validFrom column must be the last primary key column,
+	// validTo is optional and each version will be valid until next version if not specified.
+	ValidFromColumn string `json:"validFromColumn,omitempty"`
+	ValidToColumn   string `json:"validToColumn,omitempty"`
+
 	AllowMissingEventTime bool `json:"allowMissingEventTime,omitempty"`
+
+	// Weight of queries against this table when queries are queued waiting for device.
+	// Queries of each table and origin get a share of devices proportional to the weight
+	// multiplied by the weight of the origin in query config, 0 means the default weight 1.
+	QueryWeight int `json:"queryWeight,omitempty" validate:"min=0"`
+
+	// Whether each ingested row is stamped with its ingestion source (the ingestion job name) in the
+	// enum column named IngestionSourceColumnName, so bad data can be traced back to its source.
+	TrackIngestionSource bool `json:"trackIngestionSource,omitempty"`
+
+	// Whether exact row counts of each day are maintained in metadata during archiving, so that
+	// count(*) by day with only a time filter on archived data is answered without scanning batches.
+	PreAggregateDailyRowCounts bool `json:"preAggregateDailyRowCounts,omitempty"`
 }
 
 // Table defines the schema and configurations of a table from MetaStore.
@@ -138,6 +259,9 @@ type Table struct {
 	// table configurations
 	Config TableConfig `json:"config"`
 
+	// Read permissions of the table and its columns, nil means no restriction.
+	ACL *TableACL `json:"acl,omitempty"`
+
 	// Fact table only.
 	// IDs of columns to sort based upon.
 	ArchivingSortColumns []int `json:"archivingSortColumns,omitempty"`
@@ -150,6 +274,56 @@ type Table struct {
 	Version int `json:"version"`
 }
 
+// TableACL defines the read permissions of a table and its columns. Principals are
+// matched by their name or any group they belong to. Values of restricted columns are
+// never masked or redacted: queries of other principals referencing a restricted column
+// are rejected with StatusForbidden, and restricted columns are left out when expanding
+// select * for them.
+type TableACL struct {
+	// Principals allowed to read the table, empty means everyone.
+	Readers []string `json:"readers,omitempty"`
+	// Restricted columns and principals allowed to read them.
+	RestrictedColumns map[string][]string `json:"restrictedColumns,omitempty"`
+}
+
+// CanRead tells whether a principal with given identities can read the table.
+func (acl *TableACL) CanRead(identities []string) bool {
+	if acl == nil || len(acl.Readers) == 0 {
+		return true
+	}
+	return matchesAny(acl.Readers, identities)
+}
+
+// CanReadColumn tells whether a principal with given identities can read the column.
+func (acl *TableACL) CanReadColumn(column string, identities []string) bool {
+	if acl == nil {
+		return true
+	}
+	allowed, restricted := acl.RestrictedColumns[column]
+	return !restricted || matchesAny(allowed, identities)
+}
+
+func matchesAny(allowed, identities []string) bool {
+	for _, a := range allowed {
+		for _, identity := range identities {
+			if a == identity {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TracksIngestionSource tells whether rows of the table are stamped with their ingestion source.
+func (t *Table) TracksIngestionSource() bool {
+	return t.Config.TrackIngestionSource
+}
+
+// IsSlowlyChangingDimension tells whether the table is a dimension table with validity ranges.
+func (t *Table) IsSlowlyChangingDimension() bool {
+	return !t.IsFactTable && t.Config.ValidFromColumn != ""
+}
+
 // IsEnumColumn checks whether a column is enum column
 func (c *Column) IsEnumColumn() bool {
 	return c.Type == BigEnum || c.Type == SmallEnum
@@ -160,16 +334,39 @@ func (c *Column) IsEnumArrayColumn() bool {
 	return c.Type == ArrayBigEnum || c.Type == ArraySmallEnum
 }
 
+// IsMapColumn checks whether a column is of map column. Keys of map columns
+// are maintained as enum cases of the column.
+func (c *Column) IsMapColumn() bool {
+	switch c.Type {
+	case MapInt8, MapUint8, MapInt16, MapUint16, MapInt32, MapUint32, MapFloat32, MapInt64:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsVectorColumn checks whether a column is of vector column, whose values are
+// arrays of VectorDimension elements.
+func (c *Column) IsVectorColumn() bool {
+	return c.Type == VectorFloat32
+}
+
+// IsTime64Column checks whether a column is of Time64 column, whose values are
+// milliseconds since epoch.
+func (c *Column) IsTime64Column() bool {
+	return c.Type == Time64
+}
+
 // IsEnumBasedColumn checks whether a column whose value is enum based
-// including both simple enum columns and arry enum columns
+// including simple enum columns, arry enum columns and map columns
 func (c *Column) IsEnumBasedColumn() bool {
-	return c.IsEnumArrayColumn() || c.IsEnumColumn()
+	return c.IsEnumArrayColumn() || c.IsEnumColumn() || c.IsMapColumn()
 }
 
 // IsOverwriteOnlyDataType checks whether a column is overwrite only
 func (c *Column) IsOverwriteOnlyDataType() bool {
 	switch c.Type {
-	case Uint8, Int8, Uint16, Int16, Uint32, Int32, Float32, Int64:
+	case Uint8, Int8, Uint16, Int16, Uint32, Int32, Float32, Int64, Decimal, Time64:
 		return false
 	default:
 		return true
@@ -179,7 +376,7 @@ func (c *Column) IsOverwriteOnlyDataType() bool {
 // EnumCardinality returns cardinality for enum type
 func EnumCardinality(columnType string) int {
 	switch columnType {
-	case SmallEnum:
+	case SmallEnum, MapInt8, MapUint8, MapInt16, MapUint16, MapInt32, MapUint32, MapFloat32, MapInt64:
 		return 1 << 8
 	case BigEnum:
 		return 1 << 16
@@ -188,6 +385,101 @@ func EnumCardinality(columnType string) int {
 	}
 }
 
+// Limits of hll precision.
+const (
+	// DefaultHLLPrecision is the default and max number of bits of the hash used as register index.
+	DefaultHLLPrecision = 14
+	// MinHLLPrecision is the min number of bits of the hash used as register index.
+	MinHLLPrecision = 4
+)
+
+// Limits of vector columns.
+const (
+	// MaxVectorDimension is the max number of elements of vector column values.
+	MaxVectorDimension = 4096
+	// MaxVectorIndexLists is the max number of clusters of IVF indexes of vector columns.
+	MaxVectorIndexLists = 4096
+)
+
+// EnumCapacityAlertRatio is the ratio of used enum cases to the cardinality of the column
+// beyond which we alert that the enum dictionary is running out of capacity.
+const EnumCapacityAlertRatio = 0.9
+
+// IsEnumPromotion tells whether changing the column type from oldType to newType promotes
+// a small enum column to a big enum column, which is the only type change allowed.
+func IsEnumPromotion(oldType, newType string) bool {
+	return oldType == SmallEnum && newType == BigEnum
+}
+
+// LegalHold exempts archive batches of a fact table within [BatchIDStart, BatchIDEnd) from
+// being purged regardless of the retention of the table. Batch IDs are days since epoch.
+type LegalHold struct {
+	BatchIDStart int `json:"batchIDStart"`
+	BatchIDEnd   int `json:"batchIDEnd"`
+	// Why the batches are held, eg. the case number.
+	Reason string `json:"reason,omitempty"`
+	// Unix seconds when the hold was placed.
+	PlacedAt int64 `json:"placedAt"`
+}
+
+// Holds tells whether the batch is held.
+func (h LegalHold) Holds(batchID int) bool {
+	return batchID >= h.BatchIDStart && batchID < h.BatchIDEnd
+}
+
+// DailyRowCounts are the numbers of rows of each day of a fact table shard archived before
+// ArchivingCutoff, keyed by archive batch ID which is days since epoch.
+type DailyRowCounts struct {
+	ArchivingCutoff uint32        `json:"archivingCutoff"`
+	Counts          map[int32]int `json:"counts"`
+}
+
+// DroppedEnumID marks enum cases dropped by an enum dictionary compaction in Remap.
+const DroppedEnumID = -1
+
+// RedoLogPosition is the position of an upsert batch in redo logs.
+type RedoLogPosition struct {
+	RedoLogFile int64  `json:"redoLogFile"`
+	Offset      uint32 `json:"offset"`
+}
+
+// NotAfter tells whether the position is before or at other.
+func (p RedoLogPosition) NotAfter(other RedoLogPosition) bool {
+	return p.RedoLogFile < other.RedoLogFile || (p.RedoLogFile == other.RedoLogFile && p.Offset <= other.Offset)
+}
+
+// ArchiveBatchVersion identifies a version of an archive batch.
+type ArchiveBatchVersion struct {
+	Version uint32 `json:"version"`
+	SeqNum  uint32 `json:"seqNum"`
+}
+
+// EnumDictCompaction records an enum dictionary compaction that dropped cases no longer
+// referenced by any data. Data written before the compaction still stores the old enum IDs
+// and needs to be remapped when loaded: upsert batches in redo logs up to RedoLogPositions,
+// snapshots up to SnapshotPositions and archive batches of ArchiveBatchVersions.
+type EnumDictCompaction struct {
+	// Unix seconds when the dictionary was compacted.
+	CompactedAt int64 `json:"compactedAt"`
+	// Remap maps old enum IDs to new enum IDs, DroppedEnumID for dropped cases.
+	Remap []int `json:"remap"`
+	// Position of the last upsert batch applied by each shard before compaction.
+	RedoLogPositions map[int]RedoLogPosition `json:"redoLogPositions,omitempty"`
+	// Position of the last snapshot of each shard of dimension tables before compaction.
+	SnapshotPositions map[int]RedoLogPosition `json:"snapshotPositions,omitempty"`
+	// Versions of archive batches of each shard of fact tables before compaction.
+	ArchiveBatchVersions map[int]map[int32]ArchiveBatchVersion `json:"archiveBatchVersions,omitempty"`
+}
+
+// MapID returns the new enum ID of the old enum ID. IDs of cases added after the compaction
+// are returned as is.
+func (c EnumDictCompaction) MapID(id int) int {
+	if id < 0 || id >= len(c.Remap) {
+		return id
+	}
+	return c.Remap[id]
+}
+
 // ShardOwnership defines an instruction on whether the receiving instance
 // should start to own or disown the specified table shard.
 type ShardOwnership struct {
@@ -30,6 +30,15 @@ var _ = ginkgo.Describe("model tests", func() {
 		Ω(c.IsEnumColumn()).Should(BeFalse())
 		Ω(c.IsEnumBasedColumn()).Should(BeTrue())
 		Ω(c.IsEnumArrayColumn()).Should(BeTrue())
+		Ω(c.IsMapColumn()).Should(BeFalse())
+
+		for _, mapType := range []string{MapInt8, MapUint8, MapInt16, MapUint16, MapInt32, MapUint32, MapFloat32, MapInt64} {
+			c.Type = mapType
+			Ω(c.IsMapColumn()).Should(BeTrue())
+			Ω(c.IsEnumBasedColumn()).Should(BeTrue())
+			Ω(c.IsEnumArrayColumn()).Should(BeFalse())
+			Ω(EnumCardinality(mapType)).Should(Equal(256))
+		}
 	})
 
 	ginkgo.It("EnumCardinality should work", func() {
@@ -37,4 +46,43 @@ var _ = ginkgo.Describe("model tests", func() {
 		Ω(EnumCardinality(BigEnum)).Should(Equal(65536))
 		Ω(EnumCardinality(UUID)).Should(Equal(0))
 	})
+
+	ginkgo.It("TableACL should work", func() {
+		var acl *TableACL
+		Ω(acl.CanRead(nil)).Should(BeTrue())
+		Ω(acl.CanReadColumn("c", nil)).Should(BeTrue())
+
+		acl = &TableACL{
+			Readers:           []string{"alice", "analysts"},
+			RestrictedColumns: map[string][]string{"email": {"admins"}},
+		}
+		Ω(acl.CanRead([]string{"alice"})).Should(BeTrue())
+		Ω(acl.CanRead([]string{"bob", "analysts"})).Should(BeTrue())
+		Ω(acl.CanRead([]string{"bob"})).Should(BeFalse())
+		Ω(acl.CanReadColumn("city", []string{"bob"})).Should(BeTrue())
+		Ω(acl.CanReadColumn("email", []string{"alice", "analysts"})).Should(BeFalse())
+		Ω(acl.CanReadColumn("email", []string{"carol", "admins"})).Should(BeTrue())
+	})
+
+	ginkgo.It("EnumNormalization should work", func() {
+		var n *EnumNormalization
+		Ω(n.Normalize(" SF ")).Should(Equal(" SF "))
+
+		n = &EnumNormalization{Trim: true}
+		normalized, remapped := n.Normalize(" SF ")
+		Ω(normalized).Should(Equal("SF"))
+		Ω(remapped).Should(BeFalse())
+
+		n = &EnumNormalization{
+			Trim:      true,
+			Lowercase: true,
+			Mappings:  map[string]string{"sf": "San Francisco"},
+		}
+		normalized, remapped = n.Normalize(" SF ")
+		Ω(normalized).Should(Equal("San Francisco"))
+		Ω(remapped).Should(BeTrue())
+		normalized, remapped = n.Normalize("NYC")
+		Ω(normalized).Should(Equal("nyc"))
+		Ω(remapped).Should(BeFalse())
+	})
 })
@@ -51,6 +51,14 @@ type MetaStore interface {
 	// Returns the assigned case IDs for each case string.
 	ExtendEnumDict(table, column string, enumCases []string) ([]int, error)
 
+	// Returns compactions of the enum column in the order applied.
+	GetEnumDictCompactions(table, column string) ([]EnumDictCompaction, error)
+	// Drops enum cases not kept, cases beyond len(keep) are kept. The remaining cases are
+	// renumbered in their original order and the compaction is recorded with its remap.
+	// The enum dict watcher of the column is closed and needs to be set again.
+	// Returns the recorded compaction.
+	CompactEnumDict(table, column string, keep []bool, compaction EnumDictCompaction) (EnumDictCompaction, error)
+
 	// List available archive batches
 	GetArchiveBatches(table string, shard int, start, end int32) ([]int, error)
 
@@ -60,6 +68,11 @@ type MetaStore interface {
 	// WriteArchiveBatchVersion
 	OverwriteArchiveBatchVersion(table string, shard, batchID int, version uint32, seqNum uint32, batchSize int) error
 
+	// Returns row counts of archived days of the specified shard, nil counts if not maintained.
+	GetDailyRowCounts(table string, shard int) (DailyRowCounts, error)
+	// Updates row counts of archived days of the specified shard, nil counts to stop maintaining them.
+	UpdateDailyRowCounts(table string, shard int, counts DailyRowCounts) error
+
 	// Updates the archiving/live cutoff time for the specified shard. This is used
 	// by the archiving job after each successful run.
 	UpdateArchivingCutoff(table string, shard int, cutoff uint32) error
@@ -85,6 +98,14 @@ type MetaStore interface {
 	// Get ingestion checkpoint offset, used for kafka like streaming ingestion
 	GetRedoLogCheckpointOffset(table string, shard int) (int64, error)
 
+	// Returns legal holds placed on archive batches of the table.
+	GetLegalHolds(table string) ([]LegalHold, error)
+	// Places a legal hold on archive batches of the table so that purge skips them,
+	// an existing hold of the same batch range is replaced.
+	PlaceLegalHold(table string, hold LegalHold) error
+	// Removes the legal hold of the batch range from the table.
+	RemoveLegalHold(table string, batchIDStart, batchIDEnd int) error
+
 	TableSchemaWatchable
 	TableSchemaMutator
 }
@@ -0,0 +1,46 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metastore
+
+import (
+	"github.com/uber/aresdb/diskstore"
+	"github.com/uber/aresdb/metastore/common"
+)
+
+// ColumnCompressionLevel returns a function returning the compression level configured for
+// a column of a table in the metastore. Columns that can not be found in metastore use the
+// default level. Columns with codec none are kept uncompressed, and columns with codec zstd
+// are the only ones compressed when compression is not enabled for the disk store.
+func ColumnCompressionLevel(metaStore common.MetaStore, compressionEnabled bool) func(table string, columnID int) int {
+	return func(table string, columnID int) int {
+		schema, err := metaStore.GetTable(table)
+		if err != nil || columnID < 0 || columnID >= len(schema.Columns) {
+			if !compressionEnabled {
+				return -1
+			}
+			return 0
+		}
+		config := schema.Columns[columnID].Config
+		switch {
+		case config.Codec == common.ColumnCodecNone:
+			return -1
+		case config.Codec == common.ColumnCodecZstd && config.CompressionLevel <= 0:
+			return diskstore.DefaultCompressionLevel
+		case config.Codec != common.ColumnCodecZstd && !compressionEnabled:
+			return -1
+		}
+		return config.CompressionLevel
+	}
+}
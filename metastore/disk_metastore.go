@@ -247,6 +247,43 @@ func (dm *diskMetaStore) GetBackfillProgressInfo(table string, shard int) (int64
 	return dm.readRedoLogFileAndOffset(file)
 }
 
+// GetDailyRowCounts returns row counts of archived days of the shard, nil counts if not maintained.
+func (dm *diskMetaStore) GetDailyRowCounts(table string, shard int) (common.DailyRowCounts, error) {
+	dm.RLock()
+	defer dm.RUnlock()
+	if err := dm.tableExists(table); err != nil {
+		return common.DailyRowCounts{}, err
+	}
+	return dm.readDailyRowCountsFile(table, shard)
+}
+
+// UpdateDailyRowCounts updates row counts of archived days of the shard, nil counts removes them.
+func (dm *diskMetaStore) UpdateDailyRowCounts(table string, shard int, counts common.DailyRowCounts) error {
+	dm.Lock()
+	defer dm.Unlock()
+	if err := dm.tableExists(table); err != nil {
+		return err
+	}
+
+	schema, err := dm.readSchemaFile(table)
+	if err != nil {
+		return err
+	}
+
+	if !schema.IsFactTable {
+		return common.ErrNotFactTable
+	}
+
+	if counts.Counts == nil {
+		err = dm.Remove(dm.getDailyRowCountsFilePath(table, shard))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return dm.writeDailyRowCountsFile(table, shard, counts)
+}
+
 // Update ingestion commit offset, used for kafka like streaming ingestion
 func (dm *diskMetaStore) UpdateRedoLogCommitOffset(table string, shard int, offset int64) error {
 	dm.Lock()
@@ -349,6 +386,76 @@ func (dm *diskMetaStore) GetRedoLogCheckpointOffset(table string, shard int) (in
 	return offset, nil
 }
 
+// GetLegalHolds returns the legal holds placed on the table.
+func (dm *diskMetaStore) GetLegalHolds(tableName string) ([]common.LegalHold, error) {
+	dm.RLock()
+	defer dm.RUnlock()
+	if err := dm.tableExists(tableName); err != nil {
+		return nil, err
+	}
+	return dm.readLegalHoldsFile(tableName)
+}
+
+// PlaceLegalHold places a legal hold on the fact table, replacing the hold of the same batch range.
+func (dm *diskMetaStore) PlaceLegalHold(tableName string, hold common.LegalHold) error {
+	if hold.BatchIDStart < 0 || hold.BatchIDEnd <= hold.BatchIDStart {
+		return common.ErrInvalidLegalHold
+	}
+
+	dm.Lock()
+	defer dm.Unlock()
+	if err := dm.tableExists(tableName); err != nil {
+		return err
+	}
+
+	schema, err := dm.readSchemaFile(tableName)
+	if err != nil {
+		return err
+	}
+
+	if !schema.IsFactTable {
+		return common.ErrNotFactTable
+	}
+
+	holds, err := dm.readLegalHoldsFile(tableName)
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i, existing := range holds {
+		if existing.BatchIDStart == hold.BatchIDStart && existing.BatchIDEnd == hold.BatchIDEnd {
+			holds[i] = hold
+			replaced = true
+		}
+	}
+	if !replaced {
+		holds = append(holds, hold)
+	}
+	return dm.writeLegalHoldsFile(tableName, holds)
+}
+
+// RemoveLegalHold removes the legal hold of the batch range from the table.
+func (dm *diskMetaStore) RemoveLegalHold(tableName string, batchIDStart, batchIDEnd int) error {
+	dm.Lock()
+	defer dm.Unlock()
+	if err := dm.tableExists(tableName); err != nil {
+		return err
+	}
+
+	holds, err := dm.readLegalHoldsFile(tableName)
+	if err != nil {
+		return err
+	}
+
+	for i, existing := range holds {
+		if existing.BatchIDStart == batchIDStart && existing.BatchIDEnd == batchIDEnd {
+			return dm.writeLegalHoldsFile(tableName, append(holds[:i], holds[i+1:]...))
+		}
+	}
+	return common.ErrLegalHoldDoesNotExist
+}
+
 // WatchTableListEvents register a watcher to table list change events,
 // should only be called once,
 // returns ErrWatcherAlreadyExist once watcher already exists
@@ -701,7 +808,7 @@ func (dm *diskMetaStore) ExtendEnumDict(table, columnName string, enumCases []st
 	if err != nil {
 		return
 	}
-	if !column.IsEnumColumn() {
+	if !column.IsEnumColumn() && !column.IsMapColumn() {
 		err = common.ErrNotEnumColumn
 		return
 	}
@@ -751,6 +858,69 @@ func (dm *diskMetaStore) ExtendEnumDict(table, columnName string, enumCases []st
 	return enumIDs, nil
 }
 
+// GetEnumDictCompactions returns compactions of the enum column in the order applied.
+func (dm *diskMetaStore) GetEnumDictCompactions(table, columnName string) ([]common.EnumDictCompaction, error) {
+	dm.RLock()
+	defer dm.RUnlock()
+	if _, err := dm.getColumnByName(table, columnName); err != nil {
+		return nil, err
+	}
+	return dm.readEnumCompactionsFile(table, columnName)
+}
+
+// CompactEnumDict drops enum cases not kept and renumbers the remaining cases in their original
+// order. The enum dict watcher of the column is closed after draining pending cases since enum
+// ids of the compacted dictionary restart from the remaining cases.
+func (dm *diskMetaStore) CompactEnumDict(table, columnName string, keep []bool, compaction common.EnumDictCompaction) (common.EnumDictCompaction, error) {
+	dm.writeLock.Lock()
+	defer dm.writeLock.Unlock()
+	dm.Lock()
+	defer dm.Unlock()
+
+	column, err := dm.getColumnByName(table, columnName)
+	if err != nil {
+		return compaction, err
+	}
+	if !column.IsEnumColumn() {
+		return compaction, common.ErrNotEnumColumn
+	}
+
+	existingCases, err := dm.readEnumFile(table, columnName)
+	if err != nil {
+		return compaction, err
+	}
+	compactions, err := dm.readEnumCompactionsFile(table, columnName)
+	if err != nil {
+		return compaction, err
+	}
+
+	compaction.Remap = make([]int, len(existingCases))
+	keptCases := make([]string, 0, len(existingCases))
+	for enumID, enumCase := range existingCases {
+		if enumID < len(keep) && !keep[enumID] {
+			compaction.Remap[enumID] = common.DroppedEnumID
+			continue
+		}
+		compaction.Remap[enumID] = len(keptCases)
+		keptCases = append(keptCases, enumCase)
+	}
+
+	// record the compaction first so that data with old enum ids can always be remapped.
+	if err = dm.writeEnumCompactionsFile(table, columnName, append(compactions, compaction)); err != nil {
+		return compaction, err
+	}
+	if err = dm.writeEnumFileWithMode(table, columnName, keptCases, os.O_TRUNC); err != nil {
+		return compaction, err
+	}
+	dm.closeEnumWatcher(table, columnName)
+
+	utils.GetRootReporter().GetChildGauge(map[string]string{
+		"table":      table,
+		"columnName": columnName,
+	}, utils.NumberOfEnumCasesPerColumn).Update(float64(len(keptCases)))
+	return compaction, nil
+}
+
 // PurgeArchiveBatches deletes the archive batches' metadata with batchID within [batchIDStart, batchIDEnd)
 func (dm *diskMetaStore) PurgeArchiveBatches(tableName string, shard, batchIDStart, batchIDEnd int) error {
 	dm.Lock()
@@ -785,7 +955,16 @@ func (dm *diskMetaStore) PurgeArchiveBatches(tableName string, shard, batchIDSta
 		}
 	}
 
-	return nil
+	counts, err := dm.readDailyRowCountsFile(tableName, shard)
+	if err != nil || counts.Counts == nil {
+		return err
+	}
+	for batchID := range counts.Counts {
+		if batchID < int32(batchIDEnd) && batchID >= int32(batchIDStart) {
+			delete(counts.Counts, batchID)
+		}
+	}
+	return dm.writeDailyRowCountsFile(tableName, shard, counts)
 }
 
 // OverwriteArchiveBatchVersion overwrites batch version
@@ -1147,6 +1326,145 @@ func (dm *diskMetaStore) getIngestionCheckpointOffsetFilePath(tableName string,
 	return filepath.Join(dm.getShardDirPath(tableName, shard), "checkpoint-offset")
 }
 
+func (dm *diskMetaStore) getDailyRowCountsFilePath(tableName string, shard int) string {
+	return filepath.Join(dm.getShardDirPath(tableName, shard), "daily-row-counts")
+}
+
+// readDailyRowCountsFile reads the daily row counts of the shard, nil counts if the file does not exist.
+func (dm *diskMetaStore) readDailyRowCountsFile(tableName string, shard int) (common.DailyRowCounts, error) {
+	var counts common.DailyRowCounts
+	jsonBytes, err := dm.ReadFile(dm.getDailyRowCountsFilePath(tableName, shard))
+	if os.IsNotExist(err) {
+		return counts, nil
+	} else if err != nil {
+		return counts, utils.StackError(err, "Failed to read daily row counts file, table: %s, shard: %d", tableName, shard)
+	}
+
+	if err = json.Unmarshal(jsonBytes, &counts); err != nil {
+		return counts, utils.StackError(err, "Failed to unmarshal daily row counts, table: %s, shard: %d", tableName, shard)
+	}
+	if counts.Counts == nil {
+		counts.Counts = make(map[int32]int)
+	}
+	return counts, nil
+}
+
+// writeDailyRowCountsFile writes the daily row counts of the shard.
+func (dm *diskMetaStore) writeDailyRowCountsFile(tableName string, shard int, counts common.DailyRowCounts) error {
+	jsonBytes, err := json.Marshal(counts)
+	if err != nil {
+		return utils.StackError(err, "Failed to marshal daily row counts")
+	}
+
+	path := dm.getDailyRowCountsFilePath(tableName, shard)
+	if err = dm.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return utils.StackError(err, "Failed to create shard directory")
+	}
+	writer, err := dm.OpenFileForWrite(
+		path,
+		os.O_WRONLY|os.O_TRUNC|os.O_CREATE,
+		0644,
+	)
+	if err != nil {
+		return utils.StackError(err, "Failed to open daily row counts file for write, table: %s, shard: %d", tableName, shard)
+	}
+
+	defer writer.Close()
+	_, err = writer.Write(jsonBytes)
+	return err
+}
+
+func (dm *diskMetaStore) getLegalHoldsFilePath(tableName string) string {
+	return filepath.Join(dm.getTableDirPath(tableName), "legal-holds")
+}
+
+// readLegalHoldsFile reads the legal holds of the table, no holds if the file does not exist.
+func (dm *diskMetaStore) readLegalHoldsFile(tableName string) ([]common.LegalHold, error) {
+	jsonBytes, err := dm.ReadFile(dm.getLegalHoldsFilePath(tableName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, utils.StackError(err, "Failed to read legal holds file, table: %s", tableName)
+	}
+
+	var holds []common.LegalHold
+	if err = json.Unmarshal(jsonBytes, &holds); err != nil {
+		return nil, utils.StackError(err, "Failed to unmarshal legal holds, table: %s", tableName)
+	}
+	return holds, nil
+}
+
+// writeLegalHoldsFile writes the legal holds of the table.
+func (dm *diskMetaStore) writeLegalHoldsFile(tableName string, holds []common.LegalHold) error {
+	jsonBytes, err := json.MarshalIndent(holds, "", "  ")
+	if err != nil {
+		return utils.StackError(err, "Failed to marshal legal holds")
+	}
+
+	writer, err := dm.OpenFileForWrite(
+		dm.getLegalHoldsFilePath(tableName),
+		os.O_WRONLY|os.O_TRUNC|os.O_CREATE,
+		0644,
+	)
+	if err != nil {
+		return utils.StackError(err, "Failed to open legal holds file for write, table: %s", tableName)
+	}
+
+	defer writer.Close()
+	_, err = writer.Write(jsonBytes)
+	return err
+}
+
+func (dm *diskMetaStore) getEnumCompactionsDirPath(tableName string) string {
+	return filepath.Join(dm.getTableDirPath(tableName), "enum-compactions")
+}
+
+func (dm *diskMetaStore) getEnumCompactionsFilePath(tableName, columnName string) string {
+	return filepath.Join(dm.getEnumCompactionsDirPath(tableName), columnName)
+}
+
+// readEnumCompactionsFile reads the compactions of the enum column, no compactions if the file
+// does not exist.
+func (dm *diskMetaStore) readEnumCompactionsFile(tableName, columnName string) ([]common.EnumDictCompaction, error) {
+	jsonBytes, err := dm.ReadFile(dm.getEnumCompactionsFilePath(tableName, columnName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, utils.StackError(err, "Failed to read enum compactions file, table: %s, column: %s", tableName, columnName)
+	}
+
+	var compactions []common.EnumDictCompaction
+	if err = json.Unmarshal(jsonBytes, &compactions); err != nil {
+		return nil, utils.StackError(err, "Failed to unmarshal enum compactions, table: %s, column: %s", tableName, columnName)
+	}
+	return compactions, nil
+}
+
+// writeEnumCompactionsFile writes the compactions of the enum column.
+func (dm *diskMetaStore) writeEnumCompactionsFile(tableName, columnName string, compactions []common.EnumDictCompaction) error {
+	jsonBytes, err := json.Marshal(compactions)
+	if err != nil {
+		return utils.StackError(err, "Failed to marshal enum compactions")
+	}
+
+	if err = dm.MkdirAll(dm.getEnumCompactionsDirPath(tableName), 0755); err != nil {
+		return utils.StackError(err, "Failed to create enum compactions directory")
+	}
+
+	writer, err := dm.OpenFileForWrite(
+		dm.getEnumCompactionsFilePath(tableName, columnName),
+		os.O_WRONLY|os.O_TRUNC|os.O_CREATE,
+		0644,
+	)
+	if err != nil {
+		return utils.StackError(err, "Failed to open enum compactions file for write, table: %s, column: %s", tableName, columnName)
+	}
+
+	defer writer.Close()
+	_, err = writer.Write(jsonBytes)
+	return err
+}
+
 // readEnumFile reads the enum cases from file.
 func (dm *diskMetaStore) readEnumFile(tableName, columnName string) ([]string, error) {
 	enumBytes, err := dm.ReadFile(dm.getEnumFilePath(tableName, columnName))
@@ -1161,6 +1479,10 @@ func (dm *diskMetaStore) readEnumFile(tableName, columnName string) ([]string, e
 				columnName,
 			)
 	}
+	// all cases may be dropped by compaction.
+	if len(enumBytes) == 0 {
+		return []string{}, nil
+	}
 	return strings.Split(strings.TrimSuffix(string(enumBytes), common.EnumDelimiter), common.EnumDelimiter), nil
 }
 
@@ -1169,6 +1491,11 @@ func (dm *diskMetaStore) writeEnumFile(tableName, columnName string, enumCases [
 	if len(enumCases) == 0 {
 		return nil
 	}
+	return dm.writeEnumFileWithMode(tableName, columnName, enumCases, os.O_APPEND)
+}
+
+// writeEnumFileWithMode writes enum cases to file with os.O_APPEND or os.O_TRUNC.
+func (dm *diskMetaStore) writeEnumFileWithMode(tableName, columnName string, enumCases []string, mode int) error {
 
 	err := dm.MkdirAll(dm.getEnumDirPath(tableName), 0755)
 	if err != nil {
@@ -1177,7 +1504,7 @@ func (dm *diskMetaStore) writeEnumFile(tableName, columnName string, enumCases [
 
 	writer, err := dm.OpenFileForWrite(
 		dm.getEnumFilePath(tableName, columnName),
-		os.O_WRONLY|os.O_APPEND|os.O_CREATE,
+		os.O_WRONLY|mode|os.O_CREATE,
 		0644,
 	)
 	if err != nil {
@@ -1185,6 +1512,9 @@ func (dm *diskMetaStore) writeEnumFile(tableName, columnName string, enumCases [
 	}
 	defer writer.Close()
 
+	if len(enumCases) == 0 {
+		return nil
+	}
 	_, err = io.WriteString(writer, fmt.Sprintf("%s%s", strings.Join(enumCases, common.EnumDelimiter), common.EnumDelimiter))
 	if err != nil {
 		return utils.StackError(err, "Failed to write enum cases, table: %s, column: %s", tableName, columnName)
@@ -1393,8 +1723,20 @@ func (dm *diskMetaStore) writeSnapshotRedoLogVersionAndOffset(file string, redoL
 	return err
 }
 
-// closeEnumWatcher try to close enum watcher and delete enum file
+// removeEnumColumn closes enum watcher and deletes enum file
 func (dm *diskMetaStore) removeEnumColumn(tableName, columnName string) {
+	dm.closeEnumWatcher(tableName, columnName)
+
+	if err := dm.Remove(dm.getEnumFilePath(tableName, columnName)); err != nil {
+		//TODO: log an error and alert.
+	}
+	if err := dm.Remove(dm.getEnumCompactionsFilePath(tableName, columnName)); err != nil {
+		//TODO: log an error and alert.
+	}
+}
+
+// closeEnumWatcher closes enum watcher of the column if any.
+func (dm *diskMetaStore) closeEnumWatcher(tableName, columnName string) {
 	if _, tableExist := dm.enumDictWatchers[tableName]; tableExist {
 		watcher, watcherExist := dm.enumDictWatchers[tableName][columnName]
 		if watcherExist {
@@ -1408,10 +1750,6 @@ func (dm *diskMetaStore) removeEnumColumn(tableName, columnName string) {
 			}
 		}
 	}
-
-	if err := dm.Remove(dm.getEnumFilePath(tableName, columnName)); err != nil {
-		//TODO: log an error and alert.
-	}
 }
 
 // tableExists checks whether table exists,
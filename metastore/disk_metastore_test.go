@@ -228,6 +228,7 @@ var _ = ginkgo.Describe("disk metastore", func() {
 
 	mockFileSystem.On("RemoveAll", "base/b").Return(nil)
 	mockFileSystem.On("Remove", "base/a/enums/column4").Return(nil)
+	mockFileSystem.On("Remove", "base/a/enum-compactions/column4").Return(os.ErrNotExist)
 
 	var createDiskMetastore = func(basepath string) *diskMetaStore {
 		diskMetaStore := &diskMetaStore{
@@ -698,6 +699,7 @@ var _ = ginkgo.Describe("disk metastore", func() {
 		mockBatch2 := &mocks.FileInfo{}
 		mockBatch1.On("Name").Return("1")
 		mockBatch2.On("Name").Return("2")
+		mockFileSystem.On("ReadFile", "base/c/shards/0/daily-row-counts").Return(nil, os.ErrNotExist).Twice()
 
 		mockFileSystem.On("ReadDir", "base/c/shards/0/batches").Return([]os.FileInfo{mockBatch1, mockBatch2}, nil).Once()
 		mockFileSystem.On("Remove", "base/c/shards/0/batches/1").Return(nil).Once()
@@ -714,6 +716,119 @@ var _ = ginkgo.Describe("disk metastore", func() {
 		Ω(err).Should(BeNil())
 	})
 
+	ginkgo.It("DailyRowCounts", func() {
+		diskMetaStore := createDiskMetastore("base")
+		mockFileSystem.On("MkdirAll", "base/c/shards/0", os.FileMode(0755)).Return(nil)
+		mockFileSystem.On("OpenFileForWrite", "base/c/shards/0/daily-row-counts", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(0644)).Return(mockWriterCloser, nil)
+
+		// not maintained yet.
+		mockFileSystem.On("ReadFile", "base/c/shards/0/daily-row-counts").Return(nil, os.ErrNotExist).Once()
+		counts, err := diskMetaStore.GetDailyRowCounts(testTableC.Name, 0)
+		Ω(err).Should(BeNil())
+		Ω(counts.Counts).Should(BeNil())
+
+		counts = common.DailyRowCounts{ArchivingCutoff: 86400 * 3, Counts: map[int32]int{0: 10, 1: 20, 2: 30}}
+		Ω(diskMetaStore.UpdateDailyRowCounts(testTableB.Name, 0, counts)).Should(Equal(common.ErrNotFactTable))
+		Ω(diskMetaStore.UpdateDailyRowCounts(testTableC.Name, 0, counts)).Should(BeNil())
+		countsBytes := append([]byte{}, mockWriterCloser.Bytes()...)
+
+		mockFileSystem.On("ReadFile", "base/c/shards/0/daily-row-counts").Return(countsBytes, nil).Once()
+		Ω(diskMetaStore.GetDailyRowCounts(testTableC.Name, 0)).Should(Equal(counts))
+
+		// counts of purged batches are removed.
+		mockWriterCloser.Reset()
+		mockFileSystem.On("ReadDir", "base/c/shards/0/batches").Return([]os.FileInfo{}, nil).Once()
+		mockFileSystem.On("ReadFile", "base/c/shards/0/daily-row-counts").Return(countsBytes, nil).Once()
+		Ω(diskMetaStore.PurgeArchiveBatches(testTableC.Name, 0, 0, 2)).Should(BeNil())
+		var purged common.DailyRowCounts
+		Ω(json.Unmarshal(mockWriterCloser.Bytes(), &purged)).Should(BeNil())
+		Ω(purged.Counts).Should(Equal(map[int32]int{2: 30}))
+
+		mockFileSystem.On("Remove", "base/c/shards/0/daily-row-counts").Return(nil).Once()
+		Ω(diskMetaStore.UpdateDailyRowCounts(testTableC.Name, 0, common.DailyRowCounts{})).Should(BeNil())
+	})
+
+	ginkgo.It("LegalHolds", func() {
+		diskMetaStore := createDiskMetastore("base")
+		mockFileSystem.On("OpenFileForWrite", "base/c/legal-holds", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(0644)).Return(mockWriterCloser, nil)
+
+		// no legal holds yet.
+		mockFileSystem.On("ReadFile", "base/c/legal-holds").Return(nil, os.ErrNotExist).Once()
+		holds, err := diskMetaStore.GetLegalHolds(testTableC.Name)
+		Ω(err).Should(BeNil())
+		Ω(holds).Should(BeEmpty())
+
+		hold := common.LegalHold{BatchIDStart: 1, BatchIDEnd: 3, Reason: "litigation", PlacedAt: 100}
+		Ω(diskMetaStore.PlaceLegalHold(testTableC.Name, common.LegalHold{BatchIDStart: 3, BatchIDEnd: 1})).Should(Equal(common.ErrInvalidLegalHold))
+		Ω(diskMetaStore.PlaceLegalHold(testTableB.Name, hold)).Should(Equal(common.ErrNotFactTable))
+		Ω(diskMetaStore.PlaceLegalHold("unknown", hold)).Should(Equal(common.ErrTableDoesNotExist))
+
+		mockFileSystem.On("ReadFile", "base/c/legal-holds").Return(nil, os.ErrNotExist).Once()
+		Ω(diskMetaStore.PlaceLegalHold(testTableC.Name, hold)).Should(BeNil())
+		holdsBytes := append([]byte{}, mockWriterCloser.Bytes()...)
+		json.Unmarshal(holdsBytes, &holds)
+		Ω(holds).Should(Equal([]common.LegalHold{hold}))
+
+		mockFileSystem.On("ReadFile", "base/c/legal-holds").Return(holdsBytes, nil).Once()
+		holds, err = diskMetaStore.GetLegalHolds(testTableC.Name)
+		Ω(err).Should(BeNil())
+		Ω(holds).Should(Equal([]common.LegalHold{hold}))
+
+		mockFileSystem.On("ReadFile", "base/c/legal-holds").Return(holdsBytes, nil).Once()
+		Ω(diskMetaStore.RemoveLegalHold(testTableC.Name, 1, 2)).Should(Equal(common.ErrLegalHoldDoesNotExist))
+
+		mockWriterCloser.Reset()
+		mockFileSystem.On("ReadFile", "base/c/legal-holds").Return(holdsBytes, nil).Once()
+		Ω(diskMetaStore.RemoveLegalHold(testTableC.Name, 1, 3)).Should(BeNil())
+		Ω(string(mockWriterCloser.Bytes())).Should(Equal("[]"))
+	})
+
+	ginkgo.It("CompactEnumDict", func() {
+		diskMetaStore := createDiskMetastore("base")
+		enumWriter := &testing.TestReadWriteCloser{}
+		compactionsWriter := &testing.TestReadWriteCloser{}
+		mockFileSystem.On("ReadFile", "base/c/enums/column1").Return([]byte(strings.Join([]string{"a", "b", "c", "d"}, common.EnumDelimiter)+common.EnumDelimiter), nil)
+		mockFileSystem.On("ReadFile", "base/c/enum-compactions/column1").Return(nil, os.ErrNotExist).Once()
+		mockFileSystem.On("MkdirAll", "base/c/enums", os.FileMode(0755)).Return(nil)
+		mockFileSystem.On("MkdirAll", "base/c/enum-compactions", os.FileMode(0755)).Return(nil)
+		mockFileSystem.On("OpenFileForWrite", "base/c/enums/column1", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(0644)).Return(enumWriter, nil)
+		mockFileSystem.On("OpenFileForWrite", "base/c/enum-compactions/column1", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(0644)).Return(compactionsWriter, nil)
+
+		// the watcher is closed after pending cases are consumed.
+		events, done, err := diskMetaStore.WatchEnumDictEvents(testTableC.Name, testColumn1.Name, 4)
+		Ω(err).Should(BeNil())
+		go func() {
+			for range events {
+				done <- struct{}{}
+			}
+			close(done)
+		}()
+
+		// case d was added after the scan and is kept.
+		compaction, err := diskMetaStore.CompactEnumDict(testTableC.Name, testColumn1.Name, []bool{true, false, true},
+			common.EnumDictCompaction{CompactedAt: 100})
+		Ω(err).Should(BeNil())
+		Ω(compaction.Remap).Should(Equal([]int{0, common.DroppedEnumID, 1, 2}))
+		Ω(compaction.MapID(2)).Should(Equal(1))
+		Ω(compaction.MapID(4)).Should(Equal(4))
+		Ω(string(enumWriter.Bytes())).Should(Equal(strings.Join([]string{"a", "c", "d"}, common.EnumDelimiter) + common.EnumDelimiter))
+		Ω(diskMetaStore.enumDictWatchers[testTableC.Name]).ShouldNot(HaveKey(testColumn1.Name))
+
+		var compactions []common.EnumDictCompaction
+		Ω(json.Unmarshal(compactionsWriter.Bytes(), &compactions)).Should(BeNil())
+		Ω(compactions).Should(Equal([]common.EnumDictCompaction{compaction}))
+
+		mockFileSystem.On("ReadFile", "base/c/enum-compactions/column1").Return(compactionsWriter.Bytes(), nil).Once()
+		compactions, err = diskMetaStore.GetEnumDictCompactions(testTableC.Name, testColumn1.Name)
+		Ω(err).Should(BeNil())
+		Ω(compactions).Should(Equal([]common.EnumDictCompaction{compaction}))
+
+		_, err = diskMetaStore.CompactEnumDict(testTableC.Name, testColumn0.Name, nil, common.EnumDictCompaction{})
+		Ω(err).Should(Equal(common.ErrNotEnumColumn))
+		_, err = diskMetaStore.CompactEnumDict("unknown", testColumn1.Name, nil, common.EnumDictCompaction{})
+		Ω(err).Should(Equal(common.ErrTableDoesNotExist))
+	})
+
 	ginkgo.It("readRedoLogFileAndOffset", func() {
 		diskMetaStore := createDiskMetastore("base")
 		redoLogFile, offset, err := diskMetaStore.readRedoLogFileAndOffset("base/notexist/shards/0/redolog-offset")
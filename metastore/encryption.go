@@ -0,0 +1,34 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package metastore
+
+import (
+	"github.com/uber/aresdb/metastore/common"
+)
+
+// ColumnEncryptionChecker returns a function telling whether a column of a table is marked as
+// encrypted in the metastore. Columns that can not be found in metastore are treated
+// as encrypted so that sensitive data is never written in plaintext.
+func ColumnEncryptionChecker(metaStore common.MetaStore) func(table string, columnID int) bool {
+	return func(table string, columnID int) bool {
+		schema, err := metaStore.GetTable(table)
+		if err != nil {
+			return true
+		}
+		if columnID < 0 || columnID >= len(schema.Columns) {
+			return true
+		}
+		return schema.Columns[columnID].Encrypted
+	}
+}
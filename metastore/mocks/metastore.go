@@ -103,6 +103,50 @@ func (_m *MetaStore) ExtendEnumDict(table string, column string, enumCases []str
 	return r0, r1
 }
 
+// CompactEnumDict provides a mock function with given fields: table, column, keep, compaction
+func (_m *MetaStore) CompactEnumDict(table string, column string, keep []bool, compaction common.EnumDictCompaction) (common.EnumDictCompaction, error) {
+	ret := _m.Called(table, column, keep, compaction)
+
+	var r0 common.EnumDictCompaction
+	if rf, ok := ret.Get(0).(func(string, string, []bool, common.EnumDictCompaction) common.EnumDictCompaction); ok {
+		r0 = rf(table, column, keep, compaction)
+	} else {
+		r0 = ret.Get(0).(common.EnumDictCompaction)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, []bool, common.EnumDictCompaction) error); ok {
+		r1 = rf(table, column, keep, compaction)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetEnumDictCompactions provides a mock function with given fields: table, column
+func (_m *MetaStore) GetEnumDictCompactions(table string, column string) ([]common.EnumDictCompaction, error) {
+	ret := _m.Called(table, column)
+
+	var r0 []common.EnumDictCompaction
+	if rf, ok := ret.Get(0).(func(string, string) []common.EnumDictCompaction); ok {
+		r0 = rf(table, column)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]common.EnumDictCompaction)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(table, column)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetArchiveBatchVersion provides a mock function with given fields: table, shard, batchID, cutoff
 func (_m *MetaStore) GetArchiveBatchVersion(table string, shard int, batchID int, cutoff uint32) (uint32, uint32, int, error) {
 	ret := _m.Called(table, shard, batchID, cutoff)
@@ -210,6 +254,27 @@ func (_m *MetaStore) GetBackfillProgressInfo(table string, shard int) (int64, ui
 	return r0, r1, r2
 }
 
+// GetDailyRowCounts provides a mock function with given fields: table, shard
+func (_m *MetaStore) GetDailyRowCounts(table string, shard int) (common.DailyRowCounts, error) {
+	ret := _m.Called(table, shard)
+
+	var r0 common.DailyRowCounts
+	if rf, ok := ret.Get(0).(func(string, int) common.DailyRowCounts); ok {
+		r0 = rf(table, shard)
+	} else {
+		r0 = ret.Get(0).(common.DailyRowCounts)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, int) error); ok {
+		r1 = rf(table, shard)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetEnumDict provides a mock function with given fields: table, column
 func (_m *MetaStore) GetEnumDict(table string, column string) ([]string, error) {
 	ret := _m.Called(table, column)
@@ -233,6 +298,29 @@ func (_m *MetaStore) GetEnumDict(table string, column string) ([]string, error)
 	return r0, r1
 }
 
+// GetLegalHolds provides a mock function with given fields: table
+func (_m *MetaStore) GetLegalHolds(table string) ([]common.LegalHold, error) {
+	ret := _m.Called(table)
+
+	var r0 []common.LegalHold
+	if rf, ok := ret.Get(0).(func(string) []common.LegalHold); ok {
+		r0 = rf(table)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]common.LegalHold)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(table)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetRedoLogCheckpointOffset provides a mock function with given fields: table, shard
 func (_m *MetaStore) GetRedoLogCheckpointOffset(table string, shard int) (int64, error) {
 	ret := _m.Called(table, shard)
@@ -377,6 +465,20 @@ func (_m *MetaStore) OverwriteArchiveBatchVersion(table string, shard int, batch
 	return r0
 }
 
+// PlaceLegalHold provides a mock function with given fields: table, hold
+func (_m *MetaStore) PlaceLegalHold(table string, hold common.LegalHold) error {
+	ret := _m.Called(table, hold)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, common.LegalHold) error); ok {
+		r0 = rf(table, hold)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // PurgeArchiveBatches provides a mock function with given fields: table, shard, batchIDStart, batchIDEnd
 func (_m *MetaStore) PurgeArchiveBatches(table string, shard int, batchIDStart int, batchIDEnd int) error {
 	ret := _m.Called(table, shard, batchIDStart, batchIDEnd)
@@ -391,6 +493,20 @@ func (_m *MetaStore) PurgeArchiveBatches(table string, shard int, batchIDStart i
 	return r0
 }
 
+// RemoveLegalHold provides a mock function with given fields: table, batchIDStart, batchIDEnd
+func (_m *MetaStore) RemoveLegalHold(table string, batchIDStart int, batchIDEnd int) error {
+	ret := _m.Called(table, batchIDStart, batchIDEnd)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int, int) error); ok {
+		r0 = rf(table, batchIDStart, batchIDEnd)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateArchivingCutoff provides a mock function with given fields: table, shard, cutoff
 func (_m *MetaStore) UpdateArchivingCutoff(table string, shard int, cutoff uint32) error {
 	ret := _m.Called(table, shard, cutoff)
@@ -433,6 +549,20 @@ func (_m *MetaStore) UpdateColumn(table string, column string, config common.Col
 	return r0
 }
 
+// UpdateDailyRowCounts provides a mock function with given fields: table, shard, counts
+func (_m *MetaStore) UpdateDailyRowCounts(table string, shard int, counts common.DailyRowCounts) error {
+	ret := _m.Called(table, shard, counts)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int, common.DailyRowCounts) error); ok {
+		r0 = rf(table, shard, counts)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateRedoLogCheckpointOffset provides a mock function with given fields: table, shard, offset
 func (_m *MetaStore) UpdateRedoLogCheckpointOffset(table string, shard int, offset int64) error {
 	ret := _m.Called(table, shard, offset)
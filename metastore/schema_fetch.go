@@ -36,6 +36,14 @@ type SchemaFetchJob struct {
 	controllerClient  controllerCli.ControllerClient
 	enumMutator       controllerMutatorCom.EnumMutator
 	stopChan          chan struct{}
+	// whether to take part in two-phase schema rollouts orchestrated by controller
+	schemaRolloutEnabled bool
+	// table name to the latest pending schema version acknowledged
+	preparedVersions map[string]int
+	// optional notifications of schema changes to fetch schema without waiting for next tick
+	schemaWatch <-chan struct{}
+	// requests to fetch schema without waiting for next tick
+	refreshChan chan struct{}
 }
 
 // NewSchemaFetchJob creates a new SchemaFetchJob
@@ -50,6 +58,29 @@ func NewSchemaFetchJob(intervalInSeconds int, schemaMutator common.TableSchemaMu
 		stopChan:          make(chan struct{}),
 		controllerClient:  controllerClient,
 		enumMutator:       enumMutator,
+		preparedVersions:  make(map[string]int),
+		refreshChan:       make(chan struct{}, 1),
+	}
+}
+
+// EnableSchemaRollout makes the job validate and acknowledge pending schemas of
+// schema rollouts, it should only be enabled on datanodes
+func (j *SchemaFetchJob) EnableSchemaRollout() {
+	j.schemaRolloutEnabled = true
+}
+
+// SetSchemaWatch makes the job fetch schema whenever notified by schemaWatch
+// in addition to the periodical fetches
+func (j *SchemaFetchJob) SetSchemaWatch(schemaWatch <-chan struct{}) {
+	j.schemaWatch = schemaWatch
+}
+
+// Refresh makes the running job fetch schema without waiting for next tick, requests
+// made before the pending fetch starts are coalesced
+func (j *SchemaFetchJob) Refresh() {
+	select {
+	case j.refreshChan <- struct{}{}:
+	default:
 	}
 }
 
@@ -64,6 +95,10 @@ func (j *SchemaFetchJob) Run() {
 			if j.enumUpdater != nil {
 				j.FetchEnum()
 			}
+		case <-j.schemaWatch:
+			j.FetchSchema()
+		case <-j.refreshChan:
+			j.FetchSchema()
 		case <-j.stopChan:
 			return
 		}
@@ -94,6 +129,9 @@ func (j *SchemaFetchJob) FetchSchema() {
 		}
 		j.hash = newHash
 	}
+	if j.schemaRolloutEnabled {
+		j.prepareSchemaRollouts()
+	}
 	utils.GetLogger().Debug("Succeeded to run schema fetch job")
 	utils.GetRootReporter().GetCounter(utils.SchemaFetchSuccess).Inc(1)
 }
@@ -113,6 +151,7 @@ func (j *SchemaFetchJob) applySchemaChange(tables []common.Table) (err error) {
 		if _, exist := oldTablesMap[table.Name]; !exist {
 			// found new table
 			err = j.schemaMutator.CreateTable(&table)
+			auditSchemaChange(utils.AuditOpCreateTable, table.Name, err)
 			if err != nil {
 				reportError(err, true, table.Name)
 				continue
@@ -131,6 +170,7 @@ func (j *SchemaFetchJob) applySchemaChange(tables []common.Table) (err error) {
 				// found new table incarnation, delete previous table and data
 				// then create new table
 				err := j.schemaMutator.DeleteTable(table.Name)
+				auditSchemaChange(utils.AuditOpDeleteTable, table.Name, err)
 				if err != nil {
 					reportError(err, true, table.Name)
 					continue
@@ -138,6 +178,7 @@ func (j *SchemaFetchJob) applySchemaChange(tables []common.Table) (err error) {
 				utils.GetRootReporter().GetCounter(utils.SchemaDeletionCount).Inc(1)
 				utils.GetLogger().With("table", table.Name).Info("deleted table")
 				err = j.schemaMutator.CreateTable(&table)
+				auditSchemaChange(utils.AuditOpCreateTable, table.Name, err)
 				if err != nil {
 					reportError(err, true, table.Name)
 					continue
@@ -145,6 +186,10 @@ func (j *SchemaFetchJob) applySchemaChange(tables []common.Table) (err error) {
 				utils.GetRootReporter().GetCounter(utils.SchemaCreationCount).Inc(1)
 				utils.GetLogger().With("table", table.Name).Info("recreated table")
 
+			} else if oldTable.Incarnation == table.Incarnation && table.Version < oldTable.Version {
+				// fence stale schema
+				utils.GetLogger().With("table", table.Name, "version", table.Version, "localVersion", oldTable.Version).
+					Warn("ignored stale table schema")
 			} else if oldTable.Incarnation == table.Incarnation && !reflect.DeepEqual(&table, oldTable) {
 				// found table update
 				j.schemaValidator.SetNewTable(table)
@@ -155,6 +200,7 @@ func (j *SchemaFetchJob) applySchemaChange(tables []common.Table) (err error) {
 					continue
 				}
 				err = j.schemaMutator.UpdateTable(table)
+				auditSchemaChange(utils.AuditOpUpdateTable, table.Name, err)
 				if err != nil {
 					reportError(err, true, table.Name)
 					continue
@@ -169,6 +215,7 @@ func (j *SchemaFetchJob) applySchemaChange(tables []common.Table) (err error) {
 		if notAddressed {
 			// found table deletion
 			err = j.schemaMutator.DeleteTable(oldTableName)
+			auditSchemaChange(utils.AuditOpDeleteTable, oldTableName, err)
 			if err != nil {
 				reportError(err, true, oldTableName)
 				continue
@@ -180,6 +227,64 @@ func (j *SchemaFetchJob) applySchemaChange(tables []common.Table) (err error) {
 	return
 }
 
+// auditSchemaChange records schema changes applied from ares-controller to audit log.
+func auditSchemaChange(operation, table string, err error) {
+	entry := utils.AuditLogEntry{
+		Actor:     utils.AuditActorSchemaFetch,
+		Operation: operation,
+		Table:     table,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	utils.Audit(entry)
+}
+
+// prepareSchemaRollouts validates pending schemas of rollouts in prepare phase against local
+// schemas and acknowledges them to controller, so that controller only commits schema changes
+// which every datanode is able to apply
+func (j *SchemaFetchJob) prepareSchemaRollouts() {
+	rollouts, err := j.controllerClient.GetSchemaRollouts(j.clusterName)
+	if err != nil {
+		reportError(err, true, "schemaRollouts")
+		return
+	}
+
+	for _, rollout := range rollouts {
+		if j.preparedVersions[rollout.Table] == rollout.Version {
+			continue
+		}
+
+		oldTable, err := j.schemaMutator.GetTable(rollout.Table)
+		if err != nil {
+			reportError(err, true, rollout.Table)
+			continue
+		}
+
+		// only prepare pending schema based on the local schema, otherwise wait
+		// until local schema catches up with the committed one
+		if oldTable.Incarnation != rollout.Schema.Incarnation || oldTable.Version != rollout.Version-1 {
+			utils.GetLogger().With("table", rollout.Table, "version", rollout.Version, "localVersion", oldTable.Version).
+				Info("local schema not ready for schema rollout")
+			continue
+		}
+
+		j.schemaValidator.SetNewTable(rollout.Schema)
+		j.schemaValidator.SetOldTable(*oldTable)
+		if err = j.schemaValidator.Validate(); err != nil {
+			reportError(err, true, rollout.Table)
+			continue
+		}
+
+		if err = j.controllerClient.AckSchemaRollout(j.clusterName, rollout.Table, rollout.Version); err != nil {
+			reportError(err, true, rollout.Table)
+			continue
+		}
+		j.preparedVersions[rollout.Table] = rollout.Version
+		utils.GetLogger().With("table", rollout.Table, "version", rollout.Version).Info("prepared schema rollout")
+	}
+}
+
 // FetchEnum updates all enums
 func (j *SchemaFetchJob) FetchEnum() {
 	var (
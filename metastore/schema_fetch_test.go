@@ -3,8 +3,10 @@ package metastore
 import (
 	"errors"
 	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
 	"github.com/stretchr/testify/mock"
 	cCliMocks "github.com/uber/aresdb/controller/client/mocks"
+	"github.com/uber/aresdb/controller/models"
 	cMuMocks "github.com/uber/aresdb/controller/mutators/mocks"
 	memMocks "github.com/uber/aresdb/memstore/common/mocks"
 	"github.com/uber/aresdb/metastore/common"
@@ -91,6 +93,38 @@ var _ = ginkgo.Describe("schema fetch job", func() {
 		job.FetchSchema()
 	})
 
+	ginkgo.It("should fetch schema when notified by schema watch", func() {
+		job = NewSchemaFetchJob(3600, &mockSchemaMutator, nil, &mockSchemaValidator, &mockControllerCli, nil, "cluster1", "123")
+		schemaWatch := make(chan struct{}, 1)
+		job.SetSchemaWatch(schemaWatch)
+
+		fetched := make(chan struct{})
+		mockControllerCli.On("GetSchemaHash", "cluster1").Return("123", nil).Run(func(args mock.Arguments) {
+			close(fetched)
+		}).Once()
+
+		go job.Run()
+		defer job.Stop()
+		schemaWatch <- struct{}{}
+		Eventually(fetched).Should(BeClosed())
+	})
+
+	ginkgo.It("should fetch schema when refreshed", func() {
+		job = NewSchemaFetchJob(3600, &mockSchemaMutator, nil, &mockSchemaValidator, &mockControllerCli, nil, "cluster1", "123")
+
+		fetched := make(chan struct{})
+		mockControllerCli.On("GetSchemaHash", "cluster1").Return("123", nil).Run(func(args mock.Arguments) {
+			close(fetched)
+		}).Once()
+
+		// refreshes are coalesced until the job runs
+		job.Refresh()
+		job.Refresh()
+		go job.Run()
+		defer job.Stop()
+		Eventually(fetched).Should(BeClosed())
+	})
+
 	ginkgo.It("should work with schema changes", func() {
 		mockControllerCli.On("GetSchemaHash", "cluster1").Return("456", nil).Once()
 		mockControllerCli.On("GetAllSchema", "cluster1").Return([]common.Table{testTable1, testTable2m, testTable3}, nil).Once()
@@ -109,6 +143,68 @@ var _ = ginkgo.Describe("schema fetch job", func() {
 		job.FetchSchema()
 	})
 
+	ginkgo.It("should ignore stale schema", func() {
+		testTable2Stale := testTable2
+		testTable2Stale.Version = 1
+		mockControllerCli.On("GetSchemaHash", "cluster1").Return("456", nil).Once()
+		mockControllerCli.On("GetAllSchema", "cluster1").Return([]common.Table{testTable2Stale}, nil).Once()
+		mockSchemaMutator.On("ListTables").Return([]string{"testTable2"}, nil).Once()
+		mockSchemaMutator.On("GetTable", "testTable2").Return(&testTable2, nil).Once()
+		job.FetchSchema()
+		mockSchemaMutator.AssertNotCalled(ginkgo.GinkgoT(), "UpdateTable", mock.Anything)
+		Ω(job.hash).Should(Equal("456"))
+	})
+
+	ginkgo.It("should prepare schema rollouts", func() {
+		job.EnableSchemaRollout()
+		testTable2Pending := testTable2m
+		testTable2Pending.Columns = append(testTable2Pending.Columns, common.Column{Name: "col2", Type: "Int32"})
+		rollouts := []models.SchemaRollout{
+			{
+				Table:   "testTable2",
+				Version: 3,
+				Phase:   models.SchemaRolloutPrepare,
+				Schema:  testTable2Pending,
+			},
+			{
+				// local schema not caught up
+				Table:   "testTable3",
+				Version: 4,
+				Phase:   models.SchemaRolloutPrepare,
+				Schema:  testTable3,
+			},
+		}
+
+		mockControllerCli.On("GetSchemaHash", "cluster1").Return("123", nil)
+		mockControllerCli.On("GetSchemaRollouts", "cluster1").Return(rollouts, nil)
+		mockSchemaMutator.On("GetTable", "testTable2").Return(&testTable2, nil)
+		mockSchemaMutator.On("GetTable", "testTable3").Return(&testTable3, nil)
+		mockSchemaValidator.On("SetNewTable", testTable2Pending).Return(nil).Once()
+		mockSchemaValidator.On("SetOldTable", testTable2).Return(nil).Once()
+		mockSchemaValidator.On("Validate").Return(nil).Once()
+		mockControllerCli.On("AckSchemaRollout", "cluster1", "testTable2", 3).Return(nil).Once()
+		job.FetchSchema()
+		Ω(job.preparedVersions).Should(Equal(map[string]int{"testTable2": 3}))
+
+		// already prepared rollout will not be acknowledged again
+		job.FetchSchema()
+		mockControllerCli.AssertNumberOfCalls(ginkgo.GinkgoT(), "AckSchemaRollout", 1)
+		mockControllerCli.AssertNotCalled(ginkgo.GinkgoT(), "AckSchemaRollout", "cluster1", "testTable3", 4)
+
+		// failed validation will not be acknowledged
+		rollouts[0].Version = 4
+		testTable2m2 := testTable2m
+		mockSchemaMutator.ExpectedCalls = nil
+		mockSchemaMutator.On("GetTable", "testTable2").Return(&testTable2m2, nil)
+		mockSchemaMutator.On("GetTable", "testTable3").Return(&testTable3, nil)
+		mockSchemaValidator.On("SetNewTable", mock.Anything).Return(nil).Once()
+		mockSchemaValidator.On("SetOldTable", mock.Anything).Return(nil).Once()
+		mockSchemaValidator.On("Validate").Return(someError).Once()
+		job.FetchSchema()
+		mockControllerCli.AssertNumberOfCalls(ginkgo.GinkgoT(), "AckSchemaRollout", 1)
+		Ω(job.preparedVersions).Should(Equal(map[string]int{"testTable2": 3}))
+	})
+
 	ginkgo.It("run and stop should work", func() {
 		go job.Run()
 		job.Stop()
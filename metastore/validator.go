@@ -63,6 +63,10 @@ func validateColumnHLLConfig(c common.Column) error {
 				c.Type, common.Uint32, common.Int32, common.Int64, common.UUID)
 		}
 	}
+	if c.HLLConfig.Precision != 0 && (!c.HLLConfig.IsHLLColumn ||
+		c.HLLConfig.Precision < common.MinHLLPrecision || c.HLLConfig.Precision > common.DefaultHLLPrecision) {
+		return common.ErrInvalidHLLPrecision
+	}
 	return nil
 }
 
@@ -76,7 +80,11 @@ func validateColumnHLLConfig(c common.Column) error {
 //	primary key columns cannot have duplicate columnID
 //	column name cannot duplicate
 //  check hll cannot be enabled on time column
+//  check enum normalization is only set on enum columns
 //  check column configs
+//  string columns are only allowed in dimension tables
+//  decimal scale is only set on decimal columns and within range
+//  vector dimension and index lists are only set on vector columns and within range
 func (v tableSchemaValidatorImpl) validateIndividualSchema(table *common.Table, creation bool) (err error) {
 	var colIdDedup []bool
 
@@ -96,6 +104,10 @@ func (v tableSchemaValidatorImpl) validateIndividualSchema(table *common.Table,
 			return common.ErrInvalidDataType
 		} else if table.IsFactTable && columnID == 0 && dataType != memCom.Uint32 {
 			return common.ErrMissingTimeColumn
+		} else if table.IsFactTable && dataType == memCom.String {
+			// archive batches do not carry batch dictionaries, String columns are limited to
+			// dimension tables which are only stored in live batches.
+			return common.ErrInvalidStringColumn
 		}
 
 		// validate hll config
@@ -103,6 +115,38 @@ func (v tableSchemaValidatorImpl) validateIndividualSchema(table *common.Table,
 			return err
 		}
 
+		if column.EnumNormalization != nil && !column.IsEnumBasedColumn() {
+			return common.ErrInvalidEnumNormalization
+		}
+
+		if (column.DecimalScale != 0 && column.Type != common.Decimal) ||
+			column.DecimalScale < 0 || column.DecimalScale > memCom.MaxDecimalScale {
+			return common.ErrInvalidDecimalScale
+		}
+
+		if column.IsVectorColumn() != (column.VectorDimension != 0) ||
+			column.VectorDimension < 0 || column.VectorDimension > common.MaxVectorDimension {
+			return common.ErrInvalidVectorDimension
+		}
+
+		if (column.Config.VectorIndexLists != 0 && !column.IsVectorColumn()) ||
+			column.Config.VectorIndexLists < 0 || column.Config.VectorIndexLists > common.MaxVectorIndexLists {
+			return common.ErrInvalidVectorIndexLists
+		}
+
+		// 22 is the highest zstd level.
+		if column.Config.CompressionLevel < -1 || column.Config.CompressionLevel > 22 {
+			return common.ErrInvalidCompressionLevel
+		}
+
+		if !isValidColumnCodec(column) {
+			return common.ErrInvalidColumnCodec
+		}
+
+		if column.Config.Indexed && !column.IsEnumColumn() && !column.IsVectorColumn() {
+			return common.ErrIndexOnNonEnumColumn
+		}
+
 		// time column does not allow hll config
 		if table.IsFactTable && columnID == 0 && column.HLLConfig.IsHLLColumn {
 			return common.ErrTimeColumnDoesNotAllowHLLConfig
@@ -117,8 +161,19 @@ func (v tableSchemaValidatorImpl) validateIndividualSchema(table *common.Table,
 				return common.ErrHLLColumnDoesNotAllowDefaultValue
 			}
 
-			err = ValidateDefaultValue(*column.DefaultValue, column.Type)
-			if err != nil {
+			if column.IsMapColumn() {
+				return common.ErrMapColumnDoesNotAllowDefaultValue
+			}
+
+			if column.IsVectorColumn() {
+				return common.ErrVectorColumnDoesNotAllowDefault
+			}
+
+			if column.Type == common.Decimal {
+				if _, err = memCom.ParseDecimal(*column.DefaultValue, column.DecimalScale); err != nil {
+					return utils.StackError(err, "invalid value %s for type %s", *column.DefaultValue, column.Type)
+				}
+			} else if err = ValidateDefaultValue(*column.DefaultValue, column.Type); err != nil {
 				return err
 			}
 		}
@@ -143,7 +198,7 @@ func (v tableSchemaValidatorImpl) validateIndividualSchema(table *common.Table,
 			return common.ErrDuplicatedColumn
 		}
 		colDataType := memCom.DataTypeFromString(table.Columns[colId].Type)
-		if memCom.IsArrayType(colDataType) {
+		if memCom.IsArrayType(colDataType) || colDataType == memCom.String {
 			return common.ErrInvalidPrimaryKeyDataType
 		}
 		colIdDedup[colId] = true
@@ -153,6 +208,22 @@ func (v tableSchemaValidatorImpl) validateIndividualSchema(table *common.Table,
 		return utils.StackError(err, "invalid table config")
 	}
 
+	if err := validateValidityColumns(table); err != nil {
+		return err
+	}
+
+	if err := validateIngestionSourceColumn(table); err != nil {
+		return err
+	}
+
+	if table.ACL != nil {
+		for column := range table.ACL.RestrictedColumns {
+			if !colNameDedup[column] {
+				return common.ErrColumnNonExist
+			}
+		}
+	}
+
 	if table.IsFactTable {
 		colIdDedup = make([]bool, len(table.Columns))
 		for _, sortColumnId := range table.ArchivingSortColumns {
@@ -176,6 +247,58 @@ func (v tableSchemaValidatorImpl) validateIndividualSchema(table *common.Table,
 	return
 }
 
+// validateIngestionSourceColumn validates the ingestion source column of tables tracking ingestion source.
+func validateIngestionSourceColumn(table *common.Table) error {
+	if !table.TracksIngestionSource() {
+		return nil
+	}
+	for _, column := range table.Columns {
+		if column.Name == common.IngestionSourceColumnName && !column.Deleted && column.IsEnumColumn() {
+			return nil
+		}
+	}
+	return common.ErrInvalidIngestionSourceColumn
+}
+
+// validateValidityColumns validates the validity range columns of slowly changing dimension table.
+func validateValidityColumns(table *common.Table) error {
+	if table.Config.ValidFromColumn == "" {
+		if table.Config.ValidToColumn != "" {
+			return common.ErrInvalidValidityColumn
+		}
+		return nil
+	}
+
+	if table.IsFactTable {
+		return common.ErrInvalidValidityColumn
+	}
+
+	columnIDs := make(map[string]int)
+	for columnID, column := range table.Columns {
+		if !column.Deleted {
+			columnIDs[column.Name] = columnID
+		}
+	}
+
+	for _, name := range []string{table.Config.ValidFromColumn, table.Config.ValidToColumn} {
+		if name == "" {
+			continue
+		}
+		columnID, ok := columnIDs[name]
+		if !ok || memCom.DataTypeFromString(table.Columns[columnID].Type) != memCom.Uint32 {
+			return common.ErrInvalidValidityColumn
+		}
+	}
+
+	// versions of the same dimension record share the leading primary key columns.
+	numPrimaryKeyColumns := len(table.PrimaryKeyColumns)
+	if numPrimaryKeyColumns < 2 ||
+		table.PrimaryKeyColumns[numPrimaryKeyColumns-1] != columnIDs[table.Config.ValidFromColumn] {
+		return common.ErrValidFromColumnNotInPrimaryKey
+	}
+	return nil
+}
+
 // checks performed
 //	check that new table is valid table
 //	check new table has larger version number
@@ -183,6 +306,8 @@ func (v tableSchemaValidatorImpl) validateIndividualSchema(table *common.Table,
 //	check updates on columns and sort columns are valid
 //  check allowMissingEventTime cannot be changed from true to false
 //  check hllConfig cannot be changed
+//  check decimal scale cannot be changed
+//  check validity columns cannot be changed
 func (v tableSchemaValidatorImpl) validateSchemaUpdate(newTable, oldTable *common.Table) (err error) {
 	if err := v.validateIndividualSchema(newTable, false); err != nil {
 		return err
@@ -206,6 +331,11 @@ func (v tableSchemaValidatorImpl) validateSchemaUpdate(newTable, oldTable *commo
 		return common.ErrDisallowMissingEventTime
 	}
 
+	if newTable.Config.ValidFromColumn != oldTable.Config.ValidFromColumn ||
+		newTable.Config.ValidToColumn != oldTable.Config.ValidToColumn {
+		return common.ErrSchemaUpdateNotAllowed
+	}
+
 	var i int
 
 	for i = 0; i < len(oldTable.Columns); i++ {
@@ -218,13 +348,19 @@ func (v tableSchemaValidatorImpl) validateSchemaUpdate(newTable, oldTable *commo
 		}
 		// check that no column configs are modified, even for deleted columns
 		if oldCol.Name != newCol.Name ||
-			oldCol.Type != newCol.Type ||
+			(oldCol.Type != newCol.Type && !common.IsEnumPromotion(oldCol.Type, newCol.Type)) ||
 			!reflect.DeepEqual(oldCol.DefaultValue, newCol.DefaultValue) ||
 			oldCol.CaseInsensitive != newCol.CaseInsensitive ||
 			oldCol.DisableAutoExpand != newCol.DisableAutoExpand ||
-			oldCol.HLLConfig != newCol.HLLConfig {
+			oldCol.HLLConfig.IsHLLColumn != newCol.HLLConfig.IsHLLColumn ||
+			oldCol.DecimalScale != newCol.DecimalScale ||
+			oldCol.VectorDimension != newCol.VectorDimension {
 			return common.ErrSchemaUpdateNotAllowed
 		}
+		// hll values are only recomputed to lower precisions since the hashes are not kept
+		if newCol.HLLConfig.GetPrecision() > oldCol.HLLConfig.GetPrecision() {
+			return common.ErrHLLPrecisionIncrease
+		}
 	}
 	// end validate columns
 
@@ -232,6 +368,12 @@ func (v tableSchemaValidatorImpl) validateSchemaUpdate(newTable, oldTable *commo
 	if !reflect.DeepEqual(newTable.PrimaryKeyColumns, oldTable.PrimaryKeyColumns) {
 		return common.ErrChangePrimaryKeyColumn
 	}
+	// promoting enum columns changes the primary key bytes of existing records
+	for _, columnID := range oldTable.PrimaryKeyColumns {
+		if columnID < len(oldTable.Columns) && oldTable.Columns[columnID].Type != newTable.Columns[columnID].Type {
+			return common.ErrChangePrimaryKeyColumn
+		}
+	}
 
 	// sort columns
 	if len(newTable.ArchivingSortColumns) < len(oldTable.ArchivingSortColumns) {
@@ -269,3 +411,18 @@ func ValidateDefaultValue(valueStr, dataTypeStr string) (err error) {
 	}
 	return err
 }
+
+// isValidColumnCodec checks whether the codec of the column is known and can encode values
+// of its data type.
+func isValidColumnCodec(column common.Column) bool {
+	dataType := memCom.DataTypeFromString(column.Type)
+	switch column.Config.Codec {
+	case "", common.ColumnCodecNone, common.ColumnCodecZstd:
+		return true
+	case common.ColumnCodecRLE:
+		return memCom.IsRunLengthEncodable(dataType)
+	case common.ColumnCodecDelta:
+		return memCom.IsDeltaEncodable(dataType)
+	}
+	return false
+}
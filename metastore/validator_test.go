@@ -25,6 +25,90 @@ var _ = ginkgo.Describe("Validator", func() {
 		Ω(err).Should(BeNil())
 	})
 
+	ginkgo.It("should return err for masking unknown column", func() {
+		table := common.Table{
+			Name: "testTable",
+			Columns: []common.Column{
+				{
+					Name: "col1",
+					Type: "Uint32",
+				},
+			},
+			PrimaryKeyColumns: []int{0},
+			Config:            DefaultTableConfig,
+			ACL: &common.TableACL{
+				RestrictedColumns: map[string][]string{"col2": {"admins"}},
+			},
+		}
+		validator := NewTableSchameValidator()
+		validator.SetNewTable(table)
+		err := validator.Validate()
+		Ω(err).Should(Equal(common.ErrColumnNonExist))
+
+		table.ACL.RestrictedColumns = map[string][]string{"col1": {"admins"}}
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(BeNil())
+	})
+
+	ginkgo.It("should validate ingestion source column", func() {
+		config := DefaultTableConfig
+		config.TrackIngestionSource = true
+		table := common.Table{
+			Name: "testTable",
+			Columns: []common.Column{
+				{
+					Name: "col1",
+					Type: "Uint32",
+				},
+				{
+					Name: common.IngestionSourceColumnName,
+					Type: "SmallEnum",
+				},
+			},
+			PrimaryKeyColumns: []int{0},
+			Config:            config,
+		}
+		validator := NewTableSchameValidator()
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(BeNil())
+
+		// ingestion source column must be an enum column
+		table.Columns[1].Type = "Uint32"
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(Equal(common.ErrInvalidIngestionSourceColumn))
+
+		// ingestion source column must exist
+		table.Columns = table.Columns[:1]
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(Equal(common.ErrInvalidIngestionSourceColumn))
+	})
+
+	ginkgo.It("should return err for enum normalization on non enum column", func() {
+		table := common.Table{
+			Name: "testTable",
+			Columns: []common.Column{
+				{
+					Name: "col1",
+					Type: "Uint32",
+				},
+				{
+					Name:              "col2",
+					Type:              "Uint32",
+					EnumNormalization: &common.EnumNormalization{Trim: true},
+				},
+			},
+			PrimaryKeyColumns: []int{0},
+			Config:            DefaultTableConfig,
+		}
+		validator := NewTableSchameValidator()
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(Equal(common.ErrInvalidEnumNormalization))
+
+		table.Columns[1].Type = common.SmallEnum
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(BeNil())
+	})
+
 	ginkgo.It("should return err for missing time column", func() {
 		table := common.Table{
 			Name: "testTable",
@@ -448,6 +532,88 @@ var _ = ginkgo.Describe("Validator", func() {
 		Ω(err).Should(Equal(common.ErrChangePrimaryKeyColumn))
 	})
 
+	ginkgo.It("should allow promoting small enum column to big enum", func() {
+		oldTable := common.Table{
+			Name: "testTable",
+			Columns: []common.Column{
+				{
+					Name: "col1",
+					Type: "Uint32",
+				},
+				{
+					Name: "col2",
+					Type: common.SmallEnum,
+				},
+			},
+			PrimaryKeyColumns: []int{0},
+			Version:           0,
+			Config:            DefaultTableConfig,
+		}
+		newTable := oldTable
+		newTable.Columns = []common.Column{oldTable.Columns[0], oldTable.Columns[1]}
+		newTable.Columns[1].Type = common.BigEnum
+		newTable.Version = 1
+		validator := NewTableSchameValidator()
+		validator.SetNewTable(newTable)
+		validator.SetOldTable(oldTable)
+		Ω(validator.Validate()).Should(BeNil())
+
+		// demoting is not allowed
+		validator.SetNewTable(oldTable)
+		validator.SetOldTable(newTable)
+		Ω(validator.Validate()).Should(Equal(common.ErrSchemaUpdateNotAllowed))
+
+		// promoting primary key columns is not allowed
+		oldTable.PrimaryKeyColumns = []int{1}
+		newTable.PrimaryKeyColumns = []int{1}
+		validator.SetNewTable(newTable)
+		validator.SetOldTable(oldTable)
+		Ω(validator.Validate()).Should(Equal(common.ErrChangePrimaryKeyColumn))
+	})
+
+	ginkgo.It("should only allow reducing hll precision", func() {
+		oldTable := common.Table{
+			Name: "testTable",
+			Columns: []common.Column{
+				{
+					Name: "col1",
+					Type: "Uint32",
+				},
+				{
+					Name:      "col2",
+					Type:      "UUID",
+					HLLConfig: common.HLLConfig{IsHLLColumn: true},
+				},
+			},
+			PrimaryKeyColumns: []int{0},
+			Version:           0,
+			Config:            DefaultTableConfig,
+		}
+		newTable := oldTable
+		newTable.Columns = []common.Column{oldTable.Columns[0], oldTable.Columns[1]}
+		newTable.Columns[1].HLLConfig.Precision = 10
+		newTable.Version = 1
+		validator := NewTableSchameValidator()
+		validator.SetNewTable(newTable)
+		validator.SetOldTable(oldTable)
+		Ω(validator.Validate()).Should(BeNil())
+
+		// increasing is not allowed
+		validator.SetNewTable(oldTable)
+		validator.SetOldTable(newTable)
+		Ω(validator.Validate()).Should(Equal(common.ErrHLLPrecisionIncrease))
+
+		// out of range
+		newTable.Columns[1].HLLConfig.Precision = 3
+		validator.SetNewTable(newTable)
+		validator.SetOldTable(oldTable)
+		Ω(validator.Validate()).Should(Equal(common.ErrInvalidHLLPrecision))
+
+		// non hll column
+		newTable.Columns[1].HLLConfig = common.HLLConfig{Precision: 10}
+		Ω(validator.Validate()).Should(Equal(common.ErrInvalidHLLPrecision))
+	})
+
 	ginkgo.It("should fail for changing sort columns", func() {
 		oldTable := common.Table{
 			Name: "testTable",
@@ -789,4 +955,237 @@ var _ = ginkgo.Describe("Validator", func() {
 		err = validator.Validate()
 		Ω(err).Should(Equal(common.ErrInvalidSortColumnDataType))
 	})
+
+	ginkgo.It("should only allow string columns in dimension tables", func() {
+		table := common.Table{
+			Name: "testTable",
+			Columns: []common.Column{
+				{
+					Name: "col1",
+					Type: "Uint32",
+				},
+				{
+					Name: "col2",
+					Type: common.String,
+				},
+			},
+			PrimaryKeyColumns: []int{0},
+			Config:            DefaultTableConfig,
+		}
+		validator := NewTableSchameValidator()
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(BeNil())
+
+		table.PrimaryKeyColumns = []int{1}
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(Equal(common.ErrInvalidPrimaryKeyDataType))
+
+		table.PrimaryKeyColumns = []int{0}
+		table.IsFactTable = true
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(Equal(common.ErrInvalidStringColumn))
+	})
+
+	ginkgo.It("should validate compression level", func() {
+		table := common.Table{
+			Name: "testTable",
+			Columns: []common.Column{
+				{
+					Name:   "col1",
+					Type:   "Uint32",
+					Config: common.ColumnConfig{CompressionLevel: 22},
+				},
+				{
+					Name:   "col2",
+					Type:   "Uint32",
+					Config: common.ColumnConfig{CompressionLevel: -1},
+				},
+			},
+			PrimaryKeyColumns: []int{0},
+			Config:            DefaultTableConfig,
+		}
+		validator := NewTableSchameValidator()
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(BeNil())
+
+		table.Columns[1].Config.CompressionLevel = -2
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(Equal(common.ErrInvalidCompressionLevel))
+
+		table.Columns[1].Config.CompressionLevel = 23
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(Equal(common.ErrInvalidCompressionLevel))
+	})
+
+	ginkgo.It("should validate column codecs against data types", func() {
+		table := common.Table{
+			Name: "testTable",
+			Columns: []common.Column{
+				{
+					Name:   "col1",
+					Type:   "Uint32",
+					Config: common.ColumnConfig{Codec: common.ColumnCodecDelta},
+				},
+				{
+					Name:   "col2",
+					Type:   "Float32",
+					Config: common.ColumnConfig{Codec: common.ColumnCodecRLE},
+				},
+				{
+					Name:   "col3",
+					Type:   "UUID",
+					Config: common.ColumnConfig{Codec: common.ColumnCodecZstd},
+				},
+			},
+			PrimaryKeyColumns: []int{0},
+			Config:            DefaultTableConfig,
+		}
+		validator := NewTableSchameValidator()
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(BeNil())
+
+		table.Columns[1].Config.Codec = common.ColumnCodecDelta
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(Equal(common.ErrInvalidColumnCodec))
+
+		table.Columns[1].Config.Codec = common.ColumnCodecRLE
+		table.Columns[2].Config.Codec = common.ColumnCodecRLE
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(Equal(common.ErrInvalidColumnCodec))
+
+		table.Columns[2].Config.Codec = "lz4"
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(Equal(common.ErrInvalidColumnCodec))
+	})
+
+	ginkgo.It("should only allow index hints on enum columns", func() {
+		table := common.Table{
+			Name: "testTable",
+			Columns: []common.Column{
+				{
+					Name: "col1",
+					Type: "Uint32",
+				},
+				{
+					Name:   "col2",
+					Type:   "SmallEnum",
+					Config: common.ColumnConfig{Indexed: true},
+				},
+			},
+			PrimaryKeyColumns: []int{0},
+			Config:            DefaultTableConfig,
+		}
+		validator := NewTableSchameValidator()
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(BeNil())
+
+		table.Columns[0].Config.Indexed = true
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(Equal(common.ErrIndexOnNonEnumColumn))
+	})
+
+	ginkgo.It("should validate decimal scale and decimal default value", func() {
+		defaultValue := "12.5"
+		table := common.Table{
+			Name: "testTable",
+			Columns: []common.Column{
+				{
+					Name: "col1",
+					Type: "Uint32",
+				},
+				{
+					Name:         "col2",
+					Type:         common.Decimal,
+					DecimalScale: 2,
+					DefaultValue: &defaultValue,
+				},
+			},
+			PrimaryKeyColumns: []int{0},
+			Config:            DefaultTableConfig,
+		}
+		validator := NewTableSchameValidator()
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(BeNil())
+
+		defaultValue = "12.505"
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).ShouldNot(BeNil())
+
+		defaultValue = "12.5"
+		table.Columns[1].DecimalScale = 19
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(Equal(common.ErrInvalidDecimalScale))
+
+		table.Columns[1].DecimalScale = 2
+		table.Columns[0].DecimalScale = 2
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(Equal(common.ErrInvalidDecimalScale))
+
+		table.Columns[0].DecimalScale = 0
+		newTable := table
+		newTable.Columns = []common.Column{table.Columns[0], table.Columns[1]}
+		newTable.Columns[1].DecimalScale = 3
+		newTable.Version = 1
+		validator.SetOldTable(table)
+		validator.SetNewTable(newTable)
+		Ω(validator.Validate()).Should(Equal(common.ErrSchemaUpdateNotAllowed))
+	})
+
+	ginkgo.It("should validate validity columns of slowly changing dimension table", func() {
+		config := DefaultTableConfig
+		config.ValidFromColumn = "valid_from"
+		config.ValidToColumn = "valid_to"
+		table := common.Table{
+			Name: "testTable",
+			Columns: []common.Column{
+				{
+					Name: "id",
+					Type: "Uint32",
+				},
+				{
+					Name: "valid_from",
+					Type: "Uint32",
+				},
+				{
+					Name: "valid_to",
+					Type: "Uint32",
+				},
+				{
+					Name: "name",
+					Type: "SmallEnum",
+				},
+			},
+			PrimaryKeyColumns: []int{0, 1},
+			Config:            config,
+		}
+		validator := NewTableSchameValidator()
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(BeNil())
+
+		// valid from column must be in primary key
+		table.PrimaryKeyColumns = []int{0}
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(Equal(common.ErrValidFromColumnNotInPrimaryKey))
+
+		// validity columns must be uint32
+		table.PrimaryKeyColumns = []int{0, 1}
+		table.Config.ValidToColumn = "name"
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(Equal(common.ErrInvalidValidityColumn))
+
+		// fact table does not allow validity columns
+		table.Config.ValidToColumn = ""
+		table.IsFactTable = true
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(Equal(common.ErrInvalidValidityColumn))
+
+		// validity columns can not be changed
+		table.IsFactTable = false
+		oldTable := table
+		table.Config.ValidToColumn = "valid_to"
+		table.Version = 1
+		validator.SetOldTable(oldTable)
+		validator.SetNewTable(table)
+		Ω(validator.Validate()).Should(Equal(common.ErrSchemaUpdateNotAllowed))
+	})
 })
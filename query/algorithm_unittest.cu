@@ -17,6 +17,7 @@
 #include <cfloat>
 #include <algorithm>
 #include <cmath>
+#include <cstring>
 #include <exception>
 #include <iterator>
 #include <iostream>
@@ -660,6 +661,49 @@ TEST(BinaryFilterTest, CheckFilter) {
   release(boolVector);
 }
 
+// cppcheck-suppress *
+TEST(BinaryFilterTest, CheckArrayContains) {
+  const int size = 6;
+  uint32_t offsetLength[12] = {0, 2, 16, 1, 32, 3, 0, 0, 0xFFFFFFFF, 0, 56, 1};
+  uint32_t values[72] = {2, 1, 2, 0x03,
+                         1, 1, 0x01, 0,
+                         3, 1, 2, 3, 0x07, 0,
+                         1, 1, 0x01, 0};
+
+  uint8_t *lhsBasePtr = allocate_array_column(
+      reinterpret_cast<uint8_t *>(&offsetLength[0]),
+      reinterpret_cast<uint8_t *>(&values[0]), size, 72 * 4);
+
+  uint32_t indexVectorH[size] = {0, 1, 2, 3, 4, 5};
+  uint8_t boolVectorH[size] = {0, 0, 0, 0, 0, 0};
+
+  uint32_t *indexVector = allocate(&indexVectorH[0], size);
+  uint8_t *boolVector = allocate(&boolVectorH[0], size);
+
+  ArrayVectorPartySlice lhsColumn = {lhsBasePtr, 0, Uint32, size};
+  ConstantVector rhsConstant = {{.IntVal = 2}, true, ConstInt};
+
+  InputVector lhs = {{.ArrayVP = lhsColumn}, ArrayVectorPartyInput};
+  InputVector rhs = {{.Constant = rhsConstant}, ConstantInput};
+
+  CGoCallResHandle resHandle = BinaryFilter(lhs, rhs, indexVector, boolVector,
+                                            size, nullptr, 0, nullptr, 0,
+                                            ArrayContains, 0, 0);
+  EXPECT_EQ(reinterpret_cast<int64_t>(resHandle.res), 2);
+  EXPECT_EQ(resHandle.pStrErr, nullptr);
+
+  int length = reinterpret_cast<int64_t>(resHandle.res);
+
+  uint32_t expectedValues[2] = {0, 2};
+  EXPECT_TRUE(
+      equal(indexVector, indexVector + length,
+            &expectedValues[0]));
+
+  release(lhsBasePtr);
+  release(indexVector);
+  release(boolVector);
+}
+
 // cppcheck-suppress *
 TEST(BinaryTransformTest, CheckMeasureOutputIterator) {
   const int size = 3;
@@ -803,6 +847,51 @@ TEST(HashLookupTest, CheckLookup) {
   release(outputValuesD);
 }
 
+// cppcheck-suppress *
+TEST(AsOfLookupTest, CheckLookup) {
+  // two versions of the same record at index 0 and 1 of batch -10.
+  uint32_t validFromsH[4] = {0, 100, 0, 0};
+  uint32_t validTosH[4] = {100, 200, 0, 0};
+  RecordID prevRecordIDsH[4] = {{0, 0}, {-10, 0}, {0, 0}, {0, 0}};
+  uint32_t *validFroms = allocate(&validFromsH[0], 4);
+  uint32_t *validTos = allocate(&validTosH[0], 4);
+  RecordID *prevRecordIDs = allocate(&prevRecordIDsH[0], 4);
+  VersionIndex versionIndex = {validFroms, validTos, prevRecordIDs, 4, -10};
+
+  const int size = 5;
+  uint32_t indexVectorH[size] = {0, 1, 2, 3, 4};
+  uint32_t eventTimesH[size] = {50, 150, 250, 100, 10};
+  uint8_t eventTimeNullsH[1] = {0xFF};
+  uint8_t *basePtr =
+      allocate_column(nullptr, &eventTimeNullsH[0], &eventTimesH[0], 0, 1,
+                      size * 4);
+  uint32_t *indexVector = allocate(&indexVectorH[0], size);
+
+  // latest versions looked up from hash index.
+  RecordID recordIDsH[size] = {{-10, 1}, {-10, 1}, {-10, 1}, {-10, 1},
+                               {0, 0}};
+  RecordID *recordIDsD = allocate(&recordIDsH[0], size);
+
+  DefaultValue defaultValue = {false, {.Int32Val = 0}};
+  VectorPartySlice inputVP = {basePtr, 0, 8, 0, Uint32, defaultValue, size};
+  InputVector input = {{.VP = inputVP}, VectorPartyInput};
+  AsOfLookup(input, recordIDsD, indexVector, size, nullptr, 0, versionIndex,
+             0, 0);
+
+  RecordID expectedRecordIDs[size] = {{-10, 0}, {-10, 1}, {0, 0}, {-10, 1},
+                                      {0, 0}};
+  EXPECT_TRUE(
+      equal(reinterpret_cast<uint8_t *>(recordIDsD),
+            reinterpret_cast<uint8_t *>(recordIDsD) + sizeof(RecordID) * size,
+            reinterpret_cast<uint8_t *>(&expectedRecordIDs[0])));
+  release(validFroms);
+  release(validTos);
+  release(prevRecordIDs);
+  release(basePtr);
+  release(indexVector);
+  release(recordIDsD);
+}
+
 // cppcheck-suppress *
 TEST(HashLookupTest, CheckUUID) {
   // hash index created in golang cuckoo_hash_index
@@ -1550,6 +1639,98 @@ TEST(GeoBatchIntersectTest, CheckInShape) {
   release(geoShapes);
 }
 
+// cppcheck-suppress *
+TEST(GeoCellTest, CheckS2LeafCellID) {
+  // same values as GeoCellID in memstore/common/geo_cell_test.go.
+  EXPECT_EQ(get_s2_leaf_cell_id(0, 0), 0x1000000000000001);
+  EXPECT_EQ(get_s2_leaf_cell_id(37.775, -122.418), 0x8085809c2c965037);
+  EXPECT_EQ(get_s2_leaf_cell_id(-33.8688, 151.2093), 0x6b12ae3ff6290ac5);
+  // faces.
+  EXPECT_EQ(get_s2_leaf_cell_id(0, 90) >> 61, 1u);
+  EXPECT_EQ(get_s2_leaf_cell_id(90, 0) >> 61, 2u);
+  EXPECT_EQ(get_s2_leaf_cell_id(0, 180) >> 61, 3u);
+  EXPECT_EQ(get_s2_leaf_cell_id(0, -90) >> 61, 4u);
+  EXPECT_EQ(get_s2_leaf_cell_id(-90, 0) >> 61, 5u);
+}
+
+// cppcheck-suppress *
+TEST(GeoBatchIntersectTest, CheckCellIndex) {
+  // Same shapes and points as CheckInShape.
+  float shapeLatsH[20] = {1, 1, -1, -1, 1,       3, 2, 4, 3, 0,
+                           3, 3, 0,  0,  FLT_MAX, 1, 2, 2, 1, 1};
+  float shapeLongsH[20] = {1, -1, -1, 1, 1,       3, 2, 2, 3, 6,
+                            6, 3,  3,  6, FLT_MAX, 5, 5, 4, 4, 5};
+  uint8_t shapeIndexsH[20] = {0, 0, 0, 0, 0, 1, 1, 1, 1, 2,
+                              2, 2, 2, 2, 2, 2, 2, 2, 2, 2};
+  GeoShapeBatch geoShapes =
+      get_geo_shape_batch(shapeLatsH, shapeLongsH, shapeIndexsH, 3, 20);
+
+  // Ranges of s2 leaf cells before and after the leaf cell of point (3, 2.5)
+  // are covered by shape 1 and shape 3, the leaf cell itself is not covered
+  // by any shape so the point is not a candidate of shape 2 and its edges
+  // should not be tested.
+  uint64_t pointCellID = get_s2_leaf_cell_id(3, 2.5);
+  uint64_t cellIndexH[5] = {1, pointCellID, pointCellID + 2};
+  uint32_t cellMasksH[3] = {5, 0, 5};
+  memcpy(&cellIndexH[3], cellMasksH, sizeof(cellMasksH));
+  uint64_t *cellIndex = allocate(cellIndexH, 5);
+  geoShapes.CellIndex = cellIndex;
+  geoShapes.NumCells = 3;
+
+  uint32_t indexVectorH[5] = {0, 1, 2, 3, 4};
+  uint32_t *indexVector = allocate(indexVectorH, 5);
+
+  // 5 points (0,0),(3,2.5),(1.5, 3.5),(1.5,4.5),null
+  //           in 1   skipped in 3       out      out
+  GeoPointT pointsH[5] = {{0, 0}, {3, 2.5}, {1.5, 3.5}, {1.5, 4.5}, {0, 0}};
+  uint8_t nullsH[1] = {0x0F};
+
+  uint32_t outputPredicateH[5] = {0};
+  uint32_t *outputPredicate = allocate(outputPredicateH, 5);
+
+  bool inOrOut = true;
+
+  uint8_t *basePtr =
+      allocate_column(nullptr, &nullsH[0], &pointsH[0], 0, 1, 40);
+
+  DefaultValue defaultValue = {false};
+  VectorPartySlice inputVP = {basePtr, 0, 8, 0, GeoPoint, defaultValue, 5};
+  InputVector points = {{.VP = inputVP}, VectorPartyInput};
+
+  CGoCallResHandle resHandle =
+      GeoBatchIntersects(geoShapes, points, indexVector, 5, 0, nullptr, 0,
+                         outputPredicate, inOrOut, 0, 0);
+
+  EXPECT_EQ(reinterpret_cast<int64_t>(resHandle.res), 2);
+  EXPECT_EQ(resHandle.pStrErr, nullptr);
+  uint32_t expectedOutputPredicate[5] = {1, 0, 4, 0, 0};
+  EXPECT_TRUE(
+      equal(outputPredicate, outputPredicate + 5, expectedOutputPredicate));
+  release(outputPredicate);
+  release(indexVector);
+
+  // not in any shape, null point is excluded.
+  indexVector = allocate(indexVectorH, 5);
+  outputPredicate = allocate(outputPredicateH, 5);
+  resHandle =
+      GeoBatchIntersects(geoShapes, points, indexVector, 5, 0, nullptr, 0,
+                         outputPredicate, false, 0, 0);
+
+  EXPECT_EQ(reinterpret_cast<int64_t>(resHandle.res), 2);
+  EXPECT_EQ(resHandle.pStrErr, nullptr);
+  uint32_t expectedOutputPredicate2[5] = {1, 0, 4, 0, 1};
+  EXPECT_TRUE(
+      equal(outputPredicate, outputPredicate + 5, expectedOutputPredicate2));
+  uint32_t expectedIndexVector[2] = {1, 3};
+  EXPECT_TRUE(equal(indexVector, indexVector + 2, expectedIndexVector));
+
+  release(outputPredicate);
+  release(indexVector);
+  release(basePtr);
+  release(cellIndex);
+  release(geoShapes);
+}
+
 // cppcheck-suppress *
 TEST(GeoBatchIntersectTest, CheckRecordIDJoinIterator) {
   // 3 shapes
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"github.com/uber/aresdb/cgoutils"
 	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
 	"time"
 	"unsafe"
 )
@@ -129,6 +130,11 @@ func (e *BatchExecutorImpl) join() {
 				mainTableJoinColumnIndex := e.qc.TableScanners[0].ColumnsByIDs[foreignTable.remoteJoinColumn.ColumnID]
 				// perform hash lookup
 				e.qc.OOPK.currentBatch.prepareForeignRecordIDs(mainTableJoinColumnIndex, joinTableID, *foreignTable, e.stream, e.qc.Device)
+				if foreignTable.asOfJoin {
+					// join the versions valid at event time
+					mainTableTimeColumnIndex := e.qc.TableScanners[0].ColumnsByIDs[0]
+					e.qc.OOPK.currentBatch.resolveForeignRecordVersions(mainTableTimeColumnIndex, joinTableID, *foreignTable, e.stream, e.qc.Device)
+				}
 			}
 		}
 		e.qc.reportTimingForCurrentBatch(e.stream, &e.start, prepareForeignRecordIDsTiming)
@@ -251,10 +257,62 @@ func (e *BatchExecutorImpl) reduce() {
 		}, "reduce", e.stream)
 	}
 	cgoutils.WaitForCudaStream(e.stream, e.qc.Device)
+	e.checkDimensionCardinality()
+}
+
+// checkDimensionCardinality stops processing further batches if any dimension has more
+// distinct values after reduction than the configured max dimension cardinality. Dimension
+// values are only copied to host for counting when the number of groups exceeds the limit,
+// since no dimension can exceed it otherwise. Reduction only adds groups, so to avoid
+// stalling the stream for every batch dimensions are only recounted once the number of
+// groups doubled since the last check, and for the last batch.
+func (e *BatchExecutorImpl) checkDimensionCardinality() {
+	limit := utils.GetConfig().Query.MaxDimensionCardinality
+	ctx := &e.qc.OOPK
+	resultSize := ctx.currentBatch.resultSize
+	if limit <= 0 || ctx.IsHLL() || resultSize <= limit || resultSize == ctx.dimCardinalityCheckedSize ||
+		(!e.isLastBatch && resultSize < 2*ctx.dimCardinalityCheckedSize) {
+		return
+	}
+	ctx.dimCardinalityCheckedSize = resultSize
+
+	// the pinned buffer is sized by result capacity to be reused until results are expanded.
+	if ctx.dimCardinalityVectorCapacity < resultSize {
+		if ctx.dimCardinalityVectorH != nil {
+			cgoutils.HostFree(ctx.dimCardinalityVectorH)
+		}
+		ctx.dimCardinalityVectorCapacity = ctx.currentBatch.resultCapacity
+		ctx.dimCardinalityVectorH = cgoutils.HostAlloc(ctx.dimCardinalityVectorCapacity * ctx.DimRowBytes)
+	}
+	dimensionVectorH := ctx.dimCardinalityVectorH
+	asyncCopyDimensionVector(dimensionVectorH, ctx.currentBatch.dimensionVectorD[0].getPointer(), resultSize, 0,
+		ctx.NumDimsPerDimWidth, resultSize, ctx.currentBatch.resultCapacity,
+		cgoutils.AsyncCopyDeviceToHost, e.stream, e.qc.Device)
+	cgoutils.WaitForCudaStream(e.stream, e.qc.Device)
+
+	for dimIndex, dim := range e.qc.Query.Dimensions {
+		cardinality := queryCom.GetDimensionCardinality(dimensionVectorH, ctx.NumDimsPerDimWidth,
+			ctx.DimensionVectorIndex[dimIndex], resultSize)
+		if cardinality <= limit {
+			continue
+		}
+
+		liveStats, archiveStats := e.qc.OOPK.LiveBatchStats, e.qc.OOPK.ArchiveBatchStats
+		e.qc.OOPK.dimCardinalityErr = utils.StackError(nil,
+			"dimension %s has %d distinct values exceeding max dimension cardinality %d, aborted after processing %d live batches (%d records) and %d archive batches (%d records)",
+			dim.Expr, cardinality, limit,
+			liveStats.NumBatches, liveStats.NumRecords, archiveStats.NumBatches, archiveStats.NumRecords)
+		e.qc.OOPK.done = true
+		utils.GetRootReporter().GetChildCounter(map[string]string{
+			"table": e.qc.Query.Table,
+		}, utils.QueryDimCardinalityExceeded).Inc(1)
+		return
+	}
 }
 
 func (e *BatchExecutorImpl) preExec(isLastBatch bool, start time.Time) {
 	e.isLastBatch = isLastBatch
+	e.qc.startDeviceTimer(e.stream)
 	// initialize index vector.
 	if !e.qc.OOPK.currentBatch.indexVectorD.isNull() {
 		initIndexVector(e.qc.OOPK.currentBatch.indexVectorD.getPointer(), 0, e.qc.OOPK.currentBatch.size, e.stream, e.qc.Device)
@@ -263,6 +321,7 @@ func (e *BatchExecutorImpl) preExec(isLastBatch bool, start time.Time) {
 }
 
 func (e *BatchExecutorImpl) postExec(start time.Time) {
+	e.qc.stopDeviceTimer(e.stream)
 	// swap result buffer before next batch
 	e.qc.OOPK.currentBatch.swapResultBufferForNextBatch()
 	e.qc.reportTimingForCurrentBatch(e.stream, &start, cleanupTiming)
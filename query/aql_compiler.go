@@ -18,7 +18,9 @@ package query
 import "C"
 
 import (
+	"encoding/json"
 	"github.com/uber/aresdb/cluster/topology"
+	"net/http"
 	"sort"
 	"strings"
 	"unsafe"
@@ -42,6 +44,20 @@ const (
 // Compile compiles AQLQueryContext for data feeding and query
 // execution. Caller should check for AQLQueryContext.Error.
 func (qc *AQLQueryContext) Compile(tableSchemaReader memCom.TableSchemaReader, shardOwner topology.ShardOwner) {
+	if !common.IsValidDataScope(qc.Query.DataScope) {
+		qc.Error = utils.StackError(nil, "unknown data scope %s", qc.Query.DataScope)
+		return
+	}
+	if !common.IsValidUnknownEnumValuePolicy(qc.Query.UnknownEnumValue) {
+		qc.Error = utils.StackError(nil, "unknown enum value policy %s", qc.Query.UnknownEnumValue)
+		return
+	}
+
+	// keep the query before compilation for splitting it across devices.
+	if utils.GetConfig().Query.MaxDevicesPerQuery > 1 {
+		qc.rawQuery, _ = json.Marshal(qc.Query)
+	}
+
 	// processTimezone might append additional joins
 	qc.processTimezone()
 	if qc.Error != nil {
@@ -50,7 +66,6 @@ func (qc *AQLQueryContext) Compile(tableSchemaReader memCom.TableSchemaReader, s
 
 	// Read schema for every table used.
 	qc.readSchema(tableSchemaReader, shardOwner)
-	defer qc.releaseSchema()
 	if qc.Error != nil {
 		return
 	}
@@ -61,6 +76,9 @@ func (qc *AQLQueryContext) Compile(tableSchemaReader memCom.TableSchemaReader, s
 		return
 	}
 
+	// Report functions and operators used before the ASTs are rewritten.
+	qc.reportFeatureUsage()
+
 	// Resolve data types in the ASTs against schema, also translate enum values.
 	qc.resolveTypes()
 	if qc.Error != nil {
@@ -102,6 +120,52 @@ func (qc *AQLQueryContext) Compile(tableSchemaReader memCom.TableSchemaReader, s
 	// TODO: VM instruction generation
 }
 
+// reportFeatureUsage emits one counter for each distinct function or operator used by the query,
+// tagged by query origin, so that adoption can be measured before changing their behavior.
+func (qc *AQLQueryContext) reportFeatureUsage() {
+	features := make(map[string]string)
+	collector := func(e expr.Expr) {
+		switch e := e.(type) {
+		case *expr.Call:
+			features[e.Name] = "function"
+		case *expr.UnaryExpr:
+			features[e.Op.String()] = "operator"
+		case *expr.BinaryExpr:
+			features[e.Op.String()] = "operator"
+		}
+	}
+
+	for _, join := range qc.Query.Joins {
+		for _, cond := range join.ConditionsParsed {
+			expr.WalkFunc(cond, collector)
+		}
+	}
+	for _, filter := range qc.Query.FiltersParsed {
+		expr.WalkFunc(filter, collector)
+	}
+	for _, dim := range qc.Query.Dimensions {
+		expr.WalkFunc(dim.ExprParsed, collector)
+	}
+	for _, measure := range qc.Query.Measures {
+		expr.WalkFunc(measure.ExprParsed, collector)
+		for _, filter := range measure.FiltersParsed {
+			expr.WalkFunc(filter, collector)
+		}
+	}
+
+	origin := qc.Origin
+	if origin == "" {
+		origin = "UNKNOWN"
+	}
+	for feature, kind := range features {
+		utils.GetRootReporter().GetChildCounter(map[string]string{
+			"feature": feature,
+			"kind":    kind,
+			"origin":  origin,
+		}, utils.QueryFeatureUsed).Inc(1)
+	}
+}
+
 // adjustFilterToTimeFilter try to find one rowfilter to be time filter if there is no timefilter for fact table query
 func (qc *AQLQueryContext) adjustFilterToTimeFilter() {
 	toBeRemovedFilters := []int{}
@@ -274,6 +338,8 @@ func isGeoJoin(j common.Join) bool {
 // 5. foreign table primary key can have only one column
 // 6. every foreign table must be joined directly to the main table, i.e. no bridges?
 // 7. up to 8 foreign tables
+// For slowly changing dimension table, the validFrom column is excluded from the primary key and
+// the version valid at the event time of the main table will be joined.
 func (qc *AQLQueryContext) matchEqualJoin(joinTableID int, joinSchema *memCom.TableSchema, conditions []expr.Expr) {
 	if len(conditions) != 1 {
 		qc.Error = utils.StackError(nil, "%d join conditions expected, got %d", 1, len(conditions))
@@ -286,8 +352,15 @@ func (qc *AQLQueryContext) matchEqualJoin(joinTableID int, joinSchema *memCom.Ta
 		return
 	}
 
+	primaryKeyColumns := joinSchema.Schema.PrimaryKeyColumns
+	asOfJoin := joinSchema.Schema.IsSlowlyChangingDimension()
+	if asOfJoin {
+		// versions are looked up by primary key columns except the validFrom column
+		primaryKeyColumns = primaryKeyColumns[:len(primaryKeyColumns)-1]
+	}
+
 	// one foreign table primary key columns only
-	if len(joinSchema.Schema.PrimaryKeyColumns) > 1 {
+	if len(primaryKeyColumns) > 1 {
 		qc.Error = utils.StackError(nil, "composite key not supported")
 		return
 	}
@@ -340,6 +413,32 @@ func (qc *AQLQueryContext) matchEqualJoin(joinTableID int, joinSchema *memCom.Ta
 		tableScanners: qc.TableScanners,
 		usages:        columnUsedByAllBatches,
 	}, left)
+
+	if asOfJoin {
+		qc.matchAsOfJoin(joinTableID, joinSchema)
+	}
+}
+
+// matchAsOfJoin sets up the as of join against slowly changing dimension table, versions will be
+// matched against event time of the main table which must be a fact table.
+func (qc *AQLQueryContext) matchAsOfJoin(joinTableID int, joinSchema *memCom.TableSchema) {
+	mainTableSchema := qc.TableSchemaByName[qc.Query.Table]
+	if !mainTableSchema.Schema.IsFactTable {
+		qc.Error = utils.StackError(nil,
+			"main table must be fact table to join slowly changing dimension table %s", joinSchema.Schema.Name)
+		return
+	}
+
+	ft := qc.OOPK.foreignTables[joinTableID]
+	ft.asOfJoin = true
+	ft.validFromColumnID = joinSchema.ColumnIDs[joinSchema.Schema.Config.ValidFromColumn]
+	ft.validToColumnID = -1
+	if validToColumn := joinSchema.Schema.Config.ValidToColumn; validToColumn != "" {
+		ft.validToColumnID = joinSchema.ColumnIDs[validToColumn]
+	}
+
+	// versions are matched against event time column of main table.
+	qc.TableScanners[0].ColumnUsages[0] |= columnUsedByAllBatches
 }
 
 func (qc *AQLQueryContext) parseExprs() {
@@ -411,7 +510,10 @@ func (qc *AQLQueryContext) parseExprs() {
 				return
 			}
 			if _, ok := dim.ExprParsed.(*expr.Wildcard); ok {
-				qc.Query.Dimensions = append(qc.Query.Dimensions, qc.getAllColumnsDimension()...)
+				for _, columnDim := range qc.getAllColumnsDimension() {
+					qc.wildcardDimensions = append(qc.wildcardDimensions, len(qc.Query.Dimensions))
+					qc.Query.Dimensions = append(qc.Query.Dimensions, columnDim)
+				}
 			} else {
 				qc.Query.Dimensions = append(qc.Query.Dimensions, dim)
 			}
@@ -479,8 +581,17 @@ func (qc *AQLQueryContext) readSchema(tableSchemaReader memCom.TableSchemaReader
 		qc.Error = utils.StackError(nil, "unknown main table %s", qc.Query.Table)
 		return
 	}
+	// compile against immutable snapshots so that schema writers are never blocked.
+	schema = schema.Snapshot()
+	if !qc.canReadTable(schema) {
+		qc.Error = qc.accessDenied("table %s", qc.Query.Table)
+		return
+	}
+	if err := qc.checkSchemaVersion(schema); err != nil {
+		qc.Error = err
+		return
+	}
 	qc.TableSchemaByName[qc.Query.Table] = schema
-	schema.RLock()
 	qc.TableScanners[0] = &TableScanner{}
 	qc.TableScanners[0].Schema = schema
 
@@ -501,17 +612,26 @@ func (qc *AQLQueryContext) readSchema(tableSchemaReader memCom.TableSchemaReader
 
 	// Foreign tables.
 	for i, join := range qc.Query.Joins {
+		if join.InlineTable != nil {
+			qc.Error = utils.StackError(nil, "inline table %s is only supported by broker", join.Alias)
+			return
+		}
 		schema = tableSchemaReader.GetSchemas()[join.Table]
 		if schema == nil {
 			qc.Error = utils.StackError(nil, "unknown join table %s", join.Table)
 			return
 		}
 
-		if qc.TableSchemaByName[join.Table] == nil {
-			qc.TableSchemaByName[join.Table] = schema
-			// Prevent double locking.
-			schema.RLock()
+		schema = schema.Snapshot()
+		if !qc.canReadTable(schema) {
+			qc.Error = qc.accessDenied("table %s", join.Table)
+			return
+		}
+		if err := qc.checkSchemaVersion(schema); err != nil {
+			qc.Error = err
+			return
 		}
+		qc.TableSchemaByName[join.Table] = schema
 
 		qc.TableScanners[1+i] = &TableScanner{}
 		qc.TableScanners[1+i].Schema = schema
@@ -541,9 +661,33 @@ func (qc *AQLQueryContext) readSchema(tableSchemaReader memCom.TableSchemaReader
 	}
 }
 
-func (qc *AQLQueryContext) releaseSchema() {
-	for _, schema := range qc.TableSchemaByName {
-		schema.RUnlock()
+// canReadTable checks the read permission of the principal on the table.
+func (qc *AQLQueryContext) canReadTable(schema *memCom.TableSchema) bool {
+	return qc.Principal == nil || schema.Schema.ACL.CanRead(qc.Principal.Identities())
+}
+
+// canReadColumn checks whether the column is restricted for the principal.
+func (qc *AQLQueryContext) canReadColumn(schema *memCom.TableSchema, column string) bool {
+	return qc.Principal == nil || schema.Schema.ACL.CanReadColumn(column, qc.Principal.Identities())
+}
+
+// checkSchemaVersion rejects the query if it was compiled by broker against another version of
+// the table schema, e.g. when the datanode has not applied a committed schema rollout yet.
+func (qc *AQLQueryContext) checkSchemaVersion(schema *memCom.TableSchema) error {
+	expected, ok := qc.Query.SchemaVersions[schema.Schema.Name]
+	if !ok || expected == schema.Schema.Version {
+		return nil
+	}
+	return utils.APIError{
+		Code:    http.StatusConflict,
+		Message: fmt.Sprintf("schema of table %s is of version %d, query expects version %d", schema.Schema.Name, schema.Schema.Version, expected),
+	}
+}
+
+func (qc *AQLQueryContext) accessDenied(format string, args ...interface{}) error {
+	return utils.APIError{
+		Code:    http.StatusForbidden,
+		Message: fmt.Sprintf("principal %s is not allowed to read %s", qc.Principal.Name, fmt.Sprintf(format, args...)),
 	}
 }
 
@@ -565,6 +709,9 @@ func (qc *AQLQueryContext) resolveColumn(identifier string) (int, int, error) {
 
 	columnID, exists := qc.TableScanners[tableID].Schema.ColumnIDs[column]
 	if !exists {
+		if systemColumn, isSystemColumn := memCom.GetSystemColumn(column); isSystemColumn && tableID == 0 {
+			return tableID, systemColumn.ID, nil
+		}
 		return 0, 0, utils.StackError(nil, "unknown column %s for table alias %s",
 			column, tableAlias)
 	}
@@ -572,16 +719,66 @@ func (qc *AQLQueryContext) resolveColumn(identifier string) (int, int, error) {
 	return tableID, columnID, nil
 }
 
-func blockNumericOpsForColumnOverFourBytes(token expr.Token, expressions ...expr.Expr) error {
-	if token == expr.UNARY_MINUS || token == expr.BITWISE_NOT ||
-		(token >= expr.ADD && token <= expr.BITWISE_LEFT_SHIFT) {
-		for _, expression := range expressions {
-			if varRef, isVarRef := expression.(*expr.VarRef); isVarRef && memCom.DataTypeBytes(varRef.DataType) > 4 {
-				return utils.StackError(nil, "numeric operations not supported for column over 4 bytes length, got %s", expression.String())
-			}
+// timeInSeconds converts values of Time64 columns from milliseconds to seconds since epoch for
+// date functions and time bucketizers, other expressions are returned as is.
+func (qc *AQLQueryContext) timeInSeconds(timeExpr expr.Expr) expr.Expr {
+	varRef, ok := timeExpr.(*expr.VarRef)
+	if !ok {
+		return timeExpr
+	}
+	tableID, columnID, err := qc.resolveColumn(varRef.Val)
+	if err != nil || memCom.IsSystemColumn(columnID) ||
+		!qc.TableScanners[tableID].Schema.Schema.Columns[columnID].IsTime64Column() {
+		return timeExpr
+	}
+	return &expr.UnaryExpr{Op: expr.MILLIS_TO_SECONDS, Expr: timeExpr, ExprType: expr.Unsigned}
+}
+
+// rewriteInt64Op moves the number literal compared with or applied on an Int64 or Decimal
+// column to the rhs and scales it by the decimal scale of the column, so that kernels can
+// operate on the 8 bytes column values directly.
+func (qc *AQLQueryContext) rewriteInt64Op(e *expr.BinaryExpr, column *expr.VarRef,
+	literal *expr.NumberLiteral, literalOnLHS bool) expr.Expr {
+	if literalOnLHS {
+		e.LHS, e.RHS = e.RHS, e.LHS
+		switch e.Op {
+		case expr.LT:
+			e.Op = expr.GT
+		case expr.LTE:
+			e.Op = expr.GTE
+		case expr.GT:
+			e.Op = expr.LT
+		case expr.GTE:
+			e.Op = expr.LTE
+		}
+	}
+
+	// Multipliers are not scaled since the product keeps the scale of the column.
+	scale := 0
+	if column.DataType == memCom.Decimal && e.Op != expr.MUL {
+		scale = column.DecimalScale
+	}
+	str := strconv.Itoa(literal.Int)
+	if literal.Type() == expr.Float {
+		str = literal.Expr
+		if str == "" {
+			str = strconv.FormatFloat(literal.Val, 'f', -1, 64)
 		}
 	}
-	return nil
+	value, err := memCom.ParseDecimal(str, scale)
+	if err != nil {
+		qc.Error = utils.StackError(err, "invalid literal %s for column %s", str, column.Val)
+		return e
+	}
+	e.RHS = &expr.NumberLiteral{Int: int(value), Val: float64(value), ExprType: expr.Signed}
+
+	switch e.Op {
+	case expr.ADD, expr.SUB, expr.MUL:
+		e.ExprType = expr.Signed
+	default:
+		e.ExprType = expr.Boolean
+	}
+	return e
 }
 
 // Rewrite walks the expresison AST and resolves data types bottom up.
@@ -597,12 +794,24 @@ func (qc *AQLQueryContext) Rewrite(expression expr.Expr) expr.Expr {
 			qc.Error = err
 			return expression
 		}
+		if memCom.IsSystemColumn(columnID) {
+			e.DataType = memCom.GetSystemColumnType(columnID)
+			e.ExprType = common.DataTypeToExprType[e.DataType]
+			e.TableID = tableID
+			e.ColumnID = columnID
+			return expression
+		}
 		column := qc.TableScanners[tableID].Schema.Schema.Columns[columnID]
 		if column.Deleted {
 			qc.Error = utils.StackError(nil, "column %s of table %s has been deleted",
 				column.Name, qc.TableScanners[tableID].Schema.Schema.Name)
 			return expression
 		}
+		if !qc.canReadColumn(qc.TableScanners[tableID].Schema, column.Name) {
+			qc.Error = qc.accessDenied("restricted column %s of table %s", column.Name,
+				qc.TableScanners[tableID].Schema.Schema.Name)
+			return expression
+		}
 		dataType := qc.TableScanners[tableID].Schema.ValueTypeByColumn[columnID]
 		e.ExprType = common.DataTypeToExprType[dataType]
 		e.TableID = tableID
@@ -612,17 +821,35 @@ func (qc *AQLQueryContext) Rewrite(expression expr.Expr) expr.Expr {
 		e.EnumReverseDict = dict.ReverseDict
 		e.DataType = dataType
 		e.IsHLLColumn = column.HLLConfig.IsHLLColumn
+		e.HLLPrecision = column.HLLConfig.GetPrecision()
+		e.DecimalScale = column.DecimalScale
+		e.IsMapColumn = column.IsMapColumn()
+		e.StringDict = qc.TableScanners[tableID].Schema.GetStringDict(columnID)
 	case *expr.UnaryExpr:
 		if expr.IsUUIDColumn(e.Expr) && e.Op != expr.GET_HLL_VALUE {
 			qc.Error = utils.StackError(nil, "uuid column type only supports countdistincthll unary expression")
 			return expression
 		}
 
-		if err := blockNumericOpsForColumnOverFourBytes(e.Op, e.Expr); err != nil {
+		if err := common.BlockNumericOpsForColumnOverFourBytes(e.Op, e.Expr); err != nil {
 			qc.Error = err
 			return expression
 		}
 
+		if err := common.BlockOpsForStringColumn(e.Op, e.Expr); err != nil {
+			qc.Error = err
+			return expression
+		}
+
+		if qc.Query.TriValuedLogic {
+			if e.Op == expr.IS_TRUE || e.Op == expr.IS_FALSE {
+				return common.RewriteTriValuedIs(e)
+			}
+			if rewritten, ok := common.RewriteTriValuedNull(e); ok {
+				return rewritten
+			}
+		}
+
 		e.ExprType = e.Expr.Type()
 		switch e.Op {
 		case expr.EXCLAMATION, expr.NOT, expr.IS_FALSE:
@@ -668,16 +895,36 @@ func (qc *AQLQueryContext) Rewrite(expression expr.Expr) expr.Expr {
 		case expr.GET_HLL_VALUE:
 			e.ExprType = expr.Unsigned
 			e.Expr = expr.Cast(e.Expr, expr.Unsigned)
+		case expr.MILLIS_TO_SECONDS:
+			e.ExprType = expr.Unsigned
 		default:
 			qc.Error = utils.StackError(nil, "unsupported unary expression %s",
 				e.String())
 		}
 	case *expr.BinaryExpr:
-		if err := blockNumericOpsForColumnOverFourBytes(e.Op, e.LHS, e.RHS); err != nil {
+		if qc.Query.TriValuedLogic {
+			if rewritten, ok := common.RewriteTriValuedNull(e); ok {
+				return rewritten
+			}
+		}
+
+		if err := common.BlockNumericOpsForColumnOverFourBytes(e.Op, e.LHS, e.RHS); err != nil {
 			qc.Error = err
 			return expression
 		}
 
+		if err := common.BlockOpsForStringColumn(e.Op, e.LHS, e.RHS); err != nil {
+			qc.Error = err
+			return expression
+		}
+
+		if column, literal, literalOnLHS, ok := common.Int64ColumnAndLiteral(e.LHS, e.RHS); ok {
+			switch e.Op {
+			case expr.ADD, expr.SUB, expr.MUL, expr.EQ, expr.NEQ, expr.LT, expr.LTE, expr.GT, expr.GTE:
+				return qc.rewriteInt64Op(e, column, literal, literalOnLHS)
+			}
+		}
+
 		if e.Op != expr.EQ && e.Op != expr.NEQ {
 			_, isRHSStr := e.RHS.(*expr.StringLiteral)
 			_, isLHSStr := e.LHS.(*expr.StringLiteral)
@@ -758,6 +1005,15 @@ func (qc *AQLQueryContext) Rewrite(expression expr.Expr) expr.Expr {
 					// short circuiting hard.
 					// To play it safe we match against an invalid value.
 					value = -1
+					qc.checkUnknownEnumValue(lhs.Val, rhs.Val)
+				}
+				e.RHS = &expr.NumberLiteral{Int: value, ExprType: expr.Unsigned}
+			} else if lhs != nil && rhs != nil && lhs.StringDict != nil {
+				// String values are compared by ids of the global dictionary, values not seen
+				// by any batch match against an invalid id.
+				value := -1
+				if id, exists := lhs.StringDict.Lookup(rhs.Val); exists {
+					value = int(id)
 				}
 				e.RHS = &expr.NumberLiteral{Int: value, ExprType: expr.Unsigned}
 			} else {
@@ -798,45 +1054,15 @@ func (qc *AQLQueryContext) Rewrite(expression expr.Expr) expr.Expr {
 		e.Name = strings.ToLower(e.Name)
 		switch e.Name {
 		case expr.ConvertTzCallName:
-			if len(e.Args) != 3 {
-				qc.Error = utils.StackError(
-					nil, "convert_tz must have 3 arguments",
-				)
-				break
-			}
-			fromTzStringExpr, isStrLiteral := e.Args[1].(*expr.StringLiteral)
-			if !isStrLiteral {
-				qc.Error = utils.StackError(nil, "2nd argument of convert_tz must be a string")
-				break
+			if len(e.Args) > 0 {
+				e.Args[0] = qc.timeInSeconds(e.Args[0])
 			}
-			toTzStringExpr, isStrLiteral := e.Args[2].(*expr.StringLiteral)
-			if !isStrLiteral {
-				qc.Error = utils.StackError(nil, "3rd argument of convert_tz must be a string")
-				break
-			}
-			fromTz, err := common.ParseTimezone(fromTzStringExpr.Val)
+			rewritten, err := common.RewriteConvertTz(e, qc.Query.Strict, qc.fromTime, qc.toTime)
 			if err != nil {
-				qc.Error = utils.StackError(err, "failed to rewrite convert_tz")
-				break
-			}
-			toTz, err := common.ParseTimezone(toTzStringExpr.Val)
-			if err != nil {
-				qc.Error = utils.StackError(err, "failed to rewrite convert_tz")
+				qc.Error = err
 				break
 			}
-			_, fromOffsetInSeconds := utils.Now().In(fromTz).Zone()
-			_, toOffsetInSeconds := utils.Now().In(toTz).Zone()
-			offsetInSeconds := toOffsetInSeconds - fromOffsetInSeconds
-			return &expr.BinaryExpr{
-				Op:  expr.ADD,
-				LHS: e.Args[0],
-				RHS: &expr.NumberLiteral{
-					Int:      offsetInSeconds,
-					Expr:     strconv.Itoa(offsetInSeconds),
-					ExprType: expr.Unsigned,
-				},
-				ExprType: expr.Unsigned,
-			}
+			return rewritten
 		case expr.CountCallName:
 			e.ExprType = expr.Unsigned
 		case expr.DayOfWeekCallName:
@@ -846,7 +1072,7 @@ func (qc *AQLQueryContext) Rewrite(expression expr.Expr) expr.Expr {
 				qc.Error = utils.StackError(nil, "dayofweek takes exactly 1 argument")
 				break
 			}
-			tsExpr := e.Args[0]
+			tsExpr := qc.timeInSeconds(e.Args[0])
 			return &expr.BinaryExpr{
 				Op:       expr.ADD,
 				ExprType: expr.Unsigned,
@@ -887,28 +1113,12 @@ func (qc *AQLQueryContext) Rewrite(expression expr.Expr) expr.Expr {
 			}
 			// no-op, this will be over written
 		case expr.FromUnixTimeCallName:
-			// for now, only the following format is allowed for backward compatibility
-			// from_unixtime(time_col / 1000)
-			timeColumnDivideErrMsg := "from_unixtime must be time column / 1000"
-			timeColDivide, isBinary := e.Args[0].(*expr.BinaryExpr)
-			if !isBinary || timeColDivide.Op != expr.DIV {
-				qc.Error = utils.StackError(nil, timeColumnDivideErrMsg)
-				break
-			}
-			divisor, isLiteral := timeColDivide.RHS.(*expr.NumberLiteral)
-			if !isLiteral || divisor.Int != 1000 {
-				qc.Error = utils.StackError(nil, timeColumnDivideErrMsg)
-				break
-			}
-			if par, isParen := timeColDivide.LHS.(*expr.ParenExpr); isParen {
-				timeColDivide.LHS = par.Expr
-			}
-			timeColExpr, isVarRef := timeColDivide.LHS.(*expr.VarRef)
-			if !isVarRef {
-				qc.Error = utils.StackError(nil, timeColumnDivideErrMsg)
+			rewritten, err := common.RewriteFromUnixTime(e, qc.Query.Strict)
+			if err != nil {
+				qc.Error = err
 				break
 			}
-			return timeColExpr
+			return rewritten
 		case expr.HourCallName:
 			if len(e.Args) != 1 {
 				qc.Error = utils.StackError(nil, "hour takes exactly 1 argument")
@@ -920,7 +1130,7 @@ func (qc *AQLQueryContext) Rewrite(expression expr.Expr) expr.Expr {
 				ExprType: expr.Unsigned,
 				LHS: &expr.BinaryExpr{
 					Op:  expr.MOD,
-					LHS: e.Args[0],
+					LHS: qc.timeInSeconds(e.Args[0]),
 					RHS: &expr.NumberLiteral{
 						Expr:     strconv.Itoa(common.SecondsPerDay),
 						Int:      common.SecondsPerDay,
@@ -1001,6 +1211,12 @@ func (qc *AQLQueryContext) Rewrite(expression expr.Expr) expr.Expr {
 					nil, "expect 1 argument to be a column for %s", e.Name)
 				break
 			}
+			// ids of String values are not consistent across data nodes.
+			if colRef.DataType == memCom.String {
+				qc.Error = utils.StackError(
+					nil, "String column %s is not supported by %s", colRef.Val, e.Name)
+				break
+			}
 
 			e.Name = expr.HllCallName
 			// 1. noop when column itself is hll column
@@ -1033,6 +1249,11 @@ func (qc *AQLQueryContext) Rewrite(expression expr.Expr) expr.Expr {
 					nil, "expect 1 argument for %s, but got %s", e.Name, e.String())
 				break
 			}
+			if common.IsStringColumn(e.Args[0]) {
+				qc.Error = utils.StackError(
+					nil, "String column %s is not supported by %s", e.Args[0].String(), e.Name)
+				break
+			}
 			// For avg, the expression type should always be float.
 			if e.Name == expr.AvgCallName {
 				e.Args[0] = expr.Cast(e.Args[0], expr.Float)
@@ -1098,6 +1319,7 @@ func (qc *AQLQueryContext) Rewrite(expression expr.Expr) expr.Expr {
 							// short circuiting hard.
 							// To play it safe we match against an invalid value.
 							value = -1
+							qc.checkUnknownEnumValue(vr.Val, strLiteral.Val)
 						}
 						literalExpr = &expr.NumberLiteral{Int: value, ExprType: expr.Unsigned}
 					}
@@ -1151,9 +1373,26 @@ func (qc *AQLQueryContext) Rewrite(expression expr.Expr) expr.Expr {
 						nil, "array function %s takes exactly 2 arguments", e.Name)
 					break
 				}
-				if _, ok := e.Args[1].(*expr.NumberLiteral); !ok {
+				if key, ok := e.Args[1].(*expr.StringLiteral); ok && vr.IsMapColumn {
+					// map values are stored as array indexed by key ids.
+					keyID, exists := vr.EnumDict[key.Val]
+					if !exists {
+						qc.checkUnknownEnumValue(vr.Val, key.Val)
+						// no key has an id this large so the value is always null.
+						keyID = metaCom.EnumCardinality(metaCom.MapFloat32)
+					}
+					e.Args[1] = &expr.NumberLiteral{
+						Val:      float64(keyID),
+						Int:      keyID,
+						Expr:     strconv.Itoa(keyID),
+						ExprType: expr.Unsigned,
+					}
+				} else if _, ok := e.Args[1].(*expr.NumberLiteral); !ok {
 					qc.Error = utils.StackError(
 						nil, "array function %s takes array type column and an index", e.Name)
+				} else if vr.IsMapColumn {
+					qc.Error = utils.StackError(
+						nil, "map column %s can only be accessed by string keys", vr.Val)
 				}
 				return &expr.BinaryExpr{
 					Op:       expr.ARRAY_ELEMENT_AT,
@@ -1163,6 +1402,11 @@ func (qc *AQLQueryContext) Rewrite(expression expr.Expr) expr.Expr {
 				}
 			}
 
+		case expr.MapValuesCallName:
+			qc.Error = utils.StackError(nil, "map_values is only supported as a dimension")
+		case expr.NearestNeighborsCallName:
+			// arguments are validated when the filter is extracted by processFilters.
+			e.ExprType = expr.Boolean
 		default:
 			qc.Error = utils.StackError(nil, "unknown function %s", e.Name)
 		}
@@ -1185,6 +1429,26 @@ func (qc *AQLQueryContext) Rewrite(expression expr.Expr) expr.Expr {
 	return expression
 }
 
+// rewriteMapValuesDimension rewrites map_values dimension to its enum column with the enum reverse dict
+// replaced by groups of enum values, results of enum values in the same group are reduced when flushed.
+func (qc *AQLQueryContext) rewriteMapValuesDimension(call *expr.Call) expr.Expr {
+	for i, arg := range call.Args {
+		call.Args[i] = expr.Rewrite(qc, arg)
+		if qc.Error != nil {
+			return call
+		}
+	}
+	column, groups, err := common.RewriteMapValues(call)
+	if err != nil {
+		qc.Error = err
+		return call
+	}
+	grouped := *column
+	grouped.EnumReverseDict = groups
+	qc.groupedDimensions = true
+	return &grouped
+}
+
 // normalizeAndFilters extracts top AND operators and flatten them out to the
 // filter slice.
 func normalizeAndFilters(filters []expr.Expr) []expr.Expr {
@@ -1217,7 +1481,15 @@ func (qc *AQLQueryContext) resolveTypes() {
 
 	// Dimensions.
 	for i, dim := range qc.Query.Dimensions {
-		dim.ExprParsed = expr.Rewrite(qc, dim.ExprParsed)
+		if call, ok := dim.ExprParsed.(*expr.Call); ok && strings.ToLower(call.Name) == expr.MapValuesCallName {
+			dim.ExprParsed = qc.rewriteMapValuesDimension(call)
+		} else {
+			dim.ExprParsed = expr.Rewrite(qc, dim.ExprParsed)
+		}
+		if qc.Error != nil {
+			return
+		}
+		qc.checkNoSystemColumn(dim.ExprParsed)
 		if qc.Error != nil {
 			return
 		}
@@ -1230,6 +1502,10 @@ func (qc *AQLQueryContext) resolveTypes() {
 		if qc.Error != nil {
 			return
 		}
+		qc.checkNoSystemColumn(measure.ExprParsed)
+		if qc.Error != nil {
+			return
+		}
 		for j, filter := range measure.FiltersParsed {
 			measure.FiltersParsed[j] = expr.Rewrite(qc, filter)
 			if qc.Error != nil {
@@ -1248,6 +1524,32 @@ func (qc *AQLQueryContext) resolveTypes() {
 		}
 	}
 	qc.Query.FiltersParsed = normalizeAndFilters(qc.Query.FiltersParsed)
+
+	if qc.Query.TriValuedLogic {
+		qc.rewriteKleeneAnds()
+	}
+}
+
+// rewriteKleeneAnds rewrites AND operators in dimensions, measures and filters to follow
+// standard SQL semantics for nulls. Top level AND operators of filters are normalized into
+// separate filters already and are kept as is.
+func (qc *AQLQueryContext) rewriteKleeneAnds() {
+	for i, dim := range qc.Query.Dimensions {
+		dim.ExprParsed = common.RewriteKleeneAnds(dim.ExprParsed)
+		qc.Query.Dimensions[i] = dim
+	}
+
+	for i, measure := range qc.Query.Measures {
+		measure.ExprParsed = common.RewriteKleeneAnds(measure.ExprParsed)
+		for j, filter := range measure.FiltersParsed {
+			measure.FiltersParsed[j] = common.RewriteKleeneAnds(filter)
+		}
+		qc.Query.Measures[i] = measure
+	}
+
+	for i, filter := range qc.Query.FiltersParsed {
+		qc.Query.FiltersParsed[i] = common.RewriteKleeneAnds(filter)
+	}
 }
 
 // extractFitler processes the specified query level filter and matches it
@@ -1430,6 +1732,27 @@ func (c columnUsageCollector) Visit(expression expr.Expr) expr.Visitor {
 	return c
 }
 
+// systemColumnDetector detects system columns involved in AST.
+type systemColumnDetector struct {
+	systemColumn *expr.VarRef
+}
+
+func (c *systemColumnDetector) Visit(expression expr.Expr) expr.Visitor {
+	if e, ok := expression.(*expr.VarRef); ok && c.systemColumn == nil && memCom.IsSystemColumn(e.ColumnID) {
+		c.systemColumn = e
+	}
+	return c
+}
+
+// checkNoSystemColumn reports error if system columns are used outside of filters.
+func (qc *AQLQueryContext) checkNoSystemColumn(expression expr.Expr) {
+	detector := systemColumnDetector{}
+	expr.Walk(&detector, expression)
+	if detector.systemColumn != nil {
+		qc.Error = utils.StackError(nil, "system column %s can only be used in filters", detector.systemColumn.Val)
+	}
+}
+
 // foreignTableColumnDetector detects foreign table columns involved in AST
 type foreignTableColumnDetector struct {
 	hasForeignTableColumn bool
@@ -1468,6 +1791,13 @@ func (qc *AQLQueryContext) processFilters() {
 
 	var geoFilterFound bool
 	for _, filter := range commonFilters {
+		if qc.matchNearestNeighbors(filter) {
+			if qc.Error != nil {
+				return
+			}
+			continue
+		}
+
 		foreignTableColumnDetector := foreignTableColumnDetector{}
 		expr.Walk(&foreignTableColumnDetector, filter)
 		if foreignTableColumnDetector.hasForeignTableColumn {
@@ -1497,6 +1827,11 @@ func (qc *AQLQueryContext) processFilters() {
 		return
 	}
 
+	qc.checkNearestNeighborsPlacement()
+	if qc.Error != nil {
+		return
+	}
+
 	// Process time filter.
 	qc.processTimeFilter()
 	if qc.Error != nil {
@@ -1669,6 +2004,7 @@ func (qc *AQLQueryContext) processTimeFilter() {
 	// TODO: resolve time filter column against foreign tables.
 	timeColumnID := 0
 	found := false
+	isTime64Column := false
 	if qc.Query.TimeFilter.Column != "" {
 		// Validate column existence and type.
 		timeColumnID, found = qc.TableScanners[0].Schema.ColumnIDs[qc.Query.TimeFilter.Column]
@@ -1678,20 +2014,35 @@ func (qc *AQLQueryContext) processTimeFilter() {
 			return
 		}
 		timeColumnType := qc.TableScanners[0].Schema.ValueTypeByColumn[timeColumnID]
-		if timeColumnType != memCom.Uint32 {
+		isTime64Column = qc.TableScanners[0].Schema.Schema.Columns[timeColumnID].IsTime64Column()
+		if timeColumnType != memCom.Uint32 && !isTime64Column {
 			qc.Error = utils.StackError(nil,
-				"expect time filter column %s of type Uint32, but got %s",
+				"expect time filter column %s of type Uint32 or Time64, but got %s",
 				qc.Query.TimeFilter.Column, memCom.DataTypeName[timeColumnType])
 			return
 		}
 	}
-	fromExpr, toExpr := common.CreateTimeFilterExpr(&expr.VarRef{
+	var timeColumnExpr expr.Expr = &expr.VarRef{
 		Val:      qc.Query.TimeFilter.Column,
 		ExprType: expr.Unsigned,
 		TableID:  0,
 		ColumnID: timeColumnID,
 		DataType: memCom.Uint32,
-	}, from, to)
+	}
+	if isTime64Column {
+		timeColumnExpr = &expr.UnaryExpr{
+			Op: expr.MILLIS_TO_SECONDS,
+			Expr: &expr.VarRef{
+				Val:      qc.Query.TimeFilter.Column,
+				ExprType: expr.Signed,
+				TableID:  0,
+				ColumnID: timeColumnID,
+				DataType: memCom.Int64,
+			},
+			ExprType: expr.Unsigned,
+		}
+	}
+	fromExpr, toExpr := common.CreateTimeFilterExpr(timeColumnExpr, from, to)
 
 	qc.TableScanners[0].ArchiveBatchIDEnd = int((utils.Now().Unix() + 86399) / 86400)
 	if timeColumnMatched {
@@ -1718,6 +2069,8 @@ func (qc *AQLQueryContext) processTimeFilter() {
 // A valid geo dimension can only in one of the following format:
 // 	1. UUID
 //  2. hex(UUID)
+//  3. any other non geo shape column of the geo table, e.g. name of the geo fence. Shapes sharing the same
+//     value will be aggregated together.
 func (qc *AQLQueryContext) matchAndRewriteGeoDimension(dimExpr expr.Expr) (expr.Expr, error) {
 	gc := &geoTableUsageCollector{
 		geoIntersection: *qc.OOPK.geoIntersection,
@@ -1742,18 +2095,14 @@ func (qc *AQLQueryContext) matchAndRewriteGeoDimension(dimExpr expr.Expr) (expr.
 		dimExpr = callExpr.Args[0]
 	}
 
-	joinSchema := qc.TableSchemaByName[qc.Query.Joins[gc.geoIntersection.shapeTableID-1].Table]
 	if varRefExpr, ok := dimExpr.(*expr.VarRef); ok {
-		var err error
-		if varRefExpr.ColumnID != joinSchema.Schema.PrimaryKeyColumns[0] {
-			err = utils.StackError(nil, "Only geo uuid is allowed in dimensions")
-		}
-
+		// the actual dimension value written is the index of matched shape, which will be translated
+		// into the value of this column in post processing.
 		varRefExpr.DataType = memCom.Uint8
-		return varRefExpr, err
+		return varRefExpr, nil
 	}
 
-	return nil, utils.StackError(nil, "Only hex(uuid) or uuid supported, got %s", dimExpr.String())
+	return nil, utils.StackError(nil, "Only hex(uuid) or geo table column supported, got %s", dimExpr.String())
 }
 
 // geoTableUsageCollector traverses an AST expression tree, finds VarRef columns
@@ -1840,6 +2189,9 @@ func (qc *AQLQueryContext) processMeasure() {
 		// for average, we should always use float type as the agg type.
 		qc.OOPK.AggregateType = C.AGGR_AVG_FLOAT
 	case expr.MinCallName:
+		if _, isInt64Column := common.AsInt64Column(qc.OOPK.Measure); isInt64Column {
+			qc.OOPK.MeasureBytes = 8
+		}
 		switch qc.OOPK.Measure.Type() {
 		case expr.Float:
 			qc.OOPK.AggregateType = C.AGGR_MIN_FLOAT
@@ -1853,6 +2205,9 @@ func (qc *AQLQueryContext) processMeasure() {
 			return
 		}
 	case expr.MaxCallName:
+		if _, isInt64Column := common.AsInt64Column(qc.OOPK.Measure); isInt64Column {
+			qc.OOPK.MeasureBytes = 8
+		}
 		switch qc.OOPK.Measure.Type() {
 		case expr.Float:
 			qc.OOPK.AggregateType = C.AGGR_MAX_FLOAT
@@ -1867,6 +2222,11 @@ func (qc *AQLQueryContext) processMeasure() {
 		}
 	case expr.HllCallName:
 		qc.OOPK.AggregateType = C.AGGR_HLL
+		// hll values computed on the fly are of the default precision.
+		qc.hllPrecision = metaCom.DefaultHLLPrecision
+		if colRef, ok := qc.OOPK.Measure.(*expr.VarRef); ok && colRef.IsHLLColumn {
+			qc.hllPrecision = colRef.HLLPrecision
+		}
 	default:
 		qc.Error = utils.StackError(nil,
 			"unsupported aggregate function: %s", aggregate.Name)
@@ -1875,9 +2235,11 @@ func (qc *AQLQueryContext) processMeasure() {
 }
 
 func (qc *AQLQueryContext) getAllColumnsDimension() (columns []common.Dimension) {
-	// only main table columns wildcard match supported
+	// only main table columns wildcard match supported, restricted columns and the hidden ingestion
+	// source column are skipped
 	for _, column := range qc.TableScanners[0].Schema.Schema.Columns {
-		if !column.Deleted && column.Type != metaCom.GeoShape {
+		if !column.Deleted && column.Type != metaCom.GeoShape && column.Name != metaCom.IngestionSourceColumnName &&
+			qc.canReadColumn(qc.TableScanners[0].Schema, column.Name) {
 			columns = append(columns, common.Dimension{
 				ExprParsed: &expr.VarRef{Val: column.Name},
 				Expr:       column.Name,
@@ -1888,6 +2250,16 @@ func (qc *AQLQueryContext) getAllColumnsDimension() (columns []common.Dimension)
 }
 
 func (qc *AQLQueryContext) processDimensions() {
+	// Results of enum values in the same group are reduced when flushed, dimension values are not
+	// translated for DataOnly requests so broker does the reduction instead.
+	if qc.groupedDimensions && !qc.IsNonAggregationQuery && !qc.DataOnly {
+		qc.reduceMeasure = getMeasureReducer(qc.OOPK.AggregateType)
+		if qc.reduceMeasure == nil {
+			qc.Error = utils.StackError(nil, "map_values does not support measure %s", qc.Query.Measures[0].Expr)
+			return
+		}
+	}
+
 	// Copy dimension ASTs.
 	qc.OOPK.Dimensions = make([]expr.Expr, len(qc.Query.Dimensions))
 	for i, dim := range qc.Query.Dimensions {
@@ -1930,10 +2302,16 @@ func (qc *AQLQueryContext) processDimensions() {
 				foundGeoJoin = true
 				qc.OOPK.Dimensions[i] = geoDimExpr
 				qc.OOPK.geoIntersection.dimIndex = i
+				qc.OOPK.geoIntersection.dimColumnID = geoDimExpr.(*expr.VarRef).ColumnID
 			}
 		}
 	}
 
+	qc.deferWideColumns()
+	if qc.Error != nil {
+		return
+	}
+
 	// Collect column usage from measure and dimensions
 	expr.Walk(columnUsageCollector{
 		tableScanners: qc.TableScanners,
@@ -2021,6 +2399,17 @@ func parseTimezoneColumnString(timezoneColumnString string) (column, joinKey str
 	return
 }
 
+// checkUnknownEnumValue applies the unknown enum value policy of the query to a value missing
+// from the enum dictionary of the column.
+func (qc *AQLQueryContext) checkUnknownEnumValue(column, value string) {
+	warning, err := qc.Query.CheckUnknownEnumValue(column, value)
+	if err != nil {
+		qc.Error = err
+	} else if warning != "" && utils.IndexOfStr(qc.Warnings, warning) < 0 {
+		qc.Warnings = append(qc.Warnings, warning)
+	}
+}
+
 func (qc *AQLQueryContext) expandINop(e *expr.BinaryExpr) (expandedExpr expr.Expr) {
 	lhs, ok := e.LHS.(*expr.VarRef)
 	if !ok {
@@ -2037,7 +2426,7 @@ func (qc *AQLQueryContext) expandINop(e *expr.BinaryExpr) (expandedExpr expr.Exp
 					Op:  expr.EQ,
 					LHS: lhs,
 					RHS: value,
-				}).(*expr.BinaryExpr)
+				})
 			default:
 				lastExpr := expandedExpr
 				expandedExpr = &expr.BinaryExpr{
@@ -2047,7 +2436,7 @@ func (qc *AQLQueryContext) expandINop(e *expr.BinaryExpr) (expandedExpr expr.Exp
 						Op:  expr.EQ,
 						LHS: lhs,
 						RHS: value,
-					}).(*expr.BinaryExpr),
+					}),
 				}
 			}
 		}
@@ -18,6 +18,7 @@ import (
 	"github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/uber/aresdb/cluster/topology"
+	"net/http"
 
 	"time"
 	"unsafe"
@@ -162,26 +163,25 @@ var _ = ginkgo.Describe("AQL compiler", func() {
 		}))
 		Ω(qc.TableScanners).Should(Equal([]*TableScanner{
 			{
-				Schema:       tripsSchema,
+				Schema:       tripsSchema.Snapshot(),
 				Shards:       []int{0},
 				ColumnUsages: map[int]columnUsage{0: columnUsedByLiveBatches},
 			},
 			{
-				Schema:       apiCitiesSchema,
+				Schema:       apiCitiesSchema.Snapshot(),
 				Shards:       []int{0},
 				ColumnUsages: map[int]columnUsage{},
 			},
 			{
-				Schema:       tripsSchema,
+				Schema:       tripsSchema.Snapshot(),
 				Shards:       []int{0},
 				ColumnUsages: map[int]columnUsage{0: columnUsedByLiveBatches},
 			},
 		}))
 		Ω(qc.TableSchemaByName).Should(Equal(map[string]*memCom.TableSchema{
-			"trips":      tripsSchema,
-			"api_cities": apiCitiesSchema,
+			"trips":      tripsSchema.Snapshot(),
+			"api_cities": apiCitiesSchema.Snapshot(),
 		}))
-		qc.releaseSchema()
 
 		qc = &AQLQueryContext{
 			Query: &queryCom.AQLQuery{
@@ -190,7 +190,6 @@ var _ = ginkgo.Describe("AQL compiler", func() {
 		}
 		qc.readSchema(store, topology.NewStaticShardOwner([]int{0}))
 		Ω(qc.Error).ShouldNot(BeNil())
-		qc.releaseSchema()
 
 		qc = &AQLQueryContext{
 			Query: &queryCom.AQLQuery{
@@ -202,7 +201,128 @@ var _ = ginkgo.Describe("AQL compiler", func() {
 		}
 		qc.readSchema(store, topology.NewStaticShardOwner([]int{0}))
 		Ω(qc.Error).ShouldNot(BeNil())
-		qc.releaseSchema()
+	})
+
+	ginkgo.It("enforces table acls of the principal", func() {
+		table := metaCom.Table{
+			Name:        "trips",
+			IsFactTable: true,
+			Columns: []metaCom.Column{
+				{Name: "request_at", Type: metaCom.Uint32},
+				{Name: "fare", Type: metaCom.Float32},
+				{Name: "email", Type: metaCom.SmallEnum},
+			},
+			ACL: &metaCom.TableACL{
+				Readers:           []string{"analysts", "admins"},
+				RestrictedColumns: map[string][]string{"email": {"admins"}},
+			},
+		}
+		store := new(mocks.MemStore)
+		store.On("RLock").Return()
+		store.On("RUnlock").Return()
+		store.On("GetSchemas").Return(map[string]*memCom.TableSchema{
+			"trips": memCom.NewTableSchema(&table),
+		})
+
+		newQC := func(principal *utils.Principal, dimensions ...string) *AQLQueryContext {
+			qc := &AQLQueryContext{
+				Query: &queryCom.AQLQuery{
+					Table:    "trips",
+					Measures: []queryCom.Measure{{Expr: "1"}},
+				},
+				Principal: principal,
+			}
+			for _, dimension := range dimensions {
+				qc.Query.Dimensions = append(qc.Query.Dimensions, queryCom.Dimension{Expr: dimension})
+			}
+			qc.readSchema(store, topology.NewStaticShardOwner([]int{0}))
+			if qc.Error == nil {
+				qc.parseExprs()
+			}
+			if qc.Error == nil {
+				qc.resolveTypes()
+			}
+			return qc
+		}
+
+		qc := newQC(&utils.Principal{Name: "bob"}, "fare")
+		Ω(qc.Error).Should(Equal(utils.APIError{
+			Code:    http.StatusForbidden,
+			Message: "principal bob is not allowed to read table trips",
+		}))
+
+		analyst := &utils.Principal{Name: "alice", Groups: []string{"analysts"}}
+		qc = newQC(analyst, "fare")
+		Ω(qc.Error).Should(BeNil())
+
+		qc = newQC(analyst, "email")
+		Ω(qc.Error).Should(Equal(utils.APIError{
+			Code:    http.StatusForbidden,
+			Message: "principal alice is not allowed to read restricted column email of table trips",
+		}))
+
+		// restricted columns are left out of select *.
+		qc = newQC(analyst, "*")
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.Query.Dimensions).Should(HaveLen(2))
+
+		qc = newQC(&utils.Principal{Name: "carol", Groups: []string{"admins"}}, "*")
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.Query.Dimensions).Should(HaveLen(3))
+
+		// no restriction without authentication.
+		qc = newQC(nil, "email")
+		Ω(qc.Error).Should(BeNil())
+	})
+
+	ginkgo.It("rejects queries compiled against other schema versions", func() {
+		table := metaCom.Table{
+			Name:        "trips",
+			IsFactTable: true,
+			Columns: []metaCom.Column{
+				{Name: "request_at", Type: metaCom.Uint32},
+			},
+			Version: 2,
+		}
+		store := new(mocks.MemStore)
+		store.On("RLock").Return()
+		store.On("RUnlock").Return()
+		store.On("GetSchemas").Return(map[string]*memCom.TableSchema{
+			"trips": memCom.NewTableSchema(&table),
+		})
+
+		qc := &AQLQueryContext{
+			Query: &queryCom.AQLQuery{
+				Table:          "trips",
+				SchemaVersions: map[string]int{"trips": 2},
+			},
+		}
+		qc.readSchema(store, topology.NewStaticShardOwner([]int{0}))
+		Ω(qc.Error).Should(BeNil())
+
+		qc = &AQLQueryContext{
+			Query: &queryCom.AQLQuery{
+				Table:          "trips",
+				SchemaVersions: map[string]int{"trips": 3},
+			},
+		}
+		qc.readSchema(store, topology.NewStaticShardOwner([]int{0}))
+		Ω(qc.Error).Should(Equal(utils.APIError{
+			Code:    http.StatusConflict,
+			Message: "schema of table trips is of version 2, query expects version 3",
+		}))
+	})
+
+	ginkgo.It("rejects unknown data scope", func() {
+		qc := &AQLQueryContext{
+			Query: &queryCom.AQLQuery{
+				Table:     "trips",
+				DataScope: "cold",
+			},
+		}
+		qc.Compile(new(mocks.MemStore), topology.NewStaticShardOwner([]int{0}))
+		Ω(qc.Error).ShouldNot(BeNil())
+		Ω(qc.Error.Error()).Should(ContainSubstring("unknown data scope cold"))
 	})
 
 	ginkgo.It("numerical operations on column over 4 bytes long not supported", func() {
@@ -255,9 +375,170 @@ var _ = ginkgo.Describe("AQL compiler", func() {
 		qc.parseExprs()
 		Ω(qc.Error).Should(BeNil())
 
+		qc.resolveTypes()
+		Ω(qc.Error).Should(BeNil())
+		Ω(queryCom.GetDimensionDataType(qc.Query.Dimensions[0].ExprParsed)).Should(Equal(memCom.Int64))
+
+		qc.Query = &queryCom.AQLQuery{
+			Table: "trips",
+			Dimensions: []queryCom.Dimension{
+				{Expr: "hex_id/2"},
+			},
+			Measures: []queryCom.Measure{
+				{Expr: "count(*)"},
+			},
+		}
+		qc.parseExprs()
+		Ω(qc.Error).Should(BeNil())
+
+		qc.resolveTypes()
+		Ω(qc.Error).ShouldNot(BeNil())
+		Ω(qc.Error.Error()).Should(ContainSubstring("numeric operations not supported for column over 4 bytes length"))
+
+		qc.Error = nil
+		qc.Query = &queryCom.AQLQuery{
+			Table: "trips",
+			Dimensions: []queryCom.Dimension{
+				{Expr: "(hex_id+1)*2"},
+			},
+			Measures: []queryCom.Measure{
+				{Expr: "count(*)"},
+			},
+		}
+		qc.parseExprs()
+		Ω(qc.Error).Should(BeNil())
+
 		qc.resolveTypes()
 		Ω(qc.Error).ShouldNot(BeNil())
 		Ω(qc.Error.Error()).Should(ContainSubstring("numeric operations not supported for column over 4 bytes length"))
+
+		// negation of int64 column is allowed but nothing on top of it.
+		qc.Error = nil
+		qc.Query = &queryCom.AQLQuery{
+			Table: "trips",
+			Dimensions: []queryCom.Dimension{
+				{Expr: "-hex_id"},
+			},
+			Measures: []queryCom.Measure{
+				{Expr: "count(*)"},
+			},
+		}
+		qc.parseExprs()
+		Ω(qc.Error).Should(BeNil())
+
+		qc.resolveTypes()
+		Ω(qc.Error).Should(BeNil())
+		Ω(queryCom.GetDimensionDataType(qc.Query.Dimensions[0].ExprParsed)).Should(Equal(memCom.Int64))
+
+		qc.Query = &queryCom.AQLQuery{
+			Table: "trips",
+			Dimensions: []queryCom.Dimension{
+				{Expr: "-hex_id*2"},
+			},
+			Measures: []queryCom.Measure{
+				{Expr: "count(*)"},
+			},
+		}
+		qc.parseExprs()
+		Ω(qc.Error).Should(BeNil())
+
+		qc.resolveTypes()
+		Ω(qc.Error).ShouldNot(BeNil())
+		Ω(qc.Error.Error()).Should(ContainSubstring("numeric operations not supported for column over 4 bytes length"))
+	})
+
+	ginkgo.It("scales literals compared with or applied on decimal columns", func() {
+		qc := &AQLQueryContext{
+			TableIDByAlias: map[string]int{
+				"trips": 0,
+			},
+			TableScanners: []*TableScanner{
+				{
+					Schema: &memCom.TableSchema{
+						ValueTypeByColumn: []memCom.DataType{
+							memCom.Decimal,
+							memCom.Int64,
+						},
+						ColumnIDs: map[string]int{
+							"amount": 0,
+							"hex_id": 1,
+						},
+						Schema: metaCom.Table{
+							Columns: []metaCom.Column{
+								{Name: "amount", Type: metaCom.Decimal, DecimalScale: 2},
+								{Name: "hex_id", Type: metaCom.Int64},
+							},
+						},
+					},
+				},
+			},
+		}
+		qc.Query = &queryCom.AQLQuery{
+			Table: "trips",
+			Dimensions: []queryCom.Dimension{
+				{Expr: "amount-0.5"},
+				{Expr: "3*amount"},
+			},
+			Measures: []queryCom.Measure{
+				{Expr: "sum(amount+1)"},
+			},
+			Filters: []string{
+				"amount > 10.25",
+				"-1 <= amount",
+				"hex_id != 4294967296",
+			},
+		}
+		qc.parseExprs()
+		Ω(qc.Error).Should(BeNil())
+
+		qc.resolveTypes()
+		Ω(qc.Error).Should(BeNil())
+
+		measure := qc.Query.Measures[0].ExprParsed.(*expr.Call).Args[0]
+		filters := qc.Query.FiltersParsed
+		for i, expected := range []struct {
+			expression expr.Expr
+			op         expr.Token
+			column     string
+			value      int
+			exprType   expr.Type
+		}{
+			{qc.Query.Dimensions[0].ExprParsed, expr.SUB, "amount", 50, expr.Signed},
+			// multipliers are not scaled.
+			{qc.Query.Dimensions[1].ExprParsed, expr.MUL, "amount", 3, expr.Signed},
+			{measure, expr.ADD, "amount", 100, expr.Signed},
+			{filters[0], expr.GT, "amount", 1025, expr.Boolean},
+			// literal is moved to rhs.
+			{filters[1], expr.GTE, "amount", -100, expr.Boolean},
+			{filters[2], expr.NEQ, "hex_id", 4294967296, expr.Boolean},
+		} {
+			binaryExpr, ok := expected.expression.(*expr.BinaryExpr)
+			Ω(ok).Should(BeTrue(), "expression %d", i)
+			Ω(binaryExpr.Op).Should(Equal(expected.op), "expression %d", i)
+			Ω(binaryExpr.LHS.(*expr.VarRef).Val).Should(Equal(expected.column), "expression %d", i)
+			Ω(binaryExpr.RHS).Should(Equal(&expr.NumberLiteral{
+				Int: expected.value, Val: float64(expected.value), ExprType: expr.Signed}), "expression %d", i)
+			Ω(binaryExpr.ExprType).Should(Equal(expected.exprType), "expression %d", i)
+		}
+		Ω(queryCom.GetDimensionDataType(qc.Query.Dimensions[1].ExprParsed)).Should(Equal(memCom.Decimal))
+		Ω(queryCom.GetDimensionDataType(qc.Query.Dimensions[0].ExprParsed)).Should(Equal(memCom.Decimal))
+
+		// literals with more fractional digits than the scale can not be compared exactly.
+		qc.Query = &queryCom.AQLQuery{
+			Table: "trips",
+			Measures: []queryCom.Measure{
+				{Expr: "count(*)"},
+			},
+			Filters: []string{
+				"amount = 1.005",
+			},
+		}
+		qc.parseExprs()
+		Ω(qc.Error).Should(BeNil())
+
+		qc.resolveTypes()
+		Ω(qc.Error).ShouldNot(BeNil())
+		Ω(qc.Error.Error()).Should(ContainSubstring("invalid literal 1.005 for column amount"))
 	})
 
 	ginkgo.It("resolves data types", func() {
@@ -567,6 +848,231 @@ var _ = ginkgo.Describe("AQL compiler", func() {
 		}))
 	})
 
+	ginkgo.It("applies unknown enum value policy", func() {
+		newQC := func(policy string) *AQLQueryContext {
+			qc := &AQLQueryContext{
+				TableIDByAlias: map[string]int{
+					"trips": 0,
+				},
+				TableScanners: []*TableScanner{
+					{
+						Schema: &memCom.TableSchema{
+							ValueTypeByColumn: []memCom.DataType{memCom.SmallEnum},
+							EnumDicts: map[string]memCom.EnumDict{
+								"status": {
+									Dict: map[string]int{"completed": 3},
+								},
+							},
+							ColumnIDs: map[string]int{"status": 0},
+							Schema: metaCom.Table{
+								Columns: []metaCom.Column{
+									{Name: "status", Type: metaCom.SmallEnum},
+								},
+							},
+						},
+					},
+				},
+			}
+			qc.Query = &queryCom.AQLQuery{
+				Table:            "trips",
+				Measures:         []queryCom.Measure{{Expr: "count(*)"}},
+				Filters:          []string{"status='completed'", "status in ('canceled', 'compelted')", "status!='canceled'"},
+				UnknownEnumValue: policy,
+			}
+			qc.parseExprs()
+			Ω(qc.Error).Should(BeNil())
+			qc.resolveTypes()
+			return qc
+		}
+
+		qc := newQC("")
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.Warnings).Should(BeEmpty())
+
+		qc = newQC(queryCom.UnknownEnumValueWarn)
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.Warnings).Should(Equal([]string{
+			`unknown enum value "canceled" of column status matches nothing`,
+			`unknown enum value "compelted" of column status matches nothing`,
+		}))
+
+		qc = newQC(queryCom.UnknownEnumValueFail)
+		Ω(qc.Error).ShouldNot(BeNil())
+		Ω(qc.Error.Error()).Should(ContainSubstring(`unknown enum value "canceled" of column status`))
+
+		qc = &AQLQueryContext{Query: &queryCom.AQLQuery{Table: "trips", UnknownEnumValue: "drop"}}
+		qc.Compile(nil, nil)
+		Ω(qc.Error.Error()).Should(ContainSubstring("unknown enum value policy drop"))
+	})
+
+	ginkgo.It("translates String values to ids of the global dictionary", func() {
+		batchDict := memCom.NewStringDictionary()
+		batchDict.GetOrAdd("sf")
+		batchDict.GetOrAdd("la")
+		schema := memCom.NewTableSchema(&metaCom.Table{
+			Name: "cities",
+			Columns: []metaCom.Column{
+				{Name: "id", Type: metaCom.Uint32},
+				{Name: "name", Type: metaCom.String},
+			},
+			PrimaryKeyColumns: []int{0},
+		})
+		schema.GetStringDict(1).Register(batchDict)
+
+		newQC := func(filters []string, measure string) *AQLQueryContext {
+			qc := &AQLQueryContext{
+				TableIDByAlias: map[string]int{"cities": 0},
+				TableScanners:  []*TableScanner{{Schema: schema}},
+			}
+			qc.Query = &queryCom.AQLQuery{
+				Table:      "cities",
+				Measures:   []queryCom.Measure{{Expr: measure}},
+				Dimensions: []queryCom.Dimension{{Expr: "name"}},
+				Filters:    filters,
+			}
+			qc.parseExprs()
+			Ω(qc.Error).Should(BeNil())
+			qc.resolveTypes()
+			return qc
+		}
+
+		qc := newQC([]string{"name = 'la'", "name != 'nyc'", "name in ('sf', 'la')"}, "count(*)")
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.Query.FiltersParsed[0]).Should(Equal(&expr.BinaryExpr{
+			Op:       expr.EQ,
+			LHS:      qc.Query.FiltersParsed[0].(*expr.BinaryExpr).LHS,
+			RHS:      &expr.NumberLiteral{Int: 1, ExprType: expr.Unsigned},
+			ExprType: expr.Boolean,
+		}))
+		Ω(qc.Query.FiltersParsed[1].(*expr.BinaryExpr).RHS).Should(Equal(&expr.NumberLiteral{Int: -1, ExprType: expr.Unsigned}))
+		Ω(qc.Query.FiltersParsed[2].String()).Should(Equal("name = 0 OR name = 1"))
+		nameRef := qc.Query.Dimensions[0].ExprParsed.(*expr.VarRef)
+		Ω(nameRef.DataType).Should(Equal(memCom.String))
+		Ω(nameRef.StringDict).Should(BeIdenticalTo(schema.GetStringDict(1)))
+		Ω(qc.getEnumReverseDict(0, nameRef)).Should(Equal([]string{"sf", "la"}))
+
+		qc = newQC([]string{"name > 'la'"}, "count(*)")
+		Ω(qc.Error.Error()).Should(ContainSubstring("String column name only supports EQ, NEQ, IN and null checks"))
+		qc = newQC(nil, "max(name)")
+		Ω(qc.Error.Error()).Should(ContainSubstring("String column name is not supported by max"))
+	})
+
+	ginkgo.It("rewrites map_values dimensions", func() {
+		newQC := func(measure, dimension string) *AQLQueryContext {
+			qc := &AQLQueryContext{
+				TableIDByAlias: map[string]int{
+					"trips": 0,
+				},
+				TableScanners: []*TableScanner{
+					{
+						Schema: &memCom.TableSchema{
+							ValueTypeByColumn: []memCom.DataType{memCom.SmallEnum},
+							EnumDicts: map[string]memCom.EnumDict{
+								"status": {
+									Dict:        map[string]int{"completed": 0, "canceled": 1, "rejected": 2},
+									ReverseDict: []string{"completed", "canceled", "rejected"},
+								},
+							},
+							ColumnIDs: map[string]int{"status": 0},
+							Schema: metaCom.Table{
+								Columns: []metaCom.Column{
+									{Name: "status", Type: metaCom.SmallEnum},
+								},
+							},
+						},
+						ColumnUsages: map[int]columnUsage{},
+					},
+				},
+			}
+			qc.Query = &queryCom.AQLQuery{
+				Table:      "trips",
+				Measures:   []queryCom.Measure{{Expr: measure}},
+				Dimensions: []queryCom.Dimension{{Expr: dimension}},
+			}
+			qc.parseExprs()
+			Ω(qc.Error).Should(BeNil())
+			qc.resolveTypes()
+			if qc.Error != nil {
+				return qc
+			}
+			qc.processMeasure()
+			Ω(qc.Error).Should(BeNil())
+			qc.processDimensions()
+			return qc
+		}
+
+		qc := newQC("count(*)", "map_values(status, {'canceled': 'failed', 'rejected': 'failed'}, 'other')")
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.OOPK.Dimensions[0]).Should(Equal(&expr.VarRef{
+			Val:             "status",
+			ExprType:        expr.Unsigned,
+			EnumDict:        map[string]int{"completed": 0, "canceled": 1, "rejected": 2},
+			EnumReverseDict: []string{"other", "failed", "failed"},
+			DataType:        memCom.SmallEnum,
+		}))
+		Ω(qc.getEnumReverseDict(0, qc.OOPK.Dimensions[0])).Should(Equal([]string{"other", "failed", "failed"}))
+		Ω(qc.reduceMeasure).ShouldNot(BeNil())
+		// enum dict of the schema is not modified.
+		Ω(qc.TableScanners[0].Schema.EnumDicts["status"].ReverseDict).Should(Equal([]string{"completed", "canceled", "rejected"}))
+
+		qc = newQC("avg(status)", "map_values(status, {'canceled': 'failed'})")
+		Ω(qc.Error.Error()).Should(ContainSubstring("map_values does not support measure avg(status)"))
+
+		qc = newQC("count(*)", "map_values(status, {'canceled': 'failed'}) = 'failed'")
+		Ω(qc.Error.Error()).Should(ContainSubstring("map_values is only supported as a dimension"))
+	})
+
+	ginkgo.It("resolves system columns in filters", func() {
+		newQC := func(filter, dimension string) *AQLQueryContext {
+			qc := &AQLQueryContext{
+				TableIDByAlias: map[string]int{
+					"trips": 0,
+				},
+				TableScanners: []*TableScanner{
+					{
+						Schema: &memCom.TableSchema{
+							ValueTypeByColumn: []memCom.DataType{memCom.Uint32},
+							ColumnIDs:         map[string]int{"request_at": 0},
+							Schema: metaCom.Table{
+								Columns: []metaCom.Column{
+									{Name: "request_at", Type: metaCom.Uint32},
+								},
+							},
+						},
+						ColumnUsages: map[int]columnUsage{},
+					},
+				},
+			}
+			qc.Query = &queryCom.AQLQuery{
+				Table:      "trips",
+				Measures:   []queryCom.Measure{{Expr: "count(*)"}},
+				Dimensions: []queryCom.Dimension{{Expr: dimension}},
+				Filters:    []string{filter},
+			}
+			qc.parseExprs()
+			Ω(qc.Error).Should(BeNil())
+			qc.resolveTypes()
+			return qc
+		}
+
+		qc := newQC("__ingested_at >= 1000", "request_at")
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.Query.FiltersParsed).Should(HaveLen(1))
+		ingestedAt := qc.Query.FiltersParsed[0].(*expr.BinaryExpr).LHS.(*expr.VarRef)
+		Ω(ingestedAt.ColumnID).Should(Equal(memCom.IngestedAtColumnID))
+		Ω(ingestedAt.DataType).Should(Equal(memCom.Uint32))
+
+		qc = newQC("not __deleted", "request_at")
+		Ω(qc.Error).ShouldNot(BeNil())
+
+		qc = newQC("__batch_id = 1", "request_at")
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.Query.FiltersParsed[0].(*expr.BinaryExpr).LHS.(*expr.VarRef).ColumnID).Should(Equal(memCom.BatchIDColumnID))
+
+		qc = newQC("request_at > 0", "__batch_id")
+		Ω(qc.Error.Error()).Should(ContainSubstring("system column __batch_id can only be used in filters"))
+	})
+
 	ginkgo.It("returns error on type resolution failure", func() {
 		qc := &AQLQueryContext{
 			TableScanners: []*TableScanner{
@@ -1469,6 +1975,65 @@ var _ = ginkgo.Describe("AQL compiler", func() {
 		Ω(qc.OOPK.Dimensions).Should(HaveLen(7))
 	})
 
+	ginkgo.It("defers wide columns of non agg query selecting all columns", func() {
+		table := metaCom.Table{
+			Columns: []metaCom.Column{
+				{Name: "city_id", Type: metaCom.Uint16},
+				{Name: "location", Type: metaCom.GeoPoint},
+				{Name: "tags", Type: metaCom.ArrayInt32},
+			},
+		}
+		schema := memCom.NewTableSchema(&table)
+
+		newQC := func(limit int) *AQLQueryContext {
+			qc := &AQLQueryContext{
+				TableIDByAlias: map[string]int{
+					"trips": 0,
+				},
+				TableScanners: []*TableScanner{
+					{Schema: schema, ColumnUsages: map[int]columnUsage{}},
+				},
+			}
+			qc.Query = &queryCom.AQLQuery{
+				Table: "trips",
+				Measures: []queryCom.Measure{
+					{Expr: "1"},
+				},
+				Dimensions: []queryCom.Dimension{
+					{Expr: "*"},
+				},
+				Limit: limit,
+			}
+			qc.parseExprs()
+			qc.resolveTypes()
+			qc.processMeasure()
+			qc.processDimensions()
+			return qc
+		}
+
+		qc := newQC(10)
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.OOPK.Dimensions).Should(HaveLen(5))
+		Ω(qc.OOPK.Dimensions[0].(*expr.VarRef).Val).Should(Equal("city_id"))
+		Ω(qc.OOPK.Dimensions[1]).Should(BeAssignableToTypeOf(&expr.NumberLiteral{}))
+		Ω(qc.OOPK.Dimensions[2]).Should(BeAssignableToTypeOf(&expr.NumberLiteral{}))
+		Ω(qc.OOPK.Dimensions[3].(*expr.VarRef).ColumnID).Should(Equal(memCom.BatchIDColumnID))
+		Ω(qc.OOPK.Dimensions[4].(*expr.VarRef).ColumnID).Should(Equal(memCom.RowIndexColumnID))
+		Ω(qc.deferredColumns.columns).Should(Equal(map[int]deferredColumn{
+			1: {columnID: 1, dataType: memCom.GeoPoint},
+			2: {columnID: 2, dataType: memCom.ArrayInt32},
+		}))
+		Ω(qc.TableScanners[0].ColumnUsages).Should(Equal(map[int]columnUsage{
+			0:                       columnUsedByAllBatches,
+			memCom.BatchIDColumnID:  columnUsedByAllBatches,
+			memCom.RowIndexColumnID: columnUsedByAllBatches,
+		}))
+
+		qc = newQC(1000)
+		Ω(qc.Error).ShouldNot(BeNil())
+		Ω(qc.deferredColumns).Should(BeNil())
+	})
+
 	ginkgo.It("sorts used columns", func() {
 		schema := &memCom.TableSchema{
 			Schema: metaCom.Table{
@@ -1732,6 +2297,135 @@ var _ = ginkgo.Describe("AQL compiler", func() {
 		Ω(qc.Error).ShouldNot(BeNil())
 	})
 
+	ginkgo.It("processJoinConditions with slowly changing dimension table", func() {
+		tripsSchema := &memCom.TableSchema{
+			ColumnIDs: map[string]int{
+				"request_at": 0,
+				"driver_id":  1,
+			},
+			Schema: metaCom.Table{
+				Name:        "trips",
+				IsFactTable: true,
+				Columns: []metaCom.Column{
+					{Name: "request_at", Type: metaCom.Uint32},
+					{Name: "driver_id", Type: metaCom.Uint32},
+				},
+			},
+			ValueTypeByColumn: []memCom.DataType{
+				memCom.Uint32,
+				memCom.Uint32,
+			},
+		}
+
+		driverTierSchema := &memCom.TableSchema{
+			ColumnIDs: map[string]int{
+				"id":         0,
+				"valid_from": 1,
+				"valid_to":   2,
+				"tier":       3,
+			},
+			Schema: metaCom.Table{
+				Name:        "driver_tiers",
+				IsFactTable: false,
+				Columns: []metaCom.Column{
+					{Name: "id", Type: metaCom.Uint32},
+					{Name: "valid_from", Type: metaCom.Uint32},
+					{Name: "valid_to", Type: metaCom.Uint32},
+					{Name: "tier", Type: metaCom.Uint8},
+				},
+				PrimaryKeyColumns: []int{0, 1},
+				Config: metaCom.TableConfig{
+					ValidFromColumn: "valid_from",
+					ValidToColumn:   "valid_to",
+				},
+			},
+			ValueTypeByColumn: []memCom.DataType{
+				memCom.Uint32,
+				memCom.Uint32,
+				memCom.Uint32,
+				memCom.Uint8,
+			},
+		}
+
+		qc := &AQLQueryContext{
+			Query: &queryCom.AQLQuery{
+				Table: "trips",
+				Measures: []queryCom.Measure{
+					{Expr: "count()"},
+				},
+				Joins: []queryCom.Join{
+					{
+						Table: "driver_tiers",
+						Conditions: []string{
+							"trips.driver_id = driver_tiers.id",
+						},
+					},
+				},
+			},
+			TableSchemaByName: map[string]*memCom.TableSchema{
+				"trips":        tripsSchema,
+				"driver_tiers": driverTierSchema,
+			},
+			TableIDByAlias: map[string]int{
+				"trips":        0,
+				"driver_tiers": 1,
+			},
+			TableScanners: []*TableScanner{
+				{Schema: tripsSchema, ColumnUsages: make(map[int]columnUsage)},
+				{Schema: driverTierSchema, ColumnUsages: make(map[int]columnUsage)},
+			},
+		}
+		qc.parseExprs()
+		Ω(qc.Error).Should(BeNil())
+		qc.resolveTypes()
+		qc.processJoinConditions()
+		Ω(qc.Error).Should(BeNil())
+
+		ft := qc.OOPK.foreignTables[0]
+		Ω(ft.asOfJoin).Should(BeTrue())
+		Ω(ft.validFromColumnID).Should(Equal(1))
+		Ω(ft.validToColumnID).Should(Equal(2))
+		Ω(qc.TableScanners[0].ColumnUsages[0]).Should(Equal(columnUsedByAllBatches))
+		Ω(qc.TableScanners[0].ColumnUsages[1]).Should(Equal(columnUsedByAllBatches))
+
+		// main table must be fact table
+		tripsSchema.Schema.IsFactTable = false
+		qc.TableScanners[0].ColumnUsages = make(map[int]columnUsage)
+		qc.processJoinConditions()
+		Ω(qc.Error).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("reports feature usage", func() {
+		utils.Init(common.AresServerConfig{}, common.NewLoggerFactory().GetDefaultLogger(),
+			common.NewLoggerFactory().GetDefaultLogger(), tally.NewTestScope("test", nil))
+		defer utils.ResetDefaults()
+
+		qc := &AQLQueryContext{
+			Query: &queryCom.AQLQuery{
+				Table: "trips",
+				Measures: []queryCom.Measure{
+					{Expr: "sum(fare)", Filters: []string{"fare > 0"}},
+				},
+				Dimensions: []queryCom.Dimension{
+					{Expr: "from_unixtime(request_at / 1000)"},
+				},
+				Filters: []string{"city_id = 1"},
+			},
+			Origin: "test_service",
+		}
+		qc.parseExprs()
+		Ω(qc.Error).Should(BeNil())
+		qc.reportFeatureUsage()
+
+		counters := utils.GetRootReporter().GetRootScope().(tally.TestScope).Snapshot().Counters()
+		Ω(counters).Should(HaveKey("test.query_feature_used+component=query,feature=sum,kind=function,origin=test_service"))
+		Ω(counters).Should(HaveKey("test.query_feature_used+component=query,feature=from_unixtime,kind=function,origin=test_service"))
+		Ω(counters).Should(HaveKey("test.query_feature_used+component=query,feature=/,kind=operator,origin=test_service"))
+		Ω(counters).Should(HaveKey("test.query_feature_used+component=query,feature=>,kind=operator,origin=test_service"))
+		Ω(counters).Should(HaveKey("test.query_feature_used+component=query,feature==,kind=operator,origin=test_service"))
+		Ω(counters).Should(HaveLen(5))
+	})
+
 	ginkgo.It("processes foreign table related filters", func() {
 		tripsSchema := &memCom.TableSchema{
 			ValueTypeByColumn: []memCom.DataType{
@@ -2890,8 +3584,12 @@ var _ = ginkgo.Describe("AQL compiler", func() {
 				shapeUUIDs: nil, shapeLatLongs: nullDevicePointer, shapeIndexs: nullDevicePointer, validShapeUUIDs: nil, dimIndex: -1, inOrOut: true}))
 		qc.processMeasure()
 		qc.processDimensions()
-		Ω(qc.Error).ShouldNot(BeNil())
-		Ω(qc.Error.Error()).Should(ContainSubstring("Only geo uuid is allowed in dimensions"))
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.OOPK.geoIntersection.dimIndex).Should(Equal(0))
+		Ω(qc.OOPK.geoIntersection.dimColumnID).Should(Equal(2))
+		dimVarRef, ok := qc.OOPK.Dimensions[0].(*expr.VarRef)
+		Ω(ok).Should(BeTrue())
+		Ω(dimVarRef.DataType).Should(Equal(memCom.Uint8))
 
 		// Only hex(uuid) or uuid supported.
 		qc = AQLQueryContext{}
@@ -3199,6 +3897,62 @@ var _ = ginkgo.Describe("AQL compiler", func() {
 		Ω(qc.Query.FiltersParsed[1].String()).Should(Equal("table1.time_col % 86400 / 3600 = 21"))
 	})
 
+	ginkgo.It("time functions should convert Time64 columns to seconds", func() {
+		query := &queryCom.AQLQuery{
+			Table:   "table1",
+			Filters: []string{"dayofweek(table1.event_time) = 2", "hour(table1.event_time) = 21"},
+			Dimensions: []queryCom.Dimension{
+				{Expr: "event_time", TimeBucketizer: "hour"},
+			},
+		}
+		tableSchema := &memCom.TableSchema{
+			ColumnIDs: map[string]int{
+				"time_col":   0,
+				"event_time": 1,
+			},
+			Schema: metaCom.Table{
+				Name:        "table1",
+				IsFactTable: true,
+				Columns: []metaCom.Column{
+					{Name: "time_col", Type: metaCom.Uint32},
+					{Name: "event_time", Type: metaCom.Time64},
+				},
+			},
+			ValueTypeByColumn: []memCom.DataType{
+				memCom.Uint32,
+				memCom.Int64,
+			},
+		}
+		qc := AQLQueryContext{
+			Query: query,
+			TableSchemaByName: map[string]*memCom.TableSchema{
+				"table1": tableSchema,
+			},
+			TableIDByAlias: map[string]int{
+				"table1": 0,
+			},
+			TableScanners: []*TableScanner{
+				{Schema: tableSchema, ColumnUsages: make(map[int]columnUsage)},
+			},
+			fixedTimezone: time.UTC,
+		}
+		qc.parseExprs()
+		Ω(qc.Error).Should(BeNil())
+		qc.resolveTypes()
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.Query.FiltersParsed[0].String()).Should(Equal("MILLIS_TO_SECONDS(table1.event_time) / 86400 + 4 % 7 + 1 = 2"))
+		Ω(qc.Query.FiltersParsed[1].String()).Should(Equal("MILLIS_TO_SECONDS(table1.event_time) % 86400 / 3600 = 21"))
+		Ω(qc.Query.Dimensions[0].ExprParsed.String()).Should(Equal("MILLIS_TO_SECONDS(event_time) FLOOR 3600"))
+
+		// time filter on Time64 column compares seconds.
+		qc.Query.TimeFilter = queryCom.TimeFilter{Column: "event_time", From: "-1d"}
+		qc.fromTime, qc.toTime, qc.Error = queryCom.ParseTimeFilter(qc.Query.TimeFilter, time.UTC, utils.Now())
+		Ω(qc.Error).Should(BeNil())
+		qc.processTimeFilter()
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.OOPK.MainTableCommonFilters[0].(*expr.BinaryExpr).LHS.String()).Should(Equal("MILLIS_TO_SECONDS(event_time)"))
+	})
+
 	ginkgo.It("convert_tz should work", func() {
 		query := &queryCom.AQLQuery{
 			Table: "table1",
@@ -3251,6 +4005,92 @@ var _ = ginkgo.Describe("AQL compiler", func() {
 		qc.resolveTypes()
 		Ω(qc.Error).ShouldNot(BeNil())
 		Ω(qc.Error.Error()).Should(ContainSubstring("from_unixtime must be time column / 1000"))
+
+		// strict mode
+		qc.Query.Strict = true
+		qc.Error = nil
+		qc.parseExprs()
+		qc.resolveTypes()
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.Query.FiltersParsed[0].String()).Should(Equal("table1.time_col + -25200 = 2"))
+
+		qc.Query.Filters = []string{"convert_tz(table1.time_col, 'GMT', 'America/Los_Angeles') = 2"}
+		qc.parseExprs()
+		qc.resolveTypes()
+		Ω(qc.Error).ShouldNot(BeNil())
+		Ω(qc.Error.Error()).Should(ContainSubstring("daylight saving time in strict mode"))
+	})
+
+	ginkgo.It("tri-valued logic should work", func() {
+		query := &queryCom.AQLQuery{
+			Table: "table1",
+			Dimensions: []queryCom.Dimension{
+				{Expr: "table1.id > 1 AND table1.is_first"},
+			},
+			Filters: []string{
+				"table1.is_first IS TRUE",
+				"NOT (table1.id > 1 AND table1.is_first)",
+				"table1.id = 1 AND table1.is_first IS FALSE",
+				"table1.id IN (1, NULL)",
+				"table1.is_first OR NOT (table1.id != NULL)",
+			},
+		}
+		tableSchema := &memCom.TableSchema{
+			ColumnIDs: map[string]int{
+				"id":       0,
+				"is_first": 1,
+			},
+			Schema: metaCom.Table{
+				Name: "table1",
+				Columns: []metaCom.Column{
+					{Name: "id", Type: metaCom.Uint16},
+					{Name: "is_first", Type: metaCom.Bool},
+				},
+			},
+			ValueTypeByColumn: []memCom.DataType{
+				memCom.Uint16,
+				memCom.Bool,
+			},
+		}
+		qc := AQLQueryContext{
+			Query: query,
+			TableSchemaByName: map[string]*memCom.TableSchema{
+				"table1": tableSchema,
+			},
+			TableIDByAlias: map[string]int{
+				"table1": 0,
+			},
+			TableScanners: []*TableScanner{
+				{Schema: tableSchema, ColumnUsages: make(map[int]columnUsage)},
+			},
+		}
+		qc.parseExprs()
+		Ω(qc.Error).Should(BeNil())
+		qc.resolveTypes()
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.Query.Dimensions[0].ExprParsed.String()).Should(Equal("table1.id > 1 AND table1.is_first"))
+		Ω(qc.Query.FiltersParsed).Should(HaveLen(6))
+		Ω(qc.Query.FiltersParsed[0].String()).Should(Equal("table1.is_first"))
+		Ω(qc.Query.FiltersParsed[1].String()).Should(Equal("NOT(table1.id > 1 AND table1.is_first)"))
+		Ω(qc.Query.FiltersParsed[2].String()).Should(Equal("table1.id = 1"))
+		Ω(qc.Query.FiltersParsed[3].String()).Should(Equal("NOT(table1.is_first)"))
+		Ω(qc.Query.FiltersParsed[4].String()).Should(Equal("table1.id = 1 OR table1.id = NULL"))
+		Ω(qc.Query.FiltersParsed[5].String()).Should(Equal("table1.is_first OR NOT(table1.id != NULL)"))
+
+		qc.Query.TriValuedLogic = true
+		qc.parseExprs()
+		qc.resolveTypes()
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.Query.Dimensions[0].ExprParsed.String()).Should(Equal("NOT(NOT(table1.id > 1) OR NOT(table1.is_first))"))
+		Ω(qc.Query.FiltersParsed).Should(HaveLen(6))
+		Ω(qc.Query.FiltersParsed[0].String()).Should(Equal("NOT(NOT(table1.is_first) OR table1.is_first IS NULL)"))
+		Ω(qc.Query.FiltersParsed[1].String()).Should(Equal("NOT(NOT(NOT(table1.id > 1) OR NOT(table1.is_first)))"))
+		// top level AND operators are normalized into separate filters.
+		Ω(qc.Query.FiltersParsed[2].String()).Should(Equal("table1.id = 1"))
+		Ω(qc.Query.FiltersParsed[3].String()).Should(Equal("NOT(table1.is_first OR table1.is_first IS NULL)"))
+		// comparisons with NULL are folded to NULL, so IN is null instead of false if nothing matches.
+		Ω(qc.Query.FiltersParsed[4].String()).Should(Equal("table1.id = 1 OR NULL"))
+		Ω(qc.Query.FiltersParsed[5].String()).Should(Equal("table1.is_first OR NULL"))
 	})
 
 	ginkgo.It("parses point expressions", func() {
@@ -3886,6 +4726,150 @@ var _ = ginkgo.Describe("AQL compiler", func() {
 		}))
 	})
 
+	ginkgo.It("rewrite map column key access should work", func() {
+		schema := &memCom.TableSchema{
+			ValueTypeByColumn: []memCom.DataType{
+				memCom.Uint32,
+				memCom.ArrayFloat32,
+			},
+			ColumnIDs: map[string]int{
+				"request_at": 0,
+				"properties": 1,
+			},
+			Schema: metaCom.Table{
+				Name:        "table1",
+				IsFactTable: true,
+				Columns: []metaCom.Column{
+					{Name: "request_at", Type: metaCom.Uint32},
+					{Name: "properties", Type: metaCom.MapFloat32},
+				},
+			},
+			EnumDicts: map[string]memCom.EnumDict{
+				"properties": {
+					Dict: map[string]int{
+						"surge": 0,
+						"tip":   1,
+					},
+					ReverseDict: []string{"surge", "tip"},
+				},
+			},
+		}
+
+		qc := &AQLQueryContext{
+			Query: &queryCom.AQLQuery{
+				Table: "table1",
+				Measures: []queryCom.Measure{
+					{Expr: "1"},
+				},
+				Dimensions: []queryCom.Dimension{
+					{Expr: "properties['unknown']"},
+				},
+				Filters: []string{
+					"properties['tip'] > 1",
+				},
+			},
+			TableIDByAlias: map[string]int{
+				"table1": 0,
+			},
+			TableScanners: []*TableScanner{
+				{Schema: schema, ColumnUsages: map[int]columnUsage{0: columnUsedByLiveBatches}},
+			},
+		}
+		qc.parseExprs()
+		qc.resolveTypes()
+		Ω(qc.Error).Should(BeNil())
+		Ω(qc.Query.FiltersParsed[0].(*expr.BinaryExpr).LHS.(*expr.BinaryExpr).RHS).Should(Equal(
+			&expr.NumberLiteral{Val: 1, Int: 1, Expr: "1", ExprType: expr.Unsigned}))
+		Ω(qc.Query.Dimensions[0].ExprParsed.(*expr.BinaryExpr).RHS).Should(Equal(
+			&expr.NumberLiteral{Val: 256, Int: 256, Expr: "256", ExprType: expr.Unsigned}))
+
+		qc.Query.Filters = []string{"properties[0] > 1"}
+		qc.parseExprs()
+		qc.resolveTypes()
+		Ω(qc.Error).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("extracts nearest_neighbors filter", func() {
+		schema := &memCom.TableSchema{
+			ValueTypeByColumn: []memCom.DataType{
+				memCom.Uint32,
+				memCom.ArrayFloat32,
+			},
+			ColumnIDs: map[string]int{
+				"id":        0,
+				"embedding": 1,
+			},
+			Schema: metaCom.Table{
+				Name: "table1",
+				Columns: []metaCom.Column{
+					{Name: "id", Type: metaCom.Uint32},
+					{Name: "embedding", Type: metaCom.VectorFloat32, VectorDimension: 2},
+				},
+			},
+		}
+
+		newQC := func(filter string, dimension string) *AQLQueryContext {
+			qc := &AQLQueryContext{
+				Query: &queryCom.AQLQuery{
+					Table: "table1",
+					Measures: []queryCom.Measure{
+						{Expr: "count(*)"},
+					},
+					Dimensions: []queryCom.Dimension{
+						{Expr: dimension},
+					},
+					Filters: []string{filter, "id > 1"},
+				},
+				TableIDByAlias: map[string]int{
+					"table1": 0,
+				},
+				TableScanners: []*TableScanner{
+					{Schema: schema, ColumnUsages: map[int]columnUsage{}},
+				},
+			}
+			qc.parseExprs()
+			qc.resolveTypes()
+			qc.processFilters()
+			return qc
+		}
+
+		qc := newQC("nearest_neighbors(embedding, [0.5, -1], 10)", "id")
+		Ω(qc.Error).Should(BeNil())
+		nn := qc.OOPK.nearestNeighbors
+		Ω(nn).ShouldNot(BeNil())
+		Ω(nn.columnID).Should(Equal(1))
+		Ω(nn.dimension).Should(Equal(2))
+		Ω(nn.vector).Should(Equal([]float32{0.5, -1}))
+		Ω(nn.k).Should(Equal(10))
+		Ω(nn.rowIndex.(*expr.VarRef).ColumnID).Should(Equal(memCom.RowIndexColumnID))
+		Ω(qc.OOPK.MainTableCommonFilters).Should(HaveLen(1))
+		Ω(qc.TableScanners[0].ColumnUsages[memCom.RowIndexColumnID]).Should(Equal(columnUsedByAllBatches))
+
+		Ω(qc.rowIndexFilter([]uint32{3, 7, 9}).String()).Should(Equal("__row_index = 3 OR __row_index = 7 OR __row_index = 9"))
+		nn.filters = map[int]map[int32]expr.Expr{0: {1: qc.rowIndexFilter([]uint32{3})}}
+		Ω(qc.shouldSkipBatchWithoutNeighbors(0, 1)).Should(BeFalse())
+		Ω(qc.shouldSkipBatchWithoutNeighbors(0, 2)).Should(BeTrue())
+		Ω(qc.shouldSkipBatchWithoutNeighbors(1, 1)).Should(BeTrue())
+
+		Ω(newQC("nearest_neighbors(embedding, [0.5], 10)", "id").Error).ShouldNot(BeNil())
+		Ω(newQC("nearest_neighbors(embedding, [0.5, -1], 0)", "id").Error).ShouldNot(BeNil())
+		Ω(newQC("nearest_neighbors(id, [0.5, -1], 10)", "id").Error).ShouldNot(BeNil())
+		Ω(newQC("not nearest_neighbors(embedding, [0.5, -1], 10)", "id").Error).ShouldNot(BeNil())
+		Ω(newQC("id > 0", "nearest_neighbors(embedding, [0.5, -1], 10)").Error).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("keeps the k nearest neighbors", func() {
+		neighbors := &neighborHeap{k: 2}
+		for i, distance := range []float32{5, 1, 3, 0.5, 4} {
+			neighbors.add(neighbor{row: uint32(i), distance: distance})
+		}
+		var rows []uint32
+		for _, n := range neighbors.items {
+			rows = append(rows, n.row)
+		}
+		Ω(rows).Should(ConsistOf(uint32(1), uint32(3)))
+	})
+
 	ginkgo.It("parses uuid expressions", func() {
 		q := &queryCom.AQLQuery{
 			Table: "trips",
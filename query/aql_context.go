@@ -19,6 +19,7 @@ import "C"
 
 import (
 	"bytes"
+	"context"
 	"github.com/uber/aresdb/cgoutils"
 	memCom "github.com/uber/aresdb/memstore/common"
 	queryCom "github.com/uber/aresdb/query/common"
@@ -118,6 +119,15 @@ type foreignTable struct {
 	// primary key data at host.
 	hostPrimaryKeyData  memCom.PrimaryKeyData
 	devicePrimaryKeyPtr devicePointer
+
+	// Following fields are only used for as of join against slowly changing dimension table.
+	asOfJoin          bool
+	validFromColumnID int
+	// -1 if validTo column is not configured.
+	validToColumnID int
+	// validFroms, validTos and previous record ids of each record slot.
+	deviceVersionIndexPtr devicePointer
+	batchSize             int
 }
 
 // deviceVectorPartySlice stores pointers to data for a column in device memory.
@@ -279,6 +289,9 @@ type OOPKContext struct {
 	// nil means no geo intersection
 	geoIntersection *geoIntersection
 
+	// nil means no nearest_neighbors filter
+	nearestNeighbors *nearestNeighbors
+
 	// Result storage in host memory. The format is the same as the dimension and
 	// measure vector in oopkBatchContext.
 	dimensionVectorH unsafe.Pointer
@@ -302,6 +315,15 @@ type OOPKContext struct {
 	// indicate query can be return in the middle, no need to process all batches,
 	// this is usually for non-aggregation query with limit condition
 	done bool
+
+	// set when any dimension has more distinct values than the configured max dimension
+	// cardinality, the query is aborted with this error once pending batches are drained.
+	dimCardinalityErr error
+	// pinned host buffer reused by all batches of the query for counting distinct dimension
+	// values, and the number of groups when dimension cardinality was last checked.
+	dimCardinalityVectorH        unsafe.Pointer
+	dimCardinalityVectorCapacity int
+	dimCardinalityCheckedSize    int
 }
 
 // timezoneTableContext stores context for timezone column queries
@@ -316,6 +338,8 @@ type resultFlushContext struct {
 	dimensionValueCache []map[queryCom.TimeDimensionMeta]map[int64]string
 	dimensionDataTypes  []memCom.DataType
 	reverseDicts        map[int][]string
+	// decimal scales of Decimal dimensions
+	decimalScales []int
 	// for eager flush non-agg query result
 	rowsFlushed int
 }
@@ -339,14 +363,21 @@ type geoIntersection struct {
 	// if <0, meaning there is no dimension for geo
 	// and this query only has geo filter
 	dimIndex int
+	// ID of the geo table column used as the geo dimension, results of shapes having
+	// the same value of this column are reduced into one row.
+	dimColumnID int
 
 	// Following fields are generated by processor
 	shapeLatLongs devicePointer
 	shapeIndexs   devicePointer
-	// map from shape index to index of shapeUUID
+	// map from shape index to geo dimension value (shape uuid by default)
 	validShapeUUIDs []string
 	numShapes       int
 	totalNumPoints  int
+	// s2 cell index used to prefilter points before point in shape tests,
+	// refer to buildGeoCellIndex for the layout.
+	cellIndex devicePointer
+	numCells  int
 }
 
 // AQLQueryContext stores all contextual data for handling an AQL query.
@@ -372,6 +403,8 @@ type AQLQueryContext struct {
 	Prefilters []int `json:"prefilters,omitempty"`
 
 	Error error `json:"error,omitempty"`
+	// Warnings found when compiling the query, e.g. unknown enum values in filters.
+	Warnings []string `json:"warnings,omitempty"`
 
 	Device int `json:"device"`
 
@@ -379,9 +412,35 @@ type AQLQueryContext struct {
 
 	Profiling string `json:"profiling,omitempty"`
 
+	// Caller of the query, used to tag feature usage metrics.
+	Origin string `json:"origin,omitempty"`
+
+	// Shards and batches scanned and pruned by the query, reported to broker.
+	ScanStats queryCom.ScanStats `json:"scanStats"`
+	// Shards of the main table scanned by the query, reported to broker as coverage tokens.
+	ShardsCovered []int `json:"shardsCovered,omitempty"`
+
+	// Authenticated principal of the query, nil if authentication is not enabled. Table acls
+	// are enforced against it when compiling.
+	Principal *utils.Principal `json:"-"`
+
+	// Request id of the query, used to inspect and kill running queries.
+	RequestID string `json:"requestID,omitempty"`
+	// Progress of the query for inspection and kill signal, accessed atomically.
+	shardsProcessed int32
+	bytesScanned    int64
+	killed          int32
+
+	// Context carrying the tracing span of the request, per shard scans are traced as its children.
+	TraceContext context.Context `json:"-"`
+
 	// We alternate with two Cuda streams between batches for pipelining.
 	// [0] stores the current stream, and [1] stores the other stream.
 	cudaStreams [2]unsafe.Pointer
+	// Cuda events recorded before and after kernels of each batch, for timing the batch on device.
+	deviceTimer [2]unsafe.Pointer
+	// Seconds kernels of the query run on device, including partitions on other devices.
+	deviceSeconds float64
 
 	Results            queryCom.AQLQueryResult `json:"-"`
 	resultFlushContext resultFlushContext
@@ -397,19 +456,52 @@ type AQLQueryContext struct {
 	fixedTimezone *time.Location
 	fromTime      *queryCom.AlignedTime
 	toTime        *queryCom.AlignedTime
-	dstswitch     int64
+	// utc offsets of fixedTimezone within the time range if it changes within the range,
+	// used to convert time dimensions back to utc.
+	timezoneTransitions *utils.TimezoneTransitions
 
 	// timezone column and time filter related
 	timezoneTable timezoneTableContext
 
+	// whether results are answered from daily row counts in metadata without processing.
+	answeredFromDailyRowCounts bool
+
+	// number of bits of the hash used as register index of hll measure values.
+	hllPrecision int
+
 	// fields for non aggregate query
 	// Flag to indicate if this query is not aggregation query
 	IsNonAggregationQuery      bool
 	numberOfRowsWritten        int
 	maxBatchSizeAfterPrefilter int
+	// indexes of dimensions expanded from the wildcard dimension.
+	wildcardDimensions []int
+	// wide columns fetched for the selected rows only, nil if no column is deferred.
+	deferredColumns *deferredColumnFetch
 
 	// for eager flush query result
 	ResponseWriter http.ResponseWriter
+
+	// query before compilation, used to compile partitions of the query. Only kept when
+	// queries can be split across devices.
+	rawQuery []byte
+	// partitions of the query by archive batch range processed concurrently on other devices,
+	// their results are reduced into this context on host.
+	partitions []*AQLQueryContext
+	// combines measure values of the same dimensions from different partitions or enum values
+	// in the same map_values group.
+	reduceMeasure func(lhs, rhs float64) float64
+	// whether any dimension groups enum values by map_values.
+	groupedDimensions bool
+	// device manager the device is found from, archive batch columns are cached on the device
+	// through it if column cache is enabled.
+	deviceManager *DeviceManager
+	// columns transferred for the current batch to be admitted to column cache.
+	pendingColumns []pendingDeviceColumn
+	// cached columns pinned by the current and previous batch, released after the batch
+	// is executed.
+	pinnedColumns         []deviceColumnKey
+	previousPinnedColumns []deviceColumnKey
 }
 
 // IsHLL return if the aggregation function is HLL
@@ -0,0 +1,227 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+// #include "time_series_aggregate.h"
+import "C"
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/uber/aresdb/cluster/topology"
+	"github.com/uber/aresdb/memstore"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// SplitAcrossDevices splits the compiled aggregation query by archive batch range into partitions
+// processed concurrently on up to Query.MaxDevicesPerQuery devices, including the device already
+// found for the query. Partitions only use devices immediately available, and reserve the same memory
+// as the query since memory requirement is estimated by the largest batch. Live batches are only
+// processed by the query itself. The query stays on its own device if it cannot be split.
+func (qc *AQLQueryContext) SplitAcrossDevices(memStore memstore.MemStore, shardOwner topology.ShardOwner,
+	deviceManager *DeviceManager) {
+	maxPartitions := utils.GetConfig().Query.MaxDevicesPerQuery
+	reduceMeasure := getMeasureReducer(qc.OOPK.AggregateType)
+	if maxPartitions <= 1 || reduceMeasure == nil || !qc.canSplitAcrossDevices() {
+		return
+	}
+
+	batchIDs := qc.getArchiveBatchIDs(memStore)
+	if maxPartitions > len(batchIDs) {
+		maxPartitions = len(batchIDs)
+	}
+
+	devices := []int{qc.Device}
+	var partitions []*AQLQueryContext
+	for len(partitions)+1 < maxPartitions {
+		partition := qc.newPartition(memStore, shardOwner)
+		if partition == nil {
+			break
+		}
+		partition.Device = deviceManager.TryFindDevice(partition.Query, qc.OOPK.DeviceMemoryRequirement, devices)
+		if partition.Device < 0 {
+			break
+		}
+		partition.OOPK.DeviceMemoryRequirement = qc.OOPK.DeviceMemoryRequirement
+		partition.deviceManager = deviceManager
+		devices = append(devices, partition.Device)
+		partitions = append(partitions, partition)
+	}
+
+	if len(partitions) == 0 {
+		return
+	}
+
+	// split the archive batch range so that each partition gets about the same number of existing
+	// batches, the ranges still cover the whole range of the query.
+	numPartitions := len(partitions) + 1
+	qc.TableScanners[0].ArchiveBatchIDEnd = int(batchIDs[len(batchIDs)/numPartitions])
+	for i, partition := range partitions {
+		scanner := partition.TableScanners[0]
+		scanner.ArchiveBatchIDStart = int(batchIDs[len(batchIDs)*(i+1)/numPartitions])
+		if i < len(partitions)-1 {
+			scanner.ArchiveBatchIDEnd = int(batchIDs[len(batchIDs)*(i+2)/numPartitions])
+		}
+		partition.reduceMeasure = reduceMeasure
+	}
+	qc.reduceMeasure = reduceMeasure
+	qc.partitions = partitions
+
+	utils.GetRootReporter().GetChildCounter(map[string]string{
+		"table": qc.Query.Table,
+	}, utils.QueryDevicePartitions).Inc(int64(numPartitions))
+}
+
+// ReleasePartitions releases the memory reserved on devices by partitions of the query.
+func (qc *AQLQueryContext) ReleasePartitions(deviceManager *DeviceManager) {
+	for _, partition := range qc.partitions {
+		deviceManager.ReleaseReservedMemory(partition.Device, partition.Query)
+	}
+}
+
+// canSplitAcrossDevices returns whether partial results of the query from different archive batch
+// ranges can be reduced on host.
+func (qc *AQLQueryContext) canSplitAcrossDevices() bool {
+	if qc.rawQuery == nil || qc.IsNonAggregationQuery || qc.ReturnHLLData || qc.ResponseWriter != nil ||
+		!qc.Query.ScanArchive() || len(qc.TableScanners) == 0 {
+		return false
+	}
+	// nearest neighbors are searched across all shards and batches.
+	if qc.OOPK.nearestNeighbors != nil {
+		return false
+	}
+	schema := qc.TableScanners[0].Schema
+	schema.RLock()
+	defer schema.RUnlock()
+	return schema.Schema.IsFactTable
+}
+
+// getMeasureReducer returns the function to combine measure values of the same dimensions
+// from different partitions, nil if the aggregate function cannot be combined this way.
+func getMeasureReducer(aggType C.enum_AggregateFunction) func(lhs, rhs float64) float64 {
+	switch aggType {
+	case C.AGGR_SUM_UNSIGNED, C.AGGR_SUM_SIGNED, C.AGGR_SUM_FLOAT:
+		return func(lhs, rhs float64) float64 { return lhs + rhs }
+	case C.AGGR_MIN_UNSIGNED, C.AGGR_MIN_SIGNED, C.AGGR_MIN_FLOAT:
+		return math.Min
+	case C.AGGR_MAX_UNSIGNED, C.AGGR_MAX_SIGNED, C.AGGR_MAX_FLOAT:
+		return math.Max
+	}
+	return nil
+}
+
+// getArchiveBatchIDs returns sorted ids of archive batches in the range of the query across all shards.
+func (qc *AQLQueryContext) getArchiveBatchIDs(memStore memstore.MemStore) []int32 {
+	scanner := qc.TableScanners[0]
+	batchIDSet := make(map[int32]struct{})
+	for _, shardID := range scanner.Shards {
+		shard, err := memStore.GetTableShard(qc.Query.Table, shardID)
+		if err != nil {
+			continue
+		}
+		archiveStore := shard.ArchiveStore.GetCurrentVersion()
+		archiveStore.RLock()
+		for batchID := range archiveStore.Batches {
+			if int(batchID) >= scanner.ArchiveBatchIDStart && int(batchID) < scanner.ArchiveBatchIDEnd {
+				batchIDSet[batchID] = struct{}{}
+			}
+		}
+		archiveStore.RUnlock()
+		archiveStore.Users.Done()
+		shard.Users.Done()
+	}
+
+	batchIDs := make([]int32, 0, len(batchIDSet))
+	for batchID := range batchIDSet {
+		batchIDs = append(batchIDs, batchID)
+	}
+	sort.Slice(batchIDs, func(i, j int) bool { return batchIDs[i] < batchIDs[j] })
+	return batchIDs
+}
+
+// newPartition compiles the query again into a new context scanning archive batches only.
+func (qc *AQLQueryContext) newPartition(memStore memstore.MemStore, shardOwner topology.ShardOwner) *AQLQueryContext {
+	query := &queryCom.AQLQuery{}
+	if err := json.Unmarshal(qc.rawQuery, query); err != nil {
+		return nil
+	}
+
+	partition := &AQLQueryContext{
+		Query:         query,
+		ReturnHLLData: qc.ReturnHLLData,
+		DataOnly:      qc.DataOnly,
+		Origin:        qc.Origin,
+		RequestID:     qc.RequestID,
+		Debug:         qc.Debug,
+		Profiling:     qc.Profiling,
+	}
+	partition.Compile(memStore, shardOwner)
+	if partition.Error != nil {
+		utils.GetLogger().With("error", partition.Error, "query", qc.Query).
+			Error("Failed to compile partition of query")
+		return nil
+	}
+	partition.rawQuery = nil
+	partition.Query.DataScope = queryCom.DataScopeArchive
+	return partition
+}
+
+// processPartitions starts processing partitions of the query on their devices and returns the
+// function to wait for them. Errors and scan stats of the partitions are merged into the query
+// after they finish.
+func (qc *AQLQueryContext) processPartitions(memStore memstore.MemStore) (wait func()) {
+	var wg sync.WaitGroup
+	for _, partition := range qc.partitions {
+		partition.TraceContext = qc.TraceContext
+		wg.Add(1)
+		go func(partition *AQLQueryContext) {
+			defer wg.Done()
+			partition.ProcessQuery(memStore)
+		}(partition)
+	}
+
+	return func() {
+		wg.Wait()
+		for _, partition := range qc.partitions {
+			if qc.Error == nil && partition.Error != nil {
+				qc.Error = partition.Error
+			}
+			qc.ScanStats.BatchesScanned += partition.ScanStats.BatchesScanned
+			qc.ScanStats.BatchesSkipped += partition.ScanStats.BatchesSkipped
+			qc.deviceSeconds += partition.deviceSeconds
+			// a shard is only pruned if none of the partitions scanned it, which is
+			// approximated by the least number of shards pruned.
+			if partition.ScanStats.ShardsPruned < qc.ScanStats.ShardsPruned {
+				qc.ScanStats.ShardsPruned = partition.ScanStats.ShardsPruned
+			}
+		}
+		if qc.Error != nil {
+			qc.ReleaseHostResultsBuffers()
+		}
+	}
+}
+
+// bytesScannedByPartitions returns the number of bytes transferred to devices by partitions of the query.
+func (qc *AQLQueryContext) bytesScannedByPartitions() (bytes int64) {
+	for _, partition := range qc.partitions {
+		bytes += atomic.LoadInt64(&partition.bytesScanned)
+	}
+	return
+}
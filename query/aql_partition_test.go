@@ -0,0 +1,46 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	queryCom "github.com/uber/aresdb/query/common"
+)
+
+var _ = ginkgo.Describe("aql partition", func() {
+	ginkgo.It("getMeasureReducer should work", func() {
+		// sum
+		Ω(getMeasureReducer(1)(1, 2)).Should(Equal(3.0))
+		Ω(getMeasureReducer(3)(1.5, 2)).Should(Equal(3.5))
+		// min
+		Ω(getMeasureReducer(5)(-1, 2)).Should(Equal(-1.0))
+		// max
+		Ω(getMeasureReducer(9)(-1, 2)).Should(Equal(2.0))
+		// hll and avg
+		Ω(getMeasureReducer(10)).Should(BeNil())
+		Ω(getMeasureReducer(11)).Should(BeNil())
+	})
+
+	ginkgo.It("SplitAcrossDevices should skip queries that cannot be split", func() {
+		qc := &AQLQueryContext{
+			Query:                 &queryCom.AQLQuery{Table: "trips"},
+			IsNonAggregationQuery: true,
+		}
+		qc.SplitAcrossDevices(nil, nil, nil)
+		Ω(qc.partitions).Should(BeEmpty())
+		Ω(qc.canSplitAcrossDevices()).Should(BeFalse())
+	})
+})
@@ -24,6 +24,7 @@ import (
 	queryCom "github.com/uber/aresdb/query/common"
 	"github.com/uber/aresdb/query/expr"
 	"github.com/uber/aresdb/utils"
+	"math"
 	"unsafe"
 )
 
@@ -33,6 +34,9 @@ var bytesComma = []byte(",")
 // format to AQLQueryResult nested result format. It also translates enum
 // values back to their string representations.
 func (qc *AQLQueryContext) Postprocess() {
+	if qc.answeredFromDailyRowCounts {
+		return
+	}
 	oopkContext := qc.OOPK
 	if oopkContext.IsHLL() {
 		// skip translate enum for HLL if query is from broker
@@ -42,12 +46,17 @@ func (qc *AQLQueryContext) Postprocess() {
 			qc.Error = utils.StackError(err, "failed to read hll result")
 			return
 		}
-		qc.Results = queryCom.ComputeHLLResult(result)
+		qc.Results = queryCom.ComputeHLLResultWithPrecision(result, qc.hllPrecision)
 		return
 	}
 
 	if !qc.IsNonAggregationQuery {
 		qc.flushResultBuffer()
+		// reduce results of partitions processed on other devices.
+		for _, partition := range qc.partitions {
+			partition.Results = qc.Results
+			partition.flushResultBuffer()
+		}
 	}
 }
 
@@ -55,10 +64,14 @@ func (qc *AQLQueryContext) initResultFlushContext() {
 	qc.resultFlushContext.dimensionValueCache = make([]map[queryCom.TimeDimensionMeta]map[int64]string, len(qc.OOPK.Dimensions))
 	qc.resultFlushContext.dimensionDataTypes = make([]memCom.DataType, len(qc.OOPK.Dimensions))
 	qc.resultFlushContext.reverseDicts = make(map[int][]string)
+	qc.resultFlushContext.decimalScales = make([]int, len(qc.OOPK.Dimensions))
 
 	oopkContext := qc.OOPK
 	for dimIndex, dimExpr := range oopkContext.Dimensions {
 		qc.resultFlushContext.dimensionDataTypes[dimIndex], qc.resultFlushContext.reverseDicts[dimIndex] = queryCom.GetDimensionDataType(dimExpr), qc.getEnumReverseDict(dimIndex, dimExpr)
+		if varRef, ok := queryCom.AsInt64Column(dimExpr); ok {
+			qc.resultFlushContext.decimalScales[dimIndex] = varRef.DecimalScale
+		}
 	}
 }
 
@@ -73,7 +86,8 @@ func (qc *AQLQueryContext) flushResultBuffer() {
 
 	oopkContext := qc.OOPK
 	dpc := qc.resultFlushContext
-	dimValues := make([]*string, len(oopkContext.Dimensions))
+	// dimensions appended to locate rows for deferred columns are not part of results.
+	dimValues := make([]*string, len(qc.Query.Dimensions))
 
 	var fromOffset, toOffset int
 	if qc.fromTime != nil && qc.toTime != nil {
@@ -86,11 +100,16 @@ func (qc *AQLQueryContext) flushResultBuffer() {
 		dimVectorIndex := oopkContext.DimensionVectorIndex[dimIndex]
 		valueOffset, nullOffset := queryCom.GetDimensionStartOffsets(oopkContext.NumDimsPerDimWidth, dimVectorIndex, oopkContext.ResultSize)
 		dimOffsets[dimIndex] = [2]int{valueOffset, nullOffset}
+		// values of String columns are merged into global dictionaries while batches are
+		// transferred so their reverse dicts need to be refreshed.
+		if dpc.dimensionDataTypes[dimIndex] == memCom.String {
+			dpc.reverseDicts[dimIndex] = qc.getEnumReverseDict(dimIndex, oopkContext.Dimensions[dimIndex])
+		}
 	}
 
 	for i := 0; i < oopkContext.ResultSize; i++ {
 		dimReadingStart := utils.Now()
-		for dimIndex := range oopkContext.Dimensions {
+		for dimIndex := range dimValues {
 			offsets := dimOffsets[dimIndex]
 			valueOffset, nullOffset := offsets[0], offsets[1]
 			valuePtr, nullPtr := utils.MemAccess(oopkContext.dimensionVectorH, valueOffset), utils.MemAccess(oopkContext.dimensionVectorH, nullOffset)
@@ -103,22 +122,28 @@ func (qc *AQLQueryContext) flushResultBuffer() {
 
 			if qc.Query.Dimensions[dimIndex].IsTimeDimension() {
 				timeDimensionMeta = &queryCom.TimeDimensionMeta{
-					TimeBucketizer:  qc.Query.Dimensions[dimIndex].TimeBucketizer,
-					TimeUnit:        qc.Query.Dimensions[dimIndex].TimeUnit,
-					IsTimezoneTable: qc.timezoneTable.tableColumn != "",
-					TimeZone:        qc.fixedTimezone,
-					DSTSwitchTs:     qc.dstswitch,
-					FromOffset:      fromOffset,
-					ToOffset:        toOffset,
+					TimeBucketizer:      qc.Query.Dimensions[dimIndex].TimeBucketizer,
+					TimeUnit:            qc.Query.Dimensions[dimIndex].TimeUnit,
+					IsTimezoneTable:     qc.timezoneTable.tableColumn != "",
+					TimeZone:            qc.fixedTimezone,
+					FromOffset:          fromOffset,
+					ToOffset:            toOffset,
+					TimezoneTransitions: qc.timezoneTransitions,
 				}
 			}
 
-			// don't translate enum if it's for distributed mode (DataOnly == true)
+			// don't translate enum if it's for distributed mode (DataOnly == true), String
+			// columns are still translated as broker does not have their dictionaries.
 			var enumDict []string
-			if !qc.DataOnly {
+			if !qc.DataOnly || dpc.dimensionDataTypes[dimIndex] == memCom.String {
 				enumDict = dpc.reverseDicts[dimIndex]
 			}
 
+			if dpc.dimensionDataTypes[dimIndex] == memCom.Decimal {
+				dimValues[dimIndex] = queryCom.ReadDecimalDimension(valuePtr, nullPtr, i, dpc.decimalScales[dimIndex])
+				continue
+			}
+
 			dimValues[dimIndex] = queryCom.ReadDimension(
 				valuePtr, nullPtr, i, dpc.dimensionDataTypes[dimIndex], enumDict,
 				timeDimensionMeta, dpc.dimensionValueCache[dimIndex])
@@ -126,23 +151,11 @@ func (qc *AQLQueryContext) flushResultBuffer() {
 		utils.GetRootReporter().GetTimer(utils.QueryDimReadLatency).Record(utils.Now().Sub(dimReadingStart))
 
 		if qc.IsNonAggregationQuery {
-			if qc.ResponseWriter != nil {
-				nullStr := queryCom.NULLString
-				for i, dimVal := range dimValues {
-					if dimVal == nil {
-						dimValues[i] = &nullStr
-					}
-				}
-				valuesBytes, _ := json.Marshal(dimValues)
-				if qc.resultFlushContext.rowsFlushed > 0 {
-					qc.ResponseWriter.Write(bytesComma)
-				}
-				qc.ResponseWriter.Write(valuesBytes)
-				qc.resultFlushContext.rowsFlushed++
+			if qc.deferredColumns != nil {
+				qc.deferredColumns.addRow(dimValues, oopkContext.dimensionVectorH, dimOffsets, i)
 			} else {
-				qc.Results.Append(dimValues)
+				qc.writeNonAggRow(dimValues)
 			}
-
 		} else {
 			measureBytes := oopkContext.MeasureBytes
 
@@ -155,9 +168,35 @@ func (qc *AQLQueryContext) flushResultBuffer() {
 				utils.MemAccess(oopkContext.measureVectorH, i*oopkContext.MeasureBytes), oopkContext.Measure,
 				measureBytes)
 
-			qc.Results.Set(dimValues, measureValue)
+			if qc.reduceMeasure != nil {
+				qc.Results.Reduce(dimValues, measureValue, qc.reduceMeasure)
+			} else {
+				qc.Results.Set(dimValues, measureValue)
+			}
+		}
+	}
+}
+
+// writeNonAggRow writes a row of non-aggregation query results to the response writer for eager
+// flush or appends it to results otherwise.
+func (qc *AQLQueryContext) writeNonAggRow(dimValues []*string) {
+	if qc.ResponseWriter == nil {
+		qc.Results.Append(dimValues)
+		return
+	}
+
+	nullStr := queryCom.NULLString
+	for i, dimVal := range dimValues {
+		if dimVal == nil {
+			dimValues[i] = &nullStr
 		}
 	}
+	valuesBytes, _ := json.Marshal(dimValues)
+	if qc.resultFlushContext.rowsFlushed > 0 {
+		qc.ResponseWriter.Write(bytesComma)
+	}
+	qc.ResponseWriter.Write(valuesBytes)
+	qc.resultFlushContext.rowsFlushed++
 }
 
 // PostprocessAsHLLData serializes the query result into HLLData format. It will also release the device memory after
@@ -190,6 +229,10 @@ func (qc *AQLQueryContext) getEnumReverseDict(dimIndex int, expression expr.Expr
 		return varRef.EnumReverseDict
 	}
 
+	if ok && varRef.DataType == memCom.String && varRef.StringDict != nil {
+		return varRef.StringDict.Values()
+	}
+
 	// return validShapeUUIDs as the reverse enum dict if dimIndex match geo dimension
 	if qc.OOPK.geoIntersection != nil && qc.OOPK.geoIntersection.dimIndex == dimIndex {
 		return qc.OOPK.geoIntersection.validShapeUUIDs
@@ -214,6 +257,10 @@ func (qc *AQLQueryContext) ReleaseHostResultsBuffers() {
 
 	// set geoIntersection to nil
 	qc.OOPK.geoIntersection = nil
+
+	for _, partition := range qc.partitions {
+		partition.ReleaseHostResultsBuffers()
+	}
 }
 
 func (qc *AQLQueryContext) ResultsRowsFlushed() int {
@@ -254,5 +301,10 @@ func readMeasure(measureRow unsafe.Pointer, ast expr.Expr, measureBytes int) *fl
 		return nil
 	}
 
+	// scale back aggregated decimal values
+	if varRef, ok := queryCom.AsInt64Column(ast); ok && varRef.DataType == memCom.Decimal {
+		result /= math.Pow10(varRef.DecimalScale)
+	}
+
 	return &result
 }
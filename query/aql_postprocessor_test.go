@@ -68,6 +68,47 @@ var _ = ginkgo.Describe("AQL postprocessor", func() {
 		}))
 	})
 
+	ginkgo.It("reduces results of geo shapes sharing the same dimension value", func() {
+		ctx := &AQLQueryContext{
+			Query: &queryCom.AQLQuery{
+				Dimensions: []queryCom.Dimension{
+					{Expr: "g.name"},
+				},
+			},
+			reduceMeasure: func(lhs, rhs float64) float64 { return lhs + rhs },
+		}
+		oopkContext := OOPKContext{
+			Dimensions: []expr.Expr{
+				&expr.VarRef{
+					ExprType: expr.GeoShape,
+					DataType: memCom.Uint8,
+				},
+			},
+			Measure: &expr.NumberLiteral{
+				ExprType: expr.Float,
+			},
+			MeasureBytes:         4,
+			DimRowBytes:          2,
+			DimensionVectorIndex: []int{0},
+			NumDimsPerDimWidth:   queryCom.DimCountsPerDimWidth{0, 0, 0, 0, 1},
+			ResultSize:           3,
+			dimensionVectorH:     unsafe.Pointer(&[]uint8{0, 1, 2, 1, 1, 1}[0]),
+			measureVectorH:       unsafe.Pointer(&[]float32{1, 2, 4}[0]),
+			geoIntersection: &geoIntersection{
+				dimIndex:        0,
+				validShapeUUIDs: []string{"downtown", "airport", "downtown"},
+			},
+		}
+
+		ctx.OOPK = oopkContext
+		ctx.initResultFlushContext()
+		ctx.Postprocess()
+		Ω(ctx.Results).Should(Equal(queryCom.AQLQueryResult{
+			"downtown": float64(5),
+			"airport":  float64(2),
+		}))
+	})
+
 	ginkgo.It("works on two dimensions and two rows", func() {
 		ctx := &AQLQueryContext{
 			Query: &queryCom.AQLQuery{
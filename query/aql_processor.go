@@ -15,12 +15,17 @@
 package query
 
 import (
+	"context"
 	"github.com/uber/aresdb/cgoutils"
+	"go.opentelemetry.io/otel/attribute"
 	"math"
+	"sort"
+	"sync/atomic"
 	"unsafe"
 
 	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"github.com/uber/aresdb/memstore"
 	memCom "github.com/uber/aresdb/memstore/common"
 	queryCom "github.com/uber/aresdb/query/common"
@@ -32,6 +37,8 @@ import (
 
 const (
 	hllQueryRequiredMemoryInMB = 10 * 1024
+	// max number of s2 cells of all shape coverings in the geo cell index
+	maxGeoCellIndexEntries = 1 << 16
 )
 
 // batchTransferExecutor defines the type of the functor to transfer a live batch or a archive batch
@@ -63,8 +70,16 @@ func (qc *AQLQueryContext) ProcessQuery(memStore memstore.MemStore) {
 		}
 	}()
 
+	// process partitions on other devices concurrently and wait for them before recovering
+	// from panic, so that their results can be released safely.
+	if len(qc.partitions) > 0 {
+		defer qc.processPartitions(memStore)()
+	}
+
 	qc.cudaStreams[0] = cgoutils.CreateCudaStream(qc.Device)
 	qc.cudaStreams[1] = cgoutils.CreateCudaStream(qc.Device)
+	qc.deviceTimer[0] = cgoutils.CreateCudaEvent(qc.Device)
+	qc.deviceTimer[1] = cgoutils.CreateCudaEvent(qc.Device)
 	qc.OOPK.currentBatch.device = qc.Device
 	qc.OOPK.LiveBatchStats = oopkQueryStats{
 		Name2Stage: make(map[stageName]*oopkStageSummaryStats),
@@ -107,6 +122,13 @@ func (qc *AQLQueryContext) ProcessQuery(memStore memstore.MemStore) {
 		}
 	}
 
+	if qc.OOPK.nearestNeighbors != nil {
+		qc.prepareNearestNeighbors(memStore)
+		if qc.Error != nil {
+			return
+		}
+	}
+
 	qc.initializeNonAggResponse()
 
 	qc.initResultFlushContext()
@@ -116,7 +138,8 @@ func (qc *AQLQueryContext) ProcessQuery(memStore memstore.MemStore) {
 		if qc.Error != nil {
 			return
 		}
-		if qc.OOPK.done {
+		atomic.AddInt32(&qc.shardsProcessed, 1)
+		if qc.OOPK.done || qc.checkKilled() {
 			break
 		}
 	}
@@ -124,6 +147,14 @@ func (qc *AQLQueryContext) ProcessQuery(memStore memstore.MemStore) {
 	// query execution for last batch.
 	qc.runBatchExecutor(previousBatchExecutor, true)
 
+	if qc.OOPK.dimCardinalityErr != nil {
+		qc.Error = qc.OOPK.dimCardinalityErr
+	}
+
+	if atomic.LoadInt32(&qc.killed) != 0 {
+		qc.Error = utils.StackError(nil, "query %s killed", qc.RequestID)
+	}
+
 	// this code snippet does the followings:
 	// 1. write stats to log.
 	// 2. allocate host buffer for result and copy the result from device to host.
@@ -160,8 +191,84 @@ func (qc *AQLQueryContext) ProcessQuery(memStore memstore.MemStore) {
 	qc.reportTiming(nil, &start, finalCleanupTiming)
 }
 
+// Kill signals the query to stop processing further batches, the query will fail with error.
+func (qc *AQLQueryContext) Kill() {
+	atomic.StoreInt32(&qc.killed, 1)
+	for _, partition := range qc.partitions {
+		partition.Kill()
+	}
+}
+
+// BytesScanned returns the number of bytes transferred to devices so far.
+func (qc *AQLQueryContext) BytesScanned() int64 {
+	return atomic.LoadInt64(&qc.bytesScanned) + qc.bytesScannedByPartitions()
+}
+
+// DeviceSeconds returns the seconds kernels of the query run on devices, it should only be called
+// after the query is processed.
+func (qc *AQLQueryContext) DeviceSeconds() float64 {
+	return qc.deviceSeconds
+}
+
+// TimeRange returns the effective time range of the time filter, nil if the query has no
+// time filter.
+func (qc *AQLQueryContext) TimeRange() *queryCom.QueryTimeRange {
+	if qc.fromTime == nil && qc.toTime == nil {
+		return nil
+	}
+	var timeRange queryCom.QueryTimeRange
+	if qc.fromTime != nil {
+		timeRange.From = qc.fromTime.Time.Unix()
+	}
+	if qc.toTime != nil {
+		timeRange.To = qc.toTime.Time.Unix()
+	}
+	return &timeRange
+}
+
+// checkKilled returns whether the query is killed and marks the query done if so.
+func (qc *AQLQueryContext) checkKilled() bool {
+	if atomic.LoadInt32(&qc.killed) == 0 {
+		return false
+	}
+	qc.OOPK.done = true
+	return true
+}
+
+// traceContext returns the context carrying the tracing span of the request.
+func (qc *AQLQueryContext) traceContext() context.Context {
+	if qc.TraceContext == nil {
+		return context.Background()
+	}
+	return qc.TraceContext
+}
+
+// prefetchArchiveBatches asynchronously loads archive batches adjacent to the archive batch range
+// of the query into host memory if archive prefetching is enabled.
+func (qc *AQLQueryContext) prefetchArchiveBatches(shard *memstore.TableShard) {
+	if qc.deviceManager == nil || qc.deviceManager.archivePrefetchBatches <= 0 || shard.ArchivePrefetcher == nil {
+		return
+	}
+	scanner := qc.TableScanners[0]
+	shard.ArchivePrefetcher.Prefetch(int32(scanner.ArchiveBatchIDStart), int32(scanner.ArchiveBatchIDEnd),
+		scanner.Columns, qc.deviceManager.archivePrefetchBatches)
+}
+
 func (qc *AQLQueryContext) processShard(memStore memstore.MemStore, shardID int, previousBatchExecutor BatchExecutor) BatchExecutor {
 	var liveRecordsProcessed, archiveRecordsProcessed, liveBatchProcessed, archiveBatchProcessed, liveBytesTransferred, archiveBytesTransferred int
+	// batches are pipelined, so the span also covers execution of the last batch of previous shard.
+	_, span := utils.StartSpan(qc.traceContext(), "datanode.ScanShard",
+		attribute.String("table", qc.Query.Table), attribute.Int("shard", shardID))
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("liveBatches", liveBatchProcessed),
+			attribute.Int("archiveBatches", archiveBatchProcessed),
+			attribute.Int("liveRecords", liveRecordsProcessed),
+			attribute.Int("archiveRecords", archiveRecordsProcessed),
+			attribute.Int("bytesTransferred", liveBytesTransferred+archiveBytesTransferred))
+		utils.EndSpan(span, qc.Error)
+	}()
+
 	shard, err := memStore.GetTableShard(qc.Query.Table, shardID)
 	if err != nil {
 		qc.Error = utils.StackError(err, "failed to get shard %d for table %s",
@@ -170,6 +277,15 @@ func (qc *AQLQueryContext) processShard(memStore memstore.MemStore, shardID int,
 	}
 	defer shard.Users.Done()
 
+	qc.ScanStats.ShardsRequested++
+	qc.ShardsCovered = append(qc.ShardsCovered, shardID)
+	defer func() {
+		qc.ScanStats.BatchesScanned += liveBatchProcessed + archiveBatchProcessed
+		if liveBatchProcessed+archiveBatchProcessed == 0 {
+			qc.ScanStats.ShardsPruned++
+		}
+	}()
+
 	var archiveStore *memstore.ArchiveStoreVersion
 	var cutoff uint32
 	if shard.Schema.Schema.IsFactTable {
@@ -179,10 +295,15 @@ func (qc *AQLQueryContext) processShard(memStore memstore.MemStore, shardID int,
 	}
 
 	// Process live batches.
-	if qc.toTime == nil || cutoff < uint32(qc.toTime.Time.Unix()) {
+	if qc.Query.ScanLive() && qc.toTime != nil && cutoff >= uint32(qc.toTime.Time.Unix()) {
+		// all live batches are after the time range.
+		batchIDs, _ := shard.LiveStore.GetBatchIDs()
+		qc.ScanStats.BatchesSkipped += len(batchIDs)
+	} else if qc.Query.ScanLive() {
 		batchIDs, numRecordsInLastBatch := shard.LiveStore.GetBatchIDs()
 		for i, batchID := range batchIDs {
-			if qc.OOPK.done {
+			previousBatchExecutor = qc.finishBatchForLimit(previousBatchExecutor)
+			if qc.OOPK.done || qc.checkKilled() {
 				break
 			}
 			batch := shard.LiveStore.GetBatchForRead(batchID)
@@ -193,9 +314,11 @@ func (qc *AQLQueryContext) processShard(memStore memstore.MemStore, shardID int,
 			// For now, dimension table does not persist min and max therefore
 			// we can only skip live batch for fact table.
 			// TODO: Persist min/max/numTrues when snapshotting.
-			if shard.Schema.Schema.IsFactTable && qc.shouldSkipLiveBatch(batch) {
+			if (shard.Schema.Schema.IsFactTable && qc.shouldSkipLiveBatch(batch)) ||
+				qc.shouldSkipBatchWithoutNeighbors(shardID, batchID) {
 				batch.RUnlock()
 				qc.OOPK.LiveBatchStats.NumBatchSkipped++
+				qc.ScanStats.BatchesSkipped++
 				continue
 			}
 
@@ -208,23 +331,29 @@ func (qc *AQLQueryContext) processShard(memStore memstore.MemStore, shardID int,
 			previousBatchExecutor = qc.processBatch(&batch.Batch,
 				batchID,
 				size,
-				qc.transferLiveBatch(batch, size),
-				qc.liveBatchCustomFilterExecutor(cutoff), previousBatchExecutor, true)
+				qc.transferLiveBatch(batch, batchID, size),
+				qc.withNearestNeighborsFilter(qc.liveBatchCustomFilterExecutor(cutoff), shardID, batchID),
+				previousBatchExecutor, true)
 			qc.cudaStreams[0], qc.cudaStreams[1] = qc.cudaStreams[1], qc.cudaStreams[0]
 			liveBytesTransferred += qc.OOPK.currentBatch.stats.bytesTransferred
+			atomic.AddInt64(&qc.bytesScanned, int64(qc.OOPK.currentBatch.stats.bytesTransferred))
 		}
 	}
 
 	// Process archive batches.
-	if archiveStore != nil && (qc.fromTime == nil || cutoff > uint32(qc.fromTime.Time.Unix())) {
+	if archiveStore != nil && qc.Query.ScanArchive() && (qc.fromTime == nil || cutoff > uint32(qc.fromTime.Time.Unix())) {
 		scanner := qc.TableScanners[0]
 		for batchID := scanner.ArchiveBatchIDStart; batchID < scanner.ArchiveBatchIDEnd; batchID++ {
-			if qc.OOPK.done {
+			previousBatchExecutor = qc.finishBatchForLimit(previousBatchExecutor)
+			if qc.OOPK.done || qc.checkKilled() {
 				break
 			}
 			archiveBatch := archiveStore.RequestBatch(int32(batchID))
-			if archiveBatch.Size == 0 {
+			if archiveBatch.Size == 0 || qc.shouldSkipBatchWithoutNeighbors(shardID, int32(batchID)) ||
+				qc.shouldSkipArchiveBatch(archiveBatch,
+					batchID == scanner.ArchiveBatchIDStart, batchID == scanner.ArchiveBatchIDEnd-1) {
 				qc.OOPK.ArchiveBatchStats.NumBatchSkipped++
+				qc.ScanStats.BatchesSkipped++
 				continue
 			}
 			isFirstOrLast := batchID == scanner.ArchiveBatchIDStart || batchID == scanner.ArchiveBatchIDEnd-1
@@ -233,14 +362,25 @@ func (qc *AQLQueryContext) processShard(memStore memstore.MemStore, shardID int,
 				int32(batchID),
 				archiveBatch.Size,
 				qc.transferArchiveBatch(archiveBatch, isFirstOrLast),
-				qc.archiveBatchCustomFilterExecutor(isFirstOrLast),
+				qc.withNearestNeighborsFilter(qc.archiveBatchCustomFilterExecutor(isFirstOrLast), shardID, int32(batchID)),
 				previousBatchExecutor, false)
 			archiveRecordsProcessed += archiveBatch.Size
 			archiveBatchProcessed++
 			qc.cudaStreams[0], qc.cudaStreams[1] = qc.cudaStreams[1], qc.cudaStreams[0]
 			archiveBytesTransferred += qc.OOPK.currentBatch.stats.bytesTransferred
+			atomic.AddInt64(&qc.bytesScanned, int64(qc.OOPK.currentBatch.stats.bytesTransferred))
 		}
+		qc.prefetchArchiveBatches(shard)
 	}
+
+	if qc.deferredColumns != nil {
+		// selected rows are located by batch id and row index, which only stay valid while the shard
+		// and its archive store version are held.
+		qc.runBatchExecutor(previousBatchExecutor, false)
+		previousBatchExecutor = NewDummyBatchExecutor()
+		qc.fetchDeferredColumns(shard, archiveStore)
+	}
+
 	utils.GetReporter(qc.Query.Table, shardID).GetCounter(utils.QueryLiveRecordsProcessed).Inc(int64(liveRecordsProcessed))
 	utils.GetReporter(qc.Query.Table, shardID).GetCounter(utils.QueryArchiveRecordsProcessed).Inc(int64(archiveRecordsProcessed))
 	utils.GetReporter(qc.Query.Table, shardID).GetCounter(utils.QueryLiveBatchProcessed).Inc(int64(liveBatchProcessed))
@@ -265,6 +405,8 @@ func (qc *AQLQueryContext) Release() {
 //  1. clean up the device buffer for storing results.
 //  2. clean up the cuda streams
 func (qc *AQLQueryContext) cleanUpDeviceStatus() {
+	qc.releaseCachedColumns()
+
 	// clean up foreign table memory after query
 	for _, foreignTable := range qc.OOPK.foreignTables {
 		qc.cleanUpForeignTable(foreignTable)
@@ -274,24 +416,36 @@ func (qc *AQLQueryContext) cleanUpDeviceStatus() {
 	// release geo pointers
 	if qc.OOPK.geoIntersection != nil {
 		deviceFreeAndSetNil(&qc.OOPK.geoIntersection.shapeLatLongs)
+		deviceFreeAndSetNil(&qc.OOPK.geoIntersection.cellIndex)
 	}
 
 	// Destroy streams
 	cgoutils.DestroyCudaStream(qc.cudaStreams[0], qc.Device)
 	cgoutils.DestroyCudaStream(qc.cudaStreams[1], qc.Device)
 	qc.cudaStreams = [2]unsafe.Pointer{nil, nil}
+	cgoutils.DestroyCudaEvent(qc.deviceTimer[0], qc.Device)
+	cgoutils.DestroyCudaEvent(qc.deviceTimer[1], qc.Device)
+	qc.deviceTimer = [2]unsafe.Pointer{nil, nil}
 
 	// Clean up the device result buffers.
 	qc.OOPK.currentBatch.cleanupDeviceResultBuffers()
 
 	// Clean up timezone lookup buffer.
 	deviceFreeAndSetNil(&qc.OOPK.currentBatch.timezoneLookupD)
+
+	// Clean up pinned buffer for checking dimension cardinality.
+	if qc.OOPK.dimCardinalityVectorH != nil {
+		cgoutils.HostFree(qc.OOPK.dimCardinalityVectorH)
+		qc.OOPK.dimCardinalityVectorH = nil
+		qc.OOPK.dimCardinalityVectorCapacity = 0
+	}
 }
 
 // clean up foreign table
 func (qc *AQLQueryContext) cleanUpForeignTable(table *foreignTable) {
 	if table != nil {
 		deviceFreeAndSetNil(&table.devicePrimaryKeyPtr)
+		deviceFreeAndSetNil(&table.deviceVersionIndexPtr)
 		for _, batch := range table.batches {
 			for _, column := range batch {
 				deviceFreeAndSetNil(&column.basePtr)
@@ -323,6 +477,90 @@ func getGeoShapeLatLongSlice(shapesLats, shapesLongs []float32, gs memCom.GeoSha
 	return shapesLats, shapesLongs, numPoints
 }
 
+// buildGeoCellIndex builds the index from s2 leaf cells to shapes covering them given the s2 cell
+// coverings of shapes computed at ingestion. The leaf cell id space is split into ranges at
+// boundaries of cells of all coverings. boundaries are in ascending order and masks stores
+// totalWords words for the range [boundaries[i], boundaries[i+1]) where bit j is set if shape j
+// covers the range, the range starting at the last boundary is not covered by any shape.
+// No index is built if any shape does not have a covering or the coverings have more than
+// maxGeoCellIndexEntries cells in total.
+func buildGeoCellIndex(coverings [][]uint64) (boundaries []uint64, masks []uint32) {
+	type boundary struct {
+		cellID     uint64
+		shapeIndex int
+		begin      bool
+	}
+
+	var events []boundary
+	for shapeIndex, covering := range coverings {
+		if len(covering) == 0 || len(events)/2+len(covering) > maxGeoCellIndexEntries {
+			return nil, nil
+		}
+		for _, cellID := range covering {
+			begin, end := memCom.GeoCellRange(cellID)
+			events = append(events, boundary{begin, shapeIndex, true}, boundary{end, shapeIndex, false})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].cellID < events[j].cellID
+	})
+
+	// number of cells of each shape covering the current range, cells of the same covering
+	// do not overlap but they may be adjacent.
+	totalWords := (len(coverings) + 31) / 32
+	shapeCounts := make([]int, len(coverings))
+	mask := make([]uint32, totalWords)
+	for i := 0; i < len(events); {
+		cellID := events[i].cellID
+		for ; i < len(events) && events[i].cellID == cellID; i++ {
+			shapeIndex := events[i].shapeIndex
+			if events[i].begin {
+				shapeCounts[shapeIndex]++
+			} else {
+				shapeCounts[shapeIndex]--
+			}
+			if shapeCounts[shapeIndex] > 0 {
+				mask[shapeIndex/32] |= 1 << uint(shapeIndex%32)
+			} else {
+				mask[shapeIndex/32] &^= 1 << uint(shapeIndex%32)
+			}
+		}
+
+		// merge with the previous range if covered by the same shapes.
+		if numRanges := len(boundaries); numRanges > 0 && uint32sEqual(masks[(numRanges-1)*totalWords:], mask) {
+			continue
+		}
+		boundaries = append(boundaries, cellID)
+		masks = append(masks, mask...)
+	}
+	return
+}
+
+func uint32sEqual(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// hasDuplicateValues tells whether any value appears more than once in values.
+func hasDuplicateValues(values []string) bool {
+	seen := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		if _, exists := seen[value]; exists {
+			return true
+		}
+		seen[value] = struct{}{}
+	}
+	return false
+}
+
 func (qc *AQLQueryContext) prepareForGeoIntersect(memStore memstore.MemStore) (shapeExists bool) {
 	tableScanner := qc.TableScanners[qc.OOPK.geoIntersection.shapeTableID]
 	shapeColumnID := qc.OOPK.geoIntersection.shapeColumnID
@@ -335,33 +573,66 @@ func (qc *AQLQueryContext) prepareForGeoIntersect(memStore memstore.MemStore) (s
 	}
 	defer shard.Users.Done()
 
-	numPointsPerShape := make([]int32, 0, len(qc.OOPK.geoIntersection.shapeUUIDs))
-	qc.OOPK.geoIntersection.validShapeUUIDs = make([]string, 0, len(qc.OOPK.geoIntersection.shapeUUIDs))
-	var shapesLats, shapesLongs []float32
-	var numPoints, totalNumPoints int
+	pkColumnID := tableScanner.Schema.Schema.PrimaryKeyColumns[0]
+	useShapeUUIDAsDim := qc.OOPK.geoIntersection.dimIndex < 0 || qc.OOPK.geoIntersection.dimColumnID == pkColumnID
+	dimDataType := tableScanner.Schema.ValueTypeByColumn[qc.OOPK.geoIntersection.dimColumnID]
+
+	// every shape is intersected on its own so that polygons of different shapes sharing the same
+	// dimension value are not mixed up as holes of each other, shape indexes are mapped to dimension
+	// values afterwards and results of the same dimension value are reduced when flushed.
+	var shapes []memCom.GeoShapeGo
+	var dimValues []string
 	for _, uuid := range qc.OOPK.geoIntersection.shapeUUIDs {
 		recordID, found := shard.LiveStore.LookupKey([]string{uuid})
 		if found {
 			batch := shard.LiveStore.GetBatchForRead(recordID.BatchID)
 			if batch != nil {
 				shapeValue := batch.GetDataValue(int(recordID.Index), shapeColumnID)
+				dimValue := uuid
+				if !useShapeUUIDAsDim {
+					dimValue = qc.readGeoDimValue(batch.GetDataValue(int(recordID.Index), qc.OOPK.geoIntersection.dimColumnID), dimDataType)
+				}
 				// compiler should have verified the geo column GeoShape type
-				shapesLats, shapesLongs, numPoints = getGeoShapeLatLongSlice(shapesLats, shapesLongs, *(shapeValue.GoVal.(*memCom.GeoShapeGo)))
-				if numPoints > 0 {
-					totalNumPoints += numPoints
-					numPointsPerShape = append(numPointsPerShape, int32(numPoints))
-					qc.OOPK.geoIntersection.validShapeUUIDs = append(qc.OOPK.geoIntersection.validShapeUUIDs, uuid)
-					shapeExists = true
+				if shape, ok := shapeValue.GoVal.(*memCom.GeoShapeGo); ok {
+					shapes = append(shapes, *shape)
+					dimValues = append(dimValues, dimValue)
 				}
 				batch.RUnlock()
 			}
 		}
 	}
 
+	numPointsPerShape := make([]int32, 0, len(shapes))
+	qc.OOPK.geoIntersection.validShapeUUIDs = make([]string, 0, len(shapes))
+	var shapesLats, shapesLongs []float32
+	var coverings [][]uint64
+	var numPoints, totalNumPoints int
+	for i, shape := range shapes {
+		shapesLats, shapesLongs, numPoints = getGeoShapeLatLongSlice(shapesLats, shapesLongs, shape)
+		if numPoints > 0 {
+			coverings = append(coverings, shape.Covering)
+			totalNumPoints += numPoints
+			numPointsPerShape = append(numPointsPerShape, int32(numPoints))
+			qc.OOPK.geoIntersection.validShapeUUIDs = append(qc.OOPK.geoIntersection.validShapeUUIDs, dimValues[i])
+			shapeExists = true
+		}
+	}
+
 	if !shapeExists {
 		return
 	}
 
+	// results of shapes sharing the same dimension value are reduced into one row. Dimension values
+	// are not translated for DataOnly requests so broker does the reduction instead.
+	if qc.OOPK.geoIntersection.dimIndex >= 0 && !qc.IsNonAggregationQuery && !qc.DataOnly &&
+		qc.reduceMeasure == nil && hasDuplicateValues(qc.OOPK.geoIntersection.validShapeUUIDs) {
+		qc.reduceMeasure = getMeasureReducer(qc.OOPK.AggregateType)
+		if qc.reduceMeasure == nil {
+			qc.Error = utils.StackError(nil, "geo dimension with duplicate values does not support measure %s", qc.Query.Measures[0].Expr)
+			return
+		}
+	}
+
 	numValidShapes := len(numPointsPerShape)
 	shapeIndexs := make([]uint8, totalNumPoints)
 	pointIndex := 0
@@ -384,9 +655,42 @@ func (qc *AQLQueryContext) prepareForGeoIntersect(memStore memstore.MemStore) (s
 	qc.OOPK.geoIntersection.shapeLatLongs = latsPtrD
 	qc.OOPK.geoIntersection.numShapes = numValidShapes
 	qc.OOPK.geoIntersection.totalNumPoints = totalNumPoints
+
+	// range boundaries followed by shape masks of each range
+	cellBoundaries, cellMasks := buildGeoCellIndex(coverings)
+	if len(cellBoundaries) > 0 {
+		cellIndexD := deviceAllocate(len(cellBoundaries)*8+len(cellMasks)*4, qc.Device)
+		cgoutils.AsyncCopyHostToDevice(cellIndexD.getPointer(), unsafe.Pointer(&cellBoundaries[0]), len(cellBoundaries)*8, qc.cudaStreams[0], qc.Device)
+		cgoutils.AsyncCopyHostToDevice(cellIndexD.offset(len(cellBoundaries)*8).getPointer(), unsafe.Pointer(&cellMasks[0]), len(cellMasks)*4, qc.cudaStreams[0], qc.Device)
+		qc.OOPK.geoIntersection.cellIndex = cellIndexD
+		qc.OOPK.geoIntersection.numCells = len(cellBoundaries)
+	}
 	return
 }
 
+// readGeoDimValue translates the value of the geo dimension column into the dimension
+// value string of the shape.
+func (qc *AQLQueryContext) readGeoDimValue(value memCom.DataValue, dataType memCom.DataType) string {
+	if !value.Valid {
+		return queryCom.NULLString
+	}
+
+	geoDimExpr := qc.OOPK.Dimensions[qc.OOPK.geoIntersection.dimIndex].(*expr.VarRef)
+	if geoDimExpr.EnumReverseDict != nil {
+		var id int
+		switch v := value.ConvertToHumanReadable(dataType).(type) {
+		case uint8:
+			id = int(v)
+		case uint16:
+			id = int(v)
+		}
+		if id < len(geoDimExpr.EnumReverseDict) {
+			return geoDimExpr.EnumReverseDict[id]
+		}
+	}
+	return fmt.Sprint(value.ConvertToHumanReadable(dataType))
+}
+
 // prepare foreign table (allocate and transfer memory) before processing
 func (qc *AQLQueryContext) prepareForeignTable(memStore memstore.MemStore, joinTableID int, join queryCom.Join) {
 	ft := qc.OOPK.foreignTables[joinTableID]
@@ -408,14 +712,22 @@ func (qc *AQLQueryContext) prepareForeignTable(memStore memstore.MemStore, joinT
 	ft.numRecordsInLastBatch = numRecordsInLastBatch
 	deviceBatches := make([][]deviceVectorPartySlice, len(batchIDs))
 
-	// transfer primary key
-	hostPrimaryKeyData := shard.LiveStore.PrimaryKey.LockForTransfer()
-	devicePrimaryKeyPtr := deviceAllocate(hostPrimaryKeyData.NumBytes, qc.Device)
-	cgoutils.AsyncCopyHostToDevice(devicePrimaryKeyPtr.getPointer(), hostPrimaryKeyData.Data, hostPrimaryKeyData.NumBytes, qc.cudaStreams[0], qc.Device)
-	cgoutils.WaitForCudaStream(qc.cudaStreams[0], qc.Device)
-	ft.hostPrimaryKeyData = hostPrimaryKeyData
-	ft.devicePrimaryKeyPtr = devicePrimaryKeyPtr
-	shard.LiveStore.PrimaryKey.UnlockAfterTransfer()
+	if ft.asOfJoin {
+		// transfer version index and primary key excluding validFrom column
+		qc.prepareVersionIndex(shard, ft, batchIDs, numRecordsInLastBatch)
+		if qc.Error != nil {
+			return
+		}
+	} else {
+		// transfer primary key
+		hostPrimaryKeyData := shard.LiveStore.PrimaryKey.LockForTransfer()
+		devicePrimaryKeyPtr := deviceAllocate(hostPrimaryKeyData.NumBytes, qc.Device)
+		cgoutils.AsyncCopyHostToDevice(devicePrimaryKeyPtr.getPointer(), hostPrimaryKeyData.Data, hostPrimaryKeyData.NumBytes, qc.cudaStreams[0], qc.Device)
+		cgoutils.WaitForCudaStream(qc.cudaStreams[0], qc.Device)
+		ft.hostPrimaryKeyData = hostPrimaryKeyData
+		ft.devicePrimaryKeyPtr = devicePrimaryKeyPtr
+		shard.LiveStore.PrimaryKey.UnlockAfterTransfer()
+	}
 
 	// allocate device memory
 	for i, batchID := range batchIDs {
@@ -503,7 +815,7 @@ func (qc *AQLQueryContext) prepareTimezoneTable(store memstore.MemStore) {
 // transferLiveBatch returns a functor to transfer a live batch to device memory. The size parameter will be either the
 // size of the batch or num records in last batch. hostColumns will always be empty since we should not release a vector
 // party of a live batch. Start row will always be zero as well.
-func (qc *AQLQueryContext) transferLiveBatch(batch *memstore.LiveBatch, size int) batchTransferExecutor {
+func (qc *AQLQueryContext) transferLiveBatch(batch *memstore.LiveBatch, batchID int32, size int) batchTransferExecutor {
 	return func(stream unsafe.Pointer) (deviceColumns []deviceVectorPartySlice, hostVPs []memCom.VectorParty,
 		firstColumn, startRow, totalBytes, numTransfers, sizeAfterPrefilter int) {
 		// Allocate column inputs.
@@ -515,7 +827,19 @@ func (qc *AQLQueryContext) transferLiveBatch(batch *memstore.LiveBatch, size int
 				if firstColumn < 0 {
 					firstColumn = i
 				}
-				sourceVP := batch.Columns[columnID]
+				if columnID == memCom.RowIndexColumnID {
+					deviceColumns[i] = rowIndexDeviceColumn(0, size, stream, qc.Device)
+					continue
+				}
+				var sourceVP memCom.VectorParty
+				if memCom.IsSystemColumn(columnID) {
+					if sourceVP = batch.GetSystemVectorParty(columnID); sourceVP == nil {
+						deviceColumns[i] = constantDeviceColumn(columnID, batch.GetSystemColumnValue(batchID, columnID), size)
+						continue
+					}
+				} else {
+					sourceVP = batch.Columns[columnID]
+				}
 				if sourceVP == nil {
 					continue
 				}
@@ -575,14 +899,46 @@ func (qc *AQLQueryContext) transferArchiveBatch(batch *memstore.ArchiveBatch,
 		hostVPs = make([]memCom.VectorParty, len(qc.TableScanners[0].Columns))
 		hostSlices := make([]memCom.HostVectorPartySlice, len(qc.TableScanners[0].Columns))
 		deviceSlices = make([]deviceVectorPartySlice, len(qc.TableScanners[0].Columns))
+		cached := make([]bool, len(qc.TableScanners[0].Columns))
 		endRow := batch.Size
 		prefilterIndex := 0
+		// rows of the batch are iterated by the first matched column, other columns kept as runs
+		// are transferred and scanned as runs.
+		baseColumn := -1
+		for i, columnID := range qc.TableScanners[0].Columns {
+			if qc.TableScanners[0].ColumnUsages[columnID]&matchedColumnUsages != 0 {
+				baseColumn = i
+				break
+			}
+		}
 		// Must iterate in reverse order to apply prefilter slicing properly.
 		for i := len(qc.TableScanners[0].Columns) - 1; i >= 0; i-- {
 			columnID := qc.TableScanners[0].Columns[i]
 			usage := qc.TableScanners[0].ColumnUsages[columnID]
 
+			if usage&matchedColumnUsages != 0 && memCom.IsSystemColumn(columnID) {
+				// system columns of archive batches have the same value for all rows.
+				prefilterIndex++
+				firstColumn = i
+				if columnID == memCom.RowIndexColumnID {
+					deviceSlices[i] = rowIndexDeviceColumn(startRow, endRow-startRow, stream, qc.Device)
+				} else {
+					deviceSlices[i] = constantDeviceColumn(columnID, batch.GetSystemColumnValue(columnID), endRow-startRow)
+				}
+				continue
+			}
+
 			if usage&matchedColumnUsages != 0 || usage&columnUsedByPrefilter != 0 {
+				// columns not used by prefilter are only sliced by row range, so cached columns
+				// can be used without loading them from disk.
+				if usage&columnUsedByPrefilter == 0 {
+					if deviceSlices[i], cached[i] = qc.getCachedColumn(batch, columnID, startRow, endRow); cached[i] {
+						prefilterIndex++
+						firstColumn = i
+						continue
+					}
+				}
+
 				// Request/pin column from disk and wait.
 				vp := batch.RequestVectorParty(columnID)
 				vp.WaitForDiskLoad()
@@ -592,9 +948,25 @@ func (qc *AQLQueryContext) transferArchiveBatch(batch *memstore.ArchiveBatch,
 				prefilterIndex++
 
 				if usage&matchedColumnUsages != 0 {
-					hostVPs[i] = vp
 					firstColumn = i
+					if deviceSlices[i], cached[i] = qc.getCachedColumn(batch, columnID, startRow, endRow); cached[i] {
+						vp.Release()
+						continue
+					}
+					hostVPs[i] = vp
+					var runs bool
+					if runsVP, ok := vp.(memstore.RunsTransferableVectorParty); ok && i != baseColumn &&
+						usage&columnUsedByPrefilter == 0 {
+						var runsSlice memCom.HostVectorPartySlice
+						if runsSlice, runs = runsVP.GetRunsHostVectorPartySlice(startRow, endRow); runs {
+							hostSlices[i] = runsSlice
+						}
+					}
 					deviceSlices[i] = hostToDeviceColumn(hostSlices[i], qc.Device)
+					// only row slices are cached so that cached columns can iterate rows.
+					if !runs {
+						qc.addPendingColumn(batch, columnID, startRow, endRow, i, hostSlices[i])
+					}
 				} else {
 					vp.Release()
 				}
@@ -604,7 +976,7 @@ func (qc *AQLQueryContext) transferArchiveBatch(batch *memstore.ArchiveBatch,
 		for i, dstVPSlice := range deviceSlices {
 			columnID := qc.TableScanners[0].Columns[i]
 			usage := qc.TableScanners[0].ColumnUsages[columnID]
-			if usage&matchedColumnUsages != 0 {
+			if usage&matchedColumnUsages != 0 && !cached[i] && !memCom.IsSystemColumn(columnID) {
 				srcVPSlice := hostSlices[i]
 				b, t := copyHostToDevice(srcVPSlice, dstVPSlice, stream, qc.Device)
 				totalBytes += b
@@ -865,6 +1237,8 @@ func (qc *AQLQueryContext) processBatch(
 
 	// Wait for data transfer of the current batch.
 	cgoutils.WaitForCudaStream(stream, qc.Device)
+	// columns can only be shared with other queries after transfer completes.
+	qc.admitPendingColumns(deviceSlices)
 
 	for _, vp := range hostVPs {
 		if vp != nil {
@@ -880,6 +1254,8 @@ func (qc *AQLQueryContext) processBatch(
 
 	// Wait for execution of the previous batch.
 	res := <-executionDone
+	// cached columns of the previous batch are no longer used.
+	qc.releasePreviousBatchColumns()
 	if res.error != nil {
 		// column data transfer for current batch is done
 		// need release current batch's column data before panic
@@ -994,7 +1370,7 @@ func (qc *AQLQueryContext) calculateMemoryRequirement(memStore memstore.MemStore
 		}
 
 		// estimate live batch memory usage
-		if qc.toTime == nil || cutoff < uint32(qc.toTime.Time.Unix()) {
+		if qc.Query.ScanLive() && (qc.toTime == nil || cutoff < uint32(qc.toTime.Time.Unix())) {
 			batchIDs, _ := shard.LiveStore.GetBatchIDs()
 
 			// find first non null batch and estimate.
@@ -1014,7 +1390,7 @@ func (qc *AQLQueryContext) calculateMemoryRequirement(memStore memstore.MemStore
 
 		// estimate archive batch memory usage
 		if archiveStore != nil {
-			if qc.fromTime == nil || cutoff > uint32(qc.fromTime.Time.Unix()) {
+			if qc.Query.ScanArchive() && (qc.fromTime == nil || cutoff > uint32(qc.fromTime.Time.Unix())) {
 				scanner := qc.TableScanners[0]
 				for batchID := scanner.ArchiveBatchIDStart; batchID < scanner.ArchiveBatchIDEnd; batchID++ {
 					archiveBatch := archiveStore.RequestBatch(int32(batchID))
@@ -1041,7 +1417,16 @@ func (qc *AQLQueryContext) calculateMemoryRequirement(memStore memstore.MemStore
 func (qc *AQLQueryContext) estimateLiveBatchMemoryUsage(batch *memstore.LiveBatch) int {
 	columnMemUsage := 0
 	for _, columnID := range qc.TableScanners[0].Columns {
-		sourceVP := batch.Columns[columnID]
+		if columnID == memCom.RowIndexColumnID {
+			columnMemUsage += batch.Capacity * 4
+			continue
+		}
+		var sourceVP memCom.VectorParty
+		if memCom.IsSystemColumn(columnID) {
+			sourceVP = batch.GetSystemVectorParty(columnID)
+		} else {
+			sourceVP = batch.Columns[columnID]
+		}
 		if sourceVP == nil {
 			continue
 		}
@@ -1083,6 +1468,15 @@ func (qc *AQLQueryContext) estimateArchiveBatchMemoryUsage(batch *memstore.Archi
 	for i := len(qc.TableScanners[0].Columns) - 1; i >= 0; i-- {
 		columnID := qc.TableScanners[0].Columns[i]
 		usage := qc.TableScanners[0].ColumnUsages[columnID]
+		// system columns of archive batches take no device memory except row indexes.
+		if memCom.IsSystemColumn(columnID) {
+			if columnID == memCom.RowIndexColumnID {
+				columnMemUsage += (endRow - startRow) * 4
+			}
+			prefilterIndex++
+			firstColumnSize = endRow - startRow
+			continue
+		}
 		// TODO(cdavid): only read metadata when estimate query memory requirement.
 		sourceVP := batch.RequestVectorParty(columnID)
 		sourceVP.WaitForDiskLoad()
@@ -1307,12 +1701,39 @@ func (qc *AQLQueryContext) FindDeviceForQuery(memStore memstore.MemStore, prefer
 	qc.OOPK.DeviceMemoryRequirement = memoryRequired
 
 	waitStart := utils.Now()
-	device := deviceManager.FindDevice(qc.Query, memoryRequired, preferredDevice, timeout)
+	device := deviceManager.FindDevice(qc.Query, qc.queryFlow(), memoryRequired, preferredDevice, timeout)
 	if device == -1 {
 		qc.Error = utils.StackError(nil, "Unable to find device to run this query")
 	}
 	qc.OOPK.DurationWaitedForDevice = utils.Now().Sub(waitStart)
 	qc.Device = device
+	qc.deviceManager = deviceManager
+}
+
+// queryFlow returns the flow of the query for fair scheduling, weighted by the main table config.
+func (qc *AQLQueryContext) queryFlow() QueryFlow {
+	flow := QueryFlow{
+		Table:  qc.Query.Table,
+		Origin: qc.Origin,
+	}
+	if len(qc.TableScanners) > 0 && qc.TableScanners[0].Schema != nil {
+		schema := qc.TableScanners[0].Schema
+		schema.RLock()
+		flow.Weight = schema.Schema.Config.QueryWeight
+		schema.RUnlock()
+	}
+	return flow
+}
+
+// finishBatchForLimit executes the pending batch of non-aggregation queries with limit
+// before the next batch is transferred, so that scanning stops as soon as enough rows
+// are collected instead of transferring one more batch down the pipeline.
+func (qc *AQLQueryContext) finishBatchForLimit(previousBatchExecutor BatchExecutor) BatchExecutor {
+	if !qc.IsNonAggregationQuery || qc.Query.Limit < 0 || qc.Debug {
+		return previousBatchExecutor
+	}
+	qc.runBatchExecutor(previousBatchExecutor, false)
+	return NewDummyBatchExecutor()
 }
 
 func (qc *AQLQueryContext) runBatchExecutor(e BatchExecutor, isLastBatch bool) {
@@ -1374,6 +1795,32 @@ func copyHostToDevice(vps memCom.HostVectorPartySlice, deviceVPSlice deviceVecto
 	return
 }
 
+// constantDeviceColumn returns the device column of a system column with the same value for
+// all rows, which takes no device memory.
+func constantDeviceColumn(columnID int, value memCom.DataValue, length int) deviceVectorPartySlice {
+	return deviceVectorPartySlice{
+		length:       length,
+		valueType:    memCom.GetSystemColumnType(columnID),
+		defaultValue: value,
+	}
+}
+
+// rowIndexDeviceColumn returns the device column of the row index system column with values
+// generated on device from startRow.
+func rowIndexDeviceColumn(startRow, length int, stream unsafe.Pointer, device int) deviceVectorPartySlice {
+	deviceColumn := deviceVectorPartySlice{
+		length:       length,
+		valueType:    memCom.GetSystemColumnType(memCom.RowIndexColumnID),
+		defaultValue: memCom.NullDataValue,
+	}
+	if length > 0 {
+		deviceColumn.basePtr = deviceAllocate(length*4, device)
+		deviceColumn.values = deviceColumn.basePtr
+		initIndexVector(deviceColumn.values.getPointer(), startRow, length, stream, device)
+	}
+	return deviceColumn
+}
+
 func hostToDeviceColumn(hostColumn memCom.HostVectorPartySlice, device int) deviceVectorPartySlice {
 	if memCom.IsArrayType(hostColumn.ValueType) {
 		deviceColumn := deviceVectorPartySlice{
@@ -1442,76 +1889,239 @@ func (qc *AQLQueryContext) shouldSkipLiveBatch(b *memstore.LiveBatch) bool {
 //  5. Another side of the xpr must be NumericalLiteral
 //  6. ColumnType must be UInt32
 func shouldSkipLiveBatchWithFilter(b *memstore.LiveBatch, filter expr.Expr) bool {
-	if filter == nil {
+	columnExpr, op, numExpr, ok := getColumnComparison(filter)
+	if !ok {
+		return false
+	}
+
+	// Time filters and main table filters are guaranteed to be on main table.
+	vp := b.Columns[columnExpr.ColumnID]
+	if vp == nil {
+		return true
+	}
+
+	if columnExpr.DataType != memCom.Uint32 {
+		return false
+	}
+
+	minUint32, maxUint32 := vp.(memCom.LiveVectorParty).GetMinMaxValue()
+	return !rangeMayMatch(op, int64(minUint32), int64(maxUint32), int64(numExpr.Int))
+}
+
+// getColumnComparison returns the column, the operator and the number if the filter compares a
+// column with a number, the operator is inverted if the number is on the left side.
+func getColumnComparison(filter expr.Expr) (columnExpr *expr.VarRef, op expr.Token, numExpr *expr.NumberLiteral, ok bool) {
+	binExpr, isBinary := filter.(*expr.BinaryExpr)
+	if !isBinary {
+		return
+	}
+	op = binExpr.Op
+	switch op {
+	case expr.GTE, expr.GT, expr.LT, expr.LTE, expr.EQ:
+	default:
+		return
+	}
+
+	// First try lhs VarRef, rhs Num.
+	lhsVarRef, lhsOK := binExpr.LHS.(*expr.VarRef)
+	rhsNum, rhsOK := binExpr.RHS.(*expr.NumberLiteral)
+	if lhsOK && rhsOK {
+		return lhsVarRef, op, rhsNum, true
+	}
+
+	// Then try rhs VarRef, lhs Num.
+	lhsNum, lhsOK := binExpr.LHS.(*expr.NumberLiteral)
+	rhsVarRef, rhsOK := binExpr.RHS.(*expr.VarRef)
+	if !lhsOK || !rhsOK {
+		return
+	}
+	// Swap column to the left and number to right.
+	// Invert the OP.
+	switch op {
+	case expr.GTE:
+		op = expr.LTE
+	case expr.GT:
+		op = expr.LT
+	case expr.LTE:
+		op = expr.GTE
+	case expr.LT:
+		op = expr.GT
+	}
+	return rhsVarRef, op, lhsNum, true
+}
+
+// rangeMayMatch returns whether any value within [min, max] satisfies the comparison with num.
+func rangeMayMatch(op expr.Token, min, max, num int64) bool {
+	switch op {
+	case expr.GTE:
+		return max >= num
+	case expr.GT:
+		return max > num
+	case expr.LTE:
+		return min <= num
+	case expr.LT:
+		return min < num
+	case expr.EQ:
+		return min <= num && max >= num
+	}
+	return true
+}
+
+// floatRangeMayMatch returns whether any value within [min, max] satisfies the comparison with num.
+func floatRangeMayMatch(op expr.Token, min, max, num float64) bool {
+	switch op {
+	case expr.GTE:
+		return max >= num
+	case expr.GT:
+		return max > num
+	case expr.LTE:
+		return min <= num
+	case expr.LT:
+		return min < num
+	case expr.EQ:
+		return min <= num && max >= num
+	}
+	return true
+}
+
+// shouldSkipArchiveBatchWithZoneMap checks the comparison filter of a numeric main table column
+// against the zone map of the column in the archive batch.
+func shouldSkipArchiveBatchWithZoneMap(b *memstore.ArchiveBatch, filter expr.Expr) bool {
+	filter, inSeconds := unwrapMillisToSeconds(filter)
+	columnExpr, op, numExpr, ok := getColumnComparison(filter)
+	if !ok || columnExpr.TableID != 0 || !memCom.IsZoneMapType(columnExpr.DataType) {
 		return false
 	}
 
-	if binExpr, ok := filter.(*expr.BinaryExpr); ok {
-		var columnExpr *expr.VarRef
-		var numExpr *expr.NumberLiteral
-		op := binExpr.Op
-		switch op {
-		case expr.GTE, expr.GT, expr.LT, expr.LTE, expr.EQ:
-		default:
+	zoneMap := b.RequestZoneMap(columnExpr.ColumnID)
+	if zoneMap == nil {
+		return false
+	}
+	// null values never match comparisons.
+	if !zoneMap.HasValues {
+		return true
+	}
+
+	if columnExpr.DataType == memCom.Float32 {
+		num := float64(numExpr.Int)
+		if numExpr.ExprType == expr.Float {
+			num = numExpr.Val
+		}
+		return !floatRangeMayMatch(op, zoneMap.MinFloat, zoneMap.MaxFloat, num)
+	}
+	// integer columns compared with float numbers are cast to float on device.
+	if numExpr.ExprType == expr.Float {
+		return false
+	}
+	min, max := zoneMap.MinInt, zoneMap.MaxInt
+	if inSeconds {
+		min, max = min/1000, max/1000
+		// seconds out of uint32 range wrap around when converted on device.
+		if min < 0 || max > math.MaxUint32 {
 			return false
 		}
-		// First try lhs VarRef, rhs Num.
-		lhsVarRef, lhsOK := binExpr.LHS.(*expr.VarRef)
-		rhsNum, rhsOK := binExpr.RHS.(*expr.NumberLiteral)
-		if lhsOK && rhsOK {
-			columnExpr = lhsVarRef
-			numExpr = rhsNum
-		} else {
-			// Then try rhs VarRef, lhs Num.
-			lhsNum, lhsOK := binExpr.LHS.(*expr.NumberLiteral)
-			rhsVarRef, rhsOK := binExpr.RHS.(*expr.VarRef)
-			if lhsOK && rhsOK {
-				// Swap column to the left and number to right.
-				columnExpr = rhsVarRef
-				numExpr = lhsNum
-				// Invert the OP.
-				switch op {
-				case expr.GTE:
-					op = expr.LTE
-				case expr.GT:
-					op = expr.LT
-				case expr.LTE:
-					op = expr.GTE
-				case expr.LT:
-					op = expr.GT
-				}
+	}
+	return !rangeMayMatch(op, min, max, int64(numExpr.Int))
+}
+
+// unwrapMillisToSeconds replaces Time64 columns converted to seconds in the comparison filter
+// with the columns themselves, and returns whether the filter compares seconds. Time filters
+// and date functions on Time64 columns are compiled into such conversions.
+func unwrapMillisToSeconds(filter expr.Expr) (expr.Expr, bool) {
+	binExpr, ok := filter.(*expr.BinaryExpr)
+	if !ok {
+		return filter, false
+	}
+	unwrap := func(e expr.Expr) (expr.Expr, bool) {
+		if unaryExpr, ok := e.(*expr.UnaryExpr); ok && unaryExpr.Op == expr.MILLIS_TO_SECONDS {
+			if varRef, ok := unaryExpr.Expr.(*expr.VarRef); ok {
+				return varRef, true
 			}
 		}
+		return e, false
+	}
+	lhs, lhsOK := unwrap(binExpr.LHS)
+	rhs, rhsOK := unwrap(binExpr.RHS)
+	if !lhsOK && !rhsOK {
+		return filter, false
+	}
+	return &expr.BinaryExpr{Op: binExpr.Op, LHS: lhs, RHS: rhs, ExprType: binExpr.ExprType}, true
+}
 
-		if columnExpr != nil && numExpr != nil {
-			// Time filters and main table filters are guaranteed to be on main table.
-			vp := b.Columns[columnExpr.ColumnID]
-			if vp == nil {
-				return true
-			}
+// shouldSkipArchiveBatch checks filters against zone maps and bitmap indexes of indexed enum columns
+// of the archive batch and determines whether no rows of the batch can match all of them, so that
+// the batch can be skipped before transferring it to the device. Lower and upper bound time filters
+// only apply to the first and last batch respectively.
+func (qc *AQLQueryContext) shouldSkipArchiveBatch(b *memstore.ArchiveBatch, isFirst, isLast bool) bool {
+	candidatesFilters := qc.OOPK.MainTableCommonFilters
+	if isFirst && qc.OOPK.TimeFilters[0] != nil {
+		candidatesFilters = append([]expr.Expr{qc.OOPK.TimeFilters[0]}, candidatesFilters...)
+	}
+	if isLast && qc.OOPK.TimeFilters[1] != nil {
+		candidatesFilters = append([]expr.Expr{qc.OOPK.TimeFilters[1]}, candidatesFilters...)
+	}
+	for _, filter := range candidatesFilters {
+		if shouldSkipArchiveBatchWithZoneMap(b, filter) {
+			return true
+		}
+	}
 
-			if columnExpr.DataType != memCom.Uint32 {
-				return false
-			}
+	var matchedRows *utils.Bitset
+	for _, filter := range qc.OOPK.MainTableCommonFilters {
+		columnID, values, ok := getEnumEqualityFilterValues(filter)
+		if !ok {
+			continue
+		}
+		index := b.RequestEnumIndex(columnID)
+		if index == nil {
+			continue
+		}
+		rows := index.Rows(values...)
+		if matchedRows == nil {
+			matchedRows = rows
+		} else {
+			matchedRows = matchedRows.And(rows)
+		}
+		if matchedRows.IsEmpty() {
+			return true
+		}
+	}
+	return false
+}
 
-			num := int64(numExpr.Int)
-			minUint32, maxUint32 := vp.(memCom.LiveVectorParty).GetMinMaxValue()
-			min, max := int64(minUint32), int64(maxUint32)
-			switch op {
-			case expr.GTE:
-				return max < num
-			case expr.GT:
-				return max <= num
-			case expr.LTE:
-				return min > num
-			case expr.LT:
-				return min >= num
-			case expr.EQ:
-				return min > num || max < num
+// getEnumEqualityFilterValues returns the enum column and the enum values matched by the filter
+// if the filter is an equality check of an enum column or a disjunction of equality checks on
+// the same enum column, which is how IN filters are compiled.
+func getEnumEqualityFilterValues(filter expr.Expr) (columnID int, values []uint32, ok bool) {
+	switch e := filter.(type) {
+	case *expr.ParenExpr:
+		return getEnumEqualityFilterValues(e.Expr)
+	case *expr.BinaryExpr:
+		switch e.Op {
+		case expr.OR:
+			lhsColumnID, lhsValues, lhsOK := getEnumEqualityFilterValues(e.LHS)
+			rhsColumnID, rhsValues, rhsOK := getEnumEqualityFilterValues(e.RHS)
+			if lhsOK && rhsOK && lhsColumnID == rhsColumnID {
+				return lhsColumnID, append(lhsValues, rhsValues...), true
+			}
+		case expr.EQ:
+			column, isColumn := e.LHS.(*expr.VarRef)
+			number, isNumber := e.RHS.(*expr.NumberLiteral)
+			if !isColumn || !isNumber {
+				column, isColumn = e.RHS.(*expr.VarRef)
+				number, isNumber = e.LHS.(*expr.NumberLiteral)
+			}
+			if isColumn && isNumber && column.TableID == 0 &&
+				(column.DataType == memCom.SmallEnum || column.DataType == memCom.BigEnum) {
+				// unknown enum values are translated to -1 which matches no rows.
+				if number.Int >= 0 {
+					values = []uint32{uint32(number.Int)}
+				}
+				return column.ColumnID, values, true
 			}
 		}
 	}
-	return false
+	return
 }
 
 func (qc *AQLQueryContext) initializeNonAggResponse() {
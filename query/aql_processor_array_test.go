@@ -17,6 +17,7 @@ import (
 	metaMocks "github.com/uber/aresdb/metastore/mocks"
 	queryCom "github.com/uber/aresdb/query/common"
 	"github.com/uber/aresdb/redolog"
+	"os"
 
 	"encoding/json"
 	"sync"
@@ -62,6 +63,8 @@ var _ = ginkgo.Describe("aql_processor for array", func() {
 		diskStore = new(diskMocks.DiskStore)
 		diskStore.(*diskMocks.DiskStore).On(
 			"OpenVectorPartyFileForRead", table, mock.Anything, shardID, mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+		diskStore.(*diskMocks.DiskStore).On(
+			"OpenBatchZoneMapFileForRead", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, os.ErrNotExist)
 
 		redologManagerMaster, _ = redolog.NewRedoLogManagerMaster("", &common.RedoLogConfig{}, diskStore, metaStore)
 		bootstrapToken := new(memComMocks.BootStrapToken)
@@ -15,9 +15,11 @@
 package query
 
 import (
+	"bytes"
 	"github.com/uber/aresdb/cgoutils"
 	"github.com/uber/aresdb/cluster/topology"
 	"io/ioutil"
+	"os"
 	"unsafe"
 
 	"encoding/binary"
@@ -125,6 +127,8 @@ var _ = ginkgo.Describe("aql_processor", func() {
 		diskStore = new(diskMocks.DiskStore)
 		diskStore.(*diskMocks.DiskStore).On(
 			"OpenVectorPartyFileForRead", table, mock.Anything, shardID, mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+		diskStore.(*diskMocks.DiskStore).On(
+			"OpenBatchZoneMapFileForRead", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, os.ErrNotExist)
 
 		redologManagerMaster, _ = redolog.NewRedoLogManagerMaster("", &common.RedoLogConfig{}, diskStore, metaStore)
 		bootstrapToken := new(memComMocks.BootStrapToken)
@@ -2370,4 +2374,202 @@ var _ = ginkgo.Describe("aql_processor", func() {
 		qc.initializeNonAggResponse()
 		Ω(w.Body.String()).Should(Equal(``))
 	})
+	ginkgo.It("buildGeoCellIndex should work", func() {
+		face0, face1 := uint64(1)<<60, uint64(3)<<60
+		leafCellID := memCom.GeoCellID(0, 0)
+		// shape 0 covers face 0 and face 1, shape 1 covers face 1 and the leaf cell of (0, 0) in face 0.
+		boundaries, masks := buildGeoCellIndex([][]uint64{{face0, face1}, {leafCellID, face1}})
+		Ω(boundaries).Should(Equal([]uint64{1, leafCellID, leafCellID + 1, 1 << 61, 1<<61 + 1, 1 << 62}))
+		Ω(masks).Should(Equal([]uint32{1, 3, 1, 0, 3, 0}))
+
+		// ranges covered by the same shapes are merged.
+		boundaries, masks = buildGeoCellIndex([][]uint64{{face0}, {face0, face1}})
+		Ω(boundaries).Should(Equal([]uint64{1, 1 << 61, 1<<61 + 1, 1 << 62}))
+		Ω(masks).Should(Equal([]uint32{3, 0, 2, 0}))
+
+		// shapes beyond 32 use more words.
+		coverings := make([][]uint64, 33)
+		for i := range coverings {
+			coverings[i] = []uint64{face1}
+		}
+		boundaries, masks = buildGeoCellIndex(coverings)
+		Ω(boundaries).Should(Equal([]uint64{1<<61 + 1, 1 << 62}))
+		Ω(masks).Should(Equal([]uint32{0xFFFFFFFF, 1, 0, 0}))
+
+		boundaries, masks = buildGeoCellIndex(nil)
+		Ω(boundaries).Should(BeEmpty())
+		Ω(masks).Should(BeEmpty())
+
+		// no index if any shape does not have a covering.
+		boundaries, masks = buildGeoCellIndex([][]uint64{{face0}, nil})
+		Ω(boundaries).Should(BeEmpty())
+		Ω(masks).Should(BeEmpty())
+
+		// no index if there are too many cells.
+		boundaries, masks = buildGeoCellIndex([][]uint64{{face0}, make([]uint64, maxGeoCellIndexEntries)})
+		Ω(boundaries).Should(BeEmpty())
+		Ω(masks).Should(BeEmpty())
+	})
+
+	ginkgo.It("readGeoDimValue should work", func() {
+		qc := AQLQueryContext{
+			OOPK: OOPKContext{
+				Dimensions: []expr.Expr{
+					&expr.VarRef{
+						Val:      "g.name",
+						TableID:  1,
+						ColumnID: 2,
+						DataType: memCom.Uint8,
+					},
+				},
+				geoIntersection: &geoIntersection{
+					dimIndex:    0,
+					dimColumnID: 2,
+				},
+			},
+		}
+
+		uint16Val := uint16(2)
+		value := memCom.DataValue{Valid: true, OtherVal: unsafe.Pointer(&uint16Val)}
+		Ω(qc.readGeoDimValue(value, memCom.Uint16)).Should(Equal("2"))
+		Ω(qc.readGeoDimValue(memCom.NullDataValue, memCom.Uint16)).Should(Equal(queryCom.NULLString))
+
+		qc.OOPK.Dimensions[0].(*expr.VarRef).EnumReverseDict = []string{"downtown", "airport", "stadium"}
+		Ω(qc.readGeoDimValue(value, memCom.BigEnum)).Should(Equal("stadium"))
+	})
+
+	ginkgo.It("getEnumEqualityFilterValues should work", func() {
+		status := &expr.VarRef{Val: "status", ColumnID: 1, DataType: memCom.SmallEnum}
+		city := &expr.VarRef{Val: "city", ColumnID: 2, DataType: memCom.BigEnum}
+		eq := func(column *expr.VarRef, value int) expr.Expr {
+			return &expr.BinaryExpr{Op: expr.EQ, LHS: column, RHS: &expr.NumberLiteral{Int: value}}
+		}
+		or := func(lhs, rhs expr.Expr) expr.Expr {
+			return &expr.BinaryExpr{Op: expr.OR, LHS: lhs, RHS: rhs}
+		}
+
+		columnID, values, ok := getEnumEqualityFilterValues(eq(status, 2))
+		Ω(ok).Should(BeTrue())
+		Ω(columnID).Should(Equal(1))
+		Ω(values).Should(Equal([]uint32{2}))
+
+		columnID, values, ok = getEnumEqualityFilterValues(&expr.ParenExpr{Expr: or(or(eq(city, 1), eq(city, 3)), eq(city, -1))})
+		Ω(ok).Should(BeTrue())
+		Ω(columnID).Should(Equal(2))
+		Ω(values).Should(Equal([]uint32{1, 3}))
+
+		_, _, ok = getEnumEqualityFilterValues(or(eq(city, 1), eq(status, 1)))
+		Ω(ok).Should(BeFalse())
+		_, _, ok = getEnumEqualityFilterValues(&expr.BinaryExpr{Op: expr.NEQ, LHS: city, RHS: &expr.NumberLiteral{Int: 1}})
+		Ω(ok).Should(BeFalse())
+		_, _, ok = getEnumEqualityFilterValues(eq(&expr.VarRef{Val: "fare", ColumnID: 3, DataType: memCom.Uint16}, 1))
+		Ω(ok).Should(BeFalse())
+	})
+
+	ginkgo.It("shouldSkipArchiveBatchWithZoneMap should work", func() {
+		var buffer bytes.Buffer
+		Ω(memCom.WriteZoneMaps(&buffer, map[int]*memCom.ZoneMap{
+			0: {HasValues: true, MinInt: 100, MaxInt: 200},
+			2: {HasValues: true, MinFloat: -1.5, MaxFloat: 2.5},
+			3: {},
+		})).Should(BeNil())
+		ds := new(diskMocks.DiskStore)
+		ds.On("OpenBatchZoneMapFileForRead", "table1", 0, 1, uint32(0), uint32(0)).
+			Return(ioutil.NopCloser(&buffer), nil).Once()
+		batch := &memstore.ArchiveBatch{
+			BatchID: 1,
+			Batch:   memCom.Batch{RWMutex: &sync.RWMutex{}},
+			Shard: memstore.NewTableShard(&memCom.TableSchema{
+				Schema: metaCom.Table{Name: "table1"},
+			}, metaStore, ds, hostMemoryManager, 0, options),
+		}
+
+		c0 := &expr.VarRef{Val: "c0", ColumnID: 0, DataType: memCom.Uint32}
+		c1 := &expr.VarRef{Val: "c1", ColumnID: 1, DataType: memCom.Uint32}
+		c2 := &expr.VarRef{Val: "c2", ColumnID: 2, DataType: memCom.Float32}
+		c3 := &expr.VarRef{Val: "c3", ColumnID: 3, DataType: memCom.Int16}
+		compare := func(op expr.Token, lhs, rhs expr.Expr) expr.Expr {
+			return &expr.BinaryExpr{Op: op, LHS: lhs, RHS: rhs}
+		}
+		integer := func(value int) expr.Expr {
+			return &expr.NumberLiteral{Int: value, Val: float64(value), ExprType: expr.Signed}
+		}
+		float := func(value float64) expr.Expr {
+			return &expr.NumberLiteral{Int: int(value), Val: value, ExprType: expr.Float}
+		}
+
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.GTE, c0, integer(200)))).Should(BeFalse())
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.GT, c0, integer(200)))).Should(BeTrue())
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.LT, c0, integer(100)))).Should(BeTrue())
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.EQ, c0, integer(150)))).Should(BeFalse())
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.EQ, c0, integer(99)))).Should(BeTrue())
+		// number on the left side.
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.GT, integer(100), c0))).Should(BeTrue())
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.LTE, integer(200), c0))).Should(BeFalse())
+		// integer columns compared with float numbers are not checked.
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.GT, c0, float(200.5)))).Should(BeFalse())
+
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.GT, c2, float(2.4)))).Should(BeFalse())
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.GT, c2, float(2.5)))).Should(BeTrue())
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.LT, c2, integer(-2)))).Should(BeTrue())
+
+		// columns without zone maps.
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.GT, c1, integer(0)))).Should(BeFalse())
+		// all values are null.
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.LT, c3, integer(0)))).Should(BeTrue())
+		// not a comparison.
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.NEQ, c0, integer(0)))).Should(BeFalse())
+		ds.AssertExpectations(ginkgo.GinkgoT())
+	})
+
+	ginkgo.It("shouldSkipArchiveBatchWithZoneMap should work for Decimal and Time64 columns", func() {
+		var buffer bytes.Buffer
+		Ω(memCom.WriteZoneMaps(&buffer, map[int]*memCom.ZoneMap{
+			// 1.00 to 2.50 with scale 2.
+			0: {HasValues: true, MinInt: 100, MaxInt: 250},
+			// 2019-02-02 08:00:00.123 to 2019-02-02 23:59:59.999.
+			1: {HasValues: true, MinInt: 1549094400123, MaxInt: 1549151999999},
+		})).Should(BeNil())
+		ds := new(diskMocks.DiskStore)
+		ds.On("OpenBatchZoneMapFileForRead", "table1", 0, 1, uint32(0), uint32(0)).
+			Return(ioutil.NopCloser(&buffer), nil).Once()
+		batch := &memstore.ArchiveBatch{
+			BatchID: 1,
+			Batch:   memCom.Batch{RWMutex: &sync.RWMutex{}},
+			Shard: memstore.NewTableShard(&memCom.TableSchema{
+				Schema: metaCom.Table{Name: "table1"},
+			}, metaStore, ds, hostMemoryManager, 0, options),
+		}
+
+		c0 := &expr.VarRef{Val: "c0", ColumnID: 0, DataType: memCom.Decimal}
+		c1 := &expr.VarRef{Val: "c1", ColumnID: 1, DataType: memCom.Int64}
+		c1InSeconds := &expr.UnaryExpr{Op: expr.MILLIS_TO_SECONDS, Expr: c1, ExprType: expr.Unsigned}
+		compare := func(op expr.Token, lhs, rhs expr.Expr) expr.Expr {
+			return &expr.BinaryExpr{Op: op, LHS: lhs, RHS: rhs}
+		}
+		integer := func(value int) expr.Expr {
+			return &expr.NumberLiteral{Int: value, Val: float64(value), ExprType: expr.Signed}
+		}
+
+		// numbers compared with Decimal columns are scaled by the compiler.
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.GT, c0, integer(250)))).Should(BeTrue())
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.GTE, c0, integer(250)))).Should(BeFalse())
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.LT, c0, integer(100)))).Should(BeTrue())
+
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.LT, c1, integer(1549094400123)))).Should(BeTrue())
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.LTE, c1, integer(1549094400123)))).Should(BeFalse())
+		// time filters compare seconds of Time64 columns.
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.GTE, c1InSeconds, integer(1549152000)))).Should(BeTrue())
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.GTE, c1InSeconds, integer(1549151999)))).Should(BeFalse())
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.LT, c1InSeconds, integer(1549094400)))).Should(BeTrue())
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.LT, c1InSeconds, integer(1549094401)))).Should(BeFalse())
+		Ω(shouldSkipArchiveBatchWithZoneMap(batch, compare(expr.GT, integer(1549094400), c1InSeconds))).Should(BeTrue())
+		ds.AssertExpectations(ginkgo.GinkgoT())
+	})
+
+	ginkgo.It("hasDuplicateValues should work", func() {
+		Ω(hasDuplicateValues(nil)).Should(BeFalse())
+		Ω(hasDuplicateValues([]string{"downtown", "airport"})).Should(BeFalse())
+		Ω(hasDuplicateValues([]string{"downtown", "airport", "downtown"})).Should(BeTrue())
+	})
 })
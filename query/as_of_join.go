@@ -0,0 +1,167 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package query
+
+import (
+	"sort"
+	"unsafe"
+
+	"github.com/uber/aresdb/cgoutils"
+	"github.com/uber/aresdb/memstore"
+	memCom "github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// dimensionVersion is one version of a record in slowly changing dimension table.
+type dimensionVersion struct {
+	// primary key bytes excluding the validFrom column
+	key       string
+	validFrom uint32
+	// 0 means valid until next version
+	validTo  uint32
+	recordID memCom.RecordID
+}
+
+// versionIndex links versions of the same dimension record from the latest to the earliest.
+type versionIndex struct {
+	// record id of the latest version of each key
+	latestVersions map[string]memCom.RecordID
+	// validity range and previous version of each record slot,
+	// slot is (batchID - BaseBatchID) * batchSize + index
+	validFroms    []uint32
+	validTos      []uint32
+	prevRecordIDs []memCom.RecordID
+}
+
+// buildVersionIndex builds the version index given all versions of a slowly changing dimension table.
+func buildVersionIndex(versions []dimensionVersion, numSlots, batchSize int) versionIndex {
+	sort.Slice(versions, func(i, j int) bool {
+		if versions[i].key != versions[j].key {
+			return versions[i].key < versions[j].key
+		}
+		return versions[i].validFrom < versions[j].validFrom
+	})
+
+	index := versionIndex{
+		latestVersions: make(map[string]memCom.RecordID),
+		validFroms:     make([]uint32, numSlots),
+		validTos:       make([]uint32, numSlots),
+		prevRecordIDs:  make([]memCom.RecordID, numSlots),
+	}
+
+	for i, version := range versions {
+		slot := int(version.recordID.BatchID-memstore.BaseBatchID)*batchSize + int(version.recordID.Index)
+		index.validFroms[slot] = version.validFrom
+		index.validTos[slot] = version.validTo
+		if i > 0 && versions[i-1].key == version.key {
+			index.prevRecordIDs[slot] = versions[i-1].recordID
+		}
+		index.latestVersions[version.key] = version.recordID
+	}
+	return index
+}
+
+// readDimensionVersions reads versions of all live records of a slowly changing dimension table.
+// Caller should hold the writer lock of the live store.
+func readDimensionVersions(shard *memstore.TableShard, ft *foreignTable, batchIDs []int32,
+	numRecordsInLastBatch int) (versions []dimensionVersion) {
+	primaryKeyColumns := shard.Schema.Schema.PrimaryKeyColumns
+	// validFrom column is the last primary key column
+	keyBytes := shard.Schema.PrimaryKeyBytes - 4
+	primaryKeyValues := make([]memCom.DataValue, len(primaryKeyColumns))
+	for i, batchID := range batchIDs {
+		batch := shard.LiveStore.GetBatchForRead(batchID)
+		if batch == nil {
+			continue
+		}
+
+		size := batch.Capacity
+		if i == len(batchIDs)-1 {
+			size = numRecordsInLastBatch
+		}
+
+		for row := 0; row < size; row++ {
+			for j, columnID := range primaryKeyColumns {
+				primaryKeyValues[j] = batch.GetDataValue(row, columnID)
+			}
+			key, err := memCom.GetPrimaryKeyBytes(primaryKeyValues, shard.Schema.PrimaryKeyBytes)
+			if err != nil {
+				continue
+			}
+
+			// skip deleted or overwritten records
+			recordID := memCom.RecordID{BatchID: batchID, Index: uint32(row)}
+			if found, ok := shard.LiveStore.PrimaryKey.Find(key); !ok || found != recordID {
+				continue
+			}
+
+			version := dimensionVersion{
+				key:       string(key[:keyBytes]),
+				validFrom: *(*uint32)(primaryKeyValues[len(primaryKeyValues)-1].OtherVal),
+				recordID:  recordID,
+			}
+			if ft.validToColumnID >= 0 {
+				if validTo := batch.GetDataValue(row, ft.validToColumnID); validTo.Valid {
+					version.validTo = *(*uint32)(validTo.OtherVal)
+				}
+			}
+			versions = append(versions, version)
+		}
+		batch.RUnlock()
+	}
+	return
+}
+
+// prepareVersionIndex transfers the version index of a slowly changing dimension table and a hash index
+// from primary key excluding validFrom column to the latest version to device.
+func (qc *AQLQueryContext) prepareVersionIndex(shard *memstore.TableShard, ft *foreignTable, batchIDs []int32,
+	numRecordsInLastBatch int) {
+	shard.LiveStore.WriterLock.RLock()
+	versions := readDimensionVersions(shard, ft, batchIDs, numRecordsInLastBatch)
+	shard.LiveStore.WriterLock.RUnlock()
+
+	batchSize := shard.LiveStore.BatchSize
+	index := buildVersionIndex(versions, len(batchIDs)*batchSize, batchSize)
+
+	primaryKey := memstore.NewPrimaryKey(shard.Schema.PrimaryKeyBytes-4, false, 0, shard.HostMemoryManager)
+	defer primaryKey.Destruct()
+	for key, recordID := range index.latestVersions {
+		if _, _, err := primaryKey.FindOrInsert([]byte(key), recordID, 0); err != nil {
+			qc.Error = utils.StackError(err, "Failed to build version index for table %s", shard.Schema.Schema.Name)
+			return
+		}
+	}
+
+	hostPrimaryKeyData := primaryKey.LockForTransfer()
+	devicePrimaryKeyPtr := deviceAllocate(hostPrimaryKeyData.NumBytes, qc.Device)
+	cgoutils.AsyncCopyHostToDevice(devicePrimaryKeyPtr.getPointer(), hostPrimaryKeyData.Data, hostPrimaryKeyData.NumBytes, qc.cudaStreams[0], qc.Device)
+	cgoutils.WaitForCudaStream(qc.cudaStreams[0], qc.Device)
+	ft.hostPrimaryKeyData = hostPrimaryKeyData
+	ft.devicePrimaryKeyPtr = devicePrimaryKeyPtr
+	primaryKey.UnlockAfterTransfer()
+
+	ft.batchSize = batchSize
+	numSlots := len(index.validFroms)
+	if numSlots == 0 {
+		return
+	}
+
+	// validFroms, validTos followed by previous record ids.
+	versionIndexPtr := deviceAllocate(numSlots*(4+4+8), qc.Device)
+	cgoutils.AsyncCopyHostToDevice(versionIndexPtr.getPointer(), unsafe.Pointer(&index.validFroms[0]), numSlots*4, qc.cudaStreams[0], qc.Device)
+	cgoutils.AsyncCopyHostToDevice(versionIndexPtr.offset(numSlots*4).getPointer(), unsafe.Pointer(&index.validTos[0]), numSlots*4, qc.cudaStreams[0], qc.Device)
+	cgoutils.AsyncCopyHostToDevice(versionIndexPtr.offset(numSlots*8).getPointer(), unsafe.Pointer(&index.prevRecordIDs[0]), numSlots*8, qc.cudaStreams[0], qc.Device)
+	cgoutils.WaitForCudaStream(qc.cudaStreams[0], qc.Device)
+	ft.deviceVersionIndexPtr = versionIndexPtr
+}
@@ -0,0 +1,56 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/memstore"
+	memCom "github.com/uber/aresdb/memstore/common"
+)
+
+var _ = ginkgo.Describe("as of join", func() {
+	ginkgo.It("buildVersionIndex should work", func() {
+		baseBatchID := memstore.BaseBatchID
+		versions := []dimensionVersion{
+			{key: "a", validFrom: 200, validTo: 0, recordID: memCom.RecordID{BatchID: baseBatchID, Index: 2}},
+			{key: "b", validFrom: 100, validTo: 300, recordID: memCom.RecordID{BatchID: baseBatchID, Index: 1}},
+			{key: "a", validFrom: 100, validTo: 0, recordID: memCom.RecordID{BatchID: baseBatchID, Index: 0}},
+			{key: "a", validFrom: 300, validTo: 400, recordID: memCom.RecordID{BatchID: baseBatchID + 1, Index: 0}},
+		}
+
+		index := buildVersionIndex(versions, 6, 3)
+		Ω(index.latestVersions).Should(Equal(map[string]memCom.RecordID{
+			"a": {BatchID: baseBatchID + 1, Index: 0},
+			"b": {BatchID: baseBatchID, Index: 1},
+		}))
+		Ω(index.validFroms).Should(Equal([]uint32{100, 100, 200, 300, 0, 0}))
+		Ω(index.validTos).Should(Equal([]uint32{0, 300, 0, 400, 0, 0}))
+		Ω(index.prevRecordIDs).Should(Equal([]memCom.RecordID{
+			{},
+			{},
+			{BatchID: baseBatchID, Index: 0},
+			{BatchID: baseBatchID, Index: 2},
+			{},
+			{},
+		}))
+	})
+
+	ginkgo.It("buildVersionIndex should work with no versions", func() {
+		index := buildVersionIndex(nil, 0, 3)
+		Ω(index.latestVersions).Should(BeEmpty())
+		Ω(index.validFroms).Should(BeEmpty())
+	})
+})
@@ -284,6 +284,34 @@ class InputVectorBinderBase {
             + "when value type of first input iterator is GeoPoint");
   }
 
+  // Int64 and Decimal columns can only be compared with or combined with
+  // constants in BinaryTransform, int constants are widened to 8 bytes.
+  template<typename Int64Iterator>
+  int bindInt64(Int64Iterator int64Iter) {
+    InputVectorBinderBase<Context, NumVectors, NumUnboundIterators - 1>
+        nextBinder(context, inputVectors, indexVector, baseCounts, startCount);
+
+    InputVector input = inputVectors[NumVectors - NumUnboundIterators];
+    if (input.Type == ConstantInput) {
+      ConstantVector constant = input.Vector.Constant;
+      if (constant.DataType == ConstInt64) {
+        return nextBinder.bind(
+            int64Iter,
+            make_constant_iterator<int64_t>(
+                constant.Value.Int64Val, constant.IsValid));
+      } else if (constant.DataType == ConstInt) {
+        return nextBinder.bind(
+            int64Iter,
+            make_constant_iterator<int64_t>(
+                static_cast<int64_t>(constant.Value.IntVal),
+                constant.IsValid));
+      }
+    }
+    throw std::invalid_argument(
+        "Unsupported data type " + std::to_string(__LINE__)
+            + "when value type of first input iterator is int64");
+  }
+
   template<typename UUIDIterator>
   int bindUUID(UUIDIterator uuidIter) {
     InputVectorBinderBase<Context, NumVectors, NumUnboundIterators - 1>
@@ -425,16 +453,14 @@ class InputVectorBinderBase {
     return bindGeneric();
   }
 
-  // Int64 data type is only supported in UnaryTransform
+  // Special handling if the first input iter is a int64 iter.
   template <typename Int64Iterator>
   typename std::enable_if<
       std::is_same<typename Int64Iterator::value_type::head_type,
                    int64_t>::value,
       int>::type
   bind(Int64Iterator int64Iter) {
-    throw std::invalid_argument(
-        "int64 data type is only supported in UnaryTransform" +
-        std::to_string(__LINE__));
+    return bindInt64(int64Iter);
   }
 
   // Special handling if the first input iter is a geo iter.
@@ -69,6 +69,17 @@ type Join struct {
 	// Condition expressions to be ANDed together for the join.
 	Conditions       []string    `json:"conditions"`
 	ConditionsParsed []expr.Expr `json:"-"`
+
+	// Literal rows joined by the broker instead of a table stored in datanodes,
+	// alias is required and table is ignored when specified.
+	InlineTable *InlineTable `json:"inlineTable,omitempty"`
+}
+
+// InlineTable is a small literal table for ad-hoc joins, eg. VALUES (1, 'a'), (2, 'b').
+// Values are numbers, strings or nulls.
+type InlineTable struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
 }
 
 // TimeFilter is a syntax sugar for specifying time range.
@@ -146,6 +157,87 @@ type AQLQuery struct {
 
 	// SQLQuery
 	SQLQuery string `json:"sql,omitempty"`
+
+	// Strict enables standard SQL semantics for functions with legacy behaviors
+	// such as from_unixtime and convert_tz.
+	Strict bool `json:"strict,omitempty"`
+
+	// TriValuedLogic evaluates boolean operators, comparisons and IN with standard SQL semantics
+	// for nulls instead of the legacy semantics coercing some null results to false.
+	TriValuedLogic bool `json:"triValuedLogic,omitempty"`
+
+	// ResultFormat specifies the layout of aggregation query results, empty for nested maps
+	// keyed by dimension values. Non aggregation query results are always row major.
+	ResultFormat string `json:"resultFormat,omitempty"`
+
+	// DataScope restricts the batches scanned by datanodes to live or archive store only,
+	// empty for both.
+	DataScope string `json:"dataScope,omitempty"`
+
+	// UnknownEnumValue is the policy for filters referencing enum values missing from the enum
+	// dictionary, one of ignore (default), warn and fail.
+	UnknownEnumValue string `json:"unknownEnumValue,omitempty"`
+
+	// Fingerprint of the query as submitted to the broker, forwarded to datanodes so that
+	// query blocks match the original query instead of the rewritten one.
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// SchemaVersions are versions of the table schemas the broker compiled the query against,
+	// keyed by table name. Datanodes reject the query if their schemas do not match, so that
+	// queries are never served by mixed schemas during schema rollouts.
+	SchemaVersions map[string]int `json:"schemaVersions,omitempty"`
+
+	// Forecast appends forecasted future buckets of each time series to the aggregation results,
+	// computed by broker from the merged results. Not forwarded to datanodes.
+	Forecast *Forecast `json:"forecast,omitempty"`
+}
+
+// Forecast methods.
+const (
+	// ForecastMethodLinear extrapolates the least squares linear trend of the series.
+	ForecastMethodLinear = "linear"
+	// ForecastMethodHoltWinters applies additive Holt-Winters exponential smoothing.
+	ForecastMethodHoltWinters = "holt_winters"
+)
+
+// Forecast specifies the forecast of future buckets of time bucketized aggregation results.
+type Forecast struct {
+	// Method is one of linear and holt_winters.
+	Method string `json:"method"`
+	// Buckets is the number of future buckets to forecast.
+	Buckets int `json:"buckets"`
+	// Period is the number of buckets of a season for holt_winters, e.g. 24 for hourly buckets of
+	// a daily pattern. 0 means no seasonality (Holt's linear trend).
+	Period int `json:"period,omitempty"`
+	// Smoothing factors of level, trend and season for holt_winters in (0, 1], 0 for defaults.
+	Alpha float64 `json:"alpha,omitempty"`
+	Beta  float64 `json:"beta,omitempty"`
+	Gamma float64 `json:"gamma,omitempty"`
+}
+
+// Data scopes of query.
+const (
+	// DataScopeAll scans both live and archive batches.
+	DataScopeAll = "all"
+	// DataScopeLive scans the freshest unarchived data in live batches only.
+	DataScopeLive = "live"
+	// DataScopeArchive scans archive batches only.
+	DataScopeArchive = "archive"
+)
+
+// IsValidDataScope tells whether the data scope is supported, empty means all.
+func IsValidDataScope(scope string) bool {
+	return scope == "" || scope == DataScopeAll || scope == DataScopeLive || scope == DataScopeArchive
+}
+
+// ScanLive tells whether live batches should be scanned.
+func (q *AQLQuery) ScanLive() bool {
+	return q.DataScope != DataScopeArchive
+}
+
+// ScanArchive tells whether archive batches should be scanned.
+func (q *AQLQuery) ScanArchive() bool {
+	return q.DataScope != DataScopeLive
 }
 
 func (d Dimension) IsTimeDimension() bool {
@@ -14,11 +14,34 @@
 
 package common
 
+import "sort"
+
 const (
 	MatrixDataKey = "matrixData"
 	HeadersKey    = "headers"
 )
 
+// ResultFormatColumnMajor returns aggregation query results as one array per column
+// instead of nested maps.
+const ResultFormatColumnMajor = "columnMajor"
+
+// ColumnMajorResult represents time series result in column major format. Values of each
+// dimension followed by the measure are stored in one array per column, the ith row of the
+// result consists of the ith element of all columns.
+type ColumnMajorResult struct {
+	Headers []string        `json:"headers"`
+	Columns [][]interface{} `json:"columns"`
+	// Forecast of future buckets with the same headers, only set if requested by the query.
+	Forecast *ColumnMajorResult `json:"forecast,omitempty"`
+}
+
+// ForecastedResult wraps row major aggregation query results with the forecast of future buckets,
+// which is in the same nested format as the results.
+type ForecastedResult struct {
+	Results  interface{}            `json:"results"`
+	Forecast map[string]interface{} `json:"forecast"`
+}
+
 // AQLQueryResult represents final result of one AQL query
 //
 // It has 2 possible formats:
@@ -67,6 +90,37 @@ func (r AQLQueryResult) Set(dimValues []*string, measureValue *float64) {
 	}
 }
 
+// Reduce sets measure value for dimensions, combining it with the existing value of the same
+// dimensions if any using reduce.
+func (r AQLQueryResult) Reduce(dimValues []*string, measureValue *float64, reduce func(lhs, rhs float64) float64) {
+	null := NULLString
+	var current map[string]interface{} = r
+	for i, dimValue := range dimValues {
+		if dimValue == nil {
+			dimValue = &null
+		}
+
+		if i == len(dimValues)-1 {
+			if measureValue == nil {
+				if _, exists := current[*dimValue]; !exists {
+					current[*dimValue] = nil
+				}
+			} else if existing, ok := current[*dimValue].(float64); ok {
+				current[*dimValue] = reduce(existing, *measureValue)
+			} else {
+				current[*dimValue] = *measureValue
+			}
+		} else {
+			child := current[*dimValue]
+			if child == nil {
+				child = make(map[string]interface{})
+				current[*dimValue] = child
+			}
+			current = child.(map[string]interface{})
+		}
+	}
+}
+
 // SetHLL sets hll struct to be the leaves of the nested map.
 func (r AQLQueryResult) SetHLL(dimValues []*string, hll HLL) {
 	null := NULLString
@@ -89,6 +143,55 @@ func (r AQLQueryResult) SetHLL(dimValues []*string, hll HLL) {
 	}
 }
 
+// ToColumnMajor converts time series result with numDims layers of dimensions to column
+// major format. Rows are ordered by dimension values.
+func (r AQLQueryResult) ToColumnMajor(headers []string, numDims int) ColumnMajorResult {
+	columns := make([][]interface{}, numDims+1)
+	for i := range columns {
+		columns[i] = []interface{}{}
+	}
+
+	dimValues := make([]string, numDims)
+	var traverse func(depth int, curr interface{})
+	traverse = func(depth int, curr interface{}) {
+		if depth == numDims {
+			for i, dimValue := range dimValues {
+				columns[i] = append(columns[i], dimValue)
+			}
+			columns[numDims] = append(columns[numDims], curr)
+			return
+		}
+
+		var children map[string]interface{}
+		switch v := curr.(type) {
+		case map[string]interface{}:
+			children = v
+		case AQLQueryResult:
+			children = v
+		default:
+			return
+		}
+
+		keys := make([]string, 0, len(children))
+		for key := range children {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			dimValues[depth] = key
+			traverse(depth+1, children[key])
+		}
+	}
+
+	if numDims > 0 {
+		traverse(0, r)
+	}
+	return ColumnMajorResult{
+		Headers: headers,
+		Columns: columns,
+	}
+}
+
 // =====  Time series result methods end =====
 
 // =====  Non aggregate query result methods start =====
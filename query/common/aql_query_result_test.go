@@ -49,6 +49,54 @@ var _ = ginkgo.Describe("time series result", func() {
 		}))
 	})
 
+	ginkgo.It("Reduce should work", func() {
+		res := AQLQueryResult{}
+		dim0 := "dim0"
+		dim1 := "dim1"
+		v1, v2, v3 := 1.0, 2.0, 3.0
+		sum := func(lhs, rhs float64) float64 { return lhs + rhs }
+		res.Reduce([]*string{&dim0, &dim1}, &v1, sum)
+		res.Reduce([]*string{&dim0, &dim1}, &v2, sum)
+		res.Reduce([]*string{&dim0, &dim1}, nil, sum)
+		res.Reduce([]*string{&dim0, nil}, nil, sum)
+		res.Reduce([]*string{&dim0, nil}, &v3, sum)
+		res.Reduce([]*string{&dim1, &dim0}, nil, sum)
+		Ω(res).Should(Equal(AQLQueryResult{
+			"dim0": map[string]interface{}{
+				"dim1": 3.0,
+				"NULL": 3.0,
+			},
+			"dim1": map[string]interface{}{
+				"dim0": nil,
+			},
+		}))
+	})
+
+	ginkgo.It("ToColumnMajor should work", func() {
+		res := AQLQueryResult{
+			"1": map[string]interface{}{
+				"b": 2.0,
+				"a": nil,
+			},
+			"0": map[string]interface{}{
+				"NULL": 1.0,
+			},
+		}
+		Ω(res.ToColumnMajor([]string{"dim0", "dim1", "count"}, 2)).Should(Equal(ColumnMajorResult{
+			Headers: []string{"dim0", "dim1", "count"},
+			Columns: [][]interface{}{
+				{"0", "1", "1"},
+				{"NULL", "a", "b"},
+				{1.0, nil, 2.0},
+			},
+		}))
+
+		Ω(AQLQueryResult{}.ToColumnMajor([]string{"count"}, 0)).Should(Equal(ColumnMajorResult{
+			Headers: []string{"count"},
+			Columns: [][]interface{}{{}},
+		}))
+	})
+
 	ginkgo.It("Append should work", func() {
 		res := AQLQueryResult{}
 		str := "1"
@@ -0,0 +1,41 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package common
+
+import (
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("aql", func() {
+	ginkgo.It("data scope should work", func() {
+		Ω(IsValidDataScope("")).Should(BeTrue())
+		Ω(IsValidDataScope(DataScopeAll)).Should(BeTrue())
+		Ω(IsValidDataScope(DataScopeLive)).Should(BeTrue())
+		Ω(IsValidDataScope(DataScopeArchive)).Should(BeTrue())
+		Ω(IsValidDataScope("cold")).Should(BeFalse())
+
+		q := &AQLQuery{}
+		Ω(q.ScanLive()).Should(BeTrue())
+		Ω(q.ScanArchive()).Should(BeTrue())
+
+		q.DataScope = DataScopeLive
+		Ω(q.ScanLive()).Should(BeTrue())
+		Ω(q.ScanArchive()).Should(BeFalse())
+
+		q.DataScope = DataScopeArchive
+		Ω(q.ScanLive()).Should(BeFalse())
+		Ω(q.ScanArchive()).Should(BeTrue())
+	})
+})
@@ -13,6 +13,7 @@ var DataTypeToExprType = map[memCom.DataType]expr.Type{
 	memCom.Int16:     expr.Signed,
 	memCom.Int32:     expr.Signed,
 	memCom.Int64:     expr.Signed,
+	memCom.Decimal:   expr.Signed,
 	memCom.Uint8:     expr.Unsigned,
 	memCom.Uint16:    expr.Unsigned,
 	memCom.Uint32:    expr.Unsigned,
@@ -22,4 +23,5 @@ var DataTypeToExprType = map[memCom.DataType]expr.Type{
 	memCom.GeoPoint:  expr.GeoPoint,
 	memCom.GeoShape:  expr.GeoShape,
 	memCom.UUID:      expr.UUID,
+	memCom.String:    expr.Unsigned,
 }
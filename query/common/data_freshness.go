@@ -0,0 +1,165 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// DataFreshnessHeaderKey is the response header carrying data freshness of the queried shards.
+// Datanodes set it to a json array of ShardFreshness and broker sets it to a json DataFreshness.
+const DataFreshnessHeaderKey = "X-Ares-Data-Freshness"
+
+// QueryTimeRangeHeaderKey is the response header carrying the json QueryTimeRange scanned by
+// datanodes, which broker reports as part of DataFreshness.
+const QueryTimeRangeHeaderKey = "X-Ares-Query-Time-Range"
+
+// QueryTimeRange is the effective time range scanned by queries in seconds, after the time
+// filter is resolved against the current time. 0 means unbounded.
+type QueryTimeRange struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// Union returns the time range covering both time ranges.
+func (r QueryTimeRange) Union(other QueryTimeRange) QueryTimeRange {
+	if other.From == 0 || (r.From != 0 && other.From < r.From) {
+		r.From = other.From
+	}
+	if other.To == 0 || (r.To != 0 && other.To > r.To) {
+		r.To = other.To
+	}
+	return r
+}
+
+// ShardFreshness describes how fresh the data of a table shard is.
+type ShardFreshness struct {
+	Table string `json:"table"`
+	Shard int    `json:"shard"`
+	// Max event time ingested into the shard in seconds, 0 if unknown.
+	HighWatermark uint32 `json:"highWatermark"`
+	// Seconds between arrival and ingestion of the last upsert batch consumed from redolog.
+	RedoLogLag uint32 `json:"redoLogLag"`
+	// Event time in seconds before which data of fact table shards has been archived.
+	ArchivingCutoff uint32 `json:"archivingCutoff,omitempty"`
+}
+
+// DataFreshness is the data freshness metadata of a query response.
+type DataFreshness struct {
+	// Min known high watermark of all shards, which all shards have caught up to.
+	HighWatermark uint32 `json:"highWatermark"`
+	// Max redolog lag of all shards.
+	RedoLogLag uint32           `json:"redoLogLag"`
+	Shards     []ShardFreshness `json:"shards"`
+	// Effective time range scanned, nil if the query has no time filter.
+	TimeRange *QueryTimeRange `json:"timeRange,omitempty"`
+	// Whether all shards with known high watermarks have ingested events at or after the end of
+	// the time range, so that the most recent time bucket is no longer filling.
+	Complete bool `json:"complete"`
+}
+
+// DataFreshnessCollector collects shard freshness from datanode responses. It's safe for concurrent use.
+type DataFreshnessCollector struct {
+	sync.Mutex
+	shards    map[ShardFreshness]struct{}
+	timeRange *QueryTimeRange
+}
+
+type dataFreshnessContextKey struct{}
+
+// NewDataFreshnessCollector creates a new DataFreshnessCollector.
+func NewDataFreshnessCollector() *DataFreshnessCollector {
+	return &DataFreshnessCollector{
+		shards: make(map[ShardFreshness]struct{}),
+	}
+}
+
+// WithDataFreshnessCollector returns a copy of ctx which carries the collector
+// for datanode query client to report shard freshness to.
+func WithDataFreshnessCollector(ctx context.Context, collector *DataFreshnessCollector) context.Context {
+	return context.WithValue(ctx, dataFreshnessContextKey{}, collector)
+}
+
+// DataFreshnessCollectorFromContext returns the collector carried by ctx, nil if none.
+func DataFreshnessCollectorFromContext(ctx context.Context) *DataFreshnessCollector {
+	collector, _ := ctx.Value(dataFreshnessContextKey{}).(*DataFreshnessCollector)
+	return collector
+}
+
+// Add adds shard freshness reported by a datanode.
+func (c *DataFreshnessCollector) Add(shards ...ShardFreshness) {
+	c.Lock()
+	defer c.Unlock()
+	for _, shard := range shards {
+		c.shards[shard] = struct{}{}
+	}
+}
+
+// AddTimeRange adds the time range scanned by a datanode.
+func (c *DataFreshnessCollector) AddTimeRange(timeRange QueryTimeRange) {
+	c.Lock()
+	defer c.Unlock()
+	if c.timeRange != nil {
+		timeRange = c.timeRange.Union(timeRange)
+	}
+	c.timeRange = &timeRange
+}
+
+// Result merges collected shard freshness. If a shard is reported more than once
+// (e.g. on retries), the freshest report is kept.
+func (c *DataFreshnessCollector) Result() DataFreshness {
+	c.Lock()
+	defer c.Unlock()
+
+	type tableShard struct {
+		table string
+		shard int
+	}
+	freshest := make(map[tableShard]ShardFreshness)
+	for shard := range c.shards {
+		key := tableShard{shard.Table, shard.Shard}
+		if existing, ok := freshest[key]; !ok || shard.HighWatermark > existing.HighWatermark ||
+			(shard.HighWatermark == existing.HighWatermark && shard.RedoLogLag < existing.RedoLogLag) {
+			freshest[key] = shard
+		}
+	}
+
+	var result DataFreshness
+	for _, shard := range freshest {
+		if shard.HighWatermark > 0 && (result.HighWatermark == 0 || shard.HighWatermark < result.HighWatermark) {
+			result.HighWatermark = shard.HighWatermark
+		}
+		if shard.RedoLogLag > result.RedoLogLag {
+			result.RedoLogLag = shard.RedoLogLag
+		}
+		result.Shards = append(result.Shards, shard)
+	}
+
+	sort.Slice(result.Shards, func(i, j int) bool {
+		if result.Shards[i].Table != result.Shards[j].Table {
+			return result.Shards[i].Table < result.Shards[j].Table
+		}
+		return result.Shards[i].Shard < result.Shards[j].Shard
+	})
+
+	if c.timeRange != nil {
+		timeRange := *c.timeRange
+		result.TimeRange = &timeRange
+		result.Complete = timeRange.To > 0 && int64(result.HighWatermark) >= timeRange.To
+	}
+	return result
+}
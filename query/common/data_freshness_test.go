@@ -0,0 +1,70 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("data freshness", func() {
+	ginkgo.It("DataFreshnessCollector should work", func() {
+		Ω(DataFreshnessCollectorFromContext(context.Background())).Should(BeNil())
+
+		collector := NewDataFreshnessCollector()
+		ctx := WithDataFreshnessCollector(context.Background(), collector)
+		Ω(DataFreshnessCollectorFromContext(ctx)).Should(BeIdenticalTo(collector))
+		Ω(collector.Result()).Should(Equal(DataFreshness{}))
+
+		collector.Add(
+			ShardFreshness{Table: "trips", Shard: 1, HighWatermark: 200, RedoLogLag: 1},
+			ShardFreshness{Table: "trips", Shard: 0, HighWatermark: 100, RedoLogLag: 5},
+			ShardFreshness{Table: "api_cities", Shard: 0, HighWatermark: 0, RedoLogLag: 3},
+		)
+		// same dimension table shard reported by another datanode
+		collector.Add(
+			ShardFreshness{Table: "trips", Shard: 0, HighWatermark: 150, RedoLogLag: 2},
+			ShardFreshness{Table: "api_cities", Shard: 0, HighWatermark: 0, RedoLogLag: 1},
+		)
+
+		Ω(collector.Result()).Should(Equal(DataFreshness{
+			HighWatermark: 150,
+			RedoLogLag:    2,
+			Shards: []ShardFreshness{
+				{Table: "api_cities", Shard: 0, HighWatermark: 0, RedoLogLag: 1},
+				{Table: "trips", Shard: 0, HighWatermark: 150, RedoLogLag: 2},
+				{Table: "trips", Shard: 1, HighWatermark: 200, RedoLogLag: 1},
+			},
+		}))
+
+		collector.AddTimeRange(QueryTimeRange{From: 100, To: 160})
+		collector.AddTimeRange(QueryTimeRange{From: 90, To: 150})
+		result := collector.Result()
+		Ω(*result.TimeRange).Should(Equal(QueryTimeRange{From: 90, To: 160}))
+		// trips shard 0 has not caught up to the end of the time range.
+		Ω(result.Complete).Should(BeFalse())
+
+		collector.Add(ShardFreshness{Table: "trips", Shard: 0, HighWatermark: 170, RedoLogLag: 2})
+		Ω(collector.Result().Complete).Should(BeTrue())
+	})
+
+	ginkgo.It("QueryTimeRange union should work", func() {
+		Ω(QueryTimeRange{From: 10, To: 20}.Union(QueryTimeRange{From: 5, To: 15})).Should(Equal(QueryTimeRange{From: 5, To: 20}))
+		// 0 is unbounded.
+		Ω(QueryTimeRange{From: 10, To: 20}.Union(QueryTimeRange{To: 15})).Should(Equal(QueryTimeRange{To: 20}))
+		Ω(QueryTimeRange{From: 10}.Union(QueryTimeRange{From: 15, To: 30})).Should(Equal(QueryTimeRange{From: 10}))
+	})
+})
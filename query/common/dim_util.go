@@ -3,13 +3,116 @@ package common
 import (
 	memCom "github.com/uber/aresdb/memstore/common"
 	"github.com/uber/aresdb/query/expr"
+	"github.com/uber/aresdb/utils"
 )
 
+// AsInt64Column returns the Int64 or Decimal column of the expression if the expression
+// is the column itself, the negation of it, or the sum, difference or product of it with
+// a number literal, which are the only numeric operations kernels support on 8 bytes
+// signed values.
+func AsInt64Column(expression expr.Expr) (*expr.VarRef, bool) {
+	switch e := expression.(type) {
+	case *expr.UnaryExpr:
+		if e.Op == expr.UNARY_MINUS {
+			return asInt64VarRef(e.Expr)
+		}
+	case *expr.BinaryExpr:
+		if column, _, literalOnLHS, ok := Int64ColumnAndLiteral(e.LHS, e.RHS); ok {
+			if e.Op == expr.ADD || e.Op == expr.MUL || (e.Op == expr.SUB && !literalOnLHS) {
+				return column, true
+			}
+		}
+	}
+	return asInt64VarRef(expression)
+}
+
+// Int64ColumnAndLiteral returns the Int64 or Decimal column and the number literal if the
+// operands are exactly one of each, and whether the literal is the left hand side.
+func Int64ColumnAndLiteral(lhs, rhs expr.Expr) (column *expr.VarRef, literal *expr.NumberLiteral, literalOnLHS, ok bool) {
+	if column, ok = asInt64VarRef(lhs); ok {
+		literal, ok = rhs.(*expr.NumberLiteral)
+		return
+	}
+	if column, ok = asInt64VarRef(rhs); ok {
+		literal, ok = lhs.(*expr.NumberLiteral)
+		return column, literal, true, ok
+	}
+	return nil, nil, false, false
+}
+
+func asInt64VarRef(expression expr.Expr) (*expr.VarRef, bool) {
+	e, ok := expression.(*expr.VarRef)
+	if !ok || (e.DataType != memCom.Int64 && e.DataType != memCom.Decimal) {
+		return nil, false
+	}
+	return e, true
+}
+
+// BlockNumericOpsForColumnOverFourBytes returns error if numeric operations are applied on
+// columns over 4 bytes, since scratch spaces of kernels are 4 bytes. Int64 and Decimal
+// columns can still be negated, compared with number literals, or added to, subtracted
+// by and multiplied by number literals, given the result is the root of the expression.
+func BlockNumericOpsForColumnOverFourBytes(token expr.Token, expressions ...expr.Expr) error {
+	numericOp := token == expr.UNARY_MINUS || token == expr.BITWISE_NOT ||
+		(token >= expr.ADD && token <= expr.BITWISE_RIGHT_SHIFT)
+	int64Op := numericOp || token == expr.AND || token == expr.OR || token == expr.NEQ ||
+		(token >= expr.EQ && token <= expr.GTE)
+
+	for _, expression := range expressions {
+		_, isInt64Column := AsInt64Column(expression)
+		varRef, isVarRef := expression.(*expr.VarRef)
+		if (isInt64Column && int64Op && !isSupportedInt64Op(token, expressions)) ||
+			(!isInt64Column && numericOp && isVarRef && memCom.DataTypeBytes(varRef.DataType) > 4) {
+			return utils.StackError(nil, "numeric operations not supported for column over 4 bytes length, got %s", expression.String())
+		}
+	}
+	return nil
+}
+
+func isSupportedInt64Op(token expr.Token, expressions []expr.Expr) bool {
+	switch token {
+	case expr.UNARY_MINUS:
+		_, isVarRef := expressions[0].(*expr.VarRef)
+		return isVarRef
+	case expr.ADD, expr.SUB, expr.MUL, expr.EQ, expr.NEQ, expr.LT, expr.LTE, expr.GT, expr.GTE:
+		if len(expressions) != 2 {
+			return false
+		}
+		_, _, literalOnLHS, ok := Int64ColumnAndLiteral(expressions[0], expressions[1])
+		return ok && !(token == expr.SUB && literalOnLHS)
+	}
+	return false
+}
+
+// IsStringColumn returns whether the expression is a String column.
+func IsStringColumn(expression expr.Expr) bool {
+	e, ok := expression.(*expr.VarRef)
+	return ok && e.DataType == memCom.String
+}
+
+// BlockOpsForStringColumn returns error if String columns are used with operators other than
+// equality, membership and null checks, as their values are only comparable by dictionary ids.
+func BlockOpsForStringColumn(token expr.Token, expressions ...expr.Expr) error {
+	switch token {
+	case expr.EQ, expr.NEQ, expr.IN, expr.NOT_IN, expr.IS_NULL, expr.IS_NOT_NULL:
+		return nil
+	}
+	for _, expression := range expressions {
+		if IsStringColumn(expression) {
+			return utils.StackError(nil, "String column %s only supports EQ, NEQ, IN and null checks", expression.String())
+		}
+	}
+	return nil
+}
+
 // GetDimensionDataType gets DataType for given expr
 func GetDimensionDataType(expression expr.Expr) memCom.DataType {
 	if e, ok := expression.(*expr.VarRef); ok {
 		return e.DataType
 	}
+	if e, ok := AsInt64Column(expression); ok {
+		return e.DataType
+	}
 	switch expression.Type() {
 	case expr.Boolean:
 		return memCom.Bool
@@ -20,6 +20,87 @@ var _ = ginkgo.Describe("dim utils", func() {
 		Ω(DimValResVectorSize(0, DimCountsPerDimWidth{0, 0, 1, 1, 1})).Should(Equal(0))
 	})
 
+	ginkgo.It("BlockOpsForStringColumn should work", func() {
+		stringColumn := &expr.VarRef{Val: "s", DataType: memCom.String}
+		literal := &expr.StringLiteral{Val: "foo"}
+		Ω(IsStringColumn(stringColumn)).Should(BeTrue())
+		Ω(IsStringColumn(&expr.VarRef{DataType: memCom.SmallEnum})).Should(BeFalse())
+		Ω(IsStringColumn(literal)).Should(BeFalse())
+
+		for _, token := range []expr.Token{expr.EQ, expr.NEQ, expr.IN, expr.NOT_IN, expr.IS_NULL, expr.IS_NOT_NULL} {
+			Ω(BlockOpsForStringColumn(token, stringColumn, literal)).Should(BeNil())
+		}
+		for _, token := range []expr.Token{expr.LT, expr.ADD, expr.AND, expr.NOT, expr.UNARY_MINUS} {
+			err := BlockOpsForStringColumn(token, stringColumn, literal)
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("String column s only supports EQ, NEQ, IN and null checks"))
+		}
+		Ω(BlockOpsForStringColumn(expr.LT, &expr.VarRef{DataType: memCom.Uint32}, literal)).Should(BeNil())
+	})
+
+	ginkgo.It("BlockNumericOpsForColumnOverFourBytes should work", func() {
+		column := &expr.VarRef{Val: "amount", DataType: memCom.Decimal}
+		literal := &expr.NumberLiteral{Int: 1, ExprType: expr.Unsigned}
+		negation := &expr.UnaryExpr{Op: expr.UNARY_MINUS, Expr: column}
+		sum := &expr.BinaryExpr{Op: expr.ADD, LHS: column, RHS: literal}
+
+		for _, token := range []expr.Token{expr.ADD, expr.MUL, expr.EQ, expr.NEQ, expr.LT, expr.LTE, expr.GT, expr.GTE} {
+			Ω(BlockNumericOpsForColumnOverFourBytes(token, column, literal)).Should(BeNil())
+			Ω(BlockNumericOpsForColumnOverFourBytes(token, literal, column)).Should(BeNil())
+		}
+		Ω(BlockNumericOpsForColumnOverFourBytes(expr.SUB, column, literal)).Should(BeNil())
+		Ω(BlockNumericOpsForColumnOverFourBytes(expr.UNARY_MINUS, column)).Should(BeNil())
+		Ω(BlockNumericOpsForColumnOverFourBytes(expr.IS_NULL, column)).Should(BeNil())
+
+		for _, operands := range [][]expr.Expr{
+			{literal, column}, {column, column}, {negation, literal}, {sum, literal},
+			{column, &expr.VarRef{DataType: memCom.Uint32}},
+		} {
+			err := BlockNumericOpsForColumnOverFourBytes(expr.SUB, operands...)
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(ContainSubstring("numeric operations not supported for column over 4 bytes length"))
+		}
+		for _, token := range []expr.Token{expr.DIV, expr.MOD, expr.BITWISE_AND, expr.BITWISE_RIGHT_SHIFT, expr.AND} {
+			Ω(BlockNumericOpsForColumnOverFourBytes(token, column, literal)).ShouldNot(BeNil())
+		}
+		Ω(BlockNumericOpsForColumnOverFourBytes(expr.UNARY_MINUS, negation)).ShouldNot(BeNil())
+		Ω(BlockNumericOpsForColumnOverFourBytes(expr.BITWISE_NOT, column)).ShouldNot(BeNil())
+		Ω(BlockNumericOpsForColumnOverFourBytes(expr.GT, sum, literal)).ShouldNot(BeNil())
+
+		uuid := &expr.VarRef{DataType: memCom.UUID}
+		Ω(BlockNumericOpsForColumnOverFourBytes(expr.ADD, uuid, literal)).ShouldNot(BeNil())
+		Ω(BlockNumericOpsForColumnOverFourBytes(expr.EQ, uuid, &expr.UUIDLiteral{})).Should(BeNil())
+		Ω(BlockNumericOpsForColumnOverFourBytes(expr.ADD, &expr.VarRef{DataType: memCom.Uint32}, literal)).Should(BeNil())
+	})
+
+	ginkgo.It("AsInt64Column should work", func() {
+		column := &expr.VarRef{Val: "amount", DataType: memCom.Int64}
+		literal := &expr.NumberLiteral{Int: 1, ExprType: expr.Unsigned}
+		for _, expression := range []expr.Expr{
+			column,
+			&expr.UnaryExpr{Op: expr.UNARY_MINUS, Expr: column},
+			&expr.BinaryExpr{Op: expr.ADD, LHS: literal, RHS: column},
+			&expr.BinaryExpr{Op: expr.SUB, LHS: column, RHS: literal},
+			&expr.BinaryExpr{Op: expr.MUL, LHS: column, RHS: literal},
+		} {
+			varRef, ok := AsInt64Column(expression)
+			Ω(ok).Should(BeTrue())
+			Ω(varRef).Should(Equal(column))
+		}
+		for _, expression := range []expr.Expr{
+			&expr.VarRef{DataType: memCom.Uint32},
+			&expr.BinaryExpr{Op: expr.SUB, LHS: literal, RHS: column},
+			&expr.BinaryExpr{Op: expr.DIV, LHS: column, RHS: literal},
+			&expr.BinaryExpr{Op: expr.GT, LHS: column, RHS: literal},
+			&expr.UnaryExpr{Op: expr.NOT, Expr: column},
+		} {
+			_, ok := AsInt64Column(expression)
+			Ω(ok).Should(BeFalse())
+		}
+		Ω(GetDimensionDataType(&expr.BinaryExpr{Op: expr.ADD, LHS: column, RHS: literal, ExprType: expr.Signed})).
+			Should(Equal(memCom.Int64))
+	})
+
 	ginkgo.It("GetDimensionDataType should work", func() {
 		expr1 := &expr.VarRef{DataType: memCom.Uint32}
 		Ω(GetDimensionDataType(expr1)).Should(Equal(memCom.Uint32))
@@ -57,7 +57,7 @@ func ReadDimension(valueStart, nullStart unsafe.Pointer,
 			result = strconv.FormatFloat(float64(*(*float32)(valuePtr)), 'g', -1, 32)
 			return &result
 		}
-	case memCom.Int64, memCom.Int32, memCom.Int16, memCom.Int8, memCom.Bool:
+	case memCom.Int64, memCom.Decimal, memCom.Int32, memCom.Int16, memCom.Int8, memCom.Bool:
 		switch valueBytes {
 		case 8:
 			intValue = int64(*(*int64)(valuePtr))
@@ -70,7 +70,7 @@ func ReadDimension(valueStart, nullStart unsafe.Pointer,
 		}
 		result = strconv.FormatInt(intValue, 10)
 		return &result
-	case memCom.Uint32, memCom.Uint16, memCom.BigEnum, memCom.Uint8, memCom.SmallEnum:
+	case memCom.Uint32, memCom.Uint16, memCom.BigEnum, memCom.Uint8, memCom.SmallEnum, memCom.String:
 		switch valueBytes {
 		case 4:
 			intValue = int64(*(*uint32)(valuePtr))
@@ -100,6 +100,16 @@ func ReadDimension(valueStart, nullStart unsafe.Pointer,
 	return &result
 }
 
+// ReadDecimalDimension reads a Decimal dimension value given the index and formats it
+// with the decimal scale of the column.
+func ReadDecimalDimension(valueStart, nullStart unsafe.Pointer, index int, scale int) *string {
+	if *(*uint8)(memAccess(nullStart, index)) == 0 {
+		return nil
+	}
+	result := memCom.FormatDecimal(*(*int64)(memAccess(valueStart, 8*index)), scale)
+	return &result
+}
+
 // formatWithDataValue formats value with given type
 func formatWithDataValue(valuePtr unsafe.Pointer, dataType memCom.DataType) *string {
 	formatted := memCom.DataValue{
@@ -143,12 +153,53 @@ func GetDimensionStartOffsets(numDimsPerDimWidth DimCountsPerDimWidth, dimIndex
 	return valueOffset, nullOffset
 }
 
+// GetDimensionCardinality returns the number of distinct values, with null counted as one value,
+// of the dimension at dimIndex inside the dimension vector holding length rows.
+func GetDimensionCardinality(dimensionVector unsafe.Pointer, numDimsPerDimWidth DimCountsPerDimWidth, dimIndex int, length int) int {
+	valueOffset, nullOffset := GetDimensionStartOffsets(numDimsPerDimWidth, dimIndex, length)
+	dimBytes := 1 << uint(len(numDimsPerDimWidth)-1)
+	startDim := 0
+	for _, numDim := range numDimsPerDimWidth {
+		if startDim+int(numDim) > dimIndex {
+			break
+		}
+		startDim += int(numDim)
+		dimBytes >>= 1
+	}
+
+	values := make(map[[16]byte]struct{})
+	hasNull := false
+	for i := 0; i < length; i++ {
+		if *(*uint8)(memAccess(dimensionVector, nullOffset+i)) == 0 {
+			hasNull = true
+			continue
+		}
+		var value [16]byte
+		for b := 0; b < dimBytes; b++ {
+			value[b] = *(*uint8)(memAccess(dimensionVector, valueOffset+i*dimBytes+b))
+		}
+		values[value] = struct{}{}
+	}
+	if hasNull {
+		return len(values) + 1
+	}
+	return len(values)
+}
+
+// FormatTimeDimension formats the time dimension value in seconds as query results.
+func FormatTimeDimension(val int64, meta TimeDimensionMeta) string {
+	return formatTimeDimension(val, meta, map[TimeDimensionMeta]map[int64]string{})
+}
+
 func formatTimeDimension(val int64, meta TimeDimensionMeta, cache map[TimeDimensionMeta]map[int64]string) (result string) {
 	// We will not process timeUnit for application/hll because if application/hll holds the raw uint32
 	// value. If we convert it to milliseconds, it will overflow.
 	if meta.TimeUnit != "" {
-		val = utils.AdjustOffset(meta.FromOffset, meta.ToOffset,
-			meta.DSTSwitchTs, val)
+		if meta.TimezoneTransitions != nil {
+			val = meta.TimezoneTransitions.ToUTC(val)
+		} else {
+			val = utils.AdjustOffset(meta.FromOffset, meta.ToOffset, 0, val)
+		}
 		switch meta.TimeUnit {
 		case "day":
 			val /= SecondsPerDay
@@ -23,6 +23,26 @@ import (
 )
 
 var _ = ginkgo.Describe("dimval", func() {
+	ginkgo.It("GetDimensionCardinality should work", func() {
+		// one 4-byte dimension and one 1-byte dimension of 4 rows.
+		numDimsPerDimWidth := DimCountsPerDimWidth{0, 0, 1, 0, 1}
+		dimensionVector := [4*4 + 4*1 + 2*4]uint8{
+			// 4-byte values.
+			1, 0, 0, 0, 2, 0, 0, 0, 1, 0, 0, 0, 7, 0, 0, 0,
+			// 1-byte values.
+			3, 3, 3, 0,
+			// nulls of the 4-byte dimension.
+			1, 1, 1, 0,
+			// nulls of the 1-byte dimension.
+			1, 1, 1, 1,
+		}
+		// 1, 2, 1 and null.
+		Ω(GetDimensionCardinality(unsafe.Pointer(&dimensionVector[0]), numDimsPerDimWidth, 0, 4)).Should(Equal(3))
+		// 3, 3, 3 and 0.
+		Ω(GetDimensionCardinality(unsafe.Pointer(&dimensionVector[0]), numDimsPerDimWidth, 1, 4)).Should(Equal(2))
+		Ω(GetDimensionCardinality(unsafe.Pointer(&dimensionVector[0]), numDimsPerDimWidth, 1, 0)).Should(Equal(0))
+	})
+
 	ginkgo.It("GetDimensionStartOffsets should work", func() {
 		numDimsPerDimWidth := DimCountsPerDimWidth{0, 0, 1, 1, 1}
 		valueOffset, nullsOffset := GetDimensionStartOffsets(numDimsPerDimWidth, 0, 10)
@@ -89,6 +109,13 @@ var _ = ginkgo.Describe("dimval", func() {
 		Ω(*ReadDimension(memAccess(dimValueVector, 0+3*16),
 			memAccess(dimNullVector, 3), 2, memCom.Int64, nil, nil, nil)).Should(Equal("4294967295"))
 
+		Ω(ReadDecimalDimension(memAccess(dimValueVector, 0+3*16),
+			memAccess(dimNullVector, 3), 0, 2)).Should(BeNil())
+		Ω(*ReadDecimalDimension(memAccess(dimValueVector, 0+3*16),
+			memAccess(dimNullVector, 3), 1, 2)).Should(Equal("0.02"))
+		Ω(*ReadDecimalDimension(memAccess(dimValueVector, 0+3*16),
+			memAccess(dimNullVector, 3), 2, 2)).Should(Equal("42949672.95"))
+
 		Ω(ReadDimension(memAccess(dimValueVector, 48+3*8),
 			memAccess(dimNullVector, 6), 0, memCom.Uint32, nil, nil, nil)).Should(BeNil())
 		Ω(*ReadDimension(memAccess(dimValueVector, 48+3*8),
@@ -0,0 +1,67 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/uber/aresdb/utils"
+)
+
+// QueryWarningsHeaderKey is the response header carrying warnings found when compiling the query,
+// one header value per warning.
+const QueryWarningsHeaderKey = "X-Ares-Query-Warnings"
+
+// StaleEnumRetryHeaderKey is the response header listing enum columns, as table.column, whose
+// dictionary cached by broker was stale and re-fetched before the query was compiled again.
+const StaleEnumRetryHeaderKey = "X-Ares-Stale-Enum-Retry"
+
+// Policies for enum values referenced by filters but missing from the enum dictionary.
+const (
+	// UnknownEnumValueIgnore silently matches nothing, which is the default.
+	UnknownEnumValueIgnore = "ignore"
+	// UnknownEnumValueWarn matches nothing and reports a warning in the response.
+	UnknownEnumValueWarn = "warn"
+	// UnknownEnumValueFail fails the query.
+	UnknownEnumValueFail = "fail"
+)
+
+// IsValidUnknownEnumValuePolicy tells whether the unknown enum value policy is supported,
+// empty means ignore.
+func IsValidUnknownEnumValuePolicy(policy string) bool {
+	return policy == "" || policy == UnknownEnumValueIgnore || policy == UnknownEnumValueWarn ||
+		policy == UnknownEnumValueFail
+}
+
+// CheckUnknownEnumValue applies the unknown enum value policy of the query to a value missing
+// from the enum dictionary of the column. It returns the warning to report if any, or the error
+// to fail the query with.
+func (q *AQLQuery) CheckUnknownEnumValue(column, value string) (string, error) {
+	switch q.UnknownEnumValue {
+	case UnknownEnumValueWarn:
+		return fmt.Sprintf("unknown enum value %q of column %s matches nothing", value, column), nil
+	case UnknownEnumValueFail:
+		return "", utils.StackError(nil, "unknown enum value %q of column %s", value, column)
+	}
+	return "", nil
+}
+
+// SetQueryWarningsHeader adds the warnings into response header.
+func SetQueryWarningsHeader(w http.ResponseWriter, warnings []string) {
+	for _, warning := range warnings {
+		w.Header().Add(QueryWarningsHeaderKey, warning)
+	}
+}
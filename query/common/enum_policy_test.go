@@ -0,0 +1,54 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/http/httptest"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("unknown enum value policy", func() {
+	ginkgo.It("CheckUnknownEnumValue should work", func() {
+		Ω(IsValidUnknownEnumValuePolicy("")).Should(BeTrue())
+		Ω(IsValidUnknownEnumValuePolicy(UnknownEnumValueWarn)).Should(BeTrue())
+		Ω(IsValidUnknownEnumValuePolicy("drop")).Should(BeFalse())
+
+		query := &AQLQuery{}
+		warning, err := query.CheckUnknownEnumValue("status", "canceled")
+		Ω(warning).Should(BeEmpty())
+		Ω(err).Should(BeNil())
+
+		query.UnknownEnumValue = UnknownEnumValueWarn
+		warning, err = query.CheckUnknownEnumValue("status", "canceled")
+		Ω(warning).Should(Equal(`unknown enum value "canceled" of column status matches nothing`))
+		Ω(err).Should(BeNil())
+
+		query.UnknownEnumValue = UnknownEnumValueFail
+		warning, err = query.CheckUnknownEnumValue("status", "canceled")
+		Ω(warning).Should(BeEmpty())
+		Ω(err.Error()).Should(ContainSubstring(`unknown enum value "canceled" of column status`))
+	})
+
+	ginkgo.It("SetQueryWarningsHeader should work", func() {
+		w := httptest.NewRecorder()
+		SetQueryWarningsHeader(w, nil)
+		Ω(w.Header()).ShouldNot(HaveKey(QueryWarningsHeaderKey))
+
+		SetQueryWarningsHeader(w, []string{"a", "b"})
+		Ω(w.Header()[QueryWarningsHeaderKey]).Should(Equal([]string{"a", "b"}))
+	})
+})
@@ -0,0 +1,240 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"strconv"
+	"time"
+
+	memCom "github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/query/expr"
+	"github.com/uber/aresdb/utils"
+)
+
+// RewriteFromUnixTime rewrites from_unixtime call to its argument as timestamps are already in seconds.
+// In lenient mode, only the legacy form from_unixtime(time_col / 1000) is allowed and rewritten to
+// time_col for backward compatibility. In strict mode, the argument is the seconds since epoch
+// as in standard SQL.
+func RewriteFromUnixTime(call *expr.Call, strict bool) (expr.Expr, error) {
+	if strict {
+		if len(call.Args) != 1 {
+			return nil, utils.StackError(nil, "from_unixtime takes exactly 1 argument")
+		}
+		return call.Args[0], nil
+	}
+
+	timeColumnDivideErrMsg := "from_unixtime must be time column / 1000"
+	if len(call.Args) != 1 {
+		return nil, utils.StackError(nil, timeColumnDivideErrMsg)
+	}
+	timeColDivide, isBinary := call.Args[0].(*expr.BinaryExpr)
+	if !isBinary || timeColDivide.Op != expr.DIV {
+		return nil, utils.StackError(nil, timeColumnDivideErrMsg)
+	}
+	divisor, isLiteral := timeColDivide.RHS.(*expr.NumberLiteral)
+	if !isLiteral || divisor.Int != 1000 {
+		return nil, utils.StackError(nil, timeColumnDivideErrMsg)
+	}
+	if par, isParen := timeColDivide.LHS.(*expr.ParenExpr); isParen {
+		timeColDivide.LHS = par.Expr
+	}
+	timeColExpr, isVarRef := timeColDivide.LHS.(*expr.VarRef)
+	if !isVarRef {
+		return nil, utils.StackError(nil, timeColumnDivideErrMsg)
+	}
+	return timeColExpr, nil
+}
+
+// maxTimezoneTransitions is the max number of utc offset changes in the time range of queries
+// converting timezones, each of which adds a comparison per row.
+const maxTimezoneTransitions = 64
+
+// RewriteConvertTz rewrites convert_tz(ts, from_tz, to_tz) to ts plus the offset between the timezones.
+// If the time range of the query is known, changes of the offset within the range (e.g. daylight
+// saving time) are shipped in the rewritten expression so that each row is converted with the
+// offset in effect at its own time. Otherwise in lenient mode, the offset is taken at current time
+// even if the timezones observe daylight saving time, and in strict mode timezones observing
+// daylight saving time are rejected. In strict mode negative offsets produce signed results.
+func RewriteConvertTz(call *expr.Call, strict bool, from, to *AlignedTime) (expr.Expr, error) {
+	if len(call.Args) != 3 {
+		return nil, utils.StackError(nil, "convert_tz must have 3 arguments")
+	}
+	fromTzStringExpr, isStrLiteral := call.Args[1].(*expr.StringLiteral)
+	if !isStrLiteral {
+		return nil, utils.StackError(nil, "2nd argument of convert_tz must be a string")
+	}
+	toTzStringExpr, isStrLiteral := call.Args[2].(*expr.StringLiteral)
+	if !isStrLiteral {
+		return nil, utils.StackError(nil, "3rd argument of convert_tz must be a string")
+	}
+	fromTz, err := ParseTimezone(fromTzStringExpr.Val)
+	if err != nil {
+		return nil, utils.StackError(err, "failed to rewrite convert_tz")
+	}
+	toTz, err := ParseTimezone(toTzStringExpr.Val)
+	if err != nil {
+		return nil, utils.StackError(err, "failed to rewrite convert_tz")
+	}
+
+	if from != nil && to != nil && from.Time.Before(to.Time) {
+		transitions := utils.CalculateTimezoneDiffTransitions(from.Time.Unix(), to.Time.Unix(), fromTz, toTz)
+		if len(transitions.Transitions) > 0 {
+			return TimezoneOffsetExpr(call.Args[0], transitions, strict)
+		}
+		return convertTzWithOffset(call.Args[0], transitions.InitialOffset, strict), nil
+	}
+
+	now := utils.Now()
+	if strict {
+		for _, tz := range []*time.Location{fromTz, toTz} {
+			if observesDST(tz, now.Year()) {
+				return nil, utils.StackError(nil,
+					"convert_tz does not support timezone %s observing daylight saving time in strict mode "+
+						"without time filter", tz)
+			}
+		}
+	}
+
+	_, fromOffsetInSeconds := now.In(fromTz).Zone()
+	_, toOffsetInSeconds := now.In(toTz).Zone()
+	return convertTzWithOffset(call.Args[0], toOffsetInSeconds-fromOffsetInSeconds, strict), nil
+}
+
+func convertTzWithOffset(ts expr.Expr, offsetInSeconds int, strict bool) expr.Expr {
+	exprType := expr.Unsigned
+	if strict && offsetInSeconds < 0 {
+		exprType = expr.Signed
+	}
+	return &expr.BinaryExpr{
+		Op:  expr.ADD,
+		LHS: ts,
+		RHS: &expr.NumberLiteral{
+			Int:      offsetInSeconds,
+			Expr:     strconv.Itoa(offsetInSeconds),
+			ExprType: exprType,
+		},
+		ExprType: exprType,
+	}
+}
+
+// TimezoneOffsetExpr returns ts plus the offsets in effect at ts, which is
+// ts + initialOffset + (ts >= transitionTs1) * (offset1 - initialOffset) + ...
+// Offsets are added or subtracted as positive numbers so that the expression survives
+// formatting and parsing again on datanodes. Results are signed in strict mode if any offset
+// is negative.
+func TimezoneOffsetExpr(ts expr.Expr, transitions utils.TimezoneTransitions, strict bool) (expr.Expr, error) {
+	if len(transitions.Transitions) > maxTimezoneTransitions {
+		return nil, utils.StackError(nil, "time range spans %d timezone offset changes, at most %d are allowed",
+			len(transitions.Transitions), maxTimezoneTransitions)
+	}
+
+	exprType := expr.Unsigned
+	if strict {
+		if transitions.InitialOffset < 0 {
+			exprType = expr.Signed
+		}
+		for _, transition := range transitions.Transitions {
+			if transition.Offset < 0 {
+				exprType = expr.Signed
+			}
+		}
+	}
+
+	addOffset := func(lhs expr.Expr, offset int, rhs func(literal expr.Expr) expr.Expr) expr.Expr {
+		op := expr.ADD
+		if offset < 0 {
+			op, offset = expr.SUB, -offset
+		}
+		return &expr.BinaryExpr{
+			Op:  op,
+			LHS: lhs,
+			RHS: rhs(&expr.NumberLiteral{
+				Int:      offset,
+				Expr:     strconv.Itoa(offset),
+				ExprType: expr.Unsigned,
+			}),
+			ExprType: exprType,
+		}
+	}
+
+	converted := addOffset(ts, transitions.InitialOffset, func(literal expr.Expr) expr.Expr { return literal })
+	offset := transitions.InitialOffset
+	for _, transition := range transitions.Transitions {
+		switchTs := transition.Ts
+		converted = addOffset(converted, transition.Offset-offset, func(literal expr.Expr) expr.Expr {
+			return &expr.ParenExpr{Expr: &expr.BinaryExpr{
+				Op:  expr.MUL,
+				LHS: literal,
+				RHS: &expr.ParenExpr{Expr: &expr.BinaryExpr{
+					Op:  expr.GTE,
+					LHS: ts,
+					RHS: &expr.NumberLiteral{
+						Int:      int(switchTs),
+						Expr:     strconv.FormatInt(switchTs, 10),
+						ExprType: expr.Unsigned,
+					},
+					ExprType: expr.Boolean,
+				}},
+				ExprType: expr.Unsigned,
+			}}
+		})
+		offset = transition.Offset
+	}
+	return converted, nil
+}
+
+// observesDST returns whether the timezone has different offsets in the given year.
+func observesDST(tz *time.Location, year int) bool {
+	_, januaryOffset := time.Date(year, time.January, 1, 0, 0, 0, 0, tz).Zone()
+	_, julyOffset := time.Date(year, time.July, 1, 0, 0, 0, 0, tz).Zone()
+	return januaryOffset != julyOffset
+}
+
+// RewriteMapValues rewrites map_values(enum_col, {'value': 'group', ...}, 'default') to the enum column
+// whose arguments have already been rewritten, and returns the groups of all enum values by enum rank.
+// Enum values not in the map fall into the default group if specified, otherwise they are kept as is.
+func RewriteMapValues(call *expr.Call) (*expr.VarRef, []string, error) {
+	if len(call.Args) != 2 && len(call.Args) != 3 {
+		return nil, nil, utils.StackError(nil, "map_values must have 2 or 3 arguments")
+	}
+	column, isVarRef := call.Args[0].(*expr.VarRef)
+	if !isVarRef || !memCom.IsEnumType(column.DataType) {
+		return nil, nil, utils.StackError(nil, "1st argument of map_values must be an enum column")
+	}
+	valueMap, isMapLiteral := call.Args[1].(*expr.MapLiteral)
+	if !isMapLiteral {
+		return nil, nil, utils.StackError(nil, "2nd argument of map_values must be a map")
+	}
+	var defaultGroup *string
+	if len(call.Args) == 3 {
+		defaultExpr, isStrLiteral := call.Args[2].(*expr.StringLiteral)
+		if !isStrLiteral {
+			return nil, nil, utils.StackError(nil, "3rd argument of map_values must be a string")
+		}
+		defaultGroup = &defaultExpr.Val
+	}
+
+	groups := make([]string, len(column.EnumReverseDict))
+	for rank, value := range column.EnumReverseDict {
+		if group, exists := valueMap.Val[value]; exists {
+			groups[rank] = group
+		} else if defaultGroup != nil {
+			groups[rank] = *defaultGroup
+		} else {
+			groups[rank] = value
+		}
+	}
+	return column, groups, nil
+}
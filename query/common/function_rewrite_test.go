@@ -0,0 +1,139 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"time"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	memCom "github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/query/expr"
+)
+
+var _ = ginkgo.Describe("function rewrite", func() {
+	ginkgo.It("RewriteFromUnixTime should work", func() {
+		timeCol := &expr.VarRef{Val: "request_at"}
+		legacyCall := &expr.Call{
+			Name: expr.FromUnixTimeCallName,
+			Args: []expr.Expr{&expr.BinaryExpr{
+				Op:  expr.DIV,
+				LHS: &expr.ParenExpr{Expr: timeCol},
+				RHS: &expr.NumberLiteral{Int: 1000, Expr: "1000"},
+			}},
+		}
+		rewritten, err := RewriteFromUnixTime(legacyCall, false)
+		Ω(err).Should(BeNil())
+		Ω(rewritten).Should(Equal(timeCol))
+
+		_, err = RewriteFromUnixTime(&expr.Call{Name: expr.FromUnixTimeCallName, Args: []expr.Expr{timeCol}}, false)
+		Ω(err.Error()).Should(ContainSubstring("from_unixtime must be time column / 1000"))
+
+		// strict mode takes seconds since epoch.
+		rewritten, err = RewriteFromUnixTime(&expr.Call{Name: expr.FromUnixTimeCallName, Args: []expr.Expr{timeCol}}, true)
+		Ω(err).Should(BeNil())
+		Ω(rewritten).Should(Equal(timeCol))
+
+		rewritten, err = RewriteFromUnixTime(legacyCall, true)
+		Ω(err).Should(BeNil())
+		Ω(rewritten).Should(Equal(legacyCall.Args[0]))
+
+		_, err = RewriteFromUnixTime(&expr.Call{Name: expr.FromUnixTimeCallName}, true)
+		Ω(err).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("RewriteConvertTz should work", func() {
+		timeCol := &expr.VarRef{Val: "request_at"}
+		call := &expr.Call{
+			Name: expr.ConvertTzCallName,
+			Args: []expr.Expr{timeCol, &expr.StringLiteral{Val: "GMT"}, &expr.StringLiteral{Val: "-8:00"}},
+		}
+		rewritten, err := RewriteConvertTz(call, false, nil, nil)
+		Ω(err).Should(BeNil())
+		Ω(rewritten.String()).Should(Equal("request_at + -28800"))
+		Ω(rewritten.Type()).Should(Equal(expr.Unsigned))
+
+		rewritten, err = RewriteConvertTz(call, true, nil, nil)
+		Ω(err).Should(BeNil())
+		Ω(rewritten.String()).Should(Equal("request_at + -28800"))
+		Ω(rewritten.Type()).Should(Equal(expr.Signed))
+
+		call.Args[2] = &expr.StringLiteral{Val: "America/Los_Angeles"}
+		_, err = RewriteConvertTz(call, false, nil, nil)
+		Ω(err).Should(BeNil())
+		_, err = RewriteConvertTz(call, true, nil, nil)
+		Ω(err.Error()).Should(ContainSubstring("daylight saving time in strict mode"))
+
+		_, err = RewriteConvertTz(&expr.Call{Name: expr.ConvertTzCallName, Args: []expr.Expr{timeCol}}, true, nil, nil)
+		Ω(err.Error()).Should(ContainSubstring("convert_tz must have 3 arguments"))
+
+		// offsets within the time range are shipped in the rewritten expression.
+		from := &AlignedTime{Time: time.Unix(1546300800, 0)}
+		to := &AlignedTime{Time: time.Unix(1577836800, 0)}
+		rewritten, err = RewriteConvertTz(call, true, from, to)
+		Ω(err).Should(BeNil())
+		Ω(rewritten.String()).Should(Equal("request_at - 28800 + (3600 * (request_at >= 1552212000)) - " +
+			"(3600 * (request_at >= 1572771600))"))
+		Ω(rewritten.Type()).Should(Equal(expr.Signed))
+		reparsed, err := expr.ParseExpr(rewritten.String())
+		Ω(err).Should(BeNil())
+		Ω(reparsed.String()).Should(Equal(rewritten.String()))
+
+		// constant offset within the time range.
+		to = &AlignedTime{Time: time.Unix(1548979200, 0)}
+		rewritten, err = RewriteConvertTz(call, true, from, to)
+		Ω(err).Should(BeNil())
+		Ω(rewritten.String()).Should(Equal("request_at + -28800"))
+	})
+
+	ginkgo.It("RewriteMapValues should work", func() {
+		enumCol := &expr.VarRef{
+			Val:             "status",
+			DataType:        memCom.SmallEnum,
+			EnumReverseDict: []string{"completed", "canceled", "rejected", "expired"},
+		}
+		valueMap := &expr.MapLiteral{Val: map[string]string{"canceled": "failed", "rejected": "failed"}}
+		column, groups, err := RewriteMapValues(&expr.Call{
+			Name: expr.MapValuesCallName,
+			Args: []expr.Expr{enumCol, valueMap, &expr.StringLiteral{Val: "other"}},
+		})
+		Ω(err).Should(BeNil())
+		Ω(column).Should(Equal(enumCol))
+		Ω(groups).Should(Equal([]string{"other", "failed", "failed", "other"}))
+
+		// values not in the map are kept as is without default group.
+		_, groups, err = RewriteMapValues(&expr.Call{
+			Name: expr.MapValuesCallName,
+			Args: []expr.Expr{enumCol, valueMap},
+		})
+		Ω(err).Should(BeNil())
+		Ω(groups).Should(Equal([]string{"completed", "failed", "failed", "expired"}))
+
+		_, _, err = RewriteMapValues(&expr.Call{
+			Name: expr.MapValuesCallName,
+			Args: []expr.Expr{&expr.VarRef{Val: "fare", DataType: memCom.Float32}, valueMap},
+		})
+		Ω(err.Error()).Should(ContainSubstring("1st argument of map_values must be an enum column"))
+
+		_, _, err = RewriteMapValues(&expr.Call{
+			Name: expr.MapValuesCallName,
+			Args: []expr.Expr{enumCol, &expr.StringLiteral{Val: "canceled"}},
+		})
+		Ω(err.Error()).Should(ContainSubstring("2nd argument of map_values must be a map"))
+
+		_, _, err = RewriteMapValues(&expr.Call{Name: expr.MapValuesCallName, Args: []expr.Expr{enumCol}})
+		Ω(err.Error()).Should(ContainSubstring("map_values must have 2 or 3 arguments"))
+	})
+})
@@ -347,7 +347,8 @@ func parseOldTimeseriesHLLResult(buffer []byte, ignoreEnum bool) (AQLQueryResult
 			valueOffset, nullOffset := offsets[0], offsets[1]
 			valuePtr, nullPtr := memAccess(dimValuesVector, valueOffset), memAccess(dimValuesVector, nullOffset)
 			enumDict := []string{}
-			if !ignoreEnum {
+			// String ids are local to the data node so they are always translated.
+			if !ignoreEnum || dataTypes[dimIndex] == memCom.String {
 				enumDict = enumDicts[dimIndex]
 			}
 			dimValues[dimIndex] = ReadDimension(valuePtr, nullPtr, i, dataTypes[dimIndex], enumDict, nil, nil)
@@ -485,7 +486,8 @@ func parseTimeseriesHLLResult(buffer []byte, ignoreEnum bool) (AQLQueryResult, e
 			valueOffset, nullOffset := offsets[0], offsets[1]
 			valuePtr, nullPtr := memAccess(dimValuesVector, valueOffset), memAccess(dimValuesVector, nullOffset)
 			enumDict := []string{}
-			if !ignoreEnum {
+			// String ids are local to the data node so they are always translated.
+			if !ignoreEnum || dataTypes[dimIndex] == memCom.String {
 				enumDict = enumDicts[dimIndex]
 			}
 			dimValues[dimIndex] = ReadDimension(valuePtr, nullPtr, i, dataTypes[dimIndex], enumDict, nil, nil)
@@ -501,24 +503,29 @@ func parseTimeseriesHLLResult(buffer []byte, ignoreEnum bool) (AQLQueryResult, e
 
 // ComputeHLLResult computes hll result
 func ComputeHLLResult(result AQLQueryResult) AQLQueryResult {
-	return computeHLLResultRecursive(result).(AQLQueryResult)
+	return ComputeHLLResultWithPrecision(result, int(hllP))
+}
+
+// ComputeHLLResultWithPrecision computes hll result of hll values of the precision.
+func ComputeHLLResultWithPrecision(result AQLQueryResult, precision int) AQLQueryResult {
+	return computeHLLResultRecursive(result, precision).(AQLQueryResult)
 }
 
 // computeHLLResultRecursive computes hll value
-func computeHLLResultRecursive(result interface{}) interface{} {
+func computeHLLResultRecursive(result interface{}, precision int) interface{} {
 	switch r := result.(type) {
 	case AQLQueryResult:
 		for k, v := range r {
-			r[k] = computeHLLResultRecursive(v)
+			r[k] = computeHLLResultRecursive(v, precision)
 		}
 		return r
 	case map[string]interface{}:
 		for k, v := range r {
-			r[k] = computeHLLResultRecursive(v)
+			r[k] = computeHLLResultRecursive(v, precision)
 		}
 		return r
 	case HLL:
-		return r.Compute()
+		return r.ComputeWithPrecision(precision)
 	default:
 		// return original for all other types
 		return r
@@ -733,8 +740,18 @@ func (hll *HLL) encodeSparse(padding bool) []byte {
 
 // Compute computes the result of the HLL.
 func (hll *HLL) Compute() float64 {
+	return hll.ComputeWithPrecision(int(hllP))
+}
+
+// ComputeWithPrecision computes the result of the HLL with 2^precision registers. Bias correction
+// data is only available for the default precision, lower precisions fall back to linear counting
+// for small cardinalities.
+func (hll *HLL) ComputeWithPrecision(precision int) float64 {
+	if precision <= 0 || precision > int(hllP) {
+		precision = int(hllP)
+	}
 	nonZeroRegisters := float64(hll.NonZeroRegisters)
-	m := float64(uint64(1) << hllP)
+	m := float64(uint64(1) << uint(precision))
 
 	// Sum of reciproclas of rhos
 	var sumOfReciprocals float64
@@ -745,14 +762,35 @@ func (hll *HLL) Compute() float64 {
 		// Add missing rho reciprocals for sparse form.
 		sumOfReciprocals += m - nonZeroRegisters
 	}
-	for _, rho := range hll.DenseData {
+	denseData := hll.DenseData
+	// registers beyond 2^precision are never set.
+	if len(denseData) > int(m) {
+		denseData = denseData[:int(m)]
+	}
+	for _, rho := range denseData {
 		sumOfReciprocals += 1.0 / float64(uint64(1)<<rho)
 	}
 
 	// Initial estimation.
 	alpha := 0.7213 / (1 + 1.079/m)
+	switch precision {
+	case 4:
+		alpha = 0.673
+	case 5:
+		alpha = 0.697
+	case 6:
+		alpha = 0.709
+	}
 	estimate := alpha * m * m / sumOfReciprocals
 
+	if precision != int(hllP) {
+		if estimate <= 2.5*m && nonZeroRegisters < m {
+			// Linear counting
+			estimate = m * math.Log(m/(m-nonZeroRegisters))
+		}
+		return float64(uint64(estimate))
+	}
+
 	// Bias correction.
 	if estimate <= 5.0*m {
 		estimate -= getEstimateBias(estimate)
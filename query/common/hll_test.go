@@ -19,6 +19,7 @@ import (
 	"github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	memCom "github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/utils"
 	"io/ioutil"
 	"unsafe"
 )
@@ -169,6 +170,26 @@ var _ = ginkgo.Describe("hll", func() {
 		Ω(h.Compute()).Should(Equal(2.0))
 	})
 
+	ginkgo.It("Computes hll of lower precision correctly", func() {
+		for _, precision := range []int{6, 10} {
+			h := HLL{DenseData: make([]byte, 1<<hllP)}
+			for i := 0; i < 20000; i++ {
+				v := uint64(i)
+				value := utils.ComputeHLLValueWithPrecision(utils.Murmur3Sum64(unsafe.Pointer(&v), 8, 0), precision)
+				index, rho := uint16(value&0xFFFF), byte(value>>16)+1
+				if h.DenseData[index] == 0 {
+					h.NonZeroRegisters++
+				}
+				if h.DenseData[index] < rho {
+					h.DenseData[index] = rho
+				}
+			}
+			estimate := h.ComputeWithPrecision(precision)
+			// standard error is 1.04/sqrt(2^precision).
+			Ω(estimate).Should(BeNumerically("~", 20000, 20000*3*1.04/float64(uint(1)<<uint(precision/2))))
+		}
+	})
+
 	ginkgo.It("Parse empty hll result", func() {
 		data, err := ioutil.ReadFile("../../testing/data/query/hll_empty_results")
 		Ω(err).Should(BeNil())
@@ -0,0 +1,180 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/uber/aresdb/utils"
+)
+
+var (
+	fingerprintStringLiteral = regexp.MustCompile(`'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"`)
+	fingerprintNumberLiteral = regexp.MustCompile(`\b[0-9]+(?:\.[0-9]+)?\b`)
+	fingerprintWhitespace    = regexp.MustCompile(`\s+`)
+)
+
+// normalizeExpr replaces literals in the expression with ? and normalizes case and whitespaces,
+// so that queries differing only in filter values share the same fingerprint.
+func normalizeExpr(expr string) string {
+	expr = fingerprintStringLiteral.ReplaceAllString(expr, "?")
+	expr = fingerprintNumberLiteral.ReplaceAllString(expr, "?")
+	expr = fingerprintWhitespace.ReplaceAllString(strings.TrimSpace(expr), " ")
+	return strings.ToLower(expr)
+}
+
+// QueryFingerprint returns the fingerprint of the shape of the query: tables, dimensions,
+// measures and filters with literals stripped. Time range, limit and shards are not part
+// of the fingerprint so that a dashboard query keeps its fingerprint when refreshed.
+func QueryFingerprint(query *AQLQuery) string {
+	var parts []string
+	parts = append(parts, "table:"+query.Table)
+	for _, join := range query.Joins {
+		conditions := make([]string, len(join.Conditions))
+		for i, condition := range join.Conditions {
+			conditions[i] = normalizeExpr(condition)
+		}
+		sort.Strings(conditions)
+		parts = append(parts, "join:"+join.Table+":"+join.Alias+":"+strings.Join(conditions, ","))
+	}
+	for _, dim := range query.Dimensions {
+		parts = append(parts, "dim:"+normalizeExpr(dim.Expr)+":"+dim.TimeBucketizer+":"+dim.TimeUnit)
+	}
+	for _, measure := range query.Measures {
+		filters := make([]string, len(measure.Filters))
+		for i, filter := range measure.Filters {
+			filters[i] = normalizeExpr(filter)
+		}
+		sort.Strings(filters)
+		parts = append(parts, "measure:"+normalizeExpr(measure.Expr)+":"+strings.Join(filters, ","))
+	}
+	filters := make([]string, len(query.Filters))
+	for i, filter := range query.Filters {
+		filters[i] = normalizeExpr(filter)
+	}
+	sort.Strings(filters)
+	parts = append(parts, "filters:"+strings.Join(filters, ","))
+	parts = append(parts, "time:"+query.TimeFilter.Column)
+
+	sum := sha1.Sum([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// QueryBlock blocks queries on a table matching the fingerprint until it expires.
+type QueryBlock struct {
+	Table string `json:"table"`
+	// Fingerprint of blocked queries, empty blocks all queries on the table.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	// Unix seconds when the block expires.
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// Matches tells whether the block applies to the query with the fingerprint.
+func (b QueryBlock) Matches(table, fingerprint string) bool {
+	return b.Table == table && (b.Fingerprint == "" || b.Fingerprint == fingerprint)
+}
+
+// QueryBlocklist holds the query blocks placed by admins in memory, blocks are dropped once
+// expired.
+type QueryBlocklist struct {
+	sync.RWMutex
+	// keyed by table and fingerprint.
+	blocks map[[2]string]QueryBlock
+}
+
+// NewQueryBlocklist creates an empty QueryBlocklist.
+func NewQueryBlocklist() *QueryBlocklist {
+	return &QueryBlocklist{
+		blocks: make(map[[2]string]QueryBlock),
+	}
+}
+
+// Block places the block, replacing the existing one with the same table and fingerprint.
+func (l *QueryBlocklist) Block(block QueryBlock) {
+	l.Lock()
+	defer l.Unlock()
+	l.blocks[[2]string{block.Table, block.Fingerprint}] = block
+}
+
+// Unblock removes the block with the table and fingerprint, returns false if not found.
+func (l *QueryBlocklist) Unblock(table, fingerprint string) bool {
+	l.Lock()
+	defer l.Unlock()
+	key := [2]string{table, fingerprint}
+	if _, ok := l.blocks[key]; !ok {
+		return false
+	}
+	delete(l.blocks, key)
+	return true
+}
+
+// List returns blocks not expired yet sorted by table and fingerprint.
+func (l *QueryBlocklist) List() []QueryBlock {
+	l.Lock()
+	defer l.Unlock()
+	l.purgeExpired()
+	blocks := make([]QueryBlock, 0, len(l.blocks))
+	for _, block := range l.blocks {
+		blocks = append(blocks, block)
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		if blocks[i].Table != blocks[j].Table {
+			return blocks[i].Table < blocks[j].Table
+		}
+		return blocks[i].Fingerprint < blocks[j].Fingerprint
+	})
+	return blocks
+}
+
+// Check returns the unexpired block matching the query if any. The fingerprint forwarded
+// by broker is used if present.
+func (l *QueryBlocklist) Check(query *AQLQuery) *QueryBlock {
+	l.RLock()
+	empty := len(l.blocks) == 0
+	l.RUnlock()
+	if empty {
+		return nil
+	}
+
+	fingerprint := query.Fingerprint
+	if fingerprint == "" {
+		fingerprint = QueryFingerprint(query)
+	}
+	now := utils.Now().Unix()
+	l.RLock()
+	defer l.RUnlock()
+	for _, block := range l.blocks {
+		if block.ExpiresAt > now && block.Matches(query.Table, fingerprint) {
+			return &block
+		}
+	}
+	return nil
+}
+
+// purgeExpired drops expired blocks, caller should hold the write lock.
+func (l *QueryBlocklist) purgeExpired() {
+	now := utils.Now().Unix()
+	for key, block := range l.blocks {
+		if block.ExpiresAt <= now {
+			delete(l.blocks, key)
+		}
+	}
+}
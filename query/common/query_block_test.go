@@ -0,0 +1,93 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"time"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/utils"
+)
+
+var _ = ginkgo.Describe("query block", func() {
+	ginkgo.AfterEach(func() {
+		utils.ResetClockImplementation()
+	})
+
+	ginkgo.It("QueryFingerprint should ignore literals, time range and limit", func() {
+		q1 := &AQLQuery{
+			Table:      "trips",
+			Dimensions: []Dimension{{Expr: "city_id"}},
+			Measures:   []Measure{{Expr: "count(*)"}},
+			Filters:    []string{"status = 'completed'", "fare > 10"},
+			TimeFilter: TimeFilter{Column: "request_at", From: "-1d"},
+		}
+		q2 := &AQLQuery{
+			Table:      "trips",
+			Dimensions: []Dimension{{Expr: "city_id"}},
+			Measures:   []Measure{{Expr: "COUNT(*)"}},
+			Filters:    []string{"fare  > 20.5", "status = \"canceled\""},
+			TimeFilter: TimeFilter{Column: "request_at", From: "-7d", To: "now"},
+			Limit:      10,
+			Shards:     []int{1},
+		}
+		Ω(QueryFingerprint(q1)).Should(Equal(QueryFingerprint(q2)))
+		Ω(QueryFingerprint(q1)).Should(HaveLen(16))
+
+		q2.Dimensions[0].Expr = "city_id2"
+		Ω(QueryFingerprint(q1)).ShouldNot(Equal(QueryFingerprint(q2)))
+		q2.Dimensions[0].Expr = "city_id"
+		q2.Table = "trips2"
+		Ω(QueryFingerprint(q1)).ShouldNot(Equal(QueryFingerprint(q2)))
+	})
+
+	ginkgo.It("QueryBlocklist should work", func() {
+		utils.SetCurrentTime(time.Unix(1000, 0))
+		q := &AQLQuery{
+			Table:    "trips",
+			Measures: []Measure{{Expr: "count(*)"}},
+		}
+		other := &AQLQuery{
+			Table:    "trips",
+			Measures: []Measure{{Expr: "sum(fare)"}},
+		}
+		l := NewQueryBlocklist()
+		Ω(l.Check(q)).Should(BeNil())
+
+		block := QueryBlock{Table: "trips", Fingerprint: QueryFingerprint(q), Reason: "bad", ExpiresAt: 1100}
+		l.Block(block)
+		Ω(l.Check(q)).Should(Equal(&block))
+		Ω(l.Check(other)).Should(BeNil())
+		// fingerprint forwarded by broker takes precedence.
+		other.Fingerprint = block.Fingerprint
+		Ω(l.Check(other)).Should(Equal(&block))
+		other.Fingerprint = ""
+
+		tableBlock := QueryBlock{Table: "trips", ExpiresAt: 1050}
+		l.Block(tableBlock)
+		Ω(l.Check(other)).Should(Equal(&tableBlock))
+		Ω(l.List()).Should(Equal([]QueryBlock{tableBlock, block}))
+
+		utils.SetCurrentTime(time.Unix(1050, 0))
+		Ω(l.Check(other)).Should(BeNil())
+		Ω(l.List()).Should(Equal([]QueryBlock{block}))
+
+		Ω(l.Unblock("trips", "")).Should(BeFalse())
+		Ω(l.Unblock("trips", block.Fingerprint)).Should(BeTrue())
+		Ω(l.Check(q)).Should(BeNil())
+		Ω(l.List()).Should(BeEmpty())
+	})
+})
@@ -0,0 +1,85 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package common
+
+import (
+	"context"
+	"sync"
+)
+
+// ScanStatsHeaderKey is the response header (or trailer for streamed responses) carrying
+// json ScanStats of the queries processed by a datanode.
+const ScanStatsHeaderKey = "X-Ares-Scan-Stats"
+
+// ScanStats describes how much data a query scanned and how much was pruned.
+type ScanStats struct {
+	// Number of shards requested and number of shards where all batches were skipped.
+	ShardsRequested int `json:"shardsRequested"`
+	ShardsPruned    int `json:"shardsPruned"`
+	// Number of batches processed and number of batches skipped by time range, min/max
+	// of live batches or being empty.
+	BatchesScanned int `json:"batchesScanned"`
+	BatchesSkipped int `json:"batchesSkipped"`
+	// Number of bytes transferred to devices and seconds kernels run on devices, accounted
+	// against query quotas by broker.
+	BytesScanned  int64   `json:"bytesScanned,omitempty"`
+	DeviceSeconds float64 `json:"deviceSeconds,omitempty"`
+	// Number of bytes of datanode responses, only set by broker.
+	BytesTransferred int `json:"bytesTransferred,omitempty"`
+}
+
+// Add adds other stats to the stats.
+func (s *ScanStats) Add(other ScanStats) {
+	s.ShardsRequested += other.ShardsRequested
+	s.ShardsPruned += other.ShardsPruned
+	s.BatchesScanned += other.BatchesScanned
+	s.BatchesSkipped += other.BatchesSkipped
+	s.BytesScanned += other.BytesScanned
+	s.DeviceSeconds += other.DeviceSeconds
+	s.BytesTransferred += other.BytesTransferred
+}
+
+// ScanStatsCollector collects scan stats from datanode responses. It's safe for concurrent use.
+type ScanStatsCollector struct {
+	sync.Mutex
+	stats ScanStats
+}
+
+type scanStatsContextKey struct{}
+
+// WithScanStatsCollector returns a copy of ctx which carries the collector
+// for datanode query client to report scan stats to.
+func WithScanStatsCollector(ctx context.Context, collector *ScanStatsCollector) context.Context {
+	return context.WithValue(ctx, scanStatsContextKey{}, collector)
+}
+
+// ScanStatsCollectorFromContext returns the collector carried by ctx, nil if none.
+func ScanStatsCollectorFromContext(ctx context.Context) *ScanStatsCollector {
+	collector, _ := ctx.Value(scanStatsContextKey{}).(*ScanStatsCollector)
+	return collector
+}
+
+// Add adds scan stats of a datanode response.
+func (c *ScanStatsCollector) Add(stats ScanStats) {
+	c.Lock()
+	defer c.Unlock()
+	c.stats.Add(stats)
+}
+
+// Result returns the collected scan stats.
+func (c *ScanStatsCollector) Result() ScanStats {
+	c.Lock()
+	defer c.Unlock()
+	return c.stats
+}
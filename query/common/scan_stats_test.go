@@ -0,0 +1,44 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package common
+
+import (
+	"context"
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("scan stats", func() {
+	ginkgo.It("ScanStatsCollector should work", func() {
+		Ω(ScanStatsCollectorFromContext(context.Background())).Should(BeNil())
+
+		collector := &ScanStatsCollector{}
+		ctx := WithScanStatsCollector(context.Background(), collector)
+		Ω(ScanStatsCollectorFromContext(ctx)).Should(BeIdenticalTo(collector))
+		Ω(collector.Result()).Should(Equal(ScanStats{}))
+
+		collector.Add(ScanStats{ShardsRequested: 2, ShardsPruned: 1, BatchesScanned: 10, BatchesSkipped: 5, BytesTransferred: 100,
+			BytesScanned: 1000, DeviceSeconds: 0.5})
+		collector.Add(ScanStats{ShardsRequested: 1, BatchesScanned: 3, BytesTransferred: 20, BytesScanned: 200, DeviceSeconds: 0.25})
+		Ω(collector.Result()).Should(Equal(ScanStats{
+			ShardsRequested:  3,
+			ShardsPruned:     1,
+			BatchesScanned:   13,
+			BatchesSkipped:   5,
+			BytesTransferred: 120,
+			BytesScanned:     1200,
+			DeviceSeconds:    0.75,
+		}))
+	})
+})
@@ -0,0 +1,75 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ShardCoverageHeaderKey is the response header carrying comma separated coverage tokens of
+// table shards scanned by a datanode, so that broker can detect partial results covering the
+// same shards, eg. when both the old and new owner serve a shard during resharding.
+const ShardCoverageHeaderKey = "X-Ares-Shard-Coverage"
+
+// CoverageToken returns the coverage token of the table shard.
+func CoverageToken(table string, shard int) string {
+	return fmt.Sprintf("%s/%d", table, shard)
+}
+
+// ParseCoverageTokens parses coverage tokens in ShardCoverageHeaderKey header.
+func ParseCoverageTokens(header string) []string {
+	if header == "" {
+		return nil
+	}
+	return strings.Split(header, ",")
+}
+
+// ShardCoverageCollector collects coverage tokens from a datanode response. It's safe for
+// concurrent use.
+type ShardCoverageCollector struct {
+	sync.Mutex
+	tokens []string
+}
+
+type shardCoverageContextKey struct{}
+
+// WithShardCoverageCollector returns a copy of ctx which carries the collector
+// for datanode query client to report coverage tokens to.
+func WithShardCoverageCollector(ctx context.Context, collector *ShardCoverageCollector) context.Context {
+	return context.WithValue(ctx, shardCoverageContextKey{}, collector)
+}
+
+// ShardCoverageCollectorFromContext returns the collector carried by ctx, nil if none.
+func ShardCoverageCollectorFromContext(ctx context.Context) *ShardCoverageCollector {
+	collector, _ := ctx.Value(shardCoverageContextKey{}).(*ShardCoverageCollector)
+	return collector
+}
+
+// Add adds coverage tokens of a datanode response.
+func (c *ShardCoverageCollector) Add(tokens ...string) {
+	c.Lock()
+	defer c.Unlock()
+	c.tokens = append(c.tokens, tokens...)
+}
+
+// Result returns the collected coverage tokens.
+func (c *ShardCoverageCollector) Result() []string {
+	c.Lock()
+	defer c.Unlock()
+	return c.tokens
+}
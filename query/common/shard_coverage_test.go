@@ -0,0 +1,38 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package common
+
+import (
+	"context"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("shard coverage", func() {
+	ginkgo.It("ParseCoverageTokens should work", func() {
+		Ω(ParseCoverageTokens("")).Should(BeNil())
+		Ω(ParseCoverageTokens(CoverageToken("t", 1))).Should(Equal([]string{"t/1"}))
+		Ω(ParseCoverageTokens("t/0,t/1")).Should(Equal([]string{"t/0", "t/1"}))
+	})
+
+	ginkgo.It("ShardCoverageCollector should work", func() {
+		Ω(ShardCoverageCollectorFromContext(context.Background())).Should(BeNil())
+		collector := &ShardCoverageCollector{}
+		ctx := WithShardCoverageCollector(context.Background(), collector)
+		ShardCoverageCollectorFromContext(ctx).Add("t/0", "t/1")
+		ShardCoverageCollectorFromContext(ctx).Add("t/2")
+		Ω(collector.Result()).Should(Equal([]string{"t/0", "t/1", "t/2"}))
+	})
+})
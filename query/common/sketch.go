@@ -0,0 +1,204 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+
+	"github.com/uber/aresdb/utils"
+)
+
+// SketchType is the type tag of a sketch in its serialized form.
+type SketchType uint8
+
+// Sketch types, tags must never be reused since they are part of the wire format.
+const (
+	// SketchTypeHLL is the type tag of hyperloglog sketches.
+	SketchTypeHLL SketchType = 1
+)
+
+// sketchJSONKey is the key of serialized sketches in json results.
+const sketchJSONKey = "sketch"
+
+// Sketch is a mergeable summary of measure values, computed by datanodes for each group and
+// merged by broker before the final estimate is computed.
+type Sketch interface {
+	// SketchType returns the type tag of the sketch.
+	SketchType() SketchType
+	// MergeSketch merges the other sketch of the same type into this one and returns the merged sketch.
+	MergeSketch(other Sketch) (Sketch, error)
+	// Estimate computes the measure value from the sketch.
+	Estimate() float64
+	// EncodeSketch encodes the sketch into the payload of its serialized form.
+	EncodeSketch() []byte
+}
+
+// SketchCodec decodes payloads of serialized sketches of a sketch type.
+type SketchCodec struct {
+	// name of the sketch type used in logs and errors.
+	Name string
+	// Decode decodes the payload encoded by Sketch.EncodeSketch.
+	Decode func(payload []byte) (Sketch, error)
+}
+
+var (
+	sketchCodecsLock sync.RWMutex
+	sketchCodecs     = make(map[SketchType]SketchCodec)
+)
+
+func init() {
+	RegisterSketchCodec(SketchTypeHLL, SketchCodec{Name: "hll", Decode: decodeHLLSketch})
+}
+
+// RegisterSketchCodec registers the codec of the sketch type, so that sketches of the type can be
+// transferred between datanodes and broker and merged by broker without changing the transport.
+// Panics if the sketch type is already registered.
+func RegisterSketchCodec(sketchType SketchType, codec SketchCodec) {
+	sketchCodecsLock.Lock()
+	defer sketchCodecsLock.Unlock()
+	if existing, ok := sketchCodecs[sketchType]; ok {
+		panic(utils.StackError(nil, "sketch type %d is already registered by %s", sketchType, existing.Name))
+	}
+	sketchCodecs[sketchType] = codec
+}
+
+// GetSketchCodec returns the codec registered for the sketch type.
+func GetSketchCodec(sketchType SketchType) (codec SketchCodec, ok bool) {
+	sketchCodecsLock.RLock()
+	defer sketchCodecsLock.RUnlock()
+	codec, ok = sketchCodecs[sketchType]
+	return
+}
+
+// EncodeSketch serializes the sketch as its type tag followed by its payload.
+func EncodeSketch(sketch Sketch) []byte {
+	payload := sketch.EncodeSketch()
+	data := make([]byte, 1+len(payload))
+	data[0] = byte(sketch.SketchType())
+	copy(data[1:], payload)
+	return data
+}
+
+// DecodeSketch deserializes the sketch serialized by EncodeSketch with the codec registered for
+// its type tag.
+func DecodeSketch(data []byte) (Sketch, error) {
+	if len(data) == 0 {
+		return nil, utils.StackError(nil, "empty sketch")
+	}
+	codec, ok := GetSketchCodec(SketchType(data[0]))
+	if !ok {
+		return nil, utils.StackError(nil, "unknown sketch type %d", data[0])
+	}
+	sketch, err := codec.Decode(data[1:])
+	if err != nil {
+		return nil, utils.StackError(err, "failed to decode %s sketch", codec.Name)
+	}
+	return sketch, nil
+}
+
+// MarshalSketchJSON serializes the sketch in json results as {"sketch": "<base64 of EncodeSketch>"}.
+func MarshalSketchJSON(sketch Sketch) ([]byte, error) {
+	return json.Marshal(map[string]string{
+		sketchJSONKey: base64.StdEncoding.EncodeToString(EncodeSketch(sketch)),
+	})
+}
+
+// DecodeSketchResult replaces sketches serialized by MarshalSketchJSON in the result decoded from
+// json with decoded sketches in place.
+func DecodeSketchResult(result AQLQueryResult) error {
+	_, err := decodeSketchRecursive(map[string]interface{}(result))
+	return err
+}
+
+func decodeSketchRecursive(value interface{}) (interface{}, error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return value, nil
+	}
+	if encoded, ok := m[sketchJSONKey].(string); ok && len(m) == 1 {
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, utils.StackError(err, "invalid sketch %s", encoded)
+		}
+		return DecodeSketch(data)
+	}
+	for k, v := range m {
+		decoded, err := decodeSketchRecursive(v)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = decoded
+	}
+	return m, nil
+}
+
+// ComputeSketchResult replaces sketches in the result with their estimates in place.
+func ComputeSketchResult(result AQLQueryResult) AQLQueryResult {
+	computeSketchRecursive(map[string]interface{}(result))
+	return result
+}
+
+func computeSketchRecursive(m map[string]interface{}) {
+	for k, v := range m {
+		switch value := v.(type) {
+		case Sketch:
+			m[k] = value.Estimate()
+		case map[string]interface{}:
+			computeSketchRecursive(value)
+		}
+	}
+}
+
+// SketchType implements Sketch.
+func (hll HLL) SketchType() SketchType {
+	return SketchTypeHLL
+}
+
+// MergeSketch implements Sketch.
+func (hll HLL) MergeSketch(other Sketch) (Sketch, error) {
+	otherHLL, ok := other.(HLL)
+	if !ok {
+		return nil, utils.StackError(nil, "cannot merge sketch type %d into hll", other.SketchType())
+	}
+	hll.Merge(otherHLL)
+	return hll, nil
+}
+
+// Estimate implements Sketch.
+func (hll HLL) Estimate() float64 {
+	return hll.Compute()
+}
+
+// EncodeSketch implements Sketch.
+func (hll HLL) EncodeSketch() []byte {
+	return hll.Encode()
+}
+
+// MarshalJSON serializes the hll as a sketch.
+func (hll HLL) MarshalJSON() ([]byte, error) {
+	return MarshalSketchJSON(hll)
+}
+
+func decodeHLLSketch(payload []byte) (Sketch, error) {
+	if len(payload) != 1<<hllP && len(payload)%3 != 0 {
+		return nil, utils.StackError(nil, "invalid hll payload of %d bytes", len(payload))
+	}
+	var hll HLL
+	// payload may be reused by callers.
+	hll.Decode(append([]byte(nil), payload...))
+	return hll, nil
+}
@@ -0,0 +1,77 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("sketch", func() {
+	newHLL := func(registers ...HLLRegister) HLL {
+		hll := HLL{}
+		for _, register := range registers {
+			hll.Set(register.Index, register.Rho)
+		}
+		return hll
+	}
+
+	ginkgo.It("EncodeSketch and DecodeSketch should work", func() {
+		hll := newHLL(HLLRegister{Index: 1, Rho: 2}, HLLRegister{Index: 300, Rho: 5})
+		data := EncodeSketch(hll)
+		Ω(data[0]).Should(Equal(byte(SketchTypeHLL)))
+
+		sketch, err := DecodeSketch(data)
+		Ω(err).Should(BeNil())
+		Ω(sketch).Should(Equal(hll))
+
+		_, err = DecodeSketch([]byte{})
+		Ω(err).ShouldNot(BeNil())
+		_, err = DecodeSketch([]byte{255, 1, 2, 3})
+		Ω(err.Error()).Should(ContainSubstring("unknown sketch type 255"))
+		_, err = DecodeSketch([]byte{byte(SketchTypeHLL), 1, 2})
+		Ω(err.Error()).Should(ContainSubstring("failed to decode hll sketch"))
+	})
+
+	ginkgo.It("RegisterSketchCodec should reject registered sketch types", func() {
+		Ω(func() {
+			RegisterSketchCodec(SketchTypeHLL, SketchCodec{Name: "another hll", Decode: decodeHLLSketch})
+		}).Should(Panic())
+	})
+
+	ginkgo.It("sketches should survive json results", func() {
+		hll := newHLL(HLLRegister{Index: 1, Rho: 2})
+		bs, err := json.Marshal(AQLQueryResult{"NULL": map[string]interface{}{"1": hll}})
+		Ω(err).Should(BeNil())
+
+		var result AQLQueryResult
+		Ω(json.Unmarshal(bs, &result)).Should(BeNil())
+		Ω(DecodeSketchResult(result)).Should(BeNil())
+		Ω(result).Should(Equal(AQLQueryResult{"NULL": map[string]interface{}{"1": hll}}))
+
+		Ω(DecodeSketchResult(AQLQueryResult{"1": map[string]interface{}{"sketch": "?"}})).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("MergeSketch and ComputeSketchResult should work", func() {
+		merged, err := newHLL(HLLRegister{Index: 1, Rho: 2}).MergeSketch(newHLL(HLLRegister{Index: 2, Rho: 1}))
+		Ω(err).Should(BeNil())
+		Ω(merged.(HLL).NonZeroRegisters).Should(Equal(uint16(2)))
+
+		result := ComputeSketchResult(AQLQueryResult{"NULL": map[string]interface{}{"1": merged, "2": 1.0}})
+		Ω(result).Should(Equal(AQLQueryResult{"NULL": map[string]interface{}{"1": 2.0, "2": 1.0}}))
+	})
+})
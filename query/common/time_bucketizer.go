@@ -53,9 +53,11 @@ type TimeDimensionMeta struct {
 	TimeUnit        string
 	IsTimezoneTable bool
 	TimeZone        *time.Location
-	DSTSwitchTs     int64
 	FromOffset      int
 	ToOffset        int
+	// utc offsets within the time range if the offset changes within the range, which
+	// supersede FromOffset and ToOffset.
+	TimezoneTransitions *utils.TimezoneTransitions
 }
 
 // TimeSeriesBucketizer is the helper struct to express parsed time bucketizer, see comment below
@@ -22,6 +22,9 @@ import (
 	"time"
 )
 
+// numbers no larger than this are not treated as timestamps in time filter expressions.
+const maxNonTimestampTimeFilterNumber = 9999999
+
 var timeUnitMap = map[string]string{
 	"year":         "y",
 	"quarter":      "q",
@@ -241,7 +244,7 @@ func parseAbsoluteTime(dateExpr, timeExpr string, location *time.Location) (star
 func parseTimeFilterExpression(expression string, now time.Time) (start, end time.Time, unit string, err error) {
 	start, end = now, now
 	unit = "m"
-	if expression == "now" {
+	if expression == "now" || expression == "now()" {
 		unit = "s"
 		return
 	}
@@ -367,6 +370,24 @@ func ParseTimeFilter(filter TimeFilter, loc *time.Location, now time.Time) (from
 	return
 }
 
+// ResolveTimeFilter resolves relative expressions (e.g. now(), -7d, this quarter) in the time
+// filter against now in loc into seconds since epoch, so that the resolved filter selects the
+// same time range no matter when it's parsed again. `to` defaults to now if `from` is present.
+func ResolveTimeFilter(filter TimeFilter, loc *time.Location, now time.Time) (TimeFilter, error) {
+	from, to, err := ParseTimeFilter(filter, loc, now)
+	if err != nil {
+		return filter, err
+	}
+	resolved := filter
+	if from != nil && from.Time.Unix() > maxNonTimestampTimeFilterNumber {
+		resolved.From = strconv.FormatInt(from.Time.Unix(), 10)
+	}
+	if to != nil && to.Time.Unix() > maxNonTimestampTimeFilterNumber {
+		resolved.To = strconv.FormatInt(to.Time.Unix(), 10)
+	}
+	return resolved, nil
+}
+
 // CreateTimeFilterExpr creates time filter expr
 func CreateTimeFilterExpr(expression expr.Expr, from, to *AlignedTime) (fromExpr, toExpr expr.Expr) {
 	if from != nil && from.Unit != "" {
@@ -43,6 +43,7 @@ var _ = ginkgo.Describe("Test", func() {
 	ginkgo.It("Works", func() {
 		testCases := []testCase{
 			{expectedFrom: "2016-03-16T00:24:26-04:00", expectedTo: "2016-03-16T00:24:26-04:00", expectedUnit: "s", expression: "now"},
+			{expectedFrom: "2016-03-16T00:24:26-04:00", expectedTo: "2016-03-16T00:24:26-04:00", expectedUnit: "s", expression: "now()"},
 			{expectedFrom: "2016-01-01T00:00:00-05:00", expectedTo: "2017-01-01T00:00:00-05:00", expectedUnit: "y", expression: "this year"},
 			{expectedFrom: "2016-01-01T00:00:00-05:00", expectedTo: "2017-01-01T00:00:00-05:00", expectedUnit: "y", expression: "0y"},
 			{expectedFrom: "2016-01-01T00:00:00-05:00", expectedTo: "2016-04-01T00:00:00-04:00", expectedUnit: "q", expression: "this quarter"}, // daylight saving begins
@@ -152,6 +153,33 @@ var _ = ginkgo.Describe("Test", func() {
 		Ω(toExpr.String()).Should(Equal("request_at < 1451633400"))
 	})
 
+	ginkgo.It("ResolveTimeFilter should resolve relative expressions into timestamps", func() {
+		filter, err := ResolveTimeFilter(TimeFilter{Column: "request_at", From: "-7d", To: "now()"}, location, now)
+		Ω(err).Should(BeNil())
+		// 2016-03-08 00:00:00 America/Los_Angeles.
+		Ω(filter).Should(Equal(TimeFilter{Column: "request_at", From: "1457424000", To: "1458102266"}))
+
+		// resolved filters stay the same when parsed later or in another timezone.
+		from, to, err := ParseTimeFilter(filter, time.UTC, now.Add(time.Hour))
+		Ω(err).Should(BeNil())
+		Ω(from.Time.Unix()).Should(Equal(int64(1457424000)))
+		Ω(to.Time.Unix()).Should(Equal(int64(1458102266)))
+
+		filter, err = ResolveTimeFilter(TimeFilter{Column: "request_at", From: "this quarter"}, location, now)
+		Ω(err).Should(BeNil())
+		Ω(filter).Should(Equal(TimeFilter{Column: "request_at", From: "1451635200", To: "1458102266"}))
+		filter, err = ResolveTimeFilter(TimeFilter{Column: "request_at", From: "this quarter"}, time.UTC, now)
+		Ω(err).Should(BeNil())
+		Ω(filter.From).Should(Equal("1451606400"))
+
+		filter, err = ResolveTimeFilter(TimeFilter{}, location, now)
+		Ω(err).Should(BeNil())
+		Ω(filter).Should(Equal(TimeFilter{}))
+
+		_, err = ResolveTimeFilter(TimeFilter{Column: "request_at", From: "future"}, location, now)
+		Ω(err).ShouldNot(BeNil())
+	})
+
 	ginkgo.It("Corrects America/Sao_Paulo daylight saving start issue", func() {
 		loc, _ := time.LoadLocation("America/Sao_Paulo")
 		t := time.Date(2016, 10, 16, 13, 23, 0, 0, loc)
@@ -0,0 +1,125 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"github.com/uber/aresdb/query/expr"
+)
+
+// Null semantics of operators on device:
+//   arithmetic, bitwise, comparison and date operators: null if any input is null.
+//   NOT: null for null input.
+//   OR: true if either input is true, otherwise null if any input is null (SQL semantics).
+//   AND: null if any input is null (legacy semantics).
+//   IS NULL, IS NOT NULL: never null.
+//   NULL literal: null constant.
+//
+// Without tri-valued logic, x IS TRUE and x IS FALSE are rewritten to NOT(NOT(x)) and NOT(x)
+// which are null instead of false for null x, a AND b is null instead of false for null a
+// and false b, and comparisons with a NULL literal are compiled as comparisons with an
+// arbitrary value. Filters and measure filters skip rows evaluated to either null or false,
+// so these only differ when the result is negated, tested for null or used as a dimension or
+// measure. In tri-valued logic mode, these operators are rewritten to follow standard SQL
+// semantics:
+//   x IS TRUE  => NOT(NOT(x) OR x IS NULL)
+//   x IS FALSE => NOT(x OR x IS NULL)
+//   a AND b    => NOT(NOT(a) OR NOT(b))
+//   x op NULL  => NULL, for op in =, !=, <, <=, > and >=
+//   NOT NULL   => NULL
+// and x IN (v1, v2, ...) is expanded to x = v1 OR x = v2 OR ..., which is true if x matches
+// any value, otherwise null if x or any value is null. OR and NOT already follow SQL semantics
+// on device and are kept as is.
+
+// RewriteTriValuedIs rewrites IS_TRUE and IS_FALSE unary expressions so that they are false
+// instead of null for null inputs. Child of the expression must have been rewritten already.
+func RewriteTriValuedIs(e *expr.UnaryExpr) expr.Expr {
+	child := expr.Cast(e.Expr, expr.Boolean)
+	nullCheck := child
+	if _, ok := child.(*expr.BinaryExpr); ok {
+		// keep the operator precedence when the expression is serialized.
+		nullCheck = &expr.ParenExpr{Expr: child}
+	}
+
+	lhs := child
+	if e.Op == expr.IS_TRUE {
+		lhs = &expr.UnaryExpr{Op: expr.NOT, Expr: child, ExprType: expr.Boolean}
+	}
+	return &expr.UnaryExpr{
+		Op: expr.NOT,
+		Expr: &expr.BinaryExpr{
+			Op:       expr.OR,
+			LHS:      lhs,
+			RHS:      &expr.UnaryExpr{Op: expr.IS_NULL, Expr: nullCheck, ExprType: expr.Boolean},
+			ExprType: expr.Boolean,
+		},
+		ExprType: expr.Boolean,
+	}
+}
+
+// RewriteKleeneAnds rewrites AND operators in the expression to NOT(NOT(a) OR NOT(b)) which
+// is false instead of null for null a and false b. It should be applied after top level
+// AND operators of filters are normalized into separate filters.
+func RewriteKleeneAnds(e expr.Expr) expr.Expr {
+	return expr.Rewrite(kleeneAndRewriter{}, e)
+}
+
+type kleeneAndRewriter struct{}
+
+// Rewrite implements expr.Rewriter.
+func (kleeneAndRewriter) Rewrite(e expr.Expr) expr.Expr {
+	binary, ok := e.(*expr.BinaryExpr)
+	if !ok || binary.Op != expr.AND {
+		return e
+	}
+	return &expr.UnaryExpr{
+		Op: expr.NOT,
+		Expr: &expr.BinaryExpr{
+			Op:       expr.OR,
+			LHS:      &expr.UnaryExpr{Op: expr.NOT, Expr: binary.LHS, ExprType: expr.Boolean},
+			RHS:      &expr.UnaryExpr{Op: expr.NOT, Expr: binary.RHS, ExprType: expr.Boolean},
+			ExprType: expr.Boolean,
+		},
+		ExprType: expr.Boolean,
+	}
+}
+
+// RewriteTriValuedNull rewrites comparisons and NOT with a NULL literal operand to NULL, and
+// returns false if the expression is kept as is. Children of the expression must have been
+// rewritten already.
+func RewriteTriValuedNull(e expr.Expr) (expr.Expr, bool) {
+	switch e := e.(type) {
+	case *expr.UnaryExpr:
+		if e.Op == expr.NOT && IsNullLiteral(e.Expr) {
+			return &expr.NullLiteral{}, true
+		}
+	case *expr.BinaryExpr:
+		switch e.Op {
+		case expr.EQ, expr.NEQ, expr.LT, expr.LTE, expr.GT, expr.GTE:
+			if IsNullLiteral(e.LHS) || IsNullLiteral(e.RHS) {
+				return &expr.NullLiteral{}, true
+			}
+		}
+	}
+	return e, false
+}
+
+// IsNullLiteral returns whether the expression is a NULL literal.
+func IsNullLiteral(e expr.Expr) bool {
+	if paren, ok := e.(*expr.ParenExpr); ok {
+		return IsNullLiteral(paren.Expr)
+	}
+	_, ok := e.(*expr.NullLiteral)
+	return ok
+}
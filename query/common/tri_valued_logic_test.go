@@ -0,0 +1,298 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/query/expr"
+)
+
+// values of expressions on device, nil for null, bool for boolean and int for numeric values.
+var triBools = []interface{}{true, false, nil}
+
+func triString(v interface{}) string {
+	if v == nil {
+		return "NULL"
+	}
+	if b, ok := v.(bool); ok {
+		if b {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	return fmt.Sprint(v)
+}
+
+// evalOnDevice evaluates expressions with null semantics of the device functors.
+func evalOnDevice(e expr.Expr, values map[string]interface{}) interface{} {
+	switch e := e.(type) {
+	case *expr.VarRef:
+		return values[e.Val]
+	case *expr.NullLiteral:
+		return nil
+	case *expr.BooleanLiteral:
+		return e.Val
+	case *expr.NumberLiteral:
+		return e.Int
+	case *expr.ParenExpr:
+		return evalOnDevice(e.Expr, values)
+	case *expr.UnaryExpr:
+		v := evalOnDevice(e.Expr, values)
+		switch e.Op {
+		case expr.NOT:
+			if v == nil {
+				return nil
+			}
+			return !v.(bool)
+		case expr.IS_NULL:
+			return v == nil
+		case expr.IS_NOT_NULL:
+			return v != nil
+		}
+	case *expr.BinaryExpr:
+		l, r := evalOnDevice(e.LHS, values), evalOnDevice(e.RHS, values)
+		switch e.Op {
+		case expr.OR:
+			if l == true || r == true {
+				return true
+			}
+			if l == nil || r == nil {
+				return nil
+			}
+			return false
+		case expr.AND:
+			if l == nil || r == nil {
+				return nil
+			}
+			return l.(bool) && r.(bool)
+		case expr.EQ, expr.NEQ, expr.LT, expr.LTE, expr.GT, expr.GTE:
+			if l == nil || r == nil {
+				return nil
+			}
+			lv, rv := l.(int), r.(int)
+			return map[expr.Token]bool{
+				expr.EQ: lv == rv, expr.NEQ: lv != rv, expr.LT: lv < rv,
+				expr.LTE: lv <= rv, expr.GT: lv > rv, expr.GTE: lv >= rv,
+			}[e.Op]
+		}
+	}
+	ginkgo.Fail("unsupported expression " + e.String())
+	return nil
+}
+
+// parseTriValued parses the expression and rewrites NULL literals the way the compiler does in
+// tri-valued logic mode.
+func parseTriValued(s string) expr.Expr {
+	e, err := expr.ParseExpr(s)
+	Ω(err).Should(BeNil())
+	return expr.RewriteFunc(e, func(e expr.Expr) expr.Expr {
+		rewritten, _ := RewriteTriValuedNull(e)
+		return rewritten
+	})
+}
+
+var _ = ginkgo.Describe("tri-valued logic", func() {
+	a := &expr.VarRef{Val: "a", ExprType: expr.Boolean}
+	b := &expr.VarRef{Val: "b", ExprType: expr.Boolean}
+
+	ginkgo.It("documents null semantics of operators on device", func() {
+		not := func(e expr.Expr) expr.Expr { return &expr.UnaryExpr{Op: expr.NOT, Expr: e} }
+		for _, va := range triBools {
+			values := map[string]interface{}{"a": va}
+			Ω(evalOnDevice(&expr.UnaryExpr{Op: expr.IS_NULL, Expr: a}, values)).ShouldNot(BeNil())
+			Ω(evalOnDevice(&expr.UnaryExpr{Op: expr.IS_NOT_NULL, Expr: a}, values)).ShouldNot(BeNil())
+			if va == nil {
+				Ω(evalOnDevice(not(a), values)).Should(BeNil())
+			}
+		}
+
+		// legacy AND is null when either side is null, so NOT(a AND b) is null instead of true.
+		values := map[string]interface{}{"a": nil, "b": false}
+		and := &expr.BinaryExpr{Op: expr.AND, LHS: a, RHS: b}
+		Ω(triString(evalOnDevice(and, values))).Should(Equal("NULL"))
+		Ω(triString(evalOnDevice(not(and), values))).Should(Equal("NULL"))
+		// OR follows SQL semantics.
+		Ω(triString(evalOnDevice(&expr.BinaryExpr{Op: expr.OR, LHS: a, RHS: b}, values))).Should(Equal("NULL"))
+		values["b"] = true
+		Ω(triString(evalOnDevice(&expr.BinaryExpr{Op: expr.OR, LHS: a, RHS: b}, values))).Should(Equal("TRUE"))
+	})
+
+	ginkgo.It("RewriteTriValuedIs should follow SQL semantics", func() {
+		isTrue := RewriteTriValuedIs(&expr.UnaryExpr{Op: expr.IS_TRUE, Expr: a})
+		Ω(isTrue.String()).Should(Equal("NOT(NOT(a) OR a IS NULL)"))
+		Ω(isTrue.Type()).Should(Equal(expr.Boolean))
+		isFalse := RewriteTriValuedIs(&expr.UnaryExpr{Op: expr.IS_FALSE, Expr: a})
+		Ω(isFalse.String()).Should(Equal("NOT(a OR a IS NULL)"))
+
+		expected := map[string][2]string{
+			"TRUE":  {"TRUE", "FALSE"},
+			"FALSE": {"FALSE", "TRUE"},
+			"NULL":  {"FALSE", "FALSE"},
+		}
+		for _, va := range triBools {
+			values := map[string]interface{}{"a": va}
+			Ω([2]string{
+				triString(evalOnDevice(isTrue, values)),
+				triString(evalOnDevice(isFalse, values)),
+			}).Should(Equal(expected[triString(va)]), "a = %s", triString(va))
+		}
+
+		// compound expressions are parenthesized for null check.
+		or := &expr.BinaryExpr{Op: expr.OR, LHS: a, RHS: b, ExprType: expr.Boolean}
+		Ω(RewriteTriValuedIs(&expr.UnaryExpr{Op: expr.IS_TRUE, Expr: or}).String()).
+			Should(Equal("NOT(NOT(a OR b) OR (a OR b) IS NULL)"))
+	})
+
+	ginkgo.It("RewriteKleeneAnds should follow SQL semantics", func() {
+		and := RewriteKleeneAnds(&expr.BinaryExpr{Op: expr.AND, LHS: a, RHS: b, ExprType: expr.Boolean})
+		Ω(and.String()).Should(Equal("NOT(NOT(a) OR NOT(b))"))
+		Ω(and.Type()).Should(Equal(expr.Boolean))
+
+		// expected results of a AND b by a then b in order of TRUE, FALSE and NULL.
+		expected := [3][3]string{
+			{"TRUE", "FALSE", "NULL"},
+			{"FALSE", "FALSE", "FALSE"},
+			{"NULL", "FALSE", "NULL"},
+		}
+		for i, va := range triBools {
+			for j, vb := range triBools {
+				values := map[string]interface{}{"a": va, "b": vb}
+				Ω(triString(evalOnDevice(and, values))).Should(Equal(expected[i][j]),
+					"a = %s, b = %s", triString(va), triString(vb))
+			}
+		}
+
+		// nested ANDs are rewritten.
+		nested := RewriteKleeneAnds(&expr.UnaryExpr{
+			Op: expr.NOT,
+			Expr: &expr.BinaryExpr{
+				Op:  expr.AND,
+				LHS: a,
+				RHS: &expr.BinaryExpr{Op: expr.AND, LHS: a, RHS: b},
+			},
+		})
+		Ω(nested.String()).Should(Equal("NOT(NOT(NOT(a) OR NOT(NOT(NOT(a) OR NOT(b)))))"))
+	})
+
+	ginkgo.It("OR should follow SQL semantics", func() {
+		or := parseTriValued("a OR b")
+		Ω(or.String()).Should(Equal("a OR b"))
+
+		// expected results of a OR b by a then b in order of TRUE, FALSE and NULL.
+		expected := [3][3]string{
+			{"TRUE", "TRUE", "TRUE"},
+			{"TRUE", "FALSE", "NULL"},
+			{"TRUE", "NULL", "NULL"},
+		}
+		for i, va := range triBools {
+			for j, vb := range triBools {
+				values := map[string]interface{}{"a": va, "b": vb}
+				Ω(triString(evalOnDevice(or, values))).Should(Equal(expected[i][j]),
+					"a = %s, b = %s", triString(va), triString(vb))
+			}
+		}
+
+		// NULL literals are null constants.
+		or = parseTriValued("a OR NULL")
+		Ω(or.String()).Should(Equal("a OR NULL"))
+		for i, va := range triBools {
+			values := map[string]interface{}{"a": va}
+			Ω(triString(evalOnDevice(or, values))).Should(Equal(expected[i][2]), "a = %s", triString(va))
+		}
+	})
+
+	ginkgo.It("NOT should follow SQL semantics", func() {
+		not := parseTriValued("NOT a")
+		expected := map[string]string{"TRUE": "FALSE", "FALSE": "TRUE", "NULL": "NULL"}
+		for _, va := range triBools {
+			values := map[string]interface{}{"a": va}
+			Ω(triString(evalOnDevice(not, values))).Should(Equal(expected[triString(va)]), "a = %s", triString(va))
+		}
+
+		// NOT NULL is folded to NULL.
+		Ω(parseTriValued("NOT NULL").String()).Should(Equal("NULL"))
+		Ω(parseTriValued("NOT (NULL)").String()).Should(Equal("NULL"))
+		Ω(parseTriValued("NOT NOT NULL").String()).Should(Equal("NULL"))
+	})
+
+	ginkgo.It("comparisons should be null for null operands", func() {
+		operands := []interface{}{1, 2, nil}
+		// expected results of x op y by x then y in order of 1, 2 and NULL.
+		expected := map[string][3][3]string{
+			"=":  {{"TRUE", "FALSE", "NULL"}, {"FALSE", "TRUE", "NULL"}, {"NULL", "NULL", "NULL"}},
+			"!=": {{"FALSE", "TRUE", "NULL"}, {"TRUE", "FALSE", "NULL"}, {"NULL", "NULL", "NULL"}},
+			"<":  {{"FALSE", "TRUE", "NULL"}, {"FALSE", "FALSE", "NULL"}, {"NULL", "NULL", "NULL"}},
+			"<=": {{"TRUE", "TRUE", "NULL"}, {"FALSE", "TRUE", "NULL"}, {"NULL", "NULL", "NULL"}},
+			">":  {{"FALSE", "FALSE", "NULL"}, {"TRUE", "FALSE", "NULL"}, {"NULL", "NULL", "NULL"}},
+			">=": {{"TRUE", "FALSE", "NULL"}, {"TRUE", "TRUE", "NULL"}, {"NULL", "NULL", "NULL"}},
+		}
+		for op, results := range expected {
+			comparison := parseTriValued("x " + op + " y")
+			Ω(comparison.String()).Should(Equal("x " + op + " y"))
+			for i, vx := range operands {
+				for j, vy := range operands {
+					values := map[string]interface{}{"x": vx, "y": vy}
+					Ω(triString(evalOnDevice(comparison, values))).Should(Equal(results[i][j]),
+						"x = %s, y = %s", triString(vx), triString(vy))
+				}
+			}
+
+			// comparisons with NULL literals are folded to NULL.
+			Ω(parseTriValued("x " + op + " NULL").String()).Should(Equal("NULL"))
+			Ω(parseTriValued("NULL " + op + " x").String()).Should(Equal("NULL"))
+			Ω(parseTriValued("NOT (x " + op + " NULL)").String()).Should(Equal("NULL"))
+		}
+	})
+
+	ginkgo.It("IN should follow SQL semantics", func() {
+		// IN is expanded to ORs of equality comparisons by the compiler.
+		in := func(list ...string) expr.Expr {
+			var e expr.Expr
+			for _, value := range list {
+				eq := parseTriValued("x = " + value)
+				if e == nil {
+					e = eq
+				} else {
+					e = &expr.BinaryExpr{Op: expr.OR, LHS: e, RHS: eq}
+				}
+			}
+			return e
+		}
+		Ω(in("1", "2").String()).Should(Equal("x = 1 OR x = 2"))
+		Ω(in("1", "NULL").String()).Should(Equal("x = 1 OR NULL"))
+
+		// expected results of x IN (...) and x NOT IN (...) by x in order of 1, 3 and NULL.
+		expected := map[string][2][3]string{
+			"1, 2":    {{"TRUE", "FALSE", "NULL"}, {"FALSE", "TRUE", "NULL"}},
+			"1, NULL": {{"TRUE", "NULL", "NULL"}, {"FALSE", "NULL", "NULL"}},
+			"NULL":    {{"NULL", "NULL", "NULL"}, {"NULL", "NULL", "NULL"}},
+		}
+		for list, results := range expected {
+			e := in(strings.Split(list, ", ")...)
+			notIn := &expr.UnaryExpr{Op: expr.NOT, Expr: e}
+			for i, vx := range []interface{}{1, 3, nil} {
+				values := map[string]interface{}{"x": vx}
+				Ω([2]string{
+					triString(evalOnDevice(e, values)),
+					triString(evalOnDevice(notIn, values)),
+				}).Should(Equal([2]string{results[0][i], results[1][i]}), "x = %s, list = %s", triString(vx), list)
+			}
+		}
+	})
+})
@@ -0,0 +1,150 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uber/aresdb/memstore"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/query/expr"
+)
+
+// AnswerFromDailyRowCounts answers count(*) queries with only a day aligned time filter on
+// archived data from daily row counts maintained in metadata, without scanning any batch.
+// Queries grouped by day get the count of each day, and queries without group by or grouped by
+// constants get the total count of days in the time filter. Returns false if the query has to be
+// processed on device.
+func (qc *AQLQueryContext) AnswerFromDailyRowCounts(memStore memstore.MemStore) bool {
+	constantDims, ok := qc.isDailyRowCountQuery()
+	if !ok {
+		return false
+	}
+
+	fromDay := int32(qc.fromTime.Time.Unix() / queryCom.SecondsPerDay)
+	toDay := int32(qc.toTime.Time.Unix() / queryCom.SecondsPerDay)
+	countsByDay := make(map[int32]int)
+	for _, shardID := range qc.TableScanners[0].Shards {
+		shard, err := memStore.GetTableShard(qc.Query.Table, shardID)
+		if err != nil {
+			return false
+		}
+		counts, ok, err := shard.GetDailyRowCounts()
+		archiveStore := shard.ArchiveStore.GetCurrentVersion()
+		// counts are only exact if they are of the cutoff of the archive store version queries see.
+		ok = ok && err == nil && counts.ArchivingCutoff == archiveStore.ArchivingCutoff &&
+			counts.ArchivingCutoff >= uint32(qc.toTime.Time.Unix())
+		archiveStore.Users.Done()
+		shard.Users.Done()
+		if !ok {
+			return false
+		}
+		for day := fromDay; day < toDay; day++ {
+			countsByDay[day] += counts.Counts[day]
+		}
+	}
+
+	qc.Results = make(queryCom.AQLQueryResult)
+	if constantDims != nil {
+		total := 0
+		for _, count := range countsByDay {
+			total += count
+		}
+		// like scanning, no group is reported if no row matches.
+		if total > 0 {
+			measureValue := float64(total)
+			qc.Results.Set(constantDims, &measureValue)
+		}
+	} else {
+		meta := queryCom.TimeDimensionMeta{
+			TimeBucketizer: qc.Query.Dimensions[0].TimeBucketizer,
+			TimeZone:       qc.fixedTimezone,
+		}
+		for day, count := range countsByDay {
+			if count == 0 {
+				continue
+			}
+			dimValue := queryCom.FormatTimeDimension(int64(day)*queryCom.SecondsPerDay, meta)
+			measureValue := float64(count)
+			qc.Results.Set([]*string{&dimValue}, &measureValue)
+		}
+	}
+	qc.ShardsCovered = append(qc.ShardsCovered, qc.TableScanners[0].Shards...)
+	qc.ScanStats.ShardsRequested += len(qc.TableScanners[0].Shards)
+	qc.answeredFromDailyRowCounts = true
+	return true
+}
+
+// isDailyRowCountQuery tells whether the compiled query only counts rows in UTC with a day
+// aligned time filter on archived data, either by day or without group by. Counts are only exact
+// for whole days, so other time filters fall back to scanning. For queries without group by or
+// only grouped by integer constants, the values of the constant dimensions are also returned,
+// otherwise the query is grouped by day and nil is returned.
+func (qc *AQLQueryContext) isDailyRowCountQuery() ([]*string, bool) {
+	if qc.ReturnHLLData || qc.IsNonAggregationQuery || !qc.Query.ScanArchive() ||
+		len(qc.Query.Joins) > 0 || qc.timezoneTable.tableColumn != "" ||
+		(qc.fixedTimezone != nil && qc.fixedTimezone.String() != time.UTC.String()) ||
+		len(qc.OOPK.MainTableCommonFilters) > 0 || len(qc.OOPK.ForeignTableCommonFilters) > 0 ||
+		len(qc.OOPK.Prefilters) > 0 || qc.OOPK.geoIntersection != nil || qc.OOPK.nearestNeighbors != nil {
+		return nil, false
+	}
+
+	schema := qc.TableScanners[0].Schema
+	if !schema.Schema.IsFactTable || !schema.Schema.Config.PreAggregateDailyRowCounts {
+		return nil, false
+	}
+
+	if qc.fromTime == nil || qc.toTime == nil ||
+		qc.fromTime.Time.Unix()%queryCom.SecondsPerDay != 0 || qc.toTime.Time.Unix()%queryCom.SecondsPerDay != 0 {
+		return nil, false
+	}
+
+	measure := qc.Query.Measures[0]
+	aggregate, ok := measure.ExprParsed.(*expr.Call)
+	if !ok || strings.ToLower(aggregate.Name) != expr.CountCallName || len(measure.Filters) > 0 {
+		return nil, false
+	}
+
+	if constantDims, ok := getConstantDimensions(qc.Query.Dimensions); ok {
+		return constantDims, true
+	}
+
+	if len(qc.Query.Dimensions) != 1 {
+		return nil, false
+	}
+	dim := qc.Query.Dimensions[0]
+	if dim.TimeUnit != "" || dim.Expr != schema.Schema.Columns[0].Name {
+		return nil, false
+	}
+	bucketizer, err := queryCom.ParseRegularTimeBucketizer(dim.TimeBucketizer)
+	return nil, err == nil && bucketizer.Unit == "d" && bucketizer.Size == 1
+}
+
+// getConstantDimensions returns the values of dimensions if all of them are integer constants,
+// which group all rows together. The returned values are non nil even if there is no dimension.
+func getConstantDimensions(dimensions []queryCom.Dimension) ([]*string, bool) {
+	dimValues := make([]*string, 0, len(dimensions))
+	for _, dim := range dimensions {
+		literal, ok := dim.ExprParsed.(*expr.NumberLiteral)
+		if !ok || dim.TimeBucketizer != "" || (literal.ExprType != expr.Unsigned && literal.ExprType != expr.Signed) {
+			return nil, false
+		}
+		dimValue := strconv.Itoa(literal.Int)
+		dimValues = append(dimValues, &dimValue)
+	}
+	return dimValues, true
+}
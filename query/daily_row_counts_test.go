@@ -0,0 +1,48 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/query/expr"
+)
+
+var _ = ginkgo.Describe("daily row counts", func() {
+	ginkgo.It("getConstantDimensions should work", func() {
+		dimValues, ok := getConstantDimensions(nil)
+		Ω(ok).Should(BeTrue())
+		Ω(dimValues).ShouldNot(BeNil())
+		Ω(dimValues).Should(BeEmpty())
+
+		dimValues, ok = getConstantDimensions([]queryCom.Dimension{
+			{Expr: "1", ExprParsed: &expr.NumberLiteral{Int: 1, Expr: "1", ExprType: expr.Unsigned}},
+		})
+		Ω(ok).Should(BeTrue())
+		Ω(dimValues).Should(HaveLen(1))
+		Ω(*dimValues[0]).Should(Equal("1"))
+
+		_, ok = getConstantDimensions([]queryCom.Dimension{
+			{Expr: "1.5", ExprParsed: &expr.NumberLiteral{Val: 1.5, Expr: "1.5", ExprType: expr.Float}},
+		})
+		Ω(ok).Should(BeFalse())
+
+		_, ok = getConstantDimensions([]queryCom.Dimension{
+			{Expr: "request_at", TimeBucketizer: "day", ExprParsed: &expr.VarRef{Val: "request_at"}},
+		})
+		Ω(ok).Should(BeFalse())
+	})
+})
@@ -0,0 +1,157 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"github.com/uber/aresdb/memstore"
+	memCom "github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/query/expr"
+	"github.com/uber/aresdb/utils"
+	"unsafe"
+)
+
+// deferredColumnFetchLimit is the max limit of non-aggregation queries selecting all columns
+// whose wide columns are fetched for the selected rows only.
+const deferredColumnFetchLimit = 100
+
+// deferredColumn is a wide column not transferred to device.
+type deferredColumn struct {
+	columnID int
+	dataType memCom.DataType
+}
+
+// deferredRow is a selected row waiting for values of deferred columns.
+type deferredRow struct {
+	dimValues []*string
+	batchID   int32
+	rowIndex  uint32
+}
+
+// deferredColumnFetch stores wide columns of a non-aggregation query selecting all columns
+// with a small limit. Instead of transferring them to device for all candidate rows, rows
+// selected by the query are located by batch id and row index and values of wide columns
+// are read from host memory for these rows only.
+type deferredColumnFetch struct {
+	// deferred columns by dimension index.
+	columns map[int]deferredColumn
+	// indexes of the dimensions locating the selected rows.
+	batchIDDimIndex  int
+	rowIndexDimIndex int
+	// rows selected from the shard being processed.
+	rows []deferredRow
+}
+
+// isWideDataType returns whether values of the data type are expensive to transfer for all
+// candidate rows.
+func isWideDataType(dataType memCom.DataType) bool {
+	return memCom.IsArrayType(dataType) || dataType == memCom.GeoPoint || dataType == memCom.UUID
+}
+
+// deferWideColumns replaces wide columns expanded from the wildcard dimension of a
+// non-aggregation query with placeholders evaluated on device, and appends dimensions of batch
+// id and row index to locate the selected rows. Array columns can not be evaluated on device,
+// so they can only be selected when they can be deferred.
+func (qc *AQLQueryContext) deferWideColumns() {
+	if !qc.IsNonAggregationQuery || len(qc.wildcardDimensions) == 0 {
+		return
+	}
+
+	deferrable := qc.Query.Limit >= 0 && qc.Query.Limit <= deferredColumnFetchLimit
+	columns := make(map[int]deferredColumn)
+	for _, dimIndex := range qc.wildcardDimensions {
+		varRef, ok := qc.OOPK.Dimensions[dimIndex].(*expr.VarRef)
+		if !ok || !isWideDataType(varRef.DataType) {
+			continue
+		}
+		if !deferrable {
+			if memCom.IsArrayType(varRef.DataType) {
+				qc.Error = utils.StackError(nil,
+					"array column %s can only be selected by * with limit no more than %d",
+					varRef.Val, deferredColumnFetchLimit)
+				return
+			}
+			continue
+		}
+		columns[dimIndex] = deferredColumn{columnID: varRef.ColumnID, dataType: varRef.DataType}
+		qc.OOPK.Dimensions[dimIndex] = &expr.NumberLiteral{Expr: "0", ExprType: expr.Unsigned}
+	}
+
+	if len(columns) == 0 {
+		return
+	}
+
+	qc.deferredColumns = &deferredColumnFetch{
+		columns:          columns,
+		batchIDDimIndex:  len(qc.OOPK.Dimensions),
+		rowIndexDimIndex: len(qc.OOPK.Dimensions) + 1,
+	}
+	qc.OOPK.Dimensions = append(qc.OOPK.Dimensions,
+		qc.Rewrite(&expr.VarRef{Val: memCom.BatchIDColumnName}),
+		qc.Rewrite(&expr.VarRef{Val: memCom.RowIndexColumnName}))
+}
+
+// addRow reads the location of the row at index of the dimension vector and keeps a copy of its
+// dimension values until values of deferred columns are fetched.
+func (f *deferredColumnFetch) addRow(dimValues []*string, dimensionVector unsafe.Pointer,
+	dimOffsets map[int][2]int, index int) {
+	batchIDOffset := dimOffsets[f.batchIDDimIndex][0]
+	rowIndexOffset := dimOffsets[f.rowIndexDimIndex][0]
+	f.rows = append(f.rows, deferredRow{
+		dimValues: append([]*string(nil), dimValues...),
+		batchID:   *(*int32)(utils.MemAccess(dimensionVector, batchIDOffset+index*4)),
+		rowIndex:  *(*uint32)(utils.MemAccess(dimensionVector, rowIndexOffset+index*4)),
+	})
+}
+
+// fetchDeferredColumns reads values of deferred columns of rows selected from the shard and
+// writes these rows to results. Rows of live batches purged since being selected get nulls.
+func (qc *AQLQueryContext) fetchDeferredColumns(shard *memstore.TableShard,
+	archiveStore *memstore.ArchiveStoreVersion) {
+	f := qc.deferredColumns
+	for _, row := range f.rows {
+		if row.batchID < 0 {
+			// live batch ids start from memstore.BaseBatchID.
+			if batch := shard.LiveStore.GetBatchForRead(row.batchID); batch != nil {
+				for dimIndex, column := range f.columns {
+					row.dimValues[dimIndex] = formatDeferredValue(
+						batch.GetDataValue(int(row.rowIndex), column.columnID), column.dataType)
+				}
+				batch.RUnlock()
+			} else {
+				for dimIndex := range f.columns {
+					row.dimValues[dimIndex] = nil
+				}
+			}
+		} else if archiveStore != nil {
+			batch := archiveStore.RequestBatch(row.batchID)
+			for dimIndex, column := range f.columns {
+				vp := batch.RequestVectorParty(column.columnID)
+				vp.WaitForDiskLoad()
+				row.dimValues[dimIndex] = formatDeferredValue(vp.GetDataValueByRow(int(row.rowIndex)), column.dataType)
+				vp.Release()
+			}
+		}
+		qc.writeNonAggRow(row.dimValues)
+	}
+	f.rows = nil
+}
+
+// formatDeferredValue formats the value of a deferred column in the same way as dimension values.
+func formatDeferredValue(value memCom.DataValue, dataType memCom.DataType) *string {
+	if result, ok := value.ConvertToHumanReadable(dataType).(string); ok {
+		return &result
+	}
+	return nil
+}
@@ -0,0 +1,375 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"container/list"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/uber-go/tally"
+	"github.com/uber/aresdb/memstore"
+	memCom "github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// deviceColumnKey identifies the device copy of a slice of an archive batch column. Archive
+// batches are immutable for a given version, so the copy can be reused by later queries.
+type deviceColumnKey struct {
+	table    string
+	shard    int
+	batchID  int32
+	version  uint32
+	seqNum   uint32
+	columnID int
+	// enum ids of archived values change with compactions of the enum dictionary.
+	enumGeneration int
+	// row range after prefilter slicing.
+	startRow int
+	endRow   int
+}
+
+// deviceColumnPriority decides which cached columns are evicted first. Columns of batches
+// within the preloading days of the column are evicted after other columns, then columns
+// with lower column priority are evicted first.
+type deviceColumnPriority struct {
+	preloading bool
+	priority   int64
+}
+
+func (p deviceColumnPriority) lessThan(other deviceColumnPriority) bool {
+	if p.preloading != other.preloading {
+		return !p.preloading
+	}
+	return p.priority < other.priority
+}
+
+type deviceColumnEntry struct {
+	key      deviceColumnKey
+	column   deviceVectorPartySlice
+	bytes    int
+	priority deviceColumnPriority
+	// number of running queries using the column, pinned columns are not evicted.
+	pins    int
+	element *list.Element
+}
+
+// deviceColumnCache keeps archive batch columns transferred by queries in device memory so that
+// later queries skip the transfer. Memory of cached columns is accounted as used memory of the
+// device in DeviceManager, and columns not pinned by running queries are evicted whenever queries
+// need the memory. A new column is only admitted when enough memory can be freed by evicting
+// columns of lower or equal priority. Callers of admit and evict need to hold the write lock of
+// DeviceManager. All methods are no-op on nil cache.
+type deviceColumnCache struct {
+	sync.Mutex
+	device      int
+	capacity    int
+	cachedBytes int
+	// bytes of cached columns pinned by running queries.
+	pinnedBytes int
+	entries     map[deviceColumnKey]*deviceColumnEntry
+	// least recently used column at the back.
+	lru *list.List
+	// frees device memory of evicted columns.
+	free func(dp *devicePointer)
+}
+
+func newDeviceColumnCache(device, capacity int) *deviceColumnCache {
+	return &deviceColumnCache{
+		device:   device,
+		capacity: capacity,
+		entries:  make(map[deviceColumnKey]*deviceColumnEntry),
+		lru:      list.New(),
+		free:     deviceFreeAndSetNil,
+	}
+}
+
+// get returns the cached column and pins it until released.
+func (c *deviceColumnCache) get(key deviceColumnKey) (deviceVectorPartySlice, bool) {
+	if c == nil {
+		return deviceVectorPartySlice{}, false
+	}
+	c.Lock()
+	defer c.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		c.getCounter(utils.DeviceColumnCacheMisses).Inc(1)
+		return deviceVectorPartySlice{}, false
+	}
+	c.getCounter(utils.DeviceColumnCacheHits).Inc(1)
+	c.pin(entry)
+	c.lru.MoveToFront(entry.element)
+	return sharedDeviceColumn(entry.column), true
+}
+
+// release unpins the column got or admitted before.
+func (c *deviceColumnCache) release(key deviceColumnKey) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if entry, ok := c.entries[key]; ok && entry.pins > 0 {
+		entry.pins--
+		if entry.pins == 0 {
+			c.pinnedBytes -= entry.bytes
+		}
+	}
+}
+
+// evictableBytes returns bytes of cached columns not pinned by running queries.
+func (c *deviceColumnCache) evictableBytes() int {
+	if c == nil {
+		return 0
+	}
+	c.Lock()
+	defer c.Unlock()
+	return c.cachedBytes - c.pinnedBytes
+}
+
+// admit caches the column transferred by a query, the column is pinned by the query after
+// admission. freeMemory is the device memory not reserved by queries nor used by cached columns.
+// Returns whether the column is admitted and bytes freed by evicting other columns.
+func (c *deviceColumnCache) admit(key deviceColumnKey, column deviceVectorPartySlice, bytes int,
+	priority deviceColumnPriority, freeMemory int) (admitted bool, freed int) {
+	if c == nil || bytes <= 0 || bytes > c.capacity {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if _, exists := c.entries[key]; exists {
+		return
+	}
+
+	required := c.cachedBytes + bytes - c.capacity
+	if bytes-freeMemory > required {
+		required = bytes - freeMemory
+	}
+	if required > 0 {
+		candidates := c.evictionCandidates(func(entry *deviceColumnEntry) bool {
+			return !priority.lessThan(entry.priority)
+		})
+		evictable := 0
+		for _, entry := range candidates {
+			evictable += entry.bytes
+		}
+		if evictable < required {
+			c.getCounter(utils.DeviceColumnCacheRejections).Inc(1)
+			return
+		}
+		freed = c.evictEntries(candidates, required)
+	}
+
+	entry := &deviceColumnEntry{
+		key:      key,
+		column:   column,
+		bytes:    bytes,
+		priority: priority,
+	}
+	entry.element = c.lru.PushFront(entry)
+	c.entries[key] = entry
+	c.cachedBytes += bytes
+	c.pin(entry)
+	c.reportMemoryUsage()
+	return true, freed
+}
+
+// evict evicts unpinned columns until at least bytes are freed or no column can be evicted,
+// returns bytes freed.
+func (c *deviceColumnCache) evict(bytes int) int {
+	if c == nil || bytes <= 0 {
+		return 0
+	}
+	c.Lock()
+	defer c.Unlock()
+	return c.evictEntries(c.evictionCandidates(nil), bytes)
+}
+
+// evictionCandidates returns unpinned columns matching the filter in eviction order. Caller
+// needs to hold the lock.
+func (c *deviceColumnCache) evictionCandidates(filter func(entry *deviceColumnEntry) bool) []*deviceColumnEntry {
+	var candidates []*deviceColumnEntry
+	for element := c.lru.Back(); element != nil; element = element.Prev() {
+		entry := element.Value.(*deviceColumnEntry)
+		if entry.pins == 0 && (filter == nil || filter(entry)) {
+			candidates = append(candidates, entry)
+		}
+	}
+	// least recently used first within the same priority.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].priority.lessThan(candidates[j].priority)
+	})
+	return candidates
+}
+
+// evictEntries evicts candidates in order until at least bytes are freed. Caller needs to hold the lock.
+func (c *deviceColumnCache) evictEntries(candidates []*deviceColumnEntry, bytes int) (freed int) {
+	for _, entry := range candidates {
+		if freed >= bytes {
+			break
+		}
+		c.free(&entry.column.basePtr)
+		c.lru.Remove(entry.element)
+		delete(c.entries, entry.key)
+		c.cachedBytes -= entry.bytes
+		freed += entry.bytes
+		c.getCounter(utils.DeviceColumnCacheEvictions).Inc(1)
+	}
+	if freed > 0 {
+		c.reportMemoryUsage()
+	}
+	return
+}
+
+// pin pins the entry. Caller needs to hold the lock.
+func (c *deviceColumnCache) pin(entry *deviceColumnEntry) {
+	if entry.pins == 0 {
+		c.pinnedBytes += entry.bytes
+	}
+	entry.pins++
+}
+
+func (c *deviceColumnCache) getCounter(name utils.MetricName) tally.Counter {
+	return utils.GetRootReporter().GetChildCounter(map[string]string{
+		"device": strconv.Itoa(c.device),
+	}, name)
+}
+
+// reportMemoryUsage reports bytes of cached columns. Caller needs to hold the lock.
+func (c *deviceColumnCache) reportMemoryUsage() {
+	utils.GetRootReporter().GetChildGauge(map[string]string{
+		"device": strconv.Itoa(c.device),
+	}, utils.DeviceColumnCacheMemory).Update(float64(c.cachedBytes))
+}
+
+// sharedDeviceColumn returns the column for queries to use without owning the device memory,
+// so that queries do not free cached columns.
+func sharedDeviceColumn(column deviceVectorPartySlice) deviceVectorPartySlice {
+	column.basePtr.allocated = false
+	return column
+}
+
+// pendingDeviceColumn is a column transferred for the current batch to be admitted to column
+// cache once the transfer completes.
+type pendingDeviceColumn struct {
+	key deviceColumnKey
+	// index of the column in device slices of the batch.
+	index    int
+	bytes    int
+	priority deviceColumnPriority
+}
+
+// getColumnCache returns the column cache of the query device, nil if column cache is disabled.
+func (qc *AQLQueryContext) getColumnCache() *deviceColumnCache {
+	if qc.deviceManager == nil {
+		return nil
+	}
+	return qc.deviceManager.getColumnCache(qc.Device)
+}
+
+// cachedColumnKey returns the column cache key of the archive batch column sliced by row range.
+func cachedColumnKey(batch *memstore.ArchiveBatch, columnID, startRow, endRow int) deviceColumnKey {
+	return deviceColumnKey{
+		table:          batch.Shard.Schema.Schema.Name,
+		shard:          batch.Shard.ShardID,
+		batchID:        batch.BatchID,
+		version:        batch.Version,
+		seqNum:         batch.SeqNum,
+		columnID:       columnID,
+		startRow:       startRow,
+		endRow:         endRow,
+		enumGeneration: batch.Shard.Schema.GetEnumDictGeneration(columnID),
+	}
+}
+
+// getCachedColumn returns the cached device copy of the archive batch column and pins it until
+// the batch is executed.
+func (qc *AQLQueryContext) getCachedColumn(batch *memstore.ArchiveBatch, columnID, startRow, endRow int) (
+	deviceVectorPartySlice, bool) {
+	cache := qc.getColumnCache()
+	if cache == nil {
+		return deviceVectorPartySlice{}, false
+	}
+	key := cachedColumnKey(batch, columnID, startRow, endRow)
+	column, ok := cache.get(key)
+	if ok {
+		qc.pinnedColumns = append(qc.pinnedColumns, key)
+	}
+	return column, ok
+}
+
+// addPendingColumn records the column being transferred to device so that it can be admitted to
+// column cache after transfer completes.
+func (qc *AQLQueryContext) addPendingColumn(batch *memstore.ArchiveBatch, columnID, startRow, endRow, index int,
+	hostColumn memCom.HostVectorPartySlice) {
+	if qc.getColumnCache() == nil {
+		return
+	}
+
+	bytes := hostColumn.ValueBytes + hostColumn.NullBytes + hostColumn.CountBytes
+	if memCom.IsArrayType(hostColumn.ValueType) {
+		bytes = hostColumn.ValueBytes + hostColumn.Length*8
+	}
+
+	batch.Shard.Schema.RLock()
+	columnConfig := batch.Shard.Schema.Schema.Columns[columnID].Config
+	batch.Shard.Schema.RUnlock()
+
+	qc.pendingColumns = append(qc.pendingColumns, pendingDeviceColumn{
+		key:   cachedColumnKey(batch, columnID, startRow, endRow),
+		index: index,
+		bytes: bytes,
+		priority: deviceColumnPriority{
+			preloading: int(utils.Now().Unix()/86400)-int(batch.BatchID) < columnConfig.PreloadingDays,
+			priority:   columnConfig.Priority,
+		},
+	})
+}
+
+// admitPendingColumns admits columns transferred for the current batch to column cache. Admitted
+// columns are owned by the cache afterwards and will not be freed by the query.
+func (qc *AQLQueryContext) admitPendingColumns(deviceSlices []deviceVectorPartySlice) {
+	for _, pending := range qc.pendingColumns {
+		if qc.deviceManager.admitColumn(qc.Device, pending.key, deviceSlices[pending.index],
+			pending.bytes, pending.priority) {
+			deviceSlices[pending.index] = sharedDeviceColumn(deviceSlices[pending.index])
+			qc.pinnedColumns = append(qc.pinnedColumns, pending.key)
+		}
+	}
+	qc.pendingColumns = qc.pendingColumns[:0]
+}
+
+// releasePreviousBatchColumns unpins cached columns of the previous batch once its execution is
+// done, and tracks columns of the current batch as the previous batch.
+func (qc *AQLQueryContext) releasePreviousBatchColumns() {
+	cache := qc.getColumnCache()
+	for _, key := range qc.previousPinnedColumns {
+		cache.release(key)
+	}
+	qc.previousPinnedColumns, qc.pinnedColumns = qc.pinnedColumns, qc.previousPinnedColumns[:0]
+}
+
+// releaseCachedColumns unpins all cached columns used by the query.
+func (qc *AQLQueryContext) releaseCachedColumns() {
+	cache := qc.getColumnCache()
+	for _, key := range qc.previousPinnedColumns {
+		cache.release(key)
+	}
+	for _, key := range qc.pinnedColumns {
+		cache.release(key)
+	}
+	qc.previousPinnedColumns, qc.pinnedColumns, qc.pendingColumns = nil, nil, nil
+}
@@ -0,0 +1,125 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("device column cache", func() {
+	var cache *deviceColumnCache
+	var freed []int
+
+	column := func(bytes int) deviceVectorPartySlice {
+		return deviceVectorPartySlice{
+			basePtr: devicePointer{bytes: bytes, allocated: true},
+		}
+	}
+
+	key := func(batchID int32) deviceColumnKey {
+		return deviceColumnKey{table: "t", batchID: batchID, endRow: 10}
+	}
+
+	ginkgo.BeforeEach(func() {
+		freed = nil
+		cache = newDeviceColumnCache(0, 100)
+		cache.free = func(dp *devicePointer) {
+			freed = append(freed, dp.bytes)
+			dp.allocated = false
+		}
+	})
+
+	ginkgo.It("nil cache should work", func() {
+		var nilCache *deviceColumnCache
+		_, ok := nilCache.get(key(1))
+		Ω(ok).Should(BeFalse())
+		admitted, _ := nilCache.admit(key(1), column(10), 10, deviceColumnPriority{}, 100)
+		Ω(admitted).Should(BeFalse())
+		Ω(nilCache.evict(10)).Should(Equal(0))
+		Ω(nilCache.evictableBytes()).Should(Equal(0))
+		nilCache.release(key(1))
+	})
+
+	ginkgo.It("admit, get and release should work", func() {
+		admitted, freedBytes := cache.admit(key(1), column(40), 40, deviceColumnPriority{}, 100)
+		Ω(admitted).Should(BeTrue())
+		Ω(freedBytes).Should(Equal(0))
+		Ω(cache.evictableBytes()).Should(Equal(0))
+
+		// admitting the same column again is rejected.
+		admitted, _ = cache.admit(key(1), column(40), 40, deviceColumnPriority{}, 100)
+		Ω(admitted).Should(BeFalse())
+
+		cache.release(key(1))
+		Ω(cache.evictableBytes()).Should(Equal(40))
+
+		cached, ok := cache.get(key(1))
+		Ω(ok).Should(BeTrue())
+		Ω(cached.basePtr.allocated).Should(BeFalse())
+		Ω(cache.evictableBytes()).Should(Equal(0))
+
+		_, ok = cache.get(key(2))
+		Ω(ok).Should(BeFalse())
+
+		// pinned columns are not evicted.
+		Ω(cache.evict(40)).Should(Equal(0))
+		cache.release(key(1))
+		Ω(cache.evict(40)).Should(Equal(40))
+		Ω(freed).Should(Equal([]int{40}))
+		_, ok = cache.get(key(1))
+		Ω(ok).Should(BeFalse())
+	})
+
+	ginkgo.It("admit should evict lower priority and least recently used columns first", func() {
+		low := deviceColumnPriority{priority: 1}
+		high := deviceColumnPriority{priority: 2}
+		preloading := deviceColumnPriority{preloading: true}
+
+		cache.admit(key(1), column(30), 30, high, 1000)
+		cache.admit(key(2), column(30), 30, low, 1000)
+		cache.admit(key(3), column(30), 30, high, 1000)
+		for batchID := int32(1); batchID <= 3; batchID++ {
+			cache.release(key(batchID))
+		}
+
+		// capacity exceeded, column of lower priority is evicted first.
+		admitted, freedBytes := cache.admit(key(4), column(30), 30, high, 1000)
+		Ω(admitted).Should(BeTrue())
+		Ω(freedBytes).Should(Equal(30))
+		_, ok := cache.get(key(2))
+		Ω(ok).Should(BeFalse())
+		cache.release(key(4))
+
+		// columns of higher priority are not evicted for lower priority columns.
+		admitted, _ = cache.admit(key(5), column(30), 30, low, 1000)
+		Ω(admitted).Should(BeFalse())
+
+		// preloading columns evict least recently used column of the same priority first.
+		_, ok = cache.get(key(1))
+		Ω(ok).Should(BeTrue())
+		cache.release(key(1))
+		admitted, freedBytes = cache.admit(key(6), column(30), 30, preloading, 1000)
+		Ω(admitted).Should(BeTrue())
+		Ω(freedBytes).Should(Equal(30))
+		_, ok = cache.get(key(3))
+		Ω(ok).Should(BeFalse())
+
+		// not enough free device memory.
+		admitted, freedBytes = cache.admit(key(7), column(10), 10, preloading, 0)
+		Ω(admitted).Should(BeTrue())
+		Ω(freedBytes).Should(Equal(30))
+	})
+})
@@ -31,6 +31,10 @@ const (
 	mb2bytes                 = 1 << 20
 	defaultDeviceUtilization = 1
 	defaultTimeout           = 10
+	// default portion of usable device memory cached columns can take
+	defaultColumnCacheUtilization = 0.5
+	// default number of archive batches to prefetch next to the range of each query
+	defaultArchivePrefetchBatches = 1
 )
 
 // DeviceInfo stores memory information per device
@@ -47,6 +51,15 @@ type DeviceInfo struct {
 	FreeMemory int `json:"totalFreeMemory"`
 	// query to memory map
 	QueryMemoryUsageMap map[*queryCom.AQLQuery]int `json:"-"`
+	// archive batch columns cached on the device, nil if disabled. Memory of cached columns
+	// is not included in FreeMemory.
+	columnCache *deviceColumnCache
+}
+
+// availableMemory returns memory available for queries, including memory of cached columns
+// that can be evicted. Caller needs to hold the lock.
+func (deviceInfo *DeviceInfo) availableMemory() int {
+	return deviceInfo.FreeMemory + deviceInfo.columnCache.evictableBytes()
 }
 
 // DeviceManager has the following functionalities:
@@ -63,8 +76,12 @@ type DeviceManager struct {
 	// Max available memory, this can be used to early determined whether a query can be satisfied or not.
 	MaxAvailableMemory int `json:"maxAvailableMemory"`
 	deviceAvailable    *sync.Cond
+	// queries waiting for device.
+	queue fairQueue
 	// device choose strategy
 	strategy deviceChooseStrategy
+	// number of archive batches to prefetch next to the range of each query, 0 means disabled.
+	archivePrefetchBatches int
 }
 
 // NewDeviceManager is used to init a DeviceManager.
@@ -87,7 +104,16 @@ func NewDeviceManager(cfg common.QueryConfig) *DeviceManager {
 	deviceCount := cgoutils.GetDeviceCount()
 	utils.GetLogger().With(
 		"utilization", deviceMemoryUtilization,
-		"timeout", timeout).Info("Initialized device manager")
+		"timeout", timeout,
+		"deviceCount", deviceCount,
+		"hostMode", !cgoutils.IsDeviceMemoryImplementation()).Info("Initialized device manager")
+
+	cacheMemoryUtilization := cfg.DeviceColumnCache.MemoryUtilization
+	if cfg.DeviceColumnCache.Enable && (cacheMemoryUtilization <= 0 || cacheMemoryUtilization > 1) {
+		utils.GetLogger().With("memoryUtilization", cacheMemoryUtilization).
+			Error("Invalid device column cache memoryUtilization config, setting to default")
+		cacheMemoryUtilization = defaultColumnCacheUtilization
+	}
 
 	deviceInfos := make([]*DeviceInfo, deviceCount)
 	maxAvailableMem := 0
@@ -96,6 +122,10 @@ func NewDeviceManager(cfg common.QueryConfig) *DeviceManager {
 		if deviceInfos[device].TotalAvailableMemory >= maxAvailableMem {
 			maxAvailableMem = deviceInfos[device].TotalAvailableMemory
 		}
+		if cfg.DeviceColumnCache.Enable {
+			deviceInfos[device].columnCache = newDeviceColumnCache(device,
+				int(float32(deviceInfos[device].TotalAvailableMemory)*cacheMemoryUtilization))
+		}
 	}
 
 	deviceManager := &DeviceManager{
@@ -103,6 +133,14 @@ func NewDeviceManager(cfg common.QueryConfig) *DeviceManager {
 		DeviceInfos:        deviceInfos,
 		MaxAvailableMemory: maxAvailableMem,
 		Timeout:            timeout,
+		queue:              fairQueue{originWeights: cfg.OriginQueryWeights},
+	}
+
+	if cfg.ArchivePrefetch.Enable {
+		deviceManager.archivePrefetchBatches = cfg.ArchivePrefetch.NumBatches
+		if deviceManager.archivePrefetchBatches <= 0 {
+			deviceManager.archivePrefetchBatches = defaultArchivePrefetchBatches
+		}
 	}
 
 	deviceManager.strategy = leastQueryCountAndMemoryStrategy{
@@ -135,9 +173,10 @@ func getDeviceInfo(device int, deviceMemoryUtilization float32) *DeviceInfo {
 	return &deviceInfo
 }
 
-// FindDevice finds a device to run a given query. If a device is not found, it will wait until
-// the DeviceChoosingTimeout seconds elapse.
-func (d *DeviceManager) FindDevice(query *queryCom.AQLQuery, requiredMem int, preferredDevice int, timeout int) int {
+// FindDevice finds a device to run a given query. If a device is not found, the query is queued
+// and it will wait until the DeviceChoosingTimeout seconds elapse. Queued queries are assigned
+// devices fairly across flows according to flow weights.
+func (d *DeviceManager) FindDevice(query *queryCom.AQLQuery, flow QueryFlow, requiredMem int, preferredDevice int, timeout int) int {
 	if requiredMem > d.MaxAvailableMemory {
 		utils.GetQueryLogger().With(
 			"query", query,
@@ -157,27 +196,42 @@ func (d *DeviceManager) FindDevice(query *queryCom.AQLQuery, requiredMem int, pr
 
 	start := utils.Now()
 	d.Lock()
-	device := -1
-	for {
+	waiter := d.queue.enqueue(query, flow, requiredMem, preferredDevice)
+	if d.dispatchQueries() {
+		d.deviceAvailable.Broadcast()
+	}
+	for waiter.device < 0 {
 		if utils.Now().Sub(start) >= timeoutDuration {
 			utils.GetQueryLogger().With(
 				"query", query,
+				"table", flow.Table,
+				"origin", flow.Origin,
 				"requiredMem", requiredMem,
 				"preferredDevice", preferredDevice,
 				"timeout", timeout,
 			).Error("DeviceChoosingTimeout when choosing the device for the query")
-			break
-		}
-
-		device = d.findDevice(query, requiredMem, preferredDevice)
-		if device >= 0 {
+			d.queue.remove(waiter)
+			d.reportQueuedQueries()
 			break
 		}
 		d.deviceAvailable.Wait()
 	}
 	d.Unlock()
 	utils.GetRootReporter().GetTimer(utils.QueryWaitForMemoryDuration).Record(utils.Now().Sub(start))
-	return device
+	return waiter.device
+}
+
+// dispatchQueries assigns devices to queued queries. Returns whether any query is dispatched.
+// Caller needs to hold the write lock.
+func (d *DeviceManager) dispatchQueries() bool {
+	dispatched := d.queue.dispatch(d.findDevice)
+	d.reportQueuedQueries()
+	return dispatched
+}
+
+// reportQueuedQueries reports the number of queries waiting for device. Caller needs to hold the lock.
+func (d *DeviceManager) reportQueuedQueries() {
+	utils.GetRootReporter().GetGauge(utils.QueryWaitingForDevice).Update(float64(d.queue.size()))
 }
 
 // findDevice finds a device to run a given query according to certain strategy.If no such device can't
@@ -192,7 +246,7 @@ func (d *DeviceManager) findDevice(query *queryCom.AQLQuery, requiredMem int, pr
 
 	// try to choose preferredDevice if it meets requirements.
 	if preferredDevice >= 0 && preferredDevice < len(d.DeviceInfos) &&
-		d.DeviceInfos[preferredDevice].FreeMemory >= requiredMem {
+		d.DeviceInfos[preferredDevice].availableMemory() >= requiredMem {
 		candidateDevice = preferredDevice
 	}
 
@@ -205,16 +259,84 @@ func (d *DeviceManager) findDevice(query *queryCom.AQLQuery, requiredMem int, pr
 		return candidateDevice
 	}
 
-	// reserve memory for this query.
-	deviceInfo := d.DeviceInfos[candidateDevice]
+	d.reserveMemory(candidateDevice, query, requiredMem)
+	return candidateDevice
+}
+
+// TryFindDevice finds a device other than the excluded ones to run a given query without waiting.
+// It returns -1 if no such device has enough free memory or other queries are waiting for device,
+// so that splitting a query across devices does not delay queued queries.
+func (d *DeviceManager) TryFindDevice(query *queryCom.AQLQuery, requiredMem int, excludedDevices []int) int {
+	d.Lock()
+	defer d.Unlock()
+	if d.queue.size() > 0 {
+		return -1
+	}
+
+	candidateDevice := -1
+	for device, deviceInfo := range d.DeviceInfos {
+		if deviceInfo.availableMemory() < requiredMem || containsDevice(excludedDevices, device) {
+			continue
+		}
+		if candidateDevice < 0 || deviceInfo.QueryCount < d.DeviceInfos[candidateDevice].QueryCount {
+			candidateDevice = device
+		}
+	}
+
+	if candidateDevice >= 0 {
+		d.reserveMemory(candidateDevice, query, requiredMem)
+	}
+	return candidateDevice
+}
+
+// reserveMemory reserves memory on the device for the query, evicting cached columns if free
+// memory is not enough. Caller needs to hold the write lock.
+func (d *DeviceManager) reserveMemory(device int, query *queryCom.AQLQuery, requiredMem int) {
+	deviceInfo := d.DeviceInfos[device]
+	if deviceInfo.FreeMemory < requiredMem {
+		deviceInfo.FreeMemory += deviceInfo.columnCache.evict(requiredMem - deviceInfo.FreeMemory)
+	}
 	deviceInfo.QueryCount++
 	deviceInfo.QueryMemoryUsageMap[query] = requiredMem
 	deviceInfo.FreeMemory -= requiredMem
 	deviceInfo.reportMemoryUsage()
 
-	utils.GetLogger().Debugf("Assign device '%d' for query", candidateDevice)
+	utils.GetLogger().Debugf("Assign device '%d' for query", device)
 	utils.GetLogger().Debugf("DeviceInfo=%+v", deviceInfo)
-	return candidateDevice
+}
+
+// getColumnCache returns the column cache of the device, nil if disabled.
+func (d *DeviceManager) getColumnCache(device int) *deviceColumnCache {
+	if device < 0 || device >= len(d.DeviceInfos) {
+		return nil
+	}
+	return d.DeviceInfos[device].columnCache
+}
+
+// admitColumn caches the column transferred to the device by a query if enough memory can
+// be freed for it, the column is pinned by the query once admitted. Returns whether the
+// column is admitted.
+func (d *DeviceManager) admitColumn(device int, key deviceColumnKey, column deviceVectorPartySlice,
+	bytes int, priority deviceColumnPriority) bool {
+	d.Lock()
+	defer d.Unlock()
+	deviceInfo := d.DeviceInfos[device]
+	admitted, freed := deviceInfo.columnCache.admit(key, column, bytes, priority, deviceInfo.FreeMemory)
+	if !admitted {
+		return false
+	}
+	deviceInfo.FreeMemory += freed - bytes
+	deviceInfo.reportMemoryUsage()
+	return true
+}
+
+func containsDevice(devices []int, device int) bool {
+	for _, d := range devices {
+		if d == device {
+			return true
+		}
+	}
+	return false
 }
 
 // ReleaseReservedMemory adjust total free global memory for a given device after a query is complete
@@ -234,6 +356,8 @@ func (d *DeviceManager) ReleaseReservedMemory(device int, query *queryCom.AQLQue
 		deviceInfo.reportMemoryUsage()
 		delete(deviceInfo.QueryMemoryUsageMap, query)
 		deviceInfo.QueryCount--
+		d.dispatchQueries()
+		// wake up dispatched queries and queries to check timeout.
 		d.deviceAvailable.Broadcast()
 	}
 }
@@ -265,11 +389,12 @@ func (s leastQueryCountAndMemoryStrategy) chooseDevice(requiredMem int) int {
 	leastMemory := int(math.MaxInt64)
 	leastQueryCount := int(math.MaxInt32)
 	for device, deviceInfo := range s.deviceManager.DeviceInfos {
-		if deviceInfo.FreeMemory >= requiredMem && (deviceInfo.QueryCount < leastQueryCount ||
-			(deviceInfo.QueryCount == leastQueryCount && deviceInfo.FreeMemory <= leastMemory)) {
+		availableMemory := deviceInfo.availableMemory()
+		if availableMemory >= requiredMem && (deviceInfo.QueryCount < leastQueryCount ||
+			(deviceInfo.QueryCount == leastQueryCount && availableMemory <= leastMemory)) {
 			candidateDevice = device
 			leastQueryCount = deviceInfo.QueryCount
-			leastMemory = deviceInfo.FreeMemory
+			leastMemory = availableMemory
 		}
 	}
 	return candidateDevice
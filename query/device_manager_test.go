@@ -118,6 +118,26 @@ var _ = ginkgo.Describe("device_manager", func() {
 		}
 	})
 
+	ginkgo.It("TryFindDevice should work", func() {
+		queries := [3]*queryCom.AQLQuery{{}, {}, {}}
+		// least query count among devices not excluded.
+		Ω(deviceManager.TryFindDevice(queries[0], 1000, []int{2})).Should(Equal(1))
+		Ω(deviceManager.DeviceInfos[1].FreeMemory).Should(Equal(1000))
+		Ω(deviceManager.DeviceInfos[1].QueryCount).Should(Equal(2))
+
+		// not enough memory on devices not excluded.
+		Ω(deviceManager.TryFindDevice(queries[1], 2000, []int{1, 2})).Should(Equal(-1))
+
+		// other queries are waiting for device.
+		deviceManager.queue.enqueue(queries[2], QueryFlow{}, 1000, -1)
+		Ω(deviceManager.TryFindDevice(queries[1], 100, nil)).Should(Equal(-1))
+		deviceManager.queue = fairQueue{}
+
+		deviceManager.ReleaseReservedMemory(1, queries[0])
+		Ω(deviceManager.DeviceInfos[1].FreeMemory).Should(Equal(2000))
+		Ω(deviceManager.DeviceInfos[1].QueryCount).Should(Equal(1))
+	})
+
 	ginkgo.It("query queuing should work", func() {
 		deviceManager = &DeviceManager{
 			RWMutex: &sync.RWMutex{},
@@ -148,7 +168,7 @@ var _ = ginkgo.Describe("device_manager", func() {
 		utils.SetCurrentTime(time.Unix(0, 0))
 
 		// first assign 500 bytes to a query 1.
-		devices[0] = deviceManager.FindDevice(queries[0], 500, -1, timeout)
+		devices[0] = deviceManager.FindDevice(queries[0], QueryFlow{}, 500, -1, timeout)
 		Ω(devices[0]).Should(Equal(0))
 		Ω(deviceManager.DeviceInfos[0].FreeMemory).Should(Equal(0))
 
@@ -158,7 +178,7 @@ var _ = ginkgo.Describe("device_manager", func() {
 		// requires 500 bytes, needs to wait for release of query 1.
 		go func() {
 			defer wg.Done()
-			devices[1] = deviceManager.FindDevice(queries[1], 500, -1, timeout)
+			devices[1] = deviceManager.FindDevice(queries[1], QueryFlow{}, 500, -1, timeout)
 			deviceManager.ReleaseReservedMemory(devices[1], queries[1])
 		}()
 
@@ -166,7 +186,7 @@ var _ = ginkgo.Describe("device_manager", func() {
 		// requires 1000 bytes, will timeout.
 		go func() {
 			defer wg.Done()
-			devices[2] = deviceManager.FindDevice(queries[2], 1000, -1, timeout)
+			devices[2] = deviceManager.FindDevice(queries[2], QueryFlow{}, 1000, -1, timeout)
 			deviceManager.ReleaseReservedMemory(devices[2], queries[2])
 		}()
 
@@ -177,7 +197,7 @@ var _ = ginkgo.Describe("device_manager", func() {
 		<-time.NewTimer(time.Second).C
 		utils.SetCurrentTime(time.Unix(10, 0))
 		// trigger another broadcast, query 2 will timeout.
-		device := deviceManager.FindDevice(queries[0], 500, -1, timeout)
+		device := deviceManager.FindDevice(queries[0], QueryFlow{}, 500, -1, timeout)
 		Ω(device).Should(Equal(0))
 		deviceManager.ReleaseReservedMemory(device, queries[0])
 
@@ -188,7 +208,7 @@ var _ = ginkgo.Describe("device_manager", func() {
 		Ω(devices[2]).Should(Equal(-1))
 		// query 4:
 		// requi0res 2000 bytes, exceeds max device memory.
-		device = deviceManager.FindDevice(queries[2], 2000, -1, timeout)
+		device = deviceManager.FindDevice(queries[2], QueryFlow{}, 2000, -1, timeout)
 		Ω(device).Should(Equal(-1))
 	})
 
@@ -334,4 +354,147 @@ var _ = ginkgo.Describe("device_manager", func() {
 			DeviceChoosingTimeout:   -1,
 		})).ShouldNot(BeNil())
 	})
+
+	ginkgo.It("queued queries should be dispatched fairly across flows", func() {
+		queue := fairQueue{}
+		queries := make([]*queryCom.AQLQuery, 7)
+		for i := range queries {
+			queries[i] = &queryCom.AQLQuery{}
+		}
+		// a burst against table1, followed by queries against table2 and from another origin.
+		burst := QueryFlow{Table: "table1", Origin: "batch"}
+		for _, query := range queries[:4] {
+			queue.enqueue(query, burst, 100, -1)
+		}
+		queue.enqueue(queries[4], QueryFlow{Table: "table2", Origin: "batch", Weight: 2}, 100, -1)
+		queue.enqueue(queries[5], QueryFlow{Table: "table2", Origin: "batch", Weight: 2}, 100, -1)
+		queue.enqueue(queries[6], QueryFlow{Table: "table1", Origin: "dashboard"}, 100, -1)
+		Ω(queue.size()).Should(Equal(7))
+
+		// dispatch one query at a time.
+		var dispatched []*queryCom.AQLQuery
+		for queue.size() > 0 {
+			budget := 1
+			Ω(queue.dispatch(func(query *queryCom.AQLQuery, requiredMem int, preferredDevice int) int {
+				if budget == 0 {
+					return -1
+				}
+				budget--
+				dispatched = append(dispatched, query)
+				return 0
+			})).Should(BeTrue())
+		}
+		Ω(dispatched).Should(Equal([]*queryCom.AQLQuery{
+			queries[0], queries[4], queries[6], queries[5], queries[1], queries[2], queries[3],
+		}))
+		// only the burst flow is still ahead of virtual time.
+		Ω(queue.finishTags).Should(HaveLen(1))
+		Ω(queue.finishTags).Should(HaveKey(burst.key()))
+
+		// queries that cannot fit do not block others.
+		big := queue.enqueue(queries[0], burst, 1000, -1)
+		small := queue.enqueue(queries[1], burst, 100, -1)
+		Ω(queue.dispatch(func(query *queryCom.AQLQuery, requiredMem int, preferredDevice int) int {
+			if requiredMem > 500 {
+				return -1
+			}
+			return 1
+		})).Should(BeTrue())
+		Ω(big.device).Should(Equal(-1))
+		Ω(small.device).Should(Equal(1))
+		Ω(queue.size()).Should(Equal(1))
+		queue.remove(big)
+		Ω(queue.size()).Should(Equal(0))
+	})
+
+	ginkgo.It("queued queries should be weighted by origin", func() {
+		queue := fairQueue{originWeights: map[string]int{"dashboard": 3}}
+		queries := make([]*queryCom.AQLQuery, 6)
+		for i := range queries {
+			queries[i] = &queryCom.AQLQuery{}
+		}
+		for _, query := range queries[:3] {
+			queue.enqueue(query, QueryFlow{Table: "table1", Origin: "batch"}, 100, -1)
+		}
+		for _, query := range queries[3:] {
+			queue.enqueue(query, QueryFlow{Table: "table1", Origin: "dashboard"}, 100, -1)
+		}
+
+		var dispatched []*queryCom.AQLQuery
+		for queue.size() > 0 {
+			budget := 1
+			queue.dispatch(func(query *queryCom.AQLQuery, requiredMem int, preferredDevice int) int {
+				if budget == 0 {
+					return -1
+				}
+				budget--
+				dispatched = append(dispatched, query)
+				return 0
+			})
+		}
+		Ω(dispatched).Should(Equal([]*queryCom.AQLQuery{
+			queries[0], queries[3], queries[4], queries[5], queries[1], queries[2],
+		}))
+	})
+
+	ginkgo.It("queued queries that do not fit should reserve devices after being passed", func() {
+		queue := fairQueue{}
+		flow := QueryFlow{Table: "table1", Origin: "batch"}
+		big := queue.enqueue(&queryCom.AQLQuery{}, flow, 1000, -1)
+		freeMem := 500
+		findDevice := func(query *queryCom.AQLQuery, requiredMem int, preferredDevice int) int {
+			if requiredMem > freeMem {
+				return -1
+			}
+			return 0
+		}
+
+		// small queries pass the big query until it has been passed maxQueryPasses times.
+		for i := 0; i < maxQueryPasses; i++ {
+			small := queue.enqueue(&queryCom.AQLQuery{}, QueryFlow{Table: "table2"}, 100, -1)
+			Ω(queue.dispatch(findDevice)).Should(BeTrue())
+			Ω(small.device).Should(Equal(0))
+		}
+		Ω(big.device).Should(Equal(-1))
+		Ω(big.passes).Should(Equal(maxQueryPasses))
+
+		small := queue.enqueue(&queryCom.AQLQuery{}, QueryFlow{Table: "table2"}, 100, -1)
+		Ω(queue.dispatch(findDevice)).Should(BeFalse())
+		Ω(small.device).Should(Equal(-1))
+
+		// memory released by running queries goes to the big query first.
+		freeMem = 1000
+		Ω(queue.dispatch(findDevice)).Should(BeTrue())
+		Ω(big.device).Should(Equal(0))
+		Ω(small.device).Should(Equal(0))
+		Ω(queue.size()).Should(Equal(0))
+	})
+
+	ginkgo.It("finish tags should be rolled back when queued queries time out", func() {
+		queue := fairQueue{}
+		flow := QueryFlow{Table: "table1", Origin: "batch"}
+		first := queue.enqueue(&queryCom.AQLQuery{}, flow, 100, -1)
+		second := queue.enqueue(&queryCom.AQLQuery{}, flow, 100, -1)
+		third := queue.enqueue(&queryCom.AQLQuery{}, flow, 100, -1)
+		Ω(queue.finishTags[flow.key()]).Should(BeNumerically("==", 3))
+
+		queue.remove(second)
+		Ω(queue.finishTags[flow.key()]).Should(BeNumerically("==", 2))
+		Ω(first.start).Should(BeNumerically("==", 0))
+		Ω(third.start).Should(BeNumerically("==", 1))
+
+		// queries of the flow enqueued later are not delayed by the removed query.
+		other := queue.enqueue(&queryCom.AQLQuery{}, QueryFlow{Table: "table2"}, 100, -1)
+		fourth := queue.enqueue(&queryCom.AQLQuery{}, flow, 100, -1)
+		Ω(fourth.start).Should(BeNumerically("==", 2))
+		Ω(other.start).Should(BeNumerically("==", 0))
+
+		queue.remove(fourth)
+		queue.remove(third)
+		queue.remove(first)
+		Ω(queue.finishTags).Should(HaveKey(QueryFlow{Table: "table2"}.key()))
+		Ω(queue.finishTags).ShouldNot(HaveKey(flow.key()))
+		Ω(queue.size()).Should(Equal(1))
+	})
+
 })
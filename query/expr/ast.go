@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"github.com/gofrs/uuid"
 	memCom "github.com/uber/aresdb/memstore/common"
+	"sort"
 	"strconv"
 	"strings"
 	"unsafe"
@@ -79,6 +80,13 @@ const (
 	LengthCallName    = "length"
 	ContainsCallName  = "contains"
 	ElementAtCallName = "element_at"
+	// map_values(enum_col, {'a': 'group'}, 'default') groups enum values
+	MapValuesCallName = "map_values"
+	// nearest_neighbors(vector_col, [0.1, 0.2], k) filters the k rows nearest to the vector
+	NearestNeighborsCallName = "nearest_neighbors"
+	// zscore_outliers(aggregate, window[, threshold]) marks time buckets of the aggregate deviating
+	// from the trailing window, only supported by broker
+	ZScoreOutliersCallName = "zscore_outliers"
 )
 
 func (t Type) String() string {
@@ -114,6 +122,7 @@ func (*VarRef) expr()          {}
 func (*Wildcard) expr()        {}
 func (*GeopointLiteral) expr() {}
 func (*UUIDLiteral) expr()     {}
+func (*MapLiteral) expr()      {}
 
 // walkNames will walk the Expr and return the database fields
 func walkNames(exp Expr) []string {
@@ -207,6 +216,20 @@ type VarRef struct {
 
 	// Whether this column is hll column (can run hll directly)
 	IsHLLColumn bool
+
+	// Number of bits of the hash used as register index for hll column.
+	HLLPrecision int
+
+	// Number of fractional digits for Decimal column.
+	DecimalScale int
+
+	// Whether this column is map column, values are stored as array indexed by ids of
+	// keys in EnumDict.
+	IsMapColumn bool
+
+	// Global dictionary for String column, values are compared and grouped by their
+	// ids in this dictionary.
+	StringDict *memCom.GlobalStringDictionary `json:"-"`
 }
 
 // Type returns the type.
@@ -410,6 +433,30 @@ func (l *UUIDLiteral) String() string {
 	return ""
 }
 
+// MapLiteral represents a literal of string key value pairs, e.g. {'a': 'x', 'b': 'y'}.
+type MapLiteral struct {
+	Val map[string]string
+}
+
+// Type returns the type.
+func (l *MapLiteral) Type() Type {
+	return UnknownType
+}
+
+// String returns a string representation of the literal with keys sorted.
+func (l *MapLiteral) String() string {
+	keys := make([]string, 0, len(l.Val))
+	for key := range l.Val {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("%s: %s", QuoteString(key), QuoteString(l.Val[key]))
+	}
+	return fmt.Sprintf("{%s}", strings.Join(pairs, ", "))
+}
+
 // NullLiteral represents a NULL literal.
 type NullLiteral struct{}
 
@@ -542,6 +589,12 @@ func CloneExpr(expr Expr) Expr {
 		return &StringLiteral{Val: expr.Val}
 	case *GeopointLiteral:
 		return &GeopointLiteral{Val: expr.Val}
+	case *MapLiteral:
+		val := make(map[string]string, len(expr.Val))
+		for key, value := range expr.Val {
+			val[key] = value
+		}
+		return &MapLiteral{Val: val}
 	case *VarRef:
 		return &VarRef{Val: expr.Val}
 	case *Wildcard:
@@ -427,7 +427,16 @@ func (p *Parser) parseUnaryExpr(inclusion bool) (Expr, error) {
 		p.unscan() // unscan the IDENT token
 
 		// Parse it as a VarRef.
-		return p.parseVarRef()
+		vr, err := p.parseVarRef()
+		if err != nil {
+			return nil, err
+		}
+		// If the next immediate token is a left bracket, parse as element access.
+		if tok0, _, _ := p.scan(); tok0 == LBRACK {
+			return p.parseElementAt(vr)
+		}
+		p.unscan()
+		return vr, nil
 	case DISTINCT:
 		// If the next immediate token is a left parentheses, parse as function call.
 		// Otherwise parse as a Distinct expression.
@@ -467,6 +476,10 @@ func (p *Parser) parseUnaryExpr(inclusion bool) (Expr, error) {
 		return &BooleanLiteral{Val: (tok == TRUE)}, nil
 	case MUL:
 		return &Wildcard{}, nil
+	case LBRACE:
+		return p.parseMap()
+	case LBRACK:
+		return p.parseList()
 	default:
 		return nil, newParseError(tokstr(tok, lit), []string{"identifier", "string", "number", "bool"}, pos)
 	}
@@ -517,6 +530,68 @@ func (p *Parser) parseCase() (*Case, error) {
 	return &kase, nil
 }
 
+// parseMap parses a map literal of string key value pairs.
+// This function assumes the LBRACE has been consumed.
+func (p *Parser) parseMap() (*MapLiteral, error) {
+	m := &MapLiteral{Val: make(map[string]string)}
+	if tok, _, _ := p.scanIgnoreWhitespace(); tok == RBRACE {
+		return m, nil
+	}
+	p.unscan()
+
+	for {
+		tok, pos, key := p.scanIgnoreWhitespace()
+		if tok != STRING {
+			return nil, newParseError(tokstr(tok, key), []string{"string"}, pos)
+		}
+		if tok, pos, lit := p.scanIgnoreWhitespace(); tok != COLON {
+			return nil, newParseError(tokstr(tok, lit), []string{":"}, pos)
+		}
+		tok, pos, value := p.scanIgnoreWhitespace()
+		if tok != STRING {
+			return nil, newParseError(tokstr(tok, value), []string{"string"}, pos)
+		}
+		m.Val[key] = value
+
+		// If there's not a comma next then stop parsing pairs.
+		if tok, _, _ := p.scanIgnoreWhitespace(); tok != COMMA {
+			p.unscan()
+			break
+		}
+	}
+
+	// There should be a right brace at the end.
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != RBRACE {
+		return nil, newParseError(tokstr(tok, lit), []string{"}"}, pos)
+	}
+	return m, nil
+}
+
+// parseList parses a non empty list like [0.1, -0.2] as a function call with empty name, the
+// same as a tuple. This function assumes the LBRACK token has already been consumed.
+func (p *Parser) parseList() (*Call, error) {
+	var args []Expr
+	for {
+		arg, err := p.ParseExpr(0)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		// If there's not a comma next then stop parsing elements.
+		if tok, _, _ := p.scanIgnoreWhitespace(); tok != COMMA {
+			p.unscan()
+			break
+		}
+	}
+
+	// There should be a right bracket at the end.
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != RBRACK {
+		return nil, newParseError(tokstr(tok, lit), []string{"]"}, pos)
+	}
+	return &Call{Name: ListCallName, Args: args}, nil
+}
+
 // parseCall parses a function call.
 // This function assumes the function name and LPAREN have been consumed.
 func (p *Parser) parseCall(name string) (*Call, error) {
@@ -552,6 +627,22 @@ func (p *Parser) parseCall(name string) (*Call, error) {
 	return &Call{Name: name, Args: args}, nil
 }
 
+// parseElementAt parses element access like column['key'] or column[0] as element_at
+// function call. This function assumes the LBRACK token has already been consumed.
+func (p *Parser) parseElementAt(vr *VarRef) (*Call, error) {
+	index, err := p.ParseExpr(0)
+	if err != nil {
+		return nil, err
+	}
+
+	// There should be a right bracket at the end.
+	if tok, pos, lit := p.scanIgnoreWhitespace(); tok != RBRACK {
+		return nil, newParseError(tokstr(tok, lit), []string{"]"}, pos)
+	}
+
+	return &Call{Name: ElementAtCallName, Args: []Expr{vr, index}}, nil
+}
+
 // scan returns the next token from the underlying scanner.
 func (p *Parser) scan() (tok Token, pos Pos, lit string) { return p.s.Scan() }
 
@@ -375,6 +375,80 @@ func TestParser_ParseExpr(t *testing.T) {
 				},
 			},
 		},
+
+		// Map literal
+		{
+			s: `map_values(status, {'a': 'x', 'b' : 'x', 'c':'y'}, 'other')`,
+			expr: &expr.Call{
+				Name: "map_values",
+				Args: []expr.Expr{
+					&expr.VarRef{Val: "status"},
+					&expr.MapLiteral{Val: map[string]string{"a": "x", "b": "x", "c": "y"}},
+					&expr.StringLiteral{Val: "other"},
+				},
+			},
+		},
+		{s: `{}`, expr: &expr.MapLiteral{Val: map[string]string{}}},
+		{
+			s:   `{'a': 1}`,
+			err: "found 1, expected string at line 1, char 7",
+		},
+		{
+			s:   `{'a' 'b'}`,
+			err: "found b, expected : at line 1, char 5",
+		},
+		{
+			s:   `{'a': 'b'`,
+			err: "found EOF, expected } at line 1, char 10",
+		},
+
+		// List literal
+		{
+			s: `nearest_neighbors(embedding, [0.5, -1], 3)`,
+			expr: &expr.Call{
+				Name: expr.NearestNeighborsCallName,
+				Args: []expr.Expr{
+					&expr.VarRef{Val: "embedding"},
+					&expr.Call{Args: []expr.Expr{
+						&expr.NumberLiteral{Val: 0.5, Int: 0, Expr: "0.5", ExprType: expr.Float},
+						&expr.NumberLiteral{Val: -1, Int: -1, Expr: "-1", ExprType: expr.Signed},
+					}},
+					&expr.NumberLiteral{Val: 3, Int: 3, Expr: "3", ExprType: expr.Unsigned},
+				},
+			},
+		},
+		{
+			s:   `[]`,
+			err: "found ], expected identifier, string, number, bool at line 1, char 2",
+		},
+		{
+			s:   `[1, 2`,
+			err: "found EOF, expected ] at line 1, char 6",
+		},
+
+		// Element access
+		{
+			s: `properties['surge'] > 1`,
+			expr: &expr.BinaryExpr{
+				Op: expr.GT,
+				LHS: &expr.Call{Name: expr.ElementAtCallName, Args: []expr.Expr{
+					&expr.VarRef{Val: "properties"},
+					&expr.StringLiteral{Val: "surge"},
+				}},
+				RHS: &expr.NumberLiteral{Val: 1, Int: 1, Expr: "1", ExprType: expr.Unsigned},
+			},
+		},
+		{
+			s: `t.tags[0]`,
+			expr: &expr.Call{Name: expr.ElementAtCallName, Args: []expr.Expr{
+				&expr.VarRef{Val: "t.tags"},
+				&expr.NumberLiteral{Val: 0, Int: 0, Expr: "0", ExprType: expr.Unsigned},
+			}},
+		},
+		{
+			s:   `properties['surge'`,
+			err: "found EOF, expected ] at line 1, char 19",
+		},
 	}
 
 	for i, tt := range tests {
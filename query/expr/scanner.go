@@ -140,6 +140,16 @@ func (s *Scanner) scan() (tok Token, pos Pos, lit string) {
 		return RPAREN, pos, ""
 	case ',':
 		return COMMA, pos, ""
+	case '{':
+		return LBRACE, pos, ""
+	case '}':
+		return RBRACE, pos, ""
+	case ':':
+		return COLON, pos, ""
+	case '[':
+		return LBRACK, pos, ""
+	case ']':
+		return RBRACK, pos, ""
 	}
 
 	return ILLEGAL, pos, string(ch0)
@@ -72,6 +72,9 @@ func TestScanner_Scan(t *testing.T) {
 		{s: `)`, tok: expr.RPAREN},
 		{s: `,`, tok: expr.COMMA},
 		{s: `.`, tok: expr.DOT},
+		{s: `{`, tok: expr.LBRACE},
+		{s: `}`, tok: expr.RBRACE},
+		{s: `:`, tok: expr.COLON},
 
 		// Identifiers
 		{s: `foo`, tok: expr.IDENT, lit: `foo`},
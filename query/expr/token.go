@@ -64,6 +64,8 @@ const (
 	GET_DAY_OF_YEAR
 	GET_MONTH_OF_YEAR
 	GET_QUARTER_OF_YEAR
+	// converts milliseconds of Time64 columns to seconds for date operators
+	MILLIS_TO_SECONDS
 	// hll operator
 	GET_HLL_VALUE
 	// array operator
@@ -118,6 +120,11 @@ const (
 	RPAREN // )
 	COMMA  // ,
 	DOT    // .
+	LBRACE // {
+	RBRACE // }
+	COLON  // :
+	LBRACK // [
+	RBRACK // ]
 
 	keyword_beg
 	// Keywords
@@ -190,6 +197,7 @@ var tokens = map[Token]string{
 	GET_DAY_OF_YEAR:     "GET_DAY_OF_YEAR",
 	GET_MONTH_OF_YEAR:   "GET_MONTH_OF_YEAR",
 	GET_QUARTER_OF_YEAR: "GET_QUARTER_OF_YEAR",
+	MILLIS_TO_SECONDS:   "MILLIS_TO_SECONDS",
 	GET_HLL_VALUE:       "GET_HLL_VALUE",
 
 	ADD:        "+",
@@ -230,6 +238,11 @@ var tokens = map[Token]string{
 	RPAREN: ")",
 	COMMA:  ",",
 	DOT:    ".",
+	LBRACE: "{",
+	RBRACE: "}",
+	COLON:  ":",
+	LBRACK: "[",
+	RBRACK: "]",
 
 	ALL:      "ALL",
 	AS:       "AS",
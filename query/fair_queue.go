@@ -0,0 +1,209 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"sort"
+
+	queryCom "github.com/uber/aresdb/query/common"
+)
+
+const (
+	defaultQueryWeight = 1
+	// number of queries a queued query can be passed by, because it does not fit into any
+	// device while queries queued behind it do, before it reserves devices by blocking the
+	// queries behind it until it is dispatched.
+	maxQueryPasses = 16
+)
+
+// QueryFlow identifies queries sharing a fair share of devices when queries are queued
+// waiting for device memory.
+type QueryFlow struct {
+	// main table of the queries.
+	Table string
+	// caller of the queries.
+	Origin string
+	// weight of the main table, weights <= 0 are treated as 1.
+	Weight int
+}
+
+func (f QueryFlow) key() string {
+	return f.Table + "/" + f.Origin
+}
+
+// queryWaiter is a query queued waiting for device memory.
+type queryWaiter struct {
+	query           *queryCom.AQLQuery
+	requiredMem     int
+	preferredDevice int
+	flowKey         string
+	// start tag in virtual time.
+	start float64
+	// virtual time the query advances the finish tag of its flow by.
+	cost float64
+	// arrival order to break ties of start tags.
+	seq uint64
+	// number of queries dispatched ahead of this query while it did not fit.
+	passes int
+	// device assigned to the query, -1 if not assigned yet.
+	device int
+}
+
+// fairQueue schedules queries waiting for device memory with start-time fair queueing,
+// so that a burst of queries from one flow cannot starve queries from other flows.
+// Each query of a flow advances the flow's finish tag by 1/weight, where the weight is the
+// table weight multiplied by the weight of the origin, and queries are dispatched in the
+// order of their start tags. Caller needs to hold the device manager lock.
+type fairQueue struct {
+	// weights of origins, origins not listed get the default weight.
+	originWeights map[string]int
+	// virtual time, which is the start tag of the last dispatched query.
+	virtualTime float64
+	// finish tags of flows with queries queued or dispatched ahead of virtual time.
+	finishTags map[string]float64
+	waiters    []*queryWaiter
+	seq        uint64
+}
+
+// weight returns the weight of the flow.
+func (q *fairQueue) weight(flow QueryFlow) int {
+	weight := flow.Weight
+	if weight <= 0 {
+		weight = defaultQueryWeight
+	}
+	if originWeight := q.originWeights[flow.Origin]; originWeight > 0 {
+		weight *= originWeight
+	}
+	return weight
+}
+
+// enqueue adds a query of the flow into the queue.
+func (q *fairQueue) enqueue(query *queryCom.AQLQuery, flow QueryFlow, requiredMem, preferredDevice int) *queryWaiter {
+	if q.finishTags == nil {
+		q.finishTags = make(map[string]float64)
+	}
+
+	key := flow.key()
+	cost := 1 / float64(q.weight(flow))
+	start := q.virtualTime
+	if finish, ok := q.finishTags[key]; ok && finish > start {
+		start = finish
+	}
+	q.finishTags[key] = start + cost
+
+	q.seq++
+	waiter := &queryWaiter{
+		query:           query,
+		requiredMem:     requiredMem,
+		preferredDevice: preferredDevice,
+		flowKey:         key,
+		start:           start,
+		cost:            cost,
+		seq:             q.seq,
+		device:          -1,
+	}
+	q.waiters = append(q.waiters, waiter)
+	q.sortWaiters()
+	return waiter
+}
+
+func (q *fairQueue) sortWaiters() {
+	sort.SliceStable(q.waiters, func(i, j int) bool {
+		if q.waiters[i].start != q.waiters[j].start {
+			return q.waiters[i].start < q.waiters[j].start
+		}
+		return q.waiters[i].seq < q.waiters[j].seq
+	})
+}
+
+// remove removes the waiter, which gave up waiting, from the queue and rolls back the finish
+// tag of its flow, so that later queries of the flow are not delayed by it.
+func (q *fairQueue) remove(waiter *queryWaiter) {
+	found := false
+	for i, w := range q.waiters {
+		if w == waiter {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			found = true
+			break
+		}
+	}
+	if !found {
+		return
+	}
+
+	// queries of the flow queued after the waiter move up to its slot.
+	for _, w := range q.waiters {
+		if w.flowKey == waiter.flowKey && w.start > waiter.start {
+			w.start -= waiter.cost
+		}
+	}
+	q.sortWaiters()
+	if finish, ok := q.finishTags[waiter.flowKey]; ok {
+		finish -= waiter.cost
+		if finish <= q.virtualTime {
+			delete(q.finishTags, waiter.flowKey)
+		} else {
+			q.finishTags[waiter.flowKey] = finish
+		}
+	}
+}
+
+// dispatch assigns devices to queued queries in the order of their start tags using
+// findDevice. Queries that cannot fit into any device are skipped so that they do not
+// block smaller queries, until they are passed by maxQueryPasses queries, after which
+// queries behind them wait until they are dispatched so that they do not starve.
+// Returns whether any query is dispatched.
+func (q *fairQueue) dispatch(findDevice func(query *queryCom.AQLQuery, requiredMem int, preferredDevice int) int) bool {
+	dispatched := false
+	blocked := false
+	remaining := q.waiters[:0]
+	for _, waiter := range q.waiters {
+		if blocked {
+			remaining = append(remaining, waiter)
+			continue
+		}
+		waiter.device = findDevice(waiter.query, waiter.requiredMem, waiter.preferredDevice)
+		if waiter.device < 0 {
+			remaining = append(remaining, waiter)
+			blocked = waiter.passes >= maxQueryPasses
+			continue
+		}
+		dispatched = true
+		if waiter.start > q.virtualTime {
+			q.virtualTime = waiter.start
+		}
+		// queries ahead of this one that did not fit.
+		for _, w := range remaining {
+			w.passes++
+		}
+	}
+	for i := len(remaining); i < len(q.waiters); i++ {
+		q.waiters[i] = nil
+	}
+	q.waiters = remaining
+
+	// finish tags behind virtual time make no difference to new queries.
+	for key, finish := range q.finishTags {
+		if finish <= q.virtualTime {
+			delete(q.finishTags, key)
+		}
+	}
+	return dispatched
+}
+
+// size returns the number of queued queries.
+func (q *fairQueue) size() int {
+	return len(q.waiters)
+}
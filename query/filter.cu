@@ -20,6 +20,7 @@
 #include <initializer_list>
 #include "query/transform.hpp"
 #include "query/binder.hpp"
+#include "query/simd.hpp"
 #include "query/utils.hpp"
 
 namespace ares {
@@ -212,6 +213,13 @@ int FilterContext<FunctorType>::executeRemoveIf(
     InputIterator inputIter,
     IndexZipIterator indexZipIterator) {
   typedef typename InputIterator::value_type::head_type InputValueType;
+#ifndef RUN_ON_DEVICE
+  // use the vectorized kernels for supported predicates in host mode.
+  if (simd::UnaryPredicate<InputValueType>(
+      functorType, inputIter, indexVectorLength, predicateVector)) {
+    return simd::Compact(indexZipIterator, predicateVector, indexVectorLength);
+  }
+#endif
   UnaryPredicateFunctor<bool, InputValueType> f(functorType);
   RemoveFilter<typename IndexZipIterator::value_type, uint8_t> removeFilter(
       predicateVector);
@@ -239,6 +247,15 @@ int FilterContext<FunctorType>::executeRemoveIf(
         typename RHSIterator::value_type::head_type,
         typename LHSIterator::value_type::head_type>::type InputValueType2;
 
+#ifndef RUN_ON_DEVICE
+  // use the vectorized kernels for supported predicates in host mode.
+  if (std::is_same<InputValueType1, InputValueType2>::value &&
+      simd::BinaryPredicate<InputValueType1>(
+          functorType, lhsIter, rhsIter, indexVectorLength, predicateVector)) {
+    return simd::Compact(indexZipIterator, predicateVector, indexVectorLength);
+  }
+#endif
+
   BinaryPredicateFunctor<bool, InputValueType1, InputValueType2> f(functorType);
   RemoveFilter<typename IndexZipIterator::value_type, uint8_t> removeFilter(
       predicateVector);
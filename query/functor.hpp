@@ -428,6 +428,18 @@ struct GetQuarterOfYearFunctor {
       const thrust::tuple<uint32_t, bool> t) const;
 };
 
+// MillisToSecondsFunctor converts milliseconds since epoch of Time64 columns
+// to seconds since epoch consumed by date functors.
+template <typename I>
+struct MillisToSecondsFunctor {
+  __host__ __device__
+  thrust::tuple<uint32_t, bool> operator()(
+      const thrust::tuple<I, bool> t) const {
+    return thrust::make_tuple<uint32_t, bool>(
+        static_cast<uint32_t>(thrust::get<0>(t) / 1000), thrust::get<1>(t));
+  }
+};
+
 template <typename I>
 inline __host__ __device__ uint64_t hll_hash(I value) {
   uint64_t hashedOutput[2];
@@ -688,6 +700,7 @@ struct UnaryFunctor {
       case GetDayOfYear: return GetDayOfYearFunctor()(t);
       case GetMonthOfYear: return GetMonthOfYearFunctor()(t);
       case GetQuarterOfYear: return GetQuarterOfYearFunctor()(t);
+      case MillisToSeconds: return MillisToSecondsFunctor<I>()(t);
       case GetHLLValue: return GetHLLValueFunctor<I>()(t);
       default:
         // We will not handle uncaught enum here since the AQL compiler
@@ -1265,6 +1278,44 @@ struct HashLookupFunctor {
   }
 };
 
+// AsOfLookupFunctor walks the version chain from the latest version of a
+// slowly changing dimension record to find the version valid at event time.
+struct AsOfLookupFunctor {
+  VersionIndex versionIndex;
+
+  typedef thrust::tuple<thrust::tuple<uint32_t, bool>, RecordID> argument_type;
+
+  explicit AsOfLookupFunctor(VersionIndex versionIndex)
+      : versionIndex(versionIndex) {}
+
+  __host__ __device__
+  RecordID operator()(const argument_type t) const {
+    RecordID notFound = {0, 0};
+    thrust::tuple<uint32_t, bool> eventTime = thrust::get<0>(t);
+    if (!thrust::get<1>(eventTime)) {
+      return notFound;
+    }
+
+    uint32_t ts = thrust::get<0>(eventTime);
+    RecordID recordID = thrust::get<1>(t);
+    while (recordID.batchID != 0 || recordID.index != 0) {
+      int slot = (recordID.batchID - versionIndex.BaseBatchID)
+          * versionIndex.BatchSize + recordID.index;
+      if (versionIndex.ValidFroms[slot] <= ts) {
+        // validTo of 0 means the version is still valid.
+        if (versionIndex.ValidTos != nullptr
+            && versionIndex.ValidTos[slot] != 0
+            && versionIndex.ValidTos[slot] <= ts) {
+          return notFound;
+        }
+        return recordID;
+      }
+      recordID = versionIndex.PrevRecordIDs[slot];
+    }
+    return notFound;
+  }
+};
+
 // ReduceByHashFunctor is the binaryOp for reduce dimIndexVector and values
 // together, according to hash vector as key for reduction AssociativeOperator
 // is the binary operator for reducing values eg. we have:
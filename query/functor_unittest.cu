@@ -1140,6 +1140,17 @@ TEST(getWeekStartTimestamp, CheckWeeklyBucketizer) {
     EXPECT_EQ(weekts, 1528675200);
 }
 
+TEST(MillisToSecondsTest, CheckMillisToSecondsFunctor) {
+  UnaryFunctor<uint32_t, int64_t> f(MillisToSeconds);
+  thrust::tuple<uint32_t, bool> res =
+      f(thrust::make_tuple<int64_t, bool>(1533081655999, true));
+  EXPECT_EQ(thrust::get<0>(res), 1533081655);
+  EXPECT_TRUE(thrust::get<1>(res));
+
+  res = f(thrust::make_tuple<int64_t, bool>(0, false));
+  EXPECT_FALSE(thrust::get<1>(res));
+}
+
 TEST(CalculateHLLHashTest, CheckUUIDT) {
   UUIDT uuidT = {0x0000483EC1324C38, 0xBE372EB5A01BBB30};
   UUIDT uuidTs[2] = {uuidT, uuidT};
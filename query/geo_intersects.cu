@@ -13,7 +13,12 @@
 // limitations under the License.
 
 #include <cuda_runtime.h>
+#include <thrust/binary_search.h>
+#include <thrust/for_each.h>
+#include <thrust/iterator/counting_iterator.h>
 #include <thrust/iterator/discard_iterator.h>
+#include <thrust/reduce.h>
+#include <thrust/scan.h>
 #include <thrust/transform.h>
 #include <algorithm>
 #include <vector>
@@ -238,6 +243,189 @@ int GeoIntersectionContext::executeRemoveIf(IndexZipIterator indexZipIterator) {
       indexZipIterator;
 }
 
+// kNullPointCell is the cell position of null points.
+const int32_t kNullPointCell = -2;
+
+// GeoCellLookupFunctor finds the position of the range containing the s2 leaf
+// cell of the point in the cell index of the shapes, -1 if the cell is before
+// the first range and kNullPointCell if the point is null.
+struct GeoCellLookupFunctor {
+  explicit GeoCellLookupFunctor(GeoShapeBatch geoShapes)
+      : geoShapes(geoShapes) {}
+
+  GeoShapeBatch geoShapes;
+
+  template<typename Value>
+  __host__ __device__
+  int32_t operator()(const Value &point) const {
+    if (!thrust::get<1>(point)) {
+      return kNullPointCell;
+    }
+    uint64_t cellID = get_s2_leaf_cell_id(thrust::get<0>(point).Lat,
+                                          thrust::get<0>(point).Long);
+    // binary search for the last range boundary not greater than the cell id.
+    int32_t low = 0;
+    int32_t high = geoShapes.NumCells - 1;
+    while (low <= high) {
+      int32_t mid = low + (high - low) / 2;
+      if (geoShapes.CellIndex[mid] <= cellID) {
+        low = mid + 1;
+      } else {
+        high = mid - 1;
+      }
+    }
+    return high;
+  }
+};
+
+// get_cell_masks returns the shape masks of the range at cellPos in the cell
+// index of the shapes.
+__host__ __device__
+inline const uint32_t *get_cell_masks(GeoShapeBatch geoShapes,
+                                      int32_t cellPos) {
+  return reinterpret_cast<const uint32_t *>(
+      geoShapes.CellIndex + geoShapes.NumCells) +
+      cellPos * geoShapes.TotalWords;
+}
+
+// GeoCandidateCountFunctor returns the number of shapes covering the range of
+// each point, which are the only shapes the point can be in.
+struct GeoCandidateCountFunctor {
+  explicit GeoCandidateCountFunctor(GeoShapeBatch geoShapes)
+      : geoShapes(geoShapes) {}
+
+  GeoShapeBatch geoShapes;
+
+  __host__ __device__
+  uint32_t operator()(int32_t cellPos) const {
+    if (cellPos < 0) {
+      return 0;
+    }
+    const uint32_t *cellMasks = get_cell_masks(geoShapes, cellPos);
+    uint32_t count = 0;
+    for (int i = 0; i < geoShapes.TotalWords; i++) {
+      for (uint32_t word = cellMasks[i]; word != 0; word &= word - 1) {
+        count++;
+      }
+    }
+    return count;
+  }
+};
+
+// GeoCandidateWriteFunctor writes the candidate (point, shape) pairs of each
+// point starting from the offset of the point in the candidate vectors. Null
+// points are not in any shape and their predicates are written directly.
+struct GeoCandidateWriteFunctor {
+  GeoCandidateWriteFunctor(GeoShapeBatch geoShapes, const int32_t *pointCells,
+                           const uint32_t *candidateOffsets,
+                           uint32_t *candidatePoints, uint8_t *candidateShapes,
+                           uint32_t *outputPredicate, bool inOrOut)
+      : geoShapes(geoShapes),
+        pointCells(pointCells),
+        candidateOffsets(candidateOffsets),
+        candidatePoints(candidatePoints),
+        candidateShapes(candidateShapes),
+        outputPredicate(outputPredicate),
+        inOrOut(inOrOut) {}
+
+  GeoShapeBatch geoShapes;
+  const int32_t *pointCells;
+  const uint32_t *candidateOffsets;
+  uint32_t *candidatePoints;
+  uint8_t *candidateShapes;
+  uint32_t *outputPredicate;
+  bool inOrOut;
+
+  __host__ __device__
+  void operator()(uint32_t pointIndex) const {
+    int32_t cellPos = pointCells[pointIndex];
+    if (cellPos == kNullPointCell) {
+      for (int i = 0; i < geoShapes.TotalWords; i++) {
+        outputPredicate[pointIndex * geoShapes.TotalWords + i] = !inOrOut;
+      }
+      return;
+    }
+    if (cellPos < 0) {
+      return;
+    }
+
+    const uint32_t *cellMasks = get_cell_masks(geoShapes, cellPos);
+    uint32_t offset = candidateOffsets[pointIndex];
+    for (int i = 0; i < geoShapes.TotalWords; i++) {
+      uint32_t word = cellMasks[i];
+      for (int bit = 0; word != 0; bit++, word >>= 1) {
+        if (word & 1) {
+          candidatePoints[offset] = pointIndex;
+          candidateShapes[offset] = i * 32 + bit;
+          offset++;
+        }
+      }
+    }
+  }
+};
+
+// GeoCandidateIntersectFunctor tests whether the point of a candidate pair is
+// in the shape of the pair by counting the edges of the shape crossed by the
+// semi-infinite ray horizontally emitted from the point, edges of other shapes
+// are not tested.
+template<typename InputIterator>
+struct GeoCandidateIntersectFunctor {
+  GeoCandidateIntersectFunctor(InputIterator geoPoints,
+                               GeoShapeBatch geoShapes,
+                               const uint32_t *shapeOffsets,
+                               uint32_t *outputPredicate)
+      : geoPoints(geoPoints),
+        geoShapes(geoShapes),
+        shapeOffsets(shapeOffsets),
+        outputPredicate(outputPredicate) {}
+
+  InputIterator geoPoints;
+  GeoShapeBatch geoShapes;
+  const uint32_t *shapeOffsets;
+  uint32_t *outputPredicate;
+
+  template<typename Candidate>
+  __host__ __device__
+  void operator()(const Candidate &candidate) const {
+    uint32_t pointIndex = thrust::get<0>(candidate);
+    uint8_t shapeIndex = thrust::get<1>(candidate);
+    auto testPoint = geoPoints[pointIndex];
+    float testLat = thrust::get<0>(testPoint).Lat;
+    float testLong = thrust::get<0>(testPoint).Long;
+
+    const float *lats = reinterpret_cast<const float *>(geoShapes.LatLongs);
+    const float *longs = lats + geoShapes.TotalNumPoints;
+    bool in = false;
+    // edges between points of the shape, the last point has no edge.
+    for (uint32_t i = shapeOffsets[shapeIndex];
+         i + 1 < shapeOffsets[shapeIndex + 1]; i++) {
+      float edgeLat1 = lats[i];
+      float edgeLat2 = lats[i + 1];
+      if (edgeLat1 < FLT_MAX && edgeLat2 < FLT_MAX) {
+        float edgeLong1 = longs[i];
+        float edgeLong2 = longs[i + 1];
+        if (((edgeLong1 > testLong) != (edgeLong2 > testLong)) &&
+            (testLat < (edgeLat2 - edgeLat1) * (testLong - edgeLong1) /
+                               (edgeLong2 - edgeLong1) +
+                           edgeLat1)) {
+          in = !in;
+        }
+      }
+    }
+
+    if (in) {
+      uint32_t *word = outputPredicate + pointIndex * geoShapes.TotalWords +
+          shapeIndex / 32;
+#ifdef RUN_ON_DEVICE
+      atomicOr(word, 1u << (shapeIndex % 32));
+#else
+      // host mode runs sequentially.
+      *word |= 1u << (shapeIndex % 32);
+#endif
+    }
+  }
+};
+
 template<typename GeoIter>
 __global__
 void geo_for_each_kernel(GeoIter iter, int64_t count) {
@@ -251,14 +439,89 @@ void geo_for_each_kernel(GeoIter iter, int64_t count) {
   }
 }
 
-// run intersection algorithm for points and 1 geoshape, side effect is
-// modifying output predicate vector
+// run intersection algorithm for points and shapes using the s2 cell index,
+// candidate (point, shape) pairs are compacted from the shapes covering the
+// range of each point first, then only edges of the candidate shapes are tested
+// for each pair. Side effect is modifying output predicate vector.
+template<typename InputIterator>
+void calculateCandidateIntersection(GeoShapeBatch geoShapes,
+                                    InputIterator geoPoints,
+                                    int indexVectorLength,
+                                    uint32_t *outputPredicate, bool inOrOut,
+                                    cudaStream_t cudaStream) {
+  ares::device_vector<int32_t> pointCells(indexVectorLength);
+  thrust::transform(GET_EXECUTION_POLICY(cudaStream), geoPoints,
+                    geoPoints + indexVectorLength, pointCells.begin(),
+                    GeoCellLookupFunctor(geoShapes));
+
+  ares::device_vector<uint32_t> candidateOffsets(indexVectorLength);
+  thrust::transform(GET_EXECUTION_POLICY(cudaStream), pointCells.begin(),
+                    pointCells.end(), candidateOffsets.begin(),
+                    GeoCandidateCountFunctor(geoShapes));
+  uint32_t numCandidates = thrust::reduce(GET_EXECUTION_POLICY(cudaStream),
+                                          candidateOffsets.begin(),
+                                          candidateOffsets.end());
+  thrust::exclusive_scan(GET_EXECUTION_POLICY(cudaStream),
+                         candidateOffsets.begin(), candidateOffsets.end(),
+                         candidateOffsets.begin());
+
+  ares::device_vector<uint32_t> candidatePoints(numCandidates);
+  ares::device_vector<uint8_t> candidateShapes(numCandidates);
+  thrust::for_each(GET_EXECUTION_POLICY(cudaStream),
+                   thrust::make_counting_iterator<uint32_t>(0),
+                   thrust::make_counting_iterator<uint32_t>(indexVectorLength),
+                   GeoCandidateWriteFunctor(
+                       geoShapes,
+                       thrust::raw_pointer_cast(pointCells.data()),
+                       thrust::raw_pointer_cast(candidateOffsets.data()),
+                       thrust::raw_pointer_cast(candidatePoints.data()),
+                       thrust::raw_pointer_cast(candidateShapes.data()),
+                       outputPredicate, inOrOut));
+
+  // points of each shape are stored contiguously with ascending shape index,
+  // shapeOffsets[i] is the first point of shape i.
+  int numShapes = geoShapes.TotalWords * 32;
+  const uint8_t *shapeIndexs =
+      geoShapes.LatLongs + geoShapes.TotalNumPoints * 2 * 4;
+  ares::device_vector<uint32_t> shapeOffsets(numShapes + 1);
+  if (numCandidates > 0) {
+    thrust::lower_bound(GET_EXECUTION_POLICY(cudaStream), shapeIndexs,
+                        shapeIndexs + geoShapes.TotalNumPoints,
+                        thrust::make_counting_iterator<int>(0),
+                        thrust::make_counting_iterator<int>(numShapes + 1),
+                        shapeOffsets.begin());
+
+    auto candidates = thrust::make_zip_iterator(thrust::make_tuple(
+        candidatePoints.begin(), candidateShapes.begin()));
+    thrust::for_each(GET_EXECUTION_POLICY(cudaStream), candidates,
+                     candidates + numCandidates,
+                     GeoCandidateIntersectFunctor<InputIterator>(
+                         geoPoints, geoShapes,
+                         thrust::raw_pointer_cast(shapeOffsets.data()),
+                         outputPredicate));
+  }
+#ifdef RUN_ON_DEVICE
+  // Wait for kernels to finish before the candidate vectors are freed.
+  cudaStreamSynchronize(cudaStream);
+  CheckCUDAError("cudaStreamSynchronize");
+#endif
+}
+
+// run intersection algorithm for points and shapes, side effect is modifying
+// output predicate vector. Without the s2 cell index, every point is tested
+// against every edge of all shapes.
 template<typename InputIterator>
 void calculateBatchIntersection(GeoShapeBatch geoShapes,
                                 InputIterator geoPoints, uint32_t *indexVector,
                                 int indexVectorLength, uint32_t startCount,
                                 uint32_t *outputPredicate, bool inOrOut,
                                 cudaStream_t cudaStream) {
+  if (geoShapes.CellIndex != nullptr && geoShapes.NumCells > 0) {
+    calculateCandidateIntersection(geoShapes, geoPoints, indexVectorLength,
+                                   outputPredicate, inOrOut, cudaStream);
+    return;
+  }
+
   auto geoIter = make_geo_batch_intersect_iterator(geoPoints, geoShapes,
                                                    outputPredicate, inOrOut);
   int64_t iterLength = (int64_t) indexVectorLength * geoShapes.TotalNumPoints;
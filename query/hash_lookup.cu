@@ -65,6 +65,49 @@ class InputVectorBinder<HashLookupContext, 1> : public InputVectorBinderBase<
   int bind(InputIterators... boundInputIterators);
 };
 
+class AsOfLookupContext {
+ public:
+  AsOfLookupContext(int indexVectorLength, VersionIndex versionIndex,
+                    RecordID* recordIDVector,
+                    void *cudaStream)
+      : indexVectorLength(indexVectorLength),
+        versionIndex(versionIndex),
+        recordIDVector(recordIDVector),
+        cudaStream(reinterpret_cast<cudaStream_t>(cudaStream)) {}
+
+  template<typename InputIterator>
+  int run(uint32_t *indexVector, InputIterator inputIterator);
+
+  cudaStream_t getStream() const {
+    return cudaStream;
+  }
+
+ private:
+  int indexVectorLength;
+  VersionIndex versionIndex;
+  RecordID* recordIDVector;
+  cudaStream_t cudaStream;
+};
+
+// Specialized for AsOfLookupContext as event time column is always uint32.
+template <>
+class InputVectorBinder<AsOfLookupContext, 1> : public InputVectorBinderBase<
+    AsOfLookupContext, 1, 1> {
+  typedef InputVectorBinderBase<AsOfLookupContext, 1, 1> super_t;
+ public:
+  explicit InputVectorBinder(AsOfLookupContext context,
+                             std::vector<InputVector> inputVectors,
+                             uint32_t *indexVector, uint32_t *baseCounts,
+                             uint32_t startCount) : super_t(context,
+                                                            inputVectors,
+                                                            indexVector,
+                                                            baseCounts,
+                                                            startCount) {
+  }
+ public:
+  int bind();
+};
+
 }  // namespace ares
 
 CGoCallResHandle HashLookup(InputVector input, RecordID *output,
@@ -93,6 +136,31 @@ CGoCallResHandle HashLookup(InputVector input, RecordID *output,
   return resHandle;
 }
 
+CGoCallResHandle AsOfLookup(InputVector eventTimes, RecordID *recordIDs,
+                            uint32_t *indexVector, int indexVectorLength,
+                            uint32_t *baseCounts, uint32_t startCount,
+                            VersionIndex versionIndex, void *cudaStream,
+                            int device) {
+  CGoCallResHandle resHandle = {nullptr, nullptr};
+  try {
+#ifdef RUN_ON_DEVICE
+    cudaSetDevice(device);
+#endif
+    ares::AsOfLookupContext ctx(indexVectorLength,
+                                versionIndex, recordIDs, cudaStream);
+    std::vector<InputVector> inputVectors = {eventTimes};
+    ares::InputVectorBinder<ares::AsOfLookupContext, 1>
+        binder(ctx, inputVectors, indexVector, baseCounts, startCount);
+    resHandle.res =
+        reinterpret_cast<void *>(binder.bind());
+    CheckCUDAError("AsOfLookup");
+  } catch (std::exception &e) {
+    std::cerr << "Exception happened when doing AsOfLookup:" << e.what()
+              << std::endl;
+    resHandle.pStrErr = strdup(e.what());
+  }
+  return resHandle;
+}
 
 namespace ares {
 
@@ -156,4 +224,47 @@ int HashLookupContext::run(uint32_t *indexVector, InputIterator inputIter) {
   recordIDVector;
 }
 
+int InputVectorBinder<AsOfLookupContext, 1>::bind() {
+  InputVector input = super_t::inputVectors[0];
+  if (input.Type != VectorPartyInput ||
+      input.Vector.VP.DataType != Uint32) {
+    throw std::invalid_argument(
+        "Event time column is expected for AsOfLookup");
+  }
+
+  VectorPartySlice inputVP = input.Vector.VP;
+  uint8_t *basePtr = inputVP.BasePtr;
+  // Treat mode 0 as constant vector.
+  if (basePtr == nullptr) {
+    return context.run(super_t::indexVector,
+                       thrust::make_constant_iterator(
+                           thrust::make_tuple<uint32_t, bool>(
+                               inputVP.DefaultValue.Value.Uint32Val,
+                               inputVP.DefaultValue.HasDefault)));
+  }
+
+  return context.run(super_t::indexVector,
+                     make_column_iterator<uint32_t>(
+                         super_t::indexVector,
+                         super_t::baseCounts,
+                         super_t::startCount,
+                         basePtr,
+                         inputVP.NullsOffset,
+                         inputVP.ValuesOffset,
+                         inputVP.Length,
+                         getStepInBytes(inputVP.DataType),
+                         inputVP.StartingIndex));
+}
+
+template<typename InputIterator>
+int AsOfLookupContext::run(uint32_t *indexVector, InputIterator inputIter) {
+  AsOfLookupFunctor f(versionIndex);
+  return thrust::transform(GET_EXECUTION_POLICY(cudaStream),
+      thrust::make_zip_iterator(thrust::make_tuple(inputIter, recordIDVector)),
+      thrust::make_zip_iterator(
+          thrust::make_tuple(inputIter + indexVectorLength,
+                             recordIDVector + indexVectorLength)),
+      recordIDVector, f) - recordIDVector;
+}
+
 }  // namespace ares
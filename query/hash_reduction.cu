@@ -378,10 +378,20 @@ int hash_reduction(DimensionVector inputKeys, uint8_t *inputValues,
         REDUCE_INTERNAL(double_t, sum_op < double_t >)
       }
     case AGGR_MIN_UNSIGNED:REDUCE_INTERNAL(uint32_t, min_op < uint32_t >)
-    case AGGR_MIN_SIGNED:REDUCE_INTERNAL(int32_t, min_op < int32_t >)
+    case AGGR_MIN_SIGNED:
+      if (valueBytes == 4) {
+        REDUCE_INTERNAL(int32_t, min_op < int32_t >)
+      } else {
+        REDUCE_INTERNAL(int64_t, min_op < int64_t >)
+      }
     case AGGR_MIN_FLOAT:REDUCE_INTERNAL(float_t, min_op < float_t >)
     case AGGR_MAX_UNSIGNED:REDUCE_INTERNAL(uint32_t, max_op < uint32_t >)
-    case AGGR_MAX_SIGNED:REDUCE_INTERNAL(int32_t, max_op < int32_t >)
+    case AGGR_MAX_SIGNED:
+      if (valueBytes == 4) {
+        REDUCE_INTERNAL(int32_t, max_op < int32_t >)
+      } else {
+        REDUCE_INTERNAL(int64_t, max_op < int64_t >)
+      }
     case AGGR_MAX_FLOAT:REDUCE_INTERNAL(float_t, max_op < float_t >)
     case AGGR_AVG_FLOAT:REDUCE_INTERNAL(uint64_t, RollingAvgFunctor)
     default:
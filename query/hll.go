@@ -86,10 +86,12 @@ func (qc *AQLQueryContext) SerializeHLL(dataTypes []memCom.DataType,
 				// Don't need to check type of time dimension, they should be guaranteed by AQL Compiler.
 
 				newVal := int64(*valuePtr)
-				if qc.fromTime != nil {
+				if qc.timezoneTransitions != nil {
+					newVal = qc.timezoneTransitions.ToUTC(newVal)
+				} else if qc.fromTime != nil {
 					_, fromOffset := qc.fromTime.Time.Zone()
 					_, toOffset := qc.toTime.Time.Zone()
-					newVal = utils.AdjustOffset(fromOffset, toOffset, qc.dstswitch, int64(*valuePtr))
+					newVal = utils.AdjustOffset(fromOffset, toOffset, 0, newVal)
 				}
 
 				if newVal >= math.MaxUint32 {
@@ -0,0 +1,366 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"container/heap"
+	"sort"
+	"strconv"
+	"unsafe"
+
+	"github.com/uber/aresdb/memstore"
+	memCom "github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/query/expr"
+	"github.com/uber/aresdb/utils"
+)
+
+// maxNearestNeighbors is the max k of nearest_neighbors filters.
+const maxNearestNeighbors = 1000
+
+// nearestNeighbors is the nearest_neighbors(vector_col, [..], k) filter of the query. The k rows
+// nearest to the query vector in euclidean distance within the time range of the query are
+// searched on host before processing batches, using IVF indexes of archive batches if the
+// column is indexed. Batches are then filtered by row indexes of their neighbors on device
+// together with other filters of the query, so the query may return less than k rows.
+type nearestNeighbors struct {
+	columnID  int
+	dimension int
+	vector    []float32
+	k         int
+	// __row_index column to match neighbors of each batch.
+	rowIndex expr.Expr
+	// filters matching the neighbors in each batch by shard and batch id, batches without
+	// neighbors are skipped.
+	filters map[int]map[int32]expr.Expr
+}
+
+// neighbor is a row of a batch compared with the query vector.
+type neighbor struct {
+	shardID  int
+	batchID  int32
+	row      uint32
+	distance float32
+}
+
+// neighborHeap keeps the k nearest neighbors found so far in a max heap of distances.
+type neighborHeap struct {
+	k     int
+	items []neighbor
+}
+
+func (h *neighborHeap) Len() int           { return len(h.items) }
+func (h *neighborHeap) Less(i, j int) bool { return h.items[i].distance > h.items[j].distance }
+func (h *neighborHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *neighborHeap) Push(x interface{}) {
+	h.items = append(h.items, x.(neighbor))
+}
+
+func (h *neighborHeap) Pop() interface{} {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return item
+}
+
+// add keeps the neighbor if it is nearer than the farthest of the k nearest neighbors.
+func (h *neighborHeap) add(n neighbor) {
+	if len(h.items) < h.k {
+		heap.Push(h, n)
+	} else if n.distance < h.items[0].distance {
+		h.items[0] = n
+		heap.Fix(h, 0)
+	}
+}
+
+// nearestNeighborsDetector detects nearest_neighbors calls in AST.
+type nearestNeighborsDetector struct {
+	found bool
+}
+
+func (d *nearestNeighborsDetector) Visit(expression expr.Expr) expr.Visitor {
+	if e, ok := expression.(*expr.Call); ok && e.Name == expr.NearestNeighborsCallName {
+		d.found = true
+	}
+	return d
+}
+
+// matchNearestNeighbors extracts the filter into qc.OOPK.nearestNeighbors if it is a
+// nearest_neighbors call, it returns whether the filter is matched.
+func (qc *AQLQueryContext) matchNearestNeighbors(filter expr.Expr) bool {
+	call, ok := filter.(*expr.Call)
+	if !ok || call.Name != expr.NearestNeighborsCallName {
+		return false
+	}
+	if qc.OOPK.nearestNeighbors != nil {
+		qc.Error = utils.StackError(nil, "at most one %s filter allowed", expr.NearestNeighborsCallName)
+		return true
+	}
+	if len(call.Args) != 3 {
+		qc.Error = utils.StackError(nil, "expect 3 arguments for %s, but got %s", call.Name, call.String())
+		return true
+	}
+
+	column, ok := call.Args[0].(*expr.VarRef)
+	if !ok || column.TableID != 0 || memCom.IsSystemColumn(column.ColumnID) ||
+		!qc.TableScanners[0].Schema.Schema.Columns[column.ColumnID].IsVectorColumn() {
+		qc.Error = utils.StackError(nil, "expect first argument of %s to be a vector column of the main table, but got %s",
+			call.Name, call.Args[0].String())
+		return true
+	}
+	dimension := qc.TableScanners[0].Schema.Schema.Columns[column.ColumnID].VectorDimension
+
+	list, ok := call.Args[1].(*expr.Call)
+	if !ok || list.Name != expr.ListCallName || len(list.Args) != dimension {
+		qc.Error = utils.StackError(nil, "expect second argument of %s to be a list of %d numbers, but got %s",
+			call.Name, dimension, call.Args[1].String())
+		return true
+	}
+	vector := make([]float32, dimension)
+	for i, arg := range list.Args {
+		number, ok := arg.(*expr.NumberLiteral)
+		if !ok {
+			qc.Error = utils.StackError(nil, "expect second argument of %s to be a list of %d numbers, but got %s",
+				call.Name, dimension, call.Args[1].String())
+			return true
+		}
+		vector[i] = float32(number.Val)
+	}
+
+	k, ok := call.Args[2].(*expr.NumberLiteral)
+	if !ok || k.ExprType != expr.Unsigned || k.Int <= 0 || k.Int > maxNearestNeighbors {
+		qc.Error = utils.StackError(nil, "expect third argument of %s to be an integer between 1 and %d, but got %s",
+			call.Name, maxNearestNeighbors, call.Args[2].String())
+		return true
+	}
+
+	qc.OOPK.nearestNeighbors = &nearestNeighbors{
+		columnID:  column.ColumnID,
+		dimension: dimension,
+		vector:    vector,
+		k:         k.Int,
+		rowIndex:  qc.Rewrite(&expr.VarRef{Val: memCom.RowIndexColumnName}),
+	}
+	// neighbors are matched by row index on device while vectors stay on host.
+	expr.Walk(columnUsageCollector{
+		tableScanners: qc.TableScanners,
+		usages:        columnUsedByAllBatches,
+	}, qc.OOPK.nearestNeighbors.rowIndex)
+	return true
+}
+
+// checkNearestNeighborsPlacement reports error if nearest_neighbors is used other than as a top
+// level filter.
+func (qc *AQLQueryContext) checkNearestNeighborsPlacement() {
+	detector := &nearestNeighborsDetector{}
+	exprs := append(append(append([]expr.Expr{}, qc.OOPK.MainTableCommonFilters...),
+		qc.OOPK.ForeignTableCommonFilters...), qc.OOPK.Prefilters...)
+	for _, dim := range qc.Query.Dimensions {
+		exprs = append(exprs, dim.ExprParsed)
+	}
+	exprs = append(exprs, qc.Query.Measures[0].ExprParsed)
+	for _, e := range exprs {
+		if e != nil {
+			expr.Walk(detector, e)
+		}
+	}
+	if detector.found {
+		qc.Error = utils.StackError(nil, "%s is only supported as a top level filter", expr.NearestNeighborsCallName)
+	}
+}
+
+// prepareNearestNeighbors searches the nearest neighbors in all shards of the query and
+// prepares the filter of each batch holding any of them.
+func (qc *AQLQueryContext) prepareNearestNeighbors(memStore memstore.MemStore) {
+	nn := qc.OOPK.nearestNeighbors
+	neighbors := &neighborHeap{k: nn.k}
+	for _, shardID := range qc.TableScanners[0].Shards {
+		shard, err := memStore.GetTableShard(qc.Query.Table, shardID)
+		if err != nil {
+			qc.Error = utils.StackError(err, "failed to get shard %d for table %s",
+				shardID, qc.Query.Table)
+			return
+		}
+		qc.searchShardNeighbors(shard, neighbors)
+		shard.Users.Done()
+	}
+
+	rowsByBatch := make(map[int]map[int32][]uint32)
+	for _, n := range neighbors.items {
+		if rowsByBatch[n.shardID] == nil {
+			rowsByBatch[n.shardID] = make(map[int32][]uint32)
+		}
+		rowsByBatch[n.shardID][n.batchID] = append(rowsByBatch[n.shardID][n.batchID], n.row)
+	}
+	nn.filters = make(map[int]map[int32]expr.Expr)
+	for shardID, batches := range rowsByBatch {
+		nn.filters[shardID] = make(map[int32]expr.Expr)
+		for batchID, rows := range batches {
+			sort.Slice(rows, func(i, j int) bool { return rows[i] < rows[j] })
+			nn.filters[shardID][batchID] = qc.rowIndexFilter(rows)
+		}
+	}
+}
+
+// rowIndexFilter returns the filter matching the rows, disjunctions are balanced to bound the
+// depth of expression evaluation.
+func (qc *AQLQueryContext) rowIndexFilter(rows []uint32) expr.Expr {
+	if len(rows) == 1 {
+		return qc.Rewrite(&expr.BinaryExpr{
+			Op:  expr.EQ,
+			LHS: qc.OOPK.nearestNeighbors.rowIndex,
+			RHS: &expr.NumberLiteral{
+				Val:      float64(rows[0]),
+				Int:      int(rows[0]),
+				Expr:     strconv.Itoa(int(rows[0])),
+				ExprType: expr.Unsigned,
+			},
+		})
+	}
+	middle := len(rows) / 2
+	return &expr.BinaryExpr{
+		Op:       expr.OR,
+		LHS:      qc.rowIndexFilter(rows[:middle]),
+		RHS:      qc.rowIndexFilter(rows[middle:]),
+		ExprType: expr.Boolean,
+	}
+}
+
+// searchShardNeighbors compares the query vector with rows of live batches and archive batches
+// of the shard scanned by the query.
+func (qc *AQLQueryContext) searchShardNeighbors(shard *memstore.TableShard, neighbors *neighborHeap) {
+	nn := qc.OOPK.nearestNeighbors
+	var archiveStore *memstore.ArchiveStoreVersion
+	var cutoff uint32
+	isFactTable := shard.Schema.Schema.IsFactTable
+	if isFactTable {
+		archiveStore = shard.ArchiveStore.GetCurrentVersion()
+		defer archiveStore.Users.Done()
+		cutoff = archiveStore.ArchivingCutoff
+	}
+
+	if qc.Query.ScanLive() {
+		batchIDs, numRecordsInLastBatch := shard.LiveStore.GetBatchIDs()
+		for i, batchID := range batchIDs {
+			batch := shard.LiveStore.GetBatchForRead(batchID)
+			if batch == nil {
+				continue
+			}
+			size := batch.Capacity
+			if i == len(batchIDs)-1 {
+				size = numRecordsInLastBatch
+			}
+			for row := 0; row < size; row++ {
+				// rows before the cutoff are already archived.
+				if isFactTable && !qc.inNeighborTimeRange(batch.GetDataValue(row, 0), cutoff) {
+					continue
+				}
+				if vector, ok := memCom.ReadVector(batch.GetDataValue(row, nn.columnID), nn.dimension); ok {
+					neighbors.add(neighbor{shard.ShardID, batchID, uint32(row), memCom.SquaredL2Distance(vector, nn.vector)})
+				}
+			}
+			batch.RUnlock()
+		}
+	}
+
+	if archiveStore != nil && qc.Query.ScanArchive() {
+		scanner := qc.TableScanners[0]
+		for batchID := scanner.ArchiveBatchIDStart; batchID < scanner.ArchiveBatchIDEnd; batchID++ {
+			batch := archiveStore.RequestBatch(int32(batchID))
+			if batch.Size == 0 {
+				continue
+			}
+			isFirstOrLast := batchID == scanner.ArchiveBatchIDStart || batchID == scanner.ArchiveBatchIDEnd-1
+			qc.searchArchiveBatchNeighbors(shard.ShardID, batch, isFirstOrLast, neighbors)
+		}
+	}
+}
+
+// searchArchiveBatchNeighbors compares the query vector with rows of the archive batch, only
+// rows of the nearest clusters are compared if the batch has an IVF index of the column. Rows
+// of the first and last batch are checked against the time range of the query.
+func (qc *AQLQueryContext) searchArchiveBatchNeighbors(shardID int, batch *memstore.ArchiveBatch,
+	isFirstOrLast bool, neighbors *neighborHeap) {
+	nn := qc.OOPK.nearestNeighbors
+	var inTimeRange []bool
+	if isFirstOrLast && (qc.fromTime != nil || qc.toTime != nil) {
+		timeVP := batch.RequestVectorParty(0)
+		timeVP.WaitForDiskLoad()
+		inTimeRange = make([]bool, batch.Size)
+		batch.ForEachValue(0, func(row int, value memCom.DataValue) {
+			inTimeRange[row] = qc.inNeighborTimeRange(value, 0)
+		})
+		timeVP.Release()
+	}
+
+	vp := batch.RequestVectorParty(nn.columnID)
+	vp.WaitForDiskLoad()
+	defer vp.Release()
+	compare := func(row int) {
+		if inTimeRange != nil && !inTimeRange[row] {
+			return
+		}
+		if vector, ok := memCom.ReadVector(vp.GetDataValue(row), nn.dimension); ok {
+			neighbors.add(neighbor{shardID, batch.BatchID, uint32(row), memCom.SquaredL2Distance(vector, nn.vector)})
+		}
+	}
+
+	if index := batch.RequestVectorIndex(nn.columnID); index != nil {
+		for _, row := range index.Candidates(nn.vector, 0) {
+			if int(row) < batch.Size {
+				compare(int(row))
+			}
+		}
+		return
+	}
+	for row := 0; row < batch.Size; row++ {
+		compare(row)
+	}
+}
+
+// inNeighborTimeRange returns whether the event time is not before the cutoff and within the
+// time range of the query.
+func (qc *AQLQueryContext) inNeighborTimeRange(eventTime memCom.DataValue, cutoff uint32) bool {
+	if !eventTime.Valid {
+		return cutoff == 0 && qc.fromTime == nil && qc.toTime == nil
+	}
+	t := *(*uint32)(eventTime.OtherVal)
+	return t >= cutoff && (qc.fromTime == nil || t >= uint32(qc.fromTime.Time.Unix())) &&
+		(qc.toTime == nil || t < uint32(qc.toTime.Time.Unix()))
+}
+
+// shouldSkipBatchWithoutNeighbors returns whether the batch holds none of the nearest neighbors
+// of the query.
+func (qc *AQLQueryContext) shouldSkipBatchWithoutNeighbors(shardID int, batchID int32) bool {
+	nn := qc.OOPK.nearestNeighbors
+	return nn != nil && nn.filters[shardID][batchID] == nil
+}
+
+// withNearestNeighborsFilter returns the custom filter executor additionally applying the
+// filter of nearest neighbors in the batch.
+func (qc *AQLQueryContext) withNearestNeighborsFilter(executor customFilterExecutor, shardID int,
+	batchID int32) customFilterExecutor {
+	nn := qc.OOPK.nearestNeighbors
+	if nn == nil {
+		return executor
+	}
+	filter := nn.filters[shardID][batchID]
+	return func(stream unsafe.Pointer) {
+		executor(stream)
+		if filter != nil {
+			qc.OOPK.currentBatch.processExpression(filter, nil,
+				qc.TableScanners, qc.OOPK.foreignTables, stream, qc.Device, qc.OOPK.currentBatch.filterAction)
+		}
+	}
+}
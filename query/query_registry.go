@@ -0,0 +1,175 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// RunningQuery describes a query being executed.
+type RunningQuery struct {
+	RequestID string `json:"requestID"`
+	// AQL query rewritten by the compiler.
+	Query         queryCom.AQLQuery `json:"query"`
+	ElapsedMillis int64             `json:"elapsedMillis"`
+	Device        int               `json:"device"`
+	ShardsPending int               `json:"shardsPending"`
+	BytesScanned  int64             `json:"bytesScanned"`
+}
+
+// runningQueryEntry is a registered query.
+type runningQueryEntry struct {
+	qc             *AQLQueryContext
+	rewrittenQuery queryCom.AQLQuery
+	startTime      time.Time
+}
+
+// QueryRegistry tracks queries being executed so that they can be inspected and killed.
+type QueryRegistry struct {
+	sync.RWMutex
+	// request id -> queries of the request being executed.
+	queries map[string][]*runningQueryEntry
+	// for generating request ids for requests not coming from broker.
+	nextRequestID int64
+}
+
+// NewQueryRegistry creates a new QueryRegistry.
+func NewQueryRegistry() *QueryRegistry {
+	return &QueryRegistry{
+		queries: make(map[string][]*runningQueryEntry),
+	}
+}
+
+// Register registers a compiled query before execution. A request id will be generated
+// if the query context does not have one.
+func (r *QueryRegistry) Register(qc *AQLQueryContext) {
+	entry := &runningQueryEntry{
+		qc:             qc,
+		rewrittenQuery: getRewrittenQuery(qc.Query),
+		startTime:      utils.Now(),
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	if qc.RequestID == "" {
+		r.nextRequestID++
+		qc.RequestID = fmt.Sprintf("local_%d", r.nextRequestID)
+	}
+	r.queries[qc.RequestID] = append(r.queries[qc.RequestID], entry)
+}
+
+// Deregister removes a query after its execution.
+func (r *QueryRegistry) Deregister(qc *AQLQueryContext) {
+	r.Lock()
+	defer r.Unlock()
+	entries := r.queries[qc.RequestID]
+	for i, entry := range entries {
+		if entry.qc == qc {
+			entries = append(entries[:i], entries[i+1:]...)
+			break
+		}
+	}
+	if len(entries) == 0 {
+		delete(r.queries, qc.RequestID)
+	} else {
+		r.queries[qc.RequestID] = entries
+	}
+}
+
+// List returns all queries being executed ordered by elapsed time descendingly.
+func (r *QueryRegistry) List() []RunningQuery {
+	now := utils.Now()
+	r.RLock()
+	defer r.RUnlock()
+
+	runningQueries := []RunningQuery{}
+	for requestID, entries := range r.queries {
+		for _, entry := range entries {
+			runningQueries = append(runningQueries, RunningQuery{
+				RequestID:     requestID,
+				Query:         entry.rewrittenQuery,
+				ElapsedMillis: now.Sub(entry.startTime).Nanoseconds() / int64(time.Millisecond),
+				Device:        entry.qc.Device,
+				ShardsPending: len(entry.qc.TableScanners[0].Shards) - int(atomic.LoadInt32(&entry.qc.shardsProcessed)),
+				BytesScanned:  atomic.LoadInt64(&entry.qc.bytesScanned),
+			})
+		}
+	}
+
+	sort.Slice(runningQueries, func(i, j int) bool {
+		return runningQueries[i].ElapsedMillis > runningQueries[j].ElapsedMillis
+	})
+	return runningQueries
+}
+
+// Kill kills all queries of a request. Returns false if no query of the request is being executed.
+func (r *QueryRegistry) Kill(requestID string) bool {
+	r.RLock()
+	defer r.RUnlock()
+	entries, ok := r.queries[requestID]
+	for _, entry := range entries {
+		entry.qc.Kill()
+	}
+	return ok
+}
+
+// getRewrittenQuery returns a copy of the query with expressions replaced by the compiled ones.
+func getRewrittenQuery(query *queryCom.AQLQuery) queryCom.AQLQuery {
+	newQuery := *query
+
+	newQuery.Joins = make([]queryCom.Join, len(query.Joins))
+	for i, join := range query.Joins {
+		join.Conditions = make([]string, len(query.Joins[i].Conditions))
+		for j, cond := range query.Joins[i].Conditions {
+			join.Conditions[j] = cond
+			if j < len(join.ConditionsParsed) && join.ConditionsParsed[j] != nil {
+				join.Conditions[j] = join.ConditionsParsed[j].String()
+			}
+		}
+		newQuery.Joins[i] = join
+	}
+
+	newQuery.Dimensions = make([]queryCom.Dimension, len(query.Dimensions))
+	for i, dim := range query.Dimensions {
+		if dim.ExprParsed != nil {
+			dim.Expr = dim.ExprParsed.String()
+		}
+		newQuery.Dimensions[i] = dim
+	}
+
+	newQuery.Measures = make([]queryCom.Measure, len(query.Measures))
+	for i, measure := range query.Measures {
+		if measure.ExprParsed != nil {
+			measure.Expr = measure.ExprParsed.String()
+		}
+		newQuery.Measures[i] = measure
+	}
+
+	newQuery.Filters = make([]string, len(query.Filters))
+	for i, filter := range query.Filters {
+		newQuery.Filters[i] = filter
+		if i < len(query.FiltersParsed) && query.FiltersParsed[i] != nil {
+			newQuery.Filters[i] = query.FiltersParsed[i].String()
+		}
+	}
+	return newQuery
+}
@@ -0,0 +1,92 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/query/expr"
+	"github.com/uber/aresdb/utils"
+	"time"
+)
+
+var _ = ginkgo.Describe("query registry", func() {
+	ginkgo.BeforeEach(func() {
+		utils.SetClockImplementation(func() time.Time {
+			return time.Unix(100, 0)
+		})
+	})
+
+	ginkgo.AfterEach(func() {
+		utils.ResetClockImplementation()
+	})
+
+	ginkgo.It("should register, list, kill and deregister queries", func() {
+		registry := NewQueryRegistry()
+		qc1 := &AQLQueryContext{
+			Query: &queryCom.AQLQuery{
+				Table: "trips",
+				Measures: []queryCom.Measure{
+					{Expr: "count(*)", ExprParsed: &expr.Call{Name: "count"}},
+				},
+				Filters:       []string{"1 = 1"},
+				FiltersParsed: []expr.Expr{&expr.BooleanLiteral{Val: true}},
+			},
+			TableScanners: []*TableScanner{{Shards: []int{0, 1, 2}}},
+			RequestID:     "broker_1",
+			Device:        1,
+		}
+		qc2 := &AQLQueryContext{
+			Query:         &queryCom.AQLQuery{Table: "trips"},
+			TableScanners: []*TableScanner{{Shards: []int{0}}},
+		}
+		registry.Register(qc1)
+		utils.SetClockImplementation(func() time.Time {
+			return time.Unix(101, 0)
+		})
+		registry.Register(qc2)
+		Ω(qc2.RequestID).Should(Equal("local_1"))
+
+		qc1.shardsProcessed = 1
+		qc1.bytesScanned = 1024
+		utils.SetClockImplementation(func() time.Time {
+			return time.Unix(102, 0)
+		})
+		runningQueries := registry.List()
+		Ω(runningQueries).Should(HaveLen(2))
+		Ω(runningQueries[0].RequestID).Should(Equal("broker_1"))
+		Ω(runningQueries[0].ElapsedMillis).Should(BeEquivalentTo(2000))
+		Ω(runningQueries[0].Device).Should(Equal(1))
+		Ω(runningQueries[0].ShardsPending).Should(Equal(2))
+		Ω(runningQueries[0].BytesScanned).Should(BeEquivalentTo(1024))
+		Ω(runningQueries[0].Query.Measures[0].Expr).Should(Equal("count()"))
+		Ω(runningQueries[0].Query.Filters).Should(Equal([]string{"true"}))
+		// original query should not be changed
+		Ω(qc1.Query.Filters).Should(Equal([]string{"1 = 1"}))
+		Ω(runningQueries[1].RequestID).Should(Equal("local_1"))
+
+		Ω(registry.Kill("broker_2")).Should(BeFalse())
+		Ω(registry.Kill("broker_1")).Should(BeTrue())
+		Ω(qc1.checkKilled()).Should(BeTrue())
+		Ω(qc1.OOPK.done).Should(BeTrue())
+		Ω(qc2.checkKilled()).Should(BeFalse())
+
+		registry.Deregister(qc1)
+		registry.Deregister(qc2)
+		Ω(registry.List()).Should(BeEmpty())
+		Ω(registry.Kill("broker_1")).Should(BeFalse())
+	})
+})
@@ -0,0 +1,130 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#ifndef QUERY_SIMD_HPP_
+#define QUERY_SIMD_HPP_
+
+#include <thrust/tuple.h>
+#include <cstdint>
+#include <iterator>
+#include <type_traits>
+#include "query/simd_kernels.hpp"
+#include "query/time_series_aggregate.h"
+
+// Vectorized scan kernels for host mode. Iterators over columns are gathered
+// chunk by chunk into contiguous value and validity arrays so that predicates
+// can be evaluated by the SIMD kernels in query/simd_kernels.hpp. Only the
+// predicates on arithmetic types are covered here, callers should fall back
+// to the generic functors when the kernels return false.
+namespace ares {
+namespace simd {
+
+// Number of rows gathered and evaluated at a time, small enough for the
+// chunk buffers to stay in L1 cache.
+const int CHUNK_SIZE = 1024;
+
+// gather copies values and validities of the next n rows of the iterator into
+// contiguous arrays.
+template<typename InputIterator, typename T>
+inline void gather(InputIterator iter, int n, T *values, uint8_t *valids) {
+  for (int i = 0; i < n; i++) {
+    typename std::iterator_traits<InputIterator>::value_type v = iter[i];
+    values[i] = static_cast<T>(thrust::get<0>(v));
+    valids[i] = thrust::get<1>(v);
+  }
+}
+
+// isSupportedPredicate tells whether the predicate can be evaluated by the
+// vectorized kernels without touching any row.
+inline bool isSupportedPredicate(BinaryFunctorType functorType) {
+  return functorType <= GreaterThanOrEqual &&
+      GetSIMDLevel() != SIMD_DISABLED;
+}
+
+inline bool isSupportedPredicate(UnaryFunctorType functorType) {
+  return (functorType == Not || functorType == IsNull ||
+      functorType == IsNotNull || functorType == Noop) &&
+      GetSIMDLevel() != SIMD_DISABLED;
+}
+
+// BinaryPredicate evaluates the binary predicate over n rows of the iterators
+// chunk by chunk. Returns false without doing anything if the predicate or
+// value type is not supported.
+template<typename T, typename LHSIterator, typename RHSIterator>
+typename std::enable_if<IsSIMDType<T>::value, bool>::type
+BinaryPredicate(BinaryFunctorType functorType, LHSIterator lhsIter,
+                RHSIterator rhsIter, int n, uint8_t *predicates) {
+  if (!isSupportedPredicate(functorType)) {
+    return false;
+  }
+  T lhs[CHUNK_SIZE], rhs[CHUNK_SIZE];
+  uint8_t lhsValids[CHUNK_SIZE], rhsValids[CHUNK_SIZE];
+  for (int start = 0; start < n; start += CHUNK_SIZE) {
+    int size = n - start < CHUNK_SIZE ? n - start : CHUNK_SIZE;
+    gather(lhsIter + start, size, lhs, lhsValids);
+    gather(rhsIter + start, size, rhs, rhsValids);
+    EvalBinaryPredicate(functorType, lhs, lhsValids, rhs, rhsValids, size,
+                        predicates + start);
+  }
+  return true;
+}
+
+template<typename T, typename LHSIterator, typename RHSIterator>
+typename std::enable_if<!IsSIMDType<T>::value, bool>::type
+BinaryPredicate(BinaryFunctorType functorType, LHSIterator lhsIter,
+                RHSIterator rhsIter, int n, uint8_t *predicates) {
+  return false;
+}
+
+// UnaryPredicate is the unary version of BinaryPredicate.
+template<typename T, typename InputIterator>
+typename std::enable_if<IsSIMDType<T>::value, bool>::type
+UnaryPredicate(UnaryFunctorType functorType, InputIterator inputIter, int n,
+               uint8_t *predicates) {
+  if (!isSupportedPredicate(functorType)) {
+    return false;
+  }
+  T values[CHUNK_SIZE];
+  uint8_t valids[CHUNK_SIZE];
+  for (int start = 0; start < n; start += CHUNK_SIZE) {
+    int size = n - start < CHUNK_SIZE ? n - start : CHUNK_SIZE;
+    gather(inputIter + start, size, values, valids);
+    EvalUnaryPredicate(functorType, values, valids, size, predicates + start);
+  }
+  return true;
+}
+
+template<typename T, typename InputIterator>
+typename std::enable_if<!IsSIMDType<T>::value, bool>::type
+UnaryPredicate(UnaryFunctorType functorType, InputIterator inputIter, int n,
+               uint8_t *predicates) {
+  return false;
+}
+
+// Compact removes the elements whose predicate is false in place and returns
+// the number of elements kept. Elements are written unconditionally to avoid
+// branch mispredictions on selective predicates.
+template<typename Iterator>
+int Compact(Iterator begin, const uint8_t *predicates, int n) {
+  int numKept = 0;
+  for (int i = 0; i < n; i++) {
+    begin[numKept] = begin[i];
+    numKept += predicates[i] != 0;
+  }
+  return numKept;
+}
+
+}  // namespace simd
+}  // namespace ares
+#endif  // QUERY_SIMD_HPP_
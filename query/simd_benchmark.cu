@@ -0,0 +1,153 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Benchmark of host mode filter and aggregation with the SIMD kernels at each
+// level supported by the cpu against the generic functors. Usage:
+//   simd_benchmark [rows] [groups] [iterations]
+#include <chrono>
+#include <cstdio>
+#include <cstdlib>
+#include <cstring>
+#include <vector>
+#include "query/simd_kernels.hpp"
+#include "query/time_series_aggregate.h"
+
+namespace {
+
+const char *levelNames[] = {"disabled", "baseline", "avx2"};
+
+struct Timer {
+  std::chrono::steady_clock::time_point start;
+  double elapsedMillis = 0;
+
+  void Start() { start = std::chrono::steady_clock::now(); }
+
+  void Stop() {
+    elapsedMillis += std::chrono::duration<double, std::milli>(
+        std::chrono::steady_clock::now() - start).count();
+  }
+};
+
+bool checkResult(const CGoCallResHandle &resHandle) {
+  if (resHandle.pStrErr != nullptr) {
+    fprintf(stderr, "%s\n", resHandle.pStrErr);
+    free(const_cast<char *>(resHandle.pStrErr));
+    return false;
+  }
+  return true;
+}
+
+// benchmarkFilter filters rows of an int32 column less than a constant
+// selecting half of the rows.
+int benchmarkFilter(int rows, int iterations, double *millis) {
+  // values followed by nulls of a scratch space vector.
+  std::vector<uint8_t> column(rows * 5);
+  int32_t *values = reinterpret_cast<int32_t *>(&column[0]);
+  for (int i = 0; i < rows; i++) {
+    values[i] = rand() % 1000;
+  }
+  memset(&column[rows * 4], 1, rows);
+  std::vector<uint32_t> indexVector(rows);
+  std::vector<uint8_t> predicates(rows);
+
+  ScratchSpaceVector lhsColumn = {&column[0],
+                                  static_cast<uint32_t>(rows * 4), Int32};
+  ConstantVector rhsConstant = {{.IntVal = 500}, true, ConstInt};
+  InputVector lhs = {{.ScratchSpace = lhsColumn}, ScratchSpaceInput};
+  InputVector rhs = {{.Constant = rhsConstant}, ConstantInput};
+
+  Timer timer;
+  int length = 0;
+  for (int iter = 0; iter < iterations; iter++) {
+    for (int i = 0; i < rows; i++) {
+      indexVector[i] = i;
+    }
+    timer.Start();
+    CGoCallResHandle resHandle = BinaryFilter(
+        lhs, rhs, &indexVector[0], &predicates[0], rows, nullptr, 0, nullptr,
+        0, LessThan, nullptr, 0);
+    timer.Stop();
+    if (!checkResult(resHandle)) {
+      return -1;
+    }
+    length = static_cast<int>(reinterpret_cast<int64_t>(resHandle.res));
+  }
+  *millis = timer.elapsedMillis / iterations;
+  return length;
+}
+
+// benchmarkReduce sums int32 values of rows sorted by hash values of a single
+// 4 byte dimension.
+int benchmarkReduce(int rows, int groups, int iterations, double *millis) {
+  // dim values followed by dim nulls.
+  std::vector<uint8_t> inputDims(rows * 5), outputDims(rows * 5);
+  std::vector<uint64_t> inputHashValues(rows), outputHashValues(rows);
+  std::vector<uint32_t> inputIndexVector(rows), outputIndexVector(rows);
+  std::vector<int32_t> inputValues(rows), outputValues(rows);
+  for (int i = 0; i < rows; i++) {
+    uint32_t group = static_cast<uint64_t>(i) * groups / rows;
+    reinterpret_cast<uint32_t *>(&inputDims[0])[i] = group;
+    inputHashValues[i] = group;
+    inputIndexVector[i] = i;
+    inputValues[i] = rand() % 1000;
+  }
+  memset(&inputDims[rows * 4], 1, rows);
+
+  DimensionVector inputKeys = {&inputDims[0], &inputHashValues[0],
+                               &inputIndexVector[0], rows, {0, 0, 1, 0, 0}};
+  DimensionVector outputKeys = {&outputDims[0], &outputHashValues[0],
+                                &outputIndexVector[0], rows, {0, 0, 1, 0, 0}};
+
+  Timer timer;
+  int length = 0;
+  for (int iter = 0; iter < iterations; iter++) {
+    timer.Start();
+    CGoCallResHandle resHandle = Reduce(
+        inputKeys, reinterpret_cast<uint8_t *>(&inputValues[0]), outputKeys,
+        reinterpret_cast<uint8_t *>(&outputValues[0]), 4, rows,
+        AGGR_SUM_SIGNED, nullptr, 0);
+    timer.Stop();
+    if (!checkResult(resHandle)) {
+      return -1;
+    }
+    length = static_cast<int>(reinterpret_cast<int64_t>(resHandle.res));
+  }
+  *millis = timer.elapsedMillis / iterations;
+  return length;
+}
+
+}  // namespace
+
+int main(int argc, char **argv) {
+  int rows = argc > 1 ? atoi(argv[1]) : 1 << 22;
+  int groups = argc > 2 ? atoi(argv[2]) : 1000;
+  int iterations = argc > 3 ? atoi(argv[3]) : 20;
+
+  ares::simd::SIMDLevel detected = ares::simd::DetectSIMDLevel();
+  printf("rows=%d groups=%d iterations=%d detected=%s\n", rows, groups,
+         iterations, levelNames[detected]);
+  printf("%-10s %-8s %12s %14s %10s\n", "level", "op", "millis", "rows/sec",
+         "output");
+  for (int level = ares::simd::SIMD_DISABLED; level <= detected; level++) {
+    ares::simd::SetSIMDLevel(static_cast<ares::simd::SIMDLevel>(level));
+    double millis = 0;
+    int length = benchmarkFilter(rows, iterations, &millis);
+    printf("%-10s %-8s %12.3f %14.0f %10d\n", levelNames[level], "filter",
+           millis, rows / millis * 1000, length);
+    length = benchmarkReduce(rows, groups, iterations, &millis);
+    printf("%-10s %-8s %12.3f %14.0f %10d\n", levelNames[level], "reduce",
+           millis, rows / millis * 1000, length);
+  }
+  return 0;
+}
@@ -0,0 +1,105 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include <atomic>
+#include "query/simd_kernels.hpp"
+
+namespace ares {
+namespace simd {
+
+SIMDLevel DetectSIMDLevel() {
+#if defined(__x86_64__) || defined(__i386__)
+  __builtin_cpu_init();
+  if (__builtin_cpu_supports("avx2") && __builtin_cpu_supports("fma")) {
+    return SIMD_AVX2;
+  }
+#endif
+  return SIMD_BASELINE;
+}
+
+static std::atomic<int> &currentLevel() {
+  static std::atomic<int> level(DetectSIMDLevel());
+  return level;
+}
+
+SIMDLevel GetSIMDLevel() {
+  return static_cast<SIMDLevel>(currentLevel().load());
+}
+
+void SetSIMDLevel(SIMDLevel level) {
+  SIMDLevel detected = DetectSIMDLevel();
+  currentLevel().store(level > detected ? detected : level);
+}
+
+template<typename T>
+bool EvalBinaryPredicate(BinaryFunctorType functorType,
+                         const T *lhs, const uint8_t *lhsValids,
+                         const T *rhs, const uint8_t *rhsValids,
+                         int n, uint8_t *predicates) {
+  switch (GetSIMDLevel()) {
+    case SIMD_AVX2:
+      return avx2::EvalBinaryPredicate(functorType, lhs, lhsValids, rhs,
+                                       rhsValids, n, predicates);
+    case SIMD_BASELINE:
+      return baseline::EvalBinaryPredicate(functorType, lhs, lhsValids, rhs,
+                                           rhsValids, n, predicates);
+    default:return false;
+  }
+}
+
+template<typename T>
+bool EvalUnaryPredicate(UnaryFunctorType functorType,
+                        const T *values, const uint8_t *valids,
+                        int n, uint8_t *predicates) {
+  switch (GetSIMDLevel()) {
+    case SIMD_AVX2:
+      return avx2::EvalUnaryPredicate(functorType, values, valids, n,
+                                      predicates);
+    case SIMD_BASELINE:
+      return baseline::EvalUnaryPredicate(functorType, values, valids, n,
+                                          predicates);
+    default:return false;
+  }
+}
+
+template<typename T>
+int ReduceByKey(AggregateFunction aggFunc, const uint64_t *hashValues,
+                const uint32_t *indexVector, const T *values, int n,
+                uint32_t *outputIndexVector, T *outputValues) {
+  switch (GetSIMDLevel()) {
+    case SIMD_AVX2:
+      return avx2::ReduceByKey(aggFunc, hashValues, indexVector, values, n,
+                               outputIndexVector, outputValues);
+    case SIMD_BASELINE:
+      return baseline::ReduceByKey(aggFunc, hashValues, indexVector, values,
+                                   n, outputIndexVector, outputValues);
+    default:return -1;
+  }
+}
+
+#define INSTANTIATE_SIMD_DISPATCH(T) \
+  template bool EvalBinaryPredicate<T>(BinaryFunctorType, \
+      const T *, const uint8_t *, const T *, const uint8_t *, int, \
+      uint8_t *); \
+  template bool EvalUnaryPredicate<T>(UnaryFunctorType, \
+      const T *, const uint8_t *, int, uint8_t *); \
+  template int ReduceByKey<T>(AggregateFunction, const uint64_t *, \
+      const uint32_t *, const T *, int, uint32_t *, T *);
+
+FOR_EACH_SIMD_TYPE(INSTANTIATE_SIMD_DISPATCH)
+
+#undef INSTANTIATE_SIMD_DISPATCH
+
+}  // namespace simd
+}  // namespace ares
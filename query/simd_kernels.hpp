@@ -0,0 +1,119 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#ifndef QUERY_SIMD_KERNELS_HPP_
+#define QUERY_SIMD_KERNELS_HPP_
+
+#include <cstdint>
+#include <type_traits>
+#include "query/time_series_aggregate.h"
+
+// Host mode SIMD kernels over contiguous arrays. The kernels are compiled once
+// for the baseline instruction set of the target and once for AVX2
+// (query/simd_kernels_*.cpp), the variant to run is picked at runtime by the
+// features of the cpu, so that libraries built on one machine run on any
+// other machine of the same architecture.
+namespace ares {
+namespace simd {
+
+enum SIMDLevel {
+  // kernels are not used, callers fall back to the generic functors.
+  SIMD_DISABLED,
+  SIMD_BASELINE,
+  SIMD_AVX2,
+};
+
+// DetectSIMDLevel returns the best level supported by the cpu.
+SIMDLevel DetectSIMDLevel();
+
+// GetSIMDLevel returns the level kernels currently run with, which defaults to
+// DetectSIMDLevel.
+SIMDLevel GetSIMDLevel();
+
+// SetSIMDLevel overrides the level kernels run with, levels not supported by
+// the cpu are lowered to the detected level. Used by tests and benchmarks.
+void SetSIMDLevel(SIMDLevel level);
+
+// IsSIMDType tells whether kernels are instantiated for the value type.
+template<typename T>
+struct IsSIMDType : std::integral_constant<bool,
+    std::is_same<T, bool>::value ||
+    std::is_same<T, int8_t>::value || std::is_same<T, uint8_t>::value ||
+    std::is_same<T, int16_t>::value || std::is_same<T, uint16_t>::value ||
+    std::is_same<T, int32_t>::value || std::is_same<T, uint32_t>::value ||
+    std::is_same<T, int64_t>::value || std::is_same<T, uint64_t>::value ||
+    std::is_same<T, float>::value || std::is_same<T, double>::value> {
+};
+
+// EvalBinaryPredicate evaluates the binary predicate over n rows and writes
+// the results to predicates. Semantics follow the corresponding predicate
+// functors: the predicate is false if any operand is null except for Or which
+// is true if any valid operand is true. Returns false without doing anything
+// if the predicate is not supported.
+template<typename T>
+bool EvalBinaryPredicate(BinaryFunctorType functorType,
+                         const T *lhs, const uint8_t *lhsValids,
+                         const T *rhs, const uint8_t *rhsValids,
+                         int n, uint8_t *predicates);
+
+// EvalUnaryPredicate is the unary version of EvalBinaryPredicate.
+template<typename T>
+bool EvalUnaryPredicate(UnaryFunctorType functorType,
+                        const T *values, const uint8_t *valids,
+                        int n, uint8_t *predicates);
+
+// ReduceByKey reduces values of rows with the same hash value, rows with the
+// same hash value must be adjacent. values are read through indexVector. For
+// each run of rows, the reduced value is written to outputValues and the
+// index of the first row of the run is written to outputIndexVector, the same
+// as reduce by key with ReduceByHashFunctor. Returns the number of runs, or -1
+// if the aggregate function is not supported. outputValues and
+// outputIndexVector need to be able to hold n elements.
+template<typename T>
+int ReduceByKey(AggregateFunction aggFunc, const uint64_t *hashValues,
+                const uint32_t *indexVector, const T *values, int n,
+                uint32_t *outputIndexVector, T *outputValues);
+
+// Kernels of each level, the functions above dispatch to one of them.
+#define DECLARE_SIMD_KERNELS(level) \
+namespace level { \
+template<typename T> \
+bool EvalBinaryPredicate(BinaryFunctorType functorType, \
+                         const T *lhs, const uint8_t *lhsValids, \
+                         const T *rhs, const uint8_t *rhsValids, \
+                         int n, uint8_t *predicates); \
+template<typename T> \
+bool EvalUnaryPredicate(UnaryFunctorType functorType, \
+                        const T *values, const uint8_t *valids, \
+                        int n, uint8_t *predicates); \
+template<typename T> \
+int ReduceByKey(AggregateFunction aggFunc, const uint64_t *hashValues, \
+                const uint32_t *indexVector, const T *values, int n, \
+                uint32_t *outputIndexVector, T *outputValues); \
+}
+
+DECLARE_SIMD_KERNELS(baseline)
+DECLARE_SIMD_KERNELS(avx2)
+
+#undef DECLARE_SIMD_KERNELS
+
+}  // namespace simd
+}  // namespace ares
+
+// Types kernels are instantiated for, matching IsSIMDType.
+#define FOR_EACH_SIMD_TYPE(F) \
+  F(bool) F(int8_t) F(uint8_t) F(int16_t) F(uint16_t) F(int32_t) \
+  F(uint32_t) F(int64_t) F(uint64_t) F(float) F(double)
+
+#endif  // QUERY_SIMD_KERNELS_HPP_
@@ -0,0 +1,149 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include <thrust/iterator/counting_iterator.h>
+#include <thrust/iterator/zip_iterator.h>
+#include <thrust/tuple.h>
+#include <algorithm>
+#include <cmath>
+#include <cstdint>
+#include "gtest/gtest.h"
+#include "query/simd.hpp"
+
+namespace ares {
+namespace simd {
+
+// cppcheck-suppress *
+TEST(SIMDTest, CheckBinaryPredicate) {
+  const int n = CHUNK_SIZE + 3;
+  thrust::tuple<uint32_t, bool> lhs[n];
+  thrust::tuple<uint32_t, bool> rhs[n];
+  for (int i = 0; i < n; i++) {
+    lhs[i] = thrust::make_tuple(static_cast<uint32_t>(i % 4), i % 5 != 0);
+    rhs[i] = thrust::make_tuple(2u, true);
+  }
+
+  uint8_t predicates[n];
+  EXPECT_TRUE(BinaryPredicate<uint32_t>(LessThan, &lhs[0], &rhs[0], n,
+                                        &predicates[0]));
+  for (int i = 0; i < n; i++) {
+    EXPECT_EQ(predicates[i], static_cast<uint8_t>(i % 5 != 0 && i % 4 < 2));
+  }
+
+  EXPECT_TRUE(BinaryPredicate<uint32_t>(Or, &lhs[0], &rhs[0], n,
+                                        &predicates[0]));
+  for (int i = 0; i < n; i++) {
+    EXPECT_EQ(predicates[i], 1);
+  }
+
+  // arithmetic functors are not predicates.
+  EXPECT_FALSE(BinaryPredicate<uint32_t>(Plus, &lhs[0], &rhs[0], n,
+                                         &predicates[0]));
+}
+
+// cppcheck-suppress *
+TEST(SIMDTest, CheckUnaryPredicate) {
+  const int n = 5;
+  thrust::tuple<float_t, bool> input[n] = {
+      thrust::make_tuple(0.0f, true),
+      thrust::make_tuple(1.5f, true),
+      thrust::make_tuple(0.0f, false),
+      thrust::make_tuple(2.0f, false),
+      thrust::make_tuple(-1.0f, true),
+  };
+
+  uint8_t predicates[n];
+  EXPECT_TRUE(UnaryPredicate<float_t>(Not, &input[0], n, &predicates[0]));
+  uint8_t expectedNot[n] = {1, 0, 0, 0, 0};
+  EXPECT_TRUE(std::equal(predicates, predicates + n, expectedNot));
+
+  EXPECT_TRUE(UnaryPredicate<float_t>(IsNull, &input[0], n, &predicates[0]));
+  uint8_t expectedIsNull[n] = {0, 0, 1, 1, 0};
+  EXPECT_TRUE(std::equal(predicates, predicates + n, expectedIsNull));
+
+  EXPECT_FALSE(UnaryPredicate<float_t>(Negate, &input[0], n, &predicates[0]));
+}
+
+// cppcheck-suppress *
+TEST(SIMDTest, CheckCompact) {
+  uint32_t indexVector[6] = {10, 11, 12, 13, 14, 15};
+  uint8_t predicates[6] = {1, 0, 0, 1, 1, 0};
+  auto begin = thrust::make_zip_iterator(thrust::make_tuple(
+      thrust::counting_iterator<uint32_t>(0), &indexVector[0]));
+  EXPECT_EQ(Compact(begin, &predicates[0], 6), 3);
+  uint32_t expected[3] = {10, 13, 14};
+  EXPECT_TRUE(std::equal(indexVector, indexVector + 3, expected));
+}
+
+// cppcheck-suppress *
+TEST(SIMDTest, CheckReduceByKey) {
+  const int n = 6;
+  uint64_t hashValues[n] = {1, 1, 2, 2, 2, 3};
+  uint32_t indexVector[n] = {1, 3, 2, 4, 0, 5};
+  int32_t values[n] = {4, 5, -3, 2, 1, 6};
+  float floatValues[n] = {4.0, 5.0, -3.0, 2.0, 1.5, 6.0};
+
+  SIMDLevel detected = DetectSIMDLevel();
+  for (int level = SIMD_BASELINE; level <= detected; level++) {
+    SetSIMDLevel(static_cast<SIMDLevel>(level));
+    uint32_t outputIndexVector[n];
+    int32_t outputValues[n];
+    uint32_t expectedIndex[3] = {1, 2, 5};
+
+    EXPECT_EQ(ReduceByKey(AGGR_SUM_SIGNED, hashValues, indexVector, values,
+                          n, outputIndexVector, outputValues), 3);
+    int32_t expectedSum[3] = {7, 2, 6};
+    EXPECT_TRUE(std::equal(outputValues, outputValues + 3, expectedSum));
+    EXPECT_TRUE(std::equal(outputIndexVector, outputIndexVector + 3,
+                           expectedIndex));
+
+    EXPECT_EQ(ReduceByKey(AGGR_MIN_SIGNED, hashValues, indexVector, values,
+                          n, outputIndexVector, outputValues), 3);
+    int32_t expectedMin[3] = {2, -3, 6};
+    EXPECT_TRUE(std::equal(outputValues, outputValues + 3, expectedMin));
+
+    float outputFloatValues[n];
+    EXPECT_EQ(ReduceByKey(AGGR_MAX_FLOAT, hashValues, indexVector,
+                          floatValues, n, outputIndexVector,
+                          outputFloatValues), 3);
+    float expectedMax[3] = {5.0, 4.0, 6.0};
+    EXPECT_TRUE(std::equal(outputFloatValues, outputFloatValues + 3,
+                           expectedMax));
+
+    // rolling average needs counts along with values.
+    EXPECT_EQ(ReduceByKey(AGGR_AVG_FLOAT, hashValues, indexVector, values,
+                          n, outputIndexVector, outputValues), -1);
+  }
+  SetSIMDLevel(detected);
+}
+
+// cppcheck-suppress *
+TEST(SIMDTest, CheckSIMDLevel) {
+  SIMDLevel detected = DetectSIMDLevel();
+  EXPECT_EQ(GetSIMDLevel(), detected);
+
+  // levels above the detected one are not used.
+  SetSIMDLevel(SIMD_AVX2);
+  EXPECT_EQ(GetSIMDLevel(), detected);
+
+  thrust::tuple<int32_t, bool> input[1] = {thrust::make_tuple(1, true)};
+  uint8_t predicates[1];
+  SetSIMDLevel(SIMD_DISABLED);
+  EXPECT_FALSE(UnaryPredicate<int32_t>(Not, &input[0], 1, &predicates[0]));
+  SetSIMDLevel(detected);
+  EXPECT_TRUE(UnaryPredicate<int32_t>(Not, &input[0], 1, &predicates[0]));
+}
+
+}  // namespace simd
+}  // namespace ares
@@ -21,6 +21,7 @@
 #include <exception>
 #include "algorithm.hpp"
 #include "iterator.hpp"
+#include "query/simd_kernels.hpp"
 #include "query/time_series_aggregate.h"
 #include "memory.hpp"
 
@@ -136,7 +137,19 @@ template<typename Value, typename AggFunc>
 int reduceInternal(uint64_t *inputHashValues, uint32_t *inputIndexVector,
                    uint8_t *inputValues, uint64_t *outputHashValues,
                    uint32_t *outputIndexVector, uint8_t *outputValues,
-                   int length, cudaStream_t cudaStream) {
+                   int length, AggregateFunction aggFunction,
+                   cudaStream_t cudaStream) {
+#ifndef RUN_ON_DEVICE
+  // use the vectorized kernels for supported aggregate functions in host mode.
+  int numRuns = simd::ReduceByKey(aggFunction, inputHashValues,
+                                  inputIndexVector,
+                                  reinterpret_cast<Value *>(inputValues),
+                                  length, outputIndexVector,
+                                  reinterpret_cast<Value *>(outputValues));
+  if (numRuns >= 0) {
+    return numRuns;
+  }
+#endif
   thrust::equal_to<uint64_t> binaryPred;
   AggFunc aggFunc;
   ReduceByHashFunctor<AggFunc> reduceFunc(aggFunc);
@@ -177,6 +190,7 @@ int bindValueAndAggFunc(uint64_t *inputHashValues,
             outputIndexVector, \
             outputValues, \
             length, \
+            aggFunc, \
             cudaStream);
 
     case AGGR_SUM_UNSIGNED:
@@ -200,13 +214,21 @@ int bindValueAndAggFunc(uint64_t *inputHashValues,
     case AGGR_MIN_UNSIGNED:
       REDUCE_INTERNAL(uint32_t, thrust::minimum<uint32_t>)
     case AGGR_MIN_SIGNED:
-      REDUCE_INTERNAL(int32_t, thrust::minimum<int32_t>)
+      if (valueBytes == 4) {
+        REDUCE_INTERNAL(int32_t, thrust::minimum<int32_t>)
+      } else {
+        REDUCE_INTERNAL(int64_t, thrust::minimum<int64_t>)
+      }
     case AGGR_MIN_FLOAT:
       REDUCE_INTERNAL(float_t, thrust::minimum<float_t>)
     case AGGR_MAX_UNSIGNED:
       REDUCE_INTERNAL(uint32_t, thrust::maximum<uint32_t>)
     case AGGR_MAX_SIGNED:
-      REDUCE_INTERNAL(int32_t, thrust::maximum<int32_t>)
+      if (valueBytes == 4) {
+        REDUCE_INTERNAL(int32_t, thrust::maximum<int32_t>)
+      } else {
+        REDUCE_INTERNAL(int64_t, thrust::maximum<int64_t>)
+      }
     case AGGR_MAX_FLOAT:
       REDUCE_INTERNAL(float_t, thrust::maximum<float_t>)
     case AGGR_AVG_FLOAT:
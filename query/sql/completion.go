@@ -0,0 +1,500 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+	"github.com/uber/aresdb/query/sql/antlrgen"
+	"github.com/uber/aresdb/query/sql/util"
+)
+
+// Kinds of completion candidates.
+const (
+	CompletionKeyword  = "keyword"
+	CompletionTable    = "table"
+	CompletionColumn   = "column"
+	CompletionFunction = "function"
+	CompletionValue    = "value"
+)
+
+// cursorTokenType is the type of the token emitted at the cursor position. DELIMITER is
+// declared by the grammar but never produced by the lexer nor matched by any rule, so the
+// parser always stops at the cursor token.
+const cursorTokenType = antlrgen.SqlBaseParserDELIMITER
+
+// scalarFunctions are the non aggregate functions supported by the query engine.
+var scalarFunctions = []string{
+	"contains", "convert_tz", "countdistincthll", "dayofweek", "element_at", "from_unixtime",
+	"geography_intersects", "hex", "hour", "length", "map_values",
+}
+
+// CompletionSchema provides the tables and columns visible to the user for completion.
+type CompletionSchema interface {
+	// TableNames returns names of all tables.
+	TableNames() []string
+	// Columns returns names of columns of the table, nil if the table does not exist.
+	Columns(table string) []string
+	// EnumCases returns the enum cases of the column, nil if it's not an enum column.
+	EnumCases(table, column string) []string
+}
+
+// CompletionCandidate is a completion candidate at the cursor.
+type CompletionCandidate struct {
+	Text string `json:"text"`
+	// one of keyword, table, column, function and value.
+	Kind string `json:"kind"`
+	// table of the column or the value.
+	Table string `json:"table,omitempty"`
+}
+
+// Completion is the completion result at the cursor.
+type Completion struct {
+	// Prefix is the partial word before the cursor candidates are filtered by, editors should
+	// replace it with the chosen candidate.
+	Prefix     string                `json:"prefix"`
+	Candidates []CompletionCandidate `json:"candidates"`
+}
+
+// completionLexer emits the probe token if any and the cursor token before EOF.
+type completionLexer struct {
+	*antlrgen.SqlBaseLexer
+	probe         int
+	probeEmitted  bool
+	cursorEmitted bool
+}
+
+// NextToken implements antlr.TokenSource.
+func (l *completionLexer) NextToken() antlr.Token {
+	token := l.SqlBaseLexer.NextToken()
+	if token.GetTokenType() != antlr.TokenEOF || l.cursorEmitted {
+		return token
+	}
+	tokenType := cursorTokenType
+	if l.probe != antlr.TokenInvalidType && !l.probeEmitted {
+		tokenType = l.probe
+		l.probeEmitted = true
+	} else {
+		l.cursorEmitted = true
+	}
+	emitted := antlr.NewCommonToken(token.GetSource(), tokenType, antlr.TokenDefaultChannel,
+		token.GetStart(), token.GetStop())
+	emitted.SetText("")
+	return emitted
+}
+
+// cursorReached is panicked by completionErrorStrategy to stop parsing once the parser
+// reaches the cursor.
+type cursorReached struct{}
+
+// completionErrorStrategy records the tokens expected by the parser at the cursor, and
+// whether the parser failed before reaching the cursor.
+type completionErrorStrategy struct {
+	*antlr.DefaultErrorStrategy
+	expected *antlr.IntervalSet
+	failed   bool
+}
+
+func (s *completionErrorStrategy) atCursor(recognizer antlr.Parser) {
+	if recognizer.GetTokenStream().LA(1) == cursorTokenType {
+		s.expected = recognizer.GetExpectedTokens()
+		panic(cursorReached{})
+	}
+}
+
+// Sync implements antlr.ErrorStrategy.
+func (s *completionErrorStrategy) Sync(recognizer antlr.Parser) {
+	s.atCursor(recognizer)
+	s.DefaultErrorStrategy.Sync(recognizer)
+}
+
+// RecoverInline implements antlr.ErrorStrategy.
+func (s *completionErrorStrategy) RecoverInline(recognizer antlr.Parser) antlr.Token {
+	s.atCursor(recognizer)
+	return s.DefaultErrorStrategy.RecoverInline(recognizer)
+}
+
+// ReportError implements antlr.ErrorStrategy.
+func (s *completionErrorStrategy) ReportError(recognizer antlr.Parser, e antlr.RecognitionException) {
+	if token := e.GetOffendingToken(); token != nil && token.GetTokenType() == cursorTokenType {
+		s.expected = recognizer.GetExpectedTokens()
+		panic(cursorReached{})
+	}
+	s.failed = true
+	s.DefaultErrorStrategy.ReportError(recognizer, e)
+}
+
+// expectedTokenTypes parses the token types out of the interval set, since antlr does not
+// expose its intervals.
+func expectedTokenTypes(set *antlr.IntervalSet, numTypes int) map[int]bool {
+	types := make(map[int]bool)
+	if set == nil {
+		return types
+	}
+	// names are token types so that the types can be parsed out of the string.
+	names := make([]string, numTypes)
+	for i := range names {
+		names[i] = strconv.Itoa(i)
+	}
+	str := strings.Trim(set.StringVerbose(nil, names, false), "{}")
+	for _, name := range strings.Split(str, ", ") {
+		if t, err := strconv.Atoi(name); err == nil {
+			types[t] = true
+		}
+	}
+	return types
+}
+
+// tokenize returns the tokens of sql on the default channel, excluding EOF.
+func tokenize(sql string) (tokens []antlr.Token) {
+	lexer := antlrgen.NewSqlBaseLexer(util.NewCaseChangingStream(antlr.NewInputStream(sql), true))
+	lexer.RemoveErrorListeners()
+	for token := lexer.NextToken(); token.GetTokenType() != antlr.TokenEOF; token = lexer.NextToken() {
+		if token.GetChannel() == antlr.TokenDefaultChannel {
+			tokens = append(tokens, token)
+		}
+	}
+	return
+}
+
+// expectedTokens parses sql followed by the cursor and returns the types of tokens
+// expected at the cursor and the literal names of token types. The parser fails to predict
+// alternatives needing lookahead beyond the cursor, e.g. comparisons at "col = ", in which
+// case each token type is probed by parsing sql followed by the token and the cursor.
+func expectedTokens(sql string) (types map[int]bool, literalNames []string, err error) {
+	expected, failed, literalNames, numTypes, err := parseToCursor(sql, antlr.TokenInvalidType)
+	if err != nil || !failed {
+		return expectedTokenTypes(expected, numTypes), literalNames, err
+	}
+
+	types = make(map[int]bool)
+	for t := 1; t < numTypes; t++ {
+		if t == cursorTokenType {
+			continue
+		}
+		expected, failed, _, _, err = parseToCursor(sql, t)
+		if err != nil {
+			return
+		}
+		if !failed && expected != nil {
+			types[t] = true
+		}
+	}
+	return
+}
+
+// parseToCursor parses sql followed by the probe token unless it's invalid and the cursor,
+// returns the tokens expected at the cursor, whether the parser failed before reaching the
+// cursor, and the literal names and number of token types.
+func parseToCursor(sql string, probe int) (expected *antlr.IntervalSet, failed bool, literalNames []string,
+	numTypes int, err error) {
+	lexer := &completionLexer{
+		SqlBaseLexer: antlrgen.NewSqlBaseLexer(util.NewCaseChangingStream(antlr.NewInputStream(sql), true)),
+		probe:        probe,
+	}
+	lexer.RemoveErrorListeners()
+	p := antlrgen.NewSqlBaseParser(antlr.NewCommonTokenStream(lexer, antlr.TokenDefaultChannel))
+	p.RemoveErrorListeners()
+	strategy := &completionErrorStrategy{DefaultErrorStrategy: antlr.NewDefaultErrorStrategy()}
+	p.SetErrorHandler(strategy)
+	p.GetInterpreter().SetPredictionMode(antlr.PredictionModeSLL)
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(cursorReached); ok {
+					return
+				}
+				if e, ok := r.(error); ok {
+					err = e
+				} else {
+					err = fmt.Errorf("unknown error, reason: %v", r)
+				}
+			}
+		}()
+		p.Statement()
+	}()
+	return strategy.expected, strategy.failed, p.LiteralNames, len(p.SymbolicNames), err
+}
+
+func isIdentifierToken(t int) bool {
+	switch t {
+	case antlrgen.SqlBaseParserIDENTIFIER, antlrgen.SqlBaseParserDIGIT_IDENTIFIER,
+		antlrgen.SqlBaseParserQUOTED_IDENTIFIER, antlrgen.SqlBaseParserBACKQUOTED_IDENTIFIER:
+		return true
+	}
+	return false
+}
+
+func isKeywordToken(t int) bool {
+	return t >= antlrgen.SqlBaseParserADD && t <= antlrgen.SqlBaseParserZONE
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// identifierText returns the name of an identifier token with quotes removed.
+func identifierText(token antlr.Token) string {
+	text := token.GetText()
+	switch token.GetTokenType() {
+	case antlrgen.SqlBaseParserQUOTED_IDENTIFIER, antlrgen.SqlBaseParserBACKQUOTED_IDENTIFIER:
+		return text[1 : len(text)-1]
+	}
+	return text
+}
+
+// tableRefs returns tables referenced in the from and join clauses keyed by their aliases
+// and names.
+func tableRefs(tokens []antlr.Token) map[string]string {
+	refs := make(map[string]string)
+	inFrom := false
+	for i, token := range tokens {
+		switch token.GetTokenType() {
+		case antlrgen.SqlBaseParserFROM:
+			inFrom = true
+		case antlrgen.SqlBaseParserWHERE, antlrgen.SqlBaseParserGROUP, antlrgen.SqlBaseParserORDER,
+			antlrgen.SqlBaseParserLIMIT, antlrgen.SqlBaseParserON, antlrgen.SqlBaseParserSELECT:
+			inFrom = false
+		}
+		if !isIdentifierToken(token.GetTokenType()) || i == 0 {
+			continue
+		}
+		prev := tokens[i-1].GetTokenType()
+		if prev != antlrgen.SqlBaseParserFROM && prev != antlrgen.SqlBaseParserJOIN &&
+			!(prev == antlrgen.SqlBaseParserT__0 && inFrom) {
+			continue
+		}
+		table := identifierText(token)
+		refs[table] = table
+		next := i + 1
+		if next < len(tokens) && tokens[next].GetTokenType() == antlrgen.SqlBaseParserAS {
+			next++
+		}
+		if next < len(tokens) && isIdentifierToken(tokens[next].GetTokenType()) {
+			refs[identifierText(tokens[next])] = table
+		}
+	}
+	return refs
+}
+
+// lastClause returns the type of the last clause keyword in tokens.
+func lastClause(tokens []antlr.Token) int {
+	for i := len(tokens) - 1; i >= 0; i-- {
+		switch t := tokens[i].GetTokenType(); t {
+		case antlrgen.SqlBaseParserSELECT, antlrgen.SqlBaseParserFROM, antlrgen.SqlBaseParserWHERE,
+			antlrgen.SqlBaseParserGROUP, antlrgen.SqlBaseParserORDER, antlrgen.SqlBaseParserHAVING,
+			antlrgen.SqlBaseParserLIMIT, antlrgen.SqlBaseParserON, antlrgen.SqlBaseParserJOIN:
+			return t
+		}
+	}
+	return antlr.TokenInvalidType
+}
+
+// columnRef reads a possibly qualified column reference ending at tokens[end], returns the
+// qualifier and the column.
+func columnRef(tokens []antlr.Token, end int) (qualifier, column string, ok bool) {
+	if end < 0 || !isIdentifierToken(tokens[end].GetTokenType()) {
+		return
+	}
+	column, ok = identifierText(tokens[end]), true
+	if end >= 2 && tokens[end-1].GetTokenType() == antlrgen.SqlBaseParserT__3 &&
+		isIdentifierToken(tokens[end-2].GetTokenType()) {
+		qualifier = identifierText(tokens[end-2])
+	}
+	return
+}
+
+// comparedColumn returns the column compared with the value at the end of tokens in
+// col = value, col != value and col [NOT] IN (value, ...) predicates.
+func comparedColumn(tokens []antlr.Token) (qualifier, column string, ok bool) {
+	i := len(tokens) - 1
+	if i < 0 {
+		return
+	}
+	switch tokens[i].GetTokenType() {
+	case antlrgen.SqlBaseParserEQ, antlrgen.SqlBaseParserNEQ:
+		return columnRef(tokens, i-1)
+	case antlrgen.SqlBaseParserT__0, antlrgen.SqlBaseParserT__1:
+		for ; i >= 0 && tokens[i].GetTokenType() != antlrgen.SqlBaseParserT__1; i-- {
+			switch tokens[i].GetTokenType() {
+			case antlrgen.SqlBaseParserT__0, antlrgen.SqlBaseParserSTRING:
+			default:
+				return
+			}
+		}
+		if i < 1 || tokens[i-1].GetTokenType() != antlrgen.SqlBaseParserIN {
+			return
+		}
+		i -= 2
+		if i >= 0 && tokens[i].GetTokenType() == antlrgen.SqlBaseParserNOT {
+			i--
+		}
+		return columnRef(tokens, i)
+	}
+	return
+}
+
+// Complete returns the completion candidates at the cursor, which is the byte offset into
+// sql. Candidates are computed from the tokens the parser expects at the cursor: keywords
+// are returned as is, identifiers are resolved to tables after FROM and JOIN, and to
+// columns of referenced tables and functions elsewhere, strings compared with enum
+// columns are resolved to enum cases.
+func Complete(sql string, cursor int, schema CompletionSchema) (completion Completion, err error) {
+	if cursor < 0 || cursor > len(sql) {
+		err = fmt.Errorf("cursor %d out of range [0, %d]", cursor, len(sql))
+		return
+	}
+	completion.Candidates = []CompletionCandidate{}
+
+	// the partial word before the cursor is excluded from parsing and used to filter
+	// candidates, unclosed string literals are treated as partial words.
+	start := cursor
+	inString := strings.Count(strings.Replace(sql[:cursor], "''", "", -1), "'")%2 == 1
+	if inString {
+		start = strings.LastIndex(sql[:cursor], "'")
+		completion.Prefix = strings.Replace(sql[start+1:cursor], "''", "'", -1)
+	} else {
+		for start > 0 && isIdentifierChar(sql[start-1]) {
+			start--
+		}
+		completion.Prefix = sql[start:cursor]
+	}
+
+	expected, literalNames, err := expectedTokens(sql[:start])
+	if err != nil {
+		return
+	}
+	tokens := tokenize(sql[:start])
+	refs := tableRefs(tokenize(sql))
+
+	seen := make(map[CompletionCandidate]bool)
+	add := func(candidate CompletionCandidate) {
+		if seen[candidate] || !strings.HasPrefix(strings.ToLower(candidate.Text), strings.ToLower(completion.Prefix)) {
+			return
+		}
+		seen[candidate] = true
+		completion.Candidates = append(completion.Candidates, candidate)
+	}
+	addColumns := func(table string) {
+		for _, column := range schema.Columns(table) {
+			add(CompletionCandidate{Text: column, Kind: CompletionColumn, Table: table})
+		}
+	}
+
+	addValues := func(quoted bool) {
+		if !expected[antlrgen.SqlBaseParserSTRING] {
+			return
+		}
+		qualifier, column, ok := comparedColumn(tokens)
+		if !ok {
+			return
+		}
+		for _, table := range resolveTables(refs, qualifier) {
+			for _, value := range schema.EnumCases(table, column) {
+				if quoted {
+					value = "'" + strings.Replace(value, "'", "''", -1) + "'"
+				}
+				add(CompletionCandidate{Text: value, Kind: CompletionValue, Table: table})
+			}
+		}
+	}
+
+	if inString {
+		addValues(false)
+		return
+	}
+
+	// qualified column references.
+	if n := len(tokens); n >= 2 && tokens[n-1].GetTokenType() == antlrgen.SqlBaseParserT__3 &&
+		isIdentifierToken(tokens[n-2].GetTokenType()) {
+		if table, ok := refs[identifierText(tokens[n-2])]; ok {
+			addColumns(table)
+		}
+		return
+	}
+
+	types := make([]int, 0, len(expected))
+	identifierExpected := false
+	for t := range expected {
+		types = append(types, t)
+		identifierExpected = identifierExpected || isIdentifierToken(t)
+	}
+	sort.Ints(types)
+
+	if identifierExpected {
+		prev := antlr.TokenInvalidType
+		if len(tokens) > 0 {
+			prev = tokens[len(tokens)-1].GetTokenType()
+		}
+		if prev == antlrgen.SqlBaseParserFROM || prev == antlrgen.SqlBaseParserJOIN ||
+			prev == antlrgen.SqlBaseParserT__0 && lastClause(tokens) == antlrgen.SqlBaseParserFROM {
+			tables := schema.TableNames()
+			sort.Strings(tables)
+			for _, table := range tables {
+				add(CompletionCandidate{Text: table, Kind: CompletionTable})
+			}
+		} else {
+			for _, table := range resolveTables(refs, "") {
+				addColumns(table)
+			}
+			functions := append([]string{}, scalarFunctions...)
+			for function := range util.AggregateFunctions {
+				functions = append(functions, function)
+			}
+			for function := range util.UdfTable {
+				functions = append(functions, function)
+			}
+			sort.Strings(functions)
+			for _, function := range functions {
+				add(CompletionCandidate{Text: function, Kind: CompletionFunction})
+			}
+		}
+	}
+
+	addValues(true)
+
+	for _, t := range types {
+		if isKeywordToken(t) && t < len(literalNames) {
+			add(CompletionCandidate{Text: strings.Trim(literalNames[t], "'"), Kind: CompletionKeyword})
+		}
+	}
+	return
+}
+
+// resolveTables returns the table referenced by the qualifier, or all referenced tables
+// sorted by name if qualifier is empty.
+func resolveTables(refs map[string]string, qualifier string) []string {
+	if qualifier != "" {
+		if table, ok := refs[qualifier]; ok {
+			return []string{table}
+		}
+		return nil
+	}
+	tableSet := make(map[string]bool)
+	for _, table := range refs {
+		tableSet[table] = true
+	}
+	tables := make([]string, 0, len(tableSet))
+	for table := range tableSet {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
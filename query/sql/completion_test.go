@@ -0,0 +1,112 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type testCompletionSchema map[string][]string
+
+func (s testCompletionSchema) TableNames() []string {
+	var tables []string
+	for table := range s {
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+func (s testCompletionSchema) Columns(table string) []string {
+	return s[table]
+}
+
+func (s testCompletionSchema) EnumCases(table, column string) []string {
+	if table == "trips" && column == "status" {
+		return []string{"completed", "cancelled"}
+	}
+	return nil
+}
+
+var _ = ginkgo.Describe("SQL Completion", func() {
+	schema := testCompletionSchema{
+		"trips":    {"request_at", "status", "city_id", "fare"},
+		"products": {"name"},
+	}
+
+	complete := func(sql string) Completion {
+		completion, err := Complete(sql, len(sql), schema)
+		Ω(err).Should(BeNil())
+		return completion
+	}
+
+	ginkgo.It("completes tables after from", func() {
+		completion := complete("SELECT count(*) FROM tr")
+		Ω(completion.Prefix).Should(Equal("tr"))
+		Ω(completion.Candidates).Should(ContainElement(CompletionCandidate{Text: "trips", Kind: CompletionTable}))
+		Ω(completion.Candidates).ShouldNot(ContainElement(CompletionCandidate{Text: "products", Kind: CompletionTable}))
+	})
+
+	ginkgo.It("completes columns of referenced tables and functions", func() {
+		completion := complete("SELECT count(*) FROM trips WHERE st")
+		Ω(completion.Prefix).Should(Equal("st"))
+		Ω(completion.Candidates).Should(ContainElement(CompletionCandidate{Text: "status", Kind: CompletionColumn, Table: "trips"}))
+		Ω(completion.Candidates).ShouldNot(ContainElement(CompletionCandidate{Text: "name", Kind: CompletionColumn, Table: "products"}))
+
+		completion = complete("SELECT cou")
+		Ω(completion.Candidates).Should(ContainElement(CompletionCandidate{Text: "count", Kind: CompletionFunction}))
+	})
+
+	ginkgo.It("completes keywords expected by the parser", func() {
+		completion := complete("SELECT count(*) FROM trips WH")
+		Ω(completion.Candidates).Should(ContainElement(CompletionCandidate{Text: "WHERE", Kind: CompletionKeyword}))
+
+		completion = complete("SELECT count(*) FROM trips WHERE status = 'completed' GROUP ")
+		Ω(completion.Candidates).Should(Equal([]CompletionCandidate{{Text: "BY", Kind: CompletionKeyword}}))
+	})
+
+	ginkgo.It("completes columns of aliased tables", func() {
+		sql := "SELECT t. FROM trips t"
+		completion, err := Complete(sql, len("SELECT t."), schema)
+		Ω(err).Should(BeNil())
+		Ω(completion.Prefix).Should(BeEmpty())
+		Ω(completion.Candidates).Should(Equal([]CompletionCandidate{
+			{Text: "request_at", Kind: CompletionColumn, Table: "trips"},
+			{Text: "status", Kind: CompletionColumn, Table: "trips"},
+			{Text: "city_id", Kind: CompletionColumn, Table: "trips"},
+			{Text: "fare", Kind: CompletionColumn, Table: "trips"},
+		}))
+	})
+
+	ginkgo.It("completes enum values", func() {
+		completion := complete("SELECT count(*) FROM trips WHERE status = 'co")
+		Ω(completion.Prefix).Should(Equal("co"))
+		Ω(completion.Candidates).Should(Equal([]CompletionCandidate{{Text: "completed", Kind: CompletionValue, Table: "trips"}}))
+
+		completion = complete("SELECT count(*) FROM trips WHERE status IN ('completed', '")
+		Ω(completion.Candidates).Should(Equal([]CompletionCandidate{
+			{Text: "completed", Kind: CompletionValue, Table: "trips"},
+			{Text: "cancelled", Kind: CompletionValue, Table: "trips"},
+		}))
+
+		completion = complete("SELECT count(*) FROM trips WHERE status = ")
+		Ω(completion.Candidates).Should(ContainElement(CompletionCandidate{Text: "'cancelled'", Kind: CompletionValue, Table: "trips"}))
+	})
+
+	ginkgo.It("rejects cursor out of range", func() {
+		_, err := Complete("SELECT", 7, schema)
+		Ω(err).ShouldNot(BeNil())
+	})
+})
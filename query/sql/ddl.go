@@ -0,0 +1,160 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	metaCom "github.com/uber/aresdb/metastore/common"
+)
+
+// DDLType is the type of schema mutation of a DDL statement.
+type DDLType string
+
+// DDL statement types.
+const (
+	// CREATE [FACT | DIMENSION] TABLE table (column type, ..., [PRIMARY KEY (column, ...)])
+	CreateTable DDLType = "createTable"
+	// DROP TABLE table
+	DropTable DDLType = "dropTable"
+	// ALTER TABLE table ADD [COLUMN] column type
+	AddColumn DDLType = "addColumn"
+	// ALTER TABLE table DROP [COLUMN] column
+	DropColumn DDLType = "dropColumn"
+)
+
+const identifierPattern = `([A-Za-z_][A-Za-z0-9_]*)`
+
+var (
+	createTableRegex = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:(FACT|DIMENSION)\s+)?TABLE\s+` + identifierPattern + `\s*\((.*)\)[\s;]*$`)
+	dropTableRegex   = regexp.MustCompile(`(?is)^\s*DROP\s+TABLE\s+` + identifierPattern + `[\s;]*$`)
+	addColumnRegex   = regexp.MustCompile(`(?is)^\s*ALTER\s+TABLE\s+` + identifierPattern + `\s+ADD\s+(?:COLUMN\s+)?` + identifierPattern + `\s+(\S+)[\s;]*$`)
+	dropColumnRegex  = regexp.MustCompile(`(?is)^\s*ALTER\s+TABLE\s+` + identifierPattern + `\s+DROP\s+(?:COLUMN\s+)?` + identifierPattern + `[\s;]*$`)
+	columnDefRegex   = regexp.MustCompile(`(?is)^` + identifierPattern + `\s+(\S+)$`)
+	primaryKeyRegex  = regexp.MustCompile(`(?is)^PRIMARY\s+KEY\s*\((.*)\)$`)
+)
+
+// DDL represents a statement mutating table schemas.
+type DDL struct {
+	Type DDLType
+	// Table to mutate, columns and primary key are only set for CREATE TABLE.
+	Table metaCom.Table
+	// Column to add or drop for ALTER TABLE, only the name is set for DROP COLUMN.
+	Column metaCom.Column
+}
+
+// IsDDL tells whether the sql is a CREATE, ALTER or DROP statement.
+func IsDDL(sql string) bool {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "CREATE", "ALTER", "DROP":
+		return true
+	}
+	return false
+}
+
+// ParseDDL parses DDL statement. Column types are the data types of the schema API, e.g. Uint32,
+// SmallEnum or Map<String,Float32>, and are validated with the table by the metastore.
+func ParseDDL(sql string) (*DDL, error) {
+	if matches := createTableRegex.FindStringSubmatch(sql); matches != nil {
+		return parseCreateTable(matches[2], strings.EqualFold(matches[1], "FACT"), matches[3])
+	}
+	if matches := dropTableRegex.FindStringSubmatch(sql); matches != nil {
+		return &DDL{Type: DropTable, Table: metaCom.Table{Name: matches[1]}}, nil
+	}
+	if matches := addColumnRegex.FindStringSubmatch(sql); matches != nil {
+		return &DDL{
+			Type:   AddColumn,
+			Table:  metaCom.Table{Name: matches[1]},
+			Column: metaCom.Column{Name: matches[2], Type: matches[3]},
+		}, nil
+	}
+	if matches := dropColumnRegex.FindStringSubmatch(sql); matches != nil {
+		return &DDL{
+			Type:   DropColumn,
+			Table:  metaCom.Table{Name: matches[1]},
+			Column: metaCom.Column{Name: matches[2]},
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported DDL statement, expect CREATE [FACT | DIMENSION] TABLE, " +
+		"ALTER TABLE ... ADD | DROP COLUMN or DROP TABLE")
+}
+
+// parseCreateTable parses the column definitions and the primary key of CREATE TABLE.
+func parseCreateTable(tableName string, isFactTable bool, definitions string) (*DDL, error) {
+	table := metaCom.Table{
+		Name:        tableName,
+		IsFactTable: isFactTable,
+	}
+
+	var primaryKey []string
+	for _, definition := range splitDefinitions(definitions) {
+		if matches := primaryKeyRegex.FindStringSubmatch(definition); matches != nil {
+			if primaryKey != nil {
+				return nil, fmt.Errorf("multiple primary keys in CREATE TABLE %s", tableName)
+			}
+			for _, column := range strings.Split(matches[1], ",") {
+				primaryKey = append(primaryKey, strings.TrimSpace(column))
+			}
+			continue
+		}
+		matches := columnDefRegex.FindStringSubmatch(definition)
+		if matches == nil {
+			return nil, fmt.Errorf("invalid column definition '%s' in CREATE TABLE %s, expect column type", definition, tableName)
+		}
+		table.Columns = append(table.Columns, metaCom.Column{Name: matches[1], Type: matches[2]})
+	}
+
+	for _, columnName := range primaryKey {
+		columnID := -1
+		for id, column := range table.Columns {
+			if column.Name == columnName {
+				columnID = id
+				break
+			}
+		}
+		if columnID < 0 {
+			return nil, fmt.Errorf("primary key column '%s' is not defined in CREATE TABLE %s", columnName, tableName)
+		}
+		table.PrimaryKeyColumns = append(table.PrimaryKeyColumns, columnID)
+	}
+	return &DDL{Type: CreateTable, Table: table}, nil
+}
+
+// splitDefinitions splits definitions by commas outside of parentheses and angle brackets,
+// since types like Map<String,Float32> and primary keys contain commas.
+func splitDefinitions(definitions string) []string {
+	var results []string
+	depth, start := 0, 0
+	for i, c := range definitions {
+		switch c {
+		case '(', '<':
+			depth++
+		case ')', '>':
+			depth--
+		case ',':
+			if depth == 0 {
+				results = append(results, strings.TrimSpace(definitions[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(results, strings.TrimSpace(definitions[start:]))
+}
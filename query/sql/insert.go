@@ -0,0 +1,102 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+	"github.com/uber/aresdb/common"
+	"github.com/uber/aresdb/query/sql/antlrgen"
+	"github.com/uber/aresdb/query/sql/tree"
+	"github.com/uber/aresdb/query/sql/util"
+)
+
+// insertRegex matches INSERT INTO table [(column, ...)] followed by the VALUES clause.
+var insertRegex = regexp.MustCompile(`(?is)^\s*INSERT\s+INTO\s+([A-Za-z_][A-Za-z0-9_]*)\s*(?:\(([^)]*)\))?\s*(VALUES\b.*?)[\s;]*$`)
+
+// Insert represents an INSERT INTO table [(column, ...)] VALUES (...), ... statement.
+type Insert struct {
+	Table string
+	// columns of the values, empty for all columns of the table in schema order.
+	Columns []string
+	// values are either float64, string or nil.
+	Rows [][]interface{}
+}
+
+// IsInsert tells whether the sql is an INSERT statement.
+func IsInsert(sql string) bool {
+	fields := strings.Fields(sql)
+	return len(fields) > 0 && strings.EqualFold(fields[0], "INSERT")
+}
+
+// ParseInsert parses INSERT statement. The grammar only supports queries, so the table and
+// columns are extracted here while the VALUES clause is parsed as an inline table query.
+func ParseInsert(sql string, logger common.Logger) (insert *Insert, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var ok bool
+			err, ok = r.(error)
+			if !ok {
+				err = fmt.Errorf("unkonwn error, reason: %v", r)
+			}
+		}
+	}()
+
+	matches := insertRegex.FindStringSubmatch(sql)
+	if matches == nil {
+		return nil, fmt.Errorf("expect INSERT INTO table [(column, ...)] VALUES (...), ...")
+	}
+	insert = &Insert{Table: matches[1]}
+	if strings.TrimSpace(matches[2]) != "" {
+		for _, column := range strings.Split(matches[2], ",") {
+			column = strings.TrimSpace(column)
+			if column == "" {
+				return nil, fmt.Errorf("empty column name in INSERT INTO %s", insert.Table)
+			}
+			insert.Columns = append(insert.Columns, column)
+		}
+	}
+
+	is := util.NewCaseChangingStream(antlr.NewInputStream(matches[3]), true)
+	lexer := antlrgen.NewSqlBaseLexer(is)
+	stream := antlr.NewCommonTokenStream(lexer, antlr.TokenDefaultChannel)
+	p := antlrgen.NewSqlBaseParser(stream)
+	p.GetInterpreter().SetPredictionMode(antlr.PredictionModeSLL)
+	inlineTable := getQueryInlineTable(p.Query())
+	if inlineTable == nil || stream.LA(1) != antlr.TokenEOF {
+		return nil, fmt.Errorf("expect VALUES (...), ... in INSERT INTO %s", insert.Table)
+	}
+
+	v := &ASTBuilder{
+		Logger:  logger,
+		IStream: stream,
+	}
+	values, _ := v.VisitInlineTable(inlineTable).(*tree.Values)
+	numColumns := len(insert.Columns)
+	for _, row := range values.Rows {
+		if numColumns == 0 {
+			numColumns = len(row)
+		}
+		if len(row) != numColumns {
+			return nil, fmt.Errorf("expect %d values per row in INSERT INTO %s, but got %d",
+				numColumns, insert.Table, len(row))
+		}
+	}
+	insert.Rows = values.Rows
+	return insert, nil
+}
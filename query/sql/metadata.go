@@ -0,0 +1,156 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	metaCom "github.com/uber/aresdb/metastore/common"
+	queryCom "github.com/uber/aresdb/query/common"
+)
+
+// MetadataStatementType is the type of metadata statement.
+type MetadataStatementType string
+
+// Metadata statement types.
+const (
+	// SHOW TABLES
+	ShowTables MetadataStatementType = "showTables"
+	// DESCRIBE table, DESC table or SHOW COLUMNS FROM table
+	DescribeTable MetadataStatementType = "describeTable"
+)
+
+var (
+	showTablesRegex    = regexp.MustCompile(`(?is)^\s*SHOW\s+TABLES[\s;]*$`)
+	describeTableRegex = regexp.MustCompile(`(?is)^\s*(?:DESCRIBE|DESC|SHOW\s+COLUMNS\s+FROM)\s+` + identifierPattern + `[\s;]*$`)
+)
+
+// Headers of metadata statement results.
+var (
+	showTablesHeaders    = []string{"table", "type", "primaryKey", "recordRetentionInDays"}
+	describeTableHeaders = []string{"column", "type", "primaryKey", "hll", "defaultValue"}
+)
+
+// MetadataStatement represents a statement introspecting table schemas.
+type MetadataStatement struct {
+	Type MetadataStatementType
+	// Table to describe, only set for DESCRIBE.
+	Table string
+}
+
+// IsMetadataStatement tells whether the sql is a SHOW or DESCRIBE statement.
+func IsMetadataStatement(sql string) bool {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SHOW", "DESCRIBE", "DESC":
+		return true
+	}
+	return false
+}
+
+// ParseMetadataStatement parses SHOW TABLES and DESCRIBE table statements.
+func ParseMetadataStatement(sql string) (*MetadataStatement, error) {
+	if showTablesRegex.MatchString(sql) {
+		return &MetadataStatement{Type: ShowTables}, nil
+	}
+	if matches := describeTableRegex.FindStringSubmatch(sql); matches != nil {
+		return &MetadataStatement{Type: DescribeTable, Table: matches[1]}, nil
+	}
+	return nil, fmt.Errorf("unsupported metadata statement, expect SHOW TABLES, DESCRIBE table or SHOW COLUMNS FROM table")
+}
+
+// Execute answers the statement from table schemas in metaStore as a non aggregation query
+// result, so clients can read it the same way as results of SELECT queries.
+func (s *MetadataStatement) Execute(metaStore metaCom.MetaStore) (queryCom.AQLQueryResult, error) {
+	result := make(queryCom.AQLQueryResult)
+	switch s.Type {
+	case ShowTables:
+		tableNames, err := metaStore.ListTables()
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(tableNames)
+		result.SetHeaders(showTablesHeaders)
+		for _, tableName := range tableNames {
+			table, err := metaStore.GetTable(tableName)
+			if err != nil {
+				return nil, err
+			}
+			result.Append(showTableRow(table))
+		}
+	case DescribeTable:
+		table, err := metaStore.GetTable(s.Table)
+		if err != nil {
+			return nil, err
+		}
+		result.SetHeaders(describeTableHeaders)
+		for columnID, column := range table.Columns {
+			if column.Deleted {
+				continue
+			}
+			result.Append(describeColumnRow(table, columnID))
+		}
+	}
+	return result, nil
+}
+
+// showTableRow returns the row of the table in SHOW TABLES result.
+func showTableRow(table *metaCom.Table) []*string {
+	tableType := "dimension"
+	if table.IsFactTable {
+		tableType = "fact"
+	}
+	primaryKey := make([]string, len(table.PrimaryKeyColumns))
+	for i, columnID := range table.PrimaryKeyColumns {
+		primaryKey[i] = table.Columns[columnID].Name
+	}
+	return []*string{
+		&table.Name,
+		&tableType,
+		stringPtr(strings.Join(primaryKey, ",")),
+		stringPtr(strconv.Itoa(table.Config.RecordRetentionInDays)),
+	}
+}
+
+// describeColumnRow returns the row of the column in DESCRIBE result, the primary key is the
+// position of the column in the primary key starting from 1, or NULL if it's not a key column.
+func describeColumnRow(table *metaCom.Table, columnID int) []*string {
+	column := table.Columns[columnID]
+	var primaryKey *string
+	for i, keyColumnID := range table.PrimaryKeyColumns {
+		if keyColumnID == columnID {
+			primaryKey = stringPtr(strconv.Itoa(i + 1))
+			break
+		}
+	}
+	return []*string{
+		&column.Name,
+		&column.Type,
+		primaryKey,
+		stringPtr(strconv.FormatBool(column.HLLConfig.IsHLLColumn)),
+		column.DefaultValue,
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -93,6 +93,7 @@ type SQL2AqlContext struct {
 	mapKey             int
 	timeNow            int64
 	timeFilter         queryCom.TimeFilter
+	timeRange          *timeRange
 	timezone           string
 	exprOrigin         ExprOrigin
 	fromJSON           []byte
@@ -707,13 +708,21 @@ func (v *ASTBuilder) VisitBooleanDefault(ctx *antlrgen.BooleanDefaultContext) in
 	}
 
 	v.Logger.Debugf("VisitBooleanDefault: %s", ctx.GetText())
-	if v.SQL2AqlCtx.exprOrigin == ExprOriginWhere && !strings.HasPrefix(v.getText(ctx), _aqlPrefix) {
+	text := v.getText(ctx.BaseParserRuleContext)
+	if predicated, ok := ctx.Predicated().(*antlrgen.PredicatedContext); ok {
+		if between, ok := predicated.Predicate().(*antlrgen.BetweenContext); ok {
+			text = v.getBetweenFilter(predicated, between)
+		}
+	}
+	if len(text) == 0 {
+		// predicate is translated into the time filter.
+	} else if v.SQL2AqlCtx.exprOrigin == ExprOriginWhere && !strings.HasPrefix(text, _aqlPrefix) {
 		v.SQL2AqlCtx.MapRowFilters[v.SQL2AqlCtx.mapKey] =
-			append(v.SQL2AqlCtx.MapRowFilters[v.SQL2AqlCtx.mapKey], v.getText(ctx.BaseParserRuleContext))
+			append(v.SQL2AqlCtx.MapRowFilters[v.SQL2AqlCtx.mapKey], text)
 	} else if v.SQL2AqlCtx.exprOrigin == ExprOriginJoinOn {
 		last := len(v.SQL2AqlCtx.MapJoinTables[v.SQL2AqlCtx.mapKey]) - 1
 		v.SQL2AqlCtx.MapJoinTables[v.SQL2AqlCtx.mapKey][last].Conditions =
-			append(v.SQL2AqlCtx.MapJoinTables[v.SQL2AqlCtx.mapKey][last].Conditions, v.getText(ctx.BaseParserRuleContext))
+			append(v.SQL2AqlCtx.MapJoinTables[v.SQL2AqlCtx.mapKey][last].Conditions, text)
 	}
 
 	expr := tree.NewExpression(v.getLocation(ctx))
@@ -722,6 +731,52 @@ func (v *ASTBuilder) VisitBooleanDefault(ctx *antlrgen.BooleanDefaultContext) in
 	return expr
 }
 
+// getBetweenFilter translates BETWEEN predicate in where clause with time literals on both ends
+// into the time filter and returns empty filter; otherwise it returns the filter comparing with
+// both ends, as AQL expressions don't support BETWEEN.
+func (v *ASTBuilder) getBetweenFilter(predicated *antlrgen.PredicatedContext, between *antlrgen.BetweenContext) string {
+	value := v.getText(predicated.ValueExpression())
+	lower := v.getText(between.GetLower())
+	upper := v.getText(between.GetUpper())
+
+	if between.NOT() != nil {
+		return fmt.Sprintf("(%s < %s OR %s > %s)", value, lower, value, upper)
+	}
+
+	if v.SQL2AqlCtx.exprOrigin == ExprOriginWhere && isTimeLiteral(lower) && isTimeLiteral(upper) {
+		location := v.getLocation(predicated)
+		if v.SQL2AqlCtx.timeRange != nil {
+			panic(fmt.Errorf("only one BETWEEN time range is allowed, got another one at (line:%d, col:%d)",
+				location.Line, location.CharPosition))
+		}
+		v.SQL2AqlCtx.timeRange = &timeRange{
+			column: value,
+			from:   lower,
+			to:     upper,
+			line:   location.Line,
+			col:    location.CharPosition,
+		}
+		return ""
+	}
+	return fmt.Sprintf("%s >= %s AND %s <= %s", value, lower, value, upper)
+}
+
+// setTimeRangeFilter resolves the BETWEEN time range in the query timezone into the time filter.
+func (v *ASTBuilder) setTimeRangeFilter() {
+	if v.SQL2AqlCtx.timeRange == nil {
+		return
+	}
+	timeFilter, err := v.SQL2AqlCtx.timeRange.toTimeFilter(v.SQL2AqlCtx.timezone)
+	if err != nil {
+		panic(err)
+	}
+	if v.SQL2AqlCtx.timeFilter != (queryCom.TimeFilter{}) && v.SQL2AqlCtx.timeFilter != timeFilter {
+		panic(fmt.Errorf("different timefilter on %s at (line:%d, col:%d)",
+			v.SQL2AqlCtx.timeRange.column, v.SQL2AqlCtx.timeRange.line, v.SQL2AqlCtx.timeRange.col))
+	}
+	v.SQL2AqlCtx.timeFilter = timeFilter
+}
+
 // VisitLogicalNot visits the node
 func (v *ASTBuilder) VisitLogicalNot(ctx *antlrgen.LogicalNotContext) interface{} {
 	v.Logger.Debugf("VisitLogicalNot check: %s", ctx.GetText())
@@ -852,6 +907,14 @@ func (v *ASTBuilder) VisitAliasedRelation(ctx *antlrgen.AliasedRelationContext)
 			queryCom.Join{})
 	}
 
+	// handle inline table
+	if inlineTableCtx := v.getInlineTableContext(ctx.RelationPrimary()); inlineTableCtx != nil {
+		aliasedRelation := v.visitAliasedInlineTable(ctx, inlineTableCtx)
+		v.setCtxLevels(v.SQL2AqlCtx, level-1, levelWith, levelQuery)
+		v.SQL2AqlCtx.mapKey = mapKey
+		return aliasedRelation
+	}
+
 	// handle relationPrimary
 	v.setCtxLevels(v.SQL2AqlCtx, level, levelWith, levelQuery)
 	child, _ := v.Visit(ctx.RelationPrimary()).(tree.IRelation)
@@ -903,6 +966,111 @@ func (v *ASTBuilder) VisitAliasedRelation(ctx *antlrgen.AliasedRelationContext)
 	return aliasedRelation
 }
 
+// getInlineTableContext returns the inline table if the relation is a plain VALUES subquery.
+func (v *ASTBuilder) getInlineTableContext(ctx antlrgen.IRelationPrimaryContext) *antlrgen.InlineTableContext {
+	subqueryRelation, ok := ctx.(*antlrgen.SubqueryRelationContext)
+	if !ok {
+		return nil
+	}
+	return getQueryInlineTable(subqueryRelation.Query())
+}
+
+// getQueryInlineTable returns the inline table if the query is a plain VALUES query.
+func getQueryInlineTable(ctx antlrgen.IQueryContext) *antlrgen.InlineTableContext {
+	query, ok := ctx.(*antlrgen.QueryContext)
+	if !ok || query.With() != nil {
+		return nil
+	}
+	queryNoWith, ok := query.QueryNoWith().(*antlrgen.QueryNoWithContext)
+	if !ok || queryNoWith.ORDER() != nil || queryNoWith.LIMIT() != nil {
+		return nil
+	}
+	queryTerm, ok := queryNoWith.QueryTerm().(*antlrgen.QueryTermDefaultContext)
+	if !ok {
+		return nil
+	}
+	inlineTable, _ := queryTerm.QueryPrimary().(*antlrgen.InlineTableContext)
+	return inlineTable
+}
+
+// visitAliasedInlineTable adds the inline table to the last join of current query. Inline tables
+// are materialized by broker so they can only be joined against tables, and need alias with column names.
+func (v *ASTBuilder) visitAliasedInlineTable(ctx *antlrgen.AliasedRelationContext, inlineTableCtx *antlrgen.InlineTableContext) *tree.AliasedRelation {
+	location := v.getLocation(ctx)
+	joins := v.SQL2AqlCtx.MapJoinTables[v.SQL2AqlCtx.mapKey]
+	last := len(joins) - 1
+	if last == 0 || len(joins[0].Table) == 0 {
+		panic(fmt.Errorf("inline table can only be joined against tables at (line:%d, col:%d)",
+			location.Line, location.CharPosition))
+	}
+
+	ctxColumnAliases, ok := ctx.ColumnAliases().(*antlrgen.ColumnAliasesContext)
+	if ctx.Identifier() == nil || !ok {
+		panic(fmt.Errorf("inline table requires alias with column names at (line:%d, col:%d)",
+			location.Line, location.CharPosition))
+	}
+
+	values, _ := v.VisitInlineTable(inlineTableCtx).(*tree.Values)
+	ctxArr := ctxColumnAliases.AllIdentifier()
+	columns := make([]string, len(ctxArr))
+	columnAliases := make([]*tree.Identifier, len(ctxArr))
+	for i, c := range ctxArr {
+		columns[i] = v.getText(c)
+		columnAliases[i], _ = v.Visit(c).(*tree.Identifier)
+	}
+	for _, row := range values.Rows {
+		if len(row) != len(columns) {
+			panic(fmt.Errorf("expect %d values per inline table row, but got %d at (line:%d, col:%d)",
+				len(columns), len(row), location.Line, location.CharPosition))
+		}
+	}
+	joins[last].InlineTable = &queryCom.InlineTable{
+		Columns: columns,
+		Rows:    values.Rows,
+	}
+
+	identifier, _ := v.Visit(ctx.Identifier()).(*tree.Identifier)
+	aliasedRelation := tree.NewAliasedRelation(location, values, identifier, columnAliases)
+	aliasedRelation.SetValue(fmt.Sprintf("AliasedRelation: (%s)", v.getText(ctx.BaseParserRuleContext)))
+	return aliasedRelation
+}
+
+// getInlineTableRow returns values of an inline table row, which is either a literal or a row constructor.
+func (v *ASTBuilder) getInlineTableRow(ctx antlrgen.IExpressionContext) []interface{} {
+	var node antlr.Tree = ctx
+	for node.GetChildCount() == 1 {
+		node = node.GetChild(0)
+	}
+	rowConstructor, ok := node.(*antlrgen.RowConstructorContext)
+	if !ok {
+		return []interface{}{v.getInlineTableValue(ctx)}
+	}
+
+	ctxArr := rowConstructor.AllExpression()
+	row := make([]interface{}, len(ctxArr))
+	for i, c := range ctxArr {
+		row[i] = v.getInlineTableValue(c)
+	}
+	return row
+}
+
+// getInlineTableValue converts a literal of inline table into number, string or nil.
+func (v *ASTBuilder) getInlineTableValue(ctx antlrgen.IExpressionContext) interface{} {
+	text := v.getText(ctx)
+	if strings.EqualFold(text, "NULL") {
+		return nil
+	}
+	if number, err := strconv.ParseFloat(text, 64); err == nil {
+		return number
+	}
+	if len(text) >= 2 && text[0] == '\'' && text[len(text)-1] == '\'' {
+		return strings.Replace(text[1:len(text)-1], "''", "'", -1)
+	}
+	location := v.getLocation(ctx)
+	panic(fmt.Errorf("expect number, string or null literal in inline table at (line:%d, col:%d)",
+		location.Line, location.CharPosition))
+}
+
 // VisitTableName visits the node
 func (v *ASTBuilder) VisitTableName(ctx *antlrgen.TableNameContext) interface{} {
 	v.Logger.Debugf("VisitTableName: %s", ctx.GetText())
@@ -1088,8 +1256,16 @@ func (v *ASTBuilder) VisitTable(ctx *antlrgen.TableContext) interface{} {
 
 // VisitInlineTable visits the node
 func (v *ASTBuilder) VisitInlineTable(ctx *antlrgen.InlineTableContext) interface{} {
-	location := v.getLocation(ctx)
-	panic(fmt.Errorf("inlineTable at (line:%d, col:%d) not supported yet", location.Line, location.CharPosition))
+	v.Logger.Debugf("VisitInlineTable: %s", ctx.GetText())
+
+	ctxArr := ctx.AllExpression()
+	rows := make([][]interface{}, len(ctxArr))
+	for i, c := range ctxArr {
+		rows[i] = v.getInlineTableRow(c)
+	}
+	values := tree.NewValues(v.getLocation(ctx), rows)
+	values.SetValue(fmt.Sprintf("Values: (%s)", v.getText(ctx.BaseParserRuleContext)))
+	return values
 }
 
 // VisitSubquery visits the node
@@ -1541,14 +1717,19 @@ func (v *ASTBuilder) setNumericBucketizer(ctx []antlrgen.IExpressionContext, def
 
 // mergeWithOrSubQueries merge all subquery/withQuery's information into v.aql
 func (v *ASTBuilder) mergeWithOrSubQueries() {
+	// matched records level 0 measures selecting a measure of subquery/withQuery directly,
+	// aliases records aliased measures of aggregate subquery/withQuery.
+	matched := make(map[int]bool)
+	aliases := make(map[string]queryCom.Measure)
 	for i, join := range v.SQL2AqlCtx.MapJoinTables[0] {
 		withOrSubQKey, _ := v.SQL2AqlCtx.queryIdentifierSet[join.Alias]
 		if i == 0 {
-			v.mergeWithOrSubQuery(withOrSubQKey, false)
+			v.mergeWithOrSubQuery(withOrSubQKey, false, matched, aliases)
 		} else {
-			v.mergeWithOrSubQuery(withOrSubQKey, true)
+			v.mergeWithOrSubQuery(withOrSubQKey, true, matched, aliases)
 		}
 	}
+	v.inlineWithOrSubQueryAliases(matched, aliases)
 
 	v.aql.Measures = v.SQL2AqlCtx.MapMeasures[0]
 	v.aql.Dimensions = v.SQL2AqlCtx.MapDimensions[0]
@@ -1562,22 +1743,44 @@ func (v *ASTBuilder) mergeWithOrSubQueries() {
 }
 
 // mergeWithOrSubQuery merge one subquery/withQuery information into v.aql
-func (v *ASTBuilder) mergeWithOrSubQuery(key int, ignoreJoin bool) {
+func (v *ASTBuilder) mergeWithOrSubQuery(key int, ignoreJoin bool, matched map[int]bool, aliases map[string]queryCom.Measure) {
 	if !ignoreJoin {
 		v.aql.Table = v.SQL2AqlCtx.MapJoinTables[key][0].Table
 		v.aql.Joins = v.SQL2AqlCtx.MapJoinTables[key][1:]
 	}
 
+	if !v.isAggregateQuery(key) {
+		// the subquery/withQuery only selects rows, so it's inlined into level 0 query:
+		// its filters apply to level 0 query and its aliased columns are replaced by their expressions.
+		v.SQL2AqlCtx.MapRowFilters[0] = append(v.SQL2AqlCtx.MapRowFilters[0], v.SQL2AqlCtx.MapRowFilters[key]...)
+		for _, measure := range v.SQL2AqlCtx.MapMeasures[key] {
+			if len(measure.Alias) == 0 {
+				continue
+			}
+			for i, mainMeasure := range v.SQL2AqlCtx.MapMeasures[0] {
+				v.SQL2AqlCtx.MapMeasures[0][i].Expr = replaceIdentifier(mainMeasure.Expr, measure.Alias, measure.Expr)
+			}
+			for i, dimension := range v.SQL2AqlCtx.MapDimensions[0] {
+				v.SQL2AqlCtx.MapDimensions[0][i].Expr = replaceIdentifier(dimension.Expr, measure.Alias, measure.Expr)
+			}
+		}
+		return
+	}
+
 	for i, measure := range v.SQL2AqlCtx.MapMeasures[key] {
+		measure.Filters = v.SQL2AqlCtx.MapRowFilters[key]
+		if len(measure.Alias) != 0 {
+			aliases[measure.Alias] = measure
+		}
 		if index := v.isMeasureInMain(key, i); index > -1 {
 			// case1: this measure of subquery/withQuery is not a supportingMeasure
 			v.SQL2AqlCtx.MapMeasures[0][index].Filters = v.SQL2AqlCtx.MapRowFilters[key]
+			matched[index] = true
 			if len(v.SQL2AqlCtx.MapDimensions[0]) == 0 {
 				v.SQL2AqlCtx.MapDimensions[0] = v.SQL2AqlCtx.MapDimensions[key]
 			}
 		} else {
 			// case2: this measure of subquery/withQuery is a supportingMeasure
-			measure.Filters = v.SQL2AqlCtx.MapRowFilters[key]
 			v.aql.SupportingMeasures = append(v.aql.SupportingMeasures, measure)
 		}
 		if len(v.SQL2AqlCtx.MapDimensions[0]) == 0 && len(v.SQL2AqlCtx.MapDimensions[key]) != 0 {
@@ -1589,6 +1792,61 @@ func (v *ASTBuilder) mergeWithOrSubQuery(key int, ignoreJoin bool) {
 	}
 }
 
+// isAggregateQuery check whether subquery/withQuery groups or aggregates rows
+func (v *ASTBuilder) isAggregateQuery(key int) bool {
+	if len(v.SQL2AqlCtx.MapDimensions[key]) != 0 {
+		return true
+	}
+	for _, measure := range v.SQL2AqlCtx.MapMeasures[key] {
+		if containsAggregateCall(measure.Expr) {
+			return true
+		}
+	}
+	return false
+}
+
+// inlineWithOrSubQueryAliases replaces aliases of aggregate subquery/withQuery measures referenced in
+// expressions of level 0 measures by the aggregate expressions, as long as all aggregates referenced
+// by a level 0 measure share the same filters; supporting measures that are no longer referenced are removed.
+func (v *ASTBuilder) inlineWithOrSubQueryAliases(matched map[int]bool, aliases map[string]queryCom.Measure) {
+	for i, measure := range v.SQL2AqlCtx.MapMeasures[0] {
+		if matched[i] {
+			continue
+		}
+		expr := measure.Expr
+		var filters []string
+		referenced := false
+		inlinable := true
+		for alias, aliasedMeasure := range aliases {
+			if !containsIdentifier(expr, alias) {
+				continue
+			}
+			if referenced && !equalFilters(filters, aliasedMeasure.Filters) {
+				inlinable = false
+				break
+			}
+			expr = replaceIdentifier(expr, alias, aliasedMeasure.Expr)
+			filters = aliasedMeasure.Filters
+			referenced = true
+		}
+		if referenced && inlinable {
+			v.SQL2AqlCtx.MapMeasures[0][i].Expr = expr
+			v.SQL2AqlCtx.MapMeasures[0][i].Filters = filters
+		}
+	}
+
+	supportingMeasures := v.aql.SupportingMeasures[:0]
+	for _, supportingMeasure := range v.aql.SupportingMeasures {
+		for _, measure := range v.SQL2AqlCtx.MapMeasures[0] {
+			if len(supportingMeasure.Alias) != 0 && containsIdentifier(measure.Expr, supportingMeasure.Alias) {
+				supportingMeasures = append(supportingMeasures, supportingMeasure)
+				break
+			}
+		}
+	}
+	v.aql.SupportingMeasures = supportingMeasures
+}
+
 // isMeasureInMain check a measure of subquery/withQuery is also a measure of level 0 query
 // return the index of the measure in level 0 query; otherwise return -1
 func (v *ASTBuilder) isMeasureInMain(key, index int) int {
@@ -1611,6 +1869,99 @@ func (v *ASTBuilder) isMeasureInMain(key, index int) int {
 	return -1
 }
 
+// scanIdentifiers calls fn with the start and end offsets of each unqualified identifier outside of
+// quotes in expr.
+func scanIdentifiers(expr string, fn func(start, end int)) {
+	isIdentifierChar := func(c byte) bool {
+		return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+	}
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return
+			}
+			i += end + 2
+		case isIdentifierChar(c):
+			start := i
+			for i < len(expr) && isIdentifierChar(expr[i]) {
+				i++
+			}
+			if (start == 0 || expr[start-1] != '.') && (c < '0' || c > '9') {
+				fn(start, i)
+			}
+		default:
+			i++
+		}
+	}
+}
+
+// containsIdentifier check whether expr references identifier name
+func containsIdentifier(expr, name string) bool {
+	found := false
+	scanIdentifiers(expr, func(start, end int) {
+		found = found || strings.EqualFold(expr[start:end], name)
+	})
+	return found
+}
+
+// replaceIdentifier replaces references of identifier name in expr by replacement expression
+func replaceIdentifier(expr, name, replacement string) string {
+	if strings.EqualFold(strings.TrimSpace(expr), name) {
+		return replacement
+	}
+	if !isSimpleIdentifier(replacement) {
+		replacement = "(" + replacement + ")"
+	}
+	var buffer bytes.Buffer
+	last := 0
+	scanIdentifiers(expr, func(start, end int) {
+		if strings.EqualFold(expr[start:end], name) {
+			buffer.WriteString(expr[last:start])
+			buffer.WriteString(replacement)
+			last = end
+		}
+	})
+	buffer.WriteString(expr[last:])
+	return buffer.String()
+}
+
+// isSimpleIdentifier check whether expr is a single unqualified identifier
+func isSimpleIdentifier(expr string) bool {
+	simple := false
+	scanIdentifiers(expr, func(start, end int) {
+		simple = start == 0 && end == len(expr)
+	})
+	return simple
+}
+
+// containsAggregateCall check whether expr calls an aggregate function
+func containsAggregateCall(expr string) bool {
+	found := false
+	scanIdentifiers(expr, func(start, end int) {
+		if util.AggregateFunctions[strings.ToLower(expr[start:end])] &&
+			strings.HasPrefix(strings.TrimSpace(expr[end:]), "(") {
+			found = true
+		}
+	})
+	return found
+}
+
+// equalFilters check whether two lists of filters are the same
+func equalFilters(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // GetAQL construct AQLQuery via read through SQL2AqlCtx
 func (v *ASTBuilder) GetAQL() *queryCom.AQLQuery {
 	var (
@@ -1618,6 +1969,8 @@ func (v *ASTBuilder) GetAQL() *queryCom.AQLQuery {
 		joins []queryCom.Join
 	)
 
+	v.setTimeRangeFilter()
+
 	if len(v.SQL2AqlCtx.MapQueryIdentifier) == 0 {
 		// there is no subquery/withQuery in sql
 		table = v.SQL2AqlCtx.MapJoinTables[0][0].Table
@@ -1638,19 +1991,6 @@ func (v *ASTBuilder) GetAQL() *queryCom.AQLQuery {
 			Limit:      v.SQL2AqlCtx.MapLimit[0],
 			Sorts:      v.SQL2AqlCtx.MapOrderBy[0],
 		}
-		// remove measures that should be dimensions
-		dimsMap := make(map[string]bool)
-		for _, d := range v.aql.Dimensions {
-			dimsMap[d.Expr] = true
-		}
-		measuresOld := v.aql.Measures
-		v.aql.Measures = []queryCom.Measure{}
-		for _, m := range measuresOld {
-			if !dimsMap[m.Expr] {
-				v.aql.Measures = append(v.aql.Measures, m)
-			}
-		}
-
 	} else {
 		v.aql = &queryCom.AQLQuery{
 			SupportingMeasures:   make([]queryCom.Measure, 0, defaultSliceCap),
@@ -1659,6 +1999,19 @@ func (v *ASTBuilder) GetAQL() *queryCom.AQLQuery {
 		v.mergeWithOrSubQueries()
 	}
 
+	// remove measures that should be dimensions
+	dimsMap := make(map[string]bool)
+	for _, d := range v.aql.Dimensions {
+		dimsMap[d.Expr] = true
+	}
+	measuresOld := v.aql.Measures
+	v.aql.Measures = []queryCom.Measure{}
+	for _, m := range measuresOld {
+		if !dimsMap[m.Expr] {
+			v.aql.Measures = append(v.aql.Measures, m)
+		}
+	}
+
 	return v.aql
 }
 
@@ -1787,6 +2140,9 @@ func (v *ASTBuilder) isQueryFromMixed(s2aCtx *SQL2AqlContext) (bool, error) {
 		flagTable = true
 	}
 	for i, join := range s2aCtx.MapJoinTables[0] {
+		if join.InlineTable != nil {
+			continue
+		}
 		if flagTable && len(join.Table) == 0 {
 			err = fmt.Errorf("# %d should be a tablename in from clause", i)
 			return true, err
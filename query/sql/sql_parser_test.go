@@ -18,6 +18,7 @@ import (
 	"github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/uber/aresdb/common"
+	metaCom "github.com/uber/aresdb/metastore/common"
 	queryCom "github.com/uber/aresdb/query/common"
 )
 
@@ -254,6 +255,44 @@ var _ = ginkgo.Describe("SQL Parser", func() {
 		runTest(sqls, res, logger)
 	})
 
+	ginkgo.It("parse inline table join should work", func() {
+		sqls := []string{
+			`SELECT t.label, count(*)
+			FROM trips
+				LEFT JOIN (VALUES (1, 'sf'), (2, 'new york'), (-3, NULL)) AS t(id, label) ON t.id=city_id
+			GROUP BY t.label`,
+		}
+		res := queryCom.AQLQuery{
+			Table: "trips",
+			Joins: []queryCom.Join{
+				{
+					Alias:      "t",
+					Conditions: []string{"t.id=city_id"},
+					InlineTable: &queryCom.InlineTable{
+						Columns: []string{"id", "label"},
+						Rows:    [][]interface{}{{1.0, "sf"}, {2.0, "new york"}, {-3.0, nil}},
+					},
+				},
+			},
+			Measures:   []queryCom.Measure{{Expr: "count(*)"}},
+			Dimensions: []queryCom.Dimension{{Expr: "t.label"}},
+		}
+		runTest(sqls, res, logger)
+	})
+
+	ginkgo.It("parse inline table join should fail without column names", func() {
+		sqls := []string{
+			`SELECT t.label, count(*) FROM trips LEFT JOIN (VALUES (1, 'sf')) AS t ON t.id=city_id GROUP BY t.label`,
+			`SELECT t.label, count(*) FROM trips LEFT JOIN (VALUES (1, 'sf')) AS t(id) ON t.id=city_id GROUP BY t.label`,
+			`SELECT count(*) FROM (VALUES (1, 'sf')) AS t(id, label)`,
+		}
+		for _, sql := range sqls {
+			actual, err := Parse(sql, logger)
+			Ω(err).ShouldNot(BeNil())
+			Ω(actual).Should(BeNil())
+		}
+	})
+
 	ginkgo.It("parse composite measures should work", func() {
 		sqls := []string{
 			// test SubQuery
@@ -385,6 +424,41 @@ var _ = ginkgo.Describe("SQL Parser", func() {
 		}
 	})
 
+	ginkgo.It("parse with queries should inline them into main query", func() {
+		sqls := []string{
+			`WITH t AS (SELECT city_id, fare AS f FROM trips WHERE status='completed')
+			SELECT city_id, sum(f) FROM t GROUP BY city_id`,
+			`SELECT city_id, sum(f)
+			FROM (SELECT city_id, fare AS f FROM trips WHERE status='completed') AS t
+			GROUP BY city_id`,
+		}
+		res := queryCom.AQLQuery{
+			Table:                "trips",
+			Joins:                []queryCom.Join{},
+			Measures:             []queryCom.Measure{{Expr: "sum(fare)"}},
+			Dimensions:           []queryCom.Dimension{{Expr: "city_id"}},
+			Filters:              []string{"status='completed'"},
+			SupportingDimensions: []queryCom.Dimension{},
+			SupportingMeasures:   []queryCom.Measure{},
+		}
+		runTest(sqls, res, logger)
+
+		sqls = []string{
+			`WITH m1 AS (SELECT count(*) AS trips, sum(fare) AS fares
+			FROM trips WHERE status='completed' GROUP BY city_id)
+			SELECT fares/trips FROM m1`,
+		}
+		res = queryCom.AQLQuery{
+			Table:                "trips",
+			Joins:                []queryCom.Join{},
+			Measures:             []queryCom.Measure{{Expr: "(sum(fare))/(count(*))", Filters: []string{"status='completed'"}}},
+			Dimensions:           []queryCom.Dimension{{Expr: "city_id"}},
+			SupportingDimensions: []queryCom.Dimension{},
+			SupportingMeasures:   []queryCom.Measure{},
+		}
+		runTest(sqls, res, logger)
+	})
+
 	ginkgo.It("With RECURSIVE is not allowed", func() {
 		sqls := []string{
 			`WITH RECURSIVE t(n) AS (
@@ -545,6 +619,45 @@ var _ = ginkgo.Describe("SQL Parser", func() {
 
 	})
 
+	ginkgo.It("Between operator should work", func() {
+		sqls := []string{
+			`SELECT count(*) FROM trips
+			WHERE request_at BETWEEN '2024-01-01' AND '2024-01-07' AND fare BETWEEN 10 AND 20
+			AND city_id NOT BETWEEN 1 AND 3
+			GROUP BY status`,
+		}
+		res := queryCom.AQLQuery{
+			Table:      "trips",
+			Measures:   []queryCom.Measure{{Expr: "count(*)"}},
+			Dimensions: []queryCom.Dimension{{Expr: "status"}},
+			Filters:    []string{"fare >= 10 AND fare <= 20", "(city_id < 1 OR city_id > 3)"},
+			TimeFilter: queryCom.TimeFilter{Column: "request_at", From: "1704067200", To: "1704672000"},
+		}
+		runTest(sqls, res, logger)
+
+		sqls = []string{
+			`SELECT count(*) FROM trips
+			WHERE request_at BETWEEN '2024-01-01' AND '2024-01-07T10:30'
+			GROUP BY aql_time_bucket_day(request_at, "minute", America/New_York)`,
+		}
+		res = queryCom.AQLQuery{
+			Table:      "trips",
+			Measures:   []queryCom.Measure{{Expr: "count(*)"}},
+			Dimensions: []queryCom.Dimension{{Expr: "request_at", TimeBucketizer: "day", TimeUnit: "minute"}},
+			TimeFilter: queryCom.TimeFilter{Column: "request_at", From: "1704085200", To: "1704641401"},
+			Timezone:   "America/New_York",
+		}
+		runTest(sqls, res, logger)
+
+		_, err := Parse(`SELECT count(*) FROM trips
+			WHERE request_at BETWEEN '2024-01-07' AND '2024-01-01' GROUP BY status`, logger)
+		Ω(err.Error()).Should(ContainSubstring("empty time range"))
+		_, err = Parse(`SELECT count(*) FROM trips
+			WHERE request_at BETWEEN '2024-01-01' AND '2024-01-07' AND request_at BETWEEN '2024-01-02' AND '2024-01-03'
+			GROUP BY status`, logger)
+		Ω(err.Error()).Should(ContainSubstring("only one BETWEEN time range is allowed"))
+	})
+
 	ginkgo.It("Array functions should work", func() {
 		sqls := []string{
 			`SELECT length(array_field2) FROM table1
@@ -596,4 +709,130 @@ var _ = ginkgo.Describe("SQL Parser", func() {
 		runTest(sqls, res, logger)
 
 	})
+
+	ginkgo.It("parse insert should work", func() {
+		Ω(IsInsert(" insert into cities values (1, 'sf')")).Should(BeTrue())
+		Ω(IsInsert("SELECT * FROM cities")).Should(BeFalse())
+
+		insert, err := ParseInsert(`INSERT INTO cities (id, name) VALUES (1, 'sf'), (2, 'new ''york'');`, logger)
+		Ω(err).Should(BeNil())
+		Ω(*insert).Should(Equal(Insert{
+			Table:   "cities",
+			Columns: []string{"id", "name"},
+			Rows:    [][]interface{}{{1.0, "sf"}, {2.0, "new 'york"}},
+		}))
+
+		insert, err = ParseInsert(`insert into cities values (3, NULL)`, logger)
+		Ω(err).Should(BeNil())
+		Ω(*insert).Should(Equal(Insert{
+			Table: "cities",
+			Rows:  [][]interface{}{{3.0, nil}},
+		}))
+
+		_, err = ParseInsert(`INSERT INTO cities (id, name) VALUES (1, 'sf', 3)`, logger)
+		Ω(err.Error()).Should(ContainSubstring("expect 2 values per row"))
+		_, err = ParseInsert(`INSERT INTO cities VALUES (1, 'sf'), (2, 'la', 3)`, logger)
+		Ω(err.Error()).Should(ContainSubstring("expect 2 values per row"))
+		_, err = ParseInsert(`INSERT INTO cities SELECT * FROM t`, logger)
+		Ω(err).ShouldNot(BeNil())
+		_, err = ParseInsert(`INSERT INTO cities VALUES (1, city)`, logger)
+		Ω(err).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("parse ddl should work", func() {
+		Ω(IsDDL("create table cities (id Uint16)")).Should(BeTrue())
+		Ω(IsDDL(" ALTER TABLE cities DROP COLUMN name")).Should(BeTrue())
+		Ω(IsDDL("SELECT * FROM cities")).Should(BeFalse())
+
+		ddl, err := ParseDDL(`CREATE FACT TABLE trips (request_at Uint32, city_id Uint16, fares Map<String,Float32>, PRIMARY KEY (request_at, city_id));`)
+		Ω(err).Should(BeNil())
+		Ω(*ddl).Should(Equal(DDL{
+			Type: CreateTable,
+			Table: metaCom.Table{
+				Name:        "trips",
+				IsFactTable: true,
+				Columns: []metaCom.Column{
+					{Name: "request_at", Type: metaCom.Uint32},
+					{Name: "city_id", Type: metaCom.Uint16},
+					{Name: "fares", Type: metaCom.MapFloat32},
+				},
+				PrimaryKeyColumns: []int{0, 1},
+			},
+		}))
+
+		ddl, err = ParseDDL(`create table cities (id Uint16, name BigEnum, primary key (id))`)
+		Ω(err).Should(BeNil())
+		Ω(ddl.Table.IsFactTable).Should(BeFalse())
+		Ω(ddl.Table.PrimaryKeyColumns).Should(Equal([]int{0}))
+
+		ddl, err = ParseDDL(`ALTER TABLE cities ADD COLUMN population Uint32`)
+		Ω(err).Should(BeNil())
+		Ω(*ddl).Should(Equal(DDL{
+			Type:   AddColumn,
+			Table:  metaCom.Table{Name: "cities"},
+			Column: metaCom.Column{Name: "population", Type: metaCom.Uint32},
+		}))
+
+		ddl, err = ParseDDL(`alter table cities drop name`)
+		Ω(err).Should(BeNil())
+		Ω(*ddl).Should(Equal(DDL{
+			Type:   DropColumn,
+			Table:  metaCom.Table{Name: "cities"},
+			Column: metaCom.Column{Name: "name"},
+		}))
+
+		ddl, err = ParseDDL(`DROP TABLE cities;`)
+		Ω(err).Should(BeNil())
+		Ω(*ddl).Should(Equal(DDL{Type: DropTable, Table: metaCom.Table{Name: "cities"}}))
+
+		_, err = ParseDDL(`CREATE TABLE cities (id Uint16, PRIMARY KEY (name))`)
+		Ω(err.Error()).Should(ContainSubstring("primary key column 'name' is not defined"))
+		_, err = ParseDDL(`CREATE TABLE cities (id)`)
+		Ω(err.Error()).Should(ContainSubstring("invalid column definition"))
+		_, err = ParseDDL(`ALTER TABLE cities RENAME TO towns`)
+		Ω(err.Error()).Should(ContainSubstring("unsupported DDL statement"))
+	})
+
+	ginkgo.It("parse metadata statement should work", func() {
+		Ω(IsMetadataStatement("show tables")).Should(BeTrue())
+		Ω(IsMetadataStatement(" DESC cities")).Should(BeTrue())
+		Ω(IsMetadataStatement("SELECT * FROM cities")).Should(BeFalse())
+
+		statement, err := ParseMetadataStatement("SHOW TABLES;")
+		Ω(err).Should(BeNil())
+		Ω(*statement).Should(Equal(MetadataStatement{Type: ShowTables}))
+
+		for _, sql := range []string{"describe cities", "DESC cities;", "SHOW COLUMNS FROM cities"} {
+			statement, err = ParseMetadataStatement(sql)
+			Ω(err).Should(BeNil())
+			Ω(*statement).Should(Equal(MetadataStatement{Type: DescribeTable, Table: "cities"}))
+		}
+
+		_, err = ParseMetadataStatement("SHOW DATABASES")
+		Ω(err.Error()).Should(ContainSubstring("unsupported metadata statement"))
+	})
+
+	ginkgo.It("parse union all should work", func() {
+		queries, err := ParseUnionAll("SELECT count(*) FROM trips", logger)
+		Ω(err).Should(BeNil())
+		Ω(queries).Should(BeNil())
+
+		queries, err = ParseUnionAll(`SELECT status, count(*) AS trips FROM trips
+			WHERE aql_time_filter(request_at, "7 days ago", "now", America/New_York) GROUP BY status
+			UNION ALL
+			SELECT status, count(*) AS trips FROM trips
+			WHERE aql_time_filter(request_at, "14 days ago", "7 days ago", America/New_York) GROUP BY status`, logger)
+		Ω(err).Should(BeNil())
+		Ω(queries).Should(HaveLen(2))
+		Ω(queries[0].TimeFilter).Should(Equal(queryCom.TimeFilter{Column: "request_at", From: "7 days ago", To: "now"}))
+		Ω(queries[1].TimeFilter).Should(Equal(queryCom.TimeFilter{Column: "request_at", From: "14 days ago", To: "7 days ago"}))
+		Ω(queries[1].Dimensions).Should(Equal([]queryCom.Dimension{{Expr: "status"}}))
+
+		_, err = ParseUnionAll("SELECT * FROM trips UNION SELECT * FROM trips", logger)
+		Ω(err.Error()).Should(ContainSubstring("only UNION ALL is supported"))
+		_, err = ParseUnionAll("SELECT * FROM trips UNION ALL SELECT * FROM trips LIMIT 10", logger)
+		Ω(err.Error()).Should(ContainSubstring("ORDER BY and LIMIT of UNION ALL"))
+		_, err = ParseUnionAll("SELECT * FROM trips UNION ALL SELECT * FROM cities", logger)
+		Ω(err.Error()).Should(ContainSubstring("must be on the same table"))
+	})
 })
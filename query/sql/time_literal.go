@@ -0,0 +1,105 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	queryCom "github.com/uber/aresdb/query/common"
+)
+
+// timeLiteralDateLayout is the layout of date only time literals.
+const timeLiteralDateLayout = "2006-01-02"
+
+// timeLiteralLayouts are ISO 8601 layouts accepted for time literals, literals without
+// timezone offset are in the query timezone.
+var timeLiteralLayouts = []string{
+	timeLiteralDateLayout,
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02 15:04:05Z07:00",
+	time.RFC3339,
+}
+
+// timeRange is a range of time literals on a column from BETWEEN predicate, both ends inclusive.
+type timeRange struct {
+	column string
+	from   string
+	to     string
+	// location of the predicate for errors.
+	line, col int
+}
+
+// parseTimeLiteral parses the ISO 8601 date or time literal in loc, dateOnly tells whether
+// the literal has no time of day.
+func parseTimeLiteral(literal string, loc *time.Location) (t time.Time, dateOnly bool, err error) {
+	for _, layout := range timeLiteralLayouts {
+		if t, err = time.ParseInLocation(layout, literal, loc); err == nil {
+			return t, layout == timeLiteralDateLayout, nil
+		}
+	}
+	return t, false, fmt.Errorf("invalid time literal %s", literal)
+}
+
+// isTimeLiteral check whether the sql string literal is a date or time literal
+func isTimeLiteral(literal string) bool {
+	if len(literal) < 2 || literal[0] != '\'' || literal[len(literal)-1] != '\'' {
+		return false
+	}
+	_, _, err := parseTimeLiteral(literal[1:len(literal)-1], time.UTC)
+	return err == nil
+}
+
+// toTimeFilter resolves the time literals in timezone into the time filter in seconds since
+// epoch, which is coerced to the unit of the time column by the AQL compiler. Date only upper
+// bounds cover the whole day.
+func (r timeRange) toTimeFilter(timezone string) (queryCom.TimeFilter, error) {
+	loc, err := queryCom.ParseTimezone(timezone)
+	if err != nil {
+		return queryCom.TimeFilter{}, fmt.Errorf("time literals require a fixed timezone, got %s at (line:%d, col:%d)",
+			timezone, r.line, r.col)
+	}
+
+	from, _, err := parseTimeLiteral(strings.Trim(r.from, "'"), loc)
+	if err != nil {
+		return queryCom.TimeFilter{}, err
+	}
+	to, dateOnly, err := parseTimeLiteral(strings.Trim(r.to, "'"), loc)
+	if err != nil {
+		return queryCom.TimeFilter{}, err
+	}
+	// the time filter excludes its upper bound.
+	if dateOnly {
+		to = to.AddDate(0, 0, 1)
+	} else {
+		to = to.Add(time.Second)
+	}
+	if !from.Before(to) {
+		return queryCom.TimeFilter{}, fmt.Errorf("empty time range between %s and %s at (line:%d, col:%d)",
+			r.from, r.to, r.line, r.col)
+	}
+
+	return queryCom.TimeFilter{
+		Column: r.column,
+		From:   strconv.FormatInt(from.Unix(), 10),
+		To:     strconv.FormatInt(to.Unix(), 10),
+	}, nil
+}
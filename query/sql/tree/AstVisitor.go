@@ -83,6 +83,9 @@ type AstVisitor interface {
 	// VisitTableSubquery visits the node
 	VisitTableSubquery(tableSubquery *TableSubquery, ctx interface{}) interface{}
 
+	// VisitValues visits the node
+	VisitValues(values *Values, ctx interface{}) interface{}
+
 	// VisitWith visits the node
 	VisitWith(with *With, ctx interface{}) interface{}
 
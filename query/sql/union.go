@@ -0,0 +1,92 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr"
+	"github.com/uber/aresdb/common"
+	queryCom "github.com/uber/aresdb/query/common"
+	"github.com/uber/aresdb/query/sql/antlrgen"
+	"github.com/uber/aresdb/query/sql/util"
+)
+
+// unionRegex avoids parsing queries without UNION twice.
+var unionRegex = regexp.MustCompile(`(?i)\bUNION\b`)
+
+// ParseUnionAll parses UNION ALL of two query specifications on the same table into the queries
+// of both sides, which are executed separately with results concatenated. Returns nil queries
+// if sql is not a set operation.
+func ParseUnionAll(sql string, logger common.Logger) (queries []*queryCom.AQLQuery, err error) {
+	if !unionRegex.MatchString(sql) {
+		return nil, nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			var ok bool
+			err, ok = r.(error)
+			if !ok {
+				err = fmt.Errorf("unkonwn error, reason: %v", r)
+			}
+		}
+	}()
+
+	is := util.NewCaseChangingStream(antlr.NewInputStream(sql), true)
+	lexer := antlrgen.NewSqlBaseLexer(is)
+	stream := antlr.NewCommonTokenStream(lexer, antlr.TokenDefaultChannel)
+	p := antlrgen.NewSqlBaseParser(stream)
+	p.GetInterpreter().SetPredictionMode(antlr.PredictionModeSLL)
+	query, ok := p.Query().(*antlrgen.QueryContext)
+	if !ok {
+		return nil, nil
+	}
+	queryNoWith, ok := query.QueryNoWith().(*antlrgen.QueryNoWithContext)
+	if !ok {
+		return nil, nil
+	}
+	setOperation, ok := queryNoWith.QueryTerm().(*antlrgen.SetOperationContext)
+	if !ok {
+		return nil, nil
+	}
+
+	start := setOperation.GetStart()
+	if setOperation.UNION() == nil || setOperation.SetQuantifier() == nil ||
+		!strings.EqualFold(setOperation.SetQuantifier().GetText(), "ALL") {
+		return nil, fmt.Errorf("setOperation at (line:%d, col:%d) not supported yet, only UNION ALL is supported",
+			start.GetLine(), start.GetColumn())
+	}
+	if query.With() != nil || queryNoWith.ORDER() != nil || queryNoWith.LIMIT() != nil {
+		return nil, fmt.Errorf("WITH, ORDER BY and LIMIT of UNION ALL at (line:%d, col:%d) not supported yet",
+			start.GetLine(), start.GetColumn())
+	}
+
+	for _, term := range []antlrgen.IQueryTermContext{setOperation.GetLeft(), setOperation.GetRight()} {
+		var aql *queryCom.AQLQuery
+		aql, err = Parse(stream.GetTextFromTokens(term.GetStart(), term.GetStop()), logger)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, aql)
+	}
+	if queries[0].Table != queries[1].Table {
+		return nil, fmt.Errorf("queries of UNION ALL must be on the same table, but got %s and %s",
+			queries[0].Table, queries[1].Table)
+	}
+	return queries, nil
+}
@@ -155,30 +155,62 @@ func (stats oopkQueryStats) ColumnHeaders() []string {
 	return []string{"stage", "avg", "max", "minCallName", "count", "total", "percentage"}
 }
 
-// reportTimingForCurrentBatch will first wait for current cuda stream if the debug mode is set and change the timing stat accordingly.
+// reportTimingForCurrentBatch will first wait for current cuda stream if the debug mode is set or the query is traced,
+// and change the timing stat or record the stage in the query trace accordingly.
 // It will add to the total timing as well. Therefore this function should only be called one time for each stage.
 func (qc *AQLQueryContext) reportTimingForCurrentBatch(stream unsafe.Pointer, start *time.Time, name stageName) {
-	if qc.Debug {
+	queryTrace := utils.QueryTraceFromContext(qc.traceContext())
+	if qc.Debug || queryTrace != nil {
 		cgoutils.WaitForCudaStream(stream, qc.Device)
 		now := utils.Now()
-		value := now.Sub(*start).Seconds() * 1000
-		qc.OOPK.currentBatch.stats.timings[name] = value
-		qc.OOPK.currentBatch.stats.totalTiming += value
+		if queryTrace != nil {
+			queryTrace.Record(string(name), *start, now.Sub(*start), map[string]interface{}{
+				"batchID": qc.OOPK.currentBatch.stats.batchID,
+			})
+		}
+		if qc.Debug {
+			value := now.Sub(*start).Seconds() * 1000
+			qc.OOPK.currentBatch.stats.timings[name] = value
+			qc.OOPK.currentBatch.stats.totalTiming += value
+		}
 		*start = now
 	}
 }
 
+// startDeviceTimer records the start of kernels of the current batch on the stream.
+func (qc *AQLQueryContext) startDeviceTimer(stream unsafe.Pointer) {
+	if qc.deviceTimer[0] != nil {
+		cgoutils.RecordCudaEvent(qc.deviceTimer[0], stream, qc.Device)
+	}
+}
+
+// stopDeviceTimer records the end of kernels of the current batch on the stream, and accounts
+// the device time elapsed since startDeviceTimer to the query. Unlike timings reported in debug
+// mode, it does not include time the host waits for the device or works in between.
+func (qc *AQLQueryContext) stopDeviceTimer(stream unsafe.Pointer) {
+	if qc.deviceTimer[1] != nil {
+		cgoutils.RecordCudaEvent(qc.deviceTimer[1], stream, qc.Device)
+		qc.deviceSeconds += cgoutils.GetCudaEventElapsedTime(qc.deviceTimer[0], qc.deviceTimer[1], qc.Device) / 1000
+	}
+}
+
 // reportTiming is similar to reportTimingForCurrentBatch except that it modifies the query stats for the
 // whole query. It's usually should be called once for each stage
 func (qc *AQLQueryContext) reportTiming(stream unsafe.Pointer, start *time.Time, name stageName) {
-	if qc.Debug {
+	queryTrace := utils.QueryTraceFromContext(qc.traceContext())
+	if qc.Debug || queryTrace != nil {
 		if stream != nil {
 			cgoutils.WaitForCudaStream(stream, qc.Device)
 		}
 		now := utils.Now()
-		value := now.Sub(*start).Seconds() * 1000
-		queryStats := &qc.OOPK.LiveBatchStats
-		queryStats.applyStageStats(name, value)
+		if queryTrace != nil {
+			queryTrace.Record(string(name), *start, now.Sub(*start), nil)
+		}
+		if qc.Debug {
+			value := now.Sub(*start).Seconds() * 1000
+			queryStats := &qc.OOPK.LiveBatchStats
+			queryStats.applyStageStats(name, value)
+		}
 		*start = now
 	}
 }
@@ -67,11 +67,13 @@ var irregularRecurringBucketizer2Functor = map[string]expr.Token{
 // buildTimeDimensionExpr constructs sub ast based on several query params:
 // the time bucketizer string and the timezone string.
 // we parse time bucketizer into bucketInSeconds, for timezone string:
-// if fixed (non-UTC) timezone is passed in, we extend the ast to `(timeColumn CONVERT_TZ fixed_timezone_offset) FLOOR bucketInSeconds`
+// if fixed (non-UTC) timezone is passed in, we extend the ast to `(timeColumn CONVERT_TZ fixed_timezone_offset) FLOOR bucketInSeconds`,
+// or to `(timeColumn + offsets in effect at timeColumn) FLOOR bucketInSeconds` if the offset changes within the time range
 // if timezoneColumn exists, we extend the ast to `(timeColumn CONVERT_TZ timezoneColumn) FLOOR bucketInSeconds`
 func (qc *AQLQueryContext) buildTimeDimensionExpr(timeBucketizerString string, timeColumn expr.Expr) (expr.Expr, error) {
 	var bucketizerExpr expr.Expr
 	var err error
+	timeColumn = qc.timeInSeconds(timeColumn)
 	timeColumnWithOffsetExpr := timeColumn
 
 	// construct TimeSeriesBucketizer expr
@@ -91,58 +93,31 @@ func (qc *AQLQueryContext) buildTimeDimensionExpr(timeBucketizerString string, t
 			},
 		}
 	} else if qc.fixedTimezone.String() != time.UTC.String() {
-		_, fromOffset := qc.fromTime.Time.Zone()
-		_, toOffset := qc.toTime.Time.Zone()
-		if fromOffset != toOffset {
-			offsetDiff := fromOffset - toOffset
-			switchTs, err := utils.CalculateDSTSwitchTs(qc.fromTime.Time.Unix(), qc.toTime.Time.Unix(), qc.fixedTimezone)
-			if err != nil {
-				return nil, err
-			}
-			qc.dstswitch = switchTs
-			// simulate IF statement. sub ast: timeCol + fromOffset + (timeCol > switchTs) * offsetDiff
-			// where (timeCol > switchTs) will return 1 or 0
-			timeColumnWithOffsetExpr = &expr.BinaryExpr{
-				Op:  expr.ADD,
-				LHS: timeColumn,
-				RHS: &expr.BinaryExpr{
-					Op: expr.ADD,
-					LHS: &expr.NumberLiteral{
-						Expr:     strconv.Itoa(fromOffset),
-						Int:      fromOffset,
-						ExprType: expr.Signed,
-					},
-					RHS: &expr.BinaryExpr{
-						Op: expr.MUL,
-						LHS: &expr.NumberLiteral{
-							Expr:     strconv.Itoa(offsetDiff),
-							Int:      offsetDiff,
-							ExprType: expr.Signed,
-						},
-						RHS: &expr.BinaryExpr{
-							Op:  expr.GTE,
-							LHS: timeColumn,
-							RHS: &expr.NumberLiteral{
-								Expr: strconv.Itoa(int(switchTs)),
-								Int:  int(switchTs),
-							},
-							ExprType: expr.Boolean,
-						},
-						ExprType: expr.Signed,
-					},
-				},
+		var offset int
+		if qc.fromTime != nil && qc.toTime != nil {
+			transitions := utils.CalculateTimezoneTransitions(qc.fromTime.Time.Unix(), qc.toTime.Time.Unix(), qc.fixedTimezone)
+			offset = transitions.InitialOffset
+			if len(transitions.Transitions) > 0 {
+				// convert each row with the offset in effect at its own time so that buckets
+				// across daylight saving time switches are aligned to local time.
+				qc.timezoneTransitions = &transitions
+				if timeColumnWithOffsetExpr, err = common.TimezoneOffsetExpr(timeColumn, transitions, false); err != nil {
+					return nil, err
+				}
 			}
 		} else {
+			_, offset = utils.Now().In(qc.fixedTimezone).Zone()
+		}
+		if qc.timezoneTransitions == nil {
 			timeColumnWithOffsetExpr = &expr.BinaryExpr{
 				Op:  expr.CONVERT_TZ,
 				LHS: timeColumn,
 				RHS: &expr.NumberLiteral{
-					Expr: strconv.Itoa(fromOffset),
-					Int:  fromOffset,
+					Expr: strconv.Itoa(offset),
+					Int:  offset,
 				},
 			}
 		}
-
 	}
 
 	bucketizerExpr, err = parseRecurringTimeBucketizer(timeBucketizerString, timeColumnWithOffsetExpr)
@@ -119,6 +119,18 @@ var _ = ginkgo.Describe("Time Bucketizer", func() {
 		Ω(exp.String()).Should(Equal("GET_WEEK_START(request_at CONVERT_TZ 2018)"))
 		Ω(err).Should(BeNil())
 
+		// offsets within the time range are converted per row across daylight saving time switches.
+		qc.fixedTimezone, _ = time.LoadLocation("America/Los_Angeles")
+		qc.fromTime = &queryCom.AlignedTime{Time: time.Unix(1546300800, 0)}
+		qc.toTime = &queryCom.AlignedTime{Time: time.Unix(1577836800, 0)}
+		exp, err = qc.buildTimeDimensionExpr("day", timeColumn)
+		Ω(err).Should(BeNil())
+		Ω(exp.String()).Should(Equal("request_at - 28800 + (3600 * (request_at >= 1552212000)) - " +
+			"(3600 * (request_at >= 1572771600)) FLOOR 86400"))
+		Ω(qc.timezoneTransitions.Transitions).Should(HaveLen(2))
+		qc.timezoneTransitions = nil
+		qc.fixedTimezone = time.FixedZone("Foo", 2018)
+
 		// bucket "Day-X" is illegal
 		exp, err = qc.buildTimeDimensionExpr("Day-X", timeColumn)
 		Ω(exp).Should(BeNil())
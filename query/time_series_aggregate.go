@@ -19,6 +19,7 @@ package query
 import "C"
 import (
 	"github.com/uber/aresdb/utils"
+	"math"
 	"strconv"
 	"unsafe"
 
@@ -38,12 +39,14 @@ var DataTypeToCDataType = map[memCom.DataType]C.enum_DataType{
 	memCom.Uint16:    C.Uint16,
 	memCom.Int32:     C.Int32,
 	memCom.Int64:     C.Int64,
+	memCom.Decimal:   C.Int64,
 	memCom.Uint32:    C.Uint32,
 	memCom.Float32:   C.Float32,
 	memCom.SmallEnum: C.Uint8,
 	memCom.BigEnum:   C.Uint16,
 	memCom.GeoPoint:  C.GeoPoint,
 	memCom.UUID:      C.UUID,
+	memCom.String:    C.Uint32,
 }
 
 // UnaryExprTypeToCFunctorType maps from unary operator to C UnaryFunctorType
@@ -61,6 +64,7 @@ var UnaryExprTypeToCFunctorType = map[expr.Token]C.enum_UnaryFunctorType{
 	expr.GET_DAY_OF_YEAR:     C.GetDayOfYear,
 	expr.GET_MONTH_OF_YEAR:   C.GetMonthOfYear,
 	expr.GET_QUARTER_OF_YEAR: C.GetQuarterOfYear,
+	expr.MILLIS_TO_SECONDS:   C.MillisToSeconds,
 	expr.GET_HLL_VALUE:       C.GetHLLValue,
 	expr.ARRAY_LENGTH:        C.ArrayLength,
 }
@@ -149,7 +153,7 @@ func makeDefaultValue(value memCom.DataValue) C.DefaultValue {
 			*(*C.uint32_t)(unsafe.Pointer(&defaultValue.Value)) = (C.uint32_t)(*(*uint32)(value.OtherVal))
 		case memCom.Float32:
 			*(*C.float)(unsafe.Pointer(&defaultValue.Value)) = (C.float)(*(*float32)(value.OtherVal))
-		case memCom.Int64:
+		case memCom.Int64, memCom.Decimal:
 			*(*C.int64_t)(unsafe.Pointer(&defaultValue.Value)) = (C.int64_t)(*(*int64)(value.OtherVal))
 		case memCom.GeoPoint:
 			*(*C.GeoPointT)(unsafe.Pointer(&defaultValue.Value)) = *(*C.GeoPointT)(value.OtherVal)
@@ -253,6 +257,10 @@ func makeConstantInput(val interface{}, isValid bool) C.InputVector {
 		if t.Type() == expr.Float {
 			*(*C.float)(unsafe.Pointer(&constVector.Value)) = C.float(t.Val)
 			constVector.DataType = C.ConstFloat
+		} else if t.Int > math.MaxInt32 || t.Int < math.MinInt32 {
+			// only literals of Int64 and Decimal columns can be over 4 bytes.
+			*(*C.int64_t)(unsafe.Pointer(&constVector.Value)) = C.int64_t(t.Int)
+			constVector.DataType = C.ConstInt64
 		} else {
 			*(*C.int32_t)(unsafe.Pointer(&constVector.Value)) = C.int32_t(t.Int)
 			constVector.DataType = C.ConstInt
@@ -488,6 +496,44 @@ func (bc *oopkBatchContext) prepareForeignRecordIDs(mainTableJoinColumnIndex int
 	})
 }
 
+// resolveForeignRecordVersions replaces the latest versions looked up by prepareForeignRecordIDs
+// with the versions valid at event time for as of join.
+func (bc *oopkBatchContext) resolveForeignRecordVersions(mainTableTimeColumnIndex int, joinTableID int, table foreignTable,
+	stream unsafe.Pointer, device int) {
+	if bc.size <= 0 {
+		return
+	}
+
+	column := bc.columns[mainTableTimeColumnIndex]
+	inputVector := makeVectorPartySliceInput(column)
+	versionIndex := makeVersionIndex(table)
+	doCGoCall(func() C.CGoCallResHandle {
+		return C.AsOfLookup(
+			inputVector, (*C.RecordID)(bc.foreignTableRecordIDsD[joinTableID].getPointer()),
+			(*C.uint32_t)(bc.indexVectorD.getPointer()), (C.int)(bc.size), (*C.uint32_t)(bc.baseCountD.getPointer()),
+			(C.uint32_t)(bc.startRow), versionIndex, stream, C.int(device))
+	})
+}
+
+// makeVersionIndex makes the C version index struct from layout of
+// prepareVersionIndex.
+func makeVersionIndex(table foreignTable) C.VersionIndex {
+	var versionIndex C.VersionIndex
+	versionIndex.BatchSize = (C.int32_t)(table.batchSize)
+	versionIndex.BaseBatchID = (C.int32_t)(memstore.BaseBatchID)
+	if table.deviceVersionIndexPtr.isNull() {
+		return versionIndex
+	}
+
+	numSlots := table.batchSize * len(table.batches)
+	versionIndex.ValidFroms = (*C.uint32_t)(table.deviceVersionIndexPtr.getPointer())
+	if table.validToColumnID >= 0 {
+		versionIndex.ValidTos = (*C.uint32_t)(table.deviceVersionIndexPtr.offset(numSlots * 4).getPointer())
+	}
+	versionIndex.PrevRecordIDs = (*C.RecordID)(table.deviceVersionIndexPtr.offset(numSlots * 8).getPointer())
+	return versionIndex
+}
+
 // processExpression does AST tree dfs traversal and apply root action on the root level,
 // rootAction includes filterAction, writeToDimensionVectorAction and makeWriteToMeasureVectorAction
 func (bc *oopkBatchContext) processExpression(exp, parentExp expr.Expr, tableScanners []*TableScanner, foreignTables []*foreignTable,
@@ -529,6 +575,19 @@ func (bc *oopkBatchContext) processExpression(exp, parentExp expr.Expr, tableSca
 			return C.InputVector{}
 		}
 		return inputVector
+	case *expr.BooleanLiteral, *expr.NullLiteral:
+		// boolean constants from IN expansion and tri-valued logic rewrites.
+		var inputVector C.InputVector
+		if literal, ok := e.(*expr.BooleanLiteral); ok && literal.Val {
+			inputVector = makeConstantInput(1, true)
+		} else {
+			inputVector = makeConstantInput(0, ok)
+		}
+		if action != nil {
+			action(C.Noop, stream, device, []C.InputVector{inputVector}, e)
+			return C.InputVector{}
+		}
+		return inputVector
 	case *expr.UnaryExpr:
 		inputVector := bc.processExpression(e.Expr, e, tableScanners, foreignTables, stream, device, nil)
 		functorType, exist := UnaryExprTypeToCFunctorType[e.Op]
@@ -592,12 +651,14 @@ func (bc *oopkBatchContext) processExpression(exp, parentExp expr.Expr, tableSca
 	}
 }
 
-func makeGeoShapeBatch(shapesLatLongs devicePointer, numShapes, totalNumPoints int) C.GeoShapeBatch {
+func makeGeoShapeBatch(geo *geoIntersection) C.GeoShapeBatch {
 	var geoShapes C.GeoShapeBatch
-	geoShapes.LatLongs = (*C.uint8_t)(shapesLatLongs.getPointer())
-	totalWords := (numShapes + 31) / 32
-	geoShapes.TotalNumPoints = (C.int32_t)(totalNumPoints)
+	geoShapes.LatLongs = (*C.uint8_t)(geo.shapeLatLongs.getPointer())
+	totalWords := (geo.numShapes + 31) / 32
+	geoShapes.TotalNumPoints = (C.int32_t)(geo.totalNumPoints)
 	geoShapes.TotalWords = (C.uint8_t)(totalWords)
+	geoShapes.CellIndex = (*C.uint64_t)(geo.cellIndex.getPointer())
+	geoShapes.NumCells = (C.int32_t)(geo.numCells)
 	return geoShapes
 }
 
@@ -647,7 +708,7 @@ func (bc *oopkBatchContext) geoIntersect(geo *geoIntersection, pointColumnIndex
 	if numForeignTables > 0 {
 		foreignTableRecordIDs = unsafe.Pointer(&bc.foreignTableRecordIDsD[0].pointer)
 	}
-	geoShapes := makeGeoShapeBatch(geo.shapeLatLongs, geo.numShapes, geo.totalNumPoints)
+	geoShapes := makeGeoShapeBatch(geo)
 	points := bc.makeGeoPointInputVector(geo.pointTableID, pointColumnIndex, foreignTables)
 	bc.size = int(doCGoCall(func() C.CGoCallResHandle {
 		return C.GeoBatchIntersects(
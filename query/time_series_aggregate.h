@@ -84,6 +84,7 @@ enum ConstDataType {
   ConstFloat,
   ConstGeoPoint,
   ConstUUID,
+  ConstInt64,
 };
 
 // All supported unary functor types.
@@ -102,6 +103,7 @@ enum UnaryFunctorType {
   GetDayOfYear,
   GetMonthOfYear,
   GetQuarterOfYear,
+  MillisToSeconds,
   GetHLLValue,
   ArrayLength,
 };
@@ -147,6 +149,18 @@ typedef struct {
   int numBuckets;
 } CuckooHashIndex;
 
+// VersionIndex stores the validity range and previous version of each record
+// of a slowly changing dimension table, indexed by
+// (batchID - BaseBatchID) * BatchSize + index.
+typedef struct {
+  uint32_t *ValidFroms;
+  // nullptr if validTo column is not configured.
+  uint32_t *ValidTos;
+  RecordID *PrevRecordIDs;
+  int32_t BatchSize;
+  int32_t BaseBatchID;
+} VersionIndex;
+
 // GeoPointT is the struct to represent a single geography point.
 typedef struct {
   float Lat;
@@ -213,6 +227,8 @@ typedef struct {
     float FloatVal;
     GeoPointT GeoPointVal;
     UUIDT UUIDVal;
+    // Literals compared with or applied on Int64 and Decimal columns.
+    int64_t Int64Val;
   } Value;
   // Whether this values is valid.
   bool IsValid;
@@ -421,6 +437,12 @@ typedef struct {
   // 2. next three bytes stores the total number of points
   int32_t TotalNumPoints;
   uint8_t TotalWords;
+  // Optional s2 cell index to prefilter points before point in shape
+  // tests. It stores NumCells ascending s2 leaf cell ids splitting leaf
+  // cells into ranges followed by TotalWords words of shape bitmask for the
+  // range starting at each of them. nullptr means no index.
+  uint64_t *CellIndex;
+  int32_t NumCells;
 } GeoShapeBatch;
 
 // unaryTransform defines the C transform interface for golang to call.
@@ -447,6 +469,19 @@ CGoCallResHandle HashLookup(InputVector input,
                             void *cudaStream,
                             int device);
 
+// AsOfLookup resolves record ids of the latest versions looked up by HashLookup
+// into the versions valid at event times of the input vector. Record id will be
+// set to {0, 0} if no version is valid at the event time.
+CGoCallResHandle AsOfLookup(InputVector eventTimes,
+                            RecordID *recordIDs,
+                            uint32_t *indexVector,
+                            int indexVectorLength,
+                            uint32_t *baseCounts,
+                            uint32_t startCount,
+                            VersionIndex versionIndex,
+                            void *cudaStream,
+                            int device);
+
 // UnaryTransform transforms an InputVector to output
 // OutputVector by applying UnaryFunctor to each of the element.
 // Output space should be preallocated by caller. Notice unaryTransform
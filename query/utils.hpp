@@ -174,10 +174,15 @@ __host__ __device__ Value get_identity_value(AggregateFunction aggFunc) {
     case AGGR_SUM_SIGNED:
     case AGGR_SUM_FLOAT:return 0;
     case AGGR_MIN_UNSIGNED:return static_cast<Value>(UINT32_MAX);
-    case AGGR_MIN_SIGNED:return static_cast<Value>(INT32_MAX);
+    case AGGR_MIN_SIGNED:
+      // 8 bytes signed values are aggregated for Int64 and Decimal columns.
+      return sizeof(Value) == 8 ? static_cast<Value>(INT64_MAX)
+                                : static_cast<Value>(INT32_MAX);
     case AGGR_MIN_FLOAT:return static_cast<Value>(FLT_MAX);
     case AGGR_MAX_UNSIGNED:return 0;
-    case AGGR_MAX_SIGNED:return static_cast<Value>(INT32_MIN);
+    case AGGR_MAX_SIGNED:
+      return sizeof(Value) == 8 ? static_cast<Value>(INT64_MIN)
+                                : static_cast<Value>(INT32_MIN);
     case AGGR_MAX_FLOAT:return static_cast<Value>(FLT_MIN);
     default:return 0;
   }
@@ -277,6 +282,66 @@ thrust::pair<t1, t2> tuple2pair(thrust::tuple<t1, t2> t) {
   return thrust::make_pair(thrust::get<0>(t), thrust::get<1>(t));
 }
 
+// get_s2_leaf_cell_id returns the id of the s2 leaf cell containing the point.
+// It must be kept in sync with GeoCellID in memstore/common/geo_cell.go which
+// uses the s2 library: the point is projected onto a face of the cube with the
+// quadratic projection and its position along the hilbert curve of the face is
+// computed one level at a time instead of with lookup tables.
+__host__ __device__
+inline uint64_t get_s2_leaf_cell_id(float lat, float lng) {
+  const double kDegreeToRadian = 3.14159265358979323846 / 180.0;
+  const int kMaxLevel = 30;
+  const int kMaxSize = 1 << kMaxLevel;
+  // position of each (i, j) child for each orientation of the parent and
+  // orientation changes of each position.
+  const uint8_t kIJToPos[4][4] = {
+      {0, 1, 3, 2}, {0, 3, 1, 2}, {2, 3, 1, 0}, {2, 1, 3, 0}};
+  const uint8_t kPosToOrientation[4] = {1, 0, 0, 3};
+
+  double phi = lat * kDegreeToRadian;
+  double theta = lng * kDegreeToRadian;
+  double cosPhi = cos(phi);
+  double x = cos(theta) * cosPhi;
+  double y = sin(theta) * cosPhi;
+  double z = sin(phi);
+
+  int face;
+  double u, v;
+  if (fabs(x) > fabs(y) && fabs(x) > fabs(z)) {
+    face = x < 0 ? 3 : 0;
+    u = face == 0 ? y / x : z / x;
+    v = face == 0 ? z / x : y / x;
+  } else if (fabs(y) > fabs(z)) {
+    face = y < 0 ? 4 : 1;
+    u = face == 1 ? -x / y : z / y;
+    v = face == 1 ? z / y : -x / y;
+  } else {
+    face = z < 0 ? 5 : 2;
+    u = face == 2 ? -x / z : -y / z;
+    v = face == 2 ? -y / z : -x / z;
+  }
+
+  double st[2] = {u, v};
+  int ij[2];
+  for (int k = 0; k < 2; k++) {
+    double s = st[k] >= 0 ? 0.5 * sqrt(1 + 3 * st[k])
+                          : 1 - 0.5 * sqrt(1 - 3 * st[k]);
+    double index = floor(kMaxSize * s);
+    ij[k] = index < 0 ? 0 : (index >= kMaxSize ? kMaxSize - 1
+                                               : static_cast<int>(index));
+  }
+
+  uint64_t pos = 0;
+  uint8_t orientation = face & 1;
+  for (int level = kMaxLevel - 1; level >= 0; level--) {
+    uint8_t childPos = kIJToPos[orientation][((ij[0] >> level) & 1) << 1 |
+                                             ((ij[1] >> level) & 1)];
+    pos = pos << 2 | childPos;
+    orientation ^= kPosToOrientation[childPos];
+  }
+  return (static_cast<uint64_t>(face) << 61) | (pos << 1) | 1;
+}
+
 __host__ __device__ uint32_t murmur3sum32(const uint8_t *key, int bytes,
                                           uint32_t seed);
 __host__ __device__ void murmur3sum128(const uint8_t *key, int len,
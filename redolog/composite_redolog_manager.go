@@ -17,7 +17,6 @@ package redolog
 import (
 	"encoding/json"
 	"github.com/Shopify/sarama"
-	"github.com/uber/aresdb/diskstore"
 	"github.com/uber/aresdb/memstore/common"
 	metaCom "github.com/uber/aresdb/metastore/common"
 	"sync"
@@ -39,13 +38,13 @@ type compositeRedoLogManager struct {
 
 // NewCompositeRedoLogManager create compositeRedoLogManager oibject
 func newCompositeRedoLogManager(namespace, table, suffix string, shard int, tableConfig *metaCom.TableConfig,
-	consumer sarama.Consumer, diskStore diskstore.DiskStore,
+	consumer sarama.Consumer, logStore LogStore,
 	commitFunc func(string, int, int64) error,
 	checkPointFunc func(string, int, int64) error,
 	getCommitOffsetFunc func(string, int) (int64, error),
 	getCheckpointOffsetFunc func(string, int) (int64, error)) *compositeRedoLogManager {
 
-	fileRedoLogManager := newFileRedoLogManager(int64(tableConfig.RedoLogRotationInterval), int64(tableConfig.MaxRedoLogFileSize), diskStore, table, shard)
+	fileRedoLogManager := newFileRedoLogManager(int64(tableConfig.RedoLogRotationInterval), int64(tableConfig.MaxRedoLogFileSize), logStore, table, shard)
 
 	kafkaReader := newKafkaRedoLogManager(namespace, table, suffix, shard, consumer, false, commitFunc, checkPointFunc, getCommitOffsetFunc, getCheckpointOffsetFunc)
 
@@ -16,16 +16,22 @@ package redolog
 
 import (
 	"encoding/json"
-	"github.com/uber/aresdb/diskstore"
+	"errors"
 	"github.com/uber/aresdb/memstore/common"
 	"github.com/uber/aresdb/utils"
 	"io"
+	"math"
+	"sort"
 	"sync"
 )
 
-// UpsertHeader is the magic header written into the beginning of each redo log file.
+// UpsertHeader is the magic header written into the beginning of each redo log file without
+// checksums, see ChecksummedUpsertHeader.
 const UpsertHeader uint32 = 0xADDAFEED
 
+// errNothingToCompact aborts rewriting a redo log file without any upsert batch to compact.
+var errNothingToCompact = errors.New("Nothing to compact")
+
 // fileRedologManager manages the redo log file append, rotation, purge. It is used by ingestion,
 // recovery and archiving. Accessor must hold the TableShard.WriterLock to access it.
 type FileRedoLogManager struct {
@@ -57,12 +63,14 @@ type FileRedoLogManager struct {
 
 	// Current log file points to the current redo log file used for appending new upsert batches.
 	currentLogFile io.WriteCloser
+	// Writes upsert batch records into the current log file.
+	currentLogWriter *RecordWriter
 
 	// Current file creation time in milliseconds.
 	CurrentFileCreationTime int64 `json:"currentFileCreationTime"`
 
-	// Pointer to the disk store for redo log access.
-	diskStore diskstore.DiskStore
+	// Pointer to the log store for redo log access.
+	logStore LogStore
 
 	// Name of the table.
 	tableName string
@@ -74,16 +82,32 @@ type FileRedoLogManager struct {
 	recoveryDone bool
 	// batch recovered counts
 	batchRecovered int
+
+	// Whether to compact redo log files at checkpoint.
+	compaction bool
+	// redo log creation time -> the checkpoint the file was last compacted with.
+	compactionCheckpoints map[int64]redoLogCheckpoint
+	// Whether to truncate redo log files at upsert batches failing checksum verification
+	// during recovery instead of failing the recovery.
+	salvage bool
+}
+
+// redoLogCheckpoint is the archiving cutoff and backfill progress a redo log file is
+// checkpointed with.
+type redoLogCheckpoint struct {
+	cutoff               uint32
+	redoFileCheckpointed int64
+	batchOffset          uint32
 }
 
 // newFileRedoLogManager creates a new fileRedologManager instance.
-func newFileRedoLogManager(rotationInterval int64, maxRedoLogSize int64, diskStore diskstore.DiskStore, tableName string, shard int) *FileRedoLogManager {
+func newFileRedoLogManager(rotationInterval int64, maxRedoLogSize int64, logStore LogStore, tableName string, shard int) *FileRedoLogManager {
 	return &FileRedoLogManager{
 		RotationInterval:    rotationInterval,
 		MaxEventTimePerFile: make(map[int64]uint32),
 		BatchCountPerFile:   make(map[int64]uint32),
 		SizePerFile:         make(map[int64]uint32),
-		diskStore:           diskStore,
+		logStore:            logStore,
 		tableName:           tableName,
 		shard:               shard,
 		MaxRedoLogSize:      maxRedoLogSize,
@@ -92,14 +116,23 @@ func newFileRedoLogManager(rotationInterval int64, maxRedoLogSize int64, diskSto
 	}
 }
 
+// configure sets whether to compact redo log files and whether to salvage corrupted ones.
+func (r *FileRedoLogManager) configure(compaction, salvage bool) {
+	r.compaction = compaction
+	r.salvage = salvage
+	if compaction {
+		r.compactionCheckpoints = make(map[int64]redoLogCheckpoint)
+	}
+}
+
 // openFileForWrite handles redo log file opening and rotation (if needed). It guarantees the
 // validity of the currentLogFile upon return.
-func (r *FileRedoLogManager) openFileForWrite(upsertBatchSize uint32) {
+func (r *FileRedoLogManager) openFileForWrite(recordSize uint32) {
 	dataTime := utils.Now().Unix()
 
 	// If current file is still valid we just return the writer back.
 	if r.currentLogFile != nil && dataTime < r.CurrentFileCreationTime+r.RotationInterval &&
-		int64(r.CurrentRedoLogSize+upsertBatchSize+4) < r.MaxRedoLogSize {
+		int64(r.CurrentRedoLogSize+recordSize) < r.MaxRedoLogSize {
 		return
 	}
 
@@ -110,14 +143,13 @@ func (r *FileRedoLogManager) openFileForWrite(upsertBatchSize uint32) {
 		}
 	}
 
-	if r.currentLogFile, err = r.diskStore.OpenLogFileForAppend(r.tableName, r.shard, dataTime); err != nil {
+	if r.currentLogFile, err = r.logStore.OpenLogFileForAppend(r.tableName, r.shard, dataTime); err != nil {
 		utils.GetLogger().With(
 			"table", r.tableName,
 			"shard", r.shard,
 			"error", err.Error()).Panic("Failed to open new redo log file")
 	}
-	writer := utils.NewStreamDataWriter(r.currentLogFile)
-	if err = writer.WriteUint32(UpsertHeader); err != nil {
+	if r.currentLogWriter, err = NewRecordWriter(r.currentLogFile); err != nil {
 		utils.GetLogger().Panic("Failed to write magic header to the new redo log")
 	}
 
@@ -135,27 +167,26 @@ func (r *FileRedoLogManager) IsAppendEnabled() bool {
 	return true
 }
 
-// AppendToRedoLog saves an upsert batch into disk before applying it. Any errors from diskStore
+// AppendToRedoLog saves an upsert batch into disk before applying it. Any errors from logStore
 // will trigger system panic.
 func (r *FileRedoLogManager) AppendToRedoLog(upsertBatch *common.UpsertBatch) (int64, uint32) {
-	r.openFileForWrite(uint32(len(upsertBatch.GetBuffer())))
-
 	buffer := upsertBatch.GetBuffer()
+	recordSize := RecordSize(buffer)
+	r.openFileForWrite(recordSize)
 
-	writer := utils.NewStreamDataWriter(r.currentLogFile)
-	// Write buffer size.
-	if err := writer.WriteUint32(uint32(len(buffer))); err != nil {
-		utils.GetLogger().With("error", err).Panic("Failed to write buffer size into the redo log")
-	}
-
-	if _, err := r.currentLogFile.Write(buffer); err != nil {
+	if err := r.currentLogWriter.Write(buffer); err != nil {
 		utils.GetLogger().With("error", err).Panic("Failed to write upsert buffer into the redo log")
 	}
+	if f, ok := r.currentLogFile.(flusher); ok {
+		if err := f.Flush(); err != nil {
+			utils.GetLogger().With("error", err).Panic("Failed to flush upsert buffer into the redo log")
+		}
+	}
 
 	// update current redo log size
-	r.CurrentRedoLogSize += uint32(len(upsertBatch.GetBuffer())) + 4
-	r.SizePerFile[r.CurrentFileCreationTime] += uint32(len(upsertBatch.GetBuffer())) + 4
-	r.TotalRedoLogSize += uint(len(upsertBatch.GetBuffer())) + 4
+	r.CurrentRedoLogSize += recordSize
+	r.SizePerFile[r.CurrentFileCreationTime] += recordSize
+	r.TotalRedoLogSize += uint(recordSize)
 
 	utils.GetReporter(r.tableName, r.shard).GetGauge(utils.CurrentRedologSize).Update(float64(r.CurrentRedoLogSize))
 	utils.GetReporter(r.tableName, r.shard).GetGauge(utils.SizeOfRedologs).Update(float64(r.TotalRedoLogSize))
@@ -195,7 +226,7 @@ func (r *FileRedoLogManager) closeRedoLogFile(creationTime int64, offset uint32,
 	if needToTruncate {
 		utils.GetLogger().Error("Corrupted file found, truncating it to resume processing")
 		// truncate current file and move to next file.
-		if err := r.diskStore.TruncateLogFile(r.tableName, r.shard, creationTime, int64(offset)); err != nil {
+		if err := r.logStore.TruncateLogFile(r.tableName, r.shard, creationTime, int64(offset)); err != nil {
 			utils.GetLogger().With(
 				"table", r.tableName,
 				"shard", r.shard,
@@ -211,7 +242,7 @@ func (r *FileRedoLogManager) closeRedoLogFile(creationTime int64, offset uint32,
 //
 // Any failure in file reading and upsert batch creation will trigger system panic.
 func (r *FileRedoLogManager) Iterator() (NextUpsertFunc, error) {
-	files, err := r.diskStore.ListLogFiles(r.tableName, r.shard)
+	files, err := r.logStore.ListLogFiles(r.tableName, r.shard)
 	if err != nil {
 		utils.GetLogger().Panic("Failed to list redo log files", err)
 	}
@@ -222,9 +253,8 @@ func (r *FileRedoLogManager) Iterator() (NextUpsertFunc, error) {
 	).Infof("Start replaying local redolog files")
 
 	currentIndex := 0
-	var currentReader utils.StreamDataReader
+	var currentReader *RecordReader
 	var currentFile io.ReadCloser
-	var offset uint32
 
 	return func() *NextUpsertBatchInfo {
 		for {
@@ -242,69 +272,77 @@ func (r *FileRedoLogManager) Iterator() (NextUpsertFunc, error) {
 					"table", r.tableName,
 					"shard", r.shard,
 				).Infof("Start replaying redo log file %d [%d/%d]", key, currentIndex+1, len(files))
-				currentFile, err = r.diskStore.OpenLogFileForReplay(r.tableName, r.shard, key)
+				currentFile, err = r.logStore.OpenLogFileForReplay(r.tableName, r.shard, key)
 				if err != nil {
 					utils.GetLogger().Panicf("Failed to open redo log file %v for replay", key)
 				}
 
-				currentReader = utils.NewStreamDataReader(currentFile)
-
 				// Read magic header. If magic number mismatches, this means the whole redolog file is corrupted.
 				// We should immediately crash the server and let engineer to handle this.
-				var header uint32
-				if header, err = currentReader.ReadUint32(); err != nil {
-					utils.GetLogger().Panicf("Failed to read magic header for redo log file %v", key)
-				}
-
-				if header != UpsertHeader {
-					utils.GetLogger().Panicf("Invalid header %#x for redo log file %v", header, key)
+				if currentReader, err = NewRecordReader(currentFile); err != nil {
+					utils.GetLogger().Panicf("Failed to read magic header for redo log file %v: %v", key, err)
 				}
-				offset = 4
 			}
 
 			// All later errors are recoverable and should be solved by truncate the redo log file.
 
 			// Try to read the next batch in the file.
-			size, err := currentReader.ReadUint32()
+			offset := currentReader.Offset
+			buffer, err := currentReader.Next()
 			if err == io.EOF {
-				r.closeRedoLogFile(files[currentIndex], offset, &currentFile, &currentIndex, false)
-			} else if err != nil {
-				utils.GetLogger().Errorf("Failed to read size info of the next upsert batch %v",
-					err)
-				r.closeRedoLogFile(files[currentIndex], offset, &currentFile, &currentIndex, true)
-			} else {
-				offset += 4
-				// Found an upsert batch to read.
-				buffer := make([]byte, size)
-				if err := currentReader.Read(buffer); err != nil {
-					utils.GetLogger().Errorf(
-						"Failed to read upsert batch of size %v from file %v at offset %v for table %v shard %v",
-						size, files[currentIndex], offset, r.tableName, r.shard)
-					r.closeRedoLogFile(files[currentIndex], offset-4, &currentFile, &currentIndex, true)
-				} else {
-					offset += size
-					upsertBatch, err := common.NewUpsertBatch(buffer)
-					if err != nil {
-						utils.GetLogger().Errorf(
-							"Failed to create upsert batch from buffer of size %v from file %v at offset %v for table %v shard %v",
-							size, files[currentIndex], offset, r.tableName, r.shard)
-						r.closeRedoLogFile(files[currentIndex], offset-4-size, &currentFile, &currentIndex, true)
-					} else {
-						// update total redolog size
-						r.TotalRedoLogSize += uint(size + 4)
-						// increment size per file
-						r.SizePerFile[files[currentIndex]] += size + 4
-
-						r.batchRecovered++
-						// update lastBatchOffset for the current redo log file
-						return &NextUpsertBatchInfo{
-							Batch:       upsertBatch,
-							RedoLogFile: files[currentIndex],
-							BatchOffset: r.updateBatchCount(files[currentIndex]) - 1,
-							Recovery:    true,
-						}
-					}
+				r.closeRedoLogFile(files[currentIndex], uint32(offset), &currentFile, &currentIndex, false)
+				continue
+			} else if err == ErrChecksumMismatch {
+				utils.GetReporter(r.tableName, r.shard).GetCounter(utils.RedoLogChecksumMismatch).Inc(1)
+				if !r.salvage {
+					utils.GetLogger().Panicf(
+						"Upsert batch from file %v at offset %v for table %v shard %v does not match its checksum",
+						files[currentIndex], offset, r.tableName, r.shard)
 				}
+				utils.GetLogger().Errorf(
+					"Upsert batch from file %v at offset %v for table %v shard %v does not match its checksum, salvaging",
+					files[currentIndex], offset, r.tableName, r.shard)
+				r.closeRedoLogFile(files[currentIndex], uint32(offset), &currentFile, &currentIndex, true)
+				continue
+			} else if err != nil {
+				utils.GetLogger().Errorf(
+					"Failed to read upsert batch from file %v at offset %v for table %v shard %v: %v",
+					files[currentIndex], offset, r.tableName, r.shard, err)
+				r.closeRedoLogFile(files[currentIndex], uint32(offset), &currentFile, &currentIndex, true)
+				continue
+			}
+
+			recordSize := uint32(currentReader.Offset - offset)
+			// An empty upsert batch in a checksummed file is left by compaction, it still takes
+			// its offset in the file.
+			if len(buffer) == 0 && currentReader.checksummed {
+				r.TotalRedoLogSize += uint(recordSize)
+				r.SizePerFile[files[currentIndex]] += recordSize
+				r.updateBatchCount(files[currentIndex])
+				continue
+			}
+
+			upsertBatch, err := common.NewUpsertBatch(buffer)
+			if err != nil {
+				utils.GetLogger().Errorf(
+					"Failed to create upsert batch from buffer of size %v from file %v at offset %v for table %v shard %v",
+					len(buffer), files[currentIndex], offset, r.tableName, r.shard)
+				r.closeRedoLogFile(files[currentIndex], uint32(offset), &currentFile, &currentIndex, true)
+				continue
+			}
+
+			// update total redolog size
+			r.TotalRedoLogSize += uint(recordSize)
+			// increment size per file
+			r.SizePerFile[files[currentIndex]] += recordSize
+
+			r.batchRecovered++
+			// update lastBatchOffset for the current redo log file
+			return &NextUpsertBatchInfo{
+				Batch:       upsertBatch,
+				RedoLogFile: files[currentIndex],
+				BatchOffset: r.updateBatchCount(files[currentIndex]) - 1,
+				Recovery:    true,
 			}
 		}
 	}, nil
@@ -358,6 +396,7 @@ func (r *FileRedoLogManager) evictRedoLogData(creationTime int64) {
 	delete(r.BatchCountPerFile, creationTime)
 	r.TotalRedoLogSize -= uint(r.SizePerFile[creationTime])
 	delete(r.SizePerFile, creationTime)
+	delete(r.compactionCheckpoints, creationTime)
 	utils.GetReporter(r.tableName, r.shard).GetGauge(utils.NumberOfRedologs).Update(float64(len(r.SizePerFile)))
 	utils.GetReporter(r.tableName, r.shard).GetGauge(utils.SizeOfRedologs).Update(float64(r.TotalRedoLogSize))
 	r.Unlock()
@@ -371,15 +410,147 @@ func (r *FileRedoLogManager) CheckpointRedolog(cutoff uint32, redoFileCheckpoint
 		"redologfile", redoFileCheckpointed, "batchoffset", batchOffset, "files", len(creationTimes)).Info("CheckpointRedolog")
 
 	for _, creationTime := range creationTimes {
-		if err := r.diskStore.DeleteLogFile(
+		if err := r.logStore.DeleteLogFile(
 			r.tableName, r.shard, creationTime); err != nil {
 			return err
 		}
 		r.evictRedoLogData(creationTime)
 	}
+
+	if r.compaction {
+		r.compactRedoLogs(redoLogCheckpoint{
+			cutoff:               cutoff,
+			redoFileCheckpointed: redoFileCheckpointed,
+			batchOffset:          batchOffset,
+		})
+	}
 	return nil
 }
 
+// getRedoLogFilesToCompact returns the redo log files that are not eligible for purging but may
+// have upsert batches covered by the checkpoint, skipping files already compacted with it.
+func (r *FileRedoLogManager) getRedoLogFilesToCompact(checkpoint redoLogCheckpoint) []int64 {
+	r.RLock()
+	defer r.RUnlock()
+	var creationTimes []int64
+	for creationTime := range r.SizePerFile {
+		// exclude current redo file since it's used by ingestion
+		if (creationTime < r.CurrentFileCreationTime || r.CurrentFileCreationTime == 0) &&
+			creationTime <= checkpoint.redoFileCheckpointed && r.compactionCheckpoints[creationTime] != checkpoint {
+			creationTimes = append(creationTimes, creationTime)
+		}
+	}
+	sort.Sort(utils.Int64Array(creationTimes))
+	return creationTimes
+}
+
+// compactRedoLogs rewrites redo log files to drop upsert batches covered by the checkpoint.
+// Failing to compact a file only leaves it uncompacted.
+func (r *FileRedoLogManager) compactRedoLogs(checkpoint redoLogCheckpoint) {
+	for _, creationTime := range r.getRedoLogFilesToCompact(checkpoint) {
+		numCompacted, err := r.compactRedoLogFile(creationTime, checkpoint)
+		if err != nil {
+			utils.GetLogger().With("action", "compactRedoLog", "table", r.tableName, "shard", r.shard,
+				"redologfile", creationTime, "error", err).Error("Failed to compact redolog file")
+			continue
+		}
+		if numCompacted > 0 {
+			utils.GetLogger().With("action", "compactRedoLog", "table", r.tableName, "shard", r.shard,
+				"redologfile", creationTime, "batches", numCompacted).Info("Compacted redolog file")
+			utils.GetReporter(r.tableName, r.shard).GetCounter(utils.RedoLogBatchesCompacted).Inc(int64(numCompacted))
+		}
+	}
+}
+
+// compactRedoLogFile replaces upsert batches covered by the checkpoint with empty ones, so that
+// the offsets of the remaining upsert batches stay the same. An upsert batch is covered when
+// it's already backfilled and all its records are older than the archiving cutoff. The file is
+// left unchanged if no upsert batch is covered.
+func (r *FileRedoLogManager) compactRedoLogFile(creationTime int64, checkpoint redoLogCheckpoint) (int, error) {
+	file, err := r.logStore.OpenLogFileForReplay(r.tableName, r.shard, creationTime)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader, err := NewRecordReader(file)
+	if err != nil {
+		return 0, err
+	}
+
+	var numCompacted int
+	var size uint32
+	err = r.logStore.RewriteLogFile(r.tableName, r.shard, creationTime, func(w io.Writer) error {
+		writer, err := NewRecordWriter(w)
+		if err != nil {
+			return err
+		}
+		for offset := uint32(0); ; offset++ {
+			buffer, err := reader.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+
+			if len(buffer) > 0 && (creationTime < checkpoint.redoFileCheckpointed || offset <= checkpoint.batchOffset) &&
+				coveredByCutoff(buffer, checkpoint.cutoff) {
+				buffer = nil
+				numCompacted++
+			}
+			if err = writer.Write(buffer); err != nil {
+				return err
+			}
+			size += RecordSize(buffer)
+		}
+		if numCompacted == 0 {
+			return errNothingToCompact
+		}
+		return nil
+	})
+
+	if numCompacted == 0 {
+		err = nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	if numCompacted > 0 {
+		r.TotalRedoLogSize = r.TotalRedoLogSize - uint(r.SizePerFile[creationTime]) + uint(size)
+		r.SizePerFile[creationTime] = size
+		utils.GetReporter(r.tableName, r.shard).GetGauge(utils.SizeOfRedologs).Update(float64(r.TotalRedoLogSize))
+	}
+	r.compactionCheckpoints[creationTime] = checkpoint
+	return numCompacted, nil
+}
+
+// coveredByCutoff tells whether all records of the upsert batch are older than the archiving
+// cutoff. Snapshots of dimension tables checkpoint with math.MaxUint32 as the cutoff, which
+// covers all upsert batches up to the snapshot.
+func coveredByCutoff(buffer []byte, cutoff uint32) bool {
+	if cutoff == math.MaxUint32 {
+		return true
+	}
+	upsertBatch, err := common.NewUpsertBatch(buffer)
+	if err != nil {
+		return false
+	}
+	col := upsertBatch.GetEventColumnIndex()
+	if col < 0 {
+		return false
+	}
+	for row := 0; row < upsertBatch.NumRows; row++ {
+		value, valid, err := upsertBatch.GetValue(row, col)
+		if err != nil || !valid || *(*uint32)(value) >= cutoff {
+			return false
+		}
+	}
+	return true
+}
+
 func (r *FileRedoLogManager) WaitForRecoveryDone() {
 	<-r.recoveryChan
 }
@@ -15,6 +15,7 @@
 package redolog
 
 import (
+	"io"
 	"time"
 
 	"sort"
@@ -498,4 +499,117 @@ var _ = ginkgo.Describe("redo_log_manager", func() {
 		Ω(redoManager.BatchCountPerFile).ShouldNot(HaveKey(1))
 		Ω(redoManager.BatchCountPerFile).ShouldNot(HaveKey(2))
 	})
+
+	ginkgo.It("AppendToRedoLog writes checksummed records", func() {
+		file := &testing.TestReadWriteCloser{}
+		diskStore := &mocks.DiskStore{}
+		diskStore.On("OpenLogFileForAppend", mock.Anything, mock.Anything, mock.Anything).Return(file, nil)
+		redoManager := newFileRedoLogManager(10, 1<<30, diskStore, "abc", 0)
+
+		buffer, _ := memCom.NewUpsertBatchBuilder().ToByteArray()
+		upsertBatch, _ := memCom.NewUpsertBatch(buffer)
+		redoManager.AppendToRedoLog(upsertBatch)
+		redoManager.AppendToRedoLog(upsertBatch)
+		Ω(redoManager.TotalRedoLogSize).Should(Equal(uint(2 * RecordSize(buffer))))
+
+		reader, err := NewRecordReader(file)
+		Ω(err).Should(BeNil())
+		Ω(reader.RecordOverhead()).Should(Equal(int64(8)))
+		for i := 0; i < 2; i++ {
+			record, err := reader.Next()
+			Ω(err).Should(BeNil())
+			Ω(record).Should(Equal(buffer))
+		}
+		_, err = reader.Next()
+		Ω(err).Should(Equal(io.EOF))
+	})
+
+	ginkgo.It("Iterator fails on checksum mismatch unless salvaging", func() {
+		buffer, _ := memCom.NewUpsertBatchBuilder().ToByteArray()
+		createFile := func() *testing.TestReadWriteCloser {
+			file := &testing.TestReadWriteCloser{}
+			writer, _ := NewRecordWriter(file)
+			writer.Write(buffer)
+			writer.Write(buffer)
+			// corrupt the last byte of the second upsert batch.
+			file.Bytes()[file.Len()-1]++
+			return file
+		}
+
+		diskStore := &mocks.DiskStore{}
+		diskStore.On("ListLogFiles", mock.Anything, mock.Anything).Return([]int64{1}, nil)
+		diskStore.On("OpenLogFileForReplay", mock.Anything, mock.Anything, int64(1)).Return(createFile(), nil).Once()
+		redoManager := newFileRedoLogManager(10, 1<<30, diskStore, "abc", 0)
+		nextUpsertBatch, _ := redoManager.Iterator()
+		Ω(nextUpsertBatch()).ShouldNot(BeNil())
+		Ω(func() { nextUpsertBatch() }).Should(Panic())
+
+		diskStore.On("OpenLogFileForReplay", mock.Anything, mock.Anything, int64(1)).Return(createFile(), nil).Once()
+		// magic header + size + checksum + first upsert batch.
+		diskStore.On("TruncateLogFile", "abc", 0, int64(1), int64(4+8+len(buffer))).Return(nil).Once()
+		redoManager = newFileRedoLogManager(10, 1<<30, diskStore, "abc", 0)
+		redoManager.configure(false, true)
+		nextUpsertBatch, _ = redoManager.Iterator()
+		Ω(nextUpsertBatch()).ShouldNot(BeNil())
+		Ω(nextUpsertBatch()).Should(BeNil())
+		diskStore.AssertExpectations(utils.TestingT)
+	})
+
+	ginkgo.It("CheckpointRedolog compacts upsert batches covered by archived batches", func() {
+		createBuffer := func(eventTimes ...uint32) []byte {
+			builder := memCom.NewUpsertBatchBuilder()
+			builder.AddColumn(0, memCom.Uint32)
+			for row, eventTime := range eventTimes {
+				builder.AddRow()
+				builder.SetValue(row, 0, eventTime)
+			}
+			buffer, _ := builder.ToByteArray()
+			return buffer
+		}
+		archived, backfilled, live := createBuffer(10, 20), createBuffer(30), createBuffer(10, 200)
+
+		file := &testing.TestReadWriteCloser{}
+		writer, _ := NewRecordWriter(file)
+		writer.Write(archived)
+		writer.Write(live)
+		writer.Write(backfilled)
+		writer.Write(backfilled)
+
+		compacted := &testing.TestReadWriteCloser{}
+		diskStore := &mocks.DiskStore{}
+		diskStore.On("ListLogFiles", mock.Anything, mock.Anything).Return([]int64{1}, nil)
+		diskStore.On("OpenLogFileForReplay", "abc", 0, int64(1)).Return(file, nil).Once()
+		diskStore.On("RewriteLogFile", "abc", 0, int64(1), mock.Anything).Run(func(args mock.Arguments) {
+			Ω(args.Get(3).(func(io.Writer) error)(compacted)).Should(BeNil())
+		}).Return(nil).Once()
+
+		redoManager := newFileRedoLogManager(10, 1<<30, diskStore, "abc", 0)
+		redoManager.configure(true, false)
+		redoManager.MaxEventTimePerFile[1] = 200
+		redoManager.SizePerFile[1] = 4 * RecordSize(backfilled)
+		redoManager.TotalRedoLogSize = uint(redoManager.SizePerFile[1])
+		redoManager.CurrentFileCreationTime = 2
+
+		// the last upsert batch is not backfilled yet.
+		Ω(redoManager.CheckpointRedolog(100, 1, 2)).Should(BeNil())
+		Ω(redoManager.SizePerFile[1]).Should(Equal(
+			RecordSize(nil) + RecordSize(live) + RecordSize(nil) + RecordSize(backfilled)))
+		Ω(redoManager.TotalRedoLogSize).Should(Equal(uint(redoManager.SizePerFile[1])))
+
+		// checkpointing again with the same progress does not read the file.
+		Ω(redoManager.CheckpointRedolog(100, 1, 2)).Should(BeNil())
+		diskStore.AssertExpectations(utils.TestingT)
+
+		// compacted upsert batches keep their offsets in recovery.
+		diskStore.On("OpenLogFileForReplay", "abc", 0, int64(1)).Return(compacted, nil).Once()
+		redoManager = newFileRedoLogManager(10, 1<<30, diskStore, "abc", 0)
+		nextUpsertBatch, _ := redoManager.Iterator()
+		batchInfo := nextUpsertBatch()
+		Ω(batchInfo.Batch.GetBuffer()).Should(Equal(live))
+		Ω(batchInfo.BatchOffset).Should(Equal(uint32(1)))
+		batchInfo = nextUpsertBatch()
+		Ω(batchInfo.Batch.GetBuffer()).Should(Equal(backfilled))
+		Ω(batchInfo.BatchOffset).Should(Equal(uint32(3)))
+		Ω(nextUpsertBatch()).Should(BeNil())
+	})
 })
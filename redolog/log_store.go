@@ -0,0 +1,302 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redolog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/uber/aresdb/utils"
+)
+
+// LogStore stores redo log files of table shards keyed by their creation time. DiskStore
+// stores them in local files, object stores can be plugged in for deployments preferring
+// durability of remote storage over append latency.
+type LogStore interface {
+	// ListLogFiles returns the creation time of redo log files of the table shard in ascending order.
+	ListLogFiles(table string, shard int) ([]int64, error)
+	// OpenLogFileForReplay opens the redo log file for read.
+	OpenLogFileForReplay(table string, shard int, creationTime int64) (utils.ReaderSeekerCloser, error)
+	// OpenLogFileForAppend opens the redo log file for append, creating it if not exist. The writer
+	// may implement Flush which persists data written so far, AppendToRedoLog flushes after each
+	// upsert batch.
+	OpenLogFileForAppend(table string, shard int, creationTime int64) (io.WriteCloser, error)
+	// DeleteLogFile deletes the redo log file.
+	DeleteLogFile(table string, shard int, creationTime int64) error
+	// TruncateLogFile truncates the redo log file to the offset.
+	TruncateLogFile(table string, shard int, creationTime int64, offset int64) error
+	// RewriteLogFile atomically replaces the redo log file with the content written by rewrite,
+	// the file is kept unchanged if rewrite fails.
+	RewriteLogFile(table string, shard int, creationTime int64, rewrite func(io.Writer) error) error
+}
+
+// flusher is implemented by redo log writers buffering appended data.
+type flusher interface {
+	Flush() error
+}
+
+// ObjectStore is the object storage redo log files can be stored in.
+type ObjectStore interface {
+	// Put writes the object of the key, overwriting any existing object.
+	Put(key string, data []byte) error
+	// Get opens the object of the key for read.
+	Get(key string) (io.ReadCloser, error)
+	// List returns keys of all objects with the prefix in lexical order.
+	List(prefix string) ([]string, error)
+	// Delete deletes the object of the key, deleting a missing object is not an error.
+	Delete(key string) error
+}
+
+// objectLogStore stores a redo log file as a sequence of part objects under
+// <prefix>/<table>/<shard>/<creationTime>/<generation>/<sequence>, each flush of the appender
+// writes a new part. Rewriting or truncating a file writes its content as the single part of
+// a new generation before deleting parts of older generations, readers only read parts of
+// the latest generation so they never see partial rewrites.
+type objectLogStore struct {
+	store  ObjectStore
+	prefix string
+}
+
+// NewObjectLogStore creates a LogStore storing redo log files in the object store under the prefix.
+func NewObjectLogStore(store ObjectStore, prefix string) LogStore {
+	return &objectLogStore{
+		store:  store,
+		prefix: prefix,
+	}
+}
+
+func (s *objectLogStore) shardPrefix(table string, shard int) string {
+	return path.Join(s.prefix, table, strconv.Itoa(shard)) + "/"
+}
+
+func (s *objectLogStore) filePrefix(table string, shard int, creationTime int64) string {
+	return fmt.Sprintf("%s%d/", s.shardPrefix(table, shard), creationTime)
+}
+
+func partKey(filePrefix string, generation, seq int) string {
+	return fmt.Sprintf("%s%08d/%010d", filePrefix, generation, seq)
+}
+
+// listParts returns the latest generation and keys of its parts in order, generation is -1
+// if the file does not exist.
+func (s *objectLogStore) listParts(filePrefix string) (generation int, parts []string, all []string, err error) {
+	all, err = s.store.List(filePrefix)
+	if err != nil {
+		return
+	}
+	generation = -1
+	for _, key := range all {
+		fields := strings.Split(strings.TrimPrefix(key, filePrefix), "/")
+		if len(fields) != 2 {
+			continue
+		}
+		gen, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		if gen > generation {
+			generation = gen
+			parts = parts[:0]
+		}
+		if gen == generation {
+			parts = append(parts, key)
+		}
+	}
+	sort.Strings(parts)
+	return
+}
+
+func (s *objectLogStore) ListLogFiles(table string, shard int) ([]int64, error) {
+	prefix := s.shardPrefix(table, shard)
+	keys, err := s.store.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var creationTimes []int64
+	seen := make(map[int64]bool)
+	for _, key := range keys {
+		fields := strings.Split(strings.TrimPrefix(key, prefix), "/")
+		creationTime, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil || seen[creationTime] {
+			continue
+		}
+		seen[creationTime] = true
+		creationTimes = append(creationTimes, creationTime)
+	}
+	sort.Sort(utils.Int64Array(creationTimes))
+	return creationTimes, nil
+}
+
+func (s *objectLogStore) OpenLogFileForReplay(table string, shard int, creationTime int64) (utils.ReaderSeekerCloser, error) {
+	_, parts, _, err := s.listParts(s.filePrefix(table, shard, creationTime))
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return nil, utils.StackError(nil, "Redo log file %d of table %s shard %d does not exist", creationTime, table, shard)
+	}
+	return &objectLogReader{store: s.store, parts: parts}, nil
+}
+
+func (s *objectLogStore) OpenLogFileForAppend(table string, shard int, creationTime int64) (io.WriteCloser, error) {
+	filePrefix := s.filePrefix(table, shard, creationTime)
+	generation, parts, _, err := s.listParts(filePrefix)
+	if err != nil {
+		return nil, err
+	}
+	if generation < 0 {
+		generation = 0
+	}
+	return &objectLogWriter{
+		store:      s.store,
+		filePrefix: filePrefix,
+		generation: generation,
+		seq:        len(parts),
+	}, nil
+}
+
+func (s *objectLogStore) DeleteLogFile(table string, shard int, creationTime int64) error {
+	_, _, all, err := s.listParts(s.filePrefix(table, shard, creationTime))
+	if err != nil {
+		return err
+	}
+	for _, key := range all {
+		if err = s.store.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *objectLogStore) TruncateLogFile(table string, shard int, creationTime int64, offset int64) error {
+	reader, err := s.OpenLogFileForReplay(table, shard, creationTime)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return s.RewriteLogFile(table, shard, creationTime, func(w io.Writer) error {
+		_, err := io.CopyN(w, reader, offset)
+		if err == io.EOF {
+			err = nil
+		}
+		return err
+	})
+}
+
+func (s *objectLogStore) RewriteLogFile(table string, shard int, creationTime int64, rewrite func(io.Writer) error) error {
+	filePrefix := s.filePrefix(table, shard, creationTime)
+	generation, _, all, err := s.listParts(filePrefix)
+	if err != nil {
+		return err
+	}
+	var buffer bytes.Buffer
+	if err = rewrite(&buffer); err != nil {
+		return err
+	}
+	if err = s.store.Put(partKey(filePrefix, generation+1, 0), buffer.Bytes()); err != nil {
+		return err
+	}
+	for _, key := range all {
+		if err = s.store.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// objectLogReader reads parts of a redo log file in order.
+type objectLogReader struct {
+	store   ObjectStore
+	parts   []string
+	current io.ReadCloser
+	offset  int64
+}
+
+func (r *objectLogReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+			var err error
+			if r.current, err = r.store.Get(r.parts[0]); err != nil {
+				return 0, err
+			}
+			r.parts = r.parts[1:]
+		}
+		n, err := r.current.Read(p)
+		r.offset += int64(n)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// Seek only supports telling the current offset, redo log files are read sequentially.
+func (r *objectLogReader) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekCurrent {
+		return r.offset, nil
+	}
+	return 0, errors.New("Seeking redo log files in object store is not supported")
+}
+
+func (r *objectLogReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
+
+// objectLogWriter buffers data appended to a redo log file and writes it as a new part on flush.
+type objectLogWriter struct {
+	store      ObjectStore
+	filePrefix string
+	generation int
+	seq        int
+	buffer     bytes.Buffer
+}
+
+func (w *objectLogWriter) Write(p []byte) (int, error) {
+	return w.buffer.Write(p)
+}
+
+// Flush writes data appended since last flush as a new part.
+func (w *objectLogWriter) Flush() error {
+	if w.buffer.Len() == 0 {
+		return nil
+	}
+	if err := w.store.Put(partKey(w.filePrefix, w.generation, w.seq), w.buffer.Bytes()); err != nil {
+		return err
+	}
+	w.seq++
+	w.buffer.Reset()
+	return nil
+}
+
+func (w *objectLogWriter) Close() error {
+	return w.Flush()
+}
@@ -0,0 +1,181 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redolog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/common"
+	memCom "github.com/uber/aresdb/memstore/common"
+	metaCom "github.com/uber/aresdb/metastore/common"
+	"github.com/uber/aresdb/utils"
+)
+
+// memObjectStore is an in memory ObjectStore for testing.
+type memObjectStore struct {
+	sync.Mutex
+	objects map[string][]byte
+	puts    int
+}
+
+func (s *memObjectStore) Put(key string, data []byte) error {
+	s.Lock()
+	defer s.Unlock()
+	s.objects[key] = append([]byte{}, data...)
+	s.puts++
+	return nil
+}
+
+func (s *memObjectStore) Get(key string) (io.ReadCloser, error) {
+	s.Lock()
+	defer s.Unlock()
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, errors.New("object not found")
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memObjectStore) List(prefix string) ([]string, error) {
+	s.Lock()
+	defer s.Unlock()
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *memObjectStore) Delete(key string) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+var _ = ginkgo.Describe("object log store", func() {
+	tableConfig := &metaCom.TableConfig{
+		RedoLogRotationInterval: 10,
+		MaxRedoLogFileSize:      1 << 30,
+	}
+
+	ginkgo.AfterEach(func() {
+		utils.ResetClockImplementation()
+	})
+
+	ginkgo.It("should store redo log files as parts", func() {
+		objectStore := &memObjectStore{objects: map[string][]byte{}}
+		logStore := NewObjectLogStore(objectStore, "redologs")
+
+		files, err := logStore.ListLogFiles("abc", 0)
+		Ω(err).Should(BeNil())
+		Ω(files).Should(BeEmpty())
+
+		w, err := logStore.OpenLogFileForAppend("abc", 0, 5)
+		Ω(err).Should(BeNil())
+		w.Write([]byte("ab"))
+		w.Write([]byte("c"))
+		Ω(w.(flusher).Flush()).Should(BeNil())
+		w.Write([]byte("de"))
+		Ω(w.Close()).Should(BeNil())
+		w, err = logStore.OpenLogFileForAppend("abc", 0, 9)
+		Ω(err).Should(BeNil())
+		w.Write([]byte("x"))
+		Ω(w.Close()).Should(BeNil())
+		Ω(objectStore.List("")).Should(Equal([]string{
+			"redologs/abc/0/5/00000000/0000000000",
+			"redologs/abc/0/5/00000000/0000000001",
+			"redologs/abc/0/9/00000000/0000000000",
+		}))
+
+		files, err = logStore.ListLogFiles("abc", 0)
+		Ω(err).Should(BeNil())
+		Ω(files).Should(Equal([]int64{5, 9}))
+
+		r, err := logStore.OpenLogFileForReplay("abc", 0, 5)
+		Ω(err).Should(BeNil())
+		data, _ := ioutil.ReadAll(r)
+		Ω(string(data)).Should(Equal("abcde"))
+		Ω(r.Seek(0, io.SeekCurrent)).Should(Equal(int64(5)))
+		r.Close()
+
+		// appending continues after existing parts.
+		w, _ = logStore.OpenLogFileForAppend("abc", 0, 5)
+		w.Write([]byte("f"))
+		Ω(w.Close()).Should(BeNil())
+
+		Ω(logStore.TruncateLogFile("abc", 0, 5, 4)).Should(BeNil())
+		Ω(objectStore.List("redologs/abc/0/5/")).Should(Equal([]string{"redologs/abc/0/5/00000001/0000000000"}))
+		r, _ = logStore.OpenLogFileForReplay("abc", 0, 5)
+		data, _ = ioutil.ReadAll(r)
+		Ω(string(data)).Should(Equal("abcd"))
+
+		Ω(logStore.RewriteLogFile("abc", 0, 5, func(w io.Writer) error {
+			return errors.New("failed")
+		})).ShouldNot(BeNil())
+		Ω(objectStore.List("redologs/abc/0/5/")).Should(Equal([]string{"redologs/abc/0/5/00000001/0000000000"}))
+
+		Ω(logStore.DeleteLogFile("abc", 0, 5)).Should(BeNil())
+		files, err = logStore.ListLogFiles("abc", 0)
+		Ω(err).Should(BeNil())
+		Ω(files).Should(Equal([]int64{9}))
+		_, err = logStore.OpenLogFileForReplay("abc", 0, 5)
+		Ω(err).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("redolog manager should append to and recover from object store", func() {
+		utils.SetCurrentTime(time.Unix(5, 0))
+		objectStore := &memObjectStore{objects: map[string][]byte{}}
+		master, _ := NewRedoLogManagerMaster("", &common.RedoLogConfig{}, nil, nil)
+		master.SetLogStore(NewObjectLogStore(objectStore, ""))
+		m, _ := master.NewRedologManager("abc", 0, false, tableConfig)
+
+		buffer, _ := memCom.NewUpsertBatchBuilder().ToByteArray()
+		upsertBatch, _ := memCom.NewUpsertBatch(buffer)
+		file, offset := m.AppendToRedoLog(upsertBatch)
+		Ω(file).Should(Equal(int64(5)))
+		Ω(offset).Should(Equal(uint32(0)))
+		// each upsert batch is written before acknowledged.
+		Ω(objectStore.puts).Should(Equal(1))
+		m.AppendToRedoLog(upsertBatch)
+		Ω(objectStore.puts).Should(Equal(2))
+		master.Close("abc", 0)
+
+		m, _ = master.NewRedologManager("abc", 0, false, tableConfig)
+		next, err := m.Iterator()
+		Ω(err).Should(BeNil())
+		for offset := uint32(0); offset < 2; offset++ {
+			batchInfo := next()
+			Ω(batchInfo).ShouldNot(BeNil())
+			Ω(batchInfo.RedoLogFile).Should(Equal(int64(5)))
+			Ω(batchInfo.BatchOffset).Should(Equal(offset))
+			Ω(batchInfo.Batch.GetBuffer()).Should(Equal(buffer))
+		}
+		Ω(next()).Should(BeNil())
+		Ω(m.GetTotalSize()).Should(Equal(2 * int(RecordSize(buffer))))
+	})
+})
@@ -0,0 +1,151 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redolog
+
+import (
+	"errors"
+	"hash/crc32"
+	"io"
+
+	"github.com/uber/aresdb/utils"
+)
+
+// ChecksummedUpsertHeader is the magic header of redo log files in which each upsert batch is
+// prefixed by its size and its CRC32 (IEEE) checksum. Files starting with UpsertHeader only
+// prefix each upsert batch with its size.
+const ChecksummedUpsertHeader uint32 = 0xADDAFEEC
+
+// ErrChecksumMismatch is returned when an upsert batch read from a redo log file does not match
+// its checksum.
+var ErrChecksumMismatch = errors.New("Upsert batch checksum mismatch")
+
+// RecordReader reads upsert batch records from a redo log file of either format. In a
+// checksummed file, a record of an empty upsert batch is left by compaction in place of an
+// upsert batch already covered by archived batches, so that the offsets of later upsert
+// batches in the file do not change.
+type RecordReader struct {
+	reader      utils.StreamDataReader
+	checksummed bool
+	// Offset is the byte offset of the next record in the file.
+	Offset int64
+}
+
+// NewRecordReader reads the magic header from the reader and returns a RecordReader for
+// the records after it.
+func NewRecordReader(reader io.Reader) (*RecordReader, error) {
+	r := &RecordReader{reader: utils.NewStreamDataReader(reader), Offset: 4}
+	header, err := r.reader.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	switch header {
+	case UpsertHeader:
+	case ChecksummedUpsertHeader:
+		r.checksummed = true
+	default:
+		return nil, utils.StackError(nil, "Invalid header %#x", header)
+	}
+	return r, nil
+}
+
+// RecordOverhead returns the number of bytes preceding each upsert batch in the file.
+func (r *RecordReader) RecordOverhead() int64 {
+	if r.checksummed {
+		return 8
+	}
+	return 4
+}
+
+// Next reads the next upsert batch. It returns io.EOF when there are no records left,
+// io.ErrUnexpectedEOF when the last record is incomplete, and ErrChecksumMismatch when the
+// upsert batch does not match its checksum. Offset is only advanced after a record is read
+// successfully.
+func (r *RecordReader) Next() ([]byte, error) {
+	size, err := r.reader.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+
+	var checksum uint32
+	if r.checksummed {
+		if checksum, err = r.reader.ReadUint32(); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	}
+
+	buffer := make([]byte, size)
+	if err = r.reader.Read(buffer); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	if r.checksummed && crc32.ChecksumIEEE(buffer) != checksum {
+		return nil, ErrChecksumMismatch
+	}
+	r.Offset += r.RecordOverhead() + int64(size)
+	return buffer, nil
+}
+
+// unexpectedEOF converts io.EOF in the middle of a record to io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Skip skips the next upsert batch without verifying its checksum.
+func (r *RecordReader) Skip() error {
+	size, err := r.reader.ReadUint32()
+	if err != nil {
+		return err
+	}
+	if err = r.reader.SkipBytes(int(r.RecordOverhead()) - 4 + int(size)); err != nil {
+		return unexpectedEOF(err)
+	}
+	r.Offset += r.RecordOverhead() + int64(size)
+	return nil
+}
+
+// RecordWriter writes upsert batch records into a redo log file with checksums.
+type RecordWriter struct {
+	writer utils.StreamDataWriter
+}
+
+// NewRecordWriter writes the magic header into the writer and returns a RecordWriter for the
+// records after it.
+func NewRecordWriter(writer io.Writer) (*RecordWriter, error) {
+	w := &RecordWriter{writer: utils.NewStreamDataWriter(writer)}
+	if err := w.writer.WriteUint32(ChecksummedUpsertHeader); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write writes the upsert batch along with its size and checksum.
+func (w *RecordWriter) Write(buffer []byte) error {
+	if err := w.writer.WriteUint32(uint32(len(buffer))); err != nil {
+		return err
+	}
+	if err := w.writer.WriteUint32(crc32.ChecksumIEEE(buffer)); err != nil {
+		return err
+	}
+	return w.writer.Write(buffer)
+}
+
+// RecordSize returns the number of bytes a record of the upsert batch takes in a redo log
+// file written by RecordWriter.
+func RecordSize(buffer []byte) uint32 {
+	return uint32(len(buffer)) + 8
+}
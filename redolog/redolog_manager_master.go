@@ -33,8 +33,8 @@ type RedoLogManagerMaster struct {
 	RedoLogConfig *common.RedoLogConfig
 	// kafka consuer if kafka consumer is configured
 	consumer sarama.Consumer
-	// DiskStore
-	diskStore diskstore.DiskStore
+	// LogStore redo log files are stored in, DiskStore unless set by SetLogStore
+	logStore LogStore
 	// Metastore
 	metaStore metaCom.MetaStore
 	// save all table partition redolog managers
@@ -69,13 +69,21 @@ func NewKafkaRedoLogManagerMaster(namespace string, cfg *common.RedoLogConfig, d
 	return &RedoLogManagerMaster{
 		Namespace:     namespace,
 		RedoLogConfig: cfg,
-		diskStore:     diskStore,
+		logStore:      diskStore,
 		managers:      make(map[string]map[int]RedologManager),
 		metaStore:     metaStore,
 		consumer:      consumer,
 	}, nil
 }
 
+// SetLogStore sets the store of redo log files created afterwards, eg. an object store
+// for durability of the redo stream beyond the local disk.
+func (m *RedoLogManagerMaster) SetLogStore(logStore LogStore) {
+	m.Lock()
+	defer m.Unlock()
+	m.logStore = logStore
+}
+
 // NewRedologManager create compositeRedoLogManager on specified table/shard
 // each table/shard should only have one compositeRedoLogManager
 func (m *RedoLogManagerMaster) NewRedologManager(table string, shard int, unsharded bool, tableConfig *metaCom.TableConfig) (RedologManager, error) {
@@ -95,7 +103,9 @@ func (m *RedoLogManagerMaster) NewRedologManager(table string, shard int, unshar
 	}
 
 	if unsharded && m.RedoLogConfig.DiskOnlyForUnsharded || !m.RedoLogConfig.KafkaConfig.Enabled {
-		manager = newFileRedoLogManager(int64(tableConfig.RedoLogRotationInterval), int64(tableConfig.MaxRedoLogFileSize), m.diskStore, table, shard)
+		fileRedoLogManager := newFileRedoLogManager(int64(tableConfig.RedoLogRotationInterval), int64(tableConfig.MaxRedoLogFileSize), m.logStore, table, shard)
+		fileRedoLogManager.configure(m.RedoLogConfig.DiskConfig.Compaction, m.RedoLogConfig.DiskConfig.Salvage)
+		manager = fileRedoLogManager
 	} else {
 		commitFunc := m.metaStore.UpdateRedoLogCommitOffset
 		checkPointFunc := m.metaStore.UpdateRedoLogCheckpointOffset
@@ -105,7 +115,9 @@ func (m *RedoLogManagerMaster) NewRedologManager(table string, shard int, unshar
 		if m.RedoLogConfig.DiskConfig.Disabled {
 			manager = newKafkaRedoLogManager(m.Namespace, table, m.RedoLogConfig.KafkaConfig.TopicSuffix, shard, m.consumer, true, commitFunc, checkPointFunc, getCommitOffsetFunc, getCheckpointOffsetFunc)
 		} else {
-			manager = newCompositeRedoLogManager(m.Namespace, table, m.RedoLogConfig.KafkaConfig.TopicSuffix, shard, tableConfig, m.consumer, m.diskStore, commitFunc, checkPointFunc, getCommitOffsetFunc, getCheckpointOffsetFunc)
+			compositeRedoLogManager := newCompositeRedoLogManager(m.Namespace, table, m.RedoLogConfig.KafkaConfig.TopicSuffix, shard, tableConfig, m.consumer, m.logStore, commitFunc, checkPointFunc, getCommitOffsetFunc, getCheckpointOffsetFunc)
+			compositeRedoLogManager.fileRedoLogManager.configure(m.RedoLogConfig.DiskConfig.Compaction, m.RedoLogConfig.DiskConfig.Salvage)
+			manager = compositeRedoLogManager
 		}
 	}
 
@@ -0,0 +1,377 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replication
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/uber/aresdb/cluster/topology"
+	"github.com/uber/aresdb/common"
+	"github.com/uber/aresdb/diskstore"
+	memCom "github.com/uber/aresdb/memstore/common"
+	"github.com/uber/aresdb/redolog"
+	"github.com/uber/aresdb/utils"
+)
+
+const (
+	defaultPollIntervalMilliseconds = 1000
+	replicationRequestTimeout       = 30 * time.Second
+	upsertDataContentType           = "application/upsert-data"
+)
+
+// Checkpoint is the position of the next upsert batch to replicate for a table shard.
+type Checkpoint struct {
+	// creation time of the redo log file.
+	RedoFile int64 `json:"redoFile"`
+	// offset of the next upsert batch in the redo log file, 0 means the beginning of the file.
+	Offset int64 `json:"offset"`
+}
+
+// Agent tails the local redo log files of owned table shards and replays the upsert batches
+// to the ingestion endpoint of a standby cluster. Checkpoints are persisted on local disk so
+// replication resumes where it stopped after restarts. Delivery is at least once, replayed
+// batches are idempotent upserts on the standby.
+//
+// Redo log files purged by archiving or snapshots before being replicated are skipped and
+// reported as gaps.
+type Agent struct {
+	cfg            common.ReplicationConfig
+	checkpointPath string
+	diskStore      diskstore.DiskStore
+	schemaReader   memCom.TableSchemaReader
+	shardOwner     topology.ShardOwner
+	client         *http.Client
+
+	// checkpoints and last caught up time of table shards, only accessed by the Run goroutine.
+	checkpoints  map[string]*Checkpoint
+	lastCaughtUp map[string]time.Time
+
+	stopChan chan struct{}
+}
+
+// NewAgent creates a new replication agent, checkpoints are stored under {rootPath}/replication.
+func NewAgent(cfg common.ReplicationConfig, rootPath string, diskStore diskstore.DiskStore,
+	schemaReader memCom.TableSchemaReader, shardOwner topology.ShardOwner) (*Agent, error) {
+	if cfg.TargetAddress == "" {
+		return nil, utils.StackError(nil, "replication target address is not set")
+	}
+	if cfg.PollIntervalMilliseconds <= 0 {
+		cfg.PollIntervalMilliseconds = defaultPollIntervalMilliseconds
+	}
+	checkpointPath := filepath.Join(rootPath, "replication")
+	if err := os.MkdirAll(checkpointPath, 0755); err != nil {
+		return nil, utils.StackError(err, "failed to create replication checkpoint directory %s", checkpointPath)
+	}
+	return &Agent{
+		cfg:            cfg,
+		checkpointPath: checkpointPath,
+		diskStore:      diskStore,
+		schemaReader:   schemaReader,
+		shardOwner:     shardOwner,
+		client: &http.Client{
+			Transport: utils.HTTPClientTransport(),
+			Timeout:   replicationRequestTimeout,
+		},
+		checkpoints:  make(map[string]*Checkpoint),
+		lastCaughtUp: make(map[string]time.Time),
+		stopChan:     make(chan struct{}),
+	}, nil
+}
+
+// Run is a ticker function to replicate new upsert batches periodically.
+func (a *Agent) Run() {
+	tickChan := time.NewTicker(time.Millisecond * time.Duration(a.cfg.PollIntervalMilliseconds)).C
+
+	for {
+		select {
+		case <-tickChan:
+			a.replicateAll()
+		case <-a.stopChan:
+			return
+		}
+	}
+}
+
+// Stop stops the replication agent.
+func (a *Agent) Stop() {
+	close(a.stopChan)
+}
+
+func (a *Agent) replicateAll() {
+	for _, table := range a.getTables() {
+		for _, shard := range a.shardOwner.GetOwnedShards() {
+			select {
+			case <-a.stopChan:
+				return
+			default:
+			}
+			if err := a.replicateShard(table, shard); err != nil {
+				utils.GetReporter(table, shard).GetCounter(utils.ReplicationFailure).Inc(1)
+				utils.GetLogger().With("table", table, "shard", shard, "error", err.Error()).
+					Error("Failed to replicate upsert batches")
+			}
+		}
+	}
+}
+
+// getTables returns the tables to replicate.
+func (a *Agent) getTables() []string {
+	a.schemaReader.RLock()
+	defer a.schemaReader.RUnlock()
+	schemas := a.schemaReader.GetSchemas()
+	var tables []string
+	if len(a.cfg.Tables) == 0 {
+		for table := range schemas {
+			tables = append(tables, table)
+		}
+		return tables
+	}
+	for _, table := range a.cfg.Tables {
+		if _, ok := schemas[table]; ok {
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+// replicateShard sends all complete upsert batches after the checkpoint of the table shard
+// to the standby and advances the checkpoint.
+func (a *Agent) replicateShard(table string, shard int) (err error) {
+	key := shardKey(table, shard)
+	checkpoint, err := a.getCheckpoint(table, shard)
+	if err != nil {
+		return err
+	}
+
+	files, err := a.diskStore.ListLogFiles(table, shard)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		a.reportLag(table, shard, files, checkpoint)
+	}()
+
+	if len(files) == 0 {
+		return nil
+	}
+
+	index := 0
+	for index < len(files) && files[index] < checkpoint.RedoFile {
+		index++
+	}
+	if index == len(files) || files[index] != checkpoint.RedoFile {
+		// Either the first time replicating the shard or the checkpointed file was purged.
+		if checkpoint.RedoFile != 0 {
+			a.reportGap(table, shard, checkpoint)
+		}
+		if index == len(files) {
+			return nil
+		}
+		checkpoint = &Checkpoint{RedoFile: files[index]}
+		if err = a.saveCheckpoint(table, shard, checkpoint); err != nil {
+			return err
+		}
+	}
+
+	for ; index < len(files); index++ {
+		last := index == len(files)-1
+		var complete bool
+		if complete, err = a.replicateFile(table, shard, checkpoint, last); err != nil {
+			return err
+		}
+		if last {
+			break
+		}
+		if !complete {
+			a.reportGap(table, shard, checkpoint)
+		}
+		// Newer files are only created after older files are closed, move to the next file.
+		checkpoint = &Checkpoint{RedoFile: files[index+1]}
+		if err = a.saveCheckpoint(table, shard, checkpoint); err != nil {
+			return err
+		}
+	}
+	a.lastCaughtUp[key] = utils.Now()
+	return nil
+}
+
+// replicateFile sends upsert batches of a redo log file starting from the checkpoint offset,
+// the checkpoint is updated after every batch accepted by the standby. It returns whether the
+// whole file is read without hitting an incomplete upsert batch. An incomplete upsert batch at
+// the end of the file currently being appended is expected and will be retried.
+func (a *Agent) replicateFile(table string, shard int, checkpoint *Checkpoint, last bool) (complete bool, err error) {
+	file, err := a.diskStore.OpenLogFileForReplay(table, shard, checkpoint.RedoFile)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	reader, err := redolog.NewRecordReader(file)
+	if err == io.EOF {
+		// header not written yet.
+		return false, nil
+	} else if err != nil {
+		return false, utils.StackError(err, "invalid header for redo log file %d", checkpoint.RedoFile)
+	}
+	if checkpoint.Offset == 0 {
+		checkpoint.Offset = reader.Offset
+	} else if _, err = file.Seek(checkpoint.Offset, io.SeekStart); err != nil {
+		return false, utils.StackError(err, "failed to seek redo log file %d", checkpoint.RedoFile)
+	}
+	reader.Offset = checkpoint.Offset
+
+	for {
+		buffer, readErr := reader.Next()
+		if readErr == io.EOF {
+			return true, nil
+		} else if readErr != nil {
+			return false, nil
+		}
+		// upsert batches compacted away have nothing to send.
+		if len(buffer) > 0 {
+			if err = a.sendUpsertBatch(table, shard, buffer); err != nil {
+				return false, err
+			}
+		}
+		checkpoint.Offset = reader.Offset
+		if err = a.saveCheckpoint(table, shard, checkpoint); err != nil {
+			return false, err
+		}
+		utils.GetReporter(table, shard).GetCounter(utils.ReplicatedUpsertBatches).Inc(1)
+		utils.GetReporter(table, shard).GetCounter(utils.ReplicatedBytes).Inc(int64(len(buffer)))
+	}
+}
+
+// sendUpsertBatch posts the upsert batch to the ingestion endpoint of the standby.
+func (a *Agent) sendUpsertBatch(table string, shard int, buffer []byte) error {
+	u := fmt.Sprintf("%s://%s/data/%s/%d", utils.HTTPScheme(), a.cfg.TargetAddress, url.PathEscape(table), shard)
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(buffer))
+	if err != nil {
+		return utils.StackError(err, "failed to create replication request")
+	}
+	req.Header.Set("Content-Type", upsertDataContentType)
+	if a.cfg.AuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+a.cfg.AuthToken)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return utils.StackError(err, "failed to send upsert batch to %s", a.cfg.TargetAddress)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return utils.StackError(nil, "got status code %d from %s: %s", resp.StatusCode, a.cfg.TargetAddress, body)
+	}
+	return nil
+}
+
+// reportLag reports the number of bytes in redo log files not yet replicated, and the seconds
+// since the table shard was last caught up.
+func (a *Agent) reportLag(table string, shard int, files []int64, checkpoint *Checkpoint) {
+	var lagBytes int64
+	for _, redoFile := range files {
+		if redoFile < checkpoint.RedoFile {
+			continue
+		}
+		file, err := a.diskStore.OpenLogFileForReplay(table, shard, redoFile)
+		if err != nil {
+			continue
+		}
+		size, err := file.Seek(0, io.SeekEnd)
+		file.Close()
+		if err != nil {
+			continue
+		}
+		if redoFile == checkpoint.RedoFile {
+			size -= checkpoint.Offset
+		}
+		if size > 0 {
+			lagBytes += size
+		}
+	}
+
+	key := shardKey(table, shard)
+	var lagSeconds float64
+	if lagBytes > 0 {
+		if lastCaughtUp, ok := a.lastCaughtUp[key]; ok {
+			lagSeconds = utils.Now().Sub(lastCaughtUp).Seconds()
+		} else if checkpoint.RedoFile != 0 {
+			lagSeconds = float64(utils.Now().Unix() - checkpoint.RedoFile)
+		}
+	}
+	utils.GetReporter(table, shard).GetGauge(utils.ReplicationLagBytes).Update(float64(lagBytes))
+	utils.GetReporter(table, shard).GetGauge(utils.ReplicationLagSeconds).Update(lagSeconds)
+}
+
+func (a *Agent) reportGap(table string, shard int, checkpoint *Checkpoint) {
+	utils.GetReporter(table, shard).GetCounter(utils.ReplicationGap).Inc(1)
+	utils.GetLogger().With("table", table, "shard", shard, "redoFile", checkpoint.RedoFile,
+		"offset", checkpoint.Offset).Warn("Upsert batches purged before being replicated")
+}
+
+// getCheckpoint returns the checkpoint of the table shard, loading it from disk if not cached.
+func (a *Agent) getCheckpoint(table string, shard int) (*Checkpoint, error) {
+	key := shardKey(table, shard)
+	if checkpoint, ok := a.checkpoints[key]; ok {
+		return checkpoint, nil
+	}
+	checkpoint := &Checkpoint{}
+	data, err := ioutil.ReadFile(a.checkpointFile(table, shard))
+	if err == nil {
+		if err = json.Unmarshal(data, checkpoint); err != nil {
+			return nil, utils.StackError(err, "failed to parse replication checkpoint of table %s shard %d", table, shard)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, utils.StackError(err, "failed to read replication checkpoint of table %s shard %d", table, shard)
+	}
+	a.checkpoints[key] = checkpoint
+	return checkpoint, nil
+}
+
+// saveCheckpoint persists the checkpoint by writing to a temp file then renaming.
+func (a *Agent) saveCheckpoint(table string, shard int, checkpoint *Checkpoint) error {
+	a.checkpoints[shardKey(table, shard)] = checkpoint
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return utils.StackError(err, "failed to marshal replication checkpoint")
+	}
+	path := a.checkpointFile(table, shard)
+	tmpPath := path + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return utils.StackError(err, "failed to write replication checkpoint %s", tmpPath)
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return utils.StackError(err, "failed to rename replication checkpoint %s", tmpPath)
+	}
+	return nil
+}
+
+func (a *Agent) checkpointFile(table string, shard int) string {
+	return filepath.Join(a.checkpointPath, fmt.Sprintf("%s_%d.json", table, shard))
+}
+
+func shardKey(table string, shard int) string {
+	return fmt.Sprintf("%s_%d", table, shard)
+}
@@ -0,0 +1,198 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replication
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/cluster/topology"
+	"github.com/uber/aresdb/common"
+	"github.com/uber/aresdb/diskstore"
+	memCom "github.com/uber/aresdb/memstore/common"
+	memComMocks "github.com/uber/aresdb/memstore/common/mocks"
+	"github.com/uber/aresdb/redolog"
+	"github.com/uber/aresdb/utils"
+)
+
+// standby records upsert batches posted to it, and fails requests while failing is set.
+type standby struct {
+	sync.Mutex
+	paths   []string
+	batches []string
+	failing bool
+}
+
+func (s *standby) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	if s.failing || r.Header.Get("Content-Type") != "application/upsert-data" {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	s.paths = append(s.paths, r.URL.Path)
+	s.batches = append(s.batches, string(body))
+}
+
+func (s *standby) received() []string {
+	s.Lock()
+	defer s.Unlock()
+	return append([]string(nil), s.batches...)
+}
+
+func writeRedoLog(rootPath, table string, shard int, creationTime int64, batches ...string) *os.File {
+	path := diskstore.GetPathForRedologFile(rootPath, table, shard, creationTime)
+	Ω(os.MkdirAll(filepath.Dir(path), 0755)).Should(BeNil())
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	Ω(err).Should(BeNil())
+	writer := utils.NewStreamDataWriter(file)
+	Ω(writer.WriteUint32(redolog.UpsertHeader)).Should(BeNil())
+	appendRedoLog(file, batches...)
+	return file
+}
+
+func appendRedoLog(file *os.File, batches ...string) {
+	writer := utils.NewStreamDataWriter(file)
+	for _, batch := range batches {
+		Ω(writer.WriteUint32(uint32(len(batch)))).Should(BeNil())
+		_, err := file.Write([]byte(batch))
+		Ω(err).Should(BeNil())
+	}
+}
+
+var _ = Describe("replication agent", func() {
+	var rootPath string
+	var server *httptest.Server
+	var target *standby
+	var schemaReader *memComMocks.TableSchemaReader
+	var cfg common.ReplicationConfig
+
+	newAgent := func() *Agent {
+		agent, err := NewAgent(cfg, rootPath, diskstore.NewLocalDiskStore(rootPath), schemaReader,
+			topology.NewStaticShardOwner([]int{0}))
+		Ω(err).Should(BeNil())
+		return agent
+	}
+
+	BeforeEach(func() {
+		var err error
+		rootPath, err = ioutil.TempDir("", "replication")
+		Ω(err).Should(BeNil())
+		target = &standby{}
+		server = httptest.NewServer(target)
+		cfg = common.ReplicationConfig{
+			Enable:        true,
+			TargetAddress: strings.TrimPrefix(server.URL, "http://"),
+		}
+		schemaReader = &memComMocks.TableSchemaReader{}
+		schemaReader.On("RLock").Return(nil)
+		schemaReader.On("RUnlock").Return(nil)
+		schemaReader.On("GetSchemas").Return(map[string]*memCom.TableSchema{
+			"t1": nil,
+		})
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(rootPath)
+	})
+
+	It("requires target address", func() {
+		cfg.TargetAddress = ""
+		_, err := NewAgent(cfg, rootPath, diskstore.NewLocalDiskStore(rootPath), schemaReader,
+			topology.NewStaticShardOwner([]int{0}))
+		Ω(err).ShouldNot(BeNil())
+	})
+
+	It("filters configured tables", func() {
+		cfg.Tables = []string{"t2", "t1"}
+		Ω(newAgent().getTables()).Should(Equal([]string{"t1"}))
+	})
+
+	It("replicates redo logs and resumes from checkpoints", func() {
+		file := writeRedoLog(rootPath, "t1", 0, 100, "b1", "b2")
+		// half written upsert batch at the end of the file being appended.
+		writer := utils.NewStreamDataWriter(file)
+		Ω(writer.WriteUint32(2)).Should(BeNil())
+
+		agent := newAgent()
+		Ω(agent.replicateShard("t1", 0)).Should(BeNil())
+		Ω(target.received()).Should(Equal([]string{"b1", "b2"}))
+		Ω(target.paths).Should(Equal([]string{"/data/t1/0", "/data/t1/0"}))
+		Ω(*agent.checkpoints["t1_0"]).Should(Equal(Checkpoint{RedoFile: 100, Offset: 16}))
+
+		_, err := file.Write([]byte("b3"))
+		Ω(err).Should(BeNil())
+		Ω(file.Close()).Should(BeNil())
+		writeRedoLog(rootPath, "t1", 0, 200, "b4").Close()
+
+		// a new agent resumes from the persisted checkpoint.
+		agent = newAgent()
+		Ω(agent.replicateShard("t1", 0)).Should(BeNil())
+		Ω(target.received()).Should(Equal([]string{"b1", "b2", "b3", "b4"}))
+
+		data, err := ioutil.ReadFile(filepath.Join(rootPath, "replication", "t1_0.json"))
+		Ω(err).Should(BeNil())
+		var checkpoint Checkpoint
+		Ω(json.Unmarshal(data, &checkpoint)).Should(BeNil())
+		Ω(checkpoint).Should(Equal(Checkpoint{RedoFile: 200, Offset: 10}))
+
+		Ω(agent.replicateShard("t1", 0)).Should(BeNil())
+		Ω(target.received()).Should(HaveLen(4))
+	})
+
+	It("retries from the checkpoint after standby failures", func() {
+		writeRedoLog(rootPath, "t1", 0, 100, "b1").Close()
+		target.failing = true
+
+		agent := newAgent()
+		Ω(agent.replicateShard("t1", 0)).ShouldNot(BeNil())
+		Ω(*agent.checkpoints["t1_0"]).Should(Equal(Checkpoint{RedoFile: 100, Offset: 4}))
+
+		target.failing = false
+		Ω(agent.replicateShard("t1", 0)).Should(BeNil())
+		Ω(target.received()).Should(Equal([]string{"b1"}))
+	})
+
+	It("skips redo logs purged before being replicated", func() {
+		writeRedoLog(rootPath, "t1", 0, 100, "b1").Close()
+		agent := newAgent()
+		Ω(agent.replicateShard("t1", 0)).Should(BeNil())
+
+		target.failing = true
+		file := writeRedoLog(rootPath, "t1", 0, 200, "b2")
+		file.Close()
+		Ω(agent.replicateShard("t1", 0)).ShouldNot(BeNil())
+
+		// redo logs purged after archiving.
+		Ω(os.Remove(diskstore.GetPathForRedologFile(rootPath, "t1", 0, 100))).Should(BeNil())
+		Ω(os.Remove(diskstore.GetPathForRedologFile(rootPath, "t1", 0, 200))).Should(BeNil())
+		writeRedoLog(rootPath, "t1", 0, 300, "b3").Close()
+
+		target.failing = false
+		Ω(agent.replicateShard("t1", 0)).Should(BeNil())
+		Ω(target.received()).Should(Equal([]string{"b1", "b3"}))
+		Ω(*agent.checkpoints["t1_0"]).Should(Equal(Checkpoint{RedoFile: 300, Offset: 10}))
+	})
+})
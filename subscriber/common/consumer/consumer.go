@@ -67,4 +67,9 @@ type Consumer interface {
 	// flushed to ZooKeeper; on startup, consumers begin processing after the last
 	// stored offset.
 	CommitUpTo(Message) error
+	// Pause stops fetching messages from the topic until Resume is called, messages already
+	// fetched are still delivered through Messages().
+	Pause()
+	// Resume resumes fetching messages paused by Pause.
+	Resume()
 }
@@ -41,6 +41,10 @@ type KafkaConsumer struct {
 	scope      tally.Scope
 	msgCh      chan consumer.Message
 
+	pauseLock sync.Mutex
+	// closed on Resume, nil if not paused.
+	resumeCh chan struct{}
+
 	// WARNING: The following channels should not be closed by the lib users
 	closeAttempted bool
 	closeCh        chan struct{}
@@ -220,6 +224,48 @@ func (c *KafkaConsumer) CommitUpTo(msg consumer.Message) error {
 	return nil
 }
 
+// Pause stops fetching messages from the topic until Resume is called, messages already
+// fetched are still delivered through Messages().
+func (c *KafkaConsumer) Pause() {
+	c.pauseLock.Lock()
+	defer c.pauseLock.Unlock()
+	if c.resumeCh == nil {
+		c.resumeCh = make(chan struct{})
+		c.scope.Counter("paused").Inc(1)
+	}
+}
+
+// Resume resumes fetching messages paused by Pause.
+func (c *KafkaConsumer) Resume() {
+	c.pauseLock.Lock()
+	defer c.pauseLock.Unlock()
+	if c.resumeCh != nil {
+		close(c.resumeCh)
+		c.resumeCh = nil
+	}
+}
+
+// waitForResume blocks the consumer claim while paused. Claims stop pulling messages so the
+// fetched messages buffered by sarama fill up and sarama stops fetching from the brokers.
+func (c *KafkaConsumer) waitForResume(session sarama.ConsumerGroupSession) {
+	c.pauseLock.Lock()
+	resumeCh := c.resumeCh
+	c.pauseLock.Unlock()
+	if resumeCh == nil {
+		return
+	}
+
+	if session == nil {
+		<-resumeCh
+		return
+	}
+	// the session ends on rebalance, claims need to return quickly then.
+	select {
+	case <-resumeCh:
+	case <-session.Context().Done():
+	}
+}
+
 func (c *KafkaConsumer) startConsuming(ctx context.Context, cgHandler *CGHandler) {
 	c.logger.Info("Start consumption goroutine")
 
@@ -255,6 +301,7 @@ func (c *KafkaConsumer) startConsuming(ctx context.Context, cgHandler *CGHandler
 
 func (c *KafkaConsumer) processMsg(msg *sarama.ConsumerMessage, cgHandler *CGHandler,
 	highWaterOffset int64, session sarama.ConsumerGroupSession) {
+	c.waitForResume(session)
 	c.Lock()
 	defer c.Unlock()
 
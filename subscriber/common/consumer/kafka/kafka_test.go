@@ -129,6 +129,14 @@ var _ = Describe("KafkaConsumer", func() {
 		cgHandler.msgLagGauge["job1-topic"] = make(map[int32]tally.Gauge)
 
 		kc.(*KafkaConsumer).processMsg(&msg, &cgHandler, 5632, nil)
+		Ω(kc.Messages()).Should(HaveLen(1))
+		<-kc.Messages()
+
+		kc.Pause()
+		go kc.(*KafkaConsumer).processMsg(&msg, &cgHandler, 5632, nil)
+		Consistently(kc.Messages(), "100ms").Should(BeEmpty())
+		kc.Resume()
+		Eventually(kc.Messages()).Should(Receive())
 
 		kafkaMsg := KafkaMessage{
 			ConsumerMessage: &msg,
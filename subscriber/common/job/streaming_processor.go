@@ -15,6 +15,7 @@
 package job
 
 import (
+	"context"
 	"fmt"
 	"github.com/uber-go/tally"
 	"github.com/uber/aresdb/client"
@@ -26,6 +27,7 @@ import (
 	"github.com/uber/aresdb/subscriber/common/tools"
 	"github.com/uber/aresdb/subscriber/config"
 	"github.com/uber/aresdb/utils"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 	"strconv"
 	"sync"
@@ -428,6 +430,13 @@ func (s *StreamingProcessor) decodeMessage(msg consumer.Message) (*message.Messa
 func (s *StreamingProcessor) saveToDestination(batch []interface{}, destination sink.Destination) {
 	s.scope.Gauge("batcherBatchSize").Update(float64(len(batch)))
 
+	ctx, span := utils.StartSpan(context.Background(), "subscriber.SaveBatch",
+		attribute.String("job", s.jobConfig.Name),
+		attribute.String("cluster", s.cluster),
+		attribute.Int("messages", len(batch)))
+	defer span.End()
+
+	_, parseSpan := utils.StartSpan(ctx, "subscriber.ParseMessages")
 	rows := []client.Row{}
 	for _, b := range batch {
 		msg := b.(*message.Message).DecodedMessage[message.MsgPrefix].(map[string]interface{})
@@ -449,16 +458,21 @@ func (s *StreamingProcessor) saveToDestination(batch []interface{}, destination
 		}
 	}
 
+	parseSpan.SetAttributes(attribute.Int("rows", len(rows)))
+	parseSpan.End()
+
 	size := len(batch)
 	if size > 0 {
 		s.scope.Timer("lag.ingestion").Record(time.Now().Sub(batch[size-1].(*message.Message).MsgInSubTS))
-		s.writeRow(rows, destination)
+		s.writeRow(ctx, rows, destination)
 	}
 
 }
 
-func (s *StreamingProcessor) writeRow(rows []client.Row, destination sink.Destination) {
-	err := s.sink.Save(destination, rows)
+func (s *StreamingProcessor) writeRow(ctx context.Context, rows []client.Row, destination sink.Destination) {
+	_, span := utils.StartSpan(ctx, "subscriber.SaveToSink", attribute.Int("rows", len(rows)))
+	err := s.saveWithBackpressure(destination, rows)
+	utils.EndSpan(span, err)
 	if err != nil {
 		s.serviceConfig.Logger.Error(
 			"Unable to save rows to database",
@@ -488,6 +502,42 @@ func (s *StreamingProcessor) writeRow(rows []client.Row, destination sink.Destin
 	s.context.Unlock()
 }
 
+// saveWithBackpressure saves rows to the sink. When the sink throttles ingestion, the consumer
+// is paused and the rows are retried after the wait asked by the sink until they are saved or
+// the processor is shut down.
+func (s *StreamingProcessor) saveWithBackpressure(destination sink.Destination, rows []client.Row) error {
+	err := s.sink.Save(destination, rows)
+	paused := false
+	defer func() {
+		if paused {
+			s.highLevelConsumer.Resume()
+		}
+	}()
+
+	for {
+		throttled, ok := err.(*client.ThrottledError)
+		if !ok {
+			return err
+		}
+		if !paused {
+			s.serviceConfig.Logger.Warn("Ingestion throttled by sink, pausing consumer",
+				zap.String("job", s.jobConfig.Name),
+				zap.String("cluster", s.cluster),
+				zap.Duration("retryAfter", throttled.RetryAfter))
+			s.highLevelConsumer.Pause()
+			paused = true
+		}
+		s.scope.Counter("errors.throttled").Inc(1)
+
+		select {
+		case <-time.After(throttled.RetryAfter):
+		case <-s.shutdown:
+			return err
+		}
+		err = s.sink.Save(destination, rows)
+	}
+}
+
 // saveToDB will parse and save given batches
 func (s *StreamingProcessor) saveToDB(batches chan []interface{}, wg *sync.WaitGroup) {
 	for batch := range batches {
@@ -24,6 +24,7 @@ import (
 	"github.com/uber/aresdb/client/mocks"
 	memCom "github.com/uber/aresdb/memstore/common"
 	metaCom "github.com/uber/aresdb/metastore/common"
+	"github.com/uber/aresdb/subscriber/common/consumer"
 	"github.com/uber/aresdb/subscriber/common/consumer/kafka"
 	"github.com/uber/aresdb/subscriber/common/message"
 	"github.com/uber/aresdb/subscriber/common/rules"
@@ -41,6 +42,20 @@ import (
 	"time"
 )
 
+// pausingConsumer records the calls to Pause and Resume.
+type pausingConsumer struct {
+	consumer.Consumer
+	paused, resumed int
+}
+
+func (c *pausingConsumer) Pause() {
+	c.paused++
+}
+
+func (c *pausingConsumer) Resume() {
+	c.resumed++
+}
+
 var _ = Describe("streaming_processor", func() {
 	var broker *sarama.MockBroker
 	serviceConfig := config.ServiceConfig{
@@ -346,4 +361,32 @@ var _ = Describe("streaming_processor", func() {
 		failureHandler.(*RetryFailureHandler).maxElapsedTime = 2 * time.Microsecond
 		failureHandler.HandleFailure(destination, rows)
 	})
+
+	It("saveWithBackpressure", func() {
+		throttledConnector := mocks.Connector{}
+		throttledConnector.On("Insert", table, columnNames, rows).
+			Return(0, &client.ThrottledError{Table: table, RetryAfter: time.Millisecond}).Twice()
+		throttledConnector.On("Insert", table, columnNames, rows).
+			Return(3, nil).Once()
+		hlConsumer := &pausingConsumer{}
+		p := &StreamingProcessor{
+			jobConfig:     jobConfig,
+			serviceConfig: serviceConfig,
+			scope:         tally.NoopScope,
+			sink: &sink.AresDatabase{
+				ServiceConfig: serviceConfig,
+				Scope:         tally.NoopScope,
+				ClusterName:   "dev01",
+				Connector:     &throttledConnector,
+				JobConfig:     jobConfig,
+			},
+			highLevelConsumer: hlConsumer,
+			shutdown:          make(chan bool),
+		}
+
+		Ω(p.saveWithBackpressure(destination, rows)).Should(BeNil())
+		Ω(hlConsumer.paused).Should(Equal(1))
+		Ω(hlConsumer.resumed).Should(Equal(1))
+		throttledConnector.AssertNumberOfCalls(GinkgoT(), "Insert", 3)
+	})
 })
@@ -43,7 +43,9 @@ type Parser struct {
 	Destination sink.Destination
 	// Transformations are keyed on the output column name
 	Transformations map[string]*rules.TransformationConfig
-	scope           tally.Scope
+	// TrackIngestionSource tells whether rows are stamped with the job name as their ingestion source
+	TrackIngestionSource bool
+	scope                tally.Scope
 }
 
 // NewParser will create a Parser for given JobConfig
@@ -58,6 +60,9 @@ func NewParser(jobConfig *rules.JobConfig, serviceConfig config.ServiceConfig) *
 			"aresCluster": jobConfig.AresTableConfig.Cluster,
 		}),
 	}
+	if table := jobConfig.AresTableConfig.Table; table != nil {
+		mp.TrackIngestionSource = table.TracksIngestionSource()
+	}
 	mp.populateDestination(jobConfig)
 	return mp
 }
@@ -99,6 +104,11 @@ func (mp *Parser) ParseMessage(msg map[string]interface{}, destination sink.Dest
 	mp.ServiceConfig.Logger.Debug("Parsing", zap.Any("msg", msg))
 	var row client.Row
 	for _, col := range destination.ColumnNames {
+		if mp.TrackIngestionSource && col == metaCom.IngestionSourceColumnName {
+			// enum case of the job name is translated to enum id by the ares client
+			row = append(row, mp.JobName)
+			continue
+		}
 		transformation := mp.Transformations[col]
 		fromValue := mp.extractSourceFieldValue(msg, col)
 		toValue, err := transformation.Transform(fromValue)
@@ -89,4 +89,27 @@ var _ = Describe("message_parser", func() {
 		Ω(row).ShouldNot(BeNil())
 		Ω(err).Should(BeNil())
 	})
+
+	It("ParseMessage stamps ingestion source", func() {
+		msg := map[string]interface{}{
+			"project":  "ares-subscriber",
+			"__source": "spoofed",
+		}
+
+		dst := sink.Destination{
+			Table:           "table",
+			ColumnNames:     []string{"__source", "project"},
+			PrimaryKeys:     map[string]int{"project": 1},
+			AresUpdateModes: []memCom.ColumnUpdateMode{memCom.UpdateOverwriteNotNull, memCom.UpdateOverwriteNotNull},
+		}
+		mp.Transformations = map[string]*rules.TransformationConfig{
+			"project": &rules.TransformationConfig{},
+		}
+		mp.TrackIngestionSource = true
+		row, err := mp.ParseMessage(msg, dst)
+		mp.TrackIngestionSource = false
+		Ω(err).Should(BeNil())
+		Ω(row).Should(HaveLen(2))
+		Ω(row[0]).Should(Equal(mp.JobName))
+	})
 })
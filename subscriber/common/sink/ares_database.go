@@ -73,6 +73,11 @@ func (db *AresDatabase) Save(destination Destination, rows []client.Row) error {
 	db.ServiceConfig.Logger.Debug("saving", zap.Any("rows", rows))
 	rowsInserted, err := db.Connector.
 		Insert(destination.Table, destination.ColumnNames, rows, destination.AresUpdateModes...)
+	if throttled, ok := err.(*client.ThrottledError); ok {
+		// returned as is so that the caller can back off.
+		db.Scope.Counter("errors.throttled").Inc(1)
+		return throttled
+	}
 	if err != nil {
 		db.Scope.Counter("errors.insert").Inc(1)
 		return utils.StackError(err, fmt.Sprintf("Failed to save rows in table %s, columns: %+v",
@@ -93,13 +93,13 @@ func getPrimaryKeyBytes(row client.Row, destination Destination, jobConfig *rule
 		columnIDInSchema := destination.PrimaryKeysInSchema[columnName]
 		if jobConfig.AresTableConfig.Table.Columns[columnIDInSchema].IsEnumColumn() {
 			// convert the string to bytes if primaryKey value is string
-			str := row[columnID].(string)
+			str, _ := jobConfig.AresTableConfig.Table.Columns[columnIDInSchema].EnumNormalization.Normalize(row[columnID].(string))
 			if strBytes == nil {
 				strBytes = make([]byte, 0, len(str))
 			}
 
 			if !jobConfig.AresTableConfig.Table.Columns[columnIDInSchema].CaseInsensitive {
-				str = strings.ToLower(row[columnID].(string))
+				str = strings.ToLower(str)
 			}
 			strBytes = append(strBytes, []byte(str)...)
 		} else {
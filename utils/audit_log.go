@@ -0,0 +1,261 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/uber/aresdb/common"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Audited operations.
+const (
+	AuditOpCreateTable       = "createTable"
+	AuditOpUpdateTableConfig = "updateTableConfig"
+	AuditOpDeleteTable       = "deleteTable"
+	AuditOpUpdateTable       = "updateTable"
+	AuditOpAddColumn         = "addColumn"
+	AuditOpUpdateColumn      = "updateColumn"
+	AuditOpDeleteColumn      = "deleteColumn"
+	AuditOpAddEnumCases      = "addEnumCases"
+	AuditOpIngestBatch       = "ingestBatch"
+	AuditOpPlaceLegalHold    = "placeLegalHold"
+	AuditOpRemoveLegalHold   = "removeLegalHold"
+	AuditOpBlockQueries      = "blockQueries"
+	AuditOpUnblockQueries    = "unblockQueries"
+	AuditOpAdmin             = "admin"
+)
+
+// AuditActorSchemaFetch is the actor of schema changes applied from ares-controller.
+const AuditActorSchemaFetch = "schema-fetch-job"
+
+const (
+	defaultAuditLogQueryLimit = 100
+	// max size of a single audit log line
+	maxAuditLogEntrySize = 16 * 1024 * 1024
+)
+
+// AuditLogEntry is a record in audit log.
+type AuditLogEntry struct {
+	Time time.Time `json:"time"`
+	// authenticated principal or the caller of the request
+	Actor string `json:"actor"`
+	// remote address of the request
+	Source    string `json:"source,omitempty"`
+	Operation string `json:"operation"`
+	Table     string `json:"table,omitempty"`
+	// method and path of admin api calls
+	Request string                 `json:"request,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+// AuditLogFilter selects entries from audit log, empty fields match all entries.
+type AuditLogFilter struct {
+	Actor     string
+	Operation string
+	Table     string
+	From      time.Time
+	To        time.Time
+	// max number of most recent entries to return, default to 100
+	Limit int
+}
+
+func (f AuditLogFilter) match(entry AuditLogEntry) bool {
+	return (f.Actor == "" || f.Actor == entry.Actor) &&
+		(f.Operation == "" || f.Operation == entry.Operation) &&
+		(f.Table == "" || f.Table == entry.Table) &&
+		(f.From.IsZero() || !entry.Time.Before(f.From)) &&
+		(f.To.IsZero() || entry.Time.Before(f.To))
+}
+
+// AuditLog appends entries to a file, one json object per line. A nil AuditLog records nothing.
+type AuditLog struct {
+	sync.Mutex
+	path string
+	file *os.File
+}
+
+// auditLog is the process wide audit log, nil if audit log is not enabled.
+var auditLog *AuditLog
+
+// NewAuditLog opens the audit log file for appending.
+func NewAuditLog(path string) (*AuditLog, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, StackError(err, "failed to open audit log file %s", path)
+	}
+	return &AuditLog{path: path, file: file}, nil
+}
+
+// InitAuditLog sets up the process wide audit log used by Audit and AuditRequest.
+// The returned function closes the audit log file.
+func InitAuditLog(cfg common.AuditLogConfig) (shutdown func(), err error) {
+	shutdown = func() {}
+	auditLog = nil
+	if !cfg.Enable {
+		return
+	}
+	if cfg.FilePath == "" {
+		err = StackError(nil, "file_path is required for audit log")
+		return
+	}
+
+	var l *AuditLog
+	l, err = NewAuditLog(cfg.FilePath)
+	if err != nil {
+		return
+	}
+	auditLog = l
+	shutdown = func() {
+		auditLog = nil
+		l.Close()
+	}
+	return
+}
+
+// GetAuditLog returns the process wide audit log, nil if not enabled.
+func GetAuditLog() *AuditLog {
+	return auditLog
+}
+
+// Write appends the entry to audit log synchronously so that no entry is lost.
+func (l *AuditLog) Write(entry AuditLogEntry) error {
+	if l == nil {
+		return nil
+	}
+	if entry.Time.IsZero() {
+		entry.Time = Now().UTC()
+	}
+	bs, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	_, err = l.file.Write(append(bs, '\n'))
+	return err
+}
+
+// Query returns the most recent entries matching the filter, newest first.
+func (l *AuditLog) Query(filter AuditLogFilter) ([]AuditLogEntry, error) {
+	entries := []AuditLogEntry{}
+	if l == nil {
+		return entries, nil
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditLogQueryLimit
+	}
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, StackError(err, "failed to open audit log file %s", l.path)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxAuditLogEntrySize)
+	for scanner.Scan() {
+		var entry AuditLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// skip partially written lines.
+			continue
+		}
+		if !filter.match(entry) {
+			continue
+		}
+		if len(entries) == limit {
+			entries = entries[1:]
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, StackError(err, "failed to read audit log file %s", l.path)
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// Close closes the audit log file.
+func (l *AuditLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.Lock()
+	defer l.Unlock()
+	return l.file.Close()
+}
+
+// Audit records the entry to the process wide audit log if enabled.
+func Audit(entry AuditLogEntry) {
+	if err := auditLog.Write(entry); err != nil {
+		GetLogger().With("error", err, "operation", entry.Operation, "table", entry.Table).Error("Failed to write audit log")
+		GetRootReporter().GetCounter(AuditLogWriteFailed).Inc(1)
+	}
+}
+
+// WithAdminAuditFunc records calls of admin handlers to audit log along with the response status,
+// tableOf returns the table the call operates on. The returned wrapper should be wrapped by the
+// authentication wrapper so that the authenticated principal is recorded as the actor.
+func WithAdminAuditFunc(tableOf func(r *http.Request) string) HTTPHandlerWrapper {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if auditLog == nil {
+				h(w, r)
+				return
+			}
+			rw := newResponseWriter(w)
+			h(rw, r)
+			var err error
+			if rw.statusCode >= http.StatusBadRequest {
+				err = fmt.Errorf("responded with status %d", rw.statusCode)
+			}
+			AuditRequest(r, AuditOpAdmin, tableOf(r), nil, err)
+		}
+	}
+}
+
+// AuditRequest records an operation performed by the http request along with its error if any.
+func AuditRequest(r *http.Request, operation, table string, details map[string]interface{}, err error) {
+	if auditLog == nil {
+		return
+	}
+	entry := AuditLogEntry{
+		Actor:     GetOrigin(r),
+		Source:    r.RemoteAddr,
+		Operation: operation,
+		Table:     table,
+		Details:   details,
+	}
+	if principal := PrincipalFromContext(r.Context()); principal != nil {
+		entry.Actor = principal.Name
+	}
+	if operation == AuditOpAdmin {
+		entry.Request = r.Method + " " + r.URL.Path
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	Audit(entry)
+}
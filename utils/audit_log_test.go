@@ -0,0 +1,167 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package utils
+
+import (
+	"errors"
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/common"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var _ = ginkgo.Describe("audit log", func() {
+	var dir string
+
+	ginkgo.BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "aresdb_audit")
+		Ω(err).Should(BeNil())
+	})
+
+	ginkgo.AfterEach(func() {
+		InitAuditLog(common.AuditLogConfig{})
+		ResetClockImplementation()
+		os.RemoveAll(dir)
+	})
+
+	ginkgo.It("InitAuditLog should work", func() {
+		shutdown, err := InitAuditLog(common.AuditLogConfig{})
+		Ω(err).Should(BeNil())
+		shutdown()
+		Ω(GetAuditLog()).Should(BeNil())
+		// nil audit log records nothing.
+		Ω(GetAuditLog().Write(AuditLogEntry{Operation: AuditOpCreateTable})).Should(BeNil())
+		entries, err := GetAuditLog().Query(AuditLogFilter{})
+		Ω(err).Should(BeNil())
+		Ω(entries).Should(BeEmpty())
+
+		_, err = InitAuditLog(common.AuditLogConfig{Enable: true})
+		Ω(err).ShouldNot(BeNil())
+
+		shutdown, err = InitAuditLog(common.AuditLogConfig{Enable: true, FilePath: filepath.Join(dir, "audit.log")})
+		Ω(err).Should(BeNil())
+		Ω(GetAuditLog()).ShouldNot(BeNil())
+		shutdown()
+		Ω(GetAuditLog()).Should(BeNil())
+	})
+
+	ginkgo.It("Write and Query should work", func() {
+		path := filepath.Join(dir, "audit.log")
+		l, err := NewAuditLog(path)
+		Ω(err).Should(BeNil())
+
+		for i, entry := range []AuditLogEntry{
+			{Actor: "alice", Operation: AuditOpCreateTable, Table: "t1"},
+			{Actor: "bob", Operation: AuditOpAddColumn, Table: "t1", Details: map[string]interface{}{"column": "c1"}},
+			{Actor: "alice", Operation: AuditOpDeleteTable, Table: "t2", Error: "table t2 does not exist"},
+		} {
+			SetCurrentTime(time.Unix(int64(100*(i+1)), 0))
+			Ω(l.Write(entry)).Should(BeNil())
+		}
+		Ω(l.Close()).Should(BeNil())
+
+		// entries written before are kept after reopening.
+		l, err = NewAuditLog(path)
+		Ω(err).Should(BeNil())
+		defer l.Close()
+		SetCurrentTime(time.Unix(400, 0))
+		Ω(l.Write(AuditLogEntry{Actor: AuditActorSchemaFetch, Operation: AuditOpUpdateTable, Table: "t1"})).Should(BeNil())
+
+		entries, err := l.Query(AuditLogFilter{})
+		Ω(err).Should(BeNil())
+		Ω(entries).Should(HaveLen(4))
+		Ω(entries[0].Actor).Should(Equal(AuditActorSchemaFetch))
+		Ω(entries[0].Time.Unix()).Should(BeEquivalentTo(400))
+		Ω(entries[2].Details).Should(Equal(map[string]interface{}{"column": "c1"}))
+		Ω(entries[3].Operation).Should(Equal(AuditOpCreateTable))
+
+		entries, err = l.Query(AuditLogFilter{Actor: "alice"})
+		Ω(err).Should(BeNil())
+		Ω(entries).Should(HaveLen(2))
+		Ω(entries[0].Error).Should(Equal("table t2 does not exist"))
+
+		entries, err = l.Query(AuditLogFilter{Table: "t1", Limit: 2})
+		Ω(err).Should(BeNil())
+		Ω(entries).Should(HaveLen(2))
+		Ω(entries[0].Operation).Should(Equal(AuditOpUpdateTable))
+		Ω(entries[1].Operation).Should(Equal(AuditOpAddColumn))
+
+		entries, err = l.Query(AuditLogFilter{From: time.Unix(200, 0), To: time.Unix(400, 0)})
+		Ω(err).Should(BeNil())
+		Ω(entries).Should(HaveLen(2))
+		Ω(entries[0].Operation).Should(Equal(AuditOpDeleteTable))
+
+		entries, err = l.Query(AuditLogFilter{Operation: AuditOpAddEnumCases})
+		Ω(err).Should(BeNil())
+		Ω(entries).Should(BeEmpty())
+	})
+
+	ginkgo.It("AuditRequest and WithAdminAuditFunc should work", func() {
+		// nothing is recorded when audit log is not enabled.
+		AuditRequest(httptest.NewRequest(http.MethodPost, "/schema/tables", nil), AuditOpCreateTable, "t1", nil, nil)
+
+		_, err := InitAuditLog(common.AuditLogConfig{Enable: true, FilePath: filepath.Join(dir, "audit.log")})
+		Ω(err).Should(BeNil())
+
+		r := httptest.NewRequest(http.MethodPost, "/schema/tables", nil)
+		r.Header.Set("RPC-Caller", "ingester")
+		AuditRequest(r, AuditOpCreateTable, "t1", nil, nil)
+
+		r = httptest.NewRequest(http.MethodDelete, "/schema/tables/t1", nil)
+		r = r.WithContext(WithPrincipal(r.Context(), &Principal{Name: "alice"}))
+		AuditRequest(r, AuditOpDeleteTable, "t1", nil, errors.New("failed"))
+
+		wrapper := WithAdminAuditFunc(func(r *http.Request) string {
+			return "t1"
+		})
+		handler := wrapper(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		})
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/dbg/t1/0/archive", nil))
+
+		// authentication runs before audit so that the principal is recorded as actor.
+		authenticator, err := NewAuthenticator(common.AuthConfig{
+			Enable: true,
+			Method: common.AuthMethodJWT,
+			JWT:    common.JWTConfig{Secret: "secret"},
+		})
+		Ω(err).Should(BeNil())
+		handler = ApplyHTTPWrappers(wrapper(func(w http.ResponseWriter, r *http.Request) {}),
+			[]HTTPHandlerWrapper{WithAuthentication(authenticator)})
+		r = httptest.NewRequest(http.MethodPost, "/dbg/t1/0/snapshot", nil)
+		r.Header.Set("Authorization", "Bearer "+signTestJWT("secret", `{"alg":"HS256","typ":"JWT"}`, `{"sub":"bob"}`))
+		handler(httptest.NewRecorder(), r)
+
+		entries, err := GetAuditLog().Query(AuditLogFilter{})
+		Ω(err).Should(BeNil())
+		Ω(entries).Should(HaveLen(4))
+		Ω(entries[0].Actor).Should(Equal("bob"))
+		Ω(entries[0].Request).Should(Equal("POST /dbg/t1/0/snapshot"))
+		Ω(entries[0].Error).Should(BeEmpty())
+		Ω(entries[1].Operation).Should(Equal(AuditOpAdmin))
+		Ω(entries[1].Request).Should(Equal("POST /dbg/t1/0/archive"))
+		Ω(entries[1].Table).Should(Equal("t1"))
+		Ω(entries[1].Error).Should(Equal("responded with status 400"))
+		Ω(entries[2].Actor).Should(Equal("alice"))
+		Ω(entries[2].Error).Should(Equal("failed"))
+		Ω(entries[3].Actor).Should(Equal("ingester"))
+		Ω(entries[3].Source).ShouldNot(BeEmpty())
+	})
+})
@@ -0,0 +1,253 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/uber/aresdb/common"
+	"net/http"
+	"strings"
+)
+
+// PrincipalHeaderKey is the http header a trusted proxy (e.g. broker) carries the principal
+// it makes requests on behalf of in.
+const PrincipalHeaderKey = "X-Ares-Principal"
+
+type principalContextKey struct{}
+
+type credentialsContextKey struct{}
+
+// Principal is the authenticated identity of a request.
+type Principal struct {
+	Name   string   `json:"name"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// Identities returns the name and groups of the principal used for matching ACLs.
+func (p *Principal) Identities() []string {
+	return append([]string{p.Name}, p.Groups...)
+}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated principal.
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated principal carried by ctx, nil if
+// the request is not authenticated.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalContextKey{}).(*Principal)
+	return principal
+}
+
+// WithCredentials returns a copy of ctx carrying the Authorization header of the
+// authenticated request.
+func WithCredentials(ctx context.Context, authorization string) context.Context {
+	return context.WithValue(ctx, credentialsContextKey{}, authorization)
+}
+
+// CredentialsFromContext returns the Authorization header carried by ctx, empty if none.
+func CredentialsFromContext(ctx context.Context) string {
+	authorization, _ := ctx.Value(credentialsContextKey{}).(string)
+	return authorization
+}
+
+// WithAuthenticationFrom returns a copy of ctx carrying the principal and credentials of
+// the request context src.
+func WithAuthenticationFrom(ctx, src context.Context) context.Context {
+	return WithPrincipal(WithCredentials(ctx, CredentialsFromContext(src)), PrincipalFromContext(src))
+}
+
+// InjectCredentials writes the credentials and principal carried by ctx into http headers
+// of outgoing requests made on behalf of the principal.
+func InjectCredentials(ctx context.Context, header http.Header) {
+	if authorization := CredentialsFromContext(ctx); authorization != "" {
+		header.Set("Authorization", authorization)
+	}
+	if principal := PrincipalFromContext(ctx); principal != nil {
+		if bs, err := json.Marshal(principal); err == nil {
+			header.Set(PrincipalHeaderKey, string(bs))
+		}
+	}
+}
+
+// Authenticator authenticates http requests.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// NewAuthenticator creates the authenticator of the configured method, returns nil if
+// authentication is not enabled.
+func NewAuthenticator(cfg common.AuthConfig) (Authenticator, error) {
+	if !cfg.Enable {
+		return nil, nil
+	}
+	var authenticator Authenticator
+	switch cfg.Method {
+	case common.AuthMethodJWT:
+		if cfg.JWT.Secret == "" {
+			return nil, errors.New("jwt secret is not configured")
+		}
+		authenticator = &jwtAuthenticator{secret: []byte(cfg.JWT.Secret), issuer: cfg.JWT.Issuer}
+	case common.AuthMethodMTLS:
+		authenticator = mtlsAuthenticator{}
+	default:
+		return nil, fmt.Errorf("unknown auth method %s", cfg.Method)
+	}
+	if len(cfg.TrustedProxies) > 0 {
+		trusted := make(map[string]bool, len(cfg.TrustedProxies))
+		for _, proxy := range cfg.TrustedProxies {
+			trusted[proxy] = true
+		}
+		authenticator = &proxyAuthenticator{Authenticator: authenticator, trusted: trusted}
+	}
+	return authenticator, nil
+}
+
+// WithAuthentication returns the wrapper rejecting requests failing authentication with
+// StatusUnauthorized and carrying the authenticated principal in request context.
+func WithAuthentication(authenticator Authenticator) HTTPHandlerWrapper {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			principal, err := authenticator.Authenticate(r)
+			if err != nil {
+				GetRootReporter().GetCounter(AuthenticationFailed).Inc(1)
+				w.Header().Set(HTTPContentTypeHeaderKey, HTTPContentTypeApplicationJson)
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(APIError{Message: fmt.Sprintf("Authentication failed: %s", err)})
+				return
+			}
+			ctx := WithCredentials(r.Context(), r.Header.Get("Authorization"))
+			h.ServeHTTP(w, r.WithContext(WithPrincipal(ctx, principal)))
+		}
+	}
+}
+
+// proxyAuthenticator authenticates requests of trusted proxies on behalf of the principal in
+// PrincipalHeaderKey header, e.g. brokers authenticated by client certificates forwarding
+// queries to datanodes. Requests of other principals ignore the header.
+type proxyAuthenticator struct {
+	Authenticator
+	trusted map[string]bool
+}
+
+func (a *proxyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	principal, err := a.Authenticator.Authenticate(r)
+	if err != nil {
+		return nil, err
+	}
+	forwarded := r.Header.Get(PrincipalHeaderKey)
+	if forwarded == "" || !a.trusted[principal.Name] {
+		return principal, nil
+	}
+	var onBehalfOf Principal
+	if err = json.Unmarshal([]byte(forwarded), &onBehalfOf); err != nil || onBehalfOf.Name == "" {
+		return nil, errors.New("malformed forwarded principal")
+	}
+	return &onBehalfOf, nil
+}
+
+// jwtAuthenticator authenticates requests by HS256 signed json web tokens in the
+// Authorization bearer header. Subject and groups claims identify the principal.
+type jwtAuthenticator struct {
+	secret []byte
+	issuer string
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+}
+
+type jwtClaims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	ExpiresAt int64    `json:"exp"`
+	Groups    []string `json:"groups"`
+}
+
+func (a *jwtAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return nil, errors.New("missing bearer token")
+	}
+	segments := strings.Split(strings.TrimPrefix(authorization, "Bearer "), ".")
+	if len(segments) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeJWTSegment(segments[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "HS256" {
+		return nil, fmt.Errorf("unsupported signing algorithm %s", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(segments[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(segments[0] + "." + segments[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errors.New("invalid token signature")
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTSegment(segments[1], &claims); err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("missing token subject")
+	}
+	if a.issuer != "" && claims.Issuer != a.issuer {
+		return nil, fmt.Errorf("unexpected token issuer %s", claims.Issuer)
+	}
+	if claims.ExpiresAt > 0 && Now().Unix() >= claims.ExpiresAt {
+		return nil, errors.New("token expired")
+	}
+	return &Principal{Name: claims.Subject, Groups: claims.Groups}, nil
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	bs, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	if err = json.Unmarshal(bs, v); err != nil {
+		return errors.New("malformed token")
+	}
+	return nil
+}
+
+// mtlsAuthenticator authenticates requests by verified tls client certificates. Common name
+// and organizational units of the certificate subject identify the principal.
+type mtlsAuthenticator struct{}
+
+func (mtlsAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, errors.New("missing verified client certificate")
+	}
+	subject := r.TLS.VerifiedChains[0][0].Subject
+	if subject.CommonName == "" {
+		return nil, errors.New("missing client certificate common name")
+	}
+	return &Principal{Name: subject.CommonName, Groups: subject.OrganizationalUnit}, nil
+}
@@ -0,0 +1,178 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/common"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+func signTestJWT(secret, header, claims string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+var _ = ginkgo.Describe("auth", func() {
+	ginkgo.AfterEach(func() {
+		ResetClockImplementation()
+	})
+
+	ginkgo.It("NewAuthenticator should work", func() {
+		authenticator, err := NewAuthenticator(common.AuthConfig{})
+		Ω(err).Should(BeNil())
+		Ω(authenticator).Should(BeNil())
+
+		_, err = NewAuthenticator(common.AuthConfig{Enable: true, Method: common.AuthMethodJWT})
+		Ω(err).ShouldNot(BeNil())
+
+		_, err = NewAuthenticator(common.AuthConfig{Enable: true, Method: "basic"})
+		Ω(err).ShouldNot(BeNil())
+
+		authenticator, err = NewAuthenticator(common.AuthConfig{Enable: true, Method: common.AuthMethodMTLS})
+		Ω(err).Should(BeNil())
+		Ω(authenticator).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("jwt authenticator should work", func() {
+		SetCurrentTime(time.Unix(1000, 0))
+		authenticator, err := NewAuthenticator(common.AuthConfig{
+			Enable: true,
+			Method: common.AuthMethodJWT,
+			JWT:    common.JWTConfig{Secret: "secret", Issuer: "ares"},
+		})
+		Ω(err).Should(BeNil())
+
+		header := `{"alg":"HS256","typ":"JWT"}`
+		r := httptest.NewRequest(http.MethodGet, "https://localhost/test", nil)
+		r.Header.Set("Authorization", "Bearer "+signTestJWT("secret", header, `{"sub":"alice","iss":"ares","exp":2000,"groups":["analysts"]}`))
+		principal, err := authenticator.Authenticate(r)
+		Ω(err).Should(BeNil())
+		Ω(*principal).Should(Equal(Principal{Name: "alice", Groups: []string{"analysts"}}))
+		Ω(principal.Identities()).Should(Equal([]string{"alice", "analysts"}))
+
+		for _, authorization := range []string{
+			"",
+			"Bearer abc",
+			"Bearer " + signTestJWT("other", header, `{"sub":"alice","iss":"ares"}`),
+			"Bearer " + signTestJWT("secret", `{"alg":"none"}`, `{"sub":"alice","iss":"ares"}`),
+			"Bearer " + signTestJWT("secret", header, `{"sub":"alice","iss":"other"}`),
+			"Bearer " + signTestJWT("secret", header, `{"sub":"alice","iss":"ares","exp":1000}`),
+			"Bearer " + signTestJWT("secret", header, `{"iss":"ares"}`),
+		} {
+			r.Header.Set("Authorization", authorization)
+			_, err = authenticator.Authenticate(r)
+			Ω(err).ShouldNot(BeNil())
+		}
+	})
+
+	ginkgo.It("mtls authenticator should work", func() {
+		authenticator := mtlsAuthenticator{}
+		r := httptest.NewRequest(http.MethodGet, "https://localhost/test", nil)
+		r.TLS = nil
+		_, err := authenticator.Authenticate(r)
+		Ω(err).ShouldNot(BeNil())
+
+		r.TLS = &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{
+				Subject: pkix.Name{CommonName: "svc", OrganizationalUnit: []string{"infra"}},
+			}}},
+		}
+		principal, err := authenticator.Authenticate(r)
+		Ω(err).Should(BeNil())
+		Ω(*principal).Should(Equal(Principal{Name: "svc", Groups: []string{"infra"}}))
+	})
+
+	ginkgo.It("proxy authenticator should work", func() {
+		authenticator, err := NewAuthenticator(common.AuthConfig{
+			Enable:         true,
+			Method:         common.AuthMethodMTLS,
+			TrustedProxies: []string{"broker"},
+		})
+		Ω(err).Should(BeNil())
+
+		r := httptest.NewRequest(http.MethodGet, "https://localhost/test", nil)
+		r.TLS = &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "broker"}}}},
+		}
+		principal, err := authenticator.Authenticate(r)
+		Ω(err).Should(BeNil())
+		Ω(principal.Name).Should(Equal("broker"))
+
+		r.Header.Set(PrincipalHeaderKey, `{"name":"alice","groups":["analysts"]}`)
+		principal, err = authenticator.Authenticate(r)
+		Ω(err).Should(BeNil())
+		Ω(*principal).Should(Equal(Principal{Name: "alice", Groups: []string{"analysts"}}))
+
+		r.Header.Set(PrincipalHeaderKey, `{}`)
+		_, err = authenticator.Authenticate(r)
+		Ω(err).ShouldNot(BeNil())
+
+		// forwarded principal of untrusted callers is ignored.
+		r.TLS.VerifiedChains[0][0].Subject.CommonName = "svc"
+		r.Header.Set(PrincipalHeaderKey, `{"name":"alice"}`)
+		principal, err = authenticator.Authenticate(r)
+		Ω(err).Should(BeNil())
+		Ω(principal.Name).Should(Equal("svc"))
+	})
+
+	ginkgo.It("InjectCredentials should work", func() {
+		header := http.Header{}
+		InjectCredentials(context.Background(), header)
+		Ω(header).Should(BeEmpty())
+
+		r := httptest.NewRequest(http.MethodGet, "https://localhost/test", nil)
+		r = r.WithContext(WithPrincipal(WithCredentials(r.Context(), "Bearer abc"), &Principal{Name: "alice"}))
+		ctx := WithAuthenticationFrom(context.Background(), r.Context())
+		InjectCredentials(ctx, header)
+		Ω(header.Get("Authorization")).Should(Equal("Bearer abc"))
+		Ω(header.Get(PrincipalHeaderKey)).Should(Equal(`{"name":"alice"}`))
+	})
+
+	ginkgo.It("WithAuthentication should work", func() {
+		authenticator := &jwtAuthenticator{secret: []byte("secret")}
+		var principal *Principal
+		var credentials string
+		handler := WithAuthentication(authenticator)(func(w http.ResponseWriter, r *http.Request) {
+			principal = PrincipalFromContext(r.Context())
+			credentials = CredentialsFromContext(r.Context())
+		})
+
+		r := httptest.NewRequest(http.MethodGet, "https://localhost/test", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		Ω(w.Code).Should(Equal(http.StatusUnauthorized))
+		Ω(w.Body.String()).Should(ContainSubstring("missing bearer token"))
+		Ω(principal).Should(BeNil())
+
+		r.Header.Set("Authorization", "Bearer "+signTestJWT("secret", `{"alg":"HS256"}`, `{"sub":"alice"}`))
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		Ω(w.Code).Should(Equal(http.StatusOK))
+		Ω(principal.Name).Should(Equal("alice"))
+		Ω(credentials).Should(Equal(r.Header.Get("Authorization")))
+	})
+})
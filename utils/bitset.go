@@ -0,0 +1,146 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/binary"
+	"io"
+	"math/bits"
+)
+
+const (
+	// bitsetFormatVersion is written before each serialized bitset so that the layout can be
+	// changed without misreading files written by older versions.
+	bitsetFormatVersion uint32 = 1
+	// bitsets hold uint32 values.
+	maxBitsetWords = (1 << 32) / 64
+)
+
+// Bitset is a bitmap of uint32 values backed by a slice of 64 bit words, which grows to hold
+// the largest value added. It is meant for values bounded by a small range, e.g. rows of an
+// archive batch.
+type Bitset struct {
+	words []uint64
+}
+
+// NewBitset creates an empty Bitset.
+func NewBitset() *Bitset {
+	return &Bitset{}
+}
+
+// Add adds the value to the bitset.
+func (b *Bitset) Add(value uint32) {
+	word := int(value / 64)
+	if word >= len(b.words) {
+		b.words = append(b.words, make([]uint64, word+1-len(b.words))...)
+	}
+	b.words[word] |= uint64(1) << (value % 64)
+}
+
+// Contains tells whether the value is in the bitset.
+func (b *Bitset) Contains(value uint32) bool {
+	word := int(value / 64)
+	return word < len(b.words) && b.words[word]&(uint64(1)<<(value%64)) != 0
+}
+
+// Cardinality returns the number of values in the bitset.
+func (b *Bitset) Cardinality() int {
+	cardinality := 0
+	for _, word := range b.words {
+		cardinality += bits.OnesCount64(word)
+	}
+	return cardinality
+}
+
+// IsEmpty tells whether the bitset has no values.
+func (b *Bitset) IsEmpty() bool {
+	for _, word := range b.words {
+		if word != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ToArray returns all values in the bitset in ascending order.
+func (b *Bitset) ToArray() []uint32 {
+	values := make([]uint32, 0, b.Cardinality())
+	for w, word := range b.words {
+		for ; word != 0; word &= word - 1 {
+			values = append(values, uint32(w*64+bits.TrailingZeros64(word)))
+		}
+	}
+	return values
+}
+
+// Or returns the union of the two bitsets as a new bitset.
+func (b *Bitset) Or(other *Bitset) *Bitset {
+	longer, shorter := b.words, other.words
+	if len(longer) < len(shorter) {
+		longer, shorter = shorter, longer
+	}
+	result := &Bitset{words: append([]uint64(nil), longer...)}
+	for w, word := range shorter {
+		result.words[w] |= word
+	}
+	return result
+}
+
+// And returns the intersection of the two bitsets as a new bitset.
+func (b *Bitset) And(other *Bitset) *Bitset {
+	numWords := len(b.words)
+	if len(other.words) < numWords {
+		numWords = len(other.words)
+	}
+	result := &Bitset{words: make([]uint64, numWords)}
+	for w := range result.words {
+		result.words[w] = b.words[w] & other.words[w]
+	}
+	// trailing empty words are dropped.
+	for len(result.words) > 0 && result.words[len(result.words)-1] == 0 {
+		result.words = result.words[:len(result.words)-1]
+	}
+	return result
+}
+
+// Write serializes the bitset to the writer as the format version and the number of words
+// followed by the words, all in little endian.
+func (b *Bitset) Write(writer io.Writer) error {
+	header := [2]uint32{bitsetFormatVersion, uint32(len(b.words))}
+	if err := binary.Write(writer, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	return binary.Write(writer, binary.LittleEndian, b.words)
+}
+
+// Read deserializes the bitset written by Write from the reader, replacing current values.
+func (b *Bitset) Read(reader io.Reader) error {
+	var header [2]uint32
+	if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	if header[0] != bitsetFormatVersion {
+		return StackError(nil, "unsupported bitset format version %d, expect %d", header[0], bitsetFormatVersion)
+	}
+	if header[1] > maxBitsetWords {
+		return StackError(nil, "invalid bitset with %d words, max %d", header[1], maxBitsetWords)
+	}
+	words := make([]uint64, header[1])
+	if err := binary.Read(reader, binary.LittleEndian, words); err != nil {
+		return err
+	}
+	b.words = words
+	return nil
+}
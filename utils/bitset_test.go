@@ -0,0 +1,89 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("bitset", func() {
+	newBitset := func(values ...uint32) *Bitset {
+		b := NewBitset()
+		for _, v := range values {
+			b.Add(v)
+		}
+		return b
+	}
+
+	// dense returns count consecutive values starting from start.
+	dense := func(start, count uint32) []uint32 {
+		values := make([]uint32, count)
+		for i := range values {
+			values[i] = start + uint32(i)
+		}
+		return values
+	}
+
+	ginkgo.It("should add values", func() {
+		b := newBitset(70000, 3, 1, 3, 65536)
+		Ω(b.ToArray()).Should(Equal([]uint32{1, 3, 65536, 70000}))
+		Ω(b.Cardinality()).Should(Equal(4))
+		Ω(b.Contains(3)).Should(BeTrue())
+		Ω(b.Contains(2)).Should(BeFalse())
+		Ω(b.Contains(131072)).Should(BeFalse())
+		Ω(NewBitset().IsEmpty()).Should(BeTrue())
+
+		b = newBitset(dense(10, 5000)...)
+		Ω(b.Cardinality()).Should(Equal(5000))
+		b.Add(10)
+		Ω(b.Cardinality()).Should(Equal(5000))
+		Ω(b.Contains(5009)).Should(BeTrue())
+		Ω(b.Contains(5010)).Should(BeFalse())
+		Ω(b.ToArray()).Should(Equal(dense(10, 5000)))
+	})
+
+	ginkgo.It("Or and And should work", func() {
+		a := newBitset(append(dense(0, 3000), 1<<20)...)
+		b := newBitset(append(dense(2000, 3000), 1<<21)...)
+
+		Ω(a.Or(b).ToArray()).Should(Equal(append(dense(0, 5000), 1<<20, 1<<21)))
+		Ω(a.And(b).ToArray()).Should(Equal(dense(2000, 1000)))
+		Ω(a.And(newBitset(1 << 21)).IsEmpty()).Should(BeTrue())
+		Ω(newBitset(1, 2).Or(newBitset(2, 3)).ToArray()).Should(Equal([]uint32{1, 2, 3}))
+		// operands are not modified.
+		Ω(a.Cardinality()).Should(Equal(3001))
+		Ω(b.Cardinality()).Should(Equal(3001))
+	})
+
+	ginkgo.It("should serialize and deserialize", func() {
+		b := newBitset(append(dense(0, 5000), 7, 1<<20)...)
+		buffer := &bytes.Buffer{}
+		Ω(b.Write(buffer)).Should(BeNil())
+
+		read := NewBitset()
+		Ω(read.Read(bytes.NewReader(buffer.Bytes()))).Should(BeNil())
+		Ω(read.ToArray()).Should(Equal(b.ToArray()))
+		Ω(read.Read(bytes.NewReader(buffer.Bytes()[:20]))).ShouldNot(BeNil())
+
+		// unknown format versions are rejected.
+		data := append([]byte(nil), buffer.Bytes()...)
+		binary.LittleEndian.PutUint32(data, bitsetFormatVersion+1)
+		Ω(read.Read(bytes.NewReader(data))).ShouldNot(BeNil())
+	})
+})
@@ -19,19 +19,69 @@ const (
 	groupBits = 14
 	// max 16 bits for group
 	maxGroupBits = 16
+	// hll values of lower precision have the precision stored in the highest byte, which is
+	// ignored when aggregating on device, values of the default precision have it unset.
+	precisionShift = 24
+	// min number of bits in hash as group
+	minGroupBits = 4
 )
 
 // ComputeHLLValue compute hll value based on hash value
 func ComputeHLLValue(hash uint64) uint32 {
-	group := uint32(hash & ((1 << groupBits) - 1))
+	return ComputeHLLValueWithPrecision(hash, groupBits)
+}
+
+// ComputeHLLValueWithPrecision computes hll value based on hash value using the lower precision
+// bits in hash as group, precision should be between 4 and 14.
+func ComputeHLLValueWithPrecision(hash uint64, precision int) uint32 {
+	group := uint32(hash & ((1 << uint(precision)) - 1))
 	var rho uint32
 	for {
-		h := hash & (1 << (rho + groupBits))
-		if rho+groupBits < 64 && h == 0 {
+		h := hash & (1 << (rho + uint32(precision)))
+		if rho+uint32(precision) < 64 && h == 0 {
 			rho++
 		} else {
 			break
 		}
 	}
-	return rho<<maxGroupBits | group
+	return encodeHLLValue(rho, group, precision)
+}
+
+// GetHLLValuePrecision returns the number of bits in hash used as group of the hll value.
+func GetHLLValuePrecision(value uint32) int {
+	if precision := int(value >> precisionShift); precision != 0 {
+		return precision
+	}
+	return groupBits
+}
+
+// ReduceHLLValuePrecision converts the hll value to the lower precision as if it were computed
+// from the same hash with the precision. Values of no higher precision are returned as is.
+func ReduceHLLValuePrecision(value uint32, precision int) uint32 {
+	oldPrecision := GetHLLValuePrecision(value)
+	if precision < minGroupBits || oldPrecision <= precision {
+		return value
+	}
+
+	group := value & ((1 << maxGroupBits) - 1)
+	rho := (value >> maxGroupBits) & 0xFF
+	// the group bits dropped from the group are the lowest bits of the rest of the hash.
+	if dropped := group >> uint(precision); dropped != 0 {
+		rho = 0
+		for dropped&1 == 0 {
+			dropped >>= 1
+			rho++
+		}
+	} else {
+		rho += uint32(oldPrecision - precision)
+	}
+	return encodeHLLValue(rho, group&((1<<uint(precision))-1), precision)
+}
+
+func encodeHLLValue(rho, group uint32, precision int) uint32 {
+	value := rho<<maxGroupBits | group
+	if precision != groupBits {
+		value |= uint32(precision) << precisionShift
+	}
+	return value
 }
@@ -37,4 +37,25 @@ var _ = ginkgo.Describe("hyperloglog utils", func() {
 			Ω(out).Should(Equal(expected))
 		}
 	})
+	ginkgo.It("ReduceHLLValuePrecision should work", func() {
+		hashes := []uint64{
+			0, 0xffffffffffffffff, 0xf0f0f0f0f0f0f0f0, 0x0f0f0f0f0f0f0f0f,
+			8849112093580131862, 720734999560851427, 506097522914230528 ^ 1084818905618843912,
+		}
+
+		for _, hash := range hashes {
+			value := ComputeHLLValue(hash)
+			Ω(GetHLLValuePrecision(value)).Should(Equal(14))
+			Ω(ReduceHLLValuePrecision(value, 14)).Should(Equal(value))
+			for precision := 4; precision < 14; precision++ {
+				expected := ComputeHLLValueWithPrecision(hash, precision)
+				Ω(GetHLLValuePrecision(expected)).Should(Equal(precision))
+				Ω(ReduceHLLValuePrecision(value, precision)).Should(Equal(expected))
+				// reducing again is a noop.
+				Ω(ReduceHLLValuePrecision(expected, precision)).Should(Equal(expected))
+				Ω(ReduceHLLValuePrecision(expected, 14)).Should(Equal(expected))
+			}
+		}
+		Ω(ComputeHLLValueWithPrecision(0x0f0f0f0f0f0f0f0f, 4)).Should(Equal(uint32(4<<24 | 4<<16 | 0x0f)))
+	})
 })
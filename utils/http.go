@@ -15,12 +15,10 @@
 package utils
 
 import (
-	"fmt"
 	"github.com/uber/aresdb/common"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 	"golang.org/x/net/netutil"
-	"net"
 	"net/http"
 	"strconv"
 	"time"
@@ -145,8 +143,9 @@ func ApplyHTTPWrappers(handler http.HandlerFunc, wrappers []HTTPHandlerWrapper)
 }
 
 // LimitServe will start a http server on the port with the handler and at most maxConnection concurrent connections.
+// Connections are served over tls if enabled.
 func LimitServe(port int, handler http.Handler, httpCfg common.HTTPConfig) {
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	listener, err := Listen(port, true)
 	if err != nil {
 		GetLogger().Fatal(err)
 	}
@@ -160,3 +159,14 @@ func LimitServe(port int, handler http.Handler, httpCfg common.HTTPConfig) {
 	}
 	GetLogger().Fatal(server.Serve(listener))
 }
+
+// ListenAndServe serves the handler on the port over tls if enabled, client certificates
+// are not required so that health checks on debug ports keep working.
+func ListenAndServe(port int, handler http.Handler) error {
+	listener, err := Listen(port, false)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	return http.Serve(listener, handler)
+}
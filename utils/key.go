@@ -66,6 +66,16 @@ func SchemaKey(namespace, name string) string {
 	return path.Join(SchemaListKey(namespace), name)
 }
 
+// SchemaRolloutListKey builds key for schema rollouts
+func SchemaRolloutListKey(namespace string) string {
+	return path.Join(NamespaceKey(namespace), "schema_rollout")
+}
+
+// SchemaRolloutKey builds key for schema rollout of a table
+func SchemaRolloutKey(namespace, name string) string {
+	return path.Join(SchemaRolloutListKey(namespace), name)
+}
+
 // JobKey builds key for job config
 func JobKey(namespace, name string) string {
 	return path.Join(JobListKey(namespace), name)
@@ -59,17 +59,21 @@ const (
 	HTTPHandlerCall
 	HTTPHandlerLatency
 	IngestSkippedRecords
+	IngestRejectedGeoShapes
 	IngestedErrorBatches
 	IngestedRecords
 	IngestedRecoveryBatches
 	IngestedUpsertBatches
+	IngestionThrottled
 	IngestionLagPerColumn
 	JobFailuresCount
 	ManagedMemorySize
 	MemoryOverflow
 	NumberOfEnumCasesPerColumn
+	EnumCapacityUsage
 	NumberOfRedologs
 	PreloadingZoneEvicted
+	ArchiveColumnSpilled
 	PrimaryKeyMissing
 	PurgeCount
 	PurgeTimingTotal
@@ -84,6 +88,7 @@ const (
 	QueryLiveBytesTransferred
 	QueryLiveRecordsProcessed
 	QueryReceived
+	QueryBlocked
 	QueryRowsReturned
 	QuerySQLParsingLatency
 	QuerySucceeded
@@ -118,18 +123,79 @@ const (
 	UnmanagedMemorySize
 	UpdatedRecords
 	UpsertBatchSize
+	QueryDimCardinalityExceeded
+	RedoLogBatchesFetched
+	QueryFeatureUsed
+	AuditLogWriteFailed
+	QueryWaitingForDevice
+	ReplicatedUpsertBatches
+	ReplicatedBytes
+	ReplicationFailure
+	ReplicationGap
+	ReplicationLagBytes
+	ReplicationLagSeconds
+	CDCEventsPublished
+	CDCPublishFailure
+	QueryDevicePartitions
+	DeviceColumnCacheHits
+	DeviceColumnCacheMisses
+	DeviceColumnCacheEvictions
+	DeviceColumnCacheRejections
+	DeviceColumnCacheMemory
 
 	// Broker metrics
 	AQLQueryReceivedBroker
 	SQLQueryReceivedBroker
 	QueryFailedBroker
+	QueryBlockedBroker
 	QuerySucceededBroker
 	QueryLatencyBroker
 	SQLParsingLatencyBroker
 	QueryPlanExecuteFailures
 	DataNodeQueryFailures
+	DataNodeQueryTimeouts
 	TimeWaitedForDataNode
 	TimeSerDeDataNodeResponse
+	QueryDimCardinalityExceededBroker
+	QueryShardCoverageDeduplicatedBroker
+	NonAggQueryShortCircuited
+	SlowQueryLoggedBroker
+	SlowQueryLogDroppedBroker
+	QueryAttachedBroker
+	AuthenticationFailed
+	ShardsRequestedBroker
+	ShardsPrunedBroker
+	BatchesScannedBroker
+	BatchesSkippedBroker
+	BytesTransferredBroker
+	CTASSucceededBroker
+	CTASFailedBroker
+	CTASRowsLoadedBroker
+	SQLInsertSucceededBroker
+	SQLInsertFailedBroker
+	QuerySplitByTimeRangeBroker
+	TimeRangeChunkFinishedBroker
+	TempTableCreatedBroker
+	TempTableCreateFailedBroker
+	TempTableJoinedBroker
+	SnapshotMergedBatches
+	CompactionCount
+	CompactionTimingTotal
+	CompactionMergedBatches
+	CompactionReclaimedRows
+	CompactionLag
+	ArchiveBatchesPrefetched
+	RedoLogBatchesCompacted
+	RedoLogChecksumMismatch
+	StaleEnumDictRefreshedBroker
+	HLLRecomputeTimingTotal
+	HLLRecomputedBatches
+	QueryQueueDepthBroker
+	QueryQueueWaitTimeBroker
+	QueryRejectedBroker
+	InFlightQueriesBroker
+	ActiveScatterRPCsBroker
+	OldestInFlightQueryAgeBroker
 
 	MetricNamesSentinel
 )
@@ -142,6 +208,15 @@ const (
 	Counter MetricType = iota
 	Gauge
 	Timer
+	Histogram
+)
+
+// Buckets of histogram metrics.
+var (
+	// for number of shards or batches
+	countBuckets = tally.MustMakeExponentialValueBuckets(1, 2, 20)
+	// for number of bytes, from 1KB to 16GB
+	bytesBuckets = tally.MustMakeExponentialValueBuckets(1024, 4, 13)
 )
 
 // metricDefinition contains the definition for a metric.
@@ -161,6 +236,11 @@ type metricDefinition struct {
 
 	// cached tally timer
 	timer tally.Timer
+
+	// buckets of histogram
+	buckets tally.Buckets
+	// cached tally histogram
+	histogram tally.Histogram
 }
 
 // Scope names .
@@ -191,7 +271,9 @@ const (
 	scopeNameAppendedRecords                 = "appended_records"
 	scopeNameUpdatedRecords                  = "updated_records"
 	scopeNameIngestSkippedRecords            = "skipped_records"
+	scopeNameIngestRejectedGeoShapes         = "rejected_geo_shapes"
 	scopeNameIngestedUpsertBatches           = "ingested_upsert_batches"
+	scopeNameIngestionThrottled              = "ingestion_throttled"
 	scopeNameIngestedRecoveryBatches         = "ingested_recovery_batches"
 	scopeNameIngestedErrorBatches            = "ingested_error_batches"
 	scopeNameUpsertBatchSize                 = "upsert_batch_size"
@@ -212,6 +294,7 @@ const (
 	scopeNameNumberOfRedologs                = "number_of_redologs"
 	scopeNameSizeOfRedologs                  = "size_of_redologs"
 	scopeNameNumberOfEnumCasesPerColumn      = "number_of_enum_cases"
+	scopeNameEnumCapacityUsage               = "enum_capacity_usage"
 	scopeNameQueryFailed                     = "query_failed"
 	scopeNameQuerySucceeded                  = "query_succeeded"
 	scopeNameQueryLatency                    = "query_latency"
@@ -219,6 +302,7 @@ const (
 	scopeNameQuerySQLParsingLatency          = "sql_parsing_latency"
 	scopeNameQueryWaitForMemoryDuration      = "query_wait_for_memory_duration"
 	scopeNameQueryReceived                   = "query_received"
+	scopeNameQueryBlocked                    = "query_blocked"
 	scopeNameQueryRecordsProcessed           = "records_processed"
 	scopeNameQueryBatchProcessed             = "batch_processed"
 	scopeNameQueryBytesTransferred           = "bytes_transferred"
@@ -234,6 +318,7 @@ const (
 	scopeNameRawVPFetchTime                  = "raw_vp_fetch_time"
 	scopeNameTotalRawVPFetchTime             = "total_raw_vp_fetch_time"
 	scopeNamePreloadingZoneEvicted           = "preloading_zone_evicted"
+	scopeNameArchiveColumnSpilled            = "archive_column_spilled"
 	scopeNameBatchesPurged                   = "purged_batches"
 	scopeNameFutureRecords                   = "records_from_future"
 	scopeNameBatchSize                       = "batch_size"
@@ -245,18 +330,75 @@ const (
 	scopeNameSchemaDeletionCount             = "schema_deletions"
 	scopeNameSchemaCreationCount             = "schema_creations"
 	scopeNameJobFailuresCount                = "job_failures_count"
+	scopeNameQueryDimCardinalityExceeded     = "query_dim_cardinality_exceeded"
+	scopeNameRedoLogBatchesFetched           = "redolog_batches_fetched"
+	scopeNameQueryFeatureUsed                = "query_feature_used"
+	scopeNameAuditLogWriteFailed             = "audit_log.write_failed"
+	scopeNameQueryWaitingForDevice           = "query_waiting_for_device"
+	scopeNameReplicatedUpsertBatches         = "replicated_upsert_batches"
+	scopeNameReplicatedBytes                 = "replicated_bytes"
+	scopeNameReplicationFailure              = "replication_failure"
+	scopeNameReplicationGap                  = "replication_gap"
+	scopeNameReplicationLagBytes             = "replication_lag_bytes"
+	scopeNameReplicationLagSeconds           = "replication_lag_seconds"
+	scopeNameCDCEventsPublished              = "cdc.events_published"
+	scopeNameCDCPublishFailure               = "cdc.publish_failure"
+	scopeNameQueryDevicePartitions           = "query_device_partitions"
+	scopeNameDeviceColumnCacheHits           = "device_column_cache_hits"
+	scopeNameDeviceColumnCacheMisses         = "device_column_cache_misses"
+	scopeNameDeviceColumnCacheEvictions      = "device_column_cache_evictions"
+	scopeNameDeviceColumnCacheRejections     = "device_column_cache_rejections"
+	scopeNameDeviceColumnCacheMemory         = "device_column_cache_memory"
 
 	// broker metrics
-	scopeNameAQLQueryReceivedBroker    = "aql_query_received_broker"
-	scopeNameSQLQueryReceivedBroker    = "sql_query_received_broker"
-	scopeNameQueryFailedBroker         = "query_failed_broker"
-	scopeNameQuerySucceededBroker      = "query_succeeded_broker"
-	scopeNameQueryLatencyBroker        = "query_latency_broker"
-	scopeNameSQLParsingLatencyBroker   = "sql_parsing_latency_broker"
-	scopeNameQueryPlanExecuteFailures  = "query_plan_execute_failures"
-	scopeNameDataNodeQueryFailures     = "datanode_query_failures"
-	scopeNameTimeWaitedForDataNode     = "time_waited_for_datanodes"
-	scopeNameTimeSerDeDataNodeResponse = "time_serde_response"
+	scopeNameAQLQueryReceivedBroker               = "aql_query_received_broker"
+	scopeNameSQLQueryReceivedBroker               = "sql_query_received_broker"
+	scopeNameQueryFailedBroker                    = "query_failed_broker"
+	scopeNameQueryBlockedBroker                   = "query_blocked_broker"
+	scopeNameQuerySucceededBroker                 = "query_succeeded_broker"
+	scopeNameQueryLatencyBroker                   = "query_latency_broker"
+	scopeNameSQLParsingLatencyBroker              = "sql_parsing_latency_broker"
+	scopeNameQueryPlanExecuteFailures             = "query_plan_execute_failures"
+	scopeNameDataNodeQueryFailures                = "datanode_query_failures"
+	scopeNameDataNodeQueryTimeouts                = "datanode_query_timeouts"
+	scopeNameTimeWaitedForDataNode                = "time_waited_for_datanodes"
+	scopeNameTimeSerDeDataNodeResponse            = "time_serde_response"
+	scopeNameQueryDimCardinalityExceededBroker    = "query_dim_cardinality_exceeded_broker"
+	scopeNameQueryShardCoverageDeduplicatedBroker = "query_shard_coverage_deduplicated_broker"
+	scopeNameNonAggQueryShortCircuited            = "non_agg_query_short_circuited"
+	scopeNameSlowQueryLoggedBroker                = "slow_query_logged_broker"
+	scopeNameSlowQueryLogDroppedBroker            = "slow_query_log_dropped_broker"
+	scopeNameQueryAttachedBroker                  = "query_attached_broker"
+	scopeNameAuthenticationFailed                 = "http.auth_failed"
+	scopeNameShardsRequestedBroker                = "shards_requested_broker"
+	scopeNameShardsPrunedBroker                   = "shards_pruned_broker"
+	scopeNameBatchesScannedBroker                 = "batches_scanned_broker"
+	scopeNameBatchesSkippedBroker                 = "batches_skipped_broker"
+	scopeNameBytesTransferredBroker               = "datanode_bytes_transferred_broker"
+	scopeNameCTASSucceededBroker                  = "ctas_succeeded_broker"
+	scopeNameCTASFailedBroker                     = "ctas_failed_broker"
+	scopeNameCTASRowsLoadedBroker                 = "ctas_rows_loaded_broker"
+	scopeNameSQLInsertSucceededBroker             = "sql_insert_succeeded_broker"
+	scopeNameSQLInsertFailedBroker                = "sql_insert_failed_broker"
+	scopeNameQuerySplitByTimeRangeBroker          = "query_split_by_time_range_broker"
+	scopeNameTimeRangeChunkFinishedBroker         = "time_range_chunk_finished_broker"
+	scopeNameTempTableCreatedBroker               = "temp_table_created_broker"
+	scopeNameTempTableCreateFailedBroker          = "temp_table_create_failed_broker"
+	scopeNameTempTableJoinedBroker                = "temp_table_joined_broker"
+	scopeNameMergedBatches                        = "merged_batches"
+	scopeNameReclaimedRows                        = "reclaimed_rows"
+	scopeNameCompactionLag                        = "compaction_lag"
+	scopeNameArchiveBatchesPrefetched             = "archive_batches_prefetched"
+	scopeNameRedoLogBatchesCompacted              = "redo_log_batches_compacted"
+	scopeNameRedoLogChecksumMismatch              = "redo_log_checksum_mismatch"
+	scopeNameStaleEnumDictRefreshedBroker         = "stale_enum_dict_refreshed_broker"
+	scopeNameRecomputedBatches                    = "recomputed_batches"
+	scopeNameQueryQueueDepthBroker                = "query_queue_depth_broker"
+	scopeNameQueryQueueWaitTimeBroker             = "query_queue_wait_time_broker"
+	scopeNameQueryRejectedBroker                  = "query_rejected_broker"
+	scopeNameInFlightQueriesBroker                = "in_flight_queries_broker"
+	scopeNameActiveScatterRPCsBroker              = "active_scatter_rpcs_broker"
+	scopeNameOldestInFlightQueryAgeBroker         = "oldest_in_flight_query_age_broker"
 )
 
 // Metric tag names
@@ -278,23 +420,27 @@ const (
 
 // Metric component tag values
 const (
-	metricsComponentMemStore  = "memstore"
-	metricsComponentAPI       = "api"
-	metricsComponentDiskStore = "diskstore"
-	metricsComponentMetaStore = "metastore"
-	metricsComponentQuery     = "query"
-	metricsComponentStats     = "stats"
+	metricsComponentMemStore    = "memstore"
+	metricsComponentAPI         = "api"
+	metricsComponentDiskStore   = "diskstore"
+	metricsComponentMetaStore   = "metastore"
+	metricsComponentQuery       = "query"
+	metricsComponentStats       = "stats"
+	metricsComponentReplication = "replication"
+	metricsComponentCDC         = "cdc"
 )
 
 // Metric operation tag values
 const (
-	metricsOperationArchiving = "archiving"
-	metricsOperationBackfill  = "backfill"
-	metricsOperationBootstrap = "bootstrap"
-	metricsOperationIngestion = "ingestion"
-	metricsOperationPurge     = "purge"
-	metricsOperationRecovery  = "recovery"
-	metricsOperationSnapshot  = "snapshot"
+	metricsOperationArchiving    = "archiving"
+	metricsOperationBackfill     = "backfill"
+	metricsOperationBootstrap    = "bootstrap"
+	metricsOperationIngestion    = "ingestion"
+	metricsOperationPurge        = "purge"
+	metricsOperationRecovery     = "recovery"
+	metricsOperationSnapshot     = "snapshot"
+	metricsOperationCompaction   = "compaction"
+	metricsOperationHLLRecompute = "hll_recompute"
 )
 
 var metricDefs = map[MetricName]metricDefinition{
@@ -430,6 +576,14 @@ var metricDefs = map[MetricName]metricDefinition{
 			metricsTagComponent: metricsComponentMemStore,
 		},
 	},
+	IngestRejectedGeoShapes: {
+		name:       scopeNameIngestRejectedGeoShapes,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagOperation: metricsOperationIngestion,
+			metricsTagComponent: metricsComponentMemStore,
+		},
+	},
 	IngestedUpsertBatches: {
 		name:       scopeNameIngestedUpsertBatches,
 		metricType: Counter,
@@ -438,6 +592,14 @@ var metricDefs = map[MetricName]metricDefinition{
 			metricsTagComponent: metricsComponentMemStore,
 		},
 	},
+	IngestionThrottled: {
+		name:       scopeNameIngestionThrottled,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagOperation: metricsOperationIngestion,
+			metricsTagComponent: metricsComponentMemStore,
+		},
+	},
 	IngestedRecoveryBatches: {
 		name:       scopeNameIngestedRecoveryBatches,
 		metricType: Counter,
@@ -673,6 +835,13 @@ var metricDefs = map[MetricName]metricDefinition{
 			metricsTagComponent: metricsComponentMetaStore,
 		},
 	},
+	EnumCapacityUsage: {
+		name:       scopeNameEnumCapacityUsage,
+		metricType: Gauge,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentMemStore,
+		},
+	},
 	QueryFailed: {
 		name:       scopeNameQueryFailed,
 		metricType: Counter,
@@ -722,6 +891,13 @@ var metricDefs = map[MetricName]metricDefinition{
 			metricsTagComponent: metricsComponentQuery,
 		},
 	},
+	QueryBlocked: {
+		name:       scopeNameQueryBlocked,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
 	QueryLiveRecordsProcessed: {
 		name:       scopeNameQueryRecordsProcessed,
 		metricType: Counter,
@@ -893,6 +1069,13 @@ var metricDefs = map[MetricName]metricDefinition{
 			metricsTagComponent: metricsComponentMemStore,
 		},
 	},
+	ArchiveColumnSpilled: {
+		name:       scopeNameArchiveColumnSpilled,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentMemStore,
+		},
+	},
 	PurgeTimingTotal: {
 		name:       scopeNameTotal,
 		metricType: Timer,
@@ -986,6 +1169,140 @@ var metricDefs = map[MetricName]metricDefinition{
 		metricType: Counter,
 		tags:       map[string]string{},
 	},
+	QueryDimCardinalityExceeded: {
+		name:       scopeNameQueryDimCardinalityExceeded,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	RedoLogBatchesFetched: {
+		name:       scopeNameRedoLogBatchesFetched,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentMemStore,
+			metricsTagOperation: metricsOperationBootstrap,
+		},
+	},
+	QueryFeatureUsed: {
+		name:       scopeNameQueryFeatureUsed,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	AuditLogWriteFailed: {
+		name:       scopeNameAuditLogWriteFailed,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentAPI,
+		},
+	},
+	QueryWaitingForDevice: {
+		name:       scopeNameQueryWaitingForDevice,
+		metricType: Gauge,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	ReplicatedUpsertBatches: {
+		name:       scopeNameReplicatedUpsertBatches,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentReplication,
+		},
+	},
+	ReplicatedBytes: {
+		name:       scopeNameReplicatedBytes,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentReplication,
+		},
+	},
+	ReplicationFailure: {
+		name:       scopeNameReplicationFailure,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentReplication,
+		},
+	},
+	ReplicationGap: {
+		name:       scopeNameReplicationGap,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentReplication,
+		},
+	},
+	ReplicationLagBytes: {
+		name:       scopeNameReplicationLagBytes,
+		metricType: Gauge,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentReplication,
+		},
+	},
+	ReplicationLagSeconds: {
+		name:       scopeNameReplicationLagSeconds,
+		metricType: Gauge,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentReplication,
+		},
+	},
+	CDCEventsPublished: {
+		name:       scopeNameCDCEventsPublished,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentCDC,
+		},
+	},
+	CDCPublishFailure: {
+		name:       scopeNameCDCPublishFailure,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentCDC,
+		},
+	},
+	QueryDevicePartitions: {
+		name:       scopeNameQueryDevicePartitions,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	DeviceColumnCacheHits: {
+		name:       scopeNameDeviceColumnCacheHits,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	DeviceColumnCacheMisses: {
+		name:       scopeNameDeviceColumnCacheMisses,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	DeviceColumnCacheEvictions: {
+		name:       scopeNameDeviceColumnCacheEvictions,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	DeviceColumnCacheRejections: {
+		name:       scopeNameDeviceColumnCacheRejections,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	DeviceColumnCacheMemory: {
+		name:       scopeNameDeviceColumnCacheMemory,
+		metricType: Gauge,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
 	AQLQueryReceivedBroker: {
 		name:       scopeNameAQLQueryReceivedBroker,
 		metricType: Counter,
@@ -1007,6 +1324,13 @@ var metricDefs = map[MetricName]metricDefinition{
 			metricsTagComponent: metricsComponentQuery,
 		},
 	},
+	QueryBlockedBroker: {
+		name:       scopeNameQueryBlockedBroker,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
 	QuerySucceededBroker: {
 		name:       scopeNameQuerySucceededBroker,
 		metricType: Counter,
@@ -1042,6 +1366,13 @@ var metricDefs = map[MetricName]metricDefinition{
 			metricsTagComponent: metricsComponentQuery,
 		},
 	},
+	DataNodeQueryTimeouts: {
+		name:       scopeNameDataNodeQueryTimeouts,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
 	TimeWaitedForDataNode: {
 		name:       scopeNameTimeWaitedForDataNode,
 		metricType: Timer,
@@ -1056,6 +1387,299 @@ var metricDefs = map[MetricName]metricDefinition{
 			metricsTagComponent: metricsComponentQuery,
 		},
 	},
+	QueryDimCardinalityExceededBroker: {
+		name:       scopeNameQueryDimCardinalityExceededBroker,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	QueryShardCoverageDeduplicatedBroker: {
+		name:       scopeNameQueryShardCoverageDeduplicatedBroker,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	NonAggQueryShortCircuited: {
+		name:       scopeNameNonAggQueryShortCircuited,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	SlowQueryLoggedBroker: {
+		name:       scopeNameSlowQueryLoggedBroker,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	SlowQueryLogDroppedBroker: {
+		name:       scopeNameSlowQueryLogDroppedBroker,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	QueryAttachedBroker: {
+		name:       scopeNameQueryAttachedBroker,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	AuthenticationFailed: {
+		name:       scopeNameAuthenticationFailed,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentAPI,
+		},
+	},
+	ShardsRequestedBroker: {
+		name:       scopeNameShardsRequestedBroker,
+		metricType: Histogram,
+		buckets:    countBuckets,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	ShardsPrunedBroker: {
+		name:       scopeNameShardsPrunedBroker,
+		metricType: Histogram,
+		buckets:    countBuckets,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	BatchesScannedBroker: {
+		name:       scopeNameBatchesScannedBroker,
+		metricType: Histogram,
+		buckets:    countBuckets,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	BatchesSkippedBroker: {
+		name:       scopeNameBatchesSkippedBroker,
+		metricType: Histogram,
+		buckets:    countBuckets,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	BytesTransferredBroker: {
+		name:       scopeNameBytesTransferredBroker,
+		metricType: Histogram,
+		buckets:    bytesBuckets,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	CTASSucceededBroker: {
+		name:       scopeNameCTASSucceededBroker,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentAPI,
+		},
+	},
+	CTASFailedBroker: {
+		name:       scopeNameCTASFailedBroker,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentAPI,
+		},
+	},
+	CTASRowsLoadedBroker: {
+		name:       scopeNameCTASRowsLoadedBroker,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentAPI,
+		},
+	},
+	SQLInsertSucceededBroker: {
+		name:       scopeNameSQLInsertSucceededBroker,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentAPI,
+		},
+	},
+	SQLInsertFailedBroker: {
+		name:       scopeNameSQLInsertFailedBroker,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentAPI,
+		},
+	},
+	QuerySplitByTimeRangeBroker: {
+		name:       scopeNameQuerySplitByTimeRangeBroker,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentAPI,
+		},
+	},
+	TimeRangeChunkFinishedBroker: {
+		name:       scopeNameTimeRangeChunkFinishedBroker,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentAPI,
+		},
+	},
+	TempTableCreatedBroker: {
+		name:       scopeNameTempTableCreatedBroker,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentAPI,
+		},
+	},
+	TempTableCreateFailedBroker: {
+		name:       scopeNameTempTableCreateFailedBroker,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentAPI,
+		},
+	},
+	TempTableJoinedBroker: {
+		name:       scopeNameTempTableJoinedBroker,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentAPI,
+		},
+	},
+	SnapshotMergedBatches: {
+		name:       scopeNameMergedBatches,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagOperation: metricsOperationSnapshot,
+			metricsTagComponent: metricsComponentMemStore,
+		},
+	},
+	CompactionCount: {
+		name:       scopeNameCount,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagOperation: metricsOperationCompaction,
+			metricsTagComponent: metricsComponentMemStore,
+		},
+	},
+	CompactionTimingTotal: {
+		name:       scopeNameTotal,
+		metricType: Timer,
+		tags: map[string]string{
+			metricsTagOperation: metricsOperationCompaction,
+			metricsTagComponent: metricsComponentMemStore,
+		},
+	},
+	CompactionMergedBatches: {
+		name:       scopeNameMergedBatches,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagOperation: metricsOperationCompaction,
+			metricsTagComponent: metricsComponentMemStore,
+		},
+	},
+	CompactionReclaimedRows: {
+		name:       scopeNameReclaimedRows,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagOperation: metricsOperationCompaction,
+			metricsTagComponent: metricsComponentMemStore,
+		},
+	},
+	CompactionLag: {
+		name:       scopeNameCompactionLag,
+		metricType: Gauge,
+		tags: map[string]string{
+			metricsTagOperation: metricsOperationCompaction,
+			metricsTagComponent: metricsComponentMemStore,
+		},
+	},
+	ArchiveBatchesPrefetched: {
+		name:       scopeNameArchiveBatchesPrefetched,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentMemStore,
+		},
+	},
+	RedoLogBatchesCompacted: {
+		name:       scopeNameRedoLogBatchesCompacted,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentDiskStore,
+		},
+	},
+	RedoLogChecksumMismatch: {
+		name:       scopeNameRedoLogChecksumMismatch,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentDiskStore,
+		},
+	},
+	StaleEnumDictRefreshedBroker: {
+		name:       scopeNameStaleEnumDictRefreshedBroker,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentAPI,
+		},
+	},
+	HLLRecomputeTimingTotal: {
+		name:       scopeNameTotal,
+		metricType: Timer,
+		tags: map[string]string{
+			metricsTagOperation: metricsOperationHLLRecompute,
+			metricsTagComponent: metricsComponentMemStore,
+		},
+	},
+	HLLRecomputedBatches: {
+		name:       scopeNameRecomputedBatches,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagOperation: metricsOperationHLLRecompute,
+			metricsTagComponent: metricsComponentMemStore,
+		},
+	},
+	QueryQueueDepthBroker: {
+		name:       scopeNameQueryQueueDepthBroker,
+		metricType: Gauge,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	QueryQueueWaitTimeBroker: {
+		name:       scopeNameQueryQueueWaitTimeBroker,
+		metricType: Timer,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	QueryRejectedBroker: {
+		name:       scopeNameQueryRejectedBroker,
+		metricType: Counter,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	InFlightQueriesBroker: {
+		name:       scopeNameInFlightQueriesBroker,
+		metricType: Gauge,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	ActiveScatterRPCsBroker: {
+		name:       scopeNameActiveScatterRPCsBroker,
+		metricType: Gauge,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
+	OldestInFlightQueryAgeBroker: {
+		name:       scopeNameOldestInFlightQueryAgeBroker,
+		metricType: Gauge,
+		tags: map[string]string{
+			metricsTagComponent: metricsComponentQuery,
+		},
+	},
 }
 
 func (def *metricDefinition) init(rootScope tally.Scope) {
@@ -1066,6 +1690,8 @@ func (def *metricDefinition) init(rootScope tally.Scope) {
 		def.gauge = rootScope.Tagged(def.tags).Gauge(def.name)
 	case Timer:
 		def.timer = rootScope.Tagged(def.tags).Timer(def.name)
+	case Histogram:
+		def.histogram = rootScope.Tagged(def.tags).Histogram(def.name, def.buckets)
 	}
 }
 
@@ -1174,6 +1800,16 @@ func (r *Reporter) GetTimer(n MetricName) tally.Timer {
 	return nil
 }
 
+// GetHistogram returns the tally histogram with corresponding tags.
+func (r *Reporter) GetHistogram(n MetricName) tally.Histogram {
+	def := r.cachedDefinitions[n]
+	if def.metricType == Histogram {
+		return def.histogram
+	}
+	GetLogger().Panicf("Cannot get histogram given %d", n)
+	return nil
+}
+
 // GetChildCounter create tagged child counter from reporter
 func (r *Reporter) GetChildCounter(tags map[string]string, n MetricName) tally.Counter {
 	childScope := r.rootScope.Tagged(tags)
@@ -1207,6 +1843,17 @@ func (r *Reporter) GetChildTimer(tags map[string]string, n MetricName) tally.Tim
 	return nil
 }
 
+// GetChildHistogram create tagged child histogram from reporter
+func (r *Reporter) GetChildHistogram(tags map[string]string, n MetricName) tally.Histogram {
+	childScope := r.rootScope.Tagged(tags)
+	def := r.cachedDefinitions[n]
+	if def.metricType == Histogram {
+		return childScope.Tagged(def.tags).Histogram(def.name, def.buckets)
+	}
+	GetLogger().Panicf("Cannot get child histogram given %d", n)
+	return nil
+}
+
 // GetRootScope returns the root scope wrapped by this reporter.
 func (r *Reporter) GetRootScope() tally.Scope {
 	return r.rootScope
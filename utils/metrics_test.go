@@ -33,6 +33,8 @@ var _ = ginkgo.Describe("metrics", func() {
 				Ω(def.gauge).ShouldNot(BeNil())
 			case Timer:
 				Ω(def.timer).ShouldNot(BeNil())
+			case Histogram:
+				Ω(def.histogram).ShouldNot(BeNil())
 			}
 		}
 	})
@@ -103,6 +105,21 @@ var _ = ginkgo.Describe("metrics", func() {
 		Ω(func() { r.GetTimer(ArchivingLowWatermark) }).Should(Panic())
 	})
 
+	ginkgo.It("GetHistogram should work", func() {
+		scope := tally.NewTestScope("test", nil)
+		r := NewReporter(scope)
+		r.GetHistogram(BatchesScannedBroker).RecordValue(3)
+		r.GetChildHistogram(map[string]string{
+			"table": "test",
+		}, BytesTransferredBroker).RecordValue(2048)
+		Ω(scope.Snapshot().Histograms()).Should(HaveKey("test.batches_scanned_broker+component=query"))
+		Ω(scope.Snapshot().Histograms()).Should(HaveKey("test.datanode_bytes_transferred_broker+component=query,table=test"))
+
+		// Not a histogram.
+		Ω(func() { r.GetHistogram(BackfillLockTiming) }).Should(Panic())
+		Ω(func() { r.GetChildHistogram(nil, BackfillLockTiming) }).Should(Panic())
+	})
+
 	ginkgo.It("GetChildGauge should work", func() {
 		scope := tally.NewTestScope("test", nil)
 		r := NewReporter(scope)
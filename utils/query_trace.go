@@ -0,0 +1,279 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// QueryTraceHeaderKey is the response header (or trailer for streamed responses) carrying json
+	// encoded trace spans of a datanode for broker to merge into the trace of the query.
+	QueryTraceHeaderKey = "X-Ares-Query-Trace"
+	// QueryTraceIDHeaderKey is the response header carrying the ID to download the trace of the
+	// query from the traces endpoint.
+	QueryTraceIDHeaderKey = "X-Ares-Query-Trace-ID"
+	// number of recent query traces kept for download.
+	queryTraceStoreCapacity = 100
+)
+
+// TraceSpan is a span recorded in the trace of a query.
+type TraceSpan struct {
+	Name string `json:"name"`
+	// Process recording the span, eg. broker or datanode.
+	Process string `json:"process"`
+	// Start time in unix microseconds.
+	Start int64 `json:"start"`
+	// Duration in microseconds.
+	Duration int64                  `json:"duration"`
+	Args     map[string]interface{} `json:"args,omitempty"`
+}
+
+// QueryTrace records spans of all stages of a single query when tracing is requested for it, to
+// be exported in chrome tracing format. It's safe for concurrent use.
+type QueryTrace struct {
+	sync.Mutex
+	process string
+	spans   []TraceSpan
+}
+
+// NewQueryTrace creates a QueryTrace recording spans of the process.
+func NewQueryTrace(process string) *QueryTrace {
+	return &QueryTrace{process: process}
+}
+
+type queryTraceContextKey struct{}
+
+// WithQueryTrace returns a copy of ctx carrying the trace, spans started from the returned
+// context and its descendants are recorded in the trace.
+func WithQueryTrace(ctx context.Context, queryTrace *QueryTrace) context.Context {
+	return context.WithValue(ctx, queryTraceContextKey{}, queryTrace)
+}
+
+// QueryTraceFromContext returns the trace carried by ctx, nil if tracing is not requested.
+func QueryTraceFromContext(ctx context.Context) *QueryTrace {
+	queryTrace, _ := ctx.Value(queryTraceContextKey{}).(*QueryTrace)
+	return queryTrace
+}
+
+// Record records a span of the process.
+func (t *QueryTrace) Record(name string, start time.Time, duration time.Duration, args map[string]interface{}) {
+	t.Add(TraceSpan{
+		Name:     name,
+		Process:  t.process,
+		Start:    start.UnixNano() / int64(time.Microsecond),
+		Duration: int64(duration / time.Microsecond),
+		Args:     args,
+	})
+}
+
+// Add adds spans, eg. reported by other processes.
+func (t *QueryTrace) Add(spans ...TraceSpan) {
+	t.Lock()
+	defer t.Unlock()
+	t.spans = append(t.spans, spans...)
+}
+
+// Spans returns a copy of spans recorded.
+func (t *QueryTrace) Spans() []TraceSpan {
+	t.Lock()
+	defer t.Unlock()
+	return append([]TraceSpan(nil), t.spans...)
+}
+
+// ChromeTraceEvent is an event in chrome tracing format, see
+// https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU
+type ChromeTraceEvent struct {
+	Name      string                 `json:"name"`
+	Phase     string                 `json:"ph"`
+	Timestamp int64                  `json:"ts"`
+	Duration  int64                  `json:"dur,omitempty"`
+	PID       int                    `json:"pid"`
+	TID       int                    `json:"tid"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+}
+
+// ChromeTrace is the chrome://tracing compatible json object of a trace.
+type ChromeTrace struct {
+	TraceEvents     []ChromeTraceEvent `json:"traceEvents"`
+	DisplayTimeUnit string             `json:"displayTimeUnit"`
+}
+
+// ChromeTrace exports spans as complete events. Each process gets a pid named after it, spans of a
+// process are laid out on threads so that spans on the same thread are properly nested, eg. shards
+// scanned in parallel are shown on separate threads. Timestamps are relative to the first span.
+func (t *QueryTrace) ChromeTrace() ChromeTrace {
+	spans := t.Spans()
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].Start != spans[j].Start {
+			return spans[i].Start < spans[j].Start
+		}
+		// parent before children starting at the same time.
+		return spans[i].Duration > spans[j].Duration
+	})
+
+	chromeTrace := ChromeTrace{
+		TraceEvents:     []ChromeTraceEvent{},
+		DisplayTimeUnit: "ms",
+	}
+	if len(spans) == 0 {
+		return chromeTrace
+	}
+	base := spans[0].Start
+
+	pids := make(map[string]int)
+	// end times of open spans on each thread by process.
+	threads := make(map[string][][]int64)
+	for _, span := range spans {
+		pid, ok := pids[span.Process]
+		if !ok {
+			pid = len(pids) + 1
+			pids[span.Process] = pid
+			chromeTrace.TraceEvents = append(chromeTrace.TraceEvents, ChromeTraceEvent{
+				Name:  "process_name",
+				Phase: "M",
+				PID:   pid,
+				Args:  map[string]interface{}{"name": span.Process},
+			})
+		}
+
+		end := span.Start + span.Duration
+		stacks := threads[span.Process]
+		tid := len(stacks)
+		for i, stack := range stacks {
+			for len(stack) > 0 && stack[len(stack)-1] <= span.Start {
+				stack = stack[:len(stack)-1]
+			}
+			stacks[i] = stack
+			if len(stack) == 0 || stack[len(stack)-1] >= end {
+				tid = i
+				break
+			}
+		}
+		if tid == len(stacks) {
+			stacks = append(stacks, nil)
+		}
+		stacks[tid] = append(stacks[tid], end)
+		threads[span.Process] = stacks
+
+		chromeTrace.TraceEvents = append(chromeTrace.TraceEvents, ChromeTraceEvent{
+			Name:      span.Name,
+			Phase:     "X",
+			Timestamp: span.Start - base,
+			Duration:  span.Duration,
+			PID:       pid,
+			TID:       tid + 1,
+			Args:      span.Args,
+		})
+	}
+	return chromeTrace
+}
+
+// recordedSpan records the span in the query trace when it ends.
+type recordedSpan struct {
+	trace.Span
+	queryTrace *QueryTrace
+	name       string
+	start      time.Time
+	args       map[string]interface{}
+}
+
+func newRecordedSpan(span trace.Span, queryTrace *QueryTrace, name string, attributes []attribute.KeyValue) *recordedSpan {
+	s := &recordedSpan{
+		Span:       span,
+		queryTrace: queryTrace,
+		name:       name,
+		start:      Now(),
+	}
+	s.setArgs(attributes)
+	return s
+}
+
+func (s *recordedSpan) setArgs(attributes []attribute.KeyValue) {
+	if len(attributes) == 0 {
+		return
+	}
+	if s.args == nil {
+		s.args = make(map[string]interface{})
+	}
+	for _, kv := range attributes {
+		s.args[string(kv.Key)] = kv.Value.AsInterface()
+	}
+}
+
+// SetAttributes sets attributes of the span, which are recorded as args of the span.
+func (s *recordedSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.setArgs(kv)
+	s.Span.SetAttributes(kv...)
+}
+
+// SetStatus sets the status of the span, error description is recorded as arg of the span.
+func (s *recordedSpan) SetStatus(code codes.Code, description string) {
+	if code == codes.Error {
+		s.setArgs([]attribute.KeyValue{attribute.String("error", description)})
+	}
+	s.Span.SetStatus(code, description)
+}
+
+// End records the span and ends it.
+func (s *recordedSpan) End(options ...trace.SpanEndOption) {
+	s.queryTrace.Record(s.name, s.start, Now().Sub(s.start), s.args)
+	s.Span.End(options...)
+}
+
+// QueryTraceStore keeps traces of recent queries in memory for download.
+type QueryTraceStore struct {
+	sync.Mutex
+	capacity int
+	// IDs in insertion order, oldest first.
+	ids    []string
+	traces map[string]*QueryTrace
+}
+
+// NewQueryTraceStore creates a QueryTraceStore keeping traces of the most recent queries.
+func NewQueryTraceStore() *QueryTraceStore {
+	return &QueryTraceStore{
+		capacity: queryTraceStoreCapacity,
+		traces:   make(map[string]*QueryTrace),
+	}
+}
+
+// Put stores the trace, evicting the oldest one when full.
+func (s *QueryTraceStore) Put(id string, queryTrace *QueryTrace) {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.traces[id]; !ok {
+		if len(s.ids) >= s.capacity {
+			delete(s.traces, s.ids[0])
+			s.ids = s.ids[1:]
+		}
+		s.ids = append(s.ids, id)
+	}
+	s.traces[id] = queryTrace
+}
+
+// Get returns the trace with the ID, nil if not found or evicted.
+func (s *QueryTraceStore) Get(id string) *QueryTrace {
+	s.Lock()
+	defer s.Unlock()
+	return s.traces[id]
+}
@@ -0,0 +1,107 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package utils
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var _ = ginkgo.Describe("query trace", func() {
+	ginkgo.AfterEach(func() {
+		ResetClockImplementation()
+	})
+
+	ginkgo.It("StartSpan should record spans in query trace", func() {
+		now := time.Unix(100, 0)
+		SetClockImplementation(func() time.Time {
+			return now
+		})
+
+		// not recorded without query trace.
+		_, span := StartSpan(context.Background(), "untraced")
+		EndSpan(span, nil)
+
+		queryTrace := NewQueryTrace("broker")
+		ctx, span := StartSpan(WithQueryTrace(context.Background(), queryTrace), "parent", attribute.String("table", "t"))
+		now = now.Add(time.Millisecond)
+		_, child := StartSpan(ctx, "child")
+		now = now.Add(time.Millisecond)
+		child.SetAttributes(attribute.Int("rows", 10))
+		EndSpan(child, errors.New("some error"))
+		now = now.Add(time.Millisecond)
+		EndSpan(span, nil)
+
+		Ω(queryTrace.Spans()).Should(Equal([]TraceSpan{
+			{
+				Name:     "child",
+				Process:  "broker",
+				Start:    100001000,
+				Duration: 1000,
+				Args:     map[string]interface{}{"rows": int64(10), "error": "some error"},
+			},
+			{
+				Name:     "parent",
+				Process:  "broker",
+				Start:    100000000,
+				Duration: 3000,
+				Args:     map[string]interface{}{"table": "t"},
+			},
+		}))
+	})
+
+	ginkgo.It("ChromeTrace should lay out spans by process and thread", func() {
+		queryTrace := NewQueryTrace("broker")
+		queryTrace.Add(
+			TraceSpan{Name: "query", Process: "broker", Start: 1000, Duration: 100},
+			TraceSpan{Name: "scan1", Process: "broker", Start: 1010, Duration: 50},
+			// overlaps with scan1 without nesting.
+			TraceSpan{Name: "scan2", Process: "broker", Start: 1020, Duration: 60},
+			TraceSpan{Name: "merge", Process: "broker", Start: 1080, Duration: 10},
+			TraceSpan{Name: "shard", Process: "datanode", Start: 1030, Duration: 20},
+		)
+
+		chromeTrace := queryTrace.ChromeTrace()
+		Ω(chromeTrace.DisplayTimeUnit).Should(Equal("ms"))
+		Ω(chromeTrace.TraceEvents).Should(Equal([]ChromeTraceEvent{
+			{Name: "process_name", Phase: "M", PID: 1, Args: map[string]interface{}{"name": "broker"}},
+			{Name: "query", Phase: "X", Timestamp: 0, Duration: 100, PID: 1, TID: 1},
+			{Name: "scan1", Phase: "X", Timestamp: 10, Duration: 50, PID: 1, TID: 1},
+			{Name: "scan2", Phase: "X", Timestamp: 20, Duration: 60, PID: 1, TID: 2},
+			{Name: "process_name", Phase: "M", PID: 2, Args: map[string]interface{}{"name": "datanode"}},
+			{Name: "shard", Phase: "X", Timestamp: 30, Duration: 20, PID: 2, TID: 1},
+			{Name: "merge", Phase: "X", Timestamp: 80, Duration: 10, PID: 1, TID: 1},
+		}))
+
+		Ω(NewQueryTrace("broker").ChromeTrace().TraceEvents).Should(BeEmpty())
+	})
+
+	ginkgo.It("QueryTraceStore should evict oldest traces", func() {
+		store := NewQueryTraceStore()
+		store.capacity = 2
+		t1, t2, t3 := NewQueryTrace("1"), NewQueryTrace("2"), NewQueryTrace("3")
+		store.Put("1", t1)
+		store.Put("2", t2)
+		store.Put("1", t1)
+		store.Put("3", t3)
+		Ω(store.Get("1")).Should(BeNil())
+		Ω(store.Get("2")).Should(Equal(t2))
+		Ω(store.Get("3")).Should(Equal(t3))
+	})
+})
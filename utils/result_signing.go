@@ -0,0 +1,199 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/uber/aresdb/common"
+)
+
+const (
+	// HTTPResultHashHeaderKey is the header of hex encoded sha256 hash of the response body.
+	HTTPResultHashHeaderKey = "X-Ares-Result-Hash"
+	// HTTPResultSignatureHeaderKey is the header of base64 encoded ed25519 signature of the result hash.
+	HTTPResultSignatureHeaderKey = "X-Ares-Result-Signature"
+	// HTTPResultKeyIDHeaderKey is the header of the id of the key signing the result.
+	HTTPResultKeyIDHeaderKey = "X-Ares-Result-Key-Id"
+
+	// defaultMaxSignedResultBytes is the max size of response bodies buffered for signing if not configured.
+	defaultMaxSignedResultBytes = 64 << 20
+)
+
+// errResultTooLargeToSign is returned to handlers writing more than the max size of signed results.
+var errResultTooLargeToSign = errors.New("result is too large to sign")
+
+// ResultSigner signs query responses with an ed25519 private key. Verifiers only hold the
+// public key of the key id, so they can not forge signed results.
+type ResultSigner struct {
+	keyID        string
+	key          ed25519.PrivateKey
+	maxBodyBytes int
+}
+
+// NewResultSigner creates the result signer, returns nil if result signing is not enabled.
+func NewResultSigner(cfg common.ResultSigningConfig) (*ResultSigner, error) {
+	if !cfg.Enable {
+		return nil, nil
+	}
+	if cfg.PrivateKeyFile == "" {
+		return nil, errors.New("result signing private key file is not configured")
+	}
+	bs, err := ioutil.ReadFile(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, StackError(err, "failed to read result signing private key %s", cfg.PrivateKeyFile)
+	}
+	key, err := ParseResultSigningKey(bs)
+	if err != nil {
+		return nil, StackError(err, "invalid result signing private key %s", cfg.PrivateKeyFile)
+	}
+	maxBodyBytes := cfg.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultMaxSignedResultBytes
+	}
+	return &ResultSigner{keyID: cfg.KeyID, key: key, maxBodyBytes: maxBodyBytes}, nil
+}
+
+// ParseResultSigningKey parses a PEM encoded PKCS #8 ed25519 private key.
+func ParseResultSigningKey(bs []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(bs)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expect ed25519 private key, got %T", key)
+	}
+	return privateKey, nil
+}
+
+// ParseResultVerificationKey parses a PEM encoded PKIX ed25519 public key.
+func ParseResultVerificationKey(bs []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(bs)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("expect ed25519 public key, got %T", key)
+	}
+	return publicKey, nil
+}
+
+// Sign returns the hex encoded sha256 hash of body and the base64 encoded signature of the hash.
+func (s *ResultSigner) Sign(body []byte) (hash string, signature string) {
+	hash = hashResult(body)
+	return hash, base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, []byte(hash)))
+}
+
+func hashResult(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyResultSignature verifies the signature headers of a response against its body, keys
+// are the public keys of signers by key id.
+func VerifyResultSignature(keys map[string]ed25519.PublicKey, header http.Header, body []byte) error {
+	keyID := header.Get(HTTPResultKeyIDHeaderKey)
+	key, ok := keys[keyID]
+	if !ok {
+		return fmt.Errorf("unknown result signing key %s", keyID)
+	}
+	hash := hashResult(body)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(header.Get(HTTPResultHashHeaderKey))) != 1 {
+		return errors.New("result hash does not match")
+	}
+	signature, err := base64.StdEncoding.DecodeString(header.Get(HTTPResultSignatureHeaderKey))
+	if err != nil || !ed25519.Verify(key, []byte(hash), signature) {
+		return errors.New("invalid result signature")
+	}
+	return nil
+}
+
+// bufferedResponseWriter buffers the response so that headers depending on the
+// whole body can be set before it is written. Writes beyond maxBodyBytes fail.
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	statusCode   int
+	body         bytes.Buffer
+	maxBodyBytes int
+	tooLarge     bool
+}
+
+func (w *bufferedResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+func (w *bufferedResponseWriter) Write(bs []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	if w.tooLarge || w.body.Len()+len(bs) > w.maxBodyBytes {
+		w.tooLarge = true
+		w.body.Reset()
+		return 0, errResultTooLargeToSign
+	}
+	return w.body.Write(bs)
+}
+
+// WithResultSigning returns the wrapper signing response bodies, the hash, signature and key
+// id are set in response headers. Since headers are sent before the body, the whole body is
+// buffered in memory until the handler returns, so each request in flight holds up to the max
+// body size of the signer, and streamed results are only sent once complete. Responses larger
+// than that fail instead of being sent unsigned.
+func WithResultSigning(signer *ResultSigner) HTTPHandlerWrapper {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			buffered := &bufferedResponseWriter{ResponseWriter: w, maxBodyBytes: signer.maxBodyBytes}
+			h.ServeHTTP(buffered, r)
+
+			if buffered.tooLarge {
+				http.Error(w, fmt.Sprintf("%s, max size is %d bytes", errResultTooLargeToSign, signer.maxBodyBytes),
+					http.StatusInternalServerError)
+				return
+			}
+			body := buffered.body.Bytes()
+			hash, signature := signer.Sign(body)
+			w.Header().Set(HTTPResultHashHeaderKey, hash)
+			w.Header().Set(HTTPResultSignatureHeaderKey, signature)
+			w.Header().Set(HTTPResultKeyIDHeaderKey, signer.keyID)
+			if buffered.statusCode != 0 {
+				w.WriteHeader(buffered.statusCode)
+			}
+			w.Write(body)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/common"
+)
+
+var _ = ginkgo.Describe("result signing", func() {
+	var dir string
+	var publicKey ed25519.PublicKey
+	var cfg common.ResultSigningConfig
+
+	ginkgo.BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "result_signing")
+		Ω(err).Should(BeNil())
+		var privateKey ed25519.PrivateKey
+		publicKey, privateKey, err = ed25519.GenerateKey(rand.Reader)
+		Ω(err).Should(BeNil())
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		Ω(err).Should(BeNil())
+		cfg = common.ResultSigningConfig{Enable: true, KeyID: "k1", PrivateKeyFile: filepath.Join(dir, "signing.key")}
+		Ω(ioutil.WriteFile(cfg.PrivateKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)).Should(BeNil())
+	})
+
+	ginkgo.AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	ginkgo.It("NewResultSigner should work", func() {
+		signer, err := NewResultSigner(common.ResultSigningConfig{})
+		Ω(err).Should(BeNil())
+		Ω(signer).Should(BeNil())
+
+		_, err = NewResultSigner(common.ResultSigningConfig{Enable: true, KeyID: "k1"})
+		Ω(err).ShouldNot(BeNil())
+
+		_, err = NewResultSigner(common.ResultSigningConfig{Enable: true, KeyID: "k1", PrivateKeyFile: filepath.Join(dir, "missing.key")})
+		Ω(err).ShouldNot(BeNil())
+
+		signer, err = NewResultSigner(cfg)
+		Ω(err).Should(BeNil())
+		Ω(signer).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("ParseResultVerificationKey should work", func() {
+		der, err := x509.MarshalPKIXPublicKey(publicKey)
+		Ω(err).Should(BeNil())
+		key, err := ParseResultVerificationKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		Ω(err).Should(BeNil())
+		Ω(key).Should(Equal(publicKey))
+
+		_, err = ParseResultVerificationKey([]byte("not a key"))
+		Ω(err).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("WithResultSigning should sign response body", func() {
+		signer, err := NewResultSigner(cfg)
+		Ω(err).Should(BeNil())
+		handler := WithResultSigning(signer)(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(HTTPContentTypeHeaderKey, HTTPContentTypeApplicationJson)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"results":`))
+			w.Write([]byte(`[1]}`))
+		})
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodPost, "/query/sql", nil))
+
+		Ω(w.Code).Should(Equal(http.StatusCreated))
+		Ω(w.Body.String()).Should(Equal(`{"results":[1]}`))
+		Ω(w.Header().Get(HTTPContentTypeHeaderKey)).Should(Equal(HTTPContentTypeApplicationJson))
+		Ω(w.Header().Get(HTTPResultKeyIDHeaderKey)).Should(Equal("k1"))
+
+		keys := map[string]ed25519.PublicKey{"k1": publicKey}
+		Ω(VerifyResultSignature(keys, w.Header(), w.Body.Bytes())).Should(BeNil())
+		Ω(VerifyResultSignature(keys, w.Header(), []byte(`{"results":[2]}`))).ShouldNot(BeNil())
+		otherKey, _, _ := ed25519.GenerateKey(rand.Reader)
+		Ω(VerifyResultSignature(map[string]ed25519.PublicKey{"k1": otherKey}, w.Header(), w.Body.Bytes())).ShouldNot(BeNil())
+		Ω(VerifyResultSignature(map[string]ed25519.PublicKey{"k2": publicKey}, w.Header(), w.Body.Bytes())).ShouldNot(BeNil())
+
+		// a verifier holding the public key can not forge signatures of other results.
+		forged := w.Header().Clone()
+		forged.Set(HTTPResultHashHeaderKey, hashResult([]byte(`{"results":[2]}`)))
+		Ω(VerifyResultSignature(keys, forged, []byte(`{"results":[2]}`))).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("WithResultSigning should fail responses larger than max body size", func() {
+		cfg.MaxBodyBytes = 10
+		signer, err := NewResultSigner(cfg)
+		Ω(err).Should(BeNil())
+		var writeErr error
+		handler := WithResultSigning(signer)(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"results":`))
+			_, writeErr = w.Write([]byte(`[1]}`))
+		})
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodPost, "/query/sql", nil))
+
+		Ω(writeErr).ShouldNot(BeNil())
+		Ω(w.Code).Should(Equal(http.StatusInternalServerError))
+		Ω(strings.TrimSpace(w.Body.String())).Should(Equal("result is too large to sign, max size is 10 bytes"))
+		Ω(w.Header().Get(HTTPResultSignatureHeaderKey)).Should(BeEmpty())
+	})
+})
@@ -21,6 +21,9 @@ import (
 
 const (
 	secondsInHour = 3600
+	// utc offset changes of a timezone are months apart, so that a window of this size
+	// contains at most one of them.
+	timezoneTransitionWindowSeconds = 7 * 24 * secondsInHour
 )
 
 // NowFunc type for function of getting current time
@@ -117,3 +120,88 @@ func AdjustOffset(fromOffset, toOffset int, switchTs, ts int64) int64 {
 	}
 	return ts - int64(offset)
 }
+
+// TimezoneTransition is a change of utc offset at Ts.
+type TimezoneTransition struct {
+	Ts     int64
+	Offset int
+}
+
+// TimezoneTransitions are the utc offsets in effect within a time range, as the offset at
+// start of the range followed by its changes in time order.
+type TimezoneTransitions struct {
+	InitialOffset int
+	Transitions   []TimezoneTransition
+}
+
+// CalculateTimezoneTransitions calculates utc offsets of loc within [fromTs, toTs].
+func CalculateTimezoneTransitions(fromTs, toTs int64, loc *time.Location) TimezoneTransitions {
+	return calculateOffsetTransitions(fromTs, toTs, func(ts int64) int {
+		_, offset := time.Unix(ts, 0).In(loc).Zone()
+		return offset
+	})
+}
+
+// CalculateTimezoneDiffTransitions calculates the offsets from fromLoc to toLoc (utc offset
+// of toLoc minus utc offset of fromLoc) within [fromTs, toTs].
+func CalculateTimezoneDiffTransitions(fromTs, toTs int64, fromLoc, toLoc *time.Location) TimezoneTransitions {
+	return calculateOffsetTransitions(fromTs, toTs, func(ts int64) int {
+		t := time.Unix(ts, 0)
+		_, fromOffset := t.In(fromLoc).Zone()
+		_, toOffset := t.In(toLoc).Zone()
+		return toOffset - fromOffset
+	})
+}
+
+func calculateOffsetTransitions(fromTs, toTs int64, offsetAt func(ts int64) int) TimezoneTransitions {
+	offset := offsetAt(fromTs)
+	transitions := TimezoneTransitions{InitialOffset: offset}
+	for start := fromTs; start < toTs; {
+		end := start + timezoneTransitionWindowSeconds
+		if end > toTs {
+			end = toTs
+		}
+		if offsetAt(end) == offset {
+			start = end
+			continue
+		}
+		// the offset changes at hi, the first second in (lo, hi] with a different offset.
+		lo, hi := start, end
+		for hi-lo > 1 {
+			mid := lo + (hi-lo)/2
+			if offsetAt(mid) == offset {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		offset = offsetAt(hi)
+		transitions.Transitions = append(transitions.Transitions, TimezoneTransition{Ts: hi, Offset: offset})
+		start = hi
+	}
+	return transitions
+}
+
+// Offset returns the utc offset in effect at ts.
+func (t *TimezoneTransitions) Offset(ts int64) int {
+	offset := t.InitialOffset
+	for _, transition := range t.Transitions {
+		if ts < transition.Ts {
+			break
+		}
+		offset = transition.Offset
+	}
+	return offset
+}
+
+// ToUTC converts ts in local time (utc timestamp plus its offset) back to utc timestamp.
+func (t *TimezoneTransitions) ToUTC(ts int64) int64 {
+	offset := t.InitialOffset
+	for _, transition := range t.Transitions {
+		if ts < transition.Ts+int64(transition.Offset) {
+			break
+		}
+		offset = transition.Offset
+	}
+	return ts - int64(offset)
+}
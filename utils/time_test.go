@@ -70,4 +70,34 @@ var _ = ginkgo.Describe("Time", func() {
 		Ω(err).Should(BeNil())
 		Ω(switchTs).Should(Equal(int64(1509872400)))
 	})
+
+	ginkgo.It("CalculateTimezoneTransitions should work", func() {
+		loc, _ := time.LoadLocation("America/Los_Angeles")
+		// 2019
+		transitions := CalculateTimezoneTransitions(1546300800, 1577836800, loc)
+		Ω(transitions).Should(Equal(TimezoneTransitions{
+			InitialOffset: -28800,
+			Transitions: []TimezoneTransition{
+				{Ts: 1552212000, Offset: -25200},
+				{Ts: 1572771600, Offset: -28800},
+			},
+		}))
+		Ω(transitions.Offset(1552211999)).Should(Equal(-28800))
+		Ω(transitions.Offset(1552212000)).Should(Equal(-25200))
+		Ω(transitions.Offset(1577836800)).Should(Equal(-28800))
+
+		// local midnights before and after the switch to daylight saving time.
+		Ω(transitions.ToUTC(1552176000)).Should(Equal(int64(1552204800)))
+		Ω(transitions.ToUTC(1552262400)).Should(Equal(int64(1552287600)))
+
+		Ω(CalculateTimezoneTransitions(1546300800, 1548979200, loc).Transitions).Should(BeEmpty())
+
+		utc, _ := time.LoadLocation("UTC")
+		transitions = CalculateTimezoneDiffTransitions(1546300800, 1577836800, loc, utc)
+		Ω(transitions.InitialOffset).Should(Equal(28800))
+		Ω(transitions.Transitions).Should(Equal([]TimezoneTransition{
+			{Ts: 1552212000, Offset: 25200},
+			{Ts: 1572771600, Offset: 28800},
+		}))
+	})
 })
@@ -0,0 +1,280 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/uber/aresdb/common"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultTLSReloadIntervalSeconds = 60
+
+// tlsReloader is the process wide certificate reloader, nil if tls is not enabled.
+var tlsReloader *certReloader
+
+// certReloader holds the certificate and CA pool loaded from files and reloads
+// them when any of the files is modified.
+type certReloader struct {
+	sync.RWMutex
+
+	cfg      common.TLSConfig
+	cert     *tls.Certificate
+	caPool   *x509.CertPool
+	modTimes map[string]time.Time
+}
+
+func newCertReloader(cfg common.TLSConfig) (*certReloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, StackError(nil, "cert_file and key_file are required for tls")
+	}
+	if cfg.MutualAuth && cfg.CAFile == "" {
+		return nil, StackError(nil, "ca_file is required for tls mutual auth")
+	}
+	r := &certReloader{cfg: cfg}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) files() []string {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.CAFile != "" {
+		files = append(files, r.cfg.CAFile)
+	}
+	return files
+}
+
+// load reads all files and swaps in the new certificate and CA pool, the
+// current ones are kept if any of the files is invalid.
+func (r *certReloader) load() error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return StackError(err, "failed to stat %s", file)
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return StackError(err, "failed to load certificate %s", r.cfg.CertFile)
+	}
+
+	var caPool *x509.CertPool
+	if r.cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(r.cfg.CAFile)
+		if err != nil {
+			return StackError(err, "failed to read ca file %s", r.cfg.CAFile)
+		}
+		caPool = x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM(pem) {
+			return StackError(nil, "no certificate found in ca file %s", r.cfg.CAFile)
+		}
+	}
+
+	r.Lock()
+	r.cert = &cert
+	r.caPool = caPool
+	r.modTimes = modTimes
+	r.Unlock()
+	return nil
+}
+
+// changed returns whether any of the files is modified since last load.
+func (r *certReloader) changed() bool {
+	r.RLock()
+	defer r.RUnlock()
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			// file may be in the middle of being replaced.
+			continue
+		}
+		if !info.ModTime().Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *certReloader) reloadIfChanged() {
+	if !r.changed() {
+		return
+	}
+	if err := r.load(); err != nil {
+		GetLogger().With("error", err).Error("Failed to reload tls certificates")
+		return
+	}
+	GetLogger().With("cert", r.cfg.CertFile).Info("Reloaded tls certificates")
+}
+
+func (r *certReloader) watch(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.reloadIfChanged()
+		case <-done:
+			return
+		}
+	}
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.RLock()
+	defer r.RUnlock()
+	return r.cert, nil
+}
+
+func (r *certReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.RLock()
+	defer r.RUnlock()
+	return r.cert, nil
+}
+
+func (r *certReloader) getCAPool() *x509.CertPool {
+	r.RLock()
+	defer r.RUnlock()
+	return r.caPool
+}
+
+// InitTLS loads the configured certificates and keeps reloading them when the
+// files change. All listeners started with Listen and clients using
+// ClientTLSConfig or HTTPClientTransport use tls afterwards.
+// The returned function stops watching the files.
+func InitTLS(cfg common.TLSConfig) (shutdown func(), err error) {
+	shutdown = func() {}
+	tlsReloader = nil
+	if !cfg.Enable {
+		return
+	}
+
+	var reloader *certReloader
+	reloader, err = newCertReloader(cfg)
+	if err != nil {
+		return
+	}
+
+	interval := cfg.ReloadIntervalSeconds
+	if interval <= 0 {
+		interval = defaultTLSReloadIntervalSeconds
+	}
+	done := make(chan struct{})
+	go reloader.watch(time.Duration(interval)*time.Second, done)
+
+	tlsReloader = reloader
+	shutdown = func() {
+		close(done)
+	}
+	return
+}
+
+// TLSEnabled returns whether tls is initialized.
+func TLSEnabled() bool {
+	return tlsReloader != nil
+}
+
+// HTTPScheme returns the url scheme to talk to other nodes.
+func HTTPScheme() string {
+	if TLSEnabled() {
+		return "https"
+	}
+	return "http"
+}
+
+// ServerTLSConfig returns the tls config for servers, nil if tls is not enabled.
+// Client certificates are verified if verifyClient is true and mutual auth is configured.
+func ServerTLSConfig(verifyClient bool) *tls.Config {
+	reloader := tlsReloader
+	if reloader == nil {
+		return nil
+	}
+
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.getCertificate,
+		// http2 is required for grpc served on the same port.
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	if verifyClient && reloader.cfg.MutualAuth {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		// clone per handshake so that reloaded CAs are picked up.
+		cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			handshakeCfg := cfg.Clone()
+			handshakeCfg.GetConfigForClient = nil
+			handshakeCfg.ClientCAs = reloader.getCAPool()
+			return handshakeCfg, nil
+		}
+	}
+	return cfg
+}
+
+// ClientTLSConfig returns the tls config for connecting to other nodes, nil if
+// tls is not enabled. The config snapshots the current CA pool so it should be
+// created for every new connection.
+func ClientTLSConfig() *tls.Config {
+	reloader := tlsReloader
+	if reloader == nil {
+		return nil
+	}
+	return &tls.Config{
+		MinVersion:           tls.VersionTLS12,
+		RootCAs:              reloader.getCAPool(),
+		GetClientCertificate: reloader.getClientCertificate,
+	}
+}
+
+// HTTPClientTransport returns the transport for http clients talking to other
+// nodes, nil means the default transport if tls is not enabled.
+func HTTPClientTransport() http.RoundTripper {
+	if !TLSEnabled() {
+		return nil
+	}
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialTLS: func(network, addr string) (net.Conn, error) {
+			return tls.DialWithDialer(dialer, network, addr, ClientTLSConfig())
+		},
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// Listen listens on the port, connections are served over tls if enabled.
+func Listen(port int, verifyClient bool) (net.Listener, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, err
+	}
+	if cfg := ServerTLSConfig(verifyClient); cfg != nil {
+		listener = tls.NewListener(listener, cfg)
+	}
+	return listener, nil
+}
@@ -0,0 +1,178 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/common"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// writeTestCert writes a certificate signed by parent (self signed if nil) and its key
+// into dir and returns the certificate and key.
+func writeTestCert(dir, name string, serial int64, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Ω(err).Should(BeNil())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	Ω(err).Should(BeNil())
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	Ω(err).Should(BeNil())
+	Ω(ioutil.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)).Should(BeNil())
+	Ω(ioutil.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)).Should(BeNil())
+	cert, err := x509.ParseCertificate(der)
+	Ω(err).Should(BeNil())
+	return cert, key
+}
+
+var _ = ginkgo.Describe("tls", func() {
+	var dir string
+	var cfg common.TLSConfig
+	var ca *x509.Certificate
+	var caKey *ecdsa.PrivateKey
+
+	ginkgo.BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "aresdb_tls")
+		Ω(err).Should(BeNil())
+		ca, caKey = writeTestCert(dir, "ca", 1, nil, nil)
+		writeTestCert(dir, "node", 2, ca, caKey)
+		cfg = common.TLSConfig{
+			Enable:     true,
+			CertFile:   filepath.Join(dir, "node.crt"),
+			KeyFile:    filepath.Join(dir, "node.key"),
+			CAFile:     filepath.Join(dir, "ca.crt"),
+			MutualAuth: true,
+		}
+	})
+
+	ginkgo.AfterEach(func() {
+		InitTLS(common.TLSConfig{})
+		os.RemoveAll(dir)
+	})
+
+	ginkgo.It("InitTLS should work", func() {
+		shutdown, err := InitTLS(common.TLSConfig{})
+		Ω(err).Should(BeNil())
+		shutdown()
+		Ω(TLSEnabled()).Should(BeFalse())
+		Ω(HTTPScheme()).Should(Equal("http"))
+		Ω(ServerTLSConfig(true)).Should(BeNil())
+		Ω(ClientTLSConfig()).Should(BeNil())
+		Ω(HTTPClientTransport()).Should(BeNil())
+
+		_, err = InitTLS(common.TLSConfig{Enable: true})
+		Ω(err).ShouldNot(BeNil())
+		_, err = InitTLS(common.TLSConfig{Enable: true, CertFile: cfg.CertFile, KeyFile: cfg.KeyFile, MutualAuth: true})
+		Ω(err).ShouldNot(BeNil())
+		_, err = InitTLS(common.TLSConfig{Enable: true, CertFile: cfg.CertFile, KeyFile: cfg.CAFile})
+		Ω(err).ShouldNot(BeNil())
+
+		shutdown, err = InitTLS(cfg)
+		Ω(err).Should(BeNil())
+		defer shutdown()
+		Ω(TLSEnabled()).Should(BeTrue())
+		Ω(HTTPScheme()).Should(Equal("https"))
+		Ω(ServerTLSConfig(true).ClientAuth).Should(Equal(tls.RequireAndVerifyClientCert))
+		Ω(ServerTLSConfig(false).ClientAuth).Should(Equal(tls.NoClientCert))
+		Ω(ClientTLSConfig().RootCAs).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("should serve and connect over mutual tls", func() {
+		shutdown, err := InitTLS(cfg)
+		Ω(err).Should(BeNil())
+		defer shutdown()
+
+		listener, err := Listen(0, true)
+		Ω(err).Should(BeNil())
+		defer listener.Close()
+		go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+		}))
+		url := fmt.Sprintf("https://127.0.0.1:%d/", listener.Addr().(*net.TCPAddr).Port)
+
+		client := http.Client{Transport: HTTPClientTransport()}
+		resp, err := client.Get(url)
+		Ω(err).Should(BeNil())
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		Ω(err).Should(BeNil())
+		Ω(string(body)).Should(Equal("node"))
+
+		// clients without certificate are rejected.
+		pool := x509.NewCertPool()
+		pool.AddCert(ca)
+		client = http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+		_, err = client.Get(url)
+		Ω(err).ShouldNot(BeNil())
+	})
+
+	ginkgo.It("should reload changed certificates", func() {
+		reloader, err := newCertReloader(cfg)
+		Ω(err).Should(BeNil())
+		cert, _ := reloader.getCertificate(nil)
+
+		// nothing changed.
+		reloader.reloadIfChanged()
+		newCert, _ := reloader.getCertificate(nil)
+		Ω(newCert).Should(BeIdenticalTo(cert))
+
+		writeTestCert(dir, "node", 3, ca, caKey)
+		future := time.Now().Add(time.Minute)
+		Ω(os.Chtimes(cfg.CertFile, future, future)).Should(BeNil())
+		reloader.reloadIfChanged()
+		newCert, _ = reloader.getCertificate(nil)
+		Ω(newCert).ShouldNot(BeIdenticalTo(cert))
+		leaf, err := x509.ParseCertificate(newCert.Certificate[0])
+		Ω(err).Should(BeNil())
+		Ω(leaf.SerialNumber.Int64()).Should(BeEquivalentTo(3))
+
+		// invalid files keep the current certificate.
+		cert = newCert
+		Ω(ioutil.WriteFile(cfg.KeyFile, []byte("invalid"), 0600)).Should(BeNil())
+		future = future.Add(time.Minute)
+		Ω(os.Chtimes(cfg.KeyFile, future, future)).Should(BeNil())
+		reloader.reloadIfChanged()
+		newCert, _ = reloader.getCertificate(nil)
+		Ω(newCert).Should(BeIdenticalTo(cert))
+	})
+})
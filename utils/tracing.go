@@ -0,0 +1,99 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"github.com/uber/aresdb/common"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+const tracerName = "github.com/uber/aresdb"
+
+// InitTracing sets up the global OpenTelemetry tracer provider exporting spans to jaeger
+// and the W3C trace context propagator. Spans are no-op if tracing is not enabled.
+// The returned function flushes pending spans and should be called before exiting.
+func InitTracing(serviceName string, cfg common.TracingConfig) (shutdown func(), err error) {
+	shutdown = func() {}
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if !cfg.Enable {
+		return
+	}
+
+	var exporter *jaeger.Exporter
+	exporter, err = jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(cfg.CollectorEndpoint)))
+	if err != nil {
+		return
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SampleRate > 0 && cfg.SampleRate < 1 {
+		sampler = sdktrace.TraceIDRatioBased(cfg.SampleRate)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		// follow the sampling decision of upstream services so that traces are complete.
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	shutdown = func() {
+		if err := provider.Shutdown(context.Background()); err != nil {
+			GetLogger().With("error", err).Error("Failed to shutdown tracer provider")
+		}
+	}
+	return
+}
+
+// StartSpan starts a span as a child of the span in ctx if any, the returned context
+// carries the new span and should be passed down to create child spans. The span is also
+// recorded in the query trace carried by ctx if any.
+func StartSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attributes...))
+	if queryTrace := QueryTraceFromContext(ctx); queryTrace != nil {
+		return ctx, newRecordedSpan(span, queryTrace, name, attributes)
+	}
+	return ctx, span
+}
+
+// EndSpan records err if not nil and ends the span.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// InjectTraceContext writes the span context in ctx into http headers of outgoing requests.
+func InjectTraceContext(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// ExtractTraceContext returns a context carrying the remote span context from http headers
+// of incoming requests.
+func ExtractTraceContext(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
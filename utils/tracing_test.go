@@ -0,0 +1,60 @@
+//  Copyright (c) 2017-2018 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/uber/aresdb/common"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+var _ = ginkgo.Describe("tracing", func() {
+	ginkgo.It("InitTracing should work when disabled", func() {
+		shutdown, err := InitTracing(AresBroker, common.TracingConfig{})
+		Ω(err).Should(BeNil())
+		Ω(shutdown).ShouldNot(BeNil())
+		shutdown()
+	})
+
+	ginkgo.It("trace context should be propagated through http headers", func() {
+		_, err := InitTracing(AresBroker, common.TracingConfig{})
+		Ω(err).Should(BeNil())
+
+		traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+		spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     spanID,
+			TraceFlags: trace.FlagsSampled,
+		}))
+
+		header := http.Header{}
+		InjectTraceContext(ctx, header)
+		Ω(header.Get("traceparent")).Should(Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"))
+
+		ctx = ExtractTraceContext(context.Background(), header)
+		ctx, span := StartSpan(ctx, "test")
+		Ω(trace.SpanContextFromContext(ctx).TraceID()).Should(Equal(traceID))
+		EndSpan(span, errors.New("some error"))
+
+		// no trace context in headers.
+		ctx = ExtractTraceContext(context.Background(), http.Header{})
+		Ω(trace.SpanContextFromContext(ctx).IsValid()).Should(BeFalse())
+	})
+})